@@ -20,10 +20,13 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/apisrv/admin"
 	"github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
 	"github.com/jekabolt/grbpwr-manager/internal/apisrv/frontend"
+	"github.com/jekabolt/grbpwr-manager/internal/auditlog"
 	"github.com/jekabolt/grbpwr-manager/internal/auth/pwhash"
 	"github.com/jekabolt/grbpwr-manager/internal/bucket"
+	"github.com/jekabolt/grbpwr-manager/internal/bundleticket"
 	"github.com/jekabolt/grbpwr-manager/internal/cache"
 	"github.com/jekabolt/grbpwr-manager/internal/campaigndispatch"
+	"github.com/jekabolt/grbpwr-manager/internal/checkoutrecovery"
 	"github.com/jekabolt/grbpwr-manager/internal/circuitbreaker"
 	"github.com/jekabolt/grbpwr-manager/internal/deliverysync"
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
@@ -34,6 +37,7 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/health"
 	"github.com/jekabolt/grbpwr-manager/internal/jpk"
 	"github.com/jekabolt/grbpwr-manager/internal/mail"
+	"github.com/jekabolt/grbpwr-manager/internal/markdownsched"
 	"github.com/jekabolt/grbpwr-manager/internal/marketingaggregate"
 	"github.com/jekabolt/grbpwr-manager/internal/openrouter"
 	"github.com/jekabolt/grbpwr-manager/internal/opexmaterialize"
@@ -42,12 +46,16 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/payment/stripe"
 	"github.com/jekabolt/grbpwr-manager/internal/revalidation"
 	"github.com/jekabolt/grbpwr-manager/internal/runpackaccess"
+	"github.com/jekabolt/grbpwr-manager/internal/search"
 	"github.com/jekabolt/grbpwr-manager/internal/shippinglabel"
+	"github.com/jekabolt/grbpwr-manager/internal/specsheet"
 	"github.com/jekabolt/grbpwr-manager/internal/stockreserve"
 	"github.com/jekabolt/grbpwr-manager/internal/store"
 	"github.com/jekabolt/grbpwr-manager/internal/storefrontcleanup"
 	"github.com/jekabolt/grbpwr-manager/internal/stripereconcile"
+	"github.com/jekabolt/grbpwr-manager/internal/subcontractportal"
 	"github.com/jekabolt/grbpwr-manager/internal/tiermanagement"
+	"github.com/jekabolt/grbpwr-manager/internal/wishlistnotify"
 )
 
 var commitHash string
@@ -70,16 +78,21 @@ type App struct {
 	oc   *ordercleanup.Worker
 	dsw  *deliverysync.Worker
 	sc   *storefrontcleanup.Worker
+	arw  *auditlog.RetentionWorker
 	tm   *tiermanagement.Worker
 	maw  *marketingaggregate.Worker
 	om   *opexmaterialize.Worker
+	mds  *markdownsched.Worker
+	sx   *search.Indexer
+	wln  *wishlistnotify.Worker
+	cr   *checkoutrecovery.Worker
 	ap   *acctposting.Worker
 	sr   *stripereconcile.Worker
 	fxw  *fxsync.Worker
 	ga4w *ga4sync.Worker
 	bqc  dependency.BQClient
 	re   dependency.RevalidationService
-	rm   *stockreserve.Manager
+	rm   dependency.StockReservationManager
 	// Stripe processors (live + test). Held so their in-process payment monitors
 	// can be stopped on shutdown before the DB is closed.
 	stripeMain *stripe.Processor
@@ -92,6 +105,12 @@ type App struct {
 	patternSvc *patternaccess.Service
 	// runPackSvc is retained for the same reason: it debounces run-pack access stats.
 	runPackSvc *runpackaccess.Service
+	// bundleTicketSvc owns the rate limiters of the public bundle ticket; Stop releases them.
+	bundleTicketSvc *bundleticket.Service
+	// subcontractPortalSvc owns the rate limiters of the factory portal, same as above.
+	subcontractPortalSvc *subcontractportal.Service
+	// specSheetSvc owns the rate limiters of the release spec sheet link, same as above.
+	specSheetSvc *specsheet.Service
 	// fileLinkSvc is retained for the same reason again: the public file link debounces its
 	// hit counters, and the flush writes rows — so it must stop while the DB is still open.
 	fileLinkSvc *fileaccess.Service
@@ -184,7 +203,15 @@ func (a *App) Start(ctx context.Context) error {
 		return err
 	}
 
-	reservationMgr := stockreserve.NewDefaultManager()
+	// Stock holds live in process memory or, with stock_reserve.backend=mysql, in the
+	// stock_reservation table shared by every instance.
+	reservationMgr, err := stockreserve.New(a.c.StockReserve, a.db)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "couldn't construct stock reservation manager",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
 	a.rm = reservationMgr
 	// NOTE: the order cleanup worker is created later, after the Stripe
 	// processors exist, so its safety-net expiry can verify payment with Stripe.
@@ -197,6 +224,14 @@ func (a *App) Start(ctx context.Context) error {
 		return err
 	}
 
+	a.arw = auditlog.NewRetentionWorker(&a.c.Audit, a.db.Audit(), a.db.Now)
+	if err = a.arw.Start(ctx); err != nil {
+		slog.Default().ErrorContext(ctx, "couldn't start audit retention worker",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+
 	a.tm = tiermanagement.New(&a.c.TierManagement, a.db, a.ma)
 	if err = a.tm.Start(ctx); err != nil {
 		slog.Default().ErrorContext(ctx, "couldn't start tier management worker",
@@ -370,6 +405,45 @@ func (a *App) Start(ctx context.Context) error {
 		a.re = rev
 	}
 
+	// Scheduled markdowns: started after the revalidator exists (every sweep that moves a sale
+	// revalidates the storefront) and after the base currency is set.
+	a.mds = markdownsched.New(&a.c.MarkdownSchedule, a.db, a.re)
+	if err = a.mds.Start(ctx); err != nil {
+		slog.Default().ErrorContext(ctx, "couldn't start markdown schedule worker",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+
+	// Storefront search: the first index builds in the background; until it is ready the search
+	// RPCs answer Unavailable instead of delaying startup.
+	a.sx = search.New(&a.c.Search, a.db)
+	if err = a.sx.Start(ctx); err != nil {
+		slog.Default().ErrorContext(ctx, "couldn't start search indexer",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+
+	// Wishlist alerts: needs the mailer; the first tick only records what every wishlist item looks
+	// like, so a restart never mails anything by itself.
+	a.wln = wishlistnotify.New(&a.c.WishlistNotify, a.db, a.ma)
+	if err = a.wln.Start(ctx); err != nil {
+		slog.Default().ErrorContext(ctx, "couldn't start wishlist notify worker",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+
+	// Abandoned checkout recovery: needs the mailer; closes purchased checkouts before mailing.
+	a.cr = checkoutrecovery.New(&a.c.CheckoutRecovery, a.db, a.ma)
+	if err = a.cr.Start(ctx); err != nil {
+		slog.Default().ErrorContext(ctx, "couldn't start checkout recovery worker",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+
 	// GA4 Analytics integration
 	ga4Client, err := ga4.NewClient(ctx, &a.c.GA4)
 	if err != nil {
@@ -438,6 +512,8 @@ func (a *App) Start(ctx context.Context) error {
 		Email:     a.c.JPK.Email,
 		Phone:     a.c.JPK.Phone,
 		TaxOffice: a.c.JPK.TaxOffice,
+		AddressL1: a.c.JPK.AddressL1,
+		AddressL2: a.c.JPK.AddressL2,
 	}, a.c.Accounting.NormalLossRate())
 	if err != nil {
 		slog.Default().ErrorContext(ctx, "failed to create admin server",
@@ -448,7 +524,7 @@ func (a *App) Start(ctx context.Context) error {
 	a.adminS = adminS
 
 	var frontendS *frontend.Server
-	frontendS, err = frontend.New(a.db, a.ma, stripeMain, stripeTest, a.re, reservationMgr, &a.c.StorefrontAuth)
+	frontendS, err = frontend.New(a.db, a.ma, stripeMain, stripeTest, a.re, reservationMgr, a.sx, &a.c.StorefrontAuth)
 	if err != nil {
 		slog.Default().ErrorContext(ctx, "failed create frontend server",
 			slog.String("err", err.Error()),
@@ -511,6 +587,46 @@ func (a *App) Start(ctx context.Context) error {
 	a.hs.SetRunPackHandler(runPackSvc.Handler())
 	a.adminS.SetRunPackTokenService(runPackSvc)
 
+	// Ярлыки пачек (/api/bt/{token}): тот же pepper, свой скоуп ('b'). Статистики доступа нет —
+	// запись о работе и есть след сканирования, — поэтому и сбрасывать на остановке нечего, кроме
+	// лимитеров.
+	bundleTicketSvc, err := bundleticket.New(a.db.ProductionRuns(), a.c.PatternToken.Pepper)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "failed to create bundle ticket service",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+	a.bundleTicketSvc = bundleTicketSvc
+	a.hs.SetBundleTicketHandler(bundleTicketSvc.Handler())
+	a.adminS.SetBundleTicketService(bundleTicketSvc)
+
+	// Портал фабрики на подряде (/api/cmt/{token}): тот же pepper, свой скоуп ('s'). Как у ярлыков
+	// пачек — статистики нет, подтверждение фабрики и есть её след.
+	subcontractPortalSvc, err := subcontractportal.New(a.db.ProductionRuns(), a.c.PatternToken.Pepper)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "failed to create subcontract portal service",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+	a.subcontractPortalSvc = subcontractPortalSvc
+	a.hs.SetSubcontractPortalHandler(subcontractPortalSvc.Handler())
+	a.adminS.SetSubcontractPortalService(subcontractPortalSvc)
+
+	// PDF-спецификация релиза (/api/ts/{token}): тот же pepper, свой скоуп ('t'). Эскизы читаются из
+	// бакета — файл уезжает на фабрику целиком, без ссылок на CDN.
+	specSheetSvc, err := specsheet.New(a.db.TechCards(), a.b, a.c.PatternToken.Pepper)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "failed to create spec sheet service",
+			slog.String("err", err.Error()),
+		)
+		return err
+	}
+	a.specSheetSvc = specSheetSvc
+	a.hs.SetSpecSheetHandler(specSheetSvc.Handler())
+	a.adminS.SetSpecSheetService(specSheetSvc)
+
 	// Публичная ссылка на файл библиотеки (/api/f/{token}, Ф7): та же капабилити-схема и тот же
 	// pepper — скоуп ('f') подписан вместе с id, поэтому один секрет обслуживает четыре
 	// непересекающихся пространства идентичности. Base url нужен ЗДЕСЬ (в отличие от наряда):
@@ -581,6 +697,9 @@ func (a *App) Start(ctx context.Context) error {
 	// are skipped so the endpoint reflects what is actually running.
 	a.hs.SetHealthRegistry(a.buildHealthRegistry(ga4Client))
 
+	// Admin audit trail: every mutating admin RPC that passed the auth interceptor is recorded.
+	a.hs.SetAuditInterceptor(auditlog.NewRecorder(a.db.Audit(), auth.GetAdminUsername, a.db.Now).UnaryServerInterceptor())
+
 	if err = a.hs.Start(ctx, adminS, frontendS, authS); err != nil {
 		slog.Default().ErrorContext(ctx, "cannot start http server")
 		return err
@@ -636,6 +755,15 @@ func (a *App) Stop(ctx context.Context) {
 	if a.runPackSvc != nil {
 		a.runPackSvc.Stop()
 	}
+	if a.bundleTicketSvc != nil {
+		a.bundleTicketSvc.Stop()
+	}
+	if a.subcontractPortalSvc != nil {
+		a.subcontractPortalSvc.Stop()
+	}
+	if a.specSheetSvc != nil {
+		a.specSheetSvc.Stop()
+	}
 	// И для публичной ссылки на файл — по тому же договору: её сброс тоже пишет строки.
 	if a.fileLinkSvc != nil {
 		a.fileLinkSvc.Stop()
@@ -678,6 +806,9 @@ func (a *App) Stop(ctx context.Context) {
 	if a.sc != nil {
 		_ = a.sc.Stop()
 	}
+	if a.arw != nil {
+		_ = a.arw.Stop()
+	}
 	if a.tm != nil {
 		_ = a.tm.Stop()
 	}
@@ -687,6 +818,18 @@ func (a *App) Stop(ctx context.Context) {
 	if a.om != nil {
 		_ = a.om.Stop()
 	}
+	if a.mds != nil {
+		_ = a.mds.Stop()
+	}
+	if a.sx != nil {
+		_ = a.sx.Stop()
+	}
+	if a.wln != nil {
+		_ = a.wln.Stop()
+	}
+	if a.cr != nil {
+		_ = a.cr.Stop()
+	}
 	if a.ap != nil {
 		_ = a.ap.Stop()
 	}
@@ -700,7 +843,8 @@ func (a *App) Stop(ctx context.Context) {
 		_ = a.ga4w.Stop()
 	}
 
-	// Stop the in-memory stock reservation manager's cleanup goroutine.
+	// Stop the stock reservation manager's cleanup/sweep goroutine. The MySQL
+	// backend waits for an in-flight sweep, so this must run before the DB closes.
 	if a.rm != nil {
 		a.rm.Stop()
 	}
@@ -767,6 +911,9 @@ func (a *App) buildHealthRegistry(ga4Client *ga4.Client) *health.Registry {
 	if a.sc != nil {
 		addWorker(a.sc)
 	}
+	if a.arw != nil {
+		addWorker(a.arw)
+	}
 	if a.tm != nil {
 		addWorker(a.tm)
 	}
@@ -776,6 +923,18 @@ func (a *App) buildHealthRegistry(ga4Client *ga4.Client) *health.Registry {
 	if a.om != nil {
 		addWorker(a.om)
 	}
+	if a.mds != nil {
+		addWorker(a.mds)
+	}
+	if a.sx != nil {
+		addWorker(a.sx)
+	}
+	if a.wln != nil {
+		addWorker(a.wln)
+	}
+	if a.cr != nil {
+		addWorker(a.cr)
+	}
 	if a.ap != nil {
 		addWorker(a.ap)
 	}
@@ -788,8 +947,8 @@ func (a *App) buildHealthRegistry(ga4Client *ga4.Client) *health.Registry {
 	if a.ga4w != nil {
 		addWorker(a.ga4w)
 	}
-	if a.rm != nil {
-		addWorker(a.rm)
+	if r, ok := a.rm.(health.Reporter); ok {
+		addWorker(r)
 	}
 
 	// DB pool stats (only the MySQL store exposes them).
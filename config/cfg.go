@@ -15,11 +15,14 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/analytics/ga4sync"
 	httpapi "github.com/jekabolt/grbpwr-manager/internal/api/http"
 	"github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/auditlog"
 	"github.com/jekabolt/grbpwr-manager/internal/bucket"
 	"github.com/jekabolt/grbpwr-manager/internal/campaigndispatch"
+	"github.com/jekabolt/grbpwr-manager/internal/checkoutrecovery"
 	"github.com/jekabolt/grbpwr-manager/internal/deliverysync"
 	"github.com/jekabolt/grbpwr-manager/internal/fxsync"
 	"github.com/jekabolt/grbpwr-manager/internal/mail"
+	"github.com/jekabolt/grbpwr-manager/internal/markdownsched"
 	"github.com/jekabolt/grbpwr-manager/internal/marketingaggregate"
 	"github.com/jekabolt/grbpwr-manager/internal/middleware"
 	"github.com/jekabolt/grbpwr-manager/internal/openrouter"
@@ -27,12 +30,15 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/ordercleanup"
 	"github.com/jekabolt/grbpwr-manager/internal/payment/stripe"
 	"github.com/jekabolt/grbpwr-manager/internal/revalidation"
+	"github.com/jekabolt/grbpwr-manager/internal/search"
 	"github.com/jekabolt/grbpwr-manager/internal/shippinglabel"
+	"github.com/jekabolt/grbpwr-manager/internal/stockreserve"
 	"github.com/jekabolt/grbpwr-manager/internal/store"
 	"github.com/jekabolt/grbpwr-manager/internal/storefront"
 	"github.com/jekabolt/grbpwr-manager/internal/storefrontcleanup"
 	"github.com/jekabolt/grbpwr-manager/internal/stripereconcile"
 	"github.com/jekabolt/grbpwr-manager/internal/tiermanagement"
+	"github.com/jekabolt/grbpwr-manager/internal/wishlistnotify"
 	"github.com/jekabolt/grbpwr-manager/log"
 	"github.com/spf13/viper"
 )
@@ -52,6 +58,8 @@ type JPKConfig struct {
 	Email     string `mapstructure:"email"`      // contact email (Podmiot1/OsobaNiefizyczna/Email)
 	Phone     string `mapstructure:"phone"`      // contact phone, optional (Podmiot1/OsobaNiefizyczna/Telefon)
 	TaxOffice string `mapstructure:"tax_office"` // 4-digit destination tax-office code (Naglowek/KodUrzedu)
+	AddressL1 string `mapstructure:"address_l1"` // seller address on KSeF sales invoices (Podmiot1/Adres/AdresL1)
+	AddressL2 string `mapstructure:"address_l2"` // optional second address line (Podmiot1/Adres/AdresL2)
 }
 
 // SecurityConfig holds request-handling security settings.
@@ -84,13 +92,19 @@ type Config struct {
 	Mailer             mail.Config               `mapstructure:"mailer"`
 	CampaignDispatch   campaigndispatch.Config   `mapstructure:"campaign_dispatch"`
 	OrderCleanup       ordercleanup.Config       `mapstructure:"order_cleanup"`
+	StockReserve       stockreserve.Config       `mapstructure:"stock_reserve"`
 	DeliverySync       deliverysync.Config       `mapstructure:"delivery_sync"`
 	AfterShip          aftership.Config          `mapstructure:"aftership"`
 	ShippingLabel      shippinglabel.Config      `mapstructure:"shipping_label"`
 	StorefrontCleanup  storefrontcleanup.Config  `mapstructure:"storefront_cleanup"`
+	Audit              auditlog.Config           `mapstructure:"audit"`
 	TierManagement     tiermanagement.Config     `mapstructure:"tier_management"`
 	MarketingAggregate marketingaggregate.Config `mapstructure:"marketing_aggregate"`
 	OpexMaterialize    opexmaterialize.Config    `mapstructure:"opex_materialize"`
+	MarkdownSchedule   markdownsched.Config      `mapstructure:"markdown_schedule"`
+	Search             search.Config             `mapstructure:"search"`
+	WishlistNotify     wishlistnotify.Config     `mapstructure:"wishlist_notify"`
+	CheckoutRecovery   checkoutrecovery.Config   `mapstructure:"checkout_recovery"`
 	Accounting         acctposting.Config        `mapstructure:"accounting"`
 	StripeReconcile    stripereconcile.Config    `mapstructure:"stripe_reconcile"`
 	FxSync             fxsync.Config             `mapstructure:"fx_sync"`
@@ -425,6 +439,12 @@ func bindEnvVars() {
 	viper.BindEnv("order_cleanup.worker_interval", "ORDER_CLEANUP_WORKER_INTERVAL")
 	viper.BindEnv("order_cleanup.placed_threshold", "ORDER_CLEANUP_PLACED_THRESHOLD")
 
+	// Stock reservations (cart/order holds): "memory" or "mysql" backend
+	viper.BindEnv("stock_reserve.backend", "STOCK_RESERVE_BACKEND")
+	viper.BindEnv("stock_reserve.cart_ttl", "STOCK_RESERVE_CART_TTL")
+	viper.BindEnv("stock_reserve.order_ttl", "STOCK_RESERVE_ORDER_TTL")
+	viper.BindEnv("stock_reserve.sweep_interval", "STOCK_RESERVE_SWEEP_INTERVAL")
+
 	// Delivery sync (shipped -> delivered via AfterShip poll + per-carrier timer safety net)
 	viper.BindEnv("delivery_sync.worker_interval", "DELIVERY_SYNC_WORKER_INTERVAL")
 	viper.BindEnv("delivery_sync.fallback_default", "DELIVERY_SYNC_FALLBACK_DEFAULT")
@@ -455,12 +475,34 @@ func bindEnvVars() {
 	// Storefront cleanup (expired JTI denylist, login challenges, refresh tokens)
 	viper.BindEnv("storefront_cleanup.worker_interval", "STOREFRONT_CLEANUP_WORKER_INTERVAL")
 
+	// Admin audit trail retention (admin_audit_event older than retention_days is purged)
+	viper.BindEnv("audit.retention_days", "AUDIT_RETENTION_DAYS")
+	viper.BindEnv("audit.worker_interval", "AUDIT_WORKER_INTERVAL")
+
 	// Marketing account aggregate (email segmentation behavioral fields)
 	viper.BindEnv("marketing_aggregate.worker_interval", "MARKETING_AGGREGATE_WORKER_INTERVAL")
 
 	// OPEX materialize (book recurring fixed-cost templates into monthly lines)
 	viper.BindEnv("opex_materialize.worker_interval", "OPEX_MATERIALIZE_WORKER_INTERVAL")
 
+	// Markdown schedule (start/end scheduled markdown events on product.sale_percentage)
+	viper.BindEnv("markdown_schedule.worker_interval", "MARKDOWN_SCHEDULE_WORKER_INTERVAL")
+
+	// Storefront search (rebuild the in-process index when the catalogue fingerprint moves)
+	viper.BindEnv("search.worker_interval", "SEARCH_WORKER_INTERVAL")
+
+	// Wishlist alerts (mail accounts when a wished item goes on sale, runs low or is restocked)
+	viper.BindEnv("wishlist_notify.worker_interval", "WISHLIST_NOTIFY_WORKER_INTERVAL")
+	viper.BindEnv("wishlist_notify.low_stock_threshold", "WISHLIST_NOTIFY_LOW_STOCK_THRESHOLD")
+
+	// Abandoned checkout recovery (timed reminder emails with a restore-cart link and an optional code)
+	viper.BindEnv("checkout_recovery.worker_interval", "CHECKOUT_RECOVERY_WORKER_INTERVAL")
+	viper.BindEnv("checkout_recovery.step_delays", "CHECKOUT_RECOVERY_STEP_DELAYS")
+	viper.BindEnv("checkout_recovery.attribution_window", "CHECKOUT_RECOVERY_ATTRIBUTION_WINDOW")
+	viper.BindEnv("checkout_recovery.incentive_promo_code", "CHECKOUT_RECOVERY_INCENTIVE_PROMO_CODE")
+	viper.BindEnv("checkout_recovery.incentive_step", "CHECKOUT_RECOVERY_INCENTIVE_STEP")
+	viper.BindEnv("checkout_recovery.incentive_prefix", "CHECKOUT_RECOVERY_INCENTIVE_PREFIX")
+
 	// Accounting posting worker (drain the order outbox + pull sources into the double-entry ledger)
 	viper.BindEnv("accounting.enabled", "ACCOUNTING_ENABLED")
 	viper.BindEnv("accounting.worker_interval", "ACCOUNTING_WORKER_INTERVAL")
@@ -472,6 +514,8 @@ func bindEnvVars() {
 	viper.BindEnv("jpk.email", "JPK_EMAIL")
 	viper.BindEnv("jpk.phone", "JPK_PHONE")
 	viper.BindEnv("jpk.tax_office", "JPK_TAX_OFFICE")
+	viper.BindEnv("jpk.address_l1", "JPK_ADDRESS_L1")
+	viper.BindEnv("jpk.address_l2", "JPK_ADDRESS_L2")
 	viper.BindEnv("accounting.settled_wait_max", "ACCOUNTING_SETTLED_WAIT_MAX")
 	viper.BindEnv("accounting.defect_normal_loss_rate", "ACCOUNTING_DEFECT_NORMAL_LOSS_RATE")
 
@@ -34,6 +34,7 @@ const (
 	Acc2070 = "2070" // VAT Payable
 	Acc2080 = "2080" // VAT Input (Recoverable) — contra-liability (phase 2, wave 1)
 	Acc2090 = "2090" // Customer Prepayments — delivered-recognition liability (phase 2, wave 2)
+	Acc2100 = "2100" // Gift Cards & Store Credit — outstanding prepaid balances (seeded by 0334)
 
 	// Equity (3).
 	Acc3010 = "3010" // Owner's Equity
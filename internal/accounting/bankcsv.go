@@ -37,7 +37,7 @@ type RevolutParser struct{}
 func NewRevolutParser() *RevolutParser { return &RevolutParser{} }
 
 // Source implements BankCsvParser.
-func (RevolutParser) Source() string { return "revolut" }
+func (RevolutParser) Source() string { return BankSourceRevolut }
 
 // revolutColumns are the header names the parser reads. Kept in ONE block so the header→index mapping
 // survives a column reorder (the file is matched by name, not position).
@@ -0,0 +1,187 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// bankSourceMaxLen is acct_bank_txn.source / acct_bank_csv_mapping.source VARCHAR(16).
+const bankSourceMaxLen = 16
+
+// MappedCsvParser parses the CSV export of a bank without a built-in parser through an
+// operator-registered column mapping (acct_bank_csv_mapping). Columns are resolved by header name like
+// the Revolut parser's; a CSV has no booked/pending state, so every row with a usable date and amount
+// is imported.
+type MappedCsvParser struct {
+	m     entity.AcctBankCsvMapping
+	delim rune
+}
+
+// NewMappedCsvParser validates m and returns its parser. The same validation guards SaveBankCsvMapping,
+// so a stored mapping always builds.
+func NewMappedCsvParser(m entity.AcctBankCsvMapping) (*MappedCsvParser, error) {
+	m.Source = strings.ToLower(strings.TrimSpace(m.Source))
+	switch {
+	case m.Source == "":
+		return nil, fmt.Errorf("accounting: csv mapping source is required")
+	case len(m.Source) > bankSourceMaxLen:
+		return nil, fmt.Errorf("accounting: csv mapping source is longer than %d characters", bankSourceMaxLen)
+	case IsBuiltinBankSource(m.Source):
+		return nil, fmt.Errorf("accounting: %q is a built-in bank format and cannot be mapped", m.Source)
+	}
+	if strings.TrimSpace(m.DateColumn) == "" || strings.TrimSpace(m.DateLayout) == "" {
+		return nil, fmt.Errorf("accounting: csv mapping needs a date column and a date layout")
+	}
+	hasAmount := strings.TrimSpace(m.AmountColumn) != ""
+	hasDebit, hasCredit := strings.TrimSpace(m.DebitColumn) != "", strings.TrimSpace(m.CreditColumn) != ""
+	if hasAmount == (hasDebit || hasCredit) || hasDebit != hasCredit {
+		return nil, fmt.Errorf("accounting: csv mapping needs either an amount column or a debit and a credit column")
+	}
+	if (strings.TrimSpace(m.CurrencyColumn) != "") == (strings.TrimSpace(m.Currency) != "") {
+		return nil, fmt.Errorf("accounting: csv mapping needs either a currency column or a fixed currency")
+	}
+	if c := strings.TrimSpace(m.Currency); c != "" && len(c) != 3 {
+		return nil, fmt.Errorf("accounting: csv mapping currency %q is not a 3-letter code", c)
+	}
+	if m.SkipLines < 0 {
+		return nil, fmt.Errorf("accounting: csv mapping skip_lines must not be negative")
+	}
+	delim := ','
+	if m.Delimiter != "" {
+		r, size := utf8.DecodeRuneInString(m.Delimiter)
+		if size != len(m.Delimiter) || r == '"' || r == '\n' || r == '\r' {
+			return nil, fmt.Errorf("accounting: csv mapping delimiter must be one character")
+		}
+		delim = r
+	}
+	return &MappedCsvParser{m: m, delim: delim}, nil
+}
+
+// Mapping returns the normalised mapping the parser was built from.
+func (p *MappedCsvParser) Mapping() entity.AcctBankCsvMapping { return p.m }
+
+// Source implements BankCsvParser.
+func (p *MappedCsvParser) Source() string { return p.m.Source }
+
+// Parse implements BankCsvParser.
+func (p *MappedCsvParser) Parse(csvText string) ([]entity.AcctBankTxnInsert, error) {
+	text := strings.TrimPrefix(strings.ReplaceAll(csvText, "\r\n", "\n"), "\ufeff")
+	for i := 0; i < p.m.SkipLines; i++ {
+		_, rest, ok := strings.Cut(text, "\n")
+		if !ok {
+			rest = ""
+		}
+		text = rest
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = p.delim
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("accounting: parse %s csv: %w", p.m.Source, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("accounting: %s csv is empty", p.m.Source)
+	}
+
+	header := rows[0]
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		idx[strings.TrimSpace(h)] = i
+	}
+	required := []string{p.m.DateColumn, p.m.AmountColumn, p.m.DebitColumn, p.m.CreditColumn,
+		p.m.CurrencyColumn, p.m.CounterpartyColumn, p.m.IdColumn}
+	required = append(required, p.m.DescriptionColumns...)
+	for _, c := range required {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		if _, ok := idx[c]; !ok {
+			return nil, fmt.Errorf("accounting: %s csv missing mapped column %q", p.m.Source, c)
+		}
+	}
+
+	get := func(row []string, name string) string {
+		i, ok := idx[strings.TrimSpace(name)]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	keys := newLineKeys(p.m.Source)
+	out := make([]entity.AcctBankTxnInsert, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if len(row) == 0 || (len(row) == 1 && strings.TrimSpace(row[0]) == "") {
+			continue
+		}
+		bookedAt, err := time.ParseInLocation(p.m.DateLayout, get(row, p.m.DateColumn), time.UTC)
+		if err != nil {
+			continue
+		}
+		amount, ok := p.amount(row, get)
+		if !ok || amount.IsZero() {
+			continue
+		}
+		currency := strings.ToUpper(strings.TrimSpace(p.m.Currency))
+		if p.m.CurrencyColumn != "" {
+			currency = strings.ToUpper(get(row, p.m.CurrencyColumn))
+		}
+		if len(currency) != 3 {
+			continue
+		}
+		descParts := make([]string, 0, len(p.m.DescriptionColumns))
+		for _, c := range p.m.DescriptionColumns {
+			descParts = append(descParts, get(row, c))
+		}
+		description := joinDistinct(" / ", descParts...)
+		counterparty := get(row, p.m.CounterpartyColumn)
+
+		var id string
+		if bankID := get(row, p.m.IdColumn); p.m.IdColumn != "" && bankID != "" {
+			id = keys.ref(bankID)
+		} else {
+			id = keys.content(bookedAt.Format("2006-01-02"), amount.String(), currency, description, counterparty)
+		}
+
+		fields := make(map[string]string, len(header))
+		for i, h := range header {
+			if i < len(row) {
+				fields[strings.TrimSpace(h)] = row[i]
+			}
+		}
+		out = append(out, entity.AcctBankTxnInsert{
+			Source:       p.m.Source,
+			ExternalId:   id,
+			BookedAt:     bookedAt,
+			Amount:       amount,
+			Currency:     currency,
+			Description:  truncateRunes(description, descMaxLen),
+			Counterparty: nullStr(truncateRunes(counterparty, counterpartyMaxLen)),
+			State:        entity.AcctBankTxnUnmatched,
+			Raw:          rawJSON(fields),
+		})
+	}
+	return out, nil
+}
+
+// amount reads the signed amount: the amount column as is, or credit − debit of the pair (banks that
+// split the columns write both unsigned; a pre-signed debit is taken by its magnitude).
+func (p *MappedCsvParser) amount(row []string, get func([]string, string) string) (decimal.Decimal, bool) {
+	if p.m.AmountColumn != "" {
+		return parseStatementAmount(get(row, p.m.AmountColumn), p.m.DecimalComma)
+	}
+	debit, dOK := parseStatementAmount(get(row, p.m.DebitColumn), p.m.DecimalComma)
+	credit, cOK := parseStatementAmount(get(row, p.m.CreditColumn), p.m.DecimalComma)
+	if !dOK && !cOK {
+		return decimal.Zero, false
+	}
+	return credit.Abs().Sub(debit.Abs()), true
+}
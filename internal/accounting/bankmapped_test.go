@@ -0,0 +1,70 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMappedCsvParser_Validation(t *testing.T) {
+	base := entity.AcctBankCsvMapping{
+		Source: "mbank", DateColumn: "Data", DateLayout: "02.01.2006",
+		AmountColumn: "Kwota", Currency: "PLN",
+	}
+	_, err := NewMappedCsvParser(base)
+	require.NoError(t, err)
+
+	for name, mut := range map[string]func(*entity.AcctBankCsvMapping){
+		"builtin source":       func(m *entity.AcctBankCsvMapping) { m.Source = "Revolut" },
+		"long source":          func(m *entity.AcctBankCsvMapping) { m.Source = "a-very-long-bank-name" },
+		"no date layout":       func(m *entity.AcctBankCsvMapping) { m.DateLayout = "" },
+		"amount and debit":     func(m *entity.AcctBankCsvMapping) { m.DebitColumn, m.CreditColumn = "Wn", "Ma" },
+		"debit without credit": func(m *entity.AcctBankCsvMapping) { m.AmountColumn, m.DebitColumn = "", "Wn" },
+		"both currencies":      func(m *entity.AcctBankCsvMapping) { m.CurrencyColumn = "Waluta" },
+		"no currency":          func(m *entity.AcctBankCsvMapping) { m.Currency = "" },
+		"long delimiter":       func(m *entity.AcctBankCsvMapping) { m.Delimiter = ";;" },
+	} {
+		m := base
+		mut(&m)
+		_, err := NewMappedCsvParser(m)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestMappedCsvParser_Parse(t *testing.T) {
+	p, err := NewMappedCsvParser(entity.AcctBankCsvMapping{
+		Source: "PKO", Delimiter: ";", SkipLines: 2,
+		DateColumn: "Data operacji", DateLayout: "02.01.2006",
+		DebitColumn: "Obciążenia", CreditColumn: "Uznania", DecimalComma: true,
+		CurrencyColumn:     "Waluta",
+		DescriptionColumns: []string{"Tytuł", "Opis"},
+		CounterpartyColumn: "Kontrahent",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "pko", p.Source())
+
+	text := "Wyciąg 7/2026\n" +
+		"Rachunek PL61109010140000071219812874\n" +
+		"Data operacji;Tytuł;Opis;Kontrahent;Obciążenia;Uznania;Waluta\n" +
+		"10.07.2026;FV/2026/07/00012;Przelew;Jan Kowalski;;1.250,00;PLN\n" +
+		"11.07.2026;Faktura 77;Przelew;Acme;80,50;;PLN\n" +
+		"11.07.2026;Faktura 77;Przelew;Acme;80,50;;PLN\n" +
+		"bad date;x;y;z;1,00;;PLN\n"
+	lines, err := p.Parse(text)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, "1250", lines[0].Amount.String())
+	assert.Equal(t, "PLN", lines[0].Currency)
+	assert.Equal(t, time.Date(2026, 7, 10, 0, 0, 0, 0, time.UTC), lines[0].BookedAt)
+	assert.Equal(t, "FV/2026/07/00012 / Przelew", lines[0].Description)
+	assert.Equal(t, "Jan Kowalski", lines[0].Counterparty.String)
+	assert.Equal(t, "-80.5", lines[1].Amount.String())
+	assert.NotEqual(t, lines[1].ExternalId, lines[2].ExternalId, "identical lines stay two lines")
+
+	_, err = p.Parse("a;b\n1;2\n")
+	assert.Error(t, err, "a file without the mapped columns is rejected")
+}
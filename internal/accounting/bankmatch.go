@@ -0,0 +1,129 @@
+package accounting
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// Bank inbox auto-matching (0338). Before an imported line falls through to the acct_bank_rule
+// substring rules it is matched against the open items a bank line can settle: a Stripe payout
+// (clears 1030), a bank-invoice order's receivable (clears 1040) and a supplier's payable (clears
+// 2010). A match only proposes — the line lands in state matched with the counter-account (and the
+// supplier) filled in, and the operator still posts it. Every rule demands exactly one candidate: an
+// ambiguous line is left for the operator rather than guessed.
+
+// BankMatchCandidates are the open items an import matches against, loaded once per import.
+type BankMatchCandidates struct {
+	Receivables []entity.AcctReceivableRow
+	// InvoiceNumbers are the sales invoice numbers of the receivable orders, by order uuid — a
+	// buyer's transfer usually quotes the invoice number rather than the order.
+	InvoiceNumbers map[string][]string
+	Payables       []entity.AcctPayableRow
+}
+
+// stripePayoutIDRe is a Stripe payout id as the payout's statement descriptor carries it.
+var stripePayoutIDRe = regexp.MustCompile(`\bpo_[A-Za-z0-9]{8,}\b`)
+
+// supplierNameMinLen keeps short supplier names ("AB") from matching half the statement.
+const supplierNameMinLen = 3
+
+// MatchBankTxn returns the open item t settles, or nil. Only unmatched lines are matched: an
+// EXCHANGE leg the parser already ignored stays ignored.
+func MatchBankTxn(t entity.AcctBankTxnInsert, c BankMatchCandidates) *entity.AcctBankMatch {
+	if t.State != entity.AcctBankTxnUnmatched || t.Amount.IsZero() {
+		return nil
+	}
+	orig := t.Description + " " + t.Counterparty.String
+	text := strings.ToLower(orig)
+	if t.Amount.IsPositive() {
+		if m := matchStripePayout(orig, t.Raw); m != nil {
+			return m
+		}
+		return matchReceivable(t, text, c)
+	}
+	return matchPayable(t, text, c)
+}
+
+func matchStripePayout(text, raw string) *entity.AcctBankMatch {
+	if !strings.Contains(strings.ToLower(text), "stripe") {
+		return nil
+	}
+	m := &entity.AcctBankMatch{Kind: entity.AcctBankMatchStripePayout, Account: Acc1030}
+	if id := stripePayoutIDRe.FindString(text + " " + raw); id != "" {
+		m.Ref = id
+	}
+	return m
+}
+
+func matchReceivable(t entity.AcctBankTxnInsert, text string, c BankMatchCandidates) *entity.AcctBankMatch {
+	// The raw trace is searched too: a reference field the parser did not fold into the description
+	// (Revolut's Reference, camt's EndToEndId) often carries the order or invoice number.
+	hay := text + " " + strings.ToLower(t.Raw)
+	var quoted, byAmount []string
+	for _, r := range c.Receivables {
+		if !r.Balance.IsPositive() {
+			continue
+		}
+		if quotesReceivable(hay, r.Ref, c.InvoiceNumbers[r.Ref]) {
+			quoted = append(quoted, r.Ref)
+		}
+		if isBaseCurrency(t.Currency) && t.Amount.Round(2).Equal(r.Balance.Round(2)) {
+			byAmount = append(byAmount, r.Ref)
+		}
+	}
+	ref := ""
+	switch {
+	case len(quoted) == 1:
+		ref = quoted[0]
+	case len(quoted) == 0 && len(byAmount) == 1:
+		ref = byAmount[0]
+	default:
+		return nil
+	}
+	return &entity.AcctBankMatch{Kind: entity.AcctBankMatchReceivable, Ref: ref, Account: Acc1040}
+}
+
+func quotesReceivable(hay, orderUUID string, invoiceNumbers []string) bool {
+	if orderUUID != "" && strings.Contains(hay, strings.ToLower(orderUUID)) {
+		return true
+	}
+	for _, n := range invoiceNumbers {
+		if n != "" && strings.Contains(hay, strings.ToLower(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPayable(t entity.AcctBankTxnInsert, text string, c BankMatchCandidates) *entity.AcctBankMatch {
+	var named, byAmount []entity.AcctPayableRow
+	for _, p := range c.Payables {
+		if p.SupplierId <= 0 || !p.Balance.IsPositive() {
+			continue // untagged AP cannot be attributed
+		}
+		if name := strings.ToLower(strings.TrimSpace(p.SupplierName)); len([]rune(name)) >= supplierNameMinLen && strings.Contains(text, name) {
+			named = append(named, p)
+		}
+		if isBaseCurrency(t.Currency) && t.Amount.Abs().Round(2).Equal(p.Balance.Round(2)) {
+			byAmount = append(byAmount, p)
+		}
+	}
+	var p entity.AcctPayableRow
+	switch {
+	case len(named) == 1:
+		p = named[0]
+	case len(named) == 0 && len(byAmount) == 1:
+		p = byAmount[0]
+	default:
+		return nil
+	}
+	return &entity.AcctBankMatch{
+		Kind:       entity.AcctBankMatchPayable,
+		Ref:        strconv.Itoa(p.SupplierId),
+		Account:    Acc2010,
+		SupplierId: p.SupplierId,
+	}
+}
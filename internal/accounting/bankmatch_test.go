@@ -0,0 +1,86 @@
+package accounting
+
+import (
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bankLine(amount, currency, desc, counterparty string) entity.AcctBankTxnInsert {
+	return entity.AcctBankTxnInsert{
+		Amount:       decimal.RequireFromString(amount),
+		Currency:     currency,
+		Description:  desc,
+		Counterparty: nullStr(counterparty),
+		State:        entity.AcctBankTxnUnmatched,
+		Raw:          "{}",
+	}
+}
+
+func TestMatchBankTxn(t *testing.T) {
+	c := BankMatchCandidates{
+		Receivables: []entity.AcctReceivableRow{
+			{Ref: "0b8e6c2a-1111-4a4a-9d9d-000000000001", Balance: decimal.RequireFromString("250.00")},
+			{Ref: "0b8e6c2a-1111-4a4a-9d9d-000000000002", Balance: decimal.RequireFromString("99.00")},
+			{Ref: "0b8e6c2a-1111-4a4a-9d9d-000000000003", Balance: decimal.RequireFromString("99.00")},
+		},
+		InvoiceNumbers: map[string][]string{
+			"0b8e6c2a-1111-4a4a-9d9d-000000000001": {"FV/2026/07/00012"},
+		},
+		Payables: []entity.AcctPayableRow{
+			{SupplierId: 7, SupplierName: "Acme Textiles", Balance: decimal.RequireFromString("80.50")},
+			{SupplierId: 8, SupplierName: "Zip", Balance: decimal.RequireFromString("40.00")},
+			{SupplierId: 0, SupplierName: "", Balance: decimal.RequireFromString("500.00")},
+		},
+	}
+
+	t.Run("stripe payout", func(t *testing.T) {
+		m := MatchBankTxn(bankLine("1200.00", "EUR", "STRIPE PAYOUT po_1Nabcdefgh", ""), c)
+		require.NotNil(t, m)
+		assert.Equal(t, entity.AcctBankMatchStripePayout, m.Kind)
+		assert.Equal(t, Acc1030, m.Account)
+		assert.Equal(t, "po_1Nabcdefgh", m.Ref)
+	})
+	t.Run("receivable by invoice number", func(t *testing.T) {
+		m := MatchBankTxn(bankLine("240.00", "EUR", "Payment fv/2026/07/00012", "Jan"), c)
+		require.NotNil(t, m)
+		assert.Equal(t, entity.AcctBankMatchReceivable, m.Kind)
+		assert.Equal(t, "0b8e6c2a-1111-4a4a-9d9d-000000000001", m.Ref)
+		assert.Equal(t, Acc1040, m.Account)
+	})
+	t.Run("receivable by amount", func(t *testing.T) {
+		m := MatchBankTxn(bankLine("250.00", "EUR", "transfer", ""), c)
+		require.NotNil(t, m)
+		assert.Equal(t, "0b8e6c2a-1111-4a4a-9d9d-000000000001", m.Ref)
+	})
+	t.Run("ambiguous amount", func(t *testing.T) {
+		assert.Nil(t, MatchBankTxn(bankLine("99.00", "EUR", "transfer", ""), c))
+	})
+	t.Run("amount only matches in base currency", func(t *testing.T) {
+		assert.Nil(t, MatchBankTxn(bankLine("250.00", "PLN", "transfer", ""), c))
+	})
+	t.Run("payable by name", func(t *testing.T) {
+		m := MatchBankTxn(bankLine("-30.00", "PLN", "Invoice 77", "ACME TEXTILES SP Z O O"), c)
+		require.NotNil(t, m)
+		assert.Equal(t, entity.AcctBankMatchPayable, m.Kind)
+		assert.Equal(t, 7, m.SupplierId)
+		assert.Equal(t, "7", m.Ref)
+		assert.Equal(t, Acc2010, m.Account)
+	})
+	t.Run("payable by amount", func(t *testing.T) {
+		m := MatchBankTxn(bankLine("-40.00", "EUR", "transfer", "someone"), c)
+		require.NotNil(t, m)
+		assert.Equal(t, 8, m.SupplierId)
+	})
+	t.Run("ignored line is not matched", func(t *testing.T) {
+		l := bankLine("250.00", "EUR", "transfer", "")
+		l.State = entity.AcctBankTxnIgnored
+		assert.Nil(t, MatchBankTxn(l, c))
+	})
+	t.Run("no match", func(t *testing.T) {
+		assert.Nil(t, MatchBankTxn(bankLine("-12.34", "EUR", "coffee", "Cafe"), c))
+	})
+}
@@ -0,0 +1,220 @@
+package accounting
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// Camt053Parser parses an ISO 20022 camt.053 bank-to-customer statement (versions 001.02 to 001.08 —
+// the elements read here did not move between them; only the entry status gained a <Cd> wrapper in
+// 001.08, and both shapes are read). Elements are matched by local name, so the namespace version does
+// not matter. One inbox line is made per booked <Ntry>: a batch entry stays one line with its
+// transactions' remittance joined, because the bank booked (and the 1010 balance moved by) the total.
+// Pending and informational entries are skipped like Revolut's non-COMPLETED rows.
+type Camt053Parser struct{}
+
+// NewCamt053Parser returns the camt.053 parser.
+func NewCamt053Parser() *Camt053Parser { return &Camt053Parser{} }
+
+// Source implements BankCsvParser.
+func (Camt053Parser) Source() string { return BankSourceCamt053 }
+
+type camtDocument struct {
+	XMLName xml.Name   `xml:"Document"`
+	Stmts   []camtStmt `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStmt struct {
+	Id      string      `xml:"Id"`
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	Other   string      `xml:"Acct>Id>Othr>Id"`
+	Ccy     string      `xml:"Acct>Ccy"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value string `xml:",chardata"`
+	Ccy   string `xml:"Ccy,attr"`
+}
+
+// camtStatus is <Sts>BOOK</Sts> up to 001.07 and <Sts><Cd>BOOK</Cd></Sts> from 001.08.
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Cd   string `xml:"Cd"`
+}
+
+func (s camtStatus) code() string {
+	if c := strings.TrimSpace(s.Cd); c != "" {
+		return strings.ToUpper(c)
+	}
+	return strings.ToUpper(strings.TrimSpace(s.Text))
+}
+
+type camtDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+func (d camtDate) parse() (time.Time, bool) {
+	if s := strings.TrimSpace(d.Dt); s != "" {
+		if t, err := time.ParseInLocation("2006-01-02", s, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	if s := strings.TrimSpace(d.DtTm); s != "" {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05"} {
+			if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+type camtEntry struct {
+	NtryRef     string     `xml:"NtryRef"`
+	Amt         camtAmount `xml:"Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	Sts         camtStatus `xml:"Sts"`
+	BookgDt     camtDate   `xml:"BookgDt"`
+	ValDt       camtDate   `xml:"ValDt"`
+	AcctSvcrRef string     `xml:"AcctSvcrRef"`
+	AddtlInf    string     `xml:"AddtlNtryInf"`
+	Txs         []camtTx   `xml:"NtryDtls>TxDtls"`
+}
+
+type camtParty struct {
+	Nm    string `xml:"Nm"`
+	PtyNm string `xml:"Pty>Nm"` // 001.08 wraps the party
+}
+
+func (p camtParty) name() string {
+	if n := strings.TrimSpace(p.Nm); n != "" {
+		return n
+	}
+	return strings.TrimSpace(p.PtyNm)
+}
+
+type camtTx struct {
+	AcctSvcrRef string    `xml:"Refs>AcctSvcrRef"`
+	EndToEndId  string    `xml:"Refs>EndToEndId"`
+	Dbtr        camtParty `xml:"RltdPties>Dbtr"`
+	Cdtr        camtParty `xml:"RltdPties>Cdtr"`
+	Ustrd       []string  `xml:"RmtInf>Ustrd"`
+	StrdRef     []string  `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AddtlInf    string    `xml:"AddtlTxInf"`
+}
+
+// Parse implements BankCsvParser.
+func (p Camt053Parser) Parse(text string) ([]entity.AcctBankTxnInsert, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal([]byte(strings.TrimSpace(text)), &doc); err != nil {
+		return nil, fmt.Errorf("accounting: parse camt.053: %w", err)
+	}
+	if len(doc.Stmts) == 0 {
+		return nil, fmt.Errorf("accounting: camt.053 has no BkToCstmrStmt/Stmt (is it a camt.053 statement?)")
+	}
+
+	keys := newLineKeys(p.Source())
+	var out []entity.AcctBankTxnInsert
+	for _, st := range doc.Stmts {
+		account := strings.TrimSpace(st.IBAN)
+		if account == "" {
+			account = strings.TrimSpace(st.Other)
+		}
+		for _, e := range st.Entries {
+			if sts := e.Sts.code(); sts != "" && sts != "BOOK" {
+				continue
+			}
+			amount, ok := parseStatementAmount(e.Amt.Value, false)
+			if !ok || amount.IsNegative() {
+				continue // camt amounts are unsigned; the sign is CdtDbtInd
+			}
+			switch strings.ToUpper(strings.TrimSpace(e.CdtDbtInd)) {
+			case "CRDT":
+			case "DBIT":
+				amount = amount.Neg()
+			default:
+				continue
+			}
+			bookedAt, ok := e.BookgDt.parse()
+			if !ok {
+				if bookedAt, ok = e.ValDt.parse(); !ok {
+					continue
+				}
+			}
+			currency := strings.ToUpper(strings.TrimSpace(e.Amt.Ccy))
+			if currency == "" {
+				currency = strings.ToUpper(strings.TrimSpace(st.Ccy))
+			}
+			if currency == "" {
+				continue
+			}
+
+			var remittance, parties []string
+			var endToEnd string
+			txRef := ""
+			for _, tx := range e.Txs {
+				remittance = append(remittance, tx.Ustrd...)
+				remittance = append(remittance, tx.StrdRef...)
+				remittance = append(remittance, tx.AddtlInf)
+				// The counterparty of an inflow is the debtor, of an outflow the creditor.
+				if amount.IsPositive() {
+					parties = append(parties, tx.Dbtr.name())
+				} else {
+					parties = append(parties, tx.Cdtr.name())
+				}
+				if endToEnd == "" && !strings.EqualFold(strings.TrimSpace(tx.EndToEndId), "NOTPROVIDED") {
+					endToEnd = strings.TrimSpace(tx.EndToEndId)
+				}
+				if txRef == "" {
+					txRef = strings.TrimSpace(tx.AcctSvcrRef)
+				}
+			}
+			remittance = append(remittance, e.AddtlInf)
+			description := joinDistinct(" / ", remittance...)
+			counterparty := joinDistinct(", ", parties...)
+
+			ref := strings.TrimSpace(e.AcctSvcrRef)
+			if ref == "" {
+				ref = txRef
+			}
+			var id string
+			if ref != "" {
+				id = keys.ref(account + ":" + ref)
+			} else {
+				id = keys.content(account, bookedAt.Format("2006-01-02"), amount.String(), currency,
+					strings.TrimSpace(e.NtryRef), endToEnd, description, counterparty)
+			}
+
+			out = append(out, entity.AcctBankTxnInsert{
+				Source:       p.Source(),
+				ExternalId:   id,
+				BookedAt:     bookedAt,
+				Amount:       amount,
+				Currency:     currency,
+				Description:  truncateRunes(description, descMaxLen),
+				Counterparty: nullStr(truncateRunes(counterparty, counterpartyMaxLen)),
+				State:        entity.AcctBankTxnUnmatched,
+				Raw: rawJSON(map[string]string{
+					"statement":   st.Id,
+					"account":     account,
+					"entryRef":    e.NtryRef,
+					"acctSvcrRef": ref,
+					"endToEndId":  endToEnd,
+					"bookingDate": bookedAt.Format("2006-01-02"),
+					"amount":      e.Amt.Value,
+					"currency":    currency,
+					"direction":   e.CdtDbtInd,
+					"remittance":  description,
+					"party":       counterparty,
+				}),
+			})
+		}
+	}
+	return out, nil
+}
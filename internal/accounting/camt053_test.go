@@ -0,0 +1,83 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const camt053Sample = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT-2026-07</Id>
+      <Acct><Id><IBAN>PL61109010140000071219812874</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">250.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2026-07-10</Dt></BookgDt>
+        <ValDt><Dt>2026-07-10</Dt></ValDt>
+        <AcctSvcrRef>REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>E2E-1</EndToEndId></Refs>
+          <RltdPties><Dbtr><Nm>Jan Kowalski</Nm></Dbtr><Cdtr><Nm>GRBPWR</Nm></Cdtr></RltdPties>
+          <RmtInf><Ustrd>FV/2026/07/00012</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">80.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2026-07-11T09:30:00+02:00</DtTm></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Nm>GRBPWR</Nm></Dbtr><Cdtr><Pty><Nm>Acme Textiles</Nm></Pty></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Invoice 77</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">10.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2026-07-12</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestCamt053Parser_Parse(t *testing.T) {
+	lines, err := NewCamt053Parser().Parse(camt053Sample)
+	require.NoError(t, err)
+	require.Len(t, lines, 2, "the pending entry is skipped")
+
+	in := lines[0]
+	assert.Equal(t, BankSourceCamt053, in.Source)
+	assert.Equal(t, "camt053:PL61109010140000071219812874:REF-1", in.ExternalId)
+	assert.Equal(t, "250", in.Amount.String())
+	assert.Equal(t, "EUR", in.Currency)
+	assert.Equal(t, time.Date(2026, 7, 10, 0, 0, 0, 0, time.UTC), in.BookedAt)
+	assert.Equal(t, "Jan Kowalski", in.Counterparty.String, "an inflow's counterparty is the debtor")
+	assert.Equal(t, "FV/2026/07/00012", in.Description)
+	assert.Equal(t, entity.AcctBankTxnUnmatched, in.State)
+
+	out := lines[1]
+	assert.Equal(t, "-80.5", out.Amount.String())
+	assert.Equal(t, "Acme Textiles", out.Counterparty.String, "an outflow's counterparty is the creditor")
+	assert.Equal(t, time.Date(2026, 7, 11, 7, 30, 0, 0, time.UTC), out.BookedAt)
+	assert.Contains(t, out.ExternalId, "camt053:h", "no bank reference: content key")
+
+	again, err := NewCamt053Parser().Parse(camt053Sample)
+	require.NoError(t, err)
+	assert.Equal(t, out.ExternalId, again[1].ExternalId, "a re-import keys the line the same")
+}
+
+func TestCamt053Parser_NotAStatement(t *testing.T) {
+	_, err := NewCamt053Parser().Parse(`<Document><BkToCstmrNtfctn/></Document>`)
+	assert.Error(t, err)
+	_, err = NewCamt053Parser().Parse("ID,Amount\n1,2")
+	assert.Error(t, err)
+}
@@ -132,10 +132,11 @@ func revenueLines(revenueAccount string, net decimal.Decimal, pct decimal.NullDe
 	}, ""
 }
 
-// grossEUR derives G, the gross EUR amount of an order (04/S1). Priority: the authoritative Stripe
-// settlement (total_settled_base) when present — this is the CLAUDE.md "authoritative revenue
-// figure", used for any currency; otherwise total_price for a non-Stripe EUR order (custom cash /
-// bank-invoice orders never receive a Stripe settlement, and in EUR total_price already is base).
+// grossEUR derives G, the gross EUR amount the payment method collected (04/S1). Priority: the
+// authoritative Stripe settlement (total_settled_base) when present — this is the CLAUDE.md
+// "authoritative revenue figure", used for any currency; otherwise total_price less any store credit
+// applied for a non-Stripe EUR order (custom cash / bank-invoice orders never receive a Stripe
+// settlement, and in EUR total_price already is base). orderTender grosses it up by the credit.
 // A Stripe order whose settlement has not arrived is ErrNotReady (the worker retries); a non-Stripe
 // non-EUR order cannot be converted without FX and is ErrSkipNonEUR (booked manually). The
 // readiness decision is the worker's — this only encodes the amount rule and refuses facts it
@@ -145,7 +146,7 @@ func grossEUR(f entity.AcctOrderFacts) (decimal.Decimal, error) {
 	case f.TotalSettledBase.Valid:
 		return f.TotalSettledBase.Decimal, nil
 	case !isStripe(f.PaymentMethodName) && isBaseCurrency(f.Currency):
-		return f.TotalPrice, nil
+		return f.TotalPrice.Sub(f.StoreCreditApplied), nil
 	case isStripe(f.PaymentMethodName):
 		return decimal.Zero, ErrNotReady
 	default:
@@ -153,6 +154,78 @@ func grossEUR(f entity.AcctOrderFacts) (decimal.Decimal, error) {
 	}
 }
 
+// tender is an order's gross EUR split by how it was paid: Money reached the payment method's account,
+// Credit was spent from a gift card or store credit (the 2100 liability). K is the EUR value of one
+// unit of order currency — the single share every order builder scales by.
+type tender struct {
+	Gross  decimal.Decimal
+	Money  decimal.Decimal
+	Credit decimal.Decimal
+	K      decimal.Decimal
+}
+
+// orderTender derives the order's gross EUR. The payment method collected only total_price less the
+// store credit applied, so k comes from that part (grossEUR / paid) and the credit share is valued at
+// the same k; Gross = Money + Credit. Without credit this is exactly the phase-1 G and k. Returns
+// grossEUR's sentinels, or ErrDegenerateAmounts for a non-positive total, paid part or G.
+func orderTender(f entity.AcctOrderFacts) (tender, error) {
+	money, err := grossEUR(f)
+	if err != nil {
+		return tender{}, err
+	}
+	paid := f.TotalPrice.Sub(f.StoreCreditApplied)
+	if f.TotalPrice.Sign() <= 0 || paid.Sign() <= 0 || money.Sign() <= 0 {
+		return tender{}, ErrDegenerateAmounts
+	}
+	k := money.Div(paid)
+	credit := f.StoreCreditApplied.Mul(k).Round(2)
+	return tender{Gross: money.Add(credit), Money: money, Credit: credit, K: k}, nil
+}
+
+// tenderDebits is the debit side of the money received on a sale: the payment method's account for
+// what it collected, 2100 for the store credit spent.
+func tenderDebits(f entity.AcctOrderFacts, t tender) []entity.AcctJournalLineInsert {
+	lines := []entity.AcctJournalLineInsert{
+		{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideDebit, Amount: t.Money},
+	}
+	if t.Credit.IsPositive() {
+		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2100, Side: entity.AcctSideDebit, Amount: t.Credit})
+	}
+	return lines
+}
+
+// refundCredits is the credit side of a refund of r EUR: the store-credit share (refund.StoreCreditAmount
+// at share k, capped at r) goes back to 2100, the balancing rest to the money account the sale debited.
+func refundCredits(f entity.AcctOrderFacts, refund entity.AcctOrderRefundPayload, k, r decimal.Decimal) []entity.AcctJournalLineInsert {
+	credit := decimal.Min(refund.StoreCreditAmount.Mul(k).Round(2), r)
+	var lines []entity.AcctJournalLineInsert
+	if money := r.Sub(credit); money.IsPositive() {
+		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideCredit, Amount: money})
+	}
+	if credit.IsPositive() {
+		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2100, Side: entity.AcctSideCredit, Amount: credit})
+	}
+	return lines
+}
+
+// giftCardEUR is the EUR value of the gift-card lines sold on the order, capped at the gross: it is
+// a 2100 liability rather than revenue and sits outside the VAT base (a multi-purpose voucher is taxed
+// when it is spent, not when it is sold).
+func giftCardEUR(f entity.AcctOrderFacts, t tender) decimal.Decimal {
+	return decimal.Min(f.GiftCardTotal.Mul(t.K).Round(2), t.Gross)
+}
+
+// vatBaseShare is the share of an order-currency refund amount in the order's VAT base (its total
+// less the gift-card lines). Gift cards are never refunded, so a refund comes entirely out of that
+// base; a non-positive base yields zero.
+func vatBaseShare(f entity.AcctOrderFacts, amount decimal.Decimal) decimal.Decimal {
+	base := f.TotalPrice.Sub(f.GiftCardTotal)
+	if base.Sign() <= 0 {
+		return decimal.Zero
+	}
+	return amount.Div(base)
+}
+
 // orderFee is F, the EUR acquirer fee booked on a sale (04/S1). Stripe methods carry a captured
 // payment_fee (PaymentFee); NULL / non-positive means no fee line. Non-Stripe methods never have a
 // captured fee, so it is estimated from the payment method's fee model (FeePct/FeeFixed, joined
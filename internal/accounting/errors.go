@@ -35,6 +35,12 @@ var (
 	// costed, an OPEX month with no costed lines). The worker records the source as processed.
 	ErrSkipEmpty = errors.New("accounting: nothing to post")
 
+	// ErrSkipConsignment means a material movement moves stock between our shelf and a
+	// subcontractor's floor (consign_out / consign_return, 0344). The material stays ours and stays
+	// on 1110 — there is no entry to post. It becomes WIP only when the subcontract is reconciled,
+	// and that write is an ordinary issue_production (M3).
+	ErrSkipConsignment = errors.New("accounting: consignment movement, nothing to post")
+
 	// ErrUnknownMovementType means a material movement carried a type outside the closed enum — a
 	// data or schema drift the builder refuses to guess at.
 	ErrUnknownMovementType = errors.New("accounting: unknown material movement type")
@@ -71,6 +71,9 @@ func BuildMaterialMovementEntry(m entity.AcctMovementFacts, startDate time.Time)
 			dr, cr = Acc5090, Acc1110
 		}
 		sourceType = entity.AcctSourceMaterialAdjustment
+	case entity.MaterialMovementConsignOut, entity.MaterialMovementConsignReturn:
+		// Kit to / from a subcontractor: ours before and after, 1110 to 1110 (ErrSkipConsignment).
+		return entity.AcctJournalEntryInsert{}, ErrSkipConsignment
 	default:
 		return entity.AcctJournalEntryInsert{}, ErrUnknownMovementType
 	}
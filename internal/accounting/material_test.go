@@ -84,6 +84,12 @@ func TestBuildMaterialMovementEntry_Skips(t *testing.T) {
 		_, err := BuildMaterialMovementEntry(m, testStartDate)
 		assert.ErrorIs(t, err, ErrSkipUncosted)
 	})
+	t.Run("consignment to and from a subcontractor", func(t *testing.T) {
+		for _, mt := range []entity.MaterialMovementType{entity.MaterialMovementConsignOut, entity.MaterialMovementConsignReturn} {
+			_, err := BuildMaterialMovementEntry(movementFacts(mt), testStartDate)
+			assert.ErrorIs(t, err, ErrSkipConsignment, mt)
+		}
+	})
 	t.Run("unknown movement type", func(t *testing.T) {
 		m := movementFacts(entity.MaterialMovementType("teleport"))
 		_, err := BuildMaterialMovementEntry(m, testStartDate)
@@ -0,0 +1,243 @@
+package accounting
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// MT940Parser parses a SWIFT MT940 customer statement, with or without the {1:}{2:}{4: block
+// envelope, and several statements in one file. Each :61: statement line is one inbox line; the :86:
+// that follows it is its description. :86: comes in the free-text form and in the structured form
+// German and Polish banks use (subfields ?20..?29 or ~20.. / <20.. after an optional 3-digit
+// transaction code) — for the latter the remittance subfields are the description and ?32/?33 the
+// counterparty.
+type MT940Parser struct{}
+
+// NewMT940Parser returns the MT940 parser.
+func NewMT940Parser() *MT940Parser { return &MT940Parser{} }
+
+// Source implements BankCsvParser.
+func (MT940Parser) Source() string { return BankSourceMT940 }
+
+var (
+	mt940TagRe = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):(.*)$`)
+	// :61: value date YYMMDD, optional entry date MMDD, mark (C, D, RC, RD), optional funds code,
+	// amount with a decimal comma, transaction type (N/F/S + 3) and the references.
+	mt940LineRe = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+,\d*)([NFS][A-Z0-9]{3})(.*)$`)
+	// A structured :86: starts with an optional transaction code and a separator-led subfield.
+	mt940StructuredRe = regexp.MustCompile(`^(\d{3})?([?~<])\d{2}`)
+)
+
+// mt940Line is a :61: line with the :86: that followed it.
+type mt940Line struct {
+	account, currency, statement string
+	valueDate, bookedAt          time.Time
+	mark, amount, txType         string
+	custRef, bankRef, supplement string
+	info                         string
+}
+
+// Parse implements BankCsvParser.
+func (p MT940Parser) Parse(text string) ([]entity.AcctBankTxnInsert, error) {
+	var (
+		lines              []*mt940Line
+		account, currency  string
+		statement          string
+		sawStatement       bool
+		tag, value         string
+		lastWasStatementLn bool
+	)
+
+	flush := func() error {
+		if tag == "" {
+			return nil
+		}
+		defer func() { tag, value = "", "" }()
+		switch tag {
+		case "20":
+			sawStatement = true
+			statement = strings.TrimSpace(value)
+			account, currency = "", ""
+		case "25":
+			sawStatement = true
+			account = strings.TrimSpace(strings.ReplaceAll(value, "\n", ""))
+		case "60F", "60M":
+			// C250101EUR1234,56 — mark, date, currency, amount.
+			if v := strings.TrimSpace(value); len(v) >= 10 {
+				currency = strings.ToUpper(v[7:10])
+			}
+		case "61":
+			l, err := parseMT940Line(value)
+			if err != nil {
+				return err
+			}
+			if l != nil {
+				l.account, l.currency, l.statement = account, currency, statement
+				lines = append(lines, l)
+				lastWasStatementLn = true
+				return nil
+			}
+		case "86":
+			if lastWasStatementLn && len(lines) > 0 {
+				lines[len(lines)-1].info = value
+			}
+		}
+		lastWasStatementLn = false
+		return nil
+	}
+
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		ln := strings.TrimRight(raw, " \t\r")
+		if i := strings.Index(ln, "{4:"); i >= 0 {
+			ln = ln[i+3:]
+		}
+		if ln == "" || ln == "-" || strings.HasPrefix(ln, "-}") || strings.HasPrefix(ln, "{") {
+			continue
+		}
+		if m := mt940TagRe.FindStringSubmatch(ln); m != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			tag, value = m[1], m[2]
+			continue
+		}
+		if tag != "" {
+			value += "\n" + ln
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if !sawStatement {
+		return nil, fmt.Errorf("accounting: mt940 has no :20:/:25: tag (is it an MT940 statement?)")
+	}
+
+	keys := newLineKeys(p.Source())
+	out := make([]entity.AcctBankTxnInsert, 0, len(lines))
+	for _, l := range lines {
+		if l.currency == "" {
+			continue // no opening balance to take the currency from
+		}
+		amount, ok := parseStatementAmount(l.amount, true)
+		if !ok || amount.IsZero() {
+			continue
+		}
+		// C and RD (reversal of a debit) credit the account; D and RC debit it.
+		if l.mark == "D" || l.mark == "RC" {
+			amount = amount.Neg()
+		}
+		description, counterparty := parseMT940Info(l.info)
+		if description == "" {
+			description = l.supplement
+		}
+
+		var id string
+		if l.bankRef != "" && !strings.EqualFold(l.bankRef, "NONREF") {
+			id = keys.ref(l.account + ":" + l.valueDate.Format("060102") + ":" + l.bankRef)
+		} else {
+			id = keys.content(l.account, l.bookedAt.Format("2006-01-02"), amount.String(), l.currency,
+				l.txType, l.custRef, description, counterparty)
+		}
+
+		out = append(out, entity.AcctBankTxnInsert{
+			Source:       p.Source(),
+			ExternalId:   id,
+			BookedAt:     l.bookedAt,
+			Amount:       amount,
+			Currency:     l.currency,
+			Description:  truncateRunes(description, descMaxLen),
+			Counterparty: nullStr(truncateRunes(counterparty, counterpartyMaxLen)),
+			State:        entity.AcctBankTxnUnmatched,
+			Raw: rawJSON(map[string]string{
+				"statement":  l.statement,
+				"account":    l.account,
+				"valueDate":  l.valueDate.Format("2006-01-02"),
+				"entryDate":  l.bookedAt.Format("2006-01-02"),
+				"mark":       l.mark,
+				"amount":     l.amount,
+				"currency":   l.currency,
+				"type":       l.txType,
+				"custRef":    l.custRef,
+				"bankRef":    l.bankRef,
+				"supplement": l.supplement,
+				"info":       l.info,
+			}),
+		})
+	}
+	return out, nil
+}
+
+// parseMT940Line parses a :61: value; a line that does not match the layout is skipped (nil), like
+// an unparseable Revolut row.
+func parseMT940Line(value string) (*mt940Line, error) {
+	first, supplement, _ := strings.Cut(value, "\n")
+	m := mt940LineRe.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return nil, nil
+	}
+	valueDate, err := time.ParseInLocation("060102", m[1], time.UTC)
+	if err != nil {
+		return nil, nil
+	}
+	bookedAt := valueDate
+	if m[2] != "" {
+		month, _ := strconv.Atoi(m[2][:2])
+		day, _ := strconv.Atoi(m[2][2:])
+		year := valueDate.Year()
+		// The entry date carries no year: a December value date booked in January is next year's.
+		switch {
+		case month < int(valueDate.Month())-6:
+			year++
+		case month > int(valueDate.Month())+6:
+			year--
+		}
+		if d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC); d.Month() == time.Month(month) {
+			bookedAt = d
+		}
+	}
+	custRef, bankRef, _ := strings.Cut(m[7], "//")
+	return &mt940Line{
+		valueDate:  valueDate,
+		bookedAt:   bookedAt,
+		mark:       m[3],
+		amount:     m[5],
+		txType:     m[6],
+		custRef:    strings.TrimSpace(custRef),
+		bankRef:    strings.TrimSpace(bankRef),
+		supplement: strings.Join(strings.Fields(supplement), " "),
+	}, nil
+}
+
+// parseMT940Info splits a :86: value into the description and the counterparty (structured form
+// only; free text has no counterparty field).
+func parseMT940Info(info string) (description, counterparty string) {
+	flat := strings.ReplaceAll(info, "\n", "")
+	m := mt940StructuredRe.FindStringSubmatch(flat)
+	if m == nil {
+		return strings.Join(strings.Fields(strings.ReplaceAll(info, "\n", " ")), " "), ""
+	}
+	var desc, party []string
+	for _, f := range strings.Split(flat[len(m[1]):], m[2]) {
+		if len(f) < 2 {
+			continue
+		}
+		code, err := strconv.Atoi(f[:2])
+		if err != nil {
+			continue
+		}
+		switch {
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			desc = append(desc, f[2:])
+		case code == 32, code == 33:
+			party = append(party, f[2:])
+		}
+	}
+	// Subfields split long text at fixed widths, so the parts are concatenated, not spaced.
+	return strings.Join(strings.Fields(strings.Join(desc, "")), " "),
+		strings.Join(strings.Fields(strings.Join(party, "")), " ")
+}
@@ -0,0 +1,54 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mt940Sample = `{1:F01BREXPLPWAXXX0000000000}{2:O9401200260711BREXPLPWAXXX00000000002607111200N}{4:
+:20:ST260711
+:25:/PL61109010140000071219812874
+:28C:00045/1
+:60F:C261230EUR1000,00
+:61:2612310102CR250,00NTRFNONREF//BR123
+:86:166?00PRZELEW?20FV/2026/12/0001?21 zamowienie?32JAN KOWALSKI
+?33 SP. Z O.O.
+:61:2612310102D80,50NTRFNONREF
+TRANSFER ACME
+:86:Invoice 77 Acme Textiles
+:61:261231RD5,00NCHGNONREF//BR124
+:62F:C270102EUR1174,50
+-}`
+
+func TestMT940Parser_Parse(t *testing.T) {
+	lines, err := NewMT940Parser().Parse(mt940Sample)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	in := lines[0]
+	assert.Equal(t, BankSourceMT940, in.Source)
+	assert.Equal(t, "mt940:/PL61109010140000071219812874:261231:BR123", in.ExternalId)
+	assert.Equal(t, "250", in.Amount.String())
+	assert.Equal(t, "EUR", in.Currency)
+	assert.Equal(t, time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC), in.BookedAt, "the entry date rolls into the next year")
+	assert.Equal(t, "FV/2026/12/0001 zamowienie", in.Description)
+	assert.Equal(t, "JAN KOWALSKI SP. Z O.O.", in.Counterparty.String)
+
+	out := lines[1]
+	assert.Equal(t, "-80.5", out.Amount.String())
+	assert.Equal(t, "Invoice 77 Acme Textiles", out.Description)
+	assert.False(t, out.Counterparty.Valid, "free-text :86: has no counterparty")
+	assert.Contains(t, out.ExternalId, "mt940:h")
+
+	rev := lines[2]
+	assert.Equal(t, "5", rev.Amount.String(), "a reversed debit credits the account")
+	assert.Equal(t, time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC), rev.BookedAt)
+}
+
+func TestMT940Parser_NotAStatement(t *testing.T) {
+	_, err := NewMT940Parser().Parse("ID,Amount\n1,2")
+	assert.Error(t, err)
+}
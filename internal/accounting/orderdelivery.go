@@ -28,16 +28,17 @@ import (
 // BuildOrderPrepaymentEntry builds the order_prepayment entry (S1n): money in, VAT recognised now, the
 // remainder parked as a customer-prepayment LIABILITY on 2090 until delivery. No revenue and no COGS —
 // those land at delivered. VAT uses the resolved regime rate (identical to S1); the fee is booked as in
-// S1. Returns the same ErrNotReady / ErrSkipNonEUR / ErrDegenerateAmounts sentinels as the sale builder.
+// S1. Store credit spent and gift cards sold are split out to 2100 exactly as in S1, so 2090 only ever
+// holds the goods-and-shipping remainder. Returns the same ErrNotReady / ErrSkipNonEUR /
+// ErrDegenerateAmounts sentinels as the sale builder.
 func BuildOrderPrepaymentEntry(f entity.AcctOrderFacts, vd VatDecision, occurredAt time.Time) (entity.AcctJournalEntryInsert, error) {
-	g, err := grossEUR(f)
+	t, err := orderTender(f)
 	if err != nil {
 		return entity.AcctJournalEntryInsert{}, err
 	}
-	if f.TotalPrice.Sign() <= 0 || g.Sign() <= 0 {
-		return entity.AcctJournalEntryInsert{}, ErrDegenerateAmounts
-	}
-	k := g.Div(f.TotalPrice)
+	g, k := t.Gross, t.K
+	gift := giftCardEUR(f, t)
+	base := g.Sub(gift)
 
 	caveats := append([]string(nil), vd.Caveats...)
 
@@ -45,32 +46,35 @@ func BuildOrderPrepaymentEntry(f entity.AcctOrderFacts, vd VatDecision, occurred
 	// and snapshot cross-check as BuildOrderSaleEntry.
 	vat := decimal.Zero
 	if RegimeHasVAT(vd.Regime) && vd.RatePct.IsPositive() {
-		vat = vatInclusive(g, vd.RatePct)
+		vat = vatInclusive(base, vd.RatePct)
 	}
-	if vat.GreaterThanOrEqual(g) {
+	if vat.IsPositive() && vat.GreaterThanOrEqual(base) {
 		vat = decimal.Zero
 		caveats = append(caveats, "vat exceeds gross; VAT line dropped")
 	}
-	if vat.IsPositive() && f.VatAmount.Valid {
+	if vat.IsPositive() && f.VatAmount.Valid && gift.IsZero() {
 		if snap := f.VatAmount.Decimal.Mul(k); vatSnapshotDiffers(vat, snap) {
 			caveats = append(caveats, vatSnapshotCaveat(vat, snap))
 		}
 	}
 
 	// prepay is the balancing remainder — the amount delivery will drain from 2090 (= NET + SHIP).
-	prepay := g.Sub(vat)
+	prepay := base.Sub(vat)
 
-	lines := []entity.AcctJournalLineInsert{
-		{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideDebit, Amount: g},
+	lines := tenderDebits(f, t)
+	if gift.IsPositive() {
+		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2100, Side: entity.AcctSideCredit, Amount: gift})
 	}
 	if vat.IsPositive() {
 		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2070, Side: entity.AcctSideCredit, Amount: vat})
 	}
-	lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2090, Side: entity.AcctSideCredit, Amount: prepay})
+	if prepay.IsPositive() {
+		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2090, Side: entity.AcctSideCredit, Amount: prepay})
+	}
 
 	// Acquirer fee, exactly as S1: Dr 6050 / Cr the same money account. Post-cutover routing is
 	// Stripe-only (see the worker), so this is the captured 1030 fee, but the general form is kept.
-	if fee := orderFee(f, g); fee.IsPositive() {
+	if fee := orderFee(f, t.Money); fee.IsPositive() {
 		lines = append(lines,
 			entity.AcctJournalLineInsert{AccountCode: Acc6050, Side: entity.AcctSideDebit, Amount: fee},
 			entity.AcctJournalLineInsert{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideCredit, Amount: fee},
@@ -134,14 +138,11 @@ func BuildOrderDeliveredSaleEntry(f entity.AcctOrderFacts, prepaymentNet, transi
 		// Nothing left to recognise (a fully pre-refunded prepayment); the worker records it processed.
 		return entity.AcctJournalEntryInsert{}, ErrSkipEmpty
 	}
-	g, err := grossEUR(f)
+	t, err := orderTender(f)
 	if err != nil {
 		return entity.AcctJournalEntryInsert{}, err
 	}
-	if f.TotalPrice.Sign() <= 0 || g.Sign() <= 0 {
-		return entity.AcctJournalEntryInsert{}, ErrDegenerateAmounts
-	}
-	k := g.Div(f.TotalPrice)
+	k := t.K
 
 	var caveats []string
 
@@ -217,14 +218,11 @@ func BuildOrderPreDeliveredRefundEntry(
 	sourceKey string,
 	occurredAt time.Time,
 ) (entity.AcctJournalEntryInsert, error) {
-	g, err := grossEUR(f)
+	t, err := orderTender(f)
 	if err != nil {
 		return entity.AcctJournalEntryInsert{}, err
 	}
-	if f.TotalPrice.Sign() <= 0 || g.Sign() <= 0 {
-		return entity.AcctJournalEntryInsert{}, ErrDegenerateAmounts
-	}
-	k := g.Div(f.TotalPrice)
+	g, k := t.Gross, t.K
 
 	rOrd := refund.RefundAmount
 	r := rOrd.Mul(k).Round(2)
@@ -237,7 +235,7 @@ func BuildOrderPreDeliveredRefundEntry(
 	// VAT portion of the refund — regime rate, proportional to the refunded fraction (mirrors S2).
 	vatr := decimal.Zero
 	if RegimeHasVAT(vd.Regime) && vd.RatePct.IsPositive() {
-		vatr = vatInclusive(g, vd.RatePct).Mul(rOrd.Div(f.TotalPrice)).Round(2)
+		vatr = vatInclusive(g.Sub(giftCardEUR(f, t)), vd.RatePct).Mul(vatBaseShare(f, rOrd)).Round(2)
 	}
 	if vatr.GreaterThanOrEqual(r) {
 		vatr = decimal.Zero
@@ -259,7 +257,7 @@ func BuildOrderPreDeliveredRefundEntry(
 	if vatr.IsPositive() {
 		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2070, Side: entity.AcctSideDebit, Amount: vatr})
 	}
-	lines = append(lines, entity.AcctJournalLineInsert{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideCredit, Amount: r})
+	lines = append(lines, refundCredits(f, refund, k, r)...)
 
 	// Unwind transit stock only if it had shipped (the cost sits on 1140). If it never shipped, S1n
 	// moved no stock, so there is nothing to unwind. WHERE the cost goes mirrors RefundOrder's stock
@@ -23,18 +23,22 @@ import (
 // remainder, so Σ credit equals G exactly; COGS is a separate balanced pair from the order-time cost
 // snapshot; the phase-1 cascading guards still collapse a bad component to zero with a caveat.
 //
+// Store credit (0334): the part of the order paid with a gift card or store credit is debited to 2100
+// instead of the money account (see orderTender), and gift-card lines sold on the order are credited
+// to 2100 outside the VAT base and revenue, with no COGS. A gift-card-only order books no revenue line.
+//
 // Returns ErrNotReady (Stripe settlement pending — retry), ErrSkipNonEUR (non-Stripe non-EUR — book
 // manually) or ErrDegenerateAmounts (non-positive total/gross — skip) when no entry can be built.
 func BuildOrderSaleEntry(f entity.AcctOrderFacts, vd VatDecision, occurredAt time.Time) (entity.AcctJournalEntryInsert, error) {
-	g, err := grossEUR(f)
+	// Guard 1 (degenerate amounts) is orderTender's: it also protects the k division from a
+	// zero/negative total.
+	t, err := orderTender(f)
 	if err != nil {
 		return entity.AcctJournalEntryInsert{}, err
 	}
-	// Guard 1: degenerate amounts. Also protects the k division below from a zero/negative total.
-	if f.TotalPrice.Sign() <= 0 || g.Sign() <= 0 {
-		return entity.AcctJournalEntryInsert{}, ErrDegenerateAmounts
-	}
-	k := g.Div(f.TotalPrice)
+	g, k := t.Gross, t.K
+	gift := giftCardEUR(f, t)
+	base := g.Sub(gift)
 
 	// Resolver caveats (unknown destination, wdt without vat id, ...) travel onto the entry.
 	caveats := append([]string(nil), vd.Caveats...)
@@ -44,45 +48,53 @@ func BuildOrderSaleEntry(f entity.AcctOrderFacts, vd VatDecision, occurredAt tim
 	// formula, as a defence against a pathological rate.
 	vat := decimal.Zero
 	if RegimeHasVAT(vd.Regime) && vd.RatePct.IsPositive() {
-		vat = vatInclusive(g, vd.RatePct)
+		vat = vatInclusive(base, vd.RatePct)
 	}
-	if vat.GreaterThanOrEqual(g) {
+	if vat.IsPositive() && vat.GreaterThanOrEqual(base) {
 		vat = decimal.Zero
 		caveats = append(caveats, "vat exceeds gross; VAT line dropped")
 	}
 	// Cross-check the regime VAT against the sale-time snapshot (scaled by k); a >1% gap is advisory.
-	if vat.IsPositive() && f.VatAmount.Valid {
+	// The snapshot is taken on the whole total, so orders with gift-card lines are not compared.
+	if vat.IsPositive() && f.VatAmount.Valid && gift.IsZero() {
 		if snap := f.VatAmount.Decimal.Mul(k); vatSnapshotDiffers(vat, snap) {
 			caveats = append(caveats, vatSnapshotCaveat(vat, snap))
 		}
 	}
 
 	// Shipping, proportional. shipment.cost only when not free-shipped. Guard 3 applies after VAT
-	// is final: shipping cannot claim the whole post-VAT remainder (that would zero out revenue).
+	// is final: shipping cannot claim the whole post-VAT remainder (that would zero out revenue). A
+	// gift-card-only order has no goods revenue to protect, so there it may take all of it.
 	ship := decimal.Zero
 	if !(f.FreeShipping.Valid && f.FreeShipping.Bool) && f.ShipmentCost.Valid {
 		ship = f.ShipmentCost.Decimal.Mul(k).Round(2)
 	}
-	if ship.GreaterThanOrEqual(g.Sub(vat)) {
+	if rest := base.Sub(vat); ship.GreaterThan(rest) || (ship.Equal(rest) && gift.IsZero()) {
 		ship = decimal.Zero
 		caveats = append(caveats, "shipping exceeds remainder; shipping line dropped")
 	}
 
-	// NET is the balancing remainder — strictly > 0 after the two guards above.
-	net := g.Sub(vat).Sub(ship)
+	// NET is the balancing remainder — strictly > 0 after the two guards above, except on a
+	// gift-card-only order, where it may be zero.
+	net := base.Sub(vat).Sub(ship)
 
 	// Revenue credit, optionally split into a full-price credit + a 4030 Discounts contra (3.3). The
 	// split preserves the entry balance and the P&L total; a non-reconstructable discount falls back to
 	// the single credit with a caveat.
-	revLines, discCaveat := revenueLines(saleRevenueAccount(f), net, f.PromoDiscountPct)
-	if discCaveat != "" {
-		caveats = append(caveats, discCaveat)
+	var revLines []entity.AcctJournalLineInsert
+	if net.IsPositive() {
+		var discCaveat string
+		revLines, discCaveat = revenueLines(saleRevenueAccount(f), net, f.PromoDiscountPct)
+		if discCaveat != "" {
+			caveats = append(caveats, discCaveat)
+		}
 	}
 
-	lines := []entity.AcctJournalLineInsert{
-		{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideDebit, Amount: g},
-	}
+	lines := tenderDebits(f, t)
 	lines = append(lines, revLines...)
+	if gift.IsPositive() {
+		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2100, Side: entity.AcctSideCredit, Amount: gift})
+	}
 	if ship.IsPositive() {
 		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc4110, Side: entity.AcctSideCredit, Amount: ship})
 	}
@@ -95,7 +107,7 @@ func BuildOrderSaleEntry(f entity.AcctOrderFacts, vd VatDecision, occurredAt tim
 	// account the sale debited (the fee reduces that balance): 1030 for Stripe, 1010 cash, 1040
 	// bank-invoice. Always crediting 1030 left a phantom negative on the processor account for a
 	// non-Stripe method that carries an estimated fee (A-2).
-	if fee := orderFee(f, g); fee.IsPositive() {
+	if fee := orderFee(f, t.Money); fee.IsPositive() {
 		lines = append(lines,
 			entity.AcctJournalLineInsert{AccountCode: Acc6050, Side: entity.AcctSideDebit, Amount: fee},
 			entity.AcctJournalLineInsert{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideCredit, Amount: fee},
@@ -165,11 +177,14 @@ func vatSnapshotCaveat(regimeVat, snapshotVat decimal.Decimal) string {
 
 // saleCOGS sums the costed order lines (UnitCost x Quantity, rounded once) and returns the product
 // ids of the uncosted lines. UnitCost is invalid only when both the sale snapshot and the live
-// product.cost_price were NULL.
+// product.cost_price were NULL. Gift-card lines are not goods and carry no cost.
 func saleCOGS(items []entity.AcctOrderItemFact) (decimal.Decimal, []int) {
 	total := decimal.Zero
 	var uncosted []int
 	for _, it := range items {
+		if it.GiftCard {
+			continue
+		}
 		if it.UnitCost.Valid {
 			total = total.Add(it.UnitCost.Decimal.Mul(it.Quantity))
 		} else {
@@ -0,0 +1,49 @@
+package accounting
+
+import (
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// BuildPayslipEntry builds the payroll journal entry for one approved piece-rate payslip (migration
+// 0341): Dr 6330 Salaries / Cr 2030 Accrued Expenses for the gross in base currency, at the paid
+// month's end — the same accounts a flat salary opex_month line books, which the payslip replaces
+// for that employee-month (the salary template stops materialising it).
+//
+// gross_base NULL means the payslip is uncosted — ErrSkipUncosted (approval refuses a missing rate,
+// so this is defensive); a zero gross is ErrSkipEmpty. A payslip reopened and approved again with a
+// different gross reposts via a versioned source_key: 'payslip:<id>' first, 'payslip:<id>:vN' for a
+// repost (mirrors dev_expense).
+func BuildPayslipEntry(f entity.AcctPayslipFacts, version int) (entity.AcctJournalEntryInsert, error) {
+	if !f.GrossBase.Valid {
+		return entity.AcctJournalEntryInsert{}, ErrSkipUncosted
+	}
+	amount := f.GrossBase.Decimal.Round(2)
+	if amount.Sign() <= 0 {
+		return entity.AcctJournalEntryInsert{}, ErrSkipEmpty
+	}
+
+	lines := []entity.AcctJournalLineInsert{
+		{AccountCode: Acc6330, Side: entity.AcctSideDebit, Amount: amount, Note: nullStr(f.EmployeeName)},
+		{AccountCode: Acc2030, Side: entity.AcctSideCredit, Amount: amount},
+	}
+
+	desc := fmt.Sprintf("payroll %s %s (%s)", f.Month.Format("2006-01"), f.EmployeeName, f.Scheme)
+	return entity.AcctJournalEntryInsert{
+		OccurredAt:  monthEnd(f.Month),
+		Description: truncateRunes(desc, descMaxLen),
+		SourceType:  entity.AcctSourcePayroll,
+		SourceKey:   payslipSourceKey(f.Id, version),
+		CreatedBy:   createdBySystem,
+		Lines:       lines,
+	}, nil
+}
+
+// payslipSourceKey is 'payslip:<id>' for the first version, 'payslip:<id>:vN' for a repost (N > 1).
+func payslipSourceKey(id, version int) string {
+	if version > 1 {
+		return fmt.Sprintf("payslip:%d:v%d", id, version)
+	}
+	return fmt.Sprintf("payslip:%d", id)
+}
@@ -0,0 +1,49 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payslipFacts(grossBase string) entity.AcctPayslipFacts {
+	f := entity.AcctPayslipFacts{
+		Id:           12,
+		EmployeeId:   4,
+		EmployeeName: "Anna K.",
+		Month:        time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+		Scheme:       "piece_rate",
+	}
+	if grossBase != "" {
+		f.GrossBase = nd(grossBase)
+	}
+	return f
+}
+
+func TestBuildPayslipEntry_Basic(t *testing.T) {
+	e, err := BuildPayslipEntry(payslipFacts("1234.56"), 1)
+	require.NoError(t, err)
+	require.NoError(t, ValidateBalanced(e))
+
+	assert.Equal(t, entity.AcctSourcePayroll, e.SourceType)
+	assert.Equal(t, "payslip:12", e.SourceKey)
+	assert.Equal(t, time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC), e.OccurredAt)
+	assertAmount(t, e, Acc6330, entity.AcctSideDebit, "1234.56")
+	assertAmount(t, e, Acc2030, entity.AcctSideCredit, "1234.56")
+	assert.Contains(t, e.Description, "2026-06")
+	assert.Contains(t, e.Description, "Anna K.")
+}
+
+func TestBuildPayslipEntry_VersionAndSkips(t *testing.T) {
+	e, err := BuildPayslipEntry(payslipFacts("10.00"), 3)
+	require.NoError(t, err)
+	assert.Equal(t, "payslip:12:v3", e.SourceKey)
+
+	_, err = BuildPayslipEntry(payslipFacts(""), 1)
+	assert.ErrorIs(t, err, ErrSkipUncosted)
+	_, err = BuildPayslipEntry(payslipFacts("0"), 1)
+	assert.ErrorIs(t, err, ErrSkipEmpty)
+}
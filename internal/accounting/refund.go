@@ -19,7 +19,8 @@ import (
 // it. occurredAt is the refund moment from the event. items carry the per-line unit cost; the
 // refunded quantity per line comes from refund.RefundedByItem.
 //
-// The acquirer fee is deliberately not reversed (a Stripe refund does not return the fee). Returns
+// The part of the refund paid back as store credit (refund.StoreCreditAmount) is credited to 2100
+// instead of the money account. The acquirer fee is deliberately not reversed (a Stripe refund does not return the fee). Returns
 // the same skip/not-ready sentinels as the sale builder, plus ErrDegenerateAmounts when the EUR
 // refund rounds to <= 0.
 func BuildOrderRefundEntry(
@@ -30,14 +31,11 @@ func BuildOrderRefundEntry(
 	sourceKey string,
 	occurredAt time.Time,
 ) (entity.AcctJournalEntryInsert, error) {
-	g, err := grossEUR(f)
+	t, err := orderTender(f)
 	if err != nil {
 		return entity.AcctJournalEntryInsert{}, err
 	}
-	if f.TotalPrice.Sign() <= 0 || g.Sign() <= 0 {
-		return entity.AcctJournalEntryInsert{}, ErrDegenerateAmounts
-	}
-	k := g.Div(f.TotalPrice)
+	g, k := t.Gross, t.K
 
 	// R — EUR value of this refund (order-currency amount, shipping included, at the sale's share).
 	rOrd := refund.RefundAmount
@@ -57,8 +55,7 @@ func BuildOrderRefundEntry(
 	// guard as S1: a VAT share >= the refund is not carved out.
 	vatr := decimal.Zero
 	if RegimeHasVAT(vd.Regime) && vd.RatePct.IsPositive() {
-		refundRatio := rOrd.Div(f.TotalPrice)
-		vatr = vatInclusive(g, vd.RatePct).Mul(refundRatio).Round(2)
+		vatr = vatInclusive(g.Sub(giftCardEUR(f, t)), vd.RatePct).Mul(vatBaseShare(f, rOrd)).Round(2)
 	}
 	if vatr.GreaterThanOrEqual(r) {
 		vatr = decimal.Zero
@@ -82,8 +79,8 @@ func BuildOrderRefundEntry(
 	if vatr.IsPositive() {
 		lines = append(lines, entity.AcctJournalLineInsert{AccountCode: Acc2070, Side: entity.AcctSideDebit, Amount: vatr})
 	}
-	// Money back to the same account S1 debited (1030 / 1010 / 1040).
-	lines = append(lines, entity.AcctJournalLineInsert{AccountCode: moneyAccount(f.PaymentMethodName), Side: entity.AcctSideCredit, Amount: r})
+	// Money back to the same account S1 debited (1030 / 1010 / 1040); store credit back to 2100.
+	lines = append(lines, refundCredits(f, refund, k, r)...)
 
 	// Stock returned to inventory — the ledger mirrors RefundOrder's stock decision exactly
 	// (Phase 8): restock returns the costed units to 1130; writeoff and seconds return NOTHING to
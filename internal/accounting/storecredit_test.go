@@ -0,0 +1,141 @@
+package accounting
+
+import (
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creditFacts is the 250 EUR walkthrough order with 50 of it paid by store credit: Stripe settled
+// only the 200 card remainder.
+func creditFacts() entity.AcctOrderFacts {
+	f := saleFacts(entity.CARD)
+	f.VatAmount = nullDec()
+	f.StoreCreditApplied = dec("50.00")
+	f.TotalSettledBase = nd("200.00")
+	return f
+}
+
+func TestBuildOrderSaleEntry_StoreCreditTender(t *testing.T) {
+	e, err := BuildOrderSaleEntry(creditFacts(), vdOSS23(), testOccurred)
+	require.NoError(t, err)
+	require.NoError(t, ValidateBalanced(e))
+	assert.False(t, e.HasCaveat)
+
+	assertAmount(t, e, Acc1030, entity.AcctSideDebit, "200.00")  // what the card paid
+	assertAmount(t, e, Acc2100, entity.AcctSideDebit, "50.00")   // credit spent
+	assertAmount(t, e, Acc2070, entity.AcctSideCredit, "46.75")  // VAT on the full 250
+	assertAmount(t, e, Acc4020, entity.AcctSideCredit, "203.25") // revenue on the full 250
+	assertAmount(t, e, Acc5010, entity.AcctSideDebit, "84.50")
+}
+
+func TestBuildOrderSaleEntry_StoreCreditSettlementFX(t *testing.T) {
+	// The card part settled at 0.9 EUR per order unit; the credit share is valued at the same k.
+	f := creditFacts()
+	f.TotalSettledBase = nd("180.00")
+
+	e, err := BuildOrderSaleEntry(f, vdOSS23(), testOccurred)
+	require.NoError(t, err)
+	require.NoError(t, ValidateBalanced(e))
+
+	assertAmount(t, e, Acc1030, entity.AcctSideDebit, "180.00")
+	assertAmount(t, e, Acc2100, entity.AcctSideDebit, "45.00")
+	assertAmount(t, e, Acc2070, entity.AcctSideCredit, "42.07")
+	assertAmount(t, e, Acc4020, entity.AcctSideCredit, "182.93")
+}
+
+func TestBuildOrderSaleEntry_GiftCardLines(t *testing.T) {
+	// A 100 EUR gift card and a 150 EUR garment: the gift card is a liability outside the VAT base.
+	f := saleFacts(entity.CARD)
+	f.GiftCardTotal = dec("100.00")
+	f.Items = append(f.Items, entity.AcctOrderItemFact{Id: 2, ProductId: 200, Quantity: dec("1"), GiftCard: true})
+
+	e, err := BuildOrderSaleEntry(f, vdOSS23(), testOccurred)
+	require.NoError(t, err)
+	require.NoError(t, ValidateBalanced(e))
+	assert.False(t, e.HasCaveat, "an uncosted gift-card line is not a COGS caveat")
+
+	assertAmount(t, e, Acc1030, entity.AcctSideDebit, "250.00")
+	assertAmount(t, e, Acc2100, entity.AcctSideCredit, "100.00")
+	assertAmount(t, e, Acc2070, entity.AcctSideCredit, "28.05")
+	assertAmount(t, e, Acc4020, entity.AcctSideCredit, "121.95")
+	assertAmount(t, e, Acc5010, entity.AcctSideDebit, "84.50")
+}
+
+func TestBuildOrderSaleEntry_GiftCardOnly(t *testing.T) {
+	f := entity.AcctOrderFacts{
+		UUID:              "order-gc",
+		TotalPrice:        dec("100.00"),
+		Currency:          "EUR",
+		TotalSettledBase:  nd("100.00"),
+		PaymentMethodName: entity.CARD,
+		GiftCardTotal:     dec("100.00"),
+		Items: []entity.AcctOrderItemFact{
+			{Id: 1, ProductId: 200, Quantity: dec("1"), GiftCard: true},
+		},
+	}
+
+	e, err := BuildOrderSaleEntry(f, vdOSS23(), testOccurred)
+	require.NoError(t, err)
+	require.NoError(t, ValidateBalanced(e))
+	assert.False(t, e.HasCaveat)
+
+	assertAmount(t, e, Acc1030, entity.AcctSideDebit, "100.00")
+	assertAmount(t, e, Acc2100, entity.AcctSideCredit, "100.00")
+	assert.False(t, hasLine(e, Acc4020, entity.AcctSideCredit), "no goods revenue")
+	assert.False(t, hasLine(e, Acc2070, entity.AcctSideCredit), "no VAT on a voucher sale")
+	assert.False(t, hasLine(e, Acc5010, entity.AcctSideDebit))
+}
+
+func TestBuildOrderPrepaymentEntry_StoreCreditTender(t *testing.T) {
+	e, err := BuildOrderPrepaymentEntry(creditFacts(), vdOSS23(), testOccurred)
+	require.NoError(t, err)
+	require.NoError(t, ValidateBalanced(e))
+
+	assertAmount(t, e, Acc1030, entity.AcctSideDebit, "200.00")
+	assertAmount(t, e, Acc2100, entity.AcctSideDebit, "50.00")
+	assertAmount(t, e, Acc2070, entity.AcctSideCredit, "46.75")
+	assertAmount(t, e, Acc2090, entity.AcctSideCredit, "203.25")
+}
+
+func TestBuildOrderRefundEntry_StoreCredit(t *testing.T) {
+	tests := []struct {
+		name      string
+		facts     entity.AcctOrderFacts
+		amount    string
+		credit    string
+		wantMoney string // "" → no money line
+		want2100  string // "" → no 2100 line
+	}{
+		{"credit-paid order, card first", creditFacts(), "250.00", "50.00", "200.00", "50.00"},
+		{"refund to store credit", saleFacts(entity.CARD), "100.00", "100.00", "", "100.00"},
+		{"plain card refund", saleFacts(entity.CARD), "100.00", "0", "100.00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refund := entity.AcctOrderRefundPayload{
+				OrderUUID:         "order-250",
+				RefundAmount:      dec(tt.amount),
+				OrderCurrency:     "EUR",
+				RefundedByItem:    map[int]int64{1: 1},
+				StoreCreditAmount: dec(tt.credit),
+			}
+			e, err := BuildOrderRefundEntry(tt.facts, refund, tt.facts.Items, vdOSS23(), "order-250:1", testOccurred)
+			require.NoError(t, err)
+			require.NoError(t, ValidateBalanced(e))
+
+			if tt.wantMoney == "" {
+				assert.False(t, hasLine(e, Acc1030, entity.AcctSideCredit))
+			} else {
+				assertAmount(t, e, Acc1030, entity.AcctSideCredit, tt.wantMoney)
+			}
+			if tt.want2100 == "" {
+				assert.False(t, hasLine(e, Acc2100, entity.AcctSideCredit))
+			} else {
+				assertAmount(t, e, Acc2100, entity.AcctSideCredit, tt.want2100)
+			}
+		})
+	}
+}
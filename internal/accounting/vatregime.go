@@ -137,6 +137,13 @@ func RegimeRateCountry(r entity.VatRegime, dest, origin string) string {
 	}
 }
 
+// IsEUCountry reports whether an ISO alpha-2 code is an EU-27 member state (the KSeF invoice uses it
+// to tell an EU VAT number, KodUE + NrVatUE, from a third-country tax id).
+func IsEUCountry(code string) bool {
+	_, ok := euCountries[normalizeCountry(code)]
+	return ok
+}
+
 // normalizeCountry upper-cases and trims a country code / name for comparison.
 func normalizeCountry(c string) string { return strings.ToUpper(strings.TrimSpace(c)) }
 
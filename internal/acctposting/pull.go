@@ -73,6 +73,8 @@ func (w *Worker) processMovements(ctx context.Context) error {
 			switch {
 			case errors.Is(berr, accounting.ErrSkipUncosted):
 				slog.Default().DebugContext(ctx, "acctposting: skip uncosted movement", slog.Int("movement_id", m.Id))
+			case errors.Is(berr, accounting.ErrSkipConsignment):
+				slog.Default().DebugContext(ctx, "acctposting: skip consignment movement", slog.Int("movement_id", m.Id))
 			default:
 				// ErrUnknownMovementType or an unexpected builder error: skip; the movement is surfaced
 				// via reconciliation.
@@ -776,6 +778,111 @@ func (w *Worker) loadDevExpenseVersions(ctx context.Context) (map[int]*entity.Ac
 	return active, count, nil
 }
 
+// processPayslips is the payroll pull (0341): approved piece-rate payslips post Dr 6330 / Cr 2030.
+// Like dev expenses it is a FULL reconcile scan each tick (a payslip per employee-month, few rows):
+// post newly approved payslips, repost one approved again with another gross, and reverse one that was
+// reopened (it is no longer in the approved list) or lost its base amount.
+func (w *Worker) processPayslips(ctx context.Context) error {
+	acc := w.repo.Accounting()
+
+	slips, err := acc.ListPayslipsForPosting(ctx, w.startDate)
+	if err != nil {
+		return fmt.Errorf("list payslips: %w", err)
+	}
+	active, versionCount, err := w.loadPayslipVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("load payslip versions: %w", err)
+	}
+
+	seen := make(map[int]bool, len(slips))
+	for _, p := range slips {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		seen[p.Id] = true
+		cur := active[p.Id]
+		label := "payslip " + strconv.Itoa(p.Id)
+
+		candidate, berr := accounting.BuildPayslipEntry(p, versionCount[p.Id]+1)
+		if errors.Is(berr, accounting.ErrSkipUncosted) || errors.Is(berr, accounting.ErrSkipEmpty) {
+			if cur != nil {
+				if err := w.reverseEntry(ctx, label, "payslip uncosted", cur.Id); err != nil {
+					slog.Default().ErrorContext(ctx, "acctposting: reverse uncosted payslip",
+						slog.Int("payslip_id", p.Id), slog.String("err", err.Error()))
+				}
+			} else {
+				slog.Default().DebugContext(ctx, "acctposting: skip empty payslip", slog.Int("payslip_id", p.Id))
+			}
+			continue
+		}
+		if berr != nil {
+			slog.Default().ErrorContext(ctx, "acctposting: build payslip",
+				slog.Int("payslip_id", p.Id), slog.String("err", berr.Error()))
+			continue
+		}
+
+		if cur == nil {
+			if err := w.commitRepost(ctx, label, "", 0, candidate); err != nil { // v1
+				slog.Default().ErrorContext(ctx, "acctposting: post payslip",
+					slog.Int("payslip_id", p.Id), slog.String("err", err.Error()))
+			}
+			continue
+		}
+		full, err := acc.GetJournalEntry(ctx, cur.Id)
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "acctposting: load active payslip entry",
+				slog.Int("payslip_id", p.Id), slog.String("err", err.Error()))
+			continue
+		}
+		if sameEntryLines(full.Lines, candidate.Lines) {
+			continue // unchanged — no-op
+		}
+		if err := w.commitRepost(ctx, label, "payslip repost", cur.Id, candidate); err != nil {
+			slog.Default().ErrorContext(ctx, "acctposting: repost payslip",
+				slog.Int("payslip_id", p.Id), slog.String("err", err.Error()))
+		}
+	}
+
+	// Reopened (or deleted after reopening): an active entry whose payslip is no longer approved.
+	for id, entry := range active {
+		if seen[id] {
+			continue
+		}
+		if err := w.reverseEntry(ctx, "payslip "+strconv.Itoa(id), "payslip reopened", entry.Id); err != nil {
+			slog.Default().ErrorContext(ctx, "acctposting: reverse reopened payslip",
+				slog.Int("payslip_id", id), slog.String("err", err.Error()))
+		}
+	}
+	return nil
+}
+
+// loadPayslipVersions returns, over ALL payroll entries, the active (un-reversed) entry per payslip id
+// and the version count per id (new version = count+1) — loadDevExpenseVersions for 'payslip:' keys.
+func (w *Worker) loadPayslipVersions(ctx context.Context) (map[int]*entity.AcctJournalEntry, map[int]int, error) {
+	entries, _, err := w.repo.Accounting().ListJournalEntries(ctx, entity.AcctEntryFilter{
+		SourceType: entity.AcctSourcePayroll,
+		Limit:      versionListLimit,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	active := make(map[int]*entity.AcctJournalEntry)
+	count := make(map[int]int)
+	for i := range entries {
+		e := entries[i]
+		id, ok := parseSourceID(e.SourceKey, "payslip:")
+		if !ok {
+			continue
+		}
+		count[id]++
+		if !e.ReversedBy.Valid {
+			ecopy := e
+			active[id] = &ecopy
+		}
+	}
+	return active, count, nil
+}
+
 // loadProductionReceiveVersions returns the active (un-reversed) production_receive entry for a
 // receipt, if any, and the total count of its versions (reversed or not) — new version = count+1
 // (mirrors loadOpexVersions/loadShippingVersions). It windows ListJournalEntries to the received_at
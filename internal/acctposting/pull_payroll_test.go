@@ -0,0 +1,35 @@
+package acctposting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Payroll pull tests (0341): which store calls happen for approved / empty / reopened payslips.
+
+func TestProcessPayslips_CreateSkipReverse(t *testing.T) {
+	repo, acct := newMocks(t)
+	w := newTestWorker(repo)
+
+	month := time.Date(testCutover.Year(), testCutover.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	slips := []entity.AcctPayslipFacts{
+		{Id: 1, EmployeeName: "A", Month: month, Scheme: "piece_rate", GrossBase: ndd("900.00")},
+		{Id: 2, EmployeeName: "B", Month: month, Scheme: "piece_rate", GrossBase: ndd("0.00")}, // empty → skip
+	}
+	acct.EXPECT().ListPayslipsForPosting(mock.Anything, mock.Anything).Return(slips, nil)
+
+	// An active entry for a payslip (id 7) that was reopened → reversed.
+	reopened := entity.AcctJournalEntry{Id: 60, SourceType: entity.AcctSourcePayroll, SourceKey: "payslip:7"}
+	acct.EXPECT().ListJournalEntries(mock.Anything, mock.Anything).
+		Return([]entity.AcctJournalEntry{reopened}, 1, nil)
+
+	acct.EXPECT().CreateJournalEntry(mock.Anything, hasSourceKey("payslip:1")).Return(1, false, nil)
+	acct.EXPECT().ReverseJournalEntry(mock.Anything, 60, mock.Anything, "system").Return(2, nil)
+
+	require.NoError(t, w.processPayslips(context.Background()))
+}
@@ -122,6 +122,7 @@ func (w *Worker) RunOnce(ctx context.Context) error {
 	phase("opex", w.processOpex)
 	phase("shipping", w.processShipping)
 	phase("devexpenses", w.processDevExpenses)
+	phase("payroll", w.processPayslips)
 
 	return errors.Join(errs...)
 }
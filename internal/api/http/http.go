@@ -171,12 +171,16 @@ type Server struct {
 	patternAccessHandler    http.Handler
 	patternViewerHandler    http.Handler
 	runPackHandler          http.Handler
+	bundleTicketHandler     http.Handler
+	cmtPortalHandler        http.Handler
+	specSheetHandler        http.Handler
 	fileUploadHandler       http.Handler
 	filePreviewHandler      http.Handler
 	fileLinkHandler         http.Handler
 	stripeWebhookHandler    StripeWebhookHandler
 	aftershipWebhookHandler AftershipWebhookHandler
 	healthRegistry          *health.Registry
+	auditInterceptor        grpc.UnaryServerInterceptor
 }
 
 // New creates a new server
@@ -232,6 +236,28 @@ func (s *Server) SetRunPackHandler(h http.Handler) {
 	s.runPackHandler = h
 }
 
+// SetBundleTicketHandler registers the shop-floor bundle ticket endpoint (/api/bt/{token}) —
+// the ticket a sewing operator scans at the machine. Same posture as /api/rp: the token is the
+// credential, no auth wrapper, inside the CORS'd /api group. Unlike its neighbours it also takes
+// POST: a scan records the operation done.
+func (s *Server) SetBundleTicketHandler(h http.Handler) {
+	s.bundleTicketHandler = h
+}
+
+// SetSubcontractPortalHandler registers the factory portal endpoint (/api/cmt/{token}) — the page a
+// subcontracted factory opens to see its run and confirm the ship date and quantity. Same posture
+// as /api/bt: the token is the credential, and POST records the confirmation.
+func (s *Server) SetSubcontractPortalHandler(h http.Handler) {
+	s.cmtPortalHandler = h
+}
+
+// SetSpecSheetHandler registers the release spec sheet link (/api/ts/{token}) — the PDF a factory
+// opens from a link sent by the tech designer. Same posture as /api/rp: the token is the
+// credential, no auth wrapper, inside the CORS'd /api group; read-only.
+func (s *Server) SetSpecSheetHandler(h http.Handler) {
+	s.specSheetHandler = h
+}
+
 // SetFileLinkHandler registers the public library-file link endpoint (/api/f/{token}, Ф7) —
 // the url a person OUTSIDE the company opens. Same posture as /api/p, /api/pv and /api/rp: the
 // token is the credential, no auth wrapper, inside the CORS'd /api group.
@@ -265,6 +291,13 @@ func (s *Server) SetHealthRegistry(r *health.Registry) {
 	s.healthRegistry = r
 }
 
+// SetAuditInterceptor registers the admin audit-trail interceptor. It is chained after the
+// auth interceptor, so it sees the authenticated admin and only calls that passed the rbac
+// check. Optional: when unset, admin writes are not recorded.
+func (s *Server) SetAuditInterceptor(i grpc.UnaryServerInterceptor) {
+	s.auditInterceptor = i
+}
+
 // Done returns a channel that is closed when gRPC server exits
 func (s *Server) Done() <-chan struct{} {
 	return s.done
@@ -547,6 +580,27 @@ func (s *Server) setupHTTPAPI(ctx context.Context, auth *auth.Server) (http.Hand
 			r.Method(http.MethodGet, "/rp/{token}", s.runPackHandler)
 			r.Method(http.MethodHead, "/rp/{token}", s.runPackHandler)
 		}
+		// Bundle ticket (/api/bt/{token}, scope 'b') — the coupon sheet of one bundle on the
+		// sewing floor. GET/HEAD read the ticket, POST records one operation; the service caps
+		// the POST body itself (it is two numbers).
+		if s.bundleTicketHandler != nil {
+			r.Method(http.MethodGet, "/bt/{token}", s.bundleTicketHandler)
+			r.Method(http.MethodHead, "/bt/{token}", s.bundleTicketHandler)
+			r.Method(http.MethodPost, "/bt/{token}", s.bundleTicketHandler)
+		}
+		// Factory portal (/api/cmt/{token}, scope 's') — a subcontracted run as its factory sees it,
+		// without money. GET/HEAD read, POST confirms the ship date and quantity.
+		if s.cmtPortalHandler != nil {
+			r.Method(http.MethodGet, "/cmt/{token}", s.cmtPortalHandler)
+			r.Method(http.MethodHead, "/cmt/{token}", s.cmtPortalHandler)
+			r.Method(http.MethodPost, "/cmt/{token}", s.cmtPortalHandler)
+		}
+		// Release spec sheet (/api/ts/{token}, scope 't') — the PDF of one tech card release,
+		// without money. GET/HEAD only.
+		if s.specSheetHandler != nil {
+			r.Method(http.MethodGet, "/ts/{token}", s.specSheetHandler)
+			r.Method(http.MethodHead, "/ts/{token}", s.specSheetHandler)
+		}
 		// Public library-file link (/api/f/{token}, scope 'f'). ДВУХБУКВЕННЫХ СОСЕДЕЙ НЕ
 		// ШАДОУИТ: /p, /pv, /rp и /f — четыре разных литеральных сегмента, chi разбирает их
 		// как дерево, а не как список префиксов. HEAD монтируется вместе с GET, иначе chi
@@ -877,6 +931,15 @@ func (s *Server) Start(ctx context.Context,
 		grpcRecovery.WithRecoveryHandlerContext(panicRecoveryHandler),
 	}
 
+	unary := []grpc.UnaryServerInterceptor{
+		grpcRecovery.UnaryServerInterceptor(recoveryOpts...),
+		grpcSlog.UnaryServerInterceptor(log.InterceptorLogger(slog.Default()), opts...),
+		authServer.UnaryAdminAuthInterceptor(),
+	}
+	if s.auditInterceptor != nil {
+		unary = append(unary, s.auditInterceptor)
+	}
+
 	s.gs = grpc.NewServer(
 		grpc.MaxRecvMsgSize(grpcMaxRecvMsgSize),
 		// Send limit matched to recv: the grpc-go default send cap (~4MiB) would
@@ -898,11 +961,7 @@ func (s *Server) Start(ctx context.Context,
 			MinTime:             grpcKeepaliveMinTime,
 			PermitWithoutStream: true,
 		}),
-		grpc.ChainUnaryInterceptor(
-			grpcRecovery.UnaryServerInterceptor(recoveryOpts...),
-			grpcSlog.UnaryServerInterceptor(log.InterceptorLogger(slog.Default()), opts...),
-			authServer.UnaryAdminAuthInterceptor(),
-		),
+		grpc.ChainUnaryInterceptor(unary...),
 		grpc.ChainStreamInterceptor(
 			grpcRecovery.StreamServerInterceptor(recoveryOpts...),
 			grpcSlog.StreamServerInterceptor(log.InterceptorLogger(slog.Default()), opts...),
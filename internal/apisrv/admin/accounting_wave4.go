@@ -19,23 +19,33 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// Wave-4 (money side) admin handlers: the Revolut bank inbox (§4.1) and the AP/AR subledgers (§4.4).
+// Wave-4 (money side) admin handlers: the bank inbox (§4.1; camt.053 / MT940 / mapped CSV in 0338) and the AP/AR subledgers (§4.4).
 // Stripe disputes (§4.3) have no admin RPC — they flow webhook → outbox → worker and surface on the
 // dashboard (acct_dispute_open). Pattern matches accounting.go: validate (dto) → store → convert.
 
-// bankParserFor returns the parser for a bank source ("" / "revolut" → Revolut; the only bank today).
-func bankParserFor(source string) (acctrules.BankCsvParser, error) {
-	switch strings.ToLower(strings.TrimSpace(source)) {
-	case "", "revolut":
-		return acctrules.NewRevolutParser(), nil
-	default:
-		return nil, status.Errorf(codes.InvalidArgument, "unknown bank source %q", source)
+// bankParserFor returns the parser for a bank source: a built-in format ("" / "revolut", "camt053",
+// "mt940") or the CSV mapping registered under that source.
+func (s *Server) bankParserFor(ctx context.Context, source string) (acctrules.BankCsvParser, error) {
+	if p, ok := acctrules.BuiltinBankParser(source); ok {
+		return p, nil
 	}
+	m, err := s.repo.Accounting().GetBankCsvMapping(ctx, strings.ToLower(strings.TrimSpace(source)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown bank source %q (register a csv mapping for it)", source)
+		}
+		return nil, mapAcctErr(ctx, "get bank csv mapping", err)
+	}
+	p, err := acctrules.NewMappedCsvParser(*m)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return p, nil
 }
 
-// ImportBankCsv parses a bank CSV export into the inbox and reports parsed/imported/skipped counts.
+// ImportBankCsv parses a bank statement into the inbox and reports parsed/imported/skipped counts.
 func (s *Server) ImportBankCsv(ctx context.Context, req *pb_admin.ImportBankCsvRequest) (*pb_admin.ImportBankCsvResponse, error) {
-	parser, err := bankParserFor(req.GetSource())
+	parser, err := s.bankParserFor(ctx, req.GetSource())
 	if err != nil {
 		return nil, err
 	}
@@ -68,14 +78,12 @@ func (s *Server) ListBankTxns(ctx context.Context, req *pb_admin.ListBankTxnsReq
 
 // PostBankTxn books a manual-provenance journal entry for an inbox line (Dr/Cr by the signed amount) and
 // marks the line posted, atomically. A non-EUR line's amount_src is folded to EUR base before posting.
+// An auto-matched line can be posted as matched: an empty account_code takes the suggested account and
+// a zero supplier_id the matched supplier.
 func (s *Server) PostBankTxn(ctx context.Context, req *pb_admin.PostBankTxnRequest) (*pb_admin.PostBankTxnResponse, error) {
 	if req.GetId() <= 0 {
 		return nil, status.Error(codes.InvalidArgument, "id is required")
 	}
-	accountCode := strings.ToUpper(strings.TrimSpace(req.GetAccountCode()))
-	if accountCode == "" {
-		return nil, status.Error(codes.InvalidArgument, "account_code is required")
-	}
 
 	txn, err := s.repo.Accounting().GetBankTxn(ctx, int(req.GetId()))
 	if err != nil {
@@ -84,6 +92,17 @@ func (s *Server) PostBankTxn(ctx context.Context, req *pb_admin.PostBankTxnReque
 	if txn.State == entity.AcctBankTxnPosted {
 		return nil, status.Error(codes.FailedPrecondition, "bank txn already posted")
 	}
+	accountCode := strings.ToUpper(strings.TrimSpace(req.GetAccountCode()))
+	if accountCode == "" && txn.State == entity.AcctBankTxnMatched && txn.SuggestedAccount.Valid {
+		accountCode = txn.SuggestedAccount.String
+	}
+	if accountCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "account_code is required")
+	}
+	supplierID := req.GetSupplierId()
+	if supplierID <= 0 && txn.SupplierId.Valid && accountCode == acctrules.Acc2010 {
+		supplierID = int32(txn.SupplierId.Int64)
+	}
 
 	occurredAt := txn.BookedAt
 	if strings.TrimSpace(req.GetOccurredAt()) != "" {
@@ -102,8 +121,8 @@ func (s *Server) PostBankTxn(ctx context.Context, req *pb_admin.PostBankTxnReque
 	// instead of the anonymous "(untagged)" row. Optional (0 = untagged) — but when the
 	// counter-account IS 2010, an untagged post is exactly the anonymous-AP failure the subledger
 	// exists to prevent, so require the tag there (mirrors CreateJournalEntry's manual-2010 rule).
-	if sid := req.GetSupplierId(); sid > 0 {
-		entry.SupplierID = sql.NullInt64{Int64: int64(sid), Valid: true}
+	if supplierID > 0 {
+		entry.SupplierID = sql.NullInt64{Int64: int64(supplierID), Valid: true}
 	} else if accountCode == acctrules.Acc2010 {
 		return nil, status.Errorf(codes.InvalidArgument,
 			"posting to %s Accounts Payable — supplier_id is required so the payable is tracked per supplier (pick one in ap / ar → suppliers)",
@@ -133,7 +152,7 @@ func (s *Server) PostBankTxn(ctx context.Context, req *pb_admin.PostBankTxnReque
 	if err != nil {
 		// A dangling supplier_id trips the FK on insert — a bad request, not a server fault.
 		if s.repo.IsErrForeignKeyViolation(err) {
-			return nil, status.Errorf(codes.InvalidArgument, "supplier_id %d does not exist", req.GetSupplierId())
+			return nil, status.Errorf(codes.InvalidArgument, "supplier_id %d does not exist", supplierID)
 		}
 		return nil, mapAcctErr(ctx, "post bank txn", err)
 	}
@@ -193,6 +212,44 @@ func (s *Server) DeleteBankRule(ctx context.Context, req *pb_admin.DeleteBankRul
 	return &pb_admin.DeleteBankRuleResponse{}, nil
 }
 
+// ListBankCsvMappings returns the registered bank CSV column mappings.
+func (s *Server) ListBankCsvMappings(ctx context.Context, _ *pb_admin.ListBankCsvMappingsRequest) (*pb_admin.ListBankCsvMappingsResponse, error) {
+	list, err := s.repo.Accounting().ListBankCsvMappings(ctx)
+	if err != nil {
+		return nil, mapAcctErr(ctx, "list bank csv mappings", err)
+	}
+	return &pb_admin.ListBankCsvMappingsResponse{Mappings: dto.ConvertAcctBankCsvMappingListToPb(list)}, nil
+}
+
+// SaveBankCsvMapping validates a column mapping by building its parser and stores it.
+func (s *Server) SaveBankCsvMapping(ctx context.Context, req *pb_admin.SaveBankCsvMappingRequest) (*pb_admin.SaveBankCsvMappingResponse, error) {
+	p, err := acctrules.NewMappedCsvParser(dto.ConvertPbAcctBankCsvMapping(req.GetMapping()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	m := p.Mapping()
+	if err := s.repo.Accounting().SaveBankCsvMapping(ctx, m); err != nil {
+		return nil, mapAcctErr(ctx, "save bank csv mapping", err)
+	}
+	saved, err := s.repo.Accounting().GetBankCsvMapping(ctx, m.Source)
+	if err != nil {
+		return nil, mapAcctErr(ctx, "get bank csv mapping", err)
+	}
+	return &pb_admin.SaveBankCsvMappingResponse{Mapping: dto.ConvertAcctBankCsvMappingToPb(*saved)}, nil
+}
+
+// DeleteBankCsvMapping removes a column mapping.
+func (s *Server) DeleteBankCsvMapping(ctx context.Context, req *pb_admin.DeleteBankCsvMappingRequest) (*pb_admin.DeleteBankCsvMappingResponse, error) {
+	source := strings.ToLower(strings.TrimSpace(req.GetSource()))
+	if source == "" {
+		return nil, status.Error(codes.InvalidArgument, "source is required")
+	}
+	if err := s.repo.Accounting().DeleteBankCsvMapping(ctx, source); err != nil {
+		return nil, mapAcctErr(ctx, "delete bank csv mapping", err)
+	}
+	return &pb_admin.DeleteBankCsvMappingResponse{}, nil
+}
+
 // CreateSupplier adds a supplier to the catalog.
 func (s *Server) CreateSupplier(ctx context.Context, req *pb_admin.CreateSupplierRequest) (*pb_admin.CreateSupplierResponse, error) {
 	ins, err := dto.ConvertPbCreateSupplier(req)
@@ -207,17 +264,48 @@ func (s *Server) CreateSupplier(ctx context.Context, req *pb_admin.CreateSupplie
 		return nil, mapAcctErr(ctx, "create supplier", err)
 	}
 	return &pb_admin.CreateSupplierResponse{
-		Supplier: dto.ConvertSupplierToPb(entity.Supplier{Id: id, Name: ins.Name, VatId: ins.VatId, Notes: ins.Notes, CreatedAt: s.repo.Now()}),
+		Supplier: dto.ConvertSupplierToPb(entity.Supplier{Id: id, Name: ins.Name, VatId: ins.VatId, Notes: ins.Notes,
+			LeadTimeDays: ins.LeadTimeDays, CreatedAt: s.repo.Now()}),
 	}, nil
 }
 
-// ListSuppliers returns the supplier catalog.
+// UpdateSupplier replaces a supplier's editable fields.
+func (s *Server) UpdateSupplier(ctx context.Context, req *pb_admin.UpdateSupplierRequest) (*pb_admin.UpdateSupplierResponse, error) {
+	id, ins, err := dto.ConvertPbUpdateSupplier(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.repo.Accounting().UpdateSupplier(ctx, id, ins); err != nil {
+		if s.repo.IsErrUniqueViolation(err) {
+			return nil, status.Errorf(codes.InvalidArgument, "supplier %q already exists", ins.Name)
+		}
+		return nil, mapAcctErr(ctx, "update supplier", err)
+	}
+	return &pb_admin.UpdateSupplierResponse{}, nil
+}
+
+// ListSuppliers returns the supplier catalog, each supplier with the lead times its received
+// purchase orders actually took next to the one it promised.
 func (s *Server) ListSuppliers(ctx context.Context, _ *pb_admin.ListSuppliersRequest) (*pb_admin.ListSuppliersResponse, error) {
 	suppliers, err := s.repo.Accounting().ListSuppliers(ctx)
 	if err != nil {
 		return nil, mapAcctErr(ctx, "list suppliers", err)
 	}
-	return &pb_admin.ListSuppliersResponse{Suppliers: dto.ConvertSupplierListToPb(suppliers)}, nil
+	lead, err := s.repo.PurchaseOrders().SupplierLeadTimes(ctx)
+	if err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "get supplier lead times", err)
+	}
+	byID := make(map[int]entity.SupplierLeadTime, len(lead))
+	for _, lt := range lead {
+		byID[lt.SupplierId] = lt
+	}
+	out := dto.ConvertSupplierListToPb(suppliers)
+	for _, pb := range out {
+		if lt, ok := byID[int(pb.Id)]; ok {
+			dto.ApplySupplierLeadTimeToPb(pb, lt)
+		}
+	}
+	return &pb_admin.ListSuppliersResponse{Suppliers: out}, nil
 }
 
 // GetPayables returns the open Accounts-Payable position per supplier.
@@ -278,11 +366,14 @@ func (s *Server) GetAcctAlerts(ctx context.Context, _ *pb_admin.GetAcctAlertsReq
 	}
 	sort.Strings(a.OpenPastMonths)
 
-	txns, err := acc.ListBankTxns(ctx, string(entity.AcctBankTxnUnmatched), 500)
-	if err != nil {
-		return nil, mapAcctErr(ctx, "get acct alerts (bank)", err)
+	// Auto-matched lines (0338) still wait for the operator to post them, so they count as unmatched.
+	for _, state := range []entity.AcctBankTxnState{entity.AcctBankTxnUnmatched, entity.AcctBankTxnMatched} {
+		txns, err := acc.ListBankTxns(ctx, string(state), 500)
+		if err != nil {
+			return nil, mapAcctErr(ctx, "get acct alerts (bank)", err)
+		}
+		a.BankUnmatched += len(txns)
 	}
-	a.BankUnmatched = len(txns)
 
 	events, err := acc.ListEventsNeedingReview(ctx, 100)
 	if err != nil {
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListAuditEvents pages the admin-wide audit trail (internal/auditlog records it).
+//
+// The RPC is gated on accounts:read, but the trail spans every section, so a scoped viewer is
+// narrowed further here: they see the events of the sections they can read themselves, plus the
+// allowlisted writes (empty section), which any account may make. The recorded payloads are the raw
+// messages — prices, costs and unit prices included — so without costing:read a viewer gets who,
+// what, when and the outcome, but not the request and response bodies.
+func (s *Server) ListAuditEvents(ctx context.Context, req *pb_admin.ListAuditEventsRequest) (*pb_admin.ListAuditEventsResponse, error) {
+	az, ok := authsrv.GetAdminAuthz(ctx)
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "no admin authorization in context")
+	}
+	limit, offset := clampPagination(int(req.GetLimit()), int(req.GetOffset()))
+	f := entity.AuditEventFilter{
+		Actor:      strings.TrimSpace(req.GetActor()),
+		Method:     strings.TrimSpace(req.GetMethod()),
+		Section:    strings.TrimSpace(req.GetSection()),
+		TargetType: strings.TrimSpace(req.GetTargetType()),
+		TargetId:   strings.TrimSpace(req.GetTargetId()),
+		FailedOnly: req.GetFailedOnly(),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if req.GetFrom() != nil {
+		f.From = sql.NullTime{Time: req.GetFrom().AsTime(), Valid: true}
+	}
+	if req.GetTo() != nil {
+		f.To = sql.NullTime{Time: req.GetTo().AsTime(), Valid: true}
+	}
+	if !az.FullAccess() {
+		f.Sections = readableSections(az)
+	}
+
+	events, total, err := s.repo.Audit().ListAuditEvents(ctx, f)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list audit events", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't list audit events")
+	}
+	costRead, _ := costingAccessFor(az, ok)
+	out := make([]*pb_admin.AuditEvent, 0, len(events))
+	for _, e := range events {
+		pb := dto.EntityAuditEventToPb(e)
+		if !costRead {
+			pb.RequestJson, pb.ResponseJson = "", ""
+		}
+		out = append(out, pb)
+	}
+	return &pb_admin.ListAuditEventsResponse{Events: out, Total: int32(total)}, nil
+}
+
+// readableSections lists the sections a scoped account can read, plus the empty section of the
+// allowlisted writes.
+func readableSections(az authsrv.AdminAuthz) []string {
+	out := []string{""}
+	for section, lvl := range az.Perms {
+		if lvl.Covers(entity.AccessRead) {
+			out = append(out, section)
+		}
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultCheckoutRecoveryDays is the report window when the request leaves from empty.
+const defaultCheckoutRecoveryDays = 30
+
+// GetCheckoutRecoveryMetrics reports the abandoned checkouts recorded in the window and what their
+// recovery emails brought back. A checkout recorded late in the window may still be open.
+func (s *Server) GetCheckoutRecoveryMetrics(ctx context.Context, req *pb_admin.GetCheckoutRecoveryMetricsRequest) (*pb_admin.GetCheckoutRecoveryMetricsResponse, error) {
+	to := time.Now().UTC()
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime().UTC()
+	}
+	from := to.AddDate(0, 0, -defaultCheckoutRecoveryDays)
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime().UTC()
+	}
+	if !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+
+	m, err := s.repo.CheckoutRecoveries().GetCheckoutRecoveryMetrics(ctx, from, to)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get checkout recovery metrics",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't get checkout recovery metrics")
+	}
+	return dto.CheckoutRecoveryMetricsToPb(m), nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"strings"
 
 	"github.com/jekabolt/grbpwr-manager/internal/bucket"
 	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
 	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
 	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
 	"google.golang.org/grpc/codes"
@@ -72,11 +74,18 @@ func (s *Server) UploadPattern(ctx context.Context, req *pb_admin.UploadPatternR
 		}
 		return nil, status.Errorf(code, "failed to upload pattern: %v", err)
 	}
-	return &pb_admin.UploadPatternResponse{
+	resp := &pb_admin.UploadPatternResponse{
 		Url:       url,
 		Filename:  req.GetFilename(),
 		SizeBytes: sizeBytes,
-	}, nil
+	}
+	// A DXF gets the server's first reading back with the url. Never a reason to refuse the file:
+	// the sniff above is the upload check, and a pattern the parser cannot read is still the
+	// designer's pattern.
+	if strings.HasSuffix(strings.ToLower(url), ".dxf") {
+		resp.Dxf = dto.DxfPatternSummaryToPb(dxfpattern.Parse(req.GetRaw()))
+	}
+	return resp, nil
 }
 
 // DeleteFromBucket
@@ -430,6 +430,32 @@ func stripStyleCostEstimate(resp *pb_admin.GetStyleCostEstimateResponse) {
 	e.Comparison = nil // estimate-vs-actual-vs-snapshot is all money
 }
 
+// stripCmtPriceAgreementCosting clears the sewing price of a CMT agreement (0344). Factory, style
+// and period stay — a production role still sees who sews what and since when. Safe on nil.
+func stripCmtPriceAgreementCosting(a *pb_common.CmtPriceAgreement) {
+	if a == nil {
+		return
+	}
+	a.UnitPrice = nil
+	a.Currency = ""
+}
+
+// stripProductionRunSubcontractCosting clears the price snapshot of a subcontracted run and the
+// unit cost of its kit. Quantities shipped, returned and consumed stay, and so does the variance —
+// it is in material units, not money. Safe on nil.
+func stripProductionRunSubcontractCosting(rs *pb_common.ProductionRunSubcontract) {
+	if rs == nil {
+		return
+	}
+	rs.UnitPrice = nil
+	rs.Currency = ""
+	for _, l := range rs.Kit {
+		if l != nil {
+			l.UnitCostBase = nil
+		}
+	}
+}
+
 // techCardInsertHasCostingData reports whether a write payload carries confidential cost
 // input: a costing block, or a BOM line with a purchase price. Used to reject a write
 // from an account without costing:write instead of silently accepting cost changes.
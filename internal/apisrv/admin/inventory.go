@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
 	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
 	"github.com/jekabolt/grbpwr-manager/internal/cache"
 	"github.com/jekabolt/grbpwr-manager/internal/dto"
@@ -120,6 +121,7 @@ func (s *Server) ListMaterialStock(ctx context.Context, req *pb_admin.ListMateri
 		Query:         req.GetQ(),
 		WithStockOnly: req.GetWithStockOnly(),
 		BelowMinOnly:  req.GetBelowMinOnly(),
+		LocationId:    int(req.GetLocationId()),
 	})
 	if err != nil {
 		slog.Default().ErrorContext(ctx, "can't list material stock", slog.String("err", err.Error()))
@@ -264,6 +266,43 @@ func (s *Server) ListMaterialLots(ctx context.Context, req *pb_admin.ListMateria
 	return &pb_admin.ListMaterialLotsResponse{Lots: out}, nil
 }
 
+// RecordMaterialLotLabTest appends a lab result (shade band, shrinkage, measured GSM) to a lot (0348).
+// The newest result by tested_at becomes the lot's current one — the one lays and the run pack read.
+func (s *Server) RecordMaterialLotLabTest(ctx context.Context, req *pb_admin.RecordMaterialLotLabTestRequest) (*pb_admin.RecordMaterialLotLabTestResponse, error) {
+	if req.GetLotId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "lot_id is required")
+	}
+	ins, err := dto.ConvertPbRecordMaterialLotLabTest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	t, err := s.repo.MaterialStock().RecordMaterialLotLabTest(ctx, ins, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		var ve *entity.ValidationError
+		switch {
+		case errors.As(err, &ve):
+			return nil, apierr.Invalid(ve)
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, status.Errorf(codes.NotFound, "material lot %d not found", req.GetLotId())
+		}
+		return nil, mapInventoryErr(ctx, "record material lot lab test", err)
+	}
+	return &pb_admin.RecordMaterialLotLabTestResponse{Test: dto.MaterialLotLabTestToPb(t)}, nil
+}
+
+// ListMaterialLotLabTests returns a lot's lab history, newest first.
+func (s *Server) ListMaterialLotLabTests(ctx context.Context, req *pb_admin.ListMaterialLotLabTestsRequest) (*pb_admin.ListMaterialLotLabTestsResponse, error) {
+	if req.GetLotId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "lot_id is required")
+	}
+	tests, err := s.repo.MaterialStock().ListMaterialLotLabTests(ctx, int(req.GetLotId()))
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list material lot lab tests", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't list material lot lab tests")
+	}
+	return &pb_admin.ListMaterialLotLabTestsResponse{Tests: dto.MaterialLotLabTestListToPb(tests)}, nil
+}
+
 // movementToPb converts a movement, stripping confidential cost fields without costing:read.
 func (s *Server) movementToPb(ctx context.Context, m entity.MaterialMovement) *pb_common.MaterialMovement {
 	pb := dto.ConvertEntityMaterialMovementToPb(m)
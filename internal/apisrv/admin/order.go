@@ -10,6 +10,7 @@ import (
 	"time"
 
 	v "github.com/asaskevich/govalidator"
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
 	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
 	"github.com/jekabolt/grbpwr-manager/internal/cache"
 	"github.com/jekabolt/grbpwr-manager/internal/dto"
@@ -273,9 +274,16 @@ func (s *Server) RefundOrder(ctx context.Context, req *pb_admin.RefundOrderReque
 		refundShipping = true
 	}
 
-	// Stripe refund for Stripe payment methods (CARD / CARD_TEST)
+	// Gift-card lines are rejected by RefundOrder; check before Stripe so no money moves for a
+	// refund that will not be recorded.
+	if err := s.checkRefundGiftCards(ctx, orderFull, req.OrderItemIds); err != nil {
+		return nil, err
+	}
+
+	// Stripe refund for Stripe payment methods (CARD / CARD_TEST). A refund to store credit moves
+	// no money.
 	pm, ok := cache.GetPaymentMethodById(orderFull.Payment.PaymentMethodID)
-	if ok && (pm.Method.Name == entity.CARD || pm.Method.Name == entity.CARD_TEST) {
+	if ok && !req.ToStoreCredit && (pm.Method.Name == entity.CARD || pm.Method.Name == entity.CARD_TEST) {
 		handler, err := s.getPaymentHandler(ctx, pm.Method.Name)
 		if err != nil {
 			slog.Default().ErrorContext(ctx, "can't get payment handler for refund",
@@ -301,6 +309,13 @@ func (s *Server) RefundOrder(ctx context.Context, req *pb_admin.RefundOrderReque
 			}
 			refundAmount = &amount
 		}
+		// Only the card's share goes through Stripe; RefundOrder returns the store-credit share of
+		// an order partly paid with credit to its wallet. A nil (full) refund already refunds just
+		// what the card was charged.
+		if refundAmount != nil {
+			card, _ := entity.SplitOrderRefund(&orderFull.Order, *refundAmount)
+			refundAmount = &card
+		}
 
 		// Deterministic idempotency key over the refund scope: a retry after a partial
 		// failure (e.g. Stripe succeeded but the DB step failed) and two concurrent
@@ -308,12 +323,14 @@ func (s *Server) RefundOrder(ctx context.Context, req *pb_admin.RefundOrderReque
 		// the money is refunded at most once.
 		idemKey := stripe.RefundIdempotencyKey(req.OrderUuid, req.OrderItemIds, refundShipping, refundAmount, orderFull.Order.Currency)
 
-		if err := handler.Refund(ctx, orderFull.Payment, req.OrderUuid, refundAmount, orderFull.Order.Currency, idemKey); err != nil {
-			slog.Default().ErrorContext(ctx, "stripe refund failed",
-				slog.String("err", err.Error()),
-				slog.String("orderUuid", req.OrderUuid),
-			)
-			return nil, status.Errorf(codes.Internal, "stripe refund failed: %v", err)
+		if refundAmount == nil || refundAmount.IsPositive() {
+			if err := handler.Refund(ctx, orderFull.Payment, req.OrderUuid, refundAmount, orderFull.Order.Currency, idemKey); err != nil {
+				slog.Default().ErrorContext(ctx, "stripe refund failed",
+					slog.String("err", err.Error()),
+					slog.String("orderUuid", req.OrderUuid),
+				)
+				return nil, status.Errorf(codes.Internal, "stripe refund failed: %v", err)
+			}
 		}
 	}
 
@@ -321,8 +338,11 @@ func (s *Server) RefundOrder(ctx context.Context, req *pb_admin.RefundOrderReque
 	if !entity.ValidRefundDispositions[disposition] {
 		return nil, status.Error(codes.InvalidArgument, "disposition must be empty, restock, writeoff or seconds")
 	}
-	err = s.repo.Order().RefundOrder(ctx, req.OrderUuid, req.OrderItemIds, req.Reason, dto.RefundReasonKey(req.ReasonCode), refundShipping, disposition)
+	err = s.repo.Order().RefundOrder(ctx, req.OrderUuid, req.OrderItemIds, req.Reason, dto.RefundReasonKey(req.ReasonCode), refundShipping, disposition, req.ToStoreCredit)
 	if err != nil {
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
 		slog.Default().ErrorContext(ctx, "can't refund order",
 			slog.String("err", err.Error()),
 		)
@@ -339,6 +359,8 @@ func (s *Server) RefundOrder(ctx context.Context, req *pb_admin.RefundOrderReque
 			)
 		}
 	}
+	// An invoiced order gets its correction invoice now, not at the month's close.
+	s.correctSalesInvoiceAfterRefund(ctx, req.OrderUuid, req.Reason)
 	// Stock-write contract: a restock refund put sellable A units back on the shelf, so the
 	// affected product pages must re-render (sold_out may flip). writeoff moves no stock and
 	// seconds lands on the B row the storefront never lists — neither needs a re-render. The
@@ -529,6 +551,35 @@ func calculateRefundAmount(orderItems []entity.OrderItem, orderItemIds []int32,
 	return dto.RoundForCurrency(total, currency)
 }
 
+// checkRefundGiftCards fails with InvalidArgument when a refund scope (one id per unit, empty = every
+// line) contains a gift-card line.
+func (s *Server) checkRefundGiftCards(ctx context.Context, orderFull *entity.OrderFull, orderItemIds []int32) error {
+	inScope := make(map[int]bool, len(orderItemIds))
+	for _, id := range orderItemIds {
+		inScope[int(id)] = true
+	}
+	productIds := make([]int, 0, len(orderFull.OrderItems))
+	for _, item := range orderFull.OrderItems {
+		if len(inScope) == 0 || inScope[item.Id] {
+			productIds = append(productIds, item.ProductId)
+		}
+	}
+	if len(productIds) == 0 {
+		return nil
+	}
+	giftCards, err := s.repo.StoreCredit().GetGiftCardProductIds(ctx, productIds)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't check gift card lines",
+			slog.String("err", err.Error()),
+		)
+		return status.Errorf(codes.Internal, "can't check gift card lines")
+	}
+	if len(giftCards) > 0 {
+		return status.Error(codes.InvalidArgument, "gift card lines cannot be refunded; disable or adjust the issued gift card instead")
+	}
+	return nil
+}
+
 // calculateFullRefundAmount calculates the total refund amount for a full refund (all
 // remaining items + optional shipping). Used when doing a full refund on non-confirmed
 // orders where we need an explicit amount for Stripe.
@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// СДЕЛЬНАЯ ОПЛАТА (0341) — pay periods and monthly payslips. Gated on costing read/write like the
+// employee registry: what a person earns is as confidential as their salary template. The pricing
+// itself lives in internal/payroll; the store loads the month's bundle scans and freezes the result.
+
+// UpsertEmployeePayPeriod inserts (id==0) or replaces an employee's pay period with its piece rates.
+func (s *Server) UpsertEmployeePayPeriod(ctx context.Context, req *pb_admin.UpsertEmployeePayPeriodRequest) (*pb_admin.UpsertEmployeePayPeriodResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to edit pay periods")
+	}
+	ins, err := dto.ConvertPbPayPeriodToEntity(req.GetPeriod())
+	if err != nil {
+		return nil, payrollInvalid(err)
+	}
+	id, err := s.repo.Payroll().UpsertPayPeriod(ctx, ins, int(req.GetId()), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.payrollError(ctx, "save the pay period", err)
+	}
+	return &pb_admin.UpsertEmployeePayPeriodResponse{Id: int32(id)}, nil
+}
+
+// DeleteEmployeePayPeriod removes a pay period; payslips keep the rates they were computed with.
+func (s *Server) DeleteEmployeePayPeriod(ctx context.Context, req *pb_admin.DeleteEmployeePayPeriodRequest) (*pb_admin.DeleteEmployeePayPeriodResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to delete a pay period")
+	}
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.Payroll().DeletePayPeriod(ctx, int(req.GetId())); err != nil {
+		return nil, s.payrollError(ctx, "delete the pay period", err)
+	}
+	return &pb_admin.DeleteEmployeePayPeriodResponse{}, nil
+}
+
+// ListEmployeePayPeriods returns the pay periods of one employee, or of all when employee_id is 0.
+func (s *Server) ListEmployeePayPeriods(ctx context.Context, req *pb_admin.ListEmployeePayPeriodsRequest) (*pb_admin.ListEmployeePayPeriodsResponse, error) {
+	if read, _ := s.costingAccess(ctx); !read {
+		return nil, status.Error(codes.PermissionDenied, "costing:read is required to view pay periods")
+	}
+	list, err := s.repo.Payroll().ListPayPeriods(ctx, int(req.GetEmployeeId()))
+	if err != nil {
+		return nil, s.payrollError(ctx, "list pay periods", err)
+	}
+	return &pb_admin.ListEmployeePayPeriodsResponse{Periods: dto.PayPeriodListToPb(list)}, nil
+}
+
+// ComputePayslip (re)computes the draft payslip of an employee-month and returns it with its lines.
+func (s *Server) ComputePayslip(ctx context.Context, req *pb_admin.ComputePayslipRequest) (*pb_admin.ComputePayslipResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to compute payslips")
+	}
+	in, err := dto.ConvertPbPayslipComputeToEntity(req, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, payrollInvalid(err)
+	}
+	id, err := s.repo.Payroll().ComputePayslip(ctx, in)
+	if err != nil {
+		return nil, s.payrollError(ctx, "compute the payslip", err)
+	}
+	slip, err := s.loadPayslipPb(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.ComputePayslipResponse{Payslip: slip}, nil
+}
+
+// ApprovePayslip freezes a draft payslip; the accounting worker posts it on its next pass.
+func (s *Server) ApprovePayslip(ctx context.Context, req *pb_admin.ApprovePayslipRequest) (*pb_admin.ApprovePayslipResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to approve payslips")
+	}
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.Payroll().ApprovePayslip(ctx, int(req.GetId()), authsrv.GetAdminUsername(ctx)); err != nil {
+		return nil, s.payrollError(ctx, "approve the payslip", err)
+	}
+	slip, err := s.loadPayslipPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.ApprovePayslipResponse{Payslip: slip}, nil
+}
+
+// ReopenPayslip returns an approved payslip to draft; the accounting worker reverses its entry.
+func (s *Server) ReopenPayslip(ctx context.Context, req *pb_admin.ReopenPayslipRequest) (*pb_admin.ReopenPayslipResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to reopen payslips")
+	}
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.Payroll().ReopenPayslip(ctx, int(req.GetId())); err != nil {
+		return nil, s.payrollError(ctx, "reopen the payslip", err)
+	}
+	slip, err := s.loadPayslipPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.ReopenPayslipResponse{Payslip: slip}, nil
+}
+
+// DeletePayslip removes a draft payslip.
+func (s *Server) DeletePayslip(ctx context.Context, req *pb_admin.DeletePayslipRequest) (*pb_admin.DeletePayslipResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to delete payslips")
+	}
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.Payroll().DeletePayslip(ctx, int(req.GetId())); err != nil {
+		return nil, s.payrollError(ctx, "delete the payslip", err)
+	}
+	return &pb_admin.DeletePayslipResponse{}, nil
+}
+
+// GetPayslip returns a payslip with its lines.
+func (s *Server) GetPayslip(ctx context.Context, req *pb_admin.GetPayslipRequest) (*pb_admin.GetPayslipResponse, error) {
+	if read, _ := s.costingAccess(ctx); !read {
+		return nil, status.Error(codes.PermissionDenied, "costing:read is required to view payslips")
+	}
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	slip, err := s.loadPayslipPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.GetPayslipResponse{Payslip: slip}, nil
+}
+
+// ListPayslips returns payslips without lines, newest month first.
+func (s *Server) ListPayslips(ctx context.Context, req *pb_admin.ListPayslipsRequest) (*pb_admin.ListPayslipsResponse, error) {
+	if read, _ := s.costingAccess(ctx); !read {
+		return nil, status.Error(codes.PermissionDenied, "costing:read is required to view payslips")
+	}
+	f, err := dto.ConvertPbPayslipFilterToEntity(req)
+	if err != nil {
+		return nil, payrollInvalid(err)
+	}
+	list, err := s.repo.Payroll().ListPayslips(ctx, f)
+	if err != nil {
+		return nil, s.payrollError(ctx, "list payslips", err)
+	}
+	return &pb_admin.ListPayslipsResponse{Payslips: dto.PayslipListToPb(list)}, nil
+}
+
+// loadPayslipPb reads a payslip with its lines back for a response.
+func (s *Server) loadPayslipPb(ctx context.Context, id int) (*pb_admin.Payslip, error) {
+	slip, err := s.repo.Payroll().GetPayslip(ctx, id)
+	if err != nil {
+		return nil, s.payrollError(ctx, "load the payslip", err)
+	}
+	return dto.PayslipToPb(*slip), nil
+}
+
+// payrollInvalid maps a dto rejection: a field-tagged violation keeps its BadRequest details.
+func payrollInvalid(err error) error {
+	var ve *entity.ValidationError
+	if errors.As(err, &ve) {
+		return apierr.Invalid(ve)
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// payrollError maps the payroll store's typed refusals onto gRPC codes. ONE table for the nine RPCs.
+//
+//	entity.ValidationError                        → InvalidArgument + BadRequest field violations
+//	ErrEmployeeNotFound / ErrPayPeriodNotFound    → NotFound
+//	ErrPayslipNotFound                            → NotFound
+//	ErrPayPeriodOverlap                           → FailedPrecondition
+//	ErrPayslipApproved / ErrPayslipNotApproved    → FailedPrecondition (reopen first / nothing to reopen)
+//	ErrPayslipStale                               → FailedPrecondition (recompute, review, approve)
+//	ErrPayslipOpexOverlap / ErrPayslipFxMissing   → FailedPrecondition
+//	FK violation                                  → InvalidArgument (an unknown work token)
+func (s *Server) payrollError(ctx context.Context, op string, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return apierr.Invalid(ve)
+	case errors.Is(err, entity.ErrEmployeeNotFound),
+		errors.Is(err, entity.ErrPayPeriodNotFound),
+		errors.Is(err, entity.ErrPayslipNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entity.ErrPayPeriodOverlap),
+		errors.Is(err, entity.ErrPayslipApproved),
+		errors.Is(err, entity.ErrPayslipNotApproved),
+		errors.Is(err, entity.ErrPayslipStale),
+		errors.Is(err, entity.ErrPayslipOpexOverlap),
+		errors.Is(err, entity.ErrPayslipFxMissing):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case s.repo.IsErrForeignKeyViolation(err):
+		return status.Error(codes.InvalidArgument, "the pay period references a missing employee or work")
+	}
+	slog.Default().ErrorContext(ctx, "payroll call failed", slog.String("op", op), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op+"; try again")
+}
@@ -0,0 +1,230 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ПРАЙС-ЛИСТЫ И УЦЕНКИ (0349) — market price lists derived from the base price and the FX rate, and
+// scheduled markdowns. RBAC gates them on the products section; the store owns every pricing rule,
+// the markdown worker starts and ends the markdowns.
+
+// ListPriceLists returns the price lists by currency.
+func (s *Server) ListPriceLists(ctx context.Context, _ *pb_admin.ListPriceListsRequest) (*pb_admin.ListPriceListsResponse, error) {
+	list, err := s.repo.Pricing().ListPriceLists(ctx)
+	if err != nil {
+		return nil, s.pricingError(ctx, "list price lists", err)
+	}
+	return &pb_admin.ListPriceListsResponse{PriceLists: dto.PriceListListToPb(list)}, nil
+}
+
+// GetPriceList returns a price list with its pins.
+func (s *Server) GetPriceList(ctx context.Context, req *pb_admin.GetPriceListRequest) (*pb_admin.GetPriceListResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	l, err := s.repo.Pricing().GetPriceList(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.pricingError(ctx, "get the price list", err)
+	}
+	return &pb_admin.GetPriceListResponse{PriceList: dto.PriceListToPb(*l)}, nil
+}
+
+// CreatePriceList stores a new price list. Nothing is priced until it is published.
+func (s *Server) CreatePriceList(ctx context.Context, req *pb_admin.CreatePriceListRequest) (*pb_admin.CreatePriceListResponse, error) {
+	ins, err := dto.ConvertPbPriceListInsertToEntity(req.GetPriceList(), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.pricingError(ctx, "create the price list", err)
+	}
+	id, err := s.repo.Pricing().CreatePriceList(ctx, ins)
+	if err != nil {
+		return nil, s.pricingError(ctx, "create the price list", err)
+	}
+	return &pb_admin.CreatePriceListResponse{Id: int32(id)}, nil
+}
+
+// UpdatePriceList replaces a price list's rule and pins.
+func (s *Server) UpdatePriceList(ctx context.Context, req *pb_admin.UpdatePriceListRequest) (*pb_admin.UpdatePriceListResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	ins, err := dto.ConvertPbPriceListInsertToEntity(req.GetPriceList(), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.pricingError(ctx, "update the price list", err)
+	}
+	if err := s.repo.Pricing().UpdatePriceList(ctx, int(req.GetId()), ins); err != nil {
+		return nil, s.pricingError(ctx, "update the price list", err)
+	}
+	return &pb_admin.UpdatePriceListResponse{}, nil
+}
+
+// DeletePriceList removes a price list; its published prices stay.
+func (s *Server) DeletePriceList(ctx context.Context, req *pb_admin.DeletePriceListRequest) (*pb_admin.DeletePriceListResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.Pricing().DeletePriceList(ctx, int(req.GetId())); err != nil {
+		return nil, s.pricingError(ctx, "delete the price list", err)
+	}
+	return &pb_admin.DeletePriceListResponse{}, nil
+}
+
+// PreviewPriceList shows every colourway a publish would price, and what it would write.
+func (s *Server) PreviewPriceList(ctx context.Context, req *pb_admin.PreviewPriceListRequest) (*pb_admin.PreviewPriceListResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	p, err := s.repo.Pricing().PreviewPriceList(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.pricingError(ctx, "preview the price list", err)
+	}
+	return dto.PriceListPreviewToPb(p), nil
+}
+
+// PublishPriceList writes the list's prices and revalidates the colourways whose price changed.
+func (s *Server) PublishPriceList(ctx context.Context, req *pb_admin.PublishPriceListRequest) (*pb_admin.PublishPriceListResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	expected, err := dto.ConvertPbExpectedRateToBase(req.GetExpectedRateToBase())
+	if err != nil {
+		return nil, s.pricingError(ctx, "publish the price list", err)
+	}
+	p, err := s.repo.Pricing().PublishPriceList(ctx, int(req.GetId()), expected, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.pricingError(ctx, "publish the price list", err)
+	}
+	var moved []int
+	for _, l := range p.Lines {
+		if l.Changed() {
+			moved = append(moved, l.ProductId)
+		}
+	}
+	if len(moved) > 0 {
+		s.revalidateAsync(&dto.RevalidationData{Products: moved})
+	}
+	lines, changed, skipped := dto.PriceListLinesToPb(p.Lines)
+	return &pb_admin.PublishPriceListResponse{
+		RateToBase: dto.PbDecimalFromNull(p.RateToBase),
+		Lines:      lines,
+		Changed:    changed,
+		Skipped:    skipped,
+	}, nil
+}
+
+// ListMarkdownEvents returns the markdowns, newest first.
+func (s *Server) ListMarkdownEvents(ctx context.Context, req *pb_admin.ListMarkdownEventsRequest) (*pb_admin.ListMarkdownEventsResponse, error) {
+	list, err := s.repo.Pricing().ListMarkdownEvents(ctx, req.GetIncludeClosed())
+	if err != nil {
+		return nil, s.pricingError(ctx, "list markdowns", err)
+	}
+	return &pb_admin.ListMarkdownEventsResponse{Events: dto.MarkdownEventListToPb(list)}, nil
+}
+
+// GetMarkdownEvent returns a markdown with its colourways.
+func (s *Server) GetMarkdownEvent(ctx context.Context, req *pb_admin.GetMarkdownEventRequest) (*pb_admin.GetMarkdownEventResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	e, err := s.repo.Pricing().GetMarkdownEvent(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.pricingError(ctx, "get the markdown", err)
+	}
+	return &pb_admin.GetMarkdownEventResponse{Event: dto.MarkdownEventToPb(*e)}, nil
+}
+
+// CreateMarkdownEvent schedules a markdown.
+func (s *Server) CreateMarkdownEvent(ctx context.Context, req *pb_admin.CreateMarkdownEventRequest) (*pb_admin.CreateMarkdownEventResponse, error) {
+	ins, err := dto.ConvertPbMarkdownEventInsertToEntity(req.GetEvent(), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.pricingError(ctx, "create the markdown", err)
+	}
+	id, err := s.repo.Pricing().CreateMarkdownEvent(ctx, ins)
+	if err != nil {
+		return nil, s.pricingError(ctx, "create the markdown", err)
+	}
+	return &pb_admin.CreateMarkdownEventResponse{Id: int32(id)}, nil
+}
+
+// UpdateMarkdownEvent replaces a markdown that has not started.
+func (s *Server) UpdateMarkdownEvent(ctx context.Context, req *pb_admin.UpdateMarkdownEventRequest) (*pb_admin.UpdateMarkdownEventResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	ins, err := dto.ConvertPbMarkdownEventInsertToEntity(req.GetEvent(), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.pricingError(ctx, "update the markdown", err)
+	}
+	if err := s.repo.Pricing().UpdateMarkdownEvent(ctx, int(req.GetId()), ins); err != nil {
+		return nil, s.pricingError(ctx, "update the markdown", err)
+	}
+	return &pb_admin.UpdateMarkdownEventResponse{}, nil
+}
+
+// CancelMarkdownEvent withdraws a scheduled markdown or ends a running one, revalidating the
+// colourways that came off sale.
+func (s *Server) CancelMarkdownEvent(ctx context.Context, req *pb_admin.CancelMarkdownEventRequest) (*pb_admin.CancelMarkdownEventResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	moved, err := s.repo.Pricing().CancelMarkdownEvent(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.pricingError(ctx, "cancel the markdown", err)
+	}
+	if len(moved) > 0 {
+		s.revalidateAsync(&dto.RevalidationData{Products: moved})
+	}
+	out := &pb_admin.CancelMarkdownEventResponse{RevertedProductIds: make([]int32, 0, len(moved))}
+	for _, id := range moved {
+		out.RevertedProductIds = append(out.RevertedProductIds, int32(id))
+	}
+	return out, nil
+}
+
+// PreviewMarkdownEvent prices a markdown payload on its colourways without storing it.
+func (s *Server) PreviewMarkdownEvent(ctx context.Context, req *pb_admin.PreviewMarkdownEventRequest) (*pb_admin.PreviewMarkdownEventResponse, error) {
+	ins, err := dto.ConvertPbMarkdownEventInsertToEntity(req.GetEvent(), "")
+	if err != nil {
+		return nil, s.pricingError(ctx, "preview the markdown", err)
+	}
+	lines, err := s.repo.Pricing().PreviewMarkdownEvent(ctx, ins, int(req.GetEventId()))
+	if err != nil {
+		return nil, s.pricingError(ctx, "preview the markdown", err)
+	}
+	return &pb_admin.PreviewMarkdownEventResponse{
+		BaseCurrency: cache.GetBaseCurrency(),
+		Lines:        dto.MarkdownLinesToPb(lines),
+	}, nil
+}
+
+func (s *Server) pricingError(ctx context.Context, op string, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return apierr.Invalid(ve)
+	case errors.Is(err, entity.ErrPriceListNotFound),
+		errors.Is(err, entity.ErrMarkdownEventNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entity.ErrPriceListRateMissing),
+		errors.Is(err, entity.ErrPriceListRateMoved),
+		errors.Is(err, entity.ErrMarkdownEventNotEditable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, dto.ErrInvalidPricingInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case s.repo.IsErrUniqueViolation(err):
+		// Two creates of one currency racing past the store's check.
+		return status.Error(codes.AlreadyExists, "a price list for this currency already exists")
+	}
+	slog.Default().ErrorContext(ctx, "pricing call failed", slog.String("op", op), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op+"; try again")
+}
@@ -863,7 +863,7 @@ func (s *Server) UpdateVariantStock(ctx context.Context, req *pb_admin.UpdateVar
 }
 
 func (s *Server) ListStockChangeHistory(ctx context.Context, req *pb_admin.ListStockChangeHistoryRequest) (*pb_admin.ListStockChangeHistoryResponse, error) {
-	var productId, sizeId *int
+	var productId, sizeId, locationId *int
 	if req.ColorwayId != 0 {
 		pid := int(req.ColorwayId)
 		productId = &pid
@@ -872,6 +872,10 @@ func (s *Server) ListStockChangeHistory(ctx context.Context, req *pb_admin.ListS
 		sid := int(*req.SizeId)
 		sizeId = &sid
 	}
+	if req.LocationId != nil && *req.LocationId != 0 {
+		lid := int(*req.LocationId)
+		locationId = &lid
+	}
 	var dateFrom, dateTo *time.Time
 	if req.DateFrom != nil {
 		t := req.DateFrom.AsTime()
@@ -896,7 +900,7 @@ func (s *Server) ListStockChangeHistory(ctx context.Context, req *pb_admin.ListS
 	}
 
 	sourceFilter := dto.StockChangeSourceToFilterString(req.Source)
-	changes, total, err := s.repo.Products().GetStockChangeHistory(ctx, productId, sizeId, dateFrom, dateTo, sourceFilter, limit, offset, orderFactor)
+	changes, total, err := s.repo.Products().GetStockChangeHistory(ctx, productId, sizeId, locationId, dateFrom, dateTo, sourceFilter, limit, offset, orderFactor)
 	if err != nil {
 		slog.Default().ErrorContext(ctx, "can't get stock change history",
 			slog.String("err", err.Error()),
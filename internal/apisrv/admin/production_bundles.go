@@ -0,0 +1,349 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/bundleticket"
+	"github.com/jekabolt/grbpwr-manager/internal/cutspec"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ПАЧКИ КРОЯ ПРОГОНА (0340) — five RPCs over one set: generate, list, record a scan, delete a scan,
+// and the WIP view. Loads, permissions and gRPC codes live here; cutting a lay into bundles and
+// folding scans into progress live in internal/bundleticket, so the public ticket endpoint and this
+// file cannot disagree about what a coupon is.
+//
+// GENERATE READS THREE THINGS AND WRITES ONE. The operations come from the spec the run is cut by
+// (cutspec.Resolve: the release snapshot when the run has one), the bundles from the named настилы
+// and their раскладки composition, and ReplaceBundles swaps both in one transaction. Nothing is
+// pre-flighted against the old set: the store refuses regeneration once a coupon is scanned, and that
+// is the only refusal the old set can cause.
+//
+// RBAC: registered in internal/rbac/rbac.go beside the cutting receipts (write for generate/record/
+// delete, read for list/WIP, section production).
+
+// ListProductionRunBundles returns the run's bundles with their coupons and ticket tokens.
+func (s *Server) ListProductionRunBundles(ctx context.Context, req *pb_admin.ListProductionRunBundlesRequest) (*pb_admin.ListProductionRunBundlesResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	set, err := s.repo.ProductionRuns().ListBundles(ctx, runID)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "list", runID, err)
+	}
+	return &pb_admin.ListProductionRunBundlesResponse{
+		Operations: convertProductionRunOperations(set.Operations),
+		Bundles:    s.convertProductionRunBundles(set),
+	}, nil
+}
+
+// GenerateProductionRunBundles cuts the named настилы into bundles and snapshots the spec's
+// operations into coupons, replacing whatever the run had.
+func (s *Server) GenerateProductionRunBundles(ctx context.Context, req *pb_admin.GenerateProductionRunBundlesRequest) (*pb_admin.GenerateProductionRunBundlesResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	bundleSize := int(req.GetBundleSize())
+	if bundleSize == 0 {
+		bundleSize = bundleticket.DefaultBundleSize
+	}
+
+	runs := s.repo.ProductionRuns()
+	run, err := runs.GetProductionRun(ctx, runID)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+	spec, err := cutspec.Resolve(ctx, s.repo.TechCards(), run)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "tech card not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't resolve bundle spec",
+			slog.Int("run_id", runID), slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't resolve the run's tech card")
+	}
+	ops, err := bundleticket.OperationsSnapshot(spec.Card.Operations)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+
+	list, err := runs.ListLays(ctx, runID)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+	if !list.Applicable {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, entity.ErrProductionRunLayNotApplicable)
+	}
+	// Настилы берутся в порядке запроса, а не плана: номера пачек идут по нему, и оператор сам
+	// решает, какой настил кроится первым.
+	byKey := make(map[string]entity.ProductionRunLay, len(list.Lays))
+	for _, l := range list.Lays {
+		byKey[l.LayKey] = l
+	}
+	lays := make([]entity.ProductionRunLay, 0, len(req.GetLayKeys()))
+	seen := make(map[string]bool, len(req.GetLayKeys()))
+	var markerIDs []int
+	seenMarker := map[int]bool{}
+	for _, key := range req.GetLayKeys() {
+		l, ok := byKey[key]
+		if !ok {
+			return nil, apierr.Invalid(entity.NewFieldViolation("lay_keys", "lay_not_found", key,
+				"reload the lay plan; the lay is not in this run"))
+		}
+		if seen[key] {
+			return nil, apierr.Invalid(entity.NewFieldViolation("lay_keys", "duplicate", key,
+				"name each lay once"))
+		}
+		seen[key] = true
+		lays = append(lays, l)
+		for _, sec := range l.Sections {
+			if sec.MarkerId > 0 && !seenMarker[sec.MarkerId] {
+				seenMarker[sec.MarkerId] = true
+				markerIDs = append(markerIDs, sec.MarkerId)
+			}
+		}
+	}
+	composition, err := runs.GetRunPackMarkerSizes(ctx, markerIDs)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+	bundles, err := bundleticket.PlanBundles(lays, composition, bundleSize)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+
+	if err := runs.ReplaceBundles(ctx, runID, ops, bundles, authsrv.GetAdminUsername(ctx)); err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+	set, err := runs.ListBundles(ctx, runID)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "generate", runID, err)
+	}
+	return &pb_admin.GenerateProductionRunBundlesResponse{
+		Operations: convertProductionRunOperations(set.Operations),
+		Bundles:    s.convertProductionRunBundles(set),
+	}, nil
+}
+
+// RecordProductionRunBundleScan records an operation from the admin panel — a supervisor catching
+// up a coupon that was never scanned. The admin is recorded_by; the employee is who did the work.
+func (s *Server) RecordProductionRunBundleScan(ctx context.Context, req *pb_admin.RecordProductionRunBundleScanRequest) (*pb_admin.RecordProductionRunBundleScanResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	bundleID := int(req.GetBundleId())
+	// Пачка адресуется через прогон, и принадлежность сверяется здесь: store адресует пачку только
+	// по id (так её видит ярлык), а путь админки обещает прогон.
+	set, err := s.repo.ProductionRuns().GetBundle(ctx, bundleID)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "record", runID, err)
+	}
+	if set.Bundles[0].RunId != runID {
+		return nil, status.Error(codes.NotFound, entity.ErrProductionRunBundleNotFound.Error())
+	}
+	scannedAt := time.Now().UTC()
+	if req.GetScannedAt() != nil {
+		scannedAt = req.GetScannedAt().AsTime().UTC()
+	}
+	scan, err := s.repo.ProductionRuns().RecordBundleScan(ctx, entity.ProductionRunBundleScanInsert{
+		BundleId:     bundleID,
+		OperationSeq: int(req.GetOperationSeq()),
+		EmployeeId:   int(req.GetEmployeeId()),
+		Source:       entity.ProductionRunBundleScanSourceAdmin,
+		RecordedBy:   authsrv.GetAdminUsername(ctx),
+		ScannedAt:    scannedAt,
+	})
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "record", runID, err)
+	}
+	return &pb_admin.RecordProductionRunBundleScanResponse{Scan: convertProductionRunBundleScan(scan)}, nil
+}
+
+// DeleteProductionRunBundleScan removes a wrong scan; the coupon is open again.
+func (s *Server) DeleteProductionRunBundleScan(ctx context.Context, req *pb_admin.DeleteProductionRunBundleScanRequest) (*pb_admin.DeleteProductionRunBundleScanResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if err := s.repo.ProductionRuns().DeleteBundleScan(ctx, runID, int(req.GetScanId())); err != nil {
+		return nil, s.productionRunBundleError(ctx, "delete", runID, err)
+	}
+	return &pb_admin.DeleteProductionRunBundleScanResponse{}, nil
+}
+
+// GetProductionRunWip folds the run's scans into per-operation progress and names the bottleneck.
+func (s *Server) GetProductionRunWip(ctx context.Context, req *pb_admin.GetProductionRunWipRequest) (*pb_admin.GetProductionRunWipResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	set, err := s.repo.ProductionRuns().ListBundles(ctx, runID)
+	if err != nil {
+		return nil, s.productionRunBundleError(ctx, "read", runID, err)
+	}
+	w := bundleticket.ComputeWip(set)
+	resp := &pb_admin.GetProductionRunWipResponse{
+		Operations:       make([]*pb_common.ProductionRunOperationProgress, 0, len(w.Operations)),
+		TotalQty:         int32(w.TotalQty),
+		TotalBundles:     int32(w.TotalBundles),
+		FinishedQty:      int32(w.FinishedQty),
+		FinishedBundles:  int32(w.FinishedBundles),
+		RemainingMinutes: dto.PbDecimalFromNull(decimal.NewNullDecimal(w.RemainingMinutes)),
+		MinutesComplete:  w.MinutesComplete,
+	}
+	for _, p := range w.Operations {
+		resp.Operations = append(resp.Operations, &pb_common.ProductionRunOperationProgress{
+			Operation:        convertProductionRunOperation(p.Operation),
+			DoneQty:          int32(p.DoneQty),
+			DoneBundles:      int32(p.DoneBundles),
+			RemainingQty:     int32(p.RemainingQty),
+			QueuedQty:        int32(p.QueuedQty),
+			RemainingMinutes: dto.PbDecimalFromNull(p.RemainingMinutes),
+			QueuedMinutes:    dto.PbDecimalFromNull(p.QueuedMinutes),
+			Bottleneck:       p.Bottleneck,
+		})
+	}
+	return resp, nil
+}
+
+func convertProductionRunOperations(ops []entity.ProductionRunOperation) []*pb_common.ProductionRunOperation {
+	out := make([]*pb_common.ProductionRunOperation, 0, len(ops))
+	for _, op := range ops {
+		out = append(out, convertProductionRunOperation(op))
+	}
+	return out
+}
+
+func convertProductionRunOperation(op entity.ProductionRunOperation) *pb_common.ProductionRunOperation {
+	return &pb_common.ProductionRunOperation{
+		Id:              int32(op.Id),
+		Seq:             int32(op.Seq),
+		OperationNumber: op.OperationNumber.Int32,
+		OperationType:   op.OperationType,
+		Zone:            op.Zone,
+		MachineType:     op.MachineType.String,
+		Smv:             dto.PbDecimalFromNull(op.SMV),
+		Note:            op.Note.String,
+		Work:            op.Work.String,
+	}
+}
+
+// convertProductionRunBundles projects the set's bundles with their coupons. The token is minted
+// here, on read, and never stored: it is a pure function of the bundle id.
+func (s *Server) convertProductionRunBundles(set *entity.ProductionRunBundleSet) []*pb_common.ProductionRunBundle {
+	coupons := bundleticket.Coupons(set)
+	out := make([]*pb_common.ProductionRunBundle, 0, len(set.Bundles))
+	for _, b := range set.Bundles {
+		pb := &pb_common.ProductionRunBundle{
+			Id:           int32(b.Id),
+			BundleNo:     int32(b.BundleNo),
+			LayKey:       b.LayKey,
+			LayName:      b.LayName,
+			SectionKey:   b.SectionKey,
+			ColorwayId:   int32(b.ColorwayId),
+			ColorwayName: b.ColorwayName,
+			SizeId:       int32(b.SizeId),
+			SizeName:     b.SizeName,
+			StackNo:      int32(b.StackNo),
+			PlyFrom:      int32(b.PlyFrom),
+			PlyTo:        int32(b.PlyTo),
+			Qty:          int32(b.Qty),
+			BundleToken:  s.bundleTickets.MintBundleToken(b.Id),
+			CreatedBy:    b.CreatedBy,
+			CreatedAt:    timestamppb.New(b.CreatedAt),
+		}
+		for _, c := range coupons[b.Id] {
+			pc := &pb_common.ProductionRunBundleCoupon{
+				OperationSeq:    int32(c.Operation.Seq),
+				StandardMinutes: dto.PbDecimalFromNull(c.StandardMinutes),
+			}
+			if c.Scan != nil {
+				pc.Scan = convertProductionRunBundleScan(c.Scan)
+			}
+			pb.Coupons = append(pb.Coupons, pc)
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+var productionRunBundleScanSourcePb = map[entity.ProductionRunBundleScanSource]pb_common.ProductionRunBundleScanSource{
+	entity.ProductionRunBundleScanSourceScan:  pb_common.ProductionRunBundleScanSource_PRODUCTION_RUN_BUNDLE_SCAN_SOURCE_SCAN,
+	entity.ProductionRunBundleScanSourceAdmin: pb_common.ProductionRunBundleScanSource_PRODUCTION_RUN_BUNDLE_SCAN_SOURCE_ADMIN,
+}
+
+func convertProductionRunBundleScan(sc *entity.ProductionRunBundleScan) *pb_common.ProductionRunBundleScan {
+	return &pb_common.ProductionRunBundleScan{
+		Id:           int32(sc.Id),
+		BundleId:     int32(sc.BundleId),
+		OperationSeq: int32(sc.OperationSeq),
+		EmployeeId:   int32(sc.EmployeeId),
+		EmployeeName: sc.EmployeeName,
+		Qty:          int32(sc.Qty),
+		Source:       productionRunBundleScanSourcePb[sc.Source],
+		RecordedBy:   sc.RecordedBy,
+		ScannedAt:    timestamppb.New(sc.ScannedAt),
+	}
+}
+
+// productionRunBundleError maps the store's and the planner's typed refusals onto gRPC codes. ONE
+// table for the five RPCs.
+//
+//	entity.ValidationError                     → InvalidArgument + BadRequest field violations
+//	ErrProductionRunBundlesScanned             → FailedPrecondition (delete the scans first)
+//	ErrProductionRunLocked                     → FailedPrecondition
+//	ErrProductionRunLayNotApplicable           → FailedPrecondition, reason lay_plan_not_applicable
+//	ErrEmployeeNotActive                       → FailedPrecondition
+//	ErrProductionRunBundleScanDuplicate        → AlreadyExists
+//	ErrProductionRunBundleNotFound             → NotFound
+//	ErrProductionRunOperationNotFound          → NotFound
+//	ErrProductionRunBundleScanNotFound         → NotFound
+//	sql.ErrNoRows                              → NotFound (the run)
+//	FK violation                               → InvalidArgument
+func (s *Server) productionRunBundleError(ctx context.Context, op string, runID int, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return apierr.Invalid(ve)
+	case errors.Is(err, entity.ErrProductionRunBundlesScanned):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, entity.ErrProductionRunLocked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, entity.ErrProductionRunLayNotApplicable):
+		return apierr.FailedPrecondition(entity.NewFieldViolation("run_id",
+			entity.ProductionRunLayNotApplicableKey, "an auxiliary tech card has no lays to cut into bundles",
+			"an auxiliary run is received through its own step 1 — there is nothing to bundle"))
+	case errors.Is(err, entity.ErrEmployeeNotActive),
+		errors.Is(err, entity.ErrPayslipApproved):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, entity.ErrProductionRunBundleScanDuplicate):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, entity.ErrProductionRunBundleNotFound),
+		errors.Is(err, entity.ErrProductionRunOperationNotFound),
+		errors.Is(err, entity.ErrProductionRunBundleScanNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "production run not found")
+	case s.repo.IsErrForeignKeyViolation(err):
+		return status.Error(codes.InvalidArgument, "the bundle references a missing lay, size or employee")
+	}
+	slog.Default().ErrorContext(ctx, "production run bundle call failed",
+		slog.String("op", op), slog.Int("run_id", runID), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op+" the bundles; try again")
+}
@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ПОДБОР НАСТИЛОВ. Same division of labour as production_lays.go: this file loads and maps codes,
+// dto.OptimizeLays decides. Apply saves through the very SaveLay the editor uses, one настил at a
+// time, so a proposed настил meets the same guards, the same lock and the same reservation
+// reconcile as a hand-built one.
+
+// layOptimizerRequest is what Suggest and Apply share: one (колорвей, слот) pair and how to lay it.
+type layOptimizerRequest interface {
+	GetRunId() int32
+	GetColorwayId() int32
+	GetBomLineKey() string
+	GetMode() pb_common.ProductionLayMode
+	GetEndLossCm() *pb_decimal.Decimal
+}
+
+// SuggestProductionRunLays proposes the раскладки and ply counts that cut what one pair still owes.
+func (s *Server) SuggestProductionRunLays(ctx context.Context, req *pb_admin.SuggestProductionRunLaysRequest) (*pb_admin.SuggestProductionRunLaysResponse, error) {
+	in, err := s.loadLayOptimizerInput(ctx, req, newLayMarkerCache(s.repo.TechCards()))
+	if err != nil {
+		return nil, err
+	}
+	return dto.LayOptimizationToPb(dto.OptimizeLays(in)), nil
+}
+
+// ApplyProductionRunLaySuggestion saves one proposal as настилы and returns the refreshed lay plan.
+//
+// The proposal is recomputed, never trusted from the wire: the client sends the fingerprint of what
+// the operator looked at, and a proposal that moved since — a настил saved by somebody else, a
+// marker re-captured — is refused with Aborted before anything is written.
+//
+// NOT ONE TRANSACTION. Each настил is its own SaveLay, because SaveLay is where the lock, the snapshot
+// and the scope guards live and a second write path would be a second definition of them. A failure
+// halfway leaves the настилы already saved in place, visible in the plan, and the error names how
+// many were.
+func (s *Server) ApplyProductionRunLaySuggestion(ctx context.Context, req *pb_admin.ApplyProductionRunLaySuggestionRequest) (*pb_admin.ApplyProductionRunLaySuggestionResponse, error) {
+	strategy := dto.LayOptimizerStrategy(req.GetStrategy())
+	if !dto.ValidLayOptimizerStrategies[strategy] {
+		return nil, status.Errorf(codes.InvalidArgument, "strategy must be %s or %s",
+			dto.LayOptimizerLeastFabric, dto.LayOptimizerFewestLays)
+	}
+	markers := newLayMarkerCache(s.repo.TechCards())
+	in, err := s.loadLayOptimizerInput(ctx, req, markers)
+	if err != nil {
+		return nil, err
+	}
+	plan, ok := dto.OptimizeLays(in).Plan(strategy)
+	if !ok || plan.Fingerprint != req.GetPlanFingerprint() {
+		return nil, status.Error(codes.Aborted, "the lay proposal has changed since it was shown; review it again")
+	}
+	if len(plan.Lays) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the proposal has no lays to save")
+	}
+
+	firstOrder := 0
+	for _, l := range in.Lays {
+		if l.DisplayOrder >= firstOrder {
+			firstOrder = l.DisplayOrder + 1
+		}
+	}
+	runID := in.Run.Id
+	username := authsrv.GetAdminUsername(ctx)
+	resp := &pb_admin.ApplyProductionRunLaySuggestionResponse{}
+	for i, ins := range dto.LayOptimizerInserts(in, plan, firstOrder) {
+		if st := s.preflightLayForSave(ctx, runID, ins, markers); st != nil {
+			return nil, layOptimizerPartial(st, i)
+		}
+		saved, err := s.repo.ProductionRuns().SaveLay(ctx, runID, ins, entity.LockGuardFromProto(nil), false, username)
+		if err != nil {
+			return nil, layOptimizerPartial(s.productionRunLayError(ctx, "save", runID, err), i)
+		}
+		resp.LayKeys = append(resp.LayKeys, saved.LayKey)
+	}
+
+	s.reconcileRunReservations(ctx, runID, username)
+
+	resp.Plan, err = s.loadRunLayPlan(ctx, runID, markers)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// layOptimizerPartial keeps the refusal's code and says how many настилы were saved before it.
+func layOptimizerPartial(err error, saved int) error {
+	if saved == 0 {
+		return err
+	}
+	st, _ := status.FromError(err)
+	return status.Errorf(st.Code(), "%s (%d lay(s) of the proposal were saved before this one; reload the run)", st.Message(), saved)
+}
+
+// loadLayOptimizerInput gathers what dto.OptimizeLays reads: the run, its card, the pair's slot, the
+// run's stored настилы, every run marker distilled, the article behind the pair and the workshop's
+// limits.
+func (s *Server) loadLayOptimizerInput(ctx context.Context, req layOptimizerRequest, markers *layMarkerCache) (dto.LayOptimizerInput, error) {
+	var in dto.LayOptimizerInput
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return in, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if req.GetColorwayId() <= 0 {
+		return in, status.Error(codes.InvalidArgument, "colorway_id is required")
+	}
+	mode, endLoss, err := dto.LayOptimizerParamsFromPb(req.GetMode(), req.GetEndLossCm())
+	if err != nil {
+		var ve *entity.ValidationError
+		if errors.As(err, &ve) {
+			return in, apierr.Invalid(ve)
+		}
+		return in, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	list, err := s.repo.ProductionRuns().ListLays(ctx, runID)
+	if err != nil {
+		return in, s.productionRunLayError(ctx, "list", runID, err)
+	}
+	if !list.Applicable {
+		return in, status.Error(codes.FailedPrecondition, productionRunLayNotApplicableMsg)
+	}
+	run, err := s.repo.ProductionRuns().GetProductionRun(ctx, runID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return in, status.Error(codes.NotFound, "production run not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't load production run for lay suggestion", slog.String("err", err.Error()))
+		return in, status.Error(codes.Internal, "can't load production run")
+	}
+	card, err := s.repo.TechCards().GetTechCardById(ctx, run.TechCardId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return in, status.Error(codes.NotFound, "tech card not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't load tech card for lay suggestion", slog.String("err", err.Error()))
+		return in, status.Error(codes.Internal, "can't load tech card")
+	}
+	lineKey := strings.TrimSpace(req.GetBomLineKey())
+	bomItemID := layBomItemByLineKey(card, lineKey)
+	if !bomItemID.Valid {
+		return in, status.Errorf(codes.InvalidArgument, "bom_line_key %q names no BOM line of the run's tech card", lineKey)
+	}
+
+	runMarkers, err := s.repo.TechCards().ListRunMarkers(ctx, runID)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list run markers for lay suggestion", slog.String("err", err.Error()))
+		return in, status.Error(codes.Internal, "can't list the markers of this run")
+	}
+	// Run markers are the candidates; section markers tell what the stored настилы already cut. A
+	// vanished row is tolerated in both roles — it is simply not proposed, and its section is not
+	// subtracted, which the optimiser says out loud.
+	ids := make([]int, 0, len(runMarkers))
+	for _, m := range runMarkers {
+		ids = append(ids, m.Id)
+	}
+	for i := range list.Lays {
+		for _, sec := range list.Lays[i].Sections {
+			ids = append(ids, sec.MarkerId)
+		}
+	}
+	for _, id := range ids {
+		if _, err := markers.get(ctx, id); err != nil {
+			if errors.Is(err, entity.ErrMarkerNotFound) {
+				continue
+			}
+			slog.Default().ErrorContext(ctx, "can't load marker for lay suggestion", slog.String("err", err.Error()))
+			return in, status.Error(codes.Internal, "can't load the marker")
+		}
+	}
+
+	materials := make(map[int]entity.MaterialWithPrice, len(card.LinkedMaterials)+1)
+	for mid, m := range card.LinkedMaterials {
+		materials[mid] = m
+	}
+	if mid := dto.LayArticleMaterialId(card, int(req.GetColorwayId()), bomItemID.Int64); mid > 0 {
+		if _, ok := materials[mid]; !ok {
+			// Best-effort, as in the plan: without the article the stack limit is simply unchecked,
+			// and the proposal says so.
+			if m, merr := s.repo.TechCards().GetMaterial(ctx, mid); merr == nil && m != nil {
+				materials[mid] = *m
+			}
+		}
+	}
+	settings, err := s.repo.Workshop().GetSettings(ctx)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't load workshop settings for lay suggestion", slog.String("err", err.Error()))
+		return in, status.Error(codes.Internal, "can't load workshop settings")
+	}
+	article, limits := dto.LayOptimizerFacts(card, int(req.GetColorwayId()), bomItemID.Int64, materials, settings)
+
+	return dto.LayOptimizerInput{
+		Run:        run,
+		Card:       card,
+		ColorwayId: int(req.GetColorwayId()),
+		BomItemId:  bomItemID.Int64,
+		BomLineKey: lineKey,
+		Mode:       mode,
+		EndLossCm:  endLoss,
+		Lays:       list.Lays,
+		Markers:    markers.all(),
+		RunMarkers: runMarkers,
+		Article:    article,
+		Limits:     limits,
+	}, nil
+}
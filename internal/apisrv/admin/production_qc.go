@@ -0,0 +1,462 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/qcsampling"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// КОНТРОЛЬ КАЧЕСТВА ПРИЁМКИ (0343) — the defect catalog, inspections of a run's lots and the defect
+// analytics. Shapes, defaults and gRPC codes live here; the sampling tables and the verdict live in
+// internal/qcsampling, and the verdict is taken by the store under the inspection's lock, so neither
+// this file nor the client ever chooses passed or failed.
+//
+// THE RECEIPT GATE IS NOT HERE. An inspection is consumed by PostProductionRunReceipt (inspection_id)
+// inside the receipt transaction; this file only opens, fills and deletes inspections.
+//
+// RBAC: registered in internal/rbac/rbac.go beside the workshop calendar (write for the catalog and
+// inspections, read for lists, the plan preview and analytics, section production).
+
+// qcDefectCodeRe is the shape of a catalog code: what an inspector writes on the sheet.
+var qcDefectCodeRe = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_]{0,31}$`)
+
+// defaultQcAnalyticsDays is the analytics window when the request names no start.
+const defaultQcAnalyticsDays = 90
+
+// ListQcDefectTypes returns the defect catalog.
+func (s *Server) ListQcDefectTypes(ctx context.Context, req *pb_admin.ListQcDefectTypesRequest) (*pb_admin.ListQcDefectTypesResponse, error) {
+	list, err := s.repo.ProductionRuns().ListQcDefectTypes(ctx, req.GetIncludeRetired())
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "list defect types", 0, err)
+	}
+	resp := &pb_admin.ListQcDefectTypesResponse{DefectTypes: make([]*pb_common.QcDefectType, 0, len(list))}
+	for i := range list {
+		resp.DefectTypes = append(resp.DefectTypes, convertQcDefectType(&list[i]))
+	}
+	return resp, nil
+}
+
+// CreateQcDefectType adds an entry to the defect catalog.
+func (s *Server) CreateQcDefectType(ctx context.Context, req *pb_admin.CreateQcDefectTypeRequest) (*pb_admin.CreateQcDefectTypeResponse, error) {
+	ins, err := qcDefectTypeInsertFromPb(req.GetDefectType())
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "create defect type", 0, err)
+	}
+	t, err := s.repo.ProductionRuns().CreateQcDefectType(ctx, ins, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "create defect type", 0, err)
+	}
+	return &pb_admin.CreateQcDefectTypeResponse{DefectType: convertQcDefectType(t)}, nil
+}
+
+// UpdateQcDefectType rewrites a catalog entry; recorded findings keep their snapshot.
+func (s *Server) UpdateQcDefectType(ctx context.Context, req *pb_admin.UpdateQcDefectTypeRequest) (*pb_admin.UpdateQcDefectTypeResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	ins, err := qcDefectTypeInsertFromPb(req.GetDefectType())
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "update defect type", 0, err)
+	}
+	t, err := s.repo.ProductionRuns().UpdateQcDefectType(ctx, int(req.GetId()), ins)
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "update defect type", 0, err)
+	}
+	return &pb_admin.UpdateQcDefectTypeResponse{DefectType: convertQcDefectType(t)}, nil
+}
+
+// SetQcDefectTypeRetired takes an entry off the inspector's sheet, or puts it back.
+func (s *Server) SetQcDefectTypeRetired(ctx context.Context, req *pb_admin.SetQcDefectTypeRetiredRequest) (*pb_admin.SetQcDefectTypeRetiredResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	t, err := s.repo.ProductionRuns().SetQcDefectTypeRetired(ctx, int(req.GetId()), req.GetRetired())
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "retire defect type", 0, err)
+	}
+	return &pb_admin.SetQcDefectTypeRetiredResponse{DefectType: convertQcDefectType(t)}, nil
+}
+
+// PreviewQcSamplingPlan returns the plan a lot would be inspected by. Writes nothing.
+func (s *Server) PreviewQcSamplingPlan(ctx context.Context, req *pb_admin.PreviewQcSamplingPlanRequest) (*pb_admin.PreviewQcSamplingPlanResponse, error) {
+	plan, err := qcsampling.Plan(int(req.GetLotSize()), qcInspectionLevelFromPb(req.GetInspectionLevel()),
+		qcAqlFromPb(req.GetAqlMajor(), entity.QcAql25), qcAqlFromPb(req.GetAqlMinor(), entity.QcAql40))
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "preview plan", 0, err)
+	}
+	return &pb_admin.PreviewQcSamplingPlanResponse{Plan: convertQcSamplingPlan(plan)}, nil
+}
+
+// CreateProductionRunQcInspection opens an inspection of a lot with the plan its size calls for.
+func (s *Server) CreateProductionRunQcInspection(ctx context.Context, req *pb_admin.CreateProductionRunQcInspectionRequest) (*pb_admin.CreateProductionRunQcInspectionResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	ins := entity.QcInspectionInsert{
+		RunId:           runID,
+		LotSize:         int(req.GetLotSize()),
+		InspectionLevel: qcInspectionLevelFromPb(req.GetInspectionLevel()),
+		AqlMajor:        qcAqlFromPb(req.GetAqlMajor(), entity.QcAql25),
+		AqlMinor:        qcAqlFromPb(req.GetAqlMinor(), entity.QcAql40),
+		CreatedBy:       authsrv.GetAdminUsername(ctx),
+	}
+	plan, err := qcsampling.Plan(ins.LotSize, ins.InspectionLevel, ins.AqlMajor, ins.AqlMinor)
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "create inspection", runID, err)
+	}
+	ins.Plan = plan
+	inspector := strings.TrimSpace(req.GetInspector())
+	if utf8.RuneCountInString(inspector) > 255 {
+		return nil, apierr.Invalid(entity.NewFieldViolation("inspector", "too_long", "", "at most 255 characters"))
+	}
+	ins.Inspector = sql.NullString{String: inspector, Valid: inspector != ""}
+	note := strings.TrimSpace(req.GetNote())
+	ins.Note = sql.NullString{String: note, Valid: note != ""}
+
+	in, err := s.repo.ProductionRuns().CreateQcInspection(ctx, ins)
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "create inspection", runID, err)
+	}
+	return &pb_admin.CreateProductionRunQcInspectionResponse{Inspection: convertQcInspection(in)}, nil
+}
+
+// ListProductionRunQcInspections returns the run's inspections with their findings, newest first.
+func (s *Server) ListProductionRunQcInspections(ctx context.Context, req *pb_admin.ListProductionRunQcInspectionsRequest) (*pb_admin.ListProductionRunQcInspectionsResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	list, err := s.repo.ProductionRuns().ListQcInspections(ctx, runID)
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "list inspections", runID, err)
+	}
+	resp := &pb_admin.ListProductionRunQcInspectionsResponse{Inspections: make([]*pb_common.QcInspection, 0, len(list))}
+	for i := range list {
+		resp.Inspections = append(resp.Inspections, convertQcInspection(&list[i]))
+	}
+	return resp, nil
+}
+
+// RecordProductionRunQcFindings replaces the findings of an inspection and returns its verdict.
+func (s *Server) RecordProductionRunQcFindings(ctx context.Context, req *pb_admin.RecordProductionRunQcFindingsRequest) (*pb_admin.RecordProductionRunQcFindingsResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if req.GetInspectionId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "inspection_id is required")
+	}
+	findings := make([]entity.QcFindingInput, 0, len(req.GetFindings()))
+	for _, f := range req.GetFindings() {
+		if f.GetDefectTypeId() <= 0 {
+			return nil, apierr.Invalid(entity.NewFieldViolation("findings.defect_type_id", "required", "",
+				"every finding names a defect of the catalog"))
+		}
+		if f.GetQty() < 1 {
+			return nil, apierr.Invalid(entity.NewFieldViolation("findings.qty", "must_be_positive", "",
+				"a finding counts at least one garment; drop the row instead"))
+		}
+		if f.GetOperationSeq() < 0 {
+			return nil, apierr.Invalid(entity.NewFieldViolation("findings.operation_seq", "must_not_be_negative", "",
+				"use 0 for a defect not traced to a step"))
+		}
+		findings = append(findings, entity.QcFindingInput{
+			DefectTypeId: int(f.GetDefectTypeId()),
+			OperationSeq: int(f.GetOperationSeq()),
+			Qty:          int(f.GetQty()),
+		})
+	}
+	in, err := s.repo.ProductionRuns().RecordQcFindings(ctx, runID, int(req.GetInspectionId()), findings, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "record findings", runID, err)
+	}
+	return &pb_admin.RecordProductionRunQcFindingsResponse{Inspection: convertQcInspection(in)}, nil
+}
+
+// DeleteProductionRunQcInspection removes an inspection no receipt has used.
+func (s *Server) DeleteProductionRunQcInspection(ctx context.Context, req *pb_admin.DeleteProductionRunQcInspectionRequest) (*pb_admin.DeleteProductionRunQcInspectionResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if err := s.repo.ProductionRuns().DeleteQcInspection(ctx, runID, int(req.GetInspectionId())); err != nil {
+		return nil, s.productionRunQcError(ctx, "delete inspection", runID, err)
+	}
+	return &pb_admin.DeleteProductionRunQcInspectionResponse{}, nil
+}
+
+// GetQcDefectAnalytics returns the defect rates of the inspections decided in the period.
+func (s *Server) GetQcDefectAnalytics(ctx context.Context, req *pb_admin.GetQcDefectAnalyticsRequest) (*pb_admin.GetQcDefectAnalyticsResponse, error) {
+	to := time.Now().UTC()
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime().UTC()
+	}
+	from := to.AddDate(0, 0, -defaultQcAnalyticsDays)
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime().UTC()
+	}
+	if !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+	a, err := s.repo.ProductionRuns().GetQcDefectAnalytics(ctx, entity.QcDefectAnalyticsFilter{From: from, To: to})
+	if err != nil {
+		return nil, s.productionRunQcError(ctx, "analytics", 0, err)
+	}
+	resp := &pb_admin.GetQcDefectAnalyticsResponse{
+		Total:       convertQcDefectRate(a.Total),
+		ByStyle:     convertQcDefectRates(a.ByStyle),
+		ByOperation: convertQcDefectRates(a.ByOperation),
+		ByWorkshop:  convertQcDefectRates(a.ByWorkshop),
+		From:        timestamppb.New(from),
+		To:          timestamppb.New(to),
+	}
+	return resp, nil
+}
+
+// qcDefectTypeInsertFromPb validates a catalog entry as written.
+func qcDefectTypeInsertFromPb(pb *pb_common.QcDefectTypeInsert) (entity.QcDefectTypeInsert, error) {
+	if pb == nil {
+		return entity.QcDefectTypeInsert{}, entity.NewFieldViolation("defect_type", "required", "", "send the defect type")
+	}
+	ins := entity.QcDefectTypeInsert{
+		Code:     strings.ToUpper(strings.TrimSpace(pb.GetCode())),
+		Name:     strings.TrimSpace(pb.GetName()),
+		Severity: qcDefectSeverityFromPb[pb.GetSeverity()],
+	}
+	if !qcDefectCodeRe.MatchString(ins.Code) {
+		return ins, entity.NewFieldViolation("code", "invalid_code", ins.Code,
+			"1–32 characters of A–Z, 0–9 and _, starting with a letter or digit")
+	}
+	if ins.Name == "" || utf8.RuneCountInString(ins.Name) > 128 {
+		return ins, entity.NewFieldViolation("name", "invalid_name", "", "a name of 1–128 characters")
+	}
+	if !entity.ValidQcDefectSeverity(ins.Severity) {
+		return ins, entity.NewFieldViolation("severity", "required", "", "pick critical, major or minor")
+	}
+	if v := strings.TrimSpace(pb.GetOperationType()); v != "" {
+		// "unknown" is legal in an operation row, never as what a defect is produced by.
+		if v == "unknown" || !slices.Contains(entity.OperationTypeTokens, v) {
+			return ins, entity.NewFieldViolation("operation_type", "unknown_operation_type", v,
+				"use an operation type of the tech card vocabulary")
+		}
+		ins.OperationType = sql.NullString{String: v, Valid: true}
+	}
+	if v := strings.TrimSpace(pb.GetWork()); v != "" {
+		ins.Work = sql.NullString{String: v, Valid: true}
+	}
+	if v := strings.TrimSpace(pb.GetDescription()); v != "" {
+		ins.Description = sql.NullString{String: v, Valid: true}
+	}
+	return ins, nil
+}
+
+var qcDefectSeverityFromPb = map[pb_common.QcDefectSeverity]entity.QcDefectSeverity{
+	pb_common.QcDefectSeverity_QC_DEFECT_SEVERITY_CRITICAL: entity.QcDefectCritical,
+	pb_common.QcDefectSeverity_QC_DEFECT_SEVERITY_MAJOR:    entity.QcDefectMajor,
+	pb_common.QcDefectSeverity_QC_DEFECT_SEVERITY_MINOR:    entity.QcDefectMinor,
+}
+
+var qcDefectSeverityPb = map[entity.QcDefectSeverity]pb_common.QcDefectSeverity{
+	entity.QcDefectCritical: pb_common.QcDefectSeverity_QC_DEFECT_SEVERITY_CRITICAL,
+	entity.QcDefectMajor:    pb_common.QcDefectSeverity_QC_DEFECT_SEVERITY_MAJOR,
+	entity.QcDefectMinor:    pb_common.QcDefectSeverity_QC_DEFECT_SEVERITY_MINOR,
+}
+
+var qcInspectionResultPb = map[entity.QcInspectionResult]pb_common.QcInspectionResult{
+	entity.QcInspectionPending: pb_common.QcInspectionResult_QC_INSPECTION_RESULT_PENDING,
+	entity.QcInspectionPassed:  pb_common.QcInspectionResult_QC_INSPECTION_RESULT_PASSED,
+	entity.QcInspectionFailed:  pb_common.QcInspectionResult_QC_INSPECTION_RESULT_FAILED,
+}
+
+var qcAqlPb = map[entity.QcAql]pb_common.QcAql{
+	entity.QcAql25: pb_common.QcAql_QC_AQL_2_5,
+	entity.QcAql40: pb_common.QcAql_QC_AQL_4_0,
+}
+
+var qcInspectionLevelPb = map[entity.QcInspectionLevel]pb_common.QcInspectionLevel{
+	entity.QcInspectionLevelI:   pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_I,
+	entity.QcInspectionLevelII:  pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_II,
+	entity.QcInspectionLevelIII: pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_III,
+}
+
+// qcAqlFromPb reads an AQL; UNKNOWN is the class's default. A value outside the enum comes back
+// as an unknown token, and qcsampling.Plan names the field.
+func qcAqlFromPb(a pb_common.QcAql, def entity.QcAql) entity.QcAql {
+	switch a {
+	case pb_common.QcAql_QC_AQL_UNKNOWN:
+		return def
+	case pb_common.QcAql_QC_AQL_2_5:
+		return entity.QcAql25
+	case pb_common.QcAql_QC_AQL_4_0:
+		return entity.QcAql40
+	}
+	return entity.QcAql(a.String())
+}
+
+// qcInspectionLevelFromPb reads a level; UNKNOWN is level II, the default of the standard.
+func qcInspectionLevelFromPb(l pb_common.QcInspectionLevel) entity.QcInspectionLevel {
+	switch l {
+	case pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_UNKNOWN, pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_II:
+		return entity.QcInspectionLevelII
+	case pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_I:
+		return entity.QcInspectionLevelI
+	case pb_common.QcInspectionLevel_QC_INSPECTION_LEVEL_III:
+		return entity.QcInspectionLevelIII
+	}
+	return entity.QcInspectionLevel(l.String())
+}
+
+func convertQcDefectType(t *entity.QcDefectType) *pb_common.QcDefectType {
+	return &pb_common.QcDefectType{
+		Id:            int32(t.Id),
+		Code:          t.Code,
+		Name:          t.Name,
+		Severity:      qcDefectSeverityPb[t.Severity],
+		OperationType: t.OperationType.String,
+		Work:          t.Work.String,
+		Description:   t.Description.String,
+		Retired:       t.RetiredAt.Valid,
+		CreatedBy:     t.CreatedBy,
+		CreatedAt:     timestamppb.New(t.CreatedAt),
+		UpdatedAt:     timestamppb.New(t.UpdatedAt),
+	}
+}
+
+func convertQcSamplingPlan(p entity.QcSamplingPlan) *pb_common.QcSamplingPlan {
+	return &pb_common.QcSamplingPlan{
+		CodeLetter:     p.CodeLetter,
+		SampleSize:     int32(p.SampleSize),
+		FullInspection: p.FullInspection,
+		MajorAccept:    int32(p.MajorAccept),
+		MajorReject:    int32(p.MajorReject),
+		MinorAccept:    int32(p.MinorAccept),
+		MinorReject:    int32(p.MinorReject),
+	}
+}
+
+// convertQcInspection projects an inspection. The fail reasons are not stored: they are re-derived
+// from the stored plan and the snapshotted findings, which is exactly what the verdict was taken on.
+func convertQcInspection(in *entity.QcInspection) *pb_common.QcInspection {
+	counts := qcsampling.Count(in.Defects)
+	pb := &pb_common.QcInspection{
+		Id:              int32(in.Id),
+		RunId:           int32(in.RunId),
+		ReceiptId:       in.ReceiptId.Int32,
+		LotSize:         int32(in.LotSize),
+		InspectionLevel: qcInspectionLevelPb[in.InspectionLevel],
+		AqlMajor:        qcAqlPb[in.AqlMajor],
+		AqlMinor:        qcAqlPb[in.AqlMinor],
+		Plan:            convertQcSamplingPlan(in.Plan()),
+		Result:          qcInspectionResultPb[in.Result],
+		CriticalFound:   int32(counts.Critical),
+		MajorFound:      int32(counts.Major),
+		MinorFound:      int32(counts.Minor),
+		Findings:        make([]*pb_common.QcInspectionFinding, 0, len(in.Defects)),
+		Inspector:       in.Inspector.String,
+		Note:            in.Note.String,
+		CreatedBy:       in.CreatedBy,
+		CreatedAt:       timestamppb.New(in.CreatedAt),
+		DecidedBy:       in.DecidedBy.String,
+	}
+	if in.DecidedAt.Valid {
+		pb.DecidedAt = timestamppb.New(in.DecidedAt.Time)
+	}
+	if in.Result == entity.QcInspectionFailed {
+		_, pb.FailReasons = qcsampling.Verdict(in.Plan(), counts)
+	}
+	for _, d := range in.Defects {
+		pb.Findings = append(pb.Findings, &pb_common.QcInspectionFinding{
+			DefectTypeId:  int32(d.DefectTypeId),
+			DefectCode:    d.DefectCode,
+			DefectName:    d.DefectName,
+			Severity:      qcDefectSeverityPb[d.Severity],
+			OperationSeq:  d.OperationSeq.Int32,
+			OperationType: d.OperationType.String,
+			Work:          d.Work.String,
+			Qty:           int32(d.Qty),
+		})
+	}
+	return pb
+}
+
+func convertQcDefectRates(rows []entity.QcDefectRateRow) []*pb_common.QcDefectRate {
+	out := make([]*pb_common.QcDefectRate, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, convertQcDefectRate(r))
+	}
+	return out
+}
+
+// convertQcDefectRate derives the two rates of a bucket. A bucket with nothing inspected has no
+// rate rather than a zero one: "no defects found" and "nothing looked at" must not read alike.
+func convertQcDefectRate(r entity.QcDefectRateRow) *pb_common.QcDefectRate {
+	pb := &pb_common.QcDefectRate{
+		Key:         r.Key,
+		Label:       r.Label,
+		Inspections: int32(r.Inspections),
+		Failed:      int32(r.Failed),
+		Inspected:   int32(r.Inspected),
+		Critical:    int32(r.Critical),
+		Major:       int32(r.Major),
+		Minor:       int32(r.Minor),
+	}
+	hundred := decimal.NewFromInt(100)
+	if r.Inspected > 0 {
+		dhu := decimal.NewFromInt(int64(r.Defects())).Mul(hundred).Div(decimal.NewFromInt(int64(r.Inspected))).Round(2)
+		pb.Dhu = dto.PbDecimalFromNull(decimal.NewNullDecimal(dhu))
+	}
+	if r.Inspections > 0 {
+		passed := decimal.NewFromInt(int64(r.Inspections - r.Failed))
+		rate := passed.Mul(hundred).Div(decimal.NewFromInt(int64(r.Inspections))).Round(2)
+		pb.PassRate = dto.PbDecimalFromNull(decimal.NewNullDecimal(rate))
+	}
+	return pb
+}
+
+// productionRunQcError maps the QC refusals onto gRPC codes. ONE table for the ten RPCs.
+//
+//	entity.ValidationError           → InvalidArgument + BadRequest field violations
+//	ErrQcInspectionConsumed          → FailedPrecondition (reverse the receipt first)
+//	ErrQcDefectTypeRetired           → FailedPrecondition (restore it or pick another)
+//	ErrQcDefectTypeNotFound          → NotFound
+//	ErrQcInspectionNotFound          → NotFound
+//	ErrQcOperationNotFound           → NotFound
+//	sql.ErrNoRows                    → NotFound (the run)
+func (s *Server) productionRunQcError(ctx context.Context, op string, runID int, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return apierr.Invalid(ve)
+	case errors.Is(err, entity.ErrQcInspectionConsumed),
+		errors.Is(err, entity.ErrQcDefectTypeRetired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, entity.ErrQcDefectTypeNotFound),
+		errors.Is(err, entity.ErrQcInspectionNotFound),
+		errors.Is(err, entity.ErrQcOperationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "production run not found")
+	}
+	slog.Default().ErrorContext(ctx, "qc call failed",
+		slog.String("op", op), slog.Int("run_id", runID), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op)
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/runschedule"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ПЛАН ЗАГРУЗКИ ЦЕХА (0342). The handler only gathers: the workshop settings, the closed days over
+// the scheduler's whole look-ahead (a run may be pushed far past the drawn horizon) and the open
+// runs' facts. Every verdict is runschedule.Plan's.
+
+// GetProductionSchedule returns the Gantt-style timeline of the open runs against the workshop's
+// capacity.
+func (s *Server) GetProductionSchedule(ctx context.Context, req *pb_admin.GetProductionScheduleRequest) (*pb_admin.GetProductionScheduleResponse, error) {
+	from, horizon, err := dto.ScheduleWindowFromPb(req, time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	settings, err := s.repo.Workshop().GetSettings(ctx)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get workshop settings", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't read the workshop settings; try again")
+	}
+	span := max(horizon, runschedule.LookaheadDays)
+	holidays, err := s.repo.Workshop().ListHolidays(ctx, from, from.AddDate(0, 0, span-1))
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list workshop holidays", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't read the workshop calendar; try again")
+	}
+	runs, err := s.repo.ProductionRuns().ListRunsForSchedule(ctx)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list runs for schedule", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't read the production runs; try again")
+	}
+	plan := runschedule.Plan(settings.Capacity(), holidays, runs, from, horizon)
+	return dto.ProductionScheduleToPb(plan), nil
+}
+
+// ListWorkshopHolidays returns the workshop's closed days, oldest first.
+func (s *Server) ListWorkshopHolidays(ctx context.Context, req *pb_admin.ListWorkshopHolidaysRequest) (*pb_admin.ListWorkshopHolidaysResponse, error) {
+	from, to, err := dto.HolidayRangeFromPb(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	list, err := s.repo.Workshop().ListHolidays(ctx, from, to)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list workshop holidays", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't read the workshop calendar; try again")
+	}
+	return &pb_admin.ListWorkshopHolidaysResponse{Holidays: dto.WorkshopHolidayListToPb(list)}, nil
+}
+
+// UpsertWorkshopHoliday closes a day, or changes the note of a day already closed.
+func (s *Server) UpsertWorkshopHoliday(ctx context.Context, req *pb_admin.UpsertWorkshopHolidayRequest) (*pb_admin.UpsertWorkshopHolidayResponse, error) {
+	day, err := dto.HolidayDayFromPb(req.GetDay())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	note, err := dto.HolidayNoteFromPb(req.GetNote())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.repo.Workshop().UpsertHoliday(ctx, day, note, authsrv.GetAdminUsername(ctx)); err != nil {
+		slog.Default().ErrorContext(ctx, "can't save workshop holiday", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't save the closed day; try again")
+	}
+	return &pb_admin.UpsertWorkshopHolidayResponse{}, nil
+}
+
+// DeleteWorkshopHoliday reopens a closed day.
+func (s *Server) DeleteWorkshopHoliday(ctx context.Context, req *pb_admin.DeleteWorkshopHolidayRequest) (*pb_admin.DeleteWorkshopHolidayResponse, error) {
+	day, err := dto.HolidayDayFromPb(req.GetDay())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.repo.Workshop().DeleteHoliday(ctx, day); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "%s is not a closed day", req.GetDay())
+		}
+		slog.Default().ErrorContext(ctx, "can't delete workshop holiday", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't reopen the day; try again")
+	}
+	return &pb_admin.DeleteWorkshopHolidayResponse{}, nil
+}
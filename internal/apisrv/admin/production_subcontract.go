@@ -0,0 +1,539 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ПОДРЯД НА ПОШИВ (0344) — factory profiles, CMT price agreements, and a run's subcontract: the
+// assignment, the kit shipped to and back from the factory, the portal link and the reconciliation.
+// Shapes and gRPC codes live here; the stock side of the kit lives in internal/store/inventory
+// (consign.go), and the factory's own side of the portal in internal/subcontractportal.
+//
+// MONEY IS COSTING. An agreement's price, the run's price snapshot and the kit's unit costs are
+// confidential exactly like a run's cost lines: reads strip them without costing:read, and opening
+// an agreement requires costing:write. Quantities — what went to the factory and what came back —
+// stay visible to production.
+//
+// RBAC: registered in internal/rbac/rbac.go beside QC (write for the profile, agreements and every
+// run call that moves something, read for the lists and the subcontract read, section production).
+
+// maxSubcontractText is the cap on the free-text fields of a profile and the notes.
+const maxSubcontractText = 1000
+
+// ListSubcontractors returns the factory profiles.
+func (s *Server) ListSubcontractors(ctx context.Context, req *pb_admin.ListSubcontractorsRequest) (*pb_admin.ListSubcontractorsResponse, error) {
+	list, err := s.repo.ProductionRuns().ListSubcontractors(ctx, req.GetIncludeArchived())
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "list subcontractors", 0, err)
+	}
+	resp := &pb_admin.ListSubcontractorsResponse{Subcontractors: make([]*pb_common.Subcontractor, 0, len(list))}
+	for i := range list {
+		resp.Subcontractors = append(resp.Subcontractors, convertSubcontractor(&list[i]))
+	}
+	return resp, nil
+}
+
+// UpsertSubcontractor creates or replaces the profile of a catalog supplier.
+func (s *Server) UpsertSubcontractor(ctx context.Context, req *pb_admin.UpsertSubcontractorRequest) (*pb_admin.UpsertSubcontractorResponse, error) {
+	pb := req.GetSubcontractor()
+	if pb.GetSupplierId() <= 0 {
+		return nil, apierr.Invalid(entity.NewFieldViolation("supplier_id", "required", "", "pick a supplier from the supplier catalog"))
+	}
+	ins := entity.SubcontractorInsert{SupplierId: int(pb.GetSupplierId())}
+	for _, f := range []struct {
+		name string
+		v    string
+		dst  *sql.NullString
+	}{
+		{"contact_name", pb.GetContactName(), &ins.ContactName},
+		{"contact_email", pb.GetContactEmail(), &ins.ContactEmail},
+		{"contact_phone", pb.GetContactPhone(), &ins.ContactPhone},
+		{"address", pb.GetAddress(), &ins.Address},
+		{"note", pb.GetNote(), &ins.Note},
+	} {
+		v, err := subcontractText(f.name, f.v)
+		if err != nil {
+			return nil, apierr.Invalid(err)
+		}
+		*f.dst = v
+	}
+	sc, err := s.repo.ProductionRuns().UpsertSubcontractor(ctx, ins, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "save subcontractor", 0, err)
+	}
+	return &pb_admin.UpsertSubcontractorResponse{Subcontractor: convertSubcontractor(sc)}, nil
+}
+
+// SetSubcontractorArchived takes a factory off assignment, or puts it back.
+func (s *Server) SetSubcontractorArchived(ctx context.Context, req *pb_admin.SetSubcontractorArchivedRequest) (*pb_admin.SetSubcontractorArchivedResponse, error) {
+	if req.GetSupplierId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "supplier_id is required")
+	}
+	sc, err := s.repo.ProductionRuns().SetSubcontractorArchived(ctx, int(req.GetSupplierId()), req.GetArchived())
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "archive subcontractor", 0, err)
+	}
+	return &pb_admin.SetSubcontractorArchivedResponse{Subcontractor: convertSubcontractor(sc)}, nil
+}
+
+// ListCmtPriceAgreements returns the agreements matching the filter.
+func (s *Server) ListCmtPriceAgreements(ctx context.Context, req *pb_admin.ListCmtPriceAgreementsRequest) (*pb_admin.ListCmtPriceAgreementsResponse, error) {
+	list, err := s.repo.ProductionRuns().ListCmtPriceAgreements(ctx, entity.CmtPriceAgreementFilter{
+		SupplierId:    int(req.GetSupplierId()),
+		TechCardId:    int(req.GetTechCardId()),
+		IncludeClosed: req.GetIncludeClosed(),
+	})
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "list cmt price agreements", 0, err)
+	}
+	read, _ := s.costingAccess(ctx)
+	resp := &pb_admin.ListCmtPriceAgreementsResponse{Agreements: make([]*pb_common.CmtPriceAgreement, 0, len(list))}
+	for i := range list {
+		pb := convertCmtPriceAgreement(&list[i])
+		if !read {
+			stripCmtPriceAgreementCosting(pb)
+		}
+		resp.Agreements = append(resp.Agreements, pb)
+	}
+	return resp, nil
+}
+
+// CreateCmtPriceAgreement opens an agreement. A price is costing, so it takes costing:write.
+func (s *Server) CreateCmtPriceAgreement(ctx context.Context, req *pb_admin.CreateCmtPriceAgreementRequest) (*pb_admin.CreateCmtPriceAgreementResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to set a cmt price")
+	}
+	ins, err := cmtPriceAgreementInsertFromPb(req.GetAgreement())
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "create cmt price agreement", 0, err)
+	}
+	a, err := s.repo.ProductionRuns().CreateCmtPriceAgreement(ctx, ins, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "create cmt price agreement", 0, err)
+	}
+	return &pb_admin.CreateCmtPriceAgreementResponse{Agreement: convertCmtPriceAgreement(a)}, nil
+}
+
+// CloseCmtPriceAgreement ends an agreement on a day. Like opening one, it takes costing:write.
+func (s *Server) CloseCmtPriceAgreement(ctx context.Context, req *pb_admin.CloseCmtPriceAgreementRequest) (*pb_admin.CloseCmtPriceAgreementResponse, error) {
+	if _, write := s.costingAccess(ctx); !write {
+		return nil, status.Error(codes.PermissionDenied, "costing:write is required to close a cmt price")
+	}
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if req.GetValidTo() == nil {
+		return nil, apierr.Invalid(entity.NewFieldViolation("valid_to", "required", "", "the last day the price is in force"))
+	}
+	a, err := s.repo.ProductionRuns().CloseCmtPriceAgreement(ctx, int(req.GetId()), req.GetValidTo().AsTime().UTC())
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "close cmt price agreement", 0, err)
+	}
+	return &pb_admin.CloseCmtPriceAgreementResponse{Agreement: convertCmtPriceAgreement(a)}, nil
+}
+
+// AssignProductionRunSubcontractor hands a run to a factory at an agreement's price.
+func (s *Server) AssignProductionRunSubcontractor(ctx context.Context, req *pb_admin.AssignProductionRunSubcontractorRequest) (*pb_admin.AssignProductionRunSubcontractorResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if req.GetSupplierId() <= 0 {
+		return nil, apierr.Invalid(entity.NewFieldViolation("supplier_id", "required", "", "pick the factory"))
+	}
+	rs, err := s.repo.ProductionRuns().AssignRunSubcontractor(ctx, runID, int(req.GetSupplierId()),
+		int(req.GetAgreementId()), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "assign subcontractor", runID, err)
+	}
+	return &pb_admin.AssignProductionRunSubcontractorResponse{Subcontract: s.runSubcontractToPb(ctx, rs)}, nil
+}
+
+// GetProductionRunSubcontract returns the run's subcontract with its kit and portal token.
+func (s *Server) GetProductionRunSubcontract(ctx context.Context, req *pb_admin.GetProductionRunSubcontractRequest) (*pb_admin.GetProductionRunSubcontractResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	rs, err := s.repo.ProductionRuns().GetRunSubcontract(ctx, runID)
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "get subcontract", runID, err)
+	}
+	return &pb_admin.GetProductionRunSubcontractResponse{Subcontract: s.runSubcontractToPb(ctx, rs)}, nil
+}
+
+// UpdateProductionRunSubcontractPortal rotates, revokes or re-dates the factory's link.
+func (s *Server) UpdateProductionRunSubcontractPortal(ctx context.Context, req *pb_admin.UpdateProductionRunSubcontractPortalRequest) (*pb_admin.UpdateProductionRunSubcontractPortalResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if req.GetRotate() && req.GetRevoke() {
+		return nil, status.Error(codes.InvalidArgument, "rotate and revoke are exclusive: rotate issues a new link, revoke kills the current one")
+	}
+	upd := entity.SubcontractPortalUpdate{Rotate: req.GetRotate(), Revoke: req.GetRevoke()}
+	if req.GetExpiresAt() != nil {
+		upd.ExpiresAt = sql.NullTime{Time: req.GetExpiresAt().AsTime().UTC(), Valid: true}
+	}
+	rs, err := s.repo.ProductionRuns().UpdateRunSubcontractPortal(ctx, runID, upd)
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "update portal", runID, err)
+	}
+	return &pb_admin.UpdateProductionRunSubcontractPortalResponse{Subcontract: s.runSubcontractToPb(ctx, rs)}, nil
+}
+
+// ShipProductionRunKit ships materials to the run's factory.
+func (s *Server) ShipProductionRunKit(ctx context.Context, req *pb_admin.ShipProductionRunKitRequest) (*pb_admin.ShipProductionRunKitResponse, error) {
+	if req.GetRunId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	mv, err := subcontractKitMoveFromPb(req.GetRunId(), req.GetLines(), req.GetOccurredAt(), req.GetComment())
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "ship kit", int(req.GetRunId()), err)
+	}
+	mv.Username = authsrv.GetAdminUsername(ctx)
+	movements, err := s.repo.ProductionRuns().ShipSubcontractKit(ctx, mv)
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "ship kit", mv.RunId, err)
+	}
+	out, rs, err := s.kitMoveResult(ctx, mv.RunId, movements)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.ShipProductionRunKitResponse{Movements: out, Subcontract: rs}, nil
+}
+
+// ReturnProductionRunKit takes leftover materials back from the run's factory.
+func (s *Server) ReturnProductionRunKit(ctx context.Context, req *pb_admin.ReturnProductionRunKitRequest) (*pb_admin.ReturnProductionRunKitResponse, error) {
+	if req.GetRunId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	mv, err := subcontractKitMoveFromPb(req.GetRunId(), req.GetLines(), req.GetOccurredAt(), req.GetComment())
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "return kit", int(req.GetRunId()), err)
+	}
+	mv.Username = authsrv.GetAdminUsername(ctx)
+	movements, err := s.repo.ProductionRuns().ReturnSubcontractKit(ctx, mv)
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "return kit", mv.RunId, err)
+	}
+	out, rs, err := s.kitMoveResult(ctx, mv.RunId, movements)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.ReturnProductionRunKitResponse{Movements: out, Subcontract: rs}, nil
+}
+
+// ReconcileProductionRunSubcontract closes the run's kit and accrues the CMT charge.
+func (s *Server) ReconcileProductionRunSubcontract(ctx context.Context, req *pb_admin.ReconcileProductionRunSubcontractRequest) (*pb_admin.ReconcileProductionRunSubcontractResponse, error) {
+	runID := int(req.GetRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "run_id is required")
+	}
+	if req.GetReturnedGoodQty() < 0 || req.GetReturnedDefectQty() < 0 {
+		return nil, apierr.Invalid(entity.NewFieldViolation("returned_good_qty", "must_not_be_negative", "",
+			"count the garments that came back; 0 when none did"))
+	}
+	in := entity.SubcontractReconcileInput{
+		RunId:          runID,
+		ReturnedGood:   int(req.GetReturnedGoodQty()),
+		ReturnedDefect: int(req.GetReturnedDefectQty()),
+		Declared:       make(map[int]decimal.Decimal, len(req.GetDeclared())),
+		Username:       authsrv.GetAdminUsername(ctx),
+	}
+	for _, l := range req.GetDeclared() {
+		if l.GetMaterialId() <= 0 {
+			return nil, apierr.Invalid(entity.NewFieldViolation("declared.material_id", "required", "", "name the material"))
+		}
+		if _, dup := in.Declared[int(l.GetMaterialId())]; dup {
+			return nil, apierr.Invalid(entity.NewFieldViolation("declared.material_id", "duplicate",
+				fmt.Sprint(l.GetMaterialId()), "declare each material once"))
+		}
+		q, err := kitQuantity("declared.quantity", l.GetQuantity(), true)
+		if err != nil {
+			return nil, apierr.Invalid(err)
+		}
+		in.Declared[int(l.GetMaterialId())] = q
+	}
+	note, ve := subcontractText("note", req.GetNote())
+	if ve != nil {
+		return nil, apierr.Invalid(ve)
+	}
+	in.Note = note
+	rs, err := s.repo.ProductionRuns().ReconcileRunSubcontract(ctx, in)
+	if err != nil {
+		return nil, s.productionSubcontractError(ctx, "reconcile subcontract", runID, err)
+	}
+	return &pb_admin.ReconcileProductionRunSubcontractResponse{Subcontract: s.runSubcontractToPb(ctx, rs)}, nil
+}
+
+// kitMoveResult projects the movements of a kit call with the subcontract as it stands after it.
+func (s *Server) kitMoveResult(ctx context.Context, runID int, movements []entity.MaterialMovement) ([]*pb_common.MaterialMovement, *pb_common.ProductionRunSubcontract, error) {
+	out := make([]*pb_common.MaterialMovement, 0, len(movements))
+	for _, m := range movements {
+		out = append(out, s.movementToPb(ctx, m))
+	}
+	rs, err := s.repo.ProductionRuns().GetRunSubcontract(ctx, runID)
+	if err != nil {
+		return nil, nil, s.productionSubcontractError(ctx, "get subcontract", runID, err)
+	}
+	return out, s.runSubcontractToPb(ctx, rs), nil
+}
+
+// subcontractKitMoveFromPb validates a kit shipment or return as written: at least one line, each
+// material once, positive quantities.
+func subcontractKitMoveFromPb(runID int32, lines []*pb_admin.SubcontractKitLineInput, occurredAt *timestamppb.Timestamp, comment string) (entity.SubcontractKitMove, error) {
+	if len(lines) == 0 {
+		return entity.SubcontractKitMove{}, entity.NewFieldViolation("lines", "required", "", "add at least one material")
+	}
+	mv := entity.SubcontractKitMove{RunId: int(runID), Lines: make([]entity.SubcontractKitInput, 0, len(lines))}
+	seen := make(map[int32]bool, len(lines))
+	for _, l := range lines {
+		if l.GetMaterialId() <= 0 {
+			return mv, entity.NewFieldViolation("lines.material_id", "required", "", "name the material")
+		}
+		if seen[l.GetMaterialId()] {
+			return mv, entity.NewFieldViolation("lines.material_id", "duplicate", fmt.Sprint(l.GetMaterialId()),
+				"put each material on one line")
+		}
+		seen[l.GetMaterialId()] = true
+		q, err := kitQuantity("lines.quantity", l.GetQuantity(), false)
+		if err != nil {
+			return mv, err
+		}
+		mv.Lines = append(mv.Lines, entity.SubcontractKitInput{MaterialId: int(l.GetMaterialId()), Quantity: q})
+	}
+	if occurredAt != nil {
+		mv.OccurredAt = sql.NullTime{Time: occurredAt.AsTime().UTC(), Valid: true}
+	}
+	c, err := subcontractText("comment", comment)
+	if err != nil {
+		return mv, err
+	}
+	mv.Comment = c
+	return mv, nil
+}
+
+// kitQuantity reads a material quantity: positive, or zero as well when allowZero (a declared
+// consumption of nothing is a real report).
+func kitQuantity(field string, d *pb_decimal.Decimal, allowZero bool) (decimal.Decimal, *entity.ValidationError) {
+	q, err := decimal.NewFromString(strings.TrimSpace(d.GetValue()))
+	if err != nil {
+		return decimal.Zero, entity.NewFieldViolation(field, "invalid_number", d.GetValue(), "a decimal quantity in the material's unit")
+	}
+	if q.IsNegative() || (!allowZero && q.IsZero()) {
+		return decimal.Zero, entity.NewFieldViolation(field, "must_be_positive", q.String(), "a quantity above zero")
+	}
+	return q, nil
+}
+
+// subcontractText trims a free-text field and caps its length; empty is NULL.
+func subcontractText(field, v string) (sql.NullString, *entity.ValidationError) {
+	v = strings.TrimSpace(v)
+	if utf8.RuneCountInString(v) > maxSubcontractText {
+		return sql.NullString{}, entity.NewFieldViolation(field, "too_long", "", fmt.Sprintf("at most %d characters", maxSubcontractText))
+	}
+	return sql.NullString{String: v, Valid: v != ""}, nil
+}
+
+// cmtPriceAgreementInsertFromPb validates an agreement as written.
+func cmtPriceAgreementInsertFromPb(pb *pb_common.CmtPriceAgreementInsert) (entity.CmtPriceAgreementInsert, error) {
+	if pb == nil {
+		return entity.CmtPriceAgreementInsert{}, entity.NewFieldViolation("agreement", "required", "", "send the agreement")
+	}
+	ins := entity.CmtPriceAgreementInsert{
+		SupplierId: int(pb.GetSupplierId()),
+		TechCardId: int(pb.GetTechCardId()),
+		Currency:   strings.ToUpper(strings.TrimSpace(pb.GetCurrency())),
+	}
+	if ins.SupplierId <= 0 {
+		return ins, entity.NewFieldViolation("supplier_id", "required", "", "pick the factory")
+	}
+	if ins.TechCardId <= 0 {
+		return ins, entity.NewFieldViolation("tech_card_id", "required", "", "pick the style")
+	}
+	price, err := decimal.NewFromString(strings.TrimSpace(pb.GetUnitPrice().GetValue()))
+	if err != nil || !price.IsPositive() {
+		return ins, entity.NewFieldViolation("unit_price", "must_be_positive", pb.GetUnitPrice().GetValue(),
+			"the price of sewing one garment, above zero")
+	}
+	ins.UnitPrice = price
+	if !dto.IsExpenseCurrency(ins.Currency) {
+		return ins, entity.NewFieldViolation("currency", "unsupported_currency", ins.Currency,
+			"use a supported currency code")
+	}
+	if pb.GetValidFrom() == nil {
+		return ins, entity.NewFieldViolation("valid_from", "required", "", "the first day the price is in force")
+	}
+	ins.ValidFrom = pb.GetValidFrom().AsTime().UTC().Truncate(24 * time.Hour)
+	if pb.GetValidTo() != nil {
+		to := pb.GetValidTo().AsTime().UTC().Truncate(24 * time.Hour)
+		if to.Before(ins.ValidFrom) {
+			return ins, entity.NewFieldViolation("valid_to", "before_valid_from", to.Format(time.DateOnly),
+				"the last day cannot come before the first")
+		}
+		ins.ValidTo = sql.NullTime{Time: to, Valid: true}
+	}
+	note, verr := subcontractText("note", pb.GetNote())
+	if verr != nil {
+		return ins, verr
+	}
+	ins.Note = note
+	return ins, nil
+}
+
+func convertSubcontractor(sc *entity.Subcontractor) *pb_common.Subcontractor {
+	return &pb_common.Subcontractor{
+		SupplierId:   int32(sc.SupplierId),
+		SupplierName: sc.SupplierName,
+		ContactName:  sc.ContactName.String,
+		ContactEmail: sc.ContactEmail.String,
+		ContactPhone: sc.ContactPhone.String,
+		Address:      sc.Address.String,
+		Note:         sc.Note.String,
+		Archived:     sc.ArchivedAt.Valid,
+		CreatedBy:    sc.CreatedBy,
+		CreatedAt:    timestamppb.New(sc.CreatedAt),
+		UpdatedAt:    timestamppb.New(sc.UpdatedAt),
+	}
+}
+
+func convertCmtPriceAgreement(a *entity.CmtPriceAgreement) *pb_common.CmtPriceAgreement {
+	pb := &pb_common.CmtPriceAgreement{
+		Id:           int32(a.Id),
+		SupplierId:   int32(a.SupplierId),
+		SupplierName: a.SupplierName,
+		TechCardId:   int32(a.TechCardId),
+		StyleNumber:  a.StyleNumber,
+		StyleName:    a.StyleName,
+		UnitPrice:    &pb_decimal.Decimal{Value: a.UnitPrice.String()},
+		Currency:     a.Currency,
+		ValidFrom:    timestamppb.New(a.ValidFrom),
+		Note:         a.Note.String,
+		CreatedBy:    a.CreatedBy,
+		CreatedAt:    timestamppb.New(a.CreatedAt),
+	}
+	if a.ValidTo.Valid {
+		pb.ValidTo = timestamppb.New(a.ValidTo.Time)
+	}
+	return pb
+}
+
+// runSubcontractToPb projects a run's subcontract with its portal token, stripped of money when the
+// caller has no costing:read.
+func (s *Server) runSubcontractToPb(ctx context.Context, rs *entity.ProductionRunSubcontract) *pb_common.ProductionRunSubcontract {
+	pb := &pb_common.ProductionRunSubcontract{
+		RunId:             int32(rs.RunId),
+		SupplierId:        int32(rs.SupplierId),
+		SupplierName:      rs.SupplierName,
+		AgreementId:       rs.AgreementId.Int32,
+		UnitPrice:         &pb_decimal.Decimal{Value: rs.UnitPrice.String()},
+		Currency:          rs.Currency,
+		PortalToken:       s.subcontractPortal.MintPortalToken(rs),
+		PortalEpoch:       int32(rs.PortalEpoch),
+		ConfirmedQty:      rs.ConfirmedQty.Int32,
+		FactoryNote:       rs.FactoryNote.String,
+		Reconciled:        rs.Reconciled(),
+		ReturnedGoodQty:   rs.ReturnedGoodQty.Int32,
+		ReturnedDefectQty: rs.ReturnedDefectQty.Int32,
+		ReconcileNote:     rs.ReconcileNote.String,
+		ReconciledBy:      rs.ReconciledBy.String,
+		Kit:               make([]*pb_common.SubcontractKitLine, 0, len(rs.Kit)),
+		AssignedBy:        rs.AssignedBy,
+		CreatedAt:         timestamppb.New(rs.CreatedAt),
+		UpdatedAt:         timestamppb.New(rs.UpdatedAt),
+	}
+	for _, t := range []struct {
+		v   sql.NullTime
+		dst **timestamppb.Timestamp
+	}{
+		{rs.PortalExpiresAt, &pb.PortalExpiresAt},
+		{rs.PortalRevokedAt, &pb.PortalRevokedAt},
+		{rs.ConfirmedShipDate, &pb.ConfirmedShipDate},
+		{rs.ConfirmedAt, &pb.ConfirmedAt},
+		{rs.ReconciledAt, &pb.ReconciledAt},
+	} {
+		if t.v.Valid {
+			*t.dst = timestamppb.New(t.v.Time)
+		}
+	}
+	for _, l := range rs.Kit {
+		pb.Kit = append(pb.Kit, &pb_common.SubcontractKitLine{
+			MaterialId:       int32(l.MaterialId),
+			MaterialName:     l.MaterialName,
+			Unit:             l.Unit,
+			Shipped:          &pb_decimal.Decimal{Value: l.Shipped.String()},
+			Returned:         &pb_decimal.Decimal{Value: l.Returned.String()},
+			Consumed:         &pb_decimal.Decimal{Value: l.Consumed.String()},
+			AtFactory:        &pb_decimal.Decimal{Value: l.AtFactory().String()},
+			DeclaredConsumed: dto.PbDecimalFromNull(l.DeclaredConsumed),
+			Variance:         dto.PbDecimalFromNull(l.Variance()),
+			UnitCostBase:     dto.PbDecimalFromNull(l.UnitCostBase),
+		})
+	}
+	if read, _ := s.costingAccess(ctx); !read {
+		stripProductionRunSubcontractCosting(pb)
+	}
+	return pb
+}
+
+// productionSubcontractError maps the subcontract refusals onto gRPC codes. ONE table for the
+// twelve RPCs; the stock refusals of the kit come through mapInventoryErr's codes.
+//
+//	entity.ValidationError              → InvalidArgument + BadRequest field violations
+//	ErrSubcontractorArchived            → FailedPrecondition (restore the factory first)
+//	ErrCmtAgreementOverlap              → FailedPrecondition (close the other agreement first)
+//	ErrSubcontractKitShipped            → FailedPrecondition (take the kit back first)
+//	ErrSubcontractReconciled            → FailedPrecondition
+//	ErrExcessiveConsignReturn           → FailedPrecondition
+//	ErrInsufficientMaterialStock, …     → as mapInventoryErr
+//	ErrSubcontractorNotFound            → NotFound
+//	ErrCmtAgreementNotFound             → NotFound (none in force for the factory and style)
+//	ErrSubcontractNotFound              → NotFound (the run is not on subcontract)
+//	sql.ErrNoRows                       → NotFound (the run)
+func (s *Server) productionSubcontractError(ctx context.Context, op string, runID int, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return apierr.Invalid(ve)
+	case errors.Is(err, entity.ErrSubcontractorArchived),
+		errors.Is(err, entity.ErrCmtAgreementOverlap),
+		errors.Is(err, entity.ErrSubcontractKitShipped),
+		errors.Is(err, entity.ErrSubcontractReconciled),
+		errors.Is(err, entity.ErrExcessiveConsignReturn),
+		errors.Is(err, entity.ErrInsufficientMaterialStock),
+		errors.Is(err, entity.ErrMaterialArchived),
+		errors.Is(err, entity.ErrMaterialIssueTargetInvalid):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, entity.ErrMaterialNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, entity.ErrSubcontractorNotFound),
+		errors.Is(err, entity.ErrCmtAgreementNotFound),
+		errors.Is(err, entity.ErrSubcontractNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "production run not found")
+	}
+	slog.Default().ErrorContext(ctx, "subcontract call failed",
+		slog.String("op", op), slog.Int("run_id", runID), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op)
+}
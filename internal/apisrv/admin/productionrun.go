@@ -380,8 +380,11 @@ func (s *Server) PostProductionRunReceipt(ctx context.Context, req *pb_admin.Pos
 		slog.Default().ErrorContext(ctx, "can't load production run for receipt", slog.String("err", err.Error()))
 		return nil, status.Error(codes.Internal, "can't load production run")
 	}
+	if req.InspectionId < 0 {
+		return nil, status.Error(codes.InvalidArgument, "inspection_id must not be negative")
+	}
 	result, st := s.executeRunReceipt(ctx, run, lines, key, entity.LockGuardFromProto(req.ExpectedLockVersion),
-		strings.TrimSpace(req.Note), req.UpdateCostPrice, !req.Partial, false)
+		strings.TrimSpace(req.Note), int(req.InspectionId), req.UpdateCostPrice, !req.Partial, false)
 	if st != nil {
 		return nil, st
 	}
@@ -578,7 +581,7 @@ func (s *Server) ReceiveProductionRun(ctx context.Context, req *pb_admin.Receive
 	// receipt from the run's STORED counts, which an old client stamped through UpdateProductionRun.
 	// So it passes the ABSENT token (legacy last-write-wins) rather than a literal 0, which under
 	// Ф6.5 would now be a real expectation and would reject every run past its first save.
-	result, st := s.executeRunReceipt(ctx, run, lines, key, entity.NoLockVersion(), "", req.UpdateCostPrice, true, true)
+	result, st := s.executeRunReceipt(ctx, run, lines, key, entity.NoLockVersion(), "", 0, req.UpdateCostPrice, true, true)
 	if st != nil {
 		return nil, st
 	}
@@ -656,7 +659,7 @@ func (s *Server) fireStockWriteSideEffects(ctx context.Context, transitions []en
 // rollup write SETs instead of accumulating, and the aux dispatch below refuses a card that
 // produces by colour, which is a shape the shim's stamped-counts flow predates.
 func (s *Server) executeRunReceipt(ctx context.Context, run *entity.ProductionRun, lines []entity.ProductionRunReceiptLineInput,
-	idempotencyKey string, expectedLockVersion entity.LockGuard, note string, updateCostPrice, final, legacyTotals bool) (*entity.PostProductionRunReceiptResult, error) {
+	idempotencyKey string, expectedLockVersion entity.LockGuard, note string, inspectionID int, updateCostPrice, final, legacyTotals bool) (*entity.PostProductionRunReceiptResult, error) {
 	runID := run.Id
 	// Moving sellable stock needs products:write on top of production:write (the RBAC interceptor
 	// gate). An account granted the permission after login must re-login — permissions ride in the JWT.
@@ -678,7 +681,7 @@ func (s *Server) executeRunReceipt(ctx context.Context, run *entity.ProductionRu
 		RunID:               runID,
 		Lines:               lines,
 		IdempotencyKey:      idempotencyKey,
-		RequestHash:         dto.HashProductionRunReceiptPayload(runID, lines, note, updateCostPrice, final),
+		RequestHash:         dto.HashProductionRunReceiptInspected(runID, lines, note, updateCostPrice, final, inspectionID),
 		ExpectedLockVersion: expectedLockVersion,
 		Note:                note,
 		UpdateCostPrice:     updateCostPrice,
@@ -687,6 +690,7 @@ func (s *Server) executeRunReceipt(ctx context.Context, run *entity.ProductionRu
 		Final:               final,
 		LegacyTotals:        legacyTotals,
 		NormalLossRate:      s.defectNormalLossRate,
+		InspectionID:        inspectionID,
 	}
 	// NF-07: an auxiliary card's output is received into the material warehouse, not product stock.
 	if card.Purpose == entity.TechCardPurposeAuxiliary {
@@ -766,6 +770,16 @@ func (s *Server) executeRunReceipt(ctx context.Context, run *entity.ProductionRu
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		case errors.Is(err, entity.ErrIdempotencyConflict):
 			return nil, status.Error(codes.AlreadyExists, err.Error())
+		case errors.Is(err, entity.ErrQcInspectionRequired),
+			errors.Is(err, entity.ErrQcInspectionPending),
+			errors.Is(err, entity.ErrQcInspectionFailed),
+			errors.Is(err, entity.ErrQcInspectionConsumed),
+			errors.Is(err, entity.ErrSubcontractNotReconciled):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, entity.ErrQcInspectionLotExceeded):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, entity.ErrQcInspectionNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, status.Error(codes.NotFound, "production run not found")
 		case s.repo.IsErrForeignKeyViolation(err):
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"log/slog"
 
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
 	"github.com/jekabolt/grbpwr-manager/internal/dto"
 	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
 	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
@@ -25,6 +26,9 @@ func (s *Server) AddPromo(ctx context.Context, req *pb_admin.AddPromoRequest) (*
 
 	err = s.repo.Promo().AddPromo(ctx, pi)
 	if err != nil {
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
 		slog.Default().ErrorContext(ctx, "can't add promo",
 			slog.String("err", err.Error()),
 		)
@@ -52,6 +56,9 @@ func (s *Server) UpdatePromoCode(ctx context.Context, req *pb_admin.UpdatePromoC
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, status.Errorf(codes.NotFound, "promo code not found")
 		}
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
 		slog.Default().ErrorContext(ctx, "can't update promo code",
 			slog.String("err", err.Error()),
 		)
@@ -112,3 +119,42 @@ func (s *Server) ListPromos(ctx context.Context, req *pb_admin.ListPromosRequest
 		PromoCodes: pbPromos,
 	}, nil
 }
+
+func (s *Server) GeneratePromoUniqueCodes(ctx context.Context, req *pb_admin.GeneratePromoUniqueCodesRequest) (*pb_admin.GeneratePromoUniqueCodesResponse, error) {
+	generated, err := s.repo.Promo().GenerateUniqueCodes(ctx, req.Code, int(req.Count), req.Prefix, req.Batch)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "promo code not found")
+		}
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
+		slog.Default().ErrorContext(ctx, "can't generate unique promo codes",
+			slog.String("err", err.Error()),
+			slog.String("code", req.Code),
+		)
+		return nil, status.Errorf(codes.Internal, "can't generate unique promo codes")
+	}
+	return &pb_admin.GeneratePromoUniqueCodesResponse{Codes: generated}, nil
+}
+
+func (s *Server) ListPromoUniqueCodes(ctx context.Context, req *pb_admin.ListPromoUniqueCodesRequest) (*pb_admin.ListPromoUniqueCodesResponse, error) {
+	limit, offset := clampPagination(int(req.Limit), int(req.Offset))
+	ucs, total, redeemed, err := s.repo.Promo().ListUniqueCodes(ctx, req.Code, req.Batch, limit, offset)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list unique promo codes",
+			slog.String("err", err.Error()),
+			slog.String("code", req.Code),
+		)
+		return nil, status.Errorf(codes.Internal, "can't list unique promo codes")
+	}
+	pbCodes := make([]*pb_common.PromoUniqueCode, 0, len(ucs))
+	for i := range ucs {
+		pbCodes = append(pbCodes, dto.ConvertEntityPromoUniqueCodeToPb(&ucs[i]))
+	}
+	return &pb_admin.ListPromoUniqueCodesResponse{
+		UniqueCodes: pbCodes,
+		Total:       int32(total),
+		Redeemed:    int32(redeemed),
+	}, nil
+}
@@ -0,0 +1,341 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ЗАКАЗ ПОСТАВЩИКУ (0336). Приёмка по заказу — это обычная приёмка на склад (ReceiveInTx), только
+// с указанием строки заказа: цена и валюта берутся из заказа, поставщик — из заказа, и кредиторка
+// возникает на ПРИНЯТОЕ, а не на заказанное. Цены в заказе — тот же костинг, что и в приёмке:
+// записать их можно только с costing:write, увидеть — только с costing:read.
+
+// CreatePurchaseOrder stores a new draft.
+func (s *Server) CreatePurchaseOrder(ctx context.Context, req *pb_admin.CreatePurchaseOrderRequest) (*pb_admin.CreatePurchaseOrderResponse, error) {
+	ins, err := dto.ConvertPbPurchaseOrderInsert(req.GetOrder())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.requirePurchasePriceWrite(ctx, ins); err != nil {
+		return nil, err
+	}
+	ins.CreatedBy = authsrv.GetAdminUsername(ctx)
+	id, err := s.repo.PurchaseOrders().CreatePurchaseOrder(ctx, ins)
+	if err != nil {
+		if s.repo.IsErrForeignKeyViolation(err) {
+			return nil, status.Error(codes.InvalidArgument, "unknown supplier, material or production run")
+		}
+		return nil, mapPurchaseOrderErr(ctx, "create purchase order", err)
+	}
+	po, err := s.purchaseOrderToPb(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.CreatePurchaseOrderResponse{Order: po}, nil
+}
+
+// UpdatePurchaseOrder replaces a draft's contents.
+func (s *Server) UpdatePurchaseOrder(ctx context.Context, req *pb_admin.UpdatePurchaseOrderRequest) (*pb_admin.UpdatePurchaseOrderResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	ins, err := dto.ConvertPbPurchaseOrderInsert(req.GetOrder())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.requirePurchasePriceWrite(ctx, ins); err != nil {
+		return nil, err
+	}
+	if err := s.repo.PurchaseOrders().UpdatePurchaseOrder(ctx, int(req.GetId()), ins); err != nil {
+		if s.repo.IsErrForeignKeyViolation(err) {
+			return nil, status.Error(codes.InvalidArgument, "unknown supplier, material or production run")
+		}
+		return nil, mapPurchaseOrderErr(ctx, "update purchase order", err)
+	}
+	po, err := s.purchaseOrderToPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.UpdatePurchaseOrderResponse{Order: po}, nil
+}
+
+// GetPurchaseOrder returns an order with the receipts booked against it.
+func (s *Server) GetPurchaseOrder(ctx context.Context, req *pb_admin.GetPurchaseOrderRequest) (*pb_admin.GetPurchaseOrderResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	po, err := s.purchaseOrderToPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	movements, _, err := s.repo.MaterialStock().ListMaterialMovements(ctx, 100, 0, entity.MaterialMovementFilter{
+		PurchaseOrderId: int(req.GetId()),
+	})
+	if err != nil {
+		return nil, mapInventoryErr(ctx, "list purchase order receipts", err)
+	}
+	out := &pb_admin.GetPurchaseOrderResponse{Order: po}
+	// The ledger lists newest first; a PO's history reads in delivery order.
+	for i := len(movements) - 1; i >= 0; i-- {
+		out.Receipts = append(out.Receipts, s.movementToPb(ctx, movements[i]))
+	}
+	return out, nil
+}
+
+// ListPurchaseOrders lists orders, newest first.
+func (s *Server) ListPurchaseOrders(ctx context.Context, req *pb_admin.ListPurchaseOrdersRequest) (*pb_admin.ListPurchaseOrdersResponse, error) {
+	limit, offset := int(req.GetLimit()), int(req.GetOffset())
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	orders, total, err := s.repo.PurchaseOrders().ListPurchaseOrders(ctx, limit, offset, entity.PurchaseOrderFilter{
+		Status:          dto.PurchaseOrderStatusFromPb(req.GetStatus()),
+		SupplierId:      int(req.GetSupplierId()),
+		MaterialId:      int(req.GetMaterialId()),
+		ProductionRunId: int(req.GetProductionRunId()),
+		OpenOnly:        req.GetOpenOnly(),
+	})
+	if err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "list purchase orders", err)
+	}
+	read, _ := s.costingAccess(ctx)
+	today := s.repo.Now()
+	out := make([]*pb_common.PurchaseOrder, 0, len(orders))
+	for _, o := range orders {
+		pb := dto.ConvertPurchaseOrderToPb(o, today)
+		if !read {
+			dto.StripPurchaseOrderCosting(pb)
+		}
+		out = append(out, pb)
+	}
+	return &pb_admin.ListPurchaseOrdersResponse{Orders: out, Total: int32(total)}, nil
+}
+
+// SetPurchaseOrderStatus sends, cancels or closes an order.
+func (s *Server) SetPurchaseOrderStatus(ctx context.Context, req *pb_admin.SetPurchaseOrderStatusRequest) (*pb_admin.SetPurchaseOrderStatusResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	to := dto.PurchaseOrderStatusFromPb(req.GetStatus())
+	if to == "" {
+		return nil, status.Error(codes.InvalidArgument, "status is required")
+	}
+	if err := s.repo.PurchaseOrders().SetPurchaseOrderStatus(ctx, int(req.GetId()), to); err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "set purchase order status", err)
+	}
+	po, err := s.purchaseOrderToPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.SetPurchaseOrderStatusResponse{Order: po}, nil
+}
+
+// ReceivePurchaseOrder books a delivery against an order. The receipt is priced from the order, so a
+// quantity-only delivery needs no costing:write; recording input VAT still does, as on a free receipt.
+func (s *Server) ReceivePurchaseOrder(ctx context.Context, req *pb_admin.ReceivePurchaseOrderRequest) (*pb_admin.ReceivePurchaseOrderResponse, error) {
+	r, err := dto.ConvertPbReceivePurchaseOrder(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if r.InputVatAmount.Valid {
+		if _, write := s.costingAccess(ctx); !write {
+			return nil, status.Error(codes.PermissionDenied, "costing:write is required to record input VAT")
+		}
+	}
+	r.AdminUsername = authsrv.GetAdminUsername(ctx)
+	movements, err := s.repo.PurchaseOrders().ReceivePurchaseOrder(ctx, r)
+	if err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "receive purchase order", err)
+	}
+	po, err := s.purchaseOrderToPb(ctx, r.PurchaseOrderId)
+	if err != nil {
+		return nil, err
+	}
+	out := &pb_admin.ReceivePurchaseOrderResponse{Order: po}
+	for _, m := range movements {
+		out.Movements = append(out.Movements, s.movementToPb(ctx, m))
+	}
+	return out, nil
+}
+
+// SuggestPurchaseOrders drafts the orders that would cover a run's material shortfall. The shortfall
+// is the material plan's — the same rows the run screen shows — less what open orders already bring.
+func (s *Server) SuggestPurchaseOrders(ctx context.Context, req *pb_admin.SuggestPurchaseOrdersRequest) (*pb_admin.SuggestPurchaseOrdersResponse, error) {
+	runID := int(req.GetProductionRunId())
+	if runID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "production_run_id is required")
+	}
+	read, write := s.costingAccess(ctx)
+	plan, err := s.buildRunMaterialPlan(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	materials, err := s.repo.TechCards().ListMaterials(ctx, "", true)
+	if err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "list materials", err)
+	}
+	byID := make(map[int]entity.MaterialWithPrice, len(materials))
+	for _, m := range materials {
+		byID[m.Id] = m
+	}
+
+	var needs []entity.PurchaseNeed
+	var ids []int
+	for _, row := range plan.GetRows() {
+		shortage, err := decimal.NewFromString(row.GetShortage().GetValue())
+		if err != nil || !shortage.IsPositive() {
+			continue
+		}
+		n := entity.PurchaseNeed{
+			MaterialId:   int(row.GetMaterialId()),
+			MaterialName: row.GetMaterialName(),
+			Unit:         row.GetUnit(),
+			Shortage:     shortage,
+		}
+		if m, ok := byID[n.MaterialId]; ok {
+			if m.SupplierId.Valid {
+				n.SupplierId = int(m.SupplierId.Int64)
+			}
+			n.LeadTimeDays = m.LeadTimeDays
+			if m.LatestPrice != nil {
+				n.LastPrice = decimal.NullDecimal{Decimal: m.LatestPrice.Price, Valid: true}
+				n.LastCurrency = m.LatestPrice.Currency
+			}
+		}
+		needs = append(needs, n)
+		ids = append(ids, n.MaterialId)
+	}
+	onOrder, err := s.repo.PurchaseOrders().OpenQuantities(ctx, ids)
+	if err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "get open purchase quantities", err)
+	}
+	for i := range needs {
+		needs[i].OnOrder = onOrder[needs[i].MaterialId]
+	}
+	suppliers, err := s.repo.Accounting().ListSuppliers(ctx)
+	if err != nil {
+		return nil, mapAcctErr(ctx, "list suppliers", err)
+	}
+	leadDays := make(map[int]sql.NullInt64, len(suppliers))
+	names := make(map[int]string, len(suppliers))
+	for _, sp := range suppliers {
+		leadDays[sp.Id] = sp.LeadTimeDays
+		names[sp.Id] = sp.Name
+	}
+
+	today := s.repo.Now()
+	sugg := entity.SuggestPurchaseOrders(needs, leadDays, cache.GetBaseCurrency(), today)
+	createdBy := authsrv.GetAdminUsername(ctx)
+	out := &pb_admin.SuggestPurchaseOrdersResponse{
+		Covered:    dto.ConvertPurchaseNeedsToPb(sugg.Covered),
+		Unassigned: dto.ConvertPurchaseNeedsToPb(sugg.Unassigned),
+	}
+	for _, o := range sugg.Orders {
+		ins := o.Insert(runID, createdBy)
+		if !req.GetCreate() {
+			out.Orders = append(out.Orders, s.suggestedOrderToPb(o, ins, names[o.SupplierId], read, today))
+			continue
+		}
+		// A suggestion carries the catalog's last price; saving it is writing a price, exactly as a
+		// hand-made draft is. Without costing:write the drafts are saved unpriced.
+		if !write {
+			for i := range ins.Lines {
+				ins.Lines[i].UnitPrice = decimal.NullDecimal{}
+			}
+		}
+		id, err := s.repo.PurchaseOrders().CreatePurchaseOrder(ctx, ins)
+		if err != nil {
+			return nil, mapPurchaseOrderErr(ctx, "create suggested purchase order", err)
+		}
+		po, err := s.purchaseOrderToPb(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out.Orders = append(out.Orders, po)
+	}
+	return out, nil
+}
+
+// suggestedOrderToPb shows an unsaved suggestion in the shape of the draft it would become.
+func (s *Server) suggestedOrderToPb(o entity.PurchaseSuggestion, ins entity.PurchaseOrderInsert, supplierName string, read bool, today time.Time) *pb_common.PurchaseOrder {
+	full := entity.PurchaseOrderFull{PurchaseOrder: entity.PurchaseOrder{
+		SupplierId:      o.SupplierId,
+		SupplierName:    supplierName,
+		Status:          entity.PurchaseOrderDraft,
+		Currency:        o.Currency,
+		ExpectedAt:      ins.ExpectedAt(),
+		ProductionRunId: ins.ProductionRunId,
+		CreatedBy:       ins.CreatedBy,
+		CreatedAt:       today,
+		UpdatedAt:       today,
+	}}
+	for i, l := range o.Lines {
+		full.Lines = append(full.Lines, entity.PurchaseOrderLine{
+			MaterialId:   l.Need.MaterialId,
+			MaterialName: l.Need.MaterialName,
+			Unit:         sql.NullString{String: l.Need.Unit, Valid: l.Need.Unit != ""},
+			OrderedQty:   ins.Lines[i].OrderedQty,
+			UnitPrice:    ins.Lines[i].UnitPrice,
+			ExpectedAt:   ins.Lines[i].ExpectedAt,
+		})
+	}
+	pb := dto.ConvertPurchaseOrderToPb(full, today)
+	pb.Number = ""
+	if !read {
+		dto.StripPurchaseOrderCosting(pb)
+	}
+	return pb
+}
+
+// requirePurchasePriceWrite refuses a draft that carries prices from an account without
+// costing:write.
+func (s *Server) requirePurchasePriceWrite(ctx context.Context, ins entity.PurchaseOrderInsert) error {
+	if !dto.HasPurchaseOrderPrices(ins) {
+		return nil
+	}
+	if _, write := s.costingAccess(ctx); !write {
+		return status.Error(codes.PermissionDenied, "costing:write is required to set purchase prices")
+	}
+	return nil
+}
+
+// purchaseOrderToPb reads an order back for a response, stripped per the caller's costing access.
+func (s *Server) purchaseOrderToPb(ctx context.Context, id int) (*pb_common.PurchaseOrder, error) {
+	po, err := s.repo.PurchaseOrders().GetPurchaseOrder(ctx, id)
+	if err != nil {
+		return nil, mapPurchaseOrderErr(ctx, "get purchase order", err)
+	}
+	pb := dto.ConvertPurchaseOrderToPb(*po, s.repo.Now())
+	if read, _ := s.costingAccess(ctx); !read {
+		dto.StripPurchaseOrderCosting(pb)
+	}
+	return pb, nil
+}
+
+// mapPurchaseOrderErr maps purchase order errors to gRPC codes; receipt errors from the shared
+// warehouse core go through mapInventoryErr.
+func mapPurchaseOrderErr(ctx context.Context, what string, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrPurchaseOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entity.ErrPurchaseOrderState),
+		errors.Is(err, entity.ErrPurchaseOrderOverReceipt):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return mapInventoryErr(ctx, what, err)
+}
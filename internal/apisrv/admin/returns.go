@@ -0,0 +1,235 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/payment/stripe"
+	"github.com/jekabolt/grbpwr-manager/internal/tiermanagement"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// returnRequestError maps a returns-store error to a gRPC status, logging the unexpected ones.
+func returnRequestError(ctx context.Context, op string, id int32, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "return request not found")
+	case errors.Is(err, entity.ErrReturnRequestTransition), errors.Is(err, entity.ErrReturnExchangeUnavailable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &ve):
+		return status.Error(codes.InvalidArgument, ve.Message)
+	}
+	slog.Default().ErrorContext(ctx, "can't "+op+" return request",
+		slog.String("err", err.Error()),
+		slog.Int("id", int(id)),
+	)
+	return status.Errorf(codes.Internal, "can't %s return request", op)
+}
+
+func (s *Server) GetReturnRequestById(ctx context.Context, req *pb_admin.GetReturnRequestByIdRequest) (*pb_admin.GetReturnRequestByIdResponse, error) {
+	rr, err := s.repo.Returns().GetReturnRequestById(ctx, int(req.Id))
+	if err != nil {
+		return nil, returnRequestError(ctx, "get", req.Id, err)
+	}
+	return &pb_admin.GetReturnRequestByIdResponse{ReturnRequest: dto.ConvertEntityReturnRequestFullToAdminPb(rr)}, nil
+}
+
+func (s *Server) GetReturnRequestsPaged(ctx context.Context, req *pb_admin.GetReturnRequestsPagedRequest) (*pb_admin.GetReturnRequestsPagedResponse, error) {
+	filters := entity.ReturnRequestFilters{
+		OrderUUID: req.GetOrderUuid(),
+		Email:     req.GetEmail(),
+	}
+	if req.Status != nil {
+		if st, ok := dto.ConvertPbReturnStatusToEntity(req.GetStatus()); ok {
+			filters.Status = &st
+		}
+	}
+	if req.Resolution != nil {
+		if r, ok := dto.ConvertPbReturnResolutionToEntity(req.GetResolution()); ok {
+			filters.Resolution = &r
+		}
+	}
+
+	limit, offset := clampPagination(int(req.Limit), int(req.Offset))
+	rrs, total, err := s.repo.Returns().GetReturnRequestsPaged(ctx, limit, offset, dto.ConvertPBCommonOrderFactorToEntity(req.OrderFactor), filters)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get return requests paged",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get return requests paged")
+	}
+
+	out := make([]*pb_admin.ReturnRequestFull, 0, len(rrs))
+	for i := range rrs {
+		out = append(out, dto.ConvertEntityReturnRequestFullToAdminPb(&entity.ReturnRequestFull{ReturnRequest: rrs[i]}))
+	}
+	return &pb_admin.GetReturnRequestsPagedResponse{ReturnRequests: out, Total: int32(total)}, nil
+}
+
+// ApproveReturnRequest accepts a requested return. An exchange's replacement already left stock when
+// the customer requested it.
+func (s *Server) ApproveReturnRequest(ctx context.Context, req *pb_admin.ApproveReturnRequestRequest) (*pb_admin.ApproveReturnRequestResponse, error) {
+	rr, err := s.repo.Returns().ApproveReturnRequest(ctx, int(req.Id), authsrv.GetAdminUsername(ctx), strings.TrimSpace(req.Note))
+	if err != nil {
+		return nil, returnRequestError(ctx, "approve", req.Id, err)
+	}
+	return &pb_admin.ApproveReturnRequestResponse{ReturnRequest: dto.ConvertEntityReturnRequestFullToAdminPb(rr)}, nil
+}
+
+func (s *Server) RejectReturnRequest(ctx context.Context, req *pb_admin.RejectReturnRequestRequest) (*pb_admin.RejectReturnRequestResponse, error) {
+	rr, err := s.repo.Returns().RejectReturnRequest(ctx, int(req.Id), authsrv.GetAdminUsername(ctx), strings.TrimSpace(req.Note))
+	if err != nil {
+		return nil, returnRequestError(ctx, "reject", req.Id, err)
+	}
+	return &pb_admin.RejectReturnRequestResponse{ReturnRequest: dto.ConvertEntityReturnRequestFullToAdminPb(rr)}, nil
+}
+
+func (s *Server) ReceiveReturnRequest(ctx context.Context, req *pb_admin.ReceiveReturnRequestRequest) (*pb_admin.ReceiveReturnRequestResponse, error) {
+	rr, err := s.repo.Returns().ReceiveReturnRequest(ctx, int(req.Id), strings.TrimSpace(req.Note))
+	if err != nil {
+		return nil, returnRequestError(ctx, "receive", req.Id, err)
+	}
+	return &pb_admin.ReceiveReturnRequestResponse{ReturnRequest: dto.ConvertEntityReturnRequestFullToAdminPb(rr)}, nil
+}
+
+// InspectReturnRequest closes a received return. A failed inspection rejects it; an exchange
+// restocks the returned units per disposition; a refund is handed to the payment provider and the
+// order refund path (RefundOrder: restock per disposition, refunded_order_item, accounting event).
+func (s *Server) InspectReturnRequest(ctx context.Context, req *pb_admin.InspectReturnRequestRequest) (*pb_admin.InspectReturnRequestResponse, error) {
+	note := strings.TrimSpace(req.Note)
+	disposition := strings.TrimSpace(req.Disposition)
+	if !entity.ValidRefundDispositions[disposition] {
+		return nil, status.Error(codes.InvalidArgument, "disposition must be empty, restock, writeoff or seconds")
+	}
+
+	if !req.Accept {
+		rr, err := s.repo.Returns().RejectReturnRequest(ctx, int(req.Id), authsrv.GetAdminUsername(ctx), note)
+		if err != nil {
+			return nil, returnRequestError(ctx, "reject", req.Id, err)
+		}
+		return &pb_admin.InspectReturnRequestResponse{ReturnRequest: dto.ConvertEntityReturnRequestFullToAdminPb(rr)}, nil
+	}
+
+	current, err := s.repo.Returns().GetReturnRequestById(ctx, int(req.Id))
+	if err != nil {
+		return nil, returnRequestError(ctx, "get", req.Id, err)
+	}
+
+	var rr *entity.ReturnRequestFull
+	if current.Resolution == entity.ReturnResolutionExchange {
+		rr, err = s.repo.Returns().CompleteReturnExchange(ctx, current.Id, disposition, note)
+		if err != nil {
+			return nil, returnRequestError(ctx, "exchange", req.Id, err)
+		}
+	} else {
+		rr, err = s.refundReturn(ctx, current.Id, disposition, note, req.RefundShipping)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if disposition == "" || disposition == entity.RefundDispositionRestock {
+		seen := map[int]bool{}
+		products := make([]int, 0, len(rr.Items))
+		for _, it := range rr.Items {
+			if !seen[it.ProductId] {
+				seen[it.ProductId] = true
+				products = append(products, it.ProductId)
+			}
+		}
+		s.revalidateAsync(&dto.RevalidationData{Products: products, Hero: true})
+	}
+	return &pb_admin.InspectReturnRequestResponse{ReturnRequest: dto.ConvertEntityReturnRequestFullToAdminPb(rr)}, nil
+}
+
+// refundReturn runs the refund hand-off of a return: claim (received → refunding), refund the
+// payment for exactly the returned units, RefundOrder, complete. The claim makes a concurrent second
+// inspect fail, and a retry after a failure re-enters from refunding with the same Stripe
+// idempotency key, so the money moves at most once.
+func (s *Server) refundReturn(ctx context.Context, id int, disposition, note string, refundShipping bool) (*entity.ReturnRequestFull, error) {
+	rr, err := s.repo.Returns().ClaimReturnRefund(ctx, id, disposition, note)
+	if err != nil {
+		return nil, returnRequestError(ctx, "refund", int32(id), err)
+	}
+
+	orderFull, err := s.repo.Order().GetOrderFullByUUID(ctx, rr.OrderUUID)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get order for return refund",
+			slog.String("err", err.Error()),
+			slog.String("rma_number", rr.RMANumber),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get order")
+	}
+
+	// RefundOrder takes one id per unit.
+	unitIDs := make([]int32, 0, len(rr.Items))
+	for _, it := range rr.Items {
+		for range it.Quantity {
+			unitIDs = append(unitIDs, int32(it.OrderItemId))
+		}
+	}
+
+	if err := s.checkRefundGiftCards(ctx, orderFull, unitIDs); err != nil {
+		return nil, err
+	}
+
+	pm, ok := cache.GetPaymentMethodById(orderFull.Payment.PaymentMethodID)
+	if ok && (pm.Method.Name == entity.CARD || pm.Method.Name == entity.CARD_TEST) {
+		handler, err := s.getPaymentHandler(ctx, pm.Method.Name)
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "can't get payment handler for return refund",
+				slog.String("err", err.Error()),
+			)
+			return nil, status.Errorf(codes.Internal, "can't get payment handler")
+		}
+		amount := calculateRefundAmount(orderFull.OrderItems, unitIDs, orderFull.Order.Currency)
+		if refundShipping && !orderFull.Shipment.FreeShipping && !orderFull.Order.ShippingRefunded {
+			amount = amount.Add(orderFull.Shipment.CostDecimal(orderFull.Order.Currency))
+		}
+		// The store-credit share of a credit-paid order goes back to its wallet in RefundOrder.
+		amount, _ = entity.SplitOrderRefund(&orderFull.Order, amount)
+		if amount.IsPositive() {
+			idemKey := stripe.RefundIdempotencyKey(rr.OrderUUID, unitIDs, refundShipping, &amount, orderFull.Order.Currency)
+			if err := handler.Refund(ctx, orderFull.Payment, rr.OrderUUID, &amount, orderFull.Order.Currency, idemKey); err != nil {
+				slog.Default().ErrorContext(ctx, "stripe refund failed",
+					slog.String("err", err.Error()),
+					slog.String("rma_number", rr.RMANumber),
+				)
+				return nil, status.Errorf(codes.Internal, "stripe refund failed: %v", err)
+			}
+		}
+	}
+
+	if err := s.repo.Order().RefundOrder(ctx, rr.OrderUUID, unitIDs, "return "+rr.RMANumber, rr.ReasonCode, refundShipping, disposition, false); err != nil {
+		slog.Default().ErrorContext(ctx, "can't refund order for return",
+			slog.String("err", err.Error()),
+			slog.String("rma_number", rr.RMANumber),
+		)
+		return nil, status.Errorf(codes.Internal, "can't refund order")
+	}
+
+	rr, err = s.repo.Returns().CompleteReturnRefund(ctx, id)
+	if err != nil {
+		return nil, returnRequestError(ctx, "complete", int32(id), err)
+	}
+
+	if orderFull.Buyer.Email != "" {
+		if err := tiermanagement.NewEngine(s.repo, s.mailer).EvaluateAfterRefund(ctx, orderFull.Buyer.Email); err != nil {
+			slog.Default().ErrorContext(ctx, "can't evaluate tier after refund",
+				slog.String("orderUuid", rr.OrderUUID),
+				slog.String("err", err.Error()),
+			)
+		}
+	}
+	return rr, nil
+}
@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/ksef"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IssueSalesInvoice issues the invoice of a paid order.
+func (s *Server) IssueSalesInvoice(ctx context.Context, req *pb_admin.IssueSalesInvoiceRequest) (*pb_admin.IssueSalesInvoiceResponse, error) {
+	if req.GetOrderUuid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uuid is required")
+	}
+	issueDate, err := dto.ParseSalesInvoiceIssueDate(req.GetIssueDate(), s.repo.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	id, err := s.repo.SalesInvoices().IssueSalesInvoice(ctx, req.GetOrderUuid(), issueDate, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, mapSalesInvoiceErr(ctx, "issue sales invoice", err)
+	}
+	inv, err := s.salesInvoiceToPb(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.IssueSalesInvoiceResponse{Invoice: inv}, nil
+}
+
+// CorrectSalesInvoice issues a correction for the refunds the invoice's corrections do not cover yet.
+func (s *Server) CorrectSalesInvoice(ctx context.Context, req *pb_admin.CorrectSalesInvoiceRequest) (*pb_admin.CorrectSalesInvoiceResponse, error) {
+	if req.GetInvoiceId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invoice_id is required")
+	}
+	reason, err := dto.ConvertPbCorrectionReason(req.GetReason())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	issueDate, err := dto.ParseSalesInvoiceIssueDate(req.GetIssueDate(), s.repo.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	id, err := s.repo.SalesInvoices().IssueCorrectionInvoice(ctx, int(req.GetInvoiceId()), reason, issueDate, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, mapSalesInvoiceErr(ctx, "correct sales invoice", err)
+	}
+	inv, err := s.salesInvoiceToPb(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.CorrectSalesInvoiceResponse{Invoice: inv}, nil
+}
+
+// GetSalesInvoice returns an invoice with its lines.
+func (s *Server) GetSalesInvoice(ctx context.Context, req *pb_admin.GetSalesInvoiceRequest) (*pb_admin.GetSalesInvoiceResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	inv, err := s.salesInvoiceToPb(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.GetSalesInvoiceResponse{Invoice: inv}, nil
+}
+
+// ListSalesInvoices lists invoice headers, newest number first.
+func (s *Server) ListSalesInvoices(ctx context.Context, req *pb_admin.ListSalesInvoicesRequest) (*pb_admin.ListSalesInvoicesResponse, error) {
+	f, err := dto.ConvertPbSalesInvoiceFilter(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	limit, offset := clampPagination(int(req.GetLimit()), int(req.GetOffset()))
+	list, total, err := s.repo.SalesInvoices().ListSalesInvoices(ctx, limit, offset, f)
+	if err != nil {
+		return nil, mapSalesInvoiceErr(ctx, "list sales invoices", err)
+	}
+	return &pb_admin.ListSalesInvoicesResponse{
+		Invoices: dto.ConvertSalesInvoiceListToPb(list),
+		Total:    int32(total),
+	}, nil
+}
+
+// ExportSalesInvoiceKsef renders an invoice as KSeF XML. Like the JPK export it needs the taxpayer
+// identity configured, and also the seller address KSeF requires.
+func (s *Server) ExportSalesInvoiceKsef(ctx context.Context, req *pb_admin.ExportSalesInvoiceKsefRequest) (*pb_admin.ExportSalesInvoiceKsefResponse, error) {
+	inv, doc, err := s.salesInvoiceKsef(ctx, int(req.GetId()), dto.KsefSchemaFromPb(req.GetSchema()))
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.ExportSalesInvoiceKsefResponse{
+		Filename:   dto.SalesInvoiceFilename(inv.Number),
+		XmlContent: string(doc),
+	}, nil
+}
+
+// SubmitSalesInvoiceKsef sends an invoice to KSeF and records its reference. A correction can only
+// quote the KSeF number of an invoice submitted before it, so an invoice is submitted ahead of its
+// corrections or the correction quotes it by number alone (NrKSeFN).
+func (s *Server) SubmitSalesInvoiceKsef(ctx context.Context, req *pb_admin.SubmitSalesInvoiceKsefRequest) (*pb_admin.SubmitSalesInvoiceKsefResponse, error) {
+	inv, doc, err := s.salesInvoiceKsef(ctx, int(req.GetId()), dto.KsefSchemaFromPb(req.GetSchema()))
+	if err != nil {
+		return nil, err
+	}
+	if inv.KsefReference.Valid {
+		return nil, status.Errorf(codes.FailedPrecondition, "invoice %s is already in KSeF as %s", inv.Number, inv.KsefReference.String)
+	}
+	sub, err := s.ksefSubmitter.Submit(ctx, s.jpkTaxpayer.NIP, doc, inv.IssueDate)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't submit sales invoice to ksef",
+			slog.Int("invoiceId", inv.Id),
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Errorf(codes.Unavailable, "can't submit invoice to KSeF: %v", err)
+	}
+	if err := s.repo.SalesInvoices().SetSalesInvoiceKsef(ctx, inv.Id, sub.Reference, sub.Hash, sub.SubmittedAt); err != nil {
+		return nil, mapSalesInvoiceErr(ctx, "record ksef reference", err)
+	}
+	pb, err := s.salesInvoiceToPb(ctx, inv.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_admin.SubmitSalesInvoiceKsefResponse{Invoice: pb, Offline: sub.Offline}, nil
+}
+
+// salesInvoiceKsef loads an invoice and generates its validated KSeF document.
+func (s *Server) salesInvoiceKsef(ctx context.Context, id int, schema ksef.Schema) (*entity.SalesInvoiceFull, []byte, error) {
+	if id <= 0 {
+		return nil, nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if !s.jpkTaxpayer.Configured() || s.jpkTaxpayer.AddressL1 == "" {
+		return nil, nil, status.Error(codes.FailedPrecondition, "KSeF export is not configured: set the JPK_NIP / JPK_FULL_NAME / JPK_EMAIL / JPK_TAX_OFFICE taxpayer identity and JPK_ADDRESS_L1")
+	}
+	inv, err := s.repo.SalesInvoices().GetSalesInvoice(ctx, id)
+	if err != nil {
+		return nil, nil, mapSalesInvoiceErr(ctx, "get sales invoice", err)
+	}
+	doc, err := ksef.Generate(s.jpkTaxpayer, inv, schema, s.repo.Now())
+	if err != nil {
+		return nil, nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return inv, doc, nil
+}
+
+func (s *Server) salesInvoiceToPb(ctx context.Context, id int) (*pb_admin.SalesInvoice, error) {
+	inv, err := s.repo.SalesInvoices().GetSalesInvoice(ctx, id)
+	if err != nil {
+		return nil, mapSalesInvoiceErr(ctx, "get sales invoice", err)
+	}
+	return dto.ConvertSalesInvoiceFullToPb(inv), nil
+}
+
+// correctSalesInvoiceAfterRefund issues the correction a refund calls for when the order was
+// invoiced. Best effort: the money is already back with the buyer, so a failure is logged for the
+// accountant to issue the correction by hand (CorrectSalesInvoice) rather than failing the refund.
+func (s *Server) correctSalesInvoiceAfterRefund(ctx context.Context, orderUUID, reason string) {
+	list, _, err := s.repo.SalesInvoices().ListSalesInvoices(ctx, 1, 0, entity.SalesInvoiceFilter{
+		OrderUUID: orderUUID,
+		Kind:      entity.SalesInvoiceKindInvoice,
+	})
+	if err != nil || len(list) == 0 {
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "can't look up sales invoice after refund",
+				slog.String("orderUuid", orderUUID),
+				slog.String("err", err.Error()),
+			)
+		}
+		return
+	}
+	reason, err = dto.ConvertPbCorrectionReason(reason)
+	if err != nil {
+		reason = "Zwrot"
+	}
+	today, _ := dto.ParseSalesInvoiceIssueDate("", s.repo.Now())
+	_, err = s.repo.SalesInvoices().IssueCorrectionInvoice(ctx, list[0].Id, reason, today, authsrv.GetAdminUsername(ctx))
+	if err != nil && !errors.Is(err, entity.ErrSalesInvoiceNothingToCorrect) {
+		slog.Default().ErrorContext(ctx, "can't issue correction invoice after refund",
+			slog.String("orderUuid", orderUUID),
+			slog.String("invoice", list[0].Number),
+			slog.String("err", err.Error()),
+		)
+	}
+}
+
+func mapSalesInvoiceErr(ctx context.Context, what string, err error) error {
+	switch {
+	case errors.Is(err, entity.ErrSalesInvoiceNotFound), errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entity.ErrSalesInvoiceExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, entity.ErrSalesInvoiceState),
+		errors.Is(err, entity.ErrSalesInvoiceNothingToCorrect),
+		errors.Is(err, entity.ErrSalesInvoiceFxMissing):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	slog.Default().ErrorContext(ctx, "can't "+what, slog.String("err", err.Error()))
+	return status.Errorf(codes.Internal, "can't %s", what)
+}
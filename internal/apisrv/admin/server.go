@@ -9,16 +9,20 @@ import (
 
 	"github.com/jekabolt/grbpwr-manager/internal/analytics/ga4mp"
 	"github.com/jekabolt/grbpwr-manager/internal/auth/pwhash"
+	"github.com/jekabolt/grbpwr-manager/internal/bundleticket"
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/dto"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
 	"github.com/jekabolt/grbpwr-manager/internal/fileaccess"
 	"github.com/jekabolt/grbpwr-manager/internal/jpk"
+	"github.com/jekabolt/grbpwr-manager/internal/ksef"
 	"github.com/jekabolt/grbpwr-manager/internal/mail/campaignrender"
 	"github.com/jekabolt/grbpwr-manager/internal/openrouter"
 	"github.com/jekabolt/grbpwr-manager/internal/patternaccess"
 	"github.com/jekabolt/grbpwr-manager/internal/runpackaccess"
 	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+	"github.com/jekabolt/grbpwr-manager/internal/specsheet"
+	"github.com/jekabolt/grbpwr-manager/internal/subcontractportal"
 	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -53,6 +57,15 @@ type Server struct {
 	// runPackTokens mints the output-only run_pack_token on a run read (/api/rp). Nil-safe
 	// for the same reason patternURLs is.
 	runPackTokens *runpackaccess.Service
+	// bundleTickets mints the bundle_token of every bundle on a bundle read (/api/bt). Nil-safe
+	// like runPackTokens: без сервиса пачки приезжают без токена, и ярлык печатается без QR.
+	bundleTickets *bundleticket.Service
+	// subcontractPortal mints the factory's portal_token on a subcontract read (/api/cmt). Nil-safe
+	// like the two above: без сервиса подряд приезжает без токена.
+	subcontractPortal *subcontractportal.Service
+	// specSheets renders release spec sheets and mints their /api/ts link. Nil-safe for the mint
+	// (ссылка приезжает пустой); the export answers Unavailable without it.
+	specSheets *specsheet.Service
 	// fileLinks mints the public /api/f/{token} url shown in a file's access block (Ф7).
 	// Nil-safe like the two above: без сервиса блок доступа приезжает без url, а не падает.
 	fileLinks       *fileaccess.Service
@@ -104,6 +117,9 @@ type Server struct {
 	// jpkTaxpayer is the Polish taxpayer identity (from JPK_* config) stamped into JPK_V7M exports.
 	// Zero (unconfigured) → ExportJpkV7M returns FailedPrecondition instead of an invalid filing.
 	jpkTaxpayer jpk.Taxpayer
+	// ksefSubmitter sends sales invoices to KSeF. The offline stub until the KSeF API session is
+	// wired up.
+	ksefSubmitter ksef.Submitter
 }
 
 // New creates a new server with admin handlers.
@@ -155,6 +171,7 @@ func New(
 		aiOps:                aiOps,
 		noteFormatSem:        make(chan struct{}, maxConcurrentNoteFormats),
 		jpkTaxpayer:          jpkTaxpayer,
+		ksefSubmitter:        ksef.NewOfflineSubmitter(),
 	}, nil
 }
 
@@ -263,6 +280,24 @@ func (s *Server) SetRunPackTokenService(svc *runpackaccess.Service) {
 	s.runPackTokens = svc
 }
 
+// SetBundleTicketService wires the bundle ticket token minter (/api/bt). Bare token, same as
+// the run pack: the admin builds the printed url from its own origin.
+func (s *Server) SetBundleTicketService(svc *bundleticket.Service) {
+	s.bundleTickets = svc
+}
+
+// SetSubcontractPortalService wires the factory portal token minter (/api/cmt). Bare token again:
+// the admin builds the url it sends the factory from its own origin.
+func (s *Server) SetSubcontractPortalService(svc *subcontractportal.Service) {
+	s.subcontractPortal = svc
+}
+
+// SetSpecSheetService wires the release spec sheet renderer and its link minter (/api/ts). Bare token,
+// like the portal: the admin builds the url from its own origin.
+func (s *Server) SetSpecSheetService(svc *specsheet.Service) {
+	s.specSheets = svc
+}
+
 // SetFileLinkService wires the public library-file link minter (/api/f, Ф7). Base url lives
 // INSIDE the service (unlike the run pack above): эту ссылку копируют в мессенджер и открывают
 // вне панели, поэтому она обязана быть абсолютной и собранной одним местом — тем же, что её
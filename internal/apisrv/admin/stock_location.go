@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// СКЛАДСКИЕ ЛОКАЦИИ (0345) — locations, their stock, transfer documents, and the allocation of a
+// paid order to the location it is packed from. RBAC gates them on the inventory section (the
+// allocation on fulfillment); the store owns every stock rule.
+
+// ListStockLocations returns the locations, the default one first.
+func (s *Server) ListStockLocations(ctx context.Context, req *pb_admin.ListStockLocationsRequest) (*pb_admin.ListStockLocationsResponse, error) {
+	list, err := s.repo.StockLocations().ListStockLocations(ctx, req.GetIncludeArchived())
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "list stock locations", err)
+	}
+	return &pb_admin.ListStockLocationsResponse{Locations: dto.StockLocationListToPb(list)}, nil
+}
+
+// CreateStockLocation stores a new location.
+func (s *Server) CreateStockLocation(ctx context.Context, req *pb_admin.CreateStockLocationRequest) (*pb_admin.CreateStockLocationResponse, error) {
+	ins, err := dto.ConvertPbStockLocationInsertToEntity(req.GetLocation(), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	id, err := s.repo.StockLocations().CreateStockLocation(ctx, ins)
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "create the stock location", err)
+	}
+	return &pb_admin.CreateStockLocationResponse{Id: int32(id)}, nil
+}
+
+// UpdateStockLocation replaces a location's code, name, kind, address and note.
+func (s *Server) UpdateStockLocation(ctx context.Context, req *pb_admin.UpdateStockLocationRequest) (*pb_admin.UpdateStockLocationResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	ins, err := dto.ConvertPbStockLocationInsertToEntity(req.GetLocation(), "")
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.repo.StockLocations().UpdateStockLocation(ctx, int(req.GetId()), ins); err != nil {
+		return nil, s.stockLocationError(ctx, "update the stock location", err)
+	}
+	return &pb_admin.UpdateStockLocationResponse{}, nil
+}
+
+// SetStockLocationArchived archives or restores a location.
+func (s *Server) SetStockLocationArchived(ctx context.Context, req *pb_admin.SetStockLocationArchivedRequest) (*pb_admin.SetStockLocationArchivedResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.StockLocations().SetStockLocationArchived(ctx, int(req.GetId()), req.GetArchived()); err != nil {
+		return nil, s.stockLocationError(ctx, "archive the stock location", err)
+	}
+	return &pb_admin.SetStockLocationArchivedResponse{}, nil
+}
+
+// ListLocationStock returns everything a location holds.
+func (s *Server) ListLocationStock(ctx context.Context, req *pb_admin.ListLocationStockRequest) (*pb_admin.ListLocationStockResponse, error) {
+	if req.GetLocationId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "location_id is required")
+	}
+	ls, err := s.repo.StockLocations().ListLocationStock(ctx, int(req.GetLocationId()))
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "list the location's stock", err)
+	}
+	return dto.LocationStockToPb(ls), nil
+}
+
+// CreateStockTransfer stores a draft transfer.
+func (s *Server) CreateStockTransfer(ctx context.Context, req *pb_admin.CreateStockTransferRequest) (*pb_admin.CreateStockTransferResponse, error) {
+	ins, err := dto.ConvertPbCreateStockTransferToEntity(req, authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		var ve *entity.ValidationError
+		if errors.As(err, &ve) {
+			return nil, apierr.Invalid(ve)
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	id, err := s.repo.StockLocations().CreateStockTransfer(ctx, ins)
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "create the stock transfer", err)
+	}
+	return &pb_admin.CreateStockTransferResponse{Id: int32(id)}, nil
+}
+
+// ListStockTransfers returns transfer headers, newest first.
+func (s *Server) ListStockTransfers(ctx context.Context, req *pb_admin.ListStockTransfersRequest) (*pb_admin.ListStockTransfersResponse, error) {
+	st := entity.StockTransferStatus(strings.TrimSpace(req.GetStatus()))
+	switch st {
+	case "", entity.StockTransferDraft, entity.StockTransferInTransit, entity.StockTransferReceived, entity.StockTransferCancelled:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown transfer status %q", st)
+	}
+	list, total, err := s.repo.StockLocations().ListStockTransfers(ctx, entity.StockTransferFilter{
+		Status:     st,
+		LocationId: int(req.GetLocationId()),
+		Limit:      int(req.GetLimit()),
+		Offset:     int(req.GetOffset()),
+	})
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "list stock transfers", err)
+	}
+	return &pb_admin.ListStockTransfersResponse{Transfers: dto.StockTransferListToPb(list), Total: int32(total)}, nil
+}
+
+// GetStockTransfer returns a transfer with its lines.
+func (s *Server) GetStockTransfer(ctx context.Context, req *pb_admin.GetStockTransferRequest) (*pb_admin.GetStockTransferResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	tr, err := s.repo.StockLocations().GetStockTransfer(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "get the stock transfer", err)
+	}
+	return &pb_admin.GetStockTransferResponse{Transfer: dto.StockTransferFullToPb(tr)}, nil
+}
+
+// DispatchStockTransfer takes a draft's lines out of the source and puts it in transit.
+func (s *Server) DispatchStockTransfer(ctx context.Context, req *pb_admin.DispatchStockTransferRequest) (*pb_admin.DispatchStockTransferResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.StockLocations().DispatchStockTransfer(ctx, int(req.GetId()), authsrv.GetAdminUsername(ctx)); err != nil {
+		return nil, s.stockLocationError(ctx, "dispatch the stock transfer", err)
+	}
+	return &pb_admin.DispatchStockTransferResponse{}, nil
+}
+
+// ReceiveStockTransfer books a transfer in transit into its destination.
+func (s *Server) ReceiveStockTransfer(ctx context.Context, req *pb_admin.ReceiveStockTransferRequest) (*pb_admin.ReceiveStockTransferResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.StockLocations().ReceiveStockTransfer(ctx, int(req.GetId()), authsrv.GetAdminUsername(ctx)); err != nil {
+		return nil, s.stockLocationError(ctx, "receive the stock transfer", err)
+	}
+	return &pb_admin.ReceiveStockTransferResponse{}, nil
+}
+
+// CancelStockTransfer withdraws a draft or returns a transfer in transit to its source.
+func (s *Server) CancelStockTransfer(ctx context.Context, req *pb_admin.CancelStockTransferRequest) (*pb_admin.CancelStockTransferResponse, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.repo.StockLocations().CancelStockTransfer(ctx, int(req.GetId()), authsrv.GetAdminUsername(ctx)); err != nil {
+		return nil, s.stockLocationError(ctx, "cancel the stock transfer", err)
+	}
+	return &pb_admin.CancelStockTransferResponse{}, nil
+}
+
+// AllocateOrderFulfillment picks the stock location a paid order is packed from.
+func (s *Server) AllocateOrderFulfillment(ctx context.Context, req *pb_admin.AllocateOrderFulfillmentRequest) (*pb_admin.AllocateOrderFulfillmentResponse, error) {
+	if req.GetOrderUuid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uuid is required")
+	}
+	if req.GetLocationId() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "location_id must not be negative")
+	}
+	loc, err := s.repo.Fulfillment().AllocateOrderFulfillment(ctx, req.GetOrderUuid(), int(req.GetLocationId()), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, s.stockLocationError(ctx, "allocate the order", err)
+	}
+	return &pb_admin.AllocateOrderFulfillmentResponse{Location: dto.StockLocationToPb(*loc)}, nil
+}
+
+// stockLocationError maps the stock location store's typed refusals onto gRPC codes. ONE table for
+// the twelve RPCs.
+//
+//	entity.ValidationError                                   → InvalidArgument + BadRequest field violations
+//	ErrStockLocationNotFound / ErrStockTransferNotFound      → NotFound
+//	ErrStockLocationCodeTaken                                → AlreadyExists
+//	ErrStockLocationArchived / Default / NotEmpty            → FailedPrecondition
+//	ErrStockTransferState                                    → FailedPrecondition
+//	ErrInsufficientLocationStock / ErrNoLocationCanFulfill   → FailedPrecondition (transfer stock in first)
+//	ErrOrderNotAllocatable                                   → FailedPrecondition
+//	FK violation                                             → InvalidArgument (an unknown variant or material)
+func (s *Server) stockLocationError(ctx context.Context, op string, err error) error {
+	var ve *entity.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return apierr.Invalid(ve)
+	case errors.Is(err, entity.ErrStockLocationNotFound),
+		errors.Is(err, entity.ErrStockTransferNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entity.ErrStockLocationCodeTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, entity.ErrStockLocationArchived),
+		errors.Is(err, entity.ErrStockLocationDefault),
+		errors.Is(err, entity.ErrStockLocationNotEmpty),
+		errors.Is(err, entity.ErrStockTransferState),
+		errors.Is(err, entity.ErrInsufficientLocationStock),
+		errors.Is(err, entity.ErrNoLocationCanFulfill),
+		errors.Is(err, entity.ErrOrderNotAllocatable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case s.repo.IsErrForeignKeyViolation(err):
+		return status.Error(codes.InvalidArgument, "the transfer references a missing variant, material or lot")
+	}
+	slog.Default().ErrorContext(ctx, "stock location call failed", slog.String("op", op), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op+"; try again")
+}
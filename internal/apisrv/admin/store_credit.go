@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/currency"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// storeCreditError maps a store-credit store error to a gRPC status, logging the unexpected ones.
+func storeCreditError(ctx context.Context, op string, id int32, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return status.Error(codes.NotFound, "store credit wallet not found")
+	}
+	if st, ok := apierr.Status(err); ok {
+		return st
+	}
+	slog.Default().ErrorContext(ctx, "can't "+op+" store credit wallet",
+		slog.String("err", err.Error()),
+		slog.Int("id", int(id)),
+	)
+	return status.Errorf(codes.Internal, "can't %s store credit wallet", op)
+}
+
+func (s *Server) GetStoreCreditWalletById(ctx context.Context, req *pb_admin.GetStoreCreditWalletByIdRequest) (*pb_admin.GetStoreCreditWalletByIdResponse, error) {
+	w, err := s.repo.StoreCredit().GetWalletById(ctx, int(req.Id))
+	if err != nil {
+		return nil, storeCreditError(ctx, "get", req.Id, err)
+	}
+	return &pb_admin.GetStoreCreditWalletByIdResponse{Wallet: dto.ConvertEntityStoreCreditWalletFullToAdminPb(w)}, nil
+}
+
+func (s *Server) GetStoreCreditWalletsPaged(ctx context.Context, req *pb_admin.GetStoreCreditWalletsPagedRequest) (*pb_admin.GetStoreCreditWalletsPagedResponse, error) {
+	filters := entity.StoreCreditWalletFilters{
+		Email: req.GetEmail(),
+		Code:  req.GetCode(),
+	}
+	if req.Kind != nil {
+		if k, ok := dto.ConvertPbStoreCreditKindToEntity(req.GetKind()); ok {
+			filters.Kind = &k
+		}
+	}
+	if req.Status != nil {
+		if st, ok := dto.ConvertPbStoreCreditStatusToEntity(req.GetStatus()); ok {
+			filters.Status = &st
+		}
+	}
+
+	limit, offset := clampPagination(int(req.Limit), int(req.Offset))
+	ws, total, err := s.repo.StoreCredit().GetWalletsPaged(ctx, limit, offset, filters)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get store credit wallets paged",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get store credit wallets paged")
+	}
+
+	out := make([]*pb_admin.StoreCreditWallet, 0, len(ws))
+	for i := range ws {
+		out = append(out, dto.ConvertEntityStoreCreditWalletToAdminPb(&ws[i]))
+	}
+	return &pb_admin.GetStoreCreditWalletsPagedResponse{Wallets: out, Total: int32(total)}, nil
+}
+
+// AdjustStoreCreditWallet books a signed manual correction with a mandatory note. A debit can take
+// the balance to zero but never below it.
+func (s *Server) AdjustStoreCreditWallet(ctx context.Context, req *pb_admin.AdjustStoreCreditWalletRequest) (*pb_admin.AdjustStoreCreditWalletResponse, error) {
+	amount, err := decimal.NewFromString(strings.TrimSpace(req.GetAmount().GetValue()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid amount")
+	}
+	w, err := s.repo.StoreCredit().AdjustWallet(ctx, int(req.Id), amount, req.GetNote(), authsrv.GetAdminUsername(ctx))
+	if err != nil {
+		return nil, storeCreditError(ctx, "adjust", req.Id, err)
+	}
+	return &pb_admin.AdjustStoreCreditWalletResponse{Wallet: dto.ConvertEntityStoreCreditWalletFullToAdminPb(w)}, nil
+}
+
+func (s *Server) SetStoreCreditWalletStatus(ctx context.Context, req *pb_admin.SetStoreCreditWalletStatusRequest) (*pb_admin.SetStoreCreditWalletStatusResponse, error) {
+	st, ok := dto.ConvertPbStoreCreditStatusToEntity(req.GetStatus())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "status is required")
+	}
+	if err := s.repo.StoreCredit().SetWalletStatus(ctx, int(req.Id), st); err != nil {
+		return nil, storeCreditError(ctx, "update", req.Id, err)
+	}
+	w, err := s.repo.StoreCredit().GetWalletById(ctx, int(req.Id))
+	if err != nil {
+		return nil, storeCreditError(ctx, "get", req.Id, err)
+	}
+	return &pb_admin.SetStoreCreditWalletStatusResponse{Wallet: dto.ConvertEntityStoreCreditWalletFullToAdminPb(w)}, nil
+}
+
+// CreditStoreCreditAccount grants goodwill credit to a customer account, opening the account's
+// wallet in that currency if it has none yet.
+func (s *Server) CreditStoreCreditAccount(ctx context.Context, req *pb_admin.CreditStoreCreditAccountRequest) (*pb_admin.CreditStoreCreditAccountResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.GetEmail()))
+	if email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	cur := strings.ToUpper(strings.TrimSpace(req.GetCurrency()))
+	if !currency.IsSupported(cur) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported currency %q", req.GetCurrency())
+	}
+	amount, err := decimal.NewFromString(strings.TrimSpace(req.GetAmount().GetValue()))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid amount")
+	}
+	if !amount.IsPositive() {
+		return nil, status.Error(codes.InvalidArgument, "amount must be positive")
+	}
+	if err := entity.ValidateStoreCreditAdjustment(amount, req.GetNote()); err != nil {
+		st, _ := apierr.Status(err)
+		return nil, st
+	}
+
+	var walletId int
+	err = s.repo.Tx(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		w, err := rep.StoreCredit().CreditAccount(ctx, email, cur, amount, 0, entity.StoreCreditEntryAdjustment, req.GetNote(), authsrv.GetAdminUsername(ctx))
+		if err != nil {
+			return err
+		}
+		walletId = w.Id
+		return nil
+	})
+	if err != nil {
+		return nil, storeCreditError(ctx, "credit", 0, err)
+	}
+	w, err := s.repo.StoreCredit().GetWalletById(ctx, walletId)
+	if err != nil {
+		return nil, storeCreditError(ctx, "get", int32(walletId), err)
+	}
+	return &pb_admin.CreditStoreCreditAccountResponse{Wallet: dto.ConvertEntityStoreCreditWalletFullToAdminPb(w)}, nil
+}
+
+// SetGiftCardProduct marks a colourway as a digital gift card: each paid unit issues a code worth
+// its price, and the line ships nothing.
+func (s *Server) SetGiftCardProduct(ctx context.Context, req *pb_admin.SetGiftCardProductRequest) (*pb_admin.SetGiftCardProductResponse, error) {
+	if req.GetColorwayId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "colorway_id is required")
+	}
+	if err := s.repo.StoreCredit().SetGiftCardProduct(ctx, int(req.GetColorwayId()), req.GetGiftCard()); err != nil {
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
+		slog.Default().ErrorContext(ctx, "can't set gift card product",
+			slog.Int("colorway_id", int(req.GetColorwayId())), slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't set gift card product")
+	}
+	return &pb_admin.SetGiftCardProductResponse{}, nil
+}
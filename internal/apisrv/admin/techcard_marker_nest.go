@@ -0,0 +1,345 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/nesting"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// РАСКЛАДКА СЕРВЕРОМ — a marker nested without a browser open.
+//
+// Every input is one the system already keeps, read the way its own readers read it. The pieces come
+// from the scope's DXF sheets, through the same measurement MeasureTechCardPatternScope runs. The
+// width is the narrowest measured lot less both selvedges, by NormWidthVsArticle. The flip policy
+// comes from the cloth's fabric_direction. What the nest produces is saved through SaveTechCardMarker,
+// so a server marker passes the same refusals a browser one does. The handler decides nothing the
+// save path would decide differently.
+
+// Width bases, as NestTechCardMarkerResponse.width_basis names them.
+const (
+	nestWidthBasisRequest = "request"
+	nestWidthBasisLot     = "measured_lot"
+	nestWidthBasisNominal = "nominal"
+)
+
+// NestTechCardMarker nests one cloth of a card for one size ratio, compares the result with the
+// cloth's norm and, with save=true, stores it as an "auto" marker (a draft when not every instance
+// was placed).
+func (s *Server) NestTechCardMarker(ctx context.Context, req *pb_admin.NestTechCardMarkerRequest) (*pb_admin.NestTechCardMarkerResponse, error) {
+	if req.GetTechCardId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "tech_card_id is required")
+	}
+	if req.GetColorwayId() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "colorway_id must not be negative")
+	}
+	if req.GetSave() && strings.TrimSpace(req.GetName()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required to save the marker")
+	}
+	in, err := dto.NestRequestFromPb(req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid nest request: %v", err)
+	}
+	techCardID := int(req.GetTechCardId())
+	card, err := s.repo.TechCards().GetTechCardById(ctx, techCardID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "tech card not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't load tech card for nesting", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't load tech card")
+	}
+
+	// THE CLOTH. An empty key is accepted only where it cannot be ambiguous — a card with one roll.
+	rollGoods := entity.RollGoodsLinesOfBom(card.BomItems)
+	lineKey := strings.TrimSpace(req.GetBomLineKey())
+	if lineKey == "" {
+		if len(rollGoods) != 1 {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"bom_line_key is required: the card has %d roll-goods lines", len(rollGoods))
+		}
+		lineKey = rollGoods[0].LineKey
+	}
+	var bom *entity.TechCardBomItem
+	for i := range card.BomItems {
+		if strings.EqualFold(card.BomItems[i].LineKey, lineKey) && entity.IsRollGoodsSection(card.BomItems[i].Section) {
+			bom = &card.BomItems[i]
+			break
+		}
+	}
+	if bom == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bom_line_key %q is not a roll-goods line of the card", lineKey)
+	}
+
+	resp := &pb_admin.NestTechCardMarkerResponse{}
+	width, selvedge, err := s.nestWidth(ctx, card, int(req.GetColorwayId()), bom, in.FabricWidthCm, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// THE DIRECTION. Unknown is nested under the strict policy: a marker laid one-way is cuttable on
+	// any cloth, the reverse is not. The save path refuses an unknown direction on its own.
+	dirLines := entity.FabricDirectionLinesOfBom(card.BomItems)
+	dir, unknown, known := entity.ScopeFabricDirection(entity.MarkerFabricScope(bom.LineKey, dirLines), dirLines)
+	strict := !known || dir == entity.FabricDirectionOneWay
+	if known {
+		resp.FabricDirection = string(dir)
+	} else {
+		names := make([]string, 0, len(unknown))
+		for _, l := range unknown {
+			names = append(names, l.Name)
+		}
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf(
+			"fabric direction is not set on %s — nested as one-way; set it before saving", strings.Join(names, ", ")))
+	}
+	resp.AllowFlip = !strict
+
+	// THE PIECES, as the scope's files say today.
+	scopeKey := entity.FabricScopeIdentity(bom.Purpose.String, bom.LineKey, rollGoods)
+	src, err := s.repo.TechCards().GetTechCardPatternScopeSource(ctx, techCardID, scopeKey)
+	if err != nil {
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
+		slog.Default().ErrorContext(ctx, "can't load pattern scope",
+			slog.Int("tech_card_id", techCardID), slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't load pattern scope")
+	}
+	if len(src.Sheets) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the cloth has no pattern sheets — upload its patterns first")
+	}
+	inRange := make(map[int]bool, len(src.SizeIds))
+	for _, id := range src.SizeIds {
+		inRange[id] = true
+	}
+	for _, c := range in.Composition {
+		if !inRange[c.SizeId] {
+			return nil, status.Errorf(codes.InvalidArgument, "composition: size %d is not one of the card's sizes", c.SizeId)
+		}
+	}
+	sheets, m := s.measurePatternScope(ctx, *src)
+	resp.Findings = dto.PatternFindingsToPb(m.Findings)
+	if !m.Complete() {
+		var blocking []string
+		for _, f := range m.Findings {
+			if f.Blocking {
+				blocking = append(blocking, f.Detail)
+			}
+		}
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"the pattern files do not give every piece of the cloth: %s", strings.Join(blocking, "; "))
+	}
+	pieces, sources := nestPieces(card, sheets, m, in.Composition)
+
+	params := nesting.Params{
+		WidthCm:         width.InexactFloat64(),
+		GapCm:           in.GapCm.InexactFloat64(),
+		EdgeMarginCm:    in.EdgeMarginCm.InexactFloat64(),
+		ResolutionCm:    req.GetResolutionCm(),
+		AllowCrossGrain: req.GetAllowCrossGrain(),
+		AllowHalfTurn:   !strict,
+		AllowFlip:       !strict,
+	}
+	sizes := make([]nesting.SizeQuantity, 0, len(in.Composition))
+	for _, c := range in.Composition {
+		sizes = append(sizes, nesting.SizeQuantity{SizeId: c.SizeId, Quantity: c.Quantity})
+	}
+	res, err := nesting.Nest(pieces, sizes, params)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "can't nest: %v", err)
+	}
+	n := dto.NestedMarkerFromResult(res, pieces, sources, in.Composition, sheetUnit(sheets))
+
+	resp.Layout = n.Layout
+	resp.FabricWidthCm = dto.PbDecimalFromNull(decimal.NullDecimal{Decimal: width, Valid: true})
+	resp.SelvedgeCm = dto.PbDecimalFromNull(decimal.NullDecimal{Decimal: selvedge, Valid: true})
+	resp.UsedLengthCm = dto.PbDecimalFromNull(decimal.NullDecimal{Decimal: n.UsedLengthCm, Valid: n.PlacedCount > 0})
+	resp.EfficiencyPct = dto.PbDecimalFromNull(n.EfficiencyPct)
+	resp.PlacedCount = int32(n.PlacedCount)
+	resp.TotalCount = int32(n.TotalCount)
+	resp.Composition = dto.NestedMarkerCompositionToPb(n)
+	resp.Warnings = append(resp.Warnings, res.Warnings...)
+
+	// THE NORM of the same cloth, if there is one.
+	if norm, _, ok := entity.SelectNorm(entity.NormPeersOf(card.Markers),
+		entity.NormScope{BomItemId: int64(bom.Id), Bound: true}); ok {
+		for i := range card.Markers {
+			if card.Markers[i].Id == norm.Id {
+				c := entity.CompareMarkerToNorm(n.Summary(), card.Markers[i])
+				resp.Comparison = dto.MarkerNestComparisonToPb(&c)
+				break
+			}
+		}
+	}
+
+	if !req.GetSave() {
+		return resp, nil
+	}
+	if n.PlacedCount == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "no piece fits the width — nothing to save")
+	}
+	saved, err := s.SaveTechCardMarker(ctx, &pb_admin.SaveTechCardMarkerRequest{
+		TechCardId: req.GetTechCardId(),
+		Marker: dto.NestedMarkerInsertToPb(n, dto.NestedMarkerConditions{
+			Name:            req.GetName(),
+			BomLineKey:      bom.LineKey,
+			ColorwayId:      int(req.GetColorwayId()),
+			FabricWidthCm:   width,
+			SelvedgeCm:      selvedge,
+			GapCm:           in.GapCm,
+			EdgeMarginCm:    in.EdgeMarginCm,
+			AllowCrossGrain: req.GetAllowCrossGrain(),
+			AllowFlip:       !strict,
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.SavedMarkerId = saved.GetId()
+	return resp, nil
+}
+
+// nestWidth resolves the cutting width and the selvedge of the article the colourway pins for the
+// slot, filling the width fields of resp. An override wins; otherwise the narrowest measured lot,
+// then the nominal usable width — the order NormWidthVsArticle judges a norm by.
+func (s *Server) nestWidth(ctx context.Context, card *entity.TechCard, colorwayID int, bom *entity.TechCardBomItem,
+	override decimal.NullDecimal, resp *pb_admin.NestTechCardMarkerResponse) (decimal.Decimal, decimal.Decimal, error) {
+	selvedge := decimal.Zero
+	nominal := decimal.NullDecimal{}
+	measured := decimal.NullDecimal{}
+	if materialID := dto.LayArticleMaterialId(card, colorwayID, int64(bom.Id)); materialID > 0 {
+		var mat *entity.Material
+		if linked, ok := card.LinkedMaterials[materialID]; ok {
+			mat = &linked.Material
+		} else {
+			got, err := s.repo.TechCards().GetMaterial(ctx, materialID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				slog.Default().ErrorContext(ctx, "can't load material for nesting",
+					slog.Int("material_id", materialID), slog.String("err", err.Error()))
+				return decimal.Zero, decimal.Zero, status.Error(codes.Internal, "can't load material")
+			}
+			if got != nil {
+				mat = &got.Material
+			}
+		}
+		if mat != nil {
+			selvedge = mat.FabricSelvedgeCm()
+			nominal = mat.UsableFabricWidthCm()
+		}
+		lots, err := s.repo.MaterialStock().NarrowestMeasuredLotWidths(ctx, []int{materialID})
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "can't load lot widths for nesting", slog.String("err", err.Error()))
+			return decimal.Zero, decimal.Zero, status.Error(codes.Internal, "can't load lot widths")
+		}
+		measured = lots[materialID]
+	}
+	resp.MeasuredRollWidthCm = dto.PbDecimalFromNull(measured)
+	if override.Valid {
+		resp.WidthBasis = nestWidthBasisRequest
+		return override.Decimal, selvedge, nil
+	}
+	v := entity.NormWidthVsArticle(decimal.Zero, measured, selvedge, nominal)
+	switch v.Basis {
+	case entity.NormWidthBasisLot:
+		resp.WidthBasis = nestWidthBasisLot
+	case entity.NormWidthBasisNominal:
+		resp.WidthBasis = nestWidthBasisNominal
+	default:
+		return decimal.Zero, decimal.Zero, status.Error(codes.FailedPrecondition,
+			"no width to nest on: the article has no measured lot and no nominal width — send fabric_width_cm")
+	}
+	width := v.TodayCuttingCm.Decimal.RoundFloor(2)
+	if !width.IsPositive() {
+		return decimal.Zero, decimal.Zero, status.Error(codes.FailedPrecondition,
+			"the article's selvedges leave no cutting width — check its width and selvedge")
+	}
+	return width, selvedge, nil
+}
+
+// nestPieces turns a complete measurement into the engine's pieces: one per area row the ratio cuts,
+// contour from the row's block, count and symmetry from the card piece it is linked to.
+func nestPieces(card *entity.TechCard, sheets []*pb_admin.TechCardPatternSheetRead,
+	m dxfpattern.Measurement, composition []entity.MarkerCompositionEntry) ([]nesting.Piece, []dto.NestedPieceSource) {
+	cardPieces := make(map[string]entity.TechCardPiece, len(card.Pieces))
+	for _, p := range card.Pieces {
+		cardPieces[p.LineKey] = p
+	}
+	filenames := make(map[string]string, len(sheets))
+	for _, sh := range sheets {
+		filenames[sh.GetLineKey()] = sh.GetFilename()
+	}
+	cuts := make(map[int]bool, len(composition))
+	for _, c := range composition {
+		cuts[c.SizeId] = true
+	}
+	var pieces []nesting.Piece
+	var sources []dto.NestedPieceSource
+	for i, row := range m.Rows {
+		sizeID := int(row.SizeId.Int64)
+		if row.SizeId.Valid && !cuts[sizeID] {
+			continue
+		}
+		b := m.Blocks[m.RowBlocks[i]]
+		cp := cardPieces[row.PieceLineKey]
+		name := cp.Name
+		if name == "" {
+			name = b.Piece.Name
+		}
+		qty := cp.PiecesPerGarment
+		if qty < 1 {
+			qty = max(b.Piece.Quantity, 1)
+		}
+		contour := make([]nesting.Point, 0, len(b.Piece.Boundary))
+		for _, q := range b.Piece.Boundary {
+			contour = append(contour, nesting.Point{X: q.X, Y: q.Y})
+		}
+		label := name
+		if b.SizeToken != "" {
+			label += " " + strings.ToUpper(b.SizeToken)
+		}
+		pieces = append(pieces, nesting.Piece{
+			Key:           label,
+			SizeId:        sizeID,
+			Quantity:      qty,
+			Mirrored:      cp.CutSymmetry.String == string(entity.PieceCutSymmetryMirrored),
+			Contour:       contour,
+			AreaCm2:       b.Piece.AreaCm2,
+			GrainAngleDeg: b.Piece.GrainAngleDeg,
+			HasGrain:      b.Piece.GrainLine != nil,
+		})
+		sources = append(sources, dto.NestedPieceSource{
+			Name:         name,
+			Filename:     filenames[b.SheetLineKey],
+			BlockName:    b.Piece.Block,
+			PieceLineKey: row.PieceLineKey,
+		})
+	}
+	return pieces, sources
+}
+
+// sheetUnit is the unit the scope's DXF sheets were read in; "" when they disagree.
+func sheetUnit(sheets []*pb_admin.TechCardPatternSheetRead) dxfpattern.Unit {
+	unit := ""
+	for _, sh := range sheets {
+		if !sh.GetDxf() || sh.GetUnit() == "" {
+			continue
+		}
+		if unit != "" && unit != sh.GetUnit() {
+			return ""
+		}
+		unit = sh.GetUnit()
+	}
+	return dxfpattern.Unit(unit)
+}
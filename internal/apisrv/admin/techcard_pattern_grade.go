@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ГРАДАЦИЯ ЛЕКАЛ СЕРВЕРОМ (0346) — every size of a fabric scope drawn from its base size and point
+// grade rules. The base pieces are the server's own reading of the scope's files (the same
+// measurePatternScope the measurement and the nest stand on), and the graded areas are written
+// through SaveTechCardPieceAreas, so the sheet-set check and the completeness proof stay the store's.
+
+// GetTechCardPatternGradeRules returns one fabric scope's grade rules and the style's grade base.
+func (s *Server) GetTechCardPatternGradeRules(ctx context.Context, req *pb_admin.GetTechCardPatternGradeRulesRequest) (*pb_admin.GetTechCardPatternGradeRulesResponse, error) {
+	techCardID, scopeKey, err := gradeScopeArgs(req.GetTechCardId(), req.GetScopeKey())
+	if err != nil {
+		return nil, err
+	}
+	chart, err := s.repo.TechCards().GetStyleSizeChart(ctx, techCardID)
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "load the style's grade base", err)
+	}
+	set, err := s.repo.TechCards().GetTechCardPatternGradeRules(ctx, techCardID, scopeKey)
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "load grade rules", err)
+	}
+	return dto.PatternGradeRuleSetToPb(set, chart.GradeBaseSizeID), nil
+}
+
+// SaveTechCardPatternGradeRules replaces one fabric scope's grade rules.
+func (s *Server) SaveTechCardPatternGradeRules(ctx context.Context, req *pb_admin.SaveTechCardPatternGradeRulesRequest) (*pb_admin.SaveTechCardPatternGradeRulesResponse, error) {
+	techCardID, scopeKey, err := gradeScopeArgs(req.GetTechCardId(), req.GetScopeKey())
+	if err != nil {
+		return nil, err
+	}
+	rules, err := dto.PatternGradeRulesFromPb(req.GetRules())
+	if err != nil {
+		return nil, techCardConvertErr(err)
+	}
+	err = s.repo.TechCards().SaveTechCardPatternGradeRules(ctx, entity.PatternGradeRuleWrite{
+		TechCardId: techCardID,
+		ScopeKey:   scopeKey,
+		Rules:      rules,
+		UpdatedBy:  authsrv.GetAdminUsername(ctx),
+	})
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "save grade rules", err)
+	}
+	return &pb_admin.SaveTechCardPatternGradeRulesResponse{}, nil
+}
+
+// GradeTechCardPatternScope grades one fabric scope from its base size, returns the pieces per size
+// and, on request, the DXF files and the area write.
+func (s *Server) GradeTechCardPatternScope(ctx context.Context, req *pb_admin.GradeTechCardPatternScopeRequest) (*pb_admin.GradeTechCardPatternScopeResponse, error) {
+	techCardID, scopeKey, err := gradeScopeArgs(req.GetTechCardId(), req.GetScopeKey())
+	if err != nil {
+		return nil, err
+	}
+	chart, err := s.repo.TechCards().GetStyleSizeChart(ctx, techCardID)
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "load the style's grade base", err)
+	}
+	if chart.GradeBaseSizeID == 0 {
+		return nil, status.Error(codes.FailedPrecondition,
+			"the style has no grade base size — set it in the size chart first; pieces grade from the same base as the measurements")
+	}
+	src, err := s.repo.TechCards().GetTechCardPatternScopeSource(ctx, techCardID, scopeKey)
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "load pattern scope", err)
+	}
+	if len(src.Sheets) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the scope has no pattern sheets — upload the fabric's base-size patterns first")
+	}
+	set, err := s.repo.TechCards().GetTechCardPatternGradeRules(ctx, techCardID, scopeKey)
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "load grade rules", err)
+	}
+
+	sheets, m := s.measurePatternScope(ctx, *src)
+	sizeNames := make(map[int]string, len(src.SizeIds))
+	for _, sid := range src.SizeIds {
+		if size, ok := cache.GetSizeById(sid); ok {
+			sizeNames[sid] = size.Name
+		}
+	}
+	g := dxfpattern.Grade(m, *src, chart.GradeBaseSizeID, dto.PatternGradeRulesToEngine(set.Rules), sizeNames)
+	resp := &pb_admin.GradeTechCardPatternScopeResponse{
+		Sheets:     sheets,
+		BaseSizeId: int32(g.BaseSizeId),
+		Pieces:     dto.GradedPiecesToPb(g.Pieces),
+		Areas:      dto.PieceAreaInputsToPb(g.Rows),
+		Findings:   dto.PatternFindingsToPb(g.Findings),
+		Complete:   g.Complete(),
+	}
+	if req.GetFiles() {
+		resp.Files = dto.GradedSizeFilesToPb(g.SizeFiles(src.SizeIds, sizeNames), techCardID, scopeKey)
+	}
+	if !req.GetApply() || !g.Complete() {
+		return resp, nil
+	}
+	res, err := s.repo.TechCards().SaveTechCardPieceAreas(ctx, entity.PieceAreaWrite{
+		TechCardId:    techCardID,
+		ScopeKey:      scopeKey,
+		SheetLineKeys: m.SheetLineKeys,
+		Rows:          g.Rows,
+		ParsedBy:      authsrv.GetAdminUsername(ctx),
+	})
+	if err != nil {
+		return nil, s.patternGradeError(ctx, "write graded piece areas", err)
+	}
+	resp.AreasApplied = true
+	resp.AreasFingerprint = res.SheetFingerprint
+	resp.StoredAreas = int32(res.Stored)
+	return resp, nil
+}
+
+func gradeScopeArgs(techCardID int32, scopeKey string) (int, string, error) {
+	if techCardID <= 0 {
+		return 0, "", status.Error(codes.InvalidArgument, "tech_card_id is required")
+	}
+	scopeKey = strings.TrimSpace(scopeKey)
+	if scopeKey == "" {
+		return 0, "", status.Error(codes.InvalidArgument, "scope_key is required")
+	}
+	return int(techCardID), scopeKey, nil
+}
+
+// patternGradeError maps the tech card store's refusals (apierr.Status: field violations, a released
+// card, a missing card) and logs anything else.
+func (s *Server) patternGradeError(ctx context.Context, op string, err error) error {
+	if st, ok := apierr.Status(err); ok {
+		return st
+	}
+	slog.Default().ErrorContext(ctx, "pattern grading failed", slog.String("op", op), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op+"; try again")
+}
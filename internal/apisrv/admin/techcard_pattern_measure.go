@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/apisrv/apierr"
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/bucket"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ЗАМЕР ВЫКРОЕК СЕРВЕРОМ — the server's own reading of a fabric scope's DXF sheets.
+//
+// It is a SECOND reader, not a replacement for the client's: both write through the same store
+// paths, so the sheet-set check, the completeness proof and the fingerprint stay the store's and
+// neither reader can claim more than its files show. What the server adds is the comparison —
+// stored areas and size index against what the files say today (drift) — and a way to fill a
+// scope nobody opened in the browser.
+
+// MeasureTechCardPatternScope reads one fabric scope's DXF sheets, reports what it found and where
+// the stored areas / size index disagree, and with apply=true writes both.
+func (s *Server) MeasureTechCardPatternScope(ctx context.Context, req *pb_admin.MeasureTechCardPatternScopeRequest) (*pb_admin.MeasureTechCardPatternScopeResponse, error) {
+	if req.GetTechCardId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "tech_card_id is required")
+	}
+	scopeKey := strings.TrimSpace(req.GetScopeKey())
+	if scopeKey == "" {
+		return nil, status.Error(codes.InvalidArgument, "scope_key is required")
+	}
+	techCardID := int(req.GetTechCardId())
+	src, err := s.repo.TechCards().GetTechCardPatternScopeSource(ctx, techCardID, scopeKey)
+	if err != nil {
+		if st, ok := apierr.Status(err); ok {
+			return nil, st
+		}
+		slog.Default().ErrorContext(ctx, "can't load pattern scope",
+			slog.Int("tech_card_id", techCardID), slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't load pattern scope")
+	}
+	if len(src.Sheets) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the scope has no pattern sheets — upload the fabric's patterns first")
+	}
+
+	sheets, m := s.measurePatternScope(ctx, *src)
+	refs := make([]entity.PatternSheetRef, 0, len(src.Sheets))
+	for _, sh := range src.Sheets {
+		refs = append(refs, sh.Ref())
+	}
+	resp := &pb_admin.MeasureTechCardPatternScopeResponse{Sheets: sheets}
+
+	stored, err := s.repo.TechCards().GetTechCardPieceAreas(ctx, techCardID)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't load piece areas", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't load piece areas")
+	}
+	index, err := s.repo.TechCards().GetTechCardPatternSizeIndex(ctx, techCardID)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't load pattern size index", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't load pattern size index")
+	}
+	var storedScope *entity.PieceAreaScope
+	if sc, ok := stored[scopeKey]; ok {
+		storedScope = &sc
+	}
+	var indexRow *entity.PatternSizeIndexRow
+	if row, ok := index[scopeKey]; ok {
+		indexRow = &row
+	}
+	drift := dxfpattern.Drift(m, storedScope, indexRow, entity.PatternSheetFingerprint(refs))
+
+	resp.Blocks = dto.PatternBlocksToPb(m.Blocks)
+	resp.Areas = dto.PieceAreaInputsToPb(m.Rows)
+	resp.SizeTokens = m.SizeTokens
+	resp.Findings = dto.PatternFindingsToPb(m.Findings)
+	resp.Drift = dto.PatternDriftToPb(drift)
+	resp.Complete = m.Complete()
+	if !req.GetApply() {
+		return resp, nil
+	}
+
+	// THE TWO WRITES ARE INDEPENDENT, as they are from the client. The size index needs every sheet
+	// read; the areas need the whole set. A scope whose sizes read cleanly but one piece lacks a cut
+	// line still gets its index — the readiness gate should not wait on a costing input.
+	parsedBy := authsrv.GetAdminUsername(ctx)
+	if m.AllSheetsRead() {
+		res, err := s.repo.TechCards().PutTechCardPatternSizeIndex(ctx, entity.PatternSizeIndexWrite{
+			TechCardId:    techCardID,
+			ScopeKey:      scopeKey,
+			SheetLineKeys: m.SheetLineKeys,
+			SizeTokens:    m.SizeTokens,
+			ParsedBy:      parsedBy,
+		})
+		if err != nil {
+			if st, ok := apierr.Status(err); ok {
+				return nil, st
+			}
+			slog.Default().ErrorContext(ctx, "can't write pattern size index", slog.String("err", err.Error()))
+			return nil, status.Error(codes.Internal, "can't write pattern size index")
+		}
+		found := make(map[string]bool, len(res.StoredTokens))
+		for _, t := range res.StoredTokens {
+			found[t] = true
+		}
+		for _, sid := range res.CardSizeIds {
+			if size, ok := cache.GetSizeById(sid); ok && entity.SizeCoveredByTokens(size.Name, found) {
+				resp.ResolvedSizeCount++
+			}
+		}
+		resp.SizeIndexApplied = true
+		resp.SizeIndexFingerprint = res.SheetFingerprint
+	}
+	if m.Complete() {
+		res, err := s.repo.TechCards().SaveTechCardPieceAreas(ctx, entity.PieceAreaWrite{
+			TechCardId:    techCardID,
+			ScopeKey:      scopeKey,
+			SheetLineKeys: m.SheetLineKeys,
+			Rows:          m.Rows,
+			ParsedBy:      parsedBy,
+		})
+		if err != nil {
+			if st, ok := apierr.Status(err); ok {
+				return nil, st
+			}
+			slog.Default().ErrorContext(ctx, "can't write piece areas", slog.String("err", err.Error()))
+			return nil, status.Error(codes.Internal, "can't write piece areas")
+		}
+		resp.AreasApplied = true
+		resp.AreasFingerprint = res.SheetFingerprint
+		resp.StoredAreas = int32(res.Stored)
+	}
+	return resp, nil
+}
+
+// measurePatternScope reads every DXF sheet of a scope and measures it, reporting how each sheet
+// was read. Shared by the measurement and the nest, so both stand on the same reading.
+func (s *Server) measurePatternScope(ctx context.Context, src entity.PatternScopeSource) ([]*pb_admin.TechCardPatternSheetRead, dxfpattern.Measurement) {
+	sheets := make([]*pb_admin.TechCardPatternSheetRead, 0, len(src.Sheets))
+	patterns := make(map[string]*dxfpattern.Pattern, len(src.Sheets))
+	unreadable := map[string]string{}
+	for _, sh := range src.Sheets {
+		read := &pb_admin.TechCardPatternSheetRead{LineKey: sh.LineKey, Filename: sh.Filename, Dxf: sh.IsDXF()}
+		sheets = append(sheets, read)
+		if !sh.IsDXF() {
+			continue
+		}
+		p, reason := s.readPatternSheet(ctx, sh)
+		if p == nil {
+			unreadable[sh.LineKey] = reason
+			read.Error = reason
+			continue
+		}
+		patterns[sh.LineKey] = p
+		read.Unit = string(p.Unit)
+		read.UnitSource = p.UnitSource
+		read.BlockCount = int32(len(p.Pieces))
+		read.Warnings = p.Warnings
+	}
+
+	sizeNames := make(map[int]string, len(src.SizeIds))
+	for _, sid := range src.SizeIds {
+		if size, ok := cache.GetSizeById(sid); ok {
+			sizeNames[sid] = size.Name
+		}
+	}
+	return sheets, dxfpattern.Measure(src, patterns, unreadable, sizeNames)
+}
+
+// readPatternSheet fetches and parses one DXF sheet. A nil pattern comes with the reason, worded
+// for the finding it becomes; only a storage failure is logged — a bad file is the files' news.
+func (s *Server) readPatternSheet(ctx context.Context, sh entity.PatternScopeSheet) (*dxfpattern.Pattern, string) {
+	key, ok := storeutil.PatternObjectKey(sh.URL)
+	if !ok {
+		return nil, "the sheet's url is not a managed pattern object"
+	}
+	raw, err := s.bucket.GetPatternObject(ctx, key)
+	if err != nil {
+		if errors.Is(err, bucket.ErrPatternObjectKeyNotManaged) {
+			return nil, "the sheet's url is not a managed pattern object"
+		}
+		slog.Default().ErrorContext(ctx, "can't read pattern object",
+			slog.String("line_key", sh.LineKey), slog.String("err", err.Error()))
+		return nil, "the file could not be fetched from storage"
+	}
+	p, err := dxfpattern.Parse(raw)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return p, ""
+}
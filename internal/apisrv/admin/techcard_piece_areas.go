@@ -15,10 +15,9 @@ import (
 
 // ПЛОЩАДИ ДЕТАЛЕЙ КРОЯ (Ф0, 0297) — the handler half.
 //
-// The server does not parse DXF and is not going to start (the same statement 0280 makes and for
-// the same reason: the heuristic that decides which token in a block name is a size, and the
-// geometry pipeline that inflates a seam-line contour by its allowance, live in ONE place — the
-// browser). What crosses the wire is the RESULT of that measurement.
+// What crosses the wire here is the RESULT of the client's measurement: the geometry pipeline that
+// inflates a seam-line contour by its allowance lives in the browser. The server's own reading
+// (MeasureTechCardPatternScope) measures the cut line only and writes through the same store call.
 //
 // What the server does own is the half that could be forged in the dangerous direction: the claim
 // «these areas were measured from the card's current patterns». The client names the sheets, the
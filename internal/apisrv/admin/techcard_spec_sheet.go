@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	authsrv "github.com/jekabolt/grbpwr-manager/internal/apisrv/auth"
+	"github.com/jekabolt/grbpwr-manager/internal/cutspec"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PDF-СПЕЦИФИКАЦИЯ РЕЛИЗА — admin export and share link. The file itself is built by
+// internal/specsheet from the release snapshot through cutspec, so there is no costing to strip
+// here: a tech-cards reader without costing:read gets the same bytes as everyone else.
+
+// ExportTechCardReleaseSpecSheet renders the release's PDF spec sheet.
+func (s *Server) ExportTechCardReleaseSpecSheet(ctx context.Context, req *pb_admin.ExportTechCardReleaseSpecSheetRequest) (*pb_admin.ExportTechCardReleaseSpecSheetResponse, error) {
+	releaseID := int(req.GetReleaseId())
+	if releaseID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "release_id is required")
+	}
+	if s.specSheets == nil {
+		return nil, status.Error(codes.Unavailable, "spec sheet export is not configured")
+	}
+	pdf, name, caveats, err := s.specSheets.Render(ctx, releaseID)
+	if err != nil {
+		return nil, specSheetError(ctx, "export spec sheet", releaseID, err)
+	}
+	return &pb_admin.ExportTechCardReleaseSpecSheetResponse{Pdf: pdf, Filename: name, Caveats: caveats}, nil
+}
+
+// ShareTechCardReleaseSpecSheet creates, rotates, revokes or re-dates the release's spec sheet link.
+func (s *Server) ShareTechCardReleaseSpecSheet(ctx context.Context, req *pb_admin.ShareTechCardReleaseSpecSheetRequest) (*pb_admin.ShareTechCardReleaseSpecSheetResponse, error) {
+	releaseID := int(req.GetReleaseId())
+	if releaseID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "release_id is required")
+	}
+	if req.GetRotate() && req.GetRevoke() {
+		return nil, status.Error(codes.InvalidArgument, "rotate and revoke are exclusive: rotate issues a new link, revoke kills the current one")
+	}
+	upd := entity.ReleaseSpecAccessUpdate{
+		Rotate:   req.GetRotate(),
+		Revoke:   req.GetRevoke(),
+		Username: authsrv.GetAdminUsername(ctx),
+	}
+	if req.GetExpiresAt() != nil {
+		upd.ExpiresAt = sql.NullTime{Time: req.GetExpiresAt().AsTime().UTC(), Valid: true}
+	}
+	row, err := s.repo.TechCards().UpdateReleaseSpecAccess(ctx, releaseID, upd)
+	if err != nil {
+		return nil, specSheetError(ctx, "share spec sheet", releaseID, err)
+	}
+	resp := &pb_admin.ShareTechCardReleaseSpecSheetResponse{
+		Token: s.specSheets.MintSpecSheetToken(row),
+		Epoch: int32(row.Epoch),
+	}
+	if row.ExpiresAt.Valid {
+		resp.ExpiresAt = timestamppb.New(row.ExpiresAt.Time)
+	}
+	if row.RevokedAt.Valid {
+		resp.RevokedAt = timestamppb.New(row.RevokedAt.Time)
+	}
+	return resp, nil
+}
+
+func specSheetError(ctx context.Context, op string, releaseID int, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "tech card release not found")
+	case errors.Is(err, cutspec.ErrReleaseSheetUnreadable):
+		return status.Error(codes.FailedPrecondition, "the release snapshot cannot be read by the current schema; re-release the tech card to print it")
+	}
+	slog.Default().ErrorContext(ctx, "can't "+op,
+		slog.Int("release_id", releaseID), slog.String("err", err.Error()))
+	return status.Errorf(codes.Internal, "can't %s", op)
+}
@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultMostWishedDays is the report window when the request leaves from empty.
+const defaultMostWishedDays = 30
+
+// ListMostWishedStyles ranks styles by the storefront accounts wishing for them. The ranking is over
+// the wishlists as they stand; the window only bounds the added_in_window count.
+func (s *Server) ListMostWishedStyles(ctx context.Context, req *pb_admin.ListMostWishedStylesRequest) (*pb_admin.ListMostWishedStylesResponse, error) {
+	to := time.Now().UTC()
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime().UTC()
+	}
+	from := to.AddDate(0, 0, -defaultMostWishedDays)
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime().UTC()
+	}
+	if !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+	limit, _ := clampPagination(int(req.GetLimit()), 0)
+
+	styles, err := s.repo.Wishlists().ListMostWishedStyles(ctx, from, to, limit)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list most wished styles",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't list most wished styles")
+	}
+	return &pb_admin.ListMostWishedStylesResponse{
+		Styles: dto.MostWishedStylesToPb(styles),
+		From:   timestamppb.New(from),
+		To:     timestamppb.New(to),
+	}, nil
+}
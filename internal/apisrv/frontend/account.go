@@ -479,6 +479,23 @@ func (s *Server) accountID(ctx context.Context) (int, error) {
 	return acc.ID, nil
 }
 
+// signedInAccount is accountID plus the account's tier, from the same lookup, for the calls that
+// hide tier-locked colourways (wishlist items, fit-profile reference garments).
+func (s *Server) signedInAccount(ctx context.Context) (int, int16, error) {
+	email, err := s.storefrontEmailFromAccess(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	acc, err := s.repo.StorefrontAccount().GetAccountByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, status.Error(codes.NotFound, "account not found")
+		}
+		return 0, 0, status.Error(codes.Internal, "can't load account")
+	}
+	return acc.ID, entity.TierCode(acc.Tier()), nil
+}
+
 // ListSavedAddresses lists saved addresses.
 func (s *Server) ListSavedAddresses(ctx context.Context, _ *pb_frontend.ListSavedAddressesRequest) (*pb_frontend.ListSavedAddressesResponse, error) {
 	aid, err := s.accountID(ctx)
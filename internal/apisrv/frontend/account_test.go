@@ -48,7 +48,7 @@ func TestRequestAccountLogin_InvalidEmail(t *testing.T) {
 	mockRepo.EXPECT().StorefrontAccount().Return(mockStorefrontAcc).Maybe()
 	mockMailer := mocks.NewMockMailer(t)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	_, err = srv.RequestAccountLogin(ctx, &pb_frontend.RequestAccountLoginRequest{Email: "invalid"})
@@ -67,7 +67,7 @@ func TestRequestAccountLogin_EmptyEmail(t *testing.T) {
 	mockRepo.EXPECT().StorefrontAccount().Return(mockStorefrontAcc).Maybe()
 	mockMailer := mocks.NewMockMailer(t)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	_, err = srv.RequestAccountLogin(ctx, &pb_frontend.RequestAccountLoginRequest{Email: ""})
@@ -93,7 +93,7 @@ func TestRequestAccountLogin_Success(t *testing.T) {
 		QueueAccountLogin(mock.Anything, mock.Anything, testEmail, mock.AnythingOfType("string"), mock.AnythingOfType("string")).
 		Return(nil)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	resp, err := srv.RequestAccountLogin(ctx, &pb_frontend.RequestAccountLoginRequest{Email: testEmail})
@@ -110,7 +110,7 @@ func TestVerifyAccountLoginCode_EmptyCode(t *testing.T) {
 	mockRepo.EXPECT().StorefrontAccount().Return(mockStorefrontAcc).Maybe()
 	mockMailer := mocks.NewMockMailer(t)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	_, err = srv.VerifyAccountLoginCode(ctx, &pb_frontend.VerifyAccountLoginCodeRequest{
@@ -132,7 +132,7 @@ func TestRefreshAccountSession_EmptyToken(t *testing.T) {
 	mockRepo.EXPECT().StorefrontAccount().Return(mockStorefrontAcc).Maybe()
 	mockMailer := mocks.NewMockMailer(t)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	_, err = srv.RefreshAccountSession(ctx, &pb_frontend.RefreshAccountSessionRequest{RefreshToken: ""})
@@ -151,7 +151,7 @@ func TestGetAccount_NoMetadata(t *testing.T) {
 	mockRepo.EXPECT().StorefrontAccount().Return(mockStorefrontAcc).Maybe()
 	mockMailer := mocks.NewMockMailer(t)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	_, err = srv.GetAccount(ctx, &pb_frontend.GetAccountRequest{})
@@ -182,7 +182,7 @@ func TestAddSavedAddress_MaxLimit(t *testing.T) {
 	mockStorefrontAcc.EXPECT().GetAccountByEmail(mock.Anything, testEmail).Return(&entity.StorefrontAccount{ID: 1, Email: testEmail}, nil)
 	mockStorefrontAcc.EXPECT().ListSavedAddresses(mock.Anything, 1).Return(addrs, nil)
 
-	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mockMailer, nil, nil, nil, nil, nil, storefrontConfig())
 	assert.NoError(t, err)
 
 	_, err = srv.AddSavedAddress(ctx, &pb_frontend.AddSavedAddressRequest{
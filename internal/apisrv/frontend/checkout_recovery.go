@@ -0,0 +1,102 @@
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"regexp"
+
+	v "github.com/asaskevich/govalidator"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/middleware"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoveryTokenRe is the shape of a restore-link token; anything else is unknown without a lookup.
+var recoveryTokenRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// checkoutRecoveryIdentity picks the address an abandoned checkout is remembered under. A signed-in
+// customer is remembered under the account's email (with the account) unless the checkout names
+// another address; otherwise the given email is used as typed, if it is one. Empty when there is no
+// usable address.
+func (s *Server) checkoutRecoveryIdentity(ctx context.Context, email string) (string, int) {
+	email = normalizeEmail(email)
+	if tokEmail, err := s.storefrontEmailFromAccess(ctx); err == nil && (email == "" || email == normalizeEmail(tokEmail)) {
+		acc, err := s.repo.StorefrontAccount().GetAccountByEmail(ctx, tokEmail)
+		if err == nil {
+			return normalizeEmail(tokEmail), acc.ID
+		}
+		return normalizeEmail(tokEmail), 0
+	}
+	if email == "" || !v.IsEmail(email) {
+		return "", 0
+	}
+	return email, 0
+}
+
+// recordAbandonedCheckout remembers a consented checkout for the recovery emails. Best-effort: a
+// failure is logged and never fails the checkout call that triggered it.
+func (s *Server) recordAbandonedCheckout(ctx context.Context, cart *entity.CheckoutRecoveryCart) {
+	if cart.Email == "" || len(cart.Lines) == 0 {
+		return
+	}
+	if err := s.repo.CheckoutRecoveries().RecordAbandonedCheckout(ctx, cart); err != nil {
+		slog.Default().WarnContext(ctx, "can't record abandoned checkout",
+			slog.String("source", string(cart.Source)),
+			slog.String("err", err.Error()),
+		)
+	}
+}
+
+// checkoutRecoveryLines keeps the variant and quantity of each order line.
+func checkoutRecoveryLines(items []entity.OrderItem) []entity.CheckoutRecoveryLine {
+	lines := make([]entity.CheckoutRecoveryLine, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, entity.CheckoutRecoveryLine{VariantId: it.VariantID, Quantity: it.Quantity})
+	}
+	return lines
+}
+
+// RestoreAbandonedCart returns the cart of an abandoned checkout for the restore link of a recovery
+// email, and records the first restore for the step the link came from. Lines no longer on sale are
+// left out; prices and stock are checked again by ValidateOrderItemsInsert.
+func (s *Server) RestoreAbandonedCart(ctx context.Context, req *pb_frontend.RestoreAbandonedCartRequest) (*pb_frontend.RestoreAbandonedCartResponse, error) {
+	if err := s.rateLimiter.CheckValidation(middleware.GetClientIP(ctx)); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	if !recoveryTokenRe.MatchString(req.GetToken()) {
+		return nil, status.Error(codes.NotFound, "cart not found")
+	}
+	restored, err := s.repo.CheckoutRecoveries().RestoreCheckoutRecovery(ctx, req.GetToken(), int(req.GetStep()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "cart not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't restore abandoned cart", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't restore cart")
+	}
+	return dto.CheckoutRecoveryRestoreToPb(restored), nil
+}
+
+// StopCheckoutRecovery stops the recovery emails of an abandoned checkout. Stopping a sequence that is
+// already over succeeds.
+func (s *Server) StopCheckoutRecovery(ctx context.Context, req *pb_frontend.StopCheckoutRecoveryRequest) (*pb_frontend.StopCheckoutRecoveryResponse, error) {
+	if err := s.rateLimiter.CheckValidation(middleware.GetClientIP(ctx)); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	if !recoveryTokenRe.MatchString(req.GetToken()) {
+		return nil, status.Error(codes.NotFound, "cart not found")
+	}
+	if err := s.repo.CheckoutRecoveries().OptOutCheckoutRecovery(ctx, req.GetToken()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "cart not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't stop checkout recovery", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't stop reminders")
+	}
+	return &pb_frontend.StopCheckoutRecoveryResponse{}, nil
+}
@@ -0,0 +1,201 @@
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/sizerec"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetFitProfile returns the signed-in account's fit profile; an empty one when it never saved one.
+func (s *Server) GetFitProfile(ctx context.Context, _ *pb_frontend.GetFitProfileRequest) (*pb_frontend.GetFitProfileResponse, error) {
+	aid, err := s.accountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.repo.Sizing().GetFitProfile(ctx, aid)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get fit profile",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't get fit profile")
+	}
+	return &pb_frontend.GetFitProfileResponse{
+		Profile:   dto.FitProfileToPb(p),
+		UpdatedAt: dto.FitProfileUpdatedAtToPb(p.UpdatedAt),
+	}, nil
+}
+
+// UpdateFitProfile replaces the signed-in account's fit profile. Measurements left out are deleted.
+func (s *Server) UpdateFitProfile(ctx context.Context, req *pb_frontend.UpdateFitProfileRequest) (*pb_frontend.UpdateFitProfileResponse, error) {
+	aid, tier, err := s.signedInAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := dto.FitProfilePbToEntity(req.GetProfile())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	p.AccountId = aid
+	if p.ReferenceVariantSKU != "" {
+		ref, err := s.fitReference(ctx, p.ReferenceVariantSKU, tier)
+		if err != nil {
+			return nil, err
+		}
+		if ref == nil {
+			return nil, status.Error(codes.InvalidArgument, "reference_variant_sku is not a storefront variant")
+		}
+		p.ReferenceProductId, p.ReferenceSizeId = ref.ProductId, ref.SizeId
+	}
+	if err := s.repo.Sizing().SaveFitProfile(ctx, p); err != nil {
+		slog.Default().ErrorContext(ctx, "can't save fit profile",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't save fit profile")
+	}
+	saved, err := s.repo.Sizing().GetFitProfile(ctx, aid)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get fit profile",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't get fit profile")
+	}
+	return &pb_frontend.UpdateFitProfileResponse{Profile: dto.FitProfileToPb(saved)}, nil
+}
+
+// RecommendSize recommends a size of a colourway from the request's fit profile or, without one, the
+// signed-in account's saved profile. Measurements given explicitly win over the ones implied by the
+// reference variant.
+func (s *Server) RecommendSize(ctx context.Context, req *pb_frontend.RecommendSizeRequest) (*pb_frontend.RecommendSizeResponse, error) {
+	if req.BaseSku == "" {
+		return nil, status.Error(codes.InvalidArgument, "base_sku is required")
+	}
+
+	// Guests size with the profile they send; only the saved profile needs the account.
+	var (
+		p    *entity.FitProfile
+		err  error
+		tier = s.viewerTier(ctx)
+	)
+	if req.Profile != nil {
+		p, err = dto.FitProfilePbToEntity(req.Profile)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	} else {
+		aid, err := s.accountID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p, err = s.repo.Sizing().GetFitProfile(ctx, aid)
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "can't get fit profile",
+				slog.String("err", err.Error()),
+			)
+			return nil, status.Error(codes.Internal, "can't recommend a size")
+		}
+	}
+
+	pf, err := s.repo.Products().GetProductBySKU(ctx, req.BaseSku)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "product not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't get product by sku",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't recommend a size")
+	}
+	// Same leak-proofing as GetColorway: a hidden colourway is not found for a viewer who doesn't qualify.
+	if pf.Product == nil || (pf.Product.HiddenForNonQualified() && !entity.TierCanPurchase(tier, pf.Product.MinTier())) {
+		return nil, status.Error(codes.NotFound, "product not found")
+	}
+
+	body := sizerec.BodyFromMeasurements(p.Measurements)
+	basis := sizerec.BasisMeasurements
+	if p.ReferenceVariantSKU != "" {
+		ref, err := s.fitReference(ctx, p.ReferenceVariantSKU, tier)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ref != nil:
+			refData, err := s.repo.Sizing().GetStyleFitData(ctx, ref.StyleId)
+			if err != nil {
+				slog.Default().ErrorContext(ctx, "can't get reference style fit data",
+					slog.String("err", err.Error()),
+				)
+				return nil, status.Error(codes.Internal, "can't recommend a size")
+			}
+			implied := sizerec.ImpliedBody(refData, ref.SizeId)
+			for name, mm := range implied {
+				if _, ok := body[name]; !ok {
+					body[name] = mm
+				}
+			}
+			if len(p.Measurements) == 0 {
+				basis = sizerec.BasisReference
+			} else if len(implied) > 0 {
+				basis = sizerec.BasisBoth
+			}
+		case req.Profile != nil:
+			// A saved reference that left the storefront is skipped; a sent one is a bad request.
+			return nil, status.Error(codes.InvalidArgument, "reference_variant_sku is not a storefront variant")
+		}
+	}
+	if len(body) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "the fit profile has no measurements or reference size")
+	}
+
+	data, err := s.repo.Sizing().GetStyleFitData(ctx, pf.Product.StyleId)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get style fit data",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't recommend a size")
+	}
+	rec, err := sizerec.Recommend(data, body, p.Preference, basis)
+	if err != nil {
+		if errors.Is(err, sizerec.ErrNoOverlap) {
+			return nil, status.Error(codes.FailedPrecondition, "the size chart of this product can't be matched with the fit profile")
+		}
+		slog.Default().ErrorContext(ctx, "can't recommend a size",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't recommend a size")
+	}
+
+	variantSKUs := make(map[int]string, len(pf.Sizes))
+	for _, v := range pf.Sizes {
+		if v.SKU.Valid && v.Grade == "A" && entity.VariantStatus(v.Status) != entity.VariantStatusArchived {
+			variantSKUs[v.SizeId] = v.SKU.String
+		}
+	}
+	return dto.SizeRecommendationToPb(rec, variantSKUs), nil
+}
+
+// fitReference resolves a reference variant SKU; nil when it is not a variant of a colourway the
+// viewer may see (unknown and tier-hidden answer the same).
+func (s *Server) fitReference(ctx context.Context, variantSKU string, tier int16) (*entity.FitVariant, error) {
+	ref, err := s.repo.Sizing().ResolveFitVariant(ctx, variantSKU)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		slog.Default().ErrorContext(ctx, "can't resolve reference variant",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't resolve reference variant")
+	}
+	if ref.HiddenForNonQualified && !entity.TierCanPurchase(tier, ref.MinTier) {
+		return nil, nil
+	}
+	return ref, nil
+}
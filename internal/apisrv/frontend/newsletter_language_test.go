@@ -24,7 +24,7 @@ func subscribeServer(t *testing.T) (*Server, *mocks.MockStorefrontAccount, conte
 	mockRepo.EXPECT().Subscribers().Return(subs).Maybe()
 	subs.EXPECT().UpsertSubscription(mock.Anything, mock.Anything, false).Return(false, nil).Maybe()
 
-	srv, err := New(mockRepo, mocks.NewMockMailer(t), nil, nil, nil, nil, storefrontConfig())
+	srv, err := New(mockRepo, mocks.NewMockMailer(t), nil, nil, nil, nil, nil, storefrontConfig())
 	require.NoError(t, err)
 
 	ctx := context.WithValue(context.Background(), middleware.ClientIPKey, "1.2.3.4")
@@ -46,7 +46,7 @@ func (s *Server) retryInsertFiatInvoiceAfterItemsUpdated(ctx context.Context, or
 		return nil, fmt.Errorf("get updated order: %w", err)
 	}
 
-	orderTotal := orderFull.Order.TotalPriceDecimal()
+	orderTotal := orderFull.Order.AmountDue()
 	if err = handler.UpdatePaymentIntentAmount(ctx, paymentIntentId, orderTotal, orderFull.Order.Currency); err != nil {
 		return nil, fmt.Errorf("update payment intent amount: %w", err)
 	}
@@ -70,7 +70,7 @@ func (s *Server) ensurePaymentIntentAmountMatchesOrder(ctx context.Context, hand
 		return nil
 	}
 	piAmount := stripe.AmountFromSmallestUnit(stripePi.Amount, string(stripePi.Currency))
-	orderTotal := orderFull.Order.TotalPriceDecimal()
+	orderTotal := orderFull.Order.AmountDue()
 	if piAmount.Equal(orderTotal) {
 		return nil
 	}
@@ -87,6 +87,19 @@ func (s *Server) ValidateOrderItemsInsert(ctx context.Context, req *pb_frontend.
 		return nil, verr
 	}
 
+	// Remember the cart for the recovery emails when the customer agreed to them.
+	if req.RecoveryConsent {
+		if email, accountID := s.checkoutRecoveryIdentity(ctx, req.Email); email != "" {
+			s.recordAbandonedCheckout(ctx, &entity.CheckoutRecoveryCart{
+				Email:     email,
+				AccountId: accountID,
+				Currency:  currency,
+				Source:    entity.CheckoutRecoverySourceValidate,
+				Lines:     checkoutRecoveryLines(oiv.ValidItems),
+			})
+		}
+	}
+
 	totalSale := oiv.SubtotalDecimal()
 
 	pbOii := make([]*pb_common.OrderItem, 0, len(oiv.ValidItems))
@@ -139,19 +152,28 @@ func (s *Server) ValidateOrderItemsInsert(ctx context.Context, req *pb_frontend.
 		effectiveShipmentPrice = decimal.Zero
 	}
 
-	// Apply promo discount if present
-	promo, promoOk := cache.GetPromoByCode(req.PromoCode)
-	if promoOk {
-		decimalPlaces := dto.DecimalPlacesForCurrency(currency)
-		promoTotal, promoFreeShipping := promo.CalculateTotalWithPromo(totalSale, effectiveShipmentPrice, decimalPlaces)
-		totalSale = promoTotal
-		if promoFreeShipping {
-			freeShipping = true
-			effectiveShipmentPrice = decimal.Zero
-		}
-	} else {
-		// No promo — just add shipping
-		totalSale = totalSale.Add(effectiveShipmentPrice)
+	// Evaluate the entered codes with the same rules CreateOrder charges. The buyer's email (for the
+	// per-customer caps) is only known for a signed-in customer; a guest's caps are checked again
+	// when the order is placed.
+	promoCodes := append([]string{req.PromoCode}, req.PromoCodes...)
+	buyerEmail, _ := s.storefrontEmailFromAccess(ctx)
+	bd, err := s.repo.Promo().EvaluateCart(ctx, promoCodes, entity.PromoCart{
+		Currency:      currency,
+		DecimalPlaces: dto.DecimalPlacesForCurrency(currency),
+		Lines:         entity.PromoCartLinesFromOrderItems(oiv.ValidItems),
+		BuyerTier:     s.viewerTier(ctx),
+		BuyerEmail:    buyerEmail,
+	})
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't evaluate promo codes",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Errorf(codes.Internal, "can't evaluate promo codes")
+	}
+	totalSale, promoFreeShipping := bd.ApplyTo(totalSale, effectiveShipmentPrice, dto.DecimalPlacesForCurrency(currency))
+	if promoFreeShipping {
+		freeShipping = true
+		effectiveShipmentPrice = decimal.Zero
 	}
 
 	response := &pb_frontend.ValidateOrderItemsInsertResponse{
@@ -162,10 +184,13 @@ func (s *Server) ValidateOrderItemsInsert(ctx context.Context, req *pb_frontend.
 		ItemAdjustments: dto.ConvertEntityOrderItemAdjustmentsToPb(oiv.ItemAdjustments),
 		FreeShipping:    freeShipping,
 		ShippingPrice:   &pb_decimal.Decimal{Value: dto.RoundForCurrency(effectiveShipmentPrice, currency).String()},
+		PromoBreakdown:  dto.ConvertEntityPromoBreakdownToPb(bd, currency),
 	}
 
-	if promoOk {
-		response.Promo = dto.ConvertEntityPromoInsertToPb(promo.PromoCodeInsert)
+	if id := bd.PrimaryPromoId(); id != 0 {
+		if promo, ok := cache.GetPromoById(id); ok {
+			response.Promo = dto.ConvertEntityPromoInsertToPb(promo.PromoCodeInsert)
+		}
 	}
 
 	// Create PaymentIntent if payment method is CARD
@@ -198,7 +223,7 @@ func (s *Server) ValidateOrderItemsInsert(ctx context.Context, req *pb_frontend.
 		}
 
 		// Cart fingerprint for session matching (same cart + same client = same session)
-		cartFingerprint := cartFingerprintForPreOrder(roundedTotal, currency, req.Country, req.PromoCode, req.ShipmentCarrierId, itemsToInsert, clientSession)
+		cartFingerprint := cartFingerprintForPreOrder(roundedTotal, currency, req.Country, strings.Join(bd.Codes(), ","), req.ShipmentCarrierId, itemsToInsert, clientSession)
 		pi, rotatedKey, err := handler.GetOrCreatePreOrderPaymentIntent(ctx, req.IdempotencyKey, roundedTotal, currency, req.Country, cartFingerprint)
 		if err != nil {
 			if errors.Is(err, stripe.ErrPaymentAlreadyCompleted) {
@@ -0,0 +1,190 @@
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/middleware"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// orderForReturn resolves the order of a public returns call from (order_uuid, b64_email): the
+// same proof of ownership CancelOrderByUser uses.
+func (s *Server) orderForReturn(ctx context.Context, orderUUID, b64Email string) (*entity.OrderFull, error) {
+	if err := s.rateLimiter.CheckOrderInvoiceIP(middleware.GetClientIP(ctx)); err != nil {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many requests")
+	}
+	if orderUUID == "" {
+		return nil, status.Error(codes.InvalidArgument, "orderUuid is required")
+	}
+	emailBytes, err := base64.StdEncoding.DecodeString(b64Email)
+	if err != nil || len(emailBytes) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "can't decode email")
+	}
+	orderFull, err := s.repo.Order().GetOrderByUUIDAndEmail(ctx, orderUUID, string(emailBytes))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "order not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't get order",
+			slog.String("err", err.Error()),
+			slog.String("order_uuid", orderUUID),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get order")
+	}
+	return orderFull, nil
+}
+
+// RequestReturn opens a return for selected lines of a delivered order. An exchange names the
+// replacement by its public variant SKU. A short stockreserve hold checks the replacement against
+// stock other shoppers hold in their carts; the store then takes it out of stock with the request,
+// so it stays put until the admin decides (rejecting or cancelling the return puts it back).
+func (s *Server) RequestReturn(ctx context.Context, req *pb_frontend.RequestReturnRequest) (*pb_frontend.RequestReturnResponse, error) {
+	orderFull, err := s.orderForReturn(ctx, req.GetOrderUuid(), req.GetB64Email())
+	if err != nil {
+		return nil, err
+	}
+
+	os, ok := cache.GetOrderStatusById(orderFull.Order.OrderStatusId)
+	if !ok {
+		return nil, status.Error(codes.Internal, "can't get order status by id")
+	}
+	if os.Status.Name != entity.Delivered && os.Status.Name != entity.PartiallyRefunded {
+		return nil, status.Errorf(codes.FailedPrecondition, "order cannot be returned in status: %s", os.Status.Name)
+	}
+	if eligible, reason := isOrderEligibleForReturn(orderFull, entity.Delivered); !eligible {
+		return nil, status.Error(codes.FailedPrecondition, reason)
+	}
+
+	resolution, _ := dto.ConvertPbReturnResolutionToEntity(req.GetResolution())
+	in := &entity.ReturnRequestInsert{
+		Resolution:   resolution,
+		ReasonCode:   dto.ReturnReasonKey(req.GetReason()),
+		CustomerNote: req.GetNote(),
+		Items:        make([]entity.ReturnRequestItemInsert, 0, len(req.GetItems())),
+	}
+
+	// Replacement units per variant: two lines exchanged into the same size hold their sum.
+	type pair struct{ productID, sizeID int }
+	holds := map[pair]decimal.Decimal{}
+	stock := map[pair]decimal.Decimal{}
+	for _, it := range req.GetItems() {
+		item := entity.ReturnRequestItemInsert{OrderItemId: int(it.GetOrderItemId()), Quantity: int(it.GetQuantity())}
+		if it.GetExchangeVariantSku() != "" {
+			variant, err := s.repo.Products().GetVariantBySKU(ctx, it.GetExchangeVariantSku())
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, status.Errorf(codes.NotFound, "variant not found")
+				}
+				slog.Default().ErrorContext(ctx, "can't resolve variant", slog.String("err", err.Error()))
+				return nil, status.Errorf(codes.Internal, "can't resolve variant")
+			}
+			if entity.VariantStatus(variant.Status) != entity.VariantStatusActive {
+				return nil, status.Errorf(codes.FailedPrecondition, "exchange size is not available")
+			}
+			item.ExchangeProductId, item.ExchangeSizeId = variant.ProductId, variant.SizeId
+			p := pair{variant.ProductId, variant.SizeId}
+			holds[p] = holds[p].Add(decimal.NewFromInt(int64(item.Quantity)))
+			stock[p] = variant.Quantity
+		}
+		in.Items = append(in.Items, item)
+	}
+	if err := entity.ValidateReturnRequestInsert(in); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	session := entity.ReturnHoldSession(orderFull.Order.UUID)
+	for p, qty := range holds {
+		available, rerr := s.reservationMgr.ReserveIfAvailable(ctx, stock[p], session, p.productID, p.sizeID, qty)
+		if rerr != nil || available.LessThan(qty) {
+			if rerr != nil {
+				slog.Default().WarnContext(ctx, "failed to hold exchange stock",
+					slog.String("order_uuid", orderFull.Order.UUID),
+					slog.String("err", rerr.Error()),
+				)
+			}
+			s.reservationMgr.ReleaseSession(ctx, session)
+			return nil, status.Errorf(codes.FailedPrecondition, "exchange size is not available")
+		}
+	}
+
+	rr, err := s.repo.Returns().CreateReturnRequest(ctx, orderFull.Order.Id, in)
+	// The request either allocated the replacement or failed; the hold is done either way.
+	s.reservationMgr.ReleaseSession(ctx, session)
+	if err != nil {
+		var ve *entity.ValidationError
+		if errors.As(err, &ve) {
+			return nil, status.Error(codes.InvalidArgument, ve.Message)
+		}
+		if errors.Is(err, entity.ErrReturnExchangeUnavailable) {
+			return nil, status.Error(codes.FailedPrecondition, "exchange size is not available")
+		}
+		slog.Default().ErrorContext(ctx, "can't create return request",
+			slog.String("err", err.Error()),
+			slog.String("order_uuid", orderFull.Order.UUID),
+		)
+		return nil, status.Errorf(codes.Internal, "can't create return request")
+	}
+
+	slog.Default().InfoContext(ctx, "return requested",
+		slog.String("order_uuid", orderFull.Order.UUID),
+		slog.String("rma_number", rr.RMANumber),
+		slog.String("resolution", string(rr.Resolution)),
+	)
+	return &pb_frontend.RequestReturnResponse{ReturnRequest: dto.ConvertEntityReturnRequestToPb(rr)}, nil
+}
+
+// GetOrderReturns lists the returns of an order, newest first.
+func (s *Server) GetOrderReturns(ctx context.Context, req *pb_frontend.GetOrderReturnsRequest) (*pb_frontend.GetOrderReturnsResponse, error) {
+	orderFull, err := s.orderForReturn(ctx, req.GetOrderUuid(), req.GetB64Email())
+	if err != nil {
+		return nil, err
+	}
+	rrs, err := s.repo.Returns().GetReturnRequestsByOrderId(ctx, orderFull.Order.Id)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get order returns",
+			slog.String("err", err.Error()),
+			slog.String("order_uuid", orderFull.Order.UUID),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get order returns")
+	}
+	return &pb_frontend.GetOrderReturnsResponse{ReturnRequests: dto.ConvertEntityReturnRequestsToPb(rrs)}, nil
+}
+
+// CancelReturn withdraws a return that has not reached the warehouse yet.
+func (s *Server) CancelReturn(ctx context.Context, req *pb_frontend.CancelReturnRequest) (*pb_frontend.CancelReturnResponse, error) {
+	orderFull, err := s.orderForReturn(ctx, req.GetOrderUuid(), req.GetB64Email())
+	if err != nil {
+		return nil, err
+	}
+	rr, err := s.repo.Returns().GetReturnRequestByUUID(ctx, req.GetReturnUuid())
+	if err != nil || rr.OrderId != orderFull.Order.Id {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "return not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't get return request", slog.String("err", err.Error()))
+		return nil, status.Errorf(codes.Internal, "can't get return request")
+	}
+
+	rr, err = s.repo.Returns().CancelReturnRequest(ctx, rr.UUID)
+	if err != nil {
+		if errors.Is(err, entity.ErrReturnRequestTransition) {
+			return nil, status.Error(codes.FailedPrecondition, "return can no longer be cancelled")
+		}
+		slog.Default().ErrorContext(ctx, "can't cancel return request",
+			slog.String("err", err.Error()),
+			slog.String("return_uuid", req.GetReturnUuid()),
+		)
+		return nil, status.Errorf(codes.Internal, "can't cancel return request")
+	}
+	return &pb_frontend.CancelReturnResponse{ReturnRequest: dto.ConvertEntityReturnRequestToPb(rr)}, nil
+}
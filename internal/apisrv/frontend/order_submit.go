@@ -77,6 +77,15 @@ func (s *Server) SubmitOrder(ctx context.Context, req *pb_frontend.SubmitOrderRe
 	// SERVER-SET (not from the request), and drives the purchase block in CreateOrder so tier-gated
 	// products can be shown as locked teasers but never actually bought by an ineligible buyer.
 	orderNew.BuyerTier = s.viewerTier(ctx)
+	// Account store credit is spent from the signed-in account only; a gift card code wins when both
+	// are sent. A guest asking for account credit is rejected rather than silently charged in full.
+	if req.Order.GetApplyStoreCredit() && orderNew.StoreCredit.GiftCardCode == "" {
+		email, err := s.storefrontEmailFromAccess(ctx)
+		if err != nil {
+			return nil, err
+		}
+		orderNew.StoreCredit.AccountEmail = email
+	}
 
 	_, err := v.ValidateStruct(orderNew)
 	if err != nil {
@@ -286,7 +295,7 @@ func (s *Server) SubmitOrder(ctx context.Context, req *pb_frontend.SubmitOrderRe
 	// is governed by the processor's parent context, not this ctx.
 	handler.StartMonitoringPayment(context.WithoutCancel(ctx), order.UUID, orderFull.Payment)
 
-	err = s.repo.Order().UpdateTotalPaymentCurrency(ctx, order.UUID, orderFull.Order.TotalPriceDecimal())
+	err = s.repo.Order().UpdateTotalPaymentCurrency(ctx, order.UUID, orderFull.Order.AmountDue())
 	if err != nil {
 		slog.Default().ErrorContext(ctx, "can't update total payment currency", slog.String("err", err.Error()))
 		return nil, status.Errorf(codes.Internal, "can't update total payment currency")
@@ -308,7 +317,7 @@ func (s *Server) SubmitOrder(ctx context.Context, req *pb_frontend.SubmitOrderRe
 		// For zero-decimal currencies (like JPY, KRW), amount is already in decimal
 		// For other currencies, convert from cents
 		piAmount := stripe.AmountFromSmallestUnit(stripePi.Amount, string(stripePi.Currency))
-		orderTotal := orderFull.Order.TotalPriceDecimal()
+		orderTotal := orderFull.Order.AmountDue() // the card pays what store credit left over
 
 		// Check if amounts match (with small tolerance for rounding)
 		if !piAmount.Equal(orderTotal) {
@@ -352,6 +361,20 @@ func (s *Server) SubmitOrder(ctx context.Context, req *pb_frontend.SubmitOrderRe
 		}
 	}
 
+	// An unpaid order of a customer who opted into marketing email is an abandoned checkout until
+	// it is paid; the recovery worker holds it while the payment is pending.
+	if receivePromo && !paymentAlreadySucceeded {
+		email, accountID := s.checkoutRecoveryIdentity(ctx, orderNew.Buyer.Email)
+		s.recordAbandonedCheckout(ctx, &entity.CheckoutRecoveryCart{
+			Email:     email,
+			AccountId: accountID,
+			OrderId:   order.Id,
+			Currency:  orderFull.Order.Currency,
+			Source:    entity.CheckoutRecoverySourceSubmit,
+			Lines:     checkoutRecoveryLines(orderFull.OrderItems),
+		})
+	}
+
 	pi := &orderFull.Payment.PaymentInsert
 
 	// Fetch order from DB for response (status may have changed to AwaitingPayment after InsertFiatInvoice)
@@ -0,0 +1,120 @@
+package frontend
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/search"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSearchQueryRunes bounds a search text; nobody types more, and the typo matching is per word.
+const maxSearchQueryRunes = 200
+
+// SearchColorways ranks the storefront catalogue against a free-text query, with the paged listing's
+// filters and facet counts. The index answers with ids; the colourways are loaded like any listing.
+func (s *Server) SearchColorways(ctx context.Context, req *pb_frontend.SearchColorwaysRequest) (*pb_frontend.SearchColorwaysResponse, error) {
+	if s.search == nil {
+		return nil, searchError(ctx, "search colourways", search.ErrIndexNotReady)
+	}
+	if utf8.RuneCountInString(req.Query) > maxSearchQueryRunes {
+		return nil, status.Errorf(codes.InvalidArgument, "query is longer than %d characters", maxSearchQueryRunes)
+	}
+	fc, err := dto.ConvertPBCommonFilterConditionsToEntity(req.FilterConditions)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if fc == nil {
+		fc = &entity.FilterConditions{}
+	}
+	// Tier gating: the same server-set viewer tier the paged listing uses.
+	fc.ViewerTier = s.viewerTier(ctx)
+
+	limit, offset := clampPagination(int(req.Limit), int(req.Offset), 30, 100)
+
+	res, err := s.search.Search(entity.SearchQuery{Text: req.Query, Filters: *fc, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, searchError(ctx, "search colourways", err)
+	}
+
+	ids := make([]int, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		ids = append(ids, h.ProductId)
+	}
+	prds, err := s.repo.Products().GetProductsByIds(ctx, ids)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get searched products",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Errorf(codes.Internal, "can't search colourways")
+	}
+
+	prdsPb := make([]*pb_frontend.StorefrontColorway, 0, len(prds))
+	for i := range prds {
+		// The index trails a write by up to one indexer interval: a colourway hidden from this viewer
+		// since then is dropped here rather than leaked (GetProductsByIds already drops non-ACTIVE ones).
+		if prds[i].HiddenForNonQualified() && !entity.TierCanPurchase(fc.ViewerTier, prds[i].MinTier()) {
+			continue
+		}
+		prdsPb = append(prdsPb, dto.StorefrontColorwayFromColorway(&prds[i], fc.ViewerTier))
+	}
+
+	return &pb_frontend.SearchColorwaysResponse{
+		Colorways:   prdsPb,
+		Total:       int32(res.Total),
+		Facets:      dto.SearchFacetsToPb(res.Facets),
+		Corrections: dto.SearchCorrectionsToPb(res.Corrections),
+	}, nil
+}
+
+// SuggestColorways completes a partly typed search: completed queries first, then matching colourways.
+func (s *Server) SuggestColorways(ctx context.Context, req *pb_frontend.SuggestColorwaysRequest) (*pb_frontend.SuggestColorwaysResponse, error) {
+	if s.search == nil {
+		return nil, searchError(ctx, "suggest colourways", search.ErrIndexNotReady)
+	}
+	if utf8.RuneCountInString(req.Query) > maxSearchQueryRunes {
+		return nil, status.Errorf(codes.InvalidArgument, "query is longer than %d characters", maxSearchQueryRunes)
+	}
+	limit, _ := clampPagination(int(req.Limit), 0, 8, 20)
+
+	langs := cache.GetLanguages()
+	var langID int
+	for _, l := range langs {
+		if strings.EqualFold(l.Code, strings.TrimSpace(req.Language)) {
+			langID = l.Id
+			break
+		}
+	}
+
+	ss, err := s.search.Suggest(entity.SuggestQuery{
+		Text:       req.Query,
+		LanguageId: langID,
+		Languages:  langs,
+		ViewerTier: s.viewerTier(ctx),
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, searchError(ctx, "suggest colourways", err)
+	}
+	return &pb_frontend.SuggestColorwaysResponse{Suggestions: dto.SearchSuggestionsToPb(ss)}, nil
+}
+
+func searchError(ctx context.Context, op string, err error) error {
+	switch {
+	case errors.Is(err, search.ErrInvalidQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, search.ErrIndexNotReady):
+		// Only until the first index is built after a start (or when no indexer is wired).
+		return status.Error(codes.Unavailable, "search is starting up; try again shortly")
+	}
+	slog.Default().ErrorContext(ctx, "search call failed", slog.String("op", op), slog.String("err", err.Error()))
+	return status.Error(codes.Internal, "can't "+op+"; try again")
+}
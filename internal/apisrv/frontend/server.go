@@ -5,7 +5,6 @@ import (
 
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/ratelimit"
-	"github.com/jekabolt/grbpwr-manager/internal/stockreserve"
 	"github.com/jekabolt/grbpwr-manager/internal/storefront"
 	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
 )
@@ -34,7 +33,8 @@ type Server struct {
 	stripePaymentTest dependency.Invoicer
 	re                dependency.RevalidationService
 	rateLimiter       *ratelimit.MultiKeyLimiter
-	reservationMgr    *stockreserve.Manager
+	reservationMgr    dependency.StockReservationManager
+	search            dependency.ProductSearch
 	storefront        *storefrontAuthRuntime
 }
 
@@ -45,7 +45,8 @@ func New(
 	stripePayment dependency.Invoicer,
 	stripePaymentTest dependency.Invoicer,
 	re dependency.RevalidationService,
-	reservationMgr *stockreserve.Manager,
+	reservationMgr dependency.StockReservationManager,
+	search dependency.ProductSearch,
 	storefrontCfg *storefront.Config,
 ) (*Server, error) {
 	// Set reservation manager on stripe processors if they support it
@@ -73,6 +74,7 @@ func New(
 		re:                re,
 		rateLimiter:       ratelimit.NewMultiKeyLimiter(),
 		reservationMgr:    reservationMgr,
+		search:            search,
 		storefront:        sa,
 	}, nil
 }
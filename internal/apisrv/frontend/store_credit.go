@@ -0,0 +1,74 @@
+package frontend
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/middleware"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetOrderGiftCards returns the gift cards a paid order issued, codes included. The buyer proves
+// ownership with (order_uuid, b64_email), as for returns.
+func (s *Server) GetOrderGiftCards(ctx context.Context, req *pb_frontend.GetOrderGiftCardsRequest) (*pb_frontend.GetOrderGiftCardsResponse, error) {
+	orderFull, err := s.orderForReturn(ctx, req.GetOrderUuid(), req.GetB64Email())
+	if err != nil {
+		return nil, err
+	}
+	ws, err := s.repo.StoreCredit().GetWalletsBySourceOrder(ctx, orderFull.Order.Id)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get order gift cards",
+			slog.String("err", err.Error()),
+			slog.String("order_uuid", orderFull.Order.UUID),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get order gift cards")
+	}
+	return &pb_frontend.GetOrderGiftCardsResponse{GiftCards: dto.ConvertEntityStoreCreditBalancesToPb(ws)}, nil
+}
+
+// GetGiftCardBalance looks a gift card up by code. Rate limited per IP: the code is the only
+// secret, so unlimited lookups would let codes be enumerated.
+func (s *Server) GetGiftCardBalance(ctx context.Context, req *pb_frontend.GetGiftCardBalanceRequest) (*pb_frontend.GetGiftCardBalanceResponse, error) {
+	clientIP := middleware.GetClientIP(ctx)
+	if err := s.rateLimiter.CheckOrderInvoiceIP(clientIP); err != nil {
+		slog.Default().WarnContext(ctx, "rate limit exceeded for gift card balance",
+			slog.String("ip", clientIP),
+		)
+		return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	code := entity.NormalizeGiftCardCode(req.GetCode())
+	if code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+	w, err := s.repo.StoreCredit().GetWalletByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "gift card not found")
+		}
+		slog.Default().ErrorContext(ctx, "can't get gift card",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Errorf(codes.Internal, "can't get gift card")
+	}
+	return &pb_frontend.GetGiftCardBalanceResponse{GiftCard: dto.ConvertEntityStoreCreditBalanceToPb(w)}, nil
+}
+
+// GetStoreCreditBalance returns the signed-in customer's store credit, one balance per currency.
+func (s *Server) GetStoreCreditBalance(ctx context.Context, _ *pb_frontend.GetStoreCreditBalanceRequest) (*pb_frontend.GetStoreCreditBalanceResponse, error) {
+	email, err := s.storefrontEmailFromAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := s.repo.StoreCredit().GetAccountWallets(ctx, email)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get store credit balance", slog.String("err", err.Error()))
+		return nil, status.Error(codes.Internal, "can't get store credit balance")
+	}
+	return &pb_frontend.GetStoreCreditBalanceResponse{Balances: dto.ConvertEntityStoreCreditBalancesToPb(ws)}, nil
+}
@@ -0,0 +1,215 @@
+package frontend
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ListWishlist lists the signed-in account's wishlist. Items whose colourway left the storefront
+// stay stored (they come back with it) but are not shown.
+func (s *Server) ListWishlist(ctx context.Context, _ *pb_frontend.ListWishlistRequest) (*pb_frontend.ListWishlistResponse, error) {
+	aid, tier, err := s.signedInAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.wishlistItemsPb(ctx, aid, tier)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_frontend.ListWishlistResponse{Items: items}, nil
+}
+
+// AddWishlistItem adds a colourway, or one size of it, to the wishlist. Adding an item already
+// there is a no-op.
+func (s *Server) AddWishlistItem(ctx context.Context, req *pb_frontend.AddWishlistItemRequest) (*pb_frontend.AddWishlistItemResponse, error) {
+	aid, tier, err := s.signedInAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := wishlistRef(req.GetItem().GetBaseSku(), req.GetItem().GetVariantSku())
+	if err != nil {
+		return nil, err
+	}
+	targets, err := s.repo.Wishlists().ResolveWishlistRefs(ctx, []entity.WishlistRef{ref})
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't resolve wishlist item",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't add wishlist item")
+	}
+	targets = visibleWishlistTargets(targets, tier)
+	if len(targets) == 0 {
+		// Same answer for unknown and tier-hidden colourways, so the call can't probe hidden SKUs.
+		return nil, status.Error(codes.NotFound, "item not found")
+	}
+	if _, err := s.repo.Wishlists().AddWishlistItems(ctx, aid, targets); err != nil {
+		if errors.Is(err, entity.ErrWishlistFull) {
+			return nil, status.Errorf(codes.ResourceExhausted, "wishlist holds at most %d items", entity.MaxWishlistItems)
+		}
+		slog.Default().ErrorContext(ctx, "can't add wishlist item",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't add wishlist item")
+	}
+	return &pb_frontend.AddWishlistItemResponse{}, nil
+}
+
+// RemoveWishlistItem removes a colourway (base_sku alone) or one size of it (variant_sku).
+func (s *Server) RemoveWishlistItem(ctx context.Context, req *pb_frontend.RemoveWishlistItemRequest) (*pb_frontend.RemoveWishlistItemResponse, error) {
+	aid, _, err := s.signedInAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := wishlistRef(req.BaseSku, req.VariantSku)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Wishlists().RemoveWishlistItem(ctx, aid, ref); err != nil {
+		if errors.Is(err, entity.ErrWishlistItemNotFound) {
+			return nil, status.Error(codes.NotFound, "item is not on the wishlist")
+		}
+		slog.Default().ErrorContext(ctx, "can't remove wishlist item",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't remove wishlist item")
+	}
+	return &pb_frontend.RemoveWishlistItemResponse{}, nil
+}
+
+// MergeWishlist adds the guest wishlist the storefront kept before login to the account's. Guest
+// items that no longer resolve (or that the account's tier may not see) are dropped and counted;
+// a full wishlist keeps what fit and reports it rather than failing the login flow.
+func (s *Server) MergeWishlist(ctx context.Context, req *pb_frontend.MergeWishlistRequest) (*pb_frontend.MergeWishlistResponse, error) {
+	aid, tier, err := s.signedInAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Items) > entity.MaxWishlistItems {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d items can be merged", entity.MaxWishlistItems)
+	}
+
+	refs := make([]entity.WishlistRef, 0, len(req.Items))
+	for _, it := range req.Items {
+		ref, err := wishlistRef(it.GetBaseSku(), it.GetVariantSku())
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	var targets []entity.WishlistTarget
+	if len(refs) > 0 {
+		targets, err = s.repo.Wishlists().ResolveWishlistRefs(ctx, refs)
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "can't resolve guest wishlist",
+				slog.String("err", err.Error()),
+			)
+			return nil, status.Error(codes.Internal, "can't merge wishlist")
+		}
+		targets = visibleWishlistTargets(targets, tier)
+	}
+
+	full := false
+	if len(targets) > 0 {
+		if _, err := s.repo.Wishlists().AddWishlistItems(ctx, aid, targets); err != nil {
+			if !errors.Is(err, entity.ErrWishlistFull) {
+				slog.Default().ErrorContext(ctx, "can't merge guest wishlist",
+					slog.String("err", err.Error()),
+				)
+				return nil, status.Error(codes.Internal, "can't merge wishlist")
+			}
+			full = true
+		}
+	}
+
+	items, err := s.wishlistItemsPb(ctx, aid, tier)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_frontend.MergeWishlistResponse{
+		Items:   items,
+		Skipped: int32(len(refs) - len(targets)),
+		Full:    full,
+	}, nil
+}
+
+// wishlistRef validates a storefront item reference: a base SKU, a variant SKU, or both.
+func wishlistRef(baseSKU, variantSKU string) (entity.WishlistRef, error) {
+	if baseSKU == "" && variantSKU == "" {
+		return entity.WishlistRef{}, status.Error(codes.InvalidArgument, "base_sku or variant_sku is required")
+	}
+	return entity.WishlistRef{BaseSKU: baseSKU, VariantSKU: variantSKU}, nil
+}
+
+// visibleWishlistTargets drops the targets whose colourway is hidden from the viewer's tier.
+func visibleWishlistTargets(targets []entity.WishlistTarget, tier int16) []entity.WishlistTarget {
+	out := targets[:0]
+	for _, t := range targets {
+		if t.HiddenForNonQualified && !entity.TierCanPurchase(tier, t.MinTier) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// wishlistItemsPb loads the account's wishlist with the storefront projection of each colourway.
+func (s *Server) wishlistItemsPb(ctx context.Context, accountID int, tier int16) ([]*pb_frontend.WishlistItem, error) {
+	items, err := s.repo.Wishlists().ListWishlistItems(ctx, accountID)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't list wishlist",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't list wishlist")
+	}
+	if len(items) == 0 {
+		return []*pb_frontend.WishlistItem{}, nil
+	}
+
+	ids := make([]int, 0, len(items))
+	seen := make(map[int]bool, len(items))
+	for _, it := range items {
+		if !seen[it.ProductId] {
+			seen[it.ProductId] = true
+			ids = append(ids, it.ProductId)
+		}
+	}
+	// GetProductsByIds drops non-ACTIVE colourways, which is what hides them here.
+	prds, err := s.repo.Products().GetProductsByIds(ctx, ids)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't get wishlist products",
+			slog.String("err", err.Error()),
+		)
+		return nil, status.Error(codes.Internal, "can't list wishlist")
+	}
+	colorways := make(map[int]*pb_frontend.StorefrontColorway, len(prds))
+	for i := range prds {
+		if prds[i].HiddenForNonQualified() && !entity.TierCanPurchase(tier, prds[i].MinTier()) {
+			continue
+		}
+		colorways[prds[i].Id] = dto.StorefrontColorwayFromColorway(&prds[i], tier)
+	}
+
+	out := make([]*pb_frontend.WishlistItem, 0, len(items))
+	for _, it := range items {
+		cw, ok := colorways[it.ProductId]
+		if !ok {
+			continue
+		}
+		// A size-pinned item whose variant is gone from the colourway shows as the colourway.
+		out = append(out, &pb_frontend.WishlistItem{
+			Colorway:   cw,
+			VariantSku: it.VariantSKU,
+			AddedAt:    timestamppb.New(it.CreatedAt),
+		})
+	}
+	return out, nil
+}
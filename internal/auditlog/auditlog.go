@@ -0,0 +1,129 @@
+// Package auditlog records the admin-wide audit trail: every call of a mutating admin.AdminService
+// method — rbac write access, or an allowlisted write — is written to admin_audit_event with its
+// actor, method, rbac section, target entity, outcome and the redacted request and response.
+//
+// The interceptor runs after the auth interceptor, so a call refused for lack of rights never
+// reaches it and is not an event; a call the handler rejects is. Recording never fails the call: an
+// insert error is logged and the handler's answer is returned as is. The HTTP multipart endpoints
+// (library files, media uploads) are not gRPC and are not covered.
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/rbac"
+)
+
+// insertTimeout bounds the audit insert. It runs detached from the request context, so a client
+// that hangs up after a write still leaves its event behind.
+const insertTimeout = 5 * time.Second
+
+// errorMessageMaxLen is admin_audit_event.error_message VARCHAR(512).
+const errorMessageMaxLen = 512
+
+// Inserter writes one audit event; dependency.Audit satisfies it.
+type Inserter interface {
+	InsertAuditEvent(ctx context.Context, e *entity.AuditEvent) error
+}
+
+// Recorder builds audit events from admin RPC calls.
+type Recorder struct {
+	store Inserter
+	actor func(context.Context) string
+	now   func() time.Time
+}
+
+// NewRecorder returns a recorder writing to store. actor extracts the admin username the auth
+// interceptor put on the context; now is the clock (the repository's Now).
+func NewRecorder(store Inserter, actor func(context.Context) string, now func() time.Time) *Recorder {
+	if now == nil {
+		now = time.Now
+	}
+	if actor == nil {
+		actor = func(context.Context) string { return "" }
+	}
+	return &Recorder{store: store, actor: actor, now: now}
+}
+
+// UnaryServerInterceptor records every mutating admin call. Chain it after the auth interceptor.
+func (r *Recorder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !rbac.IsWrite(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		start := r.now()
+		resp, err := handler(ctx, req)
+		r.record(ctx, info.FullMethod, req, resp, err, start)
+		return resp, err
+	}
+}
+
+func (r *Recorder) record(ctx context.Context, fullMethod string, req, resp any, callErr error, start time.Time) {
+	e := r.event(ctx, fullMethod, req, resp, callErr, start)
+	insCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), insertTimeout)
+	defer cancel()
+	if err := r.store.InsertAuditEvent(insCtx, e); err != nil {
+		slog.Default().ErrorContext(ctx, "audit: can't record admin call",
+			slog.String("method", e.Method),
+			slog.String("actor", e.Actor),
+			slog.String("err", err.Error()),
+		)
+	}
+}
+
+// event builds the row for one call.
+func (r *Recorder) event(ctx context.Context, fullMethod string, req, resp any, callErr error, start time.Time) *entity.AuditEvent {
+	method := strings.TrimPrefix(fullMethod, rbac.MethodPrefix)
+	requirement, _, _ := rbac.Lookup(fullMethod)
+
+	reqV, err := decode(req)
+	if err != nil {
+		reqV = map[string]any{"_error": err.Error()}
+	}
+	reqV = sanitize(reqV)
+
+	var respV any
+	if callErr == nil {
+		if respV, err = decode(resp); err != nil {
+			respV = map[string]any{"_error": err.Error()}
+		}
+		respV = sanitize(respV)
+	}
+
+	typ := targetType(method)
+	e := &entity.AuditEvent{
+		OccurredAt: start.UTC(),
+		Actor:      r.actor(ctx),
+		Method:     method,
+		Section:    requirement.Section,
+		TargetType: typ,
+		StatusCode: status.Code(callErr).String(),
+		DurationMs: int(r.now().Sub(start).Milliseconds()),
+		Request:    encode(reqV),
+	}
+	if id := targetID(typ, reqV, respV); id != "" {
+		e.TargetId = sql.NullString{String: id, Valid: true}
+	}
+	if callErr != nil {
+		e.ErrorMessage = sql.NullString{String: truncate(status.Convert(callErr).Message(), errorMessageMaxLen), Valid: true}
+	} else if respV != nil {
+		e.Response = sql.NullString{String: encode(respV), Valid: true}
+	}
+	return e
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,193 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/rbac"
+)
+
+type fakeStore struct {
+	events []*entity.AuditEvent
+	err    error
+}
+
+func (f *fakeStore) InsertAuditEvent(_ context.Context, e *entity.AuditEvent) error {
+	f.events = append(f.events, e)
+	return f.err
+}
+
+func mustStruct(t *testing.T, m map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	require.NoError(t, err)
+	return s
+}
+
+func call(t *testing.T, r *Recorder, method string, req any, resp any, callErr error) (any, error) {
+	t.Helper()
+	info := &grpc.UnaryServerInfo{FullMethod: rbac.MethodPrefix + method}
+	return r.UnaryServerInterceptor()(context.Background(), req, info, func(context.Context, any) (any, error) {
+		return resp, callErr
+	})
+}
+
+func TestInterceptorRecordsWrites(t *testing.T) {
+	store := &fakeStore{}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	r := NewRecorder(store, func(context.Context) string { return "anna" }, func() time.Time { return now })
+
+	req := mustStruct(t, map[string]any{"username": "bob", "password": "hunter2"})
+	resp := mustStruct(t, map[string]any{"account": map[string]any{"username": "bob"}})
+	got, err := call(t, r, "CreateAccount", req, resp, nil)
+	require.NoError(t, err)
+	assert.Same(t, resp, got)
+
+	require.Len(t, store.events, 1)
+	e := store.events[0]
+	assert.Equal(t, now, e.OccurredAt)
+	assert.Equal(t, "anna", e.Actor)
+	assert.Equal(t, "CreateAccount", e.Method)
+	assert.Equal(t, rbac.SectionAccounts, e.Section)
+	assert.Equal(t, "Account", e.TargetType)
+	assert.Equal(t, "bob", e.TargetId.String)
+	assert.Equal(t, "OK", e.StatusCode)
+	assert.False(t, e.ErrorMessage.Valid)
+	assert.NotContains(t, e.Request, "hunter2")
+	assert.Contains(t, e.Request, redacted)
+	assert.True(t, e.Response.Valid)
+}
+
+func TestInterceptorSkipsReads(t *testing.T) {
+	store := &fakeStore{}
+	r := NewRecorder(store, nil, nil)
+	_, err := call(t, r, "ListAccounts", mustStruct(t, nil), mustStruct(t, nil), nil)
+	require.NoError(t, err)
+	_, err = call(t, r, "GetDictionary", mustStruct(t, nil), mustStruct(t, nil), nil)
+	require.NoError(t, err)
+	assert.Empty(t, store.events)
+}
+
+func TestInterceptorRecordsAllowlistedWrite(t *testing.T) {
+	store := &fakeStore{}
+	r := NewRecorder(store, nil, nil)
+	_, err := call(t, r, "SetAccountSpecialties", mustStruct(t, map[string]any{"username": "bob"}), mustStruct(t, nil), nil)
+	require.NoError(t, err)
+	require.Len(t, store.events, 1)
+	assert.Equal(t, "", store.events[0].Section)
+}
+
+func TestInterceptorRecordsFailureWithoutResponse(t *testing.T) {
+	store := &fakeStore{}
+	r := NewRecorder(store, nil, nil)
+	callErr := status.Error(codes.NotFound, "account not found")
+	_, err := call(t, r, "DeleteAccount", mustStruct(t, map[string]any{"username": "bob"}), nil, callErr)
+	assert.Equal(t, callErr, err)
+
+	require.Len(t, store.events, 1)
+	e := store.events[0]
+	assert.Equal(t, "NotFound", e.StatusCode)
+	assert.Equal(t, "account not found", e.ErrorMessage.String)
+	assert.False(t, e.Response.Valid)
+}
+
+func TestInterceptorInsertFailureDoesNotFailCall(t *testing.T) {
+	store := &fakeStore{err: errors.New("db down")}
+	r := NewRecorder(store, nil, nil)
+	resp := mustStruct(t, nil)
+	got, err := call(t, r, "DeleteAccount", mustStruct(t, map[string]any{"username": "bob"}), resp, nil)
+	require.NoError(t, err)
+	assert.Same(t, resp, got)
+}
+
+func TestSanitizeRedactsSecretKeys(t *testing.T) {
+	v := sanitize(map[string]any{
+		"new_password":   "a",
+		"refreshToken":   "b",
+		"webhook_secret": "c",
+		"stripe_api_key": "d",
+		"invite_url":     "https://x/i/tok",
+		"footprint":      "kept",
+		"nested":         map[string]any{"otp": "123456", "name": "kept"},
+		"list":           []any{map[string]any{"card_number": "4242"}},
+	}).(map[string]any)
+
+	for _, k := range []string{"new_password", "refreshToken", "webhook_secret", "stripe_api_key", "invite_url"} {
+		assert.Equal(t, redacted, v[k], k)
+	}
+	assert.Equal(t, "kept", v["footprint"])
+	assert.Equal(t, redacted, v["nested"].(map[string]any)["otp"])
+	assert.Equal(t, "kept", v["nested"].(map[string]any)["name"])
+	assert.Equal(t, redacted, v["list"].([]any)[0].(map[string]any)["card_number"])
+}
+
+func TestSanitizeCutsLongValues(t *testing.T) {
+	long := strings.Repeat("я", maxStringRunes+10)
+	list := make([]any, maxListItems+5)
+	for i := range list {
+		list[i] = float64(i)
+	}
+	v := sanitize(map[string]any{"body": long, "ids": list}).(map[string]any)
+
+	assert.Equal(t, strings.Repeat("я", maxStringRunes)+"…[+10 chars]", v["body"])
+	ids := v["ids"].([]any)
+	require.Len(t, ids, maxListItems+1)
+	assert.Equal(t, "…[+5 items]", ids[maxListItems])
+}
+
+func TestEncodeCapsPayload(t *testing.T) {
+	list := make([]any, maxListItems)
+	for i := range list {
+		list[i] = strings.Repeat("x", maxStringRunes)
+	}
+	out := encode(map[string]any{"a": list})
+	var m map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &m))
+	assert.Equal(t, true, m["_truncated"])
+	assert.Equal(t, "{}", encode(nil))
+}
+
+func TestTargetType(t *testing.T) {
+	for method, want := range map[string]string{
+		"UpsertProduct":      "Product",
+		"SetAccountDisabled": "AccountDisabled",
+		"BulkUpdateProducts": "Products",
+		"Logout":             "Logout",
+	} {
+		assert.Equal(t, want, targetType(method), method)
+	}
+}
+
+func TestTargetID(t *testing.T) {
+	cases := []struct {
+		name      string
+		typ       string
+		req, resp any
+		want      string
+	}{
+		{"request id", "Product", map[string]any{"id": float64(12), "category_id": float64(3)}, nil, "12"},
+		{"typed id", "Supplier", map[string]any{"supplier_id": "77"}, nil, "77"},
+		{"create learns id from response", "Product",
+			map[string]any{"category_id": float64(3)}, map[string]any{"id": float64(40)}, "40"},
+		{"nested object", "Supplier",
+			map[string]any{"supplier": map[string]any{"id": float64(5), "name": "Acme"}}, nil, "5"},
+		{"any other id last", "BankTxnIgnored", map[string]any{"txn_id": "9", "reason": "fee"}, nil, "9"},
+		{"zero is no id", "Product", map[string]any{"id": float64(0)}, map[string]any{}, ""},
+		{"uuid", "OrderRefund", map[string]any{"order_uuid": "ab-cd"}, nil, "ab-cd"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, targetID(c.typ, c.req, c.resp), c.name)
+	}
+}
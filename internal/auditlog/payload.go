@@ -0,0 +1,272 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Payload limits. A string past maxStringRunes is cut (an uploaded image arrives as base64, a
+// tech-card note can be pages long); a list past maxListItems keeps its head; a payload that still
+// serialises past maxPayloadBytes is replaced by a marker with its size. The trail records what
+// changed, not a copy of every upload.
+const (
+	maxStringRunes  = 1024
+	maxListItems    = 100
+	maxPayloadBytes = 64 << 10
+)
+
+// redacted replaces the value of a secret-bearing field.
+const redacted = "[REDACTED]"
+
+// secretWords are key words whose field never reaches the trail, whatever the message: a key is
+// split into its snake/camel words and one of these anywhere redacts it (new_password, refreshToken,
+// webhook_secret).
+var secretWords = map[string]bool{
+	"password":    true,
+	"passwd":      true,
+	"secret":      true,
+	"token":       true,
+	"otp":         true,
+	"totp":        true,
+	"cvc":         true,
+	"cvv":         true,
+	"credential":  true,
+	"credentials": true,
+}
+
+// secretPhrases are multi-word keys redacted as a whole. invite_url carries the raw hacker-invite
+// token (GenerateHackerInvite answers with it exactly once).
+var secretPhrases = []string{"api_key", "apikey", "private_key", "card_number", "invite_url"}
+
+// isSecretKey reports whether a JSON key names a secret-bearing field.
+func isSecretKey(key string) bool {
+	snake := toSnake(key)
+	for _, w := range strings.Split(snake, "_") {
+		if secretWords[w] {
+			return true
+		}
+	}
+	for _, p := range secretPhrases {
+		if strings.Contains(snake, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// toSnake lowercases key into snake_case; protojson with UseProtoNames already is, google.protobuf.Struct
+// keys and map keys need not be.
+func toSnake(key string) string {
+	var b strings.Builder
+	prevLower := false
+	for _, r := range key {
+		if unicode.IsUpper(r) {
+			if prevLower {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			prevLower = false
+			continue
+		}
+		if r == '-' || r == ' ' {
+			r = '_'
+		}
+		b.WriteRune(r)
+		prevLower = unicode.IsLower(r) || unicode.IsDigit(r)
+	}
+	return b.String()
+}
+
+// decode renders m as the generic JSON value the trail works on: protojson with proto field names,
+// unpopulated fields omitted. A nil message or a non-proto value decodes to nil.
+func decode(m any) (any, error) {
+	pm, ok := m.(proto.Message)
+	if !ok || pm == nil {
+		return nil, nil
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(pm)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %T: %w", m, err)
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("decode %T: %w", m, err)
+	}
+	return v, nil
+}
+
+// sanitize redacts secrets and cuts long strings and lists, recursively. It rewrites v in place.
+func sanitize(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if isSecretKey(k) {
+				t[k] = redacted
+				continue
+			}
+			t[k] = sanitize(val)
+		}
+		return t
+	case []any:
+		if len(t) > maxListItems {
+			rest := len(t) - maxListItems
+			t = append(t[:maxListItems:maxListItems], fmt.Sprintf("…[+%d items]", rest))
+		}
+		for i := range t {
+			t[i] = sanitize(t[i])
+		}
+		return t
+	case string:
+		if n := utf8.RuneCountInString(t); n > maxStringRunes {
+			r := []rune(t)
+			return string(r[:maxStringRunes]) + fmt.Sprintf("…[+%d chars]", n-maxStringRunes)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// encode serialises a sanitized value for the JSON column. nil encodes as the empty object, so a
+// request column is never NULL.
+func encode(v any) string {
+	if v == nil {
+		return "{}"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	if len(b) > maxPayloadBytes {
+		b, _ = json.Marshal(map[string]any{"_truncated": true, "_bytes": len(b)})
+	}
+	return string(b)
+}
+
+// targetIDMaxLen is admin_audit_event.target_id VARCHAR(64).
+const targetIDMaxLen = 64
+
+// targetType names the entity a method acts on: the method name without its leading verb
+// (UpsertProduct → Product, SetAccountDisabled → AccountDisabled). Bulk/Batch is part of the verb.
+func targetType(method string) string {
+	words := camelWords(method)
+	if len(words) < 2 {
+		return method
+	}
+	skip := 1
+	if (words[0] == "Bulk" || words[0] == "Batch") && len(words) > 2 {
+		skip = 2
+	}
+	return strings.Join(words[skip:], "")
+}
+
+// camelWords splits an UpperCamel name into its words.
+func camelWords(s string) []string {
+	var words []string
+	start := 0
+	for i, r := range s {
+		if i > 0 && unicode.IsUpper(r) {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// targetID finds the id of the target in the sanitized request, then in the response (a create
+// learns its id only there). The keys tried, in order: id, uuid, <type>_id, <type>_uuid and username
+// at the top level; then the id/uuid of a top-level object (UpsertSupplier{supplier:{id}}); last any
+// top-level *_id/*_uuid of the request, alphabetically. Zero and empty values do not count.
+func targetID(typ string, req, resp any) string {
+	snake := toSnake(typ)
+	exact := []string{"id", "uuid", snake + "_id", snake + "_uuid", "username"}
+	for _, v := range []any{req, resp} {
+		if id := exactID(v, exact); id != "" {
+			return id
+		}
+	}
+	for _, v := range []any{req, resp} {
+		if id := nestedID(v); id != "" {
+			return id
+		}
+	}
+	m, ok := req.(map[string]any)
+	if !ok {
+		return ""
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if strings.HasSuffix(k, "_id") || strings.HasSuffix(k, "_uuid") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if id := idString(m[k]); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func exactID(v any, keys []string) string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return ""
+	}
+	for _, k := range keys {
+		if id := idString(m[k]); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func nestedID(v any) string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return ""
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if id := exactID(m[k], []string{"id", "uuid"}); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// idString formats an id value: a JSON number (int32 ids) or a string (int64 ids and uuids).
+func idString(v any) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = strings.TrimSpace(t)
+	case float64:
+		s = strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return ""
+	}
+	if s == "" || s == "0" || s == redacted {
+		return ""
+	}
+	if len(s) > targetIDMaxLen {
+		s = s[:targetIDMaxLen]
+	}
+	return s
+}
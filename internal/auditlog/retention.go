@@ -0,0 +1,194 @@
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/health"
+	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+)
+
+// tickTimeout bounds the purge done in a single tick, so one stuck delete can't block the loop
+// forever or stall graceful shutdown. What a tick leaves behind the next one takes.
+const tickTimeout = 30 * time.Second
+
+// purgeBatch is the number of rows one DELETE removes.
+const purgeBatch = 5000
+
+// Backoff bounds for consecutive-failure backoff; see storefrontcleanup for the pattern.
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 5 * time.Minute
+)
+
+// Config holds configuration for the audit trail.
+type Config struct {
+	// RetentionDays is how long an event is kept: 0 (unset) is the default year, a negative value
+	// keeps the trail forever.
+	RetentionDays  int           `mapstructure:"retention_days"`
+	WorkerInterval time.Duration `mapstructure:"worker_interval"`
+}
+
+// DefaultConfig returns default configuration values.
+func DefaultConfig() Config {
+	return Config{
+		RetentionDays:  365,
+		WorkerInterval: 24 * time.Hour,
+	}
+}
+
+// Purger deletes expired audit events; dependency.Audit satisfies it.
+type Purger interface {
+	PurgeAuditEvents(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+// RetentionWorker deletes audit events older than the retention window.
+type RetentionWorker struct {
+	store   Purger
+	now     func() time.Time
+	c       *Config
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	tracker health.Tracker
+}
+
+// Name implements health.Reporter.
+func (w *RetentionWorker) Name() string { return "auditretention" }
+
+// LastSuccess implements health.Reporter (zero time until the first clean tick).
+func (w *RetentionWorker) LastSuccess() time.Time { return w.tracker.LastSuccess() }
+
+// NewRetentionWorker creates a new audit retention worker.
+func NewRetentionWorker(c *Config, store Purger, now func() time.Time) *RetentionWorker {
+	if c == nil {
+		dc := DefaultConfig()
+		c = &dc
+	}
+	if c.WorkerInterval == 0 {
+		c.WorkerInterval = 24 * time.Hour
+	}
+	if c.RetentionDays == 0 {
+		c.RetentionDays = DefaultConfig().RetentionDays
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &RetentionWorker{
+		store: store,
+		now:   now,
+		c:     c,
+	}
+}
+
+// Start starts the worker.
+func (w *RetentionWorker) Start(ctx context.Context) error {
+	if w.ctx != nil && w.stop != nil {
+		return fmt.Errorf("audit retention worker already started")
+	}
+	w.ctx, w.stop = context.WithCancel(ctx)
+	w.wg.Go(func() {
+		w.worker(w.ctx)
+	})
+	return nil
+}
+
+// Stop signals the worker to stop and waits for its goroutine to exit.
+func (w *RetentionWorker) Stop() error {
+	if w.stop == nil {
+		return fmt.Errorf("audit retention worker already stopped or not started")
+	}
+	w.stop()
+	w.stop = nil
+	w.wg.Wait()
+	return nil
+}
+
+func (w *RetentionWorker) worker(ctx context.Context) {
+	ticker := time.NewTicker(w.c.WorkerInterval)
+	defer ticker.Stop()
+
+	var consecutiveFailures int
+
+	for {
+		select {
+		case <-ticker.C:
+			if w.runPurge(ctx) {
+				consecutiveFailures = 0
+				continue
+			}
+			consecutiveFailures++
+			delay := backoffDelay(consecutiveFailures)
+			slog.Default().WarnContext(ctx, "audit retention: backing off after failed tick",
+				slog.Int("consecutive_failures", consecutiveFailures),
+				slog.Duration("delay", delay),
+			)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backoffDelay returns the extra inter-iteration delay for the given number of
+// consecutive failures: base * 2^(n-1), capped at backoffMax.
+func backoffDelay(consecutiveFailures int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < consecutiveFailures; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+// runPurge deletes expired events batch by batch until a batch comes back short, and reports
+// whether the tick succeeded. A tick that ran out of time with events left is still a success:
+// the deletes it did are committed and the next tick continues.
+func (w *RetentionWorker) runPurge(ctx context.Context) bool {
+	defer saferun.Recover(ctx, "auditretention")
+
+	if w.c.RetentionDays < 0 {
+		w.tracker.MarkSuccess()
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tickTimeout)
+	defer cancel()
+
+	cutoff := w.now().AddDate(0, 0, -w.c.RetentionDays)
+	var total int64
+	for {
+		n, err := w.store.PurgeAuditEvents(ctx, cutoff, purgeBatch)
+		total += n
+		if err != nil {
+			if ctx.Err() != nil && total > 0 {
+				break
+			}
+			w.tracker.MarkError(err)
+			slog.Default().ErrorContext(ctx, "audit retention: purge failed", slog.String("err", err.Error()))
+			return false
+		}
+		if n < purgeBatch {
+			break
+		}
+	}
+	if total > 0 {
+		slog.Default().InfoContext(ctx, "audit retention: expired events removed",
+			slog.Int64("count", total),
+			slog.Time("cutoff", cutoff),
+		)
+	}
+	w.tracker.MarkSuccess()
+	return true
+}
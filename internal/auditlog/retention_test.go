@@ -0,0 +1,55 @@
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePurger struct {
+	batches []int64
+	err     error
+	cutoffs []time.Time
+}
+
+func (f *fakePurger) PurgeAuditEvents(_ context.Context, cutoff time.Time, limit int) (int64, error) {
+	f.cutoffs = append(f.cutoffs, cutoff)
+	if f.err != nil {
+		return 0, f.err
+	}
+	if len(f.batches) == 0 {
+		return 0, nil
+	}
+	n := f.batches[0]
+	f.batches = f.batches[1:]
+	return n, nil
+}
+
+func TestRunPurgeDrainsBatches(t *testing.T) {
+	now := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	p := &fakePurger{batches: []int64{purgeBatch, purgeBatch, 12}}
+	w := NewRetentionWorker(&Config{RetentionDays: 30}, p, func() time.Time { return now })
+
+	assert.True(t, w.runPurge(context.Background()))
+	assert.Len(t, p.cutoffs, 3)
+	assert.Equal(t, now.AddDate(0, 0, -30), p.cutoffs[0])
+	assert.False(t, w.LastSuccess().IsZero())
+}
+
+func TestRunPurgeDefaultsAndKeepForever(t *testing.T) {
+	w := NewRetentionWorker(&Config{}, &fakePurger{}, nil)
+	assert.Equal(t, 365, w.c.RetentionDays)
+
+	p := &fakePurger{}
+	w = NewRetentionWorker(&Config{RetentionDays: -1}, p, nil)
+	assert.True(t, w.runPurge(context.Background()))
+	assert.Empty(t, p.cutoffs)
+}
+
+func TestRunPurgeReportsFailure(t *testing.T) {
+	w := NewRetentionWorker(&Config{RetentionDays: 30}, &fakePurger{err: errors.New("db down")}, nil)
+	assert.False(t, w.runPurge(context.Background()))
+}
@@ -0,0 +1,45 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// maxMediaReadBytes caps one media read. Картинки медиатеки грузятся с потолком 25 MB (full-size
+// варианты), и читать больше нет причины: всё, что длиннее, — не наша картинка.
+const maxMediaReadBytes = 25 * 1024 * 1024
+
+// ErrMediaURLNotManaged — адрес не на нашем CDN/origin: GetMediaObject отказал, не спрашивая бакет.
+var ErrMediaURLNotManaged = errors.New("bucket: media url is not a managed bucket url")
+
+// GetMediaObject reads a media-library object, addressed by its stored URL, into memory — the PDF
+// spec sheet embeds technical sketches into the file instead of linking to them (a factory prints the
+// sheet, and a link on paper does not render).
+//
+// Гарды — те же, что у DeleteObjects: адрес обязан указывать на сконфигурированный CDN или origin
+// бакета (чужой хост это ОТКАЗ до всякого обращения к клиенту, иначе метод — это server-side fetch
+// по любому url), и читается не больше потолка медиатеки.
+func (b *Bucket) GetMediaObject(ctx context.Context, mediaURL string) ([]byte, error) {
+	key, err := b.managedObjectKeyFromURL(mediaURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMediaURLNotManaged, err)
+	}
+	obj, err := b.Client.GetObject(ctx, b.S3BucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't open media object",
+			slog.String("key", key), slog.String("err", err.Error()))
+		return nil, fmt.Errorf("get media object %q: %w", key, err)
+	}
+	defer obj.Close()
+	data, err := readWithinLimit(obj, maxMediaReadBytes)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't read media object",
+			slog.String("key", key), slog.String("err", err.Error()))
+		return nil, fmt.Errorf("read media object %q: %w", key, err)
+	}
+	return data, nil
+}
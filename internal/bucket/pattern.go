@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 )
@@ -28,6 +29,10 @@ const (
 // failure).
 var ErrInvalidPattern = errors.New("invalid pattern file")
 
+// ErrPatternObjectKeyNotManaged marks a key outside the pattern folder: GetPatternObject refused it
+// before touching the bucket.
+var ErrPatternObjectKeyNotManaged = errors.New("bucket: object key is not a managed pattern key")
+
 // UploadPatternFile stores a raw cut pattern (выкройка) in object storage and returns
 // its CDN url plus the stored byte size. The payload must be a real PDF or DXF (sniffed
 // from the bytes, not the caller-declared type); the sniffed type picks the object
@@ -84,6 +89,31 @@ func (b *Bucket) UploadPatternFile(ctx context.Context, raw []byte, objectName s
 	return b.getCDNURL(fp), int64(len(raw)), nil
 }
 
+// GetPatternObject reads a managed pattern object into memory — the server's own DXF measurement
+// reads the sheets it fingerprints. The same guards as GetLibraryObject: the key must lie under the
+// pattern folder (a foreign key is refused before the bucket is asked), and no more than the upload
+// cap is read, so a pattern row can never be turned into a way to pull an arbitrary object.
+func (b *Bucket) GetPatternObject(ctx context.Context, objectKey string) ([]byte, error) {
+	key := strings.Trim(objectKey, "/")
+	if !isManagedKeyInSegment(key, patternObjectPathSegment) {
+		return nil, fmt.Errorf("%w: %q", ErrPatternObjectKeyNotManaged, objectKey)
+	}
+	obj, err := b.Client.GetObject(ctx, b.S3BucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't open pattern object",
+			slog.String("key", key), slog.String("err", err.Error()))
+		return nil, fmt.Errorf("get pattern object %q: %w", key, err)
+	}
+	defer obj.Close()
+	data, err := readWithinLimit(obj, maxPatternPayloadBytes)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't read pattern object",
+			slog.String("key", key), slog.String("err", err.Error()))
+		return nil, fmt.Errorf("read pattern object %q: %w", key, err)
+	}
+	return data, nil
+}
+
 // isPDF reports whether raw starts with the PDF magic header (%PDF-).
 func isPDF(raw []byte) bool {
 	return len(raw) >= 5 && string(raw[:5]) == "%PDF-"
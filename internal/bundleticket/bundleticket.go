@@ -0,0 +1,315 @@
+// Package bundleticket — ЯРЛЫКИ ПАЧЕК КРОЯ: нарезка настилов на пачки, купоны операций, публичный
+// эндпоинт сканирования (/api/bt/{token}) и WIP прогона.
+//
+// Посадка эндпоинта — копия наряда на партию (internal/runpackaccess), и по той же причине: ярлык
+// сканируют телефоном за швейной машиной, без логина, и токен аутентифицирует сам себя. Любой отказ
+// по ТОКЕНУ (битая подпись, чужой скоуп, лимит, пачки больше нет) — один и тот же голый 404 с
+// причиной только в сэмплированном логе.
+//
+// ОТ НАРЯДА ЭНДПОИНТ ОТЛИЧАЕТСЯ ТЕМ, ЧТО ПИШЕТ. GET отдаёт ярлык (пачка и её купоны с отметками о
+// выполнении), POST записывает выполнение одной операции: {"operation_seq", "employee_id"}. Ответы
+// POST после принятого токена уже не голые: держатель ярлыка — цех, и «такой операции нет», «сотрудник
+// не найден» и «операция уже отмечена» — то, что швея должна прочитать на экране, а не угадывать.
+//
+// СТРОКИ ДОСТУПА НЕТ. Идентичность токена — id пачки (скоуп 'b'), эпоха всегда 1: перегенерация
+// пачек удаляет строки, id не переиспользуются, и ярлык удалённой пачки мёртв без отдельного отзыва.
+// Денег в ответах нет: SMV — минуты, не ставки.
+package bundleticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/middleware"
+	"github.com/jekabolt/grbpwr-manager/internal/patterntoken"
+	"github.com/jekabolt/grbpwr-manager/internal/ratelimit"
+)
+
+// Runs — узкий срез dependency.ProductionRuns, который нужен ярлыку.
+type Runs interface {
+	GetBundle(ctx context.Context, bundleID int) (*entity.ProductionRunBundleSet, error)
+	GetRunPack(ctx context.Context, runID int) (*entity.RunPack, error)
+	RecordBundleScan(ctx context.Context, ins entity.ProductionRunBundleScanInsert) (*entity.ProductionRunBundleScan, error)
+}
+
+const (
+	// tokenEpoch — эпоха каждого токена ярлыка; см. комментарий пакета.
+	tokenEpoch = 1
+
+	// Пер (ip|пачка). Ярлык сканируют на каждой операции, а операций у изделия десятки; бюджета
+	// хватает на повторы и перезагрузки одной пачки.
+	perTokenWindow = time.Minute
+	perTokenMax    = 60
+
+	// Свой бюджет на ip, как у наряда: за ним весь швейный участок с одним NAT.
+	perIPWindow = time.Minute
+	perIPMax    = 1200
+
+	// maxScanBodyBytes — тело POST: два числа.
+	maxScanBodyBytes = 4 << 10
+
+	// deniedLogSample — 1 из N отказов пишется на Info, остальные на Debug.
+	deniedLogSample = 10
+)
+
+// Service минтит токены ярлыков и обслуживает /api/bt/{token}.
+type Service struct {
+	runs   Runs
+	minter *patterntoken.Minter
+	now    func() time.Time
+
+	tokenLimiter *ratelimit.Limiter
+	ipLimiter    *ratelimit.Limiter
+	stopOnce     sync.Once
+
+	deniedSeq atomic.Int64
+}
+
+// New собирает сервис. Пустой pepper — отказ на старте (patterntoken.NewMinter).
+func New(runs Runs, pepper string) (*Service, error) {
+	minter, err := patterntoken.NewMinter(pepper)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		runs:         runs,
+		minter:       minter,
+		now:          time.Now,
+		tokenLimiter: ratelimit.NewLimiter(perTokenWindow, perTokenMax),
+		ipLimiter:    ratelimit.NewLimiter(perIPWindow, perIPMax),
+	}, nil
+}
+
+// Stop останавливает лимитеры (идемпотентно).
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() {
+		s.tokenLimiter.Stop()
+		s.ipLimiter.Stop()
+	})
+}
+
+// MintBundleToken отдаёт токен ярлыка пачки. Безопасен на nil-получателе: ответ без сервиса
+// приезжает без токена, а ярлык — без QR.
+func (s *Service) MintBundleToken(bundleID int) string {
+	if s == nil || bundleID <= 0 {
+		return ""
+	}
+	return s.minter.Mint(patterntoken.ScopeBundle, int64(bundleID), tokenEpoch)
+}
+
+// Handler обслуживает GET/HEAD (ярлык) и POST (сканирование) /api/bt/{token}.
+func (s *Service) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.ServeScan(w, r)
+			return
+		}
+		s.ServeTicket(w, r)
+	})
+}
+
+// Ticket — ярлык пачки, как его видит телефон в цеху.
+type Ticket struct {
+	BundleNo     int    `json:"bundle_no"`
+	RunId        int    `json:"run_id"`
+	StyleNumber  string `json:"style_number"`
+	StyleName    string `json:"style_name"`
+	LayName      string `json:"lay_name"`
+	ColorwayName string `json:"colorway_name"`
+	SizeName     string `json:"size_name"`
+	StackNo      int    `json:"stack_no"`
+	PlyFrom      int    `json:"ply_from"`
+	PlyTo        int    `json:"ply_to"`
+	Qty          int    `json:"qty"`
+	// Coupons — по купону на операцию снимка, в порядке карты.
+	Coupons []TicketCoupon `json:"coupons"`
+}
+
+// TicketCoupon — одна операция пачки. DoneAt пусто, пока операция не отмечена.
+type TicketCoupon struct {
+	OperationSeq    int    `json:"operation_seq"`
+	OperationNumber int    `json:"operation_number,omitempty"`
+	OperationType   string `json:"operation_type"`
+	Zone            string `json:"zone"`
+	MachineType     string `json:"machine_type,omitempty"`
+	// StandardMinutes — qty пачки × SMV, строкой-десятичным; пусто, когда у шага нет нормы.
+	StandardMinutes string `json:"standard_minutes,omitempty"`
+	Done            bool   `json:"done"`
+	DoneAt          string `json:"done_at,omitempty"`
+}
+
+// ScanRequest — тело POST.
+type ScanRequest struct {
+	OperationSeq int `json:"operation_seq"`
+	EmployeeId   int `json:"employee_id"`
+}
+
+// ScanResponse — ответ на принятое сканирование.
+type ScanResponse struct {
+	OperationSeq int    `json:"operation_seq"`
+	Qty          int    `json:"qty"`
+	EmployeeName string `json:"employee_name"`
+	ScannedAt    string `json:"scanned_at"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// resolve проверяет лимиты и токен и отдаёт id пачки; ok=false — ответ (голый 404) уже записан.
+func (s *Service) resolve(w http.ResponseWriter, r *http.Request) (int, bool) {
+	ip := middleware.ClientIPFromRequest(r)
+	if !s.ipLimiter.Allow(ip) {
+		s.notFound(w, r, ip, "ip rate limited")
+		return 0, false
+	}
+	scope, id, epoch, err := s.minter.Parse(chi.URLParam(r, "token"))
+	if err != nil {
+		s.notFound(w, r, ip, "bad token")
+		return 0, false
+	}
+	// СКОУП-ALLOWLIST: id здесь — номер ПАЧКИ, и токен любого другого скоупа с тем же числом
+	// назвал бы чужую пачку.
+	if scope != patterntoken.ScopeBundle || epoch != tokenEpoch {
+		s.notFound(w, r, ip, "wrong token scope")
+		return 0, false
+	}
+	if !s.tokenLimiter.Allow(ip + "|b|" + strconv.FormatInt(id, 10)) {
+		s.notFound(w, r, ip, "token rate limited")
+		return 0, false
+	}
+	return int(id), true
+}
+
+// notFound — единственный ответ на отказ по токену. Причина только в сэмплированном логе:
+// эндпоинт неаутентифицированный, и строка лога на каждый отбитый запрос — усилитель объёма.
+func (s *Service) notFound(w http.ResponseWriter, r *http.Request, ip, reason string) {
+	level := slog.LevelDebug
+	if s.deniedSeq.Add(1)%deniedLogSample == 0 {
+		level = slog.LevelInfo
+	}
+	slog.Default().Log(r.Context(), level, "bundle ticket denied",
+		slog.String("reason", reason), slog.String("ip", ip), slog.String("ua", r.UserAgent()))
+	http.NotFound(w, r)
+}
+
+// ServeTicket обслуживает GET/HEAD /api/bt/{token}.
+func (s *Service) ServeTicket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bundleID, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	set, err := s.runs.GetBundle(ctx, bundleID)
+	if err != nil {
+		if !errors.Is(err, entity.ErrProductionRunBundleNotFound) {
+			slog.Default().ErrorContext(ctx, "bundle ticket read failed", slog.String("err", err.Error()))
+		}
+		s.notFound(w, r, middleware.ClientIPFromRequest(r), "bundle gone")
+		return
+	}
+	t := Ticket{Coupons: []TicketCoupon{}}
+	b := set.Bundles[0]
+	t.BundleNo, t.RunId, t.LayName = b.BundleNo, b.RunId, b.LayName
+	t.ColorwayName, t.SizeName = b.ColorwayName, b.SizeName
+	t.StackNo, t.PlyFrom, t.PlyTo, t.Qty = b.StackNo, b.PlyFrom, b.PlyTo, b.Qty
+	if pack, err := s.runs.GetRunPack(ctx, b.RunId); err == nil {
+		t.StyleNumber, t.StyleName = pack.StyleNumber, pack.StyleName
+	} else {
+		// Шапка без артикула лучше, чем ярлык, который не открывается посреди смены.
+		slog.Default().WarnContext(ctx, "bundle ticket style read failed",
+			slog.Int("run_id", b.RunId), slog.String("err", err.Error()))
+	}
+	for _, c := range Coupons(set)[b.Id] {
+		tc := TicketCoupon{
+			OperationSeq:  c.Operation.Seq,
+			OperationType: c.Operation.OperationType,
+			Zone:          c.Operation.Zone,
+			MachineType:   c.Operation.MachineType.String,
+		}
+		if c.Operation.OperationNumber.Valid {
+			tc.OperationNumber = int(c.Operation.OperationNumber.Int32)
+		}
+		if c.StandardMinutes.Valid {
+			tc.StandardMinutes = c.StandardMinutes.Decimal.String()
+		}
+		if c.Scan != nil {
+			tc.Done = true
+			tc.DoneAt = c.Scan.ScannedAt.UTC().Format(time.RFC3339)
+		}
+		t.Coupons = append(t.Coupons, tc)
+	}
+
+	// Ярлык живой — отметки меняются с каждым сканированием, общим кэшам его хранить нельзя.
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// ServeScan обслуживает POST /api/bt/{token}: выполнение одной операции по пачке целиком.
+func (s *Service) ServeScan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bundleID, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	var req ScanRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxScanBodyBytes)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "body must be {\"operation_seq\": n, \"employee_id\": n}"})
+		return
+	}
+	scan, err := s.runs.RecordBundleScan(ctx, entity.ProductionRunBundleScanInsert{
+		BundleId:     bundleID,
+		OperationSeq: req.OperationSeq,
+		EmployeeId:   req.EmployeeId,
+		Source:       entity.ProductionRunBundleScanSourceScan,
+		ScannedAt:    s.now().UTC(),
+	})
+	if err != nil {
+		var ve *entity.ValidationError
+		switch {
+		case errors.Is(err, entity.ErrProductionRunBundleNotFound):
+			s.notFound(w, r, middleware.ClientIPFromRequest(r), "bundle gone")
+		case errors.As(err, &ve):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: ve.Error()})
+		case errors.Is(err, entity.ErrProductionRunOperationNotFound):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "this bundle has no such operation"})
+		case errors.Is(err, entity.ErrEmployeeNotActive):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: entity.ErrEmployeeNotActive.Error()})
+		case errors.Is(err, entity.ErrProductionRunBundleScanDuplicate):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: entity.ErrProductionRunBundleScanDuplicate.Error()})
+		case errors.Is(err, entity.ErrPayslipApproved):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: "this month's pay is already approved; ask the supervisor"})
+		default:
+			slog.Default().ErrorContext(ctx, "bundle scan failed",
+				slog.Int("bundle_id", bundleID), slog.String("err", err.Error()))
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "can't record the operation; try again"})
+		}
+		return
+	}
+	slog.Default().InfoContext(ctx, "bundle operation recorded",
+		slog.Int("bundle_id", bundleID), slog.Int("operation_seq", scan.OperationSeq),
+		slog.Int("employee_id", scan.EmployeeId))
+	writeJSON(w, http.StatusOK, ScanResponse{
+		OperationSeq: scan.OperationSeq,
+		Qty:          scan.Qty,
+		EmployeeName: scan.EmployeeName,
+		ScannedAt:    scan.ScannedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
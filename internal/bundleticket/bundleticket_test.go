@@ -0,0 +1,187 @@
+package bundleticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/patterntoken"
+)
+
+const testPepper = "test-pepper-for-bundles"
+
+// fakeRuns держит одну пачку (id 7) и пишет сканирования в память.
+type fakeRuns struct {
+	set       *entity.ProductionRunBundleSet
+	recorded  []entity.ProductionRunBundleScanInsert
+	recordErr error
+}
+
+func newFakeRuns() *fakeRuns {
+	set := wipFixture()
+	set.Bundles = []entity.ProductionRunBundle{{Id: 7, RunId: 3, BundleNo: 4, ColorwayName: "black", SizeName: "M", StackNo: 1, PlyFrom: 1, PlyTo: 20, Qty: 20}}
+	set.Scans = []entity.ProductionRunBundleScan{{BundleId: 7, OperationId: 1, OperationSeq: 1, Qty: 20, EmployeeName: "Anna", ScannedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)}}
+	return &fakeRuns{set: set}
+}
+
+func (f *fakeRuns) GetBundle(_ context.Context, bundleID int) (*entity.ProductionRunBundleSet, error) {
+	if bundleID != 7 {
+		return nil, entity.ErrProductionRunBundleNotFound
+	}
+	return f.set, nil
+}
+
+func (f *fakeRuns) GetRunPack(_ context.Context, runID int) (*entity.RunPack, error) {
+	return &entity.RunPack{StyleNumber: "GR-01", StyleName: "Coat"}, nil
+}
+
+func (f *fakeRuns) RecordBundleScan(_ context.Context, ins entity.ProductionRunBundleScanInsert) (*entity.ProductionRunBundleScan, error) {
+	if ins.BundleId != 7 {
+		return nil, entity.ErrProductionRunBundleNotFound
+	}
+	if f.recordErr != nil {
+		return nil, f.recordErr
+	}
+	f.recorded = append(f.recorded, ins)
+	return &entity.ProductionRunBundleScan{BundleId: 7, OperationSeq: ins.OperationSeq, EmployeeId: ins.EmployeeId, EmployeeName: "Anna", Qty: 20, ScannedAt: ins.ScannedAt}, nil
+}
+
+func newTestService(t *testing.T, runs Runs) *Service {
+	t.Helper()
+	svc, err := New(runs, testPepper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Stop)
+	return svc
+}
+
+// serveBundle routes через тот же mount, что и http.go.
+func serveBundle(svc *Service, method, target, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost} {
+		r.Method(m, "/api/bt/{token}", svc.Handler())
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+	return w
+}
+
+func TestTicketShape(t *testing.T) {
+	svc := newTestService(t, newFakeRuns())
+	w := serveBundle(svc, http.MethodGet, "/api/bt/"+svc.MintBundleToken(7), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "private, no-store" {
+		t.Fatalf("cache-control = %q", w.Header().Get("Cache-Control"))
+	}
+	if strings.Contains(w.Body.String(), "Anna") {
+		t.Fatal("the ticket must not name who did the work")
+	}
+	var got Ticket
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.BundleNo != 4 || got.StyleNumber != "GR-01" || got.Qty != 20 || len(got.Coupons) != 3 {
+		t.Fatalf("ticket = %+v", got)
+	}
+	if !got.Coupons[0].Done || got.Coupons[1].Done || got.Coupons[0].StandardMinutes != "30" {
+		t.Fatalf("coupons = %+v", got.Coupons)
+	}
+}
+
+func TestForeignScopeAndBrokenTokensAreNotFound(t *testing.T) {
+	svc := newTestService(t, newFakeRuns())
+	m, _ := patterntoken.NewMinter(testPepper)
+	for _, tok := range []string{
+		m.Mint(patterntoken.ScopeRunPack, 7, 1),
+		m.Mint(patterntoken.ScopeBundle, 7, 2),
+		m.Mint(patterntoken.ScopeBundle, 8, 1), // пачки нет
+		"b7.1.AAAA",
+		"garbage",
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			w := serveBundle(svc, method, "/api/bt/"+tok, `{"operation_seq":2,"employee_id":1}`)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("%s %s = %d, want 404", method, tok, w.Code)
+			}
+		}
+	}
+}
+
+func TestScanRecordsOperation(t *testing.T) {
+	runs := newFakeRuns()
+	svc := newTestService(t, runs)
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	w := serveBundle(svc, http.MethodPost, "/api/bt/"+svc.MintBundleToken(7), `{"operation_seq":2,"employee_id":5}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d body=%s", w.Code, w.Body)
+	}
+	if len(runs.recorded) != 1 {
+		t.Fatalf("recorded = %d", len(runs.recorded))
+	}
+	got := runs.recorded[0]
+	if got.BundleId != 7 || got.OperationSeq != 2 || got.EmployeeId != 5 ||
+		got.Source != entity.ProductionRunBundleScanSourceScan || !got.ScannedAt.Equal(now) {
+		t.Fatalf("insert = %+v", got)
+	}
+}
+
+func TestScanErrorsAreReadable(t *testing.T) {
+	cases := []struct {
+		err  error
+		body string
+		code int
+	}{
+		{nil, `not json`, http.StatusBadRequest},
+		{entity.ErrProductionRunOperationNotFound, `{"operation_seq":9,"employee_id":5}`, http.StatusBadRequest},
+		{entity.ErrEmployeeNotActive, `{"operation_seq":2,"employee_id":5}`, http.StatusBadRequest},
+		{entity.NewFieldViolation("employee_id", "required", "", ""), `{"operation_seq":2}`, http.StatusBadRequest},
+		{entity.ErrProductionRunBundleScanDuplicate, `{"operation_seq":1,"employee_id":5}`, http.StatusConflict},
+		{errors.New("db down"), `{"operation_seq":2,"employee_id":5}`, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		runs := newFakeRuns()
+		runs.recordErr = tc.err
+		svc := newTestService(t, runs)
+		w := serveBundle(svc, http.MethodPost, "/api/bt/"+svc.MintBundleToken(7), tc.body)
+		if w.Code != tc.code {
+			t.Fatalf("%v: code = %d, want %d", tc.err, w.Code, tc.code)
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Fatalf("%v: body = %s", tc.err, w.Body)
+		}
+	}
+}
+
+func TestRateLimitedLooksLikeNotFound(t *testing.T) {
+	svc := newTestService(t, newFakeRuns())
+	target := "/api/bt/" + svc.MintBundleToken(7)
+	for i := 0; i < perTokenMax; i++ {
+		if w := serveBundle(svc, http.MethodGet, target, ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i, w.Code)
+		}
+	}
+	if w := serveBundle(svc, http.MethodGet, target, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("over budget = %d, want 404", w.Code)
+	}
+}
+
+func TestMintOnNilServiceIsEmpty(t *testing.T) {
+	var svc *Service
+	if svc.MintBundleToken(7) != "" {
+		t.Fatal("nil service must mint nothing")
+	}
+}
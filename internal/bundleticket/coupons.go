@@ -0,0 +1,37 @@
+package bundleticket
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// Coupon is one operation of one bundle as the ticket prints it.
+type Coupon struct {
+	Operation entity.ProductionRunOperation
+	// StandardMinutes is the bundle qty × SMV; invalid when the step has no SMV.
+	StandardMinutes decimal.NullDecimal
+	// Scan is the recorded completion, nil while the operation is still to do.
+	Scan *entity.ProductionRunBundleScan
+}
+
+// Coupons lays out the coupons of every bundle of the set, keyed by bundle id, in operation order.
+func Coupons(set *entity.ProductionRunBundleSet) map[int][]Coupon {
+	out := make(map[int][]Coupon, len(set.Bundles))
+	scans := make(map[[2]int]*entity.ProductionRunBundleScan, len(set.Scans))
+	for i := range set.Scans {
+		s := &set.Scans[i]
+		scans[[2]int{s.BundleId, s.OperationId}] = s
+	}
+	for _, b := range set.Bundles {
+		coupons := make([]Coupon, 0, len(set.Operations))
+		for _, op := range set.Operations {
+			c := Coupon{Operation: op, Scan: scans[[2]int{b.Id, op.Id}]}
+			if op.SMV.Valid {
+				c.StandardMinutes = decimal.NewNullDecimal(op.SMV.Decimal.Mul(decimal.NewFromInt(int64(b.Qty))))
+			}
+			coupons = append(coupons, c)
+		}
+		out[b.Id] = coupons
+	}
+	return out
+}
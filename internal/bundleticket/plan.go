@@ -0,0 +1,120 @@
+package bundleticket
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+const (
+	// DefaultBundleSize — изделий в пачке, когда размер не задан. Двадцать — привычная пачка
+	// швейного потока: достаточно мала, чтобы швея закрыла её за смену, и достаточно велика, чтобы
+	// ярлыков не было больше, чем деталей.
+	DefaultBundleSize = 20
+	// MaxBundleSize — потолок chk_prlays_plies (0281): пачка больше настила не бывает.
+	MaxBundleSize = 500
+)
+
+// PlanBundles режет выбранные настилы на пачки.
+//
+// Одна укладка размера на раскладке — одна стопка деталей глубиной в число слоёв секции. Стопка
+// режется на пачки по bundleSize СОСЕДНИХ слоёв (последняя — остаток): пачка держит детали с
+// соседних слоёв вместе, потому что оттенок меняется по рулону, а изделие, собранное из деталей с
+// разных концов настила, — это полосатое изделие. Номера слоёв — внутри секции: ступенчатый
+// настил кладёт секции разными участками стола, и у каждой свои слои.
+//
+// Состав раскладки (composition) — изделий размера в ОДНОМ слое, и в обоих режимах настилания
+// одна укладка даёт по изделию со слоя: лицом к лицу слои чередуются, но стопка остаётся стопкой.
+// Нечётные слои лицом к лицу — отказ по тому же правилу, что и у покрытия (dto.ErrLayModeParity):
+// последний непарный слой даёт одну руку, и пачка из него не сшивается.
+//
+// ОДИН СЛОТ НА КОЛОРВЕЙ. Настилы — пары (колорвей, слот BOM), и подкладка колорвея кроится своим
+// настилом из тех же изделий. Пачки из двух слотов одного колорвея посчитали бы каждое изделие
+// дважды, поэтому все выбранные настилы колорвея обязаны лежать на одном слоте — обычно основной
+// ткани; детали остальных слотов докладываются в пачку в цеху.
+func PlanBundles(lays []entity.ProductionRunLay, composition map[int][]entity.RunPackMarkerSize, bundleSize int) ([]entity.ProductionRunBundleInsert, error) {
+	if bundleSize < 1 || bundleSize > MaxBundleSize {
+		return nil, entity.NewFieldViolation("bundle_size", "out_of_range", "",
+			fmt.Sprintf("a bundle holds 1..%d garments", MaxBundleSize))
+	}
+	if len(lays) == 0 {
+		return nil, entity.NewFieldViolation("lay_keys", "required", "",
+			"name the lays to cut into bundles, one cloth slot per colourway")
+	}
+
+	slotByColorway := map[int]string{}
+	var out []entity.ProductionRunBundleInsert
+	for _, l := range lays {
+		label := layLabel(l)
+		if l.Broken() {
+			return nil, entity.NewFieldViolation("lay_keys", "lay_broken", label,
+				"the lay lost its BOM slot; fix the lay before cutting bundles from it")
+		}
+		if slot, seen := slotByColorway[l.ColorwayId]; seen && slot != l.BomLineKey {
+			return nil, entity.NewFieldViolation("lay_keys", "colorway_slot_mixed", label,
+				"pick the lays of one cloth slot per colourway, or every garment is counted once per slot")
+		}
+		slotByColorway[l.ColorwayId] = l.BomLineKey
+		if len(l.Sections) == 0 {
+			return nil, entity.NewFieldViolation("lay_keys", "lay_empty", label, "the lay has no sections")
+		}
+		for _, sec := range l.Sections {
+			if l.Mode == entity.ProductionLayModeFaceToFace && sec.Plies%2 != 0 {
+				return nil, entity.NewFieldViolation("lay_keys", "lay_mode_parity", label,
+					fmt.Sprintf("marker %q is laid face to face on %d plies; face to face needs an even ply count", sec.MarkerName, sec.Plies))
+			}
+			sizes := composition[sec.MarkerId]
+			if len(sizes) == 0 {
+				return nil, entity.NewFieldViolation("lay_keys", "marker_composition_unknown", label,
+					fmt.Sprintf("marker %q has no size composition; record which sizes it holds", sec.MarkerName))
+			}
+			for _, sz := range sizes {
+				for stack := 1; stack <= sz.Quantity; stack++ {
+					for from := 1; from <= sec.Plies; from += bundleSize {
+						out = append(out, entity.ProductionRunBundleInsert{
+							LayId:      l.Id,
+							LayKey:     l.LayKey,
+							SectionKey: sec.SectionKey,
+							ColorwayId: l.ColorwayId,
+							SizeId:     sz.SizeId,
+							StackNo:    stack,
+							PlyFrom:    from,
+							PlyTo:      min(from+bundleSize-1, sec.Plies),
+						})
+					}
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// OperationsSnapshot takes the card's operations in card order. An empty list is a refusal: a
+// bundle without coupons has nothing to scan.
+func OperationsSnapshot(ops []entity.TechCardOperation) ([]entity.ProductionRunOperationInsert, error) {
+	if len(ops) == 0 {
+		return nil, entity.NewFieldViolation("run_id", "tech_card_operations_missing", "",
+			"the tech card has no operations; list them before printing coupons")
+	}
+	out := make([]entity.ProductionRunOperationInsert, 0, len(ops))
+	for _, op := range ops {
+		out = append(out, entity.ProductionRunOperationInsert{
+			OperationNumber: op.OperationNumber,
+			OperationType:   string(op.OperationType),
+			Zone:            string(op.Zone),
+			MachineType:     op.MachineType,
+			Work:            op.Work,
+			SMV:             op.SMV,
+			Note:            op.Note,
+		})
+	}
+	return out, nil
+}
+
+func layLabel(l entity.ProductionRunLay) string {
+	if name := strings.TrimSpace(l.Name); name != "" {
+		return fmt.Sprintf("lay %q", name)
+	}
+	return fmt.Sprintf("lay %s (%s)", l.LayKey, l.ColorwayName)
+}
@@ -0,0 +1,117 @@
+package bundleticket
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+func testLay(id, colorway int, slot string, mode entity.ProductionLayMode, sections ...entity.ProductionRunLaySection) entity.ProductionRunLay {
+	return entity.ProductionRunLay{
+		Id:         id,
+		LayKey:     "lay" + string(rune('A'+id)),
+		ColorwayId: colorway,
+		BomItemId:  sql.NullInt64{Int64: 1, Valid: true},
+		BomLineKey: slot,
+		Mode:       mode,
+		Sections:   sections,
+	}
+}
+
+func TestPlanBundlesSplitsStacksByAdjacentPlies(t *testing.T) {
+	lay := testLay(1, 10, "shell", entity.ProductionLayModeFaceUp,
+		entity.ProductionRunLaySection{SectionKey: "s1", MarkerId: 5, Plies: 45})
+	comp := map[int][]entity.RunPackMarkerSize{5: {{MarkerId: 5, SizeId: 2, Quantity: 2}, {MarkerId: 5, SizeId: 3, Quantity: 1}}}
+
+	got, err := PlanBundles([]entity.ProductionRunLay{lay}, comp, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 stacks × ceil(45/20) = 9 bundles, 45 garments per stack.
+	if len(got) != 9 {
+		t.Fatalf("bundles = %d, want 9", len(got))
+	}
+	perSizeStack := map[[2]int]int{}
+	for _, b := range got {
+		perSizeStack[[2]int{b.SizeId, b.StackNo}] += b.Qty()
+		if b.LayId != 1 || b.SectionKey != "s1" || b.ColorwayId != 10 {
+			t.Fatalf("bundle lost its lay: %+v", b)
+		}
+	}
+	for k, qty := range perSizeStack {
+		if qty != 45 {
+			t.Fatalf("size %d stack %d holds %d garments, want 45", k[0], k[1], qty)
+		}
+	}
+	last := got[2]
+	if last.PlyFrom != 41 || last.PlyTo != 45 {
+		t.Fatalf("remainder bundle = plies %d..%d, want 41..45", last.PlyFrom, last.PlyTo)
+	}
+}
+
+func TestPlanBundlesRefusals(t *testing.T) {
+	comp := map[int][]entity.RunPackMarkerSize{5: {{MarkerId: 5, SizeId: 2, Quantity: 1}}}
+	sec := func(plies int) entity.ProductionRunLaySection {
+		return entity.ProductionRunLaySection{SectionKey: "s", MarkerId: 5, Plies: plies}
+	}
+	broken := testLay(1, 10, "shell", entity.ProductionLayModeFaceUp, sec(10))
+	broken.BomItemId = sql.NullInt64{}
+
+	cases := []struct {
+		name   string
+		lays   []entity.ProductionRunLay
+		comp   map[int][]entity.RunPackMarkerSize
+		size   int
+		reason string
+	}{
+		{"size zero", []entity.ProductionRunLay{testLay(1, 10, "shell", entity.ProductionLayModeFaceUp, sec(10))}, comp, 0, "out_of_range"},
+		{"no lays", nil, comp, 20, "required"},
+		{"broken lay", []entity.ProductionRunLay{broken}, comp, 20, "lay_broken"},
+		{"two slots of one colorway", []entity.ProductionRunLay{
+			testLay(1, 10, "shell", entity.ProductionLayModeFaceUp, sec(10)),
+			testLay(2, 10, "lining", entity.ProductionLayModeFaceUp, sec(10)),
+		}, comp, 20, "colorway_slot_mixed"},
+		{"odd face to face", []entity.ProductionRunLay{testLay(1, 10, "shell", entity.ProductionLayModeFaceToFace, sec(9))}, comp, 20, "lay_mode_parity"},
+		{"no composition", []entity.ProductionRunLay{testLay(1, 10, "shell", entity.ProductionLayModeFaceUp, sec(10))}, nil, 20, "marker_composition_unknown"},
+		{"no sections", []entity.ProductionRunLay{testLay(1, 10, "shell", entity.ProductionLayModeFaceUp)}, comp, 20, "lay_empty"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := PlanBundles(tc.lays, tc.comp, tc.size)
+			var ve *entity.ValidationError
+			if !errors.As(err, &ve) || ve.Reason != tc.reason {
+				t.Fatalf("err = %v, want reason %q", err, tc.reason)
+			}
+		})
+	}
+}
+
+func TestPlanBundlesTwoColorwaysKeepTheirOwnSlot(t *testing.T) {
+	comp := map[int][]entity.RunPackMarkerSize{5: {{MarkerId: 5, SizeId: 2, Quantity: 1}}}
+	lays := []entity.ProductionRunLay{
+		testLay(1, 10, "shell", entity.ProductionLayModeFaceToFace, entity.ProductionRunLaySection{SectionKey: "a", MarkerId: 5, Plies: 10}),
+		testLay(2, 11, "lining", entity.ProductionLayModeFaceUp, entity.ProductionRunLaySection{SectionKey: "b", MarkerId: 5, Plies: 5}),
+	}
+	got, err := PlanBundles(lays, comp, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Qty() != 10 || got[1].Qty() != 5 {
+		t.Fatalf("bundles = %+v", got)
+	}
+}
+
+func TestOperationsSnapshotRefusesEmptyCard(t *testing.T) {
+	if _, err := OperationsSnapshot(nil); err == nil {
+		t.Fatal("empty card must be refused")
+	}
+	got, err := OperationsSnapshot([]entity.TechCardOperation{{OperationType: "seam", Zone: "body"}, {OperationType: "hem", Zone: "hem"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].OperationType != "hem" {
+		t.Fatalf("snapshot = %+v", got)
+	}
+}
@@ -0,0 +1,126 @@
+package bundleticket
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// OperationProgress is one operation of the run's snapshot as the WIP view reads it.
+type OperationProgress struct {
+	Operation entity.ProductionRunOperation
+	// DoneQty / DoneBundles — garments and bundles on which the operation is recorded.
+	DoneQty     int
+	DoneBundles int
+	// RemainingQty is the bundled quantity the operation has not yet been recorded on.
+	RemainingQty int
+	// QueuedQty is the work WAITING in front of the operation: garments the previous operation has
+	// released and this one has not taken (for the first operation, everything bundled). It is the
+	// pile-up the floor sees, and the bottleneck is where it is highest.
+	QueuedQty int
+	// RemainingMinutes / QueuedMinutes are the quantities × SMV; invalid when the step has no SMV.
+	RemainingMinutes decimal.NullDecimal
+	QueuedMinutes    decimal.NullDecimal
+	Bottleneck       bool
+}
+
+// Wip is the run's work in progress over its bundles.
+type Wip struct {
+	Operations []OperationProgress
+	// TotalQty / TotalBundles — everything bundled.
+	TotalQty     int
+	TotalBundles int
+	// FinishedQty / FinishedBundles — bundles with every operation recorded.
+	FinishedQty     int
+	FinishedBundles int
+	// RemainingMinutes sums the standard minutes still to be done. MinutesComplete is false when
+	// some operation with remaining work has no SMV, i.e. the sum is a floor.
+	RemainingMinutes decimal.Decimal
+	MinutesComplete  bool
+}
+
+// ComputeWip folds the run's scans over its bundles and operation snapshot.
+//
+// THE QUEUE READS THE OPERATIONS IN CARD ORDER. A sewing line is not strictly sequential — zones are
+// sewn in parallel and joined later — but the card order is the order the technologist wrote and
+// the order the coupons are printed in, and it is the only order the data has. A step that runs
+// ahead of its predecessor reads as an empty queue (clamped at zero), never as a negative one.
+//
+// The bottleneck is the operation with the most garments queued; a tie goes to the longer SMV
+// (the slower step drains its queue later), then to the earlier step. Nothing bundled or
+// everything finished means no bottleneck.
+func ComputeWip(set *entity.ProductionRunBundleSet) Wip {
+	w := Wip{MinutesComplete: true}
+	if set == nil {
+		return w
+	}
+	qtyByBundle := make(map[int]int, len(set.Bundles))
+	for _, b := range set.Bundles {
+		qtyByBundle[b.Id] = b.Qty
+		w.TotalQty += b.Qty
+		w.TotalBundles++
+	}
+
+	doneQty := map[int]int{}
+	doneBundles := map[int]int{}
+	opsByBundle := map[int]int{}
+	for _, s := range set.Scans {
+		if _, ok := qtyByBundle[s.BundleId]; !ok {
+			continue
+		}
+		doneQty[s.OperationId] += s.Qty
+		doneBundles[s.OperationId]++
+		opsByBundle[s.BundleId]++
+	}
+	if len(set.Operations) > 0 {
+		for _, b := range set.Bundles {
+			if opsByBundle[b.Id] >= len(set.Operations) {
+				w.FinishedBundles++
+				w.FinishedQty += b.Qty
+			}
+		}
+	}
+
+	released := w.TotalQty
+	bottleneck := -1
+	for i, op := range set.Operations {
+		p := OperationProgress{
+			Operation:    op,
+			DoneQty:      doneQty[op.Id],
+			DoneBundles:  doneBundles[op.Id],
+			RemainingQty: max(w.TotalQty-doneQty[op.Id], 0),
+			QueuedQty:    max(released-doneQty[op.Id], 0),
+		}
+		released = p.DoneQty
+		if op.SMV.Valid {
+			p.RemainingMinutes = decimal.NewNullDecimal(op.SMV.Decimal.Mul(decimal.NewFromInt(int64(p.RemainingQty))))
+			p.QueuedMinutes = decimal.NewNullDecimal(op.SMV.Decimal.Mul(decimal.NewFromInt(int64(p.QueuedQty))))
+			w.RemainingMinutes = w.RemainingMinutes.Add(p.RemainingMinutes.Decimal)
+		} else if p.RemainingQty > 0 {
+			w.MinutesComplete = false
+		}
+		w.Operations = append(w.Operations, p)
+		if p.QueuedQty > 0 && (bottleneck < 0 || slower(p, w.Operations[bottleneck])) {
+			bottleneck = i
+		}
+	}
+	if bottleneck >= 0 {
+		w.Operations[bottleneck].Bottleneck = true
+	}
+	return w
+}
+
+// slower reports whether a is a worse bottleneck than b: more queued, then the longer SMV. An
+// equal answer keeps b, the earlier step.
+func slower(a, b OperationProgress) bool {
+	if a.QueuedQty != b.QueuedQty {
+		return a.QueuedQty > b.QueuedQty
+	}
+	return smvOf(a.Operation).GreaterThan(smvOf(b.Operation))
+}
+
+func smvOf(op entity.ProductionRunOperation) decimal.Decimal {
+	if op.SMV.Valid {
+		return op.SMV.Decimal
+	}
+	return decimal.Zero
+}
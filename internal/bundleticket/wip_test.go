@@ -0,0 +1,113 @@
+package bundleticket
+
+import (
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+func smv(s string) decimal.NullDecimal {
+	return decimal.NewNullDecimal(decimal.RequireFromString(s))
+}
+
+func wipFixture() *entity.ProductionRunBundleSet {
+	return &entity.ProductionRunBundleSet{
+		Operations: []entity.ProductionRunOperation{
+			{Id: 1, Seq: 1, OperationType: "seam", SMV: smv("1.5")},
+			{Id: 2, Seq: 2, OperationType: "overlock", SMV: smv("0.5")},
+			{Id: 3, Seq: 3, OperationType: "hem"}, // без нормы
+		},
+		Bundles: []entity.ProductionRunBundle{
+			{Id: 10, Qty: 20}, {Id: 11, Qty: 20}, {Id: 12, Qty: 10},
+		},
+		Scans: []entity.ProductionRunBundleScan{
+			{BundleId: 10, OperationId: 1, Qty: 20},
+			{BundleId: 11, OperationId: 1, Qty: 20},
+			{BundleId: 10, OperationId: 2, Qty: 20},
+			{BundleId: 10, OperationId: 3, Qty: 20},
+		},
+	}
+}
+
+func TestComputeWipQueuesAndBottleneck(t *testing.T) {
+	w := ComputeWip(wipFixture())
+	if w.TotalQty != 50 || w.TotalBundles != 3 {
+		t.Fatalf("total = %d/%d", w.TotalQty, w.TotalBundles)
+	}
+	if w.FinishedQty != 20 || w.FinishedBundles != 1 {
+		t.Fatalf("finished = %d/%d, want 20/1", w.FinishedQty, w.FinishedBundles)
+	}
+	// op1: 10 waiting (bundle 12); op2: 40 released − 20 done = 20; op3: 20 − 20 = 0.
+	wantQueued := []int{10, 20, 0}
+	for i, p := range w.Operations {
+		if p.QueuedQty != wantQueued[i] {
+			t.Fatalf("op %d queued = %d, want %d", i+1, p.QueuedQty, wantQueued[i])
+		}
+		if p.Bottleneck != (i == 1) {
+			t.Fatalf("op %d bottleneck = %v", i+1, p.Bottleneck)
+		}
+	}
+	// 10×1.5 + 30×0.5 = 30; op3 has 30 garments left and no SMV.
+	if !w.RemainingMinutes.Equal(decimal.NewFromInt(30)) || w.MinutesComplete {
+		t.Fatalf("remaining minutes = %s complete=%v", w.RemainingMinutes, w.MinutesComplete)
+	}
+	if w.Operations[2].RemainingMinutes.Valid {
+		t.Fatal("a step without SMV has no minutes")
+	}
+}
+
+func TestComputeWipTieGoesToLongerSMV(t *testing.T) {
+	set := &entity.ProductionRunBundleSet{
+		Operations: []entity.ProductionRunOperation{
+			{Id: 1, Seq: 1, SMV: smv("0.5")},
+			{Id: 2, Seq: 2, SMV: smv("2")},
+		},
+		Bundles: []entity.ProductionRunBundle{{Id: 10, Qty: 10}, {Id: 11, Qty: 10}},
+		Scans:   []entity.ProductionRunBundleScan{{BundleId: 10, OperationId: 1, Qty: 10}},
+	}
+	w := ComputeWip(set)
+	if w.Operations[0].Bottleneck || !w.Operations[1].Bottleneck {
+		t.Fatalf("tie must go to the slower step: %+v", w.Operations)
+	}
+}
+
+func TestComputeWipRunAheadIsNotNegative(t *testing.T) {
+	set := &entity.ProductionRunBundleSet{
+		Operations: []entity.ProductionRunOperation{{Id: 1, Seq: 1}, {Id: 2, Seq: 2}},
+		Bundles:    []entity.ProductionRunBundle{{Id: 10, Qty: 10}},
+		Scans:      []entity.ProductionRunBundleScan{{BundleId: 10, OperationId: 2, Qty: 10}},
+	}
+	w := ComputeWip(set)
+	if w.Operations[1].QueuedQty != 0 {
+		t.Fatalf("queued = %d, want 0", w.Operations[1].QueuedQty)
+	}
+	if !w.Operations[0].Bottleneck {
+		t.Fatal("the untouched first step is the pile-up")
+	}
+}
+
+func TestComputeWipFinishedRunHasNoBottleneck(t *testing.T) {
+	set := &entity.ProductionRunBundleSet{
+		Operations: []entity.ProductionRunOperation{{Id: 1, Seq: 1}},
+		Bundles:    []entity.ProductionRunBundle{{Id: 10, Qty: 10}},
+		Scans:      []entity.ProductionRunBundleScan{{BundleId: 10, OperationId: 1, Qty: 10}},
+	}
+	w := ComputeWip(set)
+	if w.Operations[0].Bottleneck || w.FinishedBundles != 1 || !w.MinutesComplete {
+		t.Fatalf("wip = %+v", w)
+	}
+}
+
+func TestCouponsCarryScansAndMinutes(t *testing.T) {
+	c := Coupons(wipFixture())
+	if len(c[12]) != 3 || c[12][0].Scan != nil {
+		t.Fatalf("bundle 12 coupons = %+v", c[12])
+	}
+	if c[10][2].Scan == nil || c[10][2].StandardMinutes.Valid {
+		t.Fatalf("bundle 10 hem coupon = %+v", c[10][2])
+	}
+	if !c[12][0].StandardMinutes.Decimal.Equal(decimal.NewFromInt(15)) {
+		t.Fatalf("10 × 1.5 = %s", c[12][0].StandardMinutes.Decimal)
+	}
+}
@@ -0,0 +1,356 @@
+// Package checkoutrecovery runs a periodic job that mails customers who left a checkout (0352): a
+// timed sequence of reminders, each with a link that restores the cart on the storefront and,
+// on one chosen step, a single-use promo code. A checkout whose customer has since paid is closed
+// before anything else, so nobody is reminded of a cart they already bought; a checkout whose order
+// is still waiting for its payment is held until it is paid or expires.
+package checkoutrecovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/health"
+	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+)
+
+// tickTimeout bounds the work done in a single tick, so one stuck query can't
+// block the loop forever or stall graceful shutdown.
+const tickTimeout = 2 * time.Minute
+
+// Backoff bounds for consecutive-failure backoff (base * 2^(n-1), capped at
+// backoffMax); a successful tick resets it. Mirrors markdownsched.
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 5 * time.Minute
+)
+
+// storefrontURL is the storefront the restore and stop links point at.
+const storefrontURL = "https://grbpwr.com"
+
+// incentiveBatch labels the unique codes minted for recovery emails (promo_unique_code.batch).
+const incentiveBatch = "checkout-recovery"
+
+// Config configures the abandoned checkout recovery worker.
+type Config struct {
+	// WorkerInterval is how often open checkouts are checked; a step goes out up to one interval late.
+	WorkerInterval time.Duration `mapstructure:"worker_interval"`
+	// StepDelays is the sequence: step n goes out StepDelays[n-1] after the last checkout activity.
+	// Must be increasing.
+	StepDelays []time.Duration `mapstructure:"step_delays"`
+	// AttributionWindow is how long after the last email a purchase still counts as a conversion;
+	// the checkout expires once it passes.
+	AttributionWindow time.Duration `mapstructure:"attribution_window"`
+	// IncentivePromoCode is the promo whose single-use codes are sent (a unique-code promo); empty
+	// sends no code.
+	IncentivePromoCode string `mapstructure:"incentive_promo_code"`
+	// IncentiveStep is the 1-based step that carries the code; 0 means the last one.
+	IncentiveStep int `mapstructure:"incentive_step"`
+	// IncentivePrefix starts every minted code (alphanumeric, up to 20 characters).
+	IncentivePrefix string `mapstructure:"incentive_prefix"`
+}
+
+// DefaultConfig returns sane defaults (run every five minutes; remind after one hour, one day and
+// three days; attribute purchases for seven days; no incentive).
+func DefaultConfig() Config {
+	return Config{
+		WorkerInterval:    5 * time.Minute,
+		StepDelays:        []time.Duration{time.Hour, 24 * time.Hour, 72 * time.Hour},
+		AttributionWindow: 7 * 24 * time.Hour,
+		IncentivePrefix:   "BACK",
+	}
+}
+
+// validDelays reports whether the delays are positive and increasing.
+func validDelays(delays []time.Duration) bool {
+	if len(delays) == 0 {
+		return false
+	}
+	var prev time.Duration
+	for _, d := range delays {
+		if d <= prev {
+			return false
+		}
+		prev = d
+	}
+	return true
+}
+
+// Worker periodically mails abandoned checkout reminders.
+type Worker struct {
+	repo    dependency.Repository
+	mailer  dependency.Mailer
+	c       *Config
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	tracker health.Tracker
+}
+
+// Name implements health.Reporter.
+func (w *Worker) Name() string { return "checkoutrecovery" }
+
+// LastSuccess implements health.Reporter (zero time until the first clean tick).
+func (w *Worker) LastSuccess() time.Time { return w.tracker.LastSuccess() }
+
+// New constructs an abandoned checkout recovery worker. Invalid step delays fall back to the
+// default sequence; an incentive step outside the sequence means its last step.
+func New(c *Config, repo dependency.Repository, mailer dependency.Mailer) *Worker {
+	if c == nil {
+		dc := DefaultConfig()
+		c = &dc
+	}
+	if c.WorkerInterval <= 0 {
+		c.WorkerInterval = DefaultConfig().WorkerInterval
+	}
+	if !validDelays(c.StepDelays) {
+		if len(c.StepDelays) > 0 {
+			slog.Default().Warn("checkoutrecovery: step delays must be positive and increasing, using the defaults",
+				slog.Any("step_delays", c.StepDelays),
+			)
+		}
+		c.StepDelays = DefaultConfig().StepDelays
+	}
+	if c.AttributionWindow <= 0 {
+		c.AttributionWindow = DefaultConfig().AttributionWindow
+	}
+	if c.IncentiveStep <= 0 || c.IncentiveStep > len(c.StepDelays) {
+		c.IncentiveStep = len(c.StepDelays)
+	}
+	if c.IncentivePrefix == "" {
+		c.IncentivePrefix = DefaultConfig().IncentivePrefix
+	}
+	return &Worker{repo: repo, mailer: mailer, c: c}
+}
+
+// Start launches the worker goroutine.
+func (w *Worker) Start(ctx context.Context) error {
+	if w.ctx != nil && w.stop != nil {
+		return fmt.Errorf("checkout recovery worker already started")
+	}
+	w.ctx, w.stop = context.WithCancel(ctx)
+	w.wg.Go(func() {
+		w.run(w.ctx)
+	})
+	return nil
+}
+
+// Stop signals the worker to exit and waits for its goroutine to return.
+func (w *Worker) Stop() error {
+	if w.stop == nil {
+		return fmt.Errorf("checkout recovery worker already stopped or not started")
+	}
+	w.stop()
+	w.stop = nil
+	w.wg.Wait()
+	return nil
+}
+
+func (w *Worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.c.WorkerInterval)
+	defer ticker.Stop()
+
+	var consecutiveFailures int
+	for {
+		select {
+		case <-ticker.C:
+			if w.runOnce(ctx) {
+				consecutiveFailures = 0
+				continue
+			}
+			consecutiveFailures++
+			delay := backoffDelay(consecutiveFailures)
+			slog.Default().WarnContext(ctx, "checkoutrecovery: backing off after failed tick",
+				slog.Int("consecutive_failures", consecutiveFailures),
+				slog.Duration("delay", delay),
+			)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backoffDelay returns base * 2^(n-1), capped at backoffMax.
+func backoffDelay(consecutiveFailures int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < consecutiveFailures; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+// runOnce closes the purchased checkouts, then walks the open ones: an unsubscribed address is
+// opted out, a lapsed sequence expires, a pending payment holds the checkout, and a due step is
+// mailed. A step whose email could not be queued is not marked sent, so it is tried again next tick.
+func (w *Worker) runOnce(ctx context.Context) bool {
+	defer saferun.Recover(ctx, "checkoutrecovery")
+
+	tickCtx, cancel := context.WithTimeout(ctx, tickTimeout)
+	defer cancel()
+
+	store := w.repo.CheckoutRecoveries()
+	converted, suppressed, err := store.ClosePurchasedCheckoutRecoveries(tickCtx)
+	if err != nil {
+		w.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "checkoutrecovery: can't close purchased checkouts", slog.String("err", err.Error()))
+		return false
+	}
+
+	open, err := store.ListOpenCheckoutRecoveries(tickCtx)
+	if err != nil {
+		w.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "checkoutrecovery: can't list open checkouts", slog.String("err", err.Error()))
+		return false
+	}
+
+	now := time.Now()
+	var sent, closed, failed int
+	for i := range open {
+		r := &open[i]
+		var closeAs entity.CheckoutRecoveryStatus
+		switch {
+		case r.Unsubscribed:
+			closeAs = entity.CheckoutRecoveryOptedOut
+		case r.Lapsed(w.c.StepDelays, w.c.AttributionWindow, now):
+			closeAs = entity.CheckoutRecoveryExpired
+		case r.PaymentPending:
+			continue
+		}
+		if closeAs == "" {
+			step, due := r.NextStep(w.c.StepDelays, now)
+			if !due {
+				continue
+			}
+			ok, err := w.sendStep(tickCtx, r, step)
+			if err != nil {
+				failed++
+				slog.Default().ErrorContext(ctx, "checkoutrecovery: can't send recovery email",
+					slog.String("err", err.Error()),
+					slog.Int("recovery_id", r.Id),
+					slog.Int("step", step),
+				)
+				continue
+			}
+			if ok {
+				sent++
+				continue
+			}
+			// Nothing in the cart is on sale any more.
+			closeAs = entity.CheckoutRecoveryExpired
+		}
+		if err := store.CloseCheckoutRecovery(tickCtx, r.Id, closeAs); err != nil {
+			failed++
+			slog.Default().ErrorContext(ctx, "checkoutrecovery: can't close checkout",
+				slog.String("err", err.Error()),
+				slog.Int("recovery_id", r.Id),
+				slog.String("status", string(closeAs)),
+			)
+			continue
+		}
+		closed++
+	}
+
+	if sent > 0 || closed > 0 || converted > 0 || suppressed > 0 || failed > 0 {
+		slog.Default().InfoContext(ctx, "checkoutrecovery: abandoned checkouts processed",
+			slog.Int("sent", sent),
+			slog.Int("closed", closed),
+			slog.Int("converted", converted),
+			slog.Int("suppressed", suppressed),
+			slog.Int("failed", failed),
+		)
+	}
+	if failed > 0 {
+		err := fmt.Errorf("%d abandoned checkouts failed", failed)
+		w.tracker.MarkError(err)
+		return false
+	}
+	w.tracker.MarkSuccess()
+	return true
+}
+
+// sendStep re-prices the cart, mints (or reuses) the incentive code on its step and queues the
+// email. False (and no error) when no line of the cart can be bought any more.
+func (w *Worker) sendStep(ctx context.Context, r *entity.CheckoutRecovery, step int) (bool, error) {
+	items := make([]entity.OrderItemInsert, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		items = append(items, entity.OrderItemInsert{VariantSKU: l.VariantSKU, Quantity: l.Quantity})
+	}
+	if len(items) == 0 {
+		return false, nil
+	}
+	oiv, err := w.repo.Order().ValidateOrderItemsInsert(ctx, items, r.Currency)
+	if err != nil {
+		return false, fmt.Errorf("can't price cart: %w", err)
+	}
+	if len(oiv.ValidItems) == 0 {
+		return false, nil
+	}
+
+	// The code goes out once, in the email of its step; the restore link hands it back to the
+	// storefront afterwards. It is stored on the checkout before the email is queued, so a step that
+	// failed to send retries with the code already minted for it.
+	var code string
+	switch {
+	case r.IncentiveCode.Valid && int(r.IncentiveStep.Int32) == step:
+		code = r.IncentiveCode.String
+	case !r.IncentiveCode.Valid && w.c.IncentivePromoCode != "" && step == w.c.IncentiveStep:
+		codes, err := w.repo.Promo().GenerateUniqueCodes(ctx, w.c.IncentivePromoCode, 1, w.c.IncentivePrefix, incentiveBatch)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// A misconfigured promo must not stop the sequence: the step goes out without a code.
+			slog.Default().WarnContext(ctx, "checkoutrecovery: incentive promo not found",
+				slog.String("promo_code", w.c.IncentivePromoCode),
+			)
+		case err != nil:
+			return false, fmt.Errorf("can't generate incentive code: %w", err)
+		case len(codes) > 0:
+			code = codes[0]
+			if err := w.repo.CheckoutRecoveries().SetCheckoutRecoveryIncentive(ctx, r.Id, step, code); err != nil {
+				return false, fmt.Errorf("can't store incentive code: %w", err)
+			}
+		}
+	}
+
+	data := &dto.CheckoutRecoveryEmail{
+		Step:           step,
+		OrderItems:     dto.EntityOrderItemsToDto(oiv.ValidItems, r.Currency),
+		CurrencySymbol: dto.CurrencySymbol(r.Currency),
+		SubtotalPrice:  dto.RoundForCurrency(oiv.Subtotal, r.Currency).String(),
+		IncentiveCode:  code,
+		RestoreURL:     restoreURL(r.Token, step),
+		StopURL:        fmt.Sprintf("%s/cart/restore/%s/stop", storefrontURL, r.Token),
+		EmailB64:       base64.StdEncoding.EncodeToString([]byte(r.Email)),
+	}
+	if err := w.mailer.QueueCheckoutRecovery(ctx, w.repo, r.Email, data); err != nil {
+		return false, err
+	}
+	if err := w.repo.CheckoutRecoveries().MarkCheckoutRecoveryStepSent(ctx, r.Id, step, code); err != nil {
+		return false, fmt.Errorf("can't mark step sent: %w", err)
+	}
+	return true, nil
+}
+
+// restoreURL is the restore-cart link of a step, tagged so storefront analytics attribute the visit
+// to the step's email.
+func restoreURL(token string, step int) string {
+	return fmt.Sprintf("%s/cart/restore/%s?step=%d&utm_source=email&utm_medium=checkout_recovery&utm_campaign=abandoned_checkout_step_%d",
+		storefrontURL, token, step, step)
+}
@@ -21,6 +21,12 @@
 // читается ли он» — правило ЭТОГО пакета, и второй его копии быть не должно (см. заголовок выше).
 // Публичный наряд её не зовёт и звать не имеет права: у него нет аккаунта, под которым деньги
 // разрешены.
+//
+// ТРЕТИЙ ЧИТАТЕЛЬ — PDF-СПЕЦИФИКАЦИЯ РЕЛИЗА (ReleaseSheet, internal/specsheet), и пришёл он сюда, а
+// не к блобу напрямую, ровно по правилу выше. Ему нужно больше, чем крою (эскизы, операции,
+// этикетки, упаковка), поэтому проекция своя — dto.ReleaseSpecSheetFromSnapshot, — но правило то же:
+// поля поимённо, денежных среди них нет, мета релиза без unit_cost/currency. Файл уходит на фабрику
+// и по ссылке без аккаунта, то есть это тот же случай, что публичный наряд.
 package cutspec
 
 import (
@@ -270,3 +276,40 @@ func resolveArticles(ctx context.Context, cards Cards, card *entity.TechCard) {
 	}
 	card.LinkedMaterials = linked
 }
+
+// ErrReleaseSheetUnreadable — снапшот релиза не читается текущей схемой или пуст.
+var ErrReleaseSheetUnreadable = errors.New("release snapshot cannot be read")
+
+// ReleaseSheet собирает PDF-спецификацию релиза из его снапшота.
+//
+// ДЕГРАДАЦИИ НА ЖИВУЮ КАРТОЧКУ ЗДЕСЬ НЕТ — в отличие от Resolve. Наряд печатается ДЛЯ ПРОГОНА, и у
+// прогона есть карточка, по которой кроить, если релиз не прочитан; у спецификации «Rev.N» ничего,
+// кроме самого релиза, нет. Файл с шапкой «Rev.3» над числами живой карточки — это ровно та подмена,
+// ради предотвращения которой заведён весь пакет, только уехавшая на фабрику без оговорки рядом.
+// Поэтому непригодный снапшот — ErrReleaseSheetUnreadable, а sql.ErrNoRows релиза едет завёрнутым.
+func ReleaseSheet(ctx context.Context, cards Cards, releaseID int) (*dto.ReleaseSpecSheet, *entity.TechCardReleaseMeta, error) {
+	rel, err := cards.GetTechCardRelease(ctx, releaseID)
+	if err == nil && rel == nil {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("spec sheet: load release %d: %w", releaseID, err)
+	}
+	// Сверять принадлежность не с чем (релиз читается по своему id), поэтому карточкой служит его
+	// собственная; правило разбора и его лог остаются общими с нарядом.
+	snap, _ := parseReleaseSnapshot(ctx, rel, rel.TechCardId, 0)
+	sheet := dto.ReleaseSpecSheetFromSnapshot(snap)
+	if sheet == nil {
+		return nil, nil, fmt.Errorf("spec sheet: release %d (Rev.%d): %w", rel.Id, rel.ReleaseNumber, ErrReleaseSheetUnreadable)
+	}
+	// Мета поимённо, как в releaseSpec: без unit_cost, currency и released_by.
+	meta := &entity.TechCardReleaseMeta{
+		Id:            rel.Id,
+		TechCardId:    rel.TechCardId,
+		ReleaseNumber: rel.ReleaseNumber,
+		CreatedAt:     rel.CreatedAt,
+	}
+	sheet.Sheet.ReleaseNumber = rel.ReleaseNumber
+	sheet.Sheet.ReleasedAt = rel.CreatedAt
+	return sheet, meta, nil
+}
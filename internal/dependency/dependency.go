@@ -104,6 +104,10 @@ type (
 		GetProductsByIds(ctx context.Context, ids []int) ([]entity.Colorway, error)
 		// GetProductsByTag returns a list of products by their tag.
 		GetProductsByTag(ctx context.Context, tag string) ([]entity.Colorway, error)
+		// ListSearchDocuments loads a search document for every storefront-visible colourway.
+		ListSearchDocuments(ctx context.Context) ([]entity.SearchDocument, error)
+		// SearchFingerprint summarises the searchable catalogue; it changes whenever a search document would.
+		SearchFingerprint(ctx context.Context) (string, error)
 		// GetLowStockProducts returns visible products with total stock in (0, threshold], ordered by ascending stock.
 		GetLowStockProducts(ctx context.Context, threshold int, limit int) ([]entity.Colorway, error)
 		// GetProductByIdShowHidden returns a product by its ID no matter hidden they or not (admin read).
@@ -195,8 +199,9 @@ type (
 		CountWaitlistForProduct(ctx context.Context, productId int) (int, error)
 		// RecordStockChange inserts stock change history entries.
 		RecordStockChange(ctx context.Context, entries []entity.StockChangeInsert) error
-		// GetStockChangeHistory returns paginated stock change history with optional filters.
-		GetStockChangeHistory(ctx context.Context, productId, sizeId *int, dateFrom, dateTo *time.Time, source string, limit, offset int, orderFactor entity.OrderFactor) ([]entity.StockChange, int, error)
+		// GetStockChangeHistory returns paginated stock change history with optional filters;
+		// locationId narrows to one stock location (the default one includes unlocated rows).
+		GetStockChangeHistory(ctx context.Context, productId, sizeId, locationId *int, dateFrom, dateTo *time.Time, source string, limit, offset int, orderFactor entity.OrderFactor) ([]entity.StockChange, int, error)
 		// GetStockChanges returns simplified stock changes for reporting API.
 		// GetStockChanges reads the stock journal; productionRunID (Phase 8) narrows to one run's
 		// whole reference family (the run itself + its receipts).
@@ -299,7 +304,7 @@ type (
 		GetAwaitingPaymentsByPaymentType(ctx context.Context, pmn ...entity.PaymentMethodName) ([]entity.PaymentOrderUUID, error)
 		ExpireOrderPayment(ctx context.Context, orderUUID string) (*entity.Payment, error)
 		OrderPaymentDone(ctx context.Context, orderUUID string, p *entity.Payment) (wasUpdated bool, err error)
-		RefundOrder(ctx context.Context, orderUUID string, orderItemIDs []int32, reason, reasonCode string, refundShipping bool, disposition string) error
+		RefundOrder(ctx context.Context, orderUUID string, orderItemIDs []int32, reason, reasonCode string, refundShipping bool, disposition string, toStoreCredit bool) error
 		DeliveredOrder(ctx context.Context, orderUUID string) error
 		// DeliverOrderWithSource marks an order delivered attributed to changedBy/notes and
 		// reports whether this call performed the transition (used by the delivery-sync worker
@@ -569,6 +574,52 @@ type (
 		SubmitTicket(ctx context.Context, ticket entity.SupportTicketInsert) (string, error)
 	}
 
+	// Returns manages customer returns and exchanges (RMA). Every transition runs under a row lock
+	// and fails with entity.ErrReturnRequestTransition when it does not apply to the current state.
+	// The refund itself stays with Order().RefundOrder; ClaimReturnRefund / CompleteReturnRefund
+	// bracket that call.
+	Returns interface {
+		CreateReturnRequest(ctx context.Context, orderId int, in *entity.ReturnRequestInsert) (*entity.ReturnRequestFull, error)
+		GetReturnRequestByUUID(ctx context.Context, returnUUID string) (*entity.ReturnRequestFull, error)
+		GetReturnRequestById(ctx context.Context, id int) (*entity.ReturnRequestFull, error)
+		GetReturnRequestsByOrderId(ctx context.Context, orderId int) ([]entity.ReturnRequestFull, error)
+		GetReturnRequestsPaged(ctx context.Context, limit, offset int, orderFactor entity.OrderFactor, filters entity.ReturnRequestFilters) ([]entity.ReturnRequest, int, error)
+		ApproveReturnRequest(ctx context.Context, id int, decidedBy, note string) (*entity.ReturnRequestFull, error)
+		RejectReturnRequest(ctx context.Context, id int, decidedBy, note string) (*entity.ReturnRequestFull, error)
+		CancelReturnRequest(ctx context.Context, returnUUID string) (*entity.ReturnRequestFull, error)
+		ReceiveReturnRequest(ctx context.Context, id int, note string) (*entity.ReturnRequestFull, error)
+		ClaimReturnRefund(ctx context.Context, id int, disposition, note string) (*entity.ReturnRequestFull, error)
+		CompleteReturnRefund(ctx context.Context, id int) (*entity.ReturnRequestFull, error)
+		CompleteReturnExchange(ctx context.Context, id int, disposition, note string) (*entity.ReturnRequestFull, error)
+	}
+
+	// StoreCredit is gift cards and per-account store credit (0334). The order-flow methods run on the
+	// caller's connection and are meant to be called through the Repository of the order store's
+	// transaction; AdjustWallet opens its own.
+	StoreCredit interface {
+		// RedeemForOrder spends the requested credit on an order, leaving at least the card minimum to
+		// charge, and records it on customer_order; returns the amount applied.
+		RedeemForOrder(ctx context.Context, orderId int, r entity.StoreCreditRedemption, total decimal.Decimal, currency string) (decimal.Decimal, error)
+		// ReleaseForOrder returns all credit an unpaid order took; a no-op without credit.
+		ReleaseForOrder(ctx context.Context, orderId int, note string) error
+		// ClampForOrder releases whatever applied credit no longer fits a reduced order total.
+		ClampForOrder(ctx context.Context, orderId int, total decimal.Decimal, currency string) (decimal.Decimal, error)
+		// IssueGiftCardsForOrder issues the gift cards a paid order bought; idempotent per order.
+		IssueGiftCardsForOrder(ctx context.Context, orderId int) ([]entity.StoreCreditWallet, error)
+		CreditAccount(ctx context.Context, email, currency string, amount decimal.Decimal, orderId int, entryType entity.StoreCreditEntryType, note, createdBy string) (*entity.StoreCreditWallet, error)
+		CreditWallet(ctx context.Context, walletId int, amount decimal.Decimal, orderId int, entryType entity.StoreCreditEntryType, note, createdBy string) (*entity.StoreCreditWallet, error)
+		AdjustWallet(ctx context.Context, walletId int, amount decimal.Decimal, note, adjustedBy string) (*entity.StoreCreditWalletFull, error)
+		SetWalletStatus(ctx context.Context, walletId int, status entity.StoreCreditWalletStatus) error
+		GetWalletById(ctx context.Context, walletId int) (*entity.StoreCreditWalletFull, error)
+		GetWalletByCode(ctx context.Context, code string) (*entity.StoreCreditWallet, error)
+		GetAccountWallets(ctx context.Context, email string) ([]entity.StoreCreditWallet, error)
+		GetWalletsBySourceOrder(ctx context.Context, orderId int) ([]entity.StoreCreditWallet, error)
+		GetWalletsPaged(ctx context.Context, limit, offset int, filters entity.StoreCreditWalletFilters) ([]entity.StoreCreditWallet, int, error)
+		SetGiftCardProduct(ctx context.Context, productId int, giftCard bool) error
+		// GetGiftCardProductIds returns which of productIds are gift cards (all gift cards when empty).
+		GetGiftCardProductIds(ctx context.Context, productIds []int) (map[int]bool, error)
+	}
+
 	Promo interface {
 		AddPromo(ctx context.Context, promo *entity.PromoCodeInsert) error
 		UpdatePromoCode(ctx context.Context, promo *entity.PromoCodeInsert) error
@@ -576,6 +627,17 @@ type (
 		DeletePromoCode(ctx context.Context, code string) error
 		DisablePromoCode(ctx context.Context, code string) error
 		DisableVoucher(ctx context.Context, promoID sql.NullInt32) error
+		GenerateUniqueCodes(ctx context.Context, code string, count int, prefix, batch string) ([]string, error)
+		ListUniqueCodes(ctx context.Context, code, batch string, limit, offset int) ([]entity.PromoUniqueCode, int, int, error)
+		// EvaluateCart prices the entered codes against a cart: what applies, what does not and why.
+		EvaluateCart(ctx context.Context, codes []string, cart entity.PromoCart) (*entity.PromoBreakdown, error)
+		// RecordRedemptions replaces the order's active redemptions with the breakdown's, re-checking
+		// the usage caps under lock; must run inside the order transaction.
+		RecordRedemptions(ctx context.Context, orderId int, buyerEmail string, bd *entity.PromoBreakdown) error
+		// ReleaseRedemptions frees the order's redemptions and unique codes when it is cancelled or expires.
+		ReleaseRedemptions(ctx context.Context, orderId int) error
+		// GetOrderPromoCodes returns the codes applied to an order, in application order.
+		GetOrderPromoCodes(ctx context.Context, orderId int) ([]string, error)
 	}
 
 	Archive interface {
@@ -913,6 +975,9 @@ type (
 		AddFulfillmentChecklistItem(ctx context.Context, orderUUID, content, createdBy string) (int, error)
 		SetFulfillmentChecklistItemDone(ctx context.Context, id int, done bool) error
 		DeleteFulfillmentChecklistItem(ctx context.Context, id int) error
+		// AllocateOrderFulfillment allocates a paid order to the stock location it is packed from
+		// (0345): locationID, or the first location holding the whole order when 0.
+		AllocateOrderFulfillment(ctx context.Context, orderUUID string, locationID int, username string) (*entity.StockLocation, error)
 	}
 
 	// TechCards manages garment tech packs (техкарта): the header, size range,
@@ -1104,6 +1169,15 @@ type (
 		// different set of files would answer for files nobody read, and an understated area
 		// understates the norm, which is discovered in the warehouse rather than on screen.
 		SaveTechCardPieceAreas(ctx context.Context, in entity.PieceAreaWrite) (entity.PieceAreaResult, error)
+		// GetTechCardPatternScopeSource loads one fabric scope's sheets, блок→деталь links, the
+		// card's pieces and size range — the input of the server's own DXF measurement
+		// (MeasureTechCardPatternScope), resolved exactly as SaveTechCardPieceAreas resolves them.
+		GetTechCardPatternScopeSource(ctx context.Context, techCardID int, scopeKey string) (*entity.PatternScopeSource, error)
+		// GetTechCardPatternGradeRules / SaveTechCardPatternGradeRules read and replace one fabric
+		// scope's point grade rules (0346) — the input of the server's pattern grading
+		// (GradeTechCardPatternScope). The save refuses a rule for a piece the card does not cut.
+		GetTechCardPatternGradeRules(ctx context.Context, techCardID int, scopeKey string) (*entity.PatternGradeRuleSet, error)
+		SaveTechCardPatternGradeRules(ctx context.Context, in entity.PatternGradeRuleWrite) error
 		// GetTechCardDerivedCostInputsDigest fingerprints the cost inputs the card's own write does
 		// not carry — measured piece areas and the recipe's piece→fabric assignments (Ф-П). The write
 		// path needs it to stamp a fresh COSTING approval over content it cannot see; the read path
@@ -1114,6 +1188,12 @@ type (
 		SaveTechCardRelease(ctx context.Context, rel entity.TechCardRelease) error
 		ListTechCardReleases(ctx context.Context, techCardID int) ([]entity.TechCardReleaseMeta, error)
 		GetTechCardRelease(ctx context.Context, id int) (*entity.TechCardRelease, error)
+		// Ссылка на PDF-спецификацию релиза (/api/ts/{token}, 0347, internal/specsheet). Токен скоупа
+		// 't' резолвится ТОЛЬКО через эти строки. GetReleaseSpecAccess отдаёт sql.ErrNoRows, пока
+		// ссылкой не делились; UpdateReleaseSpecAccess заводит строку явным «поделиться» и
+		// перевыпускает/отзывает её.
+		GetReleaseSpecAccess(ctx context.Context, releaseID int) (*entity.TechCardReleaseSpecAccess, error)
+		UpdateReleaseSpecAccess(ctx context.Context, releaseID int, upd entity.ReleaseSpecAccessUpdate) (*entity.TechCardReleaseSpecAccess, error)
 		// Development (R&D) cost journal (task 14): append + delete + list rows at the tech-card
 		// level (NOT full-replace); a period cost, never seeded into product.cost_price.
 		AddTechCardDevExpense(ctx context.Context, e entity.TechCardDevExpense) (entity.TechCardDevExpense, error)
@@ -1260,6 +1340,89 @@ type (
 		// GetRunPackMarkerSizes читает состав набора раскладок (размер × количество в одном слое)
 		// одним запросом — краткая разбивка настила в наряде.
 		GetRunPackMarkerSizes(ctx context.Context, markerIDs []int) (map[int][]entity.RunPackMarkerSize, error)
+
+		// ПАЧКИ И КУПОНЫ (migration 0340): пачки кроя с ярлыками, снимок операций тех-карты, по
+		// которому напечатаны купоны, и выполнение операций по пачкам (internal/bundleticket).
+		//
+		// ReplaceBundles заменяет пачки и снимок операций прогона одной транзакцией; терминальный
+		// прогон и вспомогательная карта отвергаются как у настила, а прогон с хоть одним
+		// сканированием — entity.ErrProductionRunBundlesScanned.
+		ReplaceBundles(ctx context.Context, runID int, ops []entity.ProductionRunOperationInsert,
+			bundles []entity.ProductionRunBundleInsert, username string) error
+		// ListBundles отдаёт снимок операций, пачки и сканирования прогона; sql.ErrNoRows, когда
+		// прогона нет.
+		ListBundles(ctx context.Context, runID int) (*entity.ProductionRunBundleSet, error)
+		// GetBundle — то же для одной пачки (токен скоупа 'b' резолвится только сюда);
+		// entity.ErrProductionRunBundleNotFound, когда пачки нет.
+		GetBundle(ctx context.Context, bundleID int) (*entity.ProductionRunBundleSet, error)
+		// RecordBundleScan записывает выполнение операции (по seq снимка) по пачке целиком.
+		RecordBundleScan(ctx context.Context, ins entity.ProductionRunBundleScanInsert) (*entity.ProductionRunBundleScan, error)
+		// DeleteBundleScan — исправление ошибочного сканирования.
+		DeleteBundleScan(ctx context.Context, runID, scanID int) error
+
+		// ListRunsForSchedule отдаёт открытые прогоны (draft..partially_received) с фактами для
+		// планировщика загрузки (0342): количество, сделанное, нормы снимка или карты.
+		ListRunsForSchedule(ctx context.Context) ([]entity.ProductionScheduleRun, error)
+
+		// КОНТРОЛЬ КАЧЕСТВА (migration 0343): каталог дефектов, инспекции партий по AQL и их
+		// находки. Гейт приёмки — в PostProductionRunReceipt (params.InspectionID).
+		//
+		// ListQcDefectTypes отдаёт каталог по коду; снятые — только по просьбе.
+		ListQcDefectTypes(ctx context.Context, includeRetired bool) ([]entity.QcDefectType, error)
+		// CreateQcDefectType / UpdateQcDefectType пишут пункт каталога; занятый код и незнакомая
+		// работа — *entity.ValidationError.
+		CreateQcDefectType(ctx context.Context, ins entity.QcDefectTypeInsert, username string) (*entity.QcDefectType, error)
+		UpdateQcDefectType(ctx context.Context, id int, ins entity.QcDefectTypeInsert) (*entity.QcDefectType, error)
+		// SetQcDefectTypeRetired снимает пункт с бланка или возвращает его.
+		SetQcDefectTypeRetired(ctx context.Context, id int, retired bool) (*entity.QcDefectType, error)
+		// CreateQcInspection открывает инспекцию партии с планом, посчитанным internal/qcsampling;
+		// sql.ErrNoRows, когда прогона нет.
+		CreateQcInspection(ctx context.Context, ins entity.QcInspectionInsert) (*entity.QcInspection, error)
+		// ListQcInspections — инспекции прогона с находками, новые первыми.
+		ListQcInspections(ctx context.Context, runID int) ([]entity.QcInspection, error)
+		// GetQcInspection — одна инспекция прогона; entity.ErrQcInspectionNotFound, когда её нет.
+		GetQcInspection(ctx context.Context, runID, id int) (*entity.QcInspection, error)
+		// RecordQcFindings заменяет находки неиспользованной инспекции и выносит вердикт по её плану.
+		RecordQcFindings(ctx context.Context, runID, inspectionID int, findings []entity.QcFindingInput, username string) (*entity.QcInspection, error)
+		// DeleteQcInspection удаляет неиспользованную инспекцию.
+		DeleteQcInspection(ctx context.Context, runID, inspectionID int) error
+		// GetQcDefectAnalytics — доля дефектов решённых за период инспекций по моделям, операциям и
+		// цехам.
+		GetQcDefectAnalytics(ctx context.Context, f entity.QcDefectAnalyticsFilter) (*entity.QcDefectAnalytics, error)
+
+		// ПОДРЯД НА ПОШИВ (migration 0344): профили фабрик, соглашения о цене пошива, комплект на
+		// консигнации и сверка. Гейт финальной приёмки — в PostProductionRunReceipt.
+		//
+		// ListSubcontractors отдаёт профили по имени; архивные — только по просьбе.
+		ListSubcontractors(ctx context.Context, includeArchived bool) ([]entity.Subcontractor, error)
+		// UpsertSubcontractor создаёт или заменяет профиль поставщика из каталога; незнакомый
+		// поставщик — *entity.ValidationError.
+		UpsertSubcontractor(ctx context.Context, ins entity.SubcontractorInsert, username string) (*entity.Subcontractor, error)
+		// SetSubcontractorArchived снимает фабрику с назначения или возвращает её.
+		SetSubcontractorArchived(ctx context.Context, supplierID int, archived bool) (*entity.Subcontractor, error)
+		// ListCmtPriceAgreements — соглашения по фильтру, новые периоды первыми.
+		ListCmtPriceAgreements(ctx context.Context, f entity.CmtPriceAgreementFilter) ([]entity.CmtPriceAgreement, error)
+		// CreateCmtPriceAgreement открывает соглашение; пересечение периодов —
+		// entity.ErrCmtAgreementOverlap.
+		CreateCmtPriceAgreement(ctx context.Context, ins entity.CmtPriceAgreementInsert, username string) (*entity.CmtPriceAgreement, error)
+		// CloseCmtPriceAgreement закрывает соглашение датой (последний день действия).
+		CloseCmtPriceAgreement(ctx context.Context, id int, validTo time.Time) (*entity.CmtPriceAgreement, error)
+		// AssignRunSubcontractor отдаёт прогон фабрике по цене соглашения (agreementID 0 — действующее
+		// сегодня); смена фабрики после движения комплекта — entity.ErrSubcontractKitShipped.
+		AssignRunSubcontractor(ctx context.Context, runID, supplierID, agreementID int, username string) (*entity.ProductionRunSubcontract, error)
+		// GetRunSubcontract — подряд прогона с комплектом; entity.ErrSubcontractNotFound, когда его нет.
+		GetRunSubcontract(ctx context.Context, runID int) (*entity.ProductionRunSubcontract, error)
+		// UpdateRunSubcontractPortal ротирует, отзывает или передатирует ссылку фабрики.
+		UpdateRunSubcontractPortal(ctx context.Context, runID int, upd entity.SubcontractPortalUpdate) (*entity.ProductionRunSubcontract, error)
+		// ShipSubcontractKit / ReturnSubcontractKit двигают комплект к фабрике и обратно, все строки
+		// одной транзакцией.
+		ShipSubcontractKit(ctx context.Context, mv entity.SubcontractKitMove) ([]entity.MaterialMovement, error)
+		ReturnSubcontractKit(ctx context.Context, mv entity.SubcontractKitMove) ([]entity.MaterialMovement, error)
+		// ConfirmRunSubcontract пишет подтверждение фабрики из портала.
+		ConfirmRunSubcontract(ctx context.Context, runID int, c entity.SubcontractConfirmation) (*entity.ProductionRunSubcontract, error)
+		// ReconcileRunSubcontract закрывает комплект: остаток у фабрики — в прогон, пошив — строкой
+		// затрат kind='cmt'.
+		ReconcileRunSubcontract(ctx context.Context, in entity.SubcontractReconcileInput) (*entity.ProductionRunSubcontract, error)
 	}
 
 	// Samples is the sample (сэмпл) repository (new-flow NF-04): a sewn prototype of a style, with
@@ -1350,6 +1513,40 @@ type (
 		// back to the catalogue width on an absent key rather than inventing one. кромка is NOT
 		// subtracted here; the comparison rule needs the raw figure to show its arithmetic.
 		NarrowestMeasuredLotWidths(ctx context.Context, materialIDs []int) (map[int]decimal.NullDecimal, error)
+		// RecordMaterialLotLabTest appends a lab result (shade band, shrinkage, GSM) to a lot's history
+		// and makes it the lot's current result unless it is back-dated behind a newer one (0348).
+		// sql.ErrNoRows when the lot does not exist.
+		RecordMaterialLotLabTest(ctx context.Context, ins entity.MaterialLotLabTestInsert, username string) (entity.MaterialLotLabTest, error)
+		// ListMaterialLotLabTests returns a lot's lab history, newest first.
+		ListMaterialLotLabTests(ctx context.Context, lotID int) ([]entity.MaterialLotLabTest, error)
+	}
+
+	// PurchaseOrders are material purchase orders (0336): drafts, sending, receiving against an order
+	// (which books ordinary purchase receipts in the same transaction), and the open quantities and
+	// observed supplier lead times that planning reads.
+	PurchaseOrders interface {
+		CreatePurchaseOrder(ctx context.Context, ins entity.PurchaseOrderInsert) (int, error)
+		UpdatePurchaseOrder(ctx context.Context, id int, ins entity.PurchaseOrderInsert) error
+		GetPurchaseOrder(ctx context.Context, id int) (*entity.PurchaseOrderFull, error)
+		ListPurchaseOrders(ctx context.Context, limit, offset int, f entity.PurchaseOrderFilter) ([]entity.PurchaseOrderFull, int, error)
+		SetPurchaseOrderStatus(ctx context.Context, id int, to entity.PurchaseOrderStatus) error
+		ReceivePurchaseOrder(ctx context.Context, r entity.PurchaseOrderReceipt) ([]entity.MaterialMovement, error)
+		// OpenQuantities is the outstanding quantity per material on open orders, drafts included.
+		OpenQuantities(ctx context.Context, materialIDs []int) (map[int]decimal.Decimal, error)
+		SupplierLeadTimes(ctx context.Context) ([]entity.SupplierLeadTime, error)
+	}
+
+	// SalesInvoices are the sales invoices and correction invoices of orders (0337), numbered
+	// gaplessly per series and month. Invoices are immutable once issued; a refund is answered with a
+	// correction, and the KSeF reference is the only field set later.
+	SalesInvoices interface {
+		IssueSalesInvoice(ctx context.Context, orderUUID string, issueDate time.Time, createdBy string) (int, error)
+		// IssueCorrectionInvoice corrects invoiceID for the refunds earlier corrections do not cover;
+		// ErrSalesInvoiceNothingToCorrect when there are none.
+		IssueCorrectionInvoice(ctx context.Context, invoiceID int, reason string, issueDate time.Time, createdBy string) (int, error)
+		GetSalesInvoice(ctx context.Context, id int) (*entity.SalesInvoiceFull, error)
+		ListSalesInvoices(ctx context.Context, limit, offset int, f entity.SalesInvoiceFilter) ([]entity.SalesInvoice, int, error)
+		SetSalesInvoiceKsef(ctx context.Context, id int, reference, hash string, submittedAt time.Time) error
 	}
 
 	// Accounting is the double-entry general ledger (docs/plan-accounting/). The ledger is a DERIVED,
@@ -1527,10 +1724,15 @@ type (
 		// ListDevExpensesForPosting returns tech_card_dev_expense rows created on/after startDate, for the
 		// wave-3 6210 dev-expense pull (3.2) — a full reconcile scan (the table has no updated_at).
 		ListDevExpensesForPosting(ctx context.Context, startDate time.Time) ([]entity.AcctDevExpenseFacts, error)
+		// ListPayslipsForPosting returns the approved payslips of months from startDate's month on, for
+		// the 6330 payroll pull (0341) — a full reconcile scan: a reopened payslip drops out and is
+		// reversed.
+		ListPayslipsForPosting(ctx context.Context, startDate time.Time) ([]entity.AcctPayslipFacts, error)
 
 		// --- wave 4: Revolut bank inbox (4.1) ---
-		// ImportBankTxns deduplicates parsed inbox lines into acct_bank_txn (external_id UNIQUE), applies
-		// the acct_bank_rule substring suggestions, and reports parsed/imported/skipped counts.
+		// ImportBankTxns deduplicates parsed inbox lines into acct_bank_txn (external_id UNIQUE), auto-matches
+		// them to open receivables / payables / Stripe payouts, applies the acct_bank_rule substring
+		// suggestions to the rest, and reports parsed/imported/skipped counts.
 		ImportBankTxns(ctx context.Context, txns []entity.AcctBankTxnInsert) (entity.AcctBankImportResult, error)
 		// ListBankTxns returns inbox lines filtered by state ("" = all), newest first, bounded to limit.
 		ListBankTxns(ctx context.Context, state string, limit int) ([]entity.AcctBankTxn, error)
@@ -1547,6 +1749,14 @@ type (
 		CreateBankRule(ctx context.Context, pattern, accountCode string) (int, error)
 		// DeleteBankRule removes a suggestion rule (sql.ErrNoRows when absent).
 		DeleteBankRule(ctx context.Context, id int) error
+		// ListBankCsvMappings returns the registered bank CSV column mappings (0338).
+		ListBankCsvMappings(ctx context.Context) ([]entity.AcctBankCsvMapping, error)
+		// GetBankCsvMapping loads the mapping of a source (sql.ErrNoRows when absent).
+		GetBankCsvMapping(ctx context.Context, source string) (*entity.AcctBankCsvMapping, error)
+		// SaveBankCsvMapping creates or replaces the mapping of m.Source.
+		SaveBankCsvMapping(ctx context.Context, m entity.AcctBankCsvMapping) error
+		// DeleteBankCsvMapping removes a mapping (sql.ErrNoRows when absent).
+		DeleteBankCsvMapping(ctx context.Context, source string) error
 
 		// --- wave 4: Stripe disputes (4.3) ---
 		// GetEntryBySource returns the journal-entry header for a (source_type, source_key), sql.ErrNoRows
@@ -1556,6 +1766,8 @@ type (
 		// --- wave 4: AP/AR subledgers (4.4) ---
 		// CreateSupplier inserts a supplier (unique name) and returns its id.
 		CreateSupplier(ctx context.Context, in entity.SupplierInsert) (int, error)
+		// UpdateSupplier replaces a supplier's editable fields; sql.ErrNoRows for an unknown id.
+		UpdateSupplier(ctx context.Context, id int, in entity.SupplierInsert) error
 		// ListSuppliers returns the supplier catalog, name-ordered.
 		ListSuppliers(ctx context.Context) ([]entity.Supplier, error)
 		// GetPayables returns the open Accounts-Payable (2010) position per supplier (accrued − paid).
@@ -1806,6 +2018,12 @@ type (
 		// UpdateSettings applies a partial patch (a setting the patch does not name keeps its stored
 		// value) and returns the resulting configuration.
 		UpdateSettings(ctx context.Context, patch entity.WorkshopSettingsPatch, updatedBy string) (*entity.WorkshopSettings, error)
+		// ListHolidays returns the closed days in [from, to] (zero bounds do not bound), oldest first.
+		ListHolidays(ctx context.Context, from, to time.Time) ([]entity.WorkshopHoliday, error)
+		// UpsertHoliday closes a day (0342); re-closing a day replaces its note.
+		UpsertHoliday(ctx context.Context, day time.Time, note sql.NullString, createdBy string) error
+		// DeleteHoliday reopens a day; sql.ErrNoRows when it was not closed.
+		DeleteHoliday(ctx context.Context, day time.Time) error
 	}
 
 	// PatternObjects manages pattern_object_access rows — per-object revocation epoch,
@@ -1838,6 +2056,163 @@ type (
 		RecordCardViewerAccess(ctx context.Context, counts map[int]int64, last map[int]time.Time) error
 	}
 
+	// Audit is the admin-wide audit trail (admin_audit_event, 0339): every mutating admin RPC, written
+	// by the auditlog interceptor after the handler returned and purged past the retention window.
+	Audit interface {
+		InsertAuditEvent(ctx context.Context, e *entity.AuditEvent) error
+		ListAuditEvents(ctx context.Context, f entity.AuditEventFilter) ([]entity.AuditEvent, int, error)
+		// PurgeAuditEvents deletes up to limit events older than cutoff and returns how many went.
+		PurgeAuditEvents(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	}
+
+	// Payroll is piece-rate pay (0341): an employee's pay periods (scheme, currency, per-minute and
+	// per-piece rates) and the monthly payslips computed from the bundle scans they recorded.
+	Payroll interface {
+		// UpsertPayPeriod inserts (id==0) or replaces a pay period with its per-piece rates.
+		// entity.ErrEmployeeNotFound, entity.ErrPayPeriodNotFound (id not of that employee),
+		// entity.ErrPayPeriodOverlap when another period of the employee shares a month.
+		UpsertPayPeriod(ctx context.Context, ins entity.EmployeePayPeriodInsert, id int, username string) (int, error)
+		// DeletePayPeriod removes a period; payslips computed with it keep their snapshot.
+		DeletePayPeriod(ctx context.Context, id int) error
+		// ListPayPeriods returns an employee's periods (every employee's when employeeID is 0).
+		ListPayPeriods(ctx context.Context, employeeID int) ([]entity.EmployeePayPeriod, error)
+		// ComputePayslip (re)computes the draft payslip of an employee-month and returns its id.
+		// entity.ErrPayslipApproved when the month is already approved, entity.ErrPayPeriodNotFound
+		// when no period covers it.
+		ComputePayslip(ctx context.Context, in entity.PayslipCompute) (int, error)
+		// ApprovePayslip freezes a draft and folds its gross to base currency at month end.
+		// entity.ErrPayslipStale / ErrPayslipOpexOverlap / ErrPayslipFxMissing refuse it.
+		ApprovePayslip(ctx context.Context, id int, username string) error
+		// ReopenPayslip returns an approved payslip to draft (the ledger entry is reversed).
+		ReopenPayslip(ctx context.Context, id int) error
+		// DeletePayslip removes a draft; entity.ErrPayslipApproved for an approved one.
+		DeletePayslip(ctx context.Context, id int) error
+		GetPayslip(ctx context.Context, id int) (*entity.Payslip, error)
+		// ListPayslips returns payslip summaries without lines, newest month first.
+		ListPayslips(ctx context.Context, f entity.PayslipFilter) ([]entity.Payslip, error)
+	}
+
+	// StockLocations are the named places stock lies in (0345): per-location quantities of variants,
+	// materials and lots, and the transfer documents moving them. The default location's quantities
+	// are derived from the totals, which every location-unaware writer keeps writing.
+	StockLocations interface {
+		ListStockLocations(ctx context.Context, includeArchived bool) ([]entity.StockLocation, error)
+		// GetStockLocation returns entity.ErrStockLocationNotFound for an unknown id.
+		GetStockLocation(ctx context.Context, id int) (*entity.StockLocation, error)
+		// CreateStockLocation returns entity.ErrStockLocationCodeTaken for a code in use.
+		CreateStockLocation(ctx context.Context, ins entity.StockLocationInsert) (int, error)
+		UpdateStockLocation(ctx context.Context, id int, ins entity.StockLocationInsert) error
+		// SetStockLocationArchived refuses the default location (entity.ErrStockLocationDefault) and one
+		// still holding stock, open transfers or paid orders (entity.ErrStockLocationNotEmpty).
+		SetStockLocationArchived(ctx context.Context, id int, archived bool) error
+		// ListLocationStock returns everything a location holds.
+		ListLocationStock(ctx context.Context, locationID int) (*entity.LocationStock, error)
+		// CreateStockTransfer stores a draft transfer between two active locations and returns its id.
+		CreateStockTransfer(ctx context.Context, ins entity.StockTransferInsert) (int, error)
+		GetStockTransfer(ctx context.Context, id int) (*entity.StockTransferFull, error)
+		ListStockTransfers(ctx context.Context, f entity.StockTransferFilter) ([]entity.StockTransfer, int, error)
+		// DispatchStockTransfer takes the lines out of the source (entity.ErrInsufficientLocationStock
+		// when it holds less); ReceiveStockTransfer puts them into the destination; CancelStockTransfer
+		// returns a dispatched transfer's lines to the source. entity.ErrStockTransferState otherwise.
+		DispatchStockTransfer(ctx context.Context, id int, username string) error
+		ReceiveStockTransfer(ctx context.Context, id int, username string) error
+		CancelStockTransfer(ctx context.Context, id int, username string) error
+	}
+
+	// Pricing is market price lists and scheduled markdowns (0349). A price list derives one
+	// currency's product_price from the base price, the costing FX rate, a markup and a rounding rule,
+	// and writes it only on publish; a markdown event sets product.sale_percentage for a window.
+	Pricing interface {
+		ListPriceLists(ctx context.Context) ([]entity.PriceList, error)
+		// GetPriceList returns entity.ErrPriceListNotFound for an unknown id.
+		GetPriceList(ctx context.Context, id int) (*entity.PriceList, error)
+		CreatePriceList(ctx context.Context, ins entity.PriceListInsert) (int, error)
+		UpdatePriceList(ctx context.Context, id int, ins entity.PriceListInsert) error
+		DeletePriceList(ctx context.Context, id int) error
+		PreviewPriceList(ctx context.Context, id int) (*entity.PriceListPreview, error)
+		// PublishPriceList writes the previewed prices. entity.ErrPriceListRateMissing without a rate;
+		// entity.ErrPriceListRateMoved when expectedRate is set and the rate is no longer it.
+		PublishPriceList(ctx context.Context, id int, expectedRate decimal.NullDecimal, username string) (*entity.PriceListPreview, error)
+
+		ListMarkdownEvents(ctx context.Context, includeClosed bool) ([]entity.MarkdownEvent, error)
+		// GetMarkdownEvent returns entity.ErrMarkdownEventNotFound for an unknown id.
+		GetMarkdownEvent(ctx context.Context, id int) (*entity.MarkdownEvent, error)
+		CreateMarkdownEvent(ctx context.Context, ins entity.MarkdownEventInsert) (int, error)
+		// UpdateMarkdownEvent refuses an event no longer scheduled (entity.ErrMarkdownEventNotEditable).
+		UpdateMarkdownEvent(ctx context.Context, id int, ins entity.MarkdownEventInsert) error
+		// CancelMarkdownEvent reverts an active event now; it returns the colourways that moved.
+		CancelMarkdownEvent(ctx context.Context, id int) ([]int, error)
+		PreviewMarkdownEvent(ctx context.Context, ins entity.MarkdownEventInsert, selfID int) ([]entity.MarkdownLine, error)
+		// ApplyDueMarkdownEvents ends and starts every event due at now.
+		ApplyDueMarkdownEvents(ctx context.Context, now time.Time) (*entity.MarkdownSweep, error)
+	}
+
+	// Wishlists is storefront account wishlists (0350) and the seen state of the alert worker.
+	Wishlists interface {
+		// ResolveWishlistRefs keeps the refs that name a storefront-visible colourway (and, for a
+		// variant SKU, an active grade-A size of it), in input order.
+		ResolveWishlistRefs(ctx context.Context, refs []entity.WishlistRef) ([]entity.WishlistTarget, error)
+		ListWishlistItems(ctx context.Context, accountID int) ([]entity.WishlistItem, error)
+		// AddWishlistItems adds the targets the wishlist does not hold yet and returns how many it
+		// added; entity.ErrWishlistFull (after adding what fits) when the rest is over the cap.
+		AddWishlistItems(ctx context.Context, accountID int, targets []entity.WishlistTarget) (int, error)
+		// RemoveWishlistItem returns entity.ErrWishlistItemNotFound when the wishlist does not hold ref.
+		RemoveWishlistItem(ctx context.Context, accountID int, ref entity.WishlistRef) error
+		ListMostWishedStyles(ctx context.Context, from, to time.Time, limit int) ([]entity.MostWishedStyle, error)
+		ListWishlistWatches(ctx context.Context) ([]entity.WishlistWatch, error)
+		RecordWishlistSeen(ctx context.Context, seen []entity.WishlistSeen) error
+	}
+
+	// Sizing is storefront fit profiles (0351) and the per-style data of the size recommender.
+	Sizing interface {
+		// GetFitProfile returns an empty regular-fit profile for an account that never saved one.
+		GetFitProfile(ctx context.Context, accountID int) (*entity.FitProfile, error)
+		// SaveFitProfile replaces the profile, measurements included.
+		SaveFitProfile(ctx context.Context, p *entity.FitProfile) error
+		// ResolveFitVariant returns sql.ErrNoRows unless the SKU is a storefront colourway's variant.
+		ResolveFitVariant(ctx context.Context, variantSKU string) (*entity.FitVariant, error)
+		GetStyleFitData(ctx context.Context, styleID int) (*entity.StyleFitData, error)
+	}
+
+	// CheckoutRecoveries is abandoned checkouts of consented customers (0352) and the state of their
+	// recovery email sequence.
+	CheckoutRecoveries interface {
+		// RecordAbandonedCheckout replaces the cart of the address's open checkout, or opens one.
+		RecordAbandonedCheckout(ctx context.Context, cart *entity.CheckoutRecoveryCart) error
+		ListOpenCheckoutRecoveries(ctx context.Context) ([]entity.CheckoutRecovery, error)
+		// ClosePurchasedCheckoutRecoveries closes the open checkouts whose customer has since paid.
+		ClosePurchasedCheckoutRecoveries(ctx context.Context) (converted, suppressed int, err error)
+		// SetCheckoutRecoveryIncentive stores a step's code before its email is queued; sql.ErrNoRows
+		// when the checkout already has one or is not waiting for that step.
+		SetCheckoutRecoveryIncentive(ctx context.Context, id, step int, code string) error
+		MarkCheckoutRecoveryStepSent(ctx context.Context, id, step int, incentiveCode string) error
+		CloseCheckoutRecovery(ctx context.Context, id int, status entity.CheckoutRecoveryStatus) error
+		// RestoreCheckoutRecovery and OptOutCheckoutRecovery return sql.ErrNoRows for an unknown token.
+		RestoreCheckoutRecovery(ctx context.Context, token string, step int) (*entity.CheckoutRecoveryRestore, error)
+		OptOutCheckoutRecovery(ctx context.Context, token string) error
+		GetCheckoutRecoveryMetrics(ctx context.Context, from, to time.Time) (*entity.CheckoutRecoveryMetrics, error)
+	}
+
+	// StockReservations is the durable hold ledger behind the MySQL stockreserve backend. "Live" means
+	// expires_at > Now(); expired rows are ignored by every read and removed by the sweeper.
+	StockReservations interface {
+		// LockProductSize row-locks the A-grade product_size row of the pair for the rest of the tx,
+		// serializing every reserve of that variant across instances.
+		LockProductSize(ctx context.Context, productID, sizeID int) error
+		// GetStockReservation returns the session's hold on the pair (expired or not); sql.ErrNoRows when absent.
+		GetStockReservation(ctx context.Context, productID, sizeID int, sessionID string) (*entity.StockReservation, error)
+		GetReservedQuantity(ctx context.Context, productID, sizeID int, excludeSessionID string) (decimal.Decimal, error)
+		CountSessionReservations(ctx context.Context, sessionID string) (int, error)
+		CountReservations(ctx context.Context) (int, error)
+		UpsertStockReservation(ctx context.Context, r *entity.StockReservationInsert) error
+		CommitSessionReservations(ctx context.Context, sessionID, orderUUID string, expiresAt time.Time) (int64, error)
+		DeleteOrderReservations(ctx context.Context, orderUUID string) (int64, error)
+		// DeleteSessionCartReservations frees only the holds not yet committed to an order.
+		DeleteSessionCartReservations(ctx context.Context, sessionID string) (int64, error)
+		DeleteExpiredStockReservations(ctx context.Context, limit int) (int64, error)
+		GetStockReservationStats(ctx context.Context) (*entity.StockReservationStats, error)
+	}
+
 	Waitlist interface {
 		AddToWaitlist(ctx context.Context, productId int, sizeId int, email string) error
 		GetWaitlistEntriesByProductSize(ctx context.Context, productId int, sizeId int) ([]entity.WaitlistEntry, error)
@@ -1861,6 +2236,8 @@ type (
 		TechCards() TechCards
 		ProductionRuns() ProductionRuns
 		MaterialStock() MaterialStock
+		PurchaseOrders() PurchaseOrders
+		SalesInvoices() SalesInvoices
 		Accounting() Accounting
 		Samples() Samples
 		Admin() Admin
@@ -1880,8 +2257,18 @@ type (
 		Settings() Settings
 		Workshop() Workshop
 		Support() Support
+		Returns() Returns
+		StoreCredit() StoreCredit
 		Language() Language
 		PatternObjects() PatternObjects
+		StockReservations() StockReservations
+		Audit() Audit
+		Payroll() Payroll
+		StockLocations() StockLocations
+		Pricing() Pricing
+		Wishlists() Wishlists
+		Sizing() Sizing
+		CheckoutRecoveries() CheckoutRecoveries
 		Tx(ctx context.Context, f func(context.Context, Repository) error) error
 		TxBegin(ctx context.Context) (Repository, error)
 		TxCommit(ctx context.Context) error
@@ -1962,6 +2349,10 @@ type (
 		// <object>/viewer remounts). download=true adds a content-disposition=attachment
 		// response override.
 		PresignPatternObject(ctx context.Context, objectKey string, download bool, downloadName string) (url string, expiresAt time.Time, err error)
+		// GetPatternObject reads a managed pattern object (a выкройка under the pattern folder) into
+		// memory, for the server's own DXF measurement. The key must come from a
+		// tech_card_size_pattern row; a key outside the pattern folder is refused, not fetched.
+		GetPatternObject(ctx context.Context, objectKey string) ([]byte, error)
 		// UploadLibraryObject streams a files-library payload into a PRIVATE object
 		// and returns its key, the hex sha256 computed from the stream, and the byte
 		// count. Privacy here is the ABSENCE of the public-read acl the media/pattern/
@@ -1993,6 +2384,10 @@ type (
 		// потолка заметки. Метод не должен УМЕТЬ вытащить произвольный объект бакета — чужой
 		// префикс и превышение размера это отказ, а не усечение.
 		GetLibraryObject(ctx context.Context, objectKey string) ([]byte, error)
+		// GetMediaObject reads a media-library object by its stored url — the spec sheet embeds
+		// technical sketches into the PDF. A url off the configured CDN/origin host is refused before
+		// the bucket is asked, and no more than the media upload cap is read.
+		GetMediaObject(ctx context.Context, mediaURL string) ([]byte, error)
 		// RemoveObjectsByKeys best-effort deletes objects addressed by KEY rather than
 		// url. DeleteObjects takes urls, which library files do not store — they keep
 		// keys, because a private object has no durable url to keep.
@@ -2009,6 +2404,13 @@ type (
 		RevalidateAll(ctx context.Context, revalidationData *dto.RevalidationData) error
 	}
 
+	// ProductSearch answers storefront search and autocomplete from the in-process index (package
+	// search). Queries are served from memory; tier gating is applied from the query's viewer tier.
+	ProductSearch interface {
+		Search(q entity.SearchQuery) (*entity.SearchResult, error)
+		Suggest(q entity.SuggestQuery) ([]entity.SearchSuggestion, error)
+	}
+
 	// Tracker is an external shipment-tracking provider (AfterShip). RegisterTracking makes the
 	// provider start monitoring a shipment (so it emits delivery webhooks); GetTrackingStatus
 	// polls the current normalized status (the delivery-sync worker's reconcile path). Behind an
@@ -2062,6 +2464,8 @@ type (
 		SendPendingReturn(ctx context.Context, rep Repository, to string, details *dto.OrderPendingReturn) error
 		SendPromoCode(ctx context.Context, rep Repository, to string, promoDetails *dto.PromoCodeDetails) error
 		SendBackInStock(ctx context.Context, rep Repository, to string, productDetails *dto.BackInStock) error
+		QueueWishlistAlert(ctx context.Context, rep Repository, to string, kind entity.WishlistAlertKind, data *dto.WishlistAlert) error
+		QueueCheckoutRecovery(ctx context.Context, rep Repository, to string, data *dto.CheckoutRecoveryEmail) error
 		QueueAccountLogin(ctx context.Context, rep Repository, to string, otpCode string, magicLinkURL string) error
 		QueueTierUpgrade(ctx context.Context, rep Repository, to string, data *dto.TierChangeEmail) error
 		QueueTierDowngrade(ctx context.Context, rep Repository, to string, data *dto.TierChangeEmail) error
@@ -2095,8 +2499,16 @@ type (
 		Start(ctx context.Context) error
 	}
 
-	// StockReservationManager handles temporary stock holds
+	// StockReservationManager handles temporary stock holds. Two backends exist, selected by
+	// stock_reserve.backend: the in-process stockreserve.Manager and the MySQL-backed
+	// stockreserve.MySQLManager shared by every instance.
 	StockReservationManager interface {
+		// ReserveIfAvailable holds up to qty of totalStock for the session and returns what it got.
+		ReserveIfAvailable(ctx context.Context, totalStock decimal.Decimal, sessionID string, productID, sizeID int, qty decimal.Decimal) (decimal.Decimal, error)
+		// Commit hands the session's cart holds to a submitted order (order TTL).
+		Commit(ctx context.Context, sessionID string, orderUUID string)
 		Release(ctx context.Context, orderUUID string)
+		ReleaseSession(ctx context.Context, sessionID string)
+		Stop()
 	}
 )
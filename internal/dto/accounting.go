@@ -806,9 +806,76 @@ func ConvertAcctBankTxnToPb(t entity.AcctBankTxn) *pb_admin.AcctBankTxn {
 	if t.IgnoreReason.Valid {
 		pb.IgnoreReason = t.IgnoreReason.String
 	}
+	if t.MatchKind.Valid {
+		pb.MatchKind = t.MatchKind.String
+	}
+	if t.MatchRef.Valid {
+		pb.MatchRef = t.MatchRef.String
+	}
+	if t.SupplierId.Valid {
+		pb.SupplierId = int32(t.SupplierId.Int64)
+	}
 	return pb
 }
 
+// ConvertPbAcctBankCsvMapping converts a wire CSV mapping; accounting.NewMappedCsvParser validates it.
+func ConvertPbAcctBankCsvMapping(pb *pb_admin.AcctBankCsvMapping) entity.AcctBankCsvMapping {
+	if pb == nil {
+		return entity.AcctBankCsvMapping{}
+	}
+	m := entity.AcctBankCsvMapping{
+		Source:             strings.TrimSpace(pb.GetSource()),
+		Delimiter:          pb.GetDelimiter(),
+		SkipLines:          int(pb.GetSkipLines()),
+		DateColumn:         strings.TrimSpace(pb.GetDateColumn()),
+		DateLayout:         strings.TrimSpace(pb.GetDateLayout()),
+		AmountColumn:       strings.TrimSpace(pb.GetAmountColumn()),
+		DebitColumn:        strings.TrimSpace(pb.GetDebitColumn()),
+		CreditColumn:       strings.TrimSpace(pb.GetCreditColumn()),
+		DecimalComma:       pb.GetDecimalComma(),
+		CurrencyColumn:     strings.TrimSpace(pb.GetCurrencyColumn()),
+		Currency:           strings.ToUpper(strings.TrimSpace(pb.GetCurrency())),
+		CounterpartyColumn: strings.TrimSpace(pb.GetCounterpartyColumn()),
+		IdColumn:           strings.TrimSpace(pb.GetIdColumn()),
+	}
+	for _, c := range pb.GetDescriptionColumns() {
+		if c = strings.TrimSpace(c); c != "" {
+			m.DescriptionColumns = append(m.DescriptionColumns, c)
+		}
+	}
+	return m
+}
+
+// ConvertAcctBankCsvMappingToPb converts a stored CSV mapping.
+func ConvertAcctBankCsvMappingToPb(m entity.AcctBankCsvMapping) *pb_admin.AcctBankCsvMapping {
+	return &pb_admin.AcctBankCsvMapping{
+		Source:             m.Source,
+		Delimiter:          m.Delimiter,
+		SkipLines:          int32(m.SkipLines),
+		DateColumn:         m.DateColumn,
+		DateLayout:         m.DateLayout,
+		AmountColumn:       m.AmountColumn,
+		DebitColumn:        m.DebitColumn,
+		CreditColumn:       m.CreditColumn,
+		DecimalComma:       m.DecimalComma,
+		CurrencyColumn:     m.CurrencyColumn,
+		Currency:           m.Currency,
+		DescriptionColumns: m.DescriptionColumns,
+		CounterpartyColumn: m.CounterpartyColumn,
+		IdColumn:           m.IdColumn,
+		UpdatedAt:          m.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// ConvertAcctBankCsvMappingListToPb converts the registered CSV mappings.
+func ConvertAcctBankCsvMappingListToPb(list []entity.AcctBankCsvMapping) []*pb_admin.AcctBankCsvMapping {
+	out := make([]*pb_admin.AcctBankCsvMapping, 0, len(list))
+	for _, m := range list {
+		out = append(out, ConvertAcctBankCsvMappingToPb(m))
+	}
+	return out
+}
+
 // ConvertAcctAlertsToPb converts the aggregated tab-dot flags.
 func ConvertAcctAlertsToPb(a entity.AcctAlerts) *pb_admin.GetAcctAlertsResponse {
 	return &pb_admin.GetAcctAlertsResponse{
@@ -863,6 +930,9 @@ func ConvertSupplierToPb(s entity.Supplier) *pb_admin.Supplier {
 	if s.Notes.Valid {
 		pb.Notes = s.Notes.String
 	}
+	if s.LeadTimeDays.Valid {
+		pb.LeadTimeDays = int32(s.LeadTimeDays.Int64)
+	}
 	return pb
 }
 
@@ -877,17 +947,38 @@ func ConvertSupplierListToPb(list []entity.Supplier) []*pb_admin.Supplier {
 
 // ConvertPbCreateSupplier validates a create-supplier request into an insert payload.
 func ConvertPbCreateSupplier(req *pb_admin.CreateSupplierRequest) (entity.SupplierInsert, error) {
-	name := strings.TrimSpace(req.GetName())
+	return supplierInsert(req.GetName(), req.GetVatId(), req.GetNotes(), req.GetLeadTimeDays())
+}
+
+// ConvertPbUpdateSupplier validates an update-supplier request: the full replacement of the
+// supplier's editable fields.
+func ConvertPbUpdateSupplier(req *pb_admin.UpdateSupplierRequest) (int, entity.SupplierInsert, error) {
+	if req.GetId() <= 0 {
+		return 0, entity.SupplierInsert{}, fmt.Errorf("id is required")
+	}
+	ins, err := supplierInsert(req.GetName(), req.GetVatId(), req.GetNotes(), req.GetLeadTimeDays())
+	return int(req.GetId()), ins, err
+}
+
+// maxSupplierLeadTimeDays bounds a promised lead time: a year is already a forecast, not a promise.
+const maxSupplierLeadTimeDays = 366
+
+func supplierInsert(name, vatID, notes string, leadTimeDays int32) (entity.SupplierInsert, error) {
+	name = strings.TrimSpace(name)
 	if name == "" {
 		return entity.SupplierInsert{}, fmt.Errorf("name is required")
 	}
 	if len(name) > maxAcctName {
 		return entity.SupplierInsert{}, fmt.Errorf("name must be at most %d characters", maxAcctName)
 	}
+	if leadTimeDays < 0 || leadTimeDays > maxSupplierLeadTimeDays {
+		return entity.SupplierInsert{}, fmt.Errorf("lead_time_days must be between 0 and %d", maxSupplierLeadTimeDays)
+	}
 	return entity.SupplierInsert{
-		Name:  name,
-		VatId: nullStringFromPb(strings.TrimSpace(req.GetVatId())),
-		Notes: nullStringFromPb(strings.TrimSpace(req.GetNotes())),
+		Name:         name,
+		VatId:        nullStringFromPb(strings.TrimSpace(vatID)),
+		Notes:        nullStringFromPb(strings.TrimSpace(notes)),
+		LeadTimeDays: nullInt64FromPb(int64(leadTimeDays)),
 	}, nil
 }
 
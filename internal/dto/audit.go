@@ -0,0 +1,25 @@
+package dto
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EntityAuditEventToPb converts an admin audit trail row to its wire form.
+func EntityAuditEventToPb(e entity.AuditEvent) *pb_admin.AuditEvent {
+	return &pb_admin.AuditEvent{
+		Id:           e.Id,
+		OccurredAt:   timestamppb.New(e.OccurredAt),
+		Actor:        e.Actor,
+		Method:       e.Method,
+		Section:      e.Section,
+		TargetType:   e.TargetType,
+		TargetId:     e.TargetId.String,
+		StatusCode:   e.StatusCode,
+		ErrorMessage: e.ErrorMessage.String,
+		DurationMs:   int32(e.DurationMs),
+		RequestJson:  e.Request,
+		ResponseJson: e.Response.String,
+	}
+}
@@ -68,3 +68,39 @@ func ProductFullToBackInStock(product *entity.ColorwayFull, sizeId int, buyerNam
 		EmailB64:    base64.StdEncoding.EncodeToString([]byte(email)),
 	}
 }
+
+// WishlistAlert represents the data needed for the wishlist on-sale, low-stock and restock emails.
+type WishlistAlert struct {
+	BuyerName   string
+	ProductName string
+	Brand       string
+	Size        string // empty when the whole colourway is wished for
+	Thumbnail   string
+	ProductURL  string
+	SalePercent string // the markdown, e.g. "30"; on-sale alerts only
+	EmailB64    string
+}
+
+// ProductFullToWishlistAlert converts entity.ColorwayFull to WishlistAlert DTO. sizeId 0 leaves the
+// size out.
+func ProductFullToWishlistAlert(product *entity.ColorwayFull, sizeId int, buyerName string, email string) *WishlistAlert {
+	bis := ProductFullToBackInStock(product, sizeId, buyerName, email)
+	wa := &WishlistAlert{
+		BuyerName:   bis.BuyerName,
+		ProductName: bis.ProductName,
+		Brand:       bis.Brand,
+		Size:        bis.Size,
+		Thumbnail:   bis.Thumbnail,
+		ProductURL:  bis.ProductURL,
+		EmailB64:    bis.EmailB64,
+	}
+	if sizeId == 0 {
+		wa.Size = ""
+	}
+	if product.Product != nil {
+		if sp := product.Product.ProductDisplay.ProductBody.ProductBodyInsert.SalePercentage; sp.Valid {
+			wa.SalePercent = sp.Decimal.StringFixed(0)
+		}
+	}
+	return wa
+}
@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CheckoutRecoveryEmail represents the data needed for one email of the abandoned checkout sequence.
+type CheckoutRecoveryEmail struct {
+	Step           int // 1-based step of the sequence
+	OrderItems     []OrderItem
+	CurrencySymbol string
+	SubtotalPrice  string
+	IncentiveCode  string // empty unless this step carries the single-use code
+	RestoreURL     string
+	StopURL        string
+	EmailB64       string
+}
+
+// CheckoutRecoveryRestoreToPb converts a restored cart to the storefront response: order line inserts
+// addressed by variant SKU (R2), ready to send back to ValidateOrderItemsInsert.
+func CheckoutRecoveryRestoreToPb(r *entity.CheckoutRecoveryRestore) *pb_frontend.RestoreAbandonedCartResponse {
+	items := make([]*pb_common.OrderItemInsert, 0, len(r.Lines))
+	for _, l := range r.Lines {
+		items = append(items, &pb_common.OrderItemInsert{
+			VariantSku: l.VariantSKU,
+			Quantity:   int32(l.Quantity.IntPart()),
+		})
+	}
+	return &pb_frontend.RestoreAbandonedCartResponse{
+		Items:         items,
+		Currency:      r.Currency,
+		IncentiveCode: r.IncentiveCode,
+	}
+}
+
+// CheckoutRecoveryMetricsToPb converts the abandoned checkout report to proto.
+func CheckoutRecoveryMetricsToPb(m *entity.CheckoutRecoveryMetrics) *pb_admin.GetCheckoutRecoveryMetricsResponse {
+	steps := make([]*pb_admin.CheckoutRecoveryStepMetrics, 0, len(m.Steps))
+	for _, st := range m.Steps {
+		steps = append(steps, &pb_admin.CheckoutRecoveryStepMetrics{
+			Step:               int32(st.Step),
+			Sent:               int32(st.Sent),
+			Restored:           int32(st.Restored),
+			Conversions:        int32(st.Conversions),
+			Revenue:            pbDecimalFromDecimal(st.Revenue),
+			IncentivesSent:     int32(st.IncentivesSent),
+			IncentivesRedeemed: int32(st.IncentivesRedeemed),
+		})
+	}
+	return &pb_admin.GetCheckoutRecoveryMetricsResponse{
+		From:       timestamppb.New(m.From),
+		To:         timestamppb.New(m.To),
+		Recorded:   int32(m.Recorded),
+		Open:       int32(m.Open),
+		Converted:  int32(m.Converted),
+		Suppressed: int32(m.Suppressed),
+		OptedOut:   int32(m.OptedOut),
+		Expired:    int32(m.Expired),
+		Revenue:    pbDecimalFromDecimal(m.Revenue),
+		Steps:      steps,
+	}
+}
@@ -0,0 +1,124 @@
+package dto
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/sizerec"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var fitPreferencePbToEntity = map[pb_frontend.FitPreference]entity.FitPreference{
+	pb_frontend.FitPreference_FIT_PREFERENCE_UNKNOWN: entity.FitPreferenceRegular,
+	pb_frontend.FitPreference_FIT_PREFERENCE_CLOSE:   entity.FitPreferenceClose,
+	pb_frontend.FitPreference_FIT_PREFERENCE_REGULAR: entity.FitPreferenceRegular,
+	pb_frontend.FitPreference_FIT_PREFERENCE_RELAXED: entity.FitPreferenceRelaxed,
+}
+
+var fitPreferenceEntityToPb = map[entity.FitPreference]pb_frontend.FitPreference{
+	entity.FitPreferenceClose:   pb_frontend.FitPreference_FIT_PREFERENCE_CLOSE,
+	entity.FitPreferenceRegular: pb_frontend.FitPreference_FIT_PREFERENCE_REGULAR,
+	entity.FitPreferenceRelaxed: pb_frontend.FitPreference_FIT_PREFERENCE_RELAXED,
+}
+
+var sizeFitVerdictToPb = map[sizerec.Verdict]pb_frontend.SizeFitVerdict{
+	sizerec.VerdictTight: pb_frontend.SizeFitVerdict_SIZE_FIT_VERDICT_TIGHT,
+	sizerec.VerdictGood:  pb_frontend.SizeFitVerdict_SIZE_FIT_VERDICT_GOOD,
+	sizerec.VerdictLoose: pb_frontend.SizeFitVerdict_SIZE_FIT_VERDICT_LOOSE,
+}
+
+var sizeRecommendationBasisToPb = map[sizerec.Basis]pb_frontend.SizeRecommendationBasis{
+	sizerec.BasisMeasurements: pb_frontend.SizeRecommendationBasis_SIZE_RECOMMENDATION_BASIS_MEASUREMENTS,
+	sizerec.BasisReference:    pb_frontend.SizeRecommendationBasis_SIZE_RECOMMENDATION_BASIS_REFERENCE,
+	sizerec.BasisBoth:         pb_frontend.SizeRecommendationBasis_SIZE_RECOMMENDATION_BASIS_MEASUREMENTS_AND_REFERENCE,
+}
+
+// FitProfilePbToEntity validates a storefront fit profile. The reference variant SKU is returned as
+// is; the caller resolves it. A nil profile is an empty one.
+func FitProfilePbToEntity(pb *pb_frontend.FitProfile) (*entity.FitProfile, error) {
+	pref, ok := fitPreferencePbToEntity[pb.GetFitPreference()]
+	if !ok {
+		return nil, fmt.Errorf("unknown fit preference: %v", pb.GetFitPreference())
+	}
+	p := &entity.FitProfile{
+		Preference:          pref,
+		Measurements:        make([]entity.ModelMeasurement, 0, len(pb.GetMeasurements())),
+		ReferenceVariantSKU: pb.GetReferenceVariantSku(),
+	}
+	seen := make(map[entity.BodyMeasurementName]bool, len(pb.GetMeasurements()))
+	for _, m := range pb.GetMeasurements() {
+		name, ok := bodyMeasurementPbToEntity[m.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown measurement name: %v", m.Name)
+		}
+		if m.ValueMm <= 0 || m.ValueMm > maxBodyMeasurementMM {
+			return nil, fmt.Errorf("measurement %q value out of range (1..%d mm): %d", name, maxBodyMeasurementMM, m.ValueMm)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate measurement name: %q", name)
+		}
+		seen[name] = true
+		p.Measurements = append(p.Measurements, entity.ModelMeasurement{Name: name, ValueMM: int(m.ValueMm)})
+	}
+	return p, nil
+}
+
+// FitProfileToPb converts a stored fit profile to proto.
+func FitProfileToPb(p *entity.FitProfile) *pb_frontend.FitProfile {
+	measurements := make([]*pb_common.ModelMeasurement, 0, len(p.Measurements))
+	for _, m := range p.Measurements {
+		name, ok := bodyMeasurementEntityToPb[m.Name]
+		if !ok {
+			continue
+		}
+		measurements = append(measurements, &pb_common.ModelMeasurement{Name: name, ValueMm: int32(m.ValueMM)})
+	}
+	return &pb_frontend.FitProfile{
+		Measurements:        measurements,
+		FitPreference:       fitPreferenceEntityToPb[p.Preference],
+		ReferenceVariantSku: p.ReferenceVariantSKU,
+	}
+}
+
+// FitProfileUpdatedAtToPb is the profile's last save, nil when it was never saved.
+func FitProfileUpdatedAtToPb(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// SizeRecommendationToPb converts a recommendation for the storefront; variantSKUs maps a size to the
+// colourway's variant in it.
+func SizeRecommendationToPb(rec *sizerec.Recommendation, variantSKUs map[int]string) *pb_frontend.RecommendSizeResponse {
+	sizes := make([]*pb_frontend.SizeFit, 0, len(rec.Sizes))
+	for _, sf := range rec.Sizes {
+		pts := make([]*pb_frontend.SizeFitPoint, 0, len(sf.Points))
+		for _, pt := range sf.Points {
+			pts = append(pts, &pb_frontend.SizeFitPoint{
+				MeasurementName: pt.Measurement,
+				EaseMm:          int32(pt.EaseMM),
+				TargetEaseMm:    int32(pt.TargetEaseMM),
+				Verdict:         sizeFitVerdictToPb[pt.Verdict],
+			})
+		}
+		sizes = append(sizes, &pb_frontend.SizeFit{
+			Size:       storefrontPublicSize(sf.SizeId),
+			VariantSku: variantSKUs[sf.SizeId],
+			Points:     pts,
+		})
+	}
+	return &pb_frontend.RecommendSizeResponse{
+		Size:       storefrontPublicSize(rec.SizeId),
+		VariantSku: variantSKUs[rec.SizeId],
+		Confidence: rec.Confidence,
+		Basis:      sizeRecommendationBasisToPb[rec.Basis],
+		Calibrated: rec.Calibrated,
+		RunsSmall:  rec.RunsSmall,
+		RunsLarge:  rec.RunsLarge,
+		Sizes:      sizes,
+	}
+}
@@ -27,6 +27,8 @@ func ConvertEntityFulfillmentCardToPb(c *entity.FulfillmentCard) (*pb_common.Ful
 		ChecklistDone:  int32(c.ChecklistDone),
 		ChecklistTotal: int32(c.ChecklistTotal),
 		HasNotes:       c.HasNotes,
+		LocationId:     int32(c.LocationId),
+		LocationName:   c.LocationName,
 	}, nil
 }
 
@@ -82,9 +84,10 @@ func ConvertEntityOrderFulfillmentToPb(orderUUID string, f *entity.OrderFulfillm
 		return &pb_common.FulfillmentAnnotation{OrderUuid: orderUUID}
 	}
 	return &pb_common.FulfillmentAnnotation{
-		OrderUuid: f.OrderUuid,
-		Assignee:  f.Assignee,
-		Notes:     f.Notes.String,
-		Checklist: ConvertEntityFulfillmentChecklistToPb(f.Checklist),
+		OrderUuid:  f.OrderUuid,
+		Assignee:   f.Assignee,
+		Notes:      f.Notes.String,
+		Checklist:  ConvertEntityFulfillmentChecklistToPb(f.Checklist),
+		LocationId: f.LocationId.Int32,
 	}
 }
@@ -24,6 +24,8 @@ var materialMovementTypeToPb = map[entity.MaterialMovementType]pb_common.Materia
 	entity.MaterialMovementReturnSample:      pb_common.MaterialMovementType_MATERIAL_MOVEMENT_TYPE_RETURN_SAMPLE,
 	entity.MaterialMovementAdjustment:        pb_common.MaterialMovementType_MATERIAL_MOVEMENT_TYPE_ADJUSTMENT,
 	entity.MaterialMovementWriteoff:          pb_common.MaterialMovementType_MATERIAL_MOVEMENT_TYPE_WRITEOFF,
+	entity.MaterialMovementConsignOut:        pb_common.MaterialMovementType_MATERIAL_MOVEMENT_TYPE_CONSIGN_OUT,
+	entity.MaterialMovementConsignReturn:     pb_common.MaterialMovementType_MATERIAL_MOVEMENT_TYPE_CONSIGN_RETURN,
 }
 
 // materialMovementTypeFromPb is the reverse map for list filtering.
@@ -311,26 +313,27 @@ func ConvertPbAdjustMaterialStock(req *pb_admin.AdjustMaterialStockRequest) (ent
 // ConvertEntityMaterialMovementToPb converts a ledger row to pb.
 func ConvertEntityMaterialMovementToPb(m entity.MaterialMovement) *pb_common.MaterialMovement {
 	out := &pb_common.MaterialMovement{
-		Id:              int32(m.Id),
-		MaterialId:      int32(m.MaterialId),
-		MovementType:    materialMovementTypeToPb[m.MovementType],
-		Quantity:        pbDecimalFromDecimal(m.Quantity),
-		OnHandBefore:    pbDecimalFromDecimal(m.OnHandBefore),
-		OnHandAfter:     pbDecimalFromDecimal(m.OnHandAfter),
-		UnitCost:        pbDecimalFromNull(m.UnitCost),
-		Currency:        m.Currency.String,
-		UnitCostBase:    pbDecimalFromNull(m.UnitCostBase),
-		ProductionRunId: nullInt32Value(m.ProductionRunId),
-		SampleId:        nullInt32Value(m.SampleId),
-		TechCardId:      nullInt32Value(m.TechCardId),
-		ProductId:       nullInt32Value(m.ProductId),
-		LotId:           nullInt32Value(m.LotId),
-		Lot:             m.Lot.String,
-		SupplierDoc:     m.SupplierDoc.String,
-		Reason:          m.Reason.String,
-		Comment:         m.Comment.String,
-		AdminUsername:   m.AdminUsername,
-		CreatedAt:       timestamppb.New(m.CreatedAt),
+		Id:                  int32(m.Id),
+		MaterialId:          int32(m.MaterialId),
+		MovementType:        materialMovementTypeToPb[m.MovementType],
+		Quantity:            pbDecimalFromDecimal(m.Quantity),
+		OnHandBefore:        pbDecimalFromDecimal(m.OnHandBefore),
+		OnHandAfter:         pbDecimalFromDecimal(m.OnHandAfter),
+		UnitCost:            pbDecimalFromNull(m.UnitCost),
+		Currency:            m.Currency.String,
+		UnitCostBase:        pbDecimalFromNull(m.UnitCostBase),
+		ProductionRunId:     nullInt32Value(m.ProductionRunId),
+		SampleId:            nullInt32Value(m.SampleId),
+		TechCardId:          nullInt32Value(m.TechCardId),
+		ProductId:           nullInt32Value(m.ProductId),
+		LotId:               nullInt32Value(m.LotId),
+		PurchaseOrderLineId: nullInt32Value(m.PurchaseOrderLineId),
+		Lot:                 m.Lot.String,
+		SupplierDoc:         m.SupplierDoc.String,
+		Reason:              m.Reason.String,
+		Comment:             m.Comment.String,
+		AdminUsername:       m.AdminUsername,
+		CreatedAt:           timestamppb.New(m.CreatedAt),
 	}
 	if m.OccurredAt.Valid {
 		out.OccurredAt = timestamppb.New(m.OccurredAt.Time)
@@ -362,6 +365,7 @@ func ConvertEntityMaterialStockRowToPb(r entity.MaterialStockRow, baseCurrency s
 		MinStock:        pbDecimalFromNull(r.MinStock),
 		BelowMinStock:   r.BelowMinStock,
 		BaseCurrency:    baseCurrency,
+		TotalOnHand:     pbDecimalFromDecimal(r.TotalOnHand),
 	}
 }
 
@@ -571,10 +575,18 @@ func MaterialLotToPb(l entity.MaterialLot) *pb_common.MaterialLot {
 		// "0 cm wide" or as agreeing with the article's nominal width.
 		MeasuredWidthCm: pbDecimalFromNull(l.MeasuredWidthCm),
 		ShadeCode:       l.ShadeCode.String,
+		// 0348: the current lab result, absent where nothing was measured.
+		ShadeBand:        l.ShadeBand.String,
+		ShrinkageWarpPct: pbDecimalFromNull(l.ShrinkageWarpPct),
+		ShrinkageWeftPct: pbDecimalFromNull(l.ShrinkageWeftPct),
+		MeasuredGsm:      pbDecimalFromNull(l.MeasuredGsm),
 	}
 	if l.ReceivedAt.Valid {
 		pb.ReceivedAt = timestamppb.New(l.ReceivedAt.Time)
 	}
+	if l.LabTestedAt.Valid {
+		pb.LabTestedAt = timestamppb.New(l.LabTestedAt.Time)
+	}
 	return pb
 }
 
@@ -586,3 +598,51 @@ func MaterialLotListToPb(lots []entity.MaterialLot) []*pb_common.MaterialLot {
 	}
 	return out
 }
+
+// ConvertPbRecordMaterialLotLabTest converts a lab-result request (0348). Ranges are checked by
+// entity.ValidateMaterialLotLabTest in the store; here only what cannot be parsed is refused.
+func ConvertPbRecordMaterialLotLabTest(req *pb_admin.RecordMaterialLotLabTestRequest) (entity.MaterialLotLabTestInsert, error) {
+	ins := entity.MaterialLotLabTestInsert{
+		LotId:     int(req.GetLotId()),
+		ShadeBand: sql.NullString{String: req.GetShadeBand(), Valid: req.GetShadeBand() != ""},
+		Note:      sql.NullString{String: req.GetNote(), Valid: req.GetNote() != ""},
+	}
+	var err error
+	if ins.ShrinkageWarpPct, err = nullDecimalFromPb(req.GetShrinkageWarpPct()); err != nil {
+		return ins, fmt.Errorf("shrinkage_warp_pct: %w", err)
+	}
+	if ins.ShrinkageWeftPct, err = nullDecimalFromPb(req.GetShrinkageWeftPct()); err != nil {
+		return ins, fmt.Errorf("shrinkage_weft_pct: %w", err)
+	}
+	if ins.MeasuredGsm, err = nullDecimalFromPb(req.GetMeasuredGsm()); err != nil {
+		return ins, fmt.Errorf("measured_gsm: %w", err)
+	}
+	if t := req.GetTestedAt(); t != nil {
+		ins.TestedAt = sql.NullTime{Time: t.AsTime(), Valid: true}
+	}
+	return ins, nil
+}
+
+// MaterialLotLabTestToPb converts one stored lab result.
+func MaterialLotLabTestToPb(t entity.MaterialLotLabTest) *pb_common.MaterialLotLabTest {
+	return &pb_common.MaterialLotLabTest{
+		Id:               int32(t.Id),
+		LotId:            int32(t.LotId),
+		ShadeBand:        t.ShadeBand.String,
+		ShrinkageWarpPct: pbDecimalFromNull(t.ShrinkageWarpPct),
+		ShrinkageWeftPct: pbDecimalFromNull(t.ShrinkageWeftPct),
+		MeasuredGsm:      pbDecimalFromNull(t.MeasuredGsm),
+		Note:             t.Note.String,
+		TestedAt:         timestamppb.New(t.TestedAt),
+		TestedBy:         t.TestedBy,
+	}
+}
+
+// MaterialLotLabTestListToPb converts a lot's lab history.
+func MaterialLotLabTestListToPb(tests []entity.MaterialLotLabTest) []*pb_common.MaterialLotLabTest {
+	out := make([]*pb_common.MaterialLotLabTest, 0, len(tests))
+	for _, t := range tests {
+		out = append(out, MaterialLotLabTestToPb(t))
+	}
+	return out
+}
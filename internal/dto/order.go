@@ -227,8 +227,12 @@ func ConvertCommonOrderNewToEntity(commonOrder *pb_common.OrderNew) (*entity.Ord
 		PaymentMethod:     ConvertPbPaymentMethodToEntity(commonOrder.PaymentMethod),
 		ShipmentCarrierId: int(commonOrder.ShipmentCarrierId),
 		PromoCode:         commonOrder.PromoCode,
+		PromoCodes:        commonOrder.PromoCodes,
 		Currency:          commonOrder.Currency,
 		Locale:            localeutil.Canonical(commonOrder.Locale),
+		// Only the gift card code comes from the request; apply_store_credit is resolved to the
+		// session's account email by the handler.
+		StoreCredit: entity.StoreCreditRedemption{GiftCardCode: entity.NormalizeGiftCardCode(commonOrder.GiftCardCode)},
 	}, receivePromo
 }
 
@@ -280,6 +284,8 @@ func ConvertEntityOrderToPbCommonOrder(eOrder entity.Order) (*pb_common.Order, e
 	if eOrder.PromoId.Valid {
 		pbOrder.PromoId = int32(eOrder.PromoId.Int32)
 	}
+	pbOrder.StoreCreditApplied = &pb_decimal.Decimal{Value: RoundForCurrency(eOrder.StoreCreditApplied, eOrder.Currency).String()}
+	pbOrder.AmountDue = &pb_decimal.Decimal{Value: eOrder.AmountDue().String()}
 	return pbOrder, nil
 }
 
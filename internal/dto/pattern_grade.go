@@ -0,0 +1,123 @@
+package dto
+
+import (
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"github.com/shopspring/decimal"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PatternGradeRulesFromPb parses a full-replace grade rule set. A delta is optional (unset = 0),
+// signed, and must fit DECIMAL(8,3).
+func PatternGradeRulesFromPb(rules []*pb_admin.TechCardPatternGradeRule) ([]entity.PatternGradeRule, error) {
+	out := make([]entity.PatternGradeRule, 0, len(rules))
+	for i, r := range rules {
+		if r == nil {
+			return nil, entity.NewFieldViolation(fmt.Sprintf("rules[%d]", i), "required", "", "")
+		}
+		dx, err := gradeDeltaFromPb(r.DxCm, fmt.Sprintf("rules[%d].dx_cm", i))
+		if err != nil {
+			return nil, err
+		}
+		dy, err := gradeDeltaFromPb(r.DyCm, fmt.Sprintf("rules[%d].dy_cm", i))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entity.PatternGradeRule{
+			PieceLineKey: r.PieceLineKey,
+			PointNo:      int(r.PointNo),
+			DxCm:         dx,
+			DyCm:         dy,
+		})
+	}
+	return out, nil
+}
+
+func gradeDeltaFromPb(d *pb_decimal.Decimal, field string) (decimal.Decimal, error) {
+	nd, err := nullDecimalFromPb(d)
+	if err != nil {
+		return decimal.Zero, entity.NewFieldViolation(field, "not_a_decimal", "", err.Error())
+	}
+	if !nd.Valid {
+		return decimal.Zero, nil
+	}
+	if err := validateDecimalFits(field, nd.Decimal, 3, 100_000, true); err != nil {
+		return decimal.Zero, err
+	}
+	return nd.Decimal, nil
+}
+
+// PatternGradeRuleSetToPb converts a scope's stored rules; baseSizeID is the style's grade base.
+func PatternGradeRuleSetToPb(set *entity.PatternGradeRuleSet, baseSizeID int) *pb_admin.GetTechCardPatternGradeRulesResponse {
+	out := &pb_admin.GetTechCardPatternGradeRulesResponse{
+		Rules:      make([]*pb_admin.TechCardPatternGradeRule, 0, len(set.Rules)),
+		BaseSizeId: int32(baseSizeID),
+		UpdatedBy:  set.UpdatedBy,
+	}
+	for _, r := range set.Rules {
+		out.Rules = append(out.Rules, &pb_admin.TechCardPatternGradeRule{
+			PieceLineKey: r.PieceLineKey,
+			PointNo:      int32(r.PointNo),
+			DxCm:         pbDecimalFromDecimal(r.DxCm),
+			DyCm:         pbDecimalFromDecimal(r.DyCm),
+		})
+	}
+	if !set.UpdatedAt.IsZero() {
+		out.UpdatedAt = timestamppb.New(set.UpdatedAt)
+	}
+	return out
+}
+
+// PatternGradeRulesToEngine hands stored rules to the grading engine.
+func PatternGradeRulesToEngine(rules []entity.PatternGradeRule) []dxfpattern.GradeRule {
+	out := make([]dxfpattern.GradeRule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, dxfpattern.GradeRule{
+			PieceLineKey: r.PieceLineKey,
+			Point:        r.PointNo,
+			DxCm:         r.DxCm.InexactFloat64(),
+			DyCm:         r.DyCm.InexactFloat64(),
+		})
+	}
+	return out
+}
+
+// GradedPiecesToPb emits a grading's pieces with their contours; the point index is the rules'
+// point_no.
+func GradedPiecesToPb(pieces []dxfpattern.GradedPiece) []*pb_admin.TechCardGradedPiece {
+	out := make([]*pb_admin.TechCardGradedPiece, 0, len(pieces))
+	for _, gp := range pieces {
+		item := &pb_admin.TechCardGradedPiece{
+			PieceLineKey: gp.PieceLineKey,
+			SizeId:       int32(gp.SizeId),
+			Step:         int32(gp.Step),
+			BlockName:    gp.Piece.Block,
+			Boundary:     make([]*pb_admin.TechCardPatternPoint, 0, len(gp.Piece.Boundary)),
+			AreaCm2:      pbPositiveCm(gp.Piece.AreaCm2),
+			PerimeterCm:  pbPositiveCm(gp.Piece.PerimeterCm),
+		}
+		for _, p := range gp.Piece.Boundary {
+			item.Boundary = append(item.Boundary, &pb_admin.TechCardPatternPoint{XCm: p.X, YCm: p.Y})
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// GradedSizeFilesToPb emits the per-size DXF files, named after the card and the size.
+func GradedSizeFilesToPb(files []dxfpattern.SizeFile, techCardID int, scopeKey string) []*pb_admin.TechCardGradedSizeFile {
+	out := make([]*pb_admin.TechCardGradedSizeFile, 0, len(files))
+	for _, f := range files {
+		out = append(out, &pb_admin.TechCardGradedSizeFile{
+			SizeId:   int32(f.SizeId),
+			Size:     f.Size,
+			Filename: fmt.Sprintf("tc%d-%s-%s.dxf", techCardID, scopeKey, f.Size),
+			Dxf:      f.DXF,
+		})
+	}
+	return out
+}
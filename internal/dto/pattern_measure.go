@@ -0,0 +1,119 @@
+package dto
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+)
+
+// DxfPatternSummaryToPb is the upload-time reading of one DXF file. parseErr set means the file
+// was stored but could not be read; the summary then carries only that.
+func DxfPatternSummaryToPb(p *dxfpattern.Pattern, parseErr error) *pb_admin.DxfPatternSummary {
+	if parseErr != nil {
+		return &pb_admin.DxfPatternSummary{ParseError: parseErr.Error()}
+	}
+	if p == nil {
+		return nil
+	}
+	out := &pb_admin.DxfPatternSummary{
+		Unit:       string(p.Unit),
+		UnitSource: p.UnitSource,
+		BlockCount: int32(len(p.Pieces)),
+		SampleSize: p.SampleSize,
+		Warnings:   p.Warnings,
+	}
+	for _, pc := range p.Pieces {
+		if pc.Measurable() {
+			out.MeasurableCount++
+		}
+	}
+	out.SizeTokens = dxfpattern.SizeTokens(dxfpattern.GradeSizes(p.Pieces))
+	return out
+}
+
+// PatternBlocksToPb emits the blocks of a measurement in file order.
+func PatternBlocksToPb(blocks []dxfpattern.MeasuredBlock) []*pb_admin.TechCardPatternBlock {
+	out := make([]*pb_admin.TechCardPatternBlock, 0, len(blocks))
+	for _, b := range blocks {
+		pc := b.Piece
+		item := &pb_admin.TechCardPatternBlock{
+			SheetLineKey:      b.SheetLineKey,
+			BlockName:         pc.Block,
+			PieceName:         pc.Name,
+			Stem:              b.Stem,
+			SizeToken:         b.SizeToken,
+			PieceLineKey:      b.PieceLineKey,
+			SizeId:            int32(b.SizeId),
+			SewLineAreaCm2:    pbPositiveCm(pc.SewLineAreaCm2),
+			HasGrainLine:      pc.GrainLine != nil,
+			GrainAngleDeg:     pc.GrainAngleDeg,
+			NotchCount:        int32(len(pc.Notches)),
+			Quantity:          int32(pc.Quantity),
+			AmbiguousBoundary: pc.AmbiguousBoundary,
+		}
+		if pc.Measurable() {
+			item.AreaCm2 = pbPositiveCm(pc.AreaCm2)
+			item.PerimeterCm = pbPositiveCm(pc.PerimeterCm)
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// PieceAreaInputsToPb emits a measured area set in the SaveTechCardPieceAreas shape: size 0 is the
+// ungraded sentinel, as in TechCardPieceAreaScopesToPb.
+func PieceAreaInputsToPb(rows []entity.PieceAreaInput) []*pb_common.TechCardPieceArea {
+	out := make([]*pb_common.TechCardPieceArea, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, &pb_common.TechCardPieceArea{
+			PieceLineKey:  r.PieceLineKey,
+			SizeId:        int32(r.SizeId.Int64),
+			AreaCm2:       pbDecimalFromDecimal(r.AreaCm2),
+			PerimeterCm:   pbDecimalFromNull(r.PerimeterCm),
+			Hulled:        r.Hulled,
+			AmbiguousPick: r.AmbiguousPick,
+		})
+	}
+	return out
+}
+
+// PatternFindingsToPb emits the findings of a measurement.
+func PatternFindingsToPb(findings []dxfpattern.Finding) []*pb_admin.TechCardPatternFinding {
+	out := make([]*pb_admin.TechCardPatternFinding, 0, len(findings))
+	for _, f := range findings {
+		out = append(out, &pb_admin.TechCardPatternFinding{
+			Code:     f.Code,
+			Blocking: f.Blocking,
+			Subject:  f.Subject,
+			Detail:   f.Detail,
+		})
+	}
+	return out
+}
+
+// PatternDriftToPb emits the stored-vs-files disagreements of a measurement.
+func PatternDriftToPb(items []dxfpattern.DriftItem) []*pb_admin.TechCardPatternDrift {
+	out := make([]*pb_admin.TechCardPatternDrift, 0, len(items))
+	for _, d := range items {
+		out = append(out, &pb_admin.TechCardPatternDrift{
+			Kind:         d.Kind,
+			PieceLineKey: d.PieceLineKey,
+			SizeId:       int32(d.SizeId),
+			Stored:       d.Stored,
+			Parsed:       d.Parsed,
+			Detail:       d.Detail,
+		})
+	}
+	return out
+}
+
+// pbPositiveCm rounds a measured length or area to the stored scale; nil for «not measured».
+func pbPositiveCm(v float64) *pb_decimal.Decimal {
+	if v <= 0 {
+		return nil
+	}
+	return pbDecimalFromDecimal(decimal.NewFromFloat(v).RoundBank(2))
+}
@@ -0,0 +1,265 @@
+package dto
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"github.com/shopspring/decimal"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Piece-rate payroll dto conversions (0341): pay periods in, payslips out. Months travel as YYYY-MM
+// like OPEX months; rates are DECIMAL(12,4), attended minutes DECIMAL(10,2), money DECIMAL(14,2).
+
+// parsePayrollDecimal reads an optional proto decimal, rejecting what its column would silently round
+// or overflow (see validateDecimalFits). Absent → invalid.
+func parsePayrollDecimal(d *pb_decimal.Decimal, field string, maxFrac int, limit int64, signed bool) (decimal.NullDecimal, error) {
+	if d == nil || strings.TrimSpace(d.Value) == "" {
+		return decimal.NullDecimal{}, nil
+	}
+	v, err := decimal.NewFromString(strings.TrimSpace(d.Value))
+	if err != nil {
+		return decimal.NullDecimal{}, fmt.Errorf("%s must be a number", field)
+	}
+	if err := validateDecimalFits(field, v, maxFrac, limit, signed); err != nil {
+		return decimal.NullDecimal{}, err
+	}
+	return decimal.NewNullDecimal(v), nil
+}
+
+// ConvertPbPayPeriodToEntity validates a pay period write. An efficiency period needs a per-minute
+// rate (chk_epp_efficiency); per-piece rates name each work at most once.
+func ConvertPbPayPeriodToEntity(p *pb_admin.EmployeePayPeriodInsert) (entity.EmployeePayPeriodInsert, error) {
+	if p == nil {
+		return entity.EmployeePayPeriodInsert{}, fmt.Errorf("pay period is required")
+	}
+	if p.EmployeeId <= 0 {
+		return entity.EmployeePayPeriodInsert{}, fmt.Errorf("pay period employee_id is required")
+	}
+	scheme := entity.PayScheme(strings.TrimSpace(p.Scheme))
+	if !entity.ValidPaySchemes[scheme] {
+		return entity.EmployeePayPeriodInsert{}, fmt.Errorf("invalid pay scheme %q: want piece_rate or efficiency", p.Scheme)
+	}
+	from, err := firstOfMonth(strings.TrimSpace(p.ValidFrom), "valid_from")
+	if err != nil {
+		return entity.EmployeePayPeriodInsert{}, err
+	}
+	var to sql.NullTime
+	if v := strings.TrimSpace(p.ValidTo); v != "" {
+		m, err := firstOfMonth(v, "valid_to")
+		if err != nil {
+			return entity.EmployeePayPeriodInsert{}, err
+		}
+		if m.Before(from) {
+			return entity.EmployeePayPeriodInsert{}, fmt.Errorf("valid_to precedes valid_from")
+		}
+		to = sql.NullTime{Time: m, Valid: true}
+	}
+	currency := normalizeCurrency(p.Currency)
+	if !IsExpenseCurrency(currency) {
+		return entity.EmployeePayPeriodInsert{}, fmt.Errorf("pay period currency must be a supported currency or USDT")
+	}
+	perMinute, err := parsePayrollDecimal(p.RatePerMinute, "rate_per_minute", 4, 100_000_000, false)
+	if err != nil {
+		return entity.EmployeePayPeriodInsert{}, err
+	}
+	if scheme == entity.PaySchemeEfficiency && !perMinute.Valid {
+		return entity.EmployeePayPeriodInsert{}, fmt.Errorf("an efficiency pay period needs rate_per_minute")
+	}
+	guaranteed, err := parsePayrollDecimal(p.GuaranteedRatePerMinute, "guaranteed_rate_per_minute", 4, 100_000_000, false)
+	if err != nil {
+		return entity.EmployeePayPeriodInsert{}, err
+	}
+	note := strings.TrimSpace(p.Note)
+	if len(note) > maxVarchar255 {
+		return entity.EmployeePayPeriodInsert{}, fmt.Errorf("pay period note must be at most %d characters", maxVarchar255)
+	}
+	rates := make([]entity.EmployeePieceRate, 0, len(p.PieceRates))
+	seen := make(map[string]bool, len(p.PieceRates))
+	for i, r := range p.PieceRates {
+		if r == nil {
+			continue
+		}
+		work := strings.TrimSpace(r.Work)
+		if work == "" {
+			return entity.EmployeePayPeriodInsert{}, fmt.Errorf("piece_rates[%d].work is required", i)
+		}
+		if seen[work] {
+			return entity.EmployeePayPeriodInsert{}, fmt.Errorf("piece_rates: work %q is listed twice", work)
+		}
+		seen[work] = true
+		rate, err := parsePayrollDecimal(r.RatePerPiece, fmt.Sprintf("piece_rates[%d].rate_per_piece", i), 4, 100_000_000, false)
+		if err != nil {
+			return entity.EmployeePayPeriodInsert{}, err
+		}
+		if !rate.Valid {
+			return entity.EmployeePayPeriodInsert{}, fmt.Errorf("piece_rates[%d].rate_per_piece is required", i)
+		}
+		rates = append(rates, entity.EmployeePieceRate{Work: work, RatePerPiece: rate.Decimal})
+	}
+	return entity.EmployeePayPeriodInsert{
+		EmployeeId:              int(p.EmployeeId),
+		Scheme:                  scheme,
+		ValidFrom:               from,
+		ValidTo:                 to,
+		Currency:                currency,
+		RatePerMinute:           perMinute,
+		GuaranteedRatePerMinute: guaranteed,
+		Note:                    trimmedNullString(note),
+		PieceRates:              rates,
+	}, nil
+}
+
+// PayPeriodToPb converts a stored pay period to protobuf.
+func PayPeriodToPb(p entity.EmployeePayPeriod) *pb_admin.EmployeePayPeriod {
+	ins := &pb_admin.EmployeePayPeriodInsert{
+		EmployeeId:              int32(p.EmployeeId),
+		Scheme:                  string(p.Scheme),
+		ValidFrom:               p.ValidFrom.Format("2006-01"),
+		Currency:                p.Currency,
+		RatePerMinute:           pbDecimalFromNull(p.RatePerMinute),
+		GuaranteedRatePerMinute: pbDecimalFromNull(p.GuaranteedRatePerMinute),
+		Note:                    p.Note.String,
+	}
+	if p.ValidTo.Valid {
+		ins.ValidTo = p.ValidTo.Time.Format("2006-01")
+	}
+	for _, r := range p.PieceRates {
+		ins.PieceRates = append(ins.PieceRates, &pb_admin.EmployeePieceRate{
+			Work:         r.Work,
+			RatePerPiece: pbDecimalFromDecimal(r.RatePerPiece),
+		})
+	}
+	return &pb_admin.EmployeePayPeriod{
+		Id:        int32(p.Id),
+		Period:    ins,
+		CreatedBy: p.CreatedBy,
+		CreatedAt: timestamppb.New(p.CreatedAt),
+		UpdatedAt: timestamppb.New(p.UpdatedAt),
+	}
+}
+
+// PayPeriodListToPb converts a slice of stored pay periods to protobuf.
+func PayPeriodListToPb(list []entity.EmployeePayPeriod) []*pb_admin.EmployeePayPeriod {
+	out := make([]*pb_admin.EmployeePayPeriod, 0, len(list))
+	for _, p := range list {
+		out = append(out, PayPeriodToPb(p))
+	}
+	return out
+}
+
+// ConvertPbPayslipComputeToEntity validates a payslip (re)computation. The adjustment is signed — a
+// bonus or a deduction; whether a deduction leaves the gross non-negative is payroll.Compute's call.
+func ConvertPbPayslipComputeToEntity(req *pb_admin.ComputePayslipRequest, username string) (entity.PayslipCompute, error) {
+	if req.GetEmployeeId() <= 0 {
+		return entity.PayslipCompute{}, fmt.Errorf("employee_id is required")
+	}
+	month, err := firstOfMonth(strings.TrimSpace(req.GetMonth()), "month")
+	if err != nil {
+		return entity.PayslipCompute{}, err
+	}
+	attended, err := parsePayrollDecimal(req.GetAttendedMinutes(), "attended_minutes", 2, 100_000_000, false)
+	if err != nil {
+		return entity.PayslipCompute{}, err
+	}
+	adjustment, err := parsePayrollDecimal(req.GetAdjustment(), "adjustment", 2, 1_000_000_000_000, true)
+	if err != nil {
+		return entity.PayslipCompute{}, err
+	}
+	note := strings.TrimSpace(req.GetAdjustmentNote())
+	if len(note) > maxVarchar255 {
+		return entity.PayslipCompute{}, fmt.Errorf("adjustment_note must be at most %d characters", maxVarchar255)
+	}
+	return entity.PayslipCompute{
+		EmployeeId:      int(req.GetEmployeeId()),
+		Month:           month,
+		AttendedMinutes: attended,
+		Adjustment:      adjustment.Decimal,
+		AdjustmentNote:  trimmedNullString(note),
+		ComputedBy:      username,
+	}, nil
+}
+
+// ConvertPbPayslipFilterToEntity reads the ListPayslips filter.
+func ConvertPbPayslipFilterToEntity(req *pb_admin.ListPayslipsRequest) (entity.PayslipFilter, error) {
+	f := entity.PayslipFilter{EmployeeId: int(req.GetEmployeeId())}
+	var err error
+	if v := strings.TrimSpace(req.GetFromMonth()); v != "" {
+		if f.From, err = firstOfMonth(v, "from_month"); err != nil {
+			return entity.PayslipFilter{}, err
+		}
+	}
+	if v := strings.TrimSpace(req.GetToMonth()); v != "" {
+		if f.To, err = firstOfMonth(v, "to_month"); err != nil {
+			return entity.PayslipFilter{}, err
+		}
+	}
+	switch st := entity.PayslipStatus(strings.TrimSpace(req.GetStatus())); st {
+	case "", entity.PayslipStatusDraft, entity.PayslipStatusApproved:
+		f.Status = st
+	default:
+		return entity.PayslipFilter{}, fmt.Errorf("invalid payslip status %q: want draft or approved", req.GetStatus())
+	}
+	return f, nil
+}
+
+// PayslipToPb converts a payslip (with its lines, when loaded) to protobuf.
+func PayslipToPb(p entity.Payslip) *pb_admin.Payslip {
+	out := &pb_admin.Payslip{
+		Id:                      int32(p.Id),
+		EmployeeId:              int32(p.EmployeeId),
+		EmployeeName:            p.EmployeeName,
+		Month:                   p.Month.Format("2006-01"),
+		PeriodId:                int32(p.PeriodId.Int64),
+		Scheme:                  string(p.Scheme),
+		Currency:                p.Currency,
+		RatePerMinute:           pbDecimalFromNull(p.RatePerMinute),
+		GuaranteedRatePerMinute: pbDecimalFromNull(p.GuaranteedRatePerMinute),
+		Status:                  string(p.Status),
+		AttendedMinutes:         pbDecimalFromNull(p.AttendedMinutes),
+		StandardMinutes:         pbDecimalFromDecimal(p.StandardMinutes),
+		EfficiencyPct:           pbDecimalFromNull(p.EfficiencyPct()),
+		PieceEarnings:           pbDecimalFromDecimal(p.PieceEarnings),
+		GuaranteedPay:           pbDecimalFromNull(p.GuaranteedPay),
+		Adjustment:              pbDecimalFromDecimal(p.Adjustment),
+		AdjustmentNote:          p.AdjustmentNote.String,
+		Gross:                   pbDecimalFromDecimal(p.Gross),
+		GrossBase:               pbDecimalFromNull(p.GrossBase),
+		UnpricedQty:             int32(p.UnpricedQty),
+		ComputedBy:              p.ComputedBy,
+		ComputedAt:              timestamppb.New(p.ComputedAt),
+		ApprovedBy:              p.ApprovedBy.String,
+	}
+	if p.ApprovedAt.Valid {
+		out.ApprovedAt = timestamppb.New(p.ApprovedAt.Time)
+	}
+	for _, l := range p.Lines {
+		out.Lines = append(out.Lines, &pb_admin.PayslipLine{
+			RunId:           int32(l.RunId),
+			OperationSeq:    int32(l.OperationSeq),
+			OperationType:   l.OperationType,
+			Work:            l.Work.String,
+			Basis:           string(l.Basis),
+			Qty:             int32(l.Qty),
+			Bundles:         int32(l.Bundles),
+			Smv:             pbDecimalFromNull(l.SMV),
+			StandardMinutes: pbDecimalFromNull(l.StandardMinutes),
+			Rate:            pbDecimalFromNull(l.Rate),
+			Amount:          pbDecimalFromDecimal(l.Amount),
+		})
+	}
+	return out
+}
+
+// PayslipListToPb converts a slice of payslips to protobuf.
+func PayslipListToPb(list []entity.Payslip) []*pb_admin.Payslip {
+	out := make([]*pb_admin.Payslip, 0, len(list))
+	for _, p := range list {
+		out = append(out, PayslipToPb(p))
+	}
+	return out
+}
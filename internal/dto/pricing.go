@@ -0,0 +1,241 @@
+package dto
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"github.com/shopspring/decimal"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Price list and markdown dto conversions (0349). Decimals are refused, not rounded, when they do not
+// fit their column (markup DECIMAL(6,2), pinned price DECIMAL(10,2), sale percentage DECIMAL(5,2)); the
+// ranges are checked by the entity validators.
+
+// ErrInvalidPricingInput wraps a malformed price list or markdown payload that is not a single field
+// violation (a missing body).
+var ErrInvalidPricingInput = errors.New("invalid pricing input")
+
+// ConvertPbPriceListInsertToEntity maps a price list payload.
+func ConvertPbPriceListInsertToEntity(p *pb_admin.PriceListInsert, username string) (entity.PriceListInsert, error) {
+	if p == nil {
+		return entity.PriceListInsert{}, fmt.Errorf("%w: price_list is required", ErrInvalidPricingInput)
+	}
+	markup, err := nullDecimalFromPb(p.MarkupPct)
+	if err != nil {
+		return entity.PriceListInsert{}, entity.NewFieldViolation("markup_pct", "invalid", p.MarkupPct.GetValue(), "enter a percentage, e.g. 12.5")
+	}
+	if markup.Valid {
+		if err := validateDecimalFits("markup_pct", markup.Decimal, 2, 1000, true); err != nil {
+			return entity.PriceListInsert{}, err
+		}
+	}
+	ins := entity.PriceListInsert{
+		Name:      p.Name,
+		Market:    p.Market,
+		Currency:  p.Currency,
+		MarkupPct: markup.Decimal,
+		Rounding:  entity.PriceRounding(strings.TrimSpace(p.Rounding)),
+		Note:      sql.NullString{String: p.Note, Valid: strings.TrimSpace(p.Note) != ""},
+		Pins:      make([]entity.PriceListPin, 0, len(p.Pins)),
+		CreatedBy: username,
+	}
+	for i, pin := range p.Pins {
+		field := fmt.Sprintf("pins[%d].price", i)
+		if pin == nil {
+			return entity.PriceListInsert{}, entity.NewFieldViolation(fmt.Sprintf("pins[%d]", i), "required", "", "")
+		}
+		price, err := nullDecimalFromPb(pin.Price)
+		if err != nil || !price.Valid {
+			return entity.PriceListInsert{}, entity.NewFieldViolation(field, "required", pin.Price.GetValue(), "enter the pinned price")
+		}
+		if err := validateDecimalFits(field, price.Decimal, 2, 100_000_000, false); err != nil {
+			return entity.PriceListInsert{}, err
+		}
+		ins.Pins = append(ins.Pins, entity.PriceListPin{ProductId: int(pin.ProductId), Price: price.Decimal})
+	}
+	return ins, nil
+}
+
+// ConvertPbExpectedRateToBase maps a publish's expected rate; unset publishes at today's rate.
+func ConvertPbExpectedRateToBase(d *pb_decimal.Decimal) (decimal.NullDecimal, error) {
+	rate, err := nullDecimalFromPb(d)
+	if err != nil || (rate.Valid && !rate.Decimal.IsPositive()) {
+		return decimal.NullDecimal{}, entity.NewFieldViolation("expected_rate_to_base", "invalid", d.GetValue(), "send the rate_to_base the preview returned")
+	}
+	return rate, nil
+}
+
+// PriceListToPb converts a price list to protobuf; pins are included when loaded.
+func PriceListToPb(l entity.PriceList) *pb_admin.PriceList {
+	out := &pb_admin.PriceList{
+		Id:                int32(l.Id),
+		Name:              l.Name,
+		Market:            l.Market,
+		Currency:          l.Currency,
+		MarkupPct:         pbDecimalFromDecimal(l.MarkupPct),
+		Rounding:          string(l.Rounding),
+		Note:              l.Note.String,
+		LastPublishedAt:   pbTimestampFromNullTime(l.LastPublishedAt),
+		LastPublishedBy:   l.LastPublishedBy,
+		LastPublishedRate: pbDecimalFromNull(l.LastPublishedRate),
+		CreatedBy:         l.CreatedBy,
+		CreatedAt:         timestamppb.New(l.CreatedAt),
+		UpdatedAt:         timestamppb.New(l.UpdatedAt),
+	}
+	for _, p := range l.Pins {
+		out.Pins = append(out.Pins, &pb_admin.PriceListPin{ProductId: int32(p.ProductId), Price: pbDecimalFromDecimal(p.Price)})
+	}
+	return out
+}
+
+// PriceListListToPb converts a price list list to protobuf.
+func PriceListListToPb(list []entity.PriceList) []*pb_admin.PriceList {
+	out := make([]*pb_admin.PriceList, 0, len(list))
+	for _, l := range list {
+		out = append(out, PriceListToPb(l))
+	}
+	return out
+}
+
+// PriceListLinesToPb converts preview lines and counts the changed and skipped ones.
+func PriceListLinesToPb(lines []entity.PriceListLine) (out []*pb_admin.PriceListLine, changed, skipped int32) {
+	out = make([]*pb_admin.PriceListLine, 0, len(lines))
+	for _, l := range lines {
+		if l.Changed() {
+			changed++
+		}
+		if !l.NewPrice.Valid {
+			skipped++
+		}
+		out = append(out, &pb_admin.PriceListLine{
+			ProductId:    int32(l.ProductId),
+			Sku:          l.SKU,
+			Color:        l.Color,
+			BasePrice:    pbDecimalFromNull(l.BasePrice),
+			CurrentPrice: pbDecimalFromNull(l.CurrentPrice),
+			NewPrice:     pbDecimalFromNull(l.NewPrice),
+			Pinned:       l.Pinned,
+			Changed:      l.Changed(),
+			SkipReason:   l.Skip,
+		})
+	}
+	return out, changed, skipped
+}
+
+// PriceListPreviewToPb converts a preview to the PreviewPriceList response.
+func PriceListPreviewToPb(p *entity.PriceListPreview) *pb_admin.PreviewPriceListResponse {
+	lines, changed, skipped := PriceListLinesToPb(p.Lines)
+	return &pb_admin.PreviewPriceListResponse{
+		PriceList:  PriceListToPb(p.List),
+		RateToBase: pbDecimalFromNull(p.RateToBase),
+		Lines:      lines,
+		Changed:    changed,
+		Skipped:    skipped,
+	}
+}
+
+// ConvertPbMarkdownEventInsertToEntity maps a markdown payload.
+func ConvertPbMarkdownEventInsertToEntity(p *pb_admin.MarkdownEventInsert, username string) (entity.MarkdownEventInsert, error) {
+	if p == nil {
+		return entity.MarkdownEventInsert{}, fmt.Errorf("%w: event is required", ErrInvalidPricingInput)
+	}
+	pct, err := nullDecimalFromPb(p.SalePercentage)
+	if err != nil || !pct.Valid {
+		return entity.MarkdownEventInsert{}, entity.NewFieldViolation("sale_percentage", "required", p.SalePercentage.GetValue(), "enter the markdown percentage, e.g. 30")
+	}
+	if err := validateDecimalFits("sale_percentage", pct.Decimal, 2, 100, false); err != nil {
+		return entity.MarkdownEventInsert{}, err
+	}
+	ins := entity.MarkdownEventInsert{
+		Name:           p.Name,
+		SalePercentage: pct.Decimal,
+		StartsAt:       markdownTime(p.StartsAt),
+		EndsAt:         markdownTime(p.EndsAt),
+		Note:           sql.NullString{String: p.Note, Valid: strings.TrimSpace(p.Note) != ""},
+		ProductIds:     make([]int, 0, len(p.ProductIds)),
+		CreatedBy:      username,
+	}
+	for _, id := range p.ProductIds {
+		ins.ProductIds = append(ins.ProductIds, int(id))
+	}
+	return ins, nil
+}
+
+// markdownTime maps a window bound; unset (or the gateway's zero instant) stays zero, which the
+// validator reports as required.
+func markdownTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	t := ts.AsTime().UTC()
+	if t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t
+}
+
+// MarkdownEventToPb converts a markdown to protobuf; products are included when loaded.
+func MarkdownEventToPb(e entity.MarkdownEvent) *pb_admin.MarkdownEvent {
+	out := &pb_admin.MarkdownEvent{
+		Id:             int32(e.Id),
+		Name:           e.Name,
+		SalePercentage: pbDecimalFromDecimal(e.SalePercentage),
+		StartsAt:       timestamppb.New(e.StartsAt),
+		EndsAt:         timestamppb.New(e.EndsAt),
+		Status:         string(e.Status),
+		AppliedAt:      pbTimestampFromNullTime(e.AppliedAt),
+		RevertedAt:     pbTimestampFromNullTime(e.RevertedAt),
+		Note:           e.Note.String,
+		CreatedBy:      e.CreatedBy,
+		CreatedAt:      timestamppb.New(e.CreatedAt),
+		UpdatedAt:      timestamppb.New(e.UpdatedAt),
+	}
+	for _, p := range e.Products {
+		out.Products = append(out.Products, &pb_admin.MarkdownEventProduct{
+			ProductId:          int32(p.ProductId),
+			Sku:                p.SKU,
+			Color:              p.Color,
+			PrevSalePercentage: pbDecimalFromNull(p.PrevSalePercentage),
+			RevertSkipped:      p.RevertSkipped,
+		})
+	}
+	return out
+}
+
+// MarkdownEventListToPb converts a markdown list to protobuf.
+func MarkdownEventListToPb(list []entity.MarkdownEvent) []*pb_admin.MarkdownEvent {
+	out := make([]*pb_admin.MarkdownEvent, 0, len(list))
+	for _, e := range list {
+		out = append(out, MarkdownEventToPb(e))
+	}
+	return out
+}
+
+// MarkdownLinesToPb converts markdown preview lines to protobuf.
+func MarkdownLinesToPb(lines []entity.MarkdownLine) []*pb_admin.MarkdownLine {
+	out := make([]*pb_admin.MarkdownLine, 0, len(lines))
+	for _, l := range lines {
+		line := &pb_admin.MarkdownLine{
+			ProductId:        int32(l.ProductId),
+			Sku:              l.SKU,
+			Color:            l.Color,
+			BasePrice:        pbDecimalFromNull(l.BasePrice),
+			CurrentSalePrice: pbDecimalFromNull(l.CurrentSalePrice),
+			NewSalePrice:     pbDecimalFromNull(l.NewSalePrice),
+			ConflictEvent:    l.ConflictEvent,
+			Missing:          l.Missing,
+		}
+		if !l.Missing {
+			line.CurrentSalePercentage = pbDecimalFromDecimal(l.CurrentSalePercentage)
+		}
+		out = append(out, line)
+	}
+	return out
+}
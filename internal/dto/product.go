@@ -48,6 +48,8 @@ var (
 		string(entity.StockChangeSourceOrderReturned):      pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ORDER_RETURNED,
 		string(entity.StockChangeSourceOrderCancelled):     pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ORDER_CANCELLED,
 		string(entity.StockChangeSourceProductionReceived): pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_PRODUCTION_RECEIVED,
+		string(entity.StockChangeSourceOrderExchange):      pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ORDER_EXCHANGE,
+		string(entity.StockChangeSourceLocationTransfer):   pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_LOCATION_TRANSFER,
 	}
 	stockChangeSourceToEntity = map[pb_common.StockChangeSource]string{
 		pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ADMIN_NEW_PRODUCT:   string(entity.StockChangeSourceAdminNewProduct),
@@ -57,6 +59,8 @@ var (
 		pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ORDER_RETURNED:      string(entity.StockChangeSourceOrderReturned),
 		pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ORDER_CANCELLED:     string(entity.StockChangeSourceOrderCancelled),
 		pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_PRODUCTION_RECEIVED: string(entity.StockChangeSourceProductionReceived),
+		pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_ORDER_EXCHANGE:      string(entity.StockChangeSourceOrderExchange),
+		pb_common.StockChangeSource_STOCK_CHANGE_SOURCE_LOCATION_TRANSFER:   string(entity.StockChangeSourceLocationTransfer),
 	}
 	stockChangeReasonToProto = map[string]pb_common.StockChangeReason{
 		string(entity.StockChangeReasonInitialStock):    pb_common.StockChangeReason_STOCK_CHANGE_REASON_INITIAL_STOCK,
@@ -71,6 +75,10 @@ var (
 		string(entity.StockChangeReasonCustomOrder):     pb_common.StockChangeReason_STOCK_CHANGE_REASON_CUSTOM_ORDER,
 		string(entity.StockChangeReasonReturnToStock):   pb_common.StockChangeReason_STOCK_CHANGE_REASON_RETURN_TO_STOCK,
 		string(entity.StockChangeReasonOrderCancelled):  pb_common.StockChangeReason_STOCK_CHANGE_REASON_ORDER_CANCELLED,
+		string(entity.StockChangeReasonExchange):        pb_common.StockChangeReason_STOCK_CHANGE_REASON_EXCHANGE,
+		string(entity.StockChangeReasonTransferOut):     pb_common.StockChangeReason_STOCK_CHANGE_REASON_TRANSFER_OUT,
+		string(entity.StockChangeReasonTransferIn):      pb_common.StockChangeReason_STOCK_CHANGE_REASON_TRANSFER_IN,
+		string(entity.StockChangeReasonOrderAllocation): pb_common.StockChangeReason_STOCK_CHANGE_REASON_ORDER_ALLOCATION,
 	}
 	stockChangeReasonToEntity = map[pb_common.StockChangeReason]string{
 		pb_common.StockChangeReason_STOCK_CHANGE_REASON_INITIAL_STOCK:    string(entity.StockChangeReasonInitialStock),
@@ -85,6 +93,10 @@ var (
 		pb_common.StockChangeReason_STOCK_CHANGE_REASON_CUSTOM_ORDER:     string(entity.StockChangeReasonCustomOrder),
 		pb_common.StockChangeReason_STOCK_CHANGE_REASON_RETURN_TO_STOCK:  string(entity.StockChangeReasonReturnToStock),
 		pb_common.StockChangeReason_STOCK_CHANGE_REASON_ORDER_CANCELLED:  string(entity.StockChangeReasonOrderCancelled),
+		pb_common.StockChangeReason_STOCK_CHANGE_REASON_EXCHANGE:         string(entity.StockChangeReasonExchange),
+		pb_common.StockChangeReason_STOCK_CHANGE_REASON_TRANSFER_OUT:     string(entity.StockChangeReasonTransferOut),
+		pb_common.StockChangeReason_STOCK_CHANGE_REASON_TRANSFER_IN:      string(entity.StockChangeReasonTransferIn),
+		pb_common.StockChangeReason_STOCK_CHANGE_REASON_ORDER_ALLOCATION: string(entity.StockChangeReasonOrderAllocation),
 	}
 )
 
@@ -526,6 +538,7 @@ func StockChangeToProto(e *entity.StockChange) *pb_common.StockChange {
 		CreatedAt:      timestamppb.New(e.CreatedAt),
 		AdminUsername:  e.AdminUsername,
 		ReferenceId:    e.ReferenceId,
+		LocationId:     int32(e.LocationId),
 	}
 	// The audit fields the write path persists (reason/comment, J.30) were dropped here — the
 	// history read must return them or the trail is write-only (beta A–L catch).
@@ -550,6 +563,8 @@ func MapStockChangeSourceToAPI(internalSource string) string {
 		string(entity.StockChangeSourceOrderCustom):      "order_custom",
 		string(entity.StockChangeSourceOrderReturned):    "order_returned",
 		string(entity.StockChangeSourceOrderCancelled):   "order_cancelled",
+		string(entity.StockChangeSourceOrderExchange):    "order_exchange",
+		string(entity.StockChangeSourceLocationTransfer): "location_transfer",
 	}
 
 	if mapped, ok := mapping[internalSource]; ok {
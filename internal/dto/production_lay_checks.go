@@ -19,6 +19,10 @@ import (
 // годности настила той же формы, что и остальные десять, и её вердикт складывается тем же
 // WorstLayCheckStatus; отдельный файл дал бы вторую лестницу статусов.
 //
+// 0348 добавила `lay_shade_band`: рулоны настила (свой лот + production_run_lay_lot) против их
+// оттеночных групп из лаборатории. По той же причине здесь же, и правило сложения групп одно —
+// entity.ShadeBandMixOf.
+//
 // Everything here is a PURE FUNCTION of (настил, маркеры, детали, BOM, настройки цеха, артикул), the
 // same shape as ComputeProductionRunMaterialPlan: no store, no wire, no clock. Each predicate answers
 // ONE named fact about a настил and returns it as a LayCheck with a stable key.
@@ -208,6 +212,9 @@ const (
 	// LayCheckKeyOvercut — выкроено больше, чем нужно на покрытые изделия. ПРЕДУПРЕЖДЕНИЕ, НЕ ЗАПРЕТ:
 	// перекрой законен и его величина — свойство состава деталей изделия, а не режима (§8.2).
 	LayCheckKeyOvercut = "lay_overcut"
+	// LayCheckKeyShadeBand — рулоны разных оттеночных групп в одном настиле (0348). НА ЗАПИСИ ТОЖЕ:
+	// SaveLay отказывает, а чтение ловит группу, перепроверенную после сохранения.
+	LayCheckKeyShadeBand = "lay_shade_band"
 )
 
 // LayCheck is one finding. Mirrors common.ProductionLayCheck field for field.
@@ -324,6 +331,9 @@ type LayArticleFacts struct {
 	// INVALID = НЕ ЗАМЕРЕНО, and that is the whole of Ф4.8's discipline: «нет толщины — нет проверки,
 	// не догадка». No default, per class or otherwise, may ever be substituted here.
 	FabricThicknessMm decimal.NullDecimal
+	// NominalShrinkagePct is material_fabric_attr.shrinkage_pct — the shrinkage the pattern was
+	// (presumably) already compensated for. INVALID = never entered, not «no shrinkage».
+	NominalShrinkagePct decimal.NullDecimal
 }
 
 // LayLotFacts is the РУЛОН this настил is actually spread from: production_run_lay.lot_id /
@@ -351,6 +361,13 @@ type LayLotFacts struct {
 	// subtracts the selvedge, and subtracting it here as well would be invisible and always
 	// permissive. INVALID = НЕ ЗАМЕРЕНО ⇒ UNKNOWN, никогда «влезает».
 	MeasuredWidthCm decimal.NullDecimal
+	// ShadeBand is material_lot.shade_band (0348), the lab's group. INVALID = рулон не сортировали, и
+	// это не «та же группа, что у соседей».
+	ShadeBand sql.NullString
+	// ShrinkageWarpPct / ShrinkageWeftPct are the lab's shrinkage of the roll (0348). No check reads
+	// them — they feed ComputeLayShrinkageCompensation, the suggestion next to the checks.
+	ShrinkageWarpPct decimal.NullDecimal
+	ShrinkageWeftPct decimal.NullDecimal
 }
 
 // LayWorkshopLimits are the settings of the ЦЕХА — one room, one set of numbers (Р5). NULL means «не
@@ -404,8 +421,11 @@ type LayCheckInput struct {
 	// Lot is the РУЛОН this настил is spread from (0285). ZERO VALUE = лот не выбран ⇒ lay_lot_width is
 	// UNKNOWN, which is the correct reading for every настил built before Ф5б and for every caller
 	// that has not wired the join yet: «не сверяли», never «влезает».
-	Lot    LayLotFacts
-	Limits LayWorkshopLimits
+	Lot LayLotFacts
+	// ExtraLots are the other rolls laid into the настил (production_run_lay_lot, 0348). Only the
+	// shade-band check reads them: the width is judged against Lot alone.
+	ExtraLots []LayLotFacts
+	Limits    LayWorkshopLimits
 	// BomLines are the card's ROLL-GOODS lines — the input entity.MarkerFabricScope resolves the
 	// направление scope out of. Passed whole because «строжайшее побеждает» is a fact about the
 	// назначение, not about one line (§8.1).
@@ -851,6 +871,60 @@ func LayLotWidthCheck(marker LayMarkerFacts, lot LayLotFacts, article LayArticle
 	return c
 }
 
+// LayShadeBandCheck — `lay_shade_band` (0348). Рулоны одного настила против их оттеночных групп:
+//
+//	один рулон или ни одного                ⇒ OK — смешивать нечего
+//	две и более ИЗВЕСТНЫЕ группы            ⇒ BLOCKER
+//	группа одна, но есть несортированные    ⇒ UNKNOWN, а НЕ «совпадает»
+//	все рулоны одной группы                 ⇒ OK
+//
+// BLOCKER, потому что детали одного изделия, выкроенные из соседних слоёв двух групп, сшиваются в
+// изделие двух оттенков, и после раскроя это не исправить ничем. Правило складывания групп —
+// entity.ShadeBandMixOf, то же, по которому отказывает SaveLay.
+func LayShadeBandCheck(lot LayLotFacts, extras []LayLotFacts) LayCheck {
+	c := LayCheck{Key: LayCheckKeyShadeBand, Label: "shade bands of the lay's rolls"}
+	rolls := make([]LayLotFacts, 0, 1+len(extras))
+	if lot.LotId.Valid || strings.TrimSpace(lot.LotCode) != "" {
+		rolls = append(rolls, lot)
+	}
+	rolls = append(rolls, extras...)
+	if len(rolls) < 2 {
+		c.Status = LayCheckStatusOK
+		return c
+	}
+
+	bands := make([]sql.NullString, 0, len(rolls))
+	for _, r := range rolls {
+		bands = append(bands, r.ShadeBand)
+	}
+	mix := entity.ShadeBandMixOf(bands)
+	switch {
+	case mix.Mixed():
+		named := make([]string, 0, len(rolls))
+		for _, r := range rolls {
+			if b := entity.NormalizeShadeBand(r.ShadeBand); b.Valid {
+				named = append(named, fmt.Sprintf("%s (%s)", layLotLabel(r), b.String))
+			}
+		}
+		c.Status = LayCheckStatusBlocker
+		c.Detail = fmt.Sprintf("rolls of %d shade bands lie in this lay — %s; pieces of one garment would come from different shades, split the lay per band",
+			len(mix.Bands), strings.Join(named, ", "))
+	case mix.Untested > 0:
+		var untested []string
+		for _, r := range rolls {
+			if !entity.NormalizeShadeBand(r.ShadeBand).Valid {
+				untested = append(untested, layLotLabel(r))
+			}
+		}
+		c.Status = LayCheckStatusUnknown
+		c.Detail = fmt.Sprintf("%s not shade-sorted (or deleted from the register) — there is no way to tell whether the %d rolls of this lay match; band them before cutting",
+			strings.Join(untested, ", "), len(rolls))
+	default:
+		c.Status = LayCheckStatusOK
+	}
+	return c
+}
+
 // layLotLabel names a lot for a message: the code the склад calls it by, falling back to the id.
 func layLotLabel(lot LayLotFacts) string {
 	if code := strings.TrimSpace(lot.LotCode); code != "" {
@@ -1159,6 +1233,7 @@ func ProductionLayChecks(in LayCheckInput) []LayCheck {
 		LaySlotDetachedCheck(in.Lay),
 		LayQuantitiesStaleCheck(in.QtySnapshot, in.QtyCurrent),
 		LayOvercutCheck(in.PieceCuts, in.Covered),
+		LayShadeBandCheck(in.Lot, in.ExtraLots),
 	}
 }
 
@@ -1014,6 +1014,40 @@ func TestLotWidthIsABlockerAndNeverGuesses(t *testing.T) {
 	})
 }
 
+// TestShadeBandCheckRefusesMixedRolls: две известные группы в одном настиле — BLOCKER, несортированный
+// рулон рядом с сортированными — UNKNOWN, а не «та же группа».
+func TestShadeBandCheckRefusesMixedRolls(t *testing.T) {
+	banded := func(id int64, code, band string) LayLotFacts {
+		l := lotOf(id, code, nd("150"))
+		if band != "" {
+			l.ShadeBand = sql.NullString{String: band, Valid: true}
+		}
+		return l
+	}
+
+	if c := LayShadeBandCheck(banded(4, "LOT-A", ""), nil); c.Status != LayCheckStatusOK {
+		t.Fatalf("one roll has nothing to mix: %v (%s)", c.Status, c.Detail)
+	}
+	if c := LayShadeBandCheck(banded(4, "LOT-A", "a"), []LayLotFacts{banded(5, "LOT-B", "A ")}); c.Status != LayCheckStatusOK {
+		t.Fatalf("«a» and «A » are one band: %v (%s)", c.Status, c.Detail)
+	}
+
+	c := LayShadeBandCheck(banded(4, "LOT-A", "A"), []LayLotFacts{banded(5, "LOT-B", "B")})
+	if c.Status != LayCheckStatusBlocker || !strings.Contains(c.Detail, "LOT-A (A)") || !strings.Contains(c.Detail, "LOT-B (B)") {
+		t.Fatalf("two bands must block and name both rolls: %v (%s)", c.Status, c.Detail)
+	}
+
+	c = LayShadeBandCheck(banded(4, "LOT-A", "A"), []LayLotFacts{banded(5, "LOT-B", "")})
+	if c.Status != LayCheckStatusUnknown || !strings.Contains(c.Detail, "LOT-B") {
+		t.Fatalf("an untested roll is UNKNOWN, never OK: %v (%s)", c.Status, c.Detail)
+	}
+
+	// Лот настила удалён из реестра, а дополнительные рулоны — одной группы: смешивать уже нечего.
+	if c := LayShadeBandCheck(LayLotFacts{}, []LayLotFacts{banded(5, "LOT-B", "B")}); c.Status != LayCheckStatusOK {
+		t.Fatalf("a single extra roll has nothing to mix: %v (%s)", c.Status, c.Detail)
+	}
+}
+
 func TestQuantitiesStaleIsAWarningAndOrderInsensitive(t *testing.T) {
 	snap := []LayQtyEntry{{SizeId: 10, Qty: 20}, {SizeId: 11, Qty: 30}}
 
@@ -1149,6 +1183,11 @@ func TestEveryCheckDetailsUnlessOK(t *testing.T) {
 		{"lot unmeasured", func(in *LayCheckInput) { in.Lot.MeasuredWidthCm = unsetDec }},
 		{"lot narrower than the marker", func(in *LayCheckInput) { in.Lot.MeasuredWidthCm = nd("140") }},
 		{"lot deleted", func(in *LayCheckInput) { in.Lot.LotId = noInt }},
+		{"rolls of two shade bands", func(in *LayCheckInput) {
+			in.Lot.ShadeBand = sql.NullString{String: "A", Valid: true}
+			in.ExtraLots = []LayLotFacts{{LotId: nullInt(5), LotCode: "LOT-B", ShadeBand: sql.NullString{String: "B", Valid: true}}}
+		}},
+		{"extra roll not shade-sorted", func(in *LayCheckInput) { in.ExtraLots = []LayLotFacts{lotOf(5, "LOT-B", nd("150"))} }},
 		{"symmetry unmarked", func(in *LayCheckInput) { in.PieceSymmetry = nil }},
 		{"blob unread", func(in *LayCheckInput) { in.Sections[0].Marker.Yield = nil }},
 	}
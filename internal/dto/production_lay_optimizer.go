@@ -0,0 +1,869 @@
+package dto
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+)
+
+// ПОДБОР НАСТИЛОВ — the lay-plan optimiser. For ONE pair (колорвей, слот BOM) it proposes which of
+// the run's раскладки to lay and how many plies deep, so that the sizes the pair still owes are cut
+// with as little cloth and as few настилов as the limits allow. Like every other file of this
+// family it is PURE: the handler loads, this file decides, nothing here touches a store.
+//
+// ЧТО ОН ВЫБИРАЕТ. Только из того, что можно сохранить: раскладки ЭТОГО прогона, годные для пары по
+// тем же предикатам, которыми план судит секцию (lay_marker_scope, lay_mirror_expansion), не
+// черновики, с известным составом, не длиннее стола. Соотношение размеров, которого нет ни в одной
+// раскладке, не выдумывается как секция — оно приезжает ПРЕДЛОЖЕНИЕМ снять такую раскладку
+// (SuggestedRatio), потому что настил без раскладки сохранить нельзя, а длину раскладки, которую
+// никто не раскладывал, можно только оценить. Такой остаток НЕ СПЛАНИРОВАН: каждое предложение
+// называет его (Uncovered, UnplannedQty), Explanation говорит об этом вслух, а Apply под него не
+// сохраняет ни одного настила — его докроят после того, как снимут раскладку.
+//
+// СКОЛЬКО ВЫКРОЕНО — ТЕМИ ЖЕ ФУНКЦИЯМИ, ЧТО У ПОКРЫТИЯ. PerLayerInstances → LayerCutInstances →
+// PieceYieldGarments, минимум по деталям слота. Второе определение «сколько изделий даёт секция»
+// разошлось бы с сеткой покрытия при первой же правке, и подбор предлагал бы настилы, которые план
+// тут же назвал бы недостаточными.
+//
+// ОПТИМУМ НЕ ДОКАЗЫВАЕТСЯ. Задача — целочисленная, и честный перебор по всем раскладкам и слоям
+// растёт быстрее, чем цех успевает ждать. Подбор жадный, и жадностей две: «меньше ткани» берёт на
+// каждом шаге вариант с наименьшим расходом на нужное изделие, «меньше настилов» — вариант, который
+// закрывает больше всего нужных изделий за один настил. Обе раскладки отдаются рядом, с разницей в
+// сантиметрах и настилах, — выбор между ними и есть компромисс, который называет Explanation.
+
+// LayOptimizerStrategy names what a proposed plan minimised first.
+type LayOptimizerStrategy string
+
+const (
+	// LayOptimizerLeastFabric — the least cloth per garment the pair still needs, lay by lay.
+	LayOptimizerLeastFabric LayOptimizerStrategy = "least_fabric"
+	// LayOptimizerFewestLays — the most needed garments per lay, lay by lay.
+	LayOptimizerFewestLays LayOptimizerStrategy = "fewest_lays"
+)
+
+// ValidLayOptimizerStrategies is the set a client may ask to apply.
+var ValidLayOptimizerStrategies = map[LayOptimizerStrategy]bool{
+	LayOptimizerLeastFabric: true,
+	LayOptimizerFewestLays:  true,
+}
+
+// layOptimizerMaxLays stops a plan that would need more настилов than anybody would spread. A pair
+// that reaches it is reported, never silently truncated into «покрыто».
+const layOptimizerMaxLays = 100
+
+// LayOptimizerInput is everything one optimisation reads, already loaded.
+type LayOptimizerInput struct {
+	Run        *entity.ProductionRun
+	Card       *entity.TechCard
+	ColorwayId int
+	BomItemId  int64
+	BomLineKey string
+	Mode       LayFaceMode
+	// EndLossCm is per ONE end of ONE ply, as on the настил itself (§7.2).
+	EndLossCm decimal.Decimal
+	// Lays are the run's stored настилы; the pair's own are subtracted from the demand.
+	Lays []entity.ProductionRunLay
+	// Markers is marker_id → distilled facts for every run marker and every marker a stored section
+	// names. RunMarkers are the candidates.
+	Markers    map[int]LayPlanMarker
+	RunMarkers []entity.TechCardMarkerSummary
+	Article    LayArticleFacts
+	Limits     LayWorkshopLimits
+}
+
+// LayOptimizerLay is one proposed настил: one раскладка laid Plies deep.
+type LayOptimizerLay struct {
+	MarkerId       int
+	MarkerName     string
+	Plies          int
+	MarkerLengthCm decimal.Decimal
+	// FabricCm is Plies × (marker length + both end losses) — what PlannedLengthCm of the saved
+	// настил will read.
+	FabricCm decimal.Decimal
+	// Cut is garments per size this настил yields for the slot; Useful of them are still owed, the
+	// rest is перекрой.
+	Cut        []LayQtyEntry
+	UsefulQty  int
+	OvercutQty int
+}
+
+// LayOptimizerPlan is one complete proposal.
+type LayOptimizerPlan struct {
+	Strategy   LayOptimizerStrategy
+	Lays       []LayOptimizerLay
+	FabricCm   decimal.Decimal
+	OvercutQty int
+	// Uncovered is the remainder this plan leaves UNPLANNED, per size: what no candidate раскладка
+	// cuts at all (or what is still owed when the plan hit layOptimizerMaxLays). No lay of the plan
+	// cuts it and applying the plan saves nothing for it. Non-empty ⇒ see SuggestedRatio.
+	Uncovered []LayQtyEntry
+	// UnplannedQty is the garments in Uncovered.
+	UnplannedQty int
+	// Fingerprint is the plan's content, marker × plies in order. ApplyProductionRunLaySuggestion
+	// refuses to save a plan whose fingerprint moved since the operator looked at it.
+	Fingerprint string
+}
+
+// LayCount is the number of настилов the plan spreads.
+func (p LayOptimizerPlan) LayCount() int { return len(p.Lays) }
+
+// LayOptimizerRatio proposes a раскладка that does not exist yet: Ratio garments per ply, Plies deep.
+type LayOptimizerRatio struct {
+	Ratio []LayQtyEntry
+	Plies int
+	// EstimatedLengthCm is Σ ratio × the mean length per garment of the candidate раскладки. INVALID
+	// when there is no candidate to estimate from — an estimate out of nothing is not a number.
+	EstimatedLengthCm decimal.NullDecimal
+}
+
+// LayOptimization is the whole answer for one pair.
+type LayOptimization struct {
+	// Demand is the run's planned quantity per size for the colourway; AlreadyCut what the pair's
+	// stored настилы yield for this slot; Remaining the difference the plans cover.
+	Demand     []LayQtyEntry
+	AlreadyCut []LayQtyEntry
+	Remaining  []LayQtyEntry
+	// MaxPlies is the deepest настил the limits allow; MaxPliesLimitedByStack says whether the stack
+	// height (rather than the schema's ceiling) set it.
+	MaxPlies               int
+	MaxPliesLimitedByStack bool
+	Candidates             []int // marker ids the plans were built from
+	// Plans are ordered least fabric first; one plan when both strategies agree.
+	Plans []LayOptimizerPlan
+	// SuggestedRatio is a hint for a раскладка to capture for the unplanned remainder — not a lay,
+	// and not part of any plan.
+	SuggestedRatio *LayOptimizerRatio
+	Explanation    string
+	Caveats        []string
+}
+
+// Plan returns the proposal of the given strategy. When both strategies agreed there is one plan,
+// and it answers for either.
+func (o LayOptimization) Plan(s LayOptimizerStrategy) (LayOptimizerPlan, bool) {
+	for _, p := range o.Plans {
+		if p.Strategy == s {
+			return p, true
+		}
+	}
+	if len(o.Plans) == 1 {
+		return o.Plans[0], true
+	}
+	return LayOptimizerPlan{}, false
+}
+
+// OptimizeLays builds the proposals for one (колорвей, слот) pair.
+func OptimizeLays(in LayOptimizerInput) LayOptimization {
+	o := &layOptimizer{in: in, seenCaveat: map[string]bool{}}
+	return o.run()
+}
+
+type layOptimizer struct {
+	in         LayOptimizerInput
+	pieces     []requiredPiece
+	step       int
+	maxPlies   int
+	lengthCm   map[int]decimal.Decimal // marker id → length + both end losses, per ply
+	caveats    []string
+	seenCaveat map[string]bool
+}
+
+func (o *layOptimizer) addCaveat(format string, args ...any) {
+	s := fmt.Sprintf(format, args...)
+	if s == "" || o.seenCaveat[s] {
+		return
+	}
+	o.seenCaveat[s] = true
+	o.caveats = append(o.caveats, s)
+}
+
+func (o *layOptimizer) run() LayOptimization {
+	in := o.in
+	var out LayOptimization
+	finish := func() LayOptimization {
+		out.Caveats = append([]string(nil), o.caveats...)
+		sort.Strings(out.Caveats)
+		return out
+	}
+	if in.Run == nil || in.Card == nil {
+		o.addCaveat("the run or the card is not loaded — there is nothing to plan")
+		return finish()
+	}
+	if !ValidLayFaceModes[in.Mode] {
+		o.addCaveat("lay mode %q is not in the dictionary — pick face_up or face_to_face", in.Mode)
+		return finish()
+	}
+
+	demand := map[int]int{}
+	for _, l := range in.Run.Lines {
+		if !l.ProductId.Valid || int(l.ProductId.Int32) != in.ColorwayId || l.SizeId <= 0 || l.PlannedQty <= 0 {
+			continue
+		}
+		demand[l.SizeId] += l.PlannedQty
+	}
+	out.Demand = layQtyFromMap(demand)
+	if len(demand) == 0 {
+		o.addCaveat("the run plans nothing in colourway #%d — there is nothing to lay", in.ColorwayId)
+		return finish()
+	}
+
+	for _, rp := range requiredPiecesForColorway(in.Card, in.ColorwayId) {
+		if rp.resolved && rp.bomItemID == in.BomItemId {
+			o.pieces = append(o.pieces, rp)
+		}
+	}
+	if len(o.pieces) == 0 {
+		o.addCaveat("no cut piece of colourway #%d is cut from this BOM slot — a lay here cuts nothing the garment needs", in.ColorwayId)
+		return finish()
+	}
+
+	o.step = 1
+	if in.Mode == LayFaceModeFaceToFace {
+		o.step = 2
+	}
+	o.maxPlies, out.MaxPliesLimitedByStack = o.plyCeiling()
+	out.MaxPlies = o.maxPlies
+	if o.maxPlies < o.step {
+		o.addCaveat("the stack limit allows %d plies, fewer than one %s section needs", o.maxPlies, in.Mode)
+		return finish()
+	}
+
+	cut := o.alreadyCut()
+	remaining := map[int]int{}
+	for size, qty := range demand {
+		if left := qty - cut[size]; left > 0 {
+			remaining[size] = left
+		}
+	}
+	out.AlreadyCut = layQtyFromMap(cut)
+	out.Remaining = layQtyFromMap(remaining)
+	if len(remaining) == 0 {
+		out.Explanation = "the pair's stored lays already cut every planned size — nothing is left to propose"
+		return finish()
+	}
+
+	candidates := o.candidates()
+	for _, c := range candidates {
+		out.Candidates = append(out.Candidates, c.Summary.Id)
+	}
+
+	fabric := o.plan(LayOptimizerLeastFabric, candidates, remaining)
+	lays := o.plan(LayOptimizerFewestLays, candidates, remaining)
+	if fabric.Fingerprint == lays.Fingerprint {
+		out.Plans = []LayOptimizerPlan{fabric}
+	} else {
+		out.Plans = []LayOptimizerPlan{fabric, lays}
+	}
+	if len(fabric.Uncovered) > 0 {
+		out.SuggestedRatio = o.suggestRatio(fabric.Uncovered, candidates)
+	}
+	out.Explanation = layOptimizerExplanation(out.Plans, candidates)
+	return finish()
+}
+
+// plyCeiling is the deepest настил the limits allow: the schema's ceiling, lowered by the stack
+// height when both the limit and the cloth's thickness are known, rounded down to the mode's step.
+// «Толщина не замерена» does not block a proposal — it is said out loud, as lay_stack_height does.
+func (o *layOptimizer) plyCeiling() (int, bool) {
+	ceiling := entity.ProductionLayPliesMax
+	byStack := false
+	limit, thickness := o.in.Limits.MaxStackHeightCm, o.in.Article.FabricThicknessMm
+	switch {
+	case !limit.Valid || !limit.Decimal.IsPositive():
+		o.addCaveat("the stack limit is not configured in the workshop settings — ply counts are bounded only by %d", entity.ProductionLayPliesMax)
+	case !thickness.Valid || !thickness.Decimal.IsPositive():
+		o.addCaveat("fabric thickness is not set on the article — ply counts are not bounded by the stack height")
+	default:
+		n := int(limit.Decimal.Mul(stackHeightMmPerCm).Div(thickness.Decimal).IntPart())
+		if n < ceiling {
+			ceiling, byStack = n, true
+		}
+	}
+	return ceiling - ceiling%o.step, byStack
+}
+
+// alreadyCut is what the pair's stored настилы yield for the slot, per size.
+func (o *layOptimizer) alreadyCut() map[int]int {
+	out := map[int]int{}
+	var sections []layOptSection
+	for i := range o.in.Lays {
+		l := &o.in.Lays[i]
+		if l.ColorwayId != o.in.ColorwayId || bomItemIdOf(l) != o.in.BomItemId {
+			continue
+		}
+		for _, s := range l.Sections {
+			m, ok := o.in.Markers[s.MarkerId]
+			if !ok || m.Yield == nil {
+				o.addCaveat("lay %q: the marker of a section cannot be read — what the lay already cuts is not subtracted", layNameOf(l))
+				continue
+			}
+			sections = append(sections, layOptSection{yield: m.Yield, mode: LayFaceMode(l.Mode), plies: s.Plies})
+		}
+	}
+	if len(sections) == 0 {
+		return out
+	}
+	for _, e := range o.in.Run.Lines {
+		if !e.ProductId.Valid || int(e.ProductId.Int32) != o.in.ColorwayId || e.SizeId <= 0 {
+			continue
+		}
+		if _, done := out[e.SizeId]; done {
+			continue
+		}
+		g, ok := o.garments(sections, e.SizeId)
+		if !ok {
+			o.addCaveat("what the stored lays cut of size #%d cannot be counted — it is not subtracted", e.SizeId)
+			continue
+		}
+		out[e.SizeId] = g
+	}
+	return out
+}
+
+// layOptSection is one section as the garment count reads it.
+type layOptSection struct {
+	yield *MarkerYield
+	mode  LayFaceMode
+	plies int
+}
+
+// garments is the slot's garment yield at one size over the given sections: per piece the cut
+// instances are summed over the sections and turned into garments, and the slot yields the minimum
+// over its pieces — coverage's own arithmetic, restricted to one slot.
+func (o *layOptimizer) garments(sections []layOptSection, sizeID int) (int, bool) {
+	least := -1
+	for _, rp := range o.pieces {
+		var cut MarkerPieceCounts
+		chirality := true
+		for _, s := range sections {
+			chirality = chirality && s.yield.ChiralityKnown()
+			inst := s.yield.PerLayerInstances(rp.piece.LineKey, sizeID)
+			if !inst.Known {
+				return 0, false
+			}
+			c, err := LayerCutInstances(inst.Counts, s.mode, s.plies)
+			if err != nil {
+				continue // an odd face-to-face section cuts nothing, as in coverage
+			}
+			cut.AsDrawn += c.AsDrawn
+			cut.Mirrored += c.Mirrored
+		}
+		y := PieceYieldGarments(cut, rp.piece.CutSymmetry, rp.piece.PiecesPerGarment, chirality)
+		if !y.Known {
+			return 0, false
+		}
+		if least < 0 || y.Garments < least {
+			least = y.Garments
+		}
+	}
+	if least < 0 {
+		return 0, false
+	}
+	return least, true
+}
+
+// layOptCandidate is a раскладка the plans may lay.
+type layOptCandidate struct {
+	LayPlanMarker
+	sizes []int // every size the marker cuts, plus every size still owed
+}
+
+// candidates are the run's раскладки a proposed настил may stand on — judged by the plan's own
+// predicates, so nothing is proposed that the plan would then flag as a blocker.
+func (o *layOptimizer) candidates() []layOptCandidate {
+	in := o.in
+	lay := LayIdentity{
+		RunId:      in.Run.Id,
+		TechCardId: in.Card.Id,
+		ColorwayId: in.ColorwayId,
+		BomItemId:  sql.NullInt64{Int64: in.BomItemId, Valid: in.BomItemId > 0},
+		BomLineKey: in.BomLineKey,
+	}
+	symmetry := cardPieceSymmetry(in.Card)
+	b := &layPlanBuilder{in: LayPlanInput{Markers: in.Markers}, seenCaveat: map[string]bool{}}
+	var out []layOptCandidate
+	for _, summary := range in.RunMarkers {
+		m, ok := in.Markers[summary.Id]
+		if !ok {
+			continue
+		}
+		label := markerLabel(m.Summary)
+		facts := b.markerFacts(summary.Id)
+		if LayMarkerScopeCheck(lay, facts).Status != LayCheckStatusOK {
+			continue // another slot or colourway: not a candidate, and not worth a sentence
+		}
+		switch {
+		case m.Summary.IsDraft:
+			o.addCaveat("marker %q is a draft (not every piece was laid) — it is not proposed", label)
+			continue
+		case m.Yield == nil:
+			o.addCaveat("marker %q: the layout cannot be read — it is not proposed", label)
+			continue
+		case !m.Yield.CompositionKnown() || !m.Yield.Attributable():
+			o.addCaveat("marker %q does not say what it cuts per size — it is not proposed", label)
+			continue
+		case !m.Summary.UsedLengthCm.IsPositive():
+			o.addCaveat("marker %q has no used length — it is not proposed", label)
+			continue
+		}
+		if t := in.Limits.CuttingTableLengthCm; t.Valid && t.Decimal.IsPositive() && m.Summary.UsedLengthCm.GreaterThan(t.Decimal) {
+			o.addCaveat("marker %q at %s cm does not fit on the %s cm table — it is not proposed",
+				label, m.Summary.UsedLengthCm.String(), t.Decimal.String())
+			continue
+		}
+		if LayMirrorExpansionCheck(in.Mode, facts, symmetry).Status == LayCheckStatusBlocker {
+			o.addCaveat("marker %q is not expanded for a %s lay — it is not proposed", label, in.Mode)
+			continue
+		}
+		c := layOptCandidate{LayPlanMarker: m}
+		seen := map[int]bool{}
+		for size := range m.Yield.Composition {
+			seen[size] = true
+		}
+		for _, l := range in.Run.Lines {
+			if l.ProductId.Valid && int(l.ProductId.Int32) == in.ColorwayId && l.SizeId > 0 {
+				seen[l.SizeId] = true
+			}
+		}
+		for size := range seen {
+			c.sizes = append(c.sizes, size)
+		}
+		sort.Ints(c.sizes)
+		if _, ok := o.yieldAt(c, o.step); !ok {
+			o.addCaveat("marker %q: how many garments it yields for this slot cannot be counted — it is not proposed", label)
+			continue
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Summary.Id < out[j].Summary.Id })
+	if o.lengthCm == nil {
+		o.lengthCm = make(map[int]decimal.Decimal, len(out))
+	}
+	ends := in.EndLossCm.Mul(decimal.NewFromInt(2))
+	for _, c := range out {
+		o.lengthCm[c.Summary.Id] = c.Summary.UsedLengthCm.Add(ends)
+	}
+	if len(out) == 0 {
+		o.addCaveat("the run has no marker fit for this colourway and slot — capture one for the run first")
+	}
+	return out
+}
+
+// yieldAt is what one настил of the candidate yields at the given depth, per size.
+func (o *layOptimizer) yieldAt(c layOptCandidate, plies int) (map[int]int, bool) {
+	sec := []layOptSection{{yield: c.Yield, mode: o.in.Mode, plies: plies}}
+	out := make(map[int]int, len(c.sizes))
+	for _, size := range c.sizes {
+		g, ok := o.garments(sec, size)
+		if !ok {
+			return nil, false
+		}
+		out[size] = g
+	}
+	return out, true
+}
+
+// layOptOption is one настил the greedy step may take.
+type layOptOption struct {
+	c       layOptCandidate
+	plies   int
+	cut     map[int]int
+	useful  int
+	overcut int
+	fabric  decimal.Decimal
+}
+
+// plan is the greedy construction under one strategy.
+func (o *layOptimizer) plan(strategy LayOptimizerStrategy, candidates []layOptCandidate, demand map[int]int) LayOptimizerPlan {
+	remaining := make(map[int]int, len(demand))
+	for k, v := range demand {
+		remaining[k] = v
+	}
+	p := LayOptimizerPlan{Strategy: strategy, FabricCm: decimal.Zero}
+	for len(remaining) > 0 && len(p.Lays) < layOptimizerMaxLays {
+		var best *layOptOption
+		for _, c := range candidates {
+			for _, opt := range o.options(c, remaining) {
+				if opt.useful > 0 && (best == nil || better(strategy, opt, *best)) {
+					b := opt
+					best = &b
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		lay := LayOptimizerLay{
+			MarkerId:       best.c.Summary.Id,
+			MarkerName:     markerLabel(best.c.Summary),
+			Plies:          best.plies,
+			MarkerLengthCm: best.c.Summary.UsedLengthCm,
+			FabricCm:       best.fabric,
+			Cut:            layQtyFromMap(best.cut),
+			UsefulQty:      best.useful,
+			OvercutQty:     best.overcut,
+		}
+		p.Lays = append(p.Lays, lay)
+		p.FabricCm = p.FabricCm.Add(best.fabric)
+		p.OvercutQty += best.overcut
+		for size, g := range best.cut {
+			if left, ok := remaining[size]; ok {
+				if left -= g; left > 0 {
+					remaining[size] = left
+				} else {
+					delete(remaining, size)
+				}
+			}
+		}
+	}
+	if len(remaining) > 0 && len(p.Lays) >= layOptimizerMaxLays {
+		o.addCaveat("the %s plan stopped at %d lays with sizes still owed — the markers are far too small for this run", strategy, layOptimizerMaxLays)
+	}
+	p.Uncovered = layQtyFromMap(remaining)
+	for _, e := range p.Uncovered {
+		p.UnplannedQty += e.Qty
+	}
+	p.Fingerprint = layOptimizerFingerprint(p.Lays)
+	return p
+}
+
+// options are the two depths worth considering for a candidate: the deepest настил that cuts no
+// more of any size than is still owed, and the shallowest one that finishes every owed size the
+// marker cuts at all (which may overcut the others). Everything between them is dominated — deeper
+// than the first only adds перекрой, shallower than the second leaves a remainder for another lay.
+func (o *layOptimizer) options(c layOptCandidate, remaining map[int]int) []layOptOption {
+	maxK := o.maxPlies / o.step
+	at := func(k int) (layOptOption, bool) {
+		cut, ok := o.yieldAt(c, k*o.step)
+		if !ok {
+			return layOptOption{}, false
+		}
+		opt := layOptOption{c: c, plies: k * o.step, cut: cut}
+		for size, g := range cut {
+			need := remaining[size]
+			if g <= need {
+				opt.useful += g
+			} else {
+				opt.useful += need
+				opt.overcut += g - need
+			}
+		}
+		opt.fabric = o.lengthCm[c.Summary.Id].Mul(decimal.NewFromInt(int64(opt.plies)))
+		return opt, true
+	}
+	fits := func(k int) bool {
+		opt, ok := at(k)
+		return ok && opt.overcut == 0
+	}
+	finishes := func(k int) bool {
+		cut, ok := o.yieldAt(c, k*o.step)
+		if !ok {
+			return false
+		}
+		for size, need := range remaining {
+			if g := cut[size]; g > 0 && g < need {
+				return false
+			}
+		}
+		return true
+	}
+
+	var out []layOptOption
+	// Both predicates are monotone in depth, so each boundary is a binary search.
+	if fits(1) {
+		lo, hi := 1, maxK
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if fits(mid) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		if opt, ok := at(lo); ok {
+			out = append(out, opt)
+		}
+	}
+	if finishes(maxK) {
+		lo, hi := 1, maxK
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if finishes(mid) {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		if len(out) == 0 || out[0].plies != lo*o.step {
+			if opt, ok := at(lo); ok {
+				out = append(out, opt)
+			}
+		}
+	}
+	return out
+}
+
+// better reports whether a beats b under the strategy. Cloth per needed garment is compared by
+// cross-multiplication so no division rounds a tie into a preference.
+func better(strategy LayOptimizerStrategy, a, b layOptOption) bool {
+	perA := a.fabric.Mul(decimal.NewFromInt(int64(b.useful)))
+	perB := b.fabric.Mul(decimal.NewFromInt(int64(a.useful)))
+	switch strategy {
+	case LayOptimizerFewestLays:
+		if a.useful != b.useful {
+			return a.useful > b.useful
+		}
+		if !perA.Equal(perB) {
+			return perA.LessThan(perB)
+		}
+	default:
+		if !perA.Equal(perB) {
+			return perA.LessThan(perB)
+		}
+		if a.useful != b.useful {
+			return a.useful > b.useful
+		}
+	}
+	if a.c.Summary.Id != b.c.Summary.Id {
+		return a.c.Summary.Id < b.c.Summary.Id
+	}
+	return a.plies > b.plies
+}
+
+// suggestRatio proposes a раскладка for what no candidate cuts: the depth that leaves the least
+// перекрой (the deeper one on a tie — fewer garments per ply make a shorter marker), and the ratio
+// that depth needs.
+func (o *layOptimizer) suggestRatio(uncovered []LayQtyEntry, candidates []layOptCandidate) *LayOptimizerRatio {
+	most := 0
+	for _, e := range uncovered {
+		if e.Qty > most {
+			most = e.Qty
+		}
+	}
+	top := min(o.maxPlies, most+o.step-1)
+	top -= top % o.step
+	bestPlies, bestOvercut := 0, -1
+	for plies := top; plies >= o.step; plies -= o.step {
+		over := 0
+		for _, e := range uncovered {
+			units := (e.Qty + plies - 1) / plies
+			over += units*plies - e.Qty
+		}
+		if bestOvercut < 0 || over < bestOvercut {
+			bestPlies, bestOvercut = plies, over
+		}
+	}
+	if bestPlies == 0 {
+		return nil
+	}
+	out := &LayOptimizerRatio{Plies: bestPlies}
+	units := 0
+	for _, e := range uncovered {
+		n := (e.Qty + bestPlies - 1) / bestPlies
+		units += n
+		out.Ratio = append(out.Ratio, LayQtyEntry{SizeId: e.SizeId, Qty: n})
+	}
+	// Length per garment, pooled over the candidates: Σ length / Σ garments per ply.
+	length, garments := decimal.Zero, 0
+	for _, c := range candidates {
+		length = length.Add(c.Summary.UsedLengthCm)
+		garments += c.Yield.TotalUnits
+	}
+	if garments > 0 {
+		out.EstimatedLengthCm = decimal.NullDecimal{
+			Decimal: length.Div(decimal.NewFromInt(int64(garments))).Mul(decimal.NewFromInt(int64(units))).Round(1),
+			Valid:   true,
+		}
+		if t := o.in.Limits.CuttingTableLengthCm; t.Valid && t.Decimal.IsPositive() && out.EstimatedLengthCm.Decimal.GreaterThan(t.Decimal) {
+			o.addCaveat("the suggested ratio is estimated at %s cm, longer than the %s cm table — split it over two markers",
+				out.EstimatedLengthCm.Decimal.String(), t.Decimal.String())
+		}
+	}
+	return out
+}
+
+// layOptimizerExplanation states the trade-off between the plans in one paragraph.
+func layOptimizerExplanation(plans []LayOptimizerPlan, candidates []layOptCandidate) string {
+	if len(candidates) == 0 || len(plans) == 0 || len(plans[0].Lays) == 0 {
+		return "no lay can be proposed from the run's markers"
+	}
+	describe := func(p LayOptimizerPlan) string {
+		s := fmt.Sprintf("%d lay(s), %s cm of cloth", p.LayCount(), p.FabricCm.Round(1).String())
+		if p.OvercutQty > 0 {
+			s += fmt.Sprintf(", %d garment(s) overcut", p.OvercutQty)
+		}
+		return s
+	}
+	var b strings.Builder
+	if len(plans) == 1 {
+		fmt.Fprintf(&b, "Least cloth and fewest lays agree: %s.", describe(plans[0]))
+	} else {
+		fabric, lays := plans[0], plans[1]
+		fmt.Fprintf(&b, "Least cloth: %s. Fewest lays: %s.", describe(fabric), describe(lays))
+		saved := lays.LayCount() - fabric.LayCount()
+		extra := lays.FabricCm.Sub(fabric.FabricCm)
+		switch {
+		case saved < 0 && extra.IsPositive():
+			pct := ""
+			if fabric.FabricCm.IsPositive() {
+				pct = fmt.Sprintf(" (%s%%)", extra.Div(fabric.FabricCm).Mul(decimal.NewFromInt(100)).Round(1).String())
+			}
+			fmt.Fprintf(&b, " Spreading %d lay(s) fewer costs %s cm more cloth%s.", -saved, extra.Round(1).String(), pct)
+		case saved < 0:
+			fmt.Fprintf(&b, " The fewest-lays plan spreads %d lay(s) fewer at no extra cloth.", -saved)
+		default:
+			b.WriteString(" The plans differ in which markers they lay, not in the trade-off.")
+		}
+	}
+	if p := plans[0]; p.UnplannedQty > 0 {
+		fmt.Fprintf(&b, " %d garment(s) in %d size(s) are left unplanned: no proposed lay cuts them and applying the plan saves none for them — capture a marker with the suggested ratio.",
+			p.UnplannedQty, len(p.Uncovered))
+	}
+	return b.String()
+}
+
+func layOptimizerFingerprint(lays []LayOptimizerLay) string {
+	parts := make([]string, 0, len(lays))
+	for _, l := range lays {
+		parts = append(parts, fmt.Sprintf("%d×%d", l.MarkerId, l.Plies))
+	}
+	return strings.Join(parts, ",")
+}
+
+func layQtyFromMap(m map[int]int) []LayQtyEntry {
+	out := make([]LayQtyEntry, 0, len(m))
+	for size, qty := range m {
+		if qty > 0 {
+			out = append(out, LayQtyEntry{SizeId: size, Qty: qty})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SizeId < out[j].SizeId })
+	return out
+}
+
+// LayOptimizerInserts turns a plan into the настилы ApplyProductionRunLaySuggestion saves: one lay
+// per proposed настил, one section each, named so a human can tell them from hand-built ones.
+func LayOptimizerInserts(in LayOptimizerInput, p LayOptimizerPlan, firstDisplayOrder int) []entity.ProductionRunLayInsert {
+	out := make([]entity.ProductionRunLayInsert, 0, len(p.Lays))
+	for i, l := range p.Lays {
+		out = append(out, entity.ProductionRunLayInsert{
+			ColorwayId: in.ColorwayId,
+			BomLineKey: in.BomLineKey,
+			Mode:       entity.ProductionLayMode(in.Mode),
+			EndLossCm:  in.EndLossCm,
+			Name:       fmt.Sprintf("Auto %d/%d · %s", i+1, len(p.Lays), l.MarkerName),
+			Note: sql.NullString{
+				String: fmt.Sprintf("proposed by the lay planner (%s)", p.Strategy),
+				Valid:  true,
+			},
+			DisplayOrder: firstDisplayOrder + i,
+			Sections: []entity.ProductionRunLaySectionInsert{
+				{MarkerId: l.MarkerId, Plies: l.Plies, Position: 0},
+			},
+		})
+	}
+	return out
+}
+
+// LayOptimizerParamsFromPb reads the lay mode and the end loss a proposal is built for. UNSPECIFIED
+// mode is face up: the proposal is a question, not a save, and the editor asks it before the
+// operator has picked anything. The end loss is bounded exactly as SaveLay bounds it, so nothing is
+// proposed that the save would then refuse.
+func LayOptimizerParamsFromPb(mode pb_common.ProductionLayMode, endLoss *pb_decimal.Decimal) (LayFaceMode, decimal.Decimal, error) {
+	m := LayFaceModeFaceUp
+	if mode != pb_common.ProductionLayMode_PRODUCTION_LAY_MODE_UNSPECIFIED {
+		em, ok := layModeFromPb(mode)
+		if !ok {
+			return "", decimal.Zero, entity.NewFieldViolation("mode", "unknown_mode", mode.String(),
+				"pick FACE_UP or FACE_TO_FACE")
+		}
+		m = LayFaceMode(em)
+	}
+	loss := decimal.Zero
+	if v := strings.TrimSpace(endLoss.GetValue()); v != "" {
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return "", decimal.Zero, entity.NewFieldViolation("end_loss_cm", "not_a_number", v,
+				fmt.Sprintf("enter a decimal number of centimetres per ONE end of ONE ply, e.g. 2 (%v)", err))
+		}
+		loss = d
+	}
+	if loss.LessThan(entity.ProductionLayEndLossMinCm) || loss.GreaterThan(entity.ProductionLayEndLossMaxCm) {
+		return "", decimal.Zero, entity.NewFieldViolation("end_loss_cm", "out_of_range", loss.String(),
+			"end loss is measured per ONE end of ONE ply, 0..100 cm")
+	}
+	return m, loss, nil
+}
+
+// LayOptimizerFacts is the article and the workshop limits a proposal reads — through the plan's own
+// builder, so the proposal and the plan that judges its настилы cannot disagree about the cloth's
+// thickness or the table's length.
+func LayOptimizerFacts(card *entity.TechCard, colorwayID int, bomItemID int64,
+	materials map[int]entity.MaterialWithPrice, settings *entity.WorkshopSettings) (LayArticleFacts, LayWorkshopLimits) {
+	b := &layPlanBuilder{in: LayPlanInput{Materials: materials, Settings: settings}, seenCaveat: map[string]bool{}}
+	return b.article(LayArticleMaterialId(card, colorwayID, bomItemID)), b.limits()
+}
+
+func layQtyPb(entries []LayQtyEntry) []*pb_common.ProductionRunLayQtyEntry {
+	out := make([]*pb_common.ProductionRunLayQtyEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, &pb_common.ProductionRunLayQtyEntry{SizeId: int32(e.SizeId), Qty: int32(e.Qty)})
+	}
+	return out
+}
+
+// LayOptimizationToPb converts the proposals to protobuf.
+func LayOptimizationToPb(o LayOptimization) *pb_admin.SuggestProductionRunLaysResponse {
+	out := &pb_admin.SuggestProductionRunLaysResponse{
+		Demand:                 layQtyPb(o.Demand),
+		AlreadyCut:             layQtyPb(o.AlreadyCut),
+		Remaining:              layQtyPb(o.Remaining),
+		MaxPlies:               int32(o.MaxPlies),
+		MaxPliesLimitedByStack: o.MaxPliesLimitedByStack,
+		CandidateMarkerIds:     make([]int32, 0, len(o.Candidates)),
+		Plans:                  make([]*pb_admin.ProductionRunLaySuggestionPlan, 0, len(o.Plans)),
+		Explanation:            o.Explanation,
+		Caveats:                o.Caveats,
+	}
+	for _, id := range o.Candidates {
+		out.CandidateMarkerIds = append(out.CandidateMarkerIds, int32(id))
+	}
+	for _, p := range o.Plans {
+		pp := &pb_admin.ProductionRunLaySuggestionPlan{
+			Strategy:     string(p.Strategy),
+			Lays:         make([]*pb_admin.ProductionRunLaySuggestionLay, 0, len(p.Lays)),
+			FabricCm:     pbDecimalFromDecimal(p.FabricCm),
+			OvercutQty:   int32(p.OvercutQty),
+			Uncovered:    layQtyPb(p.Uncovered),
+			UnplannedQty: int32(p.UnplannedQty),
+			Fingerprint:  p.Fingerprint,
+		}
+		for _, l := range p.Lays {
+			pp.Lays = append(pp.Lays, &pb_admin.ProductionRunLaySuggestionLay{
+				MarkerId:       int32(l.MarkerId),
+				MarkerName:     l.MarkerName,
+				Plies:          int32(l.Plies),
+				MarkerLengthCm: pbDecimalFromDecimal(l.MarkerLengthCm),
+				FabricCm:       pbDecimalFromDecimal(l.FabricCm),
+				Cut:            layQtyPb(l.Cut),
+				UsefulQty:      int32(l.UsefulQty),
+				OvercutQty:     int32(l.OvercutQty),
+			})
+		}
+		out.Plans = append(out.Plans, pp)
+	}
+	if r := o.SuggestedRatio; r != nil {
+		out.SuggestedRatio = &pb_admin.ProductionRunLaySuggestedRatio{
+			Ratio:             layQtyPb(r.Ratio),
+			Plies:             int32(r.Plies),
+			EstimatedLengthCm: pbDecimalFromNull(r.EstimatedLengthCm),
+		}
+	}
+	return out
+}
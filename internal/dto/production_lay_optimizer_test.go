@@ -0,0 +1,259 @@
+package dto
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// Фикстуры карточки (twoClothCard / runLine / idxRef / slotRef) живут в production_lay_coverage_test.go:
+// подбор обязан считать изделия той же карточкой, что и покрытие.
+
+const (
+	optRunID = 3
+	optS     = 10
+	optM     = 20
+	optL     = 30
+	optXL    = 40
+)
+
+// liningRatioYield is a раскладка of the lining cut `ratio` garments per ply: one lining panel per
+// garment of each size, all as drawn.
+func liningRatioYield(t *testing.T, ratio map[int]int) MarkerYield {
+	t.Helper()
+	l := &pb_common.TechCardMarkerLayout{SchemaVersion: 4}
+	id := int32(0)
+	for _, size := range []int{optS, optM, optL, optXL} {
+		n := ratio[size]
+		if n == 0 {
+			continue
+		}
+		id++
+		l.Composition = append(l.Composition, comp([2]int32{int32(size), int32(n)})...)
+		l.Pieces = append(l.Pieces, piece(id, "ПОДКЛАДКА ПОЛОЧКИ", "K_LINING", int32(size), int32(n)))
+		l.Placements = append(l.Placements, placements(id, n, 0)...)
+	}
+	return mustYield(t, l)
+}
+
+func optMarker(t *testing.T, id int, name, lengthCm string, ratio map[int]int) LayPlanMarker {
+	y := liningRatioYield(t, ratio)
+	return LayPlanMarker{
+		Summary: entity.TechCardMarkerSummary{
+			Id: id, Name: name, TechCardId: 7,
+			RunId:        sql.NullInt64{Int64: optRunID, Valid: true},
+			BomItemId:    slotRef(fixtureLiningSlot),
+			UsedLengthCm: decimal.RequireFromString(lengthCm),
+		},
+		Yield: &y,
+	}
+}
+
+// optInput is a run of 30 S + 50 M + 20 L in the first colourway, laid on the lining, with three
+// раскладки: S-M-M-L at 100 cm a garment, M alone at 110 and S-M at 105. The stack takes 50 plies.
+func optInput(t *testing.T, markers ...LayPlanMarker) LayOptimizerInput {
+	in := LayOptimizerInput{
+		Run: &entity.ProductionRun{Id: optRunID, ProductionRunInsert: entity.ProductionRunInsert{
+			TechCardId: 7,
+			Lines: []entity.ProductionRunLine{
+				runLine("L1", fixtureCw1, optS, 30),
+				runLine("L2", fixtureCw1, optM, 50),
+				runLine("L3", fixtureCw1, optL, 20),
+				runLine("L4", fixtureCw2, optS, 99), // другой колорвей — не наш спрос
+			},
+		}},
+		Card:       twoClothCard(),
+		ColorwayId: fixtureCw1,
+		BomItemId:  fixtureLiningSlot,
+		BomLineKey: "BOM_LINING",
+		Mode:       LayFaceModeFaceUp,
+		EndLossCm:  decimal.Zero,
+		Markers:    map[int]LayPlanMarker{},
+		Article:    LayArticleFacts{FabricThicknessMm: decimal.NewNullDecimal(cm("2"))},
+		Limits: LayWorkshopLimits{
+			MaxStackHeightCm:     decimal.NewNullDecimal(cm("10")),
+			CuttingTableLengthCm: decimal.NewNullDecimal(cm("1000")),
+		},
+	}
+	if len(markers) == 0 {
+		markers = []LayPlanMarker{
+			optMarker(t, 1, "S-M-M-L", "400", map[int]int{optS: 1, optM: 2, optL: 1}),
+			optMarker(t, 2, "M", "110", map[int]int{optM: 1}),
+			optMarker(t, 3, "S-M", "210", map[int]int{optS: 1, optM: 1}),
+		}
+	}
+	for _, m := range markers {
+		in.Markers[m.Summary.Id] = m
+		in.RunMarkers = append(in.RunMarkers, m.Summary)
+	}
+	return in
+}
+
+func planOf(t *testing.T, o LayOptimization, s LayOptimizerStrategy) LayOptimizerPlan {
+	t.Helper()
+	p, ok := o.Plan(s)
+	require.True(t, ok, "no %s plan in %+v", s, o.Plans)
+	return p
+}
+
+// Две жадности расходятся ровно там, где и должен быть компромисс: меньше ткани — два настила без
+// перекроя, меньше настилов — один настил на 30 слоёв и 20 изделий перекроя.
+func TestOptimizeLaysNamesTheTradeOff(t *testing.T) {
+	o := OptimizeLays(optInput(t))
+
+	require.Equal(t, 50, o.MaxPlies)
+	require.True(t, o.MaxPliesLimitedByStack, "10 cm of 2 mm cloth is 50 plies, below the schema's 500")
+	require.Equal(t, []int{1, 2, 3}, o.Candidates)
+	require.Len(t, o.Plans, 2)
+
+	fabric := planOf(t, o, LayOptimizerLeastFabric)
+	require.Equal(t, "1×20,3×10", fabric.Fingerprint)
+	require.True(t, fabric.FabricCm.Equal(cm("10100")), "fabric = %s", fabric.FabricCm)
+	require.Zero(t, fabric.OvercutQty)
+	require.Empty(t, fabric.Uncovered)
+	require.Equal(t, []LayQtyEntry{{SizeId: optS, Qty: 20}, {SizeId: optM, Qty: 40}, {SizeId: optL, Qty: 20}}, fabric.Lays[0].Cut)
+
+	lays := planOf(t, o, LayOptimizerFewestLays)
+	require.Equal(t, "1×30", lays.Fingerprint)
+	require.True(t, lays.FabricCm.Equal(cm("12000")), "fabric = %s", lays.FabricCm)
+	require.Equal(t, 20, lays.OvercutQty)
+
+	require.Contains(t, o.Explanation, "1 lay(s) fewer costs 1900 cm more cloth (18.8%)")
+	require.Nil(t, o.SuggestedRatio)
+}
+
+// Настилы пары, что уже сохранены, вычитаются из спроса — ТЕМИ ЖЕ деталями слота, что у покрытия.
+func TestOptimizeLaysSubtractsWhatIsAlreadyLaid(t *testing.T) {
+	in := optInput(t)
+	in.Lays = []entity.ProductionRunLay{
+		{LayKey: "A", ColorwayId: fixtureCw1, BomItemId: slotRef(fixtureLiningSlot), Mode: entity.ProductionLayMode(LayFaceModeFaceUp),
+			Sections: []entity.ProductionRunLaySection{{MarkerId: 2, Plies: 50}}},
+		// Чужой слот — его полочки подкладку не кроят.
+		{LayKey: "B", ColorwayId: fixtureCw1, BomItemId: slotRef(fixtureFabricSlot), Mode: entity.ProductionLayMode(LayFaceModeFaceUp),
+			Sections: []entity.ProductionRunLaySection{{MarkerId: 3, Plies: 50}}},
+	}
+	o := OptimizeLays(in)
+
+	require.Equal(t, []LayQtyEntry{{SizeId: optM, Qty: 50}}, o.AlreadyCut)
+	require.Equal(t, []LayQtyEntry{{SizeId: optS, Qty: 30}, {SizeId: optL, Qty: 20}}, o.Remaining)
+	for _, p := range o.Plans {
+		require.Empty(t, p.Uncovered, "%s left sizes uncovered", p.Strategy)
+	}
+}
+
+// Раскладка, которую план назвал бы блокером, не предлагается — и молча тоже не пропадает.
+func TestOptimizeLaysProposesOnlyWhatThePlanWouldAccept(t *testing.T) {
+	draft := optMarker(t, 4, "черновик", "50", map[int]int{optS: 1, optM: 1, optL: 1})
+	draft.Summary.IsDraft = true
+	long := optMarker(t, 5, "длинная", "1200", map[int]int{optS: 5, optM: 5, optL: 5})
+	foreign := optMarker(t, 6, "основная", "50", map[int]int{optS: 1})
+	foreign.Summary.BomItemId = slotRef(fixtureFabricSlot)
+	cardNorm := optMarker(t, 7, "норма", "50", map[int]int{optS: 1})
+	cardNorm.Summary.RunId = sql.NullInt64{}
+
+	o := OptimizeLays(optInput(t,
+		optMarker(t, 1, "S-M-M-L", "400", map[int]int{optS: 1, optM: 2, optL: 1}),
+		draft, long, foreign, cardNorm))
+
+	require.Equal(t, []int{1}, o.Candidates)
+	joined := strings.Join(o.Caveats, "\n")
+	require.Contains(t, joined, `"черновик" is a draft`)
+	require.Contains(t, joined, `"длинная" at 1200 cm does not fit on the 1000 cm table`)
+	require.NotContains(t, joined, "основная", "another slot's marker is not a candidate, not a finding")
+}
+
+// Размер, которого не кроит ни одна раскладка, не превращается в выдуманную секцию: он остаётся
+// непокрытым, и приезжает предложение снять раскладку под него.
+func TestOptimizeLaysSuggestsARatioForWhatNoMarkerCuts(t *testing.T) {
+	in := optInput(t)
+	in.Run.Lines = append(in.Run.Lines, runLine("L5", fixtureCw1, optXL, 7))
+	o := OptimizeLays(in)
+
+	fabric := planOf(t, o, LayOptimizerLeastFabric)
+	require.Equal(t, []LayQtyEntry{{SizeId: optXL, Qty: 7}}, fabric.Uncovered)
+	require.NotNil(t, o.SuggestedRatio)
+	require.Equal(t, 7, o.SuggestedRatio.Plies)
+	require.Equal(t, []LayQtyEntry{{SizeId: optXL, Qty: 1}}, o.SuggestedRatio.Ratio)
+	// (400 + 110 + 210) cm over 7 garments a ply ≈ 102.9 cm a garment.
+	require.True(t, o.SuggestedRatio.EstimatedLengthCm.Valid)
+	require.True(t, o.SuggestedRatio.EstimatedLengthCm.Decimal.Equal(cm("102.9")), "estimate = %s", o.SuggestedRatio.EstimatedLengthCm.Decimal)
+	require.Contains(t, o.Explanation, "7 garment(s) in 1 size(s) are left unplanned")
+}
+
+// Остаток без раскладки называется в каждом предложении, и Apply его не кроит: ни один сохранённый
+// настил не режет XL, а UnplannedQty — ровно то, что осталось должно.
+func TestOptimizeLaysReportsTheUnplannedLeftover(t *testing.T) {
+	in := optInput(t)
+	in.Run.Lines = append(in.Run.Lines, runLine("L5", fixtureCw1, optXL, 7))
+	o := OptimizeLays(in)
+
+	require.Contains(t, o.Remaining, LayQtyEntry{SizeId: optXL, Qty: 7})
+	require.NotEmpty(t, o.Plans)
+	for _, p := range o.Plans {
+		require.Equal(t, 7, p.UnplannedQty, "%s plan", p.Strategy)
+		require.Equal(t, []LayQtyEntry{{SizeId: optXL, Qty: 7}}, p.Uncovered, "%s plan", p.Strategy)
+		for _, l := range p.Lays {
+			for _, c := range l.Cut {
+				require.False(t, c.SizeId == optXL && c.Qty > 0, "%s plan lays XL on %q", p.Strategy, l.MarkerName)
+			}
+		}
+	}
+	require.Contains(t, o.Explanation, "applying the plan saves none for them")
+
+	// Fully covered sizes leave nothing unplanned.
+	for _, p := range OptimizeLays(optInput(t)).Plans {
+		require.Zero(t, p.UnplannedQty, "%s plan", p.Strategy)
+	}
+}
+
+// Лицом к лицу настил бывает только чётным: потолок и каждое предложенное число слоёв — тоже.
+func TestOptimizeLaysKeepsFaceToFacePliesEven(t *testing.T) {
+	in := optInput(t)
+	in.Mode = LayFaceModeFaceToFace
+	in.Limits.MaxStackHeightCm = decimal.NewNullDecimal(cm("4.9")) // 24.5 → 24
+	o := OptimizeLays(in)
+
+	require.Equal(t, 24, o.MaxPlies)
+	for _, p := range o.Plans {
+		for _, l := range p.Lays {
+			require.Zero(t, l.Plies%2, "%s proposes %d plies face to face", p.Strategy, l.Plies)
+		}
+	}
+}
+
+// Без предела стопки и без толщины ткани подбор не молчит и не угадывает: потолок — схемный, и это
+// сказано вслух.
+func TestOptimizeLaysSaysWhenTheStackIsUnchecked(t *testing.T) {
+	in := optInput(t)
+	in.Article.FabricThicknessMm = decimal.NullDecimal{}
+	o := OptimizeLays(in)
+
+	require.Equal(t, entity.ProductionLayPliesMax, o.MaxPlies)
+	require.False(t, o.MaxPliesLimitedByStack)
+	require.Contains(t, strings.Join(o.Caveats, "\n"), "fabric thickness is not set")
+}
+
+func TestLayOptimizerInsertsAreOneSectionLaysAfterTheExistingOnes(t *testing.T) {
+	in := optInput(t)
+	in.EndLossCm = cm("1.5")
+	p := planOf(t, OptimizeLays(in), LayOptimizerLeastFabric)
+	ins := LayOptimizerInserts(in, p, 4)
+
+	require.Len(t, ins, len(p.Lays))
+	for i, l := range ins {
+		require.Empty(t, l.LayKey, "the server mints the key")
+		require.Equal(t, fixtureCw1, l.ColorwayId)
+		require.Equal(t, "BOM_LINING", l.BomLineKey)
+		require.Equal(t, 4+i, l.DisplayOrder)
+		require.True(t, l.EndLossCm.Equal(cm("1.5")))
+		require.Len(t, l.Sections, 1)
+		require.Equal(t, p.Lays[i].MarkerId, l.Sections[0].MarkerId)
+		require.Equal(t, p.Lays[i].Plies, l.Sections[0].Plies)
+		require.True(t, strings.HasPrefix(l.Name, "Auto "), l.Name)
+	}
+}
@@ -0,0 +1,125 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+)
+
+// ПРИПУСК НА УСАДКУ (0348) — подсказка конструктору, сколько добавить к лекалам под ТЕ рулоны, что
+// лежат в настиле, а не под номинал артикула.
+//
+// ЭТО ПОДСКАЗКА, НЕ ПРОВЕРКА. Лекала этого настила уже нарисованы и, возможно, уже компенсированы
+// под номинал (material_fabric_attr.shrinkage_pct); сервер не знает, с каким припуском их строили, и
+// поэтому не отказывает и не ставит бейдж — он считает разницу и называет её. Решение остаётся за
+// конструктором.
+//
+// ХУДШИЙ РУЛОН, НЕ СРЕДНИЙ. Настил кроится одним лекалом на все слои; если один рулон садится на 5%,
+// а другой на 2%, лекало под среднее даёт маломерки из первого. Припуск — по наибольшей усадке в
+// каждом направлении, а разброс больше процентного пункта называется отдельно: такой настил лучше
+// разложить по рулонам.
+
+// layShrinkageSpreadPct — разброс усадки между рулонами одного настила (п.п.), после которого это
+// называется вслух. Один пункт на 100 см лекала — сантиметр, то есть размер.
+var layShrinkageSpreadPct = decimal.NewFromInt(1)
+
+// LayShrinkageCompensation is the suggestion for one настил. Every number is absent, not zero, when
+// there is nothing to compute it from.
+type LayShrinkageCompensation struct {
+	// MaxWarpPct / MaxWeftPct are the largest measured shrinkage among the lay's rolls.
+	MaxWarpPct decimal.NullDecimal
+	MaxWeftPct decimal.NullDecimal
+	// CompWarpPct / CompWeftPct are entity.ShrinkageCompensationPct of the maxima: the full
+	// allowance a pattern drawn at finished size would need.
+	CompWarpPct decimal.NullDecimal
+	CompWeftPct decimal.NullDecimal
+	// NominalPct is the article's shrinkage_pct — the allowance the pattern presumably already has.
+	NominalPct decimal.NullDecimal
+	// AdjustWarpPct / AdjustWeftPct = comp(max) − comp(nominal): what to add ON TOP of the pattern.
+	// Negative = the rolls shrink less than the nominal, the pattern is over-compensated. Absent
+	// when either side is unknown.
+	AdjustWarpPct decimal.NullDecimal
+	AdjustWeftPct decimal.NullDecimal
+	Notes         []string
+}
+
+// ComputeLayShrinkageCompensation folds the rolls of one настил against the article's nominal
+// shrinkage. ok=false when the lay names no roll at all — there is nothing to suggest.
+func ComputeLayShrinkageCompensation(lots []LayLotFacts, nominalPct decimal.NullDecimal) (LayShrinkageCompensation, bool) {
+	if len(lots) == 0 {
+		return LayShrinkageCompensation{}, false
+	}
+	out := LayShrinkageCompensation{NominalPct: nominalPct}
+	nominalComp, nominalOK := entity.ShrinkageCompensationPct(nominalPct)
+
+	for _, dir := range []struct {
+		name   string
+		of     func(LayLotFacts) decimal.NullDecimal
+		max    *decimal.NullDecimal
+		comp   *decimal.NullDecimal
+		adjust *decimal.NullDecimal
+	}{
+		{"warp", func(l LayLotFacts) decimal.NullDecimal { return l.ShrinkageWarpPct }, &out.MaxWarpPct, &out.CompWarpPct, &out.AdjustWarpPct},
+		{"weft", func(l LayLotFacts) decimal.NullDecimal { return l.ShrinkageWeftPct }, &out.MaxWeftPct, &out.CompWeftPct, &out.AdjustWeftPct},
+	} {
+		var lo, hi decimal.NullDecimal
+		var unmeasured []string
+		for _, l := range lots {
+			v := dir.of(l)
+			if !v.Valid {
+				unmeasured = append(unmeasured, layLotLabel(l))
+				continue
+			}
+			if !hi.Valid || v.Decimal.GreaterThan(hi.Decimal) {
+				hi = v
+			}
+			if !lo.Valid || v.Decimal.LessThan(lo.Decimal) {
+				lo = v
+			}
+		}
+		if len(unmeasured) > 0 {
+			out.Notes = append(out.Notes, fmt.Sprintf("%s shrinkage not tested on %s — the suggestion covers only the tested rolls",
+				dir.name, strings.Join(unmeasured, ", ")))
+		}
+		if !hi.Valid {
+			continue
+		}
+		*dir.max = hi
+		if spread := hi.Decimal.Sub(lo.Decimal); spread.GreaterThan(layShrinkageSpreadPct) {
+			out.Notes = append(out.Notes, fmt.Sprintf("%s shrinkage varies by %s pp between the rolls (%s…%s%%) — one pattern cannot fit both; consider a lay per roll",
+				dir.name, spread, lo.Decimal, hi.Decimal))
+		}
+		comp, ok := entity.ShrinkageCompensationPct(hi)
+		if !ok {
+			continue
+		}
+		*dir.comp = decimal.NullDecimal{Decimal: comp, Valid: true}
+		if nominalOK {
+			*dir.adjust = decimal.NullDecimal{Decimal: comp.Sub(nominalComp), Valid: true}
+		}
+	}
+	if !nominalPct.Valid && (out.CompWarpPct.Valid || out.CompWeftPct.Valid) {
+		out.Notes = append(out.Notes, "the article has no nominal shrinkage — it is unknown what allowance the pattern already has, so only the full compensation is given")
+	}
+	return out, true
+}
+
+// LayShrinkageCompensationPb is the wire form; nil when there is no suggestion.
+func LayShrinkageCompensationPb(c LayShrinkageCompensation, ok bool) *pb_common.ProductionRunLayShrinkageCompensation {
+	if !ok {
+		return nil
+	}
+	return &pb_common.ProductionRunLayShrinkageCompensation{
+		MaxShrinkageWarpPct: pbDecimalFromNull(c.MaxWarpPct),
+		MaxShrinkageWeftPct: pbDecimalFromNull(c.MaxWeftPct),
+		CompensationWarpPct: pbDecimalFromNull(c.CompWarpPct),
+		CompensationWeftPct: pbDecimalFromNull(c.CompWeftPct),
+		NominalShrinkagePct: pbDecimalFromNull(c.NominalPct),
+		AdjustWarpPct:       pbDecimalFromNull(c.AdjustWarpPct),
+		AdjustWeftPct:       pbDecimalFromNull(c.AdjustWeftPct),
+		Notes:               c.Notes,
+	}
+}
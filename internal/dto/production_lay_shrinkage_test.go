@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayShrinkageCompensationFollowsTheWorstRoll(t *testing.T) {
+	roll := func(id int64, code, warp, weft string) LayLotFacts {
+		l := lotOf(id, code, nd("150"))
+		if warp != "" {
+			l.ShrinkageWarpPct = nd(warp)
+		}
+		if weft != "" {
+			l.ShrinkageWeftPct = nd(weft)
+		}
+		return l
+	}
+
+	if _, ok := ComputeLayShrinkageCompensation(nil, nd("3")); ok {
+		t.Fatal("a lay without rolls has nothing to suggest")
+	}
+
+	// Номинал 3% (лекала уже +3.09%), рулоны сели на 5% и 4.5% по основе: добавить 5.26 − 3.09.
+	c, ok := ComputeLayShrinkageCompensation([]LayLotFacts{roll(4, "LOT-A", "5", "1"), roll(5, "LOT-B", "4.5", "1.5")}, nd("3"))
+	if !ok {
+		t.Fatal("measured rolls must produce a suggestion")
+	}
+	if c.MaxWarpPct.Decimal.String() != "5" || c.CompWarpPct.Decimal.String() != "5.26" || c.AdjustWarpPct.Decimal.String() != "2.17" {
+		t.Fatalf("warp: max %v comp %v adjust %v", c.MaxWarpPct, c.CompWarpPct, c.AdjustWarpPct)
+	}
+	// По утку рулоны сели меньше номинала — лекала перекомпенсированы, поправка отрицательная.
+	if !c.AdjustWeftPct.Valid || !c.AdjustWeftPct.Decimal.IsNegative() {
+		t.Fatalf("weft below the nominal must suggest a negative adjustment: %v", c.AdjustWeftPct)
+	}
+	if len(c.Notes) != 0 {
+		t.Fatalf("a tight, fully tested lay carries no notes: %q", c.Notes)
+	}
+
+	// Разброс 2 п.п. и неизмеренный рулон — оба названы; без номинала поправки нет вовсе.
+	c, _ = ComputeLayShrinkageCompensation([]LayLotFacts{roll(4, "LOT-A", "5", ""), roll(5, "LOT-B", "3", ""), roll(6, "LOT-C", "", "")}, unsetDec)
+	joined := strings.Join(c.Notes, " | ")
+	for _, want := range []string{"varies by 2 pp", "LOT-C", "no nominal shrinkage"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("notes %q miss %q", joined, want)
+		}
+	}
+	if c.AdjustWarpPct.Valid || c.MaxWeftPct.Valid {
+		t.Fatalf("unknown nominal / unmeasured weft must stay absent: adjust %v weft %v", c.AdjustWarpPct, c.MaxWeftPct)
+	}
+}
@@ -295,10 +295,14 @@ func (b *layPlanBuilder) lay(l *entity.ProductionRunLay, cov LayCoverage) (*pb_c
 		// джойном вместе с настилом (LotMeasuredWidthCm) — сырым, с кромкой; кромку снимает сам
 		// предикат, и снимать её здесь значило бы вычесть дважды, всегда в разрешающую сторону.
 		Lot: LayLotFacts{
-			LotId:           l.LotId,
-			LotCode:         l.LotCode,
-			MeasuredWidthCm: l.LotMeasuredWidthCm,
+			LotId:            l.LotId,
+			LotCode:          l.LotCode,
+			MeasuredWidthCm:  l.LotMeasuredWidthCm,
+			ShadeBand:        l.LotShadeBand,
+			ShrinkageWarpPct: l.LotShrinkageWarpPct,
+			ShrinkageWeftPct: l.LotShrinkageWeftPct,
 		},
+		ExtraLots:     layLotFactsOf(l.ExtraLots),
 		Limits:        b.limits(),
 		BomLines:      entity.FabricDirectionLinesOfBom(card.BomItems),
 		PieceSymmetry: cardPieceSymmetry(card),
@@ -316,6 +320,9 @@ func (b *layPlanBuilder) lay(l *entity.ProductionRunLay, cov LayCoverage) (*pb_c
 		}
 	}
 
+	// Припуск — по ВСЕМ рулонам настила, своему и дополнительным, через тот же Lots(), что и отчёт наряда.
+	shrinkage, shrinkageOK := ComputeLayShrinkageCompensation(layLotFactsOf(l.Lots()), article.NominalShrinkagePct)
+
 	stack := LayStackHeightVerdict(in.TotalPlies(), article.FabricThicknessMm, in.Limits.MaxStackHeightCm)
 
 	cloth := decimal.Zero
@@ -403,10 +410,53 @@ func (b *layPlanBuilder) lay(l *entity.ProductionRunLay, cov LayCoverage) (*pb_c
 		// сравнить» и «сошлось ровно» обязаны выглядеть по-разному — иначе цех прочитает первое как
 		// второе и успокоится.
 		ActualDriftPercent: layDriftPercentPb(cloth.Add(endLoss), l),
+
+		// РУЛОНЫ С ЛАБОРАТОРИЕЙ (0348): свой лот первым, затем дополнительные в порядке position.
+		Lots:                  layLotsPb(l),
+		ShrinkageCompensation: LayShrinkageCompensationPb(shrinkage, shrinkageOK),
 	}
 	return out, all
 }
 
+// layLotFactsOf projects stored rolls onto the checks' input.
+func layLotFactsOf(lots []entity.ProductionRunLayLot) []LayLotFacts {
+	if len(lots) == 0 {
+		return nil
+	}
+	out := make([]LayLotFacts, 0, len(lots))
+	for _, r := range lots {
+		out = append(out, LayLotFacts{
+			LotId:            r.LotId,
+			LotCode:          r.LotCode,
+			MeasuredWidthCm:  r.MeasuredWidthCm,
+			ShadeBand:        r.ShadeBand,
+			ShrinkageWarpPct: r.ShrinkageWarpPct,
+			ShrinkageWeftPct: r.ShrinkageWeftPct,
+		})
+	}
+	return out
+}
+
+// layLotsPb lists every roll of the настил. extra is Position > 0: the lay's own lot carries none.
+func layLotsPb(l *entity.ProductionRunLay) []*pb_common.ProductionRunLayLot {
+	lots := l.Lots()
+	out := make([]*pb_common.ProductionRunLayLot, 0, len(lots))
+	for _, r := range lots {
+		out = append(out, &pb_common.ProductionRunLayLot{
+			LotId:            int32(r.LotId.Int64),
+			LotCode:          r.LotCode,
+			Extra:            r.Position > 0,
+			ShadeCode:        r.ShadeCode.String,
+			ShadeBand:        r.ShadeBand.String,
+			ShrinkageWarpPct: pbDecimalFromNull(r.ShrinkageWarpPct),
+			ShrinkageWeftPct: pbDecimalFromNull(r.ShrinkageWeftPct),
+			MeasuredGsm:      pbDecimalFromNull(r.MeasuredGsm),
+			MeasuredWidthCm:  pbDecimalFromNull(r.MeasuredWidthCm),
+		})
+	}
+	return out
+}
+
 // layActualAtPb keeps «замера не было» absent instead of turning it into the zero instant: a
 // timestamp of 1 January year 1 reads as a date, and a date is a claim that somebody measured.
 func layActualAtPb(t sql.NullTime) *timestamppb.Timestamp {
@@ -631,6 +681,9 @@ func (b *layPlanBuilder) article(materialID int) LayArticleFacts {
 	out.SelvedgeCm = m.FabricSelvedgeCm()
 	out.FabricThicknessMm = m.EffectiveFabricThicknessMm()
 	out.NarrowestMeasuredLotCm = b.in.NarrowestMeasuredLotCm[materialID]
+	if m.FabricAttr != nil {
+		out.NominalShrinkagePct = m.FabricAttr.ShrinkagePct
+	}
 	return out
 }
 
@@ -862,6 +915,14 @@ func layLotAndActualFromPb(pb *pb_common.ProductionRunLayInsert, out *entity.Pro
 		bind := int(pb.GetLotId())
 		out.LotId = &bind
 	}
+	// Остальные рулоны (0348) — тот же договор о молчании: без set_extra_lots список не трогается.
+	if pb.GetSetExtraLots() {
+		ids := make([]int, 0, len(pb.GetExtraLotIds()))
+		for _, id := range pb.GetExtraLotIds() {
+			ids = append(ids, int(id))
+		}
+		out.ExtraLotIds = &ids
+	}
 
 	if pb.GetClearActual() {
 		// Факт без количества = снять факт целиком, вместе с автором и временем: «замер отозван» и
@@ -0,0 +1,158 @@
+package dto
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const scheduleDateLayout = "2006-01-02"
+
+// Schedule horizon: the timeline drawn, not how far the scheduler looks (runschedule.LookaheadDays).
+const (
+	DefaultScheduleHorizonDays = 28
+	MaxScheduleHorizonDays     = 180
+)
+
+// ScheduleWindowFromPb reads the timeline's first day (today, UTC, when empty) and its length.
+func ScheduleWindowFromPb(req *pb_admin.GetProductionScheduleRequest, now time.Time) (time.Time, int, error) {
+	from := now.UTC()
+	if v := strings.TrimSpace(req.GetFrom()); v != "" {
+		t, err := time.Parse(scheduleDateLayout, v)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("from must be YYYY-MM-DD")
+		}
+		from = t
+	}
+	horizon := int(req.GetHorizonDays())
+	switch {
+	case horizon == 0:
+		horizon = DefaultScheduleHorizonDays
+	case horizon < 0 || horizon > MaxScheduleHorizonDays:
+		return time.Time{}, 0, fmt.Errorf("horizon_days must be between 1 and %d", MaxScheduleHorizonDays)
+	}
+	return time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC), horizon, nil
+}
+
+// HolidayDayFromPb reads a workshop holiday's date, which is required.
+func HolidayDayFromPb(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, fmt.Errorf("day is required")
+	}
+	t, err := time.Parse(scheduleDateLayout, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("day must be YYYY-MM-DD")
+	}
+	return t, nil
+}
+
+// HolidayRangeFromPb reads the ListWorkshopHolidays bounds; an empty bound is the zero time.
+func HolidayRangeFromPb(req *pb_admin.ListWorkshopHolidaysRequest) (time.Time, time.Time, error) {
+	from, err := parseNullDate(req.GetFrom())
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from %w", err)
+	}
+	to, err := parseNullDate(req.GetTo())
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to %w", err)
+	}
+	if from.Valid && to.Valid && to.Time.Before(from.Time) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must not be before from")
+	}
+	return from.Time, to.Time, nil
+}
+
+// HolidayNoteFromPb validates the free-text note of a closed day.
+func HolidayNoteFromPb(v string) (sql.NullString, error) {
+	v = strings.TrimSpace(v)
+	if utf8.RuneCountInString(v) > maxVarchar255 {
+		return sql.NullString{}, fmt.Errorf("note must be at most %d characters", maxVarchar255)
+	}
+	return trimmedNullString(v), nil
+}
+
+// WorkshopHolidayListToPb converts closed days to protobuf.
+func WorkshopHolidayListToPb(list []entity.WorkshopHoliday) []*pb_admin.WorkshopHoliday {
+	out := make([]*pb_admin.WorkshopHoliday, 0, len(list))
+	for _, h := range list {
+		out = append(out, &pb_admin.WorkshopHoliday{
+			Day:       h.Day.Format(scheduleDateLayout),
+			Note:      h.Note.String,
+			CreatedBy: h.CreatedBy,
+			CreatedAt: timestamppb.New(h.CreatedAt),
+		})
+	}
+	return out
+}
+
+// ProductionScheduleToPb converts the scheduler's timeline to protobuf.
+func ProductionScheduleToPb(s entity.ProductionSchedule) *pb_admin.GetProductionScheduleResponse {
+	out := &pb_admin.GetProductionScheduleResponse{
+		CapacityConfigured: s.Capacity.Configured,
+		From:               s.From.Format(scheduleDateLayout),
+		Days:               make([]*pb_admin.ProductionScheduleDay, 0, len(s.Days)),
+		Bars:               make([]*pb_admin.ProductionScheduleBar, 0, len(s.Bars)),
+		Conflicts:          make([]*pb_admin.ProductionScheduleConflict, 0, len(s.Conflicts)),
+	}
+	if s.Capacity.Configured {
+		out.DailyCapacityMinutes = pbDecimalFromDecimal(s.Capacity.DailyMinutes)
+	}
+	for _, d := range s.Days {
+		out.Days = append(out.Days, &pb_admin.ProductionScheduleDay{
+			Date:            d.Date.Format(scheduleDateLayout),
+			Working:         d.Working,
+			HolidayNote:     d.HolidayNote,
+			CapacityMinutes: pbDecimalFromDecimal(d.CapacityMinutes),
+			ProposedMinutes: pbDecimalFromDecimal(d.ProposedMinutes),
+			PlannedMinutes:  pbDecimalFromDecimal(d.PlannedMinutes),
+		})
+	}
+	for _, b := range s.Bars {
+		pb := &pb_admin.ProductionScheduleBar{
+			RunId:            int32(b.Run.RunId),
+			TechCardId:       int32(b.Run.TechCardId),
+			TechCardName:     b.Run.TechCardName,
+			StyleNumber:      b.Run.StyleNumber,
+			Status:           string(b.Run.Status),
+			PlannedStartAt:   nullTimeToPb(b.Run.PlannedStartAt),
+			PromisedAt:       nullTimeToPb(b.Run.PromisedAt),
+			PlannedQty:       int32(b.Run.PlannedQty),
+			DoneQty:          int32(b.Run.DoneQty),
+			SmvPerUnit:       pbDecimalFromNull(b.Run.SMVPerUnit),
+			LoadMinutes:      pbDecimalFromDecimal(b.LoadMinutes),
+			RemainingMinutes: pbDecimalFromDecimal(b.RemainingMinutes),
+			Warnings:         make([]string, 0, len(b.Warnings)),
+		}
+		if b.ProposedStart.Valid {
+			pb.ProposedStart = b.ProposedStart.Time.Format(scheduleDateLayout)
+		}
+		if b.ProposedFinish.Valid {
+			pb.ProposedFinish = b.ProposedFinish.Time.Format(scheduleDateLayout)
+		}
+		for _, w := range b.Warnings {
+			pb.Warnings = append(pb.Warnings, string(w))
+		}
+		out.Bars = append(out.Bars, pb)
+	}
+	for _, c := range s.Conflicts {
+		pc := &pb_admin.ProductionScheduleConflict{
+			From:            c.From.Format(scheduleDateLayout),
+			To:              c.To.Format(scheduleDateLayout),
+			PeakMinutes:     pbDecimalFromDecimal(c.PeakMinutes),
+			CapacityMinutes: pbDecimalFromDecimal(c.CapacityMinutes),
+			RunIds:          make([]int32, 0, len(c.RunIds)),
+		}
+		for _, id := range c.RunIds {
+			pc.RunIds = append(pc.RunIds, int32(id))
+		}
+		out.Conflicts = append(out.Conflicts, pc)
+	}
+	return out
+}
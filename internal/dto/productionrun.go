@@ -527,6 +527,13 @@ func ConvertPbReceiptLinesToEntity(pbs []*pb_admin.PostProductionRunReceiptLineI
 // the lock version is a concurrency token, not intent: a retry of the same count after a refetch
 // must replay, not die on AlreadyExists.
 func HashProductionRunReceiptPayload(runID int, lines []entity.ProductionRunReceiptLineInput, note string, updateCostPrice, final bool) string {
+	return HashProductionRunReceiptInspected(runID, lines, note, updateCostPrice, final, 0)
+}
+
+// HashProductionRunReceiptInspected is HashProductionRunReceiptPayload with the QC inspection (0343)
+// the receipt is booked against. The same key with another inspection is another intent — it would
+// consume a different sample's verdict.
+func HashProductionRunReceiptInspected(runID int, lines []entity.ProductionRunReceiptLineInput, note string, updateCostPrice, final bool, inspectionID int) string {
 	sorted := make([]entity.ProductionRunReceiptLineInput, len(lines))
 	copy(sorted, lines)
 	sort.Slice(sorted, func(i, j int) bool { return sorted[i].LineKey < sorted[j].LineKey })
@@ -541,6 +548,11 @@ func HashProductionRunReceiptPayload(runID int, lines []entity.ProductionRunRece
 			fmt.Fprintf(h, "/seconds")
 		}
 	}
+	if inspectionID > 0 {
+		// Same rule as the disposition: only a receipt that names an inspection hashes it, so every
+		// payload from before the QC gate keeps its hash.
+		fmt.Fprintf(h, ";qc=%d", inspectionID)
+	}
 	return hex.EncodeToString(h.Sum(nil))
 }
 
@@ -319,3 +319,13 @@ func TestConvertPbProductionRunInsertLeavesTheMixedGridToTheStore(t *testing.T)
 	require.False(t, e.Lines[0].OutputVariantId.Valid)
 	require.True(t, e.Lines[1].OutputVariantId.Valid)
 }
+
+// A receipt without an inspection keeps the pre-QC hash; naming one makes it another intent.
+func TestHashProductionRunReceiptInspected(t *testing.T) {
+	lines := []entity.ProductionRunReceiptLineInput{{LineKey: "B", GoodQty: 3}, {LineKey: "A", GoodQty: 1, DefectQty: 1}}
+	plain := HashProductionRunReceiptPayload(9, lines, "n", false, true)
+	require.Equal(t, plain, HashProductionRunReceiptInspected(9, lines, "n", false, true, 0))
+	inspected := HashProductionRunReceiptInspected(9, lines, "n", false, true, 4)
+	require.NotEqual(t, plain, inspected)
+	require.NotEqual(t, inspected, HashProductionRunReceiptInspected(9, lines, "n", false, true, 5))
+}
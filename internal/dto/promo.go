@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
@@ -34,18 +35,95 @@ func ConvertPbCommonPromoToEntity(pbPromo *pb_common.PromoCodeInsert) (*entity.P
 	}
 
 	entityPromo := &entity.PromoCodeInsert{
-		Code:         pbPromo.Code,
-		FreeShipping: pbPromo.FreeShipping,
-		Discount:     discountDecimal,
-		Expiration:   pbPromo.Expiration.AsTime(),
-		Start:        pbPromo.Start.AsTime(),
-		Allowed:      pbPromo.Allowed,
-		Voucher:      pbPromo.Voucher,
+		Code:               pbPromo.Code,
+		FreeShipping:       pbPromo.FreeShipping,
+		Discount:           discountDecimal,
+		Expiration:         pbPromo.Expiration.AsTime(),
+		Start:              pbPromo.Start.AsTime(),
+		Allowed:            pbPromo.Allowed,
+		Voucher:            pbPromo.Voucher,
+		DiscountType:       promoDiscountTypeFromPb[pbPromo.DiscountType],
+		MinTier:            int16(pbPromo.MinTier),
+		MaxUses:            sql.NullInt32{Int32: pbPromo.MaxUses, Valid: pbPromo.MaxUses > 0},
+		MaxUsesPerCustomer: sql.NullInt32{Int32: pbPromo.MaxUsesPerCustomer, Valid: pbPromo.MaxUsesPerCustomer > 0},
+		BuyQuantity:        int(pbPromo.BuyQuantity),
+		GetQuantity:        int(pbPromo.GetQuantity),
+		Stacking:           promoStackingFromPb[pbPromo.Stacking],
+		ExcludeSaleItems:   pbPromo.ExcludeSaleItems,
+		UniqueCodesOnly:    pbPromo.UniqueCodesOnly,
+	}
+
+	for _, r := range pbPromo.CurrencyRules {
+		rule := entity.PromoCurrencyRule{Currency: r.GetCurrency()}
+		if rule.FixedAmount, err = optionalPbDecimal(r.GetFixedAmount()); err != nil {
+			return nil, fmt.Errorf("error converting fixed amount for %s: %v", r.GetCurrency(), err)
+		}
+		if rule.MinSpend, err = optionalPbDecimal(r.GetMinSpend()); err != nil {
+			return nil, fmt.Errorf("error converting min spend for %s: %v", r.GetCurrency(), err)
+		}
+		entityPromo.CurrencyRules = append(entityPromo.CurrencyRules, rule)
+	}
+	for _, sc := range pbPromo.Scopes {
+		kind, ok := promoScopeKindFromPb[sc.GetKind()]
+		if !ok {
+			return nil, fmt.Errorf("unknown promo scope kind %s", sc.GetKind())
+		}
+		entityPromo.Scopes = append(entityPromo.Scopes, entity.PromoScope{Kind: kind, Value: sc.GetValue()})
 	}
 
 	return entityPromo, nil
 }
 
+var (
+	promoDiscountTypeToPb = map[entity.PromoDiscountType]pb_common.PromoDiscountTypeEnum{
+		entity.PromoDiscountPercentage:  pb_common.PromoDiscountTypeEnum_PROMO_DISCOUNT_TYPE_ENUM_PERCENTAGE,
+		entity.PromoDiscountFixedAmount: pb_common.PromoDiscountTypeEnum_PROMO_DISCOUNT_TYPE_ENUM_FIXED_AMOUNT,
+		entity.PromoDiscountBuyXGetY:    pb_common.PromoDiscountTypeEnum_PROMO_DISCOUNT_TYPE_ENUM_BUY_X_GET_Y,
+	}
+	promoDiscountTypeFromPb = map[pb_common.PromoDiscountTypeEnum]entity.PromoDiscountType{
+		pb_common.PromoDiscountTypeEnum_PROMO_DISCOUNT_TYPE_ENUM_PERCENTAGE:   entity.PromoDiscountPercentage,
+		pb_common.PromoDiscountTypeEnum_PROMO_DISCOUNT_TYPE_ENUM_FIXED_AMOUNT: entity.PromoDiscountFixedAmount,
+		pb_common.PromoDiscountTypeEnum_PROMO_DISCOUNT_TYPE_ENUM_BUY_X_GET_Y:  entity.PromoDiscountBuyXGetY,
+	}
+	promoStackingToPb = map[entity.PromoStacking]pb_common.PromoStackingEnum{
+		entity.PromoStackingExclusive: pb_common.PromoStackingEnum_PROMO_STACKING_ENUM_EXCLUSIVE,
+		entity.PromoStackingStackable: pb_common.PromoStackingEnum_PROMO_STACKING_ENUM_STACKABLE,
+	}
+	promoStackingFromPb = map[pb_common.PromoStackingEnum]entity.PromoStacking{
+		pb_common.PromoStackingEnum_PROMO_STACKING_ENUM_EXCLUSIVE: entity.PromoStackingExclusive,
+		pb_common.PromoStackingEnum_PROMO_STACKING_ENUM_STACKABLE: entity.PromoStackingStackable,
+	}
+	promoScopeKindToPb = map[entity.PromoScopeKind]pb_common.PromoScopeKindEnum{
+		entity.PromoScopeCollection:  pb_common.PromoScopeKindEnum_PROMO_SCOPE_KIND_ENUM_COLLECTION,
+		entity.PromoScopeTag:         pb_common.PromoScopeKindEnum_PROMO_SCOPE_KIND_ENUM_TAG,
+		entity.PromoScopeTopCategory: pb_common.PromoScopeKindEnum_PROMO_SCOPE_KIND_ENUM_TOP_CATEGORY,
+	}
+	promoScopeKindFromPb = map[pb_common.PromoScopeKindEnum]entity.PromoScopeKind{
+		pb_common.PromoScopeKindEnum_PROMO_SCOPE_KIND_ENUM_COLLECTION:   entity.PromoScopeCollection,
+		pb_common.PromoScopeKindEnum_PROMO_SCOPE_KIND_ENUM_TAG:          entity.PromoScopeTag,
+		pb_common.PromoScopeKindEnum_PROMO_SCOPE_KIND_ENUM_TOP_CATEGORY: entity.PromoScopeTopCategory,
+	}
+)
+
+// optionalPbDecimal maps an unset or empty decimal to an invalid NullDecimal.
+func optionalPbDecimal(d *pb_decimal.Decimal) (decimal.NullDecimal, error) {
+	if d == nil || d.Value == "" {
+		return decimal.NullDecimal{}, nil
+	}
+	v, err := decimal.NewFromString(d.Value)
+	if err != nil {
+		return decimal.NullDecimal{}, err
+	}
+	return decimal.NullDecimal{Decimal: v, Valid: true}, nil
+}
+
+func nullDecimalToPb(d decimal.NullDecimal) *pb_decimal.Decimal {
+	if !d.Valid {
+		return nil
+	}
+	return &pb_decimal.Decimal{Value: d.Decimal.String()}
+}
+
 // ConvertEntityToPb converts an entity.PromoCode to pb_common.PromoCode
 func ConvertEntityPromoToPb(entityPromo entity.PromoCode) *pb_common.PromoCode {
 	pbPromo := &pb_common.PromoCode{
@@ -61,14 +139,74 @@ func ConvertEntityPromoInsertToPb(entityPromo entity.PromoCodeInsert) *pb_common
 
 	// Create pb_common.PromoCodeInsert
 	pbPromoInsert := &pb_common.PromoCodeInsert{
-		Code:         entityPromo.Code,
-		FreeShipping: entityPromo.FreeShipping,
-		Discount:     &pb_decimal.Decimal{Value: discountStr},
-		Expiration:   timestamppb.New(entityPromo.Expiration),
-		Start:        timestamppb.New(entityPromo.Start),
-		Allowed:      entityPromo.Allowed,
-		Voucher:      entityPromo.Voucher,
+		Code:               entityPromo.Code,
+		FreeShipping:       entityPromo.FreeShipping,
+		Discount:           &pb_decimal.Decimal{Value: discountStr},
+		Expiration:         timestamppb.New(entityPromo.Expiration),
+		Start:              timestamppb.New(entityPromo.Start),
+		Allowed:            entityPromo.Allowed,
+		Voucher:            entityPromo.Voucher,
+		DiscountType:       promoDiscountTypeToPb[entityPromo.Type()],
+		MinTier:            int32(entityPromo.MinTier),
+		MaxUses:            entityPromo.MaxUses.Int32,
+		BuyQuantity:        int32(entityPromo.BuyQuantity),
+		GetQuantity:        int32(entityPromo.GetQuantity),
+		Stacking:           promoStackingToPb[entityPromo.Stacking],
+		MaxUsesPerCustomer: entityPromo.MaxUsesPerCustomer.Int32,
+		ExcludeSaleItems:   entityPromo.ExcludeSaleItems,
+		UniqueCodesOnly:    entityPromo.UniqueCodesOnly,
+	}
+	for _, r := range entityPromo.CurrencyRules {
+		pbPromoInsert.CurrencyRules = append(pbPromoInsert.CurrencyRules, &pb_common.PromoCurrencyRule{
+			Currency:    r.Currency,
+			FixedAmount: nullDecimalToPb(r.FixedAmount),
+			MinSpend:    nullDecimalToPb(r.MinSpend),
+		})
+	}
+	for _, sc := range entityPromo.Scopes {
+		pbPromoInsert.Scopes = append(pbPromoInsert.Scopes, &pb_common.PromoScope{
+			Kind:  promoScopeKindToPb[sc.Kind],
+			Value: sc.Value,
+		})
 	}
 
 	return pbPromoInsert
 }
+
+// ConvertEntityPromoBreakdownToPb is what the storefront shows for the entered codes.
+func ConvertEntityPromoBreakdownToPb(bd *entity.PromoBreakdown, currency string) *pb_common.PromoBreakdown {
+	if bd == nil {
+		return nil
+	}
+	pb := &pb_common.PromoBreakdown{
+		Discount:     &pb_decimal.Decimal{Value: RoundForCurrency(bd.Discount, currency).String()},
+		FreeShipping: bd.FreeShipping,
+	}
+	for _, a := range bd.Applied {
+		pb.Applied = append(pb.Applied, &pb_common.PromoApplication{
+			Code:         a.Code,
+			DiscountType: promoDiscountTypeToPb[a.DiscountType],
+			Discount:     &pb_decimal.Decimal{Value: RoundForCurrency(a.Discount, currency).String()},
+			FreeShipping: a.FreeShipping,
+		})
+	}
+	for _, r := range bd.Rejected {
+		pb.Rejected = append(pb.Rejected, &pb_common.PromoRejection{Code: r.Code, Reason: string(r.Reason)})
+	}
+	return pb
+}
+
+// ConvertEntityPromoUniqueCodeToPb converts a generated single-use code.
+func ConvertEntityPromoUniqueCodeToPb(uc *entity.PromoUniqueCode) *pb_common.PromoUniqueCode {
+	pb := &pb_common.PromoUniqueCode{
+		Id:        int32(uc.Id),
+		Code:      uc.Code,
+		Batch:     uc.Batch,
+		OrderUuid: uc.OrderUUID.String,
+		CreatedAt: timestamppb.New(uc.CreatedAt),
+	}
+	if uc.RedeemedAt.Valid {
+		pb.RedeemedAt = timestamppb.New(uc.RedeemedAt.Time)
+	}
+	return pb
+}
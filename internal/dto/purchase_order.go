@@ -0,0 +1,281 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// purchaseOrderStatusPbToEntity maps the proto status enum to the stored string.
+var purchaseOrderStatusPbToEntity = map[pb_common.PurchaseOrderStatus]entity.PurchaseOrderStatus{
+	pb_common.PurchaseOrderStatus_PURCHASE_ORDER_STATUS_DRAFT:              entity.PurchaseOrderDraft,
+	pb_common.PurchaseOrderStatus_PURCHASE_ORDER_STATUS_ORDERED:            entity.PurchaseOrderOrdered,
+	pb_common.PurchaseOrderStatus_PURCHASE_ORDER_STATUS_PARTIALLY_RECEIVED: entity.PurchaseOrderPartiallyReceived,
+	pb_common.PurchaseOrderStatus_PURCHASE_ORDER_STATUS_RECEIVED:           entity.PurchaseOrderReceived,
+	pb_common.PurchaseOrderStatus_PURCHASE_ORDER_STATUS_CLOSED:             entity.PurchaseOrderClosed,
+	pb_common.PurchaseOrderStatus_PURCHASE_ORDER_STATUS_CANCELLED:          entity.PurchaseOrderCancelled,
+}
+
+// purchaseOrderStatusEntityToPb is the reverse map.
+var purchaseOrderStatusEntityToPb = func() map[entity.PurchaseOrderStatus]pb_common.PurchaseOrderStatus {
+	m := make(map[entity.PurchaseOrderStatus]pb_common.PurchaseOrderStatus, len(purchaseOrderStatusPbToEntity))
+	for k, v := range purchaseOrderStatusPbToEntity {
+		m[v] = k
+	}
+	return m
+}()
+
+// PurchaseOrderStatusFromPb maps a wire status; UNKNOWN (and anything unmapped) is "".
+func PurchaseOrderStatusFromPb(s pb_common.PurchaseOrderStatus) entity.PurchaseOrderStatus {
+	return purchaseOrderStatusPbToEntity[s]
+}
+
+const (
+	// purchaseQtyMaxFrac / purchasePriceMaxFrac are the scales of purchase_order_line's DECIMAL(12,3)
+	// quantities and DECIMAL(12,4) price — the same scales as the movement ledger they feed.
+	purchaseQtyMaxFrac    = 3
+	purchasePriceMaxFrac  = 4
+	purchaseColumnLimit   = 100000000
+	maxPurchaseOrderLines = 200
+)
+
+// ConvertPbPurchaseOrderInsert validates a draft's contents. CreatedBy is set by the handler from the
+// auth context, not here.
+func ConvertPbPurchaseOrderInsert(pb *pb_admin.PurchaseOrderInsert) (entity.PurchaseOrderInsert, error) {
+	var out entity.PurchaseOrderInsert
+	if pb == nil {
+		return out, fmt.Errorf("order is required")
+	}
+	if pb.GetSupplierId() <= 0 {
+		return out, fmt.Errorf("supplier_id is required")
+	}
+	currency := strings.ToUpper(strings.TrimSpace(pb.GetCurrency()))
+	if !IsExpenseCurrency(currency) {
+		return out, fmt.Errorf("currency must be a supported currency or USDT")
+	}
+	if pb.GetProductionRunId() < 0 {
+		return out, fmt.Errorf("production_run_id must not be negative")
+	}
+	supplierRef := strings.TrimSpace(pb.GetSupplierRef())
+	if len(supplierRef) > maxVarchar64 {
+		return out, fmt.Errorf("supplier_ref must be at most %d characters", maxVarchar64)
+	}
+	notes := strings.TrimSpace(pb.GetNotes())
+	if len(notes) > 512 {
+		return out, fmt.Errorf("notes must be at most 512 characters")
+	}
+	if len(pb.GetLines()) == 0 {
+		return out, fmt.Errorf("at least one line is required")
+	}
+	if len(pb.GetLines()) > maxPurchaseOrderLines {
+		return out, fmt.Errorf("at most %d lines per order", maxPurchaseOrderLines)
+	}
+	seen := map[int32]bool{}
+	for i, l := range pb.GetLines() {
+		field := fmt.Sprintf("lines[%d]", i)
+		if l.GetMaterialId() <= 0 {
+			return out, fmt.Errorf("%s.material_id is required", field)
+		}
+		// One line per material: a receipt names the line it delivers, and two lines of the same
+		// cloth would leave the storeman guessing which one the roll belongs to.
+		if seen[l.GetMaterialId()] {
+			return out, fmt.Errorf("%s: material %d is already on the order", field, l.GetMaterialId())
+		}
+		seen[l.GetMaterialId()] = true
+		qty, err := positiveDecimal(l.GetOrderedQty().GetValue(), field+".ordered_qty")
+		if err != nil {
+			return out, err
+		}
+		if err := validateDecimalScale(decimal.NullDecimal{Decimal: qty, Valid: true}, field+".ordered_qty",
+			purchaseQtyMaxFrac, purchaseColumnLimit); err != nil {
+			return out, err
+		}
+		price, err := nullDecimalFromPb(l.GetUnitPrice())
+		if err != nil {
+			return out, fmt.Errorf("%s.unit_price: %w", field, err)
+		}
+		if err := validateDecimalScale(price, field+".unit_price", purchasePriceMaxFrac, purchaseColumnLimit); err != nil {
+			return out, err
+		}
+		expectedAt, err := parseNullDate(l.GetExpectedAt())
+		if err != nil {
+			return out, fmt.Errorf("%s.expected_at: %w", field, err)
+		}
+		note := strings.TrimSpace(l.GetNote())
+		if len(note) > maxVarchar255 {
+			return out, fmt.Errorf("%s.note must be at most %d characters", field, maxVarchar255)
+		}
+		out.Lines = append(out.Lines, entity.PurchaseOrderLineInsert{
+			MaterialId: int(l.GetMaterialId()),
+			OrderedQty: qty,
+			UnitPrice:  price,
+			ExpectedAt: expectedAt,
+			Note:       nullStringFromPb(note),
+		})
+	}
+	out.SupplierId = int(pb.GetSupplierId())
+	out.Currency = currency
+	out.ProductionRunId = nullInt32FromPb(pb.GetProductionRunId())
+	out.SupplierRef = nullStringFromPb(supplierRef)
+	out.Notes = nullStringFromPb(notes)
+	return out, nil
+}
+
+// HasPurchaseOrderPrices reports whether a draft carries any price — the handler's costing:write check.
+func HasPurchaseOrderPrices(ins entity.PurchaseOrderInsert) bool {
+	for _, l := range ins.Lines {
+		if l.UnitPrice.Valid {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertPbReceivePurchaseOrder validates a delivery against a purchase order. The lot rules are
+// ReceiveMaterialStock's: a measured width or a shade is recorded on a lot, so either one without a
+// lot code is refused rather than dropped.
+func ConvertPbReceivePurchaseOrder(req *pb_admin.ReceivePurchaseOrderRequest) (entity.PurchaseOrderReceipt, error) {
+	var out entity.PurchaseOrderReceipt
+	if req.GetId() <= 0 {
+		return out, fmt.Errorf("id is required")
+	}
+	if len(req.GetLines()) == 0 {
+		return out, fmt.Errorf("at least one line is required")
+	}
+	seen := map[int32]bool{}
+	for i, l := range req.GetLines() {
+		field := fmt.Sprintf("lines[%d]", i)
+		if l.GetLineId() <= 0 {
+			return out, fmt.Errorf("%s.line_id is required", field)
+		}
+		if seen[l.GetLineId()] {
+			return out, fmt.Errorf("%s: line %d is already in the receipt", field, l.GetLineId())
+		}
+		seen[l.GetLineId()] = true
+		qty, err := positiveDecimal(l.GetQuantity().GetValue(), field+".quantity")
+		if err != nil {
+			return out, err
+		}
+		if err := validateDecimalScale(decimal.NullDecimal{Decimal: qty, Valid: true}, field+".quantity",
+			purchaseQtyMaxFrac, purchaseColumnLimit); err != nil {
+			return out, err
+		}
+		width, err := nullDecimalFromPb(l.GetMeasuredWidthCm())
+		if err != nil {
+			return out, fmt.Errorf("%s.measured_width_cm: %w", field, err)
+		}
+		if width.Valid && !width.Decimal.IsPositive() {
+			return out, fmt.Errorf("%s.measured_width_cm must be positive when set", field)
+		}
+		if err := validateDecimalScale(width, field+".measured_width_cm", 2, 10000); err != nil {
+			return out, err
+		}
+		shade := strings.TrimSpace(l.GetShadeCode())
+		if len(shade) > maxVarchar64 {
+			return out, fmt.Errorf("%s.shade_code must be at most %d characters", field, maxVarchar64)
+		}
+		lot := strings.TrimSpace(l.GetLot())
+		if lot == "" && (width.Valid || shade != "") {
+			return out, fmt.Errorf("%s: measured_width_cm/shade_code are recorded on the lot — set `lot` to record them", field)
+		}
+		out.Lines = append(out.Lines, entity.PurchaseOrderReceiptLine{
+			LineId:          int(l.GetLineId()),
+			Quantity:        qty,
+			Lot:             nullStringFromPb(lot),
+			MeasuredWidthCm: width,
+			ShadeCode:       nullStringFromPb(shade),
+		})
+	}
+	occurredAt, err := parseNullDate(req.GetOccurredAt())
+	if err != nil {
+		return out, fmt.Errorf("occurred_at: %w", err)
+	}
+	vatAmount, vatRegime, err := convertInputVat(req.GetInputVatAmount(), req.GetInputVatRegime())
+	if err != nil {
+		return out, err
+	}
+	out.PurchaseOrderId = int(req.GetId())
+	out.SupplierDoc = nullStringFromPb(strings.TrimSpace(req.GetSupplierDoc()))
+	out.OccurredAt = occurredAt
+	out.InputVatAmount = vatAmount
+	out.InputVatRegime = vatRegime
+	out.Comment = nullStringFromPb(strings.TrimSpace(req.GetComment()))
+	return out, nil
+}
+
+// ConvertPurchaseOrderToPb converts an order with its lines. `today` decides is_late.
+func ConvertPurchaseOrderToPb(p entity.PurchaseOrderFull, today time.Time) *pb_common.PurchaseOrder {
+	out := &pb_common.PurchaseOrder{
+		Id:              int32(p.Id),
+		Number:          entity.PurchaseOrderNumber(p.Id),
+		SupplierId:      int32(p.SupplierId),
+		SupplierName:    p.SupplierName,
+		Status:          purchaseOrderStatusEntityToPb[p.Status],
+		Currency:        p.Currency,
+		OrderedAt:       dateString(p.OrderedAt),
+		ExpectedAt:      dateString(p.ExpectedAt),
+		ProductionRunId: nullInt32Value(p.ProductionRunId),
+		SupplierRef:     p.SupplierRef.String,
+		Notes:           p.Notes.String,
+		OrderedValue:    pbDecimalFromDecimal(p.OrderedValue()),
+		ReceivedValue:   pbDecimalFromDecimal(p.ReceivedValue()),
+		IsLate:          p.IsLate(today),
+		CreatedBy:       p.CreatedBy,
+		CreatedAt:       timestamppb.New(p.CreatedAt),
+		UpdatedAt:       timestamppb.New(p.UpdatedAt),
+	}
+	for _, l := range p.Lines {
+		out.Lines = append(out.Lines, &pb_common.PurchaseOrderLine{
+			Id:             int32(l.Id),
+			MaterialId:     int32(l.MaterialId),
+			MaterialName:   l.MaterialName,
+			Unit:           l.Unit.String,
+			OrderedQty:     pbDecimalFromDecimal(l.OrderedQty),
+			ReceivedQty:    pbDecimalFromDecimal(l.ReceivedQty),
+			OutstandingQty: pbDecimalFromDecimal(l.OutstandingQty()),
+			UnitPrice:      pbDecimalFromNull(l.UnitPrice),
+			ExpectedAt:     dateString(l.ExpectedAt),
+			Note:           l.Note.String,
+		})
+	}
+	return out
+}
+
+// StripPurchaseOrderCosting clears the price and value fields for an account without costing:read.
+func StripPurchaseOrderCosting(pb *pb_common.PurchaseOrder) {
+	pb.OrderedValue = nil
+	pb.ReceivedValue = nil
+	for _, l := range pb.Lines {
+		l.UnitPrice = nil
+	}
+}
+
+// ConvertPurchaseNeedsToPb converts the needs a suggestion did not turn into order lines.
+func ConvertPurchaseNeedsToPb(needs []entity.PurchaseNeed) []*pb_admin.PurchaseNeedRow {
+	out := make([]*pb_admin.PurchaseNeedRow, 0, len(needs))
+	for _, n := range needs {
+		out = append(out, &pb_admin.PurchaseNeedRow{
+			MaterialId:   int32(n.MaterialId),
+			MaterialName: n.MaterialName,
+			Unit:         n.Unit,
+			Shortage:     pbDecimalFromDecimal(n.Shortage),
+			OnOrder:      pbDecimalFromDecimal(n.OnOrder),
+			SupplierId:   int32(n.SupplierId),
+		})
+	}
+	return out
+}
+
+// ApplySupplierLeadTimeToPb adds a supplier's observed lead times to its catalog entry.
+func ApplySupplierLeadTimeToPb(pb *pb_admin.Supplier, lt entity.SupplierLeadTime) {
+	pb.ObservedLeadTimeDays = lt.AvgLeadTimeDays
+	pb.ObservedMaxLeadTimeDays = int32(lt.MaxLeadTimeDays)
+	pb.ReceivedOrderCount = int32(lt.ReceivedOrders)
+	pb.LateOrderCount = int32(lt.LateOrders)
+}
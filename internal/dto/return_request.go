@@ -0,0 +1,113 @@
+package dto
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	returnStatusToPb = map[entity.ReturnRequestStatus]pb_common.ReturnRequestStatusEnum{
+		entity.ReturnStatusRequested: pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_REQUESTED,
+		entity.ReturnStatusApproved:  pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_APPROVED,
+		entity.ReturnStatusRejected:  pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_REJECTED,
+		entity.ReturnStatusReceived:  pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_RECEIVED,
+		entity.ReturnStatusRefunding: pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_REFUNDING,
+		entity.ReturnStatusRefunded:  pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_REFUNDED,
+		entity.ReturnStatusExchanged: pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_EXCHANGED,
+		entity.ReturnStatusCancelled: pb_common.ReturnRequestStatusEnum_RETURN_REQUEST_STATUS_ENUM_CANCELLED,
+	}
+	returnResolutionToPb = map[entity.ReturnResolution]pb_common.ReturnResolutionEnum{
+		entity.ReturnResolutionRefund:   pb_common.ReturnResolutionEnum_RETURN_RESOLUTION_ENUM_REFUND,
+		entity.ReturnResolutionExchange: pb_common.ReturnResolutionEnum_RETURN_RESOLUTION_ENUM_EXCHANGE,
+	}
+	returnReasonToPb = map[string]pb_common.ReturnReasonEnum{
+		"wrong_size":       pb_common.ReturnReasonEnum_RETURN_REASON_ENUM_WRONG_SIZE,
+		"not_as_described": pb_common.ReturnReasonEnum_RETURN_REASON_ENUM_NOT_AS_DESCRIBED,
+		"defective":        pb_common.ReturnReasonEnum_RETURN_REASON_ENUM_DEFECTIVE,
+		"changed_mind":     pb_common.ReturnReasonEnum_RETURN_REASON_ENUM_CHANGED_MIND,
+		"other":            pb_common.ReturnReasonEnum_RETURN_REASON_ENUM_OTHER,
+	}
+)
+
+// ReturnReasonKey maps the storefront ReturnReasonEnum to the refund reason key (see RefundReasonKey).
+// Returns "" for UNKNOWN, which the entity validation rejects.
+func ReturnReasonKey(r pb_common.ReturnReasonEnum) string {
+	for k, v := range returnReasonToPb {
+		if v == r {
+			return k
+		}
+	}
+	return ""
+}
+
+// ConvertPbReturnStatusToEntity maps a status filter; ok=false for UNKNOWN.
+func ConvertPbReturnStatusToEntity(s pb_common.ReturnRequestStatusEnum) (entity.ReturnRequestStatus, bool) {
+	for k, v := range returnStatusToPb {
+		if v == s {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// ConvertPbReturnResolutionToEntity maps a resolution; ok=false for UNKNOWN.
+func ConvertPbReturnResolutionToEntity(r pb_common.ReturnResolutionEnum) (entity.ReturnResolution, bool) {
+	for k, v := range returnResolutionToPb {
+		if v == r {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// ConvertEntityReturnRequestToPb is the customer-facing projection of a return.
+func ConvertEntityReturnRequestToPb(rr *entity.ReturnRequestFull) *pb_common.ReturnRequest {
+	items := make([]*pb_common.ReturnRequestItem, 0, len(rr.Items))
+	for _, it := range rr.Items {
+		items = append(items, &pb_common.ReturnRequestItem{
+			OrderItemId:        int32(it.OrderItemId),
+			Quantity:           int32(it.Quantity),
+			VariantSkuSnapshot: it.VariantSKU,
+			ExchangeVariantSku: it.ExchangeVariantSKU.String,
+		})
+	}
+	return &pb_common.ReturnRequest{
+		Uuid:         rr.UUID,
+		RmaNumber:    rr.RMANumber,
+		OrderUuid:    rr.OrderUUID,
+		Status:       returnStatusToPb[rr.Status],
+		Resolution:   returnResolutionToPb[rr.Resolution],
+		Reason:       returnReasonToPb[rr.ReasonCode],
+		CustomerNote: rr.CustomerNote.String,
+		Items:        items,
+		CreatedAt:    timestamppb.New(rr.CreatedAt),
+		UpdatedAt:    timestamppb.New(rr.UpdatedAt),
+	}
+}
+
+// ConvertEntityReturnRequestsToPb converts an order's returns for the storefront.
+func ConvertEntityReturnRequestsToPb(rrs []entity.ReturnRequestFull) []*pb_common.ReturnRequest {
+	out := make([]*pb_common.ReturnRequest, 0, len(rrs))
+	for i := range rrs {
+		out = append(out, ConvertEntityReturnRequestToPb(&rrs[i]))
+	}
+	return out
+}
+
+// ConvertEntityReturnRequestFullToAdminPb is the admin queue projection of a return.
+func ConvertEntityReturnRequestFullToAdminPb(rr *entity.ReturnRequestFull) *pb_admin.ReturnRequestFull {
+	return &pb_admin.ReturnRequestFull{
+		Id:             int32(rr.Id),
+		ReturnRequest:  ConvertEntityReturnRequestToPb(rr),
+		BuyerEmail:     rr.BuyerEmail,
+		AdminNote:      rr.AdminNote.String,
+		Disposition:    rr.Disposition,
+		StockAllocated: rr.StockAllocated,
+		DecidedBy:      rr.DecidedBy.String,
+		ApprovedAt:     nullTimeToPb(rr.ApprovedAt),
+		ReceivedAt:     nullTimeToPb(rr.ReceivedAt),
+		ClosedAt:       nullTimeToPb(rr.ClosedAt),
+	}
+}
@@ -0,0 +1,146 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/ksef"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxSalesInvoiceReason mirrors sales_invoice.reason VARCHAR(256) (0337).
+const maxSalesInvoiceReason = 256
+
+var salesInvoiceKindPbToEntity = map[pb_admin.SalesInvoiceKind]entity.SalesInvoiceKind{
+	pb_admin.SalesInvoiceKind_SALES_INVOICE_KIND_INVOICE:    entity.SalesInvoiceKindInvoice,
+	pb_admin.SalesInvoiceKind_SALES_INVOICE_KIND_CORRECTION: entity.SalesInvoiceKindCorrection,
+}
+
+var salesInvoiceKindEntityToPb = map[entity.SalesInvoiceKind]pb_admin.SalesInvoiceKind{
+	entity.SalesInvoiceKindInvoice:    pb_admin.SalesInvoiceKind_SALES_INVOICE_KIND_INVOICE,
+	entity.SalesInvoiceKindCorrection: pb_admin.SalesInvoiceKind_SALES_INVOICE_KIND_CORRECTION,
+}
+
+// KsefSchemaFromPb maps the wire schema; UNKNOWN is the current FA(3).
+func KsefSchemaFromPb(s pb_admin.KsefSchema) ksef.Schema {
+	if s == pb_admin.KsefSchema_KSEF_SCHEMA_FA2 {
+		return ksef.SchemaFA2
+	}
+	return ksef.SchemaFA3
+}
+
+// ParseSalesInvoiceIssueDate parses an optional YYYY-MM-DD issue date; empty is today's date
+// (UTC), and a date in the future is refused — an invoice is not issued ahead of time.
+func ParseSalesInvoiceIssueDate(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if strings.TrimSpace(s) == "" {
+		return today, nil
+	}
+	t, err := parseAcctDate(s, "issue_date")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.After(today) {
+		return time.Time{}, fmt.Errorf("issue_date must not be in the future")
+	}
+	return t, nil
+}
+
+// ConvertPbCorrectionReason validates a correction reason; empty is "Zwrot" (a return), the
+// reason a refund-driven correction carries.
+func ConvertPbCorrectionReason(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "Zwrot", nil
+	}
+	if len([]rune(s)) > maxSalesInvoiceReason {
+		return "", fmt.Errorf("reason is longer than %d characters", maxSalesInvoiceReason)
+	}
+	return s, nil
+}
+
+// ConvertPbSalesInvoiceFilter converts ListSalesInvoices' filter.
+func ConvertPbSalesInvoiceFilter(req *pb_admin.ListSalesInvoicesRequest) (entity.SalesInvoiceFilter, error) {
+	f := entity.SalesInvoiceFilter{
+		OrderUUID: strings.TrimSpace(req.GetOrderUuid()),
+		Kind:      salesInvoiceKindPbToEntity[req.GetKind()],
+	}
+	if p := strings.TrimSpace(req.GetPeriod()); p != "" {
+		if _, err := time.Parse("2006-01", p); err != nil {
+			return f, fmt.Errorf("invalid period %q: want YYYY-MM", p)
+		}
+		f.Period = p
+	}
+	return f, nil
+}
+
+// ConvertSalesInvoiceToPb converts an invoice header; lines are added by ConvertSalesInvoiceFullToPb.
+func ConvertSalesInvoiceToPb(inv entity.SalesInvoice) *pb_admin.SalesInvoice {
+	pb := &pb_admin.SalesInvoice{
+		Id:             int32(inv.Id),
+		Number:         inv.Number,
+		Kind:           salesInvoiceKindEntityToPb[inv.Kind],
+		OrderUuid:      inv.OrderUUID,
+		CorrectsId:     nullInt32ToPb(inv.CorrectsId),
+		CorrectsNumber: inv.CorrectsNumber.String,
+		IssueDate:      inv.IssueDate.Format(acctDateLayout),
+		SaleDate:       inv.SaleDate.Format(acctDateLayout),
+		Currency:       inv.Currency,
+		PlnRate:        pbDecimalFromNull(inv.PlnRate),
+		VatRegime:      string(inv.VatRegime),
+		VatRatePct:     pbDecimalFromDecimal(inv.VatRatePct),
+		BuyerName:      inv.BuyerName,
+		BuyerVatId:     inv.BuyerVatId.String,
+		BuyerAddress:   inv.BuyerAddress,
+		BuyerCountry:   inv.BuyerCountry,
+		Net:            pbDecimalFromDecimal(inv.Net),
+		Vat:            pbDecimalFromDecimal(inv.Vat),
+		Gross:          pbDecimalFromDecimal(inv.Gross),
+		Reason:         inv.Reason.String,
+		KsefReference:  inv.KsefReference.String,
+		CreatedBy:      inv.CreatedBy,
+		CreatedAt:      timestamppb.New(inv.CreatedAt),
+	}
+	if inv.KsefSubmittedAt.Valid {
+		pb.KsefSubmittedAt = timestamppb.New(inv.KsefSubmittedAt.Time)
+	}
+	return pb
+}
+
+// ConvertSalesInvoiceFullToPb converts an invoice with its lines.
+func ConvertSalesInvoiceFullToPb(full *entity.SalesInvoiceFull) *pb_admin.SalesInvoice {
+	pb := ConvertSalesInvoiceToPb(full.SalesInvoice)
+	pb.Lines = make([]*pb_admin.SalesInvoiceLine, 0, len(full.Lines))
+	for _, l := range full.Lines {
+		pb.Lines = append(pb.Lines, &pb_admin.SalesInvoiceLine{
+			Position:    int32(l.Position),
+			Kind:        string(l.Kind),
+			OrderItemId: nullInt32ToPb(l.OrderItemId),
+			Name:        l.Name,
+			Quantity:    pbDecimalFromDecimal(l.Quantity),
+			UnitGross:   pbDecimalFromDecimal(l.UnitGross),
+			Net:         pbDecimalFromDecimal(l.Net),
+			Vat:         pbDecimalFromDecimal(l.Vat),
+			Gross:       pbDecimalFromDecimal(l.Gross),
+		})
+	}
+	return pb
+}
+
+// ConvertSalesInvoiceListToPb converts a page of invoice headers.
+func ConvertSalesInvoiceListToPb(list []entity.SalesInvoice) []*pb_admin.SalesInvoice {
+	out := make([]*pb_admin.SalesInvoice, 0, len(list))
+	for _, inv := range list {
+		out = append(out, ConvertSalesInvoiceToPb(inv))
+	}
+	return out
+}
+
+// SalesInvoiceFilename is the download name of an invoice's KSeF XML: its number with the slashes
+// replaced, e.g. FV_2026_07_00012.xml.
+func SalesInvoiceFilename(number string) string {
+	return strings.ReplaceAll(number, "/", "_") + ".xml"
+}
@@ -0,0 +1,70 @@
+package dto
+
+import (
+	"sort"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_frontend "github.com/jekabolt/grbpwr-manager/proto/gen/frontend"
+)
+
+// Storefront search dto conversions. Facet values are returned in the form the storefront sends back
+// as filter conditions: gender and season as their enum names, categories and sizes as ids.
+
+// SearchFacetsToPb converts search facet counts to protobuf.
+func SearchFacetsToPb(f entity.SearchFacets) *pb_frontend.SearchFacets {
+	values := func(in []entity.SearchFacet, name func(string) (string, bool)) []*pb_frontend.SearchFacetValue {
+		out := make([]*pb_frontend.SearchFacetValue, 0, len(in))
+		for _, v := range in {
+			value := v.Value
+			if name != nil {
+				n, ok := name(v.Value)
+				if !ok {
+					continue
+				}
+				value = n
+			}
+			out = append(out, &pb_frontend.SearchFacetValue{Value: value, Count: int32(v.Count)})
+		}
+		return out
+	}
+	gender := func(v string) (string, bool) {
+		g, ok := genderEntityPbMap[entity.GenderEnum(v)]
+		return g.String(), ok
+	}
+	season := func(v string) (string, bool) {
+		s, ok := seasonEntityPbMap[entity.SeasonEnum(v)]
+		return s.String(), ok
+	}
+	return &pb_frontend.SearchFacets{
+		Gender:      values(f.Gender, gender),
+		TopCategory: values(f.TopCategory, nil),
+		Collection:  values(f.Collection, nil),
+		Color:       values(f.Color, nil),
+		Season:      values(f.Season, season),
+		Size:        values(f.Size, nil),
+		OnSale:      int32(f.OnSale),
+	}
+}
+
+// SearchCorrectionsToPb converts the words a search read as misspellings, ordered by word.
+func SearchCorrectionsToPb(corrections map[string]string) []*pb_frontend.SearchCorrection {
+	out := make([]*pb_frontend.SearchCorrection, 0, len(corrections))
+	for w, c := range corrections {
+		out = append(out, &pb_frontend.SearchCorrection{Word: w, ReadAs: c})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Word < out[j].Word })
+	return out
+}
+
+// SearchSuggestionsToPb converts autocomplete suggestions; products are identified by base SKU.
+func SearchSuggestionsToPb(ss []entity.SearchSuggestion) []*pb_frontend.SearchSuggestion {
+	out := make([]*pb_frontend.SearchSuggestion, 0, len(ss))
+	for _, s := range ss {
+		out = append(out, &pb_frontend.SearchSuggestion{
+			Kind:    string(s.Kind),
+			Text:    s.Text,
+			BaseSku: s.SKU,
+		})
+	}
+	return out
+}
@@ -0,0 +1,228 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/specpdf"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+)
+
+// ReleaseSpecSheet — проекция снапшота релиза под PDF-спецификацию для фабрики (internal/specpdf).
+//
+// Поля Sheet собираются ПОИМЁННО из снапшота, и денежных среди них нет: ни unit_price/currency строк
+// BOM, ни costing, ни цен колорвеев. Снапшот их несёт, Sheet — нет, и сюда они не копируются ни в
+// каком виде (тот же принцип, что у CutSpecCardFromReleaseSnapshot: что не скопировано, того нельзя
+// напечатать).
+//
+// Табель мер и байты эскизов снапшот не несёт — их добирает internal/specsheet: для этого здесь
+// остаются размерный ряд релиза (порядок колонок табеля) и адреса картинок эскизов.
+type ReleaseSpecSheet struct {
+	Sheet specpdf.Sheet
+	// SizeIds — размерный ряд релиза по порядку; Sheet.Sizes — их имена.
+	SizeIds []int
+	// SketchURLs — адрес картинки каждого технического эскиза (media id → url сжатого варианта, а
+	// если его нет — полного).
+	SketchURLs map[int]string
+	// Unit — единица табеля мер карточки («mm»/«cm»).
+	Unit string
+}
+
+// ReleaseSpecSheetFromSnapshot собирает спецификацию из снапшота. nil — снапшот пуст.
+func ReleaseSpecSheetFromSnapshot(snap *pb_common.TechCard) *ReleaseSpecSheet {
+	if snap == nil || snap.GetTechCard() == nil {
+		return nil
+	}
+	ins := snap.GetTechCard()
+	out := &ReleaseSpecSheet{
+		Sheet: specpdf.Sheet{
+			StyleNumber: ins.GetStyleNumber(),
+			Name:        ins.GetName(),
+			Brand:       ins.GetBrand(),
+			Collection:  ins.GetCollection(),
+			Fit:         snap.GetFit(),
+			Composition: snap.GetComposition(),
+			Care:        snap.GetCareInstructions(),
+			Concept:     ins.GetConcept(),
+			Notes:       ins.GetNotes(),
+		},
+		SketchURLs: map[int]string{},
+		Unit:       string(techCardUnitPbToEntity[ins.GetMeasurementUnit()]),
+	}
+	if out.Unit == "" {
+		// UNKNOWN пишется как MM (см. TechCardMeasurementUnit), и читается так же.
+		out.Unit = "mm"
+	}
+	for _, id := range ins.GetSizeIds() {
+		out.SizeIds = append(out.SizeIds, int(id))
+		out.Sheet.Sizes = append(out.Sheet.Sizes, specSheetSizeName(int(id)))
+	}
+
+	for _, m := range snap.GetResolvedTechnicalMedia() {
+		mf := m.GetMedia()
+		if mf == nil || mf.GetId() <= 0 {
+			continue
+		}
+		id := int(mf.GetId())
+		url := mf.GetMedia().GetCompressed().GetMediaUrl()
+		if url == "" {
+			url = mf.GetMedia().GetFullSize().GetMediaUrl()
+		}
+		out.SketchURLs[id] = url
+		out.Sheet.Sketches = append(out.Sheet.Sketches, specpdf.Sketch{MediaId: id, Caption: m.GetCaption()})
+	}
+	for _, c := range ins.GetCallouts() {
+		x, xErr := nullDecimalFromPb(c.GetPosX())
+		y, yErr := nullDecimalFromPb(c.GetPosY())
+		part := c.GetPart()
+		if part == "" {
+			part = strings.Join(c.GetParts(), ", ")
+		}
+		out.Sheet.Callouts = append(out.Sheet.Callouts, specpdf.Callout{
+			Number:      int(c.GetNumber()),
+			MediaId:     int(c.GetMediaId()),
+			HasPos:      xErr == nil && yErr == nil && x.Valid && y.Valid,
+			X:           x.Decimal.InexactFloat64(),
+			Y:           y.Decimal.InexactFloat64(),
+			Part:        part,
+			Description: c.GetDescription(),
+			Dimensions:  c.GetDimensions(),
+		})
+	}
+
+	for _, b := range ins.GetBomItems() {
+		out.Sheet.Bom = append(out.Sheet.Bom, specpdf.BomLine{
+			Section:     string(techCardBomSectionPbToEntity[b.GetSection()]),
+			Name:        b.GetName(),
+			Supplier:    b.GetSupplier(),
+			SupplierRef: b.GetSupplierRef(),
+			Color:       b.GetColor(),
+			Composition: b.GetComposition(),
+			Spec:        b.GetSpec(),
+			Unit:        b.GetUnit(),
+			Comment:     b.GetComment(),
+		})
+	}
+
+	pieceNames := make(map[string]string, len(ins.GetPieces()))
+	for _, p := range ins.GetPieces() {
+		pieceNames[p.GetLineKey()] = p.GetName()
+	}
+	for i, o := range ins.GetOperations() {
+		no := int(o.GetOperationNumber())
+		if no <= 0 {
+			no = (i + 1) * 10
+		}
+		pieces := make([]string, 0, len(o.GetPieceLineKeys()))
+		for _, k := range o.GetPieceLineKeys() {
+			if n := pieceNames[k]; n != "" {
+				pieces = append(pieces, n)
+			} else {
+				pieces = append(pieces, k)
+			}
+		}
+		out.Sheet.Operations = append(out.Sheet.Operations, specpdf.Operation{
+			Number: no,
+			Type:   specSheetOperationType(o),
+			Zone:   string(techCardGarmentZonePbToEntity[o.GetZone()]),
+			Pieces: strings.Join(pieces, ", "),
+			Smv:    specSheetDecimal(o.GetSmv()),
+			Detail: specSheetOperationDetail(o),
+			Note:   o.GetNote(),
+		})
+	}
+
+	for _, lb := range ins.GetLabels() {
+		out.Sheet.Labels = append(out.Sheet.Labels, specpdf.Label{
+			Type:       string(techCardLabelTypePbToEntity[lb.GetLabelType()]),
+			Content:    lb.GetContent(),
+			Placement:  lb.GetPlacement(),
+			Attachment: lb.GetAttachment(),
+			Size:       lb.GetSize(),
+			Note:       lb.GetNote(),
+		})
+	}
+	if pk := ins.GetPackaging(); pk != nil {
+		add := func(label, value string) {
+			if strings.TrimSpace(value) != "" {
+				out.Sheet.Packaging = append(out.Sheet.Packaging, specpdf.Field{Label: label, Value: value})
+			}
+		}
+		add("Folding", pk.GetFoldingMethod())
+		add("Polybag", pk.GetPolybag())
+		add("Bag sticker", pk.GetBagSticker())
+		add("Inserts", pk.GetInserts())
+		if n := pk.GetUnitsPerBox(); n > 0 {
+			add("Units per box", fmt.Sprint(n))
+		}
+		add("Box marking", pk.GetBoxMarking())
+		add("Box dimensions", pk.GetBoxDimensions())
+		if g := pk.GetWeightNetGrams(); g > 0 {
+			add("Net weight", fmt.Sprintf("%d g", g))
+		}
+		if g := pk.GetWeightGrossGrams(); g > 0 {
+			add("Gross weight", fmt.Sprintf("%d g", g))
+		}
+		add("Notes", pk.GetNotes())
+	}
+	return out
+}
+
+func specSheetSizeName(id int) string {
+	if s, ok := cache.GetSizeById(id); ok && s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// specSheetOperationType — глагол шага. Легаси-значения (снапшот до разделения «что/на чём» держит
+// их навсегда) печатаются своим старым словом: переписывать замороженный релиз при печати нельзя.
+func specSheetOperationType(o *pb_common.TechCardOperation) string {
+	if tok, ok := operationTypePbToToken[o.GetOperationType()]; ok {
+		if mt, ok := machineTypePbToToken[o.GetMachineType()]; ok && mt != "" && mt != "unknown" {
+			return tok + " · " + mt
+		}
+		return tok
+	}
+	if legacy, ok := legacyOperationTypePbToEntity[o.GetOperationType()]; ok {
+		return string(legacy)
+	}
+	return ""
+}
+
+// specSheetOperationDetail — отклонения шага от стандарта карточки, только заданные.
+func specSheetOperationDetail(o *pb_common.TechCardOperation) string {
+	var parts []string
+	if v := specSheetDecimal(o.GetStitchesPerCm()); v != "" {
+		parts = append(parts, v+" st/cm")
+	}
+	if tok, ok := seamClassPbToToken[o.GetSeamClass()]; ok && tok != "" && tok != "unknown" {
+		parts = append(parts, "seam "+tok)
+	}
+	if o.SeamAllowanceMm != nil {
+		parts = append(parts, "SA "+specSheetDecimal(o.GetSeamAllowanceMm())+" mm")
+	}
+	if n := o.GetThreadCount(); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d threads", n))
+	}
+	if n := o.GetNeedleSizeNm(); n > 0 {
+		parts = append(parts, fmt.Sprintf("needle Nm %d", n))
+	}
+	if t := o.GetPressTemperatureC(); t > 0 {
+		parts = append(parts, fmt.Sprintf("%d °C", t))
+	}
+	if s := o.GetPressDwellSec(); s > 0 {
+		parts = append(parts, fmt.Sprintf("%d s", s))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func specSheetDecimal(d *pb_decimal.Decimal) string {
+	v, err := nullDecimalFromPb(d)
+	if err != nil || !v.Valid {
+		return ""
+	}
+	return v.Decimal.String()
+}
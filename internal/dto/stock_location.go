@@ -0,0 +1,176 @@
+package dto
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Stock location dto conversions (0345). Quantities travel as decimals: transfer lines carry both
+// garments and metres of fabric, DECIMAL(12,3).
+
+// ConvertPbStockLocationInsertToEntity maps a location payload; the store normalises and validates.
+func ConvertPbStockLocationInsertToEntity(p *pb_admin.StockLocationInsert, username string) (entity.StockLocationInsert, error) {
+	if p == nil {
+		return entity.StockLocationInsert{}, fmt.Errorf("location is required")
+	}
+	return entity.StockLocationInsert{
+		Code:      p.Code,
+		Name:      p.Name,
+		Kind:      entity.StockLocationKind(p.Kind),
+		Address:   sql.NullString{String: p.Address, Valid: strings.TrimSpace(p.Address) != ""},
+		Note:      sql.NullString{String: p.Note, Valid: strings.TrimSpace(p.Note) != ""},
+		CreatedBy: username,
+	}, nil
+}
+
+// StockLocationToPb converts a location to protobuf.
+func StockLocationToPb(l entity.StockLocation) *pb_admin.StockLocation {
+	return &pb_admin.StockLocation{
+		Id:        int32(l.Id),
+		Code:      l.Code,
+		Name:      l.Name,
+		Kind:      string(l.Kind),
+		IsDefault: l.IsDefault,
+		Address:   l.Address.String,
+		Note:      l.Note.String,
+		Archived:  l.ArchivedAt.Valid,
+		CreatedBy: l.CreatedBy,
+		CreatedAt: timestamppb.New(l.CreatedAt),
+		UpdatedAt: timestamppb.New(l.UpdatedAt),
+	}
+}
+
+// StockLocationListToPb converts a location list to protobuf.
+func StockLocationListToPb(list []entity.StockLocation) []*pb_admin.StockLocation {
+	out := make([]*pb_admin.StockLocation, 0, len(list))
+	for _, l := range list {
+		out = append(out, StockLocationToPb(l))
+	}
+	return out
+}
+
+// LocationStockToPb converts a location's contents to the ListLocationStock response.
+func LocationStockToPb(ls *entity.LocationStock) *pb_admin.ListLocationStockResponse {
+	out := &pb_admin.ListLocationStockResponse{
+		Location:  StockLocationToPb(ls.Location),
+		Variants:  make([]*pb_admin.LocationVariantStock, 0, len(ls.Variants)),
+		Materials: make([]*pb_admin.LocationMaterialStock, 0, len(ls.Materials)),
+	}
+	for _, v := range ls.Variants {
+		out.Variants = append(out.Variants, &pb_admin.LocationVariantStock{
+			VariantId: int32(v.ProductSizeId),
+			ProductId: int32(v.ProductId),
+			SizeId:    int32(v.SizeId),
+			Grade:     v.Grade,
+			Sku:       v.SKU,
+			SizeName:  v.SizeName,
+			Quantity:  int32(v.Quantity),
+			Total:     int32(v.Total),
+		})
+	}
+	for _, m := range ls.Materials {
+		pm := &pb_admin.LocationMaterialStock{
+			MaterialId:   int32(m.MaterialId),
+			MaterialName: m.MaterialName,
+			Unit:         m.Unit.String,
+			Quantity:     pbDecimalFromDecimal(m.Quantity),
+			Total:        pbDecimalFromDecimal(m.Total),
+			Lots:         make([]*pb_admin.LocationLotStock, 0, len(m.Lots)),
+		}
+		for _, l := range m.Lots {
+			pm.Lots = append(pm.Lots, &pb_admin.LocationLotStock{
+				LotId:    int32(l.LotId),
+				LotCode:  l.LotCode,
+				Quantity: pbDecimalFromDecimal(l.Quantity),
+			})
+		}
+		out.Materials = append(out.Materials, pm)
+	}
+	return out
+}
+
+// ConvertPbCreateStockTransferToEntity maps a new transfer. Quantities must parse and fit
+// DECIMAL(12,3); the rest is checked by entity.ValidateStockTransferInsert.
+func ConvertPbCreateStockTransferToEntity(req *pb_admin.CreateStockTransferRequest, username string) (entity.StockTransferInsert, error) {
+	ins := entity.StockTransferInsert{
+		FromLocationId: int(req.FromLocationId),
+		ToLocationId:   int(req.ToLocationId),
+		Note:           sql.NullString{String: req.Note, Valid: strings.TrimSpace(req.Note) != ""},
+		Lines:          make([]entity.StockTransferLineInsert, 0, len(req.Lines)),
+		CreatedBy:      username,
+	}
+	for i, l := range req.Lines {
+		if l == nil {
+			return entity.StockTransferInsert{}, fmt.Errorf("lines[%d] is required", i)
+		}
+		q, err := requiredDecimalFromPb(l.Quantity, fmt.Sprintf("lines[%d].quantity", i), 3, 1_000_000_000)
+		if err != nil {
+			return entity.StockTransferInsert{}, err
+		}
+		ins.Lines = append(ins.Lines, entity.StockTransferLineInsert{
+			ProductSizeId: int(l.VariantId),
+			MaterialId:    int(l.MaterialId),
+			LotId:         int(l.LotId),
+			Quantity:      q,
+		})
+	}
+	return ins, nil
+}
+
+// StockTransferToPb converts a transfer header to protobuf.
+func StockTransferToPb(t entity.StockTransfer) *pb_admin.StockTransfer {
+	return &pb_admin.StockTransfer{
+		Id:             int32(t.Id),
+		Number:         entity.StockTransferNumber(t.Id),
+		FromLocationId: int32(t.FromLocationId),
+		FromLocation:   t.FromLocation,
+		ToLocationId:   int32(t.ToLocationId),
+		ToLocation:     t.ToLocation,
+		Status:         string(t.Status),
+		Note:           t.Note.String,
+		CreatedBy:      t.CreatedBy,
+		DispatchedBy:   t.DispatchedBy.String,
+		DispatchedAt:   pbTimestampFromNullTime(t.DispatchedAt),
+		ReceivedBy:     t.ReceivedBy.String,
+		ReceivedAt:     pbTimestampFromNullTime(t.ReceivedAt),
+		CancelledBy:    t.CancelledBy.String,
+		CancelledAt:    pbTimestampFromNullTime(t.CancelledAt),
+		CreatedAt:      timestamppb.New(t.CreatedAt),
+		UpdatedAt:      timestamppb.New(t.UpdatedAt),
+	}
+}
+
+// StockTransferFullToPb converts a transfer with its lines to protobuf.
+func StockTransferFullToPb(t *entity.StockTransferFull) *pb_admin.StockTransfer {
+	out := StockTransferToPb(t.StockTransfer)
+	out.Lines = make([]*pb_admin.StockTransferLine, 0, len(t.Lines))
+	for _, l := range t.Lines {
+		out.Lines = append(out.Lines, &pb_admin.StockTransferLine{
+			Id:           int32(l.Id),
+			VariantId:    l.ProductSizeId.Int32,
+			MaterialId:   l.MaterialId.Int32,
+			LotId:        l.LotId.Int32,
+			Quantity:     pbDecimalFromDecimal(l.Quantity),
+			Sku:          l.SKU,
+			SizeName:     l.SizeName,
+			MaterialName: l.MaterialName,
+			Unit:         l.Unit.String,
+			LotCode:      l.LotCode,
+		})
+	}
+	return out
+}
+
+// StockTransferListToPb converts transfer headers to protobuf.
+func StockTransferListToPb(list []entity.StockTransfer) []*pb_admin.StockTransfer {
+	out := make([]*pb_admin.StockTransfer, 0, len(list))
+	for _, t := range list {
+		out = append(out, StockTransferToPb(t))
+	}
+	return out
+}
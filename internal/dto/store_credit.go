@@ -0,0 +1,102 @@
+package dto
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	pb_decimal "google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	storeCreditKindToPb = map[entity.StoreCreditWalletKind]pb_common.StoreCreditWalletKindEnum{
+		entity.StoreCreditWalletGiftCard: pb_common.StoreCreditWalletKindEnum_STORE_CREDIT_WALLET_KIND_ENUM_GIFT_CARD,
+		entity.StoreCreditWalletAccount:  pb_common.StoreCreditWalletKindEnum_STORE_CREDIT_WALLET_KIND_ENUM_ACCOUNT,
+	}
+	storeCreditStatusToPb = map[entity.StoreCreditWalletStatus]pb_common.StoreCreditWalletStatusEnum{
+		entity.StoreCreditWalletActive:   pb_common.StoreCreditWalletStatusEnum_STORE_CREDIT_WALLET_STATUS_ENUM_ACTIVE,
+		entity.StoreCreditWalletDisabled: pb_common.StoreCreditWalletStatusEnum_STORE_CREDIT_WALLET_STATUS_ENUM_DISABLED,
+	}
+	storeCreditEntryToPb = map[entity.StoreCreditEntryType]pb_common.StoreCreditEntryTypeEnum{
+		entity.StoreCreditEntryIssue:        pb_common.StoreCreditEntryTypeEnum_STORE_CREDIT_ENTRY_TYPE_ENUM_ISSUE,
+		entity.StoreCreditEntryRedeem:       pb_common.StoreCreditEntryTypeEnum_STORE_CREDIT_ENTRY_TYPE_ENUM_REDEEM,
+		entity.StoreCreditEntryRelease:      pb_common.StoreCreditEntryTypeEnum_STORE_CREDIT_ENTRY_TYPE_ENUM_RELEASE,
+		entity.StoreCreditEntryRefundCredit: pb_common.StoreCreditEntryTypeEnum_STORE_CREDIT_ENTRY_TYPE_ENUM_REFUND_CREDIT,
+		entity.StoreCreditEntryAdjustment:   pb_common.StoreCreditEntryTypeEnum_STORE_CREDIT_ENTRY_TYPE_ENUM_ADJUSTMENT,
+	}
+)
+
+// ConvertPbStoreCreditKindToEntity maps a kind filter; ok=false for UNKNOWN.
+func ConvertPbStoreCreditKindToEntity(k pb_common.StoreCreditWalletKindEnum) (entity.StoreCreditWalletKind, bool) {
+	for e, v := range storeCreditKindToPb {
+		if v == k {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// ConvertPbStoreCreditStatusToEntity maps a wallet status; ok=false for UNKNOWN.
+func ConvertPbStoreCreditStatusToEntity(s pb_common.StoreCreditWalletStatusEnum) (entity.StoreCreditWalletStatus, bool) {
+	for e, v := range storeCreditStatusToPb {
+		if v == s {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// ConvertEntityStoreCreditBalanceToPb is the customer-facing projection of a wallet.
+func ConvertEntityStoreCreditBalanceToPb(w *entity.StoreCreditWallet) *pb_common.StoreCreditBalance {
+	return &pb_common.StoreCreditBalance{
+		Kind:          storeCreditKindToPb[w.Kind],
+		Code:          w.Code.String,
+		Currency:      w.Currency,
+		Balance:       &pb_decimal.Decimal{Value: RoundForCurrency(w.Balance, w.Currency).String()},
+		InitialAmount: &pb_decimal.Decimal{Value: RoundForCurrency(w.InitialAmount, w.Currency).String()},
+		Status:        storeCreditStatusToPb[w.Status],
+	}
+}
+
+// ConvertEntityStoreCreditBalancesToPb converts a list of wallets for the storefront.
+func ConvertEntityStoreCreditBalancesToPb(ws []entity.StoreCreditWallet) []*pb_common.StoreCreditBalance {
+	out := make([]*pb_common.StoreCreditBalance, 0, len(ws))
+	for i := range ws {
+		out = append(out, ConvertEntityStoreCreditBalanceToPb(&ws[i]))
+	}
+	return out
+}
+
+// ConvertEntityStoreCreditWalletToAdminPb is the admin list row of a wallet.
+func ConvertEntityStoreCreditWalletToAdminPb(w *entity.StoreCreditWallet) *pb_admin.StoreCreditWallet {
+	return &pb_admin.StoreCreditWallet{
+		Id:              int32(w.Id),
+		Balance:         ConvertEntityStoreCreditBalanceToPb(w),
+		AccountEmail:    w.AccountEmail.String,
+		PurchaserEmail:  w.PurchaserEmail.String,
+		SourceOrderUuid: w.SourceOrderUUID.String,
+		CreatedAt:       timestamppb.New(w.CreatedAt),
+		UpdatedAt:       timestamppb.New(w.UpdatedAt),
+	}
+}
+
+// ConvertEntityStoreCreditWalletFullToAdminPb adds the wallet's ledger.
+func ConvertEntityStoreCreditWalletFullToAdminPb(w *entity.StoreCreditWalletFull) *pb_admin.StoreCreditWalletFull {
+	ledger := make([]*pb_admin.StoreCreditLedgerEntry, 0, len(w.Ledger))
+	for _, l := range w.Ledger {
+		ledger = append(ledger, &pb_admin.StoreCreditLedgerEntry{
+			Id:           int32(l.Id),
+			EntryType:    storeCreditEntryToPb[l.EntryType],
+			Amount:       &pb_decimal.Decimal{Value: l.Amount.StringFixed(2)},
+			BalanceAfter: &pb_decimal.Decimal{Value: l.BalanceAfter.StringFixed(2)},
+			OrderUuid:    l.OrderUUID.String,
+			Note:         l.Note.String,
+			CreatedBy:    l.CreatedBy,
+			CreatedAt:    timestamppb.New(l.CreatedAt),
+		})
+	}
+	return &pb_admin.StoreCreditWalletFull{
+		Wallet: ConvertEntityStoreCreditWalletToAdminPb(&w.StoreCreditWallet),
+		Ledger: ledger,
+	}
+}
@@ -0,0 +1,253 @@
+package dto
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dxfpattern"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/nesting"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
+)
+
+// РАСКЛАДКА СЕРВЕРОМ. internal/nesting knows contours and a strip; this file dresses its result as
+// the раскладка the rest of the system already reads — a schema-4 layout blob, the row figures, and
+// an insert for SaveTechCardMarker — so a nested marker is stored and read by exactly the paths a
+// browser-made one is, and nothing downstream has to know who laid it.
+
+// nestedCoordPlaces is the precision contour points and placements are written with: a hundredth of
+// a millimetre, far below the raster the engine placed on, and a third of the blob's digits.
+const nestedCoordPlaces = 3
+
+// NestedPieceSource is where one nested piece came from — the provenance the layout blob records.
+type NestedPieceSource struct {
+	Name         string // the card's piece name, else the block's
+	Filename     string // the pattern sheet it was read from
+	BlockName    string
+	PieceLineKey string
+}
+
+// NestedMarker is a nest result as a раскладка.
+type NestedMarker struct {
+	Layout        *pb_common.TechCardMarkerLayout
+	UsedLengthCm  decimal.Decimal
+	EfficiencyPct decimal.NullDecimal
+	PlacedCount   int
+	TotalCount    int
+	// Composition is the состав with each size's area per garment, derived from the layout's pieces
+	// by the same function the save path uses — so the numbers shown before saving are the ones the
+	// saved row will carry.
+	Composition []entity.MarkerCompositionEntry
+}
+
+// IsDraft reports a nest that did not place every instance.
+func (n NestedMarker) IsDraft() bool { return n.PlacedCount < n.TotalCount }
+
+// Summary is the nested marker as the summary the norm arithmetic reads.
+func (n NestedMarker) Summary() entity.TechCardMarkerSummary {
+	return entity.TechCardMarkerSummary{
+		UsedLengthCm:  n.UsedLengthCm,
+		EfficiencyPct: n.EfficiencyPct,
+		PlacedCount:   n.PlacedCount,
+		TotalCount:    n.TotalCount,
+		IsDraft:       n.IsDraft(),
+		Composition:   n.Composition,
+	}
+}
+
+// NestedMarkerFromResult builds the layout blob of a nest. pieces and sources are index-aligned with
+// what was passed to nesting.Nest; composition is the ratio it ran on.
+//
+// The length is rounded UP to the column's hundredth — a marker that claims a tenth of a millimetre
+// less cloth than it lays is the one rounding direction that is wrong.
+func NestedMarkerFromResult(r nesting.Result, pieces []nesting.Piece, sources []NestedPieceSource,
+	composition []entity.MarkerCompositionEntry, unit dxfpattern.Unit) NestedMarker {
+	l := &pb_common.TechCardMarkerLayout{
+		SchemaVersion: entity.MarkerLayoutSchemaWithComposition,
+		Params:        &pb_common.TechCardMarkerNestParams{Unit: string(unit), TolCm: dxfpattern.ArcToleranceCm},
+		Warnings:      r.Warnings,
+	}
+	for _, c := range composition {
+		l.Composition = append(l.Composition, &pb_common.TechCardMarkerCompositionEntry{
+			SizeId: int32(c.SizeId), Quantity: int32(c.Quantity)})
+	}
+	for i, pc := range pieces {
+		shape := r.Shapes[i]
+		if len(shape) < 3 {
+			continue
+		}
+		src := sources[i]
+		out := &pb_common.TechCardMarkerPiece{
+			PieceId:      int32(i + 1),
+			Name:         src.Name,
+			Source:       src.Filename,
+			Quantity:     int32(pc.Quantity),
+			AreaCm2:      roundCoord(r.AreasCm2[i]),
+			PieceLineKey: src.PieceLineKey,
+			BlockName:    src.BlockName,
+			SizeId:       int32(pc.SizeId),
+		}
+		for _, q := range shape {
+			out.Poly = append(out.Poly, &pb_common.TechCardMarkerPoint{XCm: roundCoord(q.X), YCm: roundCoord(q.Y)})
+			out.BboxWCm = math.Max(out.BboxWCm, roundCoord(q.X))
+			out.BboxHCm = math.Max(out.BboxHCm, roundCoord(q.Y))
+		}
+		l.Pieces = append(l.Pieces, out)
+	}
+	for _, pl := range r.Placements {
+		l.Placements = append(l.Placements, &pb_common.TechCardMarkerPlacement{
+			PieceId:  int32(pl.Piece + 1),
+			Instance: int32(pl.Instance),
+			RotDeg:   int32(pl.RotDeg),
+			XCm:      roundCoord(pl.XCm),
+			YCm:      roundCoord(pl.YCm),
+			Flipped:  pl.Flipped,
+		})
+	}
+	n := NestedMarker{
+		Layout:      l,
+		PlacedCount: r.PlacedCount,
+		TotalCount:  r.TotalCount,
+		Composition: entity.WithMarkerSizeAreas(composition, markerPieceAreasFromPb(l)),
+	}
+	if r.PlacedCount > 0 {
+		n.UsedLengthCm = decimal.NewFromFloat(r.UsedLengthCm).RoundUp(markerDimMaxFrac)
+		n.EfficiencyPct = decimal.NullDecimal{
+			Decimal: decimal.NewFromFloat(math.Min(r.EfficiencyPct, 100)).Round(markerDimMaxFrac), Valid: true}
+	}
+	return n
+}
+
+func roundCoord(v float64) float64 {
+	p := math.Pow10(nestedCoordPlaces)
+	return math.Round(v*p) / p
+}
+
+// NestedMarkerConditions are the conditions a server nest ran under, as the insert records them.
+type NestedMarkerConditions struct {
+	Name            string
+	BomLineKey      string
+	ColorwayId      int
+	FabricWidthCm   decimal.Decimal // cutting width
+	SelvedgeCm      decimal.Decimal
+	GapCm           decimal.Decimal
+	EdgeMarginCm    decimal.Decimal
+	AllowCrossGrain bool
+	AllowFlip       bool
+}
+
+// NestedMarkerInsertToPb is the SaveTechCardMarker payload of a nested marker. The allowance facts
+// are the ones the server's reading actually knows: the contour is the AAMA cut line, laid as drawn
+// (seam_allowance_mm 0), and how far that line sits outside the sew line was not measured — so
+// contour_allowance_mm stays absent rather than claiming a zero. grain_layer names the layer the
+// grain was read from. is_draft is consent, not a label: the stored flag is derived from the counts.
+func NestedMarkerInsertToPb(n NestedMarker, c NestedMarkerConditions) *pb_common.TechCardMarkerInsert {
+	contourLayer := dxfpattern.ContourLayerCut
+	grainLayer := dxfpattern.LayerGrain
+	allowFlip := c.AllowFlip
+	return &pb_common.TechCardMarkerInsert{
+		Name:            strings.TrimSpace(c.Name),
+		Source:          string(entity.MarkerSourceAuto),
+		BomLineKey:      c.BomLineKey,
+		ColorwayId:      int32(c.ColorwayId),
+		FabricWidthCm:   pbDecimalFromDecimal(c.FabricWidthCm),
+		GapCm:           pbDecimalFromDecimal(c.GapCm),
+		EdgeMarginCm:    pbDecimalFromDecimal(c.EdgeMarginCm),
+		SelvedgeCm:      pbDecimalFromDecimal(c.SelvedgeCm),
+		AllowCrossGrain: c.AllowCrossGrain,
+		UsedLengthCm:    pbDecimalFromDecimal(n.UsedLengthCm),
+		EfficiencyPct:   pbDecimalFromNull(n.EfficiencyPct),
+		PlacedCount:     int32(n.PlacedCount),
+		TotalCount:      int32(n.TotalCount),
+		Layout:          n.Layout,
+		SeamAllowanceMm: pbDecimalFromDecimal(decimal.Zero),
+		ContourLayer:    &contourLayer,
+		GrainLayer:      &grainLayer,
+		AllowFlip:       &allowFlip,
+		IsDraft:         n.IsDraft(),
+	}
+}
+
+// NestedMarkerCompositionToPb emits the nested состав with its per-size расход — withheld on a
+// draft, exactly as a stored draft's summary withholds it.
+func NestedMarkerCompositionToPb(n NestedMarker) []*pb_common.TechCardMarkerCompositionEntry {
+	return markerCompositionToPb(n.Summary().PerSizeConsumption())
+}
+
+// MarkerNestComparisonToPb emits the nested marker against the norm; nil without a norm.
+func MarkerNestComparisonToPb(c *entity.MarkerNormComparison) *pb_admin.TechCardMarkerNestComparison {
+	if c == nil {
+		return nil
+	}
+	out := &pb_admin.TechCardMarkerNestComparison{
+		NormMarkerId:       int32(c.Norm.Id),
+		NormName:           c.Norm.Name,
+		NormUsedLengthCm:   pbDecimalFromDecimal(c.Norm.UsedLengthCm),
+		NormEfficiencyPct:  pbDecimalFromNull(c.Norm.EfficiencyPct),
+		UsedLengthDeltaCm:  pbDecimalFromNull(roundNullDecimal(c.UsedLengthDeltaCm, 2)),
+		EfficiencyDeltaPct: pbDecimalFromNull(roundNullDecimal(c.EfficiencyDeltaPct, 2)),
+	}
+	for _, s := range c.Sizes {
+		out.Sizes = append(out.Sizes, &pb_admin.TechCardMarkerNestSizeDelta{
+			SizeId:   int32(s.SizeId),
+			NestedCm: pbDecimalFromNull(roundNullDecimal(s.NestedCm, 2)),
+			NormCm:   pbDecimalFromNull(roundNullDecimal(s.NormCm, 2)),
+			DeltaCm:  pbDecimalFromNull(roundNullDecimal(s.DeltaCm, 2)),
+		})
+	}
+	return out
+}
+
+// NestRequest is the validated input of a server nest.
+type NestRequest struct {
+	Composition []entity.MarkerCompositionEntry
+	// FabricWidthCm is the cutting width override; INVALID = take it from the article.
+	FabricWidthCm decimal.NullDecimal
+	GapCm         decimal.Decimal
+	EdgeMarginCm  decimal.Decimal
+}
+
+// NestRequestFromPb validates a nest request against the same bounds a saved marker's columns hold,
+// so a result that is shown can also be stored.
+func NestRequestFromPb(req *pb_admin.NestTechCardMarkerRequest) (NestRequest, error) {
+	var out NestRequest
+	composition, err := markerCompositionFromPb(req.GetComposition())
+	if err != nil {
+		return out, err
+	}
+	if len(composition) == 0 {
+		return out, fmt.Errorf("composition is required: which sizes, and how many of each, one lay cuts")
+	}
+	out.Composition = composition
+	width, err := nullDecimalFromPb(req.GetFabricWidthCm())
+	if err != nil {
+		return out, fmt.Errorf("fabric_width_cm: %w", err)
+	}
+	if width.Valid && !width.Decimal.IsPositive() {
+		return out, fmt.Errorf("fabric_width_cm must be positive")
+	}
+	if err := validateDecimalScale(width, "fabric_width_cm", markerDimMaxFrac, markerWidthLimit); err != nil {
+		return out, err
+	}
+	out.FabricWidthCm = width
+	gap, err := nullDecimalFromPb(req.GetGapCm())
+	if err != nil {
+		return out, fmt.Errorf("gap_cm: %w", err)
+	}
+	if err := validateDecimalScale(gap, "gap_cm", markerDimMaxFrac, markerSmallDimLimit); err != nil {
+		return out, err
+	}
+	out.GapCm = gap.Decimal
+	margin, err := nullDecimalFromPb(req.GetEdgeMarginCm())
+	if err != nil {
+		return out, fmt.Errorf("edge_margin_cm: %w", err)
+	}
+	if err := validateDecimalScale(margin, "edge_margin_cm", markerDimMaxFrac, markerSmallDimLimit); err != nil {
+		return out, err
+	}
+	out.EdgeMarginCm = margin.Decimal
+	return out, nil
+}
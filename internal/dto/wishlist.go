@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
+)
+
+// MostWishedStylesToPb converts the most-wished report to proto.
+func MostWishedStylesToPb(styles []entity.MostWishedStyle) []*pb_admin.MostWishedStyle {
+	out := make([]*pb_admin.MostWishedStyle, 0, len(styles))
+	for _, st := range styles {
+		cws := make([]*pb_admin.MostWishedColorway, 0, len(st.Colorways))
+		for _, c := range st.Colorways {
+			cws = append(cws, &pb_admin.MostWishedColorway{
+				ProductId: int32(c.ProductId),
+				Sku:       c.SKU,
+				Color:     c.Color,
+				Wishers:   int32(c.Wishers),
+			})
+		}
+		out = append(out, &pb_admin.MostWishedStyle{
+			StyleId:       int32(st.StyleId),
+			StyleNumber:   st.StyleNumber,
+			Name:          st.Name,
+			Wishers:       int32(st.Wishers),
+			Items:         int32(st.Items),
+			AddedInWindow: int32(st.AddedInWindow),
+			Colorways:     cws,
+		})
+	}
+	return out
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
@@ -43,6 +44,31 @@ func WorkshopSettingsToPb(s *entity.WorkshopSettings) *pb_admin.WorkshopSettings
 	if s.MaxStackHeightCm.Valid {
 		out.MaxStackHeightCm = &pb_decimal.Decimal{Value: s.MaxStackHeightCm.Decimal.String()}
 	}
+	// 0342. Absent when unconfigured, for the max-stack reason: "0 stations" would read as a workshop
+	// that sews nothing, where an absent field makes the schedule withhold its dates and say why.
+	if s.SewingWorkstations.Valid {
+		v := s.SewingWorkstations.Int32
+		out.SewingWorkstations = &v
+	}
+	if s.ShiftMinutes.Valid {
+		v := s.ShiftMinutes.Int32
+		out.ShiftMinutes = &v
+	}
+	if s.ShiftsPerDay.Valid {
+		v := s.ShiftsPerDay.Int32
+		out.ShiftsPerDay = &v
+	}
+	if s.WorkingWeekdays.Valid {
+		v := s.WorkingWeekdays.String
+		out.WorkingWeekdays = &v
+	}
+	if s.PlanningEfficiencyPct.Valid {
+		out.PlanningEfficiencyPct = &pb_decimal.Decimal{Value: s.PlanningEfficiencyPct.Decimal.String()}
+	}
+	if s.QcInspectionRequired.Valid {
+		v := s.QcInspectionRequired.Bool
+		out.QcInspectionRequired = &v
+	}
 	if !s.UpdatedAt.IsZero() {
 		out.UpdatedAt = timestamppb.New(s.UpdatedAt)
 	}
@@ -85,9 +111,44 @@ func WorkshopSettingsPatchFromPb(req *pb_admin.UpdateWorkshopSettingsRequest) (e
 		v := req.GetRunReadinessBlocking()
 		p.RunReadinessBlocking = &v
 	}
+	// 0342 — the counts ride `optional` for the same reason, and a present 0 clears (none of them has
+	// a legal zero). The range itself is the store's to judge, next to the other validators.
+	p.SewingWorkstations = presentNullInt32(req.SewingWorkstations)
+	p.ShiftMinutes = presentNullInt32(req.ShiftMinutes)
+	p.ShiftsPerDay = presentNullInt32(req.ShiftsPerDay)
+	if req.WorkingWeekdays != nil {
+		p.WorkingWeekdays = &sql.NullString{}
+		if req.GetWorkingWeekdays() != "" {
+			days, err := entity.ParseWorkingWeekdays(req.GetWorkingWeekdays())
+			if err != nil {
+				return p, err
+			}
+			p.WorkingWeekdays = &sql.NullString{String: days, Valid: true}
+		}
+	}
+	eff, err := presentNullDecimal(req.PlanningEfficiencyPct, "planning_efficiency_pct")
+	if err != nil {
+		return p, err
+	}
+	p.PlanningEfficiencyPct = eff
+	if req.QcInspectionRequired != nil {
+		v := req.GetQcInspectionRequired()
+		p.QcInspectionRequired = &v
+	}
 	return p, nil
 }
 
+// presentNullInt32 is presentNullDecimal for an `optional int32` whose zero means "clear".
+func presentNullInt32(v *int32) *sql.NullInt32 {
+	if v == nil {
+		return nil
+	}
+	if *v == 0 {
+		return &sql.NullInt32{}
+	}
+	return &sql.NullInt32{Int32: *v, Valid: true}
+}
+
 // presentNullDecimal turns a proto decimal into the tri-state pointer the entity patch expects:
 // nil field -> nil (absent), empty value -> a non-nil invalid NullDecimal (clear), a parsable
 // number -> a non-nil valid one.
@@ -0,0 +1,501 @@
+// Package dxfpattern reads AAMA / ASTM D6673 DXF pattern files — the graded выкройки uploaded
+// through Admin.UploadPattern — into cut pieces: one piece per BLOCK, with its cut-line contour,
+// area and perimeter, grain line, notches and the size it was graded to.
+//
+// THE SERVER USED TO NOT PARSE DXF AT ALL: the browser read the file, and the areas and size tokens
+// it sent were trusted as sent. This package is the server's own reading of the same files. It does
+// not replace the client's measurement on the wire; it lets the server measure a scope by itself
+// and, more importantly, compare what is STORED against what the files actually say (see Measure and
+// Drift).
+//
+// WHAT THE FORMAT GIVES AND WHAT IT DOES NOT. An AAMA file is plain DXF with conventions on top:
+//   - every piece is a BLOCK, inserted once in ENTITIES;
+//   - geometry is split by LAYER NUMBER: 1 cut line (boundary), 4 notches, 7 grain line, 8 internal
+//     lines, 11 internal cutouts, 13 drill holes, 14 sew line;
+//   - attributes are TEXT entities of the form «Key: value» — «Piece Name:», «Size:», «Quantity:»,
+//     and at file level «Units:» and «Sample Size:».
+//
+// Units are declared by $INSUNITS where the exporter wrote it, else by the AAMA «Units:» text, else by
+// $MEASUREMENT. A file that declares none is read as millimetres and SAYS SO in Warnings — a guessed
+// unit is a factor of 25.4 (or 100) on every area, and it must be visible, not silent.
+//
+// Binary DXF is refused with ErrBinaryDXF: no pattern CAD in use exports it, and a half-supported
+// decoder would be a second way for a file to read as «no pieces».
+package dxfpattern
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AAMA layer numbers this package reads. Everything else (internal lines, drill holes, annotation)
+// is skipped — it carries nothing the cut area depends on.
+const (
+	LayerBoundary = "1"
+	LayerNotch    = "4"
+	LayerGrain    = "7"
+	LayerSewLine  = "14"
+)
+
+// maxPieces bounds one file. The largest real graded pack seen is a few hundred blocks; a file past
+// this is either not a pattern or a pathological one, and either way is not worth a memory spike.
+const maxPieces = 5000
+
+var (
+	// ErrBinaryDXF marks a binary-encoded DXF, which is not read.
+	ErrBinaryDXF = errors.New("dxfpattern: binary DXF is not supported — export the pattern as ASCII DXF")
+	// ErrNotDXF marks a payload that does not open like a DXF drawing.
+	ErrNotDXF = errors.New("dxfpattern: payload is not an ASCII DXF drawing")
+)
+
+var binarySentinel = []byte("AutoCAD Binary DXF\r\n\x1a\x00")
+
+// Unit is the length unit the drawing's coordinates are in.
+type Unit string
+
+const (
+	UnitMillimetre Unit = "mm"
+	UnitCentimetre Unit = "cm"
+	UnitMetre      Unit = "m"
+	UnitInch       Unit = "in"
+)
+
+// cmPer is how many centimetres one drawing unit is.
+var cmPer = map[Unit]float64{
+	UnitMillimetre: 0.1,
+	UnitCentimetre: 1,
+	UnitMetre:      100,
+	UnitInch:       2.54,
+}
+
+// Point is a position in centimetres.
+type Point struct {
+	X, Y float64
+}
+
+// Segment is a two-point line in centimetres.
+type Segment struct {
+	From, To Point
+}
+
+// Piece is one block of the file, read as a cut piece. All lengths are centimetres, areas cm².
+type Piece struct {
+	// Block is the block name as written in the file.
+	Block string
+	// Name is the «Piece Name:» text of the block, or the block name when the block has none.
+	Name string
+	// Size is the «Size:» text of the block as written; "" when the block does not say.
+	Size string
+	// Quantity is the «Quantity:» text; 0 when absent (the card's pieces_per_garment rules anyway).
+	Quantity int
+	// Boundary is the chosen cut-line contour (layer 1), closed implicitly. Empty when the block has
+	// no closed contour on layer 1 — then AreaCm2 and PerimeterCm are zero and the piece is NOT
+	// measurable: a sew line alone would need the seam allowance added, which the file does not state.
+	// Arcs (bulged edges) come flattened into chords within ArcToleranceCm, so the outline is a plain
+	// polygon; AreaCm2 and PerimeterCm are still taken along the true arcs.
+	Boundary    []Point
+	AreaCm2     float64
+	PerimeterCm float64
+	// AmbiguousBoundary: layer 1 carried several closed contours of the same (largest) area and the
+	// first was taken — the same state entity.PieceAreaRow.AmbiguousPick records.
+	AmbiguousBoundary bool
+	// SewLineAreaCm2 is the area of the largest closed contour on layer 14, 0 when there is none.
+	SewLineAreaCm2 float64
+	// GrainLine is the first line on layer 7; nil when the block has none.
+	GrainLine *Segment
+	// GrainAngleDeg is the grain line's direction in [0, 180) degrees from the X axis.
+	GrainAngleDeg float64
+	// Notches are the positions of the block's notches (layer 4).
+	Notches []Point
+}
+
+// Measurable reports whether the piece has a cut-line area.
+func (p Piece) Measurable() bool {
+	return len(p.Boundary) >= 3 && p.AreaCm2 > 0
+}
+
+// Pattern is one parsed file.
+type Pattern struct {
+	Unit Unit
+	// UnitSource says where Unit came from: "$INSUNITS", "Units text", "$MEASUREMENT" or "assumed".
+	UnitSource string
+	// SampleSize is the file-level «Sample Size:» text, "" when absent.
+	SampleSize string
+	Pieces     []Piece
+	// Warnings are sentences for a human about what was read on an assumption.
+	Warnings []string
+}
+
+// Parse reads an ASCII DXF pattern.
+func Parse(raw []byte) (*Pattern, error) {
+	if bytes.HasPrefix(raw, binarySentinel) {
+		return nil, ErrBinaryDXF
+	}
+	pairs, err := readPairs(raw)
+	if err != nil {
+		return nil, err
+	}
+	doc := readDocument(pairs)
+
+	out := &Pattern{}
+	out.Unit, out.UnitSource = doc.unit()
+	if out.UnitSource == "assumed" {
+		out.Warnings = append(out.Warnings,
+			"the file declares no units ($INSUNITS, «Units:» or $MEASUREMENT) — read as millimetres")
+	}
+	scale := cmPer[out.Unit]
+	for _, t := range doc.texts {
+		if k, v, ok := attribute(t); ok && k == "sample size" {
+			out.SampleSize = v
+		}
+	}
+	if len(doc.blocks) > maxPieces {
+		return nil, fmt.Errorf("dxfpattern: file has %d blocks, max %d", len(doc.blocks), maxPieces)
+	}
+	for _, b := range doc.blocks {
+		p, ok := readPiece(b, scale)
+		if ok {
+			out.Pieces = append(out.Pieces, p)
+		}
+	}
+	return out, nil
+}
+
+// pair is one group-code / value line pair.
+type pair struct {
+	code  int
+	value string
+}
+
+// readPairs splits the file into group-code / value pairs, skipping 999 comments.
+func readPairs(raw []byte) ([]pair, error) {
+	raw = bytes.TrimPrefix(raw, []byte{0xEF, 0xBB, 0xBF})
+	lines := strings.Split(string(raw), "\n")
+	pairs := make([]pair, 0, len(lines)/2)
+	for i := 0; i+1 < len(lines); i += 2 {
+		codeLine := strings.TrimSpace(lines[i])
+		if codeLine == "" && len(pairs) == 0 {
+			// Leading blank lines before the first pair; re-align by one.
+			i--
+			continue
+		}
+		code, err := strconv.Atoi(codeLine)
+		if err != nil {
+			if len(pairs) == 0 {
+				return nil, ErrNotDXF
+			}
+			return nil, fmt.Errorf("dxfpattern: line %d: group code %q is not a number", i+1, codeLine)
+		}
+		if code == 999 {
+			continue
+		}
+		pairs = append(pairs, pair{code: code, value: strings.TrimSpace(lines[i+1])})
+	}
+	if len(pairs) == 0 || pairs[0].code != 0 || pairs[0].value != "SECTION" {
+		return nil, ErrNotDXF
+	}
+	return pairs, nil
+}
+
+// drawingEntity is one drawing entity with its raw pairs. POLYLINE keeps its VERTEX children folded in.
+type drawingEntity struct {
+	kind     string
+	layer    string
+	pairs    []pair
+	vertices []vertex
+}
+
+type vertex struct {
+	p     Point
+	bulge float64
+}
+
+type block struct {
+	name     string
+	entities []drawingEntity
+}
+
+type document struct {
+	insUnits    int
+	measurement int
+	hasIns      bool
+	hasMeasure  bool
+	texts       []string
+	blocks      []block
+}
+
+// unit resolves the drawing unit in the order the package doc gives.
+func (d *document) unit() (Unit, string) {
+	if d.hasIns {
+		switch d.insUnits {
+		case 1:
+			return UnitInch, "$INSUNITS"
+		case 4:
+			return UnitMillimetre, "$INSUNITS"
+		case 5:
+			return UnitCentimetre, "$INSUNITS"
+		case 6:
+			return UnitMetre, "$INSUNITS"
+		}
+	}
+	for _, t := range d.texts {
+		if k, v, ok := attribute(t); ok && k == "units" {
+			switch strings.ToUpper(v) {
+			case "ENGLISH":
+				return UnitInch, "Units text"
+			case "METRIC":
+				return UnitMillimetre, "Units text"
+			}
+		}
+	}
+	if d.hasMeasure {
+		if d.measurement == 0 {
+			return UnitInch, "$MEASUREMENT"
+		}
+		return UnitMillimetre, "$MEASUREMENT"
+	}
+	return UnitMillimetre, "assumed"
+}
+
+// readDocument walks the sections. Only HEADER (units), BLOCKS (pieces) and ENTITIES (file-level
+// texts) are read.
+func readDocument(pairs []pair) *document {
+	doc := &document{}
+	for i := 0; i < len(pairs); i++ {
+		if pairs[i].code != 0 || pairs[i].value != "SECTION" || i+1 >= len(pairs) || pairs[i+1].code != 2 {
+			continue
+		}
+		name := pairs[i+1].value
+		end := i + 2
+		for end < len(pairs) && !(pairs[end].code == 0 && pairs[end].value == "ENDSEC") {
+			end++
+		}
+		body := pairs[i+2 : end]
+		switch name {
+		case "HEADER":
+			doc.readHeader(body)
+		case "BLOCKS":
+			doc.readBlocks(body)
+		case "ENTITIES":
+			for _, e := range readEntities(body) {
+				if t, ok := e.text(); ok {
+					doc.texts = append(doc.texts, t)
+				}
+			}
+		}
+		i = end
+	}
+	return doc
+}
+
+func (d *document) readHeader(body []pair) {
+	for i := 0; i+1 < len(body); i++ {
+		if body[i].code != 9 {
+			continue
+		}
+		next := body[i+1]
+		if next.code != 70 {
+			continue
+		}
+		n, err := strconv.Atoi(next.value)
+		if err != nil {
+			continue
+		}
+		switch body[i].value {
+		case "$INSUNITS":
+			d.insUnits, d.hasIns = n, true
+		case "$MEASUREMENT":
+			d.measurement, d.hasMeasure = n, true
+		}
+	}
+}
+
+func (d *document) readBlocks(body []pair) {
+	for i := 0; i < len(body); i++ {
+		if body[i].code != 0 || body[i].value != "BLOCK" {
+			continue
+		}
+		// The block header runs until its first entity.
+		j := i + 1
+		var b block
+		for ; j < len(body) && body[j].code != 0; j++ {
+			if body[j].code == 2 && b.name == "" {
+				b.name = body[j].value
+			}
+		}
+		start := j
+		for j < len(body) && !(body[j].code == 0 && body[j].value == "ENDBLK") {
+			j++
+		}
+		b.entities = readEntities(body[start:j])
+		// *Model_Space / *Paper_Space and anonymous blocks are drawing plumbing, not pieces.
+		if b.name != "" && !strings.HasPrefix(b.name, "*") {
+			d.blocks = append(d.blocks, b)
+		}
+		i = j
+	}
+}
+
+// readEntities groups pairs into entities, folding a POLYLINE's VERTEX run (up to SEQEND) into it.
+func readEntities(body []pair) []drawingEntity {
+	var out []drawingEntity
+	var cur *drawingEntity
+	var inPolyline bool
+	for _, p := range body {
+		if p.code == 0 {
+			if inPolyline {
+				switch p.value {
+				case "VERTEX":
+					cur.vertices = append(cur.vertices, vertex{})
+					continue
+				case "SEQEND":
+					out = append(out, *cur)
+					cur, inPolyline = nil, false
+					continue
+				}
+				// A POLYLINE cut short by another entity: keep what it had.
+				out = append(out, *cur)
+				cur, inPolyline = nil, false
+			}
+			if cur != nil {
+				out = append(out, *cur)
+			}
+			cur = &drawingEntity{kind: p.value}
+			inPolyline = p.value == "POLYLINE"
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if inPolyline && len(cur.vertices) > 0 {
+			v := &cur.vertices[len(cur.vertices)-1]
+			switch p.code {
+			case 10:
+				v.p.X = parseFloat(p.value)
+			case 20:
+				v.p.Y = parseFloat(p.value)
+			case 42:
+				v.bulge = parseFloat(p.value)
+			}
+			continue
+		}
+		if p.code == 8 {
+			cur.layer = strings.TrimSpace(p.value)
+		}
+		cur.pairs = append(cur.pairs, p)
+	}
+	if cur != nil {
+		out = append(out, *cur)
+	}
+	return out
+}
+
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// text returns the string of a TEXT / MTEXT entity.
+func (e drawingEntity) text() (string, bool) {
+	if e.kind != "TEXT" && e.kind != "MTEXT" && e.kind != "ATTRIB" && e.kind != "ATTDEF" {
+		return "", false
+	}
+	var b strings.Builder
+	for _, p := range e.pairs {
+		// MTEXT splits long strings into 3-chunks followed by the final 1.
+		if p.code == 1 || p.code == 3 {
+			b.WriteString(p.value)
+		}
+	}
+	return b.String(), true
+}
+
+// attribute splits an AAMA «Key: value» text. The key comes back lower-cased and space-normalised.
+func attribute(t string) (string, string, bool) {
+	k, v, ok := strings.Cut(t, ":")
+	if !ok {
+		return "", "", false
+	}
+	k = strings.ToLower(strings.Join(strings.Fields(k), " "))
+	v = strings.TrimSpace(v)
+	if k == "" || v == "" {
+		return "", "", false
+	}
+	return k, v, true
+}
+
+// path returns the entity's vertices and whether it is closed, for the entity kinds that carry a
+// path (LINE, LWPOLYLINE, POLYLINE). Coordinates are still in drawing units.
+func (e drawingEntity) path() ([]vertex, bool, bool) {
+	switch e.kind {
+	case "LINE":
+		var a, b Point
+		for _, p := range e.pairs {
+			switch p.code {
+			case 10:
+				a.X = parseFloat(p.value)
+			case 20:
+				a.Y = parseFloat(p.value)
+			case 11:
+				b.X = parseFloat(p.value)
+			case 21:
+				b.Y = parseFloat(p.value)
+			}
+		}
+		return []vertex{{p: a}, {p: b}}, false, true
+	case "LWPOLYLINE":
+		var vs []vertex
+		closed := false
+		for _, p := range e.pairs {
+			switch p.code {
+			case 70:
+				n, _ := strconv.Atoi(p.value)
+				closed = n&1 == 1
+			case 10:
+				vs = append(vs, vertex{p: Point{X: parseFloat(p.value)}})
+			case 20:
+				if len(vs) > 0 {
+					vs[len(vs)-1].p.Y = parseFloat(p.value)
+				}
+			case 42:
+				if len(vs) > 0 {
+					vs[len(vs)-1].bulge = parseFloat(p.value)
+				}
+			}
+		}
+		return vs, closed, true
+	case "POLYLINE":
+		closed := false
+		for _, p := range e.pairs {
+			if p.code == 70 {
+				n, _ := strconv.Atoi(p.value)
+				closed = n&1 == 1
+			}
+		}
+		return e.vertices, closed, true
+	}
+	return nil, false, false
+}
+
+// point returns the position of a POINT entity.
+func (e drawingEntity) point() (Point, bool) {
+	if e.kind != "POINT" {
+		return Point{}, false
+	}
+	var pt Point
+	for _, p := range e.pairs {
+		switch p.code {
+		case 10:
+			pt.X = parseFloat(p.value)
+		case 20:
+			pt.Y = parseFloat(p.value)
+		}
+	}
+	return pt, true
+}
@@ -0,0 +1,290 @@
+package dxfpattern
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// dxf assembles an ASCII DXF from a header and blocks. Each block is a name plus its raw entity
+// pairs, already formatted.
+func dxf(header string, blocks ...string) []byte {
+	var b strings.Builder
+	b.WriteString("999\nexported by a test\n0\nSECTION\n2\nHEADER\n")
+	b.WriteString(header)
+	b.WriteString("0\nENDSEC\n0\nSECTION\n2\nBLOCKS\n")
+	for _, blk := range blocks {
+		b.WriteString(blk)
+	}
+	b.WriteString("0\nENDSEC\n0\nSECTION\n2\nENTITIES\n0\nTEXT\n8\n1\n1\nUnits: METRIC\n0\nTEXT\n8\n1\n1\nSample Size: M\n0\nENDSEC\n0\nEOF\n")
+	return []byte(b.String())
+}
+
+func blk(name string, entities ...string) string {
+	return "0\nBLOCK\n8\n0\n2\n" + name + "\n70\n0\n10\n0\n20\n0\n" + strings.Join(entities, "") + "0\nENDBLK\n8\n0\n"
+}
+
+func text(layer, s string) string {
+	return "0\nTEXT\n8\n" + layer + "\n10\n0\n20\n0\n1\n" + s + "\n"
+}
+
+// lwpoly draws a closed LWPOLYLINE; bulges are optional, one per vertex.
+func lwpoly(layer string, pts [][2]float64, bulges ...float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "0\nLWPOLYLINE\n8\n%s\n90\n%d\n70\n1\n", layer, len(pts))
+	for i, p := range pts {
+		fmt.Fprintf(&b, "10\n%g\n20\n%g\n", p[0], p[1])
+		if i < len(bulges) && bulges[i] != 0 {
+			fmt.Fprintf(&b, "42\n%g\n", bulges[i])
+		}
+	}
+	return b.String()
+}
+
+func line(layer string, x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("0\nLINE\n8\n%s\n10\n%g\n20\n%g\n11\n%g\n21\n%g\n", layer, x1, y1, x2, y2)
+}
+
+func point(layer string, x, y float64) string {
+	return fmt.Sprintf("0\nPOINT\n8\n%s\n10\n%g\n20\n%g\n", layer, x, y)
+}
+
+func rect(w, h float64) [][2]float64 {
+	return [][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}}
+}
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+// TestParseReadsAnAAMAPiece: one block, every layer the package reads. 100 × 200 mm is 200 cm² and a
+// 60 cm perimeter — units come from the AAMA «Units: METRIC» text (millimetres).
+func TestParseReadsAnAAMAPiece(t *testing.T) {
+	raw := dxf("", blk("FRONT-M",
+		text("1", "Piece Name: FRONT"),
+		text("1", "Size: M"),
+		text("1", "Quantity: 2"),
+		lwpoly("1", rect(100, 200)),
+		lwpoly("14", [][2]float64{{10, 10}, {90, 10}, {90, 190}, {10, 190}}),
+		line("7", 50, 20, 50, 180),
+		point("4", 0, 100),
+		line("4", 100, 100, 95, 100),
+	))
+	p, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Unit != UnitMillimetre || p.UnitSource != "Units text" || p.SampleSize != "M" || len(p.Warnings) != 0 {
+		t.Fatalf("file attributes: %+v", p)
+	}
+	if len(p.Pieces) != 1 {
+		t.Fatalf("want 1 piece, got %d", len(p.Pieces))
+	}
+	pc := p.Pieces[0]
+	if pc.Block != "FRONT-M" || pc.Name != "FRONT" || pc.Size != "M" || pc.Quantity != 2 {
+		t.Fatalf("attributes: %+v", pc)
+	}
+	if !approx(pc.AreaCm2, 200) || !approx(pc.PerimeterCm, 60) || pc.AmbiguousBoundary {
+		t.Fatalf("cut line: area %v perimeter %v ambiguous %v", pc.AreaCm2, pc.PerimeterCm, pc.AmbiguousBoundary)
+	}
+	if !approx(pc.SewLineAreaCm2, 8*18) {
+		t.Fatalf("sew line area %v", pc.SewLineAreaCm2)
+	}
+	if pc.GrainLine == nil || !approx(pc.GrainAngleDeg, 90) {
+		t.Fatalf("grain line %+v angle %v", pc.GrainLine, pc.GrainAngleDeg)
+	}
+	if len(pc.Notches) != 2 || !approx(pc.Notches[1].X, 10) {
+		t.Fatalf("notches %+v", pc.Notches)
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	piece := blk("P", lwpoly("1", rect(1, 1)))
+	cases := []struct {
+		name    string
+		raw     []byte
+		unit    Unit
+		source  string
+		areaCm2 float64
+	}{
+		{"$INSUNITS inch wins over the text", dxf("9\n$INSUNITS\n70\n1\n", piece), UnitInch, "$INSUNITS", 2.54 * 2.54},
+		{"$INSUNITS cm", dxf("9\n$INSUNITS\n70\n5\n", piece), UnitCentimetre, "$INSUNITS", 1},
+		{"AAMA text", dxf("", piece), UnitMillimetre, "Units text", 0.01},
+		{"$MEASUREMENT english", []byte(strings.Replace(string(dxf("9\n$MEASUREMENT\n70\n0\n", piece)), "Units: METRIC", "Note", 1)), UnitInch, "$MEASUREMENT", 2.54 * 2.54},
+		{"nothing declared", []byte(strings.Replace(string(dxf("", piece)), "Units: METRIC", "Note", 1)), UnitMillimetre, "assumed", 0.01},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := Parse(c.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Unit != c.unit || p.UnitSource != c.source {
+				t.Fatalf("unit %s from %s, want %s from %s", p.Unit, p.UnitSource, c.unit, c.source)
+			}
+			if !approx(p.Pieces[0].AreaCm2, c.areaCm2) {
+				t.Fatalf("area %v, want %v", p.Pieces[0].AreaCm2, c.areaCm2)
+			}
+			if (c.source == "assumed") != (len(p.Warnings) == 1) {
+				t.Fatalf("an assumed unit must be said, and only then: %v", p.Warnings)
+			}
+		})
+	}
+}
+
+// TestParseGeometry covers the contours exporters actually write: a bulged edge, a cut line drawn
+// as loose LINEs (one of them backwards), and two equal contours on the cut layer.
+func TestParseGeometry(t *testing.T) {
+	// A 20 × 10 mm rectangle whose top edge is a half circle outward (bulge 1 on the edge drawn
+	// right-to-left, counter-clockwise polygon): 2 cm² + π·1²/2 cm².
+	semi := lwpoly("1", [][2]float64{{0, 0}, {20, 0}, {20, 10}, {0, 10}}, 0, 0, 1, 0)
+	loose := line("1", 0, 0, 100, 0) + line("1", 100, 0, 100, 50) + line("1", 0, 50, 100, 50) + line("1", 0, 50, 0, 0)
+	twin := lwpoly("1", rect(30, 30)) + lwpoly("1", [][2]float64{{40, 0}, {70, 0}, {70, 30}, {40, 30}})
+	cases := []struct {
+		name      string
+		block     string
+		area      float64
+		perimeter float64
+		ambiguous bool
+	}{
+		{"bulge", blk("A", semi), 2 + math.Pi/2, (2 + 1 + 1) + math.Pi, false},
+		{"stitched lines", blk("B", loose), 50, 30, false},
+		{"equal contours", blk("C", twin), 9, 12, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := Parse(dxf("", c.block))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pc := p.Pieces[0]
+			if !approx(pc.AreaCm2, c.area) || !approx(pc.PerimeterCm, c.perimeter) || pc.AmbiguousBoundary != c.ambiguous {
+				t.Fatalf("area %v perimeter %v ambiguous %v; want %v %v %v",
+					pc.AreaCm2, pc.PerimeterCm, pc.AmbiguousBoundary, c.area, c.perimeter, c.ambiguous)
+			}
+		})
+	}
+}
+
+// TestParseFlattensArcs: the half circle of TestParseGeometry comes back in Boundary as chords
+// that reach the top of the arc and stay on the circle.
+func TestParseFlattensArcs(t *testing.T) {
+	p, err := Parse(dxf("", blk("A", lwpoly("1", [][2]float64{{0, 0}, {20, 0}, {20, 10}, {0, 10}}, 0, 0, 1, 0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := p.Pieces[0].Boundary
+	if len(b) <= 4 {
+		t.Fatalf("boundary has %d points, want the arc flattened", len(b))
+	}
+	top := 0.0
+	for _, pt := range b[3 : len(b)-1] {
+		if d := math.Hypot(pt.X-1, pt.Y-1); math.Abs(d-1) > 1e-9 {
+			t.Fatalf("arc point %+v is %v from the centre, want 1", pt, d)
+		}
+		top = math.Max(top, pt.Y)
+	}
+	if top < 2-ArcToleranceCm {
+		t.Fatalf("arc reaches y=%v, want about 2", top)
+	}
+}
+
+// TestParsePolylineVertices: old-style POLYLINE/VERTEX/SEQEND, and the SEQEND's own layer must not
+// re-file the polyline.
+func TestParsePolylineVertices(t *testing.T) {
+	poly := "0\nPOLYLINE\n8\n1\n66\n1\n70\n1\n" +
+		"0\nVERTEX\n8\n1\n10\n0\n20\n0\n" +
+		"0\nVERTEX\n8\n1\n10\n100\n20\n0\n" +
+		"0\nVERTEX\n8\n1\n10\n100\n20\n100\n" +
+		"0\nSEQEND\n8\n0\n"
+	p, err := Parse(dxf("", blk("T", poly)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(p.Pieces[0].AreaCm2, 50) {
+		t.Fatalf("area %v, want 50", p.Pieces[0].AreaCm2)
+	}
+}
+
+func TestParseRefusals(t *testing.T) {
+	if _, err := Parse([]byte("AutoCAD Binary DXF\r\n\x1a\x00rest")); !errors.Is(err, ErrBinaryDXF) {
+		t.Fatalf("binary: %v", err)
+	}
+	if _, err := Parse([]byte("%PDF-1.7\n")); !errors.Is(err, ErrNotDXF) {
+		t.Fatalf("pdf: %v", err)
+	}
+	p, err := Parse(dxf("", blk("*Model_Space"), blk("TITLE", text("1", "Style Name: X"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Pieces) != 0 {
+		t.Fatalf("plumbing and text-only blocks are not pieces: %+v", p.Pieces)
+	}
+}
+
+func TestGradeSizes(t *testing.T) {
+	pieces := func(names ...string) []Piece {
+		out := make([]Piece, len(names))
+		for i, n := range names {
+			out[i] = Piece{Block: n, Name: n}
+		}
+		return out
+	}
+	cases := []struct {
+		name   string
+		in     []Piece
+		stems  []string
+		tokens []string
+	}{
+		{
+			name:   "graded stems",
+			in:     pieces("FRONT-S", "FRONT-M", "BACK_S", "BACK_M", "COLLAR"),
+			stems:  []string{"FRONT", "FRONT", "BACK", "BACK", "COLLAR"},
+			tokens: []string{"m", "s"},
+		},
+		{
+			name:   "decorated base size",
+			in:     pieces("BP_<S>", "BP_M", "BP_L"),
+			stems:  []string{"BP", "BP", "BP"},
+			tokens: []string{"l", "m", "s"},
+		},
+		{
+			name:   "one tail per stem is not a grade",
+			in:     pieces("CUFF-L", "YOKE-M"),
+			stems:  []string{"CUFF-L", "YOKE-M"},
+			tokens: []string{},
+		},
+		{
+			name:   "a variant pair does not add sizes to a graded pack",
+			in:     pieces("FRONT-S", "FRONT-M", "BACK-S", "BACK-M", "SLEEVE-S", "SLEEVE-M", "POCKET-A", "POCKET-B"),
+			stems:  []string{"FRONT", "FRONT", "BACK", "BACK", "SLEEVE", "SLEEVE", "POCKET-A", "POCKET-B"},
+			tokens: []string{"m", "s"},
+		},
+		{
+			name:   "long tails are words",
+			in:     pieces("FRONT-FACING", "FRONT-LINING"),
+			stems:  []string{"FRONT-FACING", "FRONT-LINING"},
+			tokens: []string{},
+		},
+		{
+			name:   "explicit size text wins",
+			in:     []Piece{{Block: "P1", Size: "XL"}, {Block: "P1 XL", Size: "XL"}},
+			stems:  []string{"P1", "P1"},
+			tokens: []string{"xl"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := GradeSizes(c.in)
+			for i := range g {
+				if g[i].Stem != c.stems[i] {
+					t.Fatalf("block %q: stem %q, want %q", c.in[i].Block, g[i].Stem, c.stems[i])
+				}
+			}
+			got := SizeTokens(g)
+			if strings.Join(got, ",") != strings.Join(c.tokens, ",") {
+				t.Fatalf("tokens %v, want %v", got, c.tokens)
+			}
+		})
+	}
+}
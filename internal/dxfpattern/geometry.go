@@ -0,0 +1,303 @@
+package dxfpattern
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// joinTolerance is how close (in drawing units scaled to cm) two path ends must be to count as one
+// point. CAD exports write the same vertex through float formatting twice, so exact equality is not
+// to be expected; 0.01 mm is far below anything a cutter resolves.
+const joinTolerance = 0.001
+
+// areaTieRatio is the relative difference below which two contours count as the same area.
+const areaTieRatio = 1e-6
+
+// ArcToleranceCm is the largest gap (cm) between an arc and the chords that stand in for it in
+// Piece.Boundary — half a millimetre, below what a nesting raster resolves.
+const ArcToleranceCm = 0.05
+
+// contour is one closed path in centimetres, with the bulges of its edges.
+type contour struct {
+	pts    []Point
+	bulges []float64 // bulges[i] is the arc of the edge pts[i] → pts[i+1 mod n]
+}
+
+// readPiece turns one block into a piece. ok=false for a block that carries no geometry at all —
+// a title block or an empty insert.
+func readPiece(b block, scale float64) (Piece, bool) {
+	p := Piece{Block: b.name, Name: b.name}
+	var cut, sew []contour
+	var openCut, openSew [][]vertex
+	hasGeometry := false
+
+	for _, e := range b.entities {
+		if t, ok := e.text(); ok {
+			if k, v, ok := attribute(t); ok {
+				switch k {
+				case "piece name":
+					p.Name = v
+				case "size":
+					p.Size = v
+				case "quantity":
+					if n, err := strconv.Atoi(strings.Fields(v)[0]); err == nil {
+						p.Quantity = n
+					}
+				}
+			}
+			continue
+		}
+		if pt, ok := e.point(); ok {
+			hasGeometry = true
+			if e.layer == LayerNotch {
+				p.Notches = append(p.Notches, scalePoint(pt, scale))
+			}
+			continue
+		}
+		vs, closed, ok := e.path()
+		if !ok || len(vs) < 2 {
+			continue
+		}
+		hasGeometry = true
+		switch e.layer {
+		case LayerBoundary:
+			if c, ok := closedContour(vs, closed, scale); ok {
+				cut = append(cut, c)
+			} else {
+				openCut = append(openCut, vs)
+			}
+		case LayerSewLine:
+			if c, ok := closedContour(vs, closed, scale); ok {
+				sew = append(sew, c)
+			} else {
+				openSew = append(openSew, vs)
+			}
+		case LayerGrain:
+			if p.GrainLine == nil {
+				g := Segment{From: scalePoint(vs[0].p, scale), To: scalePoint(vs[len(vs)-1].p, scale)}
+				p.GrainLine = &g
+				p.GrainAngleDeg = grainAngle(g)
+			}
+		case LayerNotch:
+			// A notch drawn as a short line: its origin is the point on the contour.
+			p.Notches = append(p.Notches, scalePoint(vs[0].p, scale))
+		}
+	}
+	if !hasGeometry {
+		return Piece{}, false
+	}
+	// Exporters that draw the cut line as a chain of separate LINEs / open polylines get it stitched
+	// back into closed contours here.
+	cut = append(cut, stitch(openCut, scale)...)
+	sew = append(sew, stitch(openSew, scale)...)
+
+	if best, area, ambiguous := largest(cut); best != nil {
+		p.Boundary = best.outline()
+		p.AreaCm2 = area
+		p.PerimeterCm = best.perimeter()
+		p.AmbiguousBoundary = ambiguous
+	}
+	if _, area, _ := largest(sew); area > 0 {
+		p.SewLineAreaCm2 = area
+	}
+	return p, true
+}
+
+func scalePoint(pt Point, scale float64) Point {
+	return Point{X: pt.X * scale, Y: pt.Y * scale}
+}
+
+func near(a, b Point) bool {
+	return math.Abs(a.X-b.X) <= joinTolerance && math.Abs(a.Y-b.Y) <= joinTolerance
+}
+
+// closedContour builds a contour from a path that is flagged closed or whose ends meet.
+func closedContour(vs []vertex, closed bool, scale float64) (contour, bool) {
+	c := contour{pts: make([]Point, 0, len(vs)), bulges: make([]float64, 0, len(vs))}
+	for _, v := range vs {
+		c.pts = append(c.pts, scalePoint(v.p, scale))
+		c.bulges = append(c.bulges, v.bulge)
+	}
+	if !closed {
+		if len(c.pts) < 3 || !near(c.pts[0], c.pts[len(c.pts)-1]) {
+			return contour{}, false
+		}
+	}
+	// A repeated closing vertex would add a zero-length edge; drop it.
+	if len(c.pts) > 1 && near(c.pts[0], c.pts[len(c.pts)-1]) {
+		c.pts = c.pts[:len(c.pts)-1]
+		c.bulges = c.bulges[:len(c.bulges)-1]
+	}
+	if len(c.pts) < 3 {
+		return contour{}, false
+	}
+	return c, true
+}
+
+// stitch chains open paths end to end into closed contours. A chain that does not close is dropped —
+// an open cut line has no area, and guessing the missing edge would invent cloth.
+func stitch(paths [][]vertex, scale float64) []contour {
+	type chain struct {
+		vs []vertex
+	}
+	left := make([]chain, 0, len(paths))
+	for _, p := range paths {
+		vs := make([]vertex, len(p))
+		for i, v := range p {
+			vs[i] = vertex{p: scalePoint(v.p, scale), bulge: v.bulge}
+		}
+		left = append(left, chain{vs: vs})
+	}
+	var out []contour
+	for len(left) > 0 {
+		cur := left[0].vs
+		left = left[1:]
+		for grew := true; grew; {
+			grew = false
+			for i := 0; i < len(left); i++ {
+				next := left[i].vs
+				end := cur[len(cur)-1].p
+				switch {
+				case near(end, next[0].p):
+					cur[len(cur)-1].bulge = next[0].bulge
+					cur = append(cur, next[1:]...)
+				case near(end, next[len(next)-1].p):
+					rev := reversePath(next)
+					cur[len(cur)-1].bulge = rev[0].bulge
+					cur = append(cur, rev[1:]...)
+				default:
+					continue
+				}
+				left = append(left[:i], left[i+1:]...)
+				grew = true
+				break
+			}
+		}
+		if c, ok := closedContour(cur, false, 1); ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// reversePath walks a path backwards; each bulge moves to the other end of its edge and flips sign.
+func reversePath(vs []vertex) []vertex {
+	out := make([]vertex, len(vs))
+	for i := range vs {
+		out[i].p = vs[len(vs)-1-i].p
+	}
+	for i := 0; i+1 < len(vs); i++ {
+		// Edge vs[i]→vs[i+1] becomes edge out[n-2-i]→out[n-1-i].
+		out[len(vs)-2-i].bulge = -vs[i].bulge
+	}
+	return out
+}
+
+// largest picks the contour of the largest area and says whether another one ties with it.
+func largest(cs []contour) (*contour, float64, bool) {
+	var best *contour
+	var bestArea float64
+	ambiguous := false
+	for i := range cs {
+		a := cs[i].area()
+		switch {
+		case best == nil || a > bestArea*(1+areaTieRatio):
+			best, bestArea, ambiguous = &cs[i], a, false
+		case a >= bestArea*(1-areaTieRatio):
+			ambiguous = true
+		}
+	}
+	return best, bestArea, ambiguous
+}
+
+// area is the shoelace area plus the circular segments of bulged edges. A positive bulge turns
+// counter-clockwise from the edge's start, so its segment adds to a counter-clockwise polygon and
+// subtracts from a clockwise one — which is exactly what adding it to the SIGNED area does.
+func (c contour) area() float64 {
+	n := len(c.pts)
+	var signed float64
+	for i := 0; i < n; i++ {
+		a, b := c.pts[i], c.pts[(i+1)%n]
+		signed += a.X*b.Y - b.X*a.Y
+		if bulge := c.bulges[i]; bulge != 0 {
+			theta := 4 * math.Atan(bulge)
+			chord := math.Hypot(b.X-a.X, b.Y-a.Y)
+			r := chord / (2 * math.Sin(theta/2))
+			// Twice the segment area, to match the doubled shoelace sum.
+			signed += r * r * (theta - math.Sin(theta))
+		}
+	}
+	return math.Abs(signed) / 2
+}
+
+// outline is the contour as a polygon: each bulged edge is replaced by chords whose sagitta stays
+// within ArcToleranceCm. A contour without arcs comes back as is.
+func (c contour) outline() []Point {
+	n := len(c.pts)
+	out := make([]Point, 0, n)
+	for i := 0; i < n; i++ {
+		a, b := c.pts[i], c.pts[(i+1)%n]
+		out = append(out, a)
+		bulge := c.bulges[i]
+		if bulge == 0 {
+			continue
+		}
+		theta := 4 * math.Atan(bulge)
+		chord := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if chord == 0 {
+			continue
+		}
+		r := chord / (2 * math.Sin(theta/2)) // signed: negative for a clockwise arc
+		// Chord count so that r·(1 − cos(step/2)) ≤ ArcToleranceCm.
+		steps := 1
+		if ar := math.Abs(r); ar > ArcToleranceCm {
+			step := 2 * math.Acos(1-ArcToleranceCm/ar)
+			steps = int(math.Ceil(math.Abs(theta) / step))
+		}
+		if steps > 256 {
+			steps = 256
+		}
+		// Centre: from the chord midpoint, along the left normal by r·cos(θ/2).
+		mx, my := (a.X+b.X)/2, (a.Y+b.Y)/2
+		ux, uy := (b.X-a.X)/chord, (b.Y-a.Y)/chord
+		d := r * math.Cos(theta/2)
+		cx, cy := mx-uy*d, my+ux*d
+		start := math.Atan2(a.Y-cy, a.X-cx)
+		ar := math.Hypot(a.X-cx, a.Y-cy)
+		for k := 1; k < steps; k++ {
+			ang := start + theta*float64(k)/float64(steps)
+			out = append(out, Point{X: cx + ar*math.Cos(ang), Y: cy + ar*math.Sin(ang)})
+		}
+	}
+	return out
+}
+
+// perimeter is the length of the contour, arcs measured along the arc.
+func (c contour) perimeter() float64 {
+	n := len(c.pts)
+	var total float64
+	for i := 0; i < n; i++ {
+		a, b := c.pts[i], c.pts[(i+1)%n]
+		chord := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if bulge := c.bulges[i]; bulge != 0 {
+			theta := 4 * math.Atan(bulge)
+			total += math.Abs(chord / (2 * math.Sin(theta/2)) * theta)
+			continue
+		}
+		total += chord
+	}
+	return total
+}
+
+// grainAngle is the undirected direction of a grain line in [0, 180) degrees.
+func grainAngle(g Segment) float64 {
+	deg := math.Atan2(g.To.Y-g.From.Y, g.To.X-g.From.X) * 180 / math.Pi
+	for deg < 0 {
+		deg += 180
+	}
+	for deg >= 180 {
+		deg -= 180
+	}
+	return deg
+}
@@ -0,0 +1,374 @@
+package dxfpattern
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// ГРАДАЦИЯ ЛЕКАЛ — the sizes of a scope drawn by the server from its base size and point grade
+// rules, instead of being drawn by the grader in CAD and uploaded one file per size.
+//
+// A RULE MOVES ONE CARDINAL POINT of the base contour by (dx, dy) cm PER SIZE STEP. A size's step is
+// its position in the card's size range minus the base size's position — the same «position delta»
+// the size chart's grade rule multiplies (StyleSizeChart.GradeSteps), so a chart graded +4 cm per
+// step and its pieces graded from the same base stay one grade.
+//
+// Cardinal points are addressed by their index in the base piece's Boundary. Every point between two
+// ruled ones follows by interpolation along the contour — the AAMA convention for unruled points,
+// and the reason a curve keeps its shape instead of being dragged by its nearest corner. A piece with
+// a single ruled point moves whole; a piece with no rules keeps the base contour at every size.
+//
+// The base contour is the server's reading of the scope's files (Measure), so the grade stands on the
+// same geometry the measurement and the nest do.
+
+// Grading finding codes. The blocking ones stop the area write and the files.
+const (
+	FindingGradeBaseNotInRange  = "grade_base_not_in_range"  // blocking: the base size is not one of the card's sizes
+	FindingGradeBaseNotInFiles  = "grade_base_not_in_files"  // blocking: a graded piece has no base-size block
+	FindingGradePointOutOfRange = "grade_point_out_of_range" // blocking: a rule names a point the contour lacks
+	FindingGradedContourInvalid = "graded_contour_invalid"   // blocking: a size's contour folds over itself
+	FindingGradeRuleUnused      = "grade_rule_unused"        // a rule for a piece the scope does not cut
+	FindingGradeRuleOnUngraded  = "grade_rule_on_ungraded"   // a rule for a piece marked ungraded — ignored
+	FindingPieceHasNoGradeRules = "piece_has_no_grade_rules" // a graded piece keeps its base contour at every size
+)
+
+// maxIntersectionCheckVertices bounds the self-intersection check of a graded contour.
+const maxIntersectionCheckVertices = 4000
+
+// GradeRule moves one cardinal point of a piece's base contour by (DxCm, DyCm) per size step.
+type GradeRule struct {
+	PieceLineKey string
+	Point        int
+	DxCm, DyCm   float64
+}
+
+// GradedPiece is one piece drawn at one size.
+type GradedPiece struct {
+	PieceLineKey string
+	// SizeId is the card size; 0 for a piece marked ungraded, which is the same at every size.
+	SizeId int
+	// Step is the size's position delta from the base size.
+	Step  int
+	Stem  string
+	Piece Piece
+}
+
+// Grading is the graded scope.
+type Grading struct {
+	BaseSizeId int
+	// Pieces holds every piece at every size, in piece order then size-range order.
+	Pieces []GradedPiece
+	// Rows is the area set in the shape SaveTechCardPieceAreas takes; meaningful only when Complete.
+	Rows     []entity.PieceAreaInput
+	Findings []Finding
+}
+
+// Complete reports whether Rows is a whole set the store may be asked to write.
+func (g Grading) Complete() bool {
+	if len(g.Rows) == 0 {
+		return false
+	}
+	for _, f := range g.Findings {
+		if f.Blocking {
+			return false
+		}
+	}
+	return true
+}
+
+// Grade draws every linked piece of a scope at every size of its range from the base size's blocks.
+//
+// m is the scope's Measure reading: its findings about sheets and pieces carry over, except «a size
+// is missing from the files», which is the very thing grading answers. src.SizeIds is the size range
+// in grade order (ascending size id, as the store loads it).
+func Grade(m Measurement, src entity.PatternScopeSource, baseSizeID int, rules []GradeRule, sizeNames map[int]string) Grading {
+	g := Grading{BaseSizeId: baseSizeID}
+	for _, f := range m.Findings {
+		if f.Code != FindingSizeNotInFiles {
+			g.Findings = append(g.Findings, f)
+		}
+	}
+	basePos := -1
+	for i, id := range src.SizeIds {
+		if id == baseSizeID {
+			basePos = i
+		}
+	}
+	if basePos < 0 {
+		g.Findings = append(g.Findings, Finding{Code: FindingGradeBaseNotInRange, Blocking: true,
+			Subject: fmt.Sprint(baseSizeID),
+			Detail:  fmt.Sprintf("the grade base size %s is not one of the card's sizes", sizeLabel(baseSizeID, sizeNames))})
+		return g
+	}
+
+	names := make(map[string]string, len(src.Pieces))
+	ungraded := map[string]bool{}
+	for _, p := range src.Pieces {
+		k := strings.ToUpper(strings.TrimSpace(p.LineKey))
+		names[k] = p.Name
+		ungraded[k] = p.Ungraded
+	}
+	byPiece := map[string][]GradeRule{}
+	for _, r := range rules {
+		k := strings.ToUpper(strings.TrimSpace(r.PieceLineKey))
+		byPiece[k] = append(byPiece[k], r)
+	}
+	blocksOf := map[string][]int{}
+	for i, b := range m.Blocks {
+		if b.PieceLineKey != "" && b.Piece.Measurable() {
+			blocksOf[b.PieceLineKey] = append(blocksOf[b.PieceLineKey], i)
+		}
+	}
+	expected := map[string]bool{}
+	for _, b := range src.Blocks {
+		if k := strings.ToUpper(strings.TrimSpace(b.PieceLineKey)); k != "" {
+			expected[k] = true
+		}
+	}
+	for _, key := range sortedKeys(ruleKeys(byPiece)) {
+		if !expected[key] {
+			g.Findings = append(g.Findings, Finding{Code: FindingGradeRuleUnused, Subject: key,
+				Detail: fmt.Sprintf("%s is not cut from this fabric — its grade rules are not applied here", pieceLabel(key, names))})
+		}
+	}
+
+	for _, key := range sortedKeys(expected) {
+		label := pieceLabel(key, names)
+		idx := blocksOf[key]
+		if len(idx) == 0 {
+			continue // Measure has already said why: not in the files, or no cut line
+		}
+		if ungraded[key] {
+			if len(byPiece[key]) > 0 {
+				g.Findings = append(g.Findings, Finding{Code: FindingGradeRuleOnUngraded, Subject: key,
+					Detail: fmt.Sprintf("%s is marked ungraded — its grade rules are ignored", label)})
+			}
+			i, _ := largestBlock(m.Blocks, idx)
+			b := m.Blocks[i]
+			g.Pieces = append(g.Pieces, GradedPiece{PieceLineKey: key, Stem: b.Stem, Piece: b.Piece})
+			g.Rows = append(g.Rows, areaRow(key, sql.NullInt64{}, b.Piece, false))
+			continue
+		}
+		base, ok := baseBlock(m.Blocks, idx, baseSizeID)
+		if !ok {
+			g.Findings = append(g.Findings, Finding{Code: FindingGradeBaseNotInFiles, Blocking: true, Subject: key,
+				Detail: fmt.Sprintf("%s: no block for the base size %s, and no ungraded block to grade from", label, sizeLabel(baseSizeID, sizeNames))})
+			continue
+		}
+		b := m.Blocks[base]
+		pr := byPiece[key]
+		if bad := outOfRange(pr, len(b.Piece.Boundary)); bad >= 0 {
+			g.Findings = append(g.Findings, Finding{Code: FindingGradePointOutOfRange, Blocking: true, Subject: key,
+				Detail: fmt.Sprintf("%s: a rule moves point %d, and the base contour has points 0–%d", label, bad, len(b.Piece.Boundary)-1)})
+			continue
+		}
+		if len(pr) == 0 {
+			g.Findings = append(g.Findings, Finding{Code: FindingPieceHasNoGradeRules, Subject: key,
+				Detail: fmt.Sprintf("%s has no grade rules — every size gets the base contour", label)})
+		}
+		for pos, id := range src.SizeIds {
+			step := pos - basePos
+			pc := b.Piece
+			if step != 0 {
+				pc = GradePiece(b.Piece, pr, step)
+			}
+			pc.Size = sizeCode(id, sizeNames)
+			if step != 0 && (!pc.Measurable() || selfIntersects(pc.Boundary)) {
+				g.Findings = append(g.Findings, Finding{Code: FindingGradedContourInvalid, Blocking: true, Subject: key,
+					Detail: fmt.Sprintf("%s, size %s: the graded contour folds over itself — check the rules' directions", label, sizeLabel(id, sizeNames))})
+				continue
+			}
+			g.Pieces = append(g.Pieces, GradedPiece{PieceLineKey: key, SizeId: id, Step: step, Stem: b.Stem, Piece: pc})
+			g.Rows = append(g.Rows, areaRow(key, sql.NullInt64{Int64: int64(id), Valid: true}, pc, false))
+		}
+	}
+	return g
+}
+
+// GradePiece applies a piece's rules step times. The contour, notches and grain line move; the
+// area and perimeter are recomputed on the graded polygon. The sew line is not graded and is dropped.
+func GradePiece(base Piece, rules []GradeRule, step int) Piece {
+	deltas := pointDeltas(base.Boundary, rules)
+	out := base
+	out.Boundary = make([]Point, len(base.Boundary))
+	k := float64(step)
+	var mx, my float64
+	for i, p := range base.Boundary {
+		out.Boundary[i] = Point{X: p.X + deltas[i].X*k, Y: p.Y + deltas[i].Y*k}
+		mx += deltas[i].X
+		my += deltas[i].Y
+	}
+	if n := float64(len(deltas)); n > 0 {
+		mx, my = mx/n*k, my/n*k
+	}
+	poly := contour{pts: out.Boundary, bulges: make([]float64, len(out.Boundary))}
+	out.AreaCm2 = poly.area()
+	out.PerimeterCm = poly.perimeter()
+	out.SewLineAreaCm2 = 0
+	out.Notches = make([]Point, len(base.Notches))
+	for i, n := range base.Notches {
+		d := deltas[nearestVertex(base.Boundary, n)]
+		out.Notches[i] = Point{X: n.X + d.X*k, Y: n.Y + d.Y*k}
+	}
+	if base.GrainLine != nil {
+		// The grain line is a direction, not an outline: it moves with the piece and never turns.
+		gl := Segment{From: Point{X: base.GrainLine.From.X + mx, Y: base.GrainLine.From.Y + my},
+			To: Point{X: base.GrainLine.To.X + mx, Y: base.GrainLine.To.Y + my}}
+		out.GrainLine = &gl
+	}
+	return out
+}
+
+// pointDeltas spreads the rules over every vertex: a ruled vertex takes its rule, the vertices
+// between two ruled ones take the linear blend by contour length, and with one ruled vertex the
+// whole contour takes its delta.
+func pointDeltas(pts []Point, rules []GradeRule) []Point {
+	n := len(pts)
+	out := make([]Point, n)
+	if n == 0 || len(rules) == 0 {
+		return out
+	}
+	ruled := map[int]Point{}
+	for _, r := range rules {
+		ruled[r.Point] = Point{X: r.DxCm, Y: r.DyCm}
+	}
+	anchors := make([]int, 0, len(ruled))
+	for i := range ruled {
+		anchors = append(anchors, i)
+	}
+	sort.Ints(anchors)
+	if len(anchors) == 1 {
+		for i := range out {
+			out[i] = ruled[anchors[0]]
+		}
+		return out
+	}
+	// at[i] is the contour length from vertex 0 to vertex i.
+	at := make([]float64, n+1)
+	for i := 0; i < n; i++ {
+		a, b := pts[i], pts[(i+1)%n]
+		at[i+1] = at[i] + math.Hypot(b.X-a.X, b.Y-a.Y)
+	}
+	total := at[n]
+	for j, from := range anchors {
+		to := anchors[(j+1)%len(anchors)]
+		span := at[to] - at[from]
+		if to <= from {
+			span += total
+		}
+		d0, d1 := ruled[from], ruled[to]
+		for i := from; ; i = (i + 1) % n {
+			if i == to {
+				break
+			}
+			run := at[i] - at[from]
+			if i < from {
+				run += total
+			}
+			t := 0.0
+			if span > 0 {
+				t = run / span
+			}
+			out[i] = Point{X: d0.X + (d1.X-d0.X)*t, Y: d0.Y + (d1.Y-d0.Y)*t}
+		}
+	}
+	return out
+}
+
+// baseBlock picks the block a piece grades from: its base-size block, or — in a file drawn in one
+// size only — its ungraded block.
+func baseBlock(blocks []MeasuredBlock, idx []int, baseSizeID int) (int, bool) {
+	var sized, plain []int
+	for _, i := range idx {
+		switch {
+		case blocks[i].SizeId == baseSizeID:
+			sized = append(sized, i)
+		case blocks[i].SizeToken == "":
+			plain = append(plain, i)
+		}
+	}
+	if len(sized) > 0 {
+		i, _ := largestBlock(blocks, sized)
+		return i, true
+	}
+	if len(plain) > 0 {
+		i, _ := largestBlock(blocks, plain)
+		return i, true
+	}
+	return 0, false
+}
+
+// outOfRange returns the first rule point the contour does not have, or -1.
+func outOfRange(rules []GradeRule, n int) int {
+	for _, r := range rules {
+		if r.Point < 0 || r.Point >= n {
+			return r.Point
+		}
+	}
+	return -1
+}
+
+func nearestVertex(pts []Point, p Point) int {
+	best, bestD := 0, math.Inf(1)
+	for i, q := range pts {
+		if d := math.Hypot(q.X-p.X, q.Y-p.Y); d < bestD {
+			best, bestD = i, d
+		}
+	}
+	return best
+}
+
+// selfIntersects reports whether two non-adjacent edges of a closed polygon cross. Contours past
+// maxIntersectionCheckVertices are not checked — the quadratic walk is not worth it for a pattern
+// nobody draws, and the area check still catches a contour turned inside out.
+func selfIntersects(pts []Point) bool {
+	n := len(pts)
+	if n < 4 || n > maxIntersectionCheckVertices {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		a, b := pts[i], pts[(i+1)%n]
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue // the edges share vertex 0
+			}
+			if segmentsCross(a, b, pts[j], pts[(j+1)%n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func segmentsCross(p1, p2, p3, p4 Point) bool {
+	d1 := cross(p3, p4, p1)
+	d2 := cross(p3, p4, p2)
+	d3 := cross(p1, p2, p3)
+	d4 := cross(p1, p2, p4)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+func cross(a, b, c Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// sizeCode is the size as a file spells it: the dictionary name's code («xs_44ta_m» → «XS»).
+func sizeCode(id int, names map[int]string) string {
+	if t := entity.SizeTokensOf(names[id]); len(t) > 0 {
+		return strings.ToUpper(t[0])
+	}
+	return fmt.Sprint(id)
+}
+
+func ruleKeys(m map[string][]GradeRule) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
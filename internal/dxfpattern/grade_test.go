@@ -0,0 +1,167 @@
+package dxfpattern
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// gradeFixture is one fabric cut in S, M, L from an M-only file: a 20 × 30 cm front, a 10 × 10 cm
+// ungraded pocket.
+func gradeFixture() (Measurement, entity.PatternScopeSource, map[int]string) {
+	src, _, _ := scopeFixture()
+	src.SizeIds = []int{1, 2, 3}
+	src.Blocks = []entity.PieceAreaBlockRef{
+		{BlockName: "FRONT", PieceLineKey: "P1"},
+		{BlockName: "POCKET", PieceLineKey: "P3"},
+	}
+	front := Piece{Block: "FRONT", Name: "Front", Quantity: 2,
+		Boundary:  []Point{{0, 0}, {20, 0}, {20, 30}, {0, 30}},
+		AreaCm2:   600,
+		GrainLine: &Segment{From: Point{10, 5}, To: Point{10, 25}},
+		Notches:   []Point{{20, 15}},
+	}
+	pocket := Piece{Block: "POCKET", Name: "Pocket", Boundary: []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, AreaCm2: 100}
+	names := map[int]string{1: "s", 2: "m", 3: "l"}
+	m := Measure(src, map[string]*Pattern{"SH1": {Unit: UnitCentimetre, Pieces: []Piece{front, pocket}}}, nil, names)
+	return m, src, names
+}
+
+func TestGradeMovesCardinalPointsPerStep(t *testing.T) {
+	m, src, names := gradeFixture()
+	// The right side grows 1 cm per step, the top 2 cm; the bottom-left corner is the anchor.
+	rules := []GradeRule{
+		{PieceLineKey: "P1", Point: 0},
+		{PieceLineKey: "P1", Point: 1, DxCm: 1},
+		{PieceLineKey: "P1", Point: 2, DxCm: 1, DyCm: 2},
+		{PieceLineKey: "P1", Point: 3, DyCm: 2},
+	}
+	g := Grade(m, src, 2, rules, names)
+	if !g.Complete() {
+		t.Fatalf("grading not complete: %+v", g.Findings)
+	}
+	areas := map[int]float64{}
+	for _, r := range g.Rows {
+		if r.PieceLineKey == "P3" {
+			if r.SizeId.Valid {
+				t.Fatalf("ungraded pocket stored per size: %+v", r)
+			}
+			continue
+		}
+		areas[int(r.SizeId.Int64)], _ = r.AreaCm2.Float64()
+	}
+	// S: 19 × 28, M: the base, L: 21 × 32.
+	if !approx(areas[1], 532) || !approx(areas[2], 600) || !approx(areas[3], 672) {
+		t.Fatalf("graded areas = %v", areas)
+	}
+	for _, gp := range g.Pieces {
+		if gp.PieceLineKey != "P1" || gp.SizeId != 3 {
+			continue
+		}
+		if n := gp.Piece.Notches[0]; !approx(n.X, 21) || !approx(n.Y, 15) {
+			t.Fatalf("notch on the right side moved to %+v, want (21, 15)", n)
+		}
+		if gl := gp.Piece.GrainLine; !approx(gl.From.X-gl.To.X, 0) || !approx(gl.To.Y-gl.From.Y, 20) {
+			t.Fatalf("grain line turned or stretched: %+v", gl)
+		}
+	}
+}
+
+func TestGradeInterpolatesUnruledPoints(t *testing.T) {
+	// Point 1 sits halfway along the bottom edge between the two ruled corners.
+	pts := []Point{{0, 0}, {10, 0}, {20, 0}, {20, 10}, {0, 10}}
+	d := pointDeltas(pts, []GradeRule{{Point: 0}, {Point: 2, DxCm: 2}})
+	if !approx(d[1].X, 1) {
+		t.Fatalf("midpoint delta = %+v, want half of the corner's", d[1])
+	}
+	// From point 2 back round to point 0 the blend runs the other way.
+	if !approx(d[3].X, 2*(1-10.0/40)) || !approx(d[4].X, 2*(1-30.0/40)) {
+		t.Fatalf("wrap-around deltas = %+v", d)
+	}
+	one := pointDeltas(pts, []GradeRule{{Point: 3, DxCm: 1, DyCm: 1}})
+	for i, p := range one {
+		if !approx(p.X, 1) || !approx(p.Y, 1) {
+			t.Fatalf("single rule should move the piece whole, point %d = %+v", i, p)
+		}
+	}
+}
+
+func TestGradeRefusals(t *testing.T) {
+	m, src, names := gradeFixture()
+
+	t.Run("base size outside the range", func(t *testing.T) {
+		g := Grade(m, src, 9, nil, names)
+		if g.Complete() || !hasFinding(g.Findings, FindingGradeBaseNotInRange) {
+			t.Fatalf("findings = %+v", g.Findings)
+		}
+	})
+	t.Run("point the contour lacks", func(t *testing.T) {
+		g := Grade(m, src, 2, []GradeRule{{PieceLineKey: "P1", Point: 4, DxCm: 1}}, names)
+		if g.Complete() || !hasFinding(g.Findings, FindingGradePointOutOfRange) {
+			t.Fatalf("findings = %+v", g.Findings)
+		}
+	})
+	t.Run("contour folded over itself", func(t *testing.T) {
+		// Dragging one corner across the opposite side.
+		g := Grade(m, src, 2, []GradeRule{{PieceLineKey: "P1", Point: 0}, {PieceLineKey: "P1", Point: 1, DxCm: -30}}, names)
+		if g.Complete() || !hasFinding(g.Findings, FindingGradedContourInvalid) {
+			t.Fatalf("findings = %+v", g.Findings)
+		}
+	})
+	t.Run("no rules is a warning, not a refusal", func(t *testing.T) {
+		g := Grade(m, src, 2, []GradeRule{{PieceLineKey: "P9", Point: 0}}, names)
+		if !g.Complete() || !hasFinding(g.Findings, FindingPieceHasNoGradeRules) || !hasFinding(g.Findings, FindingGradeRuleUnused) {
+			t.Fatalf("findings = %+v", g.Findings)
+		}
+	})
+}
+
+// TestGradedFilesReadBack: the written files are what Measure reads back — same sizes, same areas.
+func TestGradedFilesReadBack(t *testing.T) {
+	m, src, names := gradeFixture()
+	g := Grade(m, src, 2, []GradeRule{{PieceLineKey: "P1", Point: 0}, {PieceLineKey: "P1", Point: 2, DxCm: 1, DyCm: 1}}, names)
+	files := g.SizeFiles(src.SizeIds, names)
+	if len(files) != 3 || files[0].Size != "S" || files[2].Size != "L" {
+		t.Fatalf("files = %+v", files)
+	}
+	patterns := map[string]*Pattern{}
+	src.Sheets = src.Sheets[:0]
+	for _, f := range files {
+		p, err := Parse(f.DXF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.UnitSource != "$INSUNITS" || p.SampleSize != "M" || len(p.Pieces) != 2 {
+			t.Fatalf("size %s read back as %+v", f.Size, p)
+		}
+		key := "G" + f.Size
+		patterns[key] = p
+		src.Sheets = append(src.Sheets, entity.PatternScopeSheet{LineKey: key, URL: "https://cdn/tech-card-patterns/" + key + ".dxf"})
+	}
+	back := Measure(src, patterns, nil, names)
+	if !back.Complete() {
+		t.Fatalf("read-back not complete: %+v", back.Findings)
+	}
+	if len(back.Rows) != len(g.Rows) {
+		t.Fatalf("read-back rows %d, graded rows %d", len(back.Rows), len(g.Rows))
+	}
+	want := map[string]string{}
+	for _, r := range g.Rows {
+		want[fmt.Sprint(r.PieceLineKey, "/", r.SizeId.Int64)] = r.AreaCm2.String()
+	}
+	for _, r := range back.Rows {
+		if k := fmt.Sprint(r.PieceLineKey, "/", r.SizeId.Int64); want[k] != r.AreaCm2.String() {
+			t.Errorf("%s read back %s, graded %s", k, r.AreaCm2, want[k])
+		}
+	}
+}
+
+func hasFinding(fs []Finding, code string) bool {
+	for _, f := range fs {
+		if f.Code == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,435 @@
+package dxfpattern
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// ContourLayerCut is the contour_layer a server measurement is stored under: the AAMA cut line,
+// which already includes the seam allowance — so SeamAllowanceMm is 0 and nothing is inflated.
+const ContourLayerCut = LayerBoundary
+
+// Finding codes. The blocking ones stop the server from writing the scope's areas: each of them
+// would make the store refuse the set anyway, or — worse — accept a set that understates the garment.
+const (
+	FindingSheetUnreadable     = "sheet_unreadable"      // blocking: a DXF sheet could not be read
+	FindingPieceNotInFiles     = "piece_not_in_files"    // blocking: a linked piece has no block
+	FindingPieceNoCutLine      = "piece_no_cut_line"     // blocking: the piece's blocks carry no layer-1 contour
+	FindingSizeNotInFiles      = "size_not_in_files"     // blocking: a graded piece lacks a size of the range
+	FindingBlockUnlinked       = "block_unlinked"        // the block is linked to no piece of the scope
+	FindingUngradedPieceGrades = "ungraded_piece_grades" // a UNI piece differs between sizes in the files
+	FindingDuplicateBlock      = "duplicate_block"       // one (piece, size) drawn twice with different areas
+	FindingSheetNotDXF         = "sheet_not_dxf"         // a PDF sheet: nothing to read, nothing missing
+)
+
+// Finding is one thing the measurement has to say about the files.
+type Finding struct {
+	Code     string
+	Blocking bool
+	// Subject names what the finding is about: a sheet line key, a block, a piece line key.
+	Subject string
+	Detail  string
+}
+
+// MeasuredBlock is one parsed block as the scope sees it.
+type MeasuredBlock struct {
+	SheetLineKey string
+	Graded
+	// PieceLineKey is the card piece the block is linked to; "" when unlinked.
+	PieceLineKey string
+	// SizeId is the card size the block's token resolves to; 0 when ungraded or not in the range.
+	SizeId int
+}
+
+// Measurement is the server's reading of one fabric scope.
+type Measurement struct {
+	// SheetLineKeys is every sheet of the scope — the set PutTechCardPatternSizeIndex and
+	// SaveTechCardPieceAreas check membership against. PDF sheets are in it: the scope holds them.
+	SheetLineKeys []string
+	Blocks        []MeasuredBlock
+	// Rows is the area set in the shape SaveTechCardPieceAreas takes; meaningful only when Complete.
+	Rows []entity.PieceAreaInput
+	// RowBlocks[i] is the index in Blocks of the block Rows[i] was measured from — the contour a
+	// nesting run lays for that row.
+	RowBlocks []int
+	// SizeTokens is the scope's size index; meaningful only when every DXF sheet was read.
+	SizeTokens []string
+	Findings   []Finding
+}
+
+// Complete reports whether Rows is a whole set the store may be asked to write.
+func (m Measurement) Complete() bool {
+	if len(m.Rows) == 0 {
+		return false
+	}
+	for _, f := range m.Findings {
+		if f.Blocking {
+			return false
+		}
+	}
+	return true
+}
+
+// AllSheetsRead reports whether every DXF sheet of the scope was parsed, i.e. whether SizeTokens is
+// the scope's answer rather than part of it.
+func (m Measurement) AllSheetsRead() bool {
+	for _, f := range m.Findings {
+		if f.Code == FindingSheetUnreadable {
+			return false
+		}
+	}
+	return true
+}
+
+// Measure turns a scope's parsed files into its area rows and size tokens.
+//
+// patterns is keyed by sheet line key; a DXF sheet missing from it (or nil) was not readable, and
+// unreadable carries the reason. sizeNames resolves the card's size ids to dictionary names, the
+// same resolution PutTechCardPatternSizeIndex does through entity.SizeCoveredByTokens.
+//
+// Blocks find their piece through the scope's block links, by the whole block name first and by
+// the stem second — a link saved for «FRONT» covers «FRONT-S» and «FRONT-M», which is how the
+// client links a graded piece once rather than once per size.
+func Measure(src entity.PatternScopeSource, patterns map[string]*Pattern, unreadable map[string]string, sizeNames map[int]string) Measurement {
+	m := Measurement{}
+	var pieces []Piece
+	var sheetOf []string
+	for _, sh := range src.Sheets {
+		m.SheetLineKeys = append(m.SheetLineKeys, sh.LineKey)
+		if !sh.IsDXF() {
+			m.Findings = append(m.Findings, Finding{Code: FindingSheetNotDXF, Subject: sh.LineKey,
+				Detail: fmt.Sprintf("%s is not a DXF — it is part of the scope and carries no geometry", sheetLabel(sh))})
+			continue
+		}
+		p := patterns[sh.LineKey]
+		if p == nil {
+			reason := unreadable[sh.LineKey]
+			if reason == "" {
+				reason = "not read"
+			}
+			m.Findings = append(m.Findings, Finding{Code: FindingSheetUnreadable, Blocking: true, Subject: sh.LineKey,
+				Detail: fmt.Sprintf("%s: %s", sheetLabel(sh), reason)})
+			continue
+		}
+		for _, pc := range p.Pieces {
+			pieces = append(pieces, pc)
+			sheetOf = append(sheetOf, sh.LineKey)
+		}
+	}
+	graded := GradeSizes(pieces)
+	if m.AllSheetsRead() {
+		m.SizeTokens = SizeTokens(graded)
+	}
+
+	links := make(map[string]string, len(src.Blocks))
+	for _, b := range src.Blocks {
+		links[blockKey(b.BlockName)] = strings.ToUpper(strings.TrimSpace(b.PieceLineKey))
+	}
+	sizeTokens := make(map[int][]string, len(src.SizeIds))
+	for _, id := range src.SizeIds {
+		sizeTokens[id] = entity.SizeTokensOf(sizeNames[id])
+	}
+
+	byPiece := map[string][]int{}
+	for i, g := range graded {
+		mb := MeasuredBlock{SheetLineKey: sheetOf[i], Graded: g}
+		mb.PieceLineKey = links[blockKey(g.Piece.Block)]
+		if mb.PieceLineKey == "" {
+			mb.PieceLineKey = links[blockKey(g.Stem)]
+		}
+		if g.SizeToken != "" {
+			for _, id := range src.SizeIds {
+				if containsToken(sizeTokens[id], g.SizeToken) {
+					mb.SizeId = id
+					break
+				}
+			}
+		}
+		m.Blocks = append(m.Blocks, mb)
+		if mb.PieceLineKey == "" {
+			m.Findings = append(m.Findings, Finding{Code: FindingBlockUnlinked, Subject: g.Piece.Block,
+				Detail: "the block is linked to no cut piece of this fabric — it is not measured"})
+			continue
+		}
+		byPiece[mb.PieceLineKey] = append(byPiece[mb.PieceLineKey], len(m.Blocks)-1)
+	}
+
+	expected := map[string]bool{}
+	for _, b := range src.Blocks {
+		if k := strings.ToUpper(strings.TrimSpace(b.PieceLineKey)); k != "" {
+			expected[k] = true
+		}
+	}
+	names := make(map[string]string, len(src.Pieces))
+	ungraded := map[string]bool{}
+	for _, p := range src.Pieces {
+		k := strings.ToUpper(strings.TrimSpace(p.LineKey))
+		names[k] = p.Name
+		ungraded[k] = p.Ungraded
+	}
+	for _, key := range sortedKeys(expected) {
+		label := pieceLabel(key, names)
+		var measurable []int
+		for _, i := range byPiece[key] {
+			if m.Blocks[i].Piece.Measurable() {
+				measurable = append(measurable, i)
+			}
+		}
+		if len(byPiece[key]) == 0 {
+			m.Findings = append(m.Findings, Finding{Code: FindingPieceNotInFiles, Blocking: true, Subject: key,
+				Detail: fmt.Sprintf("%s: none of its linked blocks is in the scope's files", label)})
+			continue
+		}
+		if len(measurable) == 0 {
+			m.Findings = append(m.Findings, Finding{Code: FindingPieceNoCutLine, Blocking: true, Subject: key,
+				Detail: fmt.Sprintf("%s: its blocks carry no closed cut line on layer %s", label, LayerBoundary)})
+			continue
+		}
+		gradedInFiles := false
+		for _, i := range measurable {
+			if m.Blocks[i].SizeToken != "" {
+				gradedInFiles = true
+			}
+		}
+		if ungraded[key] || !gradedInFiles {
+			i, spread := largestBlock(m.Blocks, measurable)
+			switch {
+			case spread && ungraded[key]:
+				m.Findings = append(m.Findings, Finding{Code: FindingUngradedPieceGrades, Subject: key,
+					Detail: fmt.Sprintf("%s is marked ungraded but its blocks differ between sizes — the largest is stored", label)})
+			case spread:
+				m.Findings = append(m.Findings, Finding{Code: FindingDuplicateBlock, Subject: key,
+					Detail: fmt.Sprintf("%s: drawn more than once with different areas — the largest is stored", label)})
+			}
+			m.Rows = append(m.Rows, areaRow(key, sql.NullInt64{}, m.Blocks[i].Piece, false))
+			m.RowBlocks = append(m.RowBlocks, i)
+			continue
+		}
+		for _, id := range src.SizeIds {
+			var hits []int
+			for _, i := range measurable {
+				if m.Blocks[i].SizeId == id {
+					hits = append(hits, i)
+				}
+			}
+			if len(hits) == 0 {
+				m.Findings = append(m.Findings, Finding{Code: FindingSizeNotInFiles, Blocking: true, Subject: key,
+					Detail: fmt.Sprintf("%s: no block for size %s", label, sizeLabel(id, sizeNames))})
+				continue
+			}
+			i, spread := largestBlock(m.Blocks, hits)
+			if spread {
+				m.Findings = append(m.Findings, Finding{Code: FindingDuplicateBlock, Subject: key,
+					Detail: fmt.Sprintf("%s, size %s: drawn more than once with different areas — the largest is stored", label, sizeLabel(id, sizeNames))})
+			}
+			m.Rows = append(m.Rows, areaRow(key, sql.NullInt64{Int64: int64(id), Valid: true}, m.Blocks[i].Piece, spread))
+			m.RowBlocks = append(m.RowBlocks, i)
+		}
+	}
+	return m
+}
+
+// areaRow builds one stored row, rounded to the column scale — the same rounding
+// dto.PieceAreaWriteFromPb applies, so a re-measurement of unchanged files is the store's no-op.
+func areaRow(pieceKey string, sizeID sql.NullInt64, p Piece, ambiguous bool) entity.PieceAreaInput {
+	row := entity.PieceAreaInput{
+		PieceLineKey:    pieceKey,
+		SizeId:          sizeID,
+		AreaCm2:         decimal.NewFromFloat(p.AreaCm2).RoundBank(2),
+		ContourLayer:    ContourLayerCut,
+		SeamAllowanceMm: decimal.Zero,
+		AmbiguousPick:   ambiguous || p.AmbiguousBoundary,
+	}
+	if per := decimal.NewFromFloat(p.PerimeterCm).RoundBank(2); per.IsPositive() {
+		row.PerimeterCm = decimal.NullDecimal{Decimal: per, Valid: true}
+	}
+	return row
+}
+
+// largestBlock picks the block of the largest area among idx and says whether they disagree.
+func largestBlock(blocks []MeasuredBlock, idx []int) (int, bool) {
+	best := idx[0]
+	spread := false
+	for _, i := range idx[1:] {
+		a, b := blocks[i].Piece.AreaCm2, blocks[best].Piece.AreaCm2
+		if math.Abs(a-b) >= 0.005 {
+			spread = true
+		}
+		if a > b {
+			best = i
+		}
+	}
+	return best, spread
+}
+
+func blockKey(name string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(name), " "))
+}
+
+func containsToken(tokens []string, t string) bool {
+	for _, x := range tokens {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+func sheetLabel(sh entity.PatternScopeSheet) string {
+	if sh.Filename != "" {
+		return fmt.Sprintf("sheet %q", sh.Filename)
+	}
+	return "sheet " + sh.LineKey
+}
+
+func pieceLabel(key string, names map[string]string) string {
+	if n := strings.TrimSpace(names[key]); n != "" {
+		return fmt.Sprintf("piece %q", n)
+	}
+	return "piece " + key
+}
+
+func sizeLabel(id int, names map[int]string) string {
+	if n := names[id]; n != "" {
+		return n
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// Drift kinds.
+const (
+	DriftAreasNotStored      = "areas_not_stored"      // the scope has no stored areas
+	DriftAreasStale          = "areas_stale"           // stored areas predate the current sheets or links
+	DriftConditionsDiffer    = "conditions_differ"     // stored rows were measured on another layer / allowance
+	DriftAreaDiffers         = "area_differs"          // stored area and file area disagree
+	DriftPerimeterDiffers    = "perimeter_differs"     // stored perimeter and file perimeter disagree
+	DriftRowNotInFiles       = "row_not_in_files"      // a stored (piece, size) the files do not have
+	DriftRowNotStored        = "row_not_stored"        // a (piece, size) the files have and the store does not
+	DriftSizeIndexNotStored  = "size_index_not_stored" // the scope has no readable size index
+	DriftSizeIndexStale      = "size_index_stale"      // the index predates the current sheets
+	DriftSizeTokenNotStored  = "size_token_not_stored" // the files grade a size the index does not list
+	DriftSizeTokenNotInFiles = "size_token_not_in_files"
+)
+
+// Drift tolerances. Below them two measurements of one contour are the same number: the stored one
+// may come from the client's own polygon flattening, and a difference of a few mm² is not a finding.
+var (
+	driftAbsCm2  = decimal.NewFromFloat(0.5)
+	driftRelArea = decimal.NewFromFloat(0.005)
+	driftAbsCm   = decimal.NewFromFloat(0.2)
+)
+
+// DriftItem is one disagreement between what is stored and what the files say.
+type DriftItem struct {
+	Kind         string
+	PieceLineKey string
+	SizeId       int
+	Stored       string
+	Parsed       string
+	Detail       string
+}
+
+// Drift compares a scope's stored areas and size index against the server's reading of its files.
+// stored and index are nil when the scope has none; currentSheetFingerprint is
+// entity.PatternSheetFingerprint of the scope's sheets today.
+//
+// Area rows are compared only when the measurement is complete and token sets only when every sheet
+// was read — a partial reading disagrees with everything and says nothing.
+func Drift(m Measurement, stored *entity.PieceAreaScope, index *entity.PatternSizeIndexRow, currentSheetFingerprint string) []DriftItem {
+	var out []DriftItem
+	if m.Complete() {
+		out = append(out, areaDrift(m.Rows, stored)...)
+	}
+	if !m.AllSheetsRead() {
+		return out
+	}
+	state, tokens := entity.PatternSizeIndexStatus(index, currentSheetFingerprint)
+	switch state {
+	case entity.PatternSizeIndexMissing:
+		return append(out, DriftItem{Kind: DriftSizeIndexNotStored,
+			Parsed: strings.Join(m.SizeTokens, ", "), Detail: "nobody has stored the sizes of these files"})
+	case entity.PatternSizeIndexStale:
+		out = append(out, DriftItem{Kind: DriftSizeIndexStale,
+			Detail: "the stored sizes were read from sheets that have changed since"})
+		tokens, _ = index.Tokens()
+	}
+	parsed := make(map[string]bool, len(m.SizeTokens))
+	for _, t := range m.SizeTokens {
+		parsed[t] = true
+		if !tokens[t] {
+			out = append(out, DriftItem{Kind: DriftSizeTokenNotStored, Parsed: t,
+				Detail: fmt.Sprintf("the files grade size %q and the stored index does not list it", t)})
+		}
+	}
+	for _, t := range sortedKeys(tokens) {
+		if !parsed[t] {
+			out = append(out, DriftItem{Kind: DriftSizeTokenNotInFiles, Stored: t,
+				Detail: fmt.Sprintf("the stored index lists size %q and the files do not grade it", t)})
+		}
+	}
+	return out
+}
+
+func areaDrift(rows []entity.PieceAreaInput, stored *entity.PieceAreaScope) []DriftItem {
+	if stored == nil || len(stored.Rows) == 0 {
+		return []DriftItem{{Kind: DriftAreasNotStored, Detail: "nobody has stored the areas of this fabric"}}
+	}
+	var out []DriftItem
+	if stored.Stale {
+		out = append(out, DriftItem{Kind: DriftAreasStale,
+			Detail: "the stored areas were measured from sheets or block links that have changed since"})
+	}
+	type key struct {
+		piece string
+		size  int64
+	}
+	parsed := make(map[key]entity.PieceAreaInput, len(rows))
+	for _, r := range rows {
+		parsed[key{r.PieceLineKey, r.SizeId.Int64}] = r
+	}
+	conditions := false
+	seen := make(map[key]bool, len(stored.Rows))
+	for _, s := range stored.Rows {
+		k := key{strings.ToUpper(strings.TrimSpace(s.PieceLineKey)), s.SizeId.Int64}
+		seen[k] = true
+		if s.ContourLayer != ContourLayerCut || !s.SeamAllowanceMm.IsZero() {
+			conditions = true
+		}
+		r, ok := parsed[k]
+		if !ok {
+			out = append(out, DriftItem{Kind: DriftRowNotInFiles, PieceLineKey: k.piece, SizeId: int(k.size),
+				Stored: s.AreaCm2.String(), Detail: "stored, but the files have no such piece at this size"})
+			continue
+		}
+		if diff := r.AreaCm2.Sub(s.AreaCm2).Abs(); diff.GreaterThan(decimal.Max(driftAbsCm2, s.AreaCm2.Mul(driftRelArea))) {
+			out = append(out, DriftItem{Kind: DriftAreaDiffers, PieceLineKey: k.piece, SizeId: int(k.size),
+				Stored: s.AreaCm2.String(), Parsed: r.AreaCm2.String(),
+				Detail: fmt.Sprintf("area differs by %s cm²", diff.StringFixed(2))})
+		}
+		if s.PerimeterCm.Valid && r.PerimeterCm.Valid {
+			if diff := r.PerimeterCm.Decimal.Sub(s.PerimeterCm.Decimal).Abs(); diff.GreaterThan(driftAbsCm) {
+				out = append(out, DriftItem{Kind: DriftPerimeterDiffers, PieceLineKey: k.piece, SizeId: int(k.size),
+					Stored: s.PerimeterCm.Decimal.String(), Parsed: r.PerimeterCm.Decimal.String(),
+					Detail: fmt.Sprintf("perimeter differs by %s cm", diff.StringFixed(2))})
+			}
+		}
+	}
+	for _, r := range rows {
+		k := key{r.PieceLineKey, r.SizeId.Int64}
+		if !seen[k] {
+			out = append(out, DriftItem{Kind: DriftRowNotStored, PieceLineKey: k.piece, SizeId: int(k.size),
+				Parsed: r.AreaCm2.String(), Detail: "in the files, but not among the stored areas"})
+		}
+	}
+	if conditions {
+		// Said once and first: every number below it compares a cut line against something else.
+		out = append([]DriftItem{{Kind: DriftConditionsDiffer,
+			Detail: fmt.Sprintf("the stored areas were measured on another layer or with a seam allowance; the server measures the cut line (layer %s)", LayerBoundary)}}, out...)
+	}
+	return out
+}
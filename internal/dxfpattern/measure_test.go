@@ -0,0 +1,217 @@
+package dxfpattern
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+func measuredPiece(block string, area float64) Piece {
+	return Piece{
+		Block:       block,
+		Name:        block,
+		Boundary:    []Point{{0, 0}, {1, 0}, {1, 1}},
+		AreaCm2:     area,
+		PerimeterCm: 40,
+	}
+}
+
+// scopeFixture is one fabric: a graded front and back, an ungraded pocket, and a PDF sheet.
+func scopeFixture() (entity.PatternScopeSource, map[string]*Pattern, map[int]string) {
+	src := entity.PatternScopeSource{
+		TechCardId: 1,
+		ScopeKey:   "shell",
+		Sheets: []entity.PatternScopeSheet{
+			{LineKey: "SH1", URL: "https://cdn/tech-card-patterns/a.dxf", Version: 1, Filename: "a.dxf"},
+			{LineKey: "SH2", URL: "https://cdn/tech-card-patterns/b.pdf", Version: 1, Filename: "b.pdf"},
+		},
+		Blocks: []entity.PieceAreaBlockRef{
+			{BlockName: "FRONT", PieceLineKey: "P1"},
+			{BlockName: "BACK", PieceLineKey: "P2"},
+			{BlockName: "POCKET", PieceLineKey: "P3"},
+		},
+		Pieces: []entity.PatternScopePiece{
+			{LineKey: "P1", Name: "Front"},
+			{LineKey: "P2", Name: "Back"},
+			{LineKey: "P3", Name: "Pocket", Ungraded: true},
+		},
+		SizeIds: []int{1, 2},
+	}
+	patterns := map[string]*Pattern{
+		"SH1": {Unit: UnitCentimetre, Pieces: []Piece{
+			measuredPiece("FRONT-S", 100),
+			measuredPiece("FRONT-M", 120),
+			measuredPiece("BACK-S", 90),
+			measuredPiece("BACK-M", 110),
+			measuredPiece("POCKET", 20.004),
+			measuredPiece("LABEL", 4),
+		}},
+	}
+	return src, patterns, map[int]string{1: "s", 2: "m"}
+}
+
+func findingCodes(m Measurement) map[string]bool {
+	out := map[string]bool{}
+	for _, f := range m.Findings {
+		out[f.Code] = true
+	}
+	return out
+}
+
+func TestMeasureWholeScope(t *testing.T) {
+	src, patterns, names := scopeFixture()
+	m := Measure(src, patterns, nil, names)
+
+	if !m.Complete() {
+		t.Fatalf("measurement not complete: %+v", m.Findings)
+	}
+	if got := m.SheetLineKeys; len(got) != 2 {
+		t.Fatalf("sheet set = %v, want both sheets (the PDF belongs to the scope)", got)
+	}
+	if got := m.SizeTokens; len(got) != 2 || got[0] != "m" || got[1] != "s" {
+		t.Fatalf("size tokens = %v, want [m s]", got)
+	}
+	codes := findingCodes(m)
+	if !codes[FindingSheetNotDXF] || !codes[FindingBlockUnlinked] {
+		t.Fatalf("findings = %+v, want sheet_not_dxf and block_unlinked", m.Findings)
+	}
+
+	type key struct {
+		piece string
+		size  sql.NullInt64
+	}
+	got := map[key]string{}
+	for _, r := range m.Rows {
+		if r.ContourLayer != ContourLayerCut || !r.SeamAllowanceMm.IsZero() {
+			t.Fatalf("row %+v not measured on the cut line", r)
+		}
+		got[key{r.PieceLineKey, r.SizeId}] = r.AreaCm2.String()
+	}
+	want := map[key]string{
+		{"P1", sql.NullInt64{Int64: 1, Valid: true}}: "100",
+		{"P1", sql.NullInt64{Int64: 2, Valid: true}}: "120",
+		{"P2", sql.NullInt64{Int64: 1, Valid: true}}: "90",
+		{"P2", sql.NullInt64{Int64: 2, Valid: true}}: "110",
+		{"P3", sql.NullInt64{}}:                      "20",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("row %v = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestMeasureBlocksIncompleteReadings(t *testing.T) {
+	t.Run("size of the range missing", func(t *testing.T) {
+		src, patterns, names := scopeFixture()
+		src.SizeIds = append(src.SizeIds, 3)
+		names[3] = "l"
+		m := Measure(src, patterns, nil, names)
+		if m.Complete() || !findingCodes(m)[FindingSizeNotInFiles] {
+			t.Fatalf("complete=%v findings=%+v, want a blocking size_not_in_files", m.Complete(), m.Findings)
+		}
+		if !m.AllSheetsRead() {
+			t.Fatal("a missing size must not withhold the size index")
+		}
+	})
+	t.Run("linked piece not in the files", func(t *testing.T) {
+		src, patterns, names := scopeFixture()
+		src.Blocks = append(src.Blocks, entity.PieceAreaBlockRef{BlockName: "COLLAR", PieceLineKey: "P4"})
+		m := Measure(src, patterns, nil, names)
+		if m.Complete() || !findingCodes(m)[FindingPieceNotInFiles] {
+			t.Fatalf("findings = %+v, want a blocking piece_not_in_files", m.Findings)
+		}
+	})
+	t.Run("unreadable sheet", func(t *testing.T) {
+		src, _, names := scopeFixture()
+		m := Measure(src, nil, map[string]string{"SH1": "binary"}, names)
+		if m.Complete() || m.AllSheetsRead() || m.SizeTokens != nil {
+			t.Fatalf("complete=%v allRead=%v tokens=%v, want nothing writable", m.Complete(), m.AllSheetsRead(), m.SizeTokens)
+		}
+	})
+	t.Run("ungraded piece that grades", func(t *testing.T) {
+		src, patterns, names := scopeFixture()
+		src.Blocks = append(src.Blocks, entity.PieceAreaBlockRef{BlockName: "CUFF", PieceLineKey: "P5"})
+		src.Pieces = append(src.Pieces, entity.PatternScopePiece{LineKey: "P5", Name: "Cuff", Ungraded: true})
+		patterns["SH1"].Pieces = append(patterns["SH1"].Pieces, measuredPiece("CUFF-S", 10), measuredPiece("CUFF-M", 12))
+		m := Measure(src, patterns, nil, names)
+		if !m.Complete() || !findingCodes(m)[FindingUngradedPieceGrades] {
+			t.Fatalf("complete=%v findings=%+v, want a non-blocking ungraded_piece_grades", m.Complete(), m.Findings)
+		}
+		for _, r := range m.Rows {
+			if r.PieceLineKey == "P5" && (r.SizeId.Valid || r.AreaCm2.String() != "12") {
+				t.Fatalf("cuff row = %+v, want one sizeless row of the largest block", r)
+			}
+		}
+	})
+}
+
+func TestDrift(t *testing.T) {
+	src, patterns, names := scopeFixture()
+	m := Measure(src, patterns, nil, names)
+	refs := make([]entity.PatternSheetRef, 0, len(src.Sheets))
+	for _, sh := range src.Sheets {
+		refs = append(refs, sh.Ref())
+	}
+	fp := entity.PatternSheetFingerprint(refs)
+
+	kinds := func(items []DriftItem) map[string]int {
+		out := map[string]int{}
+		for _, d := range items {
+			out[d.Kind]++
+		}
+		return out
+	}
+
+	if got := kinds(Drift(m, nil, nil, fp)); got[DriftAreasNotStored] != 1 || got[DriftSizeIndexNotStored] != 1 {
+		t.Fatalf("nothing stored: drift = %v", got)
+	}
+
+	row := func(piece string, size int64, area string) entity.PieceAreaRow {
+		r := entity.PieceAreaRow{
+			PieceLineKey:    piece,
+			AreaCm2:         decimal.RequireFromString(area),
+			ContourLayer:    ContourLayerCut,
+			SeamAllowanceMm: decimal.Zero,
+		}
+		if size > 0 {
+			r.SizeId = sql.NullInt64{Int64: size, Valid: true}
+		}
+		return r
+	}
+	stored := &entity.PieceAreaScope{ScopeKey: "shell", Rows: []entity.PieceAreaRow{
+		row("P1", 1, "100.3"), // within tolerance
+		row("P1", 2, "125"),   // differs
+		row("P2", 1, "90"),
+		row("P2", 2, "110"),
+		row("P9", 0, "5"), // not in the files; P3 not stored
+	}}
+	index := &entity.PatternSizeIndexRow{SheetFingerprint: fp, SizeTokensJSON: `["s","xl"]`}
+	got := kinds(Drift(m, stored, index, fp))
+	want := map[string]int{
+		DriftAreaDiffers:         1,
+		DriftRowNotInFiles:       1,
+		DriftRowNotStored:        1,
+		DriftSizeTokenNotStored:  1,
+		DriftSizeTokenNotInFiles: 1,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("drift = %v, want %v", got, want)
+	}
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("drift %s = %d, want %d", k, got[k], n)
+		}
+	}
+
+	stored.Rows[0].ContourLayer = "14"
+	items := Drift(m, stored, index, "other")
+	if items[0].Kind != DriftConditionsDiffer || kinds(items)[DriftSizeIndexStale] != 1 {
+		t.Fatalf("drift = %+v, want conditions_differ first and size_index_stale", items)
+	}
+}
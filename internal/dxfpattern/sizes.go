@@ -0,0 +1,144 @@
+package dxfpattern
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// maxSizeTokenLen bounds what a block-name tail may be to count as a size. Real size codes are
+// «XS», «3XL», «44», «128», «<M>»; a longer tail is a word («BACK», «FACING») and not a grade.
+const maxSizeTokenLen = 5
+
+// sizeSeparators split a block name's size tail from its stem: «FRONT-M», «FRONT_M», «FRONT M»,
+// «FRONT#M», «BP_<S>».
+const sizeSeparators = "-_ #."
+
+// Graded is one piece of a scope with its size resolved: which cut piece it is (Stem) and which size
+// it was graded to (SizeToken, normalised; "" = the piece does not grade in these files).
+type Graded struct {
+	Piece Piece
+	// Stem is the piece identity across sizes: the block name without its size tail, or the block
+	// name whole when no tail was taken as a size.
+	Stem      string
+	SizeToken string
+}
+
+// GradeSizes resolves the size of every piece of a scope's files at once.
+//
+// AN EXPLICIT «Size:» TEXT WINS. AAMA graded files label each size's block, and a label is a
+// statement of the file, not an inference.
+//
+// WITHOUT ONE, THE SIZE IS READ OFF THE BLOCK NAMES, and only as a whole-set judgement: a tail is a
+// size when it is a TAIL ON AT LEAST TWO SIZES OF ONE STEM («FRONT-S», «FRONT-M»). One file of
+// ungraded pieces whose names happen to end in short words («CUFF-L» for the left cuff and nothing
+// else) must not read as «size L». Across several graded stems a tail must also recur on at least
+// max(2, most-frequent/2) of them, so a stray «FRONT-A»/«FRONT-B» variant pair in a pack of
+// ten graded pieces does not add sizes A and B to the card. With a single graded stem the floor
+// is that stem itself.
+//
+// The set is the WHOLE SCOPE (all its sheets), not one file: a pack exported one size per file
+// carries the grade across files, and judging each file alone would read every size as ungraded.
+func GradeSizes(pieces []Piece) []Graded {
+	out := make([]Graded, len(pieces))
+	type split struct {
+		stem, tail string
+	}
+	splits := make([]split, len(pieces))
+	tailsByStem := map[string]map[string]bool{}
+	for i, p := range pieces {
+		out[i] = Graded{Piece: p, Stem: strings.TrimSpace(p.Block)}
+		if tok := entity.NormalizeSizeToken(p.Size); tok != "" {
+			out[i].SizeToken = tok
+			out[i].Stem = stemOf(p)
+			continue
+		}
+		stem, tail, ok := splitSizeTail(p.Block)
+		if !ok {
+			continue
+		}
+		splits[i] = split{stem: stem, tail: tail}
+		key := strings.ToUpper(stem)
+		if tailsByStem[key] == nil {
+			tailsByStem[key] = map[string]bool{}
+		}
+		tailsByStem[key][tail] = true
+	}
+
+	freq := map[string]int{}
+	graded := 0
+	for _, tails := range tailsByStem {
+		if len(tails) < 2 {
+			continue
+		}
+		graded++
+		for t := range tails {
+			freq[t]++
+		}
+	}
+	maxFreq := 0
+	for _, n := range freq {
+		maxFreq = max(maxFreq, n)
+	}
+	floor := max(2, maxFreq/2)
+	if graded == 1 {
+		floor = 1
+	}
+	for i, s := range splits {
+		if s.tail == "" || len(tailsByStem[strings.ToUpper(s.stem)]) < 2 || freq[s.tail] < floor {
+			continue
+		}
+		out[i].Stem = s.stem
+		out[i].SizeToken = s.tail
+	}
+	return out
+}
+
+// SizeTokens returns the scope's size tokens — normalised, de-duplicated and sorted, the shape
+// PutTechCardPatternSizeIndex stores.
+func SizeTokens(graded []Graded) []string {
+	tokens := make([]string, 0, len(graded))
+	for _, g := range graded {
+		if g.SizeToken != "" {
+			tokens = append(tokens, g.SizeToken)
+		}
+	}
+	return entity.NormalizeSizeTokens(tokens)
+}
+
+// stemOf names a size-labelled piece across sizes: the block name without the labelled size when it
+// ends in it, else the block name whole.
+func stemOf(p Piece) string {
+	if stem, tail, ok := splitSizeTail(p.Block); ok && tail == entity.NormalizeSizeToken(p.Size) {
+		return stem
+	}
+	return strings.TrimSpace(p.Block)
+}
+
+// splitSizeTail cuts a block name at its last separator. The tail comes back normalised; ok=false
+// when there is no separator, the stem is empty, or the tail is too long to be a size.
+func splitSizeTail(name string) (string, string, bool) {
+	name = strings.TrimSpace(name)
+	// «BP_<S>»: the decoration is not a separator, it belongs to the tail.
+	i := strings.LastIndexAny(strings.TrimRight(name, "<>[]()"), sizeSeparators)
+	if i <= 0 {
+		return "", "", false
+	}
+	stem := strings.TrimSpace(strings.TrimRight(name[:i], sizeSeparators))
+	tail := entity.NormalizeSizeToken(name[i+1:])
+	if stem == "" || tail == "" || len(tail) > maxSizeTokenLen {
+		return "", "", false
+	}
+	return stem, tail, true
+}
+
+// sortedKeys is a small helper for deterministic output.
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
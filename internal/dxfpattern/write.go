@@ -0,0 +1,137 @@
+package dxfpattern
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SizeFile is one size's graded pieces as an AAMA DXF.
+type SizeFile struct {
+	SizeId int
+	// Size is the size as the file labels it («M»).
+	Size string
+	DXF  []byte
+}
+
+// SizeFiles writes the grading out one file per size of the range, in range order. A piece marked
+// ungraded is drawn in every file — it is cut for every size. Nothing is written for a grading that
+// is not Complete: a file set with a piece missing is the partial set the area write refuses.
+func (g Grading) SizeFiles(sizeIDs []int, sizeNames map[int]string) []SizeFile {
+	if !g.Complete() {
+		return nil
+	}
+	out := make([]SizeFile, 0, len(sizeIDs))
+	for _, id := range sizeIDs {
+		code := sizeCode(id, sizeNames)
+		var pieces []Piece
+		for _, gp := range g.Pieces {
+			if gp.SizeId != 0 && gp.SizeId != id {
+				continue
+			}
+			p := gp.Piece
+			p.Block = gp.Stem
+			if gp.SizeId != 0 {
+				p.Block = gp.Stem + "-" + code
+				p.Size = code
+			}
+			pieces = append(pieces, p)
+		}
+		out = append(out, SizeFile{SizeId: id, Size: code, DXF: Write(pieces, sizeCode(g.BaseSizeId, sizeNames))})
+	}
+	return out
+}
+
+// Write renders pieces as an ASCII AAMA DXF in millimetres: one BLOCK per piece with its «Piece
+// Name:», «Size:» and «Quantity:» texts, the cut line on layer 1, the grain line on layer 7 and the
+// notches on layer 4, each block inserted once in ENTITIES. Units are declared twice — $INSUNITS and
+// the AAMA «Units: METRIC» text — so no reader, this package's included, has to assume them.
+func Write(pieces []Piece, sampleSize string) []byte {
+	var b strings.Builder
+	pair := func(code int, value string) {
+		b.WriteString(strconv.Itoa(code))
+		b.WriteByte('\n')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	num := func(code int, cm float64) {
+		pair(code, strconv.FormatFloat(cm*10, 'f', 3, 64))
+	}
+	text := func(s string) {
+		pair(0, "TEXT")
+		pair(8, LayerBoundary)
+		pair(10, "0")
+		pair(20, "0")
+		pair(40, "2.5")
+		pair(1, s)
+	}
+
+	pair(999, "grbpwr-manager pattern grading")
+	pair(0, "SECTION")
+	pair(2, "HEADER")
+	pair(9, "$INSUNITS")
+	pair(70, "4")
+	pair(9, "$MEASUREMENT")
+	pair(70, "1")
+	pair(0, "ENDSEC")
+
+	pair(0, "SECTION")
+	pair(2, "BLOCKS")
+	for _, p := range pieces {
+		pair(0, "BLOCK")
+		pair(8, "0")
+		pair(2, p.Block)
+		pair(70, "0")
+		pair(10, "0")
+		pair(20, "0")
+		text("Piece Name: " + p.Name)
+		if p.Size != "" {
+			text("Size: " + p.Size)
+		}
+		if p.Quantity > 0 {
+			text(fmt.Sprintf("Quantity: %d", p.Quantity))
+		}
+		pair(0, "LWPOLYLINE")
+		pair(8, LayerBoundary)
+		pair(90, strconv.Itoa(len(p.Boundary)))
+		pair(70, "1")
+		for _, pt := range p.Boundary {
+			num(10, pt.X)
+			num(20, pt.Y)
+		}
+		if p.GrainLine != nil {
+			pair(0, "LINE")
+			pair(8, LayerGrain)
+			num(10, p.GrainLine.From.X)
+			num(20, p.GrainLine.From.Y)
+			num(11, p.GrainLine.To.X)
+			num(21, p.GrainLine.To.Y)
+		}
+		for _, n := range p.Notches {
+			pair(0, "POINT")
+			pair(8, LayerNotch)
+			num(10, n.X)
+			num(20, n.Y)
+		}
+		pair(0, "ENDBLK")
+		pair(8, "0")
+	}
+	pair(0, "ENDSEC")
+
+	pair(0, "SECTION")
+	pair(2, "ENTITIES")
+	for _, p := range pieces {
+		pair(0, "INSERT")
+		pair(8, "0")
+		pair(2, p.Block)
+		pair(10, "0")
+		pair(20, "0")
+	}
+	text("Units: METRIC")
+	if sampleSize != "" {
+		text("Sample Size: " + sampleSize)
+	}
+	pair(0, "ENDSEC")
+	pair(0, "EOF")
+	return []byte(b.String())
+}
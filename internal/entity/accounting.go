@@ -108,6 +108,11 @@ const (
 	// reposts (reverse + a versioned source_key), like opex_month.
 	AcctSourceShippingActual AcctSourceType = "shipping_actual"
 	AcctSourceDevExpense     AcctSourceType = "dev_expense"
+	// AcctSourcePayroll posts an approved piece-rate payslip (migration 0341): Dr 6330 Salaries /
+	// Cr 2030 for the payslip's gross in base currency at month end. It replaces the flat salary
+	// opex_month line for that employee-month and reposts like dev_expense when a payslip is reopened
+	// and approved with a different amount.
+	AcctSourcePayroll AcctSourceType = "payroll"
 	// AcctSourceOrderDispute is a Stripe chargeback (phase 2, wave 4 — migration 0198). A created
 	// dispute posts Dr 4040 (disputed amount) + Dr 6050 (dispute fee) / Cr 1030 (money pulled from
 	// Stripe); a closed-won dispute is reversed. COGS is untouched (the goods were not returned). See
@@ -223,6 +228,7 @@ var ValidAcctSourceTypes = map[AcctSourceType]bool{
 	AcctSourceOpexMonth:                 true,
 	AcctSourceShippingActual:            true,
 	AcctSourceDevExpense:                true,
+	AcctSourcePayroll:                   true,
 	AcctSourceDepreciation:              true,
 	AcctSourceCorpTax:                   true,
 	AcctSourceOrderDispute:              true,
@@ -427,6 +433,10 @@ type AcctOrderRefundPayload struct {
 	// books NO Dr 1130 / Cr 5050 pair (the goods are consumed, or live as zero-cost B stock).
 	// Pre-Phase-8 payloads have no field → restock semantics, byte-compatible.
 	Disposition string `json:"disposition,omitempty"`
+	// StoreCreditAmount is the part of RefundAmount paid back as store credit rather than money
+	// (order currency): the credit share of an order partly paid with credit, or the whole refund
+	// when the admin chose a refund to store credit. S2 credits 2100 for it. Absent → all money.
+	StoreCreditAmount decimal.Decimal `json:"store_credit_amount"`
 }
 
 // AcctOrderShippedPayload / AcctOrderDeliveredPayload are the outbox payloads for the wave-2
@@ -523,6 +533,9 @@ type AcctOrderItemFact struct {
 	ProductId int                 `db:"product_id"`
 	Quantity  decimal.Decimal     `db:"quantity"`
 	UnitCost  decimal.NullDecimal `db:"unit_cost"`
+	// GiftCard marks a gift-card line: its price is a store-credit liability, not a sale, so it
+	// carries no COGS.
+	GiftCard bool `db:"gift_card"`
 }
 
 // AcctOrderFacts is the flat fact set for posting an order sale (S1) or refund (S2), assembled by
@@ -565,7 +578,14 @@ type AcctOrderFacts struct {
 	// analytics only: the P&L total is unchanged and the split is applied only when it reconstructs
 	// cleanly (07 §7.4.11).
 	PromoDiscountPct decimal.NullDecimal `db:"promo_discount_pct"`
-	Items            []AcctOrderItemFact `db:"-"`
+	// StoreCreditApplied is the part of TotalPrice paid with a gift card or store credit (order
+	// currency). The settlement covers only the rest, so the builders gross the settled amount up by
+	// it and debit 2100 for the credit share.
+	StoreCreditApplied decimal.Decimal `db:"store_credit_applied"`
+	// GiftCardTotal is the order-currency value of the gift-card lines sold on the order: credited to
+	// the 2100 liability instead of revenue and kept out of the VAT base.
+	GiftCardTotal decimal.Decimal     `db:"gift_card_total"`
+	Items         []AcctOrderItemFact `db:"-"`
 }
 
 // AcctShipmentCostFacts is one shipment's actual carrier cost, the fact set for the wave-3 shipping_actual
@@ -596,6 +616,17 @@ type AcctDevExpenseFacts struct {
 	CreatedAt    time.Time           `db:"created_at"`
 }
 
+// AcctPayslipFacts is one approved payroll_payslip, the fact set for the payroll pull (0341). GrossBase
+// is the gross folded to base currency at approval; Month is the 1st of the paid month.
+type AcctPayslipFacts struct {
+	Id           int                 `db:"id"`
+	EmployeeId   int                 `db:"employee_id"`
+	EmployeeName string              `db:"employee_name"`
+	Month        time.Time           `db:"month"`
+	Scheme       string              `db:"scheme"`
+	GrossBase    decimal.NullDecimal `db:"gross_base"`
+}
+
 // AcctMovementFacts is one material_stock_movement joined with its material name — the fact set for
 // the M1–M8 material-movement rules (03 §3.3, 04). UnitCostBase NULL where money is expected means
 // the movement is uncosted: no entry is posted and it surfaces in the reconciliation report.
@@ -1119,6 +1150,53 @@ type AcctBankTxnInsert struct {
 	State            AcctBankTxnState
 	SuggestedAccount sql.NullString
 	Raw              string
+	// Match is the open item auto-matching linked the line to at import (state matched); nil when
+	// it fell through to the substring rules.
+	Match *AcctBankMatch
+}
+
+// AcctBankMatchKind is what an auto-matched inbox line settles (acct_bank_txn.match_kind, 0338).
+type AcctBankMatchKind string
+
+const (
+	// AcctBankMatchReceivable is a payment of an open 1040 receivable; Ref is the order uuid.
+	AcctBankMatchReceivable AcctBankMatchKind = "receivable"
+	// AcctBankMatchPayable is a payment of a supplier's open 2010 payable; Ref is the supplier id.
+	AcctBankMatchPayable AcctBankMatchKind = "payable"
+	// AcctBankMatchStripePayout is a Stripe payout clearing 1030; Ref is the payout id when the
+	// statement quotes one.
+	AcctBankMatchStripePayout AcctBankMatchKind = "stripe_payout"
+)
+
+// AcctBankMatch links an inbox line to the open item it settles: the counter-account it posts to and,
+// for a payable, the supplier the payment is tagged with.
+type AcctBankMatch struct {
+	Kind       AcctBankMatchKind
+	Ref        string
+	Account    string
+	SupplierId int
+}
+
+// AcctBankCsvMapping is an operator-registered column mapping for a bank's CSV export
+// (acct_bank_csv_mapping, 0338), selected by Source at import. Columns are header names. The amount is
+// either one signed AmountColumn or a DebitColumn/CreditColumn pair; the currency is CurrencyColumn or,
+// for a single-currency account, the fixed Currency.
+type AcctBankCsvMapping struct {
+	Source             string
+	Delimiter          string // one character; "" = ","
+	SkipLines          int    // preamble lines before the header row
+	DateColumn         string
+	DateLayout         string // Go layout, e.g. "02.01.2006"
+	AmountColumn       string
+	DebitColumn        string
+	CreditColumn       string
+	DecimalComma       bool // "1.234,56"
+	CurrencyColumn     string
+	Currency           string
+	DescriptionColumns []string // joined with " / "
+	CounterpartyColumn string
+	IdColumn           string // the bank's transaction id; "" = derived from the line's content
+	UpdatedAt          time.Time
 }
 
 // AcctBankTxn is a stored bank inbox line (acct_bank_txn).
@@ -1136,6 +1214,9 @@ type AcctBankTxn struct {
 	MatchedEntryId   sql.NullInt64       `db:"matched_entry_id"`
 	SuggestedAccount sql.NullString      `db:"suggested_account"`
 	IgnoreReason     sql.NullString      `db:"ignore_reason"` // why the line was deliberately not booked (migration 0202)
+	MatchKind        sql.NullString      `db:"match_kind"`    // AcctBankMatchKind of an auto-matched line (0338)
+	MatchRef         sql.NullString      `db:"match_ref"`
+	SupplierId       sql.NullInt64       `db:"supplier_id"` // the supplier a matched payable payment is tagged with
 	CreatedAt        time.Time           `db:"created_at"`
 }
 
@@ -1157,18 +1238,22 @@ type AcctBankRule struct {
 
 // Supplier is a purchase-side counterparty (supplier table, migration 0197) — the AP catalog (4.4).
 type Supplier struct {
-	Id        int            `db:"id"`
-	Name      string         `db:"name"`
-	VatId     sql.NullString `db:"vat_id"`
-	Notes     sql.NullString `db:"notes"`
-	CreatedAt time.Time      `db:"created_at"`
+	Id    int            `db:"id"`
+	Name  string         `db:"name"`
+	VatId sql.NullString `db:"vat_id"`
+	Notes sql.NullString `db:"notes"`
+	// LeadTimeDays is the supplier's promised order-to-door time (0336), the default for materials
+	// that carry no lead time of their own. NULL = not known.
+	LeadTimeDays sql.NullInt64 `db:"lead_time_days"`
+	CreatedAt    time.Time     `db:"created_at"`
 }
 
-// SupplierInsert is the writable payload of a new supplier.
+// SupplierInsert is the writable payload of a new supplier, or the full replacement of one.
 type SupplierInsert struct {
-	Name  string
-	VatId sql.NullString
-	Notes sql.NullString
+	Name         string
+	VatId        sql.NullString
+	Notes        sql.NullString
+	LeadTimeDays sql.NullInt64
 }
 
 // AcctPayableRow is one supplier's Accounts-Payable (2010) position (GetPayables, 4.4): Accrued is the Σ
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AuditEvent is a row in admin_audit_event: one mutating admin RPC call, successful or not.
+// Request and Response are the redacted protojson of the call's messages; Response is only kept
+// for calls that succeeded.
+type AuditEvent struct {
+	Id           int64          `db:"id"`
+	OccurredAt   time.Time      `db:"occurred_at"`
+	Actor        string         `db:"actor"`
+	Method       string         `db:"method"`
+	Section      string         `db:"section"`
+	TargetType   string         `db:"target_type"`
+	TargetId     sql.NullString `db:"target_id"`
+	StatusCode   string         `db:"status_code"`
+	ErrorMessage sql.NullString `db:"error_message"`
+	DurationMs   int            `db:"duration_ms"`
+	Request      string         `db:"request"`
+	Response     sql.NullString `db:"response"`
+}
+
+// AuditEventFilter filters the admin audit trail. Zero fields do not filter.
+type AuditEventFilter struct {
+	Actor      string
+	Method     string
+	Section    string
+	TargetType string
+	TargetId   string
+	From       sql.NullTime
+	To         sql.NullTime
+	FailedOnly bool
+	// Sections, when non-nil, limits the result to events in these sections (an empty non-nil
+	// slice matches nothing) — a viewer only sees the trail of what they can read.
+	Sections []string
+	Limit    int
+	Offset   int
+}
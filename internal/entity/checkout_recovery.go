@@ -0,0 +1,151 @@
+package entity
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ВОЗВРАТ БРОШЕННОГО ЧЕКАУТА (checkout_recovery, 0352): корзина покупателя, давшего согласие на
+// письма, и серия напоминаний о ней. Когда слать следующее письмо и когда запись отживает —
+// CheckoutRecovery.NextStep / Lapsed; отправляет воркер checkoutrecovery.
+
+// CheckoutRecoverySource is where the abandoned checkout was recorded.
+type CheckoutRecoverySource string
+
+const (
+	// CheckoutRecoverySourceValidate: the cart was validated at checkout with a consented email.
+	CheckoutRecoverySourceValidate CheckoutRecoverySource = "validate"
+	// CheckoutRecoverySourceSubmit: an order was submitted with marketing consent and left unpaid.
+	CheckoutRecoverySourceSubmit CheckoutRecoverySource = "submit"
+)
+
+// CheckoutRecoveryStatus is the state of an abandoned checkout. Only open ones are mailed.
+type CheckoutRecoveryStatus string
+
+const (
+	CheckoutRecoveryOpen CheckoutRecoveryStatus = "open"
+	// CheckoutRecoveryConverted: the customer bought after at least one recovery email.
+	CheckoutRecoveryConverted CheckoutRecoveryStatus = "converted"
+	// CheckoutRecoverySuppressed: the customer bought before the first email; nothing was sent.
+	CheckoutRecoverySuppressed CheckoutRecoveryStatus = "suppressed"
+	// CheckoutRecoveryOptedOut: the customer stopped the sequence or unsubscribed from marketing.
+	CheckoutRecoveryOptedOut CheckoutRecoveryStatus = "opted_out"
+	// CheckoutRecoveryExpired: the sequence ran out (or the cart emptied) without a purchase.
+	CheckoutRecoveryExpired CheckoutRecoveryStatus = "expired"
+)
+
+// CheckoutRecoveryLine is one line of an abandoned cart: a variant (product_size.id) and a quantity.
+// VariantSKU is filled on read.
+type CheckoutRecoveryLine struct {
+	VariantId  int             `db:"variant_id"`
+	VariantSKU string          `db:"variant_sku"`
+	Quantity   decimal.Decimal `db:"quantity"`
+}
+
+// CheckoutRecoveryCart is a checkout to remember: who, in which currency, and the cart.
+type CheckoutRecoveryCart struct {
+	Email     string
+	AccountId int // 0 — a guest
+	OrderId   int // 0 — no order yet (pre-checkout)
+	Currency  string
+	Source    CheckoutRecoverySource
+	Lines     []CheckoutRecoveryLine
+}
+
+// CheckoutRecovery is a recorded abandoned checkout.
+type CheckoutRecovery struct {
+	Id             int                    `db:"id"`
+	Token          string                 `db:"token"`
+	Email          string                 `db:"email"`
+	AccountId      sql.NullInt32          `db:"account_id"`
+	OrderId        sql.NullInt32          `db:"order_id"`
+	Currency       string                 `db:"currency"`
+	Source         CheckoutRecoverySource `db:"source"`
+	Status         CheckoutRecoveryStatus `db:"status"`
+	StepsSent      int                    `db:"steps_sent"`
+	LastActivityAt time.Time              `db:"last_activity_at"`
+	LastSentAt     sql.NullTime           `db:"last_sent_at"`
+	IncentiveCode  sql.NullString         `db:"incentive_code"`
+	IncentiveStep  sql.NullInt32          `db:"incentive_step"` // stored before its email is queued: sent once StepsSent reaches it
+	RestoredAt     sql.NullTime           `db:"restored_at"`
+	CreatedAt      time.Time              `db:"created_at"`
+	// Unsubscribed: the address has opted out of marketing email (subscriber.receive_promo_emails = 0).
+	Unsubscribed bool `db:"unsubscribed"`
+	// PaymentPending: an order of the address is still waiting for its payment; no email goes out
+	// until it is paid or expires.
+	PaymentPending bool                   `db:"payment_pending"`
+	Lines          []CheckoutRecoveryLine `db:"-"`
+}
+
+// NextStep returns the 1-based step due at now: step n goes out delays[n-1] after the last checkout
+// activity, so coming back to the checkout pushes the rest of the sequence out without restarting it.
+// delays must be increasing. False when every step was sent or the next one is not due yet.
+func (r *CheckoutRecovery) NextStep(delays []time.Duration, now time.Time) (int, bool) {
+	if r.StepsSent >= len(delays) {
+		return 0, false
+	}
+	if now.Before(r.LastActivityAt.Add(delays[r.StepsSent])) {
+		return 0, false
+	}
+	return r.StepsSent + 1, true
+}
+
+// Lapsed reports whether the sequence is over: every step was sent and the attribution window after
+// the last one passed, or nothing was sent for the whole sequence plus the window (the worker was off).
+func (r *CheckoutRecovery) Lapsed(delays []time.Duration, window time.Duration, now time.Time) bool {
+	if r.StepsSent >= len(delays) {
+		last := r.LastActivityAt
+		if r.LastSentAt.Valid {
+			last = r.LastSentAt.Time
+		}
+		return !now.Before(last.Add(window))
+	}
+	if len(delays) == 0 {
+		return true
+	}
+	return !now.Before(r.LastActivityAt.Add(delays[len(delays)-1] + window))
+}
+
+// CheckoutRecoveryPurchase is a paid order that closes an open abandoned checkout.
+type CheckoutRecoveryPurchase struct {
+	RecoveryId int `db:"recovery_id"`
+	OrderId    int `db:"order_id"`
+}
+
+// CheckoutRecoveryRestore is what a restore-cart link gives back: the lines still on sale, the
+// currency of the checkout and the incentive code, if one was sent.
+type CheckoutRecoveryRestore struct {
+	Currency      string
+	IncentiveCode string
+	Lines         []CheckoutRecoveryLine
+}
+
+// CheckoutRecoveryStepMetrics is one step of the sequence over the abandoned checkouts recorded in a
+// window. Conversions and revenue are attributed to the last email before the purchase; revenue is
+// the settled base-currency total (orders without one count as conversions only).
+type CheckoutRecoveryStepMetrics struct {
+	Step               int             `db:"step"`
+	Sent               int             `db:"sent"`
+	Restored           int             `db:"restored"`
+	Conversions        int             `db:"conversions"`
+	Revenue            decimal.Decimal `db:"revenue"`
+	IncentivesSent     int             `db:"incentives_sent"`
+	IncentivesRedeemed int             `db:"incentives_redeemed"`
+}
+
+// CheckoutRecoveryMetrics summarises the abandoned checkouts recorded between From and To.
+type CheckoutRecoveryMetrics struct {
+	From       time.Time
+	To         time.Time
+	Recorded   int `db:"recorded"`
+	Open       int `db:"open"`
+	Converted  int `db:"converted"`
+	Suppressed int `db:"suppressed"`
+	OptedOut   int `db:"opted_out"`
+	Expired    int `db:"expired"`
+	// Revenue is the converted orders' settled base-currency total.
+	Revenue decimal.Decimal `db:"revenue"`
+	Steps   []CheckoutRecoveryStepMetrics
+}
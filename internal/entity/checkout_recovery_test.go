@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestCheckoutRecoverySchedule(t *testing.T) {
+	delays := []time.Duration{time.Hour, 24 * time.Hour, 72 * time.Hour}
+	window := 7 * 24 * time.Hour
+	activity := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sent := func(at time.Time) sql.NullTime { return sql.NullTime{Time: at, Valid: true} }
+
+	cases := []struct {
+		name     string
+		steps    int
+		lastSent sql.NullTime
+		now      time.Time
+		step     int
+		due      bool
+		lapsed   bool
+	}{
+		{"too early for the first", 0, sql.NullTime{}, activity.Add(59 * time.Minute), 0, false, false},
+		{"first due", 0, sql.NullTime{}, activity.Add(time.Hour), 1, true, false},
+		{"second waits for its own delay", 1, sent(activity.Add(time.Hour)), activity.Add(23 * time.Hour), 0, false, false},
+		{"second due", 1, sent(activity.Add(time.Hour)), activity.Add(25 * time.Hour), 2, true, false},
+		// The worker was down: only the next step goes, never a burst.
+		{"late worker sends the next step only", 0, sql.NullTime{}, activity.Add(80 * time.Hour), 1, true, false},
+		{"all sent, inside the window", 3, sent(activity.Add(72 * time.Hour)), activity.Add(100 * time.Hour), 0, false, false},
+		{"all sent, window over", 3, sent(activity.Add(72 * time.Hour)), activity.Add(72*time.Hour + window), 0, false, true},
+		{"never sent, whole sequence and window over", 1, sent(activity.Add(time.Hour)), activity.Add(72*time.Hour + window), 2, true, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := CheckoutRecovery{StepsSent: c.steps, LastActivityAt: activity, LastSentAt: c.lastSent}
+			step, due := r.NextStep(delays, c.now)
+			if step != c.step || due != c.due {
+				t.Fatalf("NextStep = (%d, %v), want (%d, %v)", step, due, c.step, c.due)
+			}
+			if got := r.Lapsed(delays, window, c.now); got != c.lapsed {
+				t.Fatalf("Lapsed = %v, want %v", got, c.lapsed)
+			}
+		})
+	}
+}
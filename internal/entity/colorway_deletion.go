@@ -63,6 +63,7 @@ const (
 	ColorwayCascadeLabDipRound     = "lab_dip_round"
 	ColorwayCascadeCostEvent       = "cost_event"
 	ColorwayCascadeWaitlist        = "waitlist"
+	ColorwayCascadeWishlist        = "wishlist"
 	ColorwayCascadeStockHistory    = "stock_history"
 	ColorwayCascadeStyleLink       = "style_link"
 
@@ -108,6 +109,7 @@ type ColorwayCascadeCounts struct {
 	LabDipRounds     int // product_lab_dip_round
 	CostEvents       int // product_cost_event
 	Waitlist         int // product_waitlist
+	Wishlist         int // storefront_wishlist_item
 	StockHistory     int // product_stock_change_history
 	StyleLinks       int // tech_card_product
 }
@@ -265,6 +267,8 @@ func ClassifyColorwayDeletion(f ColorwayDeletionFacts) ColorwayDeletionVerdict {
 		"%d cost event", "%d cost events")
 	v.Cascade = appendEntry(v.Cascade, ColorwayCascadeWaitlist, c.Waitlist,
 		"%d waitlist entry", "%d waitlist entries")
+	v.Cascade = appendEntry(v.Cascade, ColorwayCascadeWishlist, c.Wishlist,
+		"%d customer wishlist entry", "%d customer wishlist entries")
 	v.Cascade = appendEntry(v.Cascade, ColorwayCascadeStockHistory, c.StockHistory,
 		"%d stock history entry", "%d stock history entries")
 	v.Cascade = appendEntry(v.Cascade, ColorwayCascadeStyleLink, c.StyleLinks,
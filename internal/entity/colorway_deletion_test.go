@@ -285,6 +285,7 @@ func TestClassifyColorwayDeletion_EveryCascadeAndOrphanFieldSurfaces(t *testing.
 		ColorwayCascadePieceMaterial: 9, ColorwayCascadePackagingRecipe: 10,
 		ColorwayCascadeLabDipRound: 11, ColorwayCascadeCostEvent: 12,
 		ColorwayCascadeWaitlist: 13, ColorwayCascadeStockHistory: 14, ColorwayCascadeStyleLink: 15,
+		ColorwayCascadeWishlist: 16,
 	}
 	wantOrphans := map[string]int{
 		ColorwayOrphanMarker: 21, ColorwayOrphanMaterialMovement: 22,
@@ -295,6 +296,7 @@ func TestClassifyColorwayDeletion_EveryCascadeAndOrphanFieldSurfaces(t *testing.
 			Variants: 1, VariantPrices: 2, Prices: 3, Media: 4, Tags: 5, Translations: 6,
 			RecipeUsages: 7, SizeConsumptions: 8, PieceMaterials: 9, PackagingRecipes: 10,
 			LabDipRounds: 11, CostEvents: 12, Waitlist: 13, StockHistory: 14, StyleLinks: 15,
+			Wishlist: 16,
 		}
 		f.Orphans = ColorwayOrphanCounts{Markers: 21, MaterialMovements: 22, Samples: 23, Tasks: 24}
 	}))
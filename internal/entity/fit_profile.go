@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ПРОФИЛЬ ПОСАДКИ И ДАННЫЕ ДЛЯ ПОДБОРА РАЗМЕРА (0351): мерки тела покупателя (мм, словарь
+// BodyMeasurementName — тот же, что у фит-моделей), предпочтение посадки и опорный размер из
+// другого стиля. Сам подбор — internal/sizerec; здесь только то, что хранится и читается из базы.
+
+// FitPreference is how the customer likes clothes to sit: it moves the target ease of girths.
+type FitPreference string
+
+const (
+	FitPreferenceClose   FitPreference = "close"
+	FitPreferenceRegular FitPreference = "regular"
+	FitPreferenceRelaxed FitPreference = "relaxed"
+)
+
+// ValidFitPreferences is the set of accepted fit preferences.
+var ValidFitPreferences = map[FitPreference]bool{
+	FitPreferenceClose:   true,
+	FitPreferenceRegular: true,
+	FitPreferenceRelaxed: true,
+}
+
+// FitProfile is a storefront account's fit profile. An account that never saved one reads as an
+// empty regular-fit profile.
+type FitProfile struct {
+	AccountId    int
+	Preference   FitPreference
+	Measurements []ModelMeasurement // sparse, mm
+	// ReferenceProductId/ReferenceSizeId name the variant the customer already wears (0 — none);
+	// ReferenceVariantSKU is its variant SKU, empty when the variant is gone.
+	ReferenceProductId  int
+	ReferenceSizeId     int
+	ReferenceVariantSKU string
+	UpdatedAt           time.Time
+}
+
+// FitVariant is a storefront variant SKU resolved for sizing: its colourway, style and size, with
+// the colourway's tier gate.
+type FitVariant struct {
+	ProductId             int    `db:"product_id"`
+	StyleId               int    `db:"style_id"`
+	SizeId                int    `db:"size_id"`
+	VariantSKU            string `db:"variant_sku"`
+	MinTier               int16  `db:"min_tier"`
+	HiddenForNonQualified bool   `db:"hidden_for_non_qualified"`
+}
+
+// StyleFitData is everything the size recommender reads about one style: the size chart in the
+// card's unit, the approved single-size fittings on fit models and the per-size wrong-size returns.
+type StyleFitData struct {
+	StyleId int
+	Unit    TechCardMeasurementUnit
+	// Sizes are the charted sizes, smallest first (size.sku_ord).
+	Sizes    []FitSize
+	Chart    []StyleFitCell
+	Fittings []FitModelFitting
+	Returns  []SizeReturnSignal
+}
+
+// FitSize is a charted size of a style.
+type FitSize struct {
+	SizeId int    `db:"size_id"`
+	Name   string `db:"name"`
+	SkuOrd int    `db:"sku_ord"`
+}
+
+// StyleFitCell is one point of measure of the style's chart: a garment measurement_name (chest,
+// waist, sleeve…) at one size, in the card's unit.
+type StyleFitCell struct {
+	SizeId      int             `db:"size_id"`
+	Measurement string          `db:"measurement"`
+	Value       decimal.Decimal `db:"value"`
+}
+
+// FitModelFitting is an approved fitting of the style in exactly one size on a fit model with known
+// measurements: the design team's word that this body in this size is the intended fit.
+type FitModelFitting struct {
+	FittingId    int
+	SizeId       int
+	Measurements []ModelMeasurement
+}
+
+// SizeReturnSignal is the style's sales and wrong-size returns in one size. ExchangedUp/Down count
+// wrong-size exchanges into a larger/smaller size — the direction the size was off in.
+type SizeReturnSignal struct {
+	SizeId        int `db:"size_id"`
+	Sold          int `db:"sold"`
+	WrongSize     int `db:"wrong_size"`
+	ExchangedUp   int `db:"exchanged_up"`
+	ExchangedDown int `db:"exchanged_down"`
+}
@@ -37,6 +37,8 @@ type OrderFulfillment struct {
 	CreatedAt time.Time                  `db:"created_at"`
 	UpdatedAt time.Time                  `db:"updated_at"`
 	Checklist []FulfillmentChecklistItem `db:"-"`
+	// LocationId is the stock location the order is packed from (0345); NULL until allocated.
+	LocationId sql.NullInt32 `db:"location_id"`
 }
 
 // FulfillmentChecklistItem is one packing-checklist row on an order fulfillment.
@@ -58,6 +60,9 @@ type FulfillmentCard struct {
 	ChecklistDone  int
 	ChecklistTotal int
 	HasNotes       bool
+	// LocationId/LocationName: the stock location the order is allocated to; 0/"" = not allocated.
+	LocationId   int
+	LocationName string
 }
 
 // FulfillmentBoard is the three-column projection returned to the board UI. The
@@ -71,6 +71,14 @@ type MaterialLot struct {
 	// why pieces of one garment are cut from adjacent layers — that is a lay-screen note, not a
 	// field.
 	ShadeCode sql.NullString `db:"shade_code"`
+	// ShadeBand..LabTestedAt are the lot's CURRENT lab result (0348) — the latest row of
+	// material_lot_lab_test by tested_at, kept on the lot so a lay and the run pack read it with one
+	// join. All invalid = never tested. See MaterialLotLabTest for what each one means.
+	ShadeBand        sql.NullString      `db:"shade_band"`
+	ShrinkageWarpPct decimal.NullDecimal `db:"shrinkage_warp_pct"`
+	ShrinkageWeftPct decimal.NullDecimal `db:"shrinkage_weft_pct"`
+	MeasuredGsm      decimal.NullDecimal `db:"measured_gsm"`
+	LabTestedAt      sql.NullTime        `db:"lab_tested_at"`
 }
 
 // MaterialPriceSourcePurchase marks a price point that entered the history from a stock receipt
@@ -90,6 +98,8 @@ const (
 	MaterialMovementReturnSample      MaterialMovementType = "return_sample"      // returned from a sample
 	MaterialMovementAdjustment        MaterialMovementType = "adjustment"         // stock count (set/adjust)
 	MaterialMovementWriteoff          MaterialMovementType = "writeoff"           // damage/loss/defect
+	MaterialMovementConsignOut        MaterialMovementType = "consign_out"        // kit shipped to a subcontractor, still ours (0344)
+	MaterialMovementConsignReturn     MaterialMovementType = "consign_return"     // kit leftover back from a subcontractor (0344)
 )
 
 // ValidMaterialMovementTypes is the closed set enforced by the DB CHECK and validated in the dto.
@@ -98,6 +108,7 @@ var ValidMaterialMovementTypes = map[MaterialMovementType]struct{}{
 	MaterialMovementIssueProduction: {}, MaterialMovementIssueSample: {},
 	MaterialMovementReturnProduction: {}, MaterialMovementReturnSample: {},
 	MaterialMovementAdjustment: {}, MaterialMovementWriteoff: {},
+	MaterialMovementConsignOut: {}, MaterialMovementConsignReturn: {},
 }
 
 // Material adjustment reasons (a subset shared with product stock-count semantics). Packaging is
@@ -146,16 +157,20 @@ type MaterialMovement struct {
 	SupplierDoc sql.NullString `db:"supplier_doc"`
 	// SupplierId is the catalogued supplier of a purchase receipt (phase 2, wave 4 — AP subledger). NULL
 	// on non-receipts and on receipts entered without a supplier; the accounting worker copies it onto the
-	// M1 journal entry so GetPayables can group open Accounts-Payable per supplier.
+	// M1 journal entry so GetPayables can group open Accounts-Payable per supplier. On the consign
+	// movements and the reconciliation issues of a subcontracted run (0344) it is the factory instead.
 	SupplierId sql.NullInt32 `db:"supplier_id"`
 	// ExpectedAt is when a purchase receipt was promised to arrive (Phase 9) — lateness becomes a
 	// queryable fact (occurred_at vs expected_at) without a PO entity.
-	ExpectedAt    sql.NullTime   `db:"expected_at"`
-	Reason        sql.NullString `db:"reason"`
-	Comment       sql.NullString `db:"comment"`
-	AdminUsername string         `db:"admin_username"`
-	OccurredAt    sql.NullTime   `db:"occurred_at"`
-	CreatedAt     time.Time      `db:"created_at"`
+	ExpectedAt sql.NullTime `db:"expected_at"`
+	// PurchaseOrderLineId is the purchase order line a receipt delivered (0336); NULL for a receipt
+	// booked without a PO and for every other movement.
+	PurchaseOrderLineId sql.NullInt32  `db:"purchase_order_line_id"`
+	Reason              sql.NullString `db:"reason"`
+	Comment             sql.NullString `db:"comment"`
+	AdminUsername       string         `db:"admin_username"`
+	OccurredAt          sql.NullTime   `db:"occurred_at"`
+	CreatedAt           time.Time      `db:"created_at"`
 	// InputVatAmount / InputVatRegime carry a purchase receipt's recoverable input VAT (base currency)
 	// and its treatment (wnt|import|domestic_pl|domestic_uk) for the extended M1 posting rule (phase 2,
 	// wave 1). Set only on receipts that record VAT; NULL everywhere else.
@@ -184,10 +199,13 @@ type MaterialReceiptInsert struct {
 	SupplierId sql.NullInt32
 	// ExpectedAt is when this delivery was promised to arrive (Phase 9, plan 13 §1) — recorded
 	// against the receipt so lateness is a queryable fact without a PO entity.
-	ExpectedAt    sql.NullTime
-	OccurredAt    sql.NullTime
-	Comment       sql.NullString
-	AdminUsername string
+	ExpectedAt sql.NullTime
+	// PurchaseOrderLineId links the receipt to the PO line it delivers (0336). Set only by the PO
+	// receive path, which also maintains the line's received_qty; a free receipt leaves it NULL.
+	PurchaseOrderLineId sql.NullInt32
+	OccurredAt          sql.NullTime
+	Comment             sql.NullString
+	AdminUsername       string
 	// FromProduction marks a receipt_production (auxiliary-run output) rather than a purchase.
 	// UnitCost is then the run's actual per-unit base cost, already in the base currency.
 	FromProduction bool
@@ -260,8 +278,13 @@ type MaterialAdjustInsert struct {
 // flag — the shape of the warehouse list. AvgUnitCostBase/StockValueBase are confidential (costing
 // field-shaping strips them for accounts without costing:read).
 type MaterialStockRow struct {
-	Material        Material
-	OnHand          decimal.Decimal
+	Material Material
+	// OnHand is the material's stock; under a location filter, the stock in that location (derived,
+	// and possibly negative, for the default location — see DefaultLocationQty).
+	OnHand decimal.Decimal
+	// TotalOnHand is the stock across all locations; equal to OnHand without a location filter. The
+	// minimum-stock flag always reads the total: min_stock is a reorder point, not a shelf target.
+	TotalOnHand     decimal.Decimal
 	AvgUnitCostBase decimal.NullDecimal
 	StockValueBase  decimal.NullDecimal
 	MinStock        decimal.NullDecimal
@@ -274,6 +297,7 @@ type MaterialStockFilter struct {
 	Query         string // matches name / code / supplier_ref
 	WithStockOnly bool   // only materials with on_hand > 0
 	BelowMinOnly  bool   // only materials under their min_stock
+	LocationId    int    // 0 = all locations; otherwise on_hand is that location's stock (0345)
 }
 
 // MaterialMovementFilter narrows the movement ledger.
@@ -282,6 +306,8 @@ type MaterialMovementFilter struct {
 	ProductionRunId int
 	SampleId        int
 	MovementType    MaterialMovementType
+	// PurchaseOrderId narrows to the receipts booked against one purchase order (0336).
+	PurchaseOrderId int
 	// Optional inclusive occurred_at DATE bounds (YYYY-MM-DD); empty = open (B-5).
 	OccurredFrom string
 	OccurredTo   string
@@ -0,0 +1,172 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/currency"
+	"github.com/shopspring/decimal"
+)
+
+// ПЛАНОВЫЕ УЦЕНКИ (markdown_event / markdown_event_product, 0349): процент, окно [StartsAt, EndsAt)
+// и список колорвеев. Воркер ставит product.sale_percentage в начале окна и возвращает прежнее
+// значение в конце — то самое поле, из которого витрина, фильтр распродажи и промо читают OnSale.
+
+// MarkdownStatus is where an event is in its life. Only a scheduled event is editable.
+type MarkdownStatus string
+
+const (
+	MarkdownScheduled MarkdownStatus = "scheduled"
+	MarkdownActive    MarkdownStatus = "active"
+	MarkdownEnded     MarkdownStatus = "ended"
+	MarkdownCancelled MarkdownStatus = "cancelled"
+)
+
+// MaxMarkdownProducts bounds one event; a whole-catalogue sale is still well under it.
+const MaxMarkdownProducts = 2000
+
+var (
+	// ErrMarkdownEventNotFound is returned for an unknown event id.
+	ErrMarkdownEventNotFound = errors.New("markdown event not found")
+	// ErrMarkdownEventNotEditable is returned when editing an event that already started, ended or
+	// was cancelled — its products' prices are, or were, live.
+	ErrMarkdownEventNotEditable = errors.New("markdown event is no longer scheduled")
+)
+
+// MarkdownEvent is one scheduled markdown.
+type MarkdownEvent struct {
+	Id             int             `db:"id"`
+	Name           string          `db:"name"`
+	SalePercentage decimal.Decimal `db:"sale_percentage"`
+	StartsAt       time.Time       `db:"starts_at"`
+	EndsAt         time.Time       `db:"ends_at"`
+	Status         MarkdownStatus  `db:"status"`
+	// AppliedAt / RevertedAt are when the worker (or a cancel) actually flipped the prices — not the
+	// planned window, which a down worker can overrun.
+	AppliedAt  sql.NullTime           `db:"applied_at"`
+	RevertedAt sql.NullTime           `db:"reverted_at"`
+	Note       sql.NullString         `db:"note"`
+	CreatedBy  string                 `db:"created_by"`
+	CreatedAt  time.Time              `db:"created_at"`
+	UpdatedAt  time.Time              `db:"updated_at"`
+	Products   []MarkdownEventProduct `db:"-"`
+}
+
+// MarkdownEventProduct is one colourway of an event.
+type MarkdownEventProduct struct {
+	ProductId int    `db:"product_id"`
+	SKU       string `db:"sku"`
+	Color     string `db:"color"`
+	// PrevSalePercentage is what stood before the event applied; invalid until it does.
+	PrevSalePercentage decimal.NullDecimal `db:"prev_sale_percentage"`
+	// RevertSkipped: somebody changed the colourway's sale percentage by hand during the event, and
+	// the revert left their value alone.
+	RevertSkipped bool `db:"revert_skipped"`
+}
+
+// MarkdownEventInsert is the writable part of an event. ProductIds is a full replace.
+type MarkdownEventInsert struct {
+	Name           string
+	SalePercentage decimal.Decimal
+	StartsAt       time.Time
+	EndsAt         time.Time
+	Note           sql.NullString
+	ProductIds     []int
+	CreatedBy      string
+}
+
+// ValidateMarkdownEventInsert normalises and checks an event payload. An event that would already
+// be over at now is refused: it would apply and revert in the same tick, which is a typo.
+func ValidateMarkdownEventInsert(ins *MarkdownEventInsert, now time.Time) error {
+	ins.Name = strings.TrimSpace(ins.Name)
+	switch {
+	case ins.Name == "":
+		return NewFieldViolation("name", "required", "", "name the markdown, e.g. «Winter sale»")
+	case len(ins.Name) > 255:
+		return NewFieldViolation("name", "too_long", "", "keep the name within 255 characters")
+	case !ins.SalePercentage.IsPositive() || ins.SalePercentage.GreaterThanOrEqual(decimal.NewFromInt(100)):
+		return NewFieldViolation("sale_percentage", "out_of_range", ins.SalePercentage.String(), "a markdown is a percentage above 0 and below 100")
+	case ins.StartsAt.IsZero() || ins.EndsAt.IsZero():
+		return NewFieldViolation("starts_at", "required", "", "give the markdown a start and an end")
+	case !ins.EndsAt.After(ins.StartsAt):
+		return NewFieldViolation("ends_at", "before_start", ins.EndsAt.UTC().Format(time.RFC3339), "the markdown must end after it starts")
+	case !ins.EndsAt.After(now):
+		return NewFieldViolation("ends_at", "in_the_past", ins.EndsAt.UTC().Format(time.RFC3339), "a markdown that has already ended cannot be scheduled")
+	case len(ins.ProductIds) == 0:
+		return NewFieldViolation("product_ids", "required", "", "add the colourways the markdown applies to")
+	case len(ins.ProductIds) > MaxMarkdownProducts:
+		return NewFieldViolation("product_ids", "too_many", "", fmt.Sprintf("at most %d colourways per markdown", MaxMarkdownProducts))
+	}
+	ins.SalePercentage = ins.SalePercentage.Round(2)
+	seen := make(map[int]bool, len(ins.ProductIds))
+	for i, id := range ins.ProductIds {
+		switch {
+		case id <= 0:
+			return NewFieldViolation(fmt.Sprintf("product_ids[%d]", i), "required", "", "")
+		case seen[id]:
+			return NewFieldViolation(fmt.Sprintf("product_ids[%d]", i), "duplicate", fmt.Sprint(id), "list a colourway once")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// MarkdownSource is one colourway as a markdown preview reads it.
+type MarkdownSource struct {
+	ProductId             int                 `db:"product_id"`
+	SKU                   string              `db:"sku"`
+	Color                 string              `db:"color"`
+	LifecycleStatus       ColorwayStatus      `db:"lifecycle_status"`
+	CurrentSalePercentage decimal.Decimal     `db:"sale_percentage"`
+	BasePrice             decimal.NullDecimal `db:"base_price"`
+}
+
+// MarkdownLine is one colourway of a preview: its price now and during the markdown, in the base
+// currency, and the event it would collide with. A colourway that does not exist comes back with
+// Missing; the save refuses both.
+type MarkdownLine struct {
+	MarkdownSource
+	CurrentSalePrice decimal.NullDecimal
+	NewSalePrice     decimal.NullDecimal
+	// ConflictEvent names an overlapping scheduled/active event holding the colourway.
+	ConflictEvent string
+	Missing       bool
+}
+
+// SalePrice is the storefront's sale price: base × (1 − pct/100), the expression the catalogue
+// query orders and filters by, in the currency's precision.
+func SalePrice(base, pct decimal.Decimal, cur string) decimal.Decimal {
+	hundred := decimal.NewFromInt(100)
+	return currency.Round(base.Mul(hundred.Sub(pct)).Div(hundred), cur)
+}
+
+// BuildMarkdownLines projects a markdown onto its colourways in the order of ids. conflicts maps a
+// product id to the overlapping event's name.
+func BuildMarkdownLines(ins MarkdownEventInsert, ids []int, sources map[int]MarkdownSource, conflicts map[int]string, baseCurrency string) []MarkdownLine {
+	out := make([]MarkdownLine, 0, len(ids))
+	for _, id := range ids {
+		src, ok := sources[id]
+		if !ok {
+			out = append(out, MarkdownLine{MarkdownSource: MarkdownSource{ProductId: id}, Missing: true})
+			continue
+		}
+		line := MarkdownLine{MarkdownSource: src, ConflictEvent: conflicts[id]}
+		if src.BasePrice.Valid {
+			line.CurrentSalePrice = decimal.NullDecimal{Decimal: SalePrice(src.BasePrice.Decimal, src.CurrentSalePercentage, baseCurrency), Valid: true}
+			line.NewSalePrice = decimal.NullDecimal{Decimal: SalePrice(src.BasePrice.Decimal, ins.SalePercentage, baseCurrency), Valid: true}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// MarkdownSweep is what one worker tick did: the events started and ended, and every colourway whose
+// sale percentage moved (the storefront revalidates them).
+type MarkdownSweep struct {
+	Started  []int
+	Ended    []int
+	Products []int
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestValidateMarkdownEventInsert(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	valid := func() MarkdownEventInsert {
+		return MarkdownEventInsert{
+			Name:           " Winter ",
+			SalePercentage: decimal.RequireFromString("30"),
+			StartsAt:       now.Add(24 * time.Hour),
+			EndsAt:         now.Add(72 * time.Hour),
+			ProductIds:     []int{3, 4},
+		}
+	}
+	ins := valid()
+	if err := ValidateMarkdownEventInsert(&ins, now); err != nil {
+		t.Fatalf("valid event: %v", err)
+	}
+	if ins.Name != "Winter" {
+		t.Fatalf("name not trimmed: %q", ins.Name)
+	}
+
+	cases := []struct {
+		mut   func(*MarkdownEventInsert)
+		field string
+		why   string
+	}{
+		{func(i *MarkdownEventInsert) { i.Name = " " }, "name", "required"},
+		{func(i *MarkdownEventInsert) { i.SalePercentage = decimal.Zero }, "sale_percentage", "out_of_range"},
+		{func(i *MarkdownEventInsert) { i.SalePercentage = decimal.NewFromInt(100) }, "sale_percentage", "out_of_range"},
+		{func(i *MarkdownEventInsert) { i.EndsAt = i.StartsAt }, "ends_at", "before_start"},
+		{func(i *MarkdownEventInsert) { i.StartsAt, i.EndsAt = now.Add(-2*time.Hour), now.Add(-time.Hour) }, "ends_at", "in_the_past"},
+		{func(i *MarkdownEventInsert) { i.ProductIds = nil }, "product_ids", "required"},
+		{func(i *MarkdownEventInsert) { i.ProductIds = []int{3, 3} }, "product_ids[1]", "duplicate"},
+	}
+	for _, c := range cases {
+		ins := valid()
+		c.mut(&ins)
+		var ve *ValidationError
+		if err := ValidateMarkdownEventInsert(&ins, now); !errors.As(err, &ve) || ve.Field != c.field || ve.Reason != c.why {
+			t.Errorf("got %v; want %s/%s", err, c.field, c.why)
+		}
+	}
+
+	// An event already running is fine — the worker applies it on the next tick.
+	ins = valid()
+	ins.StartsAt = now.Add(-time.Hour)
+	if err := ValidateMarkdownEventInsert(&ins, now); err != nil {
+		t.Fatalf("a started window that has not ended is schedulable: %v", err)
+	}
+}
+
+func TestBuildMarkdownLines(t *testing.T) {
+	base := decimal.NullDecimal{Decimal: decimal.RequireFromString("199.99"), Valid: true}
+	sources := map[int]MarkdownSource{
+		1: {ProductId: 1, BasePrice: base, CurrentSalePercentage: decimal.Zero},
+		2: {ProductId: 2, CurrentSalePercentage: decimal.NewFromInt(10)},
+	}
+	ins := MarkdownEventInsert{SalePercentage: decimal.NewFromInt(30)}
+	lines := BuildMarkdownLines(ins, []int{1, 2, 9}, sources, map[int]string{2: "Summer"}, "EUR")
+	if len(lines) != 3 {
+		t.Fatalf("one line per id: %d", len(lines))
+	}
+	if l := lines[0]; l.CurrentSalePrice.Decimal.String() != "199.99" || l.NewSalePrice.Decimal.String() != "139.99" {
+		t.Fatalf("sale prices: %+v", l)
+	}
+	if l := lines[1]; l.ConflictEvent != "Summer" || l.NewSalePrice.Valid {
+		t.Fatalf("conflict / missing base price: %+v", l)
+	}
+	if l := lines[2]; !l.Missing || l.ProductId != 9 {
+		t.Fatalf("unknown colourway must come back missing: %+v", l)
+	}
+}
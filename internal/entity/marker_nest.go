@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// РАСКЛАДКА СЕРВЕРОМ ПРОТИВ НОРМЫ. A nest the server ran (internal/nesting) is worth showing only next
+// to the раскладка the card already costs by — «is it shorter, and for which sizes». The comparison
+// is made of the same derived numbers either marker publishes on its own (PerSizeConsumption), so
+// the delta cannot disagree with the two figures it sits between.
+
+// MarkerSizeDelta is one size's расход per garment, nested against the norm. NormCm is INVALID when
+// the norm does not cut that size or withholds its number; DeltaCm is INVALID with it.
+type MarkerSizeDelta struct {
+	SizeId   int
+	NestedCm decimal.NullDecimal
+	NormCm   decimal.NullDecimal
+	DeltaCm  decimal.NullDecimal
+}
+
+// MarkerNormComparison is a nested marker against the norm of its cloth.
+type MarkerNormComparison struct {
+	Norm TechCardMarkerSummary
+	// UsedLengthDeltaCm compares length per lay, which means something only when both lays cut the
+	// same состав; INVALID otherwise — the per-size rows are the comparison then.
+	UsedLengthDeltaCm  decimal.NullDecimal
+	EfficiencyDeltaPct decimal.NullDecimal
+	Sizes              []MarkerSizeDelta
+}
+
+// CompareMarkerToNorm lines a nested marker up against the norm, size by size in the nested
+// marker's состав order. Both are read through PerSizeConsumption, so a draft on either side
+// withholds its per-size figures rather than comparing a short length.
+func CompareMarkerToNorm(nested, norm TechCardMarkerSummary) MarkerNormComparison {
+	out := MarkerNormComparison{Norm: norm}
+	if sameComposition(nested.CompositionOrLegacy(), norm.CompositionOrLegacy()) {
+		out.UsedLengthDeltaCm = decimal.NullDecimal{Decimal: nested.UsedLengthCm.Sub(norm.UsedLengthCm), Valid: true}
+	}
+	if nested.EfficiencyPct.Valid && norm.EfficiencyPct.Valid {
+		out.EfficiencyDeltaPct = decimal.NullDecimal{
+			Decimal: nested.EfficiencyPct.Decimal.Sub(norm.EfficiencyPct.Decimal), Valid: true}
+	}
+	normBySize := map[int]decimal.NullDecimal{}
+	for _, r := range norm.PerSizeConsumption() {
+		normBySize[r.SizeId] = r.ConsumptionCm
+	}
+	for _, r := range nested.PerSizeConsumption() {
+		d := MarkerSizeDelta{SizeId: r.SizeId, NestedCm: r.ConsumptionCm, NormCm: normBySize[r.SizeId]}
+		if d.NestedCm.Valid && d.NormCm.Valid {
+			d.DeltaCm = decimal.NullDecimal{Decimal: d.NestedCm.Decimal.Sub(d.NormCm.Decimal), Valid: true}
+		}
+		out.Sizes = append(out.Sizes, d)
+	}
+	return out
+}
+
+// sameComposition compares two составы as sets of (size, quantity).
+func sameComposition(a, b []MarkerCompositionEntry) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	key := func(cs []MarkerCompositionEntry) []MarkerCompositionEntry {
+		out := make([]MarkerCompositionEntry, len(cs))
+		for i, c := range cs {
+			out[i] = MarkerCompositionEntry{SizeId: c.SizeId, Quantity: c.Quantity}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].SizeId < out[j].SizeId })
+		return out
+	}
+	ka, kb := key(a), key(b)
+	for i := range ka {
+		if ka[i].SizeId != kb[i].SizeId || ka[i].Quantity != kb[i].Quantity {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCompareMarkerToNorm(t *testing.T) {
+	withAreas := WithMarkerSizeAreas(fixtureComposition, fixturePieces)
+	norm := TechCardMarkerSummary{
+		Id:            7,
+		UsedLengthCm:  d("1400"),
+		EfficiencyPct: decimal.NullDecimal{Decimal: d("80"), Valid: true},
+		Composition:   withAreas,
+	}
+	nested := TechCardMarkerSummary{
+		UsedLengthCm:  d("1344"),
+		EfficiencyPct: decimal.NullDecimal{Decimal: d("83.5"), Valid: true},
+		Composition:   withAreas,
+	}
+
+	got := CompareMarkerToNorm(nested, norm)
+	if !got.UsedLengthDeltaCm.Valid || !got.UsedLengthDeltaCm.Decimal.Equal(d("-56")) {
+		t.Fatalf("length delta = %+v, want -56", got.UsedLengthDeltaCm)
+	}
+	if !got.EfficiencyDeltaPct.Decimal.Equal(d("3.5")) {
+		t.Fatalf("efficiency delta = %+v, want 3.5", got.EfficiencyDeltaPct)
+	}
+	// Same areas, 4% shorter: 260 → 249.6, 310 → 297.6.
+	want := map[int]string{10: "-10.4", 20: "-12.4"}
+	if len(got.Sizes) != 2 {
+		t.Fatalf("sizes = %+v", got.Sizes)
+	}
+	for _, s := range got.Sizes {
+		if !s.DeltaCm.Valid || !s.DeltaCm.Decimal.Equal(d(want[s.SizeId])) {
+			t.Errorf("size %d delta = %+v, want %s", s.SizeId, s.DeltaCm, want[s.SizeId])
+		}
+	}
+
+	t.Run("another состав compares per size only", func(t *testing.T) {
+		other := nested
+		other.Composition = WithMarkerSizeAreas(
+			[]MarkerCompositionEntry{{SizeId: 10, Quantity: 1}, {SizeId: 30, Quantity: 1}},
+			[]MarkerPieceArea{{SizeId: 10, Quantity: 1, AreaCm2: d("100")}, {SizeId: 30, Quantity: 1, AreaCm2: d("100")}})
+		got := CompareMarkerToNorm(other, norm)
+		if got.UsedLengthDeltaCm.Valid {
+			t.Fatal("length per lay of two different составы must not be compared")
+		}
+		if got.Sizes[1].NormCm.Valid || got.Sizes[1].DeltaCm.Valid {
+			t.Fatalf("size the norm does not cut: %+v", got.Sizes[1])
+		}
+	})
+	t.Run("a draft withholds its sizes", func(t *testing.T) {
+		draft := nested
+		draft.IsDraft = true
+		for _, s := range CompareMarkerToNorm(draft, norm).Sizes {
+			if s.NestedCm.Valid || s.DeltaCm.Valid {
+				t.Fatalf("draft size row %+v carries a number", s)
+			}
+		}
+	})
+}
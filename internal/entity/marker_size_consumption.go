@@ -33,11 +33,12 @@ import (
 //
 // ЧТО ЗДЕСЬ ПРИНЦИПИАЛЬНО НЕ ЖИВЁТ — размеры, которых нет в составе. The plan continues the same
 // formula across the whole размерный ряд using piece areas «известны из файлов БЕЗ маркера», and
-// THE SERVER CANNOT DO THAT HALF: it never parses a DXF, and the blob it does read contains pieces
-// only for the sizes the состав cuts (the save path refuses a piece pointing at a size the состав
-// does not cut — dto.MarkerLayoutFactsFromPb). So the server publishes the two numbers that make the
-// continuation possible for whoever CAN parse the files — a_s per size (AreaPerGarmentCm2, on the
-// wire beside each состав line) and, through them, the constant L/A — and stops there. Inventing an
+// THIS FILE DOES NOT DO THAT HALF: the blob it reads contains pieces only for the sizes the состав
+// cuts (the save path refuses a piece pointing at a size the состав does not cut —
+// dto.MarkerLayoutFactsFromPb). The server does parse the files now (internal/dxfpattern), but a
+// size's area is the files' fact, not the marker's — so a marker publishes the two numbers that make
+// the continuation possible — a_s per size (AreaPerGarmentCm2, on the wire beside each состав line)
+// and, through them, the constant L/A — and stops there. Inventing an
 // area for a size it has never seen the выкройки of would be exactly the plausible-looking lie this
 // whole subsystem is built to refuse.
 
@@ -0,0 +1,150 @@
+package entity
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ЛАБОРАТОРИЯ ЛОТА (0348): оттеночная группа, усадка по основе/утку и плотность рулона. История
+// испытаний — material_lot_lab_test; текущий результат (последний по tested_at) лежит на самом
+// material_lot, и его читают настил, проверка смешивания групп и отчёт наряда.
+
+// MaxShadeBandLength is material_lot.shade_band / material_lot_lab_test.shade_band (VARCHAR(16)).
+const MaxShadeBandLength = 16
+
+// Shrinkage bounds, mirrored by chk_material_lot_lab / chk_mllt_shrinkage. A negative value is
+// growth (the cloth relaxes longer), which a wash test does report; past 50% the number is a typo,
+// not a fabric.
+var (
+	MinLotShrinkagePct = decimal.NewFromInt(-20)
+	MaxLotShrinkagePct = decimal.NewFromInt(50)
+)
+
+// MaterialLotLabTest is one lab result for a lot. Every measurement is optional — a shade sorting
+// and a wash test are often done on different days — but a row carries at least one of them
+// (chk_mllt_any).
+type MaterialLotLabTest struct {
+	Id    int `db:"id"`
+	LotId int `db:"lot_id"`
+	// ShadeBand is the group the controller put the roll in under the light box (A, B, C…). It is
+	// NOT MaterialLot.ShadeCode: that one is what the supplier printed, this one is what the shop
+	// saw, and the two legitimately disagree in both directions.
+	ShadeBand sql.NullString `db:"shade_band"`
+	// ShrinkageWarpPct / ShrinkageWeftPct are the measured shrinkage along the warp (length of the
+	// roll) and the weft (across it), in percent. Negative = growth.
+	ShrinkageWarpPct decimal.NullDecimal `db:"shrinkage_warp_pct"`
+	ShrinkageWeftPct decimal.NullDecimal `db:"shrinkage_weft_pct"`
+	MeasuredGsm      decimal.NullDecimal `db:"measured_gsm"`
+	Note             sql.NullString      `db:"note"`
+	TestedAt         time.Time           `db:"tested_at"`
+	TestedBy         string              `db:"tested_by"`
+	CreatedAt        time.Time           `db:"created_at"`
+}
+
+// MaterialLotLabTestInsert is the payload of RecordMaterialLotLabTest. TestedAt invalid = now; a
+// back-dated result goes into the history but does not replace a newer current result on the lot.
+type MaterialLotLabTestInsert struct {
+	LotId            int
+	ShadeBand        sql.NullString
+	ShrinkageWarpPct decimal.NullDecimal
+	ShrinkageWeftPct decimal.NullDecimal
+	MeasuredGsm      decimal.NullDecimal
+	Note             sql.NullString
+	TestedAt         sql.NullTime
+}
+
+// NormalizeShadeBand trims and upper-cases a band, so «a» and «A » are one group. Empty → invalid.
+func NormalizeShadeBand(s sql.NullString) sql.NullString {
+	v := strings.ToUpper(strings.TrimSpace(s.String))
+	if !s.Valid || v == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: v, Valid: true}
+}
+
+// ValidateMaterialLotLabTest normalises the band and checks the ranges the schema enforces, naming
+// the offending field instead of surfacing a MySQL 3819.
+func ValidateMaterialLotLabTest(ins *MaterialLotLabTestInsert) error {
+	if ins.LotId <= 0 {
+		return NewFieldViolation("lot_id", "required", "", "name the lot the test was made on")
+	}
+	ins.ShadeBand = NormalizeShadeBand(ins.ShadeBand)
+	if len(ins.ShadeBand.String) > MaxShadeBandLength {
+		return NewFieldViolation("shade_band", "too_long", ins.ShadeBand.String,
+			fmt.Sprintf("a shade band is a short group label (A, B, C…), at most %d characters", MaxShadeBandLength))
+	}
+	for _, f := range []struct {
+		field string
+		v     decimal.NullDecimal
+	}{{"shrinkage_warp_pct", ins.ShrinkageWarpPct}, {"shrinkage_weft_pct", ins.ShrinkageWeftPct}} {
+		if f.v.Valid && (f.v.Decimal.LessThan(MinLotShrinkagePct) || f.v.Decimal.GreaterThan(MaxLotShrinkagePct)) {
+			return NewFieldViolation(f.field, "out_of_range", f.v.Decimal.String(),
+				fmt.Sprintf("shrinkage is a percentage between %s and %s; negative means the cloth grew",
+					MinLotShrinkagePct, MaxLotShrinkagePct))
+		}
+	}
+	if ins.MeasuredGsm.Valid && !ins.MeasuredGsm.Decimal.IsPositive() {
+		return NewFieldViolation("measured_gsm", "out_of_range", ins.MeasuredGsm.Decimal.String(),
+			"the measured weight is a positive number of grams per square metre")
+	}
+	if !ins.ShadeBand.Valid && !ins.ShrinkageWarpPct.Valid && !ins.ShrinkageWeftPct.Valid && !ins.MeasuredGsm.Valid {
+		return NewFieldViolation("lab_test", "empty", "",
+			"record at least one result: the shade band, the shrinkage or the measured weight")
+	}
+	return nil
+}
+
+// ShrinkageCompensationPct is how much a pattern dimension has to GROW so that the garment comes out
+// at the drawn size after the cloth shrinks by shrinkagePct:
+//
+//	L × (1 − s/100) = L₀  ⇒  L = L₀ / (1 − s/100)  ⇒  припуск = s / (100 − s) × 100 %
+//
+// NOT simply s: 5% of shrinkage needs 5.26% of compensation, because the shrinkage is taken off the
+// LARGER, compensated length. Rounded to 2 places, the precision the lab reports in. ok=false for
+// an unmeasured shrinkage and for one the formula cannot carry (≥ 100%).
+func ShrinkageCompensationPct(shrinkagePct decimal.NullDecimal) (decimal.Decimal, bool) {
+	if !shrinkagePct.Valid {
+		return decimal.Zero, false
+	}
+	hundred := decimal.NewFromInt(100)
+	rest := hundred.Sub(shrinkagePct.Decimal)
+	if !rest.IsPositive() {
+		return decimal.Zero, false
+	}
+	return shrinkagePct.Decimal.Mul(hundred).Div(rest).Round(2), true
+}
+
+// ShadeBandMix is the answer to «can these rolls lie in one lay?». Bands are the distinct KNOWN
+// bands, sorted; Untested counts the rolls nobody has banded yet.
+type ShadeBandMix struct {
+	Bands    []string
+	Untested int
+}
+
+// Mixed reports two or more known bands — the one combination that is refused.
+func (m ShadeBandMix) Mixed() bool { return len(m.Bands) > 1 }
+
+// ShadeBandMixOf folds the bands of the rolls of one lay. An untested roll is counted, never
+// guessed into a group: «not sorted yet» is not «same as the others».
+func ShadeBandMixOf(bands []sql.NullString) ShadeBandMix {
+	seen := map[string]bool{}
+	var out ShadeBandMix
+	for _, b := range bands {
+		b = NormalizeShadeBand(b)
+		if !b.Valid {
+			out.Untested++
+			continue
+		}
+		if !seen[b.String] {
+			seen[b.String] = true
+			out.Bands = append(out.Bands, b.String)
+		}
+	}
+	sort.Strings(out.Bands)
+	return out
+}
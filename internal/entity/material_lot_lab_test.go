@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestShrinkageCompensationPct(t *testing.T) {
+	cases := []struct {
+		in   decimal.NullDecimal
+		want string
+		ok   bool
+	}{
+		{decimal.NullDecimal{Decimal: decimal.RequireFromString("5"), Valid: true}, "5.26", true},
+		{decimal.NullDecimal{Decimal: decimal.RequireFromString("3"), Valid: true}, "3.09", true},
+		{decimal.NullDecimal{Decimal: decimal.Zero, Valid: true}, "0", true},
+		// Growth compensates by SHORTENING the pattern.
+		{decimal.NullDecimal{Decimal: decimal.RequireFromString("-2"), Valid: true}, "-1.96", true},
+		{decimal.NullDecimal{}, "0", false},
+		{decimal.NullDecimal{Decimal: decimal.NewFromInt(100), Valid: true}, "0", false},
+	}
+	for _, c := range cases {
+		got, ok := ShrinkageCompensationPct(c.in)
+		if ok != c.ok || got.String() != c.want {
+			t.Errorf("ShrinkageCompensationPct(%v) = %s, %v; want %s, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestShadeBandMixOf(t *testing.T) {
+	band := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+	m := ShadeBandMixOf([]sql.NullString{band("a"), band(" A "), {}})
+	if m.Mixed() || len(m.Bands) != 1 || m.Bands[0] != "A" || m.Untested != 1 {
+		t.Fatalf("one band plus an untested roll: %+v", m)
+	}
+	m = ShadeBandMixOf([]sql.NullString{band("B"), band("A"), band("B")})
+	if !m.Mixed() || len(m.Bands) != 2 || m.Bands[0] != "A" {
+		t.Fatalf("two bands must be mixed and sorted: %+v", m)
+	}
+	if m := ShadeBandMixOf(nil); m.Mixed() || m.Untested != 0 {
+		t.Fatalf("no rolls: %+v", m)
+	}
+}
+
+func TestValidateMaterialLotLabTest(t *testing.T) {
+	ins := MaterialLotLabTestInsert{LotId: 4, ShadeBand: sql.NullString{String: " b ", Valid: true}}
+	if err := ValidateMaterialLotLabTest(&ins); err != nil {
+		t.Fatalf("band-only test: %v", err)
+	}
+	if ins.ShadeBand.String != "B" {
+		t.Fatalf("band not normalised: %q", ins.ShadeBand.String)
+	}
+
+	var ve *ValidationError
+	empty := MaterialLotLabTestInsert{LotId: 4, ShadeBand: sql.NullString{String: "  ", Valid: true}}
+	if err := ValidateMaterialLotLabTest(&empty); !errors.As(err, &ve) || ve.Reason != "empty" {
+		t.Fatalf("a test with no result must be refused, got %v", err)
+	}
+	wild := MaterialLotLabTestInsert{LotId: 4, ShrinkageWeftPct: decimal.NullDecimal{Decimal: decimal.NewFromInt(70), Valid: true}}
+	if err := ValidateMaterialLotLabTest(&wild); !errors.As(err, &ve) || ve.Field != "shrinkage_weft_pct" {
+		t.Fatalf("70%% shrinkage must be refused on its field, got %v", err)
+	}
+}
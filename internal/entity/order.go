@@ -35,6 +35,7 @@ type OrderNew struct {
 	PaymentMethod      PaymentMethodName `valid:"required"`
 	ShipmentCarrierId  int               `valid:"required"`
 	PromoCode          string            `valid:"-"`
+	PromoCodes         []string          `valid:"-"`                    // further codes to stack after PromoCode, in entry order
 	Currency           string            `valid:"required,length(3|4)"` // ISO 4217 (3) or USDT (4)
 	CustomShipmentCost *decimal.Decimal  `valid:"-"`                    // optional; when set, overrides carrier price (admin custom orders)
 	GAClientID         string            `valid:"-"`                    // GA4 client ID from browser _ga cookie
@@ -49,6 +50,18 @@ type OrderNew struct {
 	// CreateCustomOrderRequest). Its presence drives the wdt / reverse-charge classification and 4310
 	// wholesale revenue. Empty (invalid) on the storefront path → written as NULL (phase 2, wave 1).
 	BuyerVatID sql.NullString `valid:"-"`
+	// StoreCredit is the gift card or account credit to tender on this order. The gift card code comes
+	// from the request; the account email is set by the storefront handler from the access token.
+	StoreCredit StoreCreditRedemption `valid:"-"`
+}
+
+// EnteredPromoCodes is PromoCode followed by PromoCodes, the order the codes are applied in.
+func (o *OrderNew) EnteredPromoCodes() []string {
+	codes := make([]string, 0, len(o.PromoCodes)+1)
+	if o.PromoCode != "" {
+		codes = append(codes, o.PromoCode)
+	}
+	return append(codes, o.PromoCodes...)
 }
 
 type OrderFull struct {
@@ -115,9 +128,13 @@ type Order struct {
 	// discount from the order, not a live promo_code join that later edits/deletion would rewrite.
 	// NULL when no promo was applied. PromoCodeSnapshot preserves the code string for promo reports
 	// after the code is deleted. These are read by metrics SQL; the order flow writes them.
-	PromoDiscountPct  decimal.NullDecimal `db:"promo_discount_pct"`
-	PromoFreeShipping sql.NullBool        `db:"promo_free_shipping"`
-	PromoCodeSnapshot sql.NullString      `db:"promo_code_snapshot"`
+	// Since 0335 PromoDiscountPct is the effective percentage of the subtotal (fixed and buy-x-get-y
+	// codes have no configured one) and PromoDiscountAmount the discount itself; with stacked codes
+	// PromoCodeSnapshot lists them comma-separated and customer_order_promo holds each one's share.
+	PromoDiscountPct    decimal.NullDecimal `db:"promo_discount_pct"`
+	PromoFreeShipping   sql.NullBool        `db:"promo_free_shipping"`
+	PromoCodeSnapshot   sql.NullString      `db:"promo_code_snapshot"`
+	PromoDiscountAmount decimal.NullDecimal `db:"promo_discount_amount"`
 	// Buyer identity for the admin order-list projection only: populated by
 	// GetOrdersByStatusAndPaymentTypePaged (which joins buyer), and empty on the many SELECT co.* paths
 	// that don't project the buyer (those carry the full Buyer via OrderFull instead). Surfaced on
@@ -134,6 +151,10 @@ type Order struct {
 	// (customer_order.buyer_vat_id); NULL for B2C/storefront orders. Read-only surface for the
 	// invoice; the custom-order flow writes it via entity.OrderNew.BuyerVatID.
 	BuyerVatID sql.NullString `db:"buyer_vat_id"`
+	// StoreCreditApplied is the part of TotalPrice paid with a gift card or account credit, taken
+	// from StoreCreditWalletId at checkout; the payment provider is charged the rest (AmountDue).
+	StoreCreditApplied  decimal.Decimal `db:"store_credit_applied"`
+	StoreCreditWalletId sql.NullInt32   `db:"store_credit_wallet_id"`
 }
 
 // OrdersOverview is the orders dashboard aggregate. Revenue remains separated by
@@ -163,6 +184,11 @@ func (o *Order) TotalPriceDecimal() decimal.Decimal {
 	return currency.Round(o.TotalPrice, o.Currency)
 }
 
+// AmountDue is what the payment provider charges: the total less the store credit applied.
+func (o *Order) AmountDue() decimal.Decimal {
+	return currency.Round(o.TotalPrice.Sub(o.StoreCreditApplied), o.Currency)
+}
+
 func (o *Order) RefundedAmountDecimal() decimal.Decimal {
 	return currency.Round(o.RefundedAmount, o.Currency)
 }
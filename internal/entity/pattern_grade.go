@@ -0,0 +1,85 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ПРАВИЛА ГРАДАЦИИ ЛЕКАЛ (0346) — point grade rules of a fabric scope's pieces: which cardinal point
+// of the base contour moves by how much per size step. The server draws every size from them
+// (dxfpattern.Grade); the base size is the style's grade base (tech_card.grade_base_size_id), the
+// one the size chart is expanded from.
+//
+// Keyed by scope like the areas (0297): a lining version of a piece is its own contour, with its
+// own point numbering.
+
+// maxGradeDeltaCm bounds one point's move per size step. A real grade moves a point millimetres to a
+// couple of centimetres; a metre is a typo in the unit.
+var maxGradeDeltaCm = decimal.NewFromInt(100)
+
+// PatternGradeRule is one cardinal point's move per size step, in cm.
+type PatternGradeRule struct {
+	PieceLineKey string          `db:"piece_line_key"`
+	PointNo      int             `db:"point_no"`
+	DxCm         decimal.Decimal `db:"dx_cm"`
+	DyCm         decimal.Decimal `db:"dy_cm"`
+}
+
+// PatternGradeRuleSet is one scope's stored rules.
+type PatternGradeRuleSet struct {
+	TechCardId int
+	ScopeKey   string
+	Rules      []PatternGradeRule
+	UpdatedBy  string
+	UpdatedAt  time.Time
+}
+
+// PatternGradeRuleWrite replaces ONE scope's rules whole.
+type PatternGradeRuleWrite struct {
+	TechCardId int
+	ScopeKey   string
+	Rules      []PatternGradeRule
+	UpdatedBy  string
+}
+
+// ValidatePatternGradeRules checks a submitted rule set: every rule names a piece and a point, moves
+// it within bounds, and no point is ruled twice.
+func ValidatePatternGradeRules(w PatternGradeRuleWrite) error {
+	if w.TechCardId <= 0 {
+		return NewFieldViolation("tech_card_id", "required", "", "")
+	}
+	if strings.TrimSpace(w.ScopeKey) == "" {
+		return NewFieldViolation("scope_key", "required", "", "")
+	}
+	type point struct {
+		piece string
+		no    int
+	}
+	seen := make(map[point]bool, len(w.Rules))
+	for i, r := range w.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		key := strings.ToUpper(strings.TrimSpace(r.PieceLineKey))
+		if key == "" {
+			return NewFieldViolation(field+".piece_line_key", "required", "", "")
+		}
+		if r.PointNo < 0 {
+			return NewFieldViolation(field+".point_no", "must_not_be_negative", "", "")
+		}
+		if r.DxCm.Abs().GreaterThan(maxGradeDeltaCm) || r.DyCm.Abs().GreaterThan(maxGradeDeltaCm) {
+			return NewFieldViolation(field, "delta_too_large", "",
+				fmt.Sprintf("a point moves at most %s cm per size step", maxGradeDeltaCm))
+		}
+		if !r.DxCm.Equal(r.DxCm.Round(3)) || !r.DyCm.Equal(r.DyCm.Round(3)) {
+			return NewFieldViolation(field, "too_precise", "", "grade deltas are stored to 0.001 cm")
+		}
+		p := point{key, r.PointNo}
+		if seen[p] {
+			return NewFieldViolation(field, "duplicate", "", "each point of a piece takes one rule")
+		}
+		seen[p] = true
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestValidatePatternGradeRules(t *testing.T) {
+	ok := PatternGradeRuleWrite{TechCardId: 1, ScopeKey: "shell", Rules: []PatternGradeRule{
+		{PieceLineKey: "p1", PointNo: 0},
+		{PieceLineKey: "P1", PointNo: 3, DxCm: decimal.RequireFromString("0.5"), DyCm: decimal.RequireFromString("-1.25")},
+	}}
+	if err := ValidatePatternGradeRules(ok); err != nil {
+		t.Fatalf("valid set refused: %v", err)
+	}
+
+	cases := map[string]struct {
+		rule   PatternGradeRule
+		field  string
+		reason string
+	}{
+		"no piece":       {PatternGradeRule{PointNo: 1}, "rules[2].piece_line_key", "required"},
+		"negative point": {PatternGradeRule{PieceLineKey: "P2", PointNo: -1}, "rules[2].point_no", "must_not_be_negative"},
+		"metre per step": {PatternGradeRule{PieceLineKey: "P2", DxCm: decimal.NewFromInt(150)}, "rules[2]", "delta_too_large"},
+		"too precise":    {PatternGradeRule{PieceLineKey: "P2", DyCm: decimal.RequireFromString("0.0005")}, "rules[2]", "too_precise"},
+		"same point":     {PatternGradeRule{PieceLineKey: " p1 ", PointNo: 3}, "rules[2]", "duplicate"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := ok
+			w.Rules = append(append([]PatternGradeRule{}, ok.Rules...), c.rule)
+			var ve *ValidationError
+			if err := ValidatePatternGradeRules(w); !errors.As(err, &ve) || ve.Field != c.field || ve.Reason != c.reason {
+				t.Fatalf("err = %#v, want %s: %s", err, c.field, c.reason)
+			}
+		})
+	}
+}
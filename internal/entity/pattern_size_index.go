@@ -16,7 +16,8 @@ import (
 // files every sheet of a card under the smallest size of the range and says so in its own comment.
 // The existing readiness row that counts DISTINCT size_id therefore does not merely approximate the
 // truth — it reports a different quantity and calls it coverage. The real answer lives in the DXF
-// block names, the server does not parse DXF, and it is not going to start.
+// block names. The client parses them; since MeasureTechCardPatternScope the server can read them
+// too (dxfpattern), and it writes through this same row under the same fingerprint rule.
 //
 // SO THE CLIENT PARSES AND THE SERVER STORES — but only the half that cannot be forged in the
 // dangerous direction. The dangerous direction is CLAIMING COVERAGE THAT DOES NOT EXIST, and it is
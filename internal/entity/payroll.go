@@ -0,0 +1,220 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// СДЕЛЬНАЯ ОПЛАТА (employee_pay_period / payroll_payslip, migration 0341).
+//
+// Зарплата сдельщика считается из сканирований купонов пачек (ProductionRunBundleScan): кто, какую
+// операцию, сколько изделий. Период оплаты говорит, по какой схеме и ставкам; расчётный листок за
+// месяц — результат, черновиком пересчитываемый, утверждённым замороженный и проведённый в журнал
+// (AcctSourcePayroll). Утверждённый листок ЗАМЕНЯЕТ плоскую строку OPEX сотрудника за месяц.
+
+// PayScheme is how a pay period turns recorded work into money.
+type PayScheme string
+
+const (
+	// PaySchemePieceRate pays each operation: its work's per-piece rate when the period has one,
+	// else the operation's standard minutes × the period's per-minute rate.
+	PaySchemePieceRate PayScheme = "piece_rate"
+	// PaySchemeEfficiency pays earned standard minutes × the per-minute rate and needs the attended
+	// minutes, from which the efficiency is read. Per-piece rates do not apply.
+	PaySchemeEfficiency PayScheme = "efficiency"
+)
+
+// ValidPaySchemes mirrors chk_epp_scheme / chk_pp_scheme (0341).
+var ValidPaySchemes = map[PayScheme]bool{
+	PaySchemePieceRate:  true,
+	PaySchemeEfficiency: true,
+}
+
+// EmployeePieceRate is a per-piece rate for one kind of work (operation_work token).
+type EmployeePieceRate struct {
+	Work         string          `db:"work_token"`
+	RatePerPiece decimal.Decimal `db:"rate_per_piece"`
+}
+
+// EmployeePayPeriodInsert is the writable payload of a pay period. ValidFrom / ValidTo are firsts of
+// months; ValidTo is the LAST month of the period, inclusive, invalid while the period is open.
+type EmployeePayPeriodInsert struct {
+	EmployeeId              int                 `db:"employee_id"`
+	Scheme                  PayScheme           `db:"scheme"`
+	ValidFrom               time.Time           `db:"valid_from"`
+	ValidTo                 sql.NullTime        `db:"valid_to"`
+	Currency                string              `db:"currency"`
+	RatePerMinute           decimal.NullDecimal `db:"rate_per_minute"`
+	GuaranteedRatePerMinute decimal.NullDecimal `db:"guaranteed_rate_per_minute"`
+	Note                    sql.NullString      `db:"note"`
+	PieceRates              []EmployeePieceRate `db:"-"`
+}
+
+// Covers reports whether the period pays the month (any time within it).
+func (p EmployeePayPeriodInsert) Covers(month time.Time) bool {
+	m := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := time.Date(p.ValidFrom.Year(), p.ValidFrom.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if m.Before(from) {
+		return false
+	}
+	if !p.ValidTo.Valid {
+		return true
+	}
+	to := time.Date(p.ValidTo.Time.Year(), p.ValidTo.Time.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return !m.After(to)
+}
+
+// EmployeePayPeriod is a stored pay period.
+type EmployeePayPeriod struct {
+	Id int `db:"id"`
+	EmployeePayPeriodInsert
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// PayrollWorkFact is an employee's recorded work on one operation of one run within a month: the
+// bundle scans summed, with the operation's snapshot (work, SMV) the pay is computed from.
+type PayrollWorkFact struct {
+	RunId         int                 `db:"run_id"`
+	OperationSeq  int                 `db:"operation_seq"`
+	OperationType string              `db:"operation_type"`
+	Work          sql.NullString      `db:"work"`
+	SMV           decimal.NullDecimal `db:"smv"`
+	Qty           int                 `db:"qty"`
+	Bundles       int                 `db:"bundles"`
+}
+
+// PayslipLineBasis says how a payslip line was priced.
+type PayslipLineBasis string
+
+const (
+	// PayslipLineBasisPiece — qty × the work's per-piece rate.
+	PayslipLineBasisPiece PayslipLineBasis = "piece"
+	// PayslipLineBasisMinute — qty × SMV × the per-minute rate.
+	PayslipLineBasisMinute PayslipLineBasis = "minute"
+	// PayslipLineBasisUnpriced — neither applies (no rate for the work and no SMV, or no per-minute
+	// rate). The work is shown and counted in UnpricedQty, never priced by guess.
+	PayslipLineBasisUnpriced PayslipLineBasis = "unpriced"
+)
+
+// PayslipLine is one operation of one run on a payslip.
+type PayslipLine struct {
+	RunId           int                 `db:"run_id"`
+	OperationSeq    int                 `db:"operation_seq"`
+	OperationType   string              `db:"operation_type"`
+	Work            sql.NullString      `db:"work"`
+	Basis           PayslipLineBasis    `db:"basis"`
+	Qty             int                 `db:"qty"`
+	Bundles         int                 `db:"bundles"`
+	SMV             decimal.NullDecimal `db:"smv"`
+	StandardMinutes decimal.NullDecimal `db:"standard_minutes"`
+	Rate            decimal.NullDecimal `db:"rate"`
+	Amount          decimal.Decimal     `db:"amount"`
+}
+
+// PayslipTotals is what a computation yields besides the lines.
+type PayslipTotals struct {
+	StandardMinutes decimal.Decimal     `db:"standard_minutes"`
+	PieceEarnings   decimal.Decimal     `db:"piece_earnings"`
+	GuaranteedPay   decimal.NullDecimal `db:"guaranteed_pay"`
+	Gross           decimal.Decimal     `db:"gross"`
+	UnpricedQty     int                 `db:"unpriced_qty"`
+}
+
+// PayslipStatus is the payslip lifecycle: draft (recomputable) → approved (frozen, posted).
+type PayslipStatus string
+
+const (
+	PayslipStatusDraft    PayslipStatus = "draft"
+	PayslipStatusApproved PayslipStatus = "approved"
+)
+
+// PayslipCompute is the input of a (re)computation: the month and what only a person knows — the
+// minutes attended (timesheet) and a manual adjustment.
+type PayslipCompute struct {
+	EmployeeId      int
+	Month           time.Time
+	AttendedMinutes decimal.NullDecimal
+	Adjustment      decimal.Decimal
+	AdjustmentNote  sql.NullString
+	ComputedBy      string
+}
+
+// Payslip is a stored payslip with its lines. Scheme, currency and rates are the snapshot of the
+// period the payslip was computed with.
+type Payslip struct {
+	Id                      int                 `db:"id"`
+	EmployeeId              int                 `db:"employee_id"`
+	EmployeeName            string              `db:"employee_name"`
+	Month                   time.Time           `db:"month"`
+	PeriodId                sql.NullInt64       `db:"period_id"`
+	Scheme                  PayScheme           `db:"scheme"`
+	Currency                string              `db:"currency"`
+	RatePerMinute           decimal.NullDecimal `db:"rate_per_minute"`
+	GuaranteedRatePerMinute decimal.NullDecimal `db:"guaranteed_rate_per_minute"`
+	Status                  PayslipStatus       `db:"status"`
+	AttendedMinutes         decimal.NullDecimal `db:"attended_minutes"`
+	PayslipTotals
+	Adjustment     decimal.Decimal     `db:"adjustment"`
+	AdjustmentNote sql.NullString      `db:"adjustment_note"`
+	GrossBase      decimal.NullDecimal `db:"gross_base"`
+	ComputedBy     string              `db:"computed_by"`
+	ComputedAt     time.Time           `db:"computed_at"`
+	ApprovedBy     sql.NullString      `db:"approved_by"`
+	ApprovedAt     sql.NullTime        `db:"approved_at"`
+	CreatedAt      time.Time           `db:"created_at"`
+	UpdatedAt      time.Time           `db:"updated_at"`
+	Lines          []PayslipLine       `db:"-"`
+}
+
+// EfficiencyPct is earned standard minutes over attended minutes, in percent; invalid without
+// attended minutes.
+func (p Payslip) EfficiencyPct() decimal.NullDecimal {
+	if !p.AttendedMinutes.Valid || !p.AttendedMinutes.Decimal.IsPositive() {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NewNullDecimal(p.StandardMinutes.Div(p.AttendedMinutes.Decimal).Mul(decimal.NewFromInt(100)).Round(1))
+}
+
+// PayslipFilter narrows ListPayslips; zero values do not filter.
+type PayslipFilter struct {
+	EmployeeId int
+	From       time.Time // first month, inclusive
+	To         time.Time // last month, inclusive
+	Status     PayslipStatus
+}
+
+// ErrPayPeriodNotFound is returned when a pay period does not exist, or when no period of the
+// employee covers the month a payslip is computed for.
+var ErrPayPeriodNotFound = errors.New("pay period not found")
+
+// ErrPayPeriodOverlap refuses a pay period that shares a month with another period of the employee.
+var ErrPayPeriodOverlap = errors.New("pay period overlaps another period of the employee")
+
+// ErrPayslipNotFound is returned when a payslip does not exist.
+var ErrPayslipNotFound = errors.New("payslip not found")
+
+// ErrPayslipApproved refuses changing an approved payslip, or the work recorded in its month. Reopen
+// the payslip first.
+var ErrPayslipApproved = errors.New("payslip is approved")
+
+// ErrPayslipStale refuses approving a payslip whose month's recorded work changed after it was
+// computed: what was reviewed is not what would be paid. Recompute, review, approve.
+var ErrPayslipStale = errors.New("payslip is out of date with the recorded work")
+
+// ErrPayslipNotApproved refuses reopening a draft.
+var ErrPayslipNotApproved = errors.New("payslip is not approved")
+
+// ErrPayslipOpexOverlap refuses approving a payslip for a month the employee's flat salary template
+// has already been booked into OPEX: both would reach the ledger as salary.
+var ErrPayslipOpexOverlap = errors.New("salary already booked as OPEX for this month")
+
+// ErrPayslipFxMissing refuses approving a payslip whose currency has no rate at the month end.
+var ErrPayslipFxMissing = errors.New("no FX rate for payslip currency at month end")
+
+// ErrEmployeeNotFound is returned when a pay operation names an employee that does not exist.
+var ErrEmployeeNotFound = errors.New("employee not found")
@@ -179,3 +179,42 @@ type PieceAreaScope struct {
 	// «measured on {date}, patterns changed since».
 	CurrentFingerprint string
 }
+
+// PatternScopeSource is everything the server needs to measure one fabric scope from its own files
+// (dxfpattern.Measure): the scope's sheets, its блок→деталь links, the card's pieces and size range —
+// resolved through entity.FabricScopeIdentity exactly as SaveTechCardPieceAreas resolves them, so the
+// server's measurement addresses the same set the write will check it against.
+type PatternScopeSource struct {
+	TechCardId int
+	ScopeKey   string
+	Sheets     []PatternScopeSheet
+	Blocks     []PieceAreaBlockRef
+	Pieces     []PatternScopePiece
+	SizeIds    []int
+}
+
+// PatternScopeSheet is one pattern sheet of the scope.
+type PatternScopeSheet struct {
+	LineKey  string `db:"line_key"`
+	URL      string `db:"url"`
+	Version  int    `db:"version"`
+	Filename string `db:"filename"`
+}
+
+// IsDXF reports whether the sheet is a DXF. The object extension is the file type — UploadPattern
+// picks it from the sniffed bytes and there is no content-type column anywhere.
+func (s PatternScopeSheet) IsDXF() bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(s.URL)), ".dxf")
+}
+
+// Ref is the sheet as the fingerprint sees it.
+func (s PatternScopeSheet) Ref() PatternSheetRef {
+	return PatternSheetRef{LineKey: s.LineKey, URL: s.URL, Version: s.Version}
+}
+
+// PatternScopePiece is one cut piece of the card, as the measurement names and grades it.
+type PatternScopePiece struct {
+	LineKey  string `db:"line_key"`
+	Name     string `db:"name"`
+	Ungraded bool   `db:"ungraded"`
+}
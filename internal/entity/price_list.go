@@ -0,0 +1,255 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/currency"
+	"github.com/shopspring/decimal"
+)
+
+// ПРАЙС-ЛИСТЫ РЫНКОВ (price_list / price_list_pin, 0349): цены одной валюты, выведенные из базовой
+// по курсу costing_fx_rate (fxsync, ECB), с наценкой рынка и правилом округления. Публикация листа
+// переписывает product_price этой валюты; до публикации лист — только правило и предпросмотр.
+
+// PriceRounding is how a derived price is finished. The currency's own precision always applies
+// on top (JPY/KRW have no minor units whatever the rule says), so «none» on JPY is a whole yen.
+type PriceRounding string
+
+const (
+	// PriceRoundingNone rounds to the currency's precision, half up.
+	PriceRoundingNone PriceRounding = "none"
+	// PriceRoundingEnd0 / End5 round UP to the next multiple of 10 / 5 whole units.
+	PriceRoundingEnd0 PriceRounding = "end_0"
+	PriceRoundingEnd5 PriceRounding = "end_5"
+	// PriceRoundingEnd9 rounds UP to the next whole number ending in 9 (120 → 129, 129 → 129).
+	PriceRoundingEnd9 PriceRounding = "end_9"
+	// PriceRoundingEnd99 rounds UP to the next x.99 — meaningless, and refused, for a zero-decimal
+	// currency.
+	PriceRoundingEnd99 PriceRounding = "end_99"
+)
+
+// ValidPriceRoundings mirrors chk_price_list_rounding.
+var ValidPriceRoundings = map[PriceRounding]bool{
+	PriceRoundingNone:  true,
+	PriceRoundingEnd0:  true,
+	PriceRoundingEnd5:  true,
+	PriceRoundingEnd9:  true,
+	PriceRoundingEnd99: true,
+}
+
+// Markup bounds, mirrored by chk_price_list_markup. Below −100% a price would be negative; past
+// 500% the number is a typo, not a market.
+var (
+	minPriceListMarkupPct = decimal.NewFromInt(-100)
+	maxPriceListMarkupPct = decimal.NewFromInt(500)
+)
+
+var (
+	// ErrPriceListNotFound is returned for an unknown price list id.
+	ErrPriceListNotFound = errors.New("price list not found")
+	// ErrPriceListRateMissing is returned when there is no FX rate for the list's currency: nothing
+	// can be derived, and publishing would write nothing but pins.
+	ErrPriceListRateMissing = errors.New("no FX rate for the price list currency")
+	// ErrPriceListRateMoved is returned when the rate changed between the preview the caller saw and
+	// the publish: the prices on screen are not the prices that would be written.
+	ErrPriceListRateMoved = errors.New("the FX rate changed since the preview")
+)
+
+// PriceList is the rule deriving one currency's colourway prices from the base currency.
+type PriceList struct {
+	Id     int    `db:"id"`
+	Name   string `db:"name"`
+	Market string `db:"market"`
+	// Currency is UPPERCASE and unique: product_price holds one price per (colourway, currency), and
+	// a second list of the same currency would write the same cell.
+	Currency  string          `db:"currency"`
+	MarkupPct decimal.Decimal `db:"markup_pct"`
+	Rounding  PriceRounding   `db:"rounding"`
+	Note      sql.NullString  `db:"note"`
+	// LastPublished* record the last publish: when, by whom and at which rate to base.
+	LastPublishedAt   sql.NullTime        `db:"last_published_at"`
+	LastPublishedBy   string              `db:"last_published_by"`
+	LastPublishedRate decimal.NullDecimal `db:"last_published_rate"`
+	CreatedBy         string              `db:"created_by"`
+	CreatedAt         time.Time           `db:"created_at"`
+	UpdatedAt         time.Time           `db:"updated_at"`
+	Pins              []PriceListPin      `db:"-"`
+}
+
+// PriceListInsert is the writable part of a price list. Pins are a full replace.
+type PriceListInsert struct {
+	Name      string
+	Market    string
+	Currency  string
+	MarkupPct decimal.Decimal
+	Rounding  PriceRounding
+	Note      sql.NullString
+	Pins      []PriceListPin
+	CreatedBy string
+}
+
+// PriceListPin is a colourway price the list takes as is instead of deriving it.
+type PriceListPin struct {
+	ProductId int             `db:"product_id"`
+	Price     decimal.Decimal `db:"price"`
+}
+
+// MaxPriceListPins bounds one list's pins — a pin is an exception, not a second price table.
+const MaxPriceListPins = 1000
+
+// ValidatePriceListInsert normalises and checks a price list payload against the base currency it
+// derives from.
+func ValidatePriceListInsert(ins *PriceListInsert, baseCurrency string) error {
+	ins.Name = strings.TrimSpace(ins.Name)
+	ins.Market = strings.TrimSpace(ins.Market)
+	ins.Currency = strings.ToUpper(strings.TrimSpace(ins.Currency))
+	if ins.Rounding == "" {
+		ins.Rounding = PriceRoundingNone
+	}
+	switch {
+	case ins.Name == "":
+		return NewFieldViolation("name", "required", "", "name the list, e.g. «Japan retail»")
+	case len(ins.Name) > 255:
+		return NewFieldViolation("name", "too_long", "", "keep the name within 255 characters")
+	case len(ins.Market) > 64:
+		return NewFieldViolation("market", "too_long", "", "the market is a short label, at most 64 characters")
+	case !currency.IsSupported(ins.Currency):
+		return NewFieldViolation("currency", "not_selling", ins.Currency, "a price list must be in a currency the shop sells in")
+	case strings.EqualFold(ins.Currency, baseCurrency):
+		return NewFieldViolation("currency", "base_currency", ins.Currency, "the base currency is the source of every list; edit base prices on the colourway")
+	case ins.MarkupPct.LessThanOrEqual(minPriceListMarkupPct) || ins.MarkupPct.GreaterThan(maxPriceListMarkupPct):
+		return NewFieldViolation("markup_pct", "out_of_range", ins.MarkupPct.String(),
+			fmt.Sprintf("the markup is a percentage above %s and at most %s", minPriceListMarkupPct, maxPriceListMarkupPct))
+	case !ValidPriceRoundings[ins.Rounding]:
+		return NewFieldViolation("rounding", "invalid", string(ins.Rounding), "use none, end_0, end_5, end_9 or end_99")
+	case ins.Rounding == PriceRoundingEnd99 && currency.IsZeroDecimal(ins.Currency):
+		return NewFieldViolation("rounding", "no_minor_units", ins.Currency, "a zero-decimal currency cannot end in .99; use end_9 or end_0")
+	case len(ins.Pins) > MaxPriceListPins:
+		return NewFieldViolation("pins", "too_many", "", fmt.Sprintf("at most %d pinned prices per list", MaxPriceListPins))
+	}
+	seen := make(map[int]bool, len(ins.Pins))
+	for i, p := range ins.Pins {
+		field := fmt.Sprintf("pins[%d]", i)
+		switch {
+		case p.ProductId <= 0:
+			return NewFieldViolation(field+".product_id", "required", "", "")
+		case seen[p.ProductId]:
+			return NewFieldViolation(field+".product_id", "duplicate", fmt.Sprint(p.ProductId), "pin a colourway once")
+		case !p.Price.IsPositive():
+			return NewFieldViolation(field+".price", "out_of_range", p.Price.String(), "a pinned price is positive")
+		}
+		if err := currency.ValidateMinimum(currency.Round(p.Price, ins.Currency), ins.Currency); err != nil {
+			return NewFieldViolation(field+".price", "below_minimum", p.Price.String(), err.Error())
+		}
+		seen[p.ProductId] = true
+	}
+	return nil
+}
+
+// RoundPriceEnding finishes a price by the rule, in the currency's precision. Every rule but «none»
+// rounds UP: a market price is never quietly cut below what the rate and the markup derived.
+func RoundPriceEnding(p decimal.Decimal, rule PriceRounding, cur string) decimal.Decimal {
+	ten, five := decimal.NewFromInt(10), decimal.NewFromInt(5)
+	switch rule {
+	case PriceRoundingEnd0:
+		p = p.Div(ten).Ceil().Mul(ten)
+	case PriceRoundingEnd5:
+		p = p.Div(five).Ceil().Mul(five)
+	case PriceRoundingEnd9:
+		n := p.Ceil()
+		p = n.Add(decimal.NewFromInt(9).Sub(n.Mod(ten)))
+	case PriceRoundingEnd99:
+		if !currency.IsZeroDecimal(cur) {
+			cent := decimal.New(1, -2)
+			p = p.Add(cent).Ceil().Sub(cent)
+		}
+	}
+	return currency.Round(p, cur)
+}
+
+// DerivePrice is one colourway's price in the list's currency:
+//
+//	base ÷ rate_to_base × (1 + markup/100), finished by RoundPriceEnding
+//
+// rate_to_base is costing_fx_rate's: how many base units one unit of the currency is worth, so the
+// base price is DIVIDED by it. ok=false for a non-positive rate.
+func DerivePrice(base, rateToBase, markupPct decimal.Decimal, rule PriceRounding, cur string) (decimal.Decimal, bool) {
+	if !rateToBase.IsPositive() {
+		return decimal.Zero, false
+	}
+	hundred := decimal.NewFromInt(100)
+	p := base.Div(rateToBase).Mul(hundred.Add(markupPct)).Div(hundred)
+	return RoundPriceEnding(p, rule, cur), true
+}
+
+// PriceListSource is one colourway as a price list reads it.
+type PriceListSource struct {
+	ProductId    int                 `db:"product_id"`
+	SKU          string              `db:"sku"`
+	Color        string              `db:"color"`
+	BasePrice    decimal.NullDecimal `db:"base_price"`
+	CurrentPrice decimal.NullDecimal `db:"current_price"`
+}
+
+// PriceListLine is one colourway of a preview: what is there now and what a publish would write.
+// NewPrice invalid = the publish leaves this colourway alone, and Skip says why.
+type PriceListLine struct {
+	PriceListSource
+	NewPrice decimal.NullDecimal
+	Pinned   bool
+	Skip     string
+}
+
+// Changed reports whether publishing would write a different price.
+func (l PriceListLine) Changed() bool {
+	return l.NewPrice.Valid && (!l.CurrentPrice.Valid || !l.CurrentPrice.Decimal.Equal(l.NewPrice.Decimal))
+}
+
+// PriceListPreview is everything a publish would do, computed but not written.
+type PriceListPreview struct {
+	List       PriceList
+	RateToBase decimal.NullDecimal
+	Lines      []PriceListLine
+}
+
+// BuildPriceListLines derives every line of a list. Pure: the store loads the colourways, the pins
+// and the rate, and the same function answers the preview and drives the publish, so the two
+// cannot disagree.
+func BuildPriceListLines(list PriceList, rateToBase decimal.NullDecimal, sources []PriceListSource) []PriceListLine {
+	pins := make(map[int]decimal.Decimal, len(list.Pins))
+	for _, p := range list.Pins {
+		pins[p.ProductId] = p.Price
+	}
+	out := make([]PriceListLine, 0, len(sources))
+	for _, src := range sources {
+		line := PriceListLine{PriceListSource: src}
+		if pin, ok := pins[src.ProductId]; ok {
+			line.Pinned = true
+			line.NewPrice = decimal.NullDecimal{Decimal: currency.Round(pin, list.Currency), Valid: true}
+			out = append(out, line)
+			continue
+		}
+		switch {
+		case !src.BasePrice.Valid:
+			line.Skip = "no base price"
+		case !rateToBase.Valid:
+			line.Skip = "no FX rate for " + list.Currency
+		default:
+			p, ok := DerivePrice(src.BasePrice.Decimal, rateToBase.Decimal, list.MarkupPct, list.Rounding, list.Currency)
+			switch {
+			case !ok:
+				line.Skip = "no FX rate for " + list.Currency
+			case currency.ValidateMinimum(p, list.Currency) != nil:
+				line.Skip = fmt.Sprintf("%s %s is below the currency minimum", p, list.Currency)
+			default:
+				line.NewPrice = decimal.NullDecimal{Decimal: p, Valid: true}
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
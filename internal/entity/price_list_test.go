@@ -0,0 +1,122 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRoundPriceEnding(t *testing.T) {
+	cases := []struct {
+		in   string
+		rule PriceRounding
+		cur  string
+		want string
+	}{
+		{"123.456", PriceRoundingNone, "USD", "123.46"},
+		{"123.456", PriceRoundingNone, "JPY", "123"},
+		{"121", PriceRoundingEnd0, "GBP", "130"},
+		{"120", PriceRoundingEnd0, "GBP", "120"},
+		{"121", PriceRoundingEnd5, "GBP", "125"},
+		{"120.2", PriceRoundingEnd9, "USD", "129"},
+		{"129", PriceRoundingEnd9, "USD", "129"},
+		{"130", PriceRoundingEnd9, "USD", "139"},
+		{"14823.4", PriceRoundingEnd9, "JPY", "14829"},
+		{"120.2", PriceRoundingEnd99, "USD", "120.99"},
+		{"120.99", PriceRoundingEnd99, "USD", "120.99"},
+		{"121", PriceRoundingEnd99, "USD", "121.99"},
+		// .99 has no meaning in yen: the rule falls through to whole units.
+		{"14823.4", PriceRoundingEnd99, "JPY", "14823"},
+	}
+	for _, c := range cases {
+		got := RoundPriceEnding(decimal.RequireFromString(c.in), c.rule, c.cur)
+		if got.String() != c.want {
+			t.Errorf("RoundPriceEnding(%s, %s, %s) = %s; want %s", c.in, c.rule, c.cur, got, c.want)
+		}
+	}
+}
+
+func TestDerivePrice(t *testing.T) {
+	// 100 EUR, 1 JPY = 0.0062 EUR, +10% → 17741.9… → end_9 → 17749.
+	p, ok := DerivePrice(decimal.NewFromInt(100), decimal.RequireFromString("0.0062"), decimal.NewFromInt(10), PriceRoundingEnd9, "JPY")
+	if !ok || p.String() != "17749" {
+		t.Fatalf("JPY derive = %s, %v", p, ok)
+	}
+	// 100 EUR, 1 GBP = 1.17 EUR, no markup → 85.47.
+	p, ok = DerivePrice(decimal.NewFromInt(100), decimal.RequireFromString("1.17"), decimal.Zero, PriceRoundingNone, "GBP")
+	if !ok || p.String() != "85.47" {
+		t.Fatalf("GBP derive = %s, %v", p, ok)
+	}
+	if _, ok := DerivePrice(decimal.NewFromInt(100), decimal.Zero, decimal.Zero, PriceRoundingNone, "GBP"); ok {
+		t.Fatal("a zero rate must not derive")
+	}
+}
+
+func TestBuildPriceListLines(t *testing.T) {
+	nd := func(s string) decimal.NullDecimal {
+		return decimal.NullDecimal{Decimal: decimal.RequireFromString(s), Valid: true}
+	}
+	list := PriceList{Currency: "GBP", Rounding: PriceRoundingEnd0, Pins: []PriceListPin{{ProductId: 2, Price: decimal.RequireFromString("99.999")}}}
+	sources := []PriceListSource{
+		{ProductId: 1, BasePrice: nd("117"), CurrentPrice: nd("100")},
+		{ProductId: 2, BasePrice: nd("117")},
+		{ProductId: 3},
+	}
+	lines := BuildPriceListLines(list, nd("1.17"), sources)
+	if len(lines) != 3 {
+		t.Fatalf("one line per colourway: %d", len(lines))
+	}
+	if l := lines[0]; l.NewPrice.Decimal.String() != "100" || l.Changed() {
+		t.Fatalf("derived price equal to the current one is not a change: %+v", l)
+	}
+	if l := lines[1]; !l.Pinned || l.NewPrice.Decimal.String() != "100" || !l.Changed() {
+		t.Fatalf("a pin is taken as is, in the currency's precision: %+v", l)
+	}
+	if l := lines[2]; l.NewPrice.Valid || l.Skip != "no base price" {
+		t.Fatalf("no base price must be skipped: %+v", l)
+	}
+
+	// 0.20 EUR at 0.0062 → 32 yen, under the 50 yen minimum.
+	jpy := PriceList{Currency: "JPY", Rounding: PriceRoundingNone}
+	lines = BuildPriceListLines(jpy, nd("0.0062"), []PriceListSource{{ProductId: 4, BasePrice: nd("0.2")}})
+	if l := lines[0]; l.NewPrice.Valid || l.Skip != "32 JPY is below the currency minimum" {
+		t.Fatalf("below the minimum must be skipped: %+v", l)
+	}
+
+	lines = BuildPriceListLines(list, decimal.NullDecimal{}, sources[:1])
+	if lines[0].NewPrice.Valid || lines[0].Skip != "no FX rate for GBP" {
+		t.Fatalf("no rate must be skipped: %+v", lines[0])
+	}
+}
+
+func TestValidatePriceListInsert(t *testing.T) {
+	ok := PriceListInsert{Name: " Japan ", Currency: "jpy", MarkupPct: decimal.NewFromInt(12)}
+	if err := ValidatePriceListInsert(&ok, "EUR"); err != nil {
+		t.Fatalf("valid list: %v", err)
+	}
+	if ok.Name != "Japan" || ok.Currency != "JPY" || ok.Rounding != PriceRoundingNone {
+		t.Fatalf("not normalised: %+v", ok)
+	}
+
+	cases := []struct {
+		ins   PriceListInsert
+		field string
+		why   string
+	}{
+		{PriceListInsert{Currency: "JPY"}, "name", "required"},
+		{PriceListInsert{Name: "x", Currency: "XYZ"}, "currency", "not_selling"},
+		{PriceListInsert{Name: "x", Currency: "eur"}, "currency", "base_currency"},
+		{PriceListInsert{Name: "x", Currency: "JPY", MarkupPct: decimal.NewFromInt(-100)}, "markup_pct", "out_of_range"},
+		{PriceListInsert{Name: "x", Currency: "JPY", Rounding: "end_7"}, "rounding", "invalid"},
+		{PriceListInsert{Name: "x", Currency: "JPY", Rounding: PriceRoundingEnd99}, "rounding", "no_minor_units"},
+		{PriceListInsert{Name: "x", Currency: "GBP", Pins: []PriceListPin{{ProductId: 1, Price: decimal.NewFromInt(5)}, {ProductId: 1, Price: decimal.NewFromInt(5)}}}, "pins[1].product_id", "duplicate"},
+		{PriceListInsert{Name: "x", Currency: "GBP", Pins: []PriceListPin{{ProductId: 1, Price: decimal.RequireFromString("0.01")}}}, "pins[0].price", "below_minimum"},
+	}
+	for _, c := range cases {
+		var ve *ValidationError
+		if err := ValidatePriceListInsert(&c.ins, "EUR"); !errors.As(err, &ve) || ve.Field != c.field || ve.Reason != c.why {
+			t.Errorf("%+v: got %v; want %s/%s", c.ins, err, c.field, c.why)
+		}
+	}
+}
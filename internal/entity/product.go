@@ -515,6 +515,15 @@ const (
 	// production run (Phase 6). reference_id carries the reversed receipt as receipt<id>; the
 	// operator's reason rides in comment.
 	StockChangeSourceProductionReversed StockChangeSource = "production_reversed"
+	// StockChangeSourceOrderExchange moves the replacement size of an exchange return (0333): out
+	// when the return is requested, back in if the return is later rejected or cancelled.
+	// order_uuid carries the original order; the returned unit itself journals as order_returned.
+	StockChangeSourceOrderExchange StockChangeSource = "order_exchange"
+	// StockChangeSourceLocationTransfer moves units between stock locations (0345) without changing the
+	// variant's total: a transfer document leaving or arriving, or a paid order allocated to (or
+	// released from) a location. Its rows always carry location_id, and quantity_before/after are that
+	// location's quantities, not the total's.
+	StockChangeSourceLocationTransfer StockChangeSource = "location_transfer"
 )
 
 // StockChangeReason represents the reason for a stock change.
@@ -541,6 +550,12 @@ const (
 	StockChangeReasonOrderCancelled StockChangeReason = "order_cancelled"
 	// production_reversed reasons
 	StockChangeReasonReceiptReversed StockChangeReason = "receipt_reversed"
+	// order_exchange reasons
+	StockChangeReasonExchange StockChangeReason = "exchange"
+	// location_transfer reasons
+	StockChangeReasonTransferOut     StockChangeReason = "transfer_out"
+	StockChangeReasonTransferIn      StockChangeReason = "transfer_in"
+	StockChangeReasonOrderAllocation StockChangeReason = "order_allocation"
 )
 
 // ValidReasonsForSource maps each source to its allowed reasons. A source present with an EMPTY list
@@ -560,6 +575,8 @@ var ValidReasonsForSource = map[StockChangeSource][]StockChangeReason{
 	// A reversal decrement always carries the fixed reason code; the operator's free-text reason
 	// rides in comment and the reversed receipt in reference_id.
 	StockChangeSourceProductionReversed: {StockChangeReasonReceiptReversed},
+	StockChangeSourceOrderExchange:      {StockChangeReasonExchange},
+	StockChangeSourceLocationTransfer:   {StockChangeReasonTransferOut, StockChangeReasonTransferIn, StockChangeReasonOrderAllocation},
 }
 
 // StockChangeSignPositive means the source only allows positive deltas.
@@ -583,6 +600,8 @@ var AllowedSignForSource = map[StockChangeSource]StockChangeSign{
 	StockChangeSourceOrderCancelled:     StockChangeSignPositive,
 	StockChangeSourceProductionReceived: StockChangeSignPositive,
 	StockChangeSourceProductionReversed: StockChangeSignNegative,
+	StockChangeSourceOrderExchange:      StockChangeSignBoth,
+	StockChangeSourceLocationTransfer:   StockChangeSignBoth,
 }
 
 // IsValidReasonForSource checks if a reason is valid for a given source.
@@ -714,6 +733,9 @@ type StockChangeInsert struct {
 	PaidAmount          decimal.NullDecimal `db:"paid_amount"`
 	PayoutBaseAmount    decimal.NullDecimal `db:"payout_base_amount"`
 	PayoutBaseCurrency  sql.NullString      `db:"payout_base_currency"`
+	// LocationId is the stock location the movement happened in (0345); NULL means the default
+	// location, which is where every location-unaware writer moves stock.
+	LocationId sql.NullInt32 `db:"location_id"`
 }
 
 // StockChange represents a row from product_stock_change_history.
@@ -738,6 +760,7 @@ type StockChange struct {
 	PaidAmount          string          `db:"paid_amount"`
 	PayoutBaseAmount    string          `db:"payout_base_amount"`
 	PayoutBaseCurrency  string          `db:"payout_base_currency"`
+	LocationId          int             `db:"location_id"` // 0 = default location (0345)
 	CreatedAt           time.Time       `db:"created_at"`
 }
 
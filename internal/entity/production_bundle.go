@@ -0,0 +1,146 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ПАЧКИ И КУПОНЫ (production_run_bundle / production_run_operation / production_run_bundle_scan,
+// migration 0340) — то, что происходит с кроем между настилом и приёмкой.
+//
+// Пачка — стопка деталей ОДНОГО размера с ОДНОЙ укладки раскладки, слои ply_from..ply_to одной
+// секции настила. К пачке печатается ярлык с купоном на каждую операцию тех-карты; швея,
+// выполнившая операцию, сканирует купон (/api/bt/{token}, internal/bundleticket), и это
+// сканирование — единственный факт выполнения. WIP прогона и сдельная оплата считаются по нему.
+//
+// ОПЕРАЦИИ — СНИМОК, а не ссылка на карту: операции тех-карты full-replace на каждом её
+// сохранении, и ссылка на их id осиротила бы все сканирования первым же сохранением карты. Снимок
+// берётся при генерации пачек по той же спецификации, по которой кроят (cutspec.Resolve), и
+// держит ровно то, что напечатано на купонах.
+
+// ProductionRunOperation is one step of the run's operation snapshot.
+type ProductionRunOperation struct {
+	Id              int                 `db:"id"`
+	RunId           int                 `db:"run_id"`
+	Seq             int                 `db:"seq"`
+	OperationNumber sql.NullInt32       `db:"operation_number"`
+	OperationType   string              `db:"operation_type"`
+	Zone            string              `db:"zone"`
+	MachineType     sql.NullString      `db:"machine_type"`
+	Work            sql.NullString      `db:"work"` // operation_work token; invalid = the step names no work
+	SMV             decimal.NullDecimal `db:"smv"`  // minutes per garment; invalid = no norm on the card
+	Note            sql.NullString      `db:"note"`
+}
+
+// ProductionRunOperationInsert is one step of a new snapshot; Seq is assigned by position.
+type ProductionRunOperationInsert struct {
+	OperationNumber sql.NullInt32
+	OperationType   string
+	Zone            string
+	MachineType     sql.NullString
+	Work            sql.NullString
+	SMV             decimal.NullDecimal
+	Note            sql.NullString
+}
+
+// ProductionRunBundle is one stored bundle with the names a ticket prints.
+type ProductionRunBundle struct {
+	Id       int `db:"id"`
+	RunId    int `db:"run_id"`
+	BundleNo int `db:"bundle_no"`
+	// LayId is invalid when the lay was deleted after the bundles were cut; LayKey still names it.
+	LayId        sql.NullInt64 `db:"lay_id"`
+	LayKey       string        `db:"lay_key"`
+	LayName      string        `db:"lay_name"`
+	SectionKey   string        `db:"section_key"`
+	ColorwayId   int           `db:"colorway_id"`
+	ColorwayName string        `db:"colorway_name"`
+	SizeId       int           `db:"size_id"`
+	SizeName     string        `db:"size_name"`
+	StackNo      int           `db:"stack_no"`
+	PlyFrom      int           `db:"ply_from"`
+	PlyTo        int           `db:"ply_to"`
+	Qty          int           `db:"qty"`
+	CreatedBy    string        `db:"created_by"`
+	CreatedAt    time.Time     `db:"created_at"`
+}
+
+// ProductionRunBundleInsert is one bundle of a new set; BundleNo is assigned by position.
+type ProductionRunBundleInsert struct {
+	LayId      int
+	LayKey     string
+	SectionKey string
+	ColorwayId int
+	SizeId     int
+	StackNo    int
+	PlyFrom    int
+	PlyTo      int
+}
+
+// Qty is the number of garments in the bundle: one per ply.
+func (b ProductionRunBundleInsert) Qty() int { return b.PlyTo - b.PlyFrom + 1 }
+
+// ProductionRunBundleScanSource says who recorded a completion.
+type ProductionRunBundleScanSource string
+
+const (
+	// ProductionRunBundleScanSourceScan — the operator scanned the coupon on the shop floor.
+	ProductionRunBundleScanSourceScan ProductionRunBundleScanSource = "scan"
+	// ProductionRunBundleScanSourceAdmin — a supervisor recorded it from the admin panel.
+	ProductionRunBundleScanSourceAdmin ProductionRunBundleScanSource = "admin"
+)
+
+// ProductionRunBundleScan is one completed operation on one bundle.
+type ProductionRunBundleScan struct {
+	Id           int                           `db:"id"`
+	BundleId     int                           `db:"bundle_id"`
+	OperationId  int                           `db:"operation_id"`
+	OperationSeq int                           `db:"operation_seq"`
+	EmployeeId   int                           `db:"employee_id"`
+	EmployeeName string                        `db:"employee_name"`
+	Qty          int                           `db:"qty"`
+	Source       ProductionRunBundleScanSource `db:"source"`
+	RecordedBy   string                        `db:"recorded_by"`
+	ScannedAt    time.Time                     `db:"scanned_at"`
+}
+
+// ProductionRunBundleScanInsert records one completion. The quantity is not part of it: a coupon is
+// scanned whole, so the store takes the bundle's own qty.
+type ProductionRunBundleScanInsert struct {
+	BundleId     int
+	OperationSeq int
+	EmployeeId   int
+	Source       ProductionRunBundleScanSource
+	RecordedBy   string
+	ScannedAt    time.Time
+}
+
+// ProductionRunBundleSet is everything the tickets and the WIP view of one run are built from.
+type ProductionRunBundleSet struct {
+	Operations []ProductionRunOperation
+	Bundles    []ProductionRunBundle
+	Scans      []ProductionRunBundleScan
+}
+
+// ErrProductionRunBundlesScanned refuses regenerating the bundles of a run once any coupon has been
+// scanned: the new bundles would not be the ones the work was recorded against.
+var ErrProductionRunBundlesScanned = errors.New("production run bundles already have recorded operations")
+
+// ErrProductionRunBundleNotFound is returned when a bundle does not exist (or not in that run).
+var ErrProductionRunBundleNotFound = errors.New("production run bundle not found")
+
+// ErrProductionRunOperationNotFound is returned when the operation seq is not in the run's snapshot.
+var ErrProductionRunOperationNotFound = errors.New("production run operation not found")
+
+// ErrProductionRunBundleScanNotFound is returned when a scan addressed for deletion does not exist.
+var ErrProductionRunBundleScanNotFound = errors.New("production run bundle scan not found")
+
+// ErrProductionRunBundleScanDuplicate is returned when the operation is already recorded on the
+// bundle. A correction is a delete and a new scan, never a second row.
+var ErrProductionRunBundleScanDuplicate = errors.New("operation already recorded on this bundle")
+
+// ErrEmployeeNotActive is returned when a completion names an unknown or archived employee.
+var ErrEmployeeNotActive = errors.New("employee not found or archived")
@@ -324,6 +324,32 @@ type ProductionRunLayInsert struct {
 	// WITHDRAWS it.
 	LotId  *int
 	Actual *ProductionRunLayActualInput
+	// ExtraLotIds are the OTHER rolls laid into this lay (0348), in spreading order — LotId stays the
+	// roll the width is checked against. Same presence contract as LotId: nil leaves the stored list
+	// alone, a non-nil (possibly empty) slice replaces it whole.
+	ExtraLotIds *[]int
+}
+
+// MaxProductionRunLayExtraLots bounds the extra rolls of one lay. A lay of more rolls than this is
+// a lay that should have been split, and the bound keeps one payload from naming the whole register.
+const MaxProductionRunLayExtraLots = 50
+
+// ProductionRunLayLot is one roll of a lay with the lot's facts joined from material_lot — either
+// the lay's own lot (production_run_lay.lot_id) or one of its extra rolls (production_run_lay_lot,
+// 0348). LotId invalid with a non-empty LotCode is a roll deleted from the register; every joined
+// fact is then invalid too.
+type ProductionRunLayLot struct {
+	LayId            int                 `db:"lay_id"`
+	Position         int                 `db:"position"`
+	LotId            sql.NullInt64       `db:"lot_id"`
+	LotCode          string              `db:"lot_code"`
+	MaterialId       sql.NullInt64       `db:"material_id"`
+	MeasuredWidthCm  decimal.NullDecimal `db:"measured_width_cm"`
+	ShadeCode        sql.NullString      `db:"shade_code"`
+	ShadeBand        sql.NullString      `db:"shade_band"`
+	ShrinkageWarpPct decimal.NullDecimal `db:"shrinkage_warp_pct"`
+	ShrinkageWeftPct decimal.NullDecimal `db:"shrinkage_weft_pct"`
+	MeasuredGsm      decimal.NullDecimal `db:"measured_gsm"`
 }
 
 // ProductionRunLay is a stored lay with its sections and both quantity sets.
@@ -360,6 +386,13 @@ type ProductionRunLay struct {
 	LotMaterialId      sql.NullInt64       `db:"lot_material_id"`
 	LotMeasuredWidthCm decimal.NullDecimal `db:"lot_measured_width_cm"`
 	LotShadeCode       sql.NullString      `db:"lot_shade_code"`
+	// The lot's current lab result (0348), joined the same way and NULL on the same terms.
+	LotShadeBand        sql.NullString      `db:"lot_shade_band"`
+	LotShrinkageWarpPct decimal.NullDecimal `db:"lot_shrinkage_warp_pct"`
+	LotShrinkageWeftPct decimal.NullDecimal `db:"lot_shrinkage_weft_pct"`
+	LotMeasuredGsm      decimal.NullDecimal `db:"lot_measured_gsm"`
+	// ExtraLots are the other rolls laid into this lay (production_run_lay_lot), in position order.
+	ExtraLots []ProductionRunLayLot `db:"-"`
 
 	// ActualQty..ActualAt are the consumption FACT (Ф5б.2): how much cloth really went, in which
 	// unit, measured how, by whom, when. Invalid ActualQty means the fact has not been recorded —
@@ -434,6 +467,27 @@ func (l ProductionRunLay) HasActual() bool { return l.ActualQty.Valid }
 // remembered code so the roll can be found on paper.
 func (l ProductionRunLay) LotDetached() bool { return !l.LotId.Valid && l.LotCode != "" }
 
+// Lots lists every roll the lay names — its own lot first (position 0), then the extra rolls. A
+// lay that names no lot of its own contributes only its extras; an empty result is «no roll named».
+func (l ProductionRunLay) Lots() []ProductionRunLayLot {
+	out := make([]ProductionRunLayLot, 0, 1+len(l.ExtraLots))
+	if l.LotId.Valid || l.LotCode != "" {
+		out = append(out, ProductionRunLayLot{
+			LayId:            l.Id,
+			LotId:            l.LotId,
+			LotCode:          l.LotCode,
+			MaterialId:       l.LotMaterialId,
+			MeasuredWidthCm:  l.LotMeasuredWidthCm,
+			ShadeCode:        l.LotShadeCode,
+			ShadeBand:        l.LotShadeBand,
+			ShrinkageWarpPct: l.LotShrinkageWarpPct,
+			ShrinkageWeftPct: l.LotShrinkageWeftPct,
+			MeasuredGsm:      l.LotMeasuredGsm,
+		})
+	}
+	return append(out, l.ExtraLots...)
+}
+
 // ActualUnit resolves the fact's stored unit against the closed vocabulary (Ф5а.3). ok=false for an
 // absent unit and for anything the vocabulary does not know — the caller must then treat the number
 // as unaddable, never as metres.
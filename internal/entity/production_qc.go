@@ -0,0 +1,265 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// КОНТРОЛЬ КАЧЕСТВА ПРИЁМКИ (qc_defect_type / production_run_qc_inspection /
+// production_run_qc_inspection_defect, migration 0343).
+//
+// До сих пор приёмка знала только два числа на строку — good и defect — и путь их распределения
+// (scrap / seconds). Откуда взялся брак, сколько изделий смотрели и по какому правилу партию
+// пропустили, не записывалось нигде. Инспекция — это запись выборочного контроля одной партии
+// прогона по ISO 2859-1: размер партии → кодовая буква → объём выборки и числа Ac/Re для
+// значительных (major) и малозначительных (minor) дефектов, критический — ноль терпимости.
+// План считает internal/qcsampling, и он же выносит вердикт по найденному.
+//
+// ПРОЙДЕННАЯ ИНСПЕКЦИЯ — ПРОПУСК ГОДНЫХ НА СКЛАД. Приёмка, которой инспекция передана (или когда
+// цех включил workshop_settings.qc_inspection_required), проводит good units в ReceiveProductionStock
+// только против пройденной, ещё не использованной инспекции этого прогона; проваленная пропускает
+// только брак. Инспекция гасится приёмкой в той же транзакции (receipt_id) и освобождается её
+// сторно.
+
+// QcAql is the acceptance quality limit of one defect class, as a token. A string rather than a
+// decimal: the tables are keyed by it and "2.5" must never meet "2.50".
+type QcAql string
+
+const (
+	QcAql25 QcAql = "2.5"
+	QcAql40 QcAql = "4.0"
+)
+
+// ValidQcAql reports whether a is one of the AQLs the sampling tables carry.
+func ValidQcAql(a QcAql) bool { return a == QcAql25 || a == QcAql40 }
+
+// QcInspectionLevel is the ISO 2859-1 general inspection level. II is the default of the standard
+// and of this workshop; I looks at fewer garments, III at more.
+type QcInspectionLevel string
+
+const (
+	QcInspectionLevelI   QcInspectionLevel = "I"
+	QcInspectionLevelII  QcInspectionLevel = "II"
+	QcInspectionLevelIII QcInspectionLevel = "III"
+)
+
+// ValidQcInspectionLevel reports whether l is a general inspection level.
+func ValidQcInspectionLevel(l QcInspectionLevel) bool {
+	return l == QcInspectionLevelI || l == QcInspectionLevelII || l == QcInspectionLevelIII
+}
+
+// QcDefectSeverity is the class of a defect. Critical — unsafe or unsellable whatever the count
+// (a needle left in a seam); major — a garment a customer would return; minor — a flaw that does
+// not stop a sale.
+type QcDefectSeverity string
+
+const (
+	QcDefectCritical QcDefectSeverity = "critical"
+	QcDefectMajor    QcDefectSeverity = "major"
+	QcDefectMinor    QcDefectSeverity = "minor"
+)
+
+// ValidQcDefectSeverity reports whether s is a known class.
+func ValidQcDefectSeverity(s QcDefectSeverity) bool {
+	return s == QcDefectCritical || s == QcDefectMajor || s == QcDefectMinor
+}
+
+// QcInspectionResult is where an inspection stands. Passed and failed are never chosen by a person:
+// they are the verdict of the sampling plan over the recorded defects.
+type QcInspectionResult string
+
+const (
+	QcInspectionPending QcInspectionResult = "pending"
+	QcInspectionPassed  QcInspectionResult = "passed"
+	QcInspectionFailed  QcInspectionResult = "failed"
+)
+
+// QcDefectType is one entry of the defect catalog. OperationType and Work say which step of a tech
+// card produces it — by the step's verb and operation_work token, never by a card operation id:
+// card operations are full-replaced on every save, the tokens are minted once and forever (0329).
+// Both are optional; a defect of the fabric itself belongs to no operation.
+type QcDefectType struct {
+	Id            int              `db:"id"`
+	Code          string           `db:"code"`
+	Name          string           `db:"name"`
+	Severity      QcDefectSeverity `db:"severity"`
+	OperationType sql.NullString   `db:"operation_type"`
+	Work          sql.NullString   `db:"work"`
+	Description   sql.NullString   `db:"description"`
+	RetiredAt     sql.NullTime     `db:"retired_at"`
+	CreatedBy     string           `db:"created_by"`
+	CreatedAt     time.Time        `db:"created_at"`
+	UpdatedAt     time.Time        `db:"updated_at"`
+}
+
+// QcDefectTypeInsert is the writable part of a catalog entry. Retiring is its own call.
+type QcDefectTypeInsert struct {
+	Code          string
+	Name          string
+	Severity      QcDefectSeverity
+	OperationType sql.NullString
+	Work          sql.NullString
+	Description   sql.NullString
+}
+
+// QcSamplingPlan is the single sampling plan (normal inspection) an inspection was created with.
+// Critical defects are not in it: their acceptance number is zero whatever the lot.
+type QcSamplingPlan struct {
+	CodeLetter string
+	SampleSize int
+	// FullInspection is set when the plan asks for at least the whole lot: every garment is looked
+	// at, and SampleSize is the lot size.
+	FullInspection bool
+	MajorAccept    int
+	MajorReject    int
+	MinorAccept    int
+	MinorReject    int
+}
+
+// QcInspection is one inspection of a lot of a run.
+type QcInspection struct {
+	Id              int                `db:"id"`
+	RunId           int                `db:"run_id"`
+	ReceiptId       sql.NullInt32      `db:"receipt_id"` // the receipt that consumed it; invalid = not used yet
+	LotSize         int                `db:"lot_size"`
+	InspectionLevel QcInspectionLevel  `db:"inspection_level"`
+	AqlMajor        QcAql              `db:"aql_major"`
+	AqlMinor        QcAql              `db:"aql_minor"`
+	CodeLetter      string             `db:"code_letter"`
+	SampleSize      int                `db:"sample_size"`
+	FullInspection  bool               `db:"full_inspection"`
+	MajorAccept     int                `db:"major_accept"`
+	MajorReject     int                `db:"major_reject"`
+	MinorAccept     int                `db:"minor_accept"`
+	MinorReject     int                `db:"minor_reject"`
+	Result          QcInspectionResult `db:"result"`
+	Inspector       sql.NullString     `db:"inspector"`
+	Note            sql.NullString     `db:"note"`
+	CreatedBy       string             `db:"created_by"`
+	CreatedAt       time.Time          `db:"created_at"`
+	DecidedBy       sql.NullString     `db:"decided_by"`
+	DecidedAt       sql.NullTime       `db:"decided_at"`
+	Defects         []QcInspectionDefect
+}
+
+// Plan is the inspection's stored sampling plan.
+func (i *QcInspection) Plan() QcSamplingPlan {
+	return QcSamplingPlan{
+		CodeLetter:     i.CodeLetter,
+		SampleSize:     i.SampleSize,
+		FullInspection: i.FullInspection,
+		MajorAccept:    i.MajorAccept,
+		MajorReject:    i.MajorReject,
+		MinorAccept:    i.MinorAccept,
+		MinorReject:    i.MinorReject,
+	}
+}
+
+// QcInspectionDefect is one finding: how many garments of the sample carried one catalog defect.
+// Severity is a SNAPSHOT of the catalog at the time of the finding — the verdict was taken on it,
+// and a later reclassification must not rewrite why a lot failed. RunOperationId names the step of
+// the run's operation snapshot (0340) it was traced to, when the inspector traced it.
+type QcInspectionDefect struct {
+	Id             int              `db:"id"`
+	InspectionId   int              `db:"inspection_id"`
+	DefectTypeId   int              `db:"defect_type_id"`
+	DefectCode     string           `db:"defect_code"`
+	DefectName     string           `db:"defect_name"`
+	Severity       QcDefectSeverity `db:"severity"`
+	RunOperationId sql.NullInt32    `db:"run_operation_id"`
+	OperationSeq   sql.NullInt32    `db:"operation_seq"`
+	OperationType  sql.NullString   `db:"operation_type"` // snapshot of the traced step's verb
+	Work           sql.NullString   `db:"work"`           // snapshot of the traced step's work
+	Qty            int              `db:"qty"`
+}
+
+// QcInspectionInsert opens an inspection; the plan is computed by the caller (qcsampling.Plan).
+type QcInspectionInsert struct {
+	RunId           int
+	LotSize         int
+	InspectionLevel QcInspectionLevel
+	AqlMajor        QcAql
+	AqlMinor        QcAql
+	Plan            QcSamplingPlan
+	Inspector       sql.NullString
+	Note            sql.NullString
+	CreatedBy       string
+}
+
+// QcFindingInput is one finding as submitted. OperationSeq 0 means "not traced to a step".
+type QcFindingInput struct {
+	DefectTypeId int
+	OperationSeq int
+	Qty          int
+}
+
+// QcDefectCounts is the number of defects found per class.
+type QcDefectCounts struct {
+	Critical int
+	Major    int
+	Minor    int
+}
+
+// QcDefectAnalyticsFilter bounds the analytics to inspections decided in [From, To).
+type QcDefectAnalyticsFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+// QcDefectRateRow is one bucket of the defect analytics: what was inspected and what was found.
+// Rates are computed by the reader (defects per hundred inspected units — DHU), never stored.
+type QcDefectRateRow struct {
+	Key         string `db:"bucket_key"`
+	Label       string `db:"bucket_label"`
+	Inspections int    `db:"inspections"`
+	Failed      int    `db:"failed"`
+	Inspected   int    `db:"inspected"`
+	Critical    int    `db:"critical"`
+	Major       int    `db:"major"`
+	Minor       int    `db:"minor"`
+}
+
+// Defects is the total found in the bucket.
+func (r QcDefectRateRow) Defects() int { return r.Critical + r.Major + r.Minor }
+
+// QcDefectAnalytics is the defect picture over a period, cut three ways. Per operation, Inspected is
+// every unit inspected in the period — a defect traced to a step was looked for in every sample, not
+// only in the samples where it showed up — and defects traced to no step form their own bucket.
+type QcDefectAnalytics struct {
+	Total       QcDefectRateRow
+	ByStyle     []QcDefectRateRow
+	ByOperation []QcDefectRateRow
+	ByWorkshop  []QcDefectRateRow
+}
+
+// ErrQcDefectTypeNotFound is returned when a catalog entry does not exist.
+var ErrQcDefectTypeNotFound = errors.New("qc defect type not found")
+
+// ErrQcDefectTypeRetired refuses recording a finding against a retired catalog entry.
+var ErrQcDefectTypeRetired = errors.New("qc defect type is retired")
+
+// ErrQcInspectionNotFound is returned when an inspection does not exist (or not in that run).
+var ErrQcInspectionNotFound = errors.New("qc inspection not found")
+
+// ErrQcInspectionConsumed refuses changing, deleting or re-using an inspection a receipt has used.
+var ErrQcInspectionConsumed = errors.New("qc inspection has already been used by a receipt")
+
+// ErrQcInspectionRequired refuses booking good units without an inspection while the workshop
+// requires one.
+var ErrQcInspectionRequired = errors.New("a passed qc inspection is required to receive good units")
+
+// ErrQcInspectionPending refuses a receipt against an inspection whose findings are not recorded.
+var ErrQcInspectionPending = errors.New("qc inspection has no verdict yet")
+
+// ErrQcInspectionFailed refuses booking good units against a failed inspection. The lot can still
+// be received as defect.
+var ErrQcInspectionFailed = errors.New("qc inspection failed; the lot can only be received as defect")
+
+// ErrQcInspectionLotExceeded refuses a receipt that counts more units than the inspected lot: the
+// sample was sized for the lot, and the surplus was never sampled.
+var ErrQcInspectionLotExceeded = errors.New("the receipt counts more units than the inspected lot")
+
+// ErrQcOperationNotFound is returned when a finding names a step that is not in the run's
+// operation snapshot.
+var ErrQcOperationNotFound = errors.New("the finding names no operation of the run's snapshot")
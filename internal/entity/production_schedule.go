@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ПЛАН ЗАГРУЗКИ ЦЕХА (0342). Прогон грузится на цех в нормо-минутах: Σ SMV операций карты ×
+// количество, за вычетом уже сделанного. Мощность дня — WorkshopSettings.Capacity(); планировщик
+// (internal/runschedule) раскладывает прогоны по дням и предлагает даты, а даты, которые вписал
+// человек, сравнивает с мощностью и предупреждает о перегрузе. Ничего не записывает: предложение
+// становится планом только тогда, когда человек перенесёт его в planned_start_at / promised_at.
+
+// ProductionScheduleRun is what the store knows about one open run for planning — facts only; every
+// verdict is the scheduler's.
+type ProductionScheduleRun struct {
+	RunId          int                 `db:"run_id"`
+	TechCardId     int                 `db:"tech_card_id"`
+	TechCardName   string              `db:"tech_card_name"`
+	StyleNumber    string              `db:"style_number"`
+	Status         ProductionRunStatus `db:"status"`
+	PlannedStartAt sql.NullTime        `db:"planned_start_at"`
+	PromisedAt     sql.NullTime        `db:"promised_at"`
+	// SupplierId set = the batch is sewn by an outside factory and does not load this workshop.
+	SupplierId sql.NullInt64 `db:"supplier_id"`
+	PlannedQty int           `db:"planned_qty"`
+	// DoneQty is received + defect over the plan grid: units that have left the line either way.
+	DoneQty int `db:"done_qty"`
+	// SMVPerUnit is Σ SMV over the run's operations — the bundle snapshot when bundles were cut,
+	// else the card's live operations. Invalid when no operation carries an SMV.
+	SMVPerUnit decimal.NullDecimal `db:"smv_per_unit"`
+	// OperationCount / OperationsWithoutSMV say how complete SMVPerUnit is.
+	OperationCount       int `db:"operation_count"`
+	OperationsWithoutSMV int `db:"operations_without_smv"`
+	// ScannedMinutes is Σ qty × SMV over the run's bundle scans (0340): work done, operation by
+	// operation, before a single unit is received.
+	ScannedMinutes decimal.Decimal `db:"scanned_minutes"`
+}
+
+// ScheduleWarningKind names why a run's bar needs attention.
+type ScheduleWarningKind string
+
+const (
+	// ScheduleWarningLate — the proposed finish falls after the promised date.
+	ScheduleWarningLate ScheduleWarningKind = "late"
+	// ScheduleWarningSMVMissing — no operation of the run carries an SMV: the load is unknown and
+	// the run is not scheduled.
+	ScheduleWarningSMVMissing ScheduleWarningKind = "smv_missing"
+	// ScheduleWarningSMVIncomplete — some operations lack an SMV: the run is scheduled, but its load
+	// is understated by them.
+	ScheduleWarningSMVIncomplete ScheduleWarningKind = "smv_incomplete"
+	// ScheduleWarningExternal — the run is sewn by an outside factory and is not loaded here.
+	ScheduleWarningExternal ScheduleWarningKind = "external_factory"
+	// ScheduleWarningBeyondHorizon — the run does not finish within the scheduler's look-ahead.
+	ScheduleWarningBeyondHorizon ScheduleWarningKind = "beyond_horizon"
+	// ScheduleWarningOverCapacity — the run's own planned window is part of an overloaded range
+	// (see ScheduleConflict).
+	ScheduleWarningOverCapacity ScheduleWarningKind = "over_capacity"
+)
+
+// ScheduleBar is one run on the timeline.
+type ScheduleBar struct {
+	Run ProductionScheduleRun
+	// LoadMinutes is the whole run; RemainingMinutes what is left of it to sew.
+	LoadMinutes      decimal.Decimal
+	RemainingMinutes decimal.Decimal
+	// ProposedStart / ProposedFinish are the first and the last working day the scheduler gives the
+	// run capacity on. Invalid when nothing is left to sew, when the run cannot be loaded (external,
+	// no SMV), when capacity is not configured, or (finish only) beyond the look-ahead.
+	ProposedStart  sql.NullTime
+	ProposedFinish sql.NullTime
+	Warnings       []ScheduleWarningKind
+}
+
+// ScheduleDay is one calendar day of the timeline.
+type ScheduleDay struct {
+	Date        time.Time
+	Working     bool
+	HolidayNote string // set on a workshop_holiday
+	// CapacityMinutes is zero on a non-working day.
+	CapacityMinutes decimal.Decimal
+	// ProposedMinutes is what the scheduler loaded on the day.
+	ProposedMinutes decimal.Decimal
+	// PlannedMinutes is what the people's own dates ask of the day: each run with both a planned
+	// start and a promised date spread evenly over the working days between them.
+	PlannedMinutes decimal.Decimal
+}
+
+// ScheduleConflict is a range of consecutive working days on which the planned dates ask more of
+// the workshop than it has.
+type ScheduleConflict struct {
+	From time.Time
+	To   time.Time
+	// PeakMinutes is the largest planned demand of a day in the range.
+	PeakMinutes     decimal.Decimal
+	CapacityMinutes decimal.Decimal
+	RunIds          []int
+}
+
+// ProductionSchedule is the Gantt-style timeline of the open runs.
+type ProductionSchedule struct {
+	From      time.Time
+	Capacity  WorkshopCapacity
+	Days      []ScheduleDay
+	Bars      []ScheduleBar
+	Conflicts []ScheduleConflict
+}
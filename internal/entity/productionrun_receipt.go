@@ -151,6 +151,12 @@ type PostProductionRunReceiptParams struct {
 	// accounting.defect_normal_loss_rate): the FINAL receipt's valuation subtracts the abnormal
 	// scrap share the ledger writes off, keeping cost_price and the FG balance on one truth.
 	NormalLossRate decimal.Decimal
+	// InspectionID is the QC inspection (0343) vouching for this delivery; 0 = none. A supplied
+	// inspection is always checked — passed (or failed with no good units), unused, of this run, and
+	// covering at least the counted units — and is stamped with the receipt in the same transaction.
+	// Part of the request hash. Without one, workshop_settings.qc_inspection_required is read under
+	// the run lock and refuses good units of a product run (ErrQcInspectionRequired).
+	InspectionID int
 }
 
 // PostProductionRunReceiptResult is what the receipt command returns — and what a replayed retry
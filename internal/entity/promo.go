@@ -1,11 +1,113 @@
 package entity
 
 import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+// PromoDiscountType says how a promo code computes its discount (0335).
+type PromoDiscountType string
+
+const (
+	// PromoDiscountPercentage takes Discount percent off the eligible lines.
+	PromoDiscountPercentage PromoDiscountType = "percentage"
+	// PromoDiscountFixedAmount takes the order currency's PromoCurrencyRule.FixedAmount off the
+	// eligible lines, split across them in proportion to their value.
+	PromoDiscountFixedAmount PromoDiscountType = "fixed_amount"
+	// PromoDiscountBuyXGetY discounts the GetQuantity cheapest units of every BuyQuantity+GetQuantity
+	// eligible units by Discount percent (100 = free).
+	PromoDiscountBuyXGetY PromoDiscountType = "buy_x_get_y"
+)
+
+// ValidPromoDiscountTypes mirrors the promo_code.discount_type ENUM.
+var ValidPromoDiscountTypes = map[PromoDiscountType]bool{
+	PromoDiscountPercentage:  true,
+	PromoDiscountFixedAmount: true,
+	PromoDiscountBuyXGetY:    true,
+}
+
+// PromoStacking says whether a code combines with other codes on the same order.
+type PromoStacking string
+
+const (
+	// PromoStackingExclusive codes are the only code on an order.
+	PromoStackingExclusive PromoStacking = "exclusive"
+	// PromoStackingStackable codes combine with other stackable codes, each applied in the order the
+	// codes were entered to what the previous ones left.
+	PromoStackingStackable PromoStacking = "stackable"
+)
+
+// ValidPromoStackings mirrors the promo_code.stacking ENUM.
+var ValidPromoStackings = map[PromoStacking]bool{
+	PromoStackingExclusive: true,
+	PromoStackingStackable: true,
+}
+
+// PromoScopeKind is what a promo_code_scope row matches an order line on.
+type PromoScopeKind string
+
+const (
+	PromoScopeCollection  PromoScopeKind = "collection"
+	PromoScopeTag         PromoScopeKind = "tag"
+	PromoScopeTopCategory PromoScopeKind = "top_category"
+)
+
+// ValidPromoScopeKinds mirrors the promo_code_scope.scope_kind ENUM.
+var ValidPromoScopeKinds = map[PromoScopeKind]bool{
+	PromoScopeCollection:  true,
+	PromoScopeTag:         true,
+	PromoScopeTopCategory: true,
+}
+
+// PromoCurrencyRule is a promo's fixed discount and minimum spend in one currency
+// (promo_code_currency). Both are optional.
+type PromoCurrencyRule struct {
+	PromoId     int                 `db:"promo_id"`
+	Currency    string              `db:"currency"`
+	FixedAmount decimal.NullDecimal `db:"fixed_amount"`
+	MinSpend    decimal.NullDecimal `db:"min_spend"`
+}
+
+// PromoScope narrows a promo to lines in a collection, with a tag, or under a top category
+// (promo_code_scope). Value is the collection name, the tag, or the top category id.
+type PromoScope struct {
+	PromoId int            `db:"promo_id"`
+	Kind    PromoScopeKind `db:"scope_kind"`
+	Value   string         `db:"scope_value"`
+}
+
+// PromoUniqueCode is one generated single-use code of a template promo (promo_unique_code).
+type PromoUniqueCode struct {
+	Id         int            `db:"id"`
+	PromoId    int            `db:"promo_id"`
+	Code       string         `db:"code"`
+	Batch      string         `db:"batch"`
+	OrderId    sql.NullInt32  `db:"order_id"`
+	OrderUUID  sql.NullString `db:"order_uuid"` // joined for display
+	RedeemedAt sql.NullTime   `db:"redeemed_at"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+// OrderPromo is one code applied to an order (customer_order_promo), the redemption the usage caps
+// count until it is released by a cancel or expiry.
+type OrderPromo struct {
+	Id             int             `db:"id"`
+	OrderId        int             `db:"order_id"`
+	PromoId        sql.NullInt32   `db:"promo_id"`
+	UniqueCodeId   sql.NullInt32   `db:"unique_code_id"`
+	Code           string          `db:"code"`
+	BuyerEmail     string          `db:"buyer_email"`
+	DiscountAmount decimal.Decimal `db:"discount_amount"`
+	FreeShipping   bool            `db:"free_shipping"`
+	Released       bool            `db:"released"`
+	CreatedAt      time.Time       `db:"created_at"`
+}
+
 // PromoCode represents the promo_code table
 type PromoCode struct {
 	Id int `db:"id"`
@@ -17,7 +119,8 @@ func (pc *PromoCode) IsAllowed() bool {
 }
 
 // CalculateTotalWithPromo applies promo discount and shipping to calculate final total.
-// Discount is applied to subtotal only, then shipping is added (unless free shipping applies).
+// It knows only the percentage discount and ignores the 0335 rules (type, scope, caps); checkout and
+// the order flow evaluate codes with EvaluatePromos. Discount is applied to subtotal only, then shipping is added (unless free shipping applies).
 // Returns the final total and whether free shipping was granted.
 // decimalPlaces: 0 for zero-decimal currencies (KRW, JPY), 2 for standard.
 func (pc *PromoCode) CalculateTotalWithPromo(subtotal, shippingPrice decimal.Decimal, decimalPlaces int32) (total decimal.Decimal, freeShippingGranted bool) {
@@ -56,6 +159,139 @@ type PromoCodeInsert struct {
 	Start        time.Time       `db:"start"`
 	Voucher      bool            `db:"voucher"`
 	Allowed      bool            `db:"allowed"`
+	// Rules (0335). The zero values keep the pre-0335 behaviour: a percentage code, usable by
+	// anyone, without caps, exclusive, on every line.
+	DiscountType       PromoDiscountType `db:"discount_type"`
+	MinTier            int16             `db:"min_tier"`
+	MaxUses            sql.NullInt32     `db:"max_uses"`
+	MaxUsesPerCustomer sql.NullInt32     `db:"max_uses_per_customer"`
+	BuyQuantity        int               `db:"buy_quantity"`
+	GetQuantity        int               `db:"get_quantity"`
+	Stacking           PromoStacking     `db:"stacking"`
+	ExcludeSaleItems   bool              `db:"exclude_sale_items"`
+	UniqueCodesOnly    bool              `db:"unique_codes_only"`
+	// CurrencyRules and Scopes live in promo_code_currency / promo_code_scope and are loaded by the
+	// promo store alongside the row.
+	CurrencyRules []PromoCurrencyRule `db:"-"`
+	Scopes        []PromoScope        `db:"-"`
+}
+
+// Type is the discount type, percentage for rows written before 0335.
+func (pc *PromoCodeInsert) Type() PromoDiscountType {
+	if pc.DiscountType == "" {
+		return PromoDiscountPercentage
+	}
+	return pc.DiscountType
+}
+
+// IsStackable reports whether the code combines with other stackable codes.
+func (pc *PromoCodeInsert) IsStackable() bool {
+	return pc.Stacking == PromoStackingStackable
+}
+
+// CurrencyRule returns the rule for cur, if the promo has one.
+func (pc *PromoCodeInsert) CurrencyRule(cur string) (PromoCurrencyRule, bool) {
+	for _, r := range pc.CurrencyRules {
+		if strings.EqualFold(r.Currency, cur) {
+			return r, true
+		}
+	}
+	return PromoCurrencyRule{}, false
+}
+
+// Validate checks the rule fields are consistent with the discount type. It normalises currency
+// codes to upper case and defaults an empty stacking to exclusive.
+func (pc *PromoCodeInsert) Validate() error {
+	if strings.TrimSpace(pc.Code) == "" {
+		return &ValidationError{Message: "promo code is required", Field: "code"}
+	}
+	if !ValidPromoDiscountTypes[pc.Type()] {
+		return &ValidationError{Message: fmt.Sprintf("unknown discount type %q", pc.DiscountType), Field: "discount_type"}
+	}
+	pc.DiscountType = pc.Type()
+	if pc.Stacking == "" {
+		pc.Stacking = PromoStackingExclusive
+	}
+	if !ValidPromoStackings[pc.Stacking] {
+		return &ValidationError{Message: fmt.Sprintf("unknown stacking %q", pc.Stacking), Field: "stacking"}
+	}
+	hundred := decimal.NewFromInt(100)
+	if pc.Discount.IsNegative() || pc.Discount.GreaterThan(hundred) {
+		return &ValidationError{Message: "discount must be a percentage between 0 and 100", Field: "discount"}
+	}
+	if pc.MinTier < 0 {
+		return &ValidationError{Message: "min tier must not be negative", Field: "min_tier"}
+	}
+	if pc.MaxUses.Valid && pc.MaxUses.Int32 < 1 {
+		return &ValidationError{Message: "max uses must be at least 1 when set", Field: "max_uses"}
+	}
+	if pc.MaxUsesPerCustomer.Valid && pc.MaxUsesPerCustomer.Int32 < 1 {
+		return &ValidationError{Message: "max uses per customer must be at least 1 when set", Field: "max_uses_per_customer"}
+	}
+
+	seen := make(map[string]bool, len(pc.CurrencyRules))
+	hasFixed := false
+	for i := range pc.CurrencyRules {
+		r := &pc.CurrencyRules[i]
+		r.Currency = strings.ToUpper(strings.TrimSpace(r.Currency))
+		if r.Currency == "" || seen[r.Currency] {
+			return &ValidationError{Message: "currency rules need distinct currencies", Field: "currency_rules"}
+		}
+		seen[r.Currency] = true
+		if r.FixedAmount.Valid {
+			if !r.FixedAmount.Decimal.IsPositive() {
+				return &ValidationError{Message: fmt.Sprintf("fixed amount for %s must be positive", r.Currency), Field: "currency_rules"}
+			}
+			hasFixed = true
+		}
+		if r.MinSpend.Valid && r.MinSpend.Decimal.IsNegative() {
+			return &ValidationError{Message: fmt.Sprintf("minimum spend for %s must not be negative", r.Currency), Field: "currency_rules"}
+		}
+	}
+
+	switch pc.DiscountType {
+	case PromoDiscountFixedAmount:
+		if !hasFixed {
+			return &ValidationError{Message: "a fixed amount promo needs a fixed amount in at least one currency", Field: "currency_rules"}
+		}
+		if !pc.Discount.IsZero() {
+			return &ValidationError{Message: "a fixed amount promo has no percentage discount", Field: "discount"}
+		}
+	case PromoDiscountBuyXGetY:
+		if pc.BuyQuantity < 1 || pc.GetQuantity < 1 {
+			return &ValidationError{Message: "buy x get y needs buy and get quantities of at least 1", Field: "buy_quantity"}
+		}
+		if !pc.Discount.IsPositive() {
+			return &ValidationError{Message: "buy x get y needs the discount on the free units (100 = free)", Field: "discount"}
+		}
+	default:
+		if hasFixed {
+			return &ValidationError{Message: "fixed amounts only apply to fixed amount promos", Field: "currency_rules"}
+		}
+	}
+	if pc.DiscountType != PromoDiscountBuyXGetY && (pc.BuyQuantity != 0 || pc.GetQuantity != 0) {
+		return &ValidationError{Message: "buy and get quantities only apply to buy x get y promos", Field: "buy_quantity"}
+	}
+
+	scopes := make(map[PromoScope]bool, len(pc.Scopes))
+	for i := range pc.Scopes {
+		sc := &pc.Scopes[i]
+		sc.Value = strings.TrimSpace(sc.Value)
+		if !ValidPromoScopeKinds[sc.Kind] || sc.Value == "" {
+			return &ValidationError{Message: "scope needs a kind and a value", Field: "scopes"}
+		}
+		if sc.Kind == PromoScopeTopCategory {
+			if _, err := strconv.Atoi(sc.Value); err != nil {
+				return &ValidationError{Message: fmt.Sprintf("top category scope %q is not a category id", sc.Value), Field: "scopes"}
+			}
+		}
+		key := PromoScope{Kind: sc.Kind, Value: sc.Value}
+		if scopes[key] {
+			return &ValidationError{Message: fmt.Sprintf("duplicate scope %s %q", sc.Kind, sc.Value), Field: "scopes"}
+		}
+		scopes[key] = true
+	}
+	return nil
 }
 
 func (pc *PromoCode) DiscountDecimal() decimal.Decimal {
@@ -0,0 +1,410 @@
+package entity
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PromoCartLine is one order line as the promotions engine sees it. UnitPrice is the price after the
+// product's own sale; Collection and Tags are resolved by the promo store for scope matching.
+type PromoCartLine struct {
+	ProductId     int
+	SizeId        int
+	Quantity      decimal.Decimal
+	UnitPrice     decimal.Decimal
+	OnSale        bool
+	TopCategoryId int
+	Collection    string
+	Tags          []string
+}
+
+// LineTotal is the line's value before any promo.
+func (l *PromoCartLine) LineTotal() decimal.Decimal {
+	return l.UnitPrice.Mul(l.Quantity)
+}
+
+// PromoCart is what the codes are evaluated against: the validated lines, the currency and the
+// buyer. BuyerEmail is empty for a guest at pre-checkout, in which case per-customer caps are checked
+// again when the order is created with the buyer's email. OrderId is set when an existing order is
+// re-priced: its own redemptions do not count towards the caps and the tier gate it passed at
+// checkout is not checked again.
+type PromoCart struct {
+	Currency      string
+	DecimalPlaces int32
+	Lines         []PromoCartLine
+	BuyerTier     int16
+	BuyerEmail    string
+	OrderId       int
+	Now           time.Time
+}
+
+// PromoCartLinesFromOrderItems maps validated order lines to cart lines at their sale price. The
+// promo store fills in Collection and Tags.
+func PromoCartLinesFromOrderItems(items []OrderItem) []PromoCartLine {
+	lines := make([]PromoCartLine, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, PromoCartLine{
+			ProductId:     it.ProductId,
+			SizeId:        it.SizeId,
+			Quantity:      it.Quantity,
+			UnitPrice:     it.ProductPriceWithSale,
+			OnSale:        it.ProductSalePercentage.IsPositive(),
+			TopCategoryId: it.TopCategoryId,
+		})
+	}
+	return lines
+}
+
+// Subtotal is the cart value before promos.
+func (c *PromoCart) Subtotal() decimal.Decimal {
+	sum := decimal.Zero
+	for i := range c.Lines {
+		sum = sum.Add(c.Lines[i].LineTotal())
+	}
+	return sum
+}
+
+// PromoUsage is how many unreleased redemptions a promo has, overall and by the cart's buyer.
+type PromoUsage struct {
+	Total    int
+	Customer int
+}
+
+// PromoCandidate is a code the buyer entered, resolved to its promo. UniqueCodeId is set for a
+// generated single-use code, whose Promo is the template it was generated from.
+type PromoCandidate struct {
+	Code         string
+	Promo        PromoCode
+	UniqueCodeId int
+	Usage        PromoUsage
+}
+
+// PromoRejectReason is the stable reason a code did not apply.
+type PromoRejectReason string
+
+const (
+	PromoRejectNotFound        PromoRejectReason = "not_found"
+	PromoRejectRedeemed        PromoRejectReason = "already_redeemed"
+	PromoRejectInactive        PromoRejectReason = "inactive"
+	PromoRejectTier            PromoRejectReason = "tier_required"
+	PromoRejectUsageLimit      PromoRejectReason = "usage_limit_reached"
+	PromoRejectCustomerLimit   PromoRejectReason = "customer_usage_limit_reached"
+	PromoRejectCurrency        PromoRejectReason = "currency_not_supported"
+	PromoRejectMinSpend        PromoRejectReason = "min_spend_not_met"
+	PromoRejectNoEligibleItems PromoRejectReason = "no_eligible_items"
+	PromoRejectNotStackable    PromoRejectReason = "not_stackable"
+	PromoRejectDuplicate       PromoRejectReason = "duplicate"
+	PromoRejectGiftCard        PromoRejectReason = "gift_card_in_cart"
+)
+
+// PromoRejection is a code that was entered but gives nothing, and why.
+type PromoRejection struct {
+	Code   string
+	Reason PromoRejectReason
+}
+
+// PromoApplication is one code's effect on the cart. LineDiscounts is aligned with PromoCart.Lines.
+type PromoApplication struct {
+	Code          string
+	PromoId       int
+	UniqueCodeId  int
+	DiscountType  PromoDiscountType
+	Discount      decimal.Decimal
+	FreeShipping  bool
+	LineDiscounts []decimal.Decimal
+}
+
+// PromoBreakdown is the result of evaluating the entered codes: what applied, what did not, and the
+// total discount off the subtotal. The storefront shows it and the order charges it.
+type PromoBreakdown struct {
+	Applied      []PromoApplication
+	Rejected     []PromoRejection
+	Discount     decimal.Decimal
+	FreeShipping bool
+}
+
+// PrimaryPromoId is the first applied promo, recorded on customer_order.promo_id; 0 when none applied.
+func (b *PromoBreakdown) PrimaryPromoId() int {
+	if b == nil || len(b.Applied) == 0 {
+		return 0
+	}
+	return b.Applied[0].PromoId
+}
+
+// RejectedFor reports whether any code was rejected for reason.
+func (b *PromoBreakdown) RejectedFor(reason PromoRejectReason) bool {
+	if b == nil {
+		return false
+	}
+	for _, r := range b.Rejected {
+		if r.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Codes lists the applied codes in application order.
+func (b *PromoBreakdown) Codes() []string {
+	if b == nil {
+		return nil
+	}
+	codes := make([]string, 0, len(b.Applied))
+	for _, a := range b.Applied {
+		codes = append(codes, a.Code)
+	}
+	return codes
+}
+
+// EffectiveDiscountPct is the discount as a percentage of subtotal, the customer_order.promo_discount_pct
+// snapshot that metrics and the accounting 4030 split reconstruct the discount from. Invalid when no
+// discount was given.
+func (b *PromoBreakdown) EffectiveDiscountPct(subtotal decimal.Decimal) decimal.NullDecimal {
+	if b == nil || !b.Discount.IsPositive() || !subtotal.IsPositive() {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NullDecimal{Decimal: b.Discount.Mul(decimal.NewFromInt(100)).Div(subtotal).Round(2), Valid: true}
+}
+
+// ApplyTo returns the order total for subtotal and shippingPrice after the breakdown: the discount
+// comes off the subtotal and shipping is added unless a code waived it.
+func (b *PromoBreakdown) ApplyTo(subtotal, shippingPrice decimal.Decimal, decimalPlaces int32) (total decimal.Decimal, freeShipping bool) {
+	discounted := subtotal
+	if b != nil {
+		discounted = subtotal.Sub(b.Discount)
+		freeShipping = b.FreeShipping
+	}
+	if discounted.IsNegative() {
+		discounted = decimal.Zero
+	}
+	if freeShipping {
+		return discounted.Round(decimalPlaces), true
+	}
+	return discounted.Add(shippingPrice).Round(decimalPlaces), false
+}
+
+// EvaluatePromos applies the candidates to the cart in the order they were entered. A code that is
+// inactive, out of the buyer's tier, over a usage cap, unsupported in the currency, short of its
+// minimum spend or without eligible lines is rejected and the rest still apply. An exclusive code
+// only applies alone; stackable codes each take their discount from what the previous codes left,
+// so the discounts never exceed the lines' value.
+func EvaluatePromos(cart PromoCart, candidates []PromoCandidate) PromoBreakdown {
+	bd := PromoBreakdown{Discount: decimal.Zero}
+	remaining := make([]decimal.Decimal, len(cart.Lines))
+	for i := range cart.Lines {
+		remaining[i] = cart.Lines[i].LineTotal()
+	}
+	now := cart.Now
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	appliedPromos := make(map[int]bool, len(candidates))
+	exclusiveApplied := false
+	for _, c := range candidates {
+		reject := func(r PromoRejectReason) {
+			bd.Rejected = append(bd.Rejected, PromoRejection{Code: c.Code, Reason: r})
+		}
+		p := &c.Promo.PromoCodeInsert
+		switch {
+		case appliedPromos[c.Promo.Id]:
+			reject(PromoRejectDuplicate)
+			continue
+		case !c.Promo.Allowed || !c.Promo.Start.Before(now) || !c.Promo.Expiration.After(now):
+			reject(PromoRejectInactive)
+			continue
+		case p.MinTier > 0 && cart.OrderId == 0 && !TierCanPurchase(cart.BuyerTier, p.MinTier):
+			reject(PromoRejectTier)
+			continue
+		case p.MaxUses.Valid && c.Usage.Total >= int(p.MaxUses.Int32):
+			reject(PromoRejectUsageLimit)
+			continue
+		case p.MaxUsesPerCustomer.Valid && cart.BuyerEmail != "" && c.Usage.Customer >= int(p.MaxUsesPerCustomer.Int32):
+			reject(PromoRejectCustomerLimit)
+			continue
+		case len(bd.Applied) > 0 && (exclusiveApplied || !p.IsStackable()):
+			reject(PromoRejectNotStackable)
+			continue
+		}
+
+		eligible := make([]bool, len(cart.Lines))
+		eligibleTotal := decimal.Zero
+		for i := range cart.Lines {
+			if promoLineEligible(p, &cart.Lines[i]) && remaining[i].IsPositive() {
+				eligible[i] = true
+				eligibleTotal = eligibleTotal.Add(remaining[i])
+			}
+		}
+
+		rule, hasRule := p.CurrencyRule(cart.Currency)
+		if p.Type() == PromoDiscountFixedAmount && (!hasRule || !rule.FixedAmount.Valid) {
+			reject(PromoRejectCurrency)
+			continue
+		}
+		// Only a free-shipping code for the whole cart applies without an eligible line to discount.
+		if !eligibleTotal.IsPositive() && (len(p.Scopes) > 0 || p.ExcludeSaleItems || !p.FreeShipping) {
+			reject(PromoRejectNoEligibleItems)
+			continue
+		}
+		if hasRule && rule.MinSpend.Valid && eligibleTotal.LessThan(rule.MinSpend.Decimal) {
+			reject(PromoRejectMinSpend)
+			continue
+		}
+
+		var lineDiscounts []decimal.Decimal
+		switch p.Type() {
+		case PromoDiscountFixedAmount:
+			lineDiscounts = fixedAmountLineDiscounts(rule.FixedAmount.Decimal, eligible, eligibleTotal, remaining, cart.DecimalPlaces)
+		case PromoDiscountBuyXGetY:
+			lineDiscounts = buyXGetYLineDiscounts(p, cart.Lines, eligible, remaining, cart.DecimalPlaces)
+		default:
+			lineDiscounts = percentageLineDiscounts(p.Discount, eligible, remaining, cart.DecimalPlaces)
+		}
+
+		discount := decimal.Zero
+		for _, d := range lineDiscounts {
+			discount = discount.Add(d)
+		}
+		if !discount.IsPositive() && !p.FreeShipping {
+			reject(PromoRejectNoEligibleItems)
+			continue
+		}
+		for i, d := range lineDiscounts {
+			remaining[i] = remaining[i].Sub(d)
+		}
+
+		bd.Applied = append(bd.Applied, PromoApplication{
+			Code:          c.Code,
+			PromoId:       c.Promo.Id,
+			UniqueCodeId:  c.UniqueCodeId,
+			DiscountType:  p.Type(),
+			Discount:      discount,
+			FreeShipping:  p.FreeShipping,
+			LineDiscounts: lineDiscounts,
+		})
+		bd.Discount = bd.Discount.Add(discount)
+		bd.FreeShipping = bd.FreeShipping || p.FreeShipping
+		appliedPromos[c.Promo.Id] = true
+		exclusiveApplied = exclusiveApplied || !p.IsStackable()
+	}
+	return bd
+}
+
+// promoLineEligible reports whether a line is in the promo's scope: not excluded as a sale line and,
+// when the promo has scopes, matching at least one of them.
+func promoLineEligible(p *PromoCodeInsert, l *PromoCartLine) bool {
+	if p.ExcludeSaleItems && l.OnSale {
+		return false
+	}
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	for _, sc := range p.Scopes {
+		switch sc.Kind {
+		case PromoScopeCollection:
+			if l.Collection != "" && strings.EqualFold(sc.Value, l.Collection) {
+				return true
+			}
+		case PromoScopeTag:
+			for _, t := range l.Tags {
+				if strings.EqualFold(sc.Value, t) {
+					return true
+				}
+			}
+		case PromoScopeTopCategory:
+			if id, err := strconv.Atoi(sc.Value); err == nil && id == l.TopCategoryId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func percentageLineDiscounts(pct decimal.Decimal, eligible []bool, remaining []decimal.Decimal, dp int32) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(remaining))
+	hundred := decimal.NewFromInt(100)
+	for i := range remaining {
+		out[i] = decimal.Zero
+		if eligible[i] {
+			out[i] = decimal.Min(remaining[i].Mul(pct).Div(hundred).Round(dp), remaining[i])
+		}
+	}
+	return out
+}
+
+// fixedAmountLineDiscounts splits amount (capped at the eligible total) across the eligible lines in
+// proportion to their value; the last eligible line takes the rounding remainder.
+func fixedAmountLineDiscounts(amount decimal.Decimal, eligible []bool, eligibleTotal decimal.Decimal, remaining []decimal.Decimal, dp int32) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(remaining))
+	for i := range out {
+		out[i] = decimal.Zero
+	}
+	if !eligibleTotal.IsPositive() {
+		return out
+	}
+	amount = decimal.Min(amount, eligibleTotal)
+	last := -1
+	for i := range remaining {
+		if eligible[i] {
+			last = i
+		}
+	}
+	allocated := decimal.Zero
+	for i := range remaining {
+		if !eligible[i] {
+			continue
+		}
+		if i == last {
+			out[i] = decimal.Min(amount.Sub(allocated), remaining[i])
+			break
+		}
+		out[i] = decimal.Min(amount.Mul(remaining[i]).Div(eligibleTotal).Round(dp), remaining[i])
+		allocated = allocated.Add(out[i])
+	}
+	return out
+}
+
+// buyXGetYLineDiscounts lines up the eligible units from dearest to cheapest and, in every complete
+// group of BuyQuantity+GetQuantity units, discounts the GetQuantity cheapest by Discount percent.
+// Only whole units count.
+func buyXGetYLineDiscounts(p *PromoCodeInsert, lines []PromoCartLine, eligible []bool, remaining []decimal.Decimal, dp int32) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(remaining))
+	for i := range out {
+		out[i] = decimal.Zero
+	}
+	group := p.BuyQuantity + p.GetQuantity
+	if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+		return out
+	}
+	type unit struct {
+		line  int
+		price decimal.Decimal
+	}
+	var units []unit
+	for i := range lines {
+		qty := lines[i].Quantity.IntPart()
+		if !eligible[i] || qty <= 0 {
+			continue
+		}
+		price := remaining[i].Div(lines[i].Quantity)
+		for n := int64(0); n < qty; n++ {
+			units = append(units, unit{line: i, price: price})
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price.GreaterThan(units[b].price) })
+
+	hundred := decimal.NewFromInt(100)
+	for start := 0; start+group <= len(units); start += group {
+		for _, u := range units[start+p.BuyQuantity : start+group] {
+			out[u.line] = out[u.line].Add(u.price.Mul(p.Discount).Div(hundred))
+		}
+	}
+	for i := range out {
+		out[i] = decimal.Min(out[i].Round(dp), remaining[i])
+	}
+	return out
+}
@@ -0,0 +1,298 @@
+package entity
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func testPromo(id int, code string, ins PromoCodeInsert) PromoCode {
+	now := time.Now().UTC()
+	ins.Code = code
+	ins.Allowed = true
+	ins.Start = now.Add(-24 * time.Hour)
+	ins.Expiration = now.Add(24 * time.Hour)
+	return PromoCode{Id: id, PromoCodeInsert: ins}
+}
+
+func testLine(productId int, price string, qty int64) PromoCartLine {
+	return PromoCartLine{
+		ProductId:     productId,
+		Quantity:      decimal.NewFromInt(qty),
+		UnitPrice:     decimal.RequireFromString(price),
+		TopCategoryId: 1,
+	}
+}
+
+func TestEvaluatePromos(t *testing.T) {
+	eur := func(lines ...PromoCartLine) PromoCart {
+		return PromoCart{Currency: "EUR", DecimalPlaces: 2, Lines: lines}
+	}
+	fixed := func(cur, amount, minSpend string) PromoCurrencyRule {
+		r := PromoCurrencyRule{Currency: cur}
+		if amount != "" {
+			r.FixedAmount = decimal.NewNullDecimal(decimal.RequireFromString(amount))
+		}
+		if minSpend != "" {
+			r.MinSpend = decimal.NewNullDecimal(decimal.RequireFromString(minSpend))
+		}
+		return r
+	}
+
+	tests := []struct {
+		name         string
+		cart         PromoCart
+		candidates   []PromoCandidate
+		wantDiscount string
+		wantFreeShip bool
+		wantApplied  []string
+		wantRejected map[string]PromoRejectReason
+	}{
+		{
+			name: "percentage on whole cart",
+			cart: eur(testLine(1, "100", 1), testLine(2, "50", 2)),
+			candidates: []PromoCandidate{
+				{Code: "SAVE10", Promo: testPromo(1, "SAVE10", PromoCodeInsert{Discount: decimal.NewFromInt(10)})},
+			},
+			wantDiscount: "20",
+			wantApplied:  []string{"SAVE10"},
+		},
+		{
+			name: "fixed amount split across lines and capped at cart value",
+			cart: eur(testLine(1, "30", 1), testLine(2, "10", 1)),
+			candidates: []PromoCandidate{
+				{Code: "TAKE50", Promo: testPromo(1, "TAKE50", PromoCodeInsert{
+					DiscountType:  PromoDiscountFixedAmount,
+					CurrencyRules: []PromoCurrencyRule{fixed("EUR", "50", "")},
+				})},
+			},
+			wantDiscount: "40",
+			wantApplied:  []string{"TAKE50"},
+		},
+		{
+			name: "fixed amount without the order currency is rejected",
+			cart: eur(testLine(1, "30", 1)),
+			candidates: []PromoCandidate{
+				{Code: "USD10", Promo: testPromo(1, "USD10", PromoCodeInsert{
+					DiscountType:  PromoDiscountFixedAmount,
+					CurrencyRules: []PromoCurrencyRule{fixed("USD", "10", "")},
+				})},
+			},
+			wantDiscount: "0",
+			wantRejected: map[string]PromoRejectReason{"USD10": PromoRejectCurrency},
+		},
+		{
+			name: "minimum spend counts only the eligible lines",
+			cart: eur(testLine(1, "80", 1), PromoCartLine{ProductId: 2, Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(100), TopCategoryId: 2}),
+			candidates: []PromoCandidate{
+				{Code: "TOPS", Promo: testPromo(1, "TOPS", PromoCodeInsert{
+					Discount:      decimal.NewFromInt(10),
+					CurrencyRules: []PromoCurrencyRule{fixed("EUR", "", "100")},
+					Scopes:        []PromoScope{{Kind: PromoScopeTopCategory, Value: "1"}},
+				})},
+			},
+			wantDiscount: "0",
+			wantRejected: map[string]PromoRejectReason{"TOPS": PromoRejectMinSpend},
+		},
+		{
+			name: "scope by tag and collection, sale lines excluded",
+			cart: eur(
+				PromoCartLine{ProductId: 1, Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(100), Tags: []string{"Outerwear"}},
+				PromoCartLine{ProductId: 2, Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(100), Collection: "FW26"},
+				PromoCartLine{ProductId: 3, Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(100), Collection: "FW26", OnSale: true},
+				PromoCartLine{ProductId: 4, Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(100)},
+			),
+			candidates: []PromoCandidate{
+				{Code: "SCOPED", Promo: testPromo(1, "SCOPED", PromoCodeInsert{
+					Discount:         decimal.NewFromInt(50),
+					ExcludeSaleItems: true,
+					Scopes: []PromoScope{
+						{Kind: PromoScopeTag, Value: "outerwear"},
+						{Kind: PromoScopeCollection, Value: "fw26"},
+					},
+				})},
+			},
+			wantDiscount: "100",
+			wantApplied:  []string{"SCOPED"},
+		},
+		{
+			name: "buy two get one free discounts the cheapest unit of each group",
+			cart: eur(testLine(1, "100", 2), testLine(2, "50", 2), testLine(3, "20", 2)),
+			candidates: []PromoCandidate{
+				{Code: "B2G1", Promo: testPromo(1, "B2G1", PromoCodeInsert{
+					DiscountType: PromoDiscountBuyXGetY,
+					BuyQuantity:  2,
+					GetQuantity:  1,
+					Discount:     decimal.NewFromInt(100),
+				})},
+			},
+			// units 100,100,50 | 50,20,20 → 50 and 20 free
+			wantDiscount: "70",
+			wantApplied:  []string{"B2G1"},
+		},
+		{
+			name: "tier-restricted code needs the tier",
+			cart: eur(testLine(1, "100", 1)),
+			candidates: []PromoCandidate{
+				{Code: "PLUS", Promo: testPromo(1, "PLUS", PromoCodeInsert{Discount: decimal.NewFromInt(10), MinTier: 2})},
+			},
+			wantDiscount: "0",
+			wantRejected: map[string]PromoRejectReason{"PLUS": PromoRejectTier},
+		},
+		{
+			name: "re-pricing an order keeps the tier code it was admitted with",
+			cart: PromoCart{Currency: "EUR", DecimalPlaces: 2, OrderId: 7, Lines: []PromoCartLine{testLine(1, "100", 1)}},
+			candidates: []PromoCandidate{
+				{Code: "PLUS", Promo: testPromo(1, "PLUS", PromoCodeInsert{Discount: decimal.NewFromInt(10), MinTier: 2})},
+			},
+			wantDiscount: "10",
+			wantApplied:  []string{"PLUS"},
+		},
+		{
+			name: "global and per-customer caps",
+			cart: PromoCart{Currency: "EUR", DecimalPlaces: 2, BuyerEmail: "a@b.c", Lines: []PromoCartLine{testLine(1, "100", 1)}},
+			candidates: []PromoCandidate{
+				{Code: "ONCE", Usage: PromoUsage{Total: 5, Customer: 1}, Promo: testPromo(1, "ONCE", PromoCodeInsert{
+					Discount: decimal.NewFromInt(10), MaxUsesPerCustomer: sql.NullInt32{Int32: 1, Valid: true},
+				})},
+				{Code: "FULL", Usage: PromoUsage{Total: 100}, Promo: testPromo(2, "FULL", PromoCodeInsert{
+					Discount: decimal.NewFromInt(10), MaxUses: sql.NullInt32{Int32: 100, Valid: true},
+				})},
+			},
+			wantDiscount: "0",
+			wantRejected: map[string]PromoRejectReason{"ONCE": PromoRejectCustomerLimit, "FULL": PromoRejectUsageLimit},
+		},
+		{
+			name: "stackable codes compound, exclusive code is refused",
+			cart: eur(testLine(1, "100", 1)),
+			candidates: []PromoCandidate{
+				{Code: "TEN", Promo: testPromo(1, "TEN", PromoCodeInsert{Discount: decimal.NewFromInt(10), Stacking: PromoStackingStackable})},
+				{Code: "SHIP", Promo: testPromo(2, "SHIP", PromoCodeInsert{FreeShipping: true, Stacking: PromoStackingStackable})},
+				{Code: "FIVE", Promo: testPromo(3, "FIVE", PromoCodeInsert{
+					DiscountType:  PromoDiscountFixedAmount,
+					Stacking:      PromoStackingStackable,
+					CurrencyRules: []PromoCurrencyRule{fixed("EUR", "5", "")},
+				})},
+				{Code: "SOLO", Promo: testPromo(4, "SOLO", PromoCodeInsert{Discount: decimal.NewFromInt(50)})},
+			},
+			wantDiscount: "15",
+			wantFreeShip: true,
+			wantApplied:  []string{"TEN", "SHIP", "FIVE"},
+			wantRejected: map[string]PromoRejectReason{"SOLO": PromoRejectNotStackable},
+		},
+		{
+			name: "exclusive code first keeps stackable codes out",
+			cart: eur(testLine(1, "100", 1)),
+			candidates: []PromoCandidate{
+				{Code: "SOLO", Promo: testPromo(1, "SOLO", PromoCodeInsert{Discount: decimal.NewFromInt(20)})},
+				{Code: "TEN", Promo: testPromo(2, "TEN", PromoCodeInsert{Discount: decimal.NewFromInt(10), Stacking: PromoStackingStackable})},
+				{Code: "SOLO", Promo: testPromo(1, "SOLO", PromoCodeInsert{Discount: decimal.NewFromInt(20)})},
+			},
+			wantDiscount: "20",
+			wantApplied:  []string{"SOLO"},
+			wantRejected: map[string]PromoRejectReason{"TEN": PromoRejectNotStackable, "SOLO": PromoRejectDuplicate},
+		},
+		{
+			name: "expired code is inactive",
+			cart: eur(testLine(1, "100", 1)),
+			candidates: []PromoCandidate{
+				{Code: "OLD", Promo: PromoCode{Id: 1, PromoCodeInsert: PromoCodeInsert{
+					Code: "OLD", Allowed: true, Discount: decimal.NewFromInt(10),
+					Start: time.Now().Add(-48 * time.Hour), Expiration: time.Now().Add(-24 * time.Hour),
+				}}},
+			},
+			wantDiscount: "0",
+			wantRejected: map[string]PromoRejectReason{"OLD": PromoRejectInactive},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := EvaluatePromos(tt.cart, tt.candidates)
+			if !bd.Discount.Equal(decimal.RequireFromString(tt.wantDiscount)) {
+				t.Errorf("discount = %s, want %s", bd.Discount, tt.wantDiscount)
+			}
+			if bd.FreeShipping != tt.wantFreeShip {
+				t.Errorf("free shipping = %v, want %v", bd.FreeShipping, tt.wantFreeShip)
+			}
+			codes := bd.Codes()
+			if len(codes) != len(tt.wantApplied) {
+				t.Fatalf("applied = %v, want %v", codes, tt.wantApplied)
+			}
+			for i := range codes {
+				if codes[i] != tt.wantApplied[i] {
+					t.Errorf("applied[%d] = %s, want %s", i, codes[i], tt.wantApplied[i])
+				}
+			}
+			got := map[string]PromoRejectReason{}
+			for _, r := range bd.Rejected {
+				got[r.Code] = r.Reason
+			}
+			if len(got) != len(tt.wantRejected) {
+				t.Fatalf("rejected = %v, want %v", got, tt.wantRejected)
+			}
+			for code, reason := range tt.wantRejected {
+				if got[code] != reason {
+					t.Errorf("rejected[%s] = %s, want %s", code, got[code], reason)
+				}
+			}
+			for _, a := range bd.Applied {
+				sum := decimal.Zero
+				for _, d := range a.LineDiscounts {
+					sum = sum.Add(d)
+				}
+				if !sum.Equal(a.Discount) {
+					t.Errorf("%s: line discounts sum to %s, discount is %s", a.Code, sum, a.Discount)
+				}
+			}
+		})
+	}
+}
+
+func TestPromoBreakdown_ApplyTo(t *testing.T) {
+	bd := PromoBreakdown{Discount: decimal.NewFromInt(30)}
+	total, free := bd.ApplyTo(decimal.NewFromInt(100), decimal.NewFromInt(15), 2)
+	if total.String() != "85" || free {
+		t.Errorf("ApplyTo = %s/%v, want 85/false", total, free)
+	}
+	bd.FreeShipping = true
+	total, free = bd.ApplyTo(decimal.NewFromInt(100), decimal.NewFromInt(15), 2)
+	if total.String() != "70" || !free {
+		t.Errorf("ApplyTo = %s/%v, want 70/true", total, free)
+	}
+	if pct := bd.EffectiveDiscountPct(decimal.NewFromInt(120)); !pct.Valid || pct.Decimal.String() != "25" {
+		t.Errorf("EffectiveDiscountPct = %v, want 25", pct)
+	}
+}
+
+func TestPromoCodeInsert_Validate(t *testing.T) {
+	ok := []PromoCodeInsert{
+		{Code: "P", Discount: decimal.NewFromInt(10)},
+		{Code: "F", DiscountType: PromoDiscountFixedAmount, CurrencyRules: []PromoCurrencyRule{{Currency: "eur", FixedAmount: decimal.NewNullDecimal(decimal.NewFromInt(5))}}},
+		{Code: "B", DiscountType: PromoDiscountBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Discount: decimal.NewFromInt(100)},
+	}
+	for _, p := range ok {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: unexpected error %v", p.Code, err)
+		}
+		if p.Stacking != PromoStackingExclusive {
+			t.Errorf("%s: stacking defaulted to %q", p.Code, p.Stacking)
+		}
+	}
+	bad := []PromoCodeInsert{
+		{Code: ""},
+		{Code: "P", Discount: decimal.NewFromInt(120)},
+		{Code: "F", DiscountType: PromoDiscountFixedAmount},
+		{Code: "B", DiscountType: PromoDiscountBuyXGetY, BuyQuantity: 2, Discount: decimal.NewFromInt(100)},
+		{Code: "S", Scopes: []PromoScope{{Kind: PromoScopeTopCategory, Value: "tops"}}},
+		{Code: "C", MaxUses: sql.NullInt32{Int32: 0, Valid: true}},
+		{Code: "X", Discount: decimal.NewFromInt(10), CurrencyRules: []PromoCurrencyRule{{Currency: "EUR", FixedAmount: decimal.NewNullDecimal(decimal.NewFromInt(5))}}},
+	}
+	for _, p := range bad {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%q: expected a validation error", p.Code)
+		}
+	}
+}
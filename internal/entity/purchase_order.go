@@ -0,0 +1,468 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Material purchase orders (0336). A purchase order is what was ASKED of a supplier — material,
+// quantity, price, promised date — and it is the document a receipt is booked against. The stock
+// side is unchanged: a PO receipt is an ordinary purchase receipt (ReceiveInTx) that additionally
+// names the PO line it delivers, so the moving average, the lot, the price history and the M1 entry
+// (Dr 1110 / Cr 2010, tagged with the PO's supplier) are the same ones a free receipt gets. The
+// payable is therefore the received value, never the ordered one: a PO that is half delivered owes
+// half.
+
+// Purchase order errors.
+var (
+	// ErrPurchaseOrderNotFound is returned for an unknown purchase order or line id.
+	ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+	// ErrPurchaseOrderState is returned when the order's status does not allow the operation —
+	// editing a sent order, receiving against a draft, cancelling one that has received goods.
+	ErrPurchaseOrderState = errors.New("purchase order status does not allow this")
+	// ErrPurchaseOrderOverReceipt is returned when a receipt would take a line past its ordered
+	// quantity by more than PurchaseOrderOverReceiptPct.
+	ErrPurchaseOrderOverReceipt = errors.New("receipt exceeds the quantity ordered")
+)
+
+// PurchaseOrderOverReceiptPct is how far past its ordered quantity a line may be received. Rolls are
+// cut to length at the mill and never arrive to the metre; refusing 151 m on a 150 m line would only
+// push the operator into a second, free receipt that the PO never sees.
+const PurchaseOrderOverReceiptPct = 10
+
+// PurchaseOrderStatus is the lifecycle of a purchase order.
+type PurchaseOrderStatus string
+
+const (
+	// PurchaseOrderDraft is being put together: lines and prices are editable, nothing is owed.
+	PurchaseOrderDraft PurchaseOrderStatus = "draft"
+	// PurchaseOrderOrdered has been sent to the supplier and awaits delivery.
+	PurchaseOrderOrdered PurchaseOrderStatus = "ordered"
+	// PurchaseOrderPartiallyReceived has received some, but not all, of its lines in full.
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	// PurchaseOrderReceived has received every line in full.
+	PurchaseOrderReceived PurchaseOrderStatus = "received"
+	// PurchaseOrderClosed was closed short by hand: what arrived stays, the rest will not come.
+	PurchaseOrderClosed PurchaseOrderStatus = "closed"
+	// PurchaseOrderCancelled was withdrawn before anything arrived.
+	PurchaseOrderCancelled PurchaseOrderStatus = "cancelled"
+)
+
+// ValidPurchaseOrderStatuses mirrors the purchase_order.status ENUM.
+var ValidPurchaseOrderStatuses = map[PurchaseOrderStatus]bool{
+	PurchaseOrderDraft:             true,
+	PurchaseOrderOrdered:           true,
+	PurchaseOrderPartiallyReceived: true,
+	PurchaseOrderReceived:          true,
+	PurchaseOrderClosed:            true,
+	PurchaseOrderCancelled:         true,
+}
+
+// IsOpen reports a status whose outstanding quantity is still expected to arrive — the quantity a
+// new suggestion must not order a second time.
+func (s PurchaseOrderStatus) IsOpen() bool {
+	return s == PurchaseOrderDraft || s == PurchaseOrderOrdered || s == PurchaseOrderPartiallyReceived
+}
+
+// IsReceivable reports a status a receipt may be booked against.
+func (s PurchaseOrderStatus) IsReceivable() bool {
+	return s == PurchaseOrderOrdered || s == PurchaseOrderPartiallyReceived
+}
+
+// PurchaseOrderNumber is the document number a purchase order is known by outside the system — on
+// the order sent to the supplier and on the receipts booked against it.
+func PurchaseOrderNumber(id int) string {
+	return fmt.Sprintf("PO-%05d", id)
+}
+
+// PurchaseOrder is a purchase order header.
+type PurchaseOrder struct {
+	Id           int                 `db:"id"`
+	SupplierId   int                 `db:"supplier_id"`
+	SupplierName string              `db:"supplier_name"` // joined for display
+	Status       PurchaseOrderStatus `db:"status"`
+	Currency     string              `db:"currency"`
+	// OrderedAt is the day the order went to the supplier; NULL while a draft.
+	OrderedAt sql.NullTime `db:"ordered_at"`
+	// ExpectedAt is the promised delivery day of the whole order — the latest of its lines'.
+	ExpectedAt sql.NullTime `db:"expected_at"`
+	// ProductionRunId is the run whose shortfall the order was suggested for; NULL for a stock order.
+	ProductionRunId sql.NullInt32  `db:"production_run_id"`
+	SupplierRef     sql.NullString `db:"supplier_ref"` // the supplier's confirmation number
+	Notes           sql.NullString `db:"notes"`
+	CreatedBy       string         `db:"created_by"`
+	CreatedAt       time.Time      `db:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at"`
+}
+
+// PurchaseOrderLine is one material of a purchase order.
+type PurchaseOrderLine struct {
+	Id              int             `db:"id"`
+	PurchaseOrderId int             `db:"purchase_order_id"`
+	MaterialId      int             `db:"material_id"`
+	MaterialName    string          `db:"material_name"` // joined for display
+	Unit            sql.NullString  `db:"unit"`          // joined for display
+	OrderedQty      decimal.Decimal `db:"ordered_qty"`
+	ReceivedQty     decimal.Decimal `db:"received_qty"`
+	// UnitPrice is NET, in the order's currency. NULL on a draft that has not been priced yet; an
+	// order cannot be sent without it, because the receipt books its value from it.
+	UnitPrice  decimal.NullDecimal `db:"unit_price"`
+	ExpectedAt sql.NullTime        `db:"expected_at"`
+	Note       sql.NullString      `db:"note"`
+}
+
+// OutstandingQty is what is still to arrive; zero once the line is received in full (or over).
+func (l PurchaseOrderLine) OutstandingQty() decimal.Decimal {
+	return decimal.Max(l.OrderedQty.Sub(l.ReceivedQty), decimal.Zero)
+}
+
+// IsReceived reports a line received in full.
+func (l PurchaseOrderLine) IsReceived() bool {
+	return l.ReceivedQty.GreaterThanOrEqual(l.OrderedQty)
+}
+
+// MaxReceivableQty is the most a single receipt may still book on the line, over-receipt tolerance
+// included.
+func (l PurchaseOrderLine) MaxReceivableQty() decimal.Decimal {
+	limit := l.OrderedQty.Mul(decimal.NewFromInt(100 + PurchaseOrderOverReceiptPct)).Div(decimal.NewFromInt(100))
+	return decimal.Max(limit.Sub(l.ReceivedQty), decimal.Zero)
+}
+
+// PurchaseOrderFull is an order with its lines and the totals derived from them.
+type PurchaseOrderFull struct {
+	PurchaseOrder
+	Lines []PurchaseOrderLine
+}
+
+// OrderedValue is Σ ordered × price over priced lines, in the order's currency.
+func (p PurchaseOrderFull) OrderedValue() decimal.Decimal {
+	total := decimal.Zero
+	for _, l := range p.Lines {
+		if l.UnitPrice.Valid {
+			total = total.Add(l.OrderedQty.Mul(l.UnitPrice.Decimal))
+		}
+	}
+	return total.Round(2)
+}
+
+// ReceivedValue is Σ received × price — what the receipts have made payable, in the order's currency.
+func (p PurchaseOrderFull) ReceivedValue() decimal.Decimal {
+	total := decimal.Zero
+	for _, l := range p.Lines {
+		if l.UnitPrice.Valid {
+			total = total.Add(l.ReceivedQty.Mul(l.UnitPrice.Decimal))
+		}
+	}
+	return total.Round(2)
+}
+
+// IsLate reports an open order past its promised date on `today`.
+func (p PurchaseOrderFull) IsLate(today time.Time) bool {
+	if !p.Status.IsReceivable() || !p.ExpectedAt.Valid {
+		return false
+	}
+	return dateOnly(today).After(dateOnly(p.ExpectedAt.Time))
+}
+
+// ReceivedStatus is the status an ordered PO takes after its lines moved: received when every line
+// is in full, partially received when anything arrived, ordered otherwise.
+func ReceivedStatus(lines []PurchaseOrderLine) PurchaseOrderStatus {
+	full, some := len(lines) > 0, false
+	for _, l := range lines {
+		if !l.IsReceived() {
+			full = false
+		}
+		if l.ReceivedQty.IsPositive() {
+			some = true
+		}
+	}
+	switch {
+	case full:
+		return PurchaseOrderReceived
+	case some:
+		return PurchaseOrderPartiallyReceived
+	}
+	return PurchaseOrderOrdered
+}
+
+// CheckPurchaseOrderTransition validates a status change made by hand. Received and partially
+// received are never set by hand — receipts derive them — so the manual moves are: send a priced
+// draft (ordered), withdraw an order nothing has arrived on (cancelled), and close short an order
+// that will not be completed (closed).
+func CheckPurchaseOrderTransition(from, to PurchaseOrderStatus, lines []PurchaseOrderLine) error {
+	received := false
+	for _, l := range lines {
+		if l.ReceivedQty.IsPositive() {
+			received = true
+		}
+	}
+	switch to {
+	case PurchaseOrderOrdered:
+		if from != PurchaseOrderDraft {
+			return fmt.Errorf("%w: only a draft can be sent, order is %s", ErrPurchaseOrderState, from)
+		}
+		if len(lines) == 0 {
+			return fmt.Errorf("%w: order has no lines", ErrPurchaseOrderState)
+		}
+		for _, l := range lines {
+			if !l.UnitPrice.Valid {
+				return fmt.Errorf("%w: line for %q has no unit price", ErrPurchaseOrderState, l.MaterialName)
+			}
+		}
+		return nil
+	case PurchaseOrderCancelled:
+		if from != PurchaseOrderDraft && from != PurchaseOrderOrdered {
+			return fmt.Errorf("%w: order is %s; close it instead", ErrPurchaseOrderState, from)
+		}
+		if received {
+			return fmt.Errorf("%w: goods were received on the order; close it instead", ErrPurchaseOrderState)
+		}
+		return nil
+	case PurchaseOrderClosed:
+		if from != PurchaseOrderOrdered && from != PurchaseOrderPartiallyReceived {
+			return fmt.Errorf("%w: only a sent order can be closed, order is %s", ErrPurchaseOrderState, from)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: status %s is not set by hand", ErrPurchaseOrderState, to)
+}
+
+// PurchaseOrderLineInsert is one line of a new or edited draft.
+type PurchaseOrderLineInsert struct {
+	MaterialId int
+	OrderedQty decimal.Decimal
+	UnitPrice  decimal.NullDecimal
+	ExpectedAt sql.NullTime
+	Note       sql.NullString
+}
+
+// PurchaseOrderInsert is the payload of a new draft, or the full replacement of a draft's contents.
+type PurchaseOrderInsert struct {
+	SupplierId      int
+	Currency        string
+	ProductionRunId sql.NullInt32
+	SupplierRef     sql.NullString
+	Notes           sql.NullString
+	Lines           []PurchaseOrderLineInsert
+	CreatedBy       string
+}
+
+// ExpectedAt is the latest promised date of the lines; NULL when no line carries one.
+func (p PurchaseOrderInsert) ExpectedAt() sql.NullTime {
+	var out sql.NullTime
+	for _, l := range p.Lines {
+		if l.ExpectedAt.Valid && (!out.Valid || l.ExpectedAt.Time.After(out.Time)) {
+			out = l.ExpectedAt
+		}
+	}
+	return out
+}
+
+// PurchaseOrderReceiptLine is one line of a delivery booked against a PO.
+type PurchaseOrderReceiptLine struct {
+	LineId   int
+	Quantity decimal.Decimal
+	// Lot / MeasuredWidthCm / ShadeCode are the roll facts of MaterialReceiptInsert, per line.
+	Lot             sql.NullString
+	MeasuredWidthCm decimal.NullDecimal
+	ShadeCode       sql.NullString
+}
+
+// PurchaseOrderReceipt is one delivery against a purchase order: several lines may arrive together
+// under one delivery note, and they are booked in one transaction.
+type PurchaseOrderReceipt struct {
+	PurchaseOrderId int
+	Lines           []PurchaseOrderReceiptLine
+	SupplierDoc     sql.NullString // the delivery note / invoice number
+	OccurredAt      sql.NullTime
+	// InputVatRegime applies to every line; each line's VAT amount is the delivery's InputVatAmount
+	// shared pro rata to net value, so the M1 input-VAT rule sees the same total the invoice states.
+	InputVatAmount decimal.NullDecimal
+	InputVatRegime sql.NullString
+	Comment        sql.NullString
+	AdminUsername  string
+}
+
+// PurchaseOrderFilter narrows the purchase order list.
+type PurchaseOrderFilter struct {
+	Status          PurchaseOrderStatus
+	SupplierId      int
+	MaterialId      int
+	ProductionRunId int
+	OpenOnly        bool // draft, ordered or partially received
+}
+
+// SupplierLeadTime is what a supplier's orders actually took: days from ordered_at to the last
+// receipt, over orders received in full. A promise (Supplier.LeadTimeDays) is what to plan with; this
+// is what to check it against.
+type SupplierLeadTime struct {
+	SupplierId      int     `db:"supplier_id"`
+	ReceivedOrders  int     `db:"received_orders"`
+	AvgLeadTimeDays float64 `db:"avg_lead_time_days"`
+	MaxLeadTimeDays int     `db:"max_lead_time_days"`
+	// LateOrders counts received orders whose last receipt came after their expected date.
+	LateOrders int `db:"late_orders"`
+}
+
+// PurchaseNeed is one material a production run is short of, with what the catalog knows about
+// buying it.
+type PurchaseNeed struct {
+	MaterialId   int
+	MaterialName string
+	Unit         string
+	// Shortage is the run's max(0, required − issued − on_hand), from the material plan.
+	Shortage decimal.Decimal
+	// OnOrder is the outstanding quantity of the material on open purchase orders.
+	OnOrder decimal.Decimal
+	// SupplierId is the material's catalogued supplier; 0 = none, the need cannot be ordered.
+	SupplierId int
+	// LeadTimeDays is the material's own order-to-door time; invalid = fall back to the supplier's.
+	LeadTimeDays sql.NullInt64
+	// LastPrice / LastCurrency are the material's latest price point; the suggested line is priced
+	// from it and left unpriced without one.
+	LastPrice    decimal.NullDecimal
+	LastCurrency string
+}
+
+// PurchaseSuggestionLine is one line of a suggested order.
+type PurchaseSuggestionLine struct {
+	Need       PurchaseNeed
+	Quantity   decimal.Decimal
+	ExpectedAt sql.NullTime
+}
+
+// PurchaseSuggestion is one order the suggestion would place: one supplier, one currency.
+type PurchaseSuggestion struct {
+	SupplierId int
+	Currency   string
+	Lines      []PurchaseSuggestionLine
+}
+
+// PurchaseSuggestions is the answer of SuggestPurchaseOrders.
+type PurchaseSuggestions struct {
+	Orders []PurchaseSuggestion
+	// Covered are needs open orders already cover in full: nothing to order.
+	Covered []PurchaseNeed
+	// Unassigned are needs whose material has no catalogued supplier: they cannot be ordered until
+	// one is set on the material, and saying so beats ordering them from nobody.
+	Unassigned []PurchaseNeed
+}
+
+// SuggestPurchaseOrders turns a run's shortfalls into draft orders: for each short material, the
+// shortage less what open orders already bring, grouped into one order per (supplier, currency).
+// The expected date is today plus the material's lead time, else the supplier's
+// (supplierLeadDays), else none. A line is priced in the currency of the material's last price;
+// an unpriced line goes with the supplier's other lines in `fallbackCurrency`.
+func SuggestPurchaseOrders(needs []PurchaseNeed, supplierLeadDays map[int]sql.NullInt64, fallbackCurrency string, today time.Time) PurchaseSuggestions {
+	var out PurchaseSuggestions
+	type key struct {
+		supplier int
+		currency string
+	}
+	index := map[key]int{}
+	for _, n := range needs {
+		if !n.Shortage.IsPositive() {
+			continue
+		}
+		qty := n.Shortage.Sub(n.OnOrder)
+		if !qty.IsPositive() {
+			out.Covered = append(out.Covered, n)
+			continue
+		}
+		if n.SupplierId <= 0 {
+			out.Unassigned = append(out.Unassigned, n)
+			continue
+		}
+		cur := fallbackCurrency
+		if n.LastPrice.Valid && n.LastCurrency != "" {
+			cur = n.LastCurrency
+		} else {
+			n.LastPrice = decimal.NullDecimal{}
+		}
+		lead := n.LeadTimeDays
+		if !lead.Valid {
+			lead = supplierLeadDays[n.SupplierId]
+		}
+		line := PurchaseSuggestionLine{Need: n, Quantity: qty}
+		if lead.Valid && lead.Int64 >= 0 {
+			line.ExpectedAt = sql.NullTime{Time: dateOnly(today).AddDate(0, 0, int(lead.Int64)), Valid: true}
+		}
+		k := key{n.SupplierId, cur}
+		i, ok := index[k]
+		if !ok {
+			i = len(out.Orders)
+			index[k] = i
+			out.Orders = append(out.Orders, PurchaseSuggestion{SupplierId: n.SupplierId, Currency: cur})
+		}
+		out.Orders[i].Lines = append(out.Orders[i].Lines, line)
+	}
+	sort.SliceStable(out.Orders, func(i, j int) bool {
+		if out.Orders[i].SupplierId != out.Orders[j].SupplierId {
+			return out.Orders[i].SupplierId < out.Orders[j].SupplierId
+		}
+		return out.Orders[i].Currency < out.Orders[j].Currency
+	})
+	return out
+}
+
+// Insert is the suggestion as a draft purchase order.
+func (s PurchaseSuggestion) Insert(runID int, createdBy string) PurchaseOrderInsert {
+	ins := PurchaseOrderInsert{
+		SupplierId:      s.SupplierId,
+		Currency:        s.Currency,
+		ProductionRunId: sql.NullInt32{Int32: int32(runID), Valid: runID > 0},
+		CreatedBy:       createdBy,
+	}
+	for _, l := range s.Lines {
+		ins.Lines = append(ins.Lines, PurchaseOrderLineInsert{
+			MaterialId: l.Need.MaterialId,
+			OrderedQty: l.Quantity,
+			UnitPrice:  l.Need.LastPrice,
+			ExpectedAt: l.ExpectedAt,
+		})
+	}
+	return ins
+}
+
+// SplitInputVat shares a delivery's input VAT across its lines pro rata to their net values, to the
+// cent, with the rounding remainder on the largest line — so the parts add up to the invoice's VAT
+// exactly. Without any net value the whole amount goes on the first line.
+func SplitInputVat(total decimal.Decimal, nets []decimal.Decimal) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(nets))
+	if len(nets) == 0 {
+		return out
+	}
+	sum, largest := decimal.Zero, 0
+	for i, n := range nets {
+		out[i] = decimal.Zero
+		sum = sum.Add(n)
+		if n.GreaterThan(nets[largest]) {
+			largest = i
+		}
+	}
+	total = total.Round(2)
+	if !sum.IsPositive() {
+		out[0] = total
+		return out
+	}
+	rest := total
+	for i, n := range nets {
+		if i == largest {
+			continue
+		}
+		out[i] = total.Mul(n).Div(sum).Round(2)
+		rest = rest.Sub(out[i])
+	}
+	out[largest] = rest
+	return out
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
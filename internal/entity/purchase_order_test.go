@@ -0,0 +1,182 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSuggestPurchaseOrders(t *testing.T) {
+	today := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	price := decimal.NullDecimal{Decimal: d("4.20"), Valid: true}
+	needs := []PurchaseNeed{
+		// 120 short, 50 already on order → order 70 from supplier 2 in EUR, material lead time wins.
+		{MaterialId: 1, Shortage: d("120"), OnOrder: d("50"), SupplierId: 2,
+			LeadTimeDays: sql.NullInt64{Int64: 14, Valid: true}, LastPrice: price, LastCurrency: "EUR"},
+		// same supplier, no price → the fallback currency, a separate order; supplier lead time.
+		{MaterialId: 2, Shortage: d("3"), SupplierId: 2},
+		// covered in full by open orders.
+		{MaterialId: 3, Shortage: d("10"), OnOrder: d("10"), SupplierId: 2},
+		// no supplier on the material.
+		{MaterialId: 4, Shortage: d("5")},
+		// not short at all.
+		{MaterialId: 5, Shortage: decimal.Zero, SupplierId: 1},
+		// supplier 1, USD price, no lead time anywhere.
+		{MaterialId: 6, Shortage: d("2.5"), SupplierId: 1, LastPrice: price, LastCurrency: "USD"},
+	}
+	lead := map[int]sql.NullInt64{2: {Int64: 21, Valid: true}}
+	got := SuggestPurchaseOrders(needs, lead, "PLN", today)
+
+	if len(got.Covered) != 1 || got.Covered[0].MaterialId != 3 {
+		t.Fatalf("covered = %+v, want material 3", got.Covered)
+	}
+	if len(got.Unassigned) != 1 || got.Unassigned[0].MaterialId != 4 {
+		t.Fatalf("unassigned = %+v, want material 4", got.Unassigned)
+	}
+	if len(got.Orders) != 3 {
+		t.Fatalf("orders = %d, want 3", len(got.Orders))
+	}
+	want := []struct {
+		supplier int
+		currency string
+		material int
+		qty      string
+		expected string
+		priced   bool
+	}{
+		{1, "USD", 6, "2.5", "", true},
+		{2, "EUR", 1, "70", "2026-03-24", true},
+		{2, "PLN", 2, "3", "2026-03-31", false},
+	}
+	for i, w := range want {
+		o := got.Orders[i]
+		if o.SupplierId != w.supplier || o.Currency != w.currency || len(o.Lines) != 1 {
+			t.Fatalf("order %d = %d/%s with %d lines, want %d/%s with 1", i, o.SupplierId, o.Currency, len(o.Lines), w.supplier, w.currency)
+		}
+		l := o.Lines[0]
+		if l.Need.MaterialId != w.material || !l.Quantity.Equal(d(w.qty)) {
+			t.Errorf("order %d line = material %d qty %s, want %d qty %s", i, l.Need.MaterialId, l.Quantity, w.material, w.qty)
+		}
+		gotExp := ""
+		if l.ExpectedAt.Valid {
+			gotExp = l.ExpectedAt.Time.Format("2006-01-02")
+		}
+		if gotExp != w.expected {
+			t.Errorf("order %d expected_at = %q, want %q", i, gotExp, w.expected)
+		}
+		ins := o.Insert(7, "kate")
+		if ins.Lines[0].UnitPrice.Valid != w.priced {
+			t.Errorf("order %d priced = %v, want %v", i, ins.Lines[0].UnitPrice.Valid, w.priced)
+		}
+		if !ins.ProductionRunId.Valid || ins.ProductionRunId.Int32 != 7 || ins.CreatedBy != "kate" {
+			t.Errorf("order %d insert header = %+v", i, ins)
+		}
+	}
+}
+
+func TestPurchaseOrderLineQuantities(t *testing.T) {
+	l := PurchaseOrderLine{OrderedQty: d("150"), ReceivedQty: d("100")}
+	if !l.OutstandingQty().Equal(d("50")) {
+		t.Errorf("outstanding = %s, want 50", l.OutstandingQty())
+	}
+	// 10% over-receipt tolerance: 165 in all, 100 already in.
+	if !l.MaxReceivableQty().Equal(d("65")) {
+		t.Errorf("max receivable = %s, want 65", l.MaxReceivableQty())
+	}
+	l.ReceivedQty = d("170")
+	if !l.OutstandingQty().IsZero() || !l.MaxReceivableQty().IsZero() || !l.IsReceived() {
+		t.Errorf("over-received line: outstanding %s, max %s, received %v", l.OutstandingQty(), l.MaxReceivableQty(), l.IsReceived())
+	}
+}
+
+func TestReceivedStatus(t *testing.T) {
+	line := func(ordered, received string) PurchaseOrderLine {
+		return PurchaseOrderLine{OrderedQty: d(ordered), ReceivedQty: d(received)}
+	}
+	cases := map[string]struct {
+		lines []PurchaseOrderLine
+		want  PurchaseOrderStatus
+	}{
+		"nothing yet": {[]PurchaseOrderLine{line("10", "0"), line("5", "0")}, PurchaseOrderOrdered},
+		"one partial": {[]PurchaseOrderLine{line("10", "4"), line("5", "0")}, PurchaseOrderPartiallyReceived},
+		"one in full": {[]PurchaseOrderLine{line("10", "10"), line("5", "0")}, PurchaseOrderPartiallyReceived},
+		"all in full": {[]PurchaseOrderLine{line("10", "10.5"), line("5", "5")}, PurchaseOrderReceived},
+		"no lines":    {nil, PurchaseOrderOrdered},
+	}
+	for name, c := range cases {
+		if got := ReceivedStatus(c.lines); got != c.want {
+			t.Errorf("%s: got %s, want %s", name, got, c.want)
+		}
+	}
+}
+
+func TestCheckPurchaseOrderTransition(t *testing.T) {
+	priced := PurchaseOrderLine{OrderedQty: d("1"), UnitPrice: decimal.NullDecimal{Decimal: d("2"), Valid: true}}
+	unpriced := PurchaseOrderLine{OrderedQty: d("1"), MaterialName: "poplin"}
+	received := priced
+	received.ReceivedQty = d("1")
+	cases := []struct {
+		name     string
+		from, to PurchaseOrderStatus
+		lines    []PurchaseOrderLine
+		ok       bool
+	}{
+		{"send priced draft", PurchaseOrderDraft, PurchaseOrderOrdered, []PurchaseOrderLine{priced}, true},
+		{"send unpriced draft", PurchaseOrderDraft, PurchaseOrderOrdered, []PurchaseOrderLine{priced, unpriced}, false},
+		{"send empty draft", PurchaseOrderDraft, PurchaseOrderOrdered, nil, false},
+		{"resend", PurchaseOrderOrdered, PurchaseOrderOrdered, []PurchaseOrderLine{priced}, false},
+		{"cancel draft", PurchaseOrderDraft, PurchaseOrderCancelled, []PurchaseOrderLine{unpriced}, true},
+		{"cancel ordered", PurchaseOrderOrdered, PurchaseOrderCancelled, []PurchaseOrderLine{priced}, true},
+		{"cancel partial", PurchaseOrderPartiallyReceived, PurchaseOrderCancelled, []PurchaseOrderLine{received}, false},
+		{"close partial", PurchaseOrderPartiallyReceived, PurchaseOrderClosed, []PurchaseOrderLine{received}, true},
+		{"close draft", PurchaseOrderDraft, PurchaseOrderClosed, []PurchaseOrderLine{priced}, false},
+		{"set received", PurchaseOrderOrdered, PurchaseOrderReceived, []PurchaseOrderLine{priced}, false},
+	}
+	for _, c := range cases {
+		err := CheckPurchaseOrderTransition(c.from, c.to, c.lines)
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.ok && !errors.Is(err, ErrPurchaseOrderState) {
+			t.Errorf("%s: got %v, want ErrPurchaseOrderState", c.name, err)
+		}
+	}
+}
+
+func TestSplitInputVat(t *testing.T) {
+	got := SplitInputVat(d("10.00"), []decimal.Decimal{d("100"), d("100"), d("100")})
+	sum := decimal.Zero
+	for _, v := range got {
+		sum = sum.Add(v)
+	}
+	if !sum.Equal(d("10")) {
+		t.Fatalf("parts %v add up to %s, want 10", got, sum)
+	}
+	if !got[1].Equal(d("3.33")) || !got[2].Equal(d("3.33")) || !got[0].Equal(d("3.34")) {
+		t.Errorf("parts = %v, want remainder on the first (largest) line", got)
+	}
+	got = SplitInputVat(d("5"), []decimal.Decimal{decimal.Zero, decimal.Zero})
+	if !got[0].Equal(d("5")) || !got[1].IsZero() {
+		t.Errorf("no net value: parts = %v, want all on the first line", got)
+	}
+}
+
+func TestPurchaseOrderIsLate(t *testing.T) {
+	po := PurchaseOrderFull{PurchaseOrder: PurchaseOrder{
+		Status:     PurchaseOrderOrdered,
+		ExpectedAt: sql.NullTime{Time: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), Valid: true},
+	}}
+	if po.IsLate(time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)) {
+		t.Error("due today is not late")
+	}
+	if !po.IsLate(time.Date(2026, 3, 11, 1, 0, 0, 0, time.UTC)) {
+		t.Error("a day past due is late")
+	}
+	po.Status = PurchaseOrderReceived
+	if po.IsLate(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("a received order is never late")
+	}
+}
@@ -0,0 +1,183 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ReturnRequestStatus is the lifecycle state of a customer return (return_request.status, 0333).
+type ReturnRequestStatus string
+
+const (
+	ReturnStatusRequested ReturnRequestStatus = "requested"
+	ReturnStatusApproved  ReturnRequestStatus = "approved"
+	ReturnStatusRejected  ReturnRequestStatus = "rejected"
+	ReturnStatusReceived  ReturnRequestStatus = "received"
+	// ReturnStatusRefunding is the claim taken before the payment provider is called; a failed
+	// hand-off stays here and may be retried.
+	ReturnStatusRefunding ReturnRequestStatus = "refunding"
+	ReturnStatusRefunded  ReturnRequestStatus = "refunded"
+	ReturnStatusExchanged ReturnRequestStatus = "exchanged"
+	ReturnStatusCancelled ReturnRequestStatus = "cancelled"
+)
+
+// ReturnResolution is what the customer wants for the returned units.
+type ReturnResolution string
+
+const (
+	ReturnResolutionRefund   ReturnResolution = "refund"
+	ReturnResolutionExchange ReturnResolution = "exchange"
+)
+
+// ValidReturnResolutions is the resolution vocabulary accepted on create.
+var ValidReturnResolutions = map[ReturnResolution]bool{
+	ReturnResolutionRefund:   true,
+	ReturnResolutionExchange: true,
+}
+
+// ValidReturnReasonCodes is the customer-facing reason vocabulary. It is the refund reason key set
+// (dto.RefundReasonKey), so a return's reason flows unchanged into refund_reason_code on hand-off.
+var ValidReturnReasonCodes = map[string]bool{
+	"wrong_size":       true,
+	"not_as_described": true,
+	"defective":        true,
+	"changed_mind":     true,
+	"other":            true,
+}
+
+// returnTransitions lists, per state, the states a return may move to. Terminal states are absent.
+var returnTransitions = map[ReturnRequestStatus][]ReturnRequestStatus{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected, ReturnStatusCancelled},
+	ReturnStatusApproved:  {ReturnStatusReceived, ReturnStatusRejected, ReturnStatusCancelled},
+	ReturnStatusReceived:  {ReturnStatusRefunding, ReturnStatusExchanged, ReturnStatusRejected},
+	ReturnStatusRefunding: {ReturnStatusRefunding, ReturnStatusRefunded},
+}
+
+// ErrReturnRequestTransition is returned when an action does not apply to the return's current state.
+var ErrReturnRequestTransition = errors.New("return request is not in a state that allows this action")
+
+// ErrReturnExchangeUnavailable is returned when the replacement size of an exchange is out of stock.
+var ErrReturnExchangeUnavailable = errors.New("exchange size is not available")
+
+// ReturnHoldSession is the stockreserve session an exchange request briefly holds its replacement
+// units under, so they are checked against other shoppers' cart holds. The hold lasts only for the
+// request call: creating the return takes the units out of stock, and the session is released.
+func ReturnHoldSession(orderUUID string) string {
+	return "rma:" + orderUUID
+}
+
+// CanTransitionReturnRequest reports whether a return in state from may move to state to.
+func CanTransitionReturnRequest(from, to ReturnRequestStatus) bool {
+	for _, s := range returnTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnRequest is one customer return. OrderUUID and BuyerEmail are joined from the order.
+type ReturnRequest struct {
+	Id             int                 `db:"id"`
+	UUID           string              `db:"uuid"`
+	RMANumber      string              `db:"rma_number"`
+	OrderId        int                 `db:"order_id"`
+	OrderUUID      string              `db:"order_uuid"`
+	BuyerEmail     string              `db:"buyer_email"`
+	Status         ReturnRequestStatus `db:"status"`
+	Resolution     ReturnResolution    `db:"resolution"`
+	ReasonCode     string              `db:"reason_code"`
+	CustomerNote   sql.NullString      `db:"customer_note"`
+	AdminNote      sql.NullString      `db:"admin_note"`
+	Disposition    string              `db:"disposition"`
+	StockAllocated bool                `db:"stock_allocated"`
+	DecidedBy      sql.NullString      `db:"decided_by"`
+	ApprovedAt     sql.NullTime        `db:"approved_at"`
+	ReceivedAt     sql.NullTime        `db:"received_at"`
+	ClosedAt       sql.NullTime        `db:"closed_at"`
+	CreatedAt      time.Time           `db:"created_at"`
+	UpdatedAt      time.Time           `db:"updated_at"`
+}
+
+// ReturnRequestItem is one order line sent back. The product/size/grade of the returned unit and the
+// frozen variant SKU are joined from order_item; the exchange pair is set for exchanges only.
+type ReturnRequestItem struct {
+	Id                 int            `db:"id"`
+	ReturnRequestId    int            `db:"return_request_id"`
+	OrderItemId        int            `db:"order_item_id"`
+	Quantity           int            `db:"quantity"`
+	ProductId          int            `db:"product_id"`
+	SizeId             int            `db:"size_id"`
+	Grade              string         `db:"grade"`
+	VariantSKU         string         `db:"variant_sku_snapshot"`
+	ExchangeProductId  sql.NullInt32  `db:"exchange_product_id"`
+	ExchangeSizeId     sql.NullInt32  `db:"exchange_size_id"`
+	ExchangeVariantSKU sql.NullString `db:"exchange_variant_sku"`
+}
+
+// ReturnRequestFull is a return with its lines.
+type ReturnRequestFull struct {
+	ReturnRequest
+	Items []ReturnRequestItem
+}
+
+// ReturnRequestInsert is a customer's return request for one order.
+type ReturnRequestInsert struct {
+	Resolution   ReturnResolution
+	ReasonCode   string
+	CustomerNote string
+	Items        []ReturnRequestItemInsert
+}
+
+// ReturnRequestItemInsert asks to send back Quantity units of one order line. For an exchange the
+// replacement (ExchangeProductId, ExchangeSizeId) is resolved by the caller from the public variant SKU.
+type ReturnRequestItemInsert struct {
+	OrderItemId       int
+	Quantity          int
+	ExchangeProductId int
+	ExchangeSizeId    int
+}
+
+// ReturnRequestFilters narrows the admin return queue.
+type ReturnRequestFilters struct {
+	Status     *ReturnRequestStatus
+	Resolution *ReturnResolution
+	OrderUUID  string
+	Email      string
+}
+
+// ValidateReturnRequestInsert checks the shape of a return request; quantities against the order are
+// checked by the store under the order lock.
+func ValidateReturnRequestInsert(r *ReturnRequestInsert) error {
+	if !ValidReturnResolutions[r.Resolution] {
+		return &ValidationError{Message: "resolution must be refund or exchange", Field: "resolution"}
+	}
+	if !ValidReturnReasonCodes[r.ReasonCode] {
+		return &ValidationError{Message: "unknown return reason", Field: "reason"}
+	}
+	if len(r.CustomerNote) > 2000 {
+		return &ValidationError{Message: "note must not exceed 2000 characters", Field: "note"}
+	}
+	if len(r.Items) == 0 {
+		return &ValidationError{Message: "at least one item is required", Field: "items"}
+	}
+	seen := make(map[int]bool, len(r.Items))
+	for _, it := range r.Items {
+		if it.OrderItemId <= 0 || it.Quantity <= 0 {
+			return &ValidationError{Message: "each item needs an order item and a positive quantity", Field: "items"}
+		}
+		if seen[it.OrderItemId] {
+			return &ValidationError{Message: "an order item may appear only once", Field: "items"}
+		}
+		seen[it.OrderItemId] = true
+		hasExchange := it.ExchangeSizeId != 0
+		if r.Resolution == ReturnResolutionExchange && !hasExchange {
+			return &ValidationError{Message: "an exchange needs a replacement size for every item", Field: "items"}
+		}
+		if r.Resolution == ReturnResolutionRefund && hasExchange {
+			return &ValidationError{Message: "a refund return cannot carry a replacement size", Field: "items"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanTransitionReturnRequest(t *testing.T) {
+	tests := []struct {
+		from, to ReturnRequestStatus
+		want     bool
+	}{
+		{ReturnStatusRequested, ReturnStatusApproved, true},
+		{ReturnStatusRequested, ReturnStatusCancelled, true},
+		{ReturnStatusRequested, ReturnStatusReceived, false},
+		{ReturnStatusApproved, ReturnStatusReceived, true},
+		{ReturnStatusApproved, ReturnStatusRefunding, false},
+		{ReturnStatusReceived, ReturnStatusRefunding, true},
+		{ReturnStatusReceived, ReturnStatusExchanged, true},
+		{ReturnStatusReceived, ReturnStatusCancelled, false},
+		{ReturnStatusRefunding, ReturnStatusRefunding, true},
+		{ReturnStatusRefunding, ReturnStatusRefunded, true},
+		{ReturnStatusRefunding, ReturnStatusRejected, false},
+		{ReturnStatusRefunded, ReturnStatusRefunding, false},
+		{ReturnStatusExchanged, ReturnStatusRejected, false},
+		{ReturnStatusCancelled, ReturnStatusApproved, false},
+		{ReturnStatusRejected, ReturnStatusApproved, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransitionReturnRequest(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransitionReturnRequest(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidateReturnRequestInsert(t *testing.T) {
+	refund := func(items ...ReturnRequestItemInsert) *ReturnRequestInsert {
+		return &ReturnRequestInsert{Resolution: ReturnResolutionRefund, ReasonCode: "wrong_size", Items: items}
+	}
+	exchange := func(items ...ReturnRequestItemInsert) *ReturnRequestInsert {
+		return &ReturnRequestInsert{Resolution: ReturnResolutionExchange, ReasonCode: "wrong_size", Items: items}
+	}
+
+	tests := []struct {
+		name      string
+		in        *ReturnRequestInsert
+		wantField string
+	}{
+		{"refund ok", refund(ReturnRequestItemInsert{OrderItemId: 1, Quantity: 1}), ""},
+		{"exchange ok", exchange(ReturnRequestItemInsert{OrderItemId: 1, Quantity: 2, ExchangeProductId: 5, ExchangeSizeId: 3}), ""},
+		{"unknown resolution", &ReturnRequestInsert{Resolution: "credit", ReasonCode: "other", Items: []ReturnRequestItemInsert{{OrderItemId: 1, Quantity: 1}}}, "resolution"},
+		{"unknown reason", &ReturnRequestInsert{Resolution: ReturnResolutionRefund, ReasonCode: "bored", Items: []ReturnRequestItemInsert{{OrderItemId: 1, Quantity: 1}}}, "reason"},
+		{"long note", &ReturnRequestInsert{Resolution: ReturnResolutionRefund, ReasonCode: "other", CustomerNote: strings.Repeat("x", 2001), Items: []ReturnRequestItemInsert{{OrderItemId: 1, Quantity: 1}}}, "note"},
+		{"no items", refund(), "items"},
+		{"zero quantity", refund(ReturnRequestItemInsert{OrderItemId: 1}), "items"},
+		{"duplicate line", refund(ReturnRequestItemInsert{OrderItemId: 1, Quantity: 1}, ReturnRequestItemInsert{OrderItemId: 1, Quantity: 1}), "items"},
+		{"exchange without size", exchange(ReturnRequestItemInsert{OrderItemId: 1, Quantity: 1}), "items"},
+		{"refund with size", refund(ReturnRequestItemInsert{OrderItemId: 1, Quantity: 1, ExchangeProductId: 5, ExchangeSizeId: 3}), "items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReturnRequestInsert(tt.in)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("want ValidationError, got %T: %v", err, err)
+			}
+			if ve.Field != tt.wantField {
+				t.Errorf("field = %q, want %q", ve.Field, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Sales invoices (0337). Until now the only tax documents were the JPK_V7M register rows built from
+// the ledger (a refund was marked "<uuid>-KOREKTA-yyyymmdd" because there was no credit-note
+// register). A sales invoice is the document itself: a gapless number per series and month, the
+// buyer, the lines and the VAT, frozen at issue. A refund never edits an invoice — it is corrected by
+// a correction invoice (faktura korygująca) with its own number in the FK series, carrying the
+// NEGATIVE difference. Both are rendered as KSeF FA(2)/FA(3) structured invoices by internal/ksef.
+
+// Sales invoice errors.
+var (
+	// ErrSalesInvoiceNotFound is returned for an unknown invoice id, or an order without an invoice.
+	ErrSalesInvoiceNotFound = errors.New("sales invoice not found")
+	// ErrSalesInvoiceExists is returned when the order already has its sales invoice.
+	ErrSalesInvoiceExists = errors.New("order already has a sales invoice")
+	// ErrSalesInvoiceState is returned when the order or the invoice does not allow the operation —
+	// an unpaid order, an unresolved VAT regime, a backdated issue date, an invoice already sent to KSeF.
+	ErrSalesInvoiceState = errors.New("sales invoice can not be issued")
+	// ErrSalesInvoiceNothingToCorrect is returned when every refund on the order is already covered by
+	// a correction invoice.
+	ErrSalesInvoiceNothingToCorrect = errors.New("no refund left to correct")
+	// ErrSalesInvoiceFxMissing is returned when a foreign-currency invoice has no PLN reference rate
+	// for the day before its sale date (art. 31a).
+	ErrSalesInvoiceFxMissing = errors.New("no PLN reference rate for the invoice")
+)
+
+// SalesInvoiceKind distinguishes an invoice from a correction of one.
+type SalesInvoiceKind string
+
+const (
+	// SalesInvoiceKindInvoice is the VAT invoice for an order (FA RodzajFaktury "VAT").
+	SalesInvoiceKindInvoice SalesInvoiceKind = "invoice"
+	// SalesInvoiceKindCorrection corrects an invoice after a refund (FA RodzajFaktury "KOR").
+	SalesInvoiceKindCorrection SalesInvoiceKind = "correction"
+)
+
+// ValidSalesInvoiceKinds mirrors the sales_invoice.kind ENUM.
+var ValidSalesInvoiceKinds = map[SalesInvoiceKind]bool{
+	SalesInvoiceKindInvoice:    true,
+	SalesInvoiceKindCorrection: true,
+}
+
+// Series returns the numbering series of the kind: FV for invoices, FK for corrections. Each series
+// counts from 1 every month.
+func (k SalesInvoiceKind) Series() string {
+	if k == SalesInvoiceKindCorrection {
+		return "FK"
+	}
+	return "FV"
+}
+
+// SalesInvoicePeriod is the numbering period (YYYY-MM) of an issue date.
+func SalesInvoicePeriod(issued time.Time) string {
+	return issued.Format("2006-01")
+}
+
+// SalesInvoiceNumber formats the invoice number: series/year/month/sequence, e.g. FV/2026/07/00012.
+func SalesInvoiceNumber(series string, issued time.Time, seq int) string {
+	return fmt.Sprintf("%s/%04d/%02d/%05d", series, issued.Year(), int(issued.Month()), seq)
+}
+
+// SalesInvoiceLineKind tells what an invoice line bills.
+type SalesInvoiceLineKind string
+
+const (
+	// SalesInvoiceLineItem is an order item; OrderItemId names it.
+	SalesInvoiceLineItem SalesInvoiceLineKind = "item"
+	// SalesInvoiceLineShipping is the shipping charged to the buyer.
+	SalesInvoiceLineShipping SalesInvoiceLineKind = "shipping"
+	// SalesInvoiceLineAdjustment is the part of a refund not explained by returned items or shipping
+	// (a goodwill or price refund); it only appears on corrections.
+	SalesInvoiceLineAdjustment SalesInvoiceLineKind = "adjustment"
+)
+
+// SalesInvoiceLine is one line of an invoice. Amounts are in the invoice currency and NEGATIVE on a
+// correction. The store computes from gross (art. 106e ust. 7): Gross is what the buyer paid for the
+// line, Vat its share of the invoice's VAT and Net the difference.
+type SalesInvoiceLine struct {
+	Id          int                  `db:"id"`
+	InvoiceId   int                  `db:"invoice_id"`
+	Position    int                  `db:"position"`
+	Kind        SalesInvoiceLineKind `db:"kind"`
+	OrderItemId sql.NullInt32        `db:"order_item_id"`
+	Name        string               `db:"name"`
+	Quantity    decimal.Decimal      `db:"quantity"`
+	UnitGross   decimal.Decimal      `db:"unit_gross"`
+	Net         decimal.Decimal      `db:"net"`
+	Vat         decimal.Decimal      `db:"vat"`
+	Gross       decimal.Decimal      `db:"gross"`
+}
+
+// SalesInvoice is the sales_invoice row. The buyer, the regime and the rate are snapshots taken at
+// issue; a correction copies them from the invoice it corrects. PlnRate is the PLN value of one unit
+// of Currency on the day before the sale date (NULL on PLN invoices) — FA needs the VAT in PLN too.
+// The Corrects* fields are joined from the corrected invoice and empty on an invoice.
+type SalesInvoice struct {
+	Id                int                 `db:"id"`
+	Number            string              `db:"number"`
+	Kind              SalesInvoiceKind    `db:"kind"`
+	Series            string              `db:"series"`
+	Period            string              `db:"period"`
+	Seq               int                 `db:"seq"`
+	OrderId           int                 `db:"order_id"`
+	OrderUUID         string              `db:"order_uuid"`
+	CorrectsId        sql.NullInt32       `db:"corrects_id"`
+	CorrectsNumber    sql.NullString      `db:"corrects_number"`
+	CorrectsIssueDate sql.NullTime        `db:"corrects_issue_date"`
+	CorrectsKsefRef   sql.NullString      `db:"corrects_ksef_reference"`
+	IssueDate         time.Time           `db:"issue_date"`
+	SaleDate          time.Time           `db:"sale_date"`
+	Currency          string              `db:"currency"`
+	PlnRate           decimal.NullDecimal `db:"pln_rate"`
+	VatRegime         VatRegime           `db:"vat_regime"`
+	VatRatePct        decimal.Decimal     `db:"vat_rate_pct"`
+	BuyerName         string              `db:"buyer_name"`
+	BuyerVatId        sql.NullString      `db:"buyer_vat_id"`
+	BuyerAddress      string              `db:"buyer_address"`
+	BuyerCountry      string              `db:"buyer_country"`
+	Net               decimal.Decimal     `db:"net"`
+	Vat               decimal.Decimal     `db:"vat"`
+	Gross             decimal.Decimal     `db:"gross"`
+	Reason            sql.NullString      `db:"reason"`
+	KsefReference     sql.NullString      `db:"ksef_reference"`
+	KsefHash          sql.NullString      `db:"ksef_hash"`
+	KsefSubmittedAt   sql.NullTime        `db:"ksef_submitted_at"`
+	CreatedBy         string              `db:"created_by"`
+	CreatedAt         time.Time           `db:"created_at"`
+}
+
+// SalesInvoiceFull is an invoice with its lines.
+type SalesInvoiceFull struct {
+	SalesInvoice
+	Lines []SalesInvoiceLine
+}
+
+// SalesInvoiceInsert is a built invoice waiting for its number.
+type SalesInvoiceInsert struct {
+	Kind         SalesInvoiceKind
+	OrderId      int
+	CorrectsId   sql.NullInt32
+	IssueDate    time.Time
+	SaleDate     time.Time
+	Currency     string
+	PlnRate      decimal.NullDecimal
+	VatRegime    VatRegime
+	VatRatePct   decimal.Decimal
+	BuyerName    string
+	BuyerVatId   sql.NullString
+	BuyerAddress string
+	BuyerCountry string
+	Reason       sql.NullString
+	Lines        []SalesInvoiceLine
+	CreatedBy    string
+}
+
+// Totals adds up the lines.
+func (ins *SalesInvoiceInsert) Totals() (net, vat, gross decimal.Decimal) {
+	for _, l := range ins.Lines {
+		net, vat, gross = net.Add(l.Net), vat.Add(l.Vat), gross.Add(l.Gross)
+	}
+	return net, vat, gross
+}
+
+// SalesInvoiceFilter narrows ListSalesInvoices. Zero values do not filter.
+type SalesInvoiceFilter struct {
+	Period    string // YYYY-MM of the issue date
+	OrderUUID string
+	Kind      SalesInvoiceKind
+}
+
+// SalesInvoiceItemSource is one order item as the invoice bills it.
+type SalesInvoiceItemSource struct {
+	OrderItemId int
+	Name        string
+	Quantity    decimal.Decimal
+	UnitGross   decimal.Decimal // price with sale, before the order-level promo
+}
+
+// SalesInvoiceSource is what an invoice is built from: the order's billable items, the shipping
+// charged, and the gross the buyer actually owes for them (total less gift cards). The difference
+// between the listed prices and that gross is the order-level promo.
+type SalesInvoiceSource struct {
+	Items    []SalesInvoiceItemSource
+	Shipping decimal.Decimal
+	Gross    decimal.Decimal
+}
+
+// InvoiceVatRate returns the rate an invoice under the regime charges. Domestic and OSS sales charge
+// the rate snapshotted on the order; WDT and export are invoiced at zero. A UK stock sale is not a
+// Polish supply, and a regime that is not resolved yet (the sale is not posted, or resolved to the
+// none placeholder) can not be invoiced at all.
+func InvoiceVatRate(regime VatRegime, orderRate decimal.NullDecimal) (decimal.Decimal, error) {
+	switch regime {
+	case VatRegimePLDomestic, VatRegimeOSS:
+		if !orderRate.Valid || !orderRate.Decimal.IsPositive() {
+			return decimal.Zero, fmt.Errorf("%w: the order has no VAT rate for regime %s", ErrSalesInvoiceState, regime)
+		}
+		return orderRate.Decimal, nil
+	case VatRegimeWDT, VatRegimeExport:
+		return decimal.Zero, nil
+	case "", VatRegimeNone:
+		return decimal.Zero, fmt.Errorf("%w: the order's VAT regime is not resolved yet (the sale is not posted)", ErrSalesInvoiceState)
+	default:
+		return decimal.Zero, fmt.Errorf("%w: regime %s is not invoiced in Poland", ErrSalesInvoiceState, regime)
+	}
+}
+
+// BuildSalesInvoiceLines turns an order into invoice lines: one per item, one for shipping. The
+// order-level promo is spread over the item lines pro rata to their value, so each line shows what
+// was really paid for it and the lines add up to the source gross to the cent.
+func BuildSalesInvoiceLines(src SalesInvoiceSource, rate decimal.Decimal) ([]SalesInvoiceLine, error) {
+	if !src.Gross.IsPositive() {
+		return nil, fmt.Errorf("%w: nothing to invoice", ErrSalesInvoiceState)
+	}
+	lines := make([]SalesInvoiceLine, 0, len(src.Items)+1)
+	listed := decimal.Zero
+	itemGross := make([]decimal.Decimal, 0, len(src.Items))
+	for _, it := range src.Items {
+		g := it.UnitGross.Mul(it.Quantity).Round(2)
+		itemGross = append(itemGross, g)
+		listed = listed.Add(g)
+		lines = append(lines, SalesInvoiceLine{
+			Kind:        SalesInvoiceLineItem,
+			OrderItemId: sql.NullInt32{Int32: int32(it.OrderItemId), Valid: true},
+			Name:        it.Name,
+			Quantity:    it.Quantity,
+			Gross:       g,
+		})
+	}
+	shipping := src.Shipping.Round(2)
+	if shipping.IsPositive() {
+		listed = listed.Add(shipping)
+	}
+	if discount := src.Gross.Sub(listed); !discount.IsZero() {
+		if len(itemGross) == 0 {
+			return nil, fmt.Errorf("%w: the order total %s does not cover its lines (%s)", ErrSalesInvoiceState, src.Gross, listed)
+		}
+		for i, part := range SplitInputVat(discount, itemGross) {
+			lines[i].Gross = lines[i].Gross.Add(part)
+		}
+	}
+	if shipping.IsPositive() {
+		lines = append(lines, SalesInvoiceLine{
+			Kind:     SalesInvoiceLineShipping,
+			Name:     "Shipping",
+			Quantity: decimal.NewFromInt(1),
+			Gross:    shipping,
+		})
+	}
+	for i := range lines {
+		if lines[i].Gross.IsNegative() {
+			return nil, fmt.Errorf("%w: the promo exceeds the value of %q", ErrSalesInvoiceState, lines[i].Name)
+		}
+		lines[i].Position = i + 1
+		lines[i].UnitGross = lines[i].Gross.Div(lines[i].Quantity).Round(2)
+	}
+	splitVatFromGross(lines, rate)
+	return lines, nil
+}
+
+// SalesCorrectionSource is the refund state of an invoiced order: the item quantities refunded so far,
+// whether shipping was refunded, and the money refunded in all (customer_order.refunded_amount).
+type SalesCorrectionSource struct {
+	RefundedQty      map[int]decimal.Decimal // by order item id
+	ShippingRefunded bool
+	RefundedGross    decimal.Decimal
+}
+
+// BuildSalesCorrectionLines builds the next correction of an invoice from the refunds its earlier
+// corrections do not cover yet. Returned items and shipping are reversed at the value they were
+// invoiced at; whatever the refunded money does not explain that way (a price or goodwill refund, a
+// refund of a discounted line at list price) goes on one adjustment line, so the correction always
+// reduces the invoice by exactly the money returned. Lines come back negative.
+func BuildSalesCorrectionLines(inv SalesInvoiceFull, prior []SalesInvoiceFull, src SalesCorrectionSource) ([]SalesInvoiceLine, error) {
+	corrected := decimal.Zero
+	correctedQty := map[int]decimal.Decimal{}
+	shippingCorrected := false
+	for _, c := range prior {
+		corrected = corrected.Sub(c.Gross)
+		for _, l := range c.Lines {
+			switch l.Kind {
+			case SalesInvoiceLineItem:
+				id := int(l.OrderItemId.Int32)
+				correctedQty[id] = correctedQty[id].Sub(l.Quantity)
+			case SalesInvoiceLineShipping:
+				shippingCorrected = true
+			}
+		}
+	}
+	refunded := src.RefundedGross.Round(2)
+	if refunded.GreaterThan(inv.Gross) {
+		return nil, fmt.Errorf("%w: refunds (%s) exceed invoice %s (%s)", ErrSalesInvoiceState, refunded, inv.Number, inv.Gross)
+	}
+	delta := refunded.Sub(corrected)
+	if !delta.IsPositive() {
+		return nil, ErrSalesInvoiceNothingToCorrect
+	}
+
+	var lines []SalesInvoiceLine
+	reversed := decimal.Zero
+	for _, l := range inv.Lines {
+		switch l.Kind {
+		case SalesInvoiceLineItem:
+			id := int(l.OrderItemId.Int32)
+			qty := decimal.Min(src.RefundedQty[id], l.Quantity).Sub(correctedQty[id])
+			if !qty.IsPositive() {
+				continue
+			}
+			g := l.Gross.Mul(qty).Div(l.Quantity).Round(2)
+			reversed = reversed.Add(g)
+			lines = append(lines, SalesInvoiceLine{
+				Kind:        SalesInvoiceLineItem,
+				OrderItemId: l.OrderItemId,
+				Name:        l.Name,
+				Quantity:    qty.Neg(),
+				UnitGross:   l.UnitGross,
+				Gross:       g.Neg(),
+			})
+		case SalesInvoiceLineShipping:
+			if !src.ShippingRefunded || shippingCorrected {
+				continue
+			}
+			reversed = reversed.Add(l.Gross)
+			lines = append(lines, SalesInvoiceLine{
+				Kind:      SalesInvoiceLineShipping,
+				Name:      l.Name,
+				Quantity:  l.Quantity.Neg(),
+				UnitGross: l.UnitGross,
+				Gross:     l.Gross.Neg(),
+			})
+		}
+	}
+	if rest := delta.Sub(reversed); !rest.IsZero() {
+		lines = append(lines, SalesInvoiceLine{
+			Kind:      SalesInvoiceLineAdjustment,
+			Name:      "Price adjustment",
+			Quantity:  decimal.NewFromInt(1),
+			UnitGross: rest.Neg(),
+			Gross:     rest.Neg(),
+		})
+	}
+	for i := range lines {
+		lines[i].Position = i + 1
+	}
+	splitVatFromGross(lines, inv.VatRatePct)
+	return lines, nil
+}
+
+// splitVatFromGross fills Vat and Net on lines whose Gross is set. The VAT is computed once on the
+// total gross and shared over the lines, with the rounding remainder on the largest line, so the
+// document's VAT is the rate applied to its total rather than a sum of rounded parts.
+func splitVatFromGross(lines []SalesInvoiceLine, rate decimal.Decimal) {
+	if len(lines) == 0 {
+		return
+	}
+	div := decimal.NewFromInt(100).Add(rate)
+	total, sum, largest := decimal.Zero, decimal.Zero, 0
+	for i, l := range lines {
+		total = total.Add(l.Gross)
+		if l.Gross.Abs().GreaterThan(lines[largest].Gross.Abs()) {
+			largest = i
+		}
+	}
+	total = total.Mul(rate).Div(div).Round(2)
+	for i := range lines {
+		lines[i].Vat = lines[i].Gross.Mul(rate).Div(div).Round(2)
+		sum = sum.Add(lines[i].Vat)
+	}
+	lines[largest].Vat = lines[largest].Vat.Add(total.Sub(sum))
+	for i := range lines {
+		lines[i].Net = lines[i].Gross.Sub(lines[i].Vat)
+	}
+}
@@ -0,0 +1,180 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSalesInvoiceNumber(t *testing.T) {
+	issued := time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)
+	if got := SalesInvoiceNumber(SalesInvoiceKindInvoice.Series(), issued, 12); got != "FV/2026/07/00012" {
+		t.Errorf("invoice number = %q", got)
+	}
+	if got := SalesInvoiceNumber(SalesInvoiceKindCorrection.Series(), issued, 1); got != "FK/2026/07/00001" {
+		t.Errorf("correction number = %q", got)
+	}
+	if got := SalesInvoicePeriod(issued); got != "2026-07" {
+		t.Errorf("period = %q", got)
+	}
+}
+
+func TestInvoiceVatRate(t *testing.T) {
+	rate := decimal.NullDecimal{Decimal: d("23"), Valid: true}
+	if r, err := InvoiceVatRate(VatRegimePLDomestic, rate); err != nil || !r.Equal(d("23")) {
+		t.Errorf("pl_domestic = %s, %v", r, err)
+	}
+	if r, err := InvoiceVatRate(VatRegimeWDT, rate); err != nil || !r.IsZero() {
+		t.Errorf("wdt = %s, %v", r, err)
+	}
+	for _, regime := range []VatRegime{"", VatRegimeNone, VatRegimeUKStockDomestic} {
+		if _, err := InvoiceVatRate(regime, rate); !errors.Is(err, ErrSalesInvoiceState) {
+			t.Errorf("%q: got %v, want ErrSalesInvoiceState", regime, err)
+		}
+	}
+	if _, err := InvoiceVatRate(VatRegimeOSS, decimal.NullDecimal{}); !errors.Is(err, ErrSalesInvoiceState) {
+		t.Errorf("oss without a rate: got %v", err)
+	}
+}
+
+func sumLines(lines []SalesInvoiceLine) (net, vat, gross decimal.Decimal) {
+	ins := SalesInvoiceInsert{Lines: lines}
+	return ins.Totals()
+}
+
+func TestBuildSalesInvoiceLines(t *testing.T) {
+	// 2 × 100 + 1 × 50 listed, 15.00 shipping, a 10% promo on the items: 225 + 15 = 240 owed.
+	src := SalesInvoiceSource{
+		Items: []SalesInvoiceItemSource{
+			{OrderItemId: 1, Name: "coat", Quantity: d("2"), UnitGross: d("100")},
+			{OrderItemId: 2, Name: "scarf", Quantity: d("1"), UnitGross: d("50")},
+		},
+		Shipping: d("15"),
+		Gross:    d("240"),
+	}
+	lines, err := BuildSalesInvoiceLines(src, d("23"))
+	if err != nil {
+		t.Fatalf("BuildSalesInvoiceLines: %v", err)
+	}
+	if len(lines) != 3 || lines[2].Kind != SalesInvoiceLineShipping || lines[2].Position != 3 {
+		t.Fatalf("lines = %+v, want two items and shipping last", lines)
+	}
+	if !lines[0].Gross.Equal(d("180")) || !lines[0].UnitGross.Equal(d("90")) || !lines[1].Gross.Equal(d("45")) {
+		t.Errorf("promo not spread pro rata: %s (%s each), %s", lines[0].Gross, lines[0].UnitGross, lines[1].Gross)
+	}
+	net, vat, gross := sumLines(lines)
+	// VAT on the total: 240 × 23/123 = 44.878… → 44.88.
+	if !gross.Equal(d("240")) || !vat.Equal(d("44.88")) || !net.Equal(d("195.12")) {
+		t.Errorf("totals = %s / %s / %s, want 195.12 / 44.88 / 240", net, vat, gross)
+	}
+	for _, l := range lines {
+		if !l.Net.Add(l.Vat).Equal(l.Gross) {
+			t.Errorf("line %d: net %s + vat %s != gross %s", l.Position, l.Net, l.Vat, l.Gross)
+		}
+	}
+
+	zero, err := BuildSalesInvoiceLines(src, decimal.Zero)
+	if err != nil {
+		t.Fatalf("zero rate: %v", err)
+	}
+	if _, vat, _ := sumLines(zero); !vat.IsZero() {
+		t.Errorf("zero-rated invoice carries VAT %s", vat)
+	}
+
+	src.Gross = d("10")
+	if _, err := BuildSalesInvoiceLines(src, d("23")); !errors.Is(err, ErrSalesInvoiceState) {
+		t.Errorf("promo above the item value: got %v, want ErrSalesInvoiceState", err)
+	}
+	src.Gross = decimal.Zero
+	if _, err := BuildSalesInvoiceLines(src, d("23")); !errors.Is(err, ErrSalesInvoiceState) {
+		t.Errorf("nothing owed: got %v, want ErrSalesInvoiceState", err)
+	}
+}
+
+func TestBuildSalesCorrectionLines(t *testing.T) {
+	lines, err := BuildSalesInvoiceLines(SalesInvoiceSource{
+		Items: []SalesInvoiceItemSource{
+			{OrderItemId: 1, Name: "coat", Quantity: d("2"), UnitGross: d("100")},
+			{OrderItemId: 2, Name: "scarf", Quantity: d("1"), UnitGross: d("50")},
+		},
+		Shipping: d("15"),
+		Gross:    d("265"),
+	}, d("23"))
+	if err != nil {
+		t.Fatalf("BuildSalesInvoiceLines: %v", err)
+	}
+	inv := SalesInvoiceFull{SalesInvoice: SalesInvoice{Number: "FV/2026/07/00001", VatRatePct: d("23"), Gross: d("265")}, Lines: lines}
+
+	// One coat back with shipping, refunded in full.
+	first, err := BuildSalesCorrectionLines(inv, nil, SalesCorrectionSource{
+		RefundedQty:      map[int]decimal.Decimal{1: d("1")},
+		ShippingRefunded: true,
+		RefundedGross:    d("115"),
+	})
+	if err != nil {
+		t.Fatalf("first correction: %v", err)
+	}
+	if len(first) != 2 || first[0].Kind != SalesInvoiceLineItem || !first[0].Quantity.Equal(d("-1")) || first[1].Kind != SalesInvoiceLineShipping {
+		t.Fatalf("first correction lines = %+v", first)
+	}
+	net, vat, gross := sumLines(first)
+	if !gross.Equal(d("-115")) || !vat.Equal(d("-21.50")) || !net.Equal(d("-93.50")) {
+		t.Errorf("first correction totals = %s / %s / %s", net, vat, gross)
+	}
+
+	// The scarf comes back but only 40 of its 50 is refunded: the missing 10 is an adjustment.
+	prior := []SalesInvoiceFull{{SalesInvoice: SalesInvoice{Gross: gross}, Lines: first}}
+	second, err := BuildSalesCorrectionLines(inv, prior, SalesCorrectionSource{
+		RefundedQty:      map[int]decimal.Decimal{1: d("1"), 2: d("1")},
+		ShippingRefunded: true,
+		RefundedGross:    d("155"),
+	})
+	if err != nil {
+		t.Fatalf("second correction: %v", err)
+	}
+	if len(second) != 2 || second[0].OrderItemId.Int32 != 2 || second[1].Kind != SalesInvoiceLineAdjustment {
+		t.Fatalf("second correction lines = %+v", second)
+	}
+	if _, _, g := sumLines(second); !g.Equal(d("-40")) || !second[1].Gross.Equal(d("10")) {
+		t.Errorf("second correction gross = %s, adjustment %s; want -40 and +10", g, second[1].Gross)
+	}
+
+	// Nothing new refunded.
+	prior = append(prior, SalesInvoiceFull{SalesInvoice: SalesInvoice{Gross: d("-40")}, Lines: second})
+	_, err = BuildSalesCorrectionLines(inv, prior, SalesCorrectionSource{
+		RefundedQty:      map[int]decimal.Decimal{1: d("1"), 2: d("1")},
+		ShippingRefunded: true,
+		RefundedGross:    d("155"),
+	})
+	if !errors.Is(err, ErrSalesInvoiceNothingToCorrect) {
+		t.Errorf("nothing new: got %v, want ErrSalesInvoiceNothingToCorrect", err)
+	}
+
+	// More refunded than was ever invoiced.
+	_, err = BuildSalesCorrectionLines(inv, nil, SalesCorrectionSource{RefundedGross: d("300")})
+	if !errors.Is(err, ErrSalesInvoiceState) {
+		t.Errorf("over-refund: got %v, want ErrSalesInvoiceState", err)
+	}
+}
+
+func TestBuildSalesCorrectionIgnoresUnknownLines(t *testing.T) {
+	inv := SalesInvoiceFull{
+		SalesInvoice: SalesInvoice{VatRatePct: decimal.Zero, Gross: d("20")},
+		Lines: []SalesInvoiceLine{{Kind: SalesInvoiceLineItem, OrderItemId: sql.NullInt32{Int32: 5, Valid: true},
+			Quantity: d("1"), UnitGross: d("20"), Gross: d("20"), Net: d("20")}},
+	}
+	// A refund on an item the invoice never billed (a gift card) is only money: one adjustment.
+	lines, err := BuildSalesCorrectionLines(inv, nil, SalesCorrectionSource{
+		RefundedQty:   map[int]decimal.Decimal{9: d("1")},
+		RefundedGross: d("5"),
+	})
+	if err != nil {
+		t.Fatalf("correction: %v", err)
+	}
+	if len(lines) != 1 || lines[0].Kind != SalesInvoiceLineAdjustment || !lines[0].Gross.Equal(d("-5")) || !lines[0].Vat.IsZero() {
+		t.Errorf("lines = %+v, want one -5 adjustment without VAT", lines)
+	}
+}
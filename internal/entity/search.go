@@ -0,0 +1,148 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ПОИСК ВИТРИНЫ — the storefront full-text search. The store hands the in-process index (package
+// search) one SearchDocument per ACTIVE colourway; the index answers SearchQuery/SuggestQuery without
+// going back to the database, and the handler loads the hit colourways by id.
+
+// SearchDocument is one ACTIVE colourway as the search index sees it: its searchable text (names and
+// descriptions in every storefront language, tags, collection, categories, composition, style number,
+// SKU, colour) and the facet values the storefront filters on.
+type SearchDocument struct {
+	ProductId   int    `db:"id"`
+	SKU         string `db:"sku"`
+	StyleNumber string `db:"style_number"`
+	Brand       string `db:"brand"`
+	Color       string `db:"color"`
+	ColorCode   string `db:"color_code"`
+	Collection  string `db:"collection"`
+	// Composition is the legacy plain-text composition; Fibers the structured composition's fibre
+	// names, space separated.
+	Composition string `db:"composition"`
+	Fibers      string `db:"fibers"`
+
+	TargetGender   GenderEnum          `db:"target_gender"`
+	Season         SeasonEnum          `db:"season_code"`
+	TopCategoryId  int                 `db:"top_category_id"`
+	SubCategoryId  int                 `db:"sub_category_id"`
+	TypeId         int                 `db:"type_id"`
+	SalePercentage decimal.NullDecimal `db:"sale_percentage"`
+	Preorder       bool                `db:"preorder"`
+
+	MinTier               int16     `db:"min_tier"`
+	HiddenForNonQualified bool      `db:"hidden_for_non_qualified"`
+	CreatedAt             time.Time `db:"created_at"`
+
+	// Loaded by follow-up queries.
+	Translations []SearchTranslation
+	// CategoryNames holds the top/sub/type category names in every language.
+	CategoryNames []string
+	Tags          []string
+	// SizeIds are the grade-A sizes the colourway carries (the paged listing's size filter).
+	SizeIds []int
+	// Prices maps currency to the list price, before the sale percentage.
+	Prices map[string]decimal.Decimal
+}
+
+// SearchTranslation is a colourway's name and description in one language.
+type SearchTranslation struct {
+	ProductId   int    `db:"product_id"`
+	LanguageId  int    `db:"language_id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+}
+
+// OnSale reports whether the colourway is marked down.
+func (d *SearchDocument) OnSale() bool {
+	return d.SalePercentage.Valid && d.SalePercentage.Decimal.IsPositive()
+}
+
+// SalePrice is the storefront price in currency (list price less the sale percentage) — the same
+// expression the paged listing's price filter uses.
+func (d *SearchDocument) SalePrice(currency string) (decimal.Decimal, bool) {
+	p, ok := d.Prices[currency]
+	if !ok {
+		return decimal.Zero, false
+	}
+	if !d.OnSale() {
+		return p, true
+	}
+	return p.Mul(decimal.NewFromInt(1).Sub(d.SalePercentage.Decimal.Div(decimal.NewFromInt(100)))), true
+}
+
+// SearchQuery is a storefront search: free text plus the paged listing's filter conditions.
+// Filters.ViewerTier is server-set, as on GetColorwaysPaged.
+type SearchQuery struct {
+	Text    string
+	Filters FilterConditions
+	Limit   int
+	Offset  int
+}
+
+// SearchHit is one ranked colourway.
+type SearchHit struct {
+	ProductId int
+	Score     float64
+}
+
+// SearchFacet is a facet value and how many results carry it.
+type SearchFacet struct {
+	Value string
+	Count int
+}
+
+// SearchFacets are the facet counts of a search. Each facet is counted with every filter applied
+// except its own, so picking a second colour does not zero the other colours.
+type SearchFacets struct {
+	Gender      []SearchFacet
+	TopCategory []SearchFacet
+	Collection  []SearchFacet
+	Color       []SearchFacet
+	Season      []SearchFacet
+	Size        []SearchFacet
+	OnSale      int
+}
+
+// SearchResult is a page of ranked hits.
+type SearchResult struct {
+	Hits  []SearchHit
+	Total int
+	// Corrections maps a query word the catalogue does not contain to the word it was read as.
+	Corrections map[string]string
+	Facets      SearchFacets
+	// IndexedAt is when the index answering the query was built.
+	IndexedAt time.Time
+}
+
+// SearchSuggestionKind says what a suggestion completes to.
+type SearchSuggestionKind string
+
+const (
+	SearchSuggestionQuery   SearchSuggestionKind = "query"
+	SearchSuggestionProduct SearchSuggestionKind = "product"
+)
+
+// SuggestQuery is an autocomplete request for a partly typed query.
+type SuggestQuery struct {
+	Text string
+	// LanguageId picks the language of product suggestion names; without a translation in it the
+	// canonical (default-language) name is used, chosen from Languages.
+	LanguageId int
+	Languages  []Language
+	ViewerTier int16
+	Limit      int
+}
+
+// SearchSuggestion is one autocomplete entry: a completed query, or a colourway whose name matches.
+type SearchSuggestion struct {
+	Kind SearchSuggestionKind
+	Text string
+	// Product suggestions only.
+	ProductId int
+	SKU       string
+}
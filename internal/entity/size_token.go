@@ -8,11 +8,11 @@ import (
 // РАЗМЕРНЫЕ ТОКЕНЫ ИМЕНИ СЛОВАРНОГО РАЗМЕРА (Ф6.3) — a PORT, and a deliberately tiny one.
 //
 // The heuristic that decides which token of a DXF block name IS a size (deriveBlockSizes in the
-// admin's block-code.ts) is NOT ported and never will be. It is non-compositional — its verdict
-// depends on the whole set of names at once, through a «at least two size tails on one name stem»
-// rule and a frequency floor of max(2, max/2) — so a second implementation would not merely be
-// duplicated code, it would give DIFFERENT ANSWERS than the button the operator pressed, and it
-// would diverge silently.
+// admin's block-code.ts) is NOT ported here. It is non-compositional — its verdict depends on the
+// whole set of names at once, through a «at least two size tails on one name stem» rule and a
+// frequency floor of max(2, max/2) — so a copy would drift silently. The server's own reading
+// (dxfpattern.GradeSizes) is a separate reader with the same rule, and where the two disagree it
+// says so as drift (MeasureTechCardPatternScope) rather than pretending to be the client's answer.
 //
 // What IS ported is the other half: turning a DICTIONARY SIZE NAME into the tokens a file might
 // spell it with. That one is NORMALISATION, not heuristics: it has no thresholds, no cross-item
@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PDF-СПЕЦИФИКАЦИЯ РЕЛИЗА — файл для фабрики по снапшоту tech_card_release (internal/specsheet).
+// Здесь живёт только строка доступа к его публичной ссылке; сам файл собирается из снапшота каждый
+// раз заново и нигде не хранится.
+
+// TechCardReleaseSpecAccess — строка доступа к ссылке на спецификацию (tech_card_release_spec_access,
+// 0347). Механика та же, что у наряда и портала фабрики: токен несёт epoch, на котором выпущен, и
+// epoch + 1 убивает все разосланные ссылки разом. Идентичность — id РЕЛИЗА (скоуп 't').
+type TechCardReleaseSpecAccess struct {
+	ReleaseId int          `db:"release_id"`
+	Epoch     int          `db:"epoch"`
+	ExpiresAt sql.NullTime `db:"expires_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+	CreatedBy string       `db:"created_by"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+// ReleaseSpecAccessUpdate заводит, перевыпускает или отзывает ссылку на спецификацию. Rotate
+// поднимает epoch и снимает отзыв; Revoke убивает ссылку до следующего Rotate. ExpiresAt заменяет
+// срок (NULL — бессрочно).
+type ReleaseSpecAccessUpdate struct {
+	Rotate    bool
+	Revoke    bool
+	ExpiresAt sql.NullTime
+	Username  string
+}
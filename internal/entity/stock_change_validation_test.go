@@ -34,6 +34,12 @@ func TestValidateStockChangeAcceptsEveryCombinationTheCodebaseWrites(t *testing.
 		{"return restores", StockChangeSourceOrderReturned, string(StockChangeReasonReturnToStock), "1"},
 		{"cancel restores", StockChangeSourceOrderCancelled, string(StockChangeReasonOrderCancelled), "1"},
 		{"production receipt has no reason", StockChangeSourceProductionReceived, "", "50"},
+		{"exchange allocates replacement", StockChangeSourceOrderExchange, string(StockChangeReasonExchange), "-1"},
+		{"exchange releases replacement", StockChangeSourceOrderExchange, string(StockChangeReasonExchange), "1"},
+		{"transfer leaves a location", StockChangeSourceLocationTransfer, string(StockChangeReasonTransferOut), "-2"},
+		{"transfer arrives at a location", StockChangeSourceLocationTransfer, string(StockChangeReasonTransferIn), "2"},
+		{"order packed from a location", StockChangeSourceLocationTransfer, string(StockChangeReasonOrderAllocation), "-1"},
+		{"order allocation released", StockChangeSourceLocationTransfer, string(StockChangeReasonOrderAllocation), "1"},
 		{"shipping row carries no movement", StockChangeSourceOrderPaid, string(StockChangeReasonOrder), "0"},
 	}
 	for _, c := range cases {
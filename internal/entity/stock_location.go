@@ -0,0 +1,345 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// СКЛАДСКИЕ ЛОКАЦИИ (stock_location / product_size_location / material_location_stock /
+// material_lot_location / stock_transfer / stock_order_allocation, migration 0345).
+//
+// Итоговые остатки (product_size.quantity, material_stock.on_hand, material_lot.remaining_qty)
+// остаются источником правды для продаж, производства и склада материалов; все пути, не знающие
+// локаций, пишут их — то есть локацию ПО УМОЛЧАНИЮ. Её количество не хранится, а выводится:
+// итог минус строки остальных локаций минус то, что едет по перемещениям (DefaultLocationQty).
+// Строки недефолтных локаций пишут только перемещения и аллокация оплаченного заказа.
+
+// StockLocationKind is what a location physically is.
+type StockLocationKind string
+
+const (
+	StockLocationStudio    StockLocationKind = "studio"
+	StockLocationWarehouse StockLocationKind = "warehouse"
+	StockLocationShowroom  StockLocationKind = "showroom"
+	StockLocationPopup     StockLocationKind = "popup"
+	StockLocationFactory   StockLocationKind = "factory"
+)
+
+// ValidStockLocationKinds mirrors chk_stock_location_kind.
+var ValidStockLocationKinds = map[StockLocationKind]bool{
+	StockLocationStudio:    true,
+	StockLocationWarehouse: true,
+	StockLocationShowroom:  true,
+	StockLocationPopup:     true,
+	StockLocationFactory:   true,
+}
+
+// StockLocation is a named place stock can be in.
+type StockLocation struct {
+	Id        int               `db:"id"`
+	Code      string            `db:"code"`
+	Name      string            `db:"name"`
+	Kind      StockLocationKind `db:"kind"`
+	IsDefault bool              `db:"is_default"`
+	Address   sql.NullString    `db:"address"`
+	Note      sql.NullString    `db:"note"`
+	// ArchivedAt retires a location from transfers and allocation; its documents stay readable. The
+	// default location is never archived.
+	ArchivedAt sql.NullTime `db:"archived_at"`
+	CreatedBy  string       `db:"created_by"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  time.Time    `db:"updated_at"`
+}
+
+// StockLocationInsert is the writable part of a location. The default flag is not writable: the
+// default location is seeded by the migration and stays the one.
+type StockLocationInsert struct {
+	Code      string
+	Name      string
+	Kind      StockLocationKind
+	Address   sql.NullString
+	Note      sql.NullString
+	CreatedBy string
+}
+
+// maxStockLocationCode matches stock_location.code.
+const maxStockLocationCode = 32
+
+// ValidateStockLocationInsert checks a location payload; it returns a field violation or nil.
+func ValidateStockLocationInsert(ins StockLocationInsert) error {
+	switch {
+	case ins.Code == "":
+		return NewFieldViolation("code", "required", "", "give the location a short code, e.g. SHOWROOM")
+	case len(ins.Code) > maxStockLocationCode:
+		return NewFieldViolation("code", "too_long", "", fmt.Sprintf("keep the code within %d characters", maxStockLocationCode))
+	case ins.Name == "":
+		return NewFieldViolation("name", "required", "", "")
+	case !ValidStockLocationKinds[ins.Kind]:
+		return NewFieldViolation("kind", "invalid", "", "one of studio, warehouse, showroom, popup, factory")
+	}
+	return nil
+}
+
+// DefaultLocationQty is the derived quantity of the default location: the total minus what is
+// stored in every other location and what is on the road. Negative means the default location sold
+// more than it holds — the sale must be fulfilled (allocated) elsewhere or the stock brought in.
+func DefaultLocationQty(total, elsewhere, inTransit decimal.Decimal) decimal.Decimal {
+	return total.Sub(elsewhere).Sub(inTransit)
+}
+
+// LocationVariantStock is one variant's stock in a location.
+type LocationVariantStock struct {
+	ProductSizeId int    `db:"product_size_id"`
+	ProductId     int    `db:"product_id"`
+	SizeId        int    `db:"size_id"`
+	Grade         string `db:"grade"`
+	SKU           string `db:"sku"`
+	SizeName      string `db:"size_name"`
+	// Quantity is the location's quantity; derived (and possibly negative) for the default location.
+	Quantity int `db:"quantity"`
+	// Total is the variant's quantity across all locations (product_size.quantity).
+	Total int `db:"total"`
+}
+
+// LocationLotStock is one lot's part of a material's stock in a location.
+type LocationLotStock struct {
+	MaterialId int             `db:"material_id"`
+	LotId      int             `db:"lot_id"`
+	LotCode    string          `db:"lot_code"`
+	Quantity   decimal.Decimal `db:"quantity"`
+}
+
+// LocationMaterialStock is one material's stock in a location, with its lots.
+type LocationMaterialStock struct {
+	MaterialId   int             `db:"material_id"`
+	MaterialName string          `db:"material_name"`
+	Unit         sql.NullString  `db:"unit"`
+	Quantity     decimal.Decimal `db:"quantity"`
+	Total        decimal.Decimal `db:"total"`
+	Lots         []LocationLotStock
+}
+
+// LocationStock is everything a location holds.
+type LocationStock struct {
+	Location  StockLocation
+	Variants  []LocationVariantStock
+	Materials []LocationMaterialStock
+}
+
+// StockTransferStatus is the lifecycle of a transfer document.
+type StockTransferStatus string
+
+const (
+	// StockTransferDraft is being put together; nothing has moved.
+	StockTransferDraft StockTransferStatus = "draft"
+	// StockTransferInTransit has left the source and not yet arrived: its lines are in no location.
+	StockTransferInTransit StockTransferStatus = "in_transit"
+	// StockTransferReceived has arrived at the destination.
+	StockTransferReceived StockTransferStatus = "received"
+	// StockTransferCancelled was withdrawn; a cancelled in-transit transfer went back to the source.
+	StockTransferCancelled StockTransferStatus = "cancelled"
+)
+
+// CanTransitionTo reports whether a transfer in status s may move to next: a draft is dispatched or
+// cancelled, a transfer in transit is received or cancelled (back to the source). Received and
+// cancelled are final.
+func (s StockTransferStatus) CanTransitionTo(next StockTransferStatus) bool {
+	switch s {
+	case StockTransferDraft:
+		return next == StockTransferInTransit || next == StockTransferCancelled
+	case StockTransferInTransit:
+		return next == StockTransferReceived || next == StockTransferCancelled
+	}
+	return false
+}
+
+// StockTransferNumber is the document number a transfer is known by on the packing slip.
+func StockTransferNumber(id int) string {
+	return fmt.Sprintf("TR-%05d", id)
+}
+
+// StockTransfer is a transfer document header.
+type StockTransfer struct {
+	Id             int                 `db:"id"`
+	FromLocationId int                 `db:"from_location_id"`
+	FromLocation   string              `db:"from_location"` // joined for display
+	ToLocationId   int                 `db:"to_location_id"`
+	ToLocation     string              `db:"to_location"` // joined for display
+	Status         StockTransferStatus `db:"status"`
+	Note           sql.NullString      `db:"note"`
+	CreatedBy      string              `db:"created_by"`
+	DispatchedBy   sql.NullString      `db:"dispatched_by"`
+	DispatchedAt   sql.NullTime        `db:"dispatched_at"`
+	ReceivedBy     sql.NullString      `db:"received_by"`
+	ReceivedAt     sql.NullTime        `db:"received_at"`
+	CancelledBy    sql.NullString      `db:"cancelled_by"`
+	CancelledAt    sql.NullTime        `db:"cancelled_at"`
+	CreatedAt      time.Time           `db:"created_at"`
+	UpdatedAt      time.Time           `db:"updated_at"`
+}
+
+// StockTransferLine is one variant or one material (optionally one of its lots) of a transfer.
+type StockTransferLine struct {
+	Id            int             `db:"id"`
+	TransferId    int             `db:"transfer_id"`
+	ProductSizeId sql.NullInt32   `db:"product_size_id"`
+	MaterialId    sql.NullInt32   `db:"material_id"`
+	LotId         sql.NullInt32   `db:"lot_id"`
+	Quantity      decimal.Decimal `db:"quantity"`
+	// Display, joined: the variant's SKU and size, or the material's name, unit and lot code.
+	SKU          string         `db:"sku"`
+	SizeName     string         `db:"size_name"`
+	MaterialName string         `db:"material_name"`
+	Unit         sql.NullString `db:"unit"`
+	LotCode      string         `db:"lot_code"`
+}
+
+// IsVariant reports a finished-goods line.
+func (l StockTransferLine) IsVariant() bool { return l.ProductSizeId.Valid }
+
+// StockTransferFull is a transfer with its lines.
+type StockTransferFull struct {
+	StockTransfer
+	Lines []StockTransferLine
+}
+
+// StockTransferLineInsert is one line of a new transfer. Exactly one of ProductSizeId and MaterialId
+// is set; LotId narrows a material line to one lot.
+type StockTransferLineInsert struct {
+	ProductSizeId int
+	MaterialId    int
+	LotId         int
+	Quantity      decimal.Decimal
+}
+
+// StockTransferInsert is the payload of a new draft transfer.
+type StockTransferInsert struct {
+	FromLocationId int
+	ToLocationId   int
+	Note           sql.NullString
+	Lines          []StockTransferLineInsert
+	CreatedBy      string
+}
+
+// StockTransferFilter narrows the transfer list. LocationId matches either end.
+type StockTransferFilter struct {
+	Status     StockTransferStatus
+	LocationId int
+	Limit      int
+	Offset     int
+}
+
+// ValidateStockTransferInsert checks a transfer payload; it returns a field violation or nil. A
+// subject may appear once per transfer — two lines of the same variant would be checked against the
+// source one at a time and could together take more than it holds.
+func ValidateStockTransferInsert(ins StockTransferInsert) error {
+	if ins.FromLocationId <= 0 {
+		return NewFieldViolation("from_location_id", "required", "", "")
+	}
+	if ins.ToLocationId <= 0 {
+		return NewFieldViolation("to_location_id", "required", "", "")
+	}
+	if ins.FromLocationId == ins.ToLocationId {
+		return NewFieldViolation("to_location_id", "same_as_source", "", "pick a different destination")
+	}
+	if len(ins.Lines) == 0 {
+		return NewFieldViolation("lines", "required", "", "add at least one variant or material")
+	}
+	type subject struct{ variant, material, lot int }
+	seen := make(map[subject]bool, len(ins.Lines))
+	for i, l := range ins.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+		if (l.ProductSizeId > 0) == (l.MaterialId > 0) {
+			return NewFieldViolation(field, "one_subject", "", "set either variant_id or material_id")
+		}
+		if l.LotId > 0 && l.MaterialId <= 0 {
+			return NewFieldViolation(field+".lot_id", "material_required", "", "a lot belongs to a material line")
+		}
+		if !l.Quantity.IsPositive() {
+			return NewFieldViolation(field+".quantity", "must_be_positive", "", "")
+		}
+		if l.ProductSizeId > 0 && !l.Quantity.IsInteger() {
+			return NewFieldViolation(field+".quantity", "whole_units", "", "garments move in whole units")
+		}
+		key := subject{l.ProductSizeId, l.MaterialId, l.LotId}
+		if seen[key] {
+			return NewFieldViolation(field, "duplicate", "", "merge the quantity into one line")
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// StockAllocationNeed is one variant of a paid order to be picked.
+type StockAllocationNeed struct {
+	ProductSizeId int
+	Quantity      int
+}
+
+// LocationAvailability is what a candidate location can give an order, by variant. For the default
+// location it already includes the order's own units (the sale took them from its derived quantity).
+type LocationAvailability struct {
+	LocationId int
+	Available  map[int]int
+}
+
+// Covers reports whether the location holds every need in full.
+func (a LocationAvailability) Covers(needs []StockAllocationNeed) bool {
+	for _, n := range needs {
+		if a.Available[n.ProductSizeId] < n.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// PickFulfillmentLocation chooses where a paid order is packed: the first candidate that covers
+// every line, in the caller's order (the default location first). Orders are not split across
+// locations — a parcel leaves from one place.
+func PickFulfillmentLocation(needs []StockAllocationNeed, candidates []LocationAvailability) (int, error) {
+	for _, c := range candidates {
+		if c.Covers(needs) {
+			return c.LocationId, nil
+		}
+	}
+	return 0, ErrNoLocationCanFulfill
+}
+
+// StockOrderAllocation is one variant of a paid order allocated to a location.
+type StockOrderAllocation struct {
+	OrderId       int       `db:"order_id"`
+	ProductSizeId int       `db:"product_size_id"`
+	LocationId    int       `db:"location_id"`
+	Quantity      int       `db:"quantity"`
+	AllocatedBy   string    `db:"allocated_by"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// Stock location errors.
+var (
+	// ErrStockLocationNotFound is returned for an unknown location id.
+	ErrStockLocationNotFound = errors.New("stock location not found")
+	// ErrStockLocationArchived refuses moving stock into, out of, or packing from an archived location.
+	ErrStockLocationArchived = errors.New("stock location is archived")
+	// ErrStockLocationDefault refuses archiving the default location.
+	ErrStockLocationDefault = errors.New("the default stock location cannot be archived")
+	// ErrStockLocationCodeTaken is returned when another location already uses the code.
+	ErrStockLocationCodeTaken = errors.New("stock location code is already used")
+	// ErrStockLocationNotEmpty refuses archiving a location that still holds stock or has
+	// transfers on the road.
+	ErrStockLocationNotEmpty = errors.New("stock location still holds stock")
+	// ErrStockTransferNotFound is returned for an unknown transfer id.
+	ErrStockTransferNotFound = errors.New("stock transfer not found")
+	// ErrStockTransferState is returned when the transfer's status does not allow the operation.
+	ErrStockTransferState = errors.New("stock transfer status does not allow this")
+	// ErrInsufficientLocationStock refuses taking more out of a location than it holds.
+	ErrInsufficientLocationStock = errors.New("not enough stock in the location")
+	// ErrNoLocationCanFulfill is returned when no single location holds every line of an order.
+	ErrNoLocationCanFulfill = errors.New("no single location holds the whole order")
+	// ErrOrderNotAllocatable refuses allocating an order that is not paid-and-unshipped.
+	ErrOrderNotAllocatable = errors.New("only a confirmed order can be allocated to a location")
+)
@@ -0,0 +1,100 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestStockTransferTransitions(t *testing.T) {
+	allowed := map[[2]StockTransferStatus]bool{
+		{StockTransferDraft, StockTransferInTransit}:     true,
+		{StockTransferDraft, StockTransferCancelled}:     true,
+		{StockTransferInTransit, StockTransferReceived}:  true,
+		{StockTransferInTransit, StockTransferCancelled}: true,
+	}
+	all := []StockTransferStatus{StockTransferDraft, StockTransferInTransit, StockTransferReceived, StockTransferCancelled}
+	for _, from := range all {
+		for _, to := range all {
+			if got := from.CanTransitionTo(to); got != allowed[[2]StockTransferStatus{from, to}] {
+				t.Errorf("%s → %s = %v", from, to, got)
+			}
+		}
+	}
+}
+
+func TestValidateStockTransferInsert(t *testing.T) {
+	ok := StockTransferInsert{
+		FromLocationId: 1,
+		ToLocationId:   2,
+		Lines: []StockTransferLineInsert{
+			{ProductSizeId: 10, Quantity: d("3")},
+			{MaterialId: 4, Quantity: d("12.5")},
+			{MaterialId: 4, LotId: 7, Quantity: d("2.25")},
+		},
+	}
+	if err := ValidateStockTransferInsert(ok); err != nil {
+		t.Fatalf("valid transfer rejected: %v", err)
+	}
+
+	cases := map[string]struct {
+		mutate func(*StockTransferInsert)
+		field  string
+	}{
+		"same location":  {func(i *StockTransferInsert) { i.ToLocationId = 1 }, "to_location_id"},
+		"no lines":       {func(i *StockTransferInsert) { i.Lines = nil }, "lines"},
+		"both subjects":  {func(i *StockTransferInsert) { i.Lines[0].MaterialId = 4 }, "lines[0]"},
+		"no subject":     {func(i *StockTransferInsert) { i.Lines[0].ProductSizeId = 0 }, "lines[0]"},
+		"zero qty":       {func(i *StockTransferInsert) { i.Lines[1].Quantity = decimal.Zero }, "lines[1].quantity"},
+		"half garment":   {func(i *StockTransferInsert) { i.Lines[0].Quantity = d("1.5") }, "lines[0].quantity"},
+		"lot on variant": {func(i *StockTransferInsert) { i.Lines[0].LotId = 7 }, "lines[0].lot_id"},
+		"duplicate":      {func(i *StockTransferInsert) { i.Lines[2].LotId = 0 }, "lines[2]"},
+	}
+	for name, c := range cases {
+		ins := ok
+		ins.Lines = append([]StockTransferLineInsert(nil), ok.Lines...)
+		c.mutate(&ins)
+		err := ValidateStockTransferInsert(ins)
+		var ve *ValidationError
+		if !errors.As(err, &ve) || ve.Field != c.field {
+			t.Errorf("%s: err = %v, want a violation on %s", name, err, c.field)
+		}
+	}
+}
+
+func TestPickFulfillmentLocation(t *testing.T) {
+	needs := []StockAllocationNeed{{ProductSizeId: 1, Quantity: 2}, {ProductSizeId: 2, Quantity: 1}}
+	candidates := []LocationAvailability{
+		{LocationId: 1, Available: map[int]int{1: 2, 2: 0}}, // default: short on the second line
+		{LocationId: 3, Available: map[int]int{1: 5}},       // showroom: lacks the second variant
+		{LocationId: 4, Available: map[int]int{1: 2, 2: 1}}, // studio: holds the whole order
+		{LocationId: 5, Available: map[int]int{1: 9, 2: 9}},
+	}
+	got, err := PickFulfillmentLocation(needs, candidates)
+	if err != nil || got != 4 {
+		t.Fatalf("picked %d, %v; want 4", got, err)
+	}
+	if _, err := PickFulfillmentLocation(needs, candidates[:2]); !errors.Is(err, ErrNoLocationCanFulfill) {
+		t.Fatalf("err = %v, want ErrNoLocationCanFulfill", err)
+	}
+}
+
+func TestDefaultLocationQty(t *testing.T) {
+	if got := DefaultLocationQty(d("10"), d("6"), d("3")); !got.Equal(d("1")) {
+		t.Fatalf("remainder = %s, want 1", got)
+	}
+	// Sold more than the default location held: the deficit shows, it is not clamped.
+	if got := DefaultLocationQty(d("4"), d("6"), decimal.Zero); !got.Equal(d("-2")) {
+		t.Fatalf("remainder = %s, want -2", got)
+	}
+}
+
+func TestValidateStockLocationInsert(t *testing.T) {
+	if err := ValidateStockLocationInsert(StockLocationInsert{Code: "SHOW", Name: "Showroom", Kind: StockLocationShowroom}); err != nil {
+		t.Fatalf("valid location rejected: %v", err)
+	}
+	if err := ValidateStockLocationInsert(StockLocationInsert{Code: "SHOW", Name: "Showroom", Kind: "shop"}); err == nil {
+		t.Fatal("unknown kind accepted")
+	}
+}
@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// StockReservation is one durable storefront stock hold (stock_reservation, 0332): a session's
+// advisory claim on a (product, size) pair until ExpiresAt. OrderUUID is set once the cart was
+// committed to a submitted order.
+type StockReservation struct {
+	Id           int             `db:"id"`
+	ProductId    int             `db:"product_id"`
+	SizeId       int             `db:"size_id"`
+	SessionId    string          `db:"session_id"`
+	OrderUUID    sql.NullString  `db:"order_uuid"`
+	Quantity     decimal.Decimal `db:"quantity"`
+	RefreshCount int             `db:"refresh_count"`
+	ExpiresAt    time.Time       `db:"expires_at"`
+	CreatedAt    time.Time       `db:"created_at"`
+}
+
+// StockReservationInsert is the full state of a hold written by an upsert on its
+// (product, size, session) key.
+type StockReservationInsert struct {
+	ProductId    int             `db:"product_id"`
+	SizeId       int             `db:"size_id"`
+	SessionId    string          `db:"session_id"`
+	OrderUUID    sql.NullString  `db:"order_uuid"`
+	Quantity     decimal.Decimal `db:"quantity"`
+	RefreshCount int             `db:"refresh_count"`
+	ExpiresAt    time.Time       `db:"expires_at"`
+	CreatedAt    time.Time       `db:"created_at"`
+}
+
+// StockReservationStats summarizes the live (non-expired) holds.
+type StockReservationStats struct {
+	Total          int `db:"total"`
+	Cart           int `db:"cart"`
+	Order          int `db:"order_holds"`
+	ActiveSessions int `db:"active_sessions"`
+	ActiveOrders   int `db:"active_orders"`
+}
@@ -0,0 +1,159 @@
+package entity
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// StoreCreditWalletKind says what a store_credit_wallet is (0334).
+type StoreCreditWalletKind string
+
+const (
+	// StoreCreditWalletGiftCard is a bearer balance behind a code, issued by a paid gift-card line.
+	StoreCreditWalletGiftCard StoreCreditWalletKind = "gift_card"
+	// StoreCreditWalletAccount is a customer's balance in one currency, keyed by email.
+	StoreCreditWalletAccount StoreCreditWalletKind = "account"
+)
+
+// ValidStoreCreditWalletKinds mirrors the store_credit_wallet.kind ENUM.
+var ValidStoreCreditWalletKinds = map[StoreCreditWalletKind]bool{
+	StoreCreditWalletGiftCard: true,
+	StoreCreditWalletAccount:  true,
+}
+
+// StoreCreditWalletStatus gates whether a wallet can be spent.
+type StoreCreditWalletStatus string
+
+const (
+	StoreCreditWalletActive   StoreCreditWalletStatus = "active"
+	StoreCreditWalletDisabled StoreCreditWalletStatus = "disabled"
+)
+
+// ValidStoreCreditWalletStatuses mirrors the store_credit_wallet.status ENUM.
+var ValidStoreCreditWalletStatuses = map[StoreCreditWalletStatus]bool{
+	StoreCreditWalletActive:   true,
+	StoreCreditWalletDisabled: true,
+}
+
+// StoreCreditEntryType is the kind of one store_credit_ledger movement.
+type StoreCreditEntryType string
+
+const (
+	// StoreCreditEntryIssue funds a gift card when the order that bought it is paid.
+	StoreCreditEntryIssue StoreCreditEntryType = "issue"
+	// StoreCreditEntryRedeem spends credit as a tender on an order (negative).
+	StoreCreditEntryRedeem StoreCreditEntryType = "redeem"
+	// StoreCreditEntryRelease gives back credit an unpaid order had taken (cancel, expiry, or a total
+	// that shrank below what was applied).
+	StoreCreditEntryRelease StoreCreditEntryType = "release"
+	// StoreCreditEntryRefundCredit is a refund paid out as store credit instead of money.
+	StoreCreditEntryRefundCredit StoreCreditEntryType = "refund_credit"
+	// StoreCreditEntryAdjustment is a manual admin correction, either sign.
+	StoreCreditEntryAdjustment StoreCreditEntryType = "adjustment"
+)
+
+// ValidStoreCreditEntryTypes mirrors the store_credit_ledger.entry_type ENUM.
+var ValidStoreCreditEntryTypes = map[StoreCreditEntryType]bool{
+	StoreCreditEntryIssue:        true,
+	StoreCreditEntryRedeem:       true,
+	StoreCreditEntryRelease:      true,
+	StoreCreditEntryRefundCredit: true,
+	StoreCreditEntryAdjustment:   true,
+}
+
+// StoreCreditWallet is a gift card or an account's store-credit balance. Balance is the running
+// total of the wallet's ledger, maintained under the wallet row lock.
+type StoreCreditWallet struct {
+	Id                int                     `db:"id"`
+	Kind              StoreCreditWalletKind   `db:"kind"`
+	Code              sql.NullString          `db:"code"`
+	AccountEmail      sql.NullString          `db:"account_email"`
+	Currency          string                  `db:"currency"`
+	Balance           decimal.Decimal         `db:"balance"`
+	InitialAmount     decimal.Decimal         `db:"initial_amount"`
+	Status            StoreCreditWalletStatus `db:"status"`
+	SourceOrderId     sql.NullInt32           `db:"source_order_id"`
+	SourceOrderItemId sql.NullInt32           `db:"source_order_item_id"`
+	SourceOrderUUID   sql.NullString          `db:"source_order_uuid"` // joined for display
+	PurchaserEmail    sql.NullString          `db:"purchaser_email"`
+	CreatedAt         time.Time               `db:"created_at"`
+	UpdatedAt         time.Time               `db:"updated_at"`
+}
+
+// StoreCreditLedgerEntry is one append-only balance movement. Amount is signed; OrderUUID is joined
+// from the order for display.
+type StoreCreditLedgerEntry struct {
+	Id           int                  `db:"id"`
+	WalletId     int                  `db:"wallet_id"`
+	EntryType    StoreCreditEntryType `db:"entry_type"`
+	Amount       decimal.Decimal      `db:"amount"`
+	BalanceAfter decimal.Decimal      `db:"balance_after"`
+	OrderId      sql.NullInt32        `db:"order_id"`
+	OrderUUID    sql.NullString       `db:"order_uuid"`
+	Note         sql.NullString       `db:"note"`
+	CreatedBy    string               `db:"created_by"`
+	CreatedAt    time.Time            `db:"created_at"`
+}
+
+// StoreCreditWalletFull is a wallet with its ledger, oldest movement first.
+type StoreCreditWalletFull struct {
+	StoreCreditWallet
+	Ledger []StoreCreditLedgerEntry
+}
+
+// StoreCreditWalletFilters narrows the admin wallet list.
+type StoreCreditWalletFilters struct {
+	Kind   *StoreCreditWalletKind
+	Email  string
+	Code   string
+	Status *StoreCreditWalletStatus
+}
+
+// StoreCreditRedemption is the tender a checkout asks to apply: a gift card code, or the buyer's own
+// account credit. AccountEmail is server-set from the authenticated storefront session, never taken
+// from the request, so one customer cannot spend another's balance.
+type StoreCreditRedemption struct {
+	GiftCardCode string
+	AccountEmail string
+}
+
+// IsZero reports whether no credit was asked for.
+func (r StoreCreditRedemption) IsZero() bool {
+	return r.GiftCardCode == "" && r.AccountEmail == ""
+}
+
+// NormalizeGiftCardCode canonicalises a customer-typed code: upper case, spaces dropped.
+func NormalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// ValidateStoreCreditAdjustment checks a manual admin adjustment before it touches a wallet.
+func ValidateStoreCreditAdjustment(amount decimal.Decimal, note string) error {
+	if amount.IsZero() {
+		return &ValidationError{Message: "adjustment amount must not be zero", Field: "amount"}
+	}
+	if amount.Exponent() < -2 {
+		return &ValidationError{Message: "adjustment amount has more than two decimal places", Field: "amount"}
+	}
+	if strings.TrimSpace(note) == "" {
+		return &ValidationError{Message: "a note is required for a manual adjustment", Field: "note"}
+	}
+	if len(note) > 255 {
+		return &ValidationError{Message: "note must not exceed 255 characters", Field: "note"}
+	}
+	return nil
+}
+
+// SplitOrderRefund divides a refund between the card and store credit for an order that was partly
+// paid with credit. Refunds go back to the card first, up to what the card was charged, and only the
+// rest returns as credit; o is the order before this refund, so RefundedAmount is what earlier
+// refunds already took. An order without credit refunds entirely to the card.
+func SplitOrderRefund(o *Order, amount decimal.Decimal) (card, credit decimal.Decimal) {
+	cardPaid := o.TotalPrice.Sub(o.StoreCreditApplied)
+	cardLeft := cardPaid.Sub(decimal.Min(o.RefundedAmount, cardPaid))
+	card = decimal.Max(decimal.Min(amount, cardLeft), decimal.Zero)
+	return card, amount.Sub(card)
+}
@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSplitOrderRefund(t *testing.T) {
+	d := decimal.RequireFromString
+	tests := []struct {
+		name                string
+		total, applied, pre string
+		amount              string
+		card, credit        string
+	}{
+		{"no credit", "250", "0", "0", "100", "100", "0"},
+		{"card first", "250", "50", "0", "100", "100", "0"},
+		{"crosses into credit", "250", "50", "100", "150", "100", "50"},
+		{"full refund", "250", "50", "0", "250", "200", "50"},
+		{"card exhausted", "250", "50", "200", "50", "0", "50"},
+		{"fully credit-paid", "80", "80", "0", "30", "0", "30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Order{TotalPrice: d(tt.total), StoreCreditApplied: d(tt.applied), RefundedAmount: d(tt.pre)}
+			card, credit := SplitOrderRefund(o, d(tt.amount))
+			if !card.Equal(d(tt.card)) || !credit.Equal(d(tt.credit)) {
+				t.Errorf("SplitOrderRefund = (%s, %s), want (%s, %s)", card, credit, tt.card, tt.credit)
+			}
+		})
+	}
+}
+
+func TestValidateStoreCreditAdjustment(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		note      string
+		wantField string
+	}{
+		{"credit", "25.00", "goodwill", ""},
+		{"debit", "-10.50", "correction", ""},
+		{"zero", "0", "goodwill", "amount"},
+		{"sub-cent", "1.005", "goodwill", "amount"},
+		{"no note", "5", "  ", "note"},
+		{"long note", "5", strings.Repeat("x", 256), "note"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStoreCreditAdjustment(decimal.RequireFromString(tt.amount), tt.note)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("want ValidationError, got %T: %v", err, err)
+			}
+			if ve.Field != tt.wantField {
+				t.Errorf("field = %q, want %q", ve.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestNormalizeGiftCardCode(t *testing.T) {
+	if got := NormalizeGiftCardCode(" gc-abcd efgh-JKLM "); got != "GC-ABCDEFGH-JKLM" {
+		t.Errorf("NormalizeGiftCardCode = %q", got)
+	}
+}
@@ -0,0 +1,242 @@
+package entity
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ПОДРЯД НА ПОШИВ (subcontractor / cmt_price_agreement / production_run_subcontract /
+// production_run_subcontract_kit, migration 0344).
+//
+// Прогон на подряде кроит и шьёт внешняя фабрика (CMT — cut, make, trim). Материал остаётся нашим:
+// комплект уезжает движением consign_out — с полки, но не со счёта 1110, — и лежит «на консигнации»
+// у фабрики, пока не вернётся остаток (consign_return) или пока СВЕРКА не спишет израсходованное в
+// прогон обычным issue_production. Цена пошива берётся из соглашения с фабрикой по модели снимком на
+// момент назначения и начисляется сверкой строкой затрат kind='cmt' за вернувшиеся годные изделия.
+//
+// Фабрика видит прогон через портал (/api/cmt/{token}, internal/subcontractportal): грид, комплект
+// и свои подтверждения — дату отгрузки и количество. ДЕНЕГ В ПОРТАЛЕ НЕТ, по тому же правилу, что и
+// в наряде (entity.RunPack).
+
+// Subcontractor is the profile of a factory sewing for us. It extends a supplier of the catalog
+// (0201) rather than duplicating it: the factory's invoices, AP and run assignment all key on the
+// same supplier id.
+type Subcontractor struct {
+	SupplierId   int            `db:"supplier_id"`
+	SupplierName string         `db:"supplier_name"`
+	ContactName  sql.NullString `db:"contact_name"`
+	ContactEmail sql.NullString `db:"contact_email"`
+	ContactPhone sql.NullString `db:"contact_phone"`
+	Address      sql.NullString `db:"address"`
+	Note         sql.NullString `db:"note"`
+	ArchivedAt   sql.NullTime   `db:"archived_at"`
+	CreatedBy    string         `db:"created_by"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+}
+
+// SubcontractorInsert is the writable part of a profile. Archiving is its own call.
+type SubcontractorInsert struct {
+	SupplierId   int
+	ContactName  sql.NullString
+	ContactEmail sql.NullString
+	ContactPhone sql.NullString
+	Address      sql.NullString
+	Note         sql.NullString
+}
+
+// CmtPriceAgreement is the price a factory sews one garment of a style for, over a period. It is
+// never edited: a new price is a new agreement, and the old one is closed by a date.
+type CmtPriceAgreement struct {
+	Id           int             `db:"id"`
+	SupplierId   int             `db:"supplier_id"`
+	SupplierName string          `db:"supplier_name"`
+	TechCardId   int             `db:"tech_card_id"`
+	StyleNumber  string          `db:"style_number"`
+	StyleName    string          `db:"style_name"`
+	UnitPrice    decimal.Decimal `db:"unit_price"`
+	Currency     string          `db:"currency"`
+	ValidFrom    time.Time       `db:"valid_from"`
+	ValidTo      sql.NullTime    `db:"valid_to"` // last day in force; invalid = open-ended
+	Note         sql.NullString  `db:"note"`
+	CreatedBy    string          `db:"created_by"`
+	CreatedAt    time.Time       `db:"created_at"`
+}
+
+// InForce reports whether the agreement applies on day (a date, time of day ignored).
+func (a *CmtPriceAgreement) InForce(day time.Time) bool {
+	d := day.Truncate(24 * time.Hour)
+	if d.Before(a.ValidFrom.Truncate(24 * time.Hour)) {
+		return false
+	}
+	return !a.ValidTo.Valid || !d.After(a.ValidTo.Time.Truncate(24*time.Hour))
+}
+
+// CmtPriceAgreementInsert opens an agreement.
+type CmtPriceAgreementInsert struct {
+	SupplierId int
+	TechCardId int
+	UnitPrice  decimal.Decimal
+	Currency   string
+	ValidFrom  time.Time
+	ValidTo    sql.NullTime
+	Note       sql.NullString
+}
+
+// CmtPriceAgreementFilter narrows the agreement list; zero fields mean "any". Closed agreements
+// (valid_to in the past) are listed only when IncludeClosed is set.
+type CmtPriceAgreementFilter struct {
+	SupplierId    int
+	TechCardId    int
+	IncludeClosed bool
+}
+
+// ProductionRunSubcontract is a run sewn by a factory: the price snapshot it was assigned at, the
+// portal access row, what the factory confirmed and, once reconciled, what came back.
+type ProductionRunSubcontract struct {
+	RunId        int             `db:"run_id"`
+	SupplierId   int             `db:"supplier_id"`
+	SupplierName string          `db:"supplier_name"`
+	AgreementId  sql.NullInt32   `db:"agreement_id"`
+	UnitPrice    decimal.Decimal `db:"unit_price"` // snapshot of the agreement at assignment
+	Currency     string          `db:"currency"`
+	// Portal access (/api/cmt/{token}, scope 's'): the run pack mechanics of 0293 — bumping the
+	// epoch kills every link handed out before.
+	PortalEpoch     int          `db:"portal_epoch"`
+	PortalExpiresAt sql.NullTime `db:"portal_expires_at"`
+	PortalRevokedAt sql.NullTime `db:"portal_revoked_at"`
+	// What the factory confirmed through the portal; invalid = not confirmed yet.
+	ConfirmedShipDate sql.NullTime   `db:"confirmed_ship_date"`
+	ConfirmedQty      sql.NullInt32  `db:"confirmed_qty"`
+	FactoryNote       sql.NullString `db:"factory_note"`
+	ConfirmedAt       sql.NullTime   `db:"confirmed_at"`
+	// Reconciliation; ReconciledAt invalid = the kit is still open.
+	ReturnedGoodQty   sql.NullInt32  `db:"returned_good_qty"`
+	ReturnedDefectQty sql.NullInt32  `db:"returned_defect_qty"`
+	ReconcileNote     sql.NullString `db:"reconcile_note"`
+	ReconciledBy      sql.NullString `db:"reconciled_by"`
+	ReconciledAt      sql.NullTime   `db:"reconciled_at"`
+	AssignedBy        string         `db:"assigned_by"`
+	CreatedAt         time.Time      `db:"created_at"`
+	UpdatedAt         time.Time      `db:"updated_at"`
+	// Kit is the per-material balance: from the movements while the kit is open, from the
+	// reconciliation rows once it is closed.
+	Kit []SubcontractKitLine `db:"-"`
+}
+
+// Reconciled reports whether the kit is closed.
+func (r *ProductionRunSubcontract) Reconciled() bool { return r.ReconciledAt.Valid }
+
+// SubcontractKitLine is one material of a run's kit. AtFactory = Shipped − Returned − Consumed is
+// what the factory still holds; after reconciliation it is zero by construction.
+type SubcontractKitLine struct {
+	MaterialId       int                 `db:"material_id"`
+	MaterialName     string              `db:"material_name"`
+	Unit             string              `db:"unit"`
+	Shipped          decimal.Decimal     `db:"shipped"`
+	Returned         decimal.Decimal     `db:"returned"`
+	Consumed         decimal.Decimal     `db:"consumed"`
+	DeclaredConsumed decimal.NullDecimal `db:"declared_consumed"`
+	UnitCostBase     decimal.NullDecimal `db:"unit_cost_base"`
+}
+
+// AtFactory is the quantity still on the factory floor.
+func (l SubcontractKitLine) AtFactory() decimal.Decimal {
+	return l.Shipped.Sub(l.Returned).Sub(l.Consumed)
+}
+
+// Variance is what the factory cannot account for: consumed by the books minus consumed by its own
+// report. Positive = material lost at the factory. Invalid when the factory declared nothing.
+func (l SubcontractKitLine) Variance() decimal.NullDecimal {
+	if !l.DeclaredConsumed.Valid {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NullDecimal{Decimal: l.Consumed.Sub(l.DeclaredConsumed.Decimal), Valid: true}
+}
+
+// SubcontractKitInput is one material of a kit shipment or a leftover return.
+type SubcontractKitInput struct {
+	MaterialId int
+	Quantity   decimal.Decimal
+}
+
+// SubcontractKitMove ships a kit to the run's factory or takes a leftover back. Every line commits
+// together or none does.
+type SubcontractKitMove struct {
+	RunId      int
+	Lines      []SubcontractKitInput
+	OccurredAt sql.NullTime
+	Comment    sql.NullString
+	Username   string
+}
+
+// SubcontractConfirmation is what the factory confirms through the portal. Both fields are
+// required: a half confirmation is not one the planner can schedule against.
+type SubcontractConfirmation struct {
+	ShipDate time.Time
+	Qty      int
+	Note     sql.NullString
+}
+
+// SubcontractReconcileInput closes a run's kit. Declared maps material id to the consumption the
+// factory reported; a material left out was not reported.
+type SubcontractReconcileInput struct {
+	RunId          int
+	ReturnedGood   int
+	ReturnedDefect int
+	Declared       map[int]decimal.Decimal
+	Note           sql.NullString
+	Username       string
+}
+
+// SubcontractPortalUpdate rotates or revokes the factory's portal link. Rotate bumps the epoch and
+// clears a revocation; Revoke kills the link until the next rotation. ExpiresAt replaces the expiry.
+type SubcontractPortalUpdate struct {
+	Rotate    bool
+	Revoke    bool
+	ExpiresAt sql.NullTime
+}
+
+// ProductionRunEventSubcontract* are the run audit trail events of the subcontract workflow.
+const (
+	ProductionRunEventSubcontractAssigned   = "subcontract_assigned"
+	ProductionRunEventSubcontractKitShipped = "subcontract_kit_shipped"
+	ProductionRunEventSubcontractKitReturn  = "subcontract_kit_returned"
+	ProductionRunEventSubcontractConfirmed  = "subcontract_confirmed"
+	ProductionRunEventSubcontractReconciled = "subcontract_reconciled"
+)
+
+// ErrSubcontractorNotFound is returned when a supplier has no subcontractor profile.
+var ErrSubcontractorNotFound = errors.New("subcontractor not found")
+
+// ErrSubcontractorArchived refuses assigning a run to, or pricing work with, an archived factory.
+var ErrSubcontractorArchived = errors.New("subcontractor is archived")
+
+// ErrCmtAgreementNotFound is returned when an agreement does not exist, or when none of the
+// factory's agreements for the run's style is in force today.
+var ErrCmtAgreementNotFound = errors.New("no cmt price agreement in force for this factory and style")
+
+// ErrCmtAgreementOverlap refuses an agreement whose period overlaps another one of the same factory
+// and style: the price of a day must be one number.
+var ErrCmtAgreementOverlap = errors.New("cmt price agreement overlaps another one for this factory and style")
+
+// ErrSubcontractNotFound is returned when a run is not on subcontract.
+var ErrSubcontractNotFound = errors.New("production run is not subcontracted")
+
+// ErrSubcontractKitShipped refuses reassigning a run whose kit has moved: the material at the old
+// factory would be orphaned.
+var ErrSubcontractKitShipped = errors.New("the kit has already moved; the run cannot change factory")
+
+// ErrSubcontractReconciled refuses kit movements, confirmations and a second reconciliation once the
+// kit is closed.
+var ErrSubcontractReconciled = errors.New("the subcontract is already reconciled")
+
+// ErrSubcontractNotReconciled refuses the final receipt of a subcontracted run whose kit is still
+// open: the run's unit cost would freeze without the material and the CMT charge.
+var ErrSubcontractNotReconciled = errors.New("the subcontract must be reconciled before the final receipt")
+
+// ErrExcessiveConsignReturn refuses taking back more of a material than is at the factory.
+var ErrExcessiveConsignReturn = errors.New("leftover exceeds what is at the factory")
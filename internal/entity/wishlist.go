@@ -0,0 +1,175 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ИЗБРАННОЕ ПОКУПАТЕЛЯ (storefront_wishlist_item, 0350): колорвей, по желанию с размером, в списке
+// аккаунта витрины. Воркер уведомлений следит за скидкой и остатком каждой строки и пишет покупателю
+// на переходе — см. WishlistWatch.Observe.
+
+// MaxWishlistItems bounds one account's wishlist; a merged guest list is cut at it.
+const MaxWishlistItems = 200
+
+var (
+	// ErrWishlistFull is returned when an item does not fit under MaxWishlistItems.
+	ErrWishlistFull = errors.New("wishlist is full")
+	// ErrWishlistItemNotFound is returned when removing an item the wishlist does not hold.
+	ErrWishlistItemNotFound = errors.New("wishlist item not found")
+)
+
+// WishlistRef is how the storefront names a wished item: a colourway base SKU, optionally pinned to
+// one size by its variant SKU. Either may be given alone; when both are, they must agree.
+type WishlistRef struct {
+	BaseSKU    string
+	VariantSKU string
+}
+
+// WishlistTarget is a WishlistRef resolved to the catalogue, with the tier gate of its colourway.
+// Only storefront-visible colourways and active grade-A variants resolve.
+type WishlistTarget struct {
+	Ref                   WishlistRef
+	ProductId             int
+	SizeId                int // 0 — the whole colourway
+	MinTier               int16
+	HiddenForNonQualified bool
+}
+
+// WishlistItem is one row of an account's wishlist.
+type WishlistItem struct {
+	Id        int    `db:"id"`
+	AccountId int    `db:"account_id"`
+	ProductId int    `db:"product_id"`
+	SizeId    int    `db:"size_id"` // 0 — the whole colourway
+	BaseSKU   string `db:"base_sku"`
+	// VariantSKU is the pinned size's variant SKU; empty for the whole colourway.
+	VariantSKU string    `db:"variant_sku"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// WishlistAlertKind is what a wishlist alert tells the customer.
+type WishlistAlertKind string
+
+const (
+	// WishlistAlertOnSale: the colourway went on sale, or its markdown deepened.
+	WishlistAlertOnSale WishlistAlertKind = "on_sale"
+	// WishlistAlertLowStock: stock dropped from above the threshold to the last few pieces.
+	WishlistAlertLowStock WishlistAlertKind = "low_stock"
+	// WishlistAlertRestock: stock came back from zero.
+	WishlistAlertRestock WishlistAlertKind = "restock"
+)
+
+// WishlistWatch is one wishlist item as the notifier sees it: what the colourway is now and what the
+// notifier saw the previous time. Seen values are invalid until the first observation.
+type WishlistWatch struct {
+	ItemId      int                   `db:"id"`
+	AccountId   int                   `db:"account_id"`
+	Email       string                `db:"email"`
+	FirstName   string                `db:"first_name"`
+	LastName    string                `db:"last_name"`
+	AccountTier StorefrontAccountTier `db:"account_tier"`
+	ProductId   int                   `db:"product_id"`
+	SizeId      int                   `db:"size_id"` // 0 — the whole colourway
+
+	// SubscribeNewsletter is the account's marketing opt-in; alerts are marketing mail.
+	SubscribeNewsletter bool `db:"subscribe_newsletter"`
+
+	MinTier               int16 `db:"min_tier"`
+	HiddenForNonQualified bool  `db:"hidden_for_non_qualified"`
+
+	SalePercentage decimal.NullDecimal `db:"sale_percentage"`
+	// Quantity is the grade-A stock of the pinned size, or of every active size of the colourway.
+	Quantity decimal.Decimal `db:"quantity"`
+
+	SeenSalePercentage decimal.NullDecimal `db:"seen_sale_percentage"`
+	SeenQuantity       decimal.NullDecimal `db:"seen_quantity"`
+}
+
+// WishlistSeen is the state the notifier records for an item after looking at it.
+type WishlistSeen struct {
+	ItemId         int
+	SalePercentage decimal.Decimal
+	Quantity       decimal.Decimal
+}
+
+// Visible reports whether the account may see the colourway: a tier-hidden colourway is never
+// mailed to an account that no longer qualifies for it.
+func (w *WishlistWatch) Visible() bool {
+	return !w.HiddenForNonQualified || TierCanPurchase(TierCode(w.AccountTier), w.MinTier)
+}
+
+// sale is the current markdown, zero when the colourway is at full price.
+func (w *WishlistWatch) sale() decimal.Decimal {
+	if w.SalePercentage.Valid && w.SalePercentage.Decimal.IsPositive() {
+		return w.SalePercentage.Decimal
+	}
+	return decimal.Zero
+}
+
+// Observe compares the item's current sale and stock with what was seen last time and returns the
+// alerts due, the state to record, and whether that state differs from the recorded one.
+//
+// Alerts fire on transitions only. A first observation just records, so wishing for something already
+// on sale or already scarce sends nothing. A sale alerts when the markdown deepens (a new sale is a
+// deepening from zero); when it ends the recorded value drops back to zero, so the next sale alerts
+// again. Stock alerts on a restock (from zero to any) and on crossing into (0, lowStock] from above it.
+func (w *WishlistWatch) Observe(lowStock decimal.Decimal) ([]WishlistAlertKind, WishlistSeen, bool) {
+	seen := WishlistSeen{ItemId: w.ItemId, SalePercentage: w.sale(), Quantity: w.Quantity}
+	if !w.SeenSalePercentage.Valid || !w.SeenQuantity.Valid {
+		return nil, seen, true
+	}
+	changed := !seen.SalePercentage.Equal(w.SeenSalePercentage.Decimal) || !seen.Quantity.Equal(w.SeenQuantity.Decimal)
+
+	var alerts []WishlistAlertKind
+	if seen.SalePercentage.GreaterThan(w.SeenSalePercentage.Decimal) {
+		alerts = append(alerts, WishlistAlertOnSale)
+	}
+	prev, cur := w.SeenQuantity.Decimal, seen.Quantity
+	switch {
+	case !prev.IsPositive() && cur.IsPositive():
+		alerts = append(alerts, WishlistAlertRestock)
+	case prev.GreaterThan(lowStock) && cur.IsPositive() && cur.LessThanOrEqual(lowStock):
+		alerts = append(alerts, WishlistAlertLowStock)
+	}
+	return alerts, seen, changed
+}
+
+// KeepUnsent returns seen with the dimension of every unsent alert put back to what was recorded
+// before — the sale for an on-sale alert, the stock for a stock alert — so exactly those alerts are
+// due again next time while the ones that went out are not repeated.
+func (w *WishlistWatch) KeepUnsent(seen WishlistSeen, unsent []WishlistAlertKind) WishlistSeen {
+	for _, kind := range unsent {
+		switch kind {
+		case WishlistAlertOnSale:
+			seen.SalePercentage = w.SeenSalePercentage.Decimal
+		case WishlistAlertLowStock, WishlistAlertRestock:
+			seen.Quantity = w.SeenQuantity.Decimal
+		}
+	}
+	return seen
+}
+
+// MostWishedStyle is one style in the most-wished report: how many accounts wish for any of its
+// colourways, and how many wishlist entries it gained in the report window.
+type MostWishedStyle struct {
+	StyleId     int    `db:"style_id"`
+	StyleNumber string `db:"style_number"`
+	Name        string `db:"name"`
+	// Wishers counts distinct accounts; Items counts entries (a size-pinned entry per size).
+	Wishers       int                  `db:"wishers"`
+	Items         int                  `db:"items"`
+	AddedInWindow int                  `db:"added_in_window"`
+	Colorways     []MostWishedColorway `db:"-"`
+}
+
+// MostWishedColorway is one colourway of a MostWishedStyle.
+type MostWishedColorway struct {
+	StyleId   int    `db:"style_id"`
+	ProductId int    `db:"product_id"`
+	SKU       string `db:"sku"`
+	Color     string `db:"color"`
+	Wishers   int    `db:"wishers"`
+}
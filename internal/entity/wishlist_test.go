@@ -0,0 +1,112 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestWishlistWatchObserve(t *testing.T) {
+	d := decimal.RequireFromString
+	nd := func(s string) decimal.NullDecimal { return decimal.NewNullDecimal(d(s)) }
+	lowStock := d("3")
+
+	cases := []struct {
+		name    string
+		sale    decimal.NullDecimal
+		qty     string
+		seenPct decimal.NullDecimal
+		seenQty decimal.NullDecimal
+		alerts  []WishlistAlertKind
+		changed bool
+	}{
+		// First observation records only, even when already on sale and scarce.
+		{"first look", nd("30"), "1", decimal.NullDecimal{}, decimal.NullDecimal{}, nil, true},
+		{"nothing moved", nd("30"), "5", nd("30"), nd("5"), nil, false},
+		{"new sale", nd("20"), "5", nd("0"), nd("5"), []WishlistAlertKind{WishlistAlertOnSale}, true},
+		{"deeper sale", nd("40"), "5", nd("20"), nd("5"), []WishlistAlertKind{WishlistAlertOnSale}, true},
+		{"shallower sale", nd("10"), "5", nd("20"), nd("5"), nil, true},
+		{"sale ended (NULL)", decimal.NullDecimal{}, "5", nd("20"), nd("5"), nil, true},
+		{"restock", nd("0"), "4", nd("0"), nd("0"), []WishlistAlertKind{WishlistAlertRestock}, true},
+		{"restock into low stock is a restock", nd("0"), "1", nd("0"), nd("0"), []WishlistAlertKind{WishlistAlertRestock}, true},
+		{"crossing into low stock", nd("0"), "3", nd("0"), nd("4"), []WishlistAlertKind{WishlistAlertLowStock}, true},
+		{"already low", nd("0"), "1", nd("0"), nd("2"), nil, true},
+		{"sold out is not low stock", nd("0"), "0", nd("0"), nd("5"), nil, true},
+		{"sale and restock together", nd("25"), "6", nd("0"), nd("0"), []WishlistAlertKind{WishlistAlertOnSale, WishlistAlertRestock}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := WishlistWatch{
+				ItemId:             7,
+				SalePercentage:     c.sale,
+				Quantity:           d(c.qty),
+				SeenSalePercentage: c.seenPct,
+				SeenQuantity:       c.seenQty,
+			}
+			alerts, seen, changed := w.Observe(lowStock)
+			if !reflect.DeepEqual(alerts, c.alerts) {
+				t.Fatalf("alerts = %v, want %v", alerts, c.alerts)
+			}
+			if changed != c.changed {
+				t.Fatalf("changed = %v, want %v", changed, c.changed)
+			}
+			if seen.ItemId != 7 || !seen.Quantity.Equal(d(c.qty)) {
+				t.Fatalf("seen = %+v", seen)
+			}
+		})
+	}
+
+	// An ended sale is recorded as zero, so the next sale alerts again.
+	w := WishlistWatch{SalePercentage: decimal.NullDecimal{}, Quantity: d("5"), SeenSalePercentage: nd("20"), SeenQuantity: nd("5")}
+	if _, seen, _ := w.Observe(lowStock); !seen.SalePercentage.IsZero() {
+		t.Fatalf("ended sale recorded as %s, want 0", seen.SalePercentage)
+	}
+}
+
+func TestWishlistWatchKeepUnsent(t *testing.T) {
+	d := decimal.RequireFromString
+	nd := func(s string) decimal.NullDecimal { return decimal.NewNullDecimal(d(s)) }
+	w := WishlistWatch{ItemId: 7, SalePercentage: nd("25"), Quantity: d("6"), SeenSalePercentage: nd("0"), SeenQuantity: nd("0")}
+	alerts, seen, _ := w.Observe(d("3"))
+	if !reflect.DeepEqual(alerts, []WishlistAlertKind{WishlistAlertOnSale, WishlistAlertRestock}) {
+		t.Fatalf("alerts = %v", alerts)
+	}
+
+	// The sale alert went out, the restock did not: the sale is recorded, the stock is not.
+	kept := w.KeepUnsent(seen, alerts[1:])
+	if !kept.SalePercentage.Equal(d("25")) || !kept.Quantity.IsZero() {
+		t.Fatalf("kept = %+v, want sale 25 and the old stock 0", kept)
+	}
+	w.SeenSalePercentage, w.SeenQuantity = nd(kept.SalePercentage.String()), nd(kept.Quantity.String())
+	if again, _, _ := w.Observe(d("3")); !reflect.DeepEqual(again, []WishlistAlertKind{WishlistAlertRestock}) {
+		t.Fatalf("next time alerts = %v, want only the restock", again)
+	}
+
+	// Nothing went out: both are due again.
+	w.SeenSalePercentage, w.SeenQuantity = nd("0"), nd("0")
+	if kept := w.KeepUnsent(seen, alerts); !kept.SalePercentage.IsZero() || !kept.Quantity.IsZero() {
+		t.Fatalf("kept = %+v, want the old state", kept)
+	}
+}
+
+func TestWishlistWatchVisible(t *testing.T) {
+	cases := []struct {
+		tier    StorefrontAccountTier
+		minTier int16
+		hidden  bool
+		want    bool
+	}{
+		{StorefrontAccountTierMember, TierCodePlusPlus, false, true}, // locked teaser: still shown
+		{StorefrontAccountTierMember, TierCodePlusPlus, true, false},
+		{StorefrontAccountTierPlusPlus, TierCodePlusPlus, true, true},
+		{StorefrontAccountTierPlusPlus, TierCodeHacker, true, false},
+		{StorefrontAccountTierHacker, TierCodeHacker, true, true},
+	}
+	for _, c := range cases {
+		w := WishlistWatch{AccountTier: c.tier, MinTier: c.minTier, HiddenForNonQualified: c.hidden}
+		if got := w.Visible(); got != c.want {
+			t.Errorf("tier %s min %d hidden %v: Visible() = %v, want %v", c.tier, c.minTier, c.hidden, got, c.want)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package entity
 
 import (
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -15,7 +18,9 @@ import (
 // on every single раскладка even though it never changes between them; it is a property of the ЦЕХ,
 // so it lives here and the раскладка merely overrides it when a particular lay is spread elsewhere.
 // Ф3.2 (припуск по умолчанию), Ф6.9 (режим гейта готовности) and Ф4.8 (предел высоты стопки) have
-// since moved in, each as its own typed column; 08-cut-out (минимальный зазор) is the next tenant.
+// since moved in, each as its own typed column, and so have the sewing capacity the run scheduler
+// plans against (0342) and the QC gate of the receipt (0343); 08-cut-out (минимальный зазор) is the
+// next tenant.
 type WorkshopSettings struct {
 	// CuttingTableLengthCm is the usable length of the cutting/spreading table, in centimetres.
 	//
@@ -65,6 +70,24 @@ type WorkshopSettings struct {
 	// the check is Material.FabricThicknessMm; either half missing ⇒ UNKNOWN.
 	MaxStackHeightCm decimal.NullDecimal `db:"max_stack_height_cm"`
 
+	// МОЩНОСТЬ ЦЕХА (0342) — five tenants that only mean something together, so they are read
+	// through Capacity() and never one by one. SewingWorkstations and ShiftMinutes ARE the capacity
+	// and keep the house rule: either unset ⇒ no capacity verdict, the scheduler loads runs but
+	// proposes no dates. The other three have an honest default of a workshop that configured
+	// nothing — one shift, Monday to Friday, SMV at face value — and Capacity() is the one place
+	// that default lives.
+	SewingWorkstations    sql.NullInt32       `db:"sewing_workstations"`
+	ShiftMinutes          sql.NullInt32       `db:"shift_minutes"`
+	ShiftsPerDay          sql.NullInt32       `db:"shifts_per_day"`
+	WorkingWeekdays       sql.NullString      `db:"working_weekdays"` // "mon,tue,wed,thu,fri"
+	PlanningEfficiencyPct decimal.NullDecimal `db:"planning_efficiency_pct"`
+
+	// QcInspectionRequired is the QC gate of the production receipt (0343). It keeps the readiness
+	// switch's reading, for the readiness switch's reason: INVALID and false both mean «not
+	// required», because on the day it ships no run has an inspection and «unset ⇒ required» would
+	// stop every receipt in the shop. The receipt reads it under the run lock, not through this struct.
+	QcInspectionRequired sql.NullBool `db:"qc_inspection_required"`
+
 	UpdatedBy string    `db:"updated_by"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
@@ -115,6 +138,17 @@ type WorkshopSettingsPatch struct {
 	// настроено», because a wrong limit fails настилы that are perfectly fine while an unset one
 	// simply declines to judge them.
 	MaxStackHeightCm *decimal.NullDecimal
+	// Capacity (0342), three-state like the decimals: clearing returns a setting to «не настроено»,
+	// which for the workstations and the shift means «no capacity verdict» and for the rest means
+	// their documented default (see WorkshopSettings.Capacity).
+	SewingWorkstations    *sql.NullInt32
+	ShiftMinutes          *sql.NullInt32
+	ShiftsPerDay          *sql.NullInt32
+	WorkingWeekdays       *sql.NullString
+	PlanningEfficiencyPct *decimal.NullDecimal
+	// QcInspectionRequired (0343) is two-state like RunReadinessBlocking, for the same reason: unset
+	// and false behave identically.
+	QcInspectionRequired *bool
 }
 
 // IsEmpty reports whether the patch names no setting at all. Such a request is rejected rather than
@@ -127,7 +161,9 @@ type WorkshopSettingsPatch struct {
 // TestWorkshopSettingsPatchIsEmptyCoversEveryField holds the line by reflection.
 func (p WorkshopSettingsPatch) IsEmpty() bool {
 	return p.CuttingTableLengthCm == nil && p.DefaultSeamAllowanceMm == nil &&
-		p.RunReadinessBlocking == nil && p.MaxStackHeightCm == nil
+		p.RunReadinessBlocking == nil && p.MaxStackHeightCm == nil &&
+		p.SewingWorkstations == nil && p.ShiftMinutes == nil && p.ShiftsPerDay == nil &&
+		p.WorkingWeekdays == nil && p.PlanningEfficiencyPct == nil && p.QcInspectionRequired == nil
 }
 
 // Plausibility band for a cutting/spreading table length, in centimetres.
@@ -218,3 +254,158 @@ func ValidateMaxStackHeightCm(v decimal.NullDecimal) error {
 	}
 	return nil
 }
+
+// Bands for the capacity tenants (0342), repeated verbatim by chk_workshop_settings_capacity — the
+// two must move together. Each ceiling is a unit check rather than a statement about real shops: 720
+// minutes is a twelve-hour shift, and a larger number is hours typed as minutes times something.
+const (
+	MaxSewingWorkstations    = 500
+	MaxShiftMinutes          = 720
+	MaxShiftsPerDay          = 3
+	MaxPlanningEfficiencyPct = 150
+)
+
+// workingWeekdayTokens are the members of the working_weekdays SET, Monday first — the order MySQL
+// returns a SET in, so a canonical value round-trips unchanged.
+var workingWeekdayTokens = [...]struct {
+	token string
+	day   time.Weekday
+}{
+	{"mon", time.Monday}, {"tue", time.Tuesday}, {"wed", time.Wednesday}, {"thu", time.Thursday},
+	{"fri", time.Friday}, {"sat", time.Saturday}, {"sun", time.Sunday},
+}
+
+// DefaultWorkingWeekdays is what an unset working_weekdays means: a five-day week.
+const DefaultWorkingWeekdays = "mon,tue,wed,thu,fri"
+
+// ParseWorkingWeekdays reads a comma-separated list of weekday tokens (mon..sun, any order, any
+// case) into the canonical SET value. An empty list is refused: a workshop with no working day is
+// not a configuration, and «не настроено» is said by clearing the setting.
+func ParseWorkingWeekdays(v string) (string, error) {
+	const field = "working_weekdays"
+	var seen [7]bool
+	for _, raw := range strings.Split(v, ",") {
+		tok := strings.ToLower(strings.TrimSpace(raw))
+		if tok == "" {
+			continue
+		}
+		found := false
+		for i, w := range workingWeekdayTokens {
+			if w.token == tok {
+				seen[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return "", NewFieldViolation(field, "unknown_weekday", raw,
+				"list the working days as mon,tue,wed,thu,fri,sat,sun")
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for i, ok := range seen {
+		if ok {
+			out = append(out, workingWeekdayTokens[i].token)
+		}
+	}
+	if len(out) == 0 {
+		return "", NewFieldViolation(field, "no_working_day", v,
+			"name at least one working day; to fall back to Monday–Friday, clear the setting")
+	}
+	return strings.Join(out, ","), nil
+}
+
+// ValidateCapacityCount checks an incoming workstation, shift-minute or shift count: a whole number
+// in 1..max. Zero is refused rather than stored, for the table length's reason — a zero-minute
+// shift would schedule nothing forever, where an unset one withholds the verdict and says why.
+func ValidateCapacityCount(field string, v sql.NullInt32, max int32) error {
+	if !v.Valid {
+		return nil
+	}
+	if v.Int32 <= 0 {
+		return NewFieldViolation(field, "must_be_positive", strconv.Itoa(int(v.Int32)),
+			"enter a positive number; to record that it is not known, clear the setting instead of entering 0")
+	}
+	if v.Int32 > max {
+		return NewFieldViolation(field, "implausibly_large", strconv.Itoa(int(v.Int32)),
+			fmt.Sprintf("at most %d — check the unit", max))
+	}
+	return nil
+}
+
+// ValidatePlanningEfficiencyPct checks the planning efficiency: (0, 150] with two decimals. Above
+// 100 is legal — an SMV set generously is finished faster than its norm.
+func ValidatePlanningEfficiencyPct(v decimal.NullDecimal) error {
+	const field = "planning_efficiency_pct"
+	if !v.Valid {
+		return nil
+	}
+	if v.Decimal.Exponent() < -2 {
+		return NewFieldViolation(field, "too_many_decimal_places", v.Decimal.String(),
+			"round to at most 2 decimal places — the column stores no more, so the extra digits would be lost silently")
+	}
+	if v.Decimal.LessThanOrEqual(decimal.Zero) || v.Decimal.GreaterThan(decimal.NewFromInt(MaxPlanningEfficiencyPct)) {
+		return NewFieldViolation(field, "out_of_range", v.Decimal.String(),
+			"enter a percentage above 0 and at most 150; clear the setting to take SMVs at face value (100)")
+	}
+	return nil
+}
+
+// WorkshopCapacity is the workshop settings read as sewing capacity — the only way the scheduler
+// reads them, so the defaults exist in one place.
+type WorkshopCapacity struct {
+	// Configured is false while the workstations or the shift length are unset. DailyMinutes is
+	// then zero and MEANS NOTHING: a consumer must withhold dates, not plan against a zero.
+	Configured    bool
+	Workstations  int
+	ShiftMinutes  int
+	ShiftsPerDay  int
+	Weekdays      [7]bool // indexed by time.Weekday
+	EfficiencyPct decimal.Decimal
+	// DailyMinutes is the standard minutes one working day sews: workstations × shifts × shift
+	// minutes × efficiency.
+	DailyMinutes decimal.Decimal
+}
+
+// Capacity reads the settings as capacity, applying the defaults of the three optional tenants.
+func (s *WorkshopSettings) Capacity() WorkshopCapacity {
+	c := WorkshopCapacity{ShiftsPerDay: 1, EfficiencyPct: decimal.NewFromInt(100)}
+	weekdays := DefaultWorkingWeekdays
+	if s != nil {
+		if s.ShiftsPerDay.Valid && s.ShiftsPerDay.Int32 > 0 {
+			c.ShiftsPerDay = int(s.ShiftsPerDay.Int32)
+		}
+		if s.PlanningEfficiencyPct.Valid && s.PlanningEfficiencyPct.Decimal.IsPositive() {
+			c.EfficiencyPct = s.PlanningEfficiencyPct.Decimal
+		}
+		if s.WorkingWeekdays.Valid && strings.TrimSpace(s.WorkingWeekdays.String) != "" {
+			weekdays = s.WorkingWeekdays.String
+		}
+		if s.SewingWorkstations.Valid && s.SewingWorkstations.Int32 > 0 &&
+			s.ShiftMinutes.Valid && s.ShiftMinutes.Int32 > 0 {
+			c.Configured = true
+			c.Workstations = int(s.SewingWorkstations.Int32)
+			c.ShiftMinutes = int(s.ShiftMinutes.Int32)
+		}
+	}
+	for _, tok := range strings.Split(weekdays, ",") {
+		for _, w := range workingWeekdayTokens {
+			if w.token == strings.TrimSpace(tok) {
+				c.Weekdays[w.day] = true
+			}
+		}
+	}
+	if c.Configured {
+		c.DailyMinutes = decimal.NewFromInt(int64(c.Workstations * c.ShiftsPerDay * c.ShiftMinutes)).
+			Mul(c.EfficiencyPct).Div(decimal.NewFromInt(100)).Round(2)
+	}
+	return c
+}
+
+// WorkshopHoliday is a day the workshop is closed (workshop_holiday, 0342): its capacity is zero
+// whatever the weekday says.
+type WorkshopHoliday struct {
+	Day       time.Time      `db:"day"`
+	Note      sql.NullString `db:"note"`
+	CreatedBy string         `db:"created_by"`
+	CreatedAt time.Time      `db:"created_at"`
+}
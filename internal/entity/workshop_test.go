@@ -183,3 +183,14 @@ func TestEffectiveMaxStackHeightCmWithholdsRatherThanZeroes(t *testing.T) {
 	}
 }
 
+func TestParseWorkingWeekdays(t *testing.T) {
+	got, err := ParseWorkingWeekdays(" Sat, mon,tue ,mon")
+	if err != nil || got != "mon,tue,sat" {
+		t.Fatalf("got %q, %v; want the canonical SET value mon,tue,sat", got, err)
+	}
+	for _, in := range []string{"", " , ", "mon,funday"} {
+		if _, err := ParseWorkingWeekdays(in); err == nil {
+			t.Errorf("%q: want a violation", in)
+		}
+	}
+}
@@ -20,6 +20,10 @@ type Taxpayer struct {
 	Email     string // contact email (Podmiot1/OsobaNiefizyczna/Email)
 	Phone     string // optional contact phone (Podmiot1/OsobaNiefizyczna/Telefon)
 	TaxOffice string // 4-digit destination tax-office code (Naglowek/KodUrzedu)
+	// Seller address for the KSeF sales invoice (internal/ksef, Podmiot1/Adres). JPK_V7M(2) has no
+	// address, so Validate does not require it; the invoice generator does.
+	AddressL1 string // street, number, postcode and city (Adres/AdresL1)
+	AddressL2 string // optional second line (Adres/AdresL2)
 }
 
 var (
@@ -0,0 +1,395 @@
+// Package ksef renders sales invoices (entity.SalesInvoiceFull, migration 0337) as KSeF structured
+// e-invoices — the FA(2) schema in force since 2023 and its FA(3) successor — and hands them to a
+// Submitter. It reuses the JPK taxpayer identity as the seller (Podmiot1) and the order's VAT regime
+// as snapshotted on the invoice for the rate buckets (P_13_x / P_14_x) and the row rates (P_12).
+//
+// The element names, their order and the enumerations follow the published FA(2)/FA(3) schemas; the
+// XSDs themselves are not vendored (the build is offline). Validate checks the constraints of those
+// schemas this generator can get wrong — identifiers, dates, enumerations, the totals tying to the
+// rows — so a malformed document fails here rather than at KSeF. The KSeF test environment remains
+// the final word before the first live submission.
+package ksef
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/accounting"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/jpk"
+	"github.com/shopspring/decimal"
+)
+
+// Schema selects the FA variant to render.
+type Schema string
+
+const (
+	// SchemaFA2 is FA(2), mandatory in KSeF until FA(3) replaces it.
+	SchemaFA2 Schema = "FA(2)"
+	// SchemaFA3 is FA(3): adds the buyer's JST/GV flags and splits the 0% rate by kind ("0 WDT", "0 EX").
+	SchemaFA3 Schema = "FA(3)"
+)
+
+type schemaInfo struct {
+	namespace    string
+	kodSystemowy string
+	wariant      int
+}
+
+var schemas = map[Schema]schemaInfo{
+	SchemaFA2: {namespace: "http://crd.gov.pl/wzor/2023/06/29/12648/", kodSystemowy: "FA (2)", wariant: 2},
+	SchemaFA3: {namespace: "http://crd.gov.pl/wzor/2025/06/25/13775/", kodSystemowy: "FA (3)", wariant: 3},
+}
+
+// systemInfo identifies the producing system in the header — informational, not validated.
+const systemInfo = "GRBPWR"
+
+// Faktura is the KSeF structured invoice.
+type Faktura struct {
+	XMLName  xml.Name `xml:"Faktura"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Naglowek Naglowek `xml:"Naglowek"`
+	Podmiot1 Podmiot1 `xml:"Podmiot1"`
+	Podmiot2 Podmiot2 `xml:"Podmiot2"`
+	Fa       Fa       `xml:"Fa"`
+}
+
+type Naglowek struct {
+	KodFormularza     KodFormularza `xml:"KodFormularza"`
+	WariantFormularza int           `xml:"WariantFormularza"`
+	DataWytworzeniaFa string        `xml:"DataWytworzeniaFa"`
+	SystemInfo        string        `xml:"SystemInfo"`
+}
+
+// KodFormularza carries the form code with its system-code / schema-version attributes.
+type KodFormularza struct {
+	KodSystemowy string `xml:"kodSystemowy,attr"`
+	WersjaSchemy string `xml:"wersjaSchemy,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type Adres struct {
+	KodKraju string `xml:"KodKraju"`
+	AdresL1  string `xml:"AdresL1"`
+	AdresL2  string `xml:"AdresL2,omitempty"`
+}
+
+type DaneKontaktowe struct {
+	Email   string `xml:"Email,omitempty"`
+	Telefon string `xml:"Telefon,omitempty"`
+}
+
+// Podmiot1 is the seller: the JPK taxpayer.
+type Podmiot1 struct {
+	DaneIdentyfikacyjne DaneSprzedawcy  `xml:"DaneIdentyfikacyjne"`
+	Adres               Adres           `xml:"Adres"`
+	DaneKontaktowe      *DaneKontaktowe `xml:"DaneKontaktowe,omitempty"`
+}
+
+type DaneSprzedawcy struct {
+	NIP   string `xml:"NIP"`
+	Nazwa string `xml:"Nazwa"`
+}
+
+// Podmiot2 is the buyer. Exactly one identification is set: a Polish NIP, an EU VAT number
+// (KodUE + NrVatUE), a third-country tax id (KodKraju + NrID), or BrakID for a consumer. JST and GV
+// are FA(3)-only and always 2 ("no") here: the store never sells to a local-government unit or a VAT
+// group member.
+type Podmiot2 struct {
+	DaneIdentyfikacyjne DaneNabywcy `xml:"DaneIdentyfikacyjne"`
+	Adres               *Adres      `xml:"Adres,omitempty"`
+	JST                 int         `xml:"JST,omitempty"`
+	GV                  int         `xml:"GV,omitempty"`
+}
+
+type DaneNabywcy struct {
+	NIP      string `xml:"NIP,omitempty"`
+	KodUE    string `xml:"KodUE,omitempty"`
+	NrVatUE  string `xml:"NrVatUE,omitempty"`
+	KodKraju string `xml:"KodKraju,omitempty"`
+	NrID     string `xml:"NrID,omitempty"`
+	BrakID   int    `xml:"BrakID,omitempty"`
+	Nazwa    string `xml:"Nazwa"`
+}
+
+// Fa is the invoice body. Only the rate buckets a sale of this store can produce are modelled:
+// 23/8/5% domestic, OSS, and the two 0% supplies (WDT, export). The *W amounts are the VAT in PLN on
+// a foreign-currency invoice.
+type Fa struct {
+	KodWaluty  string             `xml:"KodWaluty"`
+	P1         string             `xml:"P_1"`
+	P2         string             `xml:"P_2"`
+	P6         string             `xml:"P_6,omitempty"`
+	P13_1      string             `xml:"P_13_1,omitempty"`
+	P14_1      string             `xml:"P_14_1,omitempty"`
+	P14_1W     string             `xml:"P_14_1W,omitempty"`
+	P13_2      string             `xml:"P_13_2,omitempty"`
+	P14_2      string             `xml:"P_14_2,omitempty"`
+	P14_2W     string             `xml:"P_14_2W,omitempty"`
+	P13_3      string             `xml:"P_13_3,omitempty"`
+	P14_3      string             `xml:"P_14_3,omitempty"`
+	P14_3W     string             `xml:"P_14_3W,omitempty"`
+	P13_5      string             `xml:"P_13_5,omitempty"`
+	P14_5      string             `xml:"P_14_5,omitempty"`
+	P13_6_2    string             `xml:"P_13_6_2,omitempty"`
+	P13_6_3    string             `xml:"P_13_6_3,omitempty"`
+	P15        string             `xml:"P_15"`
+	Adnotacje  Adnotacje          `xml:"Adnotacje"`
+	Rodzaj     string             `xml:"RodzajFaktury"`
+	Przyczyna  string             `xml:"PrzyczynaKorekty,omitempty"`
+	TypKorekty int                `xml:"TypKorekty,omitempty"`
+	Korygowana *DaneFaKorygowanej `xml:"DaneFaKorygowanej,omitempty"`
+	FaWiersz   []FaWiersz         `xml:"FaWiersz"`
+}
+
+// Adnotacje are the mandatory annotations; every flag is "no" (2) / "not applicable" (1) for a
+// plain sale: no cash accounting, self-billing, reverse charge, split payment, exemption, new means
+// of transport, simplified triangulation or margin scheme.
+type Adnotacje struct {
+	P16                  int                  `xml:"P_16"`
+	P17                  int                  `xml:"P_17"`
+	P18                  int                  `xml:"P_18"`
+	P18A                 int                  `xml:"P_18A"`
+	Zwolnienie           Zwolnienie           `xml:"Zwolnienie"`
+	NoweSrodkiTransportu NoweSrodkiTransportu `xml:"NoweSrodkiTransportu"`
+	P23                  int                  `xml:"P_23"`
+	PMarzy               PMarzy               `xml:"PMarzy"`
+}
+
+type Zwolnienie struct {
+	P19N int `xml:"P_19N"`
+}
+
+type NoweSrodkiTransportu struct {
+	P22N int `xml:"P_22N"`
+}
+
+type PMarzy struct {
+	PMarzyN int `xml:"P_PMarzyN"`
+}
+
+// DaneFaKorygowanej names the corrected invoice: its date, number and — when it went through KSeF —
+// its KSeF number; otherwise NrKSeFN marks an invoice issued outside KSeF.
+type DaneFaKorygowanej struct {
+	Data     string `xml:"DataWystFaKorygowanej"`
+	Nr       string `xml:"NrFaKorygowanej"`
+	NrKSeF   int    `xml:"NrKSeF,omitempty"`
+	NrKSeFFa string `xml:"NrKSeFFaKorygowanej,omitempty"`
+	NrKSeFN  int    `xml:"NrKSeFN,omitempty"`
+}
+
+// FaWiersz is one invoice row, priced gross (P_9B / P_11A) as the invoice is computed from gross.
+// P_12 is the rate for a domestic or zero-rated row; an OSS row carries its foreign rate in P_12_XII
+// instead.
+type FaWiersz struct {
+	Nr     int    `xml:"NrWierszaFa"`
+	P7     string `xml:"P_7"`
+	P8A    string `xml:"P_8A"`
+	P8B    string `xml:"P_8B"`
+	P9B    string `xml:"P_9B"`
+	P11A   string `xml:"P_11A"`
+	P12    string `xml:"P_12,omitempty"`
+	P12XII string `xml:"P_12_XII,omitempty"`
+}
+
+// Generate renders an invoice as FA(2) or FA(3) XML, validated before it is returned. The taxpayer is
+// validated as for JPK and must also carry the seller address.
+func Generate(taxpayer jpk.Taxpayer, inv *entity.SalesInvoiceFull, schema Schema, generatedAt time.Time) ([]byte, error) {
+	doc, err := Build(taxpayer, inv, schema, generatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(doc, schema); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("ksef: encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Build maps an invoice onto the FA document without validating it.
+func Build(taxpayer jpk.Taxpayer, inv *entity.SalesInvoiceFull, schema Schema, generatedAt time.Time) (*Faktura, error) {
+	info, ok := schemas[schema]
+	if !ok {
+		return nil, fmt.Errorf("ksef: unknown schema %q", schema)
+	}
+	if err := taxpayer.Validate(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(taxpayer.AddressL1) == "" {
+		return nil, fmt.Errorf("ksef: the seller address (JPK_ADDRESS_L1) is required on an invoice")
+	}
+	if inv == nil {
+		return nil, fmt.Errorf("ksef: nil invoice")
+	}
+
+	doc := &Faktura{
+		Xmlns: info.namespace,
+		Naglowek: Naglowek{
+			KodFormularza:     KodFormularza{KodSystemowy: info.kodSystemowy, WersjaSchemy: "1-0E", Value: "FA"},
+			WariantFormularza: info.wariant,
+			DataWytworzeniaFa: generatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			SystemInfo:        systemInfo,
+		},
+		Podmiot1: Podmiot1{
+			DaneIdentyfikacyjne: DaneSprzedawcy{NIP: strings.TrimSpace(taxpayer.NIP), Nazwa: taxpayer.FullName},
+			Adres:               Adres{KodKraju: "PL", AdresL1: taxpayer.AddressL1, AdresL2: taxpayer.AddressL2},
+		},
+		Podmiot2: buyer(inv.SalesInvoice),
+	}
+	if taxpayer.Email != "" || taxpayer.Phone != "" {
+		doc.Podmiot1.DaneKontaktowe = &DaneKontaktowe{Email: taxpayer.Email, Telefon: taxpayer.Phone}
+	}
+	if schema == SchemaFA3 {
+		doc.Podmiot2.JST, doc.Podmiot2.GV = 2, 2
+	}
+
+	fa, err := body(inv, schema)
+	if err != nil {
+		return nil, err
+	}
+	doc.Fa = *fa
+	return doc, nil
+}
+
+// buyer identifies the buyer from the VAT id snapshotted on the invoice: a PL-prefixed (or bare,
+// for a Polish buyer) id is a NIP, an EU prefix is an EU VAT number, anything else a foreign tax id.
+// A consumer has none (BrakID).
+func buyer(inv entity.SalesInvoice) Podmiot2 {
+	p := Podmiot2{DaneIdentyfikacyjne: DaneNabywcy{Nazwa: inv.BuyerName}}
+	if inv.BuyerAddress != "" {
+		p.Adres = &Adres{KodKraju: inv.BuyerCountry, AdresL1: inv.BuyerAddress}
+	}
+	id := strings.ToUpper(strings.Join(strings.Fields(inv.BuyerVatId.String), ""))
+	if !inv.BuyerVatId.Valid || id == "" {
+		p.DaneIdentyfikacyjne.BrakID = 1
+		return p
+	}
+	prefix := ""
+	if len(id) > 2 && isLetter(id[0]) && isLetter(id[1]) {
+		prefix = id[:2]
+	}
+	switch {
+	case prefix == "PL" || (prefix == "" && inv.BuyerCountry == "PL"):
+		p.DaneIdentyfikacyjne.NIP = strings.TrimPrefix(id, "PL")
+	case prefix == "EL" || prefix == "XI" || (prefix != "" && accounting.IsEUCountry(prefix)):
+		p.DaneIdentyfikacyjne.KodUE = prefix
+		p.DaneIdentyfikacyjne.NrVatUE = id[2:]
+	default:
+		p.DaneIdentyfikacyjne.KodKraju = inv.BuyerCountry
+		p.DaneIdentyfikacyjne.NrID = id
+	}
+	return p
+}
+
+func isLetter(c byte) bool { return c >= 'A' && c <= 'Z' }
+
+// body fills the invoice body: the single rate bucket the invoice's regime puts it in, the rows and,
+// on a correction, the reference to the corrected invoice.
+func body(inv *entity.SalesInvoiceFull, schema Schema) (*Fa, error) {
+	fa := &Fa{
+		KodWaluty: strings.ToUpper(inv.Currency),
+		P1:        inv.IssueDate.Format(dateLayout),
+		P2:        inv.Number,
+		P15:       inv.Gross.StringFixed(2),
+		Adnotacje: Adnotacje{P16: 2, P17: 2, P18: 2, P18A: 2, Zwolnienie: Zwolnienie{P19N: 1},
+			NoweSrodkiTransportu: NoweSrodkiTransportu{P22N: 1}, P23: 2, PMarzy: PMarzy{PMarzyN: 1}},
+		Rodzaj: "VAT",
+	}
+	if !inv.SaleDate.Equal(inv.IssueDate) {
+		fa.P6 = inv.SaleDate.Format(dateLayout)
+	}
+
+	net, vat := inv.Net.StringFixed(2), inv.Vat.StringFixed(2)
+	vatPln := ""
+	if fa.KodWaluty != "PLN" && !inv.Vat.IsZero() {
+		if !inv.PlnRate.Valid || !inv.PlnRate.Decimal.IsPositive() {
+			return nil, fmt.Errorf("ksef: invoice %s in %s has no PLN rate for its VAT", inv.Number, fa.KodWaluty)
+		}
+		vatPln = inv.Vat.Mul(inv.PlnRate.Decimal).StringFixed(2)
+	}
+	rate := inv.VatRatePct
+	p12, p12XII := "", ""
+	switch inv.VatRegime {
+	case entity.VatRegimePLDomestic:
+		switch {
+		case rate.Equal(decimal.NewFromInt(23)):
+			fa.P13_1, fa.P14_1, fa.P14_1W = net, vat, vatPln
+		case rate.Equal(decimal.NewFromInt(8)):
+			fa.P13_2, fa.P14_2, fa.P14_2W = net, vat, vatPln
+		case rate.Equal(decimal.NewFromInt(5)):
+			fa.P13_3, fa.P14_3, fa.P14_3W = net, vat, vatPln
+		default:
+			return nil, fmt.Errorf("ksef: %s%% is not a Polish VAT rate (invoice %s)", rate, inv.Number)
+		}
+		p12 = rate.String()
+	case entity.VatRegimeOSS:
+		fa.P13_5, fa.P14_5 = net, vat
+		p12XII = rate.String()
+	case entity.VatRegimeWDT:
+		fa.P13_6_2 = net
+		p12 = zeroRate(schema, "WDT")
+	case entity.VatRegimeExport:
+		fa.P13_6_3 = net
+		p12 = zeroRate(schema, "EX")
+	default:
+		return nil, fmt.Errorf("ksef: invoice %s has regime %q, which is not invoiced through KSeF", inv.Number, inv.VatRegime)
+	}
+	if inv.Kind == entity.SalesInvoiceKindCorrection {
+		if !inv.CorrectsNumber.Valid || !inv.CorrectsIssueDate.Valid {
+			return nil, fmt.Errorf("ksef: correction %s does not name the invoice it corrects", inv.Number)
+		}
+		fa.Rodzaj = "KOR"
+		fa.Przyczyna = inv.Reason.String
+		fa.TypKorekty = 2 // effective in the correction's own period (a refund)
+		fa.Korygowana = &DaneFaKorygowanej{
+			Data: inv.CorrectsIssueDate.Time.Format(dateLayout),
+			Nr:   inv.CorrectsNumber.String,
+		}
+		if inv.CorrectsKsefRef.Valid && inv.CorrectsKsefRef.String != "" {
+			fa.Korygowana.NrKSeF, fa.Korygowana.NrKSeFFa = 1, inv.CorrectsKsefRef.String
+		} else {
+			fa.Korygowana.NrKSeFN = 1
+		}
+	}
+
+	for _, l := range inv.Lines {
+		fa.FaWiersz = append(fa.FaWiersz, FaWiersz{
+			Nr:     l.Position,
+			P7:     l.Name,
+			P8A:    unit(l.Kind),
+			P8B:    l.Quantity.String(),
+			P9B:    l.UnitGross.StringFixed(2),
+			P11A:   l.Gross.StringFixed(2),
+			P12:    p12,
+			P12XII: p12XII,
+		})
+	}
+	return fa, nil
+}
+
+// zeroRate is the P_12 code of a 0% supply: FA(2) has a single "0", FA(3) names the kind.
+func zeroRate(schema Schema, kind string) string {
+	if schema == SchemaFA3 {
+		return "0 " + kind
+	}
+	return "0"
+}
+
+// unit is the P_8A measure of a row.
+func unit(kind entity.SalesInvoiceLineKind) string {
+	if kind == entity.SalesInvoiceLineItem {
+		return "szt."
+	}
+	return "usł."
+}
+
+const dateLayout = "2006-01-02"
@@ -0,0 +1,249 @@
+package ksef
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/jpk"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+var (
+	gen    = time.Date(2026, 8, 3, 9, 30, 0, 0, time.UTC)
+	seller = jpk.Taxpayer{NIP: "1234563218", FullName: "GRBPWR sp. z o.o.", Email: "vat@grbpwr.com",
+		TaxOffice: "1471", AddressL1: "ul. Prosta 1, 00-838 Warszawa"}
+)
+
+// domestic is a B2C invoice at 23%: a coat and shipping, 240.00 gross.
+func domestic() *entity.SalesInvoiceFull {
+	return &entity.SalesInvoiceFull{
+		SalesInvoice: entity.SalesInvoice{
+			Number: "FV/2026/07/00001", Kind: entity.SalesInvoiceKindInvoice,
+			IssueDate: time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC), SaleDate: time.Date(2026, 7, 30, 0, 0, 0, 0, time.UTC),
+			Currency: "PLN", VatRegime: entity.VatRegimePLDomestic, VatRatePct: d("23"),
+			BuyerName: "Jan Kowalski", BuyerAddress: "ul. Długa 5, 31-147 Kraków", BuyerCountry: "PL",
+			Net: d("195.12"), Vat: d("44.88"), Gross: d("240"),
+		},
+		Lines: []entity.SalesInvoiceLine{
+			{Position: 1, Kind: entity.SalesInvoiceLineItem, Name: "coat", Quantity: d("1"), UnitGross: d("225"),
+				Gross: d("225"), Vat: d("42.07"), Net: d("182.93")},
+			{Position: 2, Kind: entity.SalesInvoiceLineShipping, Name: "Shipping", Quantity: d("1"), UnitGross: d("15"),
+				Gross: d("15"), Vat: d("2.81"), Net: d("12.19")},
+		},
+	}
+}
+
+func TestGenerateFA2(t *testing.T) {
+	out, err := Generate(seller, domestic(), SchemaFA2, gen)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	var back Faktura
+	if err := xml.Unmarshal(out, &back); err != nil {
+		t.Fatalf("generated XML does not parse: %v", err)
+	}
+	if err := Validate(&back, SchemaFA2); err != nil {
+		t.Fatalf("parsed document no longer validates: %v", err)
+	}
+	s := string(out)
+	for _, want := range []string{
+		`<Faktura xmlns="http://crd.gov.pl/wzor/2023/06/29/12648/">`,
+		`<KodFormularza kodSystemowy="FA (2)" wersjaSchemy="1-0E">FA</KodFormularza>`,
+		"<WariantFormularza>2</WariantFormularza>",
+		"<NIP>1234563218</NIP>",
+		"<BrakID>1</BrakID>",
+		"<P_1>2026-07-31</P_1>",
+		"<P_2>FV/2026/07/00001</P_2>",
+		"<P_6>2026-07-30</P_6>",
+		"<P_13_1>195.12</P_13_1>",
+		"<P_14_1>44.88</P_14_1>",
+		"<P_15>240.00</P_15>",
+		"<RodzajFaktury>VAT</RodzajFaktury>",
+		"<P_12>23</P_12>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("FA(2) XML missing %q", want)
+		}
+	}
+	for _, absent := range []string{"<JST>", "<GV>", "P_14_1W", "DaneFaKorygowanej"} {
+		if strings.Contains(s, absent) {
+			t.Errorf("FA(2) XML has %q", absent)
+		}
+	}
+
+	if _, err := Generate(jpk.Taxpayer{NIP: "bad"}, domestic(), SchemaFA2, gen); err == nil {
+		t.Error("Generate accepted an invalid taxpayer")
+	}
+	noAddress := seller
+	noAddress.AddressL1 = ""
+	if _, err := Generate(noAddress, domestic(), SchemaFA2, gen); err == nil {
+		t.Error("Generate accepted a seller without an address")
+	}
+}
+
+func TestGenerateFA3WdtInEuro(t *testing.T) {
+	inv := domestic()
+	inv.Currency = "EUR"
+	inv.VatRegime, inv.VatRatePct = entity.VatRegimeWDT, decimal.Zero
+	inv.BuyerName, inv.BuyerCountry = "Mode GmbH", "DE"
+	inv.BuyerVatId = sql.NullString{String: "DE 123456789", Valid: true}
+	inv.Net, inv.Vat = d("240"), decimal.Zero
+	for i := range inv.Lines {
+		inv.Lines[i].Net, inv.Lines[i].Vat = inv.Lines[i].Gross, decimal.Zero
+	}
+
+	out, err := Generate(seller, inv, SchemaFA3, gen)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	s := string(out)
+	for _, want := range []string{
+		`xmlns="http://crd.gov.pl/wzor/2025/06/25/13775/"`,
+		`kodSystemowy="FA (3)"`,
+		"<KodUE>DE</KodUE>",
+		"<NrVatUE>123456789</NrVatUE>",
+		"<JST>2</JST>",
+		"<GV>2</GV>",
+		"<KodWaluty>EUR</KodWaluty>",
+		"<P_13_6_2>240.00</P_13_6_2>",
+		"<P_12>0 WDT</P_12>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("FA(3) XML missing %q", want)
+		}
+	}
+	// The same invoice in FA(2) has the single zero rate.
+	out, err = Generate(seller, inv, SchemaFA2, gen)
+	if err != nil {
+		t.Fatalf("Generate FA(2): %v", err)
+	}
+	if !strings.Contains(string(out), "<P_12>0</P_12>") {
+		t.Error("FA(2) zero rate is not \"0\"")
+	}
+}
+
+func TestGenerateForeignCurrencyVatInPln(t *testing.T) {
+	inv := domestic()
+	inv.Currency = "EUR"
+	if _, err := Generate(seller, inv, SchemaFA2, gen); err == nil {
+		t.Fatal("a EUR invoice with VAT and no PLN rate was generated")
+	}
+	inv.PlnRate = decimal.NullDecimal{Decimal: d("4.2512"), Valid: true}
+	out, err := Generate(seller, inv, SchemaFA2, gen)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	// 44.88 × 4.2512 = 190.793856 → 190.79.
+	if !strings.Contains(string(out), "<P_14_1W>190.79</P_14_1W>") {
+		t.Errorf("missing P_14_1W in\n%s", out)
+	}
+}
+
+func TestGenerateCorrection(t *testing.T) {
+	inv := domestic()
+	inv.Kind = entity.SalesInvoiceKindCorrection
+	inv.Number = "FK/2026/08/00001"
+	inv.Reason = sql.NullString{String: "Zwrot towaru", Valid: true}
+	inv.CorrectsNumber = sql.NullString{String: "FV/2026/07/00001", Valid: true}
+	inv.CorrectsIssueDate = sql.NullTime{Time: time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC), Valid: true}
+	inv.Net, inv.Vat, inv.Gross = d("-182.93"), d("-42.07"), d("-225")
+	inv.Lines = []entity.SalesInvoiceLine{{Position: 1, Kind: entity.SalesInvoiceLineItem, Name: "coat",
+		Quantity: d("-1"), UnitGross: d("225"), Gross: d("-225"), Vat: d("-42.07"), Net: d("-182.93")}}
+
+	out, err := Generate(seller, inv, SchemaFA2, gen)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	s := string(out)
+	for _, want := range []string{
+		"<RodzajFaktury>KOR</RodzajFaktury>",
+		"<PrzyczynaKorekty>Zwrot towaru</PrzyczynaKorekty>",
+		"<NrFaKorygowanej>FV/2026/07/00001</NrFaKorygowanej>",
+		"<NrKSeFN>1</NrKSeFN>",
+		"<P_8B>-1</P_8B>",
+		"<P_15>-225.00</P_15>",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("KOR XML missing %q", want)
+		}
+	}
+
+	inv.CorrectsKsefRef = sql.NullString{String: "1234563218-20260731-0A1B2C-3D4E5F-6A", Valid: true}
+	out, err = Generate(seller, inv, SchemaFA2, gen)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if s := string(out); !strings.Contains(s, "<NrKSeF>1</NrKSeF>") || strings.Contains(s, "NrKSeFN") {
+		t.Errorf("a KSeF-numbered original must be quoted by its KSeF number:\n%s", s)
+	}
+
+	inv.CorrectsNumber = sql.NullString{}
+	if _, err := Generate(seller, inv, SchemaFA2, gen); err == nil {
+		t.Error("a correction without the corrected invoice was generated")
+	}
+}
+
+func TestBuyerIdentification(t *testing.T) {
+	cases := []struct {
+		vatID, country string
+		want           DaneNabywcy
+	}{
+		{"", "PL", DaneNabywcy{BrakID: 1}},
+		{"PL1234563218", "PL", DaneNabywcy{NIP: "1234563218"}},
+		{"1234563218", "PL", DaneNabywcy{NIP: "1234563218"}},
+		{"el094259216", "GR", DaneNabywcy{KodUE: "EL", NrVatUE: "094259216"}},
+		{"GB123456789", "GB", DaneNabywcy{KodKraju: "GB", NrID: "GB123456789"}},
+		{"12-3456789", "US", DaneNabywcy{KodKraju: "US", NrID: "12-3456789"}},
+	}
+	for _, c := range cases {
+		got := buyer(entity.SalesInvoice{BuyerVatId: sql.NullString{String: c.vatID, Valid: c.vatID != ""}, BuyerCountry: c.country}).DaneIdentyfikacyjne
+		if got != c.want {
+			t.Errorf("%q/%s: got %+v, want %+v", c.vatID, c.country, got, c.want)
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	build := func() *Faktura {
+		f, err := Build(seller, domestic(), SchemaFA3, gen)
+		if err != nil {
+			t.Fatalf("Build: %v", err)
+		}
+		return f
+	}
+	if err := Validate(build(), SchemaFA3); err != nil {
+		t.Fatalf("valid document rejected: %v", err)
+	}
+	cases := map[string]func(f *Faktura){
+		"wrong schema header":   func(f *Faktura) { f.Naglowek.WariantFormularza = 2 },
+		"two buyer ids":         func(f *Faktura) { f.Podmiot2.DaneIdentyfikacyjne.NIP = "1234563218" },
+		"missing GV":            func(f *Faktura) { f.Podmiot2.GV = 0 },
+		"FA(2) rate in FA(3)":   func(f *Faktura) { f.Fa.FaWiersz[0].P12 = "0" },
+		"both rate fields":      func(f *Faktura) { f.Fa.FaWiersz[0].P12XII = "19" },
+		"P_15 off the buckets":  func(f *Faktura) { f.Fa.P15 = "241.00" },
+		"rows off P_15":         func(f *Faktura) { f.Fa.FaWiersz[1].P11A = "16.00" },
+		"three decimals":        func(f *Faktura) { f.Fa.P14_1 = "44.880" },
+		"bad issue date":        func(f *Faktura) { f.Fa.P1 = "31.07.2026" },
+		"rows out of order":     func(f *Faktura) { f.Fa.FaWiersz[1].Nr = 3 },
+		"KOR without reference": func(f *Faktura) { f.Fa.Rodzaj = "KOR"; f.Fa.TypKorekty = 2 },
+		"PLN with W amount":     func(f *Faktura) { f.Fa.P14_1W = "44.88" },
+		"empty rows":            func(f *Faktura) { f.Fa.FaWiersz = nil },
+	}
+	for name, mutate := range cases {
+		f := build()
+		mutate(f)
+		if err := Validate(f, SchemaFA3); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	// An FA(3) document is not an FA(2) one.
+	if err := Validate(build(), SchemaFA2); err == nil {
+		t.Error("FA(3) document validated as FA(2)")
+	}
+}
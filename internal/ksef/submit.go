@@ -0,0 +1,78 @@
+package ksef
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Submission is what KSeF (or the offline stub) returned for a sent invoice.
+type Submission struct {
+	// Reference is the KSeF number the invoice is known by from now on; a correction quotes it in
+	// NrKSeFFaKorygowanej.
+	Reference string
+	// Hash is the SHA-256 of the document, base64url — the value the invoice's verification QR code
+	// carries in offline mode.
+	Hash        string
+	SubmittedAt time.Time
+	// Offline marks a reference issued by the stub, not by KSeF.
+	Offline bool
+}
+
+// Submitter sends an invoice document to KSeF.
+type Submitter interface {
+	Submit(ctx context.Context, nip string, document []byte, issuedAt time.Time) (Submission, error)
+}
+
+// OfflineSubmitter is the stand-in until the KSeF API session (token, encryption, UPO polling) is
+// wired up: it submits nothing and issues a deterministic reference in the shape of a KSeF number —
+// NIP-YYYYMMDD-<12 hex of the hash>-<2 hex check> — prefixed "OFFLINE-" so it can never be taken for
+// a real one. The same document always gets the same reference, so a retried call is harmless.
+type OfflineSubmitter struct {
+	Now func() time.Time
+}
+
+// NewOfflineSubmitter returns an offline submitter stamping the current time.
+func NewOfflineSubmitter() *OfflineSubmitter {
+	return &OfflineSubmitter{Now: time.Now}
+}
+
+// Submit hashes the document and returns the offline reference.
+func (o *OfflineSubmitter) Submit(_ context.Context, nip string, document []byte, issuedAt time.Time) (Submission, error) {
+	nip = strings.TrimSpace(nip)
+	if !nipShape.MatchString(nip) {
+		return Submission{}, fmt.Errorf("ksef: seller NIP %q is not 10 digits", nip)
+	}
+	if len(document) == 0 {
+		return Submission{}, fmt.Errorf("ksef: empty document")
+	}
+	sum := sha256.Sum256(document)
+	id := strings.ToUpper(hex.EncodeToString(sum[:6]))
+	ref := fmt.Sprintf("%s-%s-%s-%s", nip, issuedAt.Format("20060102"), id[:6], id[6:])
+	return Submission{
+		Reference:   fmt.Sprintf("OFFLINE-%s-%02X", ref, crc8(ref)),
+		Hash:        base64.RawURLEncoding.EncodeToString(sum[:]),
+		SubmittedAt: o.Now().UTC(),
+		Offline:     true,
+	}, nil
+}
+
+// crc8 is the CRC-8 (polynomial 0x07) KSeF numbers end with.
+func crc8(s string) byte {
+	var crc byte
+	for i := 0; i < len(s); i++ {
+		crc ^= s[i]
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,42 @@
+package ksef
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestOfflineSubmitter(t *testing.T) {
+	now := time.Date(2026, 8, 3, 10, 0, 0, 0, time.UTC)
+	sub := &OfflineSubmitter{Now: func() time.Time { return now }}
+	issued := time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)
+	doc := []byte("<Faktura/>")
+
+	got, err := sub.Submit(context.Background(), "1234563218", doc, issued)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if !regexp.MustCompile(`^OFFLINE-1234563218-20260731-[0-9A-F]{6}-[0-9A-F]{6}-[0-9A-F]{2}$`).MatchString(got.Reference) {
+		t.Errorf("reference %q is not KSeF-shaped", got.Reference)
+	}
+	if !got.Offline || !got.SubmittedAt.Equal(now) || len(got.Hash) != 43 {
+		t.Errorf("submission = %+v", got)
+	}
+
+	again, _ := sub.Submit(context.Background(), "1234563218", doc, issued)
+	if again.Reference != got.Reference || again.Hash != got.Hash {
+		t.Error("the same document got a different reference")
+	}
+	other, _ := sub.Submit(context.Background(), "1234563218", []byte("<Faktura></Faktura>"), issued)
+	if other.Reference == got.Reference {
+		t.Error("a different document got the same reference")
+	}
+
+	if _, err := sub.Submit(context.Background(), "123", doc, issued); err == nil {
+		t.Error("Submit accepted a malformed NIP")
+	}
+	if _, err := sub.Submit(context.Background(), "1234563218", nil, issued); err == nil {
+		t.Error("Submit accepted an empty document")
+	}
+}
@@ -0,0 +1,249 @@
+package ksef
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/accounting"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	nipShape      = regexp.MustCompile(`^[1-9][0-9]{9}$`)
+	countryShape  = regexp.MustCompile(`^[A-Z]{2}$`)
+	currencyShape = regexp.MustCompile(`^[A-Z]{3}$`)
+	// amountShape is TKwotowy: up to 16 integer digits and 2 decimals, signed on corrections.
+	amountShape = regexp.MustCompile(`^-?[0-9]{1,16}(\.[0-9]{1,2})?$`)
+	// quantityShape is TIlosci: up to 16 integer digits and 6 decimals.
+	quantityShape = regexp.MustCompile(`^-?[0-9]{1,16}(\.[0-9]{1,6})?$`)
+)
+
+// rowRates are the P_12 enumerations (TStawkaPodatku) of the two schemas.
+var rowRates = map[Schema]map[string]bool{
+	SchemaFA2: {"23": true, "22": true, "8": true, "7": true, "5": true, "4": true, "3": true,
+		"0": true, "zw": true, "oo": true, "np": true},
+	SchemaFA3: {"23": true, "22": true, "8": true, "7": true, "5": true, "4": true, "3": true,
+		"0 KR": true, "0 WDT": true, "0 EX": true, "zw": true, "oo": true, "np I": true, "np II": true},
+}
+
+// Validate checks a document against the FA(2)/FA(3) constraints this generator relies on: the form
+// header of the schema, identifier and date formats, exactly one buyer identification, the P_12
+// enumeration, the correction reference, and that P_15 equals both the rate buckets and the rows.
+// It reports every problem at once.
+func Validate(f *Faktura, schema Schema) error {
+	info, ok := schemas[schema]
+	if !ok {
+		return fmt.Errorf("ksef: unknown schema %q", schema)
+	}
+	var errs []string
+	bad := func(format string, args ...any) { errs = append(errs, fmt.Sprintf(format, args...)) }
+
+	if f.Xmlns != info.namespace || f.Naglowek.KodFormularza.KodSystemowy != info.kodSystemowy ||
+		f.Naglowek.WariantFormularza != info.wariant || f.Naglowek.KodFormularza.Value != "FA" {
+		bad("header is not %s", schema)
+	}
+	if _, err := time.Parse("2006-01-02T15:04:05Z", f.Naglowek.DataWytworzeniaFa); err != nil {
+		bad("DataWytworzeniaFa %q is not a UTC timestamp", f.Naglowek.DataWytworzeniaFa)
+	}
+
+	if !nipShape.MatchString(f.Podmiot1.DaneIdentyfikacyjne.NIP) {
+		bad("seller NIP %q is not 10 digits", f.Podmiot1.DaneIdentyfikacyjne.NIP)
+	}
+	checkText(bad, "seller Nazwa", f.Podmiot1.DaneIdentyfikacyjne.Nazwa, 512)
+	checkAddress(bad, "seller", &f.Podmiot1.Adres)
+
+	b := f.Podmiot2.DaneIdentyfikacyjne
+	ids := 0
+	if b.NIP != "" {
+		ids++
+		if !nipShape.MatchString(b.NIP) {
+			bad("buyer NIP %q is not 10 digits", b.NIP)
+		}
+	}
+	if b.KodUE != "" || b.NrVatUE != "" {
+		ids++
+		if !(b.KodUE == "EL" || b.KodUE == "XI" || accounting.IsEUCountry(b.KodUE)) || b.KodUE == "PL" {
+			bad("buyer KodUE %q is not another EU member state", b.KodUE)
+		}
+		checkText(bad, "buyer NrVatUE", b.NrVatUE, 30)
+	}
+	if b.KodKraju != "" || b.NrID != "" {
+		ids++
+		if !countryShape.MatchString(b.KodKraju) {
+			bad("buyer KodKraju %q is not an ISO country code", b.KodKraju)
+		}
+		checkText(bad, "buyer NrID", b.NrID, 50)
+	}
+	if b.BrakID != 0 {
+		ids++
+		if b.BrakID != 1 {
+			bad("buyer BrakID must be 1")
+		}
+	}
+	if ids != 1 {
+		bad("buyer must carry exactly one identification, has %d", ids)
+	}
+	checkText(bad, "buyer Nazwa", b.Nazwa, 512)
+	if f.Podmiot2.Adres != nil {
+		checkAddress(bad, "buyer", f.Podmiot2.Adres)
+	}
+	if schema == SchemaFA3 {
+		if !flag12(f.Podmiot2.JST) || !flag12(f.Podmiot2.GV) {
+			bad("FA(3) buyer needs JST and GV set to 1 or 2")
+		}
+	} else if f.Podmiot2.JST != 0 || f.Podmiot2.GV != 0 {
+		bad("JST / GV do not exist in %s", schema)
+	}
+
+	fa := &f.Fa
+	if !currencyShape.MatchString(fa.KodWaluty) {
+		bad("KodWaluty %q is not an ISO currency", fa.KodWaluty)
+	}
+	checkDate(bad, "P_1", fa.P1, true)
+	checkDate(bad, "P_6", fa.P6, false)
+	checkText(bad, "P_2", fa.P2, 256)
+
+	buckets := decimal.Zero
+	for name, v := range map[string]string{
+		"P_13_1": fa.P13_1, "P_14_1": fa.P14_1, "P_13_2": fa.P13_2, "P_14_2": fa.P14_2,
+		"P_13_3": fa.P13_3, "P_14_3": fa.P14_3, "P_13_5": fa.P13_5, "P_14_5": fa.P14_5,
+		"P_13_6_2": fa.P13_6_2, "P_13_6_3": fa.P13_6_3,
+	} {
+		buckets = buckets.Add(amount(bad, name, v))
+	}
+	for name, pair := range map[string][2]string{
+		"P_14_1W": {fa.P14_1, fa.P14_1W}, "P_14_2W": {fa.P14_2, fa.P14_2W}, "P_14_3W": {fa.P14_3, fa.P14_3W},
+	} {
+		amount(bad, name, pair[1])
+		vatDue := pair[0] != "" && !amount(func(string, ...any) {}, "", pair[0]).IsZero()
+		if fa.KodWaluty != "PLN" && vatDue && pair[1] == "" {
+			bad("%s (VAT in PLN) is required on a %s invoice", name, fa.KodWaluty)
+		}
+		if fa.KodWaluty == "PLN" && pair[1] != "" {
+			bad("%s must be absent on a PLN invoice", name)
+		}
+	}
+	total := amount(bad, "P_15", fa.P15)
+	if fa.P15 == "" {
+		bad("P_15 is required")
+	}
+	if !buckets.Equal(total) {
+		bad("P_15 %s does not equal the rate buckets %s", total, buckets)
+	}
+
+	switch fa.Rodzaj {
+	case "VAT":
+		if fa.Korygowana != nil || fa.Przyczyna != "" || fa.TypKorekty != 0 {
+			bad("a VAT invoice carries no correction data")
+		}
+	case "KOR":
+		k := fa.Korygowana
+		if k == nil {
+			bad("KOR needs DaneFaKorygowanej")
+			break
+		}
+		checkDate(bad, "DataWystFaKorygowanej", k.Data, true)
+		checkText(bad, "NrFaKorygowanej", k.Nr, 256)
+		switch {
+		case k.NrKSeF == 1 && k.NrKSeFN == 0:
+			checkText(bad, "NrKSeFFaKorygowanej", k.NrKSeFFa, 64)
+		case k.NrKSeF == 0 && k.NrKSeFN == 1:
+			if k.NrKSeFFa != "" {
+				bad("NrKSeFFaKorygowanej with NrKSeFN")
+			}
+		default:
+			bad("DaneFaKorygowanej needs exactly one of NrKSeF / NrKSeFN")
+		}
+		if fa.TypKorekty < 1 || fa.TypKorekty > 3 {
+			bad("TypKorekty %d is not 1..3", fa.TypKorekty)
+		}
+		if len(fa.Przyczyna) > 256 {
+			bad("PrzyczynaKorekty is longer than 256")
+		}
+	default:
+		bad("RodzajFaktury %q is not VAT or KOR", fa.Rodzaj)
+	}
+
+	if len(fa.FaWiersz) == 0 {
+		bad("no FaWiersz rows")
+	}
+	rows := decimal.Zero
+	for i, w := range fa.FaWiersz {
+		if w.Nr != i+1 {
+			bad("row %d is numbered %d", i+1, w.Nr)
+		}
+		checkText(bad, fmt.Sprintf("row %d P_7", i+1), w.P7, 512)
+		checkText(bad, fmt.Sprintf("row %d P_8A", i+1), w.P8A, 256)
+		if !quantityShape.MatchString(w.P8B) {
+			bad("row %d P_8B %q is not a quantity", i+1, w.P8B)
+		}
+		amount(bad, fmt.Sprintf("row %d P_9B", i+1), w.P9B)
+		rows = rows.Add(amount(bad, fmt.Sprintf("row %d P_11A", i+1), w.P11A))
+		switch {
+		case w.P12 != "" && w.P12XII == "":
+			if !rowRates[schema][w.P12] {
+				bad("row %d P_12 %q is not a %s rate", i+1, w.P12, schema)
+			}
+		case w.P12 == "" && w.P12XII != "":
+			if r, err := decimal.NewFromString(w.P12XII); err != nil || !r.IsPositive() || r.GreaterThan(decimal.NewFromInt(100)) {
+				bad("row %d P_12_XII %q is not a rate", i+1, w.P12XII)
+			}
+		default:
+			bad("row %d needs exactly one of P_12 / P_12_XII", i+1)
+		}
+	}
+	if len(fa.FaWiersz) > 0 && !rows.Equal(total) {
+		bad("rows add up to %s, P_15 is %s", rows, total)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("ksef: invalid %s invoice %s: %s", schema, fa.P2, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func flag12(v int) bool { return v == 1 || v == 2 }
+
+func checkText(bad func(string, ...any), name, v string, max int) {
+	if strings.TrimSpace(v) == "" {
+		bad("%s is required", name)
+	} else if len([]rune(v)) > max {
+		bad("%s is longer than %d", name, max)
+	}
+}
+
+func checkDate(bad func(string, ...any), name, v string, required bool) {
+	if v == "" {
+		if required {
+			bad("%s is required", name)
+		}
+		return
+	}
+	if _, err := time.Parse(dateLayout, v); err != nil {
+		bad("%s %q is not a date", name, v)
+	}
+}
+
+func checkAddress(bad func(string, ...any), who string, a *Adres) {
+	if !countryShape.MatchString(a.KodKraju) {
+		bad("%s KodKraju %q is not an ISO country code", who, a.KodKraju)
+	}
+	checkText(bad, who+" AdresL1", a.AdresL1, 512)
+	if len([]rune(a.AdresL2)) > 512 {
+		bad("%s AdresL2 is longer than 512", who)
+	}
+}
+
+// amount parses an optional TKwotowy value; an empty one is zero.
+func amount(bad func(string, ...any), name, v string) decimal.Decimal {
+	if v == "" {
+		return decimal.Zero
+	}
+	if !amountShape.MatchString(v) {
+		bad("%s %q is not an amount", name, v)
+		return decimal.Zero
+	}
+	return decimal.RequireFromString(v)
+}
@@ -401,6 +401,10 @@ var subjectKey = map[templateName]string{
 	OrderPendingReturn:      "order.return.subject",
 	PromoCode:               "promo.code.subject",
 	BackInStock:             "stock.back.subject",
+	WishlistOnSale:          "wishlist.sale.subject",
+	WishlistLowStock:        "wishlist.lowstock.subject",
+	WishlistRestock:         "wishlist.restock.subject",
+	CheckoutRecovery:        "checkout.recovery.subject",
 	TierUpgrade:             "tier.upgrade.subject",
 	TierDowngrade:           "tier.downgrade.subject",
 	DowngradeReminder:       "tier.reminder.subject",
@@ -17,7 +17,7 @@ var preheaderKeyRe = regexp.MustCompile(`"preheader"\s+(?:\(or\s+\.Preheader\s+)
 // TestTemplatePreheadersAreLocalized asserts every transactional template renders its
 // preview line from the catalog and that the key exists in en.json. Without this, a
 // missing key would silently render as the raw key text in the recipient's inbox — the
-// 19x7 render smoke test cannot catch it (it only looks for leaked "{{" actions).
+// 23x7 render smoke test cannot catch it (it only looks for leaked "{{" actions).
 func TestTemplatePreheadersAreLocalized(t *testing.T) {
 	en := loadLocaleStrings(t, defaultLocale)
 
@@ -40,7 +40,7 @@ func TestTemplatePreheadersAreLocalized(t *testing.T) {
 		require.NotEmptyf(t, en[key], "%s references preheader key %q which is missing from en.json", e.Name(), key)
 		seen++
 	}
-	require.Equal(t, 19, seen, "expected one preheader per transactional template")
+	require.Equal(t, 23, seen, "expected one preheader per transactional template")
 }
 
 // TestPreheaderFollowsRecipientLocale renders every template for a ja recipient with
@@ -10,7 +10,7 @@ import (
 )
 
 // TestAllTemplatesRenderInAllLocales renders every transactional template in every supported
-// locale (19×7) with localization ON, asserting each produces non-empty HTML with a subject
+// locale (23×7) with localization ON, asserting each produces non-empty HTML with a subject
 // and no leaked raw catalog keys. It is the structural safety net for translations: a template
 // that references a key a locale mistranslates into a broken placeholder, or a plural/markup
 // mismatch, surfaces here rather than in a customer's inbox. Content is validated separately by
@@ -26,7 +26,7 @@ func TestAllTemplatesRenderInAllLocales(t *testing.T) {
 	require.NoError(t, err)
 
 	samples := emailSamples()
-	require.Len(t, samples, 19, "expected one sample per transactional template")
+	require.Len(t, samples, 23, "expected one sample per transactional template")
 
 	for _, code := range supportedLocales {
 		for _, s := range samples {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
 )
 
 type templateName string
@@ -21,6 +22,10 @@ const (
 	OrderPendingReturn   templateName = "pending_return.gohtml"
 	PromoCode            templateName = "promo_code.gohtml"
 	BackInStock          templateName = "back_in_stock.gohtml"
+	WishlistOnSale       templateName = "wishlist_sale.gohtml"
+	WishlistLowStock     templateName = "wishlist_low_stock.gohtml"
+	WishlistRestock      templateName = "wishlist_restock.gohtml"
+	CheckoutRecovery     templateName = "checkout_recovery.gohtml"
 
 	TierUpgrade             templateName = "tier_upgrade.gohtml"
 	TierDowngrade           templateName = "tier_downgrade.gohtml"
@@ -45,6 +50,10 @@ var templateSubjects = map[templateName]string{
 	OrderPendingReturn:   "Your return has been requested",
 	PromoCode:            "Your promo code",
 	BackInStock:          "Your waitlist item is back in stock",
+	WishlistOnSale:       "A piece on your wishlist is on sale",
+	WishlistLowStock:     "A piece on your wishlist is almost gone",
+	WishlistRestock:      "A piece on your wishlist is back in stock",
+	CheckoutRecovery:     "You left something in your bag",
 
 	TierUpgrade:             "Your GRBPWR tier",
 	TierDowngrade:           "Your GRBPWR tier",
@@ -275,3 +284,38 @@ func (m *Mailer) SendBackInStock(ctx context.Context, rep dependency.Repository,
 	// Queue email for async sending (better for batch operations)
 	return m.queueEmail(ctx, rep, ser)
 }
+
+// wishlistTemplates maps a wishlist alert to its template.
+var wishlistTemplates = map[entity.WishlistAlertKind]templateName{
+	entity.WishlistAlertOnSale:   WishlistOnSale,
+	entity.WishlistAlertLowStock: WishlistLowStock,
+	entity.WishlistAlertRestock:  WishlistRestock,
+}
+
+// QueueWishlistAlert queues a wishlist alert (on sale, low stock or restock). Marketing.
+func (m *Mailer) QueueWishlistAlert(ctx context.Context, rep dependency.Repository, to string, kind entity.WishlistAlertKind, data *dto.WishlistAlert) error {
+	tmpl, ok := wishlistTemplates[kind]
+	if !ok {
+		return fmt.Errorf("unknown wishlist alert kind %q", kind)
+	}
+	if data.ProductURL == "" {
+		return fmt.Errorf("incomplete product details: %+v", data)
+	}
+	ser, err := m.buildSendMailRequest(to, tmpl, data)
+	if err != nil {
+		return fmt.Errorf("can't build wishlist %s email: %w", kind, err)
+	}
+	return m.queueEmail(ctx, rep, ser)
+}
+
+// QueueCheckoutRecovery queues one email of the abandoned checkout sequence. Marketing.
+func (m *Mailer) QueueCheckoutRecovery(ctx context.Context, rep dependency.Repository, to string, data *dto.CheckoutRecoveryEmail) error {
+	if data.RestoreURL == "" || len(data.OrderItems) == 0 {
+		return fmt.Errorf("incomplete checkout recovery details: %+v", data)
+	}
+	ser, err := m.buildSendMailRequest(to, CheckoutRecovery, data)
+	if err != nil {
+		return fmt.Errorf("can't build checkout recovery email: %w", err)
+	}
+	return m.queueEmail(ctx, rep, ser)
+}
@@ -13,7 +13,7 @@ type emailSample struct {
 }
 
 // emailSamples returns one representative data payload per transactional template, shared by
-// the preview writer (TestRenderAllEmails) and the 19×7 locale smoke test
+// the preview writer (TestRenderAllEmails) and the 23×7 locale smoke test
 // (TestAllTemplatesRenderInAllLocales). Order-bearing samples carry LocalizedNames so the
 // localName selector is exercised.
 func emailSamples() []emailSample {
@@ -120,6 +120,43 @@ func emailSamples() []emailSample {
 			ProductURL:  "https://grbpwr.com/product/oversized-wool-coat",
 			EmailB64:    b64,
 		}},
+		{WishlistOnSale, &dto.WishlistAlert{
+			BuyerName:   "Alex",
+			ProductName: "OVERSIZED WOOL COAT",
+			Brand:       "GRBPWR",
+			Size:        "M",
+			Thumbnail:   "https://picsum.photos/seed/coat/400",
+			ProductURL:  "https://grbpwr.com/product/oversized-wool-coat",
+			SalePercent: "30",
+			EmailB64:    b64,
+		}},
+		{WishlistLowStock, &dto.WishlistAlert{
+			BuyerName:   "Alex",
+			ProductName: "TECHNICAL CARGO TROUSERS",
+			Brand:       "GRBPWR",
+			Size:        "32",
+			Thumbnail:   "https://picsum.photos/seed/cargo/400",
+			ProductURL:  "https://grbpwr.com/product/technical-cargo-trousers",
+			EmailB64:    b64,
+		}},
+		{WishlistRestock, &dto.WishlistAlert{
+			BuyerName:   "Alex",
+			ProductName: "OVERSIZED WOOL COAT",
+			Brand:       "GRBPWR",
+			Thumbnail:   "https://picsum.photos/seed/coat/400",
+			ProductURL:  "https://grbpwr.com/product/oversized-wool-coat",
+			EmailB64:    b64,
+		}},
+		{CheckoutRecovery, &dto.CheckoutRecoveryEmail{
+			Step:           3,
+			OrderItems:     items,
+			CurrencySymbol: "€",
+			SubtotalPrice:  "840",
+			IncentiveCode:  "BACK7QK2M9XA",
+			RestoreURL:     "https://grbpwr.com/cart/restore/0123456789abcdef0123456789abcdef?step=3",
+			StopURL:        "https://grbpwr.com/cart/restore/0123456789abcdef0123456789abcdef/stop",
+			EmailB64:       b64,
+		}},
 		{TierUpgrade, &dto.TierChangeEmail{
 			Preheader:       "YOUR GRBPWR TIER HAS CHANGED",
 			EmailB64:        " ",
@@ -0,0 +1,178 @@
+// Package markdownsched runs a periodic job that starts and ends scheduled markdown events (0349):
+// at the start of an event's window it sets product.sale_percentage on the event's colourways, at
+// the end it puts the previous value back (see pricing.Store.ApplyDueMarkdownEvents). The storefront
+// is revalidated for every colourway whose sale moved, so OnSale flips without anybody publishing.
+package markdownsched
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/health"
+	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+)
+
+// tickTimeout bounds the DB work done in a single tick, so one stuck query can't
+// block the loop forever or stall graceful shutdown.
+const tickTimeout = 30 * time.Second
+
+// revalidateTimeout bounds the storefront revalidation after a tick that moved prices.
+const revalidateTimeout = 30 * time.Second
+
+// Backoff bounds for consecutive-failure backoff (base * 2^(n-1), capped at
+// backoffMax); a successful tick resets it. Mirrors opexmaterialize.
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 5 * time.Minute
+)
+
+// Config configures the markdown schedule worker.
+type Config struct {
+	// WorkerInterval is how often due events are started and ended — the lateness of a markdown is
+	// at most one interval.
+	WorkerInterval time.Duration `mapstructure:"worker_interval"`
+}
+
+// DefaultConfig returns sane defaults (run every minute).
+func DefaultConfig() Config {
+	return Config{WorkerInterval: time.Minute}
+}
+
+// Worker periodically applies and reverts scheduled markdowns.
+type Worker struct {
+	repo    dependency.Repository
+	re      dependency.RevalidationService
+	c       *Config
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	tracker health.Tracker
+}
+
+// Name implements health.Reporter.
+func (w *Worker) Name() string { return "markdownsched" }
+
+// LastSuccess implements health.Reporter (zero time until the first clean tick).
+func (w *Worker) LastSuccess() time.Time { return w.tracker.LastSuccess() }
+
+// New constructs a markdown schedule worker.
+func New(c *Config, repo dependency.Repository, re dependency.RevalidationService) *Worker {
+	if c == nil {
+		dc := DefaultConfig()
+		c = &dc
+	}
+	if c.WorkerInterval <= 0 {
+		c.WorkerInterval = DefaultConfig().WorkerInterval
+	}
+	return &Worker{repo: repo, re: re, c: c}
+}
+
+// Start launches the worker goroutine.
+func (w *Worker) Start(ctx context.Context) error {
+	if w.ctx != nil && w.stop != nil {
+		return fmt.Errorf("markdown schedule worker already started")
+	}
+	w.ctx, w.stop = context.WithCancel(ctx)
+	w.wg.Go(func() {
+		w.run(w.ctx)
+	})
+	return nil
+}
+
+// Stop signals the worker to exit and waits for its goroutine to return.
+func (w *Worker) Stop() error {
+	if w.stop == nil {
+		return fmt.Errorf("markdown schedule worker already stopped or not started")
+	}
+	w.stop()
+	w.stop = nil
+	w.wg.Wait()
+	return nil
+}
+
+func (w *Worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.c.WorkerInterval)
+	defer ticker.Stop()
+
+	// Sweep once at startup: an event that came due while the process was down applies now, not an
+	// interval later.
+	w.runOnce(ctx)
+
+	var consecutiveFailures int
+	for {
+		select {
+		case <-ticker.C:
+			if w.runOnce(ctx) {
+				consecutiveFailures = 0
+				continue
+			}
+			consecutiveFailures++
+			delay := backoffDelay(consecutiveFailures)
+			slog.Default().WarnContext(ctx, "markdownsched: backing off after failed tick",
+				slog.Int("consecutive_failures", consecutiveFailures),
+				slog.Duration("delay", delay),
+			)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backoffDelay returns base * 2^(n-1), capped at backoffMax.
+func backoffDelay(consecutiveFailures int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < consecutiveFailures; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+// runOnce ends and starts every due event in one transaction, then revalidates the colourways that
+// moved. A failed revalidation is logged, not a failed tick: the prices are already written, and
+// the storefront catches up on its own revalidation interval.
+func (w *Worker) runOnce(ctx context.Context) bool {
+	defer saferun.Recover(ctx, "markdownsched")
+
+	tickCtx, cancel := context.WithTimeout(ctx, tickTimeout)
+	defer cancel()
+
+	sweep, err := w.repo.Pricing().ApplyDueMarkdownEvents(tickCtx, time.Now())
+	if err != nil {
+		w.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "markdownsched: sweep failed", slog.String("err", err.Error()))
+		return false
+	}
+	w.tracker.MarkSuccess()
+	if len(sweep.Started) == 0 && len(sweep.Ended) == 0 {
+		return true
+	}
+	slog.Default().InfoContext(ctx, "markdownsched: markdowns moved",
+		slog.Any("started", sweep.Started),
+		slog.Any("ended", sweep.Ended),
+		slog.Int("products", len(sweep.Products)),
+	)
+	if len(sweep.Products) > 0 && w.re != nil {
+		reCtx, reCancel := context.WithTimeout(ctx, revalidateTimeout)
+		defer reCancel()
+		if err := w.re.RevalidateAll(reCtx, &dto.RevalidationData{Products: sweep.Products}); err != nil {
+			slog.Default().WarnContext(ctx, "markdownsched: storefront revalidation failed", slog.String("err", err.Error()))
+		}
+	}
+	return true
+}
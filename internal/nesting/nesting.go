@@ -0,0 +1,574 @@
+// Package nesting lays a marker on the server: pattern-piece contours into a strip of fabric of a
+// given width, as short as it can make it.
+//
+// The engine is a raster bottom-left fill. The strip is a grid of square cells; each instance goes
+// where its right edge ends up least far along the roll, trying every orientation the cloth allows.
+// It is deterministic, and it makes no claim to beat the browser's search. It answers «what length
+// does this size ratio need on this width», with no client open, to a cell of accuracy.
+//
+// The package knows nothing of cards, sizes or storage. Its coordinates are those of
+// TechCardMarkerLayout: x runs along the roll, y across the width, cm. A placement is
+//
+//	placed(p) = R(rot_deg) · M^flipped · p + (x_cm, y_cm),   M: (x, y) ↦ (−x, y)
+//
+// applied to the piece's aligned contour (Result.Shapes).
+package nesting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// DefaultResolutionCm is the cell size when Params.ResolutionCm is zero. Half a centimetre
+	// costs a little length against an exact nest and keeps a 150 cm strip at 300 cells.
+	DefaultResolutionCm = 0.5
+	MinResolutionCm     = 0.1
+	MaxResolutionCm     = 5.0
+
+	// MaxInstances bounds one run. A lay bigger than this is a production plan, not a marker.
+	MaxInstances = 2000
+)
+
+var (
+	ErrNoPieces          = errors.New("nothing to nest: no piece has an instance in the composition")
+	ErrTooManyInstances  = fmt.Errorf("more than %d instances in one marker", MaxInstances)
+	ErrStripTooNarrow    = errors.New("the edge margins leave no width to lay on")
+	ErrInvalidResolution = fmt.Errorf("resolution must be between %g and %g cm", MinResolutionCm, MaxResolutionCm)
+)
+
+// Point is a contour vertex, cm.
+type Point struct {
+	X, Y float64
+}
+
+// Piece is one distinct contour and how many instances ONE GARMENT cuts. The instance count in the
+// marker follows the layout formula: Quantity × (SizeId > 0 ? composition[SizeId] : total units).
+type Piece struct {
+	Key      string // the caller's identity; the engine only echoes it
+	SizeId   int    // 0 = ungraded, cut once per garment of the whole composition
+	Quantity int
+	// Mirrored is a piece cut as a left/right pair from one contour: every other instance is laid
+	// turned over when Params.AllowFlip, and as drawn (for a face-to-face cut) otherwise.
+	Mirrored bool
+	Contour  []Point
+	// AreaCm2 is the piece's true area when the caller has it (arcs measured along the arc); zero
+	// means the contour's own.
+	AreaCm2 float64
+	// GrainAngleDeg is the grain line's direction in the contour's drawing; the contour is turned so
+	// that the grain runs along the roll. HasGrain=false takes the drawing's X axis as the warp.
+	GrainAngleDeg float64
+	HasGrain      bool
+}
+
+// SizeQuantity is one entry of the size ratio: garments of SizeId in one lay.
+type SizeQuantity struct {
+	SizeId   int
+	Quantity int
+}
+
+// Params is the strip and what the cloth allows.
+type Params struct {
+	WidthCm      float64 // the cutting width — between the selvedges
+	GapCm        float64 // kept clear between any two pieces
+	EdgeMarginCm float64 // kept clear along both edges and at both ends
+	ResolutionCm float64 // cell size; 0 = DefaultResolutionCm
+	// AllowCrossGrain lets a piece lie across the width (90°; 270° too with AllowHalfTurn).
+	AllowCrossGrain bool
+	// AllowHalfTurn lets a piece lie head-to-tail (180°). Off on one-way cloth.
+	AllowHalfTurn bool
+	// AllowFlip lets the mirrored half of a pair be laid turned over. Off on one-way cloth.
+	AllowFlip bool
+}
+
+// Placement is one laid instance, in the TechCardMarkerPlacement convention.
+type Placement struct {
+	Piece    int // index into the pieces passed to Nest
+	Instance int // 0-based within the piece
+	RotDeg   int
+	Flipped  bool
+	XCm, YCm float64
+}
+
+// Result is a nested marker.
+type Result struct {
+	Placements []Placement
+	// Shapes[i] is piece i's contour as the placements read it: turned grain-along-X,
+	// counter-clockwise, origin at its bounding-box corner.
+	Shapes        [][]Point
+	AreasCm2      []float64
+	PlacedCount   int
+	TotalCount    int
+	UsedLengthCm  float64
+	EfficiencyPct float64
+	Warnings      []string
+}
+
+// orientation is one way an instance may lie.
+type orientation struct {
+	rot  int
+	flip bool
+}
+
+// mask is a rasterised orientation: body is what the piece covers, halo the body grown by the gap.
+// Both are per-column runs of cells; halo is offset by pad in both axes.
+type mask struct {
+	o          orientation
+	w, h       int // body size in cells
+	body, halo [][]run
+	haloMaxRun []int   // longest halo run per column, for the quick reject
+	minX, minY float64 // of the turned contour, to convert a cell position into (x_cm, y_cm)
+	bboxW      float64 // of the turned contour, cm
+}
+
+type run struct{ lo, hi int } // cells [lo, hi)
+
+// Nest lays every instance the composition asks for. Instances that fit nowhere are left out and
+// named in Warnings; PlacedCount < TotalCount then says the marker is a draft.
+func Nest(pieces []Piece, composition []SizeQuantity, p Params) (Result, error) {
+	res := p.ResolutionCm
+	if res == 0 {
+		res = DefaultResolutionCm
+	}
+	if res < MinResolutionCm || res > MaxResolutionCm || math.IsNaN(res) {
+		return Result{}, ErrInvalidResolution
+	}
+	if p.GapCm < 0 || p.EdgeMarginCm < 0 {
+		return Result{}, errors.New("gap and edge margin must not be negative")
+	}
+	usable := p.WidthCm - 2*p.EdgeMarginCm
+	cells := int(math.Floor(usable/res + 1e-9))
+	if cells <= 0 {
+		return Result{}, ErrStripTooNarrow
+	}
+
+	perSize := make(map[int]int, len(composition))
+	units := 0
+	for _, c := range composition {
+		if c.Quantity > 0 {
+			perSize[c.SizeId] += c.Quantity
+			units += c.Quantity
+		}
+	}
+
+	out := Result{Shapes: make([][]Point, len(pieces)), AreasCm2: make([]float64, len(pieces))}
+	type instance struct {
+		piece, n int
+		area     float64
+	}
+	var queue []instance
+	for i, pc := range pieces {
+		shape, area, warn := align(pc, pieceLabel(pc, i))
+		if warn != "" {
+			out.Warnings = append(out.Warnings, warn)
+		}
+		out.Shapes[i] = shape
+		if pc.AreaCm2 > 0 {
+			area = pc.AreaCm2
+		}
+		out.AreasCm2[i] = area
+		count := pc.Quantity * units
+		if pc.SizeId > 0 {
+			count = pc.Quantity * perSize[pc.SizeId]
+		}
+		if count <= 0 || len(shape) < 3 {
+			continue
+		}
+		if pc.Mirrored && !p.AllowFlip && count > 1 {
+			out.Warnings = append(out.Warnings, fmt.Sprintf(
+				"%s: a mirrored pair laid unflipped — cut it face to face", pieceLabel(pc, i)))
+		}
+		for n := 0; n < count; n++ {
+			queue = append(queue, instance{piece: i, n: n, area: area})
+		}
+		out.TotalCount += count
+		if out.TotalCount > MaxInstances {
+			return Result{}, ErrTooManyInstances
+		}
+	}
+	if len(queue) == 0 {
+		return Result{}, ErrNoPieces
+	}
+	// Biggest first: the small pieces fill the holes the big ones leave.
+	sort.SliceStable(queue, func(a, b int) bool { return queue[a].area > queue[b].area })
+
+	pad := int(math.Ceil(p.GapCm/res - 1e-9))
+	g := newGrid(cells, pad)
+	type maskKey struct {
+		piece int
+		o     orientation
+	}
+	masks := make(map[maskKey]*mask)
+	var placedArea, right float64
+	unfit := make(map[int]bool)
+
+	for _, in := range queue {
+		pc := pieces[in.piece]
+		flip := pc.Mirrored && p.AllowFlip && in.n%2 == 1
+		var best *mask
+		bestX, bestY := 0, 0
+		bestRight := math.MaxInt
+		for _, rot := range rotations(p) {
+			o := orientation{rot: rot, flip: flip}
+			m, ok := masks[maskKey{in.piece, o}]
+			if !ok {
+				m = rasterise(out.Shapes[in.piece], o, res, pad)
+				masks[maskKey{in.piece, o}] = m
+			}
+			if m.h > cells {
+				continue
+			}
+			if cx, cy, ok := g.find(m, bestRight); ok {
+				best, bestX, bestY, bestRight = m, cx, cy, cx+m.w
+			}
+		}
+		if best == nil {
+			unfit[in.piece] = true
+			continue
+		}
+		g.place(best, bestX, bestY)
+		x := p.EdgeMarginCm + float64(bestX)*res
+		y := p.EdgeMarginCm + float64(bestY)*res
+		out.Placements = append(out.Placements, Placement{
+			Piece:    in.piece,
+			Instance: in.n,
+			RotDeg:   best.o.rot,
+			Flipped:  best.o.flip,
+			XCm:      x - best.minX,
+			YCm:      y - best.minY,
+		})
+		placedArea += in.area
+		right = math.Max(right, x+best.bboxW)
+	}
+	for i := range pieces {
+		if unfit[i] {
+			out.Warnings = append(out.Warnings, fmt.Sprintf(
+				"%s: does not fit the width in any allowed orientation", pieceLabel(pieces[i], i)))
+		}
+	}
+	// Instances in piece order, as a reader of the layout expects them.
+	sort.SliceStable(out.Placements, func(a, b int) bool {
+		pa, pb := out.Placements[a], out.Placements[b]
+		if pa.Piece != pb.Piece {
+			return pa.Piece < pb.Piece
+		}
+		return pa.Instance < pb.Instance
+	})
+	out.PlacedCount = len(out.Placements)
+	if out.PlacedCount > 0 {
+		out.UsedLengthCm = right + p.EdgeMarginCm
+		out.EfficiencyPct = placedArea / (out.UsedLengthCm * p.WidthCm) * 100
+	}
+	return out, nil
+}
+
+func pieceLabel(pc Piece, i int) string {
+	if pc.Key != "" {
+		return pc.Key
+	}
+	return fmt.Sprintf("piece %d", i+1)
+}
+
+// rotations are the turns the cloth allows, the natural one first so that it wins a tie.
+func rotations(p Params) []int {
+	out := []int{0}
+	if p.AllowHalfTurn {
+		out = append(out, 180)
+	}
+	if p.AllowCrossGrain {
+		out = append(out, 90)
+		if p.AllowHalfTurn {
+			out = append(out, 270)
+		}
+	}
+	return out
+}
+
+// align turns a piece grain-along-X, makes it counter-clockwise and moves its bbox corner to the
+// origin. It returns the contour's own area too.
+func align(pc Piece, label string) ([]Point, float64, string) {
+	if len(pc.Contour) < 3 {
+		return nil, 0, fmt.Sprintf("%s: no contour to lay", label)
+	}
+	var warn string
+	theta := 0.0
+	if pc.HasGrain {
+		theta = -pc.GrainAngleDeg * math.Pi / 180
+	} else {
+		warn = fmt.Sprintf("%s: no grain line — laid with the drawing's X axis along the warp", label)
+	}
+	sin, cos := math.Sincos(theta)
+	pts := make([]Point, len(pc.Contour))
+	for i, q := range pc.Contour {
+		pts[i] = Point{X: q.X*cos - q.Y*sin, Y: q.X*sin + q.Y*cos}
+	}
+	if signedArea(pts) < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	minX, minY, _, _ := bounds(pts)
+	for i := range pts {
+		pts[i].X -= minX
+		pts[i].Y -= minY
+	}
+	return pts, math.Abs(signedArea(pts)), warn
+}
+
+func signedArea(pts []Point) float64 {
+	var s float64
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		s += a.X*b.Y - b.X*a.Y
+	}
+	return s / 2
+}
+
+func bounds(pts []Point) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, q := range pts {
+		minX, maxX = math.Min(minX, q.X), math.Max(maxX, q.X)
+		minY, maxY = math.Min(minY, q.Y), math.Max(maxY, q.Y)
+	}
+	return
+}
+
+// turn applies M^flip then R(rot) — the layout's order.
+func turn(q Point, o orientation) Point {
+	if o.flip {
+		q.X = -q.X
+	}
+	switch o.rot {
+	case 90:
+		return Point{X: -q.Y, Y: q.X}
+	case 180:
+		return Point{X: -q.X, Y: -q.Y}
+	case 270:
+		return Point{X: q.Y, Y: -q.X}
+	}
+	return q
+}
+
+// rasterise covers every cell the turned contour touches: cells whose centre is inside, and cells
+// an edge passes through. Over-covering costs a fraction of a cell of length; under-covering would
+// let two pieces overlap.
+func rasterise(shape []Point, o orientation, res float64, pad int) *mask {
+	pts := make([]Point, len(shape))
+	for i, q := range shape {
+		pts[i] = turn(q, o)
+	}
+	minX, minY, maxX, maxY := bounds(pts)
+	for i := range pts {
+		pts[i].X -= minX
+		pts[i].Y -= minY
+	}
+	w := max(1, int(math.Ceil((maxX-minX)/res-1e-9)))
+	h := max(1, int(math.Ceil((maxY-minY)/res-1e-9)))
+	cover := make([]bool, w*h)
+	mark := func(cx, cy int) {
+		cx, cy = min(max(cx, 0), w-1), min(max(cy, 0), h-1)
+		cover[cx*h+cy] = true
+	}
+
+	// Interior: per column, the crossings of the vertical through the cell centres.
+	var ys []float64
+	for cx := 0; cx < w; cx++ {
+		xc := (float64(cx) + 0.5) * res
+		ys = ys[:0]
+		for i := range pts {
+			a, b := pts[i], pts[(i+1)%len(pts)]
+			if (a.X <= xc) == (b.X <= xc) {
+				continue
+			}
+			ys = append(ys, a.Y+(xc-a.X)*(b.Y-a.Y)/(b.X-a.X))
+		}
+		sort.Float64s(ys)
+		for k := 0; k+1 < len(ys); k += 2 {
+			lo := int(math.Ceil(ys[k]/res - 0.5))
+			hi := int(math.Floor(ys[k+1]/res - 0.5))
+			for cy := lo; cy <= hi; cy++ {
+				mark(cx, cy)
+			}
+		}
+	}
+	// Outline: every edge walked in steps shorter than half a cell.
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		steps := max(1, int(math.Ceil(math.Hypot(b.X-a.X, b.Y-a.Y)/(res/2))))
+		for k := 0; k <= steps; k++ {
+			t := float64(k) / float64(steps)
+			mark(int((a.X+(b.X-a.X)*t)/res), int((a.Y+(b.Y-a.Y)*t)/res))
+		}
+	}
+
+	m := &mask{o: o, w: w, h: h, minX: minX, minY: minY, bboxW: maxX - minX}
+	m.body = runsOf(cover, w, h)
+	if pad == 0 {
+		m.halo = m.body
+	} else {
+		hw, hh := w+2*pad, h+2*pad
+		halo := make([]bool, hw*hh)
+		for cx := 0; cx < w; cx++ {
+			for _, r := range m.body[cx] {
+				for x := cx; x <= cx+2*pad; x++ {
+					for y := r.lo; y < r.hi+2*pad; y++ {
+						halo[x*hh+y] = true
+					}
+				}
+			}
+		}
+		m.halo = runsOf(halo, hw, hh)
+	}
+	m.haloMaxRun = make([]int, len(m.halo))
+	for x, rs := range m.halo {
+		for _, r := range rs {
+			m.haloMaxRun[x] = max(m.haloMaxRun[x], r.hi-r.lo)
+		}
+	}
+	return m
+}
+
+func runsOf(cover []bool, w, h int) [][]run {
+	out := make([][]run, w)
+	for x := 0; x < w; x++ {
+		col := cover[x*h : (x+1)*h]
+		for y := 0; y < h; {
+			if !col[y] {
+				y++
+				continue
+			}
+			lo := y
+			for y < h && col[y] {
+				y++
+			}
+			out[x] = append(out[x], run{lo, y})
+		}
+	}
+	return out
+}
+
+// grid is the strip, column by column along the roll. Rows [pad, pad+cells) are the usable width;
+// the pad rows either side and pad columns at the start are never occupied, so a halo can hang
+// into them — the gap is between pieces, the edge margin is the caller's.
+type grid struct {
+	cells, pad, rows, words int
+	cols                    [][]uint64
+	freeRun                 []int // longest free run of each column
+}
+
+func newGrid(cells, pad int) *grid {
+	rows := cells + 2*pad
+	return &grid{cells: cells, pad: pad, rows: rows, words: (rows + 63) / 64}
+}
+
+func (g *grid) col(x int) []uint64 {
+	if x < len(g.cols) {
+		return g.cols[x]
+	}
+	return nil
+}
+
+func (g *grid) longestFree(x int) int {
+	if x < len(g.freeRun) {
+		return g.freeRun[x]
+	}
+	return g.rows
+}
+
+// find returns the halo position (cx, cy) of the lowest, then leftmost-across, spot for m whose
+// right edge is short of limit.
+func (g *grid) find(m *mask, limit int) (int, int, bool) {
+	maxY := g.cells - m.h // halo y range: the body lands on [cy+pad, cy+pad+h) ⊂ [pad, pad+cells)
+	for cx := 0; cx+m.w < limit; cx++ {
+		if !g.roomy(m, cx) {
+			continue
+		}
+		for cy := 0; cy <= maxY; {
+			next, ok := g.clash(m, cx, cy)
+			if ok {
+				return cx, cy, true
+			}
+			cy = next
+		}
+	}
+	return 0, 0, false
+}
+
+// roomy is the quick reject: a column too full for the halo's longest run there.
+func (g *grid) roomy(m *mask, cx int) bool {
+	for x, n := range m.haloMaxRun {
+		if n > g.longestFree(cx+x) {
+			return false
+		}
+	}
+	return true
+}
+
+// clash tests the halo at (cx, cy). On a hit it returns the next cy worth trying: past the highest
+// occupied cell the hit found.
+func (g *grid) clash(m *mask, cx, cy int) (int, bool) {
+	for x, rs := range m.halo {
+		col := g.col(cx + x)
+		if col == nil {
+			continue
+		}
+		for _, r := range rs {
+			if top := lastSet(col, cy+r.lo, cy+r.hi); top >= 0 {
+				return top - r.lo + 1, false
+			}
+		}
+	}
+	return cy, true
+}
+
+// place marks m's body with its halo at (cx, cy).
+func (g *grid) place(m *mask, cx, cy int) {
+	for x, rs := range m.body {
+		gx := cx + g.pad + x
+		for len(g.cols) <= gx {
+			g.cols = append(g.cols, make([]uint64, g.words))
+			g.freeRun = append(g.freeRun, g.rows)
+		}
+		col := g.cols[gx]
+		for _, r := range rs {
+			for y := cy + g.pad + r.lo; y < cy+g.pad+r.hi; y++ {
+				col[y/64] |= 1 << (y % 64)
+			}
+		}
+		g.freeRun[gx] = longestZeroRun(col, g.rows)
+	}
+}
+
+// lastSet is the highest set bit in [lo, hi), or -1.
+func lastSet(col []uint64, lo, hi int) int {
+	for w := (hi - 1) / 64; w >= lo/64 && w >= 0; w-- {
+		word := col[w]
+		if top := (w + 1) * 64; top > hi {
+			word &= (1 << (hi - w*64)) - 1
+		}
+		if w == lo/64 {
+			word &^= (1 << (lo - w*64)) - 1
+		}
+		if word != 0 {
+			return w*64 + 63 - bits.LeadingZeros64(word)
+		}
+	}
+	return -1
+}
+
+func longestZeroRun(col []uint64, rows int) int {
+	best, cur := 0, 0
+	for y := 0; y < rows; y++ {
+		if col[y/64]&(1<<(y%64)) != 0 {
+			cur = 0
+			continue
+		}
+		cur++
+		best = max(best, cur)
+	}
+	return best
+}
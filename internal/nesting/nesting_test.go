@@ -0,0 +1,176 @@
+package nesting
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func rect(w, h float64) []Point {
+	return []Point{{0, 0}, {w, 0}, {w, h}, {0, h}}
+}
+
+// placedBox is a placement's bounding box, composed the way a layout reader composes it.
+func placedBox(r Result, pl Placement) (minX, minY, maxX, maxY float64) {
+	pts := make([]Point, 0, len(r.Shapes[pl.Piece]))
+	for _, q := range r.Shapes[pl.Piece] {
+		q = turn(q, orientation{rot: pl.RotDeg, flip: pl.Flipped})
+		pts = append(pts, Point{X: q.X + pl.XCm, Y: q.Y + pl.YCm})
+	}
+	return bounds(pts)
+}
+
+// checkLaid fails on a placement outside the strip or two bounding boxes closer than gap — exact
+// for the rectangles these tests lay.
+func checkLaid(t *testing.T, r Result, p Params) {
+	t.Helper()
+	const eps = 1e-6
+	for i, a := range r.Placements {
+		ax0, ay0, ax1, ay1 := placedBox(r, a)
+		if ax0 < p.EdgeMarginCm-eps || ay0 < p.EdgeMarginCm-eps || ay1 > p.WidthCm-p.EdgeMarginCm+eps || ax1 > r.UsedLengthCm-p.EdgeMarginCm+eps {
+			t.Fatalf("placement %+v at [%v,%v]-[%v,%v] leaves the strip", a, ax0, ay0, ax1, ay1)
+		}
+		for _, b := range r.Placements[i+1:] {
+			bx0, by0, bx1, by1 := placedBox(r, b)
+			if ax0 < bx1+p.GapCm-eps && bx0 < ax1+p.GapCm-eps && ay0 < by1+p.GapCm-eps && by0 < ay1+p.GapCm-eps {
+				t.Fatalf("placements %+v and %+v are closer than the gap", a, b)
+			}
+		}
+	}
+}
+
+func TestNestFillsTheStrip(t *testing.T) {
+	p := Params{WidthCm: 20}
+	r, err := Nest([]Piece{{Key: "sq", Quantity: 2, Contour: rect(10, 10), HasGrain: true}}, []SizeQuantity{{SizeId: 1, Quantity: 2}}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PlacedCount != 4 || r.TotalCount != 4 {
+		t.Fatalf("placed %d of %d, want 4 of 4", r.PlacedCount, r.TotalCount)
+	}
+	if math.Abs(r.UsedLengthCm-20) > 1e-9 || math.Abs(r.EfficiencyPct-100) > 1e-9 {
+		t.Fatalf("length %v efficiency %v, want 20 cm at 100%%", r.UsedLengthCm, r.EfficiencyPct)
+	}
+	checkLaid(t, r, p)
+	for i, pl := range r.Placements {
+		if pl.Instance != i {
+			t.Fatalf("placements %+v not in instance order", r.Placements)
+		}
+	}
+}
+
+func TestNestKeepsGapAndMargin(t *testing.T) {
+	p := Params{WidthCm: 30, GapCm: 1, EdgeMarginCm: 2}
+	pieces := []Piece{
+		{Key: "front", SizeId: 1, Quantity: 2, Contour: rect(20, 12), HasGrain: true},
+		{Key: "pocket", Quantity: 1, Contour: rect(5, 5), HasGrain: true},
+	}
+	r, err := Nest(pieces, []SizeQuantity{{SizeId: 1, Quantity: 3}, {SizeId: 2, Quantity: 1}}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// front: 2 × 3 of size 1; pocket: 1 × 4 garments.
+	if r.TotalCount != 10 || r.PlacedCount != 10 {
+		t.Fatalf("placed %d of %d, want 10 of 10", r.PlacedCount, r.TotalCount)
+	}
+	checkLaid(t, r, p)
+	// Two fronts across 26 cm of usable width with a 1 cm gap, three deep, then the four pockets in
+	// one column: 2 + 3×20 + 2×1 + 1 + 5 + 2.
+	if math.Abs(r.UsedLengthCm-72) > 1e-9 {
+		t.Fatalf("length %v, want 72 cm", r.UsedLengthCm)
+	}
+}
+
+func TestNestHonoursTheCloth(t *testing.T) {
+	tall := []Piece{{Key: "sleeve", Quantity: 1, Contour: rect(10, 50), HasGrain: true}}
+	one := []SizeQuantity{{SizeId: 1, Quantity: 1}}
+
+	r, err := Nest(tall, one, Params{WidthCm: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PlacedCount != 0 || len(r.Warnings) != 1 {
+		t.Fatalf("placed %d, warnings %v: a piece wider than the strip on the grain must stay out", r.PlacedCount, r.Warnings)
+	}
+	r, err = Nest(tall, one, Params{WidthCm: 30, AllowCrossGrain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PlacedCount != 1 || r.Placements[0].RotDeg != 90 {
+		t.Fatalf("placements %+v, want one turned 90°", r.Placements)
+	}
+
+	pair := []Piece{{Key: "front", Quantity: 2, Mirrored: true, Contour: []Point{{0, 0}, {10, 0}, {0, 10}}, HasGrain: true}}
+	p := Params{WidthCm: 40, AllowFlip: true, AllowHalfTurn: true}
+	r, err = Nest(pair, []SizeQuantity{{SizeId: 1, Quantity: 2}}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := 0
+	for _, pl := range r.Placements {
+		if pl.Flipped != (pl.Instance%2 == 1) {
+			t.Fatalf("placement %+v: every other instance of a mirrored piece is the turned-over half", pl)
+		}
+		if pl.Flipped {
+			flipped++
+		}
+	}
+	if flipped != 2 {
+		t.Fatalf("flipped %d, want 2 of 4", flipped)
+	}
+
+	// One-way cloth: no half turn, no flip — the pair is cut face to face and says so.
+	r, err = Nest(pair, []SizeQuantity{{SizeId: 1, Quantity: 2}}, Params{WidthCm: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pl := range r.Placements {
+		if pl.Flipped || pl.RotDeg != 0 {
+			t.Fatalf("placement %+v on one-way cloth", pl)
+		}
+	}
+	if len(r.Warnings) != 1 {
+		t.Fatalf("warnings %v, want the face-to-face note", r.Warnings)
+	}
+}
+
+func TestNestAlignsTheGrain(t *testing.T) {
+	// Drawn 10 wide and 40 tall with the grain up the page: on a 20 cm strip it only fits once the
+	// grain is turned along the roll.
+	pc := Piece{Quantity: 1, Contour: rect(10, 40), GrainAngleDeg: 90, HasGrain: true}
+	r, err := Nest([]Piece{pc}, []SizeQuantity{{SizeId: 1, Quantity: 1}}, Params{WidthCm: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PlacedCount != 1 || r.Placements[0].RotDeg != 0 || math.Abs(r.UsedLengthCm-40) > 1e-6 {
+		t.Fatalf("placements %+v length %v, want one at 0° along 40 cm", r.Placements, r.UsedLengthCm)
+	}
+	_, _, w, h := bounds(r.Shapes[0])
+	if math.Abs(w-40) > 1e-6 || math.Abs(h-10) > 1e-6 || signedArea(r.Shapes[0]) <= 0 {
+		t.Fatalf("shape %v, want 40 × 10 counter-clockwise", r.Shapes[0])
+	}
+}
+
+func TestNestRefusals(t *testing.T) {
+	sq := []Piece{{Quantity: 1, Contour: rect(1, 1), HasGrain: true}}
+	one := []SizeQuantity{{SizeId: 1, Quantity: 1}}
+	cases := []struct {
+		name   string
+		pieces []Piece
+		comp   []SizeQuantity
+		p      Params
+		want   error
+	}{
+		{"margins eat the width", sq, one, Params{WidthCm: 10, EdgeMarginCm: 5}, ErrStripTooNarrow},
+		{"resolution", sq, one, Params{WidthCm: 10, ResolutionCm: 0.01}, ErrInvalidResolution},
+		{"size not in the composition", []Piece{{SizeId: 2, Quantity: 1, Contour: rect(1, 1)}}, one, Params{WidthCm: 10}, ErrNoPieces},
+		{"too many", sq, []SizeQuantity{{SizeId: 1, Quantity: MaxInstances + 1}}, Params{WidthCm: 10}, ErrTooManyInstances},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Nest(c.pieces, c.comp, c.p); !errors.Is(err, c.want) {
+				t.Fatalf("err = %v, want %v", err, c.want)
+			}
+		})
+	}
+}
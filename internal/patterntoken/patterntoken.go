@@ -7,14 +7,17 @@
 // Format: {scope}{id36}.{epoch36}.{sig}
 //   - scope is one byte: 'i' (internal — admin SPA), 'p' (print — tech-pack QR), 'c'
 //     (card viewer — the id names a TECH CARD, not a pattern_object_access row), 'r'
-//     (run pack — the id names a PRODUCTION RUN) or 'f' (library file — the id names a
-//     LIBRARY FILE).
+//     (run pack — the id names a PRODUCTION RUN), 'f' (library file — the id names a
+//     LIBRARY FILE), 'b' (bundle ticket — the id names a PRODUCTION RUN BUNDLE) or 's'
+//     (subcontract portal — the id names a PRODUCTION RUN on subcontract) or 't' (spec sheet —
+//     the id names a TECH CARD RELEASE).
 //     Scopes sign differently, so revoking a leaked paper tech-pack does not have to
 //     break the admin UI and vice versa (each scope can be re-epoched independently at a
 //     policy level later; today 'i'/'p' share the object row epoch, 'c' has its own row in
 //     tech_card_pattern_viewer_access, 'r' its own in production_run_pack_access and 'f'
-//     its own in library_file_public_access).
-//     Because 'c', 'r' and 'f' tokens carry DIFFERENT id namespaces, every handler must
+//     its own in library_file_public_access, 's' its own in production_run_subcontract, 't' its own in tech_card_release_spec_access;
+//     'b' has none, see ScopeBundle).
+//     Because 'c', 'r', 'f', 'b', 's' and 't' tokens carry DIFFERENT id namespaces, every handler must
 //     check the scope it serves — a card token looked up as an object id would resolve to
 //     an unrelated object, and a run token looked up as a card id would serve an unrelated
 //     card's manifest. The check is an allowlist per endpoint, never a denylist.
@@ -72,6 +75,23 @@ const (
 	// 'team' keeps its access row, and a token that only matched the epoch would outlive
 	// the decision to close the file.
 	ScopeFile Scope = 'f'
+	// ScopeBundle marks shop-floor bundle tickets (/api/bt/{token}, migration 0340): the id is
+	// a PRODUCTION RUN BUNDLE id (production_run_bundle.id), the fifth id namespace. There is
+	// no access row and no epoch to bump: bundles are replaced wholesale when a run is
+	// re-bundled, the ids are never reused, and a ticket whose bundle is gone is dead. Tokens
+	// are minted at epoch 1.
+	ScopeBundle Scope = 'b'
+	// ScopeSubcontract marks factory portal links (/api/cmt/{token}, migration 0344): the id is
+	// a PRODUCTION RUN id at the production_run_subcontract.portal_epoch, the sixth id
+	// namespace. It shares run ids with ScopeRunPack deliberately on a separate row: a run pack
+	// link handed to the cutting room must not let its holder confirm dates on the factory's
+	// behalf, and rotating the factory's link must not reprint the pack QR.
+	ScopeSubcontract Scope = 's'
+	// ScopeSpecSheet marks links to a release's PDF spec sheet (/api/ts/{token}, migration 0347):
+	// the id is a TECH CARD RELEASE id at the tech_card_release_spec_access epoch, the seventh id
+	// namespace. Per release, not per card: the link promises «Rev.N», and the next release is a
+	// different document with its own link.
+	ScopeSpecSheet Scope = 't'
 )
 
 // valid reports whether s is one of the known scopes. Parse refuses everything else, so an
@@ -80,7 +100,7 @@ const (
 // reject legitimate tokens of a scope that mints fine.
 func (s Scope) valid() bool {
 	switch s {
-	case ScopeInternal, ScopePrint, ScopeCard, ScopeRunPack, ScopeFile:
+	case ScopeInternal, ScopePrint, ScopeCard, ScopeRunPack, ScopeFile, ScopeBundle, ScopeSubcontract, ScopeSpecSheet:
 		return true
 	default:
 		return false
@@ -8,7 +8,7 @@ import (
 // allScopes is the list every scope test iterates. A new scope MUST be added here — the
 // completeness test below fails otherwise, so the list cannot silently fall behind the
 // Scope constants the way it did for 'r' (added in 0293, never reached these tables).
-var allScopes = []Scope{ScopeInternal, ScopePrint, ScopeCard, ScopeRunPack, ScopeFile}
+var allScopes = []Scope{ScopeInternal, ScopePrint, ScopeCard, ScopeRunPack, ScopeFile, ScopeBundle, ScopeSubcontract, ScopeSpecSheet}
 
 // TestScopeListIsComplete walks the whole byte space and demands that exactly the scopes
 // listed above pass Scope.valid(). Without it, adding a constant and forgetting either
@@ -600,7 +600,7 @@ func (p *Processor) GetOrderInvoice(ctx context.Context, orderUUID string) (*ent
 		}
 
 		// Set transaction amounts: order currency amount and payment currency amount (what was actually charged)
-		payment.TransactionAmount = of.Order.TotalPriceDecimal()
+		payment.TransactionAmount = of.Order.AmountDue()
 		payment.TransactionAmountPaymentCurrency = paymentCurrencyAmount
 
 		err = rep.Order().UpdateTotalPaymentCurrency(ctx, orderUUID, paymentCurrencyAmount)
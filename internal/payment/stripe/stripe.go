@@ -69,13 +69,14 @@ func (p *Processor) createPaymentIntent(order entity.OrderFull, idempotencyKey s
 	if !dto.IsStripeChargeable(order.Order.Currency) {
 		return nil, fmt.Errorf("currency %s cannot be charged via Stripe; it is settled manually", order.Order.Currency)
 	}
-	// Validate order total meets Stripe minimum (e.g. KRW >= 100)
-	if err := dto.ValidatePriceMeetsMinimum(order.Order.TotalPrice, order.Order.Currency); err != nil {
+	// Validate the amount due meets Stripe minimum (e.g. KRW >= 100). Store credit applied at
+	// checkout is already paid, so the card is charged only the remainder.
+	if err := dto.ValidatePriceMeetsMinimum(order.Order.AmountDue(), order.Order.Currency); err != nil {
 		return nil, fmt.Errorf("order total below currency minimum: %w", err)
 	}
 	// Use the order total directly - prices are already stored in the correct currency
 	// Calculate the order amount in smallest currency unit (cents for most currencies, but not for zero-decimal currencies like JPY, KRW)
-	amountCents := amountToSmallestUnit(order.Order.TotalPrice, order.Order.Currency)
+	amountCents := amountToSmallestUnit(order.Order.AmountDue(), order.Order.Currency)
 
 	params := &stripe.PaymentIntentParams{
 		Amount:   stripe.Int64(amountCents),
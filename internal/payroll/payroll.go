@@ -0,0 +1,107 @@
+// Package payroll computes piece-rate payslips from the work recorded on production-run bundles
+// (migration 0341). It is pure: the store loads the pay period and the month's work facts, Compute
+// turns them into payslip lines and totals, and the store writes the result.
+package payroll
+
+import (
+	"sort"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// Compute prices the month's work under the pay period.
+//
+// Each fact (one operation of one run) becomes one line:
+//   - piece_rate: the work's per-piece rate when the period has one (qty × rate), else the
+//     operation's standard minutes × the per-minute rate;
+//   - efficiency: standard minutes × the per-minute rate, per-piece rates ignored.
+//
+// Work that fits neither (no rate for the work and no SMV, or no per-minute rate) is a line with
+// basis unpriced and amount zero, counted in UnpricedQty: it is shown to whoever approves, never
+// priced by guess.
+//
+// Standard minutes are summed over every fact with an SMV, whatever the line's basis — they are
+// what the efficiency reads. The guaranteed pay (attended minutes × guaranteed rate) is a floor
+// under the earnings, not an addition to them; the adjustment is added after the floor.
+//
+// Line amounts are rounded to cents first and the earnings are their sum, so the payslip adds up
+// on paper.
+func Compute(period entity.EmployeePayPeriodInsert, facts []entity.PayrollWorkFact,
+	attended decimal.NullDecimal, adjustment decimal.Decimal) ([]entity.PayslipLine, entity.PayslipTotals, error) {
+
+	var totals entity.PayslipTotals
+	if attended.Valid && attended.Decimal.IsNegative() {
+		return nil, totals, entity.NewFieldViolation("attended_minutes", "negative",
+			attended.Decimal.String(), "enter the minutes attended in the month, or leave empty")
+	}
+	if period.Scheme == entity.PaySchemeEfficiency && !attended.Valid {
+		return nil, totals, entity.NewFieldViolation("attended_minutes", "required_for_efficiency", "",
+			"an efficiency pay period needs the minutes attended in the month")
+	}
+
+	pieceRates := make(map[string]decimal.Decimal, len(period.PieceRates))
+	if period.Scheme == entity.PaySchemePieceRate {
+		for _, r := range period.PieceRates {
+			pieceRates[r.Work] = r.RatePerPiece
+		}
+	}
+
+	sorted := append([]entity.PayrollWorkFact(nil), facts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].RunId != sorted[j].RunId {
+			return sorted[i].RunId < sorted[j].RunId
+		}
+		return sorted[i].OperationSeq < sorted[j].OperationSeq
+	})
+
+	lines := make([]entity.PayslipLine, 0, len(sorted))
+	for _, f := range sorted {
+		if f.Qty <= 0 {
+			continue
+		}
+		qty := decimal.NewFromInt(int64(f.Qty))
+		l := entity.PayslipLine{
+			RunId:         f.RunId,
+			OperationSeq:  f.OperationSeq,
+			OperationType: f.OperationType,
+			Work:          f.Work,
+			Qty:           f.Qty,
+			Bundles:       f.Bundles,
+			SMV:           f.SMV,
+			Basis:         entity.PayslipLineBasisUnpriced,
+		}
+		if f.SMV.Valid {
+			l.StandardMinutes = decimal.NewNullDecimal(qty.Mul(f.SMV.Decimal).Round(4))
+			totals.StandardMinutes = totals.StandardMinutes.Add(l.StandardMinutes.Decimal)
+		}
+		if rate, ok := pieceRates[f.Work.String]; ok && f.Work.Valid {
+			l.Basis = entity.PayslipLineBasisPiece
+			l.Rate = decimal.NewNullDecimal(rate)
+			l.Amount = qty.Mul(rate).Round(2)
+		} else if l.StandardMinutes.Valid && period.RatePerMinute.Valid {
+			l.Basis = entity.PayslipLineBasisMinute
+			l.Rate = period.RatePerMinute
+			l.Amount = l.StandardMinutes.Decimal.Mul(period.RatePerMinute.Decimal).Round(2)
+		} else {
+			totals.UnpricedQty += f.Qty
+		}
+		totals.PieceEarnings = totals.PieceEarnings.Add(l.Amount)
+		lines = append(lines, l)
+	}
+
+	base := totals.PieceEarnings
+	if attended.Valid && period.GuaranteedRatePerMinute.Valid {
+		g := attended.Decimal.Mul(period.GuaranteedRatePerMinute.Decimal).Round(2)
+		totals.GuaranteedPay = decimal.NewNullDecimal(g)
+		if g.GreaterThan(base) {
+			base = g
+		}
+	}
+	totals.Gross = base.Add(adjustment.Round(2))
+	if totals.Gross.IsNegative() {
+		return nil, entity.PayslipTotals{}, entity.NewFieldViolation("adjustment", "gross_negative",
+			adjustment.String(), "a deduction cannot exceed the month's earnings")
+	}
+	return lines, totals, nil
+}
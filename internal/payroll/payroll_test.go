@@ -0,0 +1,123 @@
+package payroll
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+func d(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func nd(s string) decimal.NullDecimal { return decimal.NewNullDecimal(d(s)) }
+
+func work(token string) sql.NullString { return sql.NullString{String: token, Valid: true} }
+
+func pieceRatePeriod() entity.EmployeePayPeriodInsert {
+	return entity.EmployeePayPeriodInsert{
+		Scheme:        entity.PaySchemePieceRate,
+		Currency:      "PLN",
+		RatePerMinute: nd("0.50"),
+		PieceRates:    []entity.EmployeePieceRate{{Work: "button_attach", RatePerPiece: d("0.30")}},
+	}
+}
+
+func monthFacts() []entity.PayrollWorkFact {
+	return []entity.PayrollWorkFact{
+		{RunId: 2, OperationSeq: 1, OperationType: "seam", Work: work("side_seam"), SMV: nd("1.2"), Qty: 40, Bundles: 2},
+		{RunId: 1, OperationSeq: 3, OperationType: "attach", Work: work("button_attach"), SMV: nd("0.4"), Qty: 100, Bundles: 5},
+		{RunId: 1, OperationSeq: 4, OperationType: "hem", Qty: 20, Bundles: 1}, // ни ставки, ни нормы
+	}
+}
+
+func TestComputePieceRate(t *testing.T) {
+	lines, tot, err := Compute(pieceRatePeriod(), monthFacts(), decimal.NullDecimal{}, decimal.Zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("lines = %d, want 3", len(lines))
+	}
+	// Sorted by run, then operation.
+	if lines[0].RunId != 1 || lines[0].OperationSeq != 3 || lines[2].RunId != 2 {
+		t.Fatalf("order = %+v", lines)
+	}
+	// button_attach has a per-piece rate: 100 × 0.30.
+	if lines[0].Basis != entity.PayslipLineBasisPiece || !lines[0].Amount.Equal(d("30")) {
+		t.Fatalf("piece line = %s %s", lines[0].Basis, lines[0].Amount)
+	}
+	// hem has neither a rate nor an SMV.
+	if lines[1].Basis != entity.PayslipLineBasisUnpriced || !lines[1].Amount.IsZero() {
+		t.Fatalf("unpriced line = %s %s", lines[1].Basis, lines[1].Amount)
+	}
+	// side_seam falls back to minutes: 40 × 1.2 = 48 min × 0.50.
+	if lines[2].Basis != entity.PayslipLineBasisMinute || !lines[2].Amount.Equal(d("24")) {
+		t.Fatalf("minute line = %s %s", lines[2].Basis, lines[2].Amount)
+	}
+	// Standard minutes count the piece-rated work too: 48 + 40.
+	if !tot.StandardMinutes.Equal(d("88")) {
+		t.Fatalf("standard minutes = %s, want 88", tot.StandardMinutes)
+	}
+	if !tot.PieceEarnings.Equal(d("54")) || !tot.Gross.Equal(d("54")) || tot.UnpricedQty != 20 {
+		t.Fatalf("totals = %+v", tot)
+	}
+}
+
+func TestComputeGuaranteeIsAFloor(t *testing.T) {
+	p := pieceRatePeriod()
+	p.GuaranteedRatePerMinute = nd("0.10")
+
+	// 600 attended min × 0.10 = 60 > 54 earned: the floor pays, the adjustment goes on top.
+	_, tot, err := Compute(p, monthFacts(), nd("600"), d("5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tot.GuaranteedPay.Valid || !tot.GuaranteedPay.Decimal.Equal(d("60")) || !tot.Gross.Equal(d("65")) {
+		t.Fatalf("totals = %+v", tot)
+	}
+
+	// 300 × 0.10 = 30 < 54: earnings pay.
+	_, tot, err = Compute(p, monthFacts(), nd("300"), decimal.Zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tot.Gross.Equal(d("54")) {
+		t.Fatalf("gross = %s, want 54", tot.Gross)
+	}
+}
+
+func TestComputeEfficiencyIgnoresPieceRates(t *testing.T) {
+	p := pieceRatePeriod()
+	p.Scheme = entity.PaySchemeEfficiency
+	lines, tot, err := Compute(p, monthFacts(), nd("176"), decimal.Zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines[0].Basis != entity.PayslipLineBasisMinute || !lines[0].Amount.Equal(d("20")) {
+		t.Fatalf("button_attach under efficiency = %s %s, want minute 20", lines[0].Basis, lines[0].Amount)
+	}
+	if !tot.Gross.Equal(d("44")) {
+		t.Fatalf("gross = %s, want 44", tot.Gross)
+	}
+	slip := entity.Payslip{AttendedMinutes: nd("176"), PayslipTotals: tot}
+	if eff := slip.EfficiencyPct(); !eff.Valid || !eff.Decimal.Equal(d("50")) {
+		t.Fatalf("efficiency = %v, want 50", eff)
+	}
+}
+
+func TestComputeRefusals(t *testing.T) {
+	p := pieceRatePeriod()
+	p.Scheme = entity.PaySchemeEfficiency
+	var ve *entity.ValidationError
+	if _, _, err := Compute(p, monthFacts(), decimal.NullDecimal{}, decimal.Zero); !errors.As(err, &ve) {
+		t.Fatalf("efficiency without attended minutes: err = %v", err)
+	}
+	if _, _, err := Compute(pieceRatePeriod(), monthFacts(), nd("-1"), decimal.Zero); !errors.As(err, &ve) {
+		t.Fatalf("negative attended minutes: err = %v", err)
+	}
+	if _, _, err := Compute(pieceRatePeriod(), monthFacts(), decimal.NullDecimal{}, d("-60")); !errors.As(err, &ve) {
+		t.Fatalf("deduction above earnings: err = %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package qcsampling holds the single sampling plans of ISO 2859-1 (normal inspection) that the
+// workshop's QC inspections are drawn by (0343), and the verdict a plan gives over recorded
+// defects. It is pure: the handler asks for a plan when an inspection is opened, stores it on the
+// row, and asks for the verdict when the findings are recorded.
+//
+// Only the two AQLs the workshop inspects at are tabulated — 2.5 and 4.0, conventionally major and
+// minor — and only the general inspection levels. A plan is read in two steps: the lot size and the
+// level give a code letter (Table 1), the code letter and the AQL give a sample size and the
+// acceptance and rejection numbers (Table 2-A). Where Table 2-A has an arrow instead of numbers the
+// first plan in the arrow's direction is used, WITH ITS OWN sample size.
+//
+// One inspection covers both classes with one sample, so when the arrows leave the two classes with
+// different sample sizes the larger one is drawn and both classes keep their numbers. That is
+// stricter than the standard for the class whose plan was smaller — the same acceptance number over
+// more garments — and it is the usual practice of apparel inspection. Critical defects accept zero
+// whatever the plan.
+package qcsampling
+
+import (
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// codeLetters are the sample size code letters of Table 2-A, and sampleSizes their sample sizes.
+var (
+	codeLetters = [...]string{"A", "B", "C", "D", "E", "F", "G", "H", "J", "K", "L", "M", "N", "P", "Q", "R"}
+	sampleSizes = [...]int{2, 3, 5, 8, 13, 20, 32, 50, 80, 125, 200, 315, 500, 800, 1250, 2000}
+)
+
+// lotUpper are the upper bounds of the lot size bands of Table 1; the last band is open.
+var lotUpper = [...]int{8, 15, 25, 50, 90, 150, 280, 500, 1200, 3200, 10000, 35000, 150000, 500000}
+
+// levelLetters is Table 1: the code letter index per lot band, per general inspection level.
+var levelLetters = map[entity.QcInspectionLevel][len(lotUpper) + 1]int{
+	entity.QcInspectionLevelI:   {0, 0, 1, 2, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+	entity.QcInspectionLevelII:  {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14},
+	entity.QcInspectionLevelIII: {1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+}
+
+// cell is one cell of Table 2-A: an Ac/Re pair, or an arrow pointing down (to a larger sample) or
+// up (to a smaller one).
+type cell struct {
+	ac, re int
+	arrow  int // +1 down, -1 up, 0 numbers
+}
+
+var (
+	down = cell{arrow: 1}
+	up   = cell{arrow: -1}
+)
+
+func ar(ac, re int) cell { return cell{ac: ac, re: re} }
+
+// columns are the AQL columns of Table 2-A, one cell per code letter A..R.
+var columns = map[entity.QcAql][len(codeLetters)]cell{
+	entity.QcAql25: {down, down, ar(0, 1), up, down, ar(1, 2), ar(2, 3), ar(3, 4),
+		ar(5, 6), ar(7, 8), ar(10, 11), ar(14, 15), ar(21, 22), up, up, up},
+	entity.QcAql40: {down, ar(0, 1), up, down, ar(1, 2), ar(2, 3), ar(3, 4), ar(5, 6),
+		ar(7, 8), ar(10, 11), ar(14, 15), ar(21, 22), up, up, up, up},
+}
+
+// classPlan is what one AQL column gives one code letter after following its arrow.
+type classPlan struct {
+	sample int
+	ac, re int
+}
+
+func resolve(aql entity.QcAql, letter int) classPlan {
+	col := columns[aql]
+	i := letter
+	for col[i].arrow != 0 {
+		i += col[i].arrow
+	}
+	return classPlan{sample: sampleSizes[i], ac: col[i].ac, re: col[i].re}
+}
+
+// codeLetter returns the index of the code letter of a lot at a level (Table 1).
+func codeLetter(lotSize int, level entity.QcInspectionLevel) int {
+	band := len(lotUpper)
+	for i, upper := range lotUpper {
+		if lotSize <= upper {
+			band = i
+			break
+		}
+	}
+	return levelLetters[level][band]
+}
+
+// Plan returns the sampling plan of a lot: the code letter, the garments to draw and the Ac/Re
+// numbers of the two classes. A plan asking for at least the lot becomes a full inspection of it.
+func Plan(lotSize int, level entity.QcInspectionLevel, major, minor entity.QcAql) (entity.QcSamplingPlan, error) {
+	if lotSize < 1 {
+		return entity.QcSamplingPlan{}, entity.NewFieldViolation("lot_size", "must_be_positive",
+			fmt.Sprint(lotSize), "a lot has at least one garment")
+	}
+	if !entity.ValidQcInspectionLevel(level) {
+		return entity.QcSamplingPlan{}, entity.NewFieldViolation("inspection_level", "unknown_level",
+			string(level), "use inspection level I, II or III")
+	}
+	for _, a := range []struct {
+		field string
+		aql   entity.QcAql
+	}{{"aql_major", major}, {"aql_minor", minor}} {
+		if !entity.ValidQcAql(a.aql) {
+			return entity.QcSamplingPlan{}, entity.NewFieldViolation(a.field, "unknown_aql",
+				string(a.aql), "use AQL 2.5 or 4.0")
+		}
+	}
+
+	letter := codeLetter(lotSize, level)
+	mj, mn := resolve(major, letter), resolve(minor, letter)
+	p := entity.QcSamplingPlan{
+		CodeLetter:  codeLetters[letter],
+		SampleSize:  max(mj.sample, mn.sample),
+		MajorAccept: mj.ac,
+		MajorReject: mj.re,
+		MinorAccept: mn.ac,
+		MinorReject: mn.re,
+	}
+	if p.SampleSize >= lotSize {
+		p.SampleSize = lotSize
+		p.FullInspection = true
+	}
+	return p, nil
+}
+
+// Verdict decides a lot from the defects found in its sample: any critical defect fails it, and so
+// does either class reaching its rejection number. The reasons name every class that failed, in
+// severity order; a passed lot has none.
+func Verdict(p entity.QcSamplingPlan, found entity.QcDefectCounts) (entity.QcInspectionResult, []string) {
+	var reasons []string
+	if found.Critical > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d critical defect(s); critical defects accept none", found.Critical))
+	}
+	if found.Major >= p.MajorReject {
+		reasons = append(reasons, fmt.Sprintf("%d major defect(s) reach the rejection number %d", found.Major, p.MajorReject))
+	}
+	if found.Minor >= p.MinorReject {
+		reasons = append(reasons, fmt.Sprintf("%d minor defect(s) reach the rejection number %d", found.Minor, p.MinorReject))
+	}
+	if len(reasons) > 0 {
+		return entity.QcInspectionFailed, reasons
+	}
+	return entity.QcInspectionPassed, nil
+}
+
+// Count sums the findings of an inspection per class, by the severity snapshotted on each finding.
+func Count(defects []entity.QcInspectionDefect) entity.QcDefectCounts {
+	var c entity.QcDefectCounts
+	for _, d := range defects {
+		switch d.Severity {
+		case entity.QcDefectCritical:
+			c.Critical += d.Qty
+		case entity.QcDefectMajor:
+			c.Major += d.Qty
+		case entity.QcDefectMinor:
+			c.Minor += d.Qty
+		}
+	}
+	return c
+}
@@ -0,0 +1,100 @@
+package qcsampling
+
+import (
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanGeneralLevelII(t *testing.T) {
+	// Lot size → (code letter, sample, major Ac/Re at 2.5, minor Ac/Re at 4.0), level II.
+	cases := []struct {
+		lot            int
+		letter         string
+		sample         int
+		majAc, majRe   int
+		minAc, minRe   int
+		fullInspection bool
+	}{
+		{lot: 100, letter: "F", sample: 20, majAc: 1, majRe: 2, minAc: 2, minRe: 3},
+		{lot: 200, letter: "G", sample: 32, majAc: 2, majRe: 3, minAc: 3, minRe: 4},
+		{lot: 500, letter: "H", sample: 50, majAc: 3, majRe: 4, minAc: 5, minRe: 6},
+		{lot: 1000, letter: "J", sample: 80, majAc: 5, majRe: 6, minAc: 7, minRe: 8},
+		{lot: 3200, letter: "K", sample: 125, majAc: 7, majRe: 8, minAc: 10, minRe: 11},
+		{lot: 10000, letter: "L", sample: 200, majAc: 10, majRe: 11, minAc: 14, minRe: 15},
+		// M: 4.0 still has numbers at 315, 2.5 too.
+		{lot: 20000, letter: "M", sample: 315, majAc: 14, majRe: 15, minAc: 21, minRe: 22},
+		// N: 4.0 points up to M (315), 2.5 has its own 500 — the larger sample is drawn.
+		{lot: 100000, letter: "N", sample: 500, majAc: 21, majRe: 22, minAc: 21, minRe: 22},
+		// E: 2.5 points down to F (20 pcs, 1/2), 4.0 has 13 pcs 1/2 — 20 are drawn.
+		{lot: 60, letter: "E", sample: 20, majAc: 1, majRe: 2, minAc: 1, minRe: 2},
+		// D: 2.5 points up to C (5 pcs, 0/1), 4.0 down to E (13 pcs, 1/2).
+		{lot: 40, letter: "D", sample: 13, majAc: 0, majRe: 1, minAc: 1, minRe: 2},
+		// A small lot: the plan asks for more garments than there are — everything is inspected.
+		{lot: 4, letter: "A", sample: 4, majAc: 0, majRe: 1, minAc: 0, minRe: 1, fullInspection: true},
+	}
+	for _, c := range cases {
+		p, err := Plan(c.lot, entity.QcInspectionLevelII, entity.QcAql25, entity.QcAql40)
+		require.NoError(t, err, "lot %d", c.lot)
+		require.Equal(t, entity.QcSamplingPlan{
+			CodeLetter: c.letter, SampleSize: c.sample, FullInspection: c.fullInspection,
+			MajorAccept: c.majAc, MajorReject: c.majRe, MinorAccept: c.minAc, MinorReject: c.minRe,
+		}, p, "lot %d", c.lot)
+	}
+}
+
+func TestPlanLevelShiftsCodeLetter(t *testing.T) {
+	low, err := Plan(1000, entity.QcInspectionLevelI, entity.QcAql25, entity.QcAql25)
+	require.NoError(t, err)
+	require.Equal(t, "G", low.CodeLetter)
+	require.Equal(t, 32, low.SampleSize)
+
+	high, err := Plan(1000, entity.QcInspectionLevelIII, entity.QcAql40, entity.QcAql40)
+	require.NoError(t, err)
+	require.Equal(t, "K", high.CodeLetter)
+	require.Equal(t, 125, high.SampleSize)
+	require.Equal(t, 10, high.MajorAccept)
+	require.Equal(t, 11, high.MinorReject)
+}
+
+func TestPlanRejectsUnknownInputs(t *testing.T) {
+	_, err := Plan(0, entity.QcInspectionLevelII, entity.QcAql25, entity.QcAql40)
+	require.Error(t, err)
+	_, err = Plan(100, "IV", entity.QcAql25, entity.QcAql40)
+	require.Error(t, err)
+	_, err = Plan(100, entity.QcInspectionLevelII, "1.0", entity.QcAql40)
+	require.Error(t, err)
+}
+
+func TestVerdict(t *testing.T) {
+	p, err := Plan(1000, entity.QcInspectionLevelII, entity.QcAql25, entity.QcAql40) // 80 pcs, 5/6, 7/8
+	require.NoError(t, err)
+
+	res, reasons := Verdict(p, entity.QcDefectCounts{Major: 5, Minor: 7})
+	require.Equal(t, entity.QcInspectionPassed, res)
+	require.Empty(t, reasons)
+
+	res, reasons = Verdict(p, entity.QcDefectCounts{Major: 6, Minor: 7})
+	require.Equal(t, entity.QcInspectionFailed, res)
+	require.Len(t, reasons, 1)
+
+	// One critical defect fails a lot that is otherwise clean.
+	res, reasons = Verdict(p, entity.QcDefectCounts{Critical: 1})
+	require.Equal(t, entity.QcInspectionFailed, res)
+	require.Len(t, reasons, 1)
+
+	res, reasons = Verdict(p, entity.QcDefectCounts{Critical: 1, Major: 9, Minor: 8})
+	require.Equal(t, entity.QcInspectionFailed, res)
+	require.Len(t, reasons, 3)
+}
+
+func TestCountBySnapshotSeverity(t *testing.T) {
+	c := Count([]entity.QcInspectionDefect{
+		{Severity: entity.QcDefectMajor, Qty: 2},
+		{Severity: entity.QcDefectMinor, Qty: 3},
+		{Severity: entity.QcDefectMajor, Qty: 1},
+		{Severity: entity.QcDefectCritical, Qty: 1},
+	})
+	require.Equal(t, entity.QcDefectCounts{Critical: 1, Major: 3, Minor: 3}, c)
+}
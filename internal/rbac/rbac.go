@@ -215,11 +215,13 @@ var methodRequirements = map[string]Requirement{
 	"ListCountries":     rd(SectionDictionaries),
 	"SetCountryActive":  wr(SectionDictionaries),
 	// promo
-	"AddPromo":         wr(SectionPromo),
-	"ListPromos":       rd(SectionPromo),
-	"DeletePromoCode":  wr(SectionPromo),
-	"DisablePromoCode": wr(SectionPromo),
-	"UpdatePromoCode":  wr(SectionPromo),
+	"AddPromo":                 wr(SectionPromo),
+	"ListPromos":               rd(SectionPromo),
+	"DeletePromoCode":          wr(SectionPromo),
+	"DisablePromoCode":         wr(SectionPromo),
+	"UpdatePromoCode":          wr(SectionPromo),
+	"GeneratePromoUniqueCodes": wr(SectionPromo),
+	"ListPromoUniqueCodes":     rd(SectionPromo),
 	// orders
 	"GetOrderByUUID":        rd(SectionOrders),
 	"ListOrders":            rd(SectionOrders),
@@ -232,6 +234,20 @@ var methodRequirements = map[string]Requirement{
 	"CancelOrder":           wr(SectionOrders),
 	"AddOrderComment":       wr(SectionOrders),
 	"CreateCustomOrder":     wr(SectionOrders),
+	// returns (RMA): the inspect step refunds money, so the queue sits with orders, not support
+	"GetReturnRequestById":   rd(SectionOrders),
+	"GetReturnRequestsPaged": rd(SectionOrders),
+	"ApproveReturnRequest":   wr(SectionOrders),
+	"RejectReturnRequest":    wr(SectionOrders),
+	"ReceiveReturnRequest":   wr(SectionOrders),
+	"InspectReturnRequest":   wr(SectionOrders),
+	// gift cards / store credit: the balance is a liability settled against orders
+	"GetStoreCreditWalletById":   rd(SectionOrders),
+	"GetStoreCreditWalletsPaged": rd(SectionOrders),
+	"AdjustStoreCreditWallet":    wr(SectionOrders),
+	"SetStoreCreditWalletStatus": wr(SectionOrders),
+	"CreditStoreCreditAccount":   wr(SectionOrders),
+	"SetGiftCardProduct":         wr(SectionOrders),
 	// analytics
 	"GetMetrics":             rd(SectionAnalytics),
 	"GetDashboard":           rd(SectionAnalytics),
@@ -256,6 +272,17 @@ var methodRequirements = map[string]Requirement{
 	"ListEmployees":       rd(SectionAnalytics),
 	"GetAlertSettings":    rd(SectionAnalytics),
 	"UpsertAlertSettings": wr(SectionAnalytics),
+	// Piece-rate payroll (0341) — pay periods and payslips of the registry's people, same gating: what
+	// a person earns is as confidential as their salary template.
+	"UpsertEmployeePayPeriod": wr(SectionAnalytics),
+	"DeleteEmployeePayPeriod": wr(SectionAnalytics),
+	"ListEmployeePayPeriods":  rd(SectionAnalytics),
+	"ComputePayslip":          wr(SectionAnalytics),
+	"ApprovePayslip":          wr(SectionAnalytics),
+	"ReopenPayslip":           wr(SectionAnalytics),
+	"DeletePayslip":           wr(SectionAnalytics),
+	"GetPayslip":              rd(SectionAnalytics),
+	"ListPayslips":            rd(SectionAnalytics),
 	// VAT rates feed the tax engine (declarations/JPK), not business metrics — governed by
 	// accounting for segregation of duties (D-5), so a metrics-only operator can't move tax numbers.
 	"GetVatRates":    rd(SectionAccounting),
@@ -299,6 +326,8 @@ var methodRequirements = map[string]Requirement{
 	"GetCampaignDispatchStatus":  rd(SectionCampaigns),
 	"GetCampaignMetrics":         rd(SectionCampaigns),
 	"GetCampaignRecipients":      rd(SectionCampaigns),
+	// abandoned checkout recovery (0352): the automated sequence is reported next to the campaigns.
+	"GetCheckoutRecoveryMetrics": rd(SectionCampaigns),
 	// models
 	"AddModel":    wr(SectionModels),
 	"GetModel":    rd(SectionModels),
@@ -365,6 +394,9 @@ var methodRequirements = map[string]Requirement{
 	"ListCostingMigrationExceptions": rd(SectionTechCards),
 	"ListTechCardReleases":           rd(SectionTechCards),
 	"GetTechCardRelease":             rd(SectionTechCards),
+	// PDF spec sheet: money-free by construction (cutspec), so a plain read; the share link is a write.
+	"ExportTechCardReleaseSpecSheet": rd(SectionTechCards),
+	"ShareTechCardReleaseSpecSheet":  wr(SectionTechCards),
 	"AddTechCardDevExpense":          wr(SectionTechCards),
 	"DeleteTechCardDevExpense":       wr(SectionTechCards),
 	"ListTechCardDevExpenses":        rd(SectionTechCards),
@@ -397,6 +429,18 @@ var methodRequirements = map[string]Requirement{
 	// Плановик производства не должен уметь его переписать: право переписать площади — это право
 	// снять себе блокер.
 	"SaveTechCardPieceAreas": wr(SectionTechCards),
+	// Замер выкроек сервером: тот же уровень, что обе записи, которые он умеет делать (apply).
+	// Сухой прогон только читает, но отдаёт геометрию файлов карточки — это содержимое вкладки
+	// выкроек, не производственный отчёт.
+	"MeasureTechCardPatternScope": wr(SectionTechCards),
+	// Раскладка сервером: читает те же файлы, что замер, и с save=true пишет раскладку — уровень
+	// SaveTechCardMarker, через который она и сохраняется.
+	"NestTechCardMarker": wr(SectionTechCards),
+	// Градация лекал: правила читает вкладка выкроек; сама градация с apply пишет площади — уровень
+	// SaveTechCardPieceAreas.
+	"GetTechCardPatternGradeRules":  rd(SectionTechCards),
+	"SaveTechCardPatternGradeRules": wr(SectionTechCards),
+	"GradeTechCardPatternScope":     wr(SectionTechCards),
 	// НАПРАВЛЕНИЕ ТКАНИ gap report (Ф1.8) — tech-cards READ, and specifically not production nor a
 	// section of its own. Every field it returns is BOM-tab content the same account already reads
 	// card by card through GetTechCard (line name, section, назначение, семпловая, approval state);
@@ -443,6 +487,9 @@ var methodRequirements = map[string]Requirement{
 	"SaveProductionRunLay":   wr(SectionProduction),
 	"DeleteProductionRunLay": wr(SectionProduction),
 	"ListProductionRunLays":  rd(SectionProduction),
+	// Подбор настилов: предложение — чтение плана, применение — те же Save, что у редактора.
+	"SuggestProductionRunLays":        rd(SectionProduction),
+	"ApplyProductionRunLaySuggestion": wr(SectionProduction),
 	// ПРИЁМКА КРОЯ (Ф5б.5) — та же секция и по тому же доводу: выкроенное и принятое в пошив считает
 	// тот, кто ведёт цех. Чтение НЕ в allowlist — в ответе едут количества прогона.
 	//
@@ -452,6 +499,12 @@ var methodRequirements = map[string]Requirement{
 	"SaveProductionRunCutReceipt":   wr(SectionProduction),
 	"DeleteProductionRunCutReceipt": wr(SectionProduction),
 	"ListProductionRunCutReceipts":  rd(SectionProduction),
+	// ПАЧКИ КРОЯ (0340) — там же. Отметка из админки — запись, WIP и список пачек — чтение цеха.
+	"GenerateProductionRunBundles":  wr(SectionProduction),
+	"ListProductionRunBundles":      rd(SectionProduction),
+	"RecordProductionRunBundleScan": wr(SectionProduction),
+	"DeleteProductionRunBundleScan": wr(SectionProduction),
+	"GetProductionRunWip":           rd(SectionProduction),
 	// КАЛИБРОВКА КОЭФФИЦИЕНТА РАСКРОЯ (Ф5б.3) — production READ, ХОТЯ ЖИВЁТ НА ЭКРАНЕ АРТИКУЛА.
 	//
 	// Секция следует за тем, ЧТО В ОТВЕТЕ, а не за тем, где кнопка. А в ответе — разбор по настилам:
@@ -494,6 +547,38 @@ var methodRequirements = map[string]Requirement{
 	// The READ is not here — it is allowlisted, see the allowlist below for why a single section
 	// gate could not serve both of its readers.
 	"UpdateWorkshopSettings": wr(SectionProduction),
+	// ПЛАН ЗАГРУЗКИ И КАЛЕНДАРЬ ЦЕХА (0342). План — чтение: сервер ничего не пишет, предложенные даты
+	// переносит в прогон рука через UpdateProductionRun. Праздники меняют мощность каждого дня, поэтому
+	// их пишет тот же, кто настраивает цех.
+	"GetProductionSchedule": rd(SectionProduction),
+	"ListWorkshopHolidays":  rd(SectionProduction),
+	"UpsertWorkshopHoliday": wr(SectionProduction),
+	"DeleteWorkshopHoliday": wr(SectionProduction),
+	// КОНТРОЛЬ КАЧЕСТВА ПРИЁМКИ (0343). Каталог и инспекции пишет производство; сама приёмка против
+	// инспекции — по-прежнему PostProductionRunReceipt со всеми её гейтами.
+	"ListQcDefectTypes":               rd(SectionProduction),
+	"CreateQcDefectType":              wr(SectionProduction),
+	"UpdateQcDefectType":              wr(SectionProduction),
+	"SetQcDefectTypeRetired":          wr(SectionProduction),
+	"PreviewQcSamplingPlan":           rd(SectionProduction),
+	"CreateProductionRunQcInspection": wr(SectionProduction),
+	"ListProductionRunQcInspections":  rd(SectionProduction),
+	"RecordProductionRunQcFindings":   wr(SectionProduction),
+	"DeleteProductionRunQcInspection": wr(SectionProduction),
+	"GetQcDefectAnalytics":            rd(SectionProduction),
+	// ПОДРЯД НА ПОШИВ (0344). Деньги соглашений дополнительно гейтит costing в самом хендлере.
+	"ListSubcontractors":                   rd(SectionProduction),
+	"UpsertSubcontractor":                  wr(SectionProduction),
+	"SetSubcontractorArchived":             wr(SectionProduction),
+	"ListCmtPriceAgreements":               rd(SectionProduction),
+	"CreateCmtPriceAgreement":              wr(SectionProduction),
+	"CloseCmtPriceAgreement":               wr(SectionProduction),
+	"AssignProductionRunSubcontractor":     wr(SectionProduction),
+	"GetProductionRunSubcontract":          rd(SectionProduction),
+	"UpdateProductionRunSubcontractPortal": wr(SectionProduction),
+	"ShipProductionRunKit":                 wr(SectionProduction),
+	"ReturnProductionRunKit":               wr(SectionProduction),
+	"ReconcileProductionRunSubcontract":    wr(SectionProduction),
 	// material warehouse (new-flow NF-01)
 	"ReceiveMaterialStock":    wr(SectionInventory),
 	"IssueMaterialStock":      wr(SectionInventory),
@@ -508,8 +593,47 @@ var methodRequirements = map[string]Requirement{
 	// packaging recipe per product/style + global fallback (PLM rework §2.8, Q3)
 	"UpsertPackagingRecipe": wr(SectionInventory),
 	"ListPackagingRecipe":   rd(SectionInventory),
-	// structured lots / rolls (gap-07 v2 D)
-	"ListMaterialLots": rd(SectionInventory),
+	// structured lots / rolls (gap-07 v2 D) and their lab results (0348)
+	"ListMaterialLots":         rd(SectionInventory),
+	"RecordMaterialLotLabTest": wr(SectionInventory),
+	"ListMaterialLotLabTests":  rd(SectionInventory),
+	// material purchase orders (0336). Suggest writes drafts when asked to, so it is a write.
+	"CreatePurchaseOrder":    wr(SectionInventory),
+	"UpdatePurchaseOrder":    wr(SectionInventory),
+	"GetPurchaseOrder":       rd(SectionInventory),
+	"ListPurchaseOrders":     rd(SectionInventory),
+	"SetPurchaseOrderStatus": wr(SectionInventory),
+	"ReceivePurchaseOrder":   wr(SectionInventory),
+	"SuggestPurchaseOrders":  wr(SectionInventory),
+	// stock locations and transfers between them (0345). A transfer moves finished goods as well as
+	// materials, but it is a warehouse document — the inventory section, not products.
+	"ListStockLocations":       rd(SectionInventory),
+	"CreateStockLocation":      wr(SectionInventory),
+	"UpdateStockLocation":      wr(SectionInventory),
+	"SetStockLocationArchived": wr(SectionInventory),
+	"ListLocationStock":        rd(SectionInventory),
+	"CreateStockTransfer":      wr(SectionInventory),
+	"ListStockTransfers":       rd(SectionInventory),
+	"GetStockTransfer":         rd(SectionInventory),
+	"DispatchStockTransfer":    wr(SectionInventory),
+	"ReceiveStockTransfer":     wr(SectionInventory),
+	"CancelStockTransfer":      wr(SectionInventory),
+	// market price lists and scheduled markdowns (0349): both write colourway prices, so products.
+	"ListPriceLists":       rd(SectionProducts),
+	"GetPriceList":         rd(SectionProducts),
+	"CreatePriceList":      wr(SectionProducts),
+	"UpdatePriceList":      wr(SectionProducts),
+	"DeletePriceList":      wr(SectionProducts),
+	"PreviewPriceList":     rd(SectionProducts),
+	"PublishPriceList":     wr(SectionProducts),
+	"ListMarkdownEvents":   rd(SectionProducts),
+	"GetMarkdownEvent":     rd(SectionProducts),
+	"CreateMarkdownEvent":  wr(SectionProducts),
+	"UpdateMarkdownEvent":  wr(SectionProducts),
+	"CancelMarkdownEvent":  wr(SectionProducts),
+	"PreviewMarkdownEvent": rd(SectionProducts),
+	// customer wishlists (0350): a demand signal read next to the other business metrics.
+	"ListMostWishedStyles": rd(SectionAnalytics),
 	// tasks (internal team kanban)
 	"AddTask":          wr(SectionTasks),
 	"GetTask":          rd(SectionTasks),
@@ -641,6 +765,8 @@ var methodRequirements = map[string]Requirement{
 	"SchedulePickup":                  wr(SectionFulfillment),
 	// packer/QC packing spec: order → items + assembly + packaging (read-only projection, WS7 scope 3)
 	"GetOrderPackingSpec": rd(SectionFulfillment),
+	// picking the stock location an order is packed from (0345) is the packer's call
+	"AllocateOrderFulfillment": wr(SectionFulfillment),
 	// settings
 	"UpdateSettings":          wr(SectionSettings),
 	"UpsertPaymentMethodFees": wr(SectionSettings),
@@ -674,6 +800,10 @@ var methodRequirements = map[string]Requirement{
 	"RevokeHackerStatus":   wr(SectionMembership),
 	"GetTierAuditLog":      rd(SectionMembership),
 	"RunTierBackfill":      wr(SectionMembership),
+	// Admin-wide audit trail: who changed what across every section, so it sits with the
+	// account-management rights. The handler further narrows a non-super viewer to the sections
+	// they can read themselves.
+	"ListAuditEvents": rd(SectionAccounts),
 	// accounts (management RPCs)
 	"ListAccounts":             rd(SectionAccounts),
 	"CreateAccount":            wr(SectionAccounts),
@@ -720,20 +850,32 @@ var methodRequirements = map[string]Requirement{
 	"GetCashFlowStatement":        rd(SectionAccounting),
 	"GetFinancialHealth":          rd(SectionAccounting),
 
+	// Sales invoices and KSeF (0337).
+	"IssueSalesInvoice":      wr(SectionAccounting),
+	"CorrectSalesInvoice":    wr(SectionAccounting),
+	"GetSalesInvoice":        rd(SectionAccounting),
+	"ListSalesInvoices":      rd(SectionAccounting),
+	"ExportSalesInvoiceKsef": rd(SectionAccounting),
+	"SubmitSalesInvoiceKsef": wr(SectionAccounting),
+
 	// Wave 4 — money side: Revolut bank inbox (4.1) + AP/AR subledgers (4.4).
-	"ImportBankCsv":  wr(SectionAccounting),
-	"ListBankTxns":   rd(SectionAccounting),
-	"PostBankTxn":    wr(SectionAccounting),
-	"IgnoreBankTxn":  wr(SectionAccounting),
-	"ListBankRules":  rd(SectionAccounting),
-	"CreateBankRule": wr(SectionAccounting),
-	"DeleteBankRule": wr(SectionAccounting),
-	"CreateSupplier": wr(SectionAccounting),
-	"ListSuppliers":  rd(SectionAccounting),
-	"GetPayables":    rd(SectionAccounting),
-	"GetReceivables": rd(SectionAccounting),
-	"GetAcctAlerts":  rd(SectionAccounting),
-	"GetVatUe":       rd(SectionAccounting),
+	"ImportBankCsv":        wr(SectionAccounting),
+	"ListBankTxns":         rd(SectionAccounting),
+	"PostBankTxn":          wr(SectionAccounting),
+	"IgnoreBankTxn":        wr(SectionAccounting),
+	"ListBankRules":        rd(SectionAccounting),
+	"CreateBankRule":       wr(SectionAccounting),
+	"DeleteBankRule":       wr(SectionAccounting),
+	"ListBankCsvMappings":  rd(SectionAccounting),
+	"SaveBankCsvMapping":   wr(SectionAccounting),
+	"DeleteBankCsvMapping": wr(SectionAccounting),
+	"CreateSupplier":       wr(SectionAccounting),
+	"UpdateSupplier":       wr(SectionAccounting),
+	"ListSuppliers":        rd(SectionAccounting),
+	"GetPayables":          rd(SectionAccounting),
+	"GetReceivables":       rd(SectionAccounting),
+	"GetAcctAlerts":        rd(SectionAccounting),
+	"GetVatUe":             rd(SectionAccounting),
 	// Fixed-asset depreciation + corporation-tax accrual (#71).
 	"CreateFixedAsset":     wr(SectionAccounting),
 	"ListFixedAssets":      rd(SectionAccounting),
@@ -791,6 +933,24 @@ var allowlist = map[string]struct{}{
 	"SetAccountSpecialties": {},
 }
 
+// allowlistedWrites are the allowlisted methods that mutate. An allowlisted method carries no
+// requirement to read the access level from, so the audit trail needs them named.
+var allowlistedWrites = map[string]struct{}{
+	"SetAccountSpecialties": {},
+}
+
+// IsWrite reports whether fullMethod is a mutating admin method: mapped with write access, or
+// an allowlisted write. Unmapped methods are not writes — the auth interceptor denies them before
+// anything runs.
+func IsWrite(fullMethod string) bool {
+	req, allowlisted, known := Lookup(fullMethod)
+	if allowlisted {
+		_, ok := allowlistedWrites[fullMethod[len(MethodPrefix):]]
+		return ok
+	}
+	return known && req.Access == entity.AccessWrite
+}
+
 // EncodePermissions formats a permission set as the "section:access" strings
 // embedded in a JWT's perms claim (e.g. "orders:write"). Unknown-section or
 // invalid-access entries are skipped so a malformed grant can't widen access.
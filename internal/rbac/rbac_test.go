@@ -118,3 +118,28 @@ func TestProjectTasksStayUnderTaskRights(t *testing.T) {
 		}
 	}
 }
+
+// TestAllowlistedWritesAreAllowlisted keeps the audit trail's list of allowlisted writes honest:
+// a name there that is not allowlisted would never be looked up, and IsWrite would silently stop
+// recording a method that moved into methodRequirements with read access.
+func TestAllowlistedWritesAreAllowlisted(t *testing.T) {
+	for name := range allowlistedWrites {
+		if _, ok := allowlist[name]; !ok {
+			t.Errorf("%s is in allowlistedWrites but not in allowlist", name)
+		}
+		if !IsWrite(MethodPrefix + name) {
+			t.Errorf("IsWrite(%s) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"GetDictionary", "ListAuditEvents", "GetTierAuditLog"} {
+		if IsWrite(MethodPrefix + name) {
+			t.Errorf("IsWrite(%s) = true, want false", name)
+		}
+	}
+	if !IsWrite(MethodPrefix + "CreateAccount") {
+		t.Error("IsWrite(CreateAccount) = false, want true")
+	}
+	if IsWrite("/other.Service/CreateAccount") {
+		t.Error("IsWrite matched a method outside AdminService")
+	}
+}
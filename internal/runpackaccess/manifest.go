@@ -13,6 +13,7 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
 	pb_admin "github.com/jekabolt/grbpwr-manager/proto/gen/admin"
 	pb_common "github.com/jekabolt/grbpwr-manager/proto/gen/common"
+	"github.com/shopspring/decimal"
 )
 
 // Тело манифеста наряда: ПРОСТОЙ snake_case JSON, руками, не протобуф. Потребитель — публичная
@@ -79,6 +80,10 @@ type Manifest struct {
 	LaysApplicable          bool          `json:"lays_applicable"`
 	LaysNotApplicableReason string        `json:"lays_not_applicable_reason"`
 	Lays                    []ManifestLay `json:"lays"`
+	// Lots — рулоны, названные настилами прогона, каждый ОДИН раз, с лабораторией (0348): оттеночная
+	// группа, усадка, плотность. Закройщик сверяет этикетку рулона на столе с этой строкой; лот,
+	// удалённый из реестра, едет с Deleted и снимком кода, а не пропадает.
+	Lots []ManifestLot `json:"lots"`
 
 	// MaterialBlockers — пары (слот, колорвей), которые материальный план НЕ смог посчитать. Из
 	// всего материального плана в наряд едут только они: остальное — про закупку и деньги, а это
@@ -203,6 +208,24 @@ type ManifestLay struct {
 	Mode        string               `json:"mode"`
 	TotalPlies  int                  `json:"total_plies"`
 	Sections    []ManifestLaySection `json:"sections"`
+	// LotCodes — рулоны настила: свой лот первым, затем дополнительные. Подробности — в Manifest.Lots.
+	LotCodes []string `json:"lot_codes"`
+}
+
+// ManifestLot — один рулон. Замеры — СТРОКИ: пусто = не измеряли, а не «0» — ноль процентов усадки
+// это результат испытания, и на бумаге он обязан отличаться от «не испытывали».
+type ManifestLot struct {
+	LotId            int    `json:"lot_id"` // 0 = лот удалён из реестра, остался только код
+	LotCode          string `json:"lot_code"`
+	Deleted          bool   `json:"deleted"`
+	ShadeCode        string `json:"shade_code"`
+	ShadeBand        string `json:"shade_band"`
+	ShrinkageWarpPct string `json:"shrinkage_warp_pct"`
+	ShrinkageWeftPct string `json:"shrinkage_weft_pct"`
+	MeasuredGsm      string `json:"measured_gsm"`
+	MeasuredWidthCm  string `json:"measured_width_cm"`
+	// Lays — имена настилов, в которые этот рулон стелют.
+	Lays []string `json:"lays"`
 }
 
 // ManifestLaySection — одна секция настила: раскладка и сколько её слоёв настилают.
@@ -331,6 +354,7 @@ func (s *Service) buildManifest(ctx context.Context, runID int) (*Manifest, erro
 		LaysApplicable:          lays.Applicable,
 		LaysNotApplicableReason: lays.NotApplicableReason,
 		Lays:                    manifestLays(lays.Lays, composition),
+		Lots:                    manifestLots(lays.Lays),
 
 		MaterialBlockers: materialBlockers(materialPlan),
 	}
@@ -559,11 +583,63 @@ func manifestLays(lays []entity.ProductionRunLay, composition map[int][]entity.R
 			Mode:         string(l.Mode),
 			TotalPlies:   l.TotalPlies(),
 			Sections:     sections,
+			LotCodes:     lotCodes(l.Lots()),
 		})
 	}
 	return out
 }
 
+// lotCodes называет рулоны настила их кодами — тем, что напечатано на этикетке.
+func lotCodes(lots []entity.ProductionRunLayLot) []string {
+	out := make([]string, 0, len(lots))
+	for _, r := range lots {
+		out = append(out, r.LotCode)
+	}
+	return out
+}
+
+// manifestLots сводит рулоны всех настилов в один список, в порядке первого упоминания. Ключ — id
+// лота; у удалённого (FK SET NULL) остаётся только код, и он же становится ключом, чтобы два
+// настила одного удалённого рулона не раздвоили его на бумаге.
+func manifestLots(lays []entity.ProductionRunLay) []ManifestLot {
+	out := []ManifestLot{}
+	index := map[string]int{}
+	for i := range lays {
+		for _, r := range lays[i].Lots() {
+			key := "code:" + r.LotCode
+			if r.LotId.Valid {
+				key = fmt.Sprintf("id:%d", r.LotId.Int64)
+			}
+			at, ok := index[key]
+			if !ok {
+				at = len(out)
+				index[key] = at
+				out = append(out, ManifestLot{
+					LotId:            int(r.LotId.Int64),
+					LotCode:          r.LotCode,
+					Deleted:          !r.LotId.Valid,
+					ShadeCode:        r.ShadeCode.String,
+					ShadeBand:        r.ShadeBand.String,
+					ShrinkageWarpPct: nullDecimalString(r.ShrinkageWarpPct),
+					ShrinkageWeftPct: nullDecimalString(r.ShrinkageWeftPct),
+					MeasuredGsm:      nullDecimalString(r.MeasuredGsm),
+					MeasuredWidthCm:  nullDecimalString(r.MeasuredWidthCm),
+				})
+			}
+			out[at].Lays = append(out[at].Lays, lays[i].Name)
+		}
+	}
+	return out
+}
+
+// nullDecimalString — пусто для неизмеренного, иначе число как есть.
+func nullDecimalString(d decimal.NullDecimal) string {
+	if !d.Valid {
+		return ""
+	}
+	return d.Decimal.String()
+}
+
 // cutSymmetryWord и bomSectionWord переводят проектные енумы в СЕРВЕРНОЕ написание — ровно те
 // строки, что лежат в БД и в entity-константах («mirrored», «fabric»). Написание выводится из
 // имени значения, а не из ручного switch: switch молча отдал бы пустую строку для значения,
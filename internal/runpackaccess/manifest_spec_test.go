@@ -2,6 +2,7 @@ package runpackaccess
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,6 +10,8 @@ import (
 	"testing"
 
 	"github.com/jekabolt/grbpwr-manager/internal/cutspec"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
 )
 
 // readManifest — общий пролог: минт токена, запрос, разбор документа.
@@ -182,3 +185,32 @@ func dump(t *testing.T, m Manifest) string {
 	}
 	return string(b)
 }
+
+// TestManifestLotsOncePerRoll — рулон, который стелют в два настила, печатается ОДИН раз с обоими
+// именами; удалённый из реестра — по коду и с Deleted; неиспытанная усадка — пустой строкой, не «0».
+func TestManifestLotsOncePerRoll(t *testing.T) {
+	id := func(v int64) sql.NullInt64 { return sql.NullInt64{Int64: v, Valid: true} }
+	lays := []entity.ProductionRunLay{
+		{
+			Name: "L1", LotId: id(4), LotCode: "LOT-A",
+			LotShadeBand:        sql.NullString{String: "A", Valid: true},
+			LotShrinkageWarpPct: decimal.NullDecimal{Decimal: decimal.RequireFromString("3.5"), Valid: true},
+			ExtraLots:           []entity.ProductionRunLayLot{{Position: 1, LotCode: "LOT-GONE"}},
+		},
+		{Name: "L2", ExtraLots: []entity.ProductionRunLayLot{{Position: 1, LotId: id(4), LotCode: "LOT-A"}}},
+	}
+	lots := manifestLots(lays)
+	if len(lots) != 2 {
+		t.Fatalf("want 2 rolls, got %+v", lots)
+	}
+	if a := lots[0]; a.LotCode != "LOT-A" || strings.Join(a.Lays, ",") != "L1,L2" || a.ShadeBand != "A" ||
+		a.ShrinkageWarpPct != "3.5" || a.ShrinkageWeftPct != "" {
+		t.Fatalf("LOT-A: %+v", a)
+	}
+	if g := lots[1]; !g.Deleted || g.LotId != 0 || g.LotCode != "LOT-GONE" {
+		t.Fatalf("deleted roll must keep its code: %+v", g)
+	}
+	if codes := manifestLays(lays, nil)[0].LotCodes; strings.Join(codes, ",") != "LOT-A,LOT-GONE" {
+		t.Fatalf("lay L1 lot codes: %v", codes)
+	}
+}
@@ -0,0 +1,302 @@
+// Package runschedule loads open production runs onto the workshop's sewing capacity (0342) and
+// builds the Gantt-style timeline the admin panel draws. It is pure: the store gathers the runs'
+// facts, the workshop settings and the holidays; Plan decides.
+//
+// The model is finite forward loading. A run's load is Σ SMV × quantity, less what is already done;
+// a day's capacity is WorkshopSettings.Capacity().DailyMinutes, zero on a day off or a holiday. Runs
+// take capacity in priority order — what is already on the floor first, then planned, then drafts,
+// each by its planned start, promise and id — starting no earlier than today and their own planned
+// start. The first and last day a run gets capacity are its proposed dates.
+//
+// The people's own dates are judged separately: a run with both a planned start and a promised date
+// asks for its remaining load spread evenly over the working days between them, and the days on
+// which those asks add up to more than the workshop has are reported as conflicts. The proposal
+// never overwrites the plan; it is something to compare it against.
+package runschedule
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// LookaheadDays bounds how far the scheduler looks for capacity. A run not finished by then is
+// reported beyond_horizon rather than given an invented date.
+const LookaheadDays = 366
+
+// Plan builds the timeline of horizonDays days from the day of from (UTC). Proposed dates may fall
+// after the horizon, up to LookaheadDays.
+func Plan(capacity entity.WorkshopCapacity, holidays []entity.WorkshopHoliday,
+	runs []entity.ProductionScheduleRun, from time.Time, horizonDays int) entity.ProductionSchedule {
+
+	from = dayOf(from)
+	span := LookaheadDays
+	if horizonDays > span {
+		span = horizonDays
+	}
+
+	notes := make(map[time.Time]string, len(holidays))
+	for _, h := range holidays {
+		note := h.Note.String
+		if note == "" {
+			note = "closed"
+		}
+		notes[dayOf(h.Day)] = note
+	}
+	working := make([]bool, span)
+	free := make([]decimal.Decimal, span)
+	for i := range working {
+		d := from.AddDate(0, 0, i)
+		_, closed := notes[d]
+		working[i] = capacity.Weekdays[d.Weekday()] && !closed
+		if working[i] && capacity.Configured {
+			free[i] = capacity.DailyMinutes
+		}
+	}
+
+	bars := make([]entity.ScheduleBar, len(runs))
+	for i, r := range runs {
+		bars[i] = newBar(r)
+	}
+	order := make([]int, len(bars))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return before(bars[order[a]].Run, bars[order[b]].Run) })
+
+	proposed := make([]decimal.Decimal, span)
+	if capacity.Configured {
+		for _, i := range order {
+			b := &bars[i]
+			if !loadable(b) || !b.RemainingMinutes.IsPositive() {
+				continue
+			}
+			left := b.RemainingMinutes
+			for d := earliest(b.Run, from); d < span && left.IsPositive(); d++ {
+				if !free[d].IsPositive() {
+					continue
+				}
+				take := decimal.Min(free[d], left)
+				free[d] = free[d].Sub(take)
+				left = left.Sub(take)
+				proposed[d] = proposed[d].Add(take)
+				if !b.ProposedStart.Valid {
+					b.ProposedStart = validDay(from, d)
+				}
+				if !left.IsPositive() {
+					b.ProposedFinish = validDay(from, d)
+				}
+			}
+			switch {
+			case !b.ProposedFinish.Valid:
+				b.Warnings = append(b.Warnings, entity.ScheduleWarningBeyondHorizon)
+			case b.Run.PromisedAt.Valid && b.ProposedFinish.Time.After(dayOf(b.Run.PromisedAt.Time)):
+				b.Warnings = append(b.Warnings, entity.ScheduleWarningLate)
+			}
+		}
+	}
+
+	// The people's plan: each dated run's remainder spread over its window's working days.
+	planned := make([]decimal.Decimal, horizonDays)
+	askers := make([][]int, horizonDays)
+	for _, i := range order {
+		b := bars[i]
+		if !loadable(&b) || !b.RemainingMinutes.IsPositive() || !b.Run.PlannedStartAt.Valid || !b.Run.PromisedAt.Valid {
+			continue
+		}
+		first := max(offset(from, b.Run.PlannedStartAt.Time), 0)
+		last := min(offset(from, b.Run.PromisedAt.Time), span-1)
+		var days []int
+		for d := first; d <= last; d++ {
+			if working[d] {
+				days = append(days, d)
+			}
+		}
+		if len(days) == 0 {
+			continue
+		}
+		per := b.RemainingMinutes.Div(decimal.NewFromInt(int64(len(days))))
+		for _, d := range days {
+			if d >= horizonDays {
+				break
+			}
+			planned[d] = planned[d].Add(per)
+			askers[d] = append(askers[d], b.Run.RunId)
+		}
+	}
+
+	out := entity.ProductionSchedule{From: from, Capacity: capacity, Days: make([]entity.ScheduleDay, 0, horizonDays)}
+	for d := 0; d < horizonDays; d++ {
+		day := entity.ScheduleDay{
+			Date:            from.AddDate(0, 0, d),
+			Working:         working[d],
+			HolidayNote:     notes[from.AddDate(0, 0, d)],
+			ProposedMinutes: proposed[d].Round(2),
+			PlannedMinutes:  planned[d].Round(2),
+		}
+		if working[d] && capacity.Configured {
+			day.CapacityMinutes = capacity.DailyMinutes
+		}
+		out.Days = append(out.Days, day)
+	}
+	if capacity.Configured {
+		out.Conflicts = conflicts(out.Days, askers, capacity.DailyMinutes)
+	}
+	overloaded := make(map[int]bool)
+	for _, c := range out.Conflicts {
+		for _, id := range c.RunIds {
+			overloaded[id] = true
+		}
+	}
+	out.Bars = make([]entity.ScheduleBar, 0, len(bars))
+	for _, i := range order {
+		b := bars[i]
+		if overloaded[b.Run.RunId] {
+			b.Warnings = append(b.Warnings, entity.ScheduleWarningOverCapacity)
+		}
+		b.LoadMinutes = b.LoadMinutes.Round(2)
+		b.RemainingMinutes = b.RemainingMinutes.Round(2)
+		out.Bars = append(out.Bars, b)
+	}
+	return out
+}
+
+// newBar computes a run's load and the warnings that do not depend on the calendar.
+func newBar(r entity.ProductionScheduleRun) entity.ScheduleBar {
+	b := entity.ScheduleBar{Run: r}
+	if r.SupplierId.Valid {
+		b.Warnings = append(b.Warnings, entity.ScheduleWarningExternal)
+	}
+	if !r.SMVPerUnit.Valid {
+		b.Warnings = append(b.Warnings, entity.ScheduleWarningSMVMissing)
+		return b
+	}
+	if r.OperationsWithoutSMV > 0 {
+		b.Warnings = append(b.Warnings, entity.ScheduleWarningSMVIncomplete)
+	}
+	smv := r.SMVPerUnit.Decimal
+	b.LoadMinutes = smv.Mul(decimal.NewFromInt(int64(r.PlannedQty)))
+	// Scans and receipts both say how much is done, and neither alone is the whole truth: scans
+	// exist only where bundles were cut, receipts only once units come off the line. The larger is
+	// the one that has seen more.
+	done := decimal.Max(r.ScannedMinutes, smv.Mul(decimal.NewFromInt(int64(r.DoneQty))))
+	if left := b.LoadMinutes.Sub(done); left.IsPositive() {
+		b.RemainingMinutes = left
+	}
+	return b
+}
+
+// loadable reports whether the run takes this workshop's capacity at all.
+func loadable(b *entity.ScheduleBar) bool {
+	return !b.Run.SupplierId.Valid && b.Run.SMVPerUnit.Valid
+}
+
+// before is the loading priority: on the floor, planned, draft; then planned start, promise, id —
+// an undated run after the dated ones.
+func before(a, b entity.ProductionScheduleRun) bool {
+	if ra, rb := rank(a.Status), rank(b.Status); ra != rb {
+		return ra < rb
+	}
+	if c := compareNullTime(a.PlannedStartAt.Time, a.PlannedStartAt.Valid, b.PlannedStartAt.Time, b.PlannedStartAt.Valid); c != 0 {
+		return c < 0
+	}
+	if c := compareNullTime(a.PromisedAt.Time, a.PromisedAt.Valid, b.PromisedAt.Time, b.PromisedAt.Valid); c != 0 {
+		return c < 0
+	}
+	return a.RunId < b.RunId
+}
+
+func rank(s entity.ProductionRunStatus) int {
+	switch s {
+	case entity.ProductionRunInProgress, entity.ProductionRunPartiallyReceived:
+		return 0
+	case entity.ProductionRunPlanned:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func compareNullTime(a time.Time, aok bool, b time.Time, bok bool) int {
+	switch {
+	case aok && !bok:
+		return -1
+	case !aok && bok:
+		return 1
+	case !aok && !bok:
+		return 0
+	case a.Before(b):
+		return -1
+	case b.Before(a):
+		return 1
+	}
+	return 0
+}
+
+// earliest is the first day a run may take capacity: today for a run already on the floor, else
+// its planned start when that is still ahead.
+func earliest(r entity.ProductionScheduleRun, from time.Time) int {
+	if rank(r.Status) == 0 || !r.PlannedStartAt.Valid {
+		return 0
+	}
+	return max(offset(from, r.PlannedStartAt.Time), 0)
+}
+
+// conflicts groups the overloaded working days into ranges. Days off inside a range do not break
+// it — they ask nothing — but a working day within capacity, or a change in who asks, does.
+func conflicts(days []entity.ScheduleDay, askers [][]int, capacity decimal.Decimal) []entity.ScheduleConflict {
+	var out []entity.ScheduleConflict
+	var cur *entity.ScheduleConflict
+	for d, day := range days {
+		if !day.Working {
+			continue
+		}
+		if !day.PlannedMinutes.GreaterThan(capacity) {
+			cur = nil
+			continue
+		}
+		if cur != nil && sameRuns(cur.RunIds, askers[d]) {
+			cur.To = day.Date
+			cur.PeakMinutes = decimal.Max(cur.PeakMinutes, day.PlannedMinutes)
+			continue
+		}
+		out = append(out, entity.ScheduleConflict{
+			From:            day.Date,
+			To:              day.Date,
+			PeakMinutes:     day.PlannedMinutes,
+			CapacityMinutes: capacity,
+			RunIds:          append([]int(nil), askers[d]...),
+		})
+		cur = &out[len(out)-1]
+	}
+	return out
+}
+
+func sameRuns(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func dayOf(t time.Time) time.Time {
+	u := t.UTC()
+	return time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// offset is the number of whole days from from to t's day.
+func offset(from, t time.Time) int {
+	return int(dayOf(t).Sub(from).Hours() / 24)
+}
+
+func validDay(from time.Time, d int) sql.NullTime {
+	return sql.NullTime{Time: from.AddDate(0, 0, d), Valid: true}
+}
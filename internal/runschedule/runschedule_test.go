@@ -0,0 +1,169 @@
+package runschedule
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// 2026-03-02 is a Monday.
+var monday = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func day(offset int) time.Time { return monday.AddDate(0, 0, offset) }
+
+func nt(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+
+func nd(s string) decimal.NullDecimal { return decimal.NewNullDecimal(decimal.RequireFromString(s)) }
+
+// workshop sews 4 stations × 1 shift × 480 min = 1920 standard minutes a day, Monday to Friday.
+func workshop() entity.WorkshopCapacity {
+	s := &entity.WorkshopSettings{
+		SewingWorkstations: sql.NullInt32{Int32: 4, Valid: true},
+		ShiftMinutes:       sql.NullInt32{Int32: 480, Valid: true},
+	}
+	return s.Capacity()
+}
+
+func run(id int, status entity.ProductionRunStatus, qty int, smv string) entity.ProductionScheduleRun {
+	return entity.ProductionScheduleRun{RunId: id, Status: status, PlannedQty: qty, SMVPerUnit: nd(smv), OperationCount: 5}
+}
+
+func bar(t *testing.T, s entity.ProductionSchedule, id int) entity.ScheduleBar {
+	t.Helper()
+	for _, b := range s.Bars {
+		if b.Run.RunId == id {
+			return b
+		}
+	}
+	t.Fatalf("run %d has no bar", id)
+	return entity.ScheduleBar{}
+}
+
+func hasWarning(b entity.ScheduleBar, w entity.ScheduleWarningKind) bool {
+	for _, x := range b.Warnings {
+		if x == w {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCapacityDefaults(t *testing.T) {
+	c := workshop()
+	if !c.Configured || !c.DailyMinutes.Equal(decimal.NewFromInt(1920)) {
+		t.Fatalf("capacity = %+v, want 1920 min/day", c)
+	}
+	if !c.Weekdays[time.Monday] || !c.Weekdays[time.Friday] || c.Weekdays[time.Saturday] {
+		t.Fatalf("weekdays = %v, want Monday–Friday", c.Weekdays)
+	}
+	if (&entity.WorkshopSettings{ShiftMinutes: sql.NullInt32{Int32: 480, Valid: true}}).Capacity().Configured {
+		t.Fatal("capacity without workstations must not be configured")
+	}
+}
+
+func TestPlanLoadsInPriorityOrderAndSkipsDaysOff(t *testing.T) {
+	// 200 × 24 min = 4800 min: 2.5 days. The planned run waits for the one already on the floor.
+	planned := run(1, entity.ProductionRunPlanned, 100, "24")
+	planned.PromisedAt = nt(day(4))
+	floor := run(2, entity.ProductionRunInProgress, 200, "24")
+	holidays := []entity.WorkshopHoliday{{Day: day(1), Note: sql.NullString{String: "local holiday", Valid: true}}}
+
+	s := Plan(workshop(), holidays, []entity.ProductionScheduleRun{planned, floor}, monday.Add(10*time.Hour), 14)
+
+	if s.Bars[0].Run.RunId != 2 {
+		t.Fatalf("first bar = run %d, want the in-progress run", s.Bars[0].Run.RunId)
+	}
+	// Mon, (Tue holiday), Wed, Thu half.
+	b := bar(t, s, 2)
+	if !b.ProposedStart.Time.Equal(day(0)) || !b.ProposedFinish.Time.Equal(day(3)) {
+		t.Fatalf("floor run = %s..%s, want Mon..Thu", b.ProposedStart.Time, b.ProposedFinish.Time)
+	}
+	// 2400 min: the rest of Thursday (960), Friday (1920) — done on Friday, within the promise.
+	b = bar(t, s, 1)
+	if !b.ProposedStart.Time.Equal(day(3)) || !b.ProposedFinish.Time.Equal(day(4)) || hasWarning(b, entity.ScheduleWarningLate) {
+		t.Fatalf("planned run = %s..%s %v, want Thu..Fri on time", b.ProposedStart.Time, b.ProposedFinish.Time, b.Warnings)
+	}
+	if s.Days[1].Working || s.Days[1].HolidayNote != "local holiday" || !s.Days[1].CapacityMinutes.IsZero() {
+		t.Fatalf("holiday = %+v", s.Days[1])
+	}
+	if s.Days[5].Working {
+		t.Fatal("Saturday must not be a working day by default")
+	}
+	if !s.Days[3].ProposedMinutes.Equal(decimal.NewFromInt(1920)) {
+		t.Fatalf("Thursday load = %s, want full", s.Days[3].ProposedMinutes)
+	}
+}
+
+func TestPlanSubtractsDoneWorkAndFlagsLateRuns(t *testing.T) {
+	r := run(1, entity.ProductionRunInProgress, 100, "48") // 4800 min
+	r.ScannedMinutes = decimal.NewFromInt(1000)
+	r.DoneQty = 40 // 1920 min received — more than the scans saw
+	r.PromisedAt = nt(day(0))
+
+	s := Plan(workshop(), nil, []entity.ProductionScheduleRun{r}, monday, 7)
+	b := bar(t, s, 1)
+	if !b.LoadMinutes.Equal(decimal.NewFromInt(4800)) || !b.RemainingMinutes.Equal(decimal.NewFromInt(2880)) {
+		t.Fatalf("load %s remaining %s, want 4800 / 2880", b.LoadMinutes, b.RemainingMinutes)
+	}
+	if !b.ProposedFinish.Time.Equal(day(1)) || !hasWarning(b, entity.ScheduleWarningLate) {
+		t.Fatalf("finish %s %v, want Tuesday and late", b.ProposedFinish.Time, b.Warnings)
+	}
+}
+
+func TestPlanWithoutCapacityProposesNothing(t *testing.T) {
+	s := Plan((&entity.WorkshopSettings{}).Capacity(), nil,
+		[]entity.ProductionScheduleRun{run(1, entity.ProductionRunPlanned, 10, "10")}, monday, 7)
+	b := bar(t, s, 1)
+	if b.ProposedStart.Valid || !b.LoadMinutes.Equal(decimal.NewFromInt(100)) || len(s.Conflicts) != 0 {
+		t.Fatalf("unconfigured: bar %+v conflicts %v", b, s.Conflicts)
+	}
+}
+
+func TestPlanDoesNotLoadExternalOrUnmeasuredRuns(t *testing.T) {
+	ext := run(1, entity.ProductionRunPlanned, 100, "10")
+	ext.SupplierId = sql.NullInt64{Int64: 3, Valid: true}
+	blind := entity.ProductionScheduleRun{RunId: 2, Status: entity.ProductionRunPlanned, PlannedQty: 50, OperationCount: 4, OperationsWithoutSMV: 4}
+	partial := run(3, entity.ProductionRunPlanned, 10, "10")
+	partial.OperationsWithoutSMV = 2
+
+	s := Plan(workshop(), nil, []entity.ProductionScheduleRun{ext, blind, partial}, monday, 7)
+	if b := bar(t, s, 1); b.ProposedStart.Valid || !hasWarning(b, entity.ScheduleWarningExternal) {
+		t.Fatalf("external run = %+v", b)
+	}
+	if b := bar(t, s, 2); b.ProposedStart.Valid || !hasWarning(b, entity.ScheduleWarningSMVMissing) {
+		t.Fatalf("run without SMV = %+v", b)
+	}
+	if b := bar(t, s, 3); !b.ProposedStart.Valid || !hasWarning(b, entity.ScheduleWarningSMVIncomplete) {
+		t.Fatalf("run with partial SMV = %+v", b)
+	}
+	if !s.Days[0].ProposedMinutes.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("Monday load = %s, want only the measured run", s.Days[0].ProposedMinutes)
+	}
+}
+
+func TestPlanReportsOverlappingPlannedWindows(t *testing.T) {
+	// Both ask Mon..Tue: 3000 min each over 2 days = 1500 + 1500 = 3000 > 1920 a day.
+	a := run(1, entity.ProductionRunPlanned, 100, "30")
+	a.PlannedStartAt, a.PromisedAt = nt(day(0)), nt(day(1))
+	b := run(2, entity.ProductionRunPlanned, 100, "30")
+	b.PlannedStartAt, b.PromisedAt = nt(day(0)), nt(day(1))
+	// Alone on Wed..Fri: 1000 a day.
+	c := run(3, entity.ProductionRunPlanned, 100, "30")
+	c.PlannedStartAt, c.PromisedAt = nt(day(2)), nt(day(4))
+
+	s := Plan(workshop(), nil, []entity.ProductionScheduleRun{a, b, c}, monday, 7)
+	if len(s.Conflicts) != 1 {
+		t.Fatalf("conflicts = %+v, want one range", s.Conflicts)
+	}
+	got := s.Conflicts[0]
+	if !got.From.Equal(day(0)) || !got.To.Equal(day(1)) || !got.PeakMinutes.Equal(decimal.NewFromInt(3000)) ||
+		len(got.RunIds) != 2 {
+		t.Fatalf("conflict = %+v", got)
+	}
+	if !hasWarning(bar(t, s, 1), entity.ScheduleWarningOverCapacity) || hasWarning(bar(t, s, 3), entity.ScheduleWarningOverCapacity) {
+		t.Fatal("only the runs of the overloaded range carry over_capacity")
+	}
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/canonical"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+)
+
+// Field weights: how much one occurrence of a term in a field counts towards a colourway's score. A
+// code hit (SKU, style number) is nearly always what the shopper meant; a word deep in a description
+// rarely is.
+const (
+	weightCode        = 6.0
+	weightName        = 4.0
+	weightTag         = 3.0
+	weightCollection  = 3.0
+	weightCategory    = 2.5
+	weightColor       = 2.0
+	weightBrand       = 1.5
+	weightComposition = 1.5
+	weightDescription = 1.0
+)
+
+// saturation is the BM25 k1 constant: repeated occurrences of a term add less and less, so a name
+// repeated in five languages does not outrank a name that is simply a better match.
+const saturation = 1.2
+
+type posting struct {
+	doc    int32
+	weight float32
+}
+
+// Index is an immutable, in-memory inverted index over the storefront catalogue. It is built whole
+// from the store's search documents and swapped in atomically by the Indexer; queries never touch the
+// database.
+type Index struct {
+	docs     []entity.SearchDocument
+	postings map[string][]posting // term → postings, ordered by doc
+	vocab    []string             // every term, sorted, for prefix lookups
+	runes    [][]rune             // vocab[i] as runes, for edit distances
+	pos      map[int]int32        // product id → docs index
+	builtAt  time.Time
+}
+
+// Build indexes docs. The slice is owned by the index afterwards.
+func Build(docs []entity.SearchDocument, builtAt time.Time) *Index {
+	idx := &Index{docs: docs, postings: make(map[string][]posting), pos: make(map[int]int32, len(docs)), builtAt: builtAt}
+	for i := range docs {
+		d := &docs[i]
+		idx.pos[d.ProductId] = int32(i)
+		weights := make(map[string]float64)
+		add := func(text string, w float64) {
+			for _, t := range tokenize(text) {
+				weights[t] += w
+			}
+		}
+		for _, code := range []string{d.SKU, d.StyleNumber} {
+			add(code, weightCode)
+			if c := compactCode(code); c != "" {
+				weights[c] += weightCode
+			}
+		}
+		for _, t := range d.Translations {
+			add(t.Name, weightName)
+			add(t.Description, weightDescription)
+		}
+		for _, t := range d.Tags {
+			add(t, weightTag)
+		}
+		add(d.Collection, weightCollection)
+		for _, c := range d.CategoryNames {
+			add(c, weightCategory)
+		}
+		add(d.Color, weightColor)
+		add(d.ColorCode, weightColor)
+		add(d.Brand, weightBrand)
+		add(d.Composition, weightComposition)
+		add(d.Fibers, weightComposition)
+
+		for t, w := range weights {
+			idx.postings[t] = append(idx.postings[t], posting{doc: int32(i), weight: float32(w)})
+		}
+	}
+	idx.vocab = make([]string, 0, len(idx.postings))
+	for t := range idx.postings {
+		idx.vocab = append(idx.vocab, t)
+	}
+	sort.Strings(idx.vocab)
+	idx.runes = make([][]rune, len(idx.vocab))
+	for i, t := range idx.vocab {
+		idx.runes[i] = []rune(t)
+	}
+	return idx
+}
+
+// Len is the number of indexed colourways.
+func (idx *Index) Len() int { return len(idx.docs) }
+
+// BuiltAt is when the index was built.
+func (idx *Index) BuiltAt() time.Time { return idx.builtAt }
+
+// idf is the BM25 inverse document frequency of a term found in df colourways.
+func (idx *Index) idf(df int) float64 {
+	n := float64(len(idx.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// termScore is what one posting contributes, before the match-quality factor.
+func (idx *Index) termScore(term string, p posting) float64 {
+	w := float64(p.weight)
+	return idx.idf(len(idx.postings[term])) * w * (saturation + 1) / (w + saturation)
+}
+
+// prefixRange returns the vocab indexes of the terms starting with prefix.
+func (idx *Index) prefixRange(prefix string) (int, int) {
+	lo := sort.SearchStrings(idx.vocab, prefix)
+	hi := lo
+	for hi < len(idx.vocab) && strings.HasPrefix(idx.vocab[hi], prefix) {
+		hi++
+	}
+	return lo, hi
+}
+
+// docName is the colourway's name in lang, falling back to the canonical name (the policy product
+// cards use everywhere), then to the SKU.
+func docName(d *entity.SearchDocument, lang int, langs []entity.Language) string {
+	for _, t := range d.Translations {
+		if t.LanguageId == lang && strings.TrimSpace(t.Name) != "" {
+			return t.Name
+		}
+	}
+	t, ok := canonical.Select(d.Translations,
+		func(t entity.SearchTranslation) int { return t.LanguageId },
+		canonical.IsDefaultFunc(langs))
+	if ok && strings.TrimSpace(t.Name) != "" {
+		return t.Name
+	}
+	return d.SKU
+}
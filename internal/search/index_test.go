@@ -0,0 +1,252 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	langEN = 1
+	langFR = 2
+)
+
+var testLangs = []entity.Language{{Id: langEN, Code: "en", IsDefault: true}, {Id: langFR, Code: "fr"}}
+
+func testCatalogue() []entity.SearchDocument {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []entity.SearchDocument{
+		{
+			ProductId: 1, SKU: "GR-1021-BLK", StyleNumber: "GR-1021", Color: "Black", ColorCode: "BLK",
+			Collection: "Nocturne", Composition: "100% cotton", TargetGender: entity.Male, Season: entity.SeasonSS,
+			TopCategoryId: 10, SizeIds: []int{1, 2}, CreatedAt: t0,
+			Translations: []entity.SearchTranslation{
+				{LanguageId: langEN, Name: "Oversized shirt", Description: "Boxy shirt with a dropped shoulder"},
+				{LanguageId: langFR, Name: "Chemise oversize", Description: "Chemise ample en coton"},
+			},
+			Tags:   []string{"essentials"},
+			Prices: map[string]decimal.Decimal{"EUR": decimal.NewFromInt(200)},
+		},
+		{
+			ProductId: 2, SKU: "GR-2040-WHT", StyleNumber: "GR-2040", Color: "White", ColorCode: "WHT",
+			Collection: "Nocturne", Fibers: "Wool Cashmere", TargetGender: entity.Female, Season: entity.SeasonFW,
+			TopCategoryId: 20, SizeIds: []int{2, 3}, CreatedAt: t0.Add(24 * time.Hour),
+			SalePercentage: decimal.NullDecimal{Decimal: decimal.NewFromInt(50), Valid: true},
+			Translations: []entity.SearchTranslation{
+				{LanguageId: langEN, Name: "Cashmere sweater", Description: "Pairs well with any shirt"},
+				{LanguageId: langFR, Name: "Pull en cachemire", Description: "Maille légère"},
+			},
+			Prices: map[string]decimal.Decimal{"EUR": decimal.NewFromInt(300)},
+		},
+		{
+			ProductId: 3, SKU: "GR-3001-BLK", StyleNumber: "GR-3001", Color: "Black", ColorCode: "BLK",
+			Collection: "Atelier", TargetGender: entity.Female, Season: entity.SeasonSS, TopCategoryId: 10,
+			SizeIds: []int{1}, CreatedAt: t0.Add(48 * time.Hour),
+			Translations: []entity.SearchTranslation{
+				{LanguageId: langEN, Name: "Poplin shirt", Description: "Crisp cotton poplin"},
+			},
+			Prices: map[string]decimal.Decimal{"EUR": decimal.NewFromInt(150)},
+		},
+		{
+			// Members-only and hidden from everyone else.
+			ProductId: 4, SKU: "GR-4004-RED", StyleNumber: "GR-4004", Color: "Red", ColorCode: "RED",
+			Collection: "Vault", TargetGender: entity.Female, TopCategoryId: 10, CreatedAt: t0.Add(72 * time.Hour),
+			MinTier: 2, HiddenForNonQualified: true,
+			Translations: []entity.SearchTranslation{
+				{LanguageId: langEN, Name: "Silk shirt", Description: "Vault release"},
+			},
+		},
+	}
+}
+
+func hitIDs(res *entity.SearchResult) []int {
+	ids := make([]int, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		ids = append(ids, h.ProductId)
+	}
+	return ids
+}
+
+func facetCounts(fs []entity.SearchFacet) map[string]int {
+	out := make(map[string]int, len(fs))
+	for _, f := range fs {
+		out[f.Value] = f.Count
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"legere", "maille"}, tokenize("Légère MAILLE"))
+	assert.Equal(t, []string{"strasse"}, tokenize("Straße"))
+	assert.Equal(t, []string{"gr", "1021", "blk"}, tokenize("GR-1021/BLK"))
+	assert.Equal(t, "gr1021blk", compactCode("GR-1021/BLK"))
+	assert.Equal(t, "", compactCode("GR1021"))
+	// Han runs become overlapping pairs; a lone character stays a term.
+	assert.Equal(t, []string{"羊毛", "毛衫", "衫", "x"}, tokenize("羊毛衫 衫x"))
+}
+
+func TestEditDistance(t *testing.T) {
+	d := func(a, b string) int { return editDistance([]rune(a), []rune(b), 2) }
+	assert.Equal(t, 0, d("cotton", "cotton"))
+	assert.Equal(t, 1, d("coton", "cotton"))
+	assert.Equal(t, 1, d("ctoton", "cotton"), "an adjacent transposition is one edit")
+	assert.Equal(t, 2, d("cotn", "cotton"))
+	assert.Equal(t, 3, d("silk", "cashmere"), "past the limit reports limit+1")
+}
+
+func TestSearchRanksAcrossLanguagesAndFields(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	// "shirt" is in two names and one description: the name hits rank first, the one that also has it
+	// in its description ahead.
+	res, err := idx.Search(entity.SearchQuery{Text: "shirt "})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3, 2}, hitIDs(res), "the hidden silk shirt never reaches a guest")
+
+	// French description words, accent-insensitively.
+	res, err = idx.Search(entity.SearchQuery{Text: "LEGERE maille"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, hitIDs(res))
+
+	// Every word must match.
+	res, err = idx.Search(entity.SearchQuery{Text: "shirt cashmere"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, hitIDs(res))
+
+	// Style numbers and SKUs, with or without separators.
+	for _, q := range []string{"GR-1021", "gr1021", "gr1021blk"} {
+		res, err = idx.Search(entity.SearchQuery{Text: q})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, hitIDs(res), q)
+	}
+
+	// Composition, from the plain text and from the structured fibres.
+	res, err = idx.Search(entity.SearchQuery{Text: "wool"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, hitIDs(res))
+}
+
+func TestSearchTypoAndPrefix(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	res, err := idx.Search(entity.SearchQuery{Text: "cashmre sweater"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, hitIDs(res))
+	assert.Equal(t, map[string]string{"cashmre": "cashmere"}, res.Corrections)
+
+	// The last word is read as typed-so-far; a completed word is not.
+	res, err = idx.Search(entity.SearchQuery{Text: "popl"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, hitIDs(res))
+	assert.Empty(t, res.Corrections)
+	res, err = idx.Search(entity.SearchQuery{Text: "popl "})
+	require.NoError(t, err)
+	assert.Empty(t, hitIDs(res))
+
+	// Short words get no typo budget.
+	res, err = idx.Search(entity.SearchQuery{Text: "sik "})
+	require.NoError(t, err)
+	assert.Empty(t, hitIDs(res))
+}
+
+func TestSearchTierGate(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	res, err := idx.Search(entity.SearchQuery{Text: "silk"})
+	require.NoError(t, err)
+	assert.Empty(t, hitIDs(res))
+
+	res, err = idx.Search(entity.SearchQuery{Text: "silk", Filters: entity.FilterConditions{ViewerTier: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, hitIDs(res))
+
+	res, err = idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{ViewerTier: 2, Exclusive: true}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, hitIDs(res))
+}
+
+func TestSearchFiltersAndDisjunctiveFacets(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	res, err := idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{ColorCodes: []string{"BLK"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 1}, hitIDs(res), "no text: newest first")
+	assert.Equal(t, 2, res.Total)
+	// The colour facet ignores the colour filter; the others respect it.
+	assert.Equal(t, map[string]int{"BLK": 2, "WHT": 1}, facetCounts(res.Facets.Color))
+	assert.Equal(t, map[string]int{"SS": 2}, facetCounts(res.Facets.Season))
+	assert.Equal(t, map[string]int{"1": 2, "2": 1}, facetCounts(res.Facets.Size))
+	assert.Equal(t, 0, res.Facets.OnSale)
+
+	res, err = idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{OnSale: true}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, hitIDs(res))
+	assert.Equal(t, 1, res.Facets.OnSale)
+	assert.Equal(t, map[string]int{"Nocturne": 1}, facetCounts(res.Facets.Collection))
+
+	res, err = idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{SizesIds: []int{3}, Gender: []entity.GenderEnum{entity.Female}}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, hitIDs(res))
+	assert.Equal(t, map[string]int{string(entity.Female): 1}, facetCounts(res.Facets.Gender))
+	assert.Equal(t, map[string]int{"1": 1, "2": 1, "3": 1}, facetCounts(res.Facets.Size))
+}
+
+func TestSearchPriceRangeUsesSalePrice(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	// The 300 EUR sweater is 150 EUR at 50% off; the 200 EUR shirt is out of range.
+	res, err := idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{Currency: "EUR", To: decimal.NewFromInt(160)}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, hitIDs(res))
+
+	res, err = idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{Currency: "EUR", From: decimal.NewFromInt(160)}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, hitIDs(res), "a colourway without a price in the currency is out")
+
+	_, err = idx.Search(entity.SearchQuery{Filters: entity.FilterConditions{Currency: "EUR", From: decimal.NewFromInt(200), To: decimal.NewFromInt(100)}})
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+func TestSearchPaging(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	res, err := idx.Search(entity.SearchQuery{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Total)
+	assert.Equal(t, []int{2, 1}, hitIDs(res))
+
+	res, err = idx.Search(entity.SearchQuery{Limit: 2, Offset: 10})
+	require.NoError(t, err)
+	assert.Empty(t, res.Hits)
+}
+
+func TestSuggest(t *testing.T) {
+	idx := Build(testCatalogue(), time.Now())
+
+	ss := idx.Suggest(entity.SuggestQuery{Text: "s", Limit: 4, Languages: testLangs})
+	require.NotEmpty(t, ss)
+	assert.Equal(t, entity.SearchSuggestion{Kind: entity.SearchSuggestionQuery, Text: "shirt"}, ss[0], "the most common completion first")
+	for _, s := range ss {
+		assert.NotEqual(t, "silk", s.Text, "a word only a hidden colourway has is not suggested")
+		assert.NotEqual(t, 4, s.ProductId)
+	}
+
+	// Completions are counted among colourways matching the earlier words.
+	ss = idx.Suggest(entity.SuggestQuery{Text: "cotton p", Limit: 2, Languages: testLangs})
+	require.Len(t, ss, 2)
+	assert.Equal(t, entity.SearchSuggestion{Kind: entity.SearchSuggestionQuery, Text: "cotton poplin"}, ss[0])
+	assert.Equal(t, entity.SearchSuggestion{Kind: entity.SearchSuggestionProduct, Text: "Poplin shirt", ProductId: 3, SKU: "GR-3001-BLK"}, ss[1])
+
+	// Product names come in the requested language, else the default language's.
+	ss = idx.Suggest(entity.SuggestQuery{Text: "poplin ", LanguageId: langFR, Limit: 1, Languages: testLangs})
+	require.Len(t, ss, 1)
+	assert.Equal(t, "Poplin shirt", ss[0].Text)
+	ss = idx.Suggest(entity.SuggestQuery{Text: "cachemire ", LanguageId: langFR, Limit: 1, Languages: testLangs})
+	require.Len(t, ss, 1)
+	assert.Equal(t, "Pull en cachemire", ss[0].Text)
+}
@@ -0,0 +1,207 @@
+// Package search is the storefront's full-text and faceted search: an in-process inverted index over
+// every ACTIVE colourway — names and descriptions in all storefront languages, tags, collection,
+// categories, composition, style number and SKU — with typo tolerance, facet counts and autocomplete,
+// so the storefront needs no external search service.
+//
+// The Indexer keeps the index current: it polls a cheap fingerprint of the searchable tables and
+// rebuilds the whole index when it moves, so every writer (admin edits, price lists, the markdown
+// worker, stock receipts adding sizes) is picked up without having to notify search.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log/slog"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/health"
+	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+)
+
+// ErrIndexNotReady is returned until the first index has been built.
+var ErrIndexNotReady = errors.New("search index is not built yet")
+
+// tickTimeout bounds the DB work done in a single tick (a fingerprint read and, when it moved, the
+// document load).
+const tickTimeout = time.Minute
+
+// Backoff bounds for consecutive-failure backoff (base * 2^(n-1), capped at
+// backoffMax); a successful tick resets it. Mirrors opexmaterialize.
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 5 * time.Minute
+)
+
+// Config configures the search indexer.
+type Config struct {
+	// WorkerInterval is how often the catalogue fingerprint is checked — a product change reaches
+	// search within one interval plus the rebuild.
+	WorkerInterval time.Duration `mapstructure:"worker_interval"`
+}
+
+// DefaultConfig returns sane defaults (check every 15 seconds).
+func DefaultConfig() Config {
+	return Config{WorkerInterval: 15 * time.Second}
+}
+
+// Indexer owns the current index and rebuilds it when the catalogue changes. It implements
+// dependency.ProductSearch.
+type Indexer struct {
+	repo        dependency.Repository
+	c           *Config
+	idx         atomic.Pointer[Index]
+	fingerprint string
+	ctx         context.Context
+	stop        context.CancelFunc
+	wg          sync.WaitGroup
+	tracker     health.Tracker
+}
+
+// Name implements health.Reporter.
+func (x *Indexer) Name() string { return "search" }
+
+// LastSuccess implements health.Reporter (zero time until the first clean tick).
+func (x *Indexer) LastSuccess() time.Time { return x.tracker.LastSuccess() }
+
+// New constructs a search indexer.
+func New(c *Config, repo dependency.Repository) *Indexer {
+	if c == nil {
+		dc := DefaultConfig()
+		c = &dc
+	}
+	if c.WorkerInterval <= 0 {
+		c.WorkerInterval = DefaultConfig().WorkerInterval
+	}
+	return &Indexer{repo: repo, c: c}
+}
+
+// Start launches the worker goroutine; the first index is built straight away.
+func (x *Indexer) Start(ctx context.Context) error {
+	if x.ctx != nil && x.stop != nil {
+		return fmt.Errorf("search indexer already started")
+	}
+	x.ctx, x.stop = context.WithCancel(ctx)
+	x.wg.Go(func() {
+		x.run(x.ctx)
+	})
+	return nil
+}
+
+// Stop signals the worker to exit and waits for its goroutine to return.
+func (x *Indexer) Stop() error {
+	if x.stop == nil {
+		return fmt.Errorf("search indexer already stopped or not started")
+	}
+	x.stop()
+	x.stop = nil
+	x.wg.Wait()
+	return nil
+}
+
+// Search implements dependency.ProductSearch.
+func (x *Indexer) Search(q entity.SearchQuery) (*entity.SearchResult, error) {
+	idx := x.idx.Load()
+	if idx == nil {
+		return nil, ErrIndexNotReady
+	}
+	return idx.Search(q)
+}
+
+// Suggest implements dependency.ProductSearch.
+func (x *Indexer) Suggest(q entity.SuggestQuery) ([]entity.SearchSuggestion, error) {
+	idx := x.idx.Load()
+	if idx == nil {
+		return nil, ErrIndexNotReady
+	}
+	return idx.Suggest(q), nil
+}
+
+func (x *Indexer) run(ctx context.Context) {
+	ticker := time.NewTicker(x.c.WorkerInterval)
+	defer ticker.Stop()
+
+	x.runOnce(ctx)
+
+	var consecutiveFailures int
+	for {
+		select {
+		case <-ticker.C:
+			if x.runOnce(ctx) {
+				consecutiveFailures = 0
+				continue
+			}
+			consecutiveFailures++
+			delay := backoffDelay(consecutiveFailures)
+			slog.Default().WarnContext(ctx, "search: backing off after failed tick",
+				slog.Int("consecutive_failures", consecutiveFailures),
+				slog.Duration("delay", delay),
+			)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backoffDelay returns base * 2^(n-1), capped at backoffMax.
+func backoffDelay(consecutiveFailures int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < consecutiveFailures; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+// runOnce rebuilds the index when the fingerprint moved. The fingerprint is read before the
+// documents: a write landing between the two leaves an older fingerprint, which only costs one extra
+// rebuild on the next tick, never a missed change. A failed rebuild keeps serving the previous index.
+func (x *Indexer) runOnce(ctx context.Context) bool {
+	defer saferun.Recover(ctx, "search")
+
+	tickCtx, cancel := context.WithTimeout(ctx, tickTimeout)
+	defer cancel()
+
+	fp, err := x.repo.Products().SearchFingerprint(tickCtx)
+	if err != nil {
+		x.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "search: fingerprint failed", slog.String("err", err.Error()))
+		return false
+	}
+	if fp == x.fingerprint && x.idx.Load() != nil {
+		x.tracker.MarkSuccess()
+		return true
+	}
+
+	started := time.Now()
+	docs, err := x.repo.Products().ListSearchDocuments(tickCtx)
+	if err != nil {
+		x.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "search: loading documents failed", slog.String("err", err.Error()))
+		return false
+	}
+	idx := Build(docs, time.Now())
+	x.idx.Store(idx)
+	x.fingerprint = fp
+	x.tracker.MarkSuccess()
+	slog.Default().InfoContext(ctx, "search: index rebuilt",
+		slog.Int("colourways", idx.Len()),
+		slog.Int("terms", len(idx.vocab)),
+		slog.Duration("took", time.Since(started)),
+	)
+	return true
+}
@@ -0,0 +1,502 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidQuery wraps a search the storefront sent wrong (a negative or inverted price range).
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Match-quality factors: a word read as the start of a longer term, or as a misspelling of one,
+// scores below the exact term.
+const (
+	prefixFactor = 0.8
+	typoFactor1  = 0.6
+	typoFactor2  = 0.4
+)
+
+// Expansion caps: a one-letter prefix can match thousands of terms; the most common ones are kept.
+const (
+	maxPrefixTerms = 64
+	maxTypoTerms   = 16
+)
+
+// termMatch is a vocabulary term a query word is read as.
+type termMatch struct {
+	term   string
+	factor float64
+	edits  int
+}
+
+// expand reads a query word against the vocabulary: the exact term; when partial (the word being
+// typed) every term it begins; when neither exists, the terms within its typo budget.
+func (idx *Index) expand(word string, partial bool) []termMatch {
+	var out []termMatch
+	_, exact := idx.postings[word]
+	if exact {
+		out = append(out, termMatch{term: word, factor: 1})
+	}
+	if partial && !isBigramScript([]rune(word)[0]) {
+		lo, hi := idx.prefixRange(word)
+		var prefixed []termMatch
+		for i := lo; i < hi; i++ {
+			if idx.vocab[i] != word {
+				prefixed = append(prefixed, termMatch{term: idx.vocab[i], factor: prefixFactor})
+			}
+		}
+		out = append(out, idx.mostCommon(prefixed, maxPrefixTerms)...)
+	}
+	if len(out) > 0 {
+		return out
+	}
+	w := []rune(word)
+	budget := maxEdits(w)
+	if budget == 0 {
+		return out
+	}
+	var typos []termMatch
+	for i, t := range idx.runes {
+		if e := editDistance(w, t, budget); e <= budget && e > 0 {
+			f := typoFactor1
+			if e > 1 {
+				f = typoFactor2
+			}
+			typos = append(typos, termMatch{term: idx.vocab[i], factor: f, edits: e})
+		}
+	}
+	sort.SliceStable(typos, func(i, j int) bool { return typos[i].edits < typos[j].edits })
+	if len(typos) > maxTypoTerms {
+		typos = typos[:maxTypoTerms]
+	}
+	return append(out, typos...)
+}
+
+// mostCommon keeps the n matches found in the most colourways, in vocabulary order.
+func (idx *Index) mostCommon(ms []termMatch, n int) []termMatch {
+	if len(ms) <= n {
+		return ms
+	}
+	sorted := slices.Clone(ms)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(idx.postings[sorted[i].term]) > len(idx.postings[sorted[j].term])
+	})
+	return sorted[:n]
+}
+
+// correction is the term a word with no exact or prefix match was read as: the closest, then most
+// common, typo candidate. Empty when the word was found as typed.
+func (idx *Index) correction(ms []termMatch) string {
+	var best *termMatch
+	for i := range ms {
+		m := &ms[i]
+		if m.edits == 0 {
+			return ""
+		}
+		if best == nil || m.edits < best.edits ||
+			(m.edits == best.edits && len(idx.postings[m.term]) > len(idx.postings[best.term])) {
+			best = m
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.term
+}
+
+// queryWords tokenizes a query. The last word is partial (the shopper may still be typing it) unless
+// the text ends in a separator.
+func queryWords(text string) (words []string, lastPartial bool) {
+	words = tokenize(text)
+	if len(words) == 0 {
+		return nil, false
+	}
+	r := []rune(text)
+	last := r[len(r)-1]
+	return words, unicode.IsLetter(last) || unicode.IsDigit(last)
+}
+
+// match scores every colourway containing all words (each through any of its expansions, best
+// expansion counted). Corrections are recorded for words read as a misspelling.
+func (idx *Index) match(words []string, lastPartial bool, corrections map[string]string) map[int32]float64 {
+	var scores map[int32]float64
+	for i, w := range words {
+		ms := idx.expand(w, lastPartial && i == len(words)-1)
+		if c := idx.correction(ms); c != "" && corrections != nil {
+			corrections[w] = c
+		}
+		best := make(map[int32]float64)
+		for _, m := range ms {
+			for _, p := range idx.postings[m.term] {
+				if scores != nil {
+					if _, ok := scores[p.doc]; !ok {
+						continue
+					}
+				}
+				if s := idx.termScore(m.term, p) * m.factor; s > best[p.doc] {
+					best[p.doc] = s
+				}
+			}
+		}
+		if scores == nil {
+			scores = best
+			continue
+		}
+		next := make(map[int32]float64, len(best))
+		for doc, s := range best {
+			next[doc] = scores[doc] + s
+		}
+		scores = next
+	}
+	return scores
+}
+
+// visible mirrors the storefront listing's tier gate: a hidden_for_non_qualified colourway exists
+// only for viewers who qualify for it (tier-locked teasers are returned to everyone).
+func visible(d *entity.SearchDocument, viewerTier int16) bool {
+	return !d.HiddenForNonQualified || entity.TierCanPurchase(viewerTier, d.MinTier)
+}
+
+// facet dimensions, counted disjunctively.
+const (
+	dimGender = iota
+	dimTopCategory
+	dimCollection
+	dimColor
+	dimSeason
+	dimSize
+	dimOnSale
+	numDims
+)
+
+// filter is FilterConditions compiled for per-document checks. It mirrors the WHERE clauses of the
+// storefront paged listing so search and browse agree on what a filter means.
+type filter struct {
+	fc          entity.FilterConditions
+	gender      map[entity.GenderEnum]bool
+	topCategory map[int]bool
+	excludeTop  map[int]bool
+	subCategory map[int]bool
+	types       map[int]bool
+	sizes       map[int]bool
+	collections map[string]bool
+	colors      map[string]bool
+	seasons     map[entity.SeasonEnum]bool
+}
+
+func setOf[T comparable](vs []T) map[T]bool {
+	if len(vs) == 0 {
+		return nil
+	}
+	m := make(map[T]bool, len(vs))
+	for _, v := range vs {
+		m[v] = true
+	}
+	return m
+}
+
+func newFilter(fc entity.FilterConditions) (*filter, error) {
+	if fc.Currency != "" {
+		if fc.From.LessThan(decimal.Zero) {
+			return nil, fmt.Errorf("%w: price range cannot be negative", ErrInvalidQuery)
+		}
+		if fc.From.GreaterThan(fc.To) && !fc.To.IsZero() {
+			return nil, fmt.Errorf("%w: invalid price range: from cannot be greater than to unless to is unset", ErrInvalidQuery)
+		}
+	}
+	return &filter{
+		fc:          fc,
+		gender:      setOf(fc.Gender),
+		topCategory: setOf(fc.TopCategoryIds),
+		excludeTop:  setOf(fc.ExcludeTopCategoryIds),
+		subCategory: setOf(fc.SubCategoryIds),
+		types:       setOf(fc.TypeIds),
+		sizes:       setOf(fc.SizesIds),
+		collections: setOf(fc.Collections),
+		colors:      setOf(fc.ColorCodes),
+		seasons:     setOf(fc.Seasons),
+	}, nil
+}
+
+// base checks the gates and the filters that are not facets.
+func (f *filter) base(d *entity.SearchDocument) bool {
+	if !visible(d, f.fc.ViewerTier) {
+		return false
+	}
+	if f.fc.Exclusive && d.MinTier <= 0 {
+		return false
+	}
+	if f.excludeTop[d.TopCategoryId] {
+		return false
+	}
+	if f.subCategory != nil && !f.subCategory[d.SubCategoryId] {
+		return false
+	}
+	if f.types != nil && !f.types[d.TypeId] {
+		return false
+	}
+	if f.fc.Preorder && !d.Preorder {
+		return false
+	}
+	if f.fc.ByTag != "" && !slices.Contains(d.Tags, f.fc.ByTag) {
+		return false
+	}
+	return f.price(d)
+}
+
+// price applies the price range on the sale price, with the listing's semantics: a zero bound is unset.
+func (f *filter) price(d *entity.SearchDocument) bool {
+	fc := f.fc
+	if fc.Currency == "" || (fc.From.IsZero() && fc.To.IsZero()) {
+		return true
+	}
+	p, ok := d.SalePrice(fc.Currency)
+	if !ok {
+		return false
+	}
+	if fc.From.IsPositive() && p.LessThan(fc.From) {
+		return false
+	}
+	if fc.To.IsPositive() && p.GreaterThan(fc.To) {
+		return false
+	}
+	return true
+}
+
+// failing returns the facet dimension the colourway fails and how many it fails (counting stops at
+// two: such a colourway counts towards no facet).
+func (f *filter) failing(d *entity.SearchDocument) (dim, n int) {
+	dim = -1
+	fail := func(k int) {
+		if dim < 0 {
+			dim = k
+		}
+		n++
+	}
+	if f.gender != nil && !f.gender[d.TargetGender] {
+		fail(dimGender)
+	}
+	if f.topCategory != nil && !f.topCategory[d.TopCategoryId] {
+		fail(dimTopCategory)
+	}
+	if f.collections != nil && !f.collections[d.Collection] {
+		fail(dimCollection)
+	}
+	if f.colors != nil && !f.colors[d.ColorCode] {
+		fail(dimColor)
+	}
+	if f.seasons != nil && !f.seasons[d.Season] {
+		fail(dimSeason)
+	}
+	if f.sizes != nil && !slices.ContainsFunc(d.SizeIds, func(id int) bool { return f.sizes[id] }) {
+		fail(dimSize)
+	}
+	if f.fc.OnSale && !d.OnSale() {
+		fail(dimOnSale)
+	}
+	return dim, n
+}
+
+// facetCounter counts facet values; a colourway failing exactly one facet filter still counts for
+// that facet, which is what makes the counts disjunctive.
+type facetCounter struct {
+	values [numDims]map[string]int
+	onSale int
+}
+
+func (c *facetCounter) add(d *entity.SearchDocument, only int) {
+	inc := func(dim int, v string) {
+		if v == "" || (only >= 0 && only != dim) {
+			return
+		}
+		if c.values[dim] == nil {
+			c.values[dim] = make(map[string]int)
+		}
+		c.values[dim][v]++
+	}
+	inc(dimGender, string(d.TargetGender))
+	inc(dimTopCategory, strconv.Itoa(d.TopCategoryId))
+	inc(dimCollection, d.Collection)
+	inc(dimColor, d.ColorCode)
+	inc(dimSeason, string(d.Season))
+	for _, id := range d.SizeIds {
+		inc(dimSize, strconv.Itoa(id))
+	}
+	if d.OnSale() && (only < 0 || only == dimOnSale) {
+		c.onSale++
+	}
+}
+
+func (c *facetCounter) facets() entity.SearchFacets {
+	list := func(dim int) []entity.SearchFacet {
+		out := make([]entity.SearchFacet, 0, len(c.values[dim]))
+		for v, n := range c.values[dim] {
+			out = append(out, entity.SearchFacet{Value: v, Count: n})
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Count != out[j].Count {
+				return out[i].Count > out[j].Count
+			}
+			return out[i].Value < out[j].Value
+		})
+		return out
+	}
+	return entity.SearchFacets{
+		Gender:      list(dimGender),
+		TopCategory: list(dimTopCategory),
+		Collection:  list(dimCollection),
+		Color:       list(dimColor),
+		Season:      list(dimSeason),
+		Size:        list(dimSize),
+		OnSale:      c.onSale,
+	}
+}
+
+// Search ranks the colourways matching q.Text and q.Filters. An empty text matches every colourway
+// (newest first), which gives the facet counts of a plain filtered listing.
+func (idx *Index) Search(q entity.SearchQuery) (*entity.SearchResult, error) {
+	f, err := newFilter(q.Filters)
+	if err != nil {
+		return nil, err
+	}
+	res := &entity.SearchResult{Corrections: map[string]string{}, IndexedAt: idx.builtAt}
+
+	words, lastPartial := queryWords(q.Text)
+	var scores map[int32]float64
+	if len(words) > 0 {
+		scores = idx.match(words, lastPartial, res.Corrections)
+	}
+
+	var (
+		counter facetCounter
+		hits    []entity.SearchHit
+	)
+	consider := func(i int32, score float64) {
+		d := &idx.docs[i]
+		if !f.base(d) {
+			return
+		}
+		dim, n := f.failing(d)
+		switch n {
+		case 0:
+			counter.add(d, -1)
+			hits = append(hits, entity.SearchHit{ProductId: d.ProductId, Score: score})
+		case 1:
+			counter.add(d, dim)
+		}
+	}
+	if len(words) == 0 {
+		for i := range idx.docs {
+			consider(int32(i), 0)
+		}
+	} else {
+		for i, s := range scores {
+			consider(i, s)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		a, b := &idx.docs[idx.pos[hits[i].ProductId]], &idx.docs[idx.pos[hits[j].ProductId]]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ProductId > b.ProductId
+	})
+
+	res.Total = len(hits)
+	res.Facets = counter.facets()
+	lo := min(max(q.Offset, 0), len(hits))
+	hi := len(hits)
+	if q.Limit > 0 {
+		hi = min(lo+q.Limit, len(hits))
+	}
+	res.Hits = hits[lo:hi]
+	return res, nil
+}
+
+// Suggest completes a partly typed query: first the most common completions of the last word among
+// the colourways matching the words before it, then the best matching colourways by name. Only what
+// the viewer may see is counted or suggested.
+func (idx *Index) Suggest(q entity.SuggestQuery) []entity.SearchSuggestion {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 8
+	}
+	words, lastPartial := queryWords(q.Text)
+	if len(words) == 0 {
+		return nil
+	}
+	var out []entity.SearchSuggestion
+
+	last := words[len(words)-1]
+	var before map[int32]float64
+	if len(words) > 1 {
+		before = idx.match(words[:len(words)-1], false, nil)
+	}
+	if lastPartial && !isBigramScript([]rune(last)[0]) {
+		type completion struct {
+			term  string
+			count int
+		}
+		var completions []completion
+		lo, hi := idx.prefixRange(last)
+		for i := lo; i < hi; i++ {
+			n := 0
+			for _, p := range idx.postings[idx.vocab[i]] {
+				if before != nil {
+					if _, ok := before[p.doc]; !ok {
+						continue
+					}
+				}
+				if visible(&idx.docs[p.doc], q.ViewerTier) {
+					n++
+				}
+			}
+			if n > 0 {
+				completions = append(completions, completion{term: idx.vocab[i], count: n})
+			}
+		}
+		sort.SliceStable(completions, func(i, j int) bool { return completions[i].count > completions[j].count })
+		prefix := strings.Join(words[:len(words)-1], " ")
+		for _, c := range completions[:min(len(completions), (limit+1)/2)] {
+			text := c.term
+			if prefix != "" {
+				text = prefix + " " + c.term
+			}
+			out = append(out, entity.SearchSuggestion{Kind: entity.SearchSuggestionQuery, Text: text})
+		}
+	}
+
+	if len(out) >= limit {
+		return out
+	}
+	res, err := idx.Search(entity.SearchQuery{
+		Text:    q.Text,
+		Filters: entity.FilterConditions{ViewerTier: q.ViewerTier},
+		Limit:   limit - len(out),
+	})
+	if err != nil {
+		return out
+	}
+	for _, h := range res.Hits {
+		d := &idx.docs[idx.pos[h.ProductId]]
+		out = append(out, entity.SearchSuggestion{
+			Kind:      entity.SearchSuggestionProduct,
+			Text:      docName(d, q.LanguageId, q.Languages),
+			ProductId: d.ProductId,
+			SKU:       d.SKU,
+		})
+	}
+	return out
+}
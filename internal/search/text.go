@@ -0,0 +1,141 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldReplacer spells out the Latin letters that do not decompose into a base letter plus a mark, so
+// "straße" finds "strasse" and "Ørsted" finds "orsted".
+var foldReplacer = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "þ", "th", "ı", "i",
+)
+
+// fold lower-cases s, applies compatibility decomposition (full-width forms, ligatures) and drops the
+// combining marks, so accents and case never decide a match.
+func fold(s string) string {
+	s = norm.NFKD.String(strings.ToLower(s))
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return foldReplacer.Replace(b.String())
+}
+
+// isBigramScript reports whether r belongs to a script written without spaces (Chinese, Japanese
+// kana); such runs are indexed as overlapping character pairs instead of words.
+func isBigramScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// tokenize folds s and splits it into terms: runs of letters and digits, with runs in a bigram
+// script cut into overlapping pairs (a lone character stays a term of its own).
+func tokenize(s string) []string {
+	var (
+		out  []string
+		word []rune
+		cjk  []rune
+	)
+	flushWord := func() {
+		if len(word) > 0 {
+			out = append(out, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			out = append(out, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				out = append(out, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range fold(s) {
+		switch {
+		case isBigramScript(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return out
+}
+
+// compactCode folds a code (SKU, style number) and strips its separators, so "GR-1021/BLK" is also
+// findable as "gr1021blk". Empty when the code has no separators to strip.
+func compactCode(s string) string {
+	if len(tokenize(s)) <= 1 {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range fold(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// editDistance is the optimal-string-alignment distance between a and b (insertions, deletions,
+// substitutions and adjacent transpositions), giving up with limit+1 once the distance must exceed limit.
+func editDistance(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			v := min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				v = min(v, prev2[j-2]+1)
+			}
+			cur[j] = v
+			rowMin = min(rowMin, v)
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// maxEdits is the typo budget for a query word: none below four characters (too many short words
+// are one edit apart), one up to seven, two from eight.
+func maxEdits(term []rune) int {
+	switch {
+	case len(term) < 4:
+		return 0
+	case len(term) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,322 @@
+// Package sizerec recommends a size of a style from a customer's body measurements (0351). For every
+// charted size it compares the garment with the body — the ease, garment minus body — against the ease
+// the style is meant to be worn with, and picks the size closest to it.
+//
+// The target ease comes from, in order of trust:
+//   - approved single-size fittings of the style on fit models: the model's body against the chart
+//     in the size they were approved in is the intended ease, by definition;
+//   - otherwise per-point defaults for a regular fit;
+//
+// moved by the customer's fit preference (girths only) and by the style's wrong-size exchanges: when
+// customers keep swapping up, the chart runs small and the target ease grows, and vice versa.
+//
+// A size the customer already wears in another style stands in for measurements (ImpliedBody): the
+// body that size fits as intended is the body we size for.
+package sizerec
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNoOverlap is returned when none of the body measurements meets a point of the style's chart.
+var ErrNoOverlap = errors.New("measurements do not cover the size chart")
+
+// point is how a garment point of measure compares with the body. Girths are charted laid flat (half
+// the circumference), so they count twice against the body girth. EaseMM is the default target
+// ease, TolMM the deviation from it that still fits.
+type point struct {
+	Body   entity.BodyMeasurementName
+	Girth  bool
+	EaseMM int
+	TolMM  int
+	Weight float64
+}
+
+// points maps the garment measurement_name dictionary to the body. Points with no body counterpart
+// (length, rise, leg-opening…) are not compared.
+var points = map[string]point{
+	"chest":     {Body: entity.BodyChest, Girth: true, EaseMM: 80, TolMM: 50, Weight: 1},
+	"waist":     {Body: entity.BodyWaist, Girth: true, EaseMM: 40, TolMM: 40, Weight: 1},
+	"hips":      {Body: entity.BodyHip, Girth: true, EaseMM: 60, TolMM: 50, Weight: 0.8},
+	"hip":       {Body: entity.BodyHip, Girth: true, EaseMM: 60, TolMM: 50, Weight: 0.8},
+	"shoulders": {Body: entity.BodyAcrossShoulder, EaseMM: 10, TolMM: 25, Weight: 0.6},
+	"sleeve":    {Body: entity.BodySleeveLength, EaseMM: 0, TolMM: 30, Weight: 0.4},
+	"inseam":    {Body: entity.BodyInseam, EaseMM: 0, TolMM: 30, Weight: 0.5},
+}
+
+const (
+	// tightPenalty weighs a garment tighter than its target over one as much looser: a loose fit is
+	// wearable, a tight one comes back.
+	tightPenalty = 1.5
+	// minDirectional is how many directional wrong-size exchanges it takes before they move the ease.
+	minDirectional = 5
+	// runsThreshold is the exchange skew (up minus down over all) that labels a style as running
+	// small or large.
+	runsThreshold = 1.0 / 3
+	// minSoldForRate is the sales in a size under which its wrong-size rate is noise.
+	minSoldForRate = 10
+)
+
+// Basis says what the recommendation was computed from.
+type Basis string
+
+const (
+	BasisMeasurements Basis = "measurements"
+	BasisReference    Basis = "reference"
+	BasisBoth         Basis = "measurements_and_reference"
+)
+
+// Verdict is how one point of measure sits on the body.
+type Verdict string
+
+const (
+	VerdictTight Verdict = "tight"
+	VerdictGood  Verdict = "good"
+	VerdictLoose Verdict = "loose"
+)
+
+// Body is a set of body measurements in mm.
+type Body map[entity.BodyMeasurementName]int
+
+// BodyFromMeasurements indexes stored measurements by name.
+func BodyFromMeasurements(ms []entity.ModelMeasurement) Body {
+	b := make(Body, len(ms))
+	for _, m := range ms {
+		if m.ValueMM > 0 {
+			b[m.Name] = m.ValueMM
+		}
+	}
+	return b
+}
+
+// PointFit is one point of measure of a size against the body.
+type PointFit struct {
+	Measurement  string
+	EaseMM       int // garment minus body
+	TargetEaseMM int
+	Verdict      Verdict
+}
+
+// SizeFit is one charted size against the body; Score is the weighted mean squared deviation from
+// the target ease in tolerances (0 — exactly as intended).
+type SizeFit struct {
+	SizeId int
+	Name   string
+	Score  float64
+	Points []PointFit
+}
+
+// Recommendation is the recommended size with a confidence in [0, 1] and every size the
+// measurements reach, smallest first.
+type Recommendation struct {
+	SizeId     int
+	Name       string
+	Confidence float64
+	Basis      Basis
+	// Calibrated is set when the target ease of a compared point comes from fit-model fittings.
+	Calibrated bool
+	RunsSmall  bool
+	RunsLarge  bool
+	Sizes      []SizeFit
+}
+
+// style is a StyleFitData in mm with its target ease resolved.
+type style struct {
+	garment    map[int]map[string]int // size → point → mm, body-comparable (girths doubled)
+	ease       map[string]float64     // point → target ease, mm
+	calibrated map[string]bool
+	bias       float64 // -1 (runs large) … 1 (runs small)
+}
+
+func newStyle(d *entity.StyleFitData) style {
+	scale := decimal.NewFromInt(10)
+	if d.Unit == entity.TechCardUnitMm {
+		scale = decimal.NewFromInt(1)
+	}
+	st := style{
+		garment:    make(map[int]map[string]int),
+		ease:       make(map[string]float64, len(points)),
+		calibrated: make(map[string]bool),
+	}
+	for _, c := range d.Chart {
+		p, ok := points[c.Measurement]
+		if !ok || !c.Value.IsPositive() {
+			continue
+		}
+		mm := int(c.Value.Mul(scale).Round(0).IntPart())
+		if p.Girth {
+			mm *= 2
+		}
+		if st.garment[c.SizeId] == nil {
+			st.garment[c.SizeId] = make(map[string]int)
+		}
+		st.garment[c.SizeId][c.Measurement] = mm
+	}
+
+	// Fit models: the mean ease each point was approved at.
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, f := range d.Fittings {
+		body := BodyFromMeasurements(f.Measurements)
+		for name, g := range st.garment[f.SizeId] {
+			if b, ok := body[points[name].Body]; ok {
+				sums[name] += float64(g - b)
+				counts[name]++
+			}
+		}
+	}
+	for name, p := range points {
+		st.ease[name] = float64(p.EaseMM)
+		if counts[name] > 0 {
+			st.ease[name] = sums[name] / float64(counts[name])
+			st.calibrated[name] = true
+		}
+	}
+
+	// Wrong-size exchanges: a chart that runs small needs more ease than it shows, and vice versa.
+	var up, down int
+	for _, r := range d.Returns {
+		up += r.ExchangedUp
+		down += r.ExchangedDown
+	}
+	if up+down >= minDirectional {
+		st.bias = float64(up-down) / float64(up+down)
+		for name, p := range points {
+			st.ease[name] += st.bias * float64(p.TolMM) / 2
+		}
+	}
+	return st
+}
+
+// sortedPoints returns the size's charted points in a stable order.
+func (st style) sortedPoints(sizeID int) []string {
+	names := make([]string, 0, len(st.garment[sizeID]))
+	for name := range st.garment[sizeID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ImpliedBody is the body the style in sizeID fits exactly as intended: the chart minus the target
+// ease. It is how a size the customer already wears becomes measurements.
+func ImpliedBody(d *entity.StyleFitData, sizeID int) Body {
+	st := newStyle(d)
+	body := make(Body)
+	for _, name := range st.sortedPoints(sizeID) {
+		p := points[name]
+		if _, ok := body[p.Body]; ok {
+			continue
+		}
+		if mm := int(math.Round(float64(st.garment[sizeID][name]) - st.ease[name])); mm > 0 {
+			body[p.Body] = mm
+		}
+	}
+	return body
+}
+
+// Recommend picks the size of the style whose ease is closest to the target for this body.
+// ErrNoOverlap when no measurement meets the chart.
+func Recommend(d *entity.StyleFitData, body Body, pref entity.FitPreference, basis Basis) (*Recommendation, error) {
+	st := newStyle(d)
+	rec := &Recommendation{
+		Basis:     basis,
+		RunsSmall: st.bias >= runsThreshold,
+		RunsLarge: st.bias <= -runsThreshold,
+	}
+
+	best, second := -1, -1
+	var bestCoverage float64
+	var bestCalibrated bool
+	for _, sz := range d.Sizes {
+		fit := SizeFit{SizeId: sz.SizeId, Name: sz.Name}
+		var cost, used, charted float64
+		calibrated := false
+		for _, name := range st.sortedPoints(sz.SizeId) {
+			p := points[name]
+			charted += p.Weight
+			b, ok := body[p.Body]
+			if !ok {
+				continue
+			}
+			target := st.ease[name]
+			if p.Girth {
+				switch pref {
+				case entity.FitPreferenceClose:
+					target -= float64(p.TolMM) / 2
+				case entity.FitPreferenceRelaxed:
+					target += float64(p.TolMM) / 2
+				}
+			}
+			ease := st.garment[sz.SizeId][name] - b
+			dev := (float64(ease) - target) / float64(p.TolMM)
+			w := p.Weight
+			if dev < 0 {
+				w *= tightPenalty
+			}
+			cost += w * dev * dev
+			used += p.Weight
+			calibrated = calibrated || st.calibrated[name]
+
+			v := VerdictGood
+			if dev < -1 {
+				v = VerdictTight
+			} else if dev > 1 {
+				v = VerdictLoose
+			}
+			fit.Points = append(fit.Points, PointFit{
+				Measurement:  name,
+				EaseMM:       ease,
+				TargetEaseMM: int(math.Round(target)),
+				Verdict:      v,
+			})
+		}
+		if used == 0 {
+			continue
+		}
+		fit.Score = cost / used
+		rec.Sizes = append(rec.Sizes, fit)
+
+		i := len(rec.Sizes) - 1
+		switch {
+		case best < 0 || fit.Score < rec.Sizes[best].Score:
+			second, best = best, i
+			bestCoverage, bestCalibrated = used/charted, calibrated
+		case second < 0 || fit.Score < rec.Sizes[second].Score:
+			second = i
+		}
+	}
+	if best < 0 {
+		return nil, ErrNoOverlap
+	}
+	rec.SizeId = rec.Sizes[best].SizeId
+	rec.Name = rec.Sizes[best].Name
+	rec.Calibrated = bestCalibrated
+
+	// Confidence: how well the best size fits, how much of its chart the measurements cover, how
+	// clearly it beats the runner-up, and how often it came back as the wrong size.
+	c := 1 / (1 + rec.Sizes[best].Score)
+	c *= bestCoverage
+	if second >= 0 {
+		sep := math.Min(1, (rec.Sizes[second].Score-rec.Sizes[best].Score)/0.5)
+		c *= 0.7 + 0.3*sep
+	}
+	for _, r := range d.Returns {
+		if r.SizeId == rec.SizeId && r.Sold >= minSoldForRate {
+			c *= 1 - math.Min(0.5, 2*float64(r.WrongSize)/float64(r.Sold))
+		}
+	}
+	if !bestCalibrated {
+		c *= 0.9
+	}
+	if basis == BasisReference {
+		c *= 0.85
+	}
+	rec.Confidence = math.Round(c*100) / 100
+	return rec, nil
+}
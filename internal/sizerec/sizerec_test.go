@@ -0,0 +1,147 @@
+package sizerec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+)
+
+// tee is a cm-charted style with a flat chest of 50/53/56 cm: 1000/1060/1120 mm around.
+func tee() *entity.StyleFitData {
+	cell := func(size int, name, v string) entity.StyleFitCell {
+		return entity.StyleFitCell{SizeId: size, Measurement: name, Value: decimal.RequireFromString(v)}
+	}
+	return &entity.StyleFitData{
+		StyleId: 1,
+		Unit:    entity.TechCardUnitCm,
+		Sizes:   []entity.FitSize{{SizeId: 1, Name: "s", SkuOrd: 1}, {SizeId: 2, Name: "m", SkuOrd: 2}, {SizeId: 3, Name: "l", SkuOrd: 3}},
+		Chart: []entity.StyleFitCell{
+			cell(1, "chest", "50"), cell(2, "chest", "53"), cell(3, "chest", "56"),
+			cell(1, "length", "70"), cell(2, "length", "72"), cell(3, "length", "74"),
+		},
+	}
+}
+
+func chest(mm int) Body { return Body{entity.BodyChest: mm} }
+
+func TestRecommend(t *testing.T) {
+	cases := []struct {
+		name  string
+		style func() *entity.StyleFitData
+		body  Body
+		pref  entity.FitPreference
+		want  string
+	}{
+		// Ease 40/100/160 against the default 80: M is the closest.
+		{"default ease", tee, chest(960), entity.FitPreferenceRegular, "m"},
+		// A close fit targets 55: S is only a little tight, M a lot loose.
+		{"close fit", tee, chest(960), entity.FitPreferenceClose, "s"},
+		{"relaxed fit", tee, chest(1000), entity.FitPreferenceRelaxed, "l"},
+		// A fit model with a 1000 mm chest approved in M: the intended ease is 60, not 80.
+		{"calibrated by fit model", func() *entity.StyleFitData {
+			d := tee()
+			d.Fittings = []entity.FitModelFitting{{FittingId: 1, SizeId: 2, Measurements: []entity.ModelMeasurement{{Name: entity.BodyChest, ValueMM: 1000}}}}
+			return d
+		}, chest(960), entity.FitPreferenceRegular, "s"},
+		// Customers keep exchanging up: the target ease grows by half a tolerance and L wins.
+		{"runs small", func() *entity.StyleFitData {
+			d := tee()
+			d.Returns = []entity.SizeReturnSignal{{SizeId: 2, Sold: 40, WrongSize: 8, ExchangedUp: 8}}
+			return d
+		}, chest(1000), entity.FitPreferenceRegular, "l"},
+		{"mm chart", func() *entity.StyleFitData {
+			d := tee()
+			d.Unit = entity.TechCardUnitMm
+			for i := range d.Chart {
+				d.Chart[i].Value = d.Chart[i].Value.Mul(decimal.NewFromInt(10))
+			}
+			return d
+		}, chest(960), entity.FitPreferenceRegular, "m"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec, err := Recommend(c.style(), c.body, c.pref, BasisMeasurements)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Name != c.want {
+				t.Fatalf("recommended %s, want %s (sizes %+v)", rec.Name, c.want, rec.Sizes)
+			}
+			if rec.Confidence <= 0 || rec.Confidence > 1 {
+				t.Fatalf("confidence %v out of (0, 1]", rec.Confidence)
+			}
+		})
+	}
+}
+
+func TestRecommendSignals(t *testing.T) {
+	rec, err := Recommend(tee(), chest(960), entity.FitPreferenceRegular, BasisMeasurements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Sizes) != 3 || rec.Calibrated || rec.RunsSmall || rec.RunsLarge {
+		t.Fatalf("unexpected recommendation %+v", rec)
+	}
+	if v := rec.Sizes[0].Points[0]; v.EaseMM != 40 || v.TargetEaseMM != 80 || v.Verdict != VerdictGood {
+		t.Fatalf("S chest = %+v", v)
+	}
+	if v := rec.Sizes[2].Points[0].Verdict; v != VerdictLoose {
+		t.Fatalf("L chest verdict = %s, want loose", v)
+	}
+
+	// Frequent wrong-size returns of the recommended size cost confidence.
+	d := tee()
+	d.Returns = []entity.SizeReturnSignal{{SizeId: 2, Sold: 50, WrongSize: 10}}
+	noisy, err := Recommend(d, chest(960), entity.FitPreferenceRegular, BasisMeasurements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noisy.Name != "m" || noisy.Confidence >= rec.Confidence {
+		t.Fatalf("confidence with returns %v, without %v", noisy.Confidence, rec.Confidence)
+	}
+
+	// Exchanges down label the style as running large.
+	d.Returns = []entity.SizeReturnSignal{{SizeId: 2, Sold: 50, WrongSize: 6, ExchangedDown: 6}}
+	large, err := Recommend(d, chest(960), entity.FitPreferenceRegular, BasisMeasurements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !large.RunsLarge || large.RunsSmall {
+		t.Fatalf("runs large not reported: %+v", large)
+	}
+
+	// Measurements the chart has no point for.
+	if _, err := Recommend(tee(), Body{entity.BodyInseam: 800}, entity.FitPreferenceRegular, BasisMeasurements); !errors.Is(err, ErrNoOverlap) {
+		t.Fatalf("err = %v, want ErrNoOverlap", err)
+	}
+}
+
+func TestImpliedBody(t *testing.T) {
+	// M at the default ease fits a 980 mm chest; the length point has no body counterpart.
+	body := ImpliedBody(tee(), 2)
+	if len(body) != 1 || body[entity.BodyChest] != 980 {
+		t.Fatalf("implied body = %v", body)
+	}
+
+	// The same customer sized in a roomier style drops a size, at a lower confidence than measured.
+	roomy := tee()
+	for i := range roomy.Chart {
+		roomy.Chart[i].Value = roomy.Chart[i].Value.Add(decimal.NewFromInt(3))
+	}
+	rec, err := Recommend(roomy, body, entity.FitPreferenceRegular, BasisReference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "s" {
+		t.Fatalf("recommended %s, want s", rec.Name)
+	}
+	measured, err := Recommend(roomy, body, entity.FitPreferenceRegular, BasisMeasurements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Confidence >= measured.Confidence {
+		t.Fatalf("reference confidence %v should be under measured %v", rec.Confidence, measured.Confidence)
+	}
+}
@@ -0,0 +1,64 @@
+package specpdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // эскизы в медиатеке бывают PNG
+	"math"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // и WebP — бакет хранит сжатые варианты в нём
+)
+
+// maxImageSide — длинная сторона картинки в файле, px. Эскиз на A4 печатается шириной ~18 см, и 2000
+// px — это ~280 dpi: больше фабрике не нужно, а full-size варианты медиатеки бывают по 6000 px и
+// раздули бы файл на порядок без единой различимой линии.
+const maxImageSide = 2000
+
+// jpegQuality — качество перекодирования. Эскизы — линии по белому, и ниже 85 вокруг них видна
+// «грязь» JPEG.
+const jpegQuality = 85
+
+// pictureOf готовит картинку к вшиванию: PDF без фильтров-декодеров умеет только JPEG (DCTDecode),
+// поэтому JPEG в RGB/Gray нужного размера уходит как есть, а всё остальное (PNG, WebP, CMYK-JPEG,
+// слишком большое) декодируется и перекодируется.
+func pictureOf(raw []byte) (*picture, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("empty image %dx%d", cfg.Width, cfg.Height)
+	}
+	if format == "jpeg" && max(cfg.Width, cfg.Height) <= maxImageSide {
+		switch cfg.ColorModel {
+		case color.YCbCrModel, color.RGBAModel:
+			return &picture{data: raw, w: cfg.Width, h: cfg.Height}, nil
+		case color.GrayModel:
+			return &picture{data: raw, w: cfg.Width, h: cfg.Height, gray: true}, nil
+		}
+	}
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s image: %w", format, err)
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if side := max(w, h); side > maxImageSide {
+		k := float64(maxImageSide) / float64(side)
+		w, h = max(1, int(math.Round(float64(w)*k))), max(1, int(math.Round(float64(h)*k)))
+	}
+	// Прозрачность PNG кладётся на БЕЛОЕ: у JPEG альфы нет, и без подложки прозрачный фон эскиза
+	// превращается в чёрный прямоугольник с невидимыми линиями.
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg: %w", err)
+	}
+	return &picture{data: out.Bytes(), w: w, h: h}, nil
+}
@@ -0,0 +1,385 @@
+package specpdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Минимальный писатель PDF 1.4: страницы A4, текст, линии, заливки и JPEG. Своим, а не библиотекой,
+// по двум причинам. Зависимости под PDF в модуле нет, а всё, что спецификации нужно от формата, —
+// это таблицы и картинки. И кириллица: 14 стандартных шрифтов PDF знают только WinAnsi, а карточки
+// пишутся по-русски, поэтому шрифт ВШИВАЕТСЯ — Go-шрифты из golang.org/x/image (WGL4, кириллица
+// есть) составным шрифтом Identity-H с ToUnicode, чтобы текст из файла копировался и искался.
+
+// A4 в пунктах.
+const (
+	pageW = 595.28
+	pageH = 841.89
+)
+
+// face — один вшитый шрифт и глифы, которые документ им напечатал (для /W и ToUnicode).
+type face struct {
+	res  string
+	name string
+	raw  []byte
+	f    *sfnt.Font
+	upm  float64
+	buf  sfnt.Buffer
+	used map[sfnt.GlyphIndex]rune
+	adv  map[rune]float64
+}
+
+func newFace(res, name string, raw []byte) (*face, error) {
+	f, err := sfnt.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %s: %w", name, err)
+	}
+	return &face{
+		res:  res,
+		name: name,
+		raw:  raw,
+		f:    f,
+		upm:  float64(f.UnitsPerEm()),
+		used: map[sfnt.GlyphIndex]rune{},
+		adv:  map[rune]float64{},
+	}, nil
+}
+
+func (fc *face) ppem() fixed.Int26_6 { return fixed.Int26_6(fc.upm * 64) }
+
+// glyph — индекс глифа руны; руна, которой в шрифте нет, печатается знаком вопроса, а не дырой.
+func (fc *face) glyph(r rune) sfnt.GlyphIndex {
+	g, err := fc.f.GlyphIndex(&fc.buf, r)
+	if err != nil || g == 0 {
+		g, _ = fc.f.GlyphIndex(&fc.buf, '?')
+	}
+	return g
+}
+
+// advance — ширина руны в единицах em (1 = кегль).
+func (fc *face) advance(r rune) float64 {
+	if a, ok := fc.adv[r]; ok {
+		return a
+	}
+	a, err := fc.f.GlyphAdvance(&fc.buf, fc.glyph(r), fc.ppem(), font.HintingNone)
+	w := 0.0
+	if err == nil {
+		w = float64(a) / 64 / fc.upm
+	}
+	fc.adv[r] = w
+	return w
+}
+
+// width — ширина строки в пунктах при кегле size.
+func (fc *face) width(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		w += fc.advance(r)
+	}
+	return w * size
+}
+
+// encode превращает строку в hex-строку двухбайтных глифов (Identity-H) и запоминает глифы.
+func (fc *face) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		if r < ' ' {
+			r = ' '
+		}
+		g := fc.glyph(r)
+		if _, ok := fc.used[g]; !ok {
+			fc.used[g] = r
+		}
+		fmt.Fprintf(&b, "%04X", uint16(g))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// picture — одна картинка документа, уже в JPEG.
+type picture struct {
+	res  string
+	data []byte
+	w, h int
+	gray bool
+}
+
+// page — поток одной страницы.
+type page struct {
+	content bytes.Buffer
+	images  map[string]bool
+}
+
+// doc собирает страницы и сериализует файл.
+type doc struct {
+	regular, bold *face
+	images        []*picture
+	pages         []*page
+}
+
+func newDoc() (*doc, error) {
+	r, err := newFace("F1", "GoRegular", goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newFace("F2", "GoBold", gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	return &doc{regular: r, bold: b}, nil
+}
+
+func (d *doc) face(bold bool) *face {
+	if bold {
+		return d.bold
+	}
+	return d.regular
+}
+
+func (d *doc) addPage() *page {
+	p := &page{images: map[string]bool{}}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func (d *doc) addImage(im *picture) *picture {
+	im.res = fmt.Sprintf("Im%d", len(d.images)+1)
+	d.images = append(d.images, im)
+	return im
+}
+
+// Операторы рисования. Координаты везде ОТ ВЕРХНЕГО ЛЕВОГО угла страницы, в пунктах; перевод в
+// систему PDF (снизу вверх) делается здесь, одним местом.
+
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+func (p *page) text(fc *face, size, x, y float64, s string, gray float64) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(&p.content, "BT %s g /%s %s Tf %s %s Td %s Tj ET\n",
+		num(gray), fc.res, num(size), num(x), num(pageH-y), fc.encode(s))
+}
+
+func (p *page) line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "%s G %s w %s %s m %s %s l S\n",
+		num(gray), num(width), num(x1), num(pageH-y1), num(x2), num(pageH-y2))
+}
+
+func (p *page) fillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "%s g %s %s %s %s re f\n", num(gray), num(x), num(pageH-y-h), num(w), num(h))
+}
+
+func (p *page) strokeRect(x, y, w, h, width, gray float64) {
+	fmt.Fprintf(&p.content, "%s G %s w %s %s %s %s re S\n", num(gray), num(width), num(x), num(pageH-y-h), num(w), num(h))
+}
+
+// circle — окружность четырьмя кривыми Безье (маркер выноски на эскизе).
+func (p *page) circle(cx, cy, r, width float64, fill bool) {
+	const k = 0.5523
+	y := pageH - cy
+	fmt.Fprintf(&p.content, "0 G 1 g %s w %s %s m ", num(width), num(cx+r), num(y))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", num(cx+r), num(y+k*r), num(cx+k*r), num(y+r), num(cx), num(y+r))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", num(cx-k*r), num(y+r), num(cx-r), num(y+k*r), num(cx-r), num(y))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", num(cx-r), num(y-k*r), num(cx-k*r), num(y-r), num(cx), num(y-r))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", num(cx+k*r), num(y-r), num(cx+r), num(y-k*r), num(cx+r), num(y))
+	if fill {
+		p.content.WriteString("b\n")
+	} else {
+		p.content.WriteString("s\n")
+	}
+}
+
+func (p *page) drawImage(im *picture, x, y, w, h float64) {
+	p.images[im.res] = true
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(pageH-y-h), im.res)
+}
+
+// bytes сериализует документ. Номера объектов раздаются заранее: каталог 1, дерево страниц 2,
+// дальше шрифты, картинки и страницы.
+func (d *doc) bytes(title string) ([]byte, error) {
+	var objs [][]byte
+	alloc := func() int { objs = append(objs, nil); return len(objs) }
+	set := func(id int, body []byte) { objs[id-1] = body }
+
+	catalog, pagesID, info := alloc(), alloc(), alloc()
+
+	fontIDs := map[string]int{}
+	for _, fc := range []*face{d.regular, d.bold} {
+		id, err := d.writeFont(fc, alloc, set)
+		if err != nil {
+			return nil, err
+		}
+		fontIDs[fc.res] = id
+	}
+	imageIDs := map[string]int{}
+	for _, im := range d.images {
+		id := alloc()
+		cs := "/DeviceRGB"
+		if im.gray {
+			cs = "/DeviceGray"
+		}
+		set(id, stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+			im.w, im.h, cs), im.data))
+		imageIDs[im.res] = id
+	}
+
+	kids := make([]string, 0, len(d.pages))
+	for _, p := range d.pages {
+		contentID, pageID := alloc(), alloc()
+		set(contentID, stream("/Filter /FlateDecode", deflate(p.content.Bytes())))
+		var res strings.Builder
+		fmt.Fprintf(&res, "/Font << /F1 %d 0 R /F2 %d 0 R >>", fontIDs["F1"], fontIDs["F2"])
+		if len(p.images) > 0 {
+			names := make([]string, 0, len(p.images))
+			for n := range p.images {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			res.WriteString(" /XObject <<")
+			for _, n := range names {
+				fmt.Fprintf(&res, " /%s %d 0 R", n, imageIDs[n])
+			}
+			res.WriteString(" >>")
+		}
+		set(pageID, []byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+			pagesID, num(pageW), num(pageH), res.String(), contentID)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+	}
+	set(catalog, []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID)))
+	set(pagesID, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))))
+	set(info, []byte(fmt.Sprintf("<< /Title %s /Producer (grbpwr-manager) >>", utf16Text(title))))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, body := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objs)+1, catalog, info, xref)
+	return out.Bytes(), nil
+}
+
+// writeFont пишет составной шрифт: Type0 → CIDFontType2 → дескриптор → сам TTF, плюс ToUnicode.
+// Шрифт вшивается целиком, без сабсеттинга: ~130 КБ на начертание — цена, которую файл
+// спецификации платит без заметной разницы, а сабсеттер TrueType — это код, который надо держать.
+func (d *doc) writeFont(fc *face, alloc func() int, set func(int, []byte)) (int, error) {
+	type0, cid, desc, file, cmap := alloc(), alloc(), alloc(), alloc(), alloc()
+
+	var buf sfnt.Buffer
+	b, err := fc.f.Bounds(&buf, fc.ppem(), font.HintingNone)
+	if err != nil {
+		return 0, fmt.Errorf("font %s bounds: %w", fc.name, err)
+	}
+	m, err := fc.f.Metrics(&buf, fc.ppem(), font.HintingNone)
+	if err != nil {
+		return 0, fmt.Errorf("font %s metrics: %w", fc.name, err)
+	}
+	// В PDF глифовое пространство — 1/1000 em; у sfnt y растёт вниз, отсюда смена знаков.
+	em := func(v fixed.Int26_6) int { return int(float64(v) / 64 / fc.upm * 1000) }
+
+	gids := make([]int, 0, len(fc.used))
+	for g := range fc.used {
+		gids = append(gids, int(g))
+	}
+	sort.Ints(gids)
+	var w strings.Builder
+	for _, g := range gids {
+		a, err := fc.f.GlyphAdvance(&buf, sfnt.GlyphIndex(g), fc.ppem(), font.HintingNone)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&w, "%d [%d] ", g, em(a))
+	}
+
+	set(type0, []byte(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		fc.name, cid, cmap)))
+	set(cid, []byte(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW 500 /W [%s] >>",
+		fc.name, desc, strings.TrimSpace(w.String()))))
+	set(desc, []byte(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		fc.name, em(b.Min.X), -em(b.Max.Y), em(b.Max.X), -em(b.Min.Y), em(m.Ascent), -em(m.Descent), em(m.CapHeight), file)))
+	set(file, stream(fmt.Sprintf("/Filter /FlateDecode /Length1 %d", len(fc.raw)), deflate(fc.raw)))
+	set(cmap, stream("/Filter /FlateDecode", deflate(toUnicode(fc.used, gids))))
+	return type0, nil
+}
+
+// toUnicode — CMap «глиф → символ», без которого из PDF с Identity-H нельзя ни скопировать, ни найти
+// текст.
+func toUnicode(used map[sfnt.GlyphIndex]rune, gids []int) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := min(start+100, len(gids))
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, g := range gids[start:end] {
+			fmt.Fprintf(&b, "<%04X> <%s>\n", g, utf16Hex(used[sfnt.GlyphIndex(g)]))
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+func utf16Hex(r rune) string {
+	if r > 0xFFFF {
+		r -= 0x10000
+		return fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+	}
+	return fmt.Sprintf("%04X", r)
+}
+
+// utf16Text — строка метаданных (Info) в UTF-16BE с BOM: заголовок файла тоже бывает по-русски.
+func utf16Text(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, r := range s {
+		b.WriteString(utf16Hex(r))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func stream(dict string, data []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return b.Bytes()
+}
@@ -0,0 +1,653 @@
+// Package specpdf — PDF-СПЕЦИФИКАЦИЯ ДЛЯ ФАБРИКИ: многостраничный пакет по снапшоту релиза тех-карты
+// (обложка, технические эскизы с выносками, табель мер по размерам, BOM, операции, этикетки и
+// упаковка).
+//
+// Пакет ЧИСТЫЙ: он не читает базу, бакет и прото, а рисует то, что ему дали в Sheet. Собирают Sheet
+// cutspec.ReleaseSheet (всё, что из снапшота) и internal/specsheet (табель мер, картинки, имена).
+//
+// ДЕНЕГ В Sheet НЕТ ПО ПОСТРОЕНИЮ — ни цены строки BOM, ни валюты, ни костинга, ни себестоимости
+// релиза. Это то же правило, что у публичного наряда (internal/runpackaccess): файл уезжает на
+// фабрику и по ссылке без аккаунта, срезать костинг «под роль» там не у кого, поэтому безопасная
+// форма — поле, которого нет, а не поле, которое кто-то не забыл обнулить.
+package specpdf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sheet — всё, что печатается в спецификации.
+type Sheet struct {
+	StyleNumber string
+	Name        string
+	Brand       string
+	Collection  string
+	// ReleaseNumber — «Rev.N», по которому собран файл; ReleasedAt — дата релиза.
+	ReleaseNumber int
+	ReleasedAt    time.Time
+	GeneratedAt   time.Time
+
+	Fit         string
+	Composition string
+	Care        string
+	Concept     string
+	Notes       string
+	// Sizes — размерный ряд карточки, по порядку.
+	Sizes []string
+	// Caveats — оговорки о том, из чего собран файл; печатаются на обложке рамкой.
+	Caveats []string
+
+	Sketches   []Sketch
+	Callouts   []Callout
+	Chart      Chart
+	Bom        []BomLine
+	Operations []Operation
+	Labels     []Label
+	Packaging  []Field
+}
+
+// Sketch — один технический эскиз. Image — байты картинки (JPEG, PNG или WebP); пусто или нечитаемо —
+// на месте эскиза рисуется рамка с подписью, а не пропадает страница.
+type Sketch struct {
+	MediaId int
+	Caption string
+	Image   []byte
+}
+
+// Callout — нумерованная выноска. HasPos — у выноски есть точка на эскизе MediaId (X, Y — доли 0..1
+// ширины и высоты картинки).
+type Callout struct {
+	Number      int
+	MediaId     int
+	HasPos      bool
+	X, Y        float64
+	Part        string
+	Description string
+	Dimensions  string
+}
+
+// Chart — табель мер: строка на мерку, колонка на размер. Values параллельны Sizes, пустая клетка —
+// мерка в этом размере не задана.
+type Chart struct {
+	Unit     string
+	Sizes    []string
+	BaseSize string
+	Rows     []ChartRow
+	Note     string
+}
+
+// ChartRow — одна мерка табеля.
+type ChartRow struct {
+	Measurement string
+	Values      []string
+}
+
+// BomLine — строка спецификации материалов БЕЗ цены.
+type BomLine struct {
+	Section     string
+	Name        string
+	Supplier    string
+	SupplierRef string
+	Color       string
+	Composition string
+	Spec        string
+	Unit        string
+	Comment     string
+}
+
+// Operation — один шаг технологической последовательности.
+type Operation struct {
+	Number int
+	Type   string
+	Zone   string
+	Pieces string
+	Smv    string
+	Detail string
+	Note   string
+}
+
+// Label — одна этикетка.
+type Label struct {
+	Type       string
+	Content    string
+	Placement  string
+	Attachment string
+	Size       string
+	Note       string
+}
+
+// Field — пара «подпись — значение» (упаковка).
+type Field struct {
+	Label string
+	Value string
+}
+
+// Геометрия страницы, пт.
+const (
+	marginX    = 36.0
+	marginTop  = 64.0
+	marginBot  = 48.0
+	contentW   = pageW - 2*marginX
+	bodySize   = 8.5
+	cellPadX   = 3.0
+	cellPadY   = 2.5
+	lineFactor = 1.25
+	// chartSizesPerTable — колонок размеров в одной таблице табеля мер. Ряд длиннее режется на
+	// несколько таблиц, а не ужимается: цифры кеглем 5 на цеховом принтере не читаются.
+	chartSizesPerTable = 10
+)
+
+// Render рисует спецификацию.
+func Render(sh Sheet) ([]byte, error) {
+	d, err := newDoc()
+	if err != nil {
+		return nil, err
+	}
+	l := &layout{d: d, sh: &sh}
+	l.cover()
+	l.sketches()
+	l.chart()
+	l.bom()
+	l.operations()
+	l.labels()
+	l.footers()
+	return d.bytes(l.title())
+}
+
+// layout — курсор по страницам.
+type layout struct {
+	d       *doc
+	sh      *Sheet
+	p       *page
+	y       float64
+	section string
+}
+
+func (l *layout) title() string {
+	t := strings.TrimSpace(l.sh.StyleNumber + " " + l.sh.Name)
+	if l.sh.ReleaseNumber > 0 {
+		t += fmt.Sprintf(" · Rev.%d", l.sh.ReleaseNumber)
+	}
+	return t
+}
+
+// newPage открывает страницу раздела с шапкой: стиль, ревизия и название раздела на каждой
+// странице — листы спецификации в цеху расходятся по столам поодиночке.
+func (l *layout) newPage(section string) {
+	l.section = section
+	l.p = l.d.addPage()
+	l.p.text(l.d.bold, 9, marginX, 30, l.title(), 0)
+	l.p.text(l.d.regular, 9, pageW-marginX-l.d.regular.width(section, 9), 30, section, 0.3)
+	l.p.line(marginX, 38, pageW-marginX, 38, 0.6, 0)
+	l.y = marginTop
+}
+
+// need переносит курсор на новую страницу того же раздела, если h не помещается.
+func (l *layout) need(h float64) bool {
+	if l.y+h <= pageH-marginBot {
+		return false
+	}
+	l.newPage(l.section)
+	return true
+}
+
+func (l *layout) heading(s string) {
+	l.need(30)
+	l.p.text(l.d.bold, 12, marginX, l.y+12, s, 0)
+	l.y += 20
+}
+
+// paragraph печатает текст с переносом по ширине.
+func (l *layout) paragraph(s string, size float64, bold bool) {
+	fc := l.d.face(bold)
+	for _, ln := range wrap(fc, s, size, contentW) {
+		l.need(size * lineFactor)
+		l.p.text(fc, size, marginX, l.y+size, ln, 0)
+		l.y += size * lineFactor
+	}
+}
+
+func (l *layout) cover() {
+	sh := l.sh
+	l.newPage("Cover")
+	l.p.text(l.d.bold, 26, marginX, l.y+26, sh.StyleNumber, 0)
+	l.y += 36
+	l.paragraph(sh.Name, 16, true)
+	l.y += 6
+	if sub := joinNonEmpty(" · ", sh.Brand, sh.Collection); sub != "" {
+		l.paragraph(sub, 11, false)
+	}
+	l.y += 10
+
+	rel := "—"
+	if sh.ReleaseNumber > 0 {
+		rel = fmt.Sprintf("Rev.%d", sh.ReleaseNumber)
+		if !sh.ReleasedAt.IsZero() {
+			rel += " · " + sh.ReleasedAt.UTC().Format("2006-01-02")
+		}
+	}
+	rows := [][]string{
+		{"Release", rel},
+		{"Sizes", strings.Join(sh.Sizes, ", ")},
+		{"Fit", sh.Fit},
+		{"Composition", sh.Composition},
+		{"Care", sh.Care},
+		{"Generated", sh.GeneratedAt.UTC().Format("2006-01-02 15:04 UTC")},
+	}
+	kept := rows[:0]
+	for _, r := range rows {
+		if strings.TrimSpace(r[1]) != "" {
+			kept = append(kept, r)
+		}
+	}
+	l.table([]column{{"", 0.22}, {"", 0.78}}, kept, false)
+
+	if len(sh.Caveats) > 0 {
+		l.y += 12
+		l.boxed("Read before use", sh.Caveats)
+	}
+	if strings.TrimSpace(sh.Concept) != "" {
+		l.y += 12
+		l.heading("Concept")
+		l.paragraph(sh.Concept, bodySize+1, false)
+	}
+	if strings.TrimSpace(sh.Notes) != "" {
+		l.y += 12
+		l.heading("Notes")
+		l.paragraph(sh.Notes, bodySize+1, false)
+	}
+}
+
+// boxed — рамка с оговорками: на обложке её нельзя не заметить.
+func (l *layout) boxed(title string, lines []string) {
+	fc := l.d.regular
+	var wrapped []string
+	for _, s := range lines {
+		for i, ln := range wrap(fc, s, bodySize+0.5, contentW-24) {
+			if i == 0 {
+				ln = "• " + ln
+			} else {
+				ln = "  " + ln
+			}
+			wrapped = append(wrapped, ln)
+		}
+	}
+	lead := (bodySize + 0.5) * lineFactor
+	h := 24 + float64(len(wrapped))*lead
+	l.need(h)
+	l.p.fillRect(marginX, l.y, contentW, h, 0.94)
+	l.p.strokeRect(marginX, l.y, contentW, h, 1, 0)
+	l.p.text(l.d.bold, 10, marginX+8, l.y+15, title, 0)
+	y := l.y + 20
+	for _, ln := range wrapped {
+		l.p.text(fc, bodySize+0.5, marginX+8, y+bodySize, ln, 0)
+		y += lead
+	}
+	l.y += h
+}
+
+// sketches — по эскизу на страницу, с маркерами выносок поверх картинки и таблицей выносок под ней.
+// Выноски без эскиза (или с эскизом, которого нет среди технических) печатаются отдельной таблицей в
+// конце раздела: потерять указание конструктора только потому, что его не к чему приколоть, нельзя.
+func (l *layout) sketches() {
+	sh := l.sh
+	pinned := map[int]bool{}
+	for _, s := range sh.Sketches {
+		l.newPage("Flat sketches")
+		if s.Caption != "" {
+			l.paragraph(s.Caption, 11, true)
+			l.y += 4
+		}
+		var own []Callout
+		for _, c := range sh.Callouts {
+			if c.MediaId != 0 && c.MediaId == s.MediaId {
+				own = append(own, c)
+			}
+		}
+		maxH := (pageH - marginBot - l.y) * 0.72
+		if len(own) == 0 {
+			maxH = pageH - marginBot - l.y
+		}
+		l.sketch(s, own, maxH)
+		if len(own) > 0 {
+			pinned[s.MediaId] = true
+			l.y += 10
+			l.table(calloutColumns, calloutRows(own), true)
+		}
+	}
+	var loose []Callout
+	for _, c := range sh.Callouts {
+		if !pinned[c.MediaId] {
+			loose = append(loose, c)
+		}
+	}
+	if len(loose) > 0 {
+		if len(sh.Sketches) == 0 {
+			l.newPage("Flat sketches")
+		} else {
+			l.y += 14
+		}
+		l.heading("Callouts")
+		l.table(calloutColumns, calloutRows(loose), true)
+	}
+}
+
+var calloutColumns = []column{{"#", 0.06}, {"Part", 0.22}, {"Description", 0.5}, {"Dimensions", 0.22}}
+
+func calloutRows(cs []Callout) [][]string {
+	out := make([][]string, 0, len(cs))
+	for _, c := range cs {
+		out = append(out, []string{fmt.Sprint(c.Number), c.Part, c.Description, c.Dimensions})
+	}
+	return out
+}
+
+func (l *layout) sketch(s Sketch, callouts []Callout, maxH float64) {
+	var pic *picture
+	if len(s.Image) > 0 {
+		pic, _ = pictureOf(s.Image)
+	}
+	if pic == nil {
+		h := min(maxH, 200)
+		l.p.strokeRect(marginX, l.y, contentW, h, 0.8, 0.5)
+		msg := "sketch image unavailable"
+		l.p.text(l.d.regular, 10, marginX+(contentW-l.d.regular.width(msg, 10))/2, l.y+h/2, msg, 0.4)
+		l.y += h
+		return
+	}
+	l.d.addImage(pic)
+	w, h := contentW, contentW*float64(pic.h)/float64(pic.w)
+	if h > maxH {
+		w, h = maxH*float64(pic.w)/float64(pic.h), maxH
+	}
+	x := marginX + (contentW-w)/2
+	l.p.drawImage(pic, x, l.y, w, h)
+	l.p.strokeRect(x, l.y, w, h, 0.4, 0.7)
+	for _, c := range callouts {
+		if !c.HasPos {
+			continue
+		}
+		cx, cy := x+clamp01(c.X)*w, l.y+clamp01(c.Y)*h
+		l.p.circle(cx, cy, 7, 0.8, true)
+		label := fmt.Sprint(c.Number)
+		l.p.text(l.d.bold, 7.5, cx-l.d.bold.width(label, 7.5)/2, cy+2.7, label, 0)
+	}
+	l.y += h
+}
+
+func (l *layout) chart() {
+	c := l.sh.Chart
+	if len(c.Rows) == 0 {
+		return
+	}
+	l.newPage("Measurements")
+	title := "Measurement chart"
+	if c.Unit != "" {
+		title += ", " + c.Unit
+	}
+	l.heading(title)
+	if c.Note != "" {
+		l.paragraph(c.Note, bodySize, false)
+		l.y += 6
+	}
+	for start := 0; start < len(c.Sizes); start += chartSizesPerTable {
+		end := min(start+chartSizesPerTable, len(c.Sizes))
+		n := end - start
+		nameW := 0.3
+		cols := []column{{"Measurement", nameW}}
+		for _, sz := range c.Sizes[start:end] {
+			if sz == c.BaseSize && sz != "" {
+				sz += " *"
+			}
+			cols = append(cols, column{sz, (1 - nameW) / float64(n)})
+		}
+		rows := make([][]string, 0, len(c.Rows))
+		for _, r := range c.Rows {
+			row := []string{r.Measurement}
+			for i := start; i < end; i++ {
+				v := ""
+				if i < len(r.Values) {
+					v = r.Values[i]
+				}
+				row = append(row, v)
+			}
+			rows = append(rows, row)
+		}
+		if start > 0 {
+			l.y += 12
+		}
+		l.table(cols, rows, true)
+	}
+	if c.BaseSize != "" {
+		l.y += 6
+		l.paragraph("* base (sample) size", bodySize-0.5, false)
+	}
+}
+
+func (l *layout) bom() {
+	if len(l.sh.Bom) == 0 {
+		return
+	}
+	l.newPage("Bill of materials")
+	l.heading("Bill of materials")
+	rows := make([][]string, 0, len(l.sh.Bom))
+	for _, b := range l.sh.Bom {
+		rows = append(rows, []string{b.Section, b.Name, joinNonEmpty(" / ", b.Supplier, b.SupplierRef),
+			b.Color, b.Composition, b.Spec, b.Unit, b.Comment})
+	}
+	l.table([]column{
+		{"Section", 0.1}, {"Material", 0.17}, {"Supplier / ref", 0.14}, {"Colour", 0.1},
+		{"Composition", 0.14}, {"Spec", 0.12}, {"Unit", 0.06}, {"Comment", 0.17},
+	}, rows, true)
+}
+
+func (l *layout) operations() {
+	if len(l.sh.Operations) == 0 {
+		return
+	}
+	l.newPage("Operations")
+	l.heading("Operation sequence")
+	rows := make([][]string, 0, len(l.sh.Operations))
+	for _, o := range l.sh.Operations {
+		no := ""
+		if o.Number > 0 {
+			no = fmt.Sprint(o.Number)
+		}
+		rows = append(rows, []string{no, o.Type, o.Zone, o.Pieces, o.Detail, o.Smv, o.Note})
+	}
+	l.table([]column{
+		{"#", 0.06}, {"Operation", 0.14}, {"Zone", 0.1}, {"Pieces", 0.16},
+		{"Settings", 0.22}, {"SMV", 0.07}, {"Note", 0.25},
+	}, rows, true)
+}
+
+func (l *layout) labels() {
+	if len(l.sh.Labels) == 0 && len(l.sh.Packaging) == 0 {
+		return
+	}
+	l.newPage("Labels & packaging")
+	if len(l.sh.Labels) > 0 {
+		l.heading("Labels")
+		rows := make([][]string, 0, len(l.sh.Labels))
+		for _, lb := range l.sh.Labels {
+			rows = append(rows, []string{lb.Type, lb.Content, lb.Placement, lb.Attachment, lb.Size, lb.Note})
+		}
+		l.table([]column{
+			{"Type", 0.12}, {"Content", 0.24}, {"Placement", 0.18}, {"Attachment", 0.16}, {"Size", 0.1}, {"Note", 0.2},
+		}, rows, true)
+	}
+	if len(l.sh.Packaging) > 0 {
+		if len(l.sh.Labels) > 0 {
+			l.y += 14
+		}
+		l.heading("Packaging")
+		rows := make([][]string, 0, len(l.sh.Packaging))
+		for _, f := range l.sh.Packaging {
+			rows = append(rows, []string{f.Label, f.Value})
+		}
+		l.table([]column{{"", 0.3}, {"", 0.7}}, rows, false)
+	}
+}
+
+// footers дописывает «стр. i / n» — число страниц известно только в конце.
+func (l *layout) footers() {
+	n := len(l.d.pages)
+	for i, p := range l.d.pages {
+		s := fmt.Sprintf("%d / %d", i+1, n)
+		p.line(marginX, pageH-34, pageW-marginX, pageH-34, 0.4, 0.6)
+		p.text(l.d.regular, 7.5, marginX, pageH-22, "Confidential — for production use only", 0.4)
+		p.text(l.d.regular, 7.5, pageW-marginX-l.d.regular.width(s, 7.5), pageH-22, s, 0.4)
+	}
+}
+
+// column — колонка таблицы; w — доля ширины полосы.
+type column struct {
+	title string
+	w     float64
+}
+
+// table печатает таблицу с переносом текста в клетках. Строка, не влезающая в остаток страницы,
+// уходит на следующую целиком, и шапка повторяется — таблица BOM на три страницы без шапки на второй
+// и третьей читается как набор случайных слов.
+func (l *layout) table(cols []column, rows [][]string, header bool) {
+	lead := bodySize * lineFactor
+	widths := make([]float64, len(cols))
+	for i, c := range cols {
+		widths[i] = c.w * contentW
+	}
+	drawRow := func(cells []string, bold bool, shade float64) {
+		fc := l.d.face(bold)
+		lines := make([][]string, len(cols))
+		n := 1
+		for i := range cols {
+			s := ""
+			if i < len(cells) {
+				s = cells[i]
+			}
+			lines[i] = wrap(fc, s, bodySize, widths[i]-2*cellPadX)
+			n = max(n, len(lines[i]))
+		}
+		h := float64(n)*lead + 2*cellPadY
+		if l.need(h) && header && !bold {
+			l.headerRow(cols, widths, lead)
+		}
+		if shade < 1 {
+			l.p.fillRect(marginX, l.y, contentW, h, shade)
+		}
+		x := marginX
+		for i := range cols {
+			for j, ln := range lines[i] {
+				l.p.text(fc, bodySize, x+cellPadX, l.y+cellPadY+float64(j)*lead+bodySize, ln, 0)
+			}
+			x += widths[i]
+		}
+		l.p.line(marginX, l.y+h, marginX+contentW, l.y+h, 0.3, 0.6)
+		l.y += h
+	}
+	if header {
+		titles := make([]string, len(cols))
+		for i, c := range cols {
+			titles[i] = c.title
+		}
+		drawRow(titles, true, 0.9)
+	}
+	for _, r := range rows {
+		drawRow(r, false, 1)
+	}
+}
+
+func (l *layout) headerRow(cols []column, widths []float64, lead float64) {
+	h := lead + 2*cellPadY
+	l.p.fillRect(marginX, l.y, contentW, h, 0.9)
+	x := marginX
+	for i, c := range cols {
+		l.p.text(l.d.bold, bodySize, x+cellPadX, l.y+cellPadY+bodySize, clip(l.d.bold, c.title, bodySize, widths[i]-2*cellPadX), 0)
+		x += widths[i]
+	}
+	l.p.line(marginX, l.y+h, marginX+contentW, l.y+h, 0.3, 0.6)
+	l.y += h
+}
+
+// wrap режет текст на строки не шире width: по словам, а слово длиннее строки — по символам.
+// Переводы строк автора сохраняются.
+func wrap(fc *face, s string, size, width float64) []string {
+	var out []string
+	for _, para := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			if len(out) > 0 {
+				out = append(out, "")
+			}
+			continue
+		}
+		cur := ""
+		for _, w := range words {
+			for fc.width(w, size) > width {
+				if cur != "" {
+					out = append(out, cur)
+					cur = ""
+				}
+				head, tail := splitAt(fc, w, size, width)
+				out = append(out, head)
+				w = tail
+			}
+			switch {
+			case cur == "":
+				cur = w
+			case fc.width(cur+" "+w, size) <= width:
+				cur += " " + w
+			default:
+				out = append(out, cur)
+				cur = w
+			}
+		}
+		if cur != "" {
+			out = append(out, cur)
+		}
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// splitAt отрезает от слова самый длинный префикс не шире width (минимум одну руну).
+func splitAt(fc *face, w string, size, width float64) (string, string) {
+	rs := []rune(w)
+	acc := 0.0
+	for i, r := range rs {
+		acc += fc.advance(r) * size
+		if acc > width {
+			i = max(i, 1)
+			return string(rs[:i]), string(rs[i:])
+		}
+	}
+	return w, ""
+}
+
+// clip обрезает строку многоточием под ширину (шапка таблицы переносов не получает).
+func clip(fc *face, s string, size, width float64) string {
+	if fc.width(s, size) <= width {
+		return s
+	}
+	rs := []rune(s)
+	for len(rs) > 0 && fc.width(string(rs)+"…", size) > width {
+		rs = rs[:len(rs)-1]
+	}
+	return string(rs) + "…"
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	kept := parts[:0:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+func clamp01(v float64) float64 {
+	return max(0, min(1, v))
+}
@@ -0,0 +1,190 @@
+package specpdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSheet() Sheet {
+	return Sheet{
+		StyleNumber:   "GR-0142",
+		Name:          "Куртка рабочая",
+		Brand:         "grbpwr",
+		ReleaseNumber: 3,
+		ReleasedAt:    time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+		GeneratedAt:   time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+		Composition:   "100% cotton",
+		Sizes:         []string{"S", "M", "L"},
+		Caveats:       []string{"measurement chart is the style's current chart"},
+		Chart: Chart{
+			Unit: "mm", Sizes: []string{"S", "M", "L"}, BaseSize: "M",
+			Rows: []ChartRow{{Measurement: "Длина спинки", Values: []string{"700", "720", ""}}},
+		},
+		Bom:        []BomLine{{Section: "fabric", Name: "Canvas 12oz", Unit: "m"}},
+		Operations: []Operation{{Number: 10, Type: "machine", Zone: "outer", Pieces: "Спинка, Полочка", Smv: "1.2"}},
+		Labels:     []Label{{Type: "main", Content: "logo"}},
+		Packaging:  []Field{{Label: "Folding", Value: "в 3 сложения"}},
+	}
+}
+
+// checkXref сверяет, что каждая запись xref указывает ровно на начало своего объекта — иначе
+// просмотрщики «чинят» файл или не открывают его вовсе.
+func checkXref(t *testing.T, pdf []byte) int {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("no startxref trailer")
+	}
+	at, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[at:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d does not point at the xref table", at)
+	}
+	lines := strings.Split(string(pdf[at:]), "\n")
+	var n int
+	fmt.Sscanf(lines[1], "0 %d", &n)
+	for i := 1; i < n; i++ {
+		off, _ := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		want := fmt.Sprintf("%d 0 obj\n", i)
+		if !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i, pdf[off:min(off+12, len(pdf))])
+		}
+	}
+	return n - 1
+}
+
+// streams распаковывает все FlateDecode-потоки файла.
+func streams(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	var out []string
+	re := regexp.MustCompile(`/FlateDecode[^>]*/Length (\d+) >>\nstream\n`)
+	for _, loc := range re.FindAllSubmatchIndex(pdf, -1) {
+		n, _ := strconv.Atoi(string(pdf[loc[2]:loc[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[loc[1] : loc[1]+n]))
+		if err != nil {
+			t.Fatalf("inflate: %v", err)
+		}
+		b, _ := io.ReadAll(zr)
+		out = append(out, string(b))
+	}
+	return out
+}
+
+func TestRenderIsWellFormed(t *testing.T) {
+	pdf, err := Render(testSheet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) {
+		t.Fatal("no PDF header")
+	}
+	checkXref(t, pdf)
+	// Обложка, табель, BOM, операции, этикетки — эскизов и выносок нет, их страниц нет тоже.
+	if got := bytes.Count(pdf, []byte("/Type /Page /Parent")); got != 5 {
+		t.Fatalf("pages = %d, want 5", got)
+	}
+	if !bytes.Contains(pdf, []byte("/Count 5")) {
+		t.Fatal("page tree count is not 5")
+	}
+}
+
+func TestRenderCyrillicIsSearchable(t *testing.T) {
+	pdf, err := Render(testSheet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(streams(t, pdf), "\n")
+	// ToUnicode обязан знать «К» (U+041A) и «Ж» не печатался — его там быть не должно: CMap
+	// пишется по напечатанным глифам, а не по шрифту.
+	if !strings.Contains(all, "<041A>") {
+		t.Fatal("ToUnicode has no mapping for Cyrillic К")
+	}
+	if strings.Contains(all, "<0416>") {
+		t.Fatal("ToUnicode maps a glyph the document never printed")
+	}
+}
+
+func TestRenderRepeatsTableHeaderAcrossPages(t *testing.T) {
+	sh := Sheet{StyleNumber: "GR-1", GeneratedAt: time.Now()}
+	for i := 0; i < 120; i++ {
+		sh.Bom = append(sh.Bom, BomLine{Section: "trim", Name: fmt.Sprintf("Button %d", i), Unit: "pcs"})
+	}
+	pdf, err := Render(sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkXref(t, pdf)
+	pages := bytes.Count(pdf, []byte("/Type /Page /Parent"))
+	if pages < 3 {
+		t.Fatalf("120 BOM lines fit on %d pages", pages)
+	}
+	// «Supplier / ref» шапки таблицы — по разу на каждой странице BOM (все страницы, кроме обложки).
+	d, _ := newDoc()
+	hdr := d.bold.encode("Supplier / ref")
+	n := 0
+	for _, s := range streams(t, pdf) {
+		if strings.Contains(s, hdr) {
+			n++
+		}
+	}
+	if n != pages-1 {
+		t.Fatalf("header printed on %d pages, want %d", n, pages-1)
+	}
+}
+
+func TestRenderEmbedsSketchAsJPEG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		img.Set(x, 10, color.Black)
+	}
+	var raw bytes.Buffer
+	if err := png.Encode(&raw, img); err != nil {
+		t.Fatal(err)
+	}
+	sh := testSheet()
+	sh.Sketches = []Sketch{{MediaId: 7, Caption: "Front", Image: raw.Bytes()}, {MediaId: 8, Caption: "Back"}}
+	sh.Callouts = []Callout{
+		{Number: 1, MediaId: 7, HasPos: true, X: 0.5, Y: 0.5, Part: "pocket"},
+		{Number: 2, Part: "unanchored"},
+	}
+	pdf, err := Render(sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkXref(t, pdf)
+	if !bytes.Contains(pdf, []byte("/Width 40 /Height 20 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode")) {
+		t.Fatal("PNG sketch was not embedded as a JPEG image")
+	}
+	// Второй эскиз без картинки — рамка-заглушка, а не пропавшая страница; выноска без эскиза — в
+	// отдельной таблице.
+	if got := bytes.Count(pdf, []byte("/Type /Page /Parent")); got != 7 {
+		t.Fatalf("pages = %d, want 7", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	d, _ := newDoc()
+	fc := d.regular
+	lines := wrap(fc, "one two three four five six seven", 10, fc.width("one two three", 10)+0.1)
+	if len(lines) != 3 || lines[0] != "one two three" {
+		t.Fatalf("wrap = %q", lines)
+	}
+	long := strings.Repeat("Ш", 50)
+	for _, ln := range wrap(fc, long, 10, 60) {
+		if fc.width(ln, 10) > 60 {
+			t.Fatalf("line %q is wider than the column", ln)
+		}
+	}
+	if got := wrap(fc, "a\n\nb", 10, 100); len(got) != 3 || got[1] != "" {
+		t.Fatalf("author's blank line lost: %q", got)
+	}
+}
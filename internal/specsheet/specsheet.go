@@ -0,0 +1,319 @@
+// Package specsheet — PDF-СПЕЦИФИКАЦИЯ РЕЛИЗА ДЛЯ ФАБРИКИ: сборка файла (админский экспорт) и
+// публичная ссылка /api/ts/{token}.
+//
+// Что печатается, решает не этот пакет. Содержимое — ТОЛЬКО снапшот релиза, прочитанный через
+// cutspec.ReleaseSheet (денег в проекции нет по построению), плюс две вещи, которых снапшот не
+// несёт: табель мер стиля и байты технических эскизов. Табель берётся ЖИВОЙ — релиз его не
+// замораживает, и файл говорит об этом оговоркой на обложке, а не молчит.
+//
+// Посадка ссылки — копия наряда на партию (internal/runpackaccess): фабрика не имеет аккаунта, и
+// токен аутентифицирует сам себя. Любой отказ по ТОКЕНУ (битая подпись, чужой скоуп, устаревшая
+// эпоха, отзыв, протухание, лимит, релиза больше нет) — один и тот же голый 404 с причиной только в
+// сэмплированном логе. Эпоха живёт в tech_card_release_spec_access: ротация из админки убивает все
+// выданные ссылки релиза.
+package specsheet
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/cutspec"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/middleware"
+	"github.com/jekabolt/grbpwr-manager/internal/patterntoken"
+	"github.com/jekabolt/grbpwr-manager/internal/ratelimit"
+	"github.com/jekabolt/grbpwr-manager/internal/specpdf"
+)
+
+// Cards — узкий срез dependency.TechCards, который нужен спецификации.
+type Cards interface {
+	cutspec.Cards
+	GetStyleSizeChart(ctx context.Context, styleID int) (entity.StyleSizeChart, error)
+	GetReleaseSpecAccess(ctx context.Context, releaseID int) (*entity.TechCardReleaseSpecAccess, error)
+}
+
+// Media — чтение картинок эскизов (dependency.FileStore.GetMediaObject).
+type Media interface {
+	GetMediaObject(ctx context.Context, mediaURL string) ([]byte, error)
+}
+
+const (
+	// Пер (ip|релиз). Файл собирается на каждый запрос, с эскизами из бакета, — это не манифест в
+	// пару килобайт, поэтому бюджет скромный: открыть, перезагрузить, скачать.
+	perTokenWindow = time.Minute
+	perTokenMax    = 10
+
+	// Свой бюджет на ip: за ним офис одной фабрики.
+	perIPWindow = time.Minute
+	perIPMax    = 60
+
+	// sketchFetchTimeout — на все эскизы одного файла вместе. Эскиз, не успевший приехать,
+	// печатается рамкой-заглушкой: файл без картинки лучше, чем файл, который не открылся.
+	sketchFetchTimeout = 20 * time.Second
+
+	// deniedLogSample — 1 из N отказов пишется на Info, остальные на Debug.
+	deniedLogSample = 10
+)
+
+// Service собирает спецификации и обслуживает /api/ts/{token}.
+type Service struct {
+	cards  Cards
+	media  Media
+	minter *patterntoken.Minter
+	now    func() time.Time
+
+	tokenLimiter *ratelimit.Limiter
+	ipLimiter    *ratelimit.Limiter
+	stopOnce     sync.Once
+
+	deniedSeq atomic.Int64
+}
+
+// New собирает сервис. Пустой pepper — отказ на старте (patterntoken.NewMinter).
+func New(cards Cards, media Media, pepper string) (*Service, error) {
+	minter, err := patterntoken.NewMinter(pepper)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		cards:        cards,
+		media:        media,
+		minter:       minter,
+		now:          time.Now,
+		tokenLimiter: ratelimit.NewLimiter(perTokenWindow, perTokenMax),
+		ipLimiter:    ratelimit.NewLimiter(perIPWindow, perIPMax),
+	}, nil
+}
+
+// Stop останавливает лимитеры (идемпотентно).
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() {
+		s.tokenLimiter.Stop()
+		s.ipLimiter.Stop()
+	})
+}
+
+// MintSpecSheetToken отдаёт токен скоупа 't' для релиза на текущей эпохе его строки доступа.
+// Безопасен на nil-получателе и nil-строке: ответ админки просто приезжает без ссылки.
+func (s *Service) MintSpecSheetToken(row *entity.TechCardReleaseSpecAccess) string {
+	if s == nil || row == nil || row.ReleaseId <= 0 {
+		return ""
+	}
+	return s.minter.Mint(patterntoken.ScopeSpecSheet, int64(row.ReleaseId), row.Epoch)
+}
+
+// Render собирает PDF релиза. caveats — оговорки, напечатанные на обложке (их же показывает
+// админка рядом с кнопкой). Релиза нет — завёрнутый sql.ErrNoRows; снапшот не читается —
+// cutspec.ErrReleaseSheetUnreadable.
+func (s *Service) Render(ctx context.Context, releaseID int) (pdf []byte, filename string, caveats []string, err error) {
+	rs, meta, err := cutspec.ReleaseSheet(ctx, s.cards, releaseID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	sh := rs.Sheet
+
+	chart, err := s.cards.GetStyleSizeChart(ctx, meta.TechCardId)
+	if err != nil {
+		// Без табеля спецификация всё ещё спецификация: страница табеля выйдет пустой, а обложка
+		// скажет почему.
+		slog.Default().WarnContext(ctx, "spec sheet size chart read failed",
+			slog.Int("release_id", releaseID), slog.String("err", err.Error()))
+		sh.Caveats = append(sh.Caveats, "The measurement chart could not be read and is not included.")
+	} else {
+		sh.Chart = chartOf(chart, rs.SizeIds, sh.Sizes, rs.Unit)
+		if len(sh.Chart.Rows) > 0 {
+			sh.Caveats = append(sh.Caveats, fmt.Sprintf(
+				"The measurement chart is the style's chart as of %s, not a part of Rev.%d: releases do not freeze it.",
+				s.now().UTC().Format("2006-01-02"), meta.ReleaseNumber))
+		}
+	}
+
+	fctx, cancel := context.WithTimeout(ctx, sketchFetchTimeout)
+	defer cancel()
+	missing := 0
+	for i := range sh.Sketches {
+		url := rs.SketchURLs[sh.Sketches[i].MediaId]
+		if url == "" {
+			missing++
+			continue
+		}
+		raw, err := s.media.GetMediaObject(fctx, url)
+		if err != nil {
+			slog.Default().WarnContext(ctx, "spec sheet sketch read failed",
+				slog.Int("release_id", releaseID), slog.Int("media_id", sh.Sketches[i].MediaId),
+				slog.String("err", err.Error()))
+			missing++
+			continue
+		}
+		sh.Sketches[i].Image = raw
+	}
+	if missing > 0 {
+		sh.Caveats = append(sh.Caveats, fmt.Sprintf("%d of %d sketches could not be loaded and are printed as empty frames.",
+			missing, len(sh.Sketches)))
+	}
+
+	sh.GeneratedAt = s.now().UTC()
+	pdf, err = specpdf.Render(sh)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("render spec sheet of release %d: %w", releaseID, err)
+	}
+	return pdf, fileName(sh.StyleNumber, meta.ReleaseNumber), sh.Caveats, nil
+}
+
+// chartOf раскладывает живой табель стиля по размерному ряду РЕЛИЗА: колонки — размеры снапшота в
+// его порядке (размер, которого в табеле нет, — пустая ячейка), строки — меры табеля в порядке
+// справочника. Размер табеля вне ряда релиза не печатается: спецификация про этот релиз.
+func chartOf(chart entity.StyleSizeChart, sizeIDs []int, sizeNames []string, unit string) specpdf.Chart {
+	out := specpdf.Chart{Unit: unit, Sizes: sizeNames}
+	col := make(map[int]int, len(sizeIDs))
+	for i, id := range sizeIDs {
+		col[id] = i
+		if id == chart.GradeBaseSizeID && i < len(sizeNames) {
+			out.BaseSize = sizeNames[i]
+		}
+	}
+	values := map[int][]string{}
+	for _, c := range chart.Cells {
+		i, ok := col[c.SizeID]
+		if !ok {
+			continue
+		}
+		row := values[c.MeasurementNameID]
+		if row == nil {
+			row = make([]string, len(sizeIDs))
+			values[c.MeasurementNameID] = row
+		}
+		row[i] = c.Value.String()
+	}
+	for _, m := range cache.GetMeasurements() {
+		if row, ok := values[m.Id]; ok {
+			out.Rows = append(out.Rows, specpdf.ChartRow{Measurement: m.Name, Values: row})
+			delete(values, m.Id)
+		}
+	}
+	// Мера, которой нет в справочнике кэша (добавлена после старта), всё равно печатается — под
+	// своим id, в конце: пропавшая строка табеля хуже некрасивой.
+	for _, c := range chart.Cells {
+		if row, ok := values[c.MeasurementNameID]; ok {
+			out.Rows = append(out.Rows, specpdf.ChartRow{Measurement: "#" + strconv.Itoa(c.MeasurementNameID), Values: row})
+			delete(values, c.MeasurementNameID)
+		}
+	}
+	return out
+}
+
+// fileName — «GR-0142_Rev3_spec.pdf». Артикул чистится до букв, цифр, «-» и «_»: имя едет в
+// Content-Disposition и в файловую систему фабрики.
+func fileName(styleNumber string, releaseNumber int) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(styleNumber))
+	if clean == "" {
+		clean = "tech_card"
+	}
+	return fmt.Sprintf("%s_Rev%d_spec.pdf", clean, releaseNumber)
+}
+
+// Handler обслуживает GET/HEAD /api/ts/{token}.
+func (s *Service) Handler() http.Handler { return http.HandlerFunc(s.ServeSheet) }
+
+// ServeSheet отдаёт PDF релиза по ссылке. Каждый отрицательный исход — один и тот же голый 404.
+func (s *Service) ServeSheet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ip := middleware.ClientIPFromRequest(r)
+
+	if !s.ipLimiter.Allow(ip) {
+		s.notFound(w, r, ip, "ip rate limited")
+		return
+	}
+	scope, id, epoch, err := s.minter.Parse(chi.URLParam(r, "token"))
+	if err != nil {
+		s.notFound(w, r, ip, "bad token")
+		return
+	}
+	// СКОУП-ALLOWLIST: id здесь — номер РЕЛИЗА, и токен любого другого скоупа с тем же числом
+	// назвал бы чужой релиз.
+	if scope != patterntoken.ScopeSpecSheet {
+		s.notFound(w, r, ip, "wrong token scope")
+		return
+	}
+	if !s.tokenLimiter.Allow(ip + "|t|" + strconv.FormatInt(id, 10)) {
+		s.notFound(w, r, ip, "token rate limited")
+		return
+	}
+	releaseID := int(id)
+	row, err := s.cards.GetReleaseSpecAccess(ctx, releaseID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.notFound(w, r, ip, "no access row")
+		} else {
+			slog.Default().ErrorContext(ctx, "spec sheet lookup failed", slog.String("err", err.Error()))
+			s.notFound(w, r, ip, "lookup error")
+		}
+		return
+	}
+	switch {
+	case row.Epoch != epoch:
+		s.notFound(w, r, ip, "stale epoch")
+		return
+	case row.RevokedAt.Valid:
+		s.notFound(w, r, ip, "revoked")
+		return
+	case row.ExpiresAt.Valid && s.now().After(row.ExpiresAt.Time):
+		s.notFound(w, r, ip, "expired")
+		return
+	}
+
+	pdf, name, _, err := s.Render(ctx, releaseID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Строка доступа пережила релиз (гонка каскада FK с чтением) — тот же 404.
+			s.notFound(w, r, ip, "release gone")
+		} else {
+			slog.Default().ErrorContext(ctx, "spec sheet render failed",
+				slog.Int("release_id", releaseID), slog.String("err", err.Error()))
+			s.notFound(w, r, ip, "render error")
+		}
+		return
+	}
+
+	slog.Default().InfoContext(ctx, "spec sheet access",
+		slog.Int("release_id", releaseID), slog.String("ip", ip))
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	// Ссылку можно отозвать — общим кэшам хранить файл нельзя.
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(pdf)
+	}
+}
+
+// notFound — единственный ответ на отказ по токену. Причина только в сэмплированном логе:
+// эндпоинт неаутентифицированный, и строка лога на каждый отбитый запрос — усилитель объёма.
+func (s *Service) notFound(w http.ResponseWriter, r *http.Request, ip, reason string) {
+	level := slog.LevelDebug
+	if s.deniedSeq.Add(1)%deniedLogSample == 0 {
+		level = slog.LevelInfo
+	}
+	slog.Default().Log(r.Context(), level, "spec sheet denied",
+		slog.String("reason", reason), slog.String("ip", ip), slog.String("ua", r.UserAgent()))
+	http.NotFound(w, r)
+}
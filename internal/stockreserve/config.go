@@ -0,0 +1,49 @@
+package stockreserve
+
+import "time"
+
+// Backends selectable via Config.Backend.
+const (
+	// BackendMemory keeps holds in the process (Manager). Holds are lost on restart and are not
+	// shared between instances; fine for a single replica.
+	BackendMemory = "memory"
+	// BackendMySQL keeps holds in the stock_reservation table (MySQLManager), shared by every
+	// instance and surviving restarts.
+	BackendMySQL = "mysql"
+)
+
+// Config holds configuration for the stock reservation manager.
+type Config struct {
+	Backend       string        `mapstructure:"backend"`        // "memory" (default) or "mysql"
+	CartTTL       time.Duration `mapstructure:"cart_ttl"`       // hold lifetime while the item sits in a cart
+	OrderTTL      time.Duration `mapstructure:"order_ttl"`      // hold lifetime once committed to an order (payment window)
+	SweepInterval time.Duration `mapstructure:"sweep_interval"` // mysql backend: how often expired rows are deleted
+}
+
+// DefaultConfig returns default configuration values.
+func DefaultConfig() Config {
+	return Config{
+		Backend:       BackendMemory,
+		CartTTL:       15 * time.Minute,
+		OrderTTL:      30 * time.Minute,
+		SweepInterval: time.Minute,
+	}
+}
+
+// withDefaults fills zero fields from DefaultConfig.
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Backend == "" {
+		c.Backend = d.Backend
+	}
+	if c.CartTTL <= 0 {
+		c.CartTTL = d.CartTTL
+	}
+	if c.OrderTTL <= 0 {
+		c.OrderTTL = d.OrderTTL
+	}
+	if c.SweepInterval <= 0 {
+		c.SweepInterval = d.SweepInterval
+	}
+	return c
+}
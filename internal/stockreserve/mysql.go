@@ -0,0 +1,378 @@
+package stockreserve
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/health"
+	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// sweepTickTimeout bounds the DB work of a single sweep tick.
+	sweepTickTimeout = 30 * time.Second
+	// sweepBatchSize caps the rows one DELETE removes; a tick loops batches until a short one.
+	sweepBatchSize = 500
+	// sweepMaxBatches bounds one tick after a long outage; the rest goes on the next tick.
+	sweepMaxBatches = 20
+)
+
+// MySQLManager is the durable counterpart of Manager: the same reserve/commit/release semantics
+// and abuse limits, but every hold lives in the stock_reservation table, so carts survive a
+// restart and all instances share one view of who holds what.
+//
+// Reserves of one (product, size) are serialized by a row lock on its A-grade product_size row,
+// so the "available = stock − others' holds" read and the upsert that follows it cannot interleave,
+// on this instance or any other. The per-session call rate is throttled in-process (it protects
+// this instance, not the stock) and the global capacity cap is a soft, unlocked count.
+type MySQLManager struct {
+	repo     dependency.Repository
+	cartTTL  time.Duration
+	orderTTL time.Duration
+	sweep    time.Duration
+	limits   Limits
+
+	rateMu       sync.Mutex
+	sessionRates map[string]*sessionRateEntry
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	tracker  health.Tracker
+}
+
+// Name implements health.Reporter.
+func (m *MySQLManager) Name() string { return "stockreserve" }
+
+// LastSuccess implements health.Reporter (zero time until the first clean sweep tick).
+func (m *MySQLManager) LastSuccess() time.Time { return m.tracker.LastSuccess() }
+
+// NewMySQLManager creates a MySQL-backed reservation manager and starts its expiry sweeper.
+func NewMySQLManager(repo dependency.Repository, cartTTL, orderTTL, sweepInterval time.Duration, limits Limits) *MySQLManager {
+	m := &MySQLManager{
+		repo:         repo,
+		cartTTL:      cartTTL,
+		orderTTL:     orderTTL,
+		sweep:        sweepInterval,
+		limits:       limits,
+		sessionRates: make(map[string]*sessionRateEntry),
+		stopCh:       make(chan struct{}),
+	}
+	m.wg.Go(m.sweeper)
+	return m
+}
+
+// New builds the reservation manager selected by c.Backend. repo is only used by the mysql backend.
+func New(c Config, repo dependency.Repository) (dependency.StockReservationManager, error) {
+	c = c.withDefaults()
+	switch c.Backend {
+	case BackendMemory:
+		return NewManager(c.CartTTL, c.OrderTTL, DefaultLimits()), nil
+	case BackendMySQL:
+		if repo == nil {
+			return nil, fmt.Errorf("stock reserve backend %q requires a repository", c.Backend)
+		}
+		return NewMySQLManager(repo, c.CartTTL, c.OrderTTL, c.SweepInterval, DefaultLimits()), nil
+	default:
+		return nil, fmt.Errorf("unknown stock reserve backend %q", c.Backend)
+	}
+}
+
+// Stop shuts down the sweeper and waits for it to exit, so the DB can be closed afterwards.
+func (m *MySQLManager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+	m.wg.Wait()
+}
+
+// ReserveIfAvailable computes availability (totalStock minus the live holds of OTHER sessions)
+// and holds min(qty, available) for sessionID in one transaction under the variant's row lock.
+// As with Manager, an abuse-limit rejection is returned as err alongside a still-valid
+// availability. If the ledger itself cannot be reached the hold is skipped and totalStock is
+// reported with the error: the hold is advisory and the conditional stock decrement at payment
+// stays the real guard, so a DB hiccup must not empty every cart.
+// The per-session rate is counted once per call, before the transaction, so a deadlock retry does
+// not spend the session's budget again.
+func (m *MySQLManager) ReserveIfAvailable(ctx context.Context, totalStock decimal.Decimal, sessionID string, productID, sizeID int, qty decimal.Decimal) (decimal.Decimal, error) {
+	var (
+		available decimal.Decimal
+		limitErr  error
+		rateErr   error
+	)
+	if !m.allowSessionRate(sessionID) {
+		rateErr = fmt.Errorf("too many reservation requests, please slow down")
+	}
+	err := m.repo.Tx(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		// Tx may re-run the callback on a deadlock; start every attempt clean.
+		available, limitErr = decimal.Zero, nil
+		rs := rep.StockReservations()
+
+		if err := rs.LockProductSize(ctx, productID, sizeID); err != nil {
+			return err
+		}
+		reservedOthers, err := rs.GetReservedQuantity(ctx, productID, sizeID, sessionID)
+		if err != nil {
+			return err
+		}
+		available = totalStock.Sub(reservedOthers)
+		if available.LessThanOrEqual(decimal.Zero) {
+			available = decimal.Zero
+			return nil
+		}
+
+		if rateErr != nil {
+			// Nothing is written; availability is still reported.
+			limitErr = rateErr
+			return nil
+		}
+
+		reserveQty := qty
+		if reserveQty.GreaterThan(available) {
+			reserveQty = available
+		}
+		err = m.reserveTx(ctx, rep, sessionID, productID, sizeID, reserveQty)
+		var le *limitError
+		if errors.As(err, &le) {
+			// Nothing was written; commit the (read-only) tx and report the rejection.
+			limitErr = le.err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return decimal.Max(totalStock, decimal.Zero), fmt.Errorf("can't reserve stock: %w", err)
+	}
+	return available, limitErr
+}
+
+// limitError marks an abuse-limit rejection from reserveTx, as opposed to a DB error.
+type limitError struct{ err error }
+
+func (e *limitError) Error() string { return e.err.Error() }
+
+// reserveTx applies the quantity and capacity checks and writes the hold inside the caller's
+// transaction (the session rate is checked by the caller, outside it). A limit rejection is
+// returned as *limitError with nothing written; any other error is a DB error.
+func (m *MySQLManager) reserveTx(ctx context.Context, rep dependency.Repository, sessionID string, productID, sizeID int, qty decimal.Decimal) error {
+	if err := m.limits.checkQuantity(qty); err != nil {
+		return &limitError{err}
+	}
+
+	rs := rep.StockReservations()
+	now := rep.Now().UTC()
+
+	existing, err := rs.GetStockReservation(ctx, productID, sizeID, sessionID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	// An expired row that the sweeper has not removed yet is a fresh reserve, not a refresh.
+	isUpdate := existing != nil && existing.ExpiresAt.After(now)
+
+	if !isUpdate {
+		sessionCount, err := rs.CountSessionReservations(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if sessionCount >= m.limits.MaxItemsPerSession {
+			return &limitError{fmt.Errorf("maximum %d items per cart reached", m.limits.MaxItemsPerSession)}
+		}
+		// Unlocked read on purpose: a locking COUNT over the whole table would serialize every
+		// reserve of every variant. The cap only protects capacity, overshooting it by a few
+		// concurrent inserts is harmless.
+		total, err := m.repo.StockReservations().CountReservations(ctx)
+		if err != nil {
+			return err
+		}
+		if total >= m.limits.MaxTotalReservations {
+			slog.Default().WarnContext(ctx, "global reservation capacity reached",
+				slog.Int("capacity", m.limits.MaxTotalReservations),
+			)
+			return &limitError{fmt.Errorf("service is busy, please try again later")}
+		}
+	}
+
+	hold := &entity.StockReservationInsert{
+		ProductId: productID,
+		SizeId:    sizeID,
+		SessionId: sessionID,
+		Quantity:  qty,
+		ExpiresAt: now.Add(m.cartTTL),
+		CreatedAt: now,
+	}
+	if isUpdate {
+		// TTL refresh protection, same as Manager: keep the original expiry and creation time and
+		// count the refresh; past MaxTTLRefreshes only the quantity changes.
+		hold.OrderUUID = existing.OrderUUID
+		hold.ExpiresAt = existing.ExpiresAt
+		hold.CreatedAt = existing.CreatedAt
+		hold.RefreshCount = existing.RefreshCount
+		if existing.RefreshCount < m.limits.MaxTTLRefreshes {
+			hold.RefreshCount++
+		}
+	}
+	if err := rs.UpsertStockReservation(ctx, hold); err != nil {
+		return err
+	}
+
+	slog.Default().DebugContext(ctx, "stock reserved",
+		slog.String("session_id", sessionID),
+		slog.Int("product_id", productID),
+		slog.Int("size_id", sizeID),
+		slog.String("quantity", qty.String()),
+		slog.Bool("is_update", isUpdate),
+	)
+	return nil
+}
+
+// Commit converts the session's cart holds to order holds (extends TTL to the payment window).
+func (m *MySQLManager) Commit(ctx context.Context, sessionID, orderUUID string) {
+	n, err := m.repo.StockReservations().CommitSessionReservations(ctx, sessionID, orderUUID, m.repo.Now().UTC().Add(m.orderTTL))
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't commit stock reservations",
+			slog.String("session_id", sessionID),
+			slog.String("order_uuid", orderUUID),
+			slog.String("err", err.Error()),
+		)
+		return
+	}
+	slog.Default().DebugContext(ctx, "reservations committed to order",
+		slog.String("order_uuid", orderUUID),
+		slog.String("session_id", sessionID),
+		slog.Int64("count", n),
+	)
+}
+
+// Release frees the holds of an order when it is paid/cancelled.
+func (m *MySQLManager) Release(ctx context.Context, orderUUID string) {
+	n, err := m.repo.StockReservations().DeleteOrderReservations(ctx, orderUUID)
+	if err != nil {
+		slog.Default().ErrorContext(ctx, "can't release stock reservations",
+			slog.String("order_uuid", orderUUID),
+			slog.String("err", err.Error()),
+		)
+		return
+	}
+	slog.Default().DebugContext(ctx, "reservations released",
+		slog.String("order_uuid", orderUUID),
+		slog.Int64("count", n),
+	)
+}
+
+// ReleaseSession frees the cart holds of a session (cart abandoned); order holds stay.
+func (m *MySQLManager) ReleaseSession(ctx context.Context, sessionID string) {
+	if _, err := m.repo.StockReservations().DeleteSessionCartReservations(ctx, sessionID); err != nil {
+		slog.Default().ErrorContext(ctx, "can't release session stock reservations",
+			slog.String("session_id", sessionID),
+			slog.String("err", err.Error()),
+		)
+	}
+	m.rateMu.Lock()
+	delete(m.sessionRates, sessionID)
+	m.rateMu.Unlock()
+}
+
+// GetStats returns current reservation statistics in the same shape as Manager.GetStats.
+func (m *MySQLManager) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	st, err := m.repo.StockReservations().GetStockReservationStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total_reservations": st.Total,
+		"cart_reservations":  st.Cart,
+		"order_reservations": st.Order,
+		"active_sessions":    st.ActiveSessions,
+		"active_orders":      st.ActiveOrders,
+		"capacity_pct":       float64(st.Total) / float64(m.limits.MaxTotalReservations) * 100,
+	}, nil
+}
+
+// sweeper periodically deletes expired holds.
+func (m *MySQLManager) sweeper() {
+	ticker := time.NewTicker(m.sweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			m.runSweepOnce()
+		}
+	}
+}
+
+// runSweepOnce deletes expired holds in bounded batches and prunes stale rate entries. A failed
+// tick is logged and retried on the next one: expired rows are already invisible to readers, so
+// a late sweep costs disk, not correctness.
+func (m *MySQLManager) runSweepOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), sweepTickTimeout)
+	defer cancel()
+	defer saferun.Recover(ctx, "stockreserve-sweep")
+
+	m.pruneSessionRates()
+
+	var swept int64
+	for range sweepMaxBatches {
+		n, err := m.repo.StockReservations().DeleteExpiredStockReservations(ctx, sweepBatchSize)
+		if err != nil {
+			slog.Default().ErrorContext(ctx, "can't sweep expired stock reservations",
+				slog.String("err", err.Error()),
+			)
+			return
+		}
+		swept += n
+		if n < sweepBatchSize {
+			break
+		}
+	}
+
+	if swept > 0 {
+		slog.Default().DebugContext(ctx, "swept expired reservations",
+			slog.Int64("count", swept),
+		)
+	}
+	m.tracker.MarkSuccess()
+}
+
+// allowSessionRate checks if a session is within its reserve call rate limit on this instance.
+func (m *MySQLManager) allowSessionRate(sessionID string) bool {
+	m.rateMu.Lock()
+	defer m.rateMu.Unlock()
+
+	now := time.Now().UTC()
+	entry, exists := m.sessionRates[sessionID]
+	if !exists || now.After(entry.expiresAt) {
+		m.sessionRates[sessionID] = &sessionRateEntry{
+			count:     1,
+			expiresAt: now.Add(time.Minute),
+		}
+		return true
+	}
+	if entry.count >= m.limits.ReserveRatePerSession {
+		return false
+	}
+	entry.count++
+	return true
+}
+
+func (m *MySQLManager) pruneSessionRates() {
+	m.rateMu.Lock()
+	defer m.rateMu.Unlock()
+
+	now := time.Now().UTC()
+	for sid, entry := range m.sessionRates {
+		if now.After(entry.expiresAt) {
+			delete(m.sessionRates, sid)
+		}
+	}
+}
@@ -38,6 +38,18 @@ func DefaultLimits() Limits {
 	}
 }
 
+// checkQuantity rejects a non-positive quantity or one above MaxQtyPerItem. Shared by both
+// backends so a cart sees the same messages whichever one is configured.
+func (l Limits) checkQuantity(qty decimal.Decimal) error {
+	if qty.LessThanOrEqual(decimal.Zero) {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if qty.GreaterThan(l.MaxQtyPerItem) {
+		return fmt.Errorf("quantity %s exceeds maximum %s per item", qty.String(), l.MaxQtyPerItem.String())
+	}
+	return nil
+}
+
 // Reservation represents a temporary stock hold
 type Reservation struct {
 	ProductID    int
@@ -157,12 +169,8 @@ func (rm *Manager) ReserveIfAvailable(ctx context.Context, totalStock decimal.De
 // insert/update. The caller must hold rm.mu (write). Split out so both Reserve
 // and ReserveIfAvailable share identical reservation semantics.
 func (rm *Manager) reserveLocked(ctx context.Context, sessionID string, productID, sizeID int, qty decimal.Decimal) error {
-	if qty.LessThanOrEqual(decimal.Zero) {
-		return fmt.Errorf("quantity must be positive")
-	}
-
-	if qty.GreaterThan(rm.limits.MaxQtyPerItem) {
-		return fmt.Errorf("quantity %s exceeds maximum %s per item", qty.String(), rm.limits.MaxQtyPerItem.String())
+	if err := rm.limits.checkQuantity(qty); err != nil {
+		return err
 	}
 
 	// Rate limit per session
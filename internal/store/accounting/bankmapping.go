@@ -0,0 +1,152 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// Bank CSV column mappings (0338): how to read the CSV export of a bank that has no built-in parser.
+// The mapping is validated by accounting.NewMappedCsvParser before it is saved; this layer only
+// stores it. description_columns holds the header names newline-separated (a header never spans lines).
+
+type bankCsvMappingRow struct {
+	Source             string         `db:"source"`
+	Delimiter          string         `db:"delimiter"`
+	SkipLines          int            `db:"skip_lines"`
+	DateColumn         string         `db:"date_column"`
+	DateLayout         string         `db:"date_layout"`
+	AmountColumn       sql.NullString `db:"amount_column"`
+	DebitColumn        sql.NullString `db:"debit_column"`
+	CreditColumn       sql.NullString `db:"credit_column"`
+	DecimalComma       bool           `db:"decimal_comma"`
+	CurrencyColumn     sql.NullString `db:"currency_column"`
+	Currency           sql.NullString `db:"currency"`
+	DescriptionColumns string         `db:"description_columns"`
+	CounterpartyColumn sql.NullString `db:"counterparty_column"`
+	IdColumn           sql.NullString `db:"id_column"`
+	UpdatedAt          time.Time      `db:"updated_at"`
+}
+
+const bankCsvMappingColumns = `source, delimiter, skip_lines, date_column, date_layout, amount_column,
+	debit_column, credit_column, decimal_comma, currency_column, currency, description_columns,
+	counterparty_column, id_column, updated_at`
+
+func (r bankCsvMappingRow) toEntity() entity.AcctBankCsvMapping {
+	m := entity.AcctBankCsvMapping{
+		Source:             r.Source,
+		Delimiter:          r.Delimiter,
+		SkipLines:          r.SkipLines,
+		DateColumn:         r.DateColumn,
+		DateLayout:         r.DateLayout,
+		AmountColumn:       r.AmountColumn.String,
+		DebitColumn:        r.DebitColumn.String,
+		CreditColumn:       r.CreditColumn.String,
+		DecimalComma:       r.DecimalComma,
+		CurrencyColumn:     r.CurrencyColumn.String,
+		Currency:           r.Currency.String,
+		CounterpartyColumn: r.CounterpartyColumn.String,
+		IdColumn:           r.IdColumn.String,
+		UpdatedAt:          r.UpdatedAt,
+	}
+	for _, c := range strings.Split(r.DescriptionColumns, "\n") {
+		if c != "" {
+			m.DescriptionColumns = append(m.DescriptionColumns, c)
+		}
+	}
+	return m
+}
+
+// ListBankCsvMappings returns the registered CSV mappings, by source.
+func (s *Store) ListBankCsvMappings(ctx context.Context) ([]entity.AcctBankCsvMapping, error) {
+	rows, err := storeutil.QueryListNamed[bankCsvMappingRow](ctx, s.DB,
+		`SELECT `+bankCsvMappingColumns+` FROM acct_bank_csv_mapping ORDER BY source`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("accounting: list bank csv mappings: %w", err)
+	}
+	out := make([]entity.AcctBankCsvMapping, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.toEntity())
+	}
+	return out, nil
+}
+
+// GetBankCsvMapping loads the mapping of a source; a missing one surfaces as wrapped sql.ErrNoRows.
+func (s *Store) GetBankCsvMapping(ctx context.Context, source string) (*entity.AcctBankCsvMapping, error) {
+	r, err := storeutil.QueryNamedOne[bankCsvMappingRow](ctx, s.DB,
+		`SELECT `+bankCsvMappingColumns+` FROM acct_bank_csv_mapping WHERE source = :source`,
+		map[string]any{"source": source})
+	if err != nil {
+		return nil, fmt.Errorf("accounting: get bank csv mapping %q: %w", source, err)
+	}
+	m := r.toEntity()
+	return &m, nil
+}
+
+// SaveBankCsvMapping creates the mapping of m.Source or replaces it.
+func (s *Store) SaveBankCsvMapping(ctx context.Context, m entity.AcctBankCsvMapping) error {
+	delimiter := m.Delimiter
+	if delimiter == "" {
+		delimiter = ","
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		INSERT INTO acct_bank_csv_mapping
+			(source, delimiter, skip_lines, date_column, date_layout, amount_column, debit_column,
+			 credit_column, decimal_comma, currency_column, currency, description_columns,
+			 counterparty_column, id_column)
+		VALUES (:source, :delimiter, :skip_lines, :date_column, :date_layout, :amount_column, :debit_column,
+			:credit_column, :decimal_comma, :currency_column, :currency, :description_columns,
+			:counterparty_column, :id_column)
+		ON DUPLICATE KEY UPDATE
+			delimiter = VALUES(delimiter), skip_lines = VALUES(skip_lines),
+			date_column = VALUES(date_column), date_layout = VALUES(date_layout),
+			amount_column = VALUES(amount_column), debit_column = VALUES(debit_column),
+			credit_column = VALUES(credit_column), decimal_comma = VALUES(decimal_comma),
+			currency_column = VALUES(currency_column), currency = VALUES(currency),
+			description_columns = VALUES(description_columns),
+			counterparty_column = VALUES(counterparty_column), id_column = VALUES(id_column)`,
+		map[string]any{
+			"source":              m.Source,
+			"delimiter":           delimiter,
+			"skip_lines":          m.SkipLines,
+			"date_column":         m.DateColumn,
+			"date_layout":         m.DateLayout,
+			"amount_column":       nullStr(m.AmountColumn),
+			"debit_column":        nullStr(m.DebitColumn),
+			"credit_column":       nullStr(m.CreditColumn),
+			"decimal_comma":       m.DecimalComma,
+			"currency_column":     nullStr(m.CurrencyColumn),
+			"currency":            nullStr(strings.ToUpper(m.Currency)),
+			"description_columns": strings.Join(m.DescriptionColumns, "\n"),
+			"counterparty_column": nullStr(m.CounterpartyColumn),
+			"id_column":           nullStr(m.IdColumn),
+		}); err != nil {
+		return fmt.Errorf("accounting: save bank csv mapping %q: %w", m.Source, err)
+	}
+	return nil
+}
+
+// DeleteBankCsvMapping removes a mapping; a missing source is sql.ErrNoRows. Lines already imported
+// under it keep their source.
+func (s *Store) DeleteBankCsvMapping(ctx context.Context, source string) error {
+	affected, err := storeutil.ExecNamedRows(ctx, s.DB,
+		`DELETE FROM acct_bank_csv_mapping WHERE source = :source`, map[string]any{"source": source})
+	if err != nil {
+		return fmt.Errorf("accounting: delete bank csv mapping %q: %w", source, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("accounting: delete bank csv mapping %q: %w", source, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// nullStr stores an unset optional column as NULL.
+func nullStr(s string) sql.NullString {
+	s = strings.TrimSpace(s)
+	return sql.NullString{String: s, Valid: s != ""}
+}
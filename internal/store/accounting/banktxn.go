@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	acctrules "github.com/jekabolt/grbpwr-manager/internal/accounting"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
 	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
 )
@@ -19,12 +20,16 @@ import (
 
 // bankTxnColumns is the acct_bank_txn read projection.
 const bankTxnColumns = `id, source, external_id, booked_at, amount, currency, fee, description,
-	counterparty, state, matched_entry_id, suggested_account, ignore_reason, created_at`
+	counterparty, state, matched_entry_id, suggested_account, ignore_reason, match_kind, match_ref,
+	supplier_id, created_at`
 
 // ImportBankTxns inserts parsed inbox lines, deduplicating on external_id (a re-imported statement is a
-// no-op for lines already present). Before insert it applies the acct_bank_rule substring suggestions to
-// each still-unmatched line (a rule whose pattern is a case-insensitive substring of the counterparty or
-// description sets suggested_account). Returns how many lines were parsed / newly imported / skipped.
+// no-op for lines already present). Before insert each still-unmatched line is auto-matched against the
+// open receivables, payables and Stripe payouts (accounting.MatchBankTxn, 0338) — a match moves it to
+// state matched with the counter-account suggested — and a line that matches nothing gets the
+// acct_bank_rule substring suggestions (a rule whose pattern is a case-insensitive substring of the
+// counterparty or description sets suggested_account). Returns how many lines were parsed / newly
+// imported / skipped.
 func (s *Store) ImportBankTxns(ctx context.Context, txns []entity.AcctBankTxnInsert) (entity.AcctBankImportResult, error) {
 	res := entity.AcctBankImportResult{Parsed: len(txns)}
 	if len(txns) == 0 {
@@ -35,12 +40,28 @@ func (s *Store) ImportBankTxns(ctx context.Context, txns []entity.AcctBankTxnIns
 	if err != nil {
 		return res, err
 	}
+	candidates, err := s.bankMatchCandidates(ctx)
+	if err != nil {
+		return res, err
+	}
 
 	for i := range txns {
 		t := txns[i]
 		if t.State == "" {
 			t.State = entity.AcctBankTxnUnmatched
 		}
+		if t.Match == nil {
+			t.Match = acctrules.MatchBankTxn(t, candidates)
+		}
+		var matchKind, matchRef sql.NullString
+		var supplierID sql.NullInt64
+		if t.Match != nil {
+			t.State = entity.AcctBankTxnMatched
+			t.SuggestedAccount = sql.NullString{String: t.Match.Account, Valid: t.Match.Account != ""}
+			matchKind = sql.NullString{String: string(t.Match.Kind), Valid: true}
+			matchRef = sql.NullString{String: t.Match.Ref, Valid: t.Match.Ref != ""}
+			supplierID = sql.NullInt64{Int64: int64(t.Match.SupplierId), Valid: t.Match.SupplierId > 0}
+		}
 		// Apply rule suggestions only to a line still awaiting a decision.
 		if t.State == entity.AcctBankTxnUnmatched && !t.SuggestedAccount.Valid {
 			if code := matchBankRule(rules, t.Counterparty, t.Description); code != "" {
@@ -50,9 +71,9 @@ func (s *Store) ImportBankTxns(ctx context.Context, txns []entity.AcctBankTxnIns
 		affected, err := storeutil.ExecNamedRows(ctx, s.DB, `
 			INSERT INTO acct_bank_txn
 				(source, external_id, booked_at, amount, currency, fee, description,
-				 counterparty, state, suggested_account, raw)
+				 counterparty, state, suggested_account, match_kind, match_ref, supplier_id, raw)
 			VALUES (:source, :external_id, :booked_at, :amount, :currency, :fee, :description,
-				:counterparty, :state, :suggested_account, :raw)
+				:counterparty, :state, :suggested_account, :match_kind, :match_ref, :supplier_id, :raw)
 			ON DUPLICATE KEY UPDATE id = id`,
 			map[string]any{
 				"source":            defaultBankSource(t.Source),
@@ -65,6 +86,9 @@ func (s *Store) ImportBankTxns(ctx context.Context, txns []entity.AcctBankTxnIns
 				"counterparty":      t.Counterparty,
 				"state":             string(t.State),
 				"suggested_account": t.SuggestedAccount,
+				"match_kind":        matchKind,
+				"match_ref":         matchRef,
+				"supplier_id":       supplierID,
 				"raw":               t.Raw,
 			})
 		if err != nil {
@@ -80,10 +104,48 @@ func (s *Store) ImportBankTxns(ctx context.Context, txns []entity.AcctBankTxnIns
 	return res, nil
 }
 
-// defaultBankSource defaults an empty source to 'revolut' (the only parser today).
+// bankMatchCandidates loads the open items ImportBankTxns matches against: the open receivables with
+// the invoice numbers of their orders, and the open payables.
+func (s *Store) bankMatchCandidates(ctx context.Context) (acctrules.BankMatchCandidates, error) {
+	var c acctrules.BankMatchCandidates
+	var err error
+	if c.Receivables, err = s.GetReceivables(ctx); err != nil {
+		return c, err
+	}
+	if c.Payables, err = s.GetPayables(ctx); err != nil {
+		return c, err
+	}
+	refs := make([]string, 0, len(c.Receivables))
+	for _, r := range c.Receivables {
+		refs = append(refs, r.Ref)
+	}
+	c.InvoiceNumbers = make(map[string][]string, len(refs))
+	if len(refs) == 0 {
+		return c, nil
+	}
+	type invoiceRef struct {
+		OrderUUID string `db:"order_uuid"`
+		Number    string `db:"number"`
+	}
+	rows, err := storeutil.QueryListNamed[invoiceRef](ctx, s.DB, `
+		SELECT co.uuid AS order_uuid, si.number
+		FROM sales_invoice si
+		JOIN customer_order co ON co.id = si.order_id
+		WHERE si.kind = 'invoice' AND co.uuid IN (:refs)`,
+		map[string]any{"refs": refs})
+	if err != nil {
+		return c, fmt.Errorf("accounting: load receivable invoice numbers: %w", err)
+	}
+	for _, r := range rows {
+		c.InvoiceNumbers[r.OrderUUID] = append(c.InvoiceNumbers[r.OrderUUID], r.Number)
+	}
+	return c, nil
+}
+
+// defaultBankSource defaults an empty source to 'revolut' (the first bank).
 func defaultBankSource(s string) string {
 	if strings.TrimSpace(s) == "" {
-		return "revolut"
+		return acctrules.BankSourceRevolut
 	}
 	return s
 }
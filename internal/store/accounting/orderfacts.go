@@ -25,6 +25,8 @@ const defaultScanBatch = 200
 // on purpose while metrics are snapshot-only (owner decision 2026-08-04): the ledger posted with
 // this formula and recon must keep mirroring it. A missing order surfaces as sql.ErrNoRows
 // (wrapped). This reads other domains' tables directly (the internal/store/metrics precedent).
+// store_credit_applied and gift_card_total (0334) let the builders split the tender between money
+// and 2100 and keep gift-card lines out of revenue, VAT and COGS.
 func (s *Store) GetOrderFactsForPosting(ctx context.Context, orderUUID string) (*entity.AcctOrderFacts, error) {
 	// Phase 2, wave 1: the buyer→shipping-address JOIN supplies the VAT destination (country_code with
 	// a fallback to country, 07 §7.4.1) and buyer_vat_id / vat_regime are read from the order. LEFT
@@ -33,6 +35,10 @@ func (s *Store) GetOrderFactsForPosting(ctx context.Context, orderUUID string) (
 		SELECT co.id, co.uuid, co.placed, co.total_price, co.currency,
 		       co.total_settled_base, co.payment_fee, co.vat_amount, co.vat_rate_pct,
 		       co.buyer_vat_id, co.vat_regime, co.promo_discount_pct,
+		       co.store_credit_applied,
+		       (SELECT COALESCE(SUM(gi.product_price_with_sale * gi.quantity), 0)
+		        FROM order_item gi JOIN gift_card_product g ON g.product_id = gi.product_id
+		        WHERE gi.order_id = co.id) AS gift_card_total,
 		       p.payment_method_id,
 		       s.cost AS shipment_cost, s.free_shipping,
 		       COALESCE(NULLIF(a.country_code, ''), a.country, '') AS dest_country
@@ -60,9 +66,11 @@ func (s *Store) GetOrderFactsForPosting(ctx context.Context, orderUUID string) (
 	// as uncosted (excluded from COGS, named in the entry caveat) rather than vanishing.
 	items, err := storeutil.QueryListNamed[entity.AcctOrderItemFact](ctx, s.DB, `
 		SELECT oi.id, oi.product_id, oi.quantity,
-		       COALESCE(oi.cost_price_at_sale, pr.cost_price) AS unit_cost
+		       COALESCE(oi.cost_price_at_sale, pr.cost_price) AS unit_cost,
+		       (gcp.product_id IS NOT NULL) AS gift_card
 		FROM order_item oi
 		LEFT JOIN product pr ON pr.id = oi.product_id
+		LEFT JOIN gift_card_product gcp ON gcp.product_id = oi.product_id
 		WHERE oi.order_id = :order_id`, map[string]any{"order_id": facts.Id})
 	if err != nil {
 		return nil, fmt.Errorf("accounting: get order item facts %s: %w", orderUUID, err)
@@ -562,3 +570,21 @@ func (s *Store) ListDevExpensesForPosting(ctx context.Context, startDate time.Ti
 	}
 	return rows, nil
 }
+
+// ListPayslipsForPosting returns every APPROVED payroll_payslip of a month on/after startDate's month,
+// joined with the employee's name (0341). Like dev expenses, the worker reconciles the full set each
+// tick: a reopened payslip is no longer approved, drops out of this list and is reversed; approved
+// again with another gross, it reposts.
+func (s *Store) ListPayslipsForPosting(ctx context.Context, startDate time.Time) ([]entity.AcctPayslipFacts, error) {
+	rows, err := storeutil.QueryListNamed[entity.AcctPayslipFacts](ctx, s.DB, `
+		SELECT p.id, p.employee_id, e.full_name AS employee_name, p.month, p.scheme, p.gross_base
+		FROM payroll_payslip p
+		JOIN employee e ON e.id = p.employee_id
+		WHERE p.status = 'approved' AND p.month >= :start_month
+		ORDER BY p.id`,
+		map[string]any{"start_month": firstOfMonthUTC(startDate).Format("2006-01-02")})
+	if err != nil {
+		return nil, fmt.Errorf("accounting: list payslips for posting: %w", err)
+	}
+	return rows, nil
+}
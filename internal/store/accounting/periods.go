@@ -169,10 +169,11 @@ func (s *Store) ClosePeriod(ctx context.Context, month time.Time, adminUsername
 	// 3d) no unmatched bank inbox lines booked in the month. An unmatched line is real money that
 	//     moved on the bank account but sits in NO journal entry at all, so gate 4's Dr==Cr check
 	//     cannot see it — the ledger balances perfectly while 1010 quietly disagrees with the real
-	//     bank. Post each line (or deliberately ignore an internal transfer leg) before closing.
+	//     bank. Post each line (or deliberately ignore an internal transfer leg) before closing. An
+	//     auto-matched line (0338) is only a proposal and is still unposted, so it counts too.
 	unmatchedBank, err := storeutil.QueryCountNamed(ctx, s.DB, `
 		SELECT COUNT(*) FROM acct_bank_txn
-		WHERE state = 'unmatched' AND booked_at >= :from AND booked_at < :to`,
+		WHERE state IN ('unmatched', 'matched') AND booked_at >= :from AND booked_at < :to`,
 		map[string]any{"from": from, "to": to})
 	if err != nil {
 		return fmt.Errorf("accounting: close period unmatched bank lines: %w", err)
@@ -107,12 +107,12 @@ func (s *Store) GetReconciliation(ctx context.Context, from, to time.Time) (*ent
 // reconBank: the 1010 Cash-Bank ledger balance as of the period end vs the Revolut inbox that feeds it
 // (phase 2, wave 4 — §4.1). There is no external base-currency bank-balance feed (the inbox is multi-
 // currency and posts via the FX-fold mechanic), so this block does not assert a delta; instead it surfaces
-// the ACTIONABLE backlog — statement lines booked in the period that are still unmatched (awaiting a
-// post/ignore decision) — so 1010 is not trusted while lines remain unposted. Ledger/Operational carry the
+// the ACTIONABLE backlog — statement lines booked in the period that are still unmatched or only
+// auto-matched (0338), both awaiting a post/ignore decision — so 1010 is not trusted while lines remain unposted. Ledger/Operational carry the
 // 1010 balance; Items sample the unposted lines with their signed amounts (own currency, informational).
 // Lines IGNORED in the period are listed too (audit finding 12): ignoring books nothing, so a real
 // expense/income wrongly ignored would otherwise vanish from every report — the sample keeps each
-// deliberate omission reviewable. TotalCount stays the unmatched (actionable) count only.
+// deliberate omission reviewable. TotalCount stays the unposted (actionable) count only.
 func (s *Store) reconBank(ctx context.Context, fromT, toT time.Time) (entity.AcctReconBlock, error) {
 	ledger, err := s.accountBalanceBefore(ctx, "1010", toT)
 	if err != nil {
@@ -127,7 +127,7 @@ func (s *Store) reconBank(ctx context.Context, fromT, toT time.Time) (entity.Acc
 	}](ctx, s.DB, `
 		SELECT external_id AS ref, currency, amount
 		FROM acct_bank_txn
-		WHERE state = 'unmatched' AND booked_at >= :from AND booked_at < :to
+		WHERE state IN ('unmatched', 'matched') AND booked_at >= :from AND booked_at < :to
 		ORDER BY booked_at
 		LIMIT :topN`,
 		map[string]any{"from": fromT, "to": toT, "topN": reconTopN})
@@ -166,7 +166,7 @@ func (s *Store) reconBank(ctx context.Context, fromT, toT time.Time) (entity.Acc
 
 	block.TotalCount, err = storeutil.QueryCountNamed(ctx, s.DB, `
 		SELECT COUNT(*) FROM acct_bank_txn
-		WHERE state = 'unmatched' AND booked_at >= :from AND booked_at < :to`,
+		WHERE state IN ('unmatched', 'matched') AND booked_at >= :from AND booked_at < :to`,
 		map[string]any{"from": fromT, "to": toT})
 	if err != nil {
 		return entity.AcctReconBlock{}, fmt.Errorf("accounting: recon bank unmatched count: %w", err)
@@ -2,6 +2,7 @@ package accounting
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
@@ -17,18 +18,40 @@ import (
 // maps to InvalidArgument.
 func (s *Store) CreateSupplier(ctx context.Context, in entity.SupplierInsert) (int, error) {
 	id, err := storeutil.ExecNamedLastId(ctx, s.DB,
-		`INSERT INTO supplier (name, vat_id, notes) VALUES (:name, :vat_id, :notes)`,
-		map[string]any{"name": in.Name, "vat_id": in.VatId, "notes": in.Notes})
+		`INSERT INTO supplier (name, vat_id, notes, lead_time_days) VALUES (:name, :vat_id, :notes, :lead_time_days)`,
+		map[string]any{"name": in.Name, "vat_id": in.VatId, "notes": in.Notes, "lead_time_days": in.LeadTimeDays})
 	if err != nil {
 		return 0, fmt.Errorf("accounting: create supplier %q: %w", in.Name, err)
 	}
 	return id, nil
 }
 
+// UpdateSupplier replaces a supplier's editable fields. sql.ErrNoRows when the id does not exist; a
+// name taken by another supplier is a unique-violation, as on create.
+func (s *Store) UpdateSupplier(ctx context.Context, id int, in entity.SupplierInsert) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		UPDATE supplier SET name = :name, vat_id = :vat_id, notes = :notes, lead_time_days = :lead_time_days
+		WHERE id = :id`,
+		map[string]any{"id": id, "name": in.Name, "vat_id": in.VatId, "notes": in.Notes, "lead_time_days": in.LeadTimeDays})
+	if err != nil {
+		return fmt.Errorf("accounting: update supplier %d: %w", id, err)
+	}
+	if n == 0 {
+		exists, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM supplier WHERE id = :id`, map[string]any{"id": id})
+		if err != nil {
+			return fmt.Errorf("accounting: update supplier %d: %w", id, err)
+		}
+		if exists == 0 {
+			return sql.ErrNoRows
+		}
+	}
+	return nil
+}
+
 // ListSuppliers returns the supplier catalog, name-ordered.
 func (s *Store) ListSuppliers(ctx context.Context) ([]entity.Supplier, error) {
 	suppliers, err := storeutil.QueryListNamed[entity.Supplier](ctx, s.DB,
-		`SELECT id, name, vat_id, notes, created_at FROM supplier ORDER BY name`, nil)
+		`SELECT id, name, vat_id, notes, lead_time_days, created_at FROM supplier ORDER BY name`, nil)
 	if err != nil {
 		return nil, fmt.Errorf("accounting: list suppliers: %w", err)
 	}
@@ -73,7 +96,9 @@ func (s *Store) GetPayables(ctx context.Context) ([]entity.AcctPayableRow, error
 //
 // Only order-keyed 1040 lines (source_key = order uuid) form real receivable rows. Bank-posted entries
 // (source_key "bank:<id>") and manual payments ("manual:<uuid>") both have source_type 'manual' and would
-// collapse into a phantom ref='bank'/'manual' group (LOW-2), so they are excluded.
+// collapse into a phantom ref='bank'/'manual' group (LOW-2), so they are excluded — except a bank line
+// auto-matched to a receivable (0338): its posted entry is grouped by the order uuid the match recorded
+// (acct_bank_txn.match_ref), so the payment nets the order it pays.
 //
 // NB: that rationale lives HERE and not in a SQL '--' comment, and the SUBSTRING_INDEX separator is
 // CHAR(58) (ASCII ':') rather than a ':' literal — sqlx's named-param scanner does not skip SQL comments
@@ -83,15 +108,16 @@ func (s *Store) GetPayables(ctx context.Context) ([]entity.AcctPayableRow, error
 // (dfb69b4) incidents; see also the parameterless-query guard in storeutil.makeQuery.
 func (s *Store) GetReceivables(ctx context.Context) ([]entity.AcctReceivableRow, error) {
 	rows, err := storeutil.QueryListNamed[entity.AcctReceivableRow](ctx, s.DB, `
-		SELECT SUBSTRING_INDEX(e.source_key, CHAR(58), 1) AS ref,
+		SELECT COALESCE(bt.match_ref, SUBSTRING_INDEX(e.source_key, CHAR(58), 1)) AS ref,
 		       COALESCE(SUM(CASE WHEN l.side = 'debit'  THEN l.amount ELSE 0 END), 0) AS invoiced,
 		       COALESCE(SUM(CASE WHEN l.side = 'credit' THEN l.amount ELSE 0 END), 0) AS received
 		FROM acct_journal_line l
 		JOIN acct_journal_entry e ON e.id = l.entry_id
 		JOIN acct_account a       ON a.id = l.account_id
+		LEFT JOIN acct_bank_txn bt ON bt.matched_entry_id = e.id AND bt.match_kind = 'receivable'
 		WHERE a.code = '1040'
-		  AND e.source_type <> 'manual'
-		GROUP BY SUBSTRING_INDEX(e.source_key, CHAR(58), 1)
+		  AND (e.source_type <> 'manual' OR bt.id IS NOT NULL)
+		GROUP BY COALESCE(bt.match_ref, SUBSTRING_INDEX(e.source_key, CHAR(58), 1))
 		HAVING invoiced > 0 AND (invoiced - received) <> 0
 		ORDER BY (invoiced - received) DESC`, nil)
 	if err != nil {
@@ -0,0 +1,130 @@
+// Package audit stores the admin-wide audit trail (admin_audit_event, 0339): one row per mutating
+// admin RPC, written by the auditlog interceptor and purged by its retention worker.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// Store implements dependency.Audit.
+type Store struct {
+	storeutil.Base
+}
+
+// New creates a new audit trail store.
+func New(base storeutil.Base) *Store {
+	return &Store{Base: base}
+}
+
+// InsertAuditEvent records one call. Id is ignored.
+func (s *Store) InsertAuditEvent(ctx context.Context, e *entity.AuditEvent) error {
+	q := `INSERT INTO admin_audit_event
+		(occurred_at, actor, method, section, target_type, target_id, status_code, error_message, duration_ms, request, response)
+		VALUES (:occurredAt, :actor, :method, :section, :targetType, :targetId, :statusCode, :errorMessage, :durationMs, :request, :response)`
+	err := storeutil.ExecNamed(ctx, s.DB, q, map[string]any{
+		"occurredAt":   e.OccurredAt.UTC(),
+		"actor":        e.Actor,
+		"method":       e.Method,
+		"section":      e.Section,
+		"targetType":   e.TargetType,
+		"targetId":     e.TargetId,
+		"statusCode":   e.StatusCode,
+		"errorMessage": e.ErrorMessage,
+		"durationMs":   e.DurationMs,
+		"request":      e.Request,
+		"response":     e.Response,
+	})
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents returns one page of the trail, newest first, and the total matching the filter.
+func (s *Store) ListAuditEvents(ctx context.Context, f entity.AuditEventFilter) ([]entity.AuditEvent, int, error) {
+	if f.Sections != nil && len(f.Sections) == 0 {
+		return []entity.AuditEvent{}, 0, nil
+	}
+	where := []string{"1=1"}
+	params := map[string]any{}
+	if f.Actor != "" {
+		where = append(where, "actor = :actor")
+		params["actor"] = f.Actor
+	}
+	if f.Method != "" {
+		where = append(where, "method = :method")
+		params["method"] = f.Method
+	}
+	if f.Section != "" {
+		where = append(where, "section = :section")
+		params["section"] = f.Section
+	}
+	if f.Sections != nil {
+		where = append(where, "section IN (:sections)")
+		params["sections"] = f.Sections
+	}
+	if f.TargetType != "" {
+		where = append(where, "target_type = :targetType")
+		params["targetType"] = f.TargetType
+	}
+	if f.TargetId != "" {
+		where = append(where, "target_id = :targetId")
+		params["targetId"] = f.TargetId
+	}
+	if f.From.Valid {
+		where = append(where, "occurred_at >= :from")
+		params["from"] = f.From.Time
+	}
+	if f.To.Valid {
+		where = append(where, "occurred_at <= :to")
+		params["to"] = f.To.Time
+	}
+	if f.FailedOnly {
+		where = append(where, "status_code <> 'OK'")
+	}
+	wc := strings.Join(where, " AND ")
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	params["limit"] = limit
+	params["offset"] = f.Offset
+	listQ := fmt.Sprintf(`SELECT id, occurred_at, actor, method, section, target_type, target_id, status_code,
+		error_message, duration_ms, request, response
+		FROM admin_audit_event WHERE %s ORDER BY occurred_at DESC, id DESC LIMIT :limit OFFSET :offset`, wc)
+	rows, err := storeutil.QueryListNamed[entity.AuditEvent](ctx, s.DB, listQ, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list audit events: %w", err)
+	}
+	cp := map[string]any{}
+	for k, v := range params {
+		if k == "limit" || k == "offset" {
+			continue
+		}
+		cp[k] = v
+	}
+	total, err := storeutil.QueryCountNamed(ctx, s.DB, "SELECT COUNT(*) FROM admin_audit_event WHERE "+wc, cp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count audit events: %w", err)
+	}
+	return rows, total, nil
+}
+
+// PurgeAuditEvents deletes up to limit events that occurred before cutoff, oldest first, and
+// returns how many went. The retention worker calls it until a batch comes back short, so one
+// large backlog never holds a long delete lock.
+func (s *Store) PurgeAuditEvents(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB,
+		`DELETE FROM admin_audit_event WHERE occurred_at < :cutoff ORDER BY id LIMIT :limit`,
+		map[string]any{"cutoff": cutoff.UTC(), "limit": limit})
+	if err != nil {
+		return 0, fmt.Errorf("purge audit events: %w", err)
+	}
+	return n, nil
+}
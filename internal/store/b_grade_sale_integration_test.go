@@ -209,7 +209,7 @@ func TestBGradeManualPriceSale(t *testing.T) {
 	require.Equal(t, 9, count(`SELECT quantity FROM product_size WHERE id = ?`, aVarID), "A stock decremented from its own row")
 	require.Equal(t, 2, count(`SELECT quantity FROM product_size WHERE id = ?`, bVarID), "B stock decremented from the B row, not A")
 
-	require.NoError(t, s.Order().RefundOrder(ctx, o.UUID, nil, "test", "OTHER", false, entity.RefundDispositionRestock))
+	require.NoError(t, s.Order().RefundOrder(ctx, o.UUID, nil, "test", "OTHER", false, entity.RefundDispositionRestock, false))
 	require.Equal(t, 10, count(`SELECT quantity FROM product_size WHERE id = ?`, aVarID), "A unit restocked to A")
 	require.Equal(t, 3, count(`SELECT quantity FROM product_size WHERE id = ?`, bVarID), "B unit restocked to B, not A")
 
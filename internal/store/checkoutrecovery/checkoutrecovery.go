@@ -0,0 +1,448 @@
+// Package checkoutrecovery implements dependency.CheckoutRecoveries: abandoned checkouts of consented
+// customers (0352), the state of their recovery email sequence, restore-cart links and the
+// conversion report.
+package checkoutrecovery
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc matches the store transaction callback used by MYSQLStore.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.CheckoutRecoveries.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a checkout recovery store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+// newToken returns a restore-link secret: 16 random bytes, hex.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("can't generate recovery token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// nullID maps 0 to NULL.
+func nullID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// RecordAbandonedCheckout remembers the cart of a consented checkout. The address's open record, if
+// there is one, takes the new cart and activity time and keeps its place in the email sequence; a
+// known account or order is never cleared by a later anonymous call. An empty cart records nothing.
+func (s *Store) RecordAbandonedCheckout(ctx context.Context, cart *entity.CheckoutRecoveryCart) error {
+	qty := make(map[int]decimal.Decimal, len(cart.Lines))
+	order := make([]int, 0, len(cart.Lines))
+	for _, l := range cart.Lines {
+		if l.VariantId == 0 || !l.Quantity.IsPositive() {
+			continue
+		}
+		if _, ok := qty[l.VariantId]; !ok {
+			order = append(order, l.VariantId)
+		}
+		qty[l.VariantId] = qty[l.VariantId].Add(l.Quantity)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		// open_email is UNIQUE, so a second checkout of the same address lands on its open record.
+		id, err := storeutil.ExecNamedLastId(ctx, db, `
+			INSERT INTO checkout_recovery (token, email, account_id, order_id, currency, source)
+			VALUES (:token, :email, :accountId, :orderId, :currency, :source)
+			ON DUPLICATE KEY UPDATE
+				id = LAST_INSERT_ID(id),
+				account_id = COALESCE(VALUES(account_id), account_id),
+				order_id = COALESCE(VALUES(order_id), order_id),
+				currency = VALUES(currency),
+				source = VALUES(source),
+				last_activity_at = CURRENT_TIMESTAMP`, map[string]any{
+			"token":     token,
+			"email":     cart.Email,
+			"accountId": nullID(cart.AccountId),
+			"orderId":   nullID(cart.OrderId),
+			"currency":  cart.Currency,
+			"source":    string(cart.Source),
+		})
+		if err != nil {
+			return fmt.Errorf("record abandoned checkout: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM checkout_recovery_item WHERE recovery_id = :id`,
+			map[string]any{"id": id}); err != nil {
+			return fmt.Errorf("clear abandoned cart: %w", err)
+		}
+		rows := make([]map[string]any, 0, len(order))
+		for _, vid := range order {
+			rows = append(rows, map[string]any{
+				"recovery_id": id,
+				"variant_id":  vid,
+				"quantity":    qty[vid],
+			})
+		}
+		if err := storeutil.BulkInsert(ctx, db, "checkout_recovery_item", rows); err != nil {
+			return fmt.Errorf("record abandoned cart: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListOpenCheckoutRecoveries returns every open abandoned checkout with its cart, whether its address
+// has unsubscribed from marketing and whether one of its orders is still waiting for payment.
+func (s *Store) ListOpenCheckoutRecoveries(ctx context.Context) ([]entity.CheckoutRecovery, error) {
+	var pendingIDs []int
+	for _, n := range []entity.OrderStatusName{entity.Placed, entity.AwaitingPayment} {
+		if st, ok := cache.GetOrderStatusByName(n); ok {
+			pendingIDs = append(pendingIDs, st.Status.Id)
+		}
+	}
+	pending := `FALSE`
+	if len(pendingIDs) > 0 {
+		pending = `EXISTS (
+				SELECT 1 FROM buyer b
+				JOIN customer_order co ON co.id = b.order_id
+				WHERE b.email = cr.email AND co.order_status_id IN (:pendingIds)
+				  AND (co.placed >= cr.created_at OR co.id = cr.order_id))`
+	}
+	recs, err := storeutil.QueryListNamed[entity.CheckoutRecovery](ctx, s.DB, `
+		SELECT cr.id, cr.token, cr.email, cr.account_id, cr.order_id, cr.currency, cr.source, cr.status,
+			cr.steps_sent, cr.last_activity_at, cr.last_sent_at, cr.incentive_code, cr.incentive_step,
+			cr.restored_at, cr.created_at,
+			EXISTS (SELECT 1 FROM subscriber sub WHERE sub.email = cr.email AND sub.receive_promo_emails = 0) AS unsubscribed,
+			`+pending+` AS payment_pending
+		FROM checkout_recovery cr
+		WHERE cr.status = 'open'
+		ORDER BY cr.id`, map[string]any{"pendingIds": pendingIDs})
+	if err != nil {
+		return nil, fmt.Errorf("can't list open abandoned checkouts: %w", err)
+	}
+	if len(recs) == 0 {
+		return recs, nil
+	}
+
+	type lineRow struct {
+		RecoveryId int `db:"recovery_id"`
+		entity.CheckoutRecoveryLine
+	}
+	lines, err := storeutil.QueryListNamed[lineRow](ctx, s.DB, `
+		SELECT cri.recovery_id, cri.variant_id, COALESCE(ps.sku, '') AS variant_sku, cri.quantity
+		FROM checkout_recovery_item cri
+		JOIN checkout_recovery cr ON cr.id = cri.recovery_id
+		JOIN product_size ps ON ps.id = cri.variant_id
+		WHERE cr.status = 'open'
+		ORDER BY cri.recovery_id, cri.variant_id`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't list abandoned carts: %w", err)
+	}
+	byID := make(map[int]*entity.CheckoutRecovery, len(recs))
+	for i := range recs {
+		byID[recs[i].Id] = &recs[i]
+	}
+	for _, l := range lines {
+		if r, ok := byID[l.RecoveryId]; ok {
+			r.Lines = append(r.Lines, l.CheckoutRecoveryLine)
+		}
+	}
+	return recs, nil
+}
+
+// ClosePurchasedCheckoutRecoveries closes every open abandoned checkout whose customer has since paid
+// for an order — one placed by the address after the checkout was recorded, or the checkout's own
+// order. A checkout that was mailed converts, attributed to its last email; one that was not is
+// suppressed. It returns how many of each it closed.
+func (s *Store) ClosePurchasedCheckoutRecoveries(ctx context.Context) (converted, suppressed int, err error) {
+	paidIDs := cache.OrderStatusIDsForRefund()
+	if len(paidIDs) == 0 {
+		return 0, 0, nil
+	}
+	err = s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		purchases, err := storeutil.QueryListNamed[entity.CheckoutRecoveryPurchase](ctx, db, `
+			SELECT cr.id AS recovery_id, MIN(co.id) AS order_id
+			FROM checkout_recovery cr
+			JOIN buyer b ON b.email = cr.email
+			JOIN customer_order co ON co.id = b.order_id
+			WHERE cr.status = 'open' AND co.order_status_id IN (:paidIds)
+			  AND (co.placed >= cr.created_at OR co.id = cr.order_id)
+			GROUP BY cr.id`, map[string]any{"paidIds": paidIDs})
+		if err != nil {
+			return fmt.Errorf("match purchases: %w", err)
+		}
+		for _, p := range purchases {
+			st, err := storeutil.QueryNamedOne[struct {
+				StepsSent int `db:"steps_sent"`
+			}](ctx, db, `SELECT steps_sent FROM checkout_recovery WHERE id = :id AND status = 'open' FOR UPDATE`,
+				map[string]any{"id": p.RecoveryId})
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					continue
+				}
+				return fmt.Errorf("read abandoned checkout %d: %w", p.RecoveryId, err)
+			}
+			status := entity.CheckoutRecoverySuppressed
+			var step any
+			if st.StepsSent > 0 {
+				status, step = entity.CheckoutRecoveryConverted, st.StepsSent
+			}
+			if err := storeutil.ExecNamed(ctx, db, `
+				UPDATE checkout_recovery
+				SET status = :status, converted_order_id = :orderId, attributed_step = :step, closed_at = CURRENT_TIMESTAMP
+				WHERE id = :id AND status = 'open'`, map[string]any{
+				"id":      p.RecoveryId,
+				"status":  string(status),
+				"orderId": p.OrderId,
+				"step":    step,
+			}); err != nil {
+				return fmt.Errorf("close abandoned checkout %d: %w", p.RecoveryId, err)
+			}
+			if status == entity.CheckoutRecoveryConverted {
+				converted++
+			} else {
+				suppressed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("can't close purchased abandoned checkouts: %w", err)
+	}
+	return converted, suppressed, nil
+}
+
+// MarkCheckoutRecoveryStepSent records that step went out, with the incentive code it carried (empty
+// — none). A checkout closed or already past the step in the meantime is left alone.
+func (s *Store) MarkCheckoutRecoveryStepSent(ctx context.Context, id, step int, incentiveCode string) error {
+	var code any
+	if incentiveCode != "" {
+		code = incentiveCode
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE checkout_recovery
+		SET steps_sent = :step, last_sent_at = CURRENT_TIMESTAMP,
+			incentive_step = IF(:code IS NULL, incentive_step, :step),
+			incentive_code = COALESCE(:code, incentive_code)
+		WHERE id = :id AND status = 'open' AND steps_sent = :step - 1`, map[string]any{
+		"id":   id,
+		"step": step,
+		"code": code,
+	}); err != nil {
+		return fmt.Errorf("can't mark abandoned checkout %d step %d sent: %w", id, step, err)
+	}
+	return nil
+}
+
+// SetCheckoutRecoveryIncentive stores the single-use code minted for step before its email is
+// queued, so a retry of the step sends the same code instead of minting another. sql.ErrNoRows when
+// the checkout already has a code, is closed or is not waiting for this step.
+func (s *Store) SetCheckoutRecoveryIncentive(ctx context.Context, id, step int, code string) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		UPDATE checkout_recovery SET incentive_code = :code, incentive_step = :step
+		WHERE id = :id AND status = 'open' AND incentive_code IS NULL AND steps_sent = :step - 1`,
+		map[string]any{"id": id, "step": step, "code": code})
+	if err != nil {
+		return fmt.Errorf("can't store incentive code of abandoned checkout %d: %w", id, err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CloseCheckoutRecovery closes an open abandoned checkout without a purchase (opted out or expired).
+func (s *Store) CloseCheckoutRecovery(ctx context.Context, id int, status entity.CheckoutRecoveryStatus) error {
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE checkout_recovery SET status = :status, closed_at = CURRENT_TIMESTAMP
+		WHERE id = :id AND status = 'open'`, map[string]any{"id": id, "status": string(status)}); err != nil {
+		return fmt.Errorf("can't close abandoned checkout %d: %w", id, err)
+	}
+	return nil
+}
+
+// RestoreCheckoutRecovery returns the cart behind a restore link: the lines whose variant is still
+// on sale, the currency and the incentive code once its email has gone out. The first restore is recorded with the step whose
+// email carried the link (0 or a step that was never sent — unknown). sql.ErrNoRows for an unknown
+// token.
+func (s *Store) RestoreCheckoutRecovery(ctx context.Context, token string, step int) (*entity.CheckoutRecoveryRestore, error) {
+	rec, err := storeutil.QueryNamedOne[struct {
+		Id            int            `db:"id"`
+		Currency      string         `db:"currency"`
+		IncentiveCode sql.NullString `db:"incentive_code"`
+	}](ctx, s.DB, `
+		SELECT id, currency, IF(incentive_step <= steps_sent, incentive_code, NULL) AS incentive_code
+		FROM checkout_recovery WHERE token = :token`,
+		map[string]any{"token": token})
+	if err != nil {
+		return nil, err
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE checkout_recovery
+		SET restored_at = CURRENT_TIMESTAMP, restored_step = IF(:step BETWEEN 1 AND steps_sent, :step, NULL)
+		WHERE id = :id AND restored_at IS NULL`, map[string]any{"id": rec.Id, "step": step}); err != nil {
+		return nil, fmt.Errorf("can't record cart restore: %w", err)
+	}
+	lines, err := storeutil.QueryListNamed[entity.CheckoutRecoveryLine](ctx, s.DB, `
+		SELECT cri.variant_id, ps.sku AS variant_sku, cri.quantity
+		FROM checkout_recovery_item cri
+		JOIN product_size ps ON ps.id = cri.variant_id
+		JOIN product p ON p.id = ps.product_id
+		WHERE cri.recovery_id = :id AND ps.sku IS NOT NULL AND ps.status = 1 AND p.lifecycle_status = 2
+		ORDER BY cri.variant_id`, map[string]any{"id": rec.Id})
+	if err != nil {
+		return nil, fmt.Errorf("can't read abandoned cart: %w", err)
+	}
+	return &entity.CheckoutRecoveryRestore{
+		Currency:      rec.Currency,
+		IncentiveCode: rec.IncentiveCode.String,
+		Lines:         lines,
+	}, nil
+}
+
+// OptOutCheckoutRecovery stops the email sequence behind a link. Stopping a closed one is a no-op;
+// sql.ErrNoRows for an unknown token.
+func (s *Store) OptOutCheckoutRecovery(ctx context.Context, token string) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		UPDATE checkout_recovery SET status = 'opted_out', closed_at = CURRENT_TIMESTAMP
+		WHERE token = :token AND status = 'open'`, map[string]any{"token": token})
+	if err != nil {
+		return fmt.Errorf("can't opt out of checkout recovery: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	cnt, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM checkout_recovery WHERE token = :token`,
+		map[string]any{"token": token})
+	if err != nil {
+		return fmt.Errorf("can't find checkout recovery: %w", err)
+	}
+	if cnt == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCheckoutRecoveryMetrics reports the abandoned checkouts recorded in [from, to): how they closed,
+// and per email step how many went out, restored the cart, converted (attributed to the last email
+// before the purchase) and redeemed the incentive code.
+func (s *Store) GetCheckoutRecoveryMetrics(ctx context.Context, from, to time.Time) (*entity.CheckoutRecoveryMetrics, error) {
+	params := map[string]any{"from": from, "to": to}
+	m, err := storeutil.QueryNamedOne[entity.CheckoutRecoveryMetrics](ctx, s.DB, `
+		SELECT COUNT(*) AS recorded,
+			CAST(COALESCE(SUM(cr.status = 'open'), 0) AS SIGNED) AS open,
+			CAST(COALESCE(SUM(cr.status = 'converted'), 0) AS SIGNED) AS converted,
+			CAST(COALESCE(SUM(cr.status = 'suppressed'), 0) AS SIGNED) AS suppressed,
+			CAST(COALESCE(SUM(cr.status = 'opted_out'), 0) AS SIGNED) AS opted_out,
+			CAST(COALESCE(SUM(cr.status = 'expired'), 0) AS SIGNED) AS expired,
+			COALESCE(SUM(CASE WHEN cr.status = 'converted' THEN co.total_settled_base END), 0) AS revenue
+		FROM checkout_recovery cr
+		LEFT JOIN customer_order co ON co.id = cr.converted_order_id
+		WHERE cr.created_at >= :from AND cr.created_at < :to`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get checkout recovery totals: %w", err)
+	}
+	m.From, m.To = from, to
+
+	type countRow struct {
+		Step int `db:"step"`
+		N    int `db:"n"`
+	}
+	sent, err := storeutil.QueryListNamed[countRow](ctx, s.DB, `
+		SELECT steps_sent AS step, COUNT(*) AS n
+		FROM checkout_recovery
+		WHERE created_at >= :from AND created_at < :to AND steps_sent > 0
+		GROUP BY steps_sent`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't count recovery emails: %w", err)
+	}
+	restored, err := storeutil.QueryListNamed[countRow](ctx, s.DB, `
+		SELECT restored_step AS step, COUNT(*) AS n
+		FROM checkout_recovery
+		WHERE created_at >= :from AND created_at < :to AND restored_step IS NOT NULL
+		GROUP BY restored_step`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't count restored carts: %w", err)
+	}
+	conversions, err := storeutil.QueryListNamed[entity.CheckoutRecoveryStepMetrics](ctx, s.DB, `
+		SELECT cr.attributed_step AS step, COUNT(*) AS conversions,
+			COALESCE(SUM(co.total_settled_base), 0) AS revenue
+		FROM checkout_recovery cr
+		LEFT JOIN customer_order co ON co.id = cr.converted_order_id
+		WHERE cr.created_at >= :from AND cr.created_at < :to AND cr.status = 'converted'
+		GROUP BY cr.attributed_step`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't count recovery conversions: %w", err)
+	}
+	incentives, err := storeutil.QueryListNamed[entity.CheckoutRecoveryStepMetrics](ctx, s.DB, `
+		SELECT cr.incentive_step AS step, COUNT(*) AS incentives_sent,
+			CAST(COALESCE(SUM(puc.order_id IS NOT NULL), 0) AS SIGNED) AS incentives_redeemed
+		FROM checkout_recovery cr
+		LEFT JOIN promo_unique_code puc ON puc.code = cr.incentive_code
+		WHERE cr.created_at >= :from AND cr.created_at < :to AND cr.incentive_step <= cr.steps_sent
+		GROUP BY cr.incentive_step`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't count recovery incentives: %w", err)
+	}
+
+	var last int
+	for _, r := range sent {
+		last = max(last, r.Step)
+	}
+	for _, r := range restored {
+		last = max(last, r.Step)
+	}
+	for _, r := range conversions {
+		last = max(last, r.Step)
+	}
+	for _, r := range incentives {
+		last = max(last, r.Step)
+	}
+	m.Steps = make([]entity.CheckoutRecoveryStepMetrics, last)
+	for i := range m.Steps {
+		m.Steps[i].Step = i + 1
+	}
+	// A checkout that got n emails was sent steps 1..n.
+	for _, r := range sent {
+		for i := 0; i < r.Step; i++ {
+			m.Steps[i].Sent += r.N
+		}
+	}
+	for _, r := range restored {
+		m.Steps[r.Step-1].Restored += r.N
+	}
+	for _, r := range conversions {
+		m.Steps[r.Step-1].Conversions += r.Conversions
+		m.Steps[r.Step-1].Revenue = m.Steps[r.Step-1].Revenue.Add(r.Revenue)
+	}
+	for _, r := range incentives {
+		m.Steps[r.Step-1].IncentivesSent += r.IncentivesSent
+		m.Steps[r.Step-1].IncentivesRedeemed += r.IncentivesRedeemed
+	}
+	return &m, nil
+}
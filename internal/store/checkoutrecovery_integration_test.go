@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckoutRecovery covers 0352: an address keeps one open checkout whose cart each new activity
+// replaces; steps are marked once and in order; the restore link returns the lines still on sale and
+// records the first restore; the stop link opts out; a paid order converts a mailed checkout and
+// suppresses an unmailed one; the report attributes per step; erasing the account removes its
+// checkouts.
+//
+// SAFE ONLY against a local container DSN — see the guard and mysql_test.go / project memory.
+func TestCheckoutRecovery(t *testing.T) {
+	if os.Getenv("CI") == "" &&
+		!strings.Contains(testCfg.DSN, "127.0.0.1") &&
+		!strings.Contains(testCfg.DSN, "localhost") {
+		t.Skip("skipping outside CI unless the DSN targets a local container (avoids the configured prod DB)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	di, err := s.Cache().GetDictionaryInfo(ctx)
+	require.NoError(t, err)
+	hf, err := s.Hero().GetHero(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cache.InitConsts(ctx, di, hf))
+	confirmed, ok := cache.GetOrderStatusByName(entity.Confirmed)
+	require.True(t, ok)
+
+	exec := func(q string, args ...any) int {
+		res, err := testDB.ExecContext(ctx, q, args...)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return int(id)
+	}
+	token := fmt.Sprintf("%d%04d", time.Now().UnixNano(), rand.Intn(10000))
+	mediaID, err := s.Media().AddMedia(ctx, &entity.MediaItem{
+		FullSizeMediaURL: "https://x/f.jpg", FullSizeWidth: 1, FullSizeHeight: 1,
+		ThumbnailMediaURL: "https://x/t.jpg", ThumbnailWidth: 1, ThumbnailHeight: 1,
+		CompressedMediaURL: "https://x/c.jpg", CompressedWidth: 1, CompressedHeight: 1,
+		BlurHash: sql.NullString{String: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", Valid: true},
+	})
+	require.NoError(t, err)
+	var sizeA, sizeB int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 ORDER BY id LIMIT 1`).Scan(&sizeA))
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 AND id > ? ORDER BY id LIMIT 1`, sizeA).Scan(&sizeB))
+
+	styleID := exec(`INSERT INTO tech_card (style_number, name, brand, collection, season_code, season_year, season, target_gender, top_category_id)
+		VALUES (CONCAT('CR-', UUID_SHORT()), 'CR', 'ACME', '', 'SS', 2026, 'SS26', 'unisex', 1)`)
+	product := func(suffix string, lifecycle int) int {
+		return exec(`INSERT INTO product (sku, color, color_code, color_hex, country_of_origin, thumbnail_id, style_id, lifecycle_status, sale_percentage)
+			VALUES (?, 'c', 'BLK', '#000000', 'US', ?, ?, ?, 0)`, "CR"+suffix+"-"+token, mediaID, styleID, lifecycle)
+	}
+	live, draft := product("A", 2), product("B", 1)
+	liveA := exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, live, sizeA, "CRA-"+token+"-1")
+	liveB := exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, live, sizeB, "CRA-"+token+"-2")
+	drafted := exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, draft, sizeA, "CRB-"+token+"-1")
+
+	mailed, unmailed, stopped := "cr-m"+token+"@example.com", "cr-u"+token+"@example.com", "cr-s"+token+"@example.com"
+	acc, err := s.StorefrontAccount().GetOrCreateAccountByEmail(ctx, "cr-e"+token+"@example.com")
+	require.NoError(t, err)
+	addrID := exec(`INSERT INTO address (country, country_code, city, address_line_one, postal_code) VALUES ('DE', 'DE', 'Berlin', 'Teststr. 1', '10115')`)
+	var orders []int
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM checkout_recovery WHERE email IN (?, ?, ?)", mailed, unmailed, stopped)
+		for _, id := range orders {
+			_, _ = testDB.ExecContext(cctx, "DELETE FROM buyer WHERE order_id = ?", id)
+			_, _ = testDB.ExecContext(cctx, "DELETE FROM customer_order WHERE id = ?", id)
+		}
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM address WHERE id = ?", addrID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM storefront_account WHERE id = ?", acc.ID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_size WHERE product_id IN (?, ?)", live, draft)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product WHERE id IN (?, ?)", live, draft)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM tech_card WHERE id = ?", styleID)
+	})
+
+	cr := s.CheckoutRecoveries()
+	line := func(variant int, qty int64) entity.CheckoutRecoveryLine {
+		return entity.CheckoutRecoveryLine{VariantId: variant, Quantity: decimal.NewFromInt(qty)}
+	}
+	record := func(email string, source entity.CheckoutRecoverySource, lines ...entity.CheckoutRecoveryLine) {
+		require.NoError(t, cr.RecordAbandonedCheckout(ctx, &entity.CheckoutRecoveryCart{
+			Email: email, Currency: "EUR", Source: source, Lines: lines,
+		}))
+	}
+	openFor := func(email string) *entity.CheckoutRecovery {
+		open, err := cr.ListOpenCheckoutRecoveries(ctx)
+		require.NoError(t, err)
+		for i := range open {
+			if open[i].Email == email {
+				return &open[i]
+			}
+		}
+		return nil
+	}
+
+	// Upsert: the second checkout of the address replaces the cart of its open record.
+	record(mailed, entity.CheckoutRecoverySourceValidate, line(liveA, 1), line(liveA, 1), line(drafted, 1))
+	first := openFor(mailed)
+	require.NotNil(t, first)
+	require.Len(t, first.Lines, 2)
+	require.True(t, first.Lines[0].Quantity.Equal(decimal.NewFromInt(2)), "duplicate lines merge")
+	record(mailed, entity.CheckoutRecoverySourceValidate, line(liveB, 1), line(drafted, 1))
+	rec := openFor(mailed)
+	require.Equal(t, first.Id, rec.Id, "one open checkout per address")
+	require.Equal(t, first.Token, rec.Token)
+	require.Len(t, rec.Lines, 2)
+	require.Equal(t, "CRA-"+token+"-2", rec.Lines[0].VariantSKU)
+	require.False(t, rec.Unsubscribed)
+	require.False(t, rec.PaymentPending)
+	record(unmailed, entity.CheckoutRecoverySourceValidate)
+	require.Nil(t, openFor(unmailed), "an empty cart records nothing")
+
+	// Steps are marked in order, once.
+	require.NoError(t, cr.MarkCheckoutRecoveryStepSent(ctx, rec.Id, 2, ""))
+	require.Equal(t, 0, openFor(mailed).StepsSent, "step 2 can't go before step 1")
+
+	// The code is stored before its email is queued, once, and stays hidden until the step is sent.
+	require.True(t, errors.Is(cr.SetCheckoutRecoveryIncentive(ctx, rec.Id, 2, "BACK"+token[:8]), sql.ErrNoRows), "step 2 is not due")
+	require.NoError(t, cr.SetCheckoutRecoveryIncentive(ctx, rec.Id, 1, "BACK"+token[:8]))
+	require.True(t, errors.Is(cr.SetCheckoutRecoveryIncentive(ctx, rec.Id, 1, "OTHER"+token[:8]), sql.ErrNoRows), "a retry reuses the stored code")
+	rec = openFor(mailed)
+	require.Equal(t, "BACK"+token[:8], rec.IncentiveCode.String)
+	require.Equal(t, int32(1), rec.IncentiveStep.Int32)
+	unsent, err := cr.RestoreCheckoutRecovery(ctx, rec.Token, 0)
+	require.NoError(t, err)
+	require.Empty(t, unsent.IncentiveCode, "a code whose email has not gone out is not handed back")
+	_, err = testDB.ExecContext(ctx, `UPDATE checkout_recovery SET restored_at = NULL WHERE id = ?`, rec.Id)
+	require.NoError(t, err)
+
+	require.NoError(t, cr.MarkCheckoutRecoveryStepSent(ctx, rec.Id, 1, "BACK"+token[:8]))
+	require.NoError(t, cr.MarkCheckoutRecoveryStepSent(ctx, rec.Id, 1, ""))
+	rec = openFor(mailed)
+	require.Equal(t, 1, rec.StepsSent)
+	require.Equal(t, "BACK"+token[:8], rec.IncentiveCode.String)
+
+	// Restore: only the lines still on sale; the first restore keeps its step.
+	restored, err := cr.RestoreCheckoutRecovery(ctx, rec.Token, 1)
+	require.NoError(t, err)
+	require.Equal(t, "EUR", restored.Currency)
+	require.Equal(t, "BACK"+token[:8], restored.IncentiveCode)
+	require.Len(t, restored.Lines, 1)
+	require.Equal(t, "CRA-"+token+"-2", restored.Lines[0].VariantSKU)
+	_, err = cr.RestoreCheckoutRecovery(ctx, rec.Token, 3)
+	require.NoError(t, err)
+	var restoredStep sql.NullInt32
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT restored_step FROM checkout_recovery WHERE id = ?`, rec.Id).Scan(&restoredStep))
+	require.Equal(t, int32(1), restoredStep.Int32)
+	_, err = cr.RestoreCheckoutRecovery(ctx, strings.Repeat("0", 32), 1)
+	require.True(t, errors.Is(err, sql.ErrNoRows))
+
+	// Stop link.
+	record(stopped, entity.CheckoutRecoverySourceValidate, line(liveA, 1))
+	stop := openFor(stopped)
+	require.NotNil(t, stop)
+	require.NoError(t, cr.OptOutCheckoutRecovery(ctx, stop.Token))
+	require.Nil(t, openFor(stopped))
+	require.NoError(t, cr.OptOutCheckoutRecovery(ctx, stop.Token), "stopping twice succeeds")
+	require.True(t, errors.Is(cr.OptOutCheckoutRecovery(ctx, strings.Repeat("0", 32)), sql.ErrNoRows))
+
+	// Purchases: the mailed checkout converts, the unmailed one is suppressed.
+	record(unmailed, entity.CheckoutRecoverySourceSubmit, line(liveA, 1))
+	paid := func(email string, total int) {
+		id := exec(`INSERT INTO customer_order (uuid, order_status_id, currency, total_price, total_settled_base, vat_amount, placed)
+			VALUES (?, ?, 'EUR', ?, ?, 0, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL 1 SECOND))`,
+			fmt.Sprintf("cr-%s-%d", token, len(orders)), confirmed.Status.Id, total, total)
+		orders = append(orders, id)
+		exec(`INSERT INTO buyer (order_id, first_name, last_name, email, phone, billing_address_id, shipping_address_id)
+			VALUES (?, 'Test', 'Buyer', ?, '1234567', ?, ?)`, id, email, addrID, addrID)
+	}
+	paid(mailed, 120)
+	paid(unmailed, 80)
+	_, _, err = cr.ClosePurchasedCheckoutRecoveries(ctx)
+	require.NoError(t, err)
+	require.Nil(t, openFor(mailed))
+	require.Nil(t, openFor(unmailed))
+	var st string
+	var attributed sql.NullInt32
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT status, attributed_step FROM checkout_recovery WHERE id = ?`, rec.Id).Scan(&st, &attributed))
+	require.Equal(t, string(entity.CheckoutRecoveryConverted), st)
+	require.Equal(t, int32(1), attributed.Int32)
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT status FROM checkout_recovery WHERE email = ?`, unmailed).Scan(&st))
+	require.Equal(t, string(entity.CheckoutRecoverySuppressed), st)
+
+	// Report, over a window only these checkouts are in.
+	from := time.Date(2037, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err = testDB.ExecContext(ctx, `UPDATE checkout_recovery SET created_at = ? WHERE email IN (?, ?, ?)`, from.Add(time.Hour), mailed, unmailed, stopped)
+	require.NoError(t, err)
+	m, err := cr.GetCheckoutRecoveryMetrics(ctx, from, from.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 3, m.Recorded)
+	require.Equal(t, 1, m.Converted)
+	require.Equal(t, 1, m.Suppressed)
+	require.Equal(t, 1, m.OptedOut)
+	require.True(t, m.Revenue.Equal(decimal.NewFromInt(120)), m.Revenue.String())
+	require.Len(t, m.Steps, 1)
+	require.Equal(t, entity.CheckoutRecoveryStepMetrics{
+		Step: 1, Sent: 1, Restored: 1, Conversions: 1, Revenue: m.Steps[0].Revenue, IncentivesSent: 1,
+	}, m.Steps[0])
+	require.True(t, m.Steps[0].Revenue.Equal(decimal.NewFromInt(120)))
+
+	// Erasing the account removes the checkouts of its address.
+	require.NoError(t, cr.RecordAbandonedCheckout(ctx, &entity.CheckoutRecoveryCart{
+		Email: acc.Email, Currency: "EUR", Source: entity.CheckoutRecoverySourceValidate, Lines: []entity.CheckoutRecoveryLine{line(liveA, 1)},
+	}))
+	require.NotNil(t, openFor(acc.Email))
+	require.NoError(t, s.Membership().HardEraseAccount(ctx, acc.ID))
+	var left int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM checkout_recovery WHERE email = ?`, acc.Email).Scan(&left))
+	require.Zero(t, left)
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/stocklocation"
 	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
 )
 
@@ -46,6 +47,8 @@ type boardRow struct {
 	Notes          string                 `db:"notes"`
 	ChecklistTotal int                    `db:"checklist_total"`
 	ChecklistDone  int                    `db:"checklist_done"`
+	LocationId     int                    `db:"location_id"`
+	LocationName   string                 `db:"location_name"`
 }
 
 // boardSelect is the shared projection + annotation join. The caller appends the
@@ -55,10 +58,13 @@ const boardSelect = `
 	       COALESCE(f.assignee, '') AS assignee,
 	       COALESCE(f.notes, '') AS notes,
 	       COALESCE(cl.total, 0) AS checklist_total,
-	       COALESCE(cl.done, 0) AS checklist_done
+	       COALESCE(cl.done, 0) AS checklist_done,
+	       COALESCE(f.location_id, 0) AS location_id,
+	       COALESCE(sl.name, '') AS location_name
 	FROM customer_order o
 	JOIN order_status os ON os.id = o.order_status_id
 	LEFT JOIN order_fulfillment f ON f.order_uuid = o.uuid
+	LEFT JOIN stock_location sl ON sl.id = f.location_id
 	LEFT JOIN (
 		SELECT order_fulfillment_id, COUNT(*) AS total,
 		       CAST(COALESCE(SUM(is_done), 0) AS SIGNED) AS done
@@ -125,6 +131,8 @@ func rowToCard(r *boardRow) entity.FulfillmentCard {
 		ChecklistDone:  r.ChecklistDone,
 		ChecklistTotal: r.ChecklistTotal,
 		HasNotes:       strings.TrimSpace(r.Notes) != "",
+		LocationId:     r.LocationId,
+		LocationName:   r.LocationName,
 	}
 }
 
@@ -132,7 +140,7 @@ func rowToCard(r *boardRow) entity.FulfillmentCard {
 // (nil, nil) when the order has no annotation yet.
 func (s *Store) GetOrderFulfillment(ctx context.Context, orderUUID string) (*entity.OrderFulfillment, error) {
 	f, err := storeutil.QueryNamedOne[entity.OrderFulfillment](ctx, s.DB,
-		`SELECT id, order_uuid, assignee, notes, created_by, created_at, updated_at
+		`SELECT id, order_uuid, assignee, notes, location_id, created_by, created_at, updated_at
 		 FROM order_fulfillment WHERE order_uuid = :uuid`, map[string]any{"uuid": orderUUID})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -259,6 +267,28 @@ func ensureFulfillment(ctx context.Context, db dependency.DB, orderUUID, created
 	return id, nil
 }
 
+// AllocateOrderFulfillment allocates a paid order to the stock location it is packed from (0345):
+// locationID, or, when 0, the first location holding the whole order. It returns the location.
+func (s *Store) AllocateOrderFulfillment(ctx context.Context, orderUUID string, locationID int, username string) (*entity.StockLocation, error) {
+	var loc *entity.StockLocation
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		orderID, err := storeutil.QueryCountNamed(ctx, rep.DB(),
+			`SELECT COALESCE(MAX(id), 0) FROM customer_order WHERE uuid = :uuid`, map[string]any{"uuid": orderUUID})
+		if err != nil {
+			return fmt.Errorf("can't resolve order: %w", err)
+		}
+		if orderID == 0 {
+			return fmt.Errorf("%w: order %s not found", entity.ErrOrderNotAllocatable, orderUUID)
+		}
+		loc, err = stocklocation.AllocateOrderInTx(ctx, rep, orderID, locationID, username)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
 func nullString(s string) sql.NullString {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// Consignment to a subcontractor (0344). A kit shipped to a factory leaves our shelf but not our
+// books: consign_out takes it off on_hand at the moving average, consign_return brings a leftover
+// back at what it left at, and neither touches the run's WIP. What the factory used becomes WIP
+// only at reconciliation (SettleConsignmentInTx) — an ordinary issue_production that moves no
+// stock, because the stock already moved when the kit shipped.
+//
+// These are *InTx helpers with no Store method of their own: a kit is one shipment of several
+// materials, and it commits together with the subcontract checks in internal/store/productionrun.
+
+// ConsignmentBalance is what a run's factory holds of one material: the quantity and the costed
+// part of it, the latter pricing a return or the settlement.
+type ConsignmentBalance struct {
+	Qty       decimal.Decimal
+	CostedQty decimal.Decimal
+	CostedVal decimal.Decimal
+}
+
+// UnitCost is the average the balance left the shelf at, or invalid when some of it left unpriced —
+// the same honesty rule as a run return (g25-14): a price over part of the quantity would mint value.
+func (b ConsignmentBalance) UnitCost(qty decimal.Decimal) decimal.NullDecimal {
+	if b.CostedQty.GreaterThanOrEqual(qty) && b.CostedQty.IsPositive() && b.CostedVal.IsPositive() {
+		return decimal.NullDecimal{Decimal: b.CostedVal.Div(b.CostedQty).Round(avgScale), Valid: true}
+	}
+	return decimal.NullDecimal{}
+}
+
+// ReadConsignmentBalance sums a material's consign_out minus consign_return for a run.
+func ReadConsignmentBalance(ctx context.Context, db dependency.DB, runID, materialID int) (ConsignmentBalance, error) {
+	row, err := storeutil.QueryNamedOne[struct {
+		Qty       decimal.Decimal `db:"qty"`
+		CostedQty decimal.Decimal `db:"costed_qty"`
+		CostedVal decimal.Decimal `db:"costed_val"`
+	}](ctx, db, `
+		SELECT
+			COALESCE(SUM(CASE movement_type WHEN :out THEN quantity WHEN :ret THEN -quantity ELSE 0 END), 0) AS qty,
+			COALESCE(SUM(CASE WHEN unit_cost_base IS NULL THEN 0
+			                  WHEN movement_type = :out THEN quantity
+			                  WHEN movement_type = :ret THEN -quantity ELSE 0 END), 0) AS costed_qty,
+			COALESCE(SUM(CASE WHEN unit_cost_base IS NULL THEN 0
+			                  WHEN movement_type = :out THEN quantity * unit_cost_base
+			                  WHEN movement_type = :ret THEN -quantity * unit_cost_base ELSE 0 END), 0) AS costed_val
+		FROM material_stock_movement
+		WHERE production_run_id = :run AND material_id = :m AND movement_type IN (:out, :ret)`,
+		map[string]any{
+			"run": runID,
+			"m":   materialID,
+			"out": string(entity.MaterialMovementConsignOut),
+			"ret": string(entity.MaterialMovementConsignReturn),
+		})
+	if err != nil {
+		return ConsignmentBalance{}, fmt.Errorf("read consignment balance of material %d on run %d: %w", materialID, runID, err)
+	}
+	return ConsignmentBalance{Qty: row.Qty, CostedQty: row.CostedQty, CostedVal: row.CostedVal}, nil
+}
+
+// ConsignOutInTx ships qty of a material to the run's factory. Guarded like an issue: the run must
+// be open, the material live and on the shelf. The run's reservation is consumed exactly as by an
+// issue — from the shelf's point of view the cloth is gone either way.
+func ConsignOutInTx(ctx context.Context, db dependency.DB, runID, supplierID int, in entity.SubcontractKitInput, mv entity.SubcontractKitMove) (entity.MaterialMovement, error) {
+	if !in.Quantity.IsPositive() {
+		return entity.MaterialMovement{}, fmt.Errorf("kit quantity must be positive")
+	}
+	meta, err := readMaterialMeta(ctx, db, in.MaterialId)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	if meta.Archived {
+		return entity.MaterialMovement{}, entity.ErrMaterialArchived
+	}
+	if err := checkRunOpen(ctx, db, runID); err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	ownerTC, err := techCardIdOfTarget(ctx, db, "production_run", runID)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	before, err := readStockForUpdate(ctx, db, in.MaterialId)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	if before.OnHand.LessThan(in.Quantity) {
+		return entity.MaterialMovement{}, fmt.Errorf("%w: material %d has %s available", entity.ErrInsufficientMaterialStock, in.MaterialId, before.OnHand.String())
+	}
+	newOnHand := before.OnHand.Sub(in.Quantity)
+	if err := upsertStock(ctx, db, in.MaterialId, newOnHand, before.Avg); err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	if err := ConsumeRunReservationInTx(ctx, db, runID, in.MaterialId, in.Quantity, mv.Username); err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	return insertMovement(ctx, db, consignMovement(entity.MaterialMovementConsignOut, runID, supplierID, ownerTC,
+		in, before.OnHand, newOnHand, before.Avg, mv))
+}
+
+// ConsignReturnInTx takes a leftover back from the run's factory, capped at what the factory holds
+// and priced at what it left at; the value is blended back into the moving average like a run
+// return (NF-01). An archived material can still come back — refusing it would leave it on a
+// foreign floor forever.
+func ConsignReturnInTx(ctx context.Context, db dependency.DB, runID, supplierID int, in entity.SubcontractKitInput, mv entity.SubcontractKitMove) (entity.MaterialMovement, error) {
+	if !in.Quantity.IsPositive() {
+		return entity.MaterialMovement{}, fmt.Errorf("kit quantity must be positive")
+	}
+	if _, err := readMaterialMeta(ctx, db, in.MaterialId); err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	ownerTC, err := techCardIdOfTarget(ctx, db, "production_run", runID)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	bal, err := ReadConsignmentBalance(ctx, db, runID, in.MaterialId)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	if in.Quantity.GreaterThan(bal.Qty) {
+		return entity.MaterialMovement{}, fmt.Errorf("%w: material %d has %s at the factory", entity.ErrExcessiveConsignReturn, in.MaterialId, bal.Qty.String())
+	}
+	before, err := readStockForUpdate(ctx, db, in.MaterialId)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	cost := bal.UnitCost(in.Quantity)
+	newOnHand := before.OnHand.Add(in.Quantity)
+	if err := upsertStock(ctx, db, in.MaterialId, newOnHand, blendIntoAvg(before, in.Quantity, cost)); err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	return insertMovement(ctx, db, consignMovement(entity.MaterialMovementConsignReturn, runID, supplierID, ownerTC,
+		in, before.OnHand, newOnHand, cost, mv))
+}
+
+// SettleConsignmentInTx books what the factory kept of a material into the run: an issue_production
+// of qty at the consignment price, tagged with the factory, with on_hand unchanged — the shelf lost
+// the cloth when the kit shipped. The stock row is still locked so the before/after pair on the
+// movement is a real reading, not a guess.
+func SettleConsignmentInTx(ctx context.Context, db dependency.DB, runID, supplierID, materialID int, qty decimal.Decimal, cost decimal.NullDecimal, username string, now time.Time) (entity.MaterialMovement, error) {
+	ownerTC, err := techCardIdOfTarget(ctx, db, "production_run", runID)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	before, err := readStockForUpdate(ctx, db, materialID)
+	if err != nil {
+		return entity.MaterialMovement{}, err
+	}
+	in := entity.SubcontractKitInput{MaterialId: materialID, Quantity: qty}
+	mv := entity.SubcontractKitMove{
+		OccurredAt: sql.NullTime{Time: now, Valid: true},
+		Comment:    sql.NullString{String: "subcontract reconciliation", Valid: true},
+		Username:   username,
+	}
+	return insertMovement(ctx, db, consignMovement(entity.MaterialMovementIssueProduction, runID, supplierID, ownerTC,
+		in, before.OnHand, before.OnHand, cost, mv))
+}
+
+func consignMovement(t entity.MaterialMovementType, runID, supplierID int, ownerTC sql.NullInt32, in entity.SubcontractKitInput,
+	before, after decimal.Decimal, cost decimal.NullDecimal, mv entity.SubcontractKitMove) entity.MaterialMovement {
+	m := entity.MaterialMovement{
+		MaterialId:      in.MaterialId,
+		MovementType:    t,
+		Quantity:        in.Quantity,
+		OnHandBefore:    before,
+		OnHandAfter:     after,
+		UnitCostBase:    cost,
+		ProductionRunId: sql.NullInt32{Int32: int32(runID), Valid: true},
+		TechCardId:      ownerTC,
+		SupplierId:      sql.NullInt32{Int32: int32(supplierID), Valid: true},
+		Comment:         mv.Comment,
+		AdminUsername:   mv.Username,
+		OccurredAt:      mv.OccurredAt,
+	}
+	if cost.Valid {
+		m.Currency = sql.NullString{String: strings.ToUpper(cache.GetBaseCurrency()), Valid: true}
+	}
+	return m
+}
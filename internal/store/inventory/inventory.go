@@ -83,37 +83,38 @@ func insertMovement(ctx context.Context, db dependency.DB, m entity.MaterialMove
 		INSERT INTO material_stock_movement
 			(material_id, movement_type, quantity, on_hand_before, on_hand_after,
 			 unit_cost, currency, unit_cost_base, production_run_id, sample_id, tech_card_id, product_id,
-			 lot, lot_id, supplier_doc, supplier_id, expected_at, reason, comment, admin_username, occurred_at,
-			 input_vat_amount, input_vat_regime)
+			 lot, lot_id, supplier_doc, supplier_id, expected_at, purchase_order_line_id, reason, comment,
+			 admin_username, occurred_at, input_vat_amount, input_vat_regime)
 		VALUES
 			(:material_id, :movement_type, :quantity, :on_hand_before, :on_hand_after,
 			 :unit_cost, :currency, :unit_cost_base, :production_run_id, :sample_id, :tech_card_id, :product_id,
-			 :lot, :lot_id, :supplier_doc, :supplier_id, :expected_at, :reason, :comment, :admin_username, :occurred_at,
-			 :input_vat_amount, :input_vat_regime)`,
+			 :lot, :lot_id, :supplier_doc, :supplier_id, :expected_at, :purchase_order_line_id, :reason, :comment,
+			 :admin_username, :occurred_at, :input_vat_amount, :input_vat_regime)`,
 		map[string]any{
-			"material_id":       m.MaterialId,
-			"movement_type":     string(m.MovementType),
-			"quantity":          m.Quantity.Round(qtyScale),
-			"on_hand_before":    m.OnHandBefore.Round(qtyScale),
-			"on_hand_after":     m.OnHandAfter.Round(qtyScale),
-			"unit_cost":         nullDecimal(m.UnitCost),
-			"currency":          m.Currency,
-			"unit_cost_base":    nullDecimal(m.UnitCostBase),
-			"production_run_id": m.ProductionRunId,
-			"sample_id":         m.SampleId,
-			"tech_card_id":      m.TechCardId,
-			"product_id":        m.ProductId,
-			"lot":               m.Lot,
-			"lot_id":            m.LotId,
-			"supplier_doc":      m.SupplierDoc,
-			"supplier_id":       m.SupplierId,
-			"expected_at":       m.ExpectedAt,
-			"reason":            m.Reason,
-			"comment":           m.Comment,
-			"admin_username":    m.AdminUsername,
-			"occurred_at":       m.OccurredAt,
-			"input_vat_amount":  nullDecimal(m.InputVatAmount),
-			"input_vat_regime":  m.InputVatRegime,
+			"material_id":            m.MaterialId,
+			"movement_type":          string(m.MovementType),
+			"quantity":               m.Quantity.Round(qtyScale),
+			"on_hand_before":         m.OnHandBefore.Round(qtyScale),
+			"on_hand_after":          m.OnHandAfter.Round(qtyScale),
+			"unit_cost":              nullDecimal(m.UnitCost),
+			"currency":               m.Currency,
+			"unit_cost_base":         nullDecimal(m.UnitCostBase),
+			"production_run_id":      m.ProductionRunId,
+			"sample_id":              m.SampleId,
+			"tech_card_id":           m.TechCardId,
+			"product_id":             m.ProductId,
+			"lot":                    m.Lot,
+			"lot_id":                 m.LotId,
+			"supplier_doc":           m.SupplierDoc,
+			"supplier_id":            m.SupplierId,
+			"expected_at":            m.ExpectedAt,
+			"purchase_order_line_id": m.PurchaseOrderLineId,
+			"reason":                 m.Reason,
+			"comment":                m.Comment,
+			"admin_username":         m.AdminUsername,
+			"occurred_at":            m.OccurredAt,
+			"input_vat_amount":       nullDecimal(m.InputVatAmount),
+			"input_vat_regime":       m.InputVatRegime,
 		})
 	if err != nil {
 		return entity.MaterialMovement{}, fmt.Errorf("insert material movement: %w", err)
@@ -327,22 +328,23 @@ func ReceiveInTx(ctx context.Context, rep dependency.Repository, ins entity.Mate
 		mvType = entity.MaterialMovementReceiptProduction
 	}
 	m := entity.MaterialMovement{
-		MaterialId:      ins.MaterialId,
-		MovementType:    mvType,
-		Quantity:        ins.Quantity,
-		OnHandBefore:    before.OnHand,
-		OnHandAfter:     newOnHand,
-		UnitCost:        ins.UnitCost,
-		UnitCostBase:    unitCostBase,
-		ProductionRunId: ins.ProductionRunId,
-		Lot:             ins.Lot,
-		SupplierDoc:     ins.SupplierDoc,
-		ExpectedAt:      ins.ExpectedAt,
-		Comment:         ins.Comment,
-		AdminUsername:   ins.AdminUsername,
-		OccurredAt:      ins.OccurredAt,
-		InputVatAmount:  ins.InputVatAmount,
-		InputVatRegime:  ins.InputVatRegime,
+		MaterialId:          ins.MaterialId,
+		MovementType:        mvType,
+		Quantity:            ins.Quantity,
+		OnHandBefore:        before.OnHand,
+		OnHandAfter:         newOnHand,
+		UnitCost:            ins.UnitCost,
+		UnitCostBase:        unitCostBase,
+		ProductionRunId:     ins.ProductionRunId,
+		Lot:                 ins.Lot,
+		SupplierDoc:         ins.SupplierDoc,
+		ExpectedAt:          ins.ExpectedAt,
+		PurchaseOrderLineId: ins.PurchaseOrderLineId,
+		Comment:             ins.Comment,
+		AdminUsername:       ins.AdminUsername,
+		OccurredAt:          ins.OccurredAt,
+		InputVatAmount:      ins.InputVatAmount,
+		InputVatRegime:      ins.InputVatRegime,
 	}
 	// A catalogued supplier tags a purchase receipt only (an auxiliary-run receipt_production has no
 	// supplier); it flows onto the M1 journal entry for the AP-by-supplier view (phase 2, wave 4).
@@ -714,6 +716,10 @@ func (s *Store) ListMaterialMovements(ctx context.Context, limit, offset int, fi
 		where += " AND movement_type = :movement_type"
 		params["movement_type"] = string(filter.MovementType)
 	}
+	if filter.PurchaseOrderId > 0 {
+		where += " AND purchase_order_line_id IN (SELECT id FROM purchase_order_line WHERE purchase_order_id = :purchase_order_id)"
+		params["purchase_order_id"] = filter.PurchaseOrderId
+	}
 	// Inclusive occurred_at date window (B-5): compare on DATE(occurred_at) so a plain YYYY-MM-DD
 	// upper bound includes movements stamped any time that day.
 	if filter.OccurredFrom != "" {
@@ -734,7 +740,7 @@ func (s *Store) ListMaterialMovements(ctx context.Context, limit, offset int, fi
 	rows, err := storeutil.QueryListNamed[entity.MaterialMovement](ctx, s.DB, fmt.Sprintf(`
 		SELECT id, material_id, movement_type, quantity, on_hand_before, on_hand_after,
 			unit_cost, currency, unit_cost_base, production_run_id, sample_id, tech_card_id, product_id,
-			lot, lot_id, supplier_doc, reason, comment, admin_username, occurred_at, created_at
+			lot, lot_id, supplier_doc, purchase_order_line_id, reason, comment, admin_username, occurred_at, created_at
 		FROM material_stock_movement WHERE 1=1%s
 		ORDER BY id DESC LIMIT :limit OFFSET :offset`, where), params)
 	if err != nil {
@@ -750,11 +756,17 @@ type materialStockRow struct {
 	entity.Material
 	MinStock decimal.NullDecimal `db:"min_stock"`
 	OnHand   decimal.NullDecimal `db:"on_hand"`
+	Total    decimal.NullDecimal `db:"total_on_hand"`
 	Avg      decimal.NullDecimal `db:"avg_unit_cost_base"`
 }
 
 // ListMaterialStock returns catalog materials joined with their stock balance, valuation and
 // low-stock flag, matching the filter. Archived materials are excluded. Ordered by section, name.
+//
+// Under a location filter on_hand is the stock in that location (0345): the stored row of a
+// material_location_stock location, or, for the default location, the total minus every other
+// location and what is on the road (entity.DefaultLocationQty). The valuation follows the location's
+// quantity; the low-stock flag stays on the total.
 func (s *Store) ListMaterialStock(ctx context.Context, filter entity.MaterialStockFilter) ([]entity.MaterialStockRow, error) {
 	params := map[string]any{
 		"section": strings.ToLower(strings.TrimSpace(filter.Section)),
@@ -762,39 +774,58 @@ func (s *Store) ListMaterialStock(ctx context.Context, filter entity.MaterialSto
 		"hasQ":    strings.TrimSpace(filter.Query) != "",
 		"withStk": filter.WithStockOnly,
 		"belowMn": filter.BelowMinOnly,
+		"locId":   filter.LocationId,
 	}
 	rows, err := storeutil.QueryListNamed[materialStockRow](ctx, s.DB, `
-		SELECT m.*, s.on_hand AS on_hand, s.avg_unit_cost_base AS avg_unit_cost_base
-		FROM material m
-		LEFT JOIN material_stock s ON s.material_id = m.id
-		WHERE m.archived = FALSE
-			AND (:section = '' OR m.section = :section)
-			AND (NOT :hasQ OR m.name LIKE :q OR m.code LIKE :q OR m.supplier_ref LIKE :q)
-			AND (NOT :withStk OR COALESCE(s.on_hand, 0) > 0)
-			AND (NOT :belowMn OR (m.min_stock IS NOT NULL AND COALESCE(s.on_hand, 0) < m.min_stock))
-		ORDER BY m.section, m.name`, params)
+		SELECT * FROM (
+			SELECT m.*, s.on_hand AS total_on_hand, s.avg_unit_cost_base AS avg_unit_cost_base,
+				CASE
+					WHEN :locId = 0 THEN s.on_hand
+					WHEN EXISTS (SELECT 1 FROM stock_location sl WHERE sl.id = :locId AND sl.is_default) THEN
+						COALESCE(s.on_hand, 0)
+						- COALESCE((SELECT SUM(mls.quantity) FROM material_location_stock mls
+						            WHERE mls.material_id = m.id), 0)
+						- COALESCE((SELECT SUM(stl.quantity) FROM stock_transfer_line stl
+						            JOIN stock_transfer st ON st.id = stl.transfer_id
+						            WHERE stl.material_id = m.id AND st.status = 'in_transit'), 0)
+					ELSE COALESCE((SELECT mls.quantity FROM material_location_stock mls
+					               WHERE mls.material_id = m.id AND mls.location_id = :locId), 0)
+				END AS on_hand
+			FROM material m
+			LEFT JOIN material_stock s ON s.material_id = m.id
+			WHERE m.archived = FALSE
+				AND (:section = '' OR m.section = :section)
+				AND (NOT :hasQ OR m.name LIKE :q OR m.code LIKE :q OR m.supplier_ref LIKE :q)
+		) t
+		WHERE (NOT :withStk OR COALESCE(t.on_hand, 0) > 0)
+			AND (NOT :belowMn OR (t.min_stock IS NOT NULL AND COALESCE(t.total_on_hand, 0) < t.min_stock))
+		ORDER BY t.section, t.name`, params)
 	if err != nil {
 		return nil, fmt.Errorf("list material stock: %w", err)
 	}
 	out := make([]entity.MaterialStockRow, len(rows))
 	for i, r := range rows {
-		onHand := decimal.Zero
+		onHand, total := decimal.Zero, decimal.Zero
 		if r.OnHand.Valid {
 			onHand = r.OnHand.Decimal
 		}
+		if r.Total.Valid {
+			total = r.Total.Decimal
+		}
 		// sqlx maps the min_stock column to the shallower materialStockRow.MinStock, leaving the
 		// nested Material.MinStock unset; copy it so a client reading the material sees its threshold.
 		r.Material.MinStock = r.MinStock
 		row := entity.MaterialStockRow{
 			Material:        r.Material,
 			OnHand:          onHand,
+			TotalOnHand:     total,
 			AvgUnitCostBase: r.Avg,
 			MinStock:        r.MinStock,
 		}
 		if r.Avg.Valid {
 			row.StockValueBase = decimal.NullDecimal{Decimal: onHand.Mul(r.Avg.Decimal).Round(2), Valid: true}
 		}
-		if r.MinStock.Valid && onHand.LessThan(r.MinStock.Decimal) {
+		if r.MinStock.Valid && total.LessThan(r.MinStock.Decimal) {
 			row.BelowMinStock = true
 		}
 		out[i] = row
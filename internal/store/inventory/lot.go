@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
@@ -111,7 +112,8 @@ func (s *Store) ListMaterialLots(ctx context.Context, materialID int, includeArc
 	}
 	rows, err := storeutil.QueryListNamed[entity.MaterialLot](ctx, s.DB, `
 		SELECT id, material_id, lot_code, supplier_doc, received_qty, remaining_qty, unit_cost,
-		       currency, received_at, note, archived, measured_width_cm, shade_code
+		       currency, received_at, note, archived, measured_width_cm, shade_code,
+		       shade_band, shrinkage_warp_pct, shrinkage_weft_pct, measured_gsm, lab_tested_at
 		FROM material_lot `+where+`
 		ORDER BY received_at DESC, id DESC`, map[string]any{"m": materialID})
 	if err != nil {
@@ -120,6 +122,94 @@ func (s *Store) ListMaterialLots(ctx context.Context, materialID int, includeArc
 	return rows, nil
 }
 
+// RecordMaterialLotLabTest appends one lab result to the lot's history (0348) and, when it is not
+// older than the lot's current result, makes it the current one — in one transaction, so the lot
+// never shows a result the history does not have. The lot row is locked first: two results
+// recorded at once must not leave the lot holding the older of the two.
+//
+// A result only REPLACES what it measured. A wash test recorded after the shade sorting does not
+// clear the band, because it says nothing about the band; the lot's current result is the latest
+// value of each measurement, not the latest row.
+func (s *Store) RecordMaterialLotLabTest(ctx context.Context, ins entity.MaterialLotLabTestInsert, username string) (entity.MaterialLotLabTest, error) {
+	if err := entity.ValidateMaterialLotLabTest(&ins); err != nil {
+		return entity.MaterialLotLabTest{}, err
+	}
+	testedAt := time.Now().UTC()
+	if ins.TestedAt.Valid {
+		testedAt = ins.TestedAt.Time.UTC()
+	}
+	var out entity.MaterialLotLabTest
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		lot, err := storeutil.QueryNamedOne[struct {
+			Id          int          `db:"id"`
+			LabTestedAt sql.NullTime `db:"lab_tested_at"`
+		}](ctx, db, `SELECT id, lab_tested_at FROM material_lot WHERE id = :id FOR UPDATE`,
+			map[string]any{"id": ins.LotId})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			return fmt.Errorf("lock material lot %d: %w", ins.LotId, err)
+		}
+		params := map[string]any{
+			"lot_id":    ins.LotId,
+			"band":      ins.ShadeBand,
+			"warp":      ins.ShrinkageWarpPct,
+			"weft":      ins.ShrinkageWeftPct,
+			"gsm":       ins.MeasuredGsm,
+			"note":      trimmedNull(ins.Note),
+			"tested_at": testedAt,
+			"by":        username,
+		}
+		id, err := storeutil.ExecNamedLastId(ctx, db, `
+			INSERT INTO material_lot_lab_test
+				(lot_id, shade_band, shrinkage_warp_pct, shrinkage_weft_pct, measured_gsm, note, tested_at, tested_by)
+			VALUES (:lot_id, :band, :warp, :weft, :gsm, :note, :tested_at, :by)`, params)
+		if err != nil {
+			return fmt.Errorf("insert lab test for lot %d: %w", ins.LotId, err)
+		}
+		if !lot.LabTestedAt.Valid || !testedAt.Before(lot.LabTestedAt.Time) {
+			if err := storeutil.ExecNamed(ctx, db, `
+				UPDATE material_lot SET
+					shade_band = COALESCE(:band, shade_band),
+					shrinkage_warp_pct = COALESCE(:warp, shrinkage_warp_pct),
+					shrinkage_weft_pct = COALESCE(:weft, shrinkage_weft_pct),
+					measured_gsm = COALESCE(:gsm, measured_gsm),
+					lab_tested_at = :tested_at
+				WHERE id = :lot_id`, params); err != nil {
+				return fmt.Errorf("update current lab result of lot %d: %w", ins.LotId, err)
+			}
+		}
+		out, err = storeutil.QueryNamedOne[entity.MaterialLotLabTest](ctx, db, `
+			SELECT id, lot_id, shade_band, shrinkage_warp_pct, shrinkage_weft_pct, measured_gsm, note,
+			       tested_at, tested_by, created_at
+			FROM material_lot_lab_test WHERE id = :id`, map[string]any{"id": id})
+		if err != nil {
+			return fmt.Errorf("read back lab test %d: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.MaterialLotLabTest{}, err
+	}
+	return out, nil
+}
+
+// ListMaterialLotLabTests returns a lot's lab history, newest first.
+func (s *Store) ListMaterialLotLabTests(ctx context.Context, lotID int) ([]entity.MaterialLotLabTest, error) {
+	rows, err := storeutil.QueryListNamed[entity.MaterialLotLabTest](ctx, s.DB, `
+		SELECT id, lot_id, shade_band, shrinkage_warp_pct, shrinkage_weft_pct, measured_gsm, note,
+		       tested_at, tested_by, created_at
+		FROM material_lot_lab_test
+		WHERE lot_id = :lot
+		ORDER BY tested_at DESC, id DESC`, map[string]any{"lot": lotID})
+	if err != nil {
+		return nil, fmt.Errorf("list lab tests of lot %d: %w", lotID, err)
+	}
+	return rows, nil
+}
+
 // NarrowestMeasuredLotWidths returns, per material, the NARROWEST measured roll width among the lots
 // that still have stock (Ф5а.1 / Ф6.2). Materials with no measured, non-empty, non-archived lot are
 // simply absent from the map — «nobody measured it» is not «it matches the nominal», and an absent
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaterialLotLabTests covers 0348: a lab result lands in the history AND becomes the lot's
+// current one; a back-dated result stays in the history without overwriting a newer one; a result
+// that omits a measurement keeps the lot's previous value for it.
+//
+// SAFE ONLY against a local container DSN — see the guard and mysql_test.go / project memory.
+func TestMaterialLotLabTests(t *testing.T) {
+	if os.Getenv("CI") == "" &&
+		!strings.Contains(testCfg.DSN, "127.0.0.1") &&
+		!strings.Contains(testCfg.DSN, "localhost") {
+		t.Skip("skipping outside CI unless the DSN targets a local container (avoids the configured prod DB)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	di, err := s.Cache().GetDictionaryInfo(ctx)
+	require.NoError(t, err)
+	hf, err := s.Hero().GetHero(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cache.InitConsts(ctx, di, hf))
+
+	nd := func(v string) decimal.NullDecimal {
+		return decimal.NullDecimal{Decimal: decimal.RequireFromString(v), Valid: true}
+	}
+	ns := func(v string) sql.NullString { return sql.NullString{String: v, Valid: true} }
+	MS := s.MaterialStock()
+
+	matID, err := s.TechCards().CreateMaterial(ctx, &entity.MaterialInsert{
+		Name: "Lab Lot Fabric", Section: "fabric", Unit: ns("m"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM material_stock_movement WHERE material_id = ?", matID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM material_lot WHERE material_id = ?", matID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM material WHERE id = ?", matID)
+	})
+
+	_, err = MS.ReceiveMaterialStock(ctx, entity.MaterialReceiptInsert{
+		MaterialId: matID, Quantity: decimal.NewFromInt(50), Lot: ns("LAB-1"),
+	})
+	require.NoError(t, err)
+	lot := func() entity.MaterialLot {
+		t.Helper()
+		lots, err := MS.ListMaterialLots(ctx, matID, false)
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		return lots[0]
+	}
+	lotID := lot().Id
+
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err = MS.RecordMaterialLotLabTest(ctx, entity.MaterialLotLabTestInsert{
+		LotId: lotID, ShadeBand: ns(" b "), ShrinkageWarpPct: nd("4.5"), ShrinkageWeftPct: nd("2"),
+		TestedAt: sql.NullTime{Time: now, Valid: true},
+	}, "lab")
+	require.NoError(t, err)
+	l := lot()
+	require.Equal(t, "B", l.ShadeBand.String, "the band is normalised")
+	require.True(t, l.ShrinkageWarpPct.Decimal.Equal(decimal.RequireFromString("4.5")))
+
+	// A re-test of the weight only keeps the band and the shrinkage.
+	_, err = MS.RecordMaterialLotLabTest(ctx, entity.MaterialLotLabTestInsert{
+		LotId: lotID, MeasuredGsm: nd("212"), TestedAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+	}, "lab")
+	require.NoError(t, err)
+	l = lot()
+	require.Equal(t, "B", l.ShadeBand.String)
+	require.True(t, l.MeasuredGsm.Decimal.Equal(decimal.NewFromInt(212)))
+
+	// A back-dated result goes into the history only.
+	_, err = MS.RecordMaterialLotLabTest(ctx, entity.MaterialLotLabTestInsert{
+		LotId: lotID, ShadeBand: ns("A"), TestedAt: sql.NullTime{Time: now.Add(-24 * time.Hour), Valid: true},
+	}, "lab")
+	require.NoError(t, err)
+	require.Equal(t, "B", lot().ShadeBand.String, "an older test must not replace the current band")
+
+	tests, err := MS.ListMaterialLotLabTests(ctx, lotID)
+	require.NoError(t, err)
+	require.Len(t, tests, 3)
+	require.True(t, tests[0].MeasuredGsm.Valid, "newest first")
+	require.Equal(t, "A", tests[2].ShadeBand.String)
+
+	_, err = MS.RecordMaterialLotLabTest(ctx, entity.MaterialLotLabTestInsert{LotId: 1 << 30, ShadeBand: ns("A")}, "lab")
+	require.True(t, errors.Is(err, sql.ErrNoRows), "an unknown lot is not found, got %v", err)
+}
@@ -512,6 +512,13 @@ func (s *Store) HardEraseAccount(ctx context.Context, accountID int) error {
 	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
 		db := rep.DB()
 		anon := fmt.Sprintf("erased+%d@deleted.invalid", accountID)
+		// Abandoned checkouts are keyed by email, so they go before the address is anonymised.
+		if err := storeutil.ExecNamed(ctx, db, `
+			DELETE cr FROM checkout_recovery cr
+			JOIN storefront_account sa ON sa.id = :id
+			WHERE cr.account_id = sa.id OR cr.email = sa.email`, map[string]any{"id": accountID}); err != nil {
+			return fmt.Errorf("erase abandoned checkouts: %w", err)
+		}
 		q := `
 			UPDATE storefront_account
 			SET email = :anon, first_name = '', last_name = '', birth_date = NULL, phone = NULL,
@@ -525,6 +532,15 @@ func (s *Store) HardEraseAccount(ctx context.Context, accountID int) error {
 		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM storefront_saved_address WHERE account_id = :id`, map[string]any{"id": accountID}); err != nil {
 			return fmt.Errorf("erase saved addresses: %w", err)
 		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM storefront_wishlist_item WHERE account_id = :id`, map[string]any{"id": accountID}); err != nil {
+			return fmt.Errorf("erase wishlist: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM storefront_fit_measurement WHERE account_id = :id`, map[string]any{"id": accountID}); err != nil {
+			return fmt.Errorf("erase fit measurements: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM storefront_fit_profile WHERE account_id = :id`, map[string]any{"id": accountID}); err != nil {
+			return fmt.Errorf("erase fit profile: %w", err)
+		}
 		if err := storeutil.ExecNamed(ctx, db, `UPDATE storefront_refresh_token SET revoked_at = CURRENT_TIMESTAMP WHERE account_id = :id AND revoked_at IS NULL`, map[string]any{"id": accountID}); err != nil {
 			return fmt.Errorf("revoke refresh tokens: %w", err)
 		}
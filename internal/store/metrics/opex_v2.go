@@ -261,6 +261,11 @@ func (s *Store) ListOpexRecurring(ctx context.Context, includeArchived bool) ([]
 // as-booked stay immutable) while a month left uncosted by a transient FX outage is repaired on the
 // next healthy tick (nf08-03). Returns the number of lines newly created (recosts don't count).
 // `upTo` is the current time (worker) or a fixed month (tests); it is snapped to the 1st of its month.
+//
+// A salary template of an employee paid by piece rate (0341) skips the months a pay period or a
+// payslip covers: the payslip is that month's salary, and booking the flat amount as well would
+// double it. Lines already booked before the period was set up are left alone — ApprovePayslip
+// refuses the month until one is deleted.
 func (s *Store) MaterializeOpexRecurring(ctx context.Context, upTo time.Time) (int, error) {
 	base := strings.ToUpper(cache.GetBaseCurrency())
 	upToMonth := firstOfMonthUTC(upTo)
@@ -276,6 +281,10 @@ func (s *Store) MaterializeOpexRecurring(ctx context.Context, upTo time.Time) (i
 	if err != nil {
 		return 0, fmt.Errorf("materialize opex: load templates: %w", err)
 	}
+	pieceRate, err := s.loadPieceRateMonths(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("materialize opex: %w", err)
+	}
 
 	created := 0
 	for _, t := range templates {
@@ -286,6 +295,9 @@ func (s *Store) MaterializeOpexRecurring(ctx context.Context, upTo time.Time) (i
 			}
 		}
 		for m := firstOfMonthUTC(t.ActiveFrom); !m.After(end); m = m.AddDate(0, 1, 0) {
+			if t.Category == "salaries" && t.EmployeeId.Valid && pieceRate.covers(int(t.EmployeeId.Int32), m) {
+				continue
+			}
 			amountBase := foldOpexToBaseAsOf(t.Amount, t.Currency, base, hist, lastDayOfMonth(m))
 			n, err := storeutil.ExecNamedRows(ctx, s.DB, `
 				INSERT INTO opex_line (month, category, label, amount, currency, amount_base, recurring_id, note)
@@ -316,6 +328,54 @@ func (s *Store) MaterializeOpexRecurring(ctx context.Context, upTo time.Time) (i
 	return created, nil
 }
 
+// pieceRateMonths is which employee-months piece-rate payroll pays (0341): those inside a pay period,
+// and those with a payslip (a period deleted after the payslip was computed still paid the month).
+type pieceRateMonths struct {
+	periods map[int][]entity.EmployeePayPeriodInsert
+	slips   map[int]map[time.Time]bool
+}
+
+func (s *Store) loadPieceRateMonths(ctx context.Context) (pieceRateMonths, error) {
+	periods, err := storeutil.QueryListNamed[entity.EmployeePayPeriodInsert](ctx, s.DB,
+		`SELECT employee_id, scheme, valid_from, valid_to, currency FROM employee_pay_period`, map[string]any{})
+	if err != nil {
+		return pieceRateMonths{}, fmt.Errorf("load pay periods: %w", err)
+	}
+	slips, err := storeutil.QueryListNamed[struct {
+		EmployeeId int       `db:"employee_id"`
+		Month      time.Time `db:"month"`
+	}](ctx, s.DB, `SELECT employee_id, month FROM payroll_payslip`, map[string]any{})
+	if err != nil {
+		return pieceRateMonths{}, fmt.Errorf("load payslip months: %w", err)
+	}
+	p := pieceRateMonths{
+		periods: make(map[int][]entity.EmployeePayPeriodInsert, len(periods)),
+		slips:   make(map[int]map[time.Time]bool, len(slips)),
+	}
+	for _, pp := range periods {
+		p.periods[pp.EmployeeId] = append(p.periods[pp.EmployeeId], pp)
+	}
+	for _, sl := range slips {
+		if p.slips[sl.EmployeeId] == nil {
+			p.slips[sl.EmployeeId] = map[time.Time]bool{}
+		}
+		p.slips[sl.EmployeeId][firstOfMonthUTC(sl.Month)] = true
+	}
+	return p, nil
+}
+
+func (p pieceRateMonths) covers(employeeID int, month time.Time) bool {
+	if p.slips[employeeID][month] {
+		return true
+	}
+	for _, pp := range p.periods[employeeID] {
+		if pp.Covers(month) {
+			return true
+		}
+	}
+	return false
+}
+
 // sqlNullMonth normalises an optional ActiveTo bound to the 1st of the month for storage.
 func sqlNullMonth(t sql.NullTime) any {
 	if !t.Valid {
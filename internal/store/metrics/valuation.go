@@ -151,7 +151,28 @@ func (s *Store) addMaterialValuation(ctx context.Context, out *entity.InventoryV
 	if err != nil {
 		return fmt.Errorf("get material valuation: %w", err)
 	}
-	out.RawMaterialsValue = raw.Value.Round(2)
+	// Kits at a subcontractor (0344) left the shelf but are still ours until the subcontract is
+	// reconciled, so they stay raw material: net consign_out − consign_return of every open
+	// subcontract, at the cost they left at. Reconciliation moves the rest into WIP below.
+	consigned, err := storeutil.QueryNamedOne[struct {
+		Value decimal.Decimal `db:"value"`
+	}](ctx, s.DB, `
+		SELECT COALESCE(SUM(
+			CASE mv.movement_type
+				WHEN :out THEN mv.quantity * COALESCE(mv.unit_cost_base, 0)
+				WHEN :ret THEN -mv.quantity * COALESCE(mv.unit_cost_base, 0)
+				ELSE 0 END), 0) AS value
+		FROM material_stock_movement mv
+		JOIN production_run_subcontract rs ON rs.run_id = mv.production_run_id
+		WHERE rs.reconciled_at IS NULL
+			AND mv.movement_type IN (:out, :ret)`, map[string]any{
+		"out": string(entity.MaterialMovementConsignOut),
+		"ret": string(entity.MaterialMovementConsignReturn),
+	})
+	if err != nil {
+		return fmt.Errorf("get consigned material valuation: %w", err)
+	}
+	out.RawMaterialsValue = raw.Value.Add(consigned.Value).Round(2)
 	out.RawMaterialsCount = raw.Count
 	out.RawUncostedCount = raw.Uncosted
 
@@ -52,21 +52,26 @@ func (s *Store) CreateOrder(ctx context.Context, orderNew *entity.OrderNew, rece
 
 		orderNew.Items = mergeOrderItems(orderNew.Items)
 
-		promo, ok := cache.GetPromoByCode(orderNew.PromoCode)
-		if !ok || !promo.IsAllowed() {
-			promo = entity.PromoCode{}
-		}
-		prId := sql.NullInt32{
-			Int32: int32(promo.Id),
-			Valid: promo.Id > 0,
-		}
-
 		validItems, _, err := txStore.validateOrderItemsInsert(ctx, orderNew.Items, orderNew.Currency)
 		if err != nil {
 			return fmt.Errorf("error while validating order items: %w", err)
 		}
 		validItemsInsert := entity.ConvertOrderItemToOrderItemInsert(validItems)
 
+		// The same evaluation ValidateOrderItemsInsert showed the storefront, now with the buyer's
+		// email for the per-customer caps. Codes that don't apply are dropped, as before.
+		bd, err := evaluateOrderPromos(ctx, rep, orderNew.EnteredPromoCodes(), validItems, orderNew.Currency, orderNew.Buyer.Email, orderNew.BuyerTier, 0)
+		if err != nil {
+			return err
+		}
+		if err := validateGiftCardLines(ctx, rep, validItemsInsert, bd.RejectedFor(entity.PromoRejectGiftCard), orderNew.StoreCredit); err != nil {
+			return err
+		}
+		prId := sql.NullInt32{
+			Int32: int32(bd.PrimaryPromoId()),
+			Valid: bd.PrimaryPromoId() > 0,
+		}
+
 		providers := entity.ConvertOrderItemInsertsToProductInfoProviders(validItemsInsert)
 		subtotal, err := calculateTotalAmount(providers, orderNew.Currency)
 		if err != nil {
@@ -86,12 +91,12 @@ func (s *Store) CreateOrder(ctx context.Context, orderNew *entity.OrderNew, rece
 				freeShipping = true
 			}
 		}
-		if promo.FreeShipping {
+		if bd.FreeShipping {
 			shipmentPrice = decimal.Zero
 			freeShipping = true
 		}
 
-		totalPrice := promo.SubtotalWithPromo(subtotal, shipmentPrice, dto.DecimalPlacesForCurrency(orderNew.Currency))
+		totalPrice, _ := bd.ApplyTo(subtotal, shipmentPrice, dto.DecimalPlacesForCurrency(orderNew.Currency))
 
 		order = &entity.Order{
 			TotalPrice:    totalPrice,
@@ -107,6 +112,21 @@ func (s *Store) CreateOrder(ctx context.Context, orderNew *entity.OrderNew, rece
 			return fmt.Errorf("error while inserting order details: %w", err)
 		}
 
+		if err := rep.Promo().RecordRedemptions(ctx, order.Id, orderNew.Buyer.Email, bd); err != nil {
+			return fmt.Errorf("error while recording promo redemptions: %w", err)
+		}
+		if err := updateOrderTotalPromo(ctx, txDB, order.Id, bd, subtotal, order.TotalPrice); err != nil {
+			return fmt.Errorf("error while snapshotting order promos: %w", err)
+		}
+
+		if !orderNew.StoreCredit.IsZero() {
+			applied, err := rep.StoreCredit().RedeemForOrder(ctx, order.Id, orderNew.StoreCredit, order.TotalPrice, order.Currency)
+			if err != nil {
+				return fmt.Errorf("error while applying store credit: %w", err)
+			}
+			order.StoreCreditApplied = applied
+		}
+
 		if receivePromo {
 			if err := s.handlePromoSubscription(ctx, rep, orderNew.Buyer.Email, &sendEmail); err != nil {
 				return fmt.Errorf("error while handling promotional subscription: %w", err)
@@ -263,3 +283,28 @@ func (s *Store) handlePromoSubscription(ctx context.Context, rep dependency.Repo
 
 	return nil
 }
+
+// validateGiftCardLines keeps gift cards out of discounting and out of store-credit tender: a promo
+// would sell credit below face value, and paying a gift card with credit would only move a balance
+// around outside the ledger's audit of who funded it.
+// promoOnGiftCard is set when the promo evaluation rejected the entered codes for a gift card line.
+func validateGiftCardLines(ctx context.Context, rep dependency.Repository, items []entity.OrderItemInsert, promoOnGiftCard bool, redemption entity.StoreCreditRedemption) error {
+	if promoOnGiftCard {
+		return &entity.ValidationError{Message: "promo codes cannot be applied to gift card purchases", Field: "promo_code"}
+	}
+	if len(items) == 0 || redemption.IsZero() {
+		return nil
+	}
+	productIds := make([]int, 0, len(items))
+	for _, it := range items {
+		productIds = append(productIds, it.ProductId)
+	}
+	giftCards, err := rep.StoreCredit().GetGiftCardProductIds(ctx, productIds)
+	if err != nil {
+		return fmt.Errorf("can't check gift card lines: %w", err)
+	}
+	if len(giftCards) == 0 {
+		return nil
+	}
+	return &entity.ValidationError{Message: "gift cards cannot be paid with store credit", Field: "gift_card_code"}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/cache"
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/stocklocation"
 	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
 	"github.com/shopspring/decimal"
 )
@@ -248,7 +249,12 @@ func (s *Store) SetShipmentActualCost(ctx context.Context, orderUUID string, act
 // behaviour); writeoff — worn/damaged, nothing restocks (the sale's COGS stays expensed); seconds —
 // back as a zero-cost B-grade variant. The disposition rides into the accounting outbox payload so
 // the S2 entry mirrors the stock decision exactly.
-func (s *Store) RefundOrder(ctx context.Context, orderUUID string, orderItemIDs []int32, reason, reasonCode string, refundShipping bool, disposition string) error {
+//
+// toStoreCredit pays the whole refund into the buyer's store-credit account instead of back to the
+// card. Otherwise an order partly paid with credit is refunded card-first (entity.SplitOrderRefund)
+// and only the remainder returns to the wallet it was spent from. Gift-card lines are not
+// refundable here: their codes may already be spent, so they are corrected on the wallet instead.
+func (s *Store) RefundOrder(ctx context.Context, orderUUID string, orderItemIDs []int32, reason, reasonCode string, refundShipping bool, disposition string, toStoreCredit bool) error {
 	if !entity.ValidRefundDispositions[disposition] {
 		return fmt.Errorf("unknown refund disposition %q", disposition)
 	}
@@ -309,8 +315,20 @@ func (s *Store) RefundOrder(ctx context.Context, orderUUID string, orderItemIDs
 		}
 
 		itemsForStock := make([]entity.OrderItemInsert, len(itemsToRefund))
+		productIds := make([]int, len(itemsToRefund))
 		for i := range itemsToRefund {
 			itemsForStock[i] = itemsToRefund[i].OrderItemInsert
+			productIds[i] = itemsToRefund[i].OrderItemInsert.ProductId
+		}
+		giftCards, err := rep.StoreCredit().GetGiftCardProductIds(ctx, productIds)
+		if err != nil {
+			return fmt.Errorf("check gift card lines: %w", err)
+		}
+		if len(giftCards) > 0 {
+			return &entity.ValidationError{
+				Message: "gift card lines cannot be refunded; disable or adjust the issued gift card instead",
+				Field:   "order_item_ids",
+			}
 		}
 		history := &entity.StockHistoryParams{
 			Source:    entity.StockChangeSourceOrderReturned,
@@ -337,6 +355,13 @@ func (s *Store) RefundOrder(ctx context.Context, orderUUID string, orderItemIDs
 		if err := releaseOpenPackagingClaims(ctx, rep.DB(), order.Id); err != nil {
 			return fmt.Errorf("release packaging reservations: %w", err)
 		}
+		// So is its stock location allocation (0345): the restock above booked the units into the
+		// default location, but units allocated to a showroom or studio are still lying there.
+		if os == entity.Confirmed {
+			if err := stocklocation.ReleaseOrderAllocationInTx(ctx, rep, order.Id, ""); err != nil {
+				return fmt.Errorf("release stock location allocation: %w", err)
+			}
+		}
 
 		refundedByItem := make(map[int]int64)
 		for _, r := range itemsToRefund {
@@ -366,6 +391,26 @@ func (s *Store) RefundOrder(ctx context.Context, orderUUID string, orderItemIDs
 			}
 		}
 
+		// order is the pre-refund row, so the split sees what earlier refunds already returned.
+		_, creditAmount := entity.SplitOrderRefund(order, refundedAmount)
+		if toStoreCredit {
+			creditAmount = refundedAmount
+			buyer, err := getBuyerById(ctx, rep.DB(), order.Id)
+			if err != nil {
+				return fmt.Errorf("get order buyer: %w", err)
+			}
+			if _, err := rep.StoreCredit().CreditAccount(ctx, buyer.Email, order.Currency, refundedAmount, order.Id, entity.StoreCreditEntryRefundCredit, reason, ""); err != nil {
+				return fmt.Errorf("refund to store credit: %w", err)
+			}
+		} else if creditAmount.IsPositive() {
+			if !order.StoreCreditWalletId.Valid {
+				return fmt.Errorf("order %s has store credit applied but no wallet", order.UUID)
+			}
+			if _, err := rep.StoreCredit().CreditWallet(ctx, int(order.StoreCreditWalletId.Int32), creditAmount, order.Id, entity.StoreCreditEntryRefundCredit, reason, ""); err != nil {
+				return fmt.Errorf("return store credit: %w", err)
+			}
+		}
+
 		if err := updateOrderStatusAndAccumulateRefundedAmount(ctx, rep.DB(), order.Id, targetStatus.Status.Id, refundedAmount, reason, reasonCode); err != nil {
 			return err
 		}
@@ -388,11 +433,12 @@ func (s *Store) RefundOrder(ctx context.Context, orderUUID string, orderItemIDs
 			EventType: entity.AcctEventOrderRefund,
 			SourceKey: fmt.Sprintf("%s:%d", order.UUID, seq),
 			Payload: entity.AcctOrderRefundPayload{
-				OrderUUID:      order.UUID,
-				RefundAmount:   refundedAmount,
-				OrderCurrency:  order.Currency,
-				RefundedByItem: refundedByItem,
-				Disposition:    disposition,
+				OrderUUID:         order.UUID,
+				RefundAmount:      refundedAmount,
+				OrderCurrency:     order.Currency,
+				RefundedByItem:    refundedByItem,
+				Disposition:       disposition,
+				StoreCreditAmount: creditAmount,
 			},
 			OccurredAt: s.Now(),
 		}); err != nil {
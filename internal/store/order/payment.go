@@ -69,10 +69,12 @@ func (s *Store) insertOrderInvoice(ctx context.Context, orderUUID string, addrOr
 			}
 			return fmt.Errorf("total price is zero")
 		}
-		if err := dto.ValidatePriceMeetsMinimum(orderFull.Order.TotalPrice, orderFull.Order.Currency); err != nil {
+		// The card is charged only what store credit left over (AmountDue).
+		if err := dto.ValidatePriceMeetsMinimum(orderFull.Order.AmountDue(), orderFull.Order.Currency); err != nil {
 			slog.Default().ErrorContext(ctx, "InsertFiatInvoice: order total below currency minimum",
 				slog.String("order_uuid", orderUUID),
 				slog.String("total", orderFull.Order.TotalPrice.String()),
+				slog.String("amount_due", orderFull.Order.AmountDue().String()),
 				slog.String("currency", orderFull.Order.Currency),
 				slog.String("err", err.Error()),
 			)
@@ -125,8 +127,8 @@ func (s *Store) insertOrderInvoice(ctx context.Context, orderUUID string, addrOr
 func (s *Store) processPayment(ctx context.Context, db dependency.DB, orderFull *entity.OrderFull, addrOrSecret string, pm entity.PaymentMethod, expiredAt time.Time) error {
 	orderFull.Payment.PaymentMethodID = pm.Id
 	orderFull.Payment.IsTransactionDone = false
-	orderFull.Payment.TransactionAmount = orderFull.Order.TotalPriceDecimal()
-	orderFull.Payment.TransactionAmountPaymentCurrency = orderFull.Order.TotalPriceDecimal()
+	orderFull.Payment.TransactionAmount = orderFull.Order.AmountDue()
+	orderFull.Payment.TransactionAmountPaymentCurrency = orderFull.Order.AmountDue()
 	orderFull.Payment.PaymentInsert.ExpiredAt = sql.NullTime{Time: expiredAt, Valid: true}
 
 	switch pm.Name {
@@ -408,6 +410,9 @@ func (s *Store) ExpireOrderPayment(ctx context.Context, orderUUID string) (*enti
 		if err := releaseOpenPackagingClaims(ctx, txDB, order.Id); err != nil {
 			return fmt.Errorf("can't release packaging reservations: %w", err)
 		}
+		if err := rep.StoreCredit().ReleaseForOrder(ctx, order.Id, "payment expired"); err != nil {
+			return fmt.Errorf("can't release store credit: %w", err)
+		}
 
 		statusCancelled, ok := cache.GetOrderStatusByName(entity.Cancelled)
 		if !ok {
@@ -423,6 +428,9 @@ func (s *Store) ExpireOrderPayment(ctx context.Context, orderUUID string) (*enti
 				return fmt.Errorf("can't remove promo: %w", err)
 			}
 		}
+		if err := rep.Promo().ReleaseRedemptions(ctx, order.Id); err != nil {
+			return fmt.Errorf("can't release promo redemptions: %w", err)
+		}
 
 		return nil
 	})
@@ -537,6 +545,12 @@ func (s *Store) OrderPaymentDone(ctx context.Context, orderUUID string, p *entit
 			return fmt.Errorf("can't freeze order SKUs: %w", err)
 		}
 
+		// Gift-card lines become spendable codes at payment, never earlier: an unpaid order must not
+		// hand out credit.
+		if _, err := rep.StoreCredit().IssueGiftCardsForOrder(ctx, order.Id); err != nil {
+			return fmt.Errorf("can't issue gift cards: %w", err)
+		}
+
 		wasUpdated = true
 
 		// Accounting outbox (push producer, docs/plan-accounting/03): record the revenue-recognition
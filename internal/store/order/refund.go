@@ -95,6 +95,16 @@ func cancelOrder(ctx context.Context, rep dependency.Repository, order *entity.O
 			return fmt.Errorf("can't remove promo: %w", err)
 		}
 	}
+	// Free the codes so a cancelled order does not hold a usage cap or a single-use code.
+	if err := rep.Promo().ReleaseRedemptions(ctx, order.Id); err != nil {
+		return fmt.Errorf("can't release promo redemptions: %w", err)
+	}
+
+	// Credit applied at checkout was never captured (Confirmed and later are rejected above), so the
+	// whole amount goes back to the wallet it came from.
+	if err := rep.StoreCredit().ReleaseForOrder(ctx, order.Id, "order cancelled"); err != nil {
+		return fmt.Errorf("can't release store credit: %w", err)
+	}
 
 	statusCancelled, ok := cache.GetOrderStatusByName(entity.Cancelled)
 	if !ok {
@@ -101,37 +101,86 @@ func updateShipmentCostAndFreeShipping(ctx context.Context, db dependency.DB, sh
 	})
 }
 
-func updateOrderTotalPromo(ctx context.Context, db dependency.DB, orderId int, promoId int, totalPrice decimal.Decimal) error {
-	// Snapshot the promo (percentage, free-shipping flag, code) onto the order at apply time so
-	// later edits/deletion of the promo_code row can't rewrite this order's historical discount and
-	// reconstructed revenue (analytics-v2 task 05). The LEFT JOIN yields NULL snapshots when promoId
-	// is 0/NULL (no promo). Cancellation only NULLs promo_id elsewhere and deliberately leaves the
-	// snapshot intact.
+func updateOrderTotalPromo(ctx context.Context, db dependency.DB, orderId int, bd *entity.PromoBreakdown, subtotal, totalPrice decimal.Decimal) error {
+	// Snapshot the applied promos onto the order at apply time so later edits/deletion of the
+	// promo_code rows can't rewrite this order's historical discount and reconstructed revenue
+	// (analytics-v2 task 05). promo_discount_pct is the effective percentage of the subtotal, which is
+	// what the metrics and the accounting 4030 split reconstruct from; the snapshots are NULL when no
+	// code applied. Cancellation only NULLs promo_id elsewhere and deliberately leaves the snapshot
+	// intact.
 	query := `
-	UPDATE customer_order co
-	LEFT JOIN promo_code pc ON pc.id = :promoId
-	SET co.promo_id = :promoId,
-		co.total_price = :totalPrice,
-		co.promo_discount_pct = pc.discount,
-		co.promo_free_shipping = pc.free_shipping,
-		co.promo_code_snapshot = pc.code
-	WHERE co.id = :orderId`
+	UPDATE customer_order
+	SET promo_id = :promoId,
+		total_price = :totalPrice,
+		promo_discount_pct = :discountPct,
+		promo_free_shipping = :freeShipping,
+		promo_code_snapshot = :codes,
+		promo_discount_amount = :discountAmount
+	WHERE id = :orderId`
 
-	promoIdNull := sql.NullInt32{
-		Int32: int32(promoId),
-		Valid: promoId != 0,
+	promoId := bd.PrimaryPromoId()
+	applied := promoId != 0
+	pct := bd.EffectiveDiscountPct(subtotal)
+	if applied && !pct.Valid {
+		pct = decimal.NullDecimal{Decimal: decimal.Zero, Valid: true} // free shipping only
+	}
+	amount := decimal.NullDecimal{}
+	freeShipping := sql.NullBool{}
+	codes := sql.NullString{}
+	if applied {
+		amount = decimal.NullDecimal{Decimal: bd.Discount, Valid: true}
+		freeShipping = sql.NullBool{Bool: bd.FreeShipping, Valid: true}
+		codes = sql.NullString{String: strings.Join(bd.Codes(), ","), Valid: true}
 	}
 
 	return storeutil.ExecNamed(ctx, db, query, map[string]any{
-		"orderId":    orderId,
-		"promoId":    promoIdNull,
-		"totalPrice": totalPrice,
+		"orderId":        orderId,
+		"promoId":        sql.NullInt32{Int32: int32(promoId), Valid: applied},
+		"totalPrice":     totalPrice,
+		"discountPct":    pct,
+		"freeShipping":   freeShipping,
+		"codes":          codes,
+		"discountAmount": amount,
 	})
 }
 
-func updateTotalAmount(ctx context.Context, db dependency.DB, orderId int, subtotal decimal.Decimal, promo entity.PromoCode, shipment entity.Shipment, currency string) (decimal.Decimal, error) {
-	if !promo.IsAllowed() {
-		promo = entity.PromoCode{}
+// evaluateOrderPromos prices codes against validated order lines. orderId is set when an existing
+// order is re-priced (its own redemptions don't count towards the caps).
+func evaluateOrderPromos(ctx context.Context, rep dependency.Repository, codes []string, items []entity.OrderItem, currency, buyerEmail string, buyerTier int16, orderId int) (*entity.PromoBreakdown, error) {
+	bd, err := rep.Promo().EvaluateCart(ctx, codes, entity.PromoCart{
+		Currency:      currency,
+		DecimalPlaces: dto.DecimalPlacesForCurrency(currency),
+		Lines:         entity.PromoCartLinesFromOrderItems(items),
+		BuyerTier:     buyerTier,
+		BuyerEmail:    buyerEmail,
+		OrderId:       orderId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't evaluate promo codes: %w", err)
+	}
+	return bd, nil
+}
+
+// updateTotalAmount re-prices an order whose lines changed: the codes it was placed with are
+// evaluated again against the new lines (a minimum spend may no longer be met), the redemptions are
+// rewritten and the shipment cost and total follow.
+func updateTotalAmount(ctx context.Context, rep dependency.Repository, orderFull *entity.OrderFull, validItems []entity.OrderItem, subtotal decimal.Decimal) (decimal.Decimal, error) {
+	db := rep.DB()
+	orderId := orderFull.Order.Id
+	currency := orderFull.Order.Currency
+	shipment := orderFull.Shipment
+
+	codes, err := rep.Promo().GetOrderPromoCodes(ctx, orderId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	// Orders placed before 0335 have no redemption rows, only promo_id.
+	if len(codes) == 0 && orderFull.PromoCode.Code != "" {
+		codes = []string{orderFull.PromoCode.Code}
+	}
+	bd, err := evaluateOrderPromos(ctx, rep, codes, validItems, currency, orderFull.Buyer.Email, 0, orderId)
+	if err != nil {
+		return decimal.Zero, err
 	}
 
 	shipmentCost := shipment.CostDecimal(currency)
@@ -150,7 +199,7 @@ func updateTotalAmount(ctx context.Context, db dependency.DB, orderId int, subto
 			}
 		}
 	}
-	if promo.FreeShipping {
+	if bd.FreeShipping {
 		shipmentCost = decimal.Zero
 		freeShipping = true
 	}
@@ -159,14 +208,16 @@ func updateTotalAmount(ctx context.Context, db dependency.DB, orderId int, subto
 		return decimal.Zero, fmt.Errorf("can't update shipment cost: %w", err)
 	}
 
-	subtotal = promo.SubtotalWithPromo(subtotal, shipmentCost, dto.DecimalPlacesForCurrency(currency))
+	total, _ := bd.ApplyTo(subtotal, shipmentCost, dto.DecimalPlacesForCurrency(currency))
 
-	err := updateOrderTotalPromo(ctx, db, orderId, promo.Id, subtotal)
-	if err != nil {
+	if err := rep.Promo().RecordRedemptions(ctx, orderId, orderFull.Buyer.Email, bd); err != nil {
+		return decimal.Zero, fmt.Errorf("can't record promo redemptions: %w", err)
+	}
+	if err := updateOrderTotalPromo(ctx, db, orderId, bd, subtotal, total); err != nil {
 		return decimal.Zero, fmt.Errorf("can't update order total promo: %w", err)
 	}
 
-	return subtotal, nil
+	return total, nil
 }
 
 func updateOrderShipment(ctx context.Context, db dependency.DB, shipment *entity.Shipment) error {
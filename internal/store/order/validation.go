@@ -797,9 +797,14 @@ func validateAndUpdateOrderIfNeededWithLock(ctx context.Context, rep dependency.
 			return false, fmt.Errorf("error updating order items: %w", err)
 		}
 
-		if _, err := updateTotalAmount(ctx, rep.DB(), orderFull.Order.Id, oiv.SubtotalDecimal(), orderFull.PromoCode, orderFull.Shipment, orderFull.Order.Currency); err != nil {
+		total, err := updateTotalAmount(ctx, rep, orderFull, oiv.ValidItems, oiv.SubtotalDecimal())
+		if err != nil {
 			return false, fmt.Errorf("error updating total amount: %w", err)
 		}
+		// A smaller total may no longer hold all the credit applied at checkout.
+		if _, err := rep.StoreCredit().ClampForOrder(ctx, orderFull.Order.Id, total, orderFull.Order.Currency); err != nil {
+			return false, fmt.Errorf("error clamping store credit: %w", err)
+		}
 
 		return true, nil
 	}
@@ -0,0 +1,588 @@
+// Package payroll stores piece-rate pay periods and monthly payslips (migration 0341). The money is
+// computed by internal/payroll from the bundle scans of the month; this package loads the inputs,
+// writes the result and guards the lifecycle (draft → approved → reopened).
+package payroll
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	payrollcalc "github.com/jekabolt/grbpwr-manager/internal/payroll"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.Payroll.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new payroll store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+func firstOfMonthUTC(t time.Time) time.Time {
+	u := t.UTC()
+	return time.Date(u.Year(), u.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func monthParam(t time.Time) string { return firstOfMonthUTC(t).Format("2006-01-02") }
+
+// lockEmployee row-locks the employee for the rest of the tx: pay periods and payslips of one
+// employee are written one writer at a time, so the overlap check and the payslip upsert cannot race.
+func lockEmployee(ctx context.Context, db dependency.DB, id int) error {
+	_, err := storeutil.QueryNamedOne[struct {
+		Id int `db:"id"`
+	}](ctx, db, `SELECT id FROM employee WHERE id = :id FOR UPDATE`, map[string]any{"id": id})
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.ErrEmployeeNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("can't lock employee %d: %w", id, err)
+	}
+	return nil
+}
+
+const periodColumns = `id, employee_id, scheme, valid_from, valid_to, currency, rate_per_minute,
+	guaranteed_rate_per_minute, note, created_by, created_at, updated_at`
+
+// UpsertPayPeriod inserts (id == 0) or replaces a pay period with its per-piece rates, returning its
+// id. Periods of one employee must not share a month (entity.ErrPayPeriodOverlap). Editing a period
+// does not touch payslips already computed with it: they hold their own snapshot of the rates, and a
+// draft picks the new rates up on its next recompute.
+func (s *Store) UpsertPayPeriod(ctx context.Context, ins entity.EmployeePayPeriodInsert, id int, username string) (int, error) {
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		if err := lockEmployee(ctx, db, ins.EmployeeId); err != nil {
+			return err
+		}
+		if id != 0 {
+			n, err := storeutil.QueryCountNamed(ctx, db,
+				`SELECT COUNT(*) FROM employee_pay_period WHERE id = :id AND employee_id = :employee_id`,
+				map[string]any{"id": id, "employee_id": ins.EmployeeId})
+			if err != nil {
+				return fmt.Errorf("can't check pay period %d: %w", id, err)
+			}
+			if n == 0 {
+				return entity.ErrPayPeriodNotFound
+			}
+		}
+		params := map[string]any{
+			"id":                         id,
+			"employee_id":                ins.EmployeeId,
+			"scheme":                     string(ins.Scheme),
+			"valid_from":                 monthParam(ins.ValidFrom),
+			"valid_to":                   nil,
+			"currency":                   strings.ToUpper(ins.Currency),
+			"rate_per_minute":            ins.RatePerMinute,
+			"guaranteed_rate_per_minute": ins.GuaranteedRatePerMinute,
+			"note":                       ins.Note,
+			"created_by":                 username,
+		}
+		if ins.ValidTo.Valid {
+			params["valid_to"] = monthParam(ins.ValidTo.Time)
+		}
+		overlaps, err := storeutil.QueryCountNamed(ctx, db, `
+			SELECT COUNT(*) FROM employee_pay_period
+			WHERE employee_id = :employee_id AND id <> :id
+			  AND (valid_to IS NULL OR valid_to >= :valid_from)
+			  AND (:valid_to IS NULL OR valid_from <= :valid_to)`, params)
+		if err != nil {
+			return fmt.Errorf("can't check pay period overlap: %w", err)
+		}
+		if overlaps > 0 {
+			return entity.ErrPayPeriodOverlap
+		}
+		if err := checkWorkTokens(ctx, db, ins.PieceRates); err != nil {
+			return err
+		}
+
+		if id == 0 {
+			id, err = storeutil.ExecNamedLastId(ctx, db, `
+				INSERT INTO employee_pay_period (employee_id, scheme, valid_from, valid_to, currency,
+					rate_per_minute, guaranteed_rate_per_minute, note, created_by)
+				VALUES (:employee_id, :scheme, :valid_from, :valid_to, :currency,
+					:rate_per_minute, :guaranteed_rate_per_minute, :note, :created_by)`, params)
+			if err != nil {
+				return fmt.Errorf("can't insert pay period: %w", err)
+			}
+		} else {
+			if err := storeutil.ExecNamed(ctx, db, `
+				UPDATE employee_pay_period
+				SET scheme = :scheme, valid_from = :valid_from, valid_to = :valid_to, currency = :currency,
+				    rate_per_minute = :rate_per_minute, guaranteed_rate_per_minute = :guaranteed_rate_per_minute,
+				    note = :note
+				WHERE id = :id`, params); err != nil {
+				return fmt.Errorf("can't update pay period %d: %w", id, err)
+			}
+			if err := storeutil.ExecNamed(ctx, db,
+				`DELETE FROM employee_pay_period_rate WHERE period_id = :id`, params); err != nil {
+				return fmt.Errorf("can't clear piece rates of pay period %d: %w", id, err)
+			}
+		}
+		rows := make([][]any, 0, len(ins.PieceRates))
+		for _, r := range ins.PieceRates {
+			rows = append(rows, []any{id, r.Work, r.RatePerPiece})
+		}
+		if err := storeutil.BulkInsertRows(ctx, db, "employee_pay_period_rate",
+			[]string{"period_id", "work_token", "rate_per_piece"}, rows); err != nil {
+			return fmt.Errorf("can't insert piece rates of pay period %d: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// checkWorkTokens refuses a per-piece rate on a work the catalog does not know. The FK would refuse
+// it too, but as a bare constraint error instead of naming the token.
+func checkWorkTokens(ctx context.Context, db dependency.DB, rates []entity.EmployeePieceRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	tokens := make([]string, 0, len(rates))
+	for _, r := range rates {
+		tokens = append(tokens, r.Work)
+	}
+	known, err := storeutil.QueryListNamed[struct {
+		Token string `db:"token"`
+	}](ctx, db, `SELECT token FROM operation_work WHERE token IN (:tokens)`, map[string]any{"tokens": tokens})
+	if err != nil {
+		return fmt.Errorf("can't check work tokens: %w", err)
+	}
+	have := make(map[string]bool, len(known))
+	for _, k := range known {
+		have[k.Token] = true
+	}
+	for _, t := range tokens {
+		if !have[t] {
+			return entity.NewFieldViolation("piece_rates.work", "unknown_work", t,
+				"pick the work from the operation catalog")
+		}
+	}
+	return nil
+}
+
+// DeletePayPeriod removes a pay period and its rates. Payslips computed with it keep their snapshot
+// (period_id is set to NULL), and their months stay out of the flat salary OPEX.
+func (s *Store) DeletePayPeriod(ctx context.Context, id int) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB,
+		`DELETE FROM employee_pay_period WHERE id = :id`, map[string]any{"id": id})
+	if err != nil {
+		return fmt.Errorf("can't delete pay period %d: %w", id, err)
+	}
+	if n == 0 {
+		return entity.ErrPayPeriodNotFound
+	}
+	return nil
+}
+
+// ListPayPeriods returns the pay periods of an employee (every employee when employeeID is 0) with
+// their per-piece rates, newest first.
+func (s *Store) ListPayPeriods(ctx context.Context, employeeID int) ([]entity.EmployeePayPeriod, error) {
+	where := ""
+	params := map[string]any{}
+	if employeeID > 0 {
+		where = "WHERE employee_id = :employee_id"
+		params["employee_id"] = employeeID
+	}
+	periods, err := storeutil.QueryListNamed[entity.EmployeePayPeriod](ctx, s.DB, `
+		SELECT `+periodColumns+` FROM employee_pay_period `+where+`
+		ORDER BY employee_id, valid_from DESC`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't list pay periods: %w", err)
+	}
+	if len(periods) == 0 {
+		return periods, nil
+	}
+	ids := make([]int, 0, len(periods))
+	for _, p := range periods {
+		ids = append(ids, p.Id)
+	}
+	rates, err := storeutil.QueryListNamed[struct {
+		PeriodId int `db:"period_id"`
+		entity.EmployeePieceRate
+	}](ctx, s.DB, `
+		SELECT period_id, work_token, rate_per_piece FROM employee_pay_period_rate
+		WHERE period_id IN (:ids)
+		ORDER BY period_id, work_token`, map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("can't list piece rates: %w", err)
+	}
+	byPeriod := make(map[int][]entity.EmployeePieceRate, len(periods))
+	for _, r := range rates {
+		byPeriod[r.PeriodId] = append(byPeriod[r.PeriodId], r.EmployeePieceRate)
+	}
+	for i := range periods {
+		periods[i].PieceRates = byPeriod[periods[i].Id]
+	}
+	return periods, nil
+}
+
+// loadWorkFacts sums the employee's bundle scans of the month per run operation. The month is the
+// scan's scanned_at in UTC, the same boundary the OPEX months use.
+func loadWorkFacts(ctx context.Context, db dependency.DB, employeeID int, month time.Time) ([]entity.PayrollWorkFact, error) {
+	from := firstOfMonthUTC(month)
+	rows, err := storeutil.QueryListNamed[entity.PayrollWorkFact](ctx, db, `
+		SELECT b.run_id, o.seq AS operation_seq, o.operation_type, o.work, o.smv,
+		       CAST(SUM(s.qty) AS SIGNED) AS qty, COUNT(*) AS bundles
+		FROM production_run_bundle_scan s
+		JOIN production_run_bundle b ON b.id = s.bundle_id
+		JOIN production_run_operation o ON o.id = s.operation_id
+		WHERE s.employee_id = :employee_id AND s.scanned_at >= :from AND s.scanned_at < :to
+		GROUP BY b.run_id, o.id, o.seq, o.operation_type, o.work, o.smv
+		ORDER BY b.run_id, o.seq`,
+		map[string]any{"employee_id": employeeID, "from": from, "to": from.AddDate(0, 1, 0)})
+	if err != nil {
+		return nil, fmt.Errorf("can't load work of employee %d in %s: %w", employeeID, from.Format("2006-01"), err)
+	}
+	return rows, nil
+}
+
+type payslipHead struct {
+	Id         int                  `db:"id"`
+	EmployeeId int                  `db:"employee_id"`
+	Month      time.Time            `db:"month"`
+	Status     entity.PayslipStatus `db:"status"`
+	Currency   string               `db:"currency"`
+	Gross      decimal.Decimal      `db:"gross"`
+}
+
+func lockPayslip(ctx context.Context, db dependency.DB, id int) (payslipHead, error) {
+	h, err := storeutil.QueryNamedOne[payslipHead](ctx, db, `
+		SELECT id, employee_id, month, status, currency, gross
+		FROM payroll_payslip WHERE id = :id FOR UPDATE`, map[string]any{"id": id})
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, entity.ErrPayslipNotFound
+	}
+	if err != nil {
+		return h, fmt.Errorf("can't lock payslip %d: %w", id, err)
+	}
+	return h, nil
+}
+
+// ComputePayslip (re)computes the employee's draft payslip for the month from the recorded work under
+// the pay period covering it, returning the payslip id. An approved payslip is refused
+// (entity.ErrPayslipApproved); a month no period covers is entity.ErrPayPeriodNotFound.
+func (s *Store) ComputePayslip(ctx context.Context, in entity.PayslipCompute) (int, error) {
+	month := firstOfMonthUTC(in.Month)
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		if err := lockEmployee(ctx, db, in.EmployeeId); err != nil {
+			return err
+		}
+		args := map[string]any{"employee_id": in.EmployeeId, "month": monthParam(month)}
+		existing, err := storeutil.QueryNamedOne[payslipHead](ctx, db, `
+			SELECT id, employee_id, month, status, currency, gross
+			FROM payroll_payslip WHERE employee_id = :employee_id AND month = :month`, args)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return fmt.Errorf("can't read payslip: %w", err)
+		case existing.Status == entity.PayslipStatusApproved:
+			return entity.ErrPayslipApproved
+		default:
+			id = existing.Id
+		}
+
+		period, err := storeutil.QueryNamedOne[entity.EmployeePayPeriod](ctx, db, `
+			SELECT `+periodColumns+` FROM employee_pay_period
+			WHERE employee_id = :employee_id AND valid_from <= :month
+			  AND (valid_to IS NULL OR valid_to >= :month)`, args)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: employee %d has no pay period covering %s",
+				entity.ErrPayPeriodNotFound, in.EmployeeId, month.Format("2006-01"))
+		}
+		if err != nil {
+			return fmt.Errorf("can't read pay period: %w", err)
+		}
+		period.PieceRates, err = storeutil.QueryListNamed[entity.EmployeePieceRate](ctx, db, `
+			SELECT work_token, rate_per_piece FROM employee_pay_period_rate WHERE period_id = :id`,
+			map[string]any{"id": period.Id})
+		if err != nil {
+			return fmt.Errorf("can't read piece rates of pay period %d: %w", period.Id, err)
+		}
+		facts, err := loadWorkFacts(ctx, db, in.EmployeeId, month)
+		if err != nil {
+			return err
+		}
+		lines, totals, err := payrollcalc.Compute(period.EmployeePayPeriodInsert, facts, in.AttendedMinutes, in.Adjustment)
+		if err != nil {
+			return err
+		}
+
+		params := map[string]any{
+			"id":                         id,
+			"employee_id":                in.EmployeeId,
+			"month":                      monthParam(month),
+			"period_id":                  period.Id,
+			"scheme":                     string(period.Scheme),
+			"currency":                   period.Currency,
+			"rate_per_minute":            period.RatePerMinute,
+			"guaranteed_rate_per_minute": period.GuaranteedRatePerMinute,
+			"attended_minutes":           in.AttendedMinutes,
+			"standard_minutes":           totals.StandardMinutes,
+			"piece_earnings":             totals.PieceEarnings,
+			"guaranteed_pay":             totals.GuaranteedPay,
+			"adjustment":                 in.Adjustment.Round(2),
+			"adjustment_note":            in.AdjustmentNote,
+			"gross":                      totals.Gross,
+			"unpriced_qty":               totals.UnpricedQty,
+			"computed_by":                in.ComputedBy,
+			"computed_at":                s.Now().UTC(),
+		}
+		if id == 0 {
+			id, err = storeutil.ExecNamedLastId(ctx, db, `
+				INSERT INTO payroll_payslip (employee_id, month, period_id, scheme, currency, rate_per_minute,
+					guaranteed_rate_per_minute, attended_minutes, standard_minutes, piece_earnings, guaranteed_pay,
+					adjustment, adjustment_note, gross, unpriced_qty, computed_by, computed_at)
+				VALUES (:employee_id, :month, :period_id, :scheme, :currency, :rate_per_minute,
+					:guaranteed_rate_per_minute, :attended_minutes, :standard_minutes, :piece_earnings, :guaranteed_pay,
+					:adjustment, :adjustment_note, :gross, :unpriced_qty, :computed_by, :computed_at)`, params)
+			if err != nil {
+				return fmt.Errorf("can't insert payslip: %w", err)
+			}
+		} else {
+			if err := storeutil.ExecNamed(ctx, db, `
+				UPDATE payroll_payslip
+				SET period_id = :period_id, scheme = :scheme, currency = :currency,
+				    rate_per_minute = :rate_per_minute, guaranteed_rate_per_minute = :guaranteed_rate_per_minute,
+				    attended_minutes = :attended_minutes, standard_minutes = :standard_minutes,
+				    piece_earnings = :piece_earnings, guaranteed_pay = :guaranteed_pay, adjustment = :adjustment,
+				    adjustment_note = :adjustment_note, gross = :gross, unpriced_qty = :unpriced_qty,
+				    computed_by = :computed_by, computed_at = :computed_at
+				WHERE id = :id`, params); err != nil {
+				return fmt.Errorf("can't update payslip %d: %w", id, err)
+			}
+			if err := storeutil.ExecNamed(ctx, db,
+				`DELETE FROM payroll_payslip_line WHERE payslip_id = :id`, params); err != nil {
+				return fmt.Errorf("can't clear lines of payslip %d: %w", id, err)
+			}
+		}
+		rows := make([][]any, 0, len(lines))
+		for _, l := range lines {
+			rows = append(rows, []any{id, l.RunId, l.OperationSeq, l.OperationType, l.Work, string(l.Basis),
+				l.Qty, l.Bundles, l.SMV, l.StandardMinutes, l.Rate, l.Amount})
+		}
+		if err := storeutil.BulkInsertRows(ctx, db, "payroll_payslip_line",
+			[]string{"payslip_id", "run_id", "operation_seq", "operation_type", "work", "basis",
+				"qty", "bundles", "smv", "standard_minutes", "rate", "amount"}, rows); err != nil {
+			return fmt.Errorf("can't insert lines of payslip %d: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ApprovePayslip freezes a draft and folds its gross to base currency at the month's end; the
+// accounting worker posts it on its next tick. Refused when:
+//   - the recorded work of the month changed since the computation (entity.ErrPayslipStale);
+//   - the employee's flat salary template was already booked into OPEX for the month
+//     (entity.ErrPayslipOpexOverlap) — both would reach the ledger as salary;
+//   - the payslip currency has no rate at the month end (entity.ErrPayslipFxMissing).
+func (s *Store) ApprovePayslip(ctx context.Context, id int, username string) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		h, err := lockPayslip(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if h.Status == entity.PayslipStatusApproved {
+			return entity.ErrPayslipApproved
+		}
+		if err := checkPayslipCurrent(ctx, db, h); err != nil {
+			return err
+		}
+		booked, err := storeutil.QueryCountNamed(ctx, db, `
+			SELECT COUNT(*) FROM opex_line l
+			JOIN opex_recurring r ON r.id = l.recurring_id
+			WHERE r.employee_id = :employee_id AND l.month = :month AND l.category = 'salaries'`,
+			map[string]any{"employee_id": h.EmployeeId, "month": monthParam(h.Month)})
+		if err != nil {
+			return fmt.Errorf("can't check salary opex of payslip %d: %w", id, err)
+		}
+		if booked > 0 {
+			return fmt.Errorf("%w: delete the %s salary OPEX line of the employee first",
+				entity.ErrPayslipOpexOverlap, h.Month.Format("2006-01"))
+		}
+		grossBase, err := foldToBase(ctx, db, h.Gross, h.Currency, firstOfMonthUTC(h.Month).AddDate(0, 1, -1))
+		if err != nil {
+			return err
+		}
+		return storeutil.ExecNamed(ctx, db, `
+			UPDATE payroll_payslip
+			SET status = 'approved', approved_by = :approved_by, approved_at = :approved_at, gross_base = :gross_base
+			WHERE id = :id`,
+			map[string]any{"id": id, "approved_by": username, "approved_at": s.Now().UTC(), "gross_base": grossBase})
+	})
+}
+
+// checkPayslipCurrent compares the stored lines with the month's work as recorded now, operation by
+// operation.
+func checkPayslipCurrent(ctx context.Context, db dependency.DB, h payslipHead) error {
+	facts, err := loadWorkFacts(ctx, db, h.EmployeeId, h.Month)
+	if err != nil {
+		return err
+	}
+	lines, err := storeutil.QueryListNamed[entity.PayslipLine](ctx, db, `
+		SELECT run_id, operation_seq, operation_type, work, basis, qty, bundles, smv, standard_minutes, rate, amount
+		FROM payroll_payslip_line WHERE payslip_id = :id`, map[string]any{"id": h.Id})
+	if err != nil {
+		return fmt.Errorf("can't read lines of payslip %d: %w", h.Id, err)
+	}
+	if len(lines) != len(facts) {
+		return entity.ErrPayslipStale
+	}
+	type key struct{ run, seq int }
+	stored := make(map[key]entity.PayslipLine, len(lines))
+	for _, l := range lines {
+		stored[key{l.RunId, l.OperationSeq}] = l
+	}
+	for _, f := range facts {
+		l, ok := stored[key{f.RunId, f.OperationSeq}]
+		if !ok || l.Qty != f.Qty || l.Bundles != f.Bundles {
+			return entity.ErrPayslipStale
+		}
+	}
+	return nil
+}
+
+// foldToBase converts the gross at the rate effective on the month's last day — the rate the flat
+// salary OPEX line of the same month would have been folded at.
+func foldToBase(ctx context.Context, db dependency.DB, amount decimal.Decimal, currency string, asOf time.Time) (decimal.Decimal, error) {
+	base := cache.GetBaseCurrency()
+	if strings.EqualFold(currency, base) {
+		return amount.Round(2), nil
+	}
+	row, err := storeutil.QueryNamedOne[struct {
+		Rate decimal.Decimal `db:"rate_to_base"`
+	}](ctx, db, `
+		SELECT rate_to_base FROM costing_fx_rate
+		WHERE currency = :cur AND valid_from <= :as_of
+		ORDER BY valid_from DESC LIMIT 1`,
+		map[string]any{"cur": strings.ToUpper(currency), "as_of": asOf.Format("2006-01-02")})
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !row.Rate.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: %s on %s", entity.ErrPayslipFxMissing, currency, asOf.Format("2006-01-02"))
+	}
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("can't get %s rate: %w", currency, err)
+	}
+	return amount.Mul(row.Rate).Round(2), nil
+}
+
+// ReopenPayslip returns an approved payslip to draft; the accounting worker reverses its entry on
+// the next tick, and the month's scans can be corrected again.
+func (s *Store) ReopenPayslip(ctx context.Context, id int) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		h, err := lockPayslip(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if h.Status != entity.PayslipStatusApproved {
+			return entity.ErrPayslipNotApproved
+		}
+		return storeutil.ExecNamed(ctx, db, `
+			UPDATE payroll_payslip
+			SET status = 'draft', approved_by = NULL, approved_at = NULL, gross_base = NULL
+			WHERE id = :id`, map[string]any{"id": id})
+	})
+}
+
+// DeletePayslip removes a draft. An approved payslip is refused; reopen it first.
+func (s *Store) DeletePayslip(ctx context.Context, id int) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB,
+		`DELETE FROM payroll_payslip WHERE id = :id AND status = 'draft'`, map[string]any{"id": id})
+	if err != nil {
+		return fmt.Errorf("can't delete payslip %d: %w", id, err)
+	}
+	if n > 0 {
+		return nil
+	}
+	exists, err := storeutil.QueryCountNamed(ctx, s.DB,
+		`SELECT COUNT(*) FROM payroll_payslip WHERE id = :id`, map[string]any{"id": id})
+	if err != nil {
+		return fmt.Errorf("can't check payslip %d: %w", id, err)
+	}
+	if exists > 0 {
+		return entity.ErrPayslipApproved
+	}
+	return entity.ErrPayslipNotFound
+}
+
+const payslipColumns = `p.id, p.employee_id, e.full_name AS employee_name, p.month, p.period_id, p.scheme,
+	p.currency, p.rate_per_minute, p.guaranteed_rate_per_minute, p.status, p.attended_minutes,
+	p.standard_minutes, p.piece_earnings, p.guaranteed_pay, p.adjustment, p.adjustment_note, p.gross,
+	p.gross_base, p.unpriced_qty, p.computed_by, p.computed_at, p.approved_by, p.approved_at,
+	p.created_at, p.updated_at`
+
+// GetPayslip reads one payslip with its lines.
+func (s *Store) GetPayslip(ctx context.Context, id int) (*entity.Payslip, error) {
+	p, err := storeutil.QueryNamedOne[entity.Payslip](ctx, s.DB, `
+		SELECT `+payslipColumns+`
+		FROM payroll_payslip p JOIN employee e ON e.id = p.employee_id
+		WHERE p.id = :id`, map[string]any{"id": id})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, entity.ErrPayslipNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't get payslip %d: %w", id, err)
+	}
+	p.Lines, err = storeutil.QueryListNamed[entity.PayslipLine](ctx, s.DB, `
+		SELECT run_id, operation_seq, operation_type, work, basis, qty, bundles, smv, standard_minutes, rate, amount
+		FROM payroll_payslip_line WHERE payslip_id = :id
+		ORDER BY run_id, operation_seq`, map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("can't get lines of payslip %d: %w", id, err)
+	}
+	return &p, nil
+}
+
+// ListPayslips returns payslip summaries (no lines), newest month first.
+func (s *Store) ListPayslips(ctx context.Context, f entity.PayslipFilter) ([]entity.Payslip, error) {
+	where := []string{"1=1"}
+	params := map[string]any{}
+	if f.EmployeeId > 0 {
+		where = append(where, "p.employee_id = :employee_id")
+		params["employee_id"] = f.EmployeeId
+	}
+	if !f.From.IsZero() {
+		where = append(where, "p.month >= :from")
+		params["from"] = monthParam(f.From)
+	}
+	if !f.To.IsZero() {
+		where = append(where, "p.month <= :to")
+		params["to"] = monthParam(f.To)
+	}
+	if f.Status != "" {
+		where = append(where, "p.status = :status")
+		params["status"] = string(f.Status)
+	}
+	rows, err := storeutil.QueryListNamed[entity.Payslip](ctx, s.DB, `
+		SELECT `+payslipColumns+`
+		FROM payroll_payslip p JOIN employee e ON e.id = p.employee_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY p.month DESC, e.full_name, p.id`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't list payslips: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,382 @@
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+const selectMarkdown = `
+	SELECT id, name, sale_percentage, starts_at, ends_at, status, applied_at, reverted_at, note,
+	       created_by, created_at, updated_at
+	FROM markdown_event`
+
+// ListMarkdownEvents returns the events by start, newest first, without products. Ended and cancelled
+// events are included only on request.
+func (s *Store) ListMarkdownEvents(ctx context.Context, includeClosed bool) ([]entity.MarkdownEvent, error) {
+	out, err := storeutil.QueryListNamed[entity.MarkdownEvent](ctx, s.DB, selectMarkdown+`
+		WHERE :all OR status IN ('scheduled', 'active')
+		ORDER BY starts_at DESC, id DESC`, map[string]any{"all": includeClosed})
+	if err != nil {
+		return nil, fmt.Errorf("can't list markdown events: %w", err)
+	}
+	return out, nil
+}
+
+// GetMarkdownEvent returns one event with its colourways.
+func (s *Store) GetMarkdownEvent(ctx context.Context, id int) (*entity.MarkdownEvent, error) {
+	return getMarkdown(ctx, s.DB, id, false)
+}
+
+// CreateMarkdownEvent stores a scheduled event and returns its id.
+func (s *Store) CreateMarkdownEvent(ctx context.Context, ins entity.MarkdownEventInsert) (int, error) {
+	if err := entity.ValidateMarkdownEventInsert(&ins, s.Now()); err != nil {
+		return 0, err
+	}
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		if err := checkMarkdownProducts(ctx, rep.DB(), ins, 0); err != nil {
+			return err
+		}
+		var err error
+		id, err = storeutil.ExecNamedLastId(ctx, rep.DB(), `
+			INSERT INTO markdown_event (name, sale_percentage, starts_at, ends_at, note, created_by)
+			VALUES (:name, :pct, :startsAt, :endsAt, :note, :createdBy)`,
+			map[string]any{
+				"name":      ins.Name,
+				"pct":       ins.SalePercentage,
+				"startsAt":  ins.StartsAt,
+				"endsAt":    ins.EndsAt,
+				"note":      ins.Note,
+				"createdBy": ins.CreatedBy,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert markdown event: %w", err)
+		}
+		return insertMarkdownProducts(ctx, rep.DB(), id, ins.ProductIds)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateMarkdownEvent replaces a scheduled event. Once it has applied its colourways' prices are live
+// and the event can only be cancelled.
+func (s *Store) UpdateMarkdownEvent(ctx context.Context, id int, ins entity.MarkdownEventInsert) error {
+	if err := entity.ValidateMarkdownEventInsert(&ins, s.Now()); err != nil {
+		return err
+	}
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		ev, err := getMarkdown(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		if ev.Status != entity.MarkdownScheduled {
+			return fmt.Errorf("%w: %s is %s", entity.ErrMarkdownEventNotEditable, ev.Name, ev.Status)
+		}
+		if err := checkMarkdownProducts(ctx, rep.DB(), ins, id); err != nil {
+			return err
+		}
+		err = storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE markdown_event
+			SET name = :name, sale_percentage = :pct, starts_at = :startsAt, ends_at = :endsAt, note = :note
+			WHERE id = :id`,
+			map[string]any{
+				"id":       id,
+				"name":     ins.Name,
+				"pct":      ins.SalePercentage,
+				"startsAt": ins.StartsAt,
+				"endsAt":   ins.EndsAt,
+				"note":     ins.Note,
+			})
+		if err != nil {
+			return fmt.Errorf("can't update markdown event: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, rep.DB(), `DELETE FROM markdown_event_product WHERE event_id = :id`,
+			map[string]any{"id": id}); err != nil {
+			return fmt.Errorf("can't delete markdown event products: %w", err)
+		}
+		return insertMarkdownProducts(ctx, rep.DB(), id, ins.ProductIds)
+	})
+}
+
+// CancelMarkdownEvent cancels a scheduled event, or ends an active one now, reverting its colourways.
+// It returns the colourways whose sale percentage moved.
+func (s *Store) CancelMarkdownEvent(ctx context.Context, id int) ([]int, error) {
+	var moved []int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		ev, err := getMarkdown(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		switch ev.Status {
+		case entity.MarkdownScheduled:
+		case entity.MarkdownActive:
+			if moved, err = revertMarkdown(ctx, rep.DB(), ev); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: %s is %s", entity.ErrMarkdownEventNotEditable, ev.Name, ev.Status)
+		}
+		return setMarkdownStatus(ctx, rep.DB(), ev, entity.MarkdownCancelled, s.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// PreviewMarkdownEvent projects a payload onto its colourways: base-currency prices now and during
+// the markdown, and the overlapping events. selfID is the event being edited (0 for a new one), so it
+// does not collide with itself.
+func (s *Store) PreviewMarkdownEvent(ctx context.Context, ins entity.MarkdownEventInsert, selfID int) ([]entity.MarkdownLine, error) {
+	sources, conflicts, err := readMarkdownProducts(ctx, s.DB, ins, selfID)
+	if err != nil {
+		return nil, err
+	}
+	return entity.BuildMarkdownLines(ins, ins.ProductIds, sources, conflicts, cache.GetBaseCurrency()), nil
+}
+
+// ApplyDueMarkdownEvents ends the active events whose window closed, then starts the scheduled ones
+// whose window opened — in that order, so a markdown following another on the same colourway
+// remembers the price from before both. An event whose whole window passed while nothing swept
+// (the worker was down) is ended without ever applying.
+func (s *Store) ApplyDueMarkdownEvents(ctx context.Context, now time.Time) (*entity.MarkdownSweep, error) {
+	sweep := &entity.MarkdownSweep{}
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		ending, err := storeutil.QueryListNamed[entity.MarkdownEvent](ctx, rep.DB(), selectMarkdown+`
+			WHERE status = 'active' AND ends_at <= :now ORDER BY ends_at, id FOR UPDATE`, map[string]any{"now": now})
+		if err != nil {
+			return fmt.Errorf("can't list ending markdown events: %w", err)
+		}
+		for i := range ending {
+			moved, err := revertMarkdown(ctx, rep.DB(), &ending[i])
+			if err != nil {
+				return err
+			}
+			if err := setMarkdownStatus(ctx, rep.DB(), &ending[i], entity.MarkdownEnded, now); err != nil {
+				return err
+			}
+			sweep.Ended = append(sweep.Ended, ending[i].Id)
+			sweep.Products = append(sweep.Products, moved...)
+		}
+
+		starting, err := storeutil.QueryListNamed[entity.MarkdownEvent](ctx, rep.DB(), selectMarkdown+`
+			WHERE status = 'scheduled' AND starts_at <= :now ORDER BY starts_at, id FOR UPDATE`, map[string]any{"now": now})
+		if err != nil {
+			return fmt.Errorf("can't list due markdown events: %w", err)
+		}
+		for i := range starting {
+			ev := &starting[i]
+			if !ev.EndsAt.After(now) {
+				if err := setMarkdownStatus(ctx, rep.DB(), ev, entity.MarkdownEnded, now); err != nil {
+					return err
+				}
+				sweep.Ended = append(sweep.Ended, ev.Id)
+				continue
+			}
+			moved, err := applyMarkdown(ctx, rep.DB(), ev)
+			if err != nil {
+				return err
+			}
+			if err := setMarkdownStatus(ctx, rep.DB(), ev, entity.MarkdownActive, now); err != nil {
+				return err
+			}
+			sweep.Started = append(sweep.Started, ev.Id)
+			sweep.Products = append(sweep.Products, moved...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sweep, nil
+}
+
+func getMarkdown(ctx context.Context, db dependency.DB, id int, forUpdate bool) (*entity.MarkdownEvent, error) {
+	query := selectMarkdown + ` WHERE id = :id`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	ev, err := storeutil.QueryNamedOne[entity.MarkdownEvent](ctx, db, query, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", entity.ErrMarkdownEventNotFound, id)
+		}
+		return nil, fmt.Errorf("can't get markdown event: %w", err)
+	}
+	ev.Products, err = storeutil.QueryListNamed[entity.MarkdownEventProduct](ctx, db, `
+		SELECT mep.product_id, p.sku, p.color, mep.prev_sale_percentage, mep.revert_skipped
+		FROM markdown_event_product mep
+		JOIN product p ON p.id = mep.product_id
+		WHERE mep.event_id = :id
+		ORDER BY p.sku, p.id`, map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("can't get markdown event products: %w", err)
+	}
+	return &ev, nil
+}
+
+// readMarkdownProducts loads the payload's live colourways and the overlapping events holding any of
+// them.
+func readMarkdownProducts(ctx context.Context, db dependency.DB, ins entity.MarkdownEventInsert, selfID int) (map[int]entity.MarkdownSource, map[int]string, error) {
+	if len(ins.ProductIds) == 0 {
+		return map[int]entity.MarkdownSource{}, map[int]string{}, nil
+	}
+	rows, err := storeutil.QueryListNamed[entity.MarkdownSource](ctx, db, `
+		SELECT p.id AS product_id, p.sku, p.color, p.lifecycle_status, COALESCE(p.sale_percentage, 0) AS sale_percentage,
+		       b.price AS base_price
+		FROM product p
+		LEFT JOIN product_price b ON b.product_id = p.id AND UPPER(b.currency) = :base
+		WHERE p.id IN (:ids) AND p.deleted_at IS NULL AND p.lifecycle_status <> :archived`,
+		map[string]any{
+			"ids":      ins.ProductIds,
+			"base":     strings.ToUpper(cache.GetBaseCurrency()),
+			"archived": entity.ColorwayStatusArchived,
+		})
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't read markdown colourways: %w", err)
+	}
+	sources := make(map[int]entity.MarkdownSource, len(rows))
+	for _, r := range rows {
+		sources[r.ProductId] = r
+	}
+	overlaps, err := storeutil.QueryListNamed[struct {
+		ProductId int    `db:"product_id"`
+		Name      string `db:"name"`
+	}](ctx, db, `
+		SELECT mep.product_id, e.name
+		FROM markdown_event_product mep
+		JOIN markdown_event e ON e.id = mep.event_id
+		WHERE mep.product_id IN (:ids) AND e.id <> :self
+		  AND e.status IN ('scheduled', 'active')
+		  AND e.starts_at < :endsAt AND e.ends_at > :startsAt`,
+		map[string]any{
+			"ids":      ins.ProductIds,
+			"self":     selfID,
+			"startsAt": ins.StartsAt,
+			"endsAt":   ins.EndsAt,
+		})
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't check overlapping markdowns: %w", err)
+	}
+	conflicts := make(map[int]string, len(overlaps))
+	for _, o := range overlaps {
+		conflicts[o.ProductId] = o.Name
+	}
+	return sources, conflicts, nil
+}
+
+// checkMarkdownProducts refuses a colourway that does not exist (or is archived) and one already in
+// an overlapping event: two markdowns on one colourway would each revert to the other's price.
+func checkMarkdownProducts(ctx context.Context, db dependency.DB, ins entity.MarkdownEventInsert, selfID int) error {
+	sources, conflicts, err := readMarkdownProducts(ctx, db, ins, selfID)
+	if err != nil {
+		return err
+	}
+	for i, id := range ins.ProductIds {
+		field := fmt.Sprintf("product_ids[%d]", i)
+		if _, ok := sources[id]; !ok {
+			return entity.NewFieldViolation(field, "not_found", fmt.Sprint(id), "mark down a colourway that exists and is not archived")
+		}
+		if name, ok := conflicts[id]; ok {
+			return entity.NewFieldViolation(field, "overlapping_markdown", name, "move the window or take the colourway out of the other markdown")
+		}
+	}
+	return nil
+}
+
+func insertMarkdownProducts(ctx context.Context, db dependency.DB, eventID int, ids []int) error {
+	rows := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, map[string]any{"event_id": eventID, "product_id": id})
+	}
+	if err := storeutil.BulkInsert(ctx, db, "markdown_event_product", rows); err != nil {
+		return fmt.Errorf("can't insert markdown event products: %w", err)
+	}
+	return nil
+}
+
+// applyMarkdown remembers each live colourway's sale percentage and sets the event's. A colourway
+// archived since the event was scheduled is left alone and keeps a NULL prev, which the revert skips.
+func applyMarkdown(ctx context.Context, db dependency.DB, ev *entity.MarkdownEvent) ([]int, error) {
+	params := map[string]any{"id": ev.Id, "pct": ev.SalePercentage, "archived": entity.ColorwayStatusArchived}
+	ids, err := storeutil.QueryScalarListNamed[int](ctx, db, `
+		SELECT p.id FROM markdown_event_product mep
+		JOIN product p ON p.id = mep.product_id
+		WHERE mep.event_id = :id AND p.deleted_at IS NULL AND p.lifecycle_status <> :archived
+		FOR UPDATE`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't lock markdown colourways: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	params["ids"] = ids
+	if err := storeutil.ExecNamed(ctx, db, `
+		UPDATE markdown_event_product mep
+		JOIN product p ON p.id = mep.product_id
+		SET mep.prev_sale_percentage = COALESCE(p.sale_percentage, 0)
+		WHERE mep.event_id = :id AND p.id IN (:ids)`, params); err != nil {
+		return nil, fmt.Errorf("can't record markdown previous sale: %w", err)
+	}
+	if err := storeutil.ExecNamed(ctx, db, `
+		UPDATE product SET sale_percentage = :pct WHERE id IN (:ids)`, params); err != nil {
+		return nil, fmt.Errorf("can't apply markdown: %w", err)
+	}
+	return ids, nil
+}
+
+// revertMarkdown puts back the remembered sale percentage wherever it is still the event's. A
+// colourway whose percentage somebody changed during the event keeps it, and is flagged.
+func revertMarkdown(ctx context.Context, db dependency.DB, ev *entity.MarkdownEvent) ([]int, error) {
+	params := map[string]any{"id": ev.Id, "pct": ev.SalePercentage}
+	if err := storeutil.ExecNamed(ctx, db, `
+		UPDATE markdown_event_product mep
+		JOIN product p ON p.id = mep.product_id
+		SET mep.revert_skipped = (COALESCE(p.sale_percentage, 0) <> :pct)
+		WHERE mep.event_id = :id AND mep.prev_sale_percentage IS NOT NULL`, params); err != nil {
+		return nil, fmt.Errorf("can't check markdown colourways: %w", err)
+	}
+	ids, err := storeutil.QueryScalarListNamed[int](ctx, db, `
+		SELECT product_id FROM markdown_event_product
+		WHERE event_id = :id AND prev_sale_percentage IS NOT NULL AND NOT revert_skipped`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't list markdown colourways: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := storeutil.ExecNamed(ctx, db, `
+		UPDATE product p
+		JOIN markdown_event_product mep ON mep.product_id = p.id AND mep.event_id = :id
+		SET p.sale_percentage = mep.prev_sale_percentage
+		WHERE mep.prev_sale_percentage IS NOT NULL AND NOT mep.revert_skipped`, params); err != nil {
+		return nil, fmt.Errorf("can't revert markdown: %w", err)
+	}
+	return ids, nil
+}
+
+func setMarkdownStatus(ctx context.Context, db dependency.DB, ev *entity.MarkdownEvent, status entity.MarkdownStatus, now time.Time) error {
+	var column string
+	switch {
+	case status == entity.MarkdownActive:
+		column = ", applied_at = :now"
+	case ev.Status == entity.MarkdownActive:
+		column = ", reverted_at = :now"
+	}
+	if err := storeutil.ExecNamed(ctx, db, `UPDATE markdown_event SET status = :status`+column+` WHERE id = :id`,
+		map[string]any{"id": ev.Id, "status": status, "now": now}); err != nil {
+		return fmt.Errorf("can't set markdown event status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+// Package pricing implements market price lists and scheduled markdowns (0349).
+//
+// A price list derives one currency's colourway prices (product_price) from the base-currency price
+// through the costing FX rate, the market's markup and a rounding rule; a publish writes them. A
+// markdown event sets product.sale_percentage on its colourways for a window and puts the previous
+// value back when the window closes. Neither touches anything outside a publish or a sweep: between
+// them, product_price and sale_percentage remain hand-editable as before.
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.Pricing.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new pricing store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const selectPriceList = `
+	SELECT id, name, market, currency, markup_pct, rounding, note,
+	       last_published_at, last_published_by, last_published_rate, created_by, created_at, updated_at
+	FROM price_list`
+
+// ListPriceLists returns every list by currency, without pins.
+func (s *Store) ListPriceLists(ctx context.Context) ([]entity.PriceList, error) {
+	out, err := storeutil.QueryListNamed[entity.PriceList](ctx, s.DB, selectPriceList+` ORDER BY currency`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't list price lists: %w", err)
+	}
+	return out, nil
+}
+
+// GetPriceList returns one list with its pins.
+func (s *Store) GetPriceList(ctx context.Context, id int) (*entity.PriceList, error) {
+	return getPriceList(ctx, s.DB, id, false)
+}
+
+// CreatePriceList stores a new list and returns its id.
+func (s *Store) CreatePriceList(ctx context.Context, ins entity.PriceListInsert) (int, error) {
+	if err := entity.ValidatePriceListInsert(&ins, cache.GetBaseCurrency()); err != nil {
+		return 0, err
+	}
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		if err := checkCurrencyFree(ctx, rep.DB(), ins.Currency, 0); err != nil {
+			return err
+		}
+		if err := checkPinProducts(ctx, rep.DB(), ins.Pins); err != nil {
+			return err
+		}
+		var err error
+		id, err = storeutil.ExecNamedLastId(ctx, rep.DB(), `
+			INSERT INTO price_list (name, market, currency, markup_pct, rounding, note, created_by)
+			VALUES (:name, :market, :currency, :markupPct, :rounding, :note, :createdBy)`,
+			map[string]any{
+				"name":      ins.Name,
+				"market":    ins.Market,
+				"currency":  ins.Currency,
+				"markupPct": ins.MarkupPct,
+				"rounding":  ins.Rounding,
+				"note":      ins.Note,
+				"createdBy": ins.CreatedBy,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert price list: %w", err)
+		}
+		return insertPins(ctx, rep.DB(), id, ins.Pins, ins.CreatedBy)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdatePriceList replaces a list's rule and pins. Published prices stay until the next publish.
+func (s *Store) UpdatePriceList(ctx context.Context, id int, ins entity.PriceListInsert) error {
+	if err := entity.ValidatePriceListInsert(&ins, cache.GetBaseCurrency()); err != nil {
+		return err
+	}
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		if _, err := getPriceList(ctx, rep.DB(), id, true); err != nil {
+			return err
+		}
+		if err := checkCurrencyFree(ctx, rep.DB(), ins.Currency, id); err != nil {
+			return err
+		}
+		if err := checkPinProducts(ctx, rep.DB(), ins.Pins); err != nil {
+			return err
+		}
+		err := storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE price_list
+			SET name = :name, market = :market, currency = :currency, markup_pct = :markupPct,
+			    rounding = :rounding, note = :note
+			WHERE id = :id`,
+			map[string]any{
+				"id":        id,
+				"name":      ins.Name,
+				"market":    ins.Market,
+				"currency":  ins.Currency,
+				"markupPct": ins.MarkupPct,
+				"rounding":  ins.Rounding,
+				"note":      ins.Note,
+			})
+		if err != nil {
+			return fmt.Errorf("can't update price list: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, rep.DB(), `DELETE FROM price_list_pin WHERE price_list_id = :id`,
+			map[string]any{"id": id}); err != nil {
+			return fmt.Errorf("can't delete price list pins: %w", err)
+		}
+		return insertPins(ctx, rep.DB(), id, ins.Pins, ins.CreatedBy)
+	})
+}
+
+// DeletePriceList removes a list and its pins. The prices it published stay in product_price: they
+// are the colourways' prices now, not the list's.
+func (s *Store) DeletePriceList(ctx context.Context, id int) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `DELETE FROM price_list WHERE id = :id`, map[string]any{"id": id})
+	if err != nil {
+		return fmt.Errorf("can't delete price list: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", entity.ErrPriceListNotFound, id)
+	}
+	return nil
+}
+
+// PreviewPriceList computes every line a publish would write, at today's rate.
+func (s *Store) PreviewPriceList(ctx context.Context, id int) (*entity.PriceListPreview, error) {
+	list, err := getPriceList(ctx, s.DB, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return previewPriceList(ctx, s.DB, list)
+}
+
+// PublishPriceList writes the list's prices into product_price and returns what it wrote. When
+// expectedRate is set it must equal the rate the publish uses — the caller confirms the numbers it
+// previewed, not whatever the ECB feed said since.
+func (s *Store) PublishPriceList(ctx context.Context, id int, expectedRate decimal.NullDecimal, username string) (*entity.PriceListPreview, error) {
+	var preview *entity.PriceListPreview
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		list, err := getPriceList(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		preview, err = previewPriceList(ctx, rep.DB(), list)
+		if err != nil {
+			return err
+		}
+		if !preview.RateToBase.Valid {
+			return fmt.Errorf("%w: %s", entity.ErrPriceListRateMissing, list.Currency)
+		}
+		if expectedRate.Valid && !expectedRate.Decimal.Equal(preview.RateToBase.Decimal) {
+			return fmt.Errorf("%w: previewed %s, now %s", entity.ErrPriceListRateMoved, expectedRate.Decimal, preview.RateToBase.Decimal)
+		}
+		rows := make([][]any, 0, len(preview.Lines))
+		for _, l := range preview.Lines {
+			if l.Changed() {
+				rows = append(rows, []any{l.ProductId, list.Currency, l.NewPrice.Decimal})
+			}
+		}
+		if err := storeutil.BulkUpsert(ctx, rep.DB(), "product_price",
+			[]string{"product_id", "currency", "price"}, []string{"price"}, rows); err != nil {
+			return fmt.Errorf("can't write price list prices: %w", err)
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE price_list
+			SET last_published_at = :now, last_published_by = :username, last_published_rate = :rate
+			WHERE id = :id`,
+			map[string]any{
+				"id":       id,
+				"now":      s.Now(),
+				"username": username,
+				"rate":     preview.RateToBase.Decimal,
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+func getPriceList(ctx context.Context, db dependency.DB, id int, forUpdate bool) (*entity.PriceList, error) {
+	query := selectPriceList + ` WHERE id = :id`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	list, err := storeutil.QueryNamedOne[entity.PriceList](ctx, db, query, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", entity.ErrPriceListNotFound, id)
+		}
+		return nil, fmt.Errorf("can't get price list: %w", err)
+	}
+	list.Pins, err = storeutil.QueryListNamed[entity.PriceListPin](ctx, db, `
+		SELECT product_id, price FROM price_list_pin WHERE price_list_id = :id ORDER BY product_id`,
+		map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("can't get price list pins: %w", err)
+	}
+	return &list, nil
+}
+
+// previewPriceList reads every live colourway (archived and deleted ones keep whatever they had) with
+// its base price and its current price in the list's currency, and today's rate.
+func previewPriceList(ctx context.Context, db dependency.DB, list *entity.PriceList) (*entity.PriceListPreview, error) {
+	rate, err := rateToBase(ctx, db, list.Currency)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := storeutil.QueryListNamed[entity.PriceListSource](ctx, db, `
+		SELECT p.id AS product_id, p.sku, p.color, b.price AS base_price, c.price AS current_price
+		FROM product p
+		LEFT JOIN product_price b ON b.product_id = p.id AND UPPER(b.currency) = :base
+		LEFT JOIN product_price c ON c.product_id = p.id AND UPPER(c.currency) = :cur
+		WHERE p.deleted_at IS NULL AND p.lifecycle_status <> :archived
+		ORDER BY p.sku, p.id`,
+		map[string]any{
+			"base":     strings.ToUpper(cache.GetBaseCurrency()),
+			"cur":      list.Currency,
+			"archived": entity.ColorwayStatusArchived,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't read price list colourways: %w", err)
+	}
+	return &entity.PriceListPreview{
+		List:       *list,
+		RateToBase: rate,
+		Lines:      entity.BuildPriceListLines(*list, rate, sources),
+	}, nil
+}
+
+// rateToBase is the currency's effective costing FX rate — the same one tech-card costing folds by.
+func rateToBase(ctx context.Context, db dependency.DB, cur string) (decimal.NullDecimal, error) {
+	r, err := storeutil.QueryNamedOne[struct {
+		Rate decimal.Decimal `db:"rate_to_base"`
+	}](ctx, db, `
+		SELECT rate_to_base FROM costing_fx_rate
+		WHERE UPPER(currency) = :cur AND valid_from <= CURDATE()
+		ORDER BY valid_from DESC LIMIT 1`, map[string]any{"cur": cur})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return decimal.NullDecimal{}, nil
+		}
+		return decimal.NullDecimal{}, fmt.Errorf("can't get fx rate for %s: %w", cur, err)
+	}
+	return decimal.NullDecimal{Decimal: r.Rate, Valid: true}, nil
+}
+
+func checkCurrencyFree(ctx context.Context, db dependency.DB, cur string, selfID int) error {
+	n, err := storeutil.QueryCountNamed(ctx, db, `
+		SELECT COUNT(*) FROM price_list WHERE currency = :cur AND id <> :self`,
+		map[string]any{"cur": cur, "self": selfID})
+	if err != nil {
+		return fmt.Errorf("can't check price list currency: %w", err)
+	}
+	if n > 0 {
+		return entity.NewFieldViolation("currency", "taken", cur, "a currency has one price list; edit the existing one")
+	}
+	return nil
+}
+
+func checkPinProducts(ctx context.Context, db dependency.DB, pins []entity.PriceListPin) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(pins))
+	for _, p := range pins {
+		ids = append(ids, p.ProductId)
+	}
+	found, err := storeutil.QueryScalarListNamed[int](ctx, db, `
+		SELECT id FROM product WHERE id IN (:ids) AND deleted_at IS NULL`, map[string]any{"ids": ids})
+	if err != nil {
+		return fmt.Errorf("can't check pinned colourways: %w", err)
+	}
+	have := make(map[int]bool, len(found))
+	for _, id := range found {
+		have[id] = true
+	}
+	for i, p := range pins {
+		if !have[p.ProductId] {
+			return entity.NewFieldViolation(fmt.Sprintf("pins[%d].product_id", i), "not_found", fmt.Sprint(p.ProductId), "pin a colourway that exists")
+		}
+	}
+	return nil
+}
+
+func insertPins(ctx context.Context, db dependency.DB, listID int, pins []entity.PriceListPin, username string) error {
+	rows := make([]map[string]any, 0, len(pins))
+	for _, p := range pins {
+		rows = append(rows, map[string]any{
+			"price_list_id": listID,
+			"product_id":    p.ProductId,
+			"price":         p.Price,
+			"created_by":    username,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := storeutil.BulkInsert(ctx, db, "price_list_pin", rows); err != nil {
+		return fmt.Errorf("can't insert price list pins: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMarketPricingAndMarkdowns covers 0349: a price list previews each colourway's derived price at
+// today's rate and refuses a publish at a rate the caller did not preview; a markdown applies when its
+// window opens, refuses an overlapping markdown on the same colourway, and on close reverts only the
+// colourways nobody touched by hand.
+//
+// The price list is never published here: a publish rewrites the currency's price of EVERY live
+// colourway, which a shared test database cannot afford.
+//
+// SAFE ONLY against a local container DSN — see the guard and mysql_test.go / project memory.
+func TestMarketPricingAndMarkdowns(t *testing.T) {
+	if os.Getenv("CI") == "" &&
+		!strings.Contains(testCfg.DSN, "127.0.0.1") &&
+		!strings.Contains(testCfg.DSN, "localhost") {
+		t.Skip("skipping outside CI unless the DSN targets a local container (avoids the configured prod DB)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	di, err := s.Cache().GetDictionaryInfo(ctx)
+	require.NoError(t, err)
+	hf, err := s.Hero().GetHero(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cache.InitConsts(ctx, di, hf))
+	base := cache.GetBaseCurrency()
+
+	exec := func(q string, args ...any) int {
+		res, err := testDB.ExecContext(ctx, q, args...)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return int(id)
+	}
+	token := fmt.Sprintf("%d%04d", time.Now().UnixNano(), rand.Intn(10000))
+	mediaID, err := s.Media().AddMedia(ctx, &entity.MediaItem{
+		FullSizeMediaURL: "https://x/f.jpg", FullSizeWidth: 1, FullSizeHeight: 1,
+		ThumbnailMediaURL: "https://x/t.jpg", ThumbnailWidth: 1, ThumbnailHeight: 1,
+		CompressedMediaURL: "https://x/c.jpg", CompressedWidth: 1, CompressedHeight: 1,
+		BlurHash: sql.NullString{String: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", Valid: true},
+	})
+	require.NoError(t, err)
+	styleID := exec(`INSERT INTO tech_card (style_number, name, brand, collection, season_code, season_year, season, target_gender, top_category_id)
+		VALUES (CONCAT('MD-', UUID_SHORT()), 'MD', 'ACME', '', 'SS', 2026, 'SS26', 'unisex', 1)`)
+	product := func(suffix string, salePct string) int {
+		pid := exec(`INSERT INTO product (sku, color, color_code, color_hex, country_of_origin, thumbnail_id, style_id, lifecycle_status, sale_percentage)
+			VALUES (?, 'c', 'BLK', '#000000', 'US', ?, ?, 2, ?)`, "MD"+suffix+"-"+token, mediaID, styleID, salePct)
+		exec(`INSERT INTO product_price (product_id, currency, price) VALUES (?, ?, 100.00)`, pid, base)
+		return pid
+	}
+	p1, p2 := product("A", "10.00"), product("B", "0.00")
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM markdown_event WHERE name LIKE ?", "MD-"+token+"%")
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM price_list WHERE name = ?", "PL-"+token)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_price WHERE product_id IN (?, ?)", p1, p2)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product WHERE id IN (?, ?)", p1, p2)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM tech_card WHERE id = ?", styleID)
+	})
+	P := s.Pricing()
+
+	t.Run("price list preview and publish guard", func(t *testing.T) {
+		cur := "JPY"
+		if strings.EqualFold(base, cur) {
+			cur = "KRW"
+		}
+		listID, err := P.CreatePriceList(ctx, entity.PriceListInsert{
+			Name: "PL-" + token, Currency: strings.ToLower(cur), MarkupPct: decimal.NewFromInt(10), Rounding: entity.PriceRoundingEnd9,
+		})
+		require.NoError(t, err)
+
+		var ve *entity.ValidationError
+		_, err = P.CreatePriceList(ctx, entity.PriceListInsert{Name: "PL-dup", Currency: cur})
+		require.True(t, errors.As(err, &ve), "a second list of one currency must be refused: %v", err)
+		require.Equal(t, "taken", ve.Reason)
+
+		pv, err := P.PreviewPriceList(ctx, listID)
+		require.NoError(t, err)
+		var line *entity.PriceListLine
+		for i := range pv.Lines {
+			if pv.Lines[i].ProductId == p1 {
+				line = &pv.Lines[i]
+			}
+		}
+		require.NotNil(t, line, "a live colourway must be in the preview")
+		if !pv.RateToBase.Valid {
+			require.Contains(t, line.Skip, "no FX rate")
+			_, err = P.PublishPriceList(ctx, listID, decimal.NullDecimal{}, "tester")
+			require.ErrorIs(t, err, entity.ErrPriceListRateMissing)
+			return
+		}
+		want, ok := entity.DerivePrice(decimal.NewFromInt(100), pv.RateToBase.Decimal, decimal.NewFromInt(10), entity.PriceRoundingEnd9, cur)
+		require.True(t, ok)
+		require.True(t, line.NewPrice.Decimal.Equal(want), "derived %s, want %s", line.NewPrice.Decimal, want)
+
+		stale := decimal.NullDecimal{Decimal: pv.RateToBase.Decimal.Add(decimal.NewFromInt(1)), Valid: true}
+		_, err = P.PublishPriceList(ctx, listID, stale, "tester")
+		require.ErrorIs(t, err, entity.ErrPriceListRateMoved)
+	})
+
+	t.Run("markdown applies and reverts", func(t *testing.T) {
+		now := time.Now().UTC().Truncate(time.Second)
+		evID, err := P.CreateMarkdownEvent(ctx, entity.MarkdownEventInsert{
+			Name: "MD-" + token, SalePercentage: decimal.NewFromInt(30),
+			StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour),
+			ProductIds: []int{p1, p2}, CreatedBy: "tester",
+		})
+		require.NoError(t, err)
+
+		var ve *entity.ValidationError
+		_, err = P.CreateMarkdownEvent(ctx, entity.MarkdownEventInsert{
+			Name: "MD-" + token + "-overlap", SalePercentage: decimal.NewFromInt(20),
+			StartsAt: now.Add(30 * time.Minute), EndsAt: now.Add(2 * time.Hour), ProductIds: []int{p2},
+		})
+		require.True(t, errors.As(err, &ve), "an overlapping markdown must be refused: %v", err)
+		require.Equal(t, "overlapping_markdown", ve.Reason)
+
+		salePct := func(pid int) string {
+			var v decimal.Decimal
+			require.NoError(t, testDB.QueryRowContext(ctx, "SELECT sale_percentage FROM product WHERE id = ?", pid).Scan(&v))
+			return v.StringFixed(2)
+		}
+
+		sweep, err := P.ApplyDueMarkdownEvents(ctx, now)
+		require.NoError(t, err)
+		require.Contains(t, sweep.Started, evID)
+		require.ElementsMatch(t, []int{p1, p2}, filterIDs(sweep.Products, p1, p2))
+		require.Equal(t, "30.00", salePct(p1))
+		require.Equal(t, "30.00", salePct(p2))
+		require.ErrorIs(t, P.UpdateMarkdownEvent(ctx, evID, entity.MarkdownEventInsert{
+			Name: "MD-" + token, SalePercentage: decimal.NewFromInt(40),
+			StartsAt: now, EndsAt: now.Add(time.Hour), ProductIds: []int{p1},
+		}), entity.ErrMarkdownEventNotEditable)
+
+		// Somebody re-prices p2 by hand during the markdown: the revert must leave it.
+		_, err = testDB.ExecContext(ctx, "UPDATE product SET sale_percentage = 45 WHERE id = ?", p2)
+		require.NoError(t, err)
+
+		sweep, err = P.ApplyDueMarkdownEvents(ctx, now.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Contains(t, sweep.Ended, evID)
+		require.Equal(t, "10.00", salePct(p1))
+		require.Equal(t, "45.00", salePct(p2))
+
+		ev, err := P.GetMarkdownEvent(ctx, evID)
+		require.NoError(t, err)
+		require.Equal(t, entity.MarkdownEnded, ev.Status)
+		require.True(t, ev.AppliedAt.Valid && ev.RevertedAt.Valid)
+		for _, p := range ev.Products {
+			require.Equal(t, p.ProductId == p2, p.RevertSkipped, "product %d", p.ProductId)
+		}
+	})
+}
+
+// filterIDs keeps the ids of this test's colourways — a sweep also moves whatever else is due.
+func filterIDs(ids []int, keep ...int) []int {
+	var out []int
+	for _, id := range ids {
+		for _, k := range keep {
+			if id == k {
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
@@ -156,6 +156,7 @@ type colorwayDeletionFactsRow struct {
 	LabDipRounds     int `db:"lab_dip_rounds"`
 	CostEvents       int `db:"cost_events"`
 	Waitlist         int `db:"waitlist"`
+	Wishlist         int `db:"wishlist"`
 	StockHistory     int `db:"stock_history"`
 	StyleLinks       int `db:"style_links"`
 
@@ -208,6 +209,7 @@ func readColorwayDeletionFacts(ctx context.Context, db dependency.DB, colorwayID
 			(SELECT COUNT(*) FROM product_lab_dip_round r WHERE r.product_id = p.id) AS lab_dip_rounds,
 			(SELECT COUNT(*) FROM product_cost_event e WHERE e.product_id = p.id) AS cost_events,
 			(SELECT COUNT(*) FROM product_waitlist w WHERE w.product_id = p.id) AS waitlist,
+			(SELECT COUNT(*) FROM storefront_wishlist_item wi WHERE wi.product_id = p.id) AS wishlist,
 			(SELECT COUNT(*) FROM product_stock_change_history h WHERE h.product_id = p.id) AS stock_history,
 			(SELECT COUNT(*) FROM tech_card_product tp WHERE tp.product_id = p.id) AS style_links,
 
@@ -272,6 +274,7 @@ func readColorwayDeletionFacts(ctx context.Context, db dependency.DB, colorwayID
 			LabDipRounds:     row.LabDipRounds,
 			CostEvents:       row.CostEvents,
 			Waitlist:         row.Waitlist,
+			Wishlist:         row.Wishlist,
 			StockHistory:     row.StockHistory,
 			StyleLinks:       row.StyleLinks,
 		},
@@ -0,0 +1,171 @@
+package product
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// ПОИСК ВИТРИНЫ — the documents the storefront search index is built from. Visibility matches the
+// paged storefront listing: ACTIVE colourways with a thumbnail; tier gating is applied per viewer by
+// the index, from the min_tier / hidden_for_non_qualified columns loaded here.
+
+// searchFingerprintQuery summarises every table a search document is read from. It changes when a
+// colourway, style, translation, tag, size, price or category name is written, so the indexer can
+// poll it cheaply and rebuild only when the catalogue moved.
+const searchFingerprintQuery = `
+	SELECT CONCAT_WS('|',
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(updated_at), '')) FROM product),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(updated_at), '')) FROM tech_card),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(updated_at), '')) FROM product_translation),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(id), 0)) FROM product_tag),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(id), 0)) FROM product_size),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(updated_at), '')) FROM product_price),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(updated_at), '')) FROM category_translation),
+		(SELECT CONCAT(COUNT(*), ':', COALESCE(MAX(id), 0), ':', COALESCE(SUM(percent), 0)) FROM style_composition)
+	) AS fingerprint`
+
+// SearchFingerprint returns an opaque summary of the searchable catalogue; equal fingerprints mean
+// the search documents have not changed.
+func (s *Store) SearchFingerprint(ctx context.Context) (string, error) {
+	fp, err := storeutil.QueryScalarListNamed[string](ctx, s.DB, searchFingerprintQuery, map[string]any{})
+	if err != nil {
+		return "", fmt.Errorf("can't get search fingerprint: %w", err)
+	}
+	if len(fp) == 0 {
+		return "", nil
+	}
+	return fp[0], nil
+}
+
+// ListSearchDocuments loads a search document for every storefront-visible colourway.
+func (s *Store) ListSearchDocuments(ctx context.Context) ([]entity.SearchDocument, error) {
+	docs, err := storeutil.QueryListNamed[entity.SearchDocument](ctx, s.DB, `
+		SELECT
+			p.id, COALESCE(p.sku, '') AS sku, COALESCE(sty.style_number, '') AS style_number,
+			COALESCE(sty.brand, '') AS brand, COALESCE(p.color, '') AS color, COALESCE(p.color_code, '') AS color_code,
+			COALESCE(sty.collection, '') AS collection,
+			COALESCE(`+styleCompositionSelect+`, '') AS composition,
+			COALESCE((SELECT GROUP_CONCAT(COALESCE(f.name, sc.fiber_code) SEPARATOR ' ')
+				FROM style_composition sc LEFT JOIN fiber f ON f.code = sc.fiber_code
+				WHERE sc.tech_card_id = sty.id), '') AS fibers,
+			COALESCE(sty.target_gender, '') AS target_gender, COALESCE(sty.season_code, '') AS season_code,
+			sty.top_category_id, COALESCE(sty.sub_category_id, 0) AS sub_category_id, COALESCE(sty.type_id, 0) AS type_id,
+			p.sale_percentage, (p.preorder IS NOT NULL AND p.preorder <> '') AS preorder,
+			p.min_tier, p.hidden_for_non_qualified, p.created_at
+		FROM product p
+		JOIN tech_card sty ON sty.id = p.style_id
+		JOIN media m ON p.thumbnail_id = m.id
+		WHERE p.lifecycle_status = 2
+		ORDER BY p.id`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get search documents: %w", err)
+	}
+	if len(docs) == 0 {
+		return docs, nil
+	}
+
+	translations, err := storeutil.QueryListNamed[entity.SearchTranslation](ctx, s.DB, `
+		SELECT pt.product_id, pt.language_id, pt.name, pt.description
+		FROM product_translation pt
+		JOIN product p ON p.id = pt.product_id
+		WHERE p.lifecycle_status = 2
+		ORDER BY pt.product_id, pt.language_id`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get search translations: %w", err)
+	}
+
+	type productText struct {
+		ProductId int    `db:"product_id"`
+		Text      string `db:"text"`
+	}
+	tags, err := storeutil.QueryListNamed[productText](ctx, s.DB, `
+		SELECT pt.product_id, pt.tag AS text
+		FROM product_tag pt
+		JOIN product p ON p.id = pt.product_id
+		WHERE p.lifecycle_status = 2
+		ORDER BY pt.product_id, pt.tag`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get search tags: %w", err)
+	}
+
+	// Category names in the dictionary and every translation, for the top, sub and type levels.
+	categories, err := storeutil.QueryListNamed[productText](ctx, s.DB, `
+		SELECT p.id AS product_id, c.name AS text
+		FROM product p
+		JOIN tech_card sty ON sty.id = p.style_id
+		JOIN category c ON c.id IN (sty.top_category_id, sty.sub_category_id, sty.type_id)
+		WHERE p.lifecycle_status = 2
+		UNION
+		SELECT p.id AS product_id, ct.name AS text
+		FROM product p
+		JOIN tech_card sty ON sty.id = p.style_id
+		JOIN category_translation ct ON ct.category_id IN (sty.top_category_id, sty.sub_category_id, sty.type_id)
+		WHERE p.lifecycle_status = 2`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get search categories: %w", err)
+	}
+
+	type productSize struct {
+		ProductId int `db:"product_id"`
+		SizeId    int `db:"size_id"`
+	}
+	sizes, err := storeutil.QueryListNamed[productSize](ctx, s.DB, `
+		SELECT ps.product_id, ps.size_id
+		FROM product_size ps
+		JOIN product p ON p.id = ps.product_id
+		WHERE p.lifecycle_status = 2 AND ps.grade = 'A'
+		ORDER BY ps.product_id, ps.size_id`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get search sizes: %w", err)
+	}
+
+	type productPrice struct {
+		ProductId int             `db:"product_id"`
+		Currency  string          `db:"currency"`
+		Price     decimal.Decimal `db:"price"`
+	}
+	prices, err := storeutil.QueryListNamed[productPrice](ctx, s.DB, `
+		SELECT pp.product_id, pp.currency, pp.price
+		FROM product_price pp
+		JOIN product p ON p.id = pp.product_id
+		WHERE p.lifecycle_status = 2`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get search prices: %w", err)
+	}
+
+	byID := make(map[int]*entity.SearchDocument, len(docs))
+	for i := range docs {
+		docs[i].Prices = map[string]decimal.Decimal{}
+		byID[docs[i].ProductId] = &docs[i]
+	}
+	for _, t := range translations {
+		if d, ok := byID[t.ProductId]; ok {
+			d.Translations = append(d.Translations, t)
+		}
+	}
+	for _, t := range tags {
+		if d, ok := byID[t.ProductId]; ok {
+			d.Tags = append(d.Tags, t.Text)
+		}
+	}
+	for _, c := range categories {
+		if d, ok := byID[c.ProductId]; ok {
+			d.CategoryNames = append(d.CategoryNames, c.Text)
+		}
+	}
+	for _, sz := range sizes {
+		if d, ok := byID[sz.ProductId]; ok {
+			d.SizeIds = append(d.SizeIds, sz.SizeId)
+		}
+	}
+	for _, p := range prices {
+		if d, ok := byID[p.ProductId]; ok {
+			d.Prices[p.Currency] = p.Price
+		}
+	}
+	return docs, nil
+}
@@ -99,6 +99,8 @@ func (s *Store) ReduceStockForProductSizes(ctx context.Context, items []entity.O
 				entry.Reason = sql.NullString{String: string(entity.StockChangeReasonOrder), Valid: true}
 			case entity.StockChangeSourceOrderCustom:
 				entry.Reason = sql.NullString{String: string(entity.StockChangeReasonCustomOrder), Valid: true}
+			case entity.StockChangeSourceOrderExchange:
+				entry.Reason = sql.NullString{String: string(entity.StockChangeReasonExchange), Valid: true}
 			}
 			historyEntries = append(historyEntries, entry)
 		}
@@ -154,6 +156,8 @@ func (s *Store) RestoreStockForProductSizes(ctx context.Context, items []entity.
 				entry.Reason = sql.NullString{String: string(entity.StockChangeReasonReturnToStock), Valid: true}
 			case entity.StockChangeSourceOrderCancelled:
 				entry.Reason = sql.NullString{String: string(entity.StockChangeReasonOrderCancelled), Valid: true}
+			case entity.StockChangeSourceOrderExchange:
+				entry.Reason = sql.NullString{String: string(entity.StockChangeReasonExchange), Valid: true}
 			}
 			historyEntries = append(historyEntries, entry)
 		}
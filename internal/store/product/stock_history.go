@@ -55,6 +55,7 @@ func (s *Store) RecordStockChange(ctx context.Context, entries []entity.StockCha
 		setNullableDecimal(row, "paid_amount", e.PaidAmount)
 		setNullableDecimal(row, "payout_base_amount", e.PayoutBaseAmount)
 		setNullableString(row, "payout_base_currency", e.PayoutBaseCurrency)
+		setNullableInt32(row, "location_id", e.LocationId)
 		rows = append(rows, row)
 	}
 	return storeutil.BulkInsert(ctx, s.DB, "product_stock_change_history", rows)
@@ -87,8 +88,10 @@ func (s *Store) RecordShippingStockChange(ctx context.Context, history *entity.S
 	return s.RecordStockChange(ctx, []entity.StockChangeInsert{entry})
 }
 
-// GetStockChangeHistory returns paginated stock change history with optional filters.
-func (s *Store) GetStockChangeHistory(ctx context.Context, productId, sizeId *int, dateFrom, dateTo *time.Time, source string, limit, offset int, orderFactor entity.OrderFactor) ([]entity.StockChange, int, error) {
+// GetStockChangeHistory returns paginated stock change history with optional filters. locationId
+// narrows to one stock location (0345); for the default location that includes every row without a
+// location, which is where the location-unaware writers move stock.
+func (s *Store) GetStockChangeHistory(ctx context.Context, productId, sizeId, locationId *int, dateFrom, dateTo *time.Time, source string, limit, offset int, orderFactor entity.OrderFactor) ([]entity.StockChange, int, error) {
 	baseQuery := `FROM product_stock_change_history WHERE 1=1`
 	params := map[string]any{"limit": limit, "offset": offset}
 	if productId != nil {
@@ -111,6 +114,11 @@ func (s *Store) GetStockChangeHistory(ctx context.Context, productId, sizeId *in
 		baseQuery += ` AND source = :source`
 		params["source"] = source
 	}
+	if locationId != nil {
+		baseQuery += ` AND (location_id = :locationId OR (location_id IS NULL
+			AND EXISTS (SELECT 1 FROM stock_location sl WHERE sl.id = :locationId AND sl.is_default)))`
+		params["locationId"] = *locationId
+	}
 
 	orderBy := "ORDER BY created_at DESC"
 	if orderFactor == entity.Ascending {
@@ -136,6 +144,7 @@ func (s *Store) GetStockChangeHistory(ctx context.Context, productId, sizeId *in
 		COALESCE(paid_amount, '') AS paid_amount,
 		COALESCE(payout_base_amount, '') AS payout_base_amount,
 		COALESCE(payout_base_currency, '') AS payout_base_currency,
+		COALESCE(location_id, 0) AS location_id,
 		created_at ` + baseQuery + ` ` + orderBy
 	// A non-positive or oversized limit is capped rather than dropped: this reads an
 	// append-only journal with no cleanup worker, so the "return all" path must never
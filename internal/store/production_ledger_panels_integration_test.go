@@ -201,7 +201,7 @@ func TestRefundDispositions(t *testing.T) {
 		uuid, _, itemID := seedOrder("W", prodID, sizeA)
 		before := aQty(prodID, sizeA)
 
-		require.NoError(t, s.Order().RefundOrder(ctx, uuid, []int32{int32(itemID)}, "damaged", "", false, entity.RefundDispositionWriteoff))
+		require.NoError(t, s.Order().RefundOrder(ctx, uuid, []int32{int32(itemID)}, "damaged", "", false, entity.RefundDispositionWriteoff, false))
 		require.Equal(t, before, aQty(prodID, sizeA), "A stock untouched")
 		var journalRows int
 		require.NoError(t, testDB.QueryRowContext(ctx,
@@ -221,7 +221,7 @@ func TestRefundDispositions(t *testing.T) {
 		uuid, _, itemID := seedOrder("S", prodID, sizeA)
 		before := aQty(prodID, sizeA)
 
-		require.NoError(t, s.Order().RefundOrder(ctx, uuid, []int32{int32(itemID)}, "worn but fine", "", false, entity.RefundDispositionSeconds))
+		require.NoError(t, s.Order().RefundOrder(ctx, uuid, []int32{int32(itemID)}, "worn but fine", "", false, entity.RefundDispositionSeconds, false))
 		require.Equal(t, before, aQty(prodID, sizeA), "A stock untouched")
 		var bQty string
 		require.NoError(t, testDB.QueryRowContext(ctx,
@@ -234,7 +234,7 @@ func TestRefundDispositions(t *testing.T) {
 	})
 
 	t.Run("unknown disposition refuses", func(t *testing.T) {
-		err := s.Order().RefundOrder(ctx, "no-such-order", nil, "r", "", false, "quarantine")
+		err := s.Order().RefundOrder(ctx, "no-such-order", nil, "r", "", false, "quarantine", false)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "disposition")
 	})
@@ -0,0 +1,306 @@
+package productionrun
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// ПАЧКИ И КУПОНЫ (migration 0340). Пачки прогона генерируются ЦЕЛИКОМ — набор пачек и снимок
+// операций заменяются одной транзакцией, — а сканирования пишутся по одному.
+//
+// ДВА РЕШЕНИЯ ЖИВУТ В ЭТОМ ФАЙЛЕ.
+//
+//  1. ПЕРЕГЕНЕРАЦИЯ ЗАПРЕЩЕНА ПОСЛЕ ПЕРВОГО СКАНИРОВАНИЯ. Новые пачки — это новые номера, новые
+//     слои и новые ярлыки, и сканирование, записанное против старой пачки, нельзя честно
+//     перенести ни на одну из новых. Пока сканов нет, пачки — всё ещё план и заменяются свободно;
+//     после — это факт, и исправить его можно только удалив сканирования явно.
+//
+//  2. У СКАНИРОВАНИЯ НЕТ ГВАРДА СТАТУСА, как у приёмки кроя (cut_receipt.go, решение 1): это
+//     отчёт о том, что физически произошло за швейной машиной, и он законно приходит после того,
+//     как прогон приняли. Генерация же — план, и терминальный прогон её отвергает через тот же
+//     lockRunForLay, что и настил.
+
+const bundleColumns = `b.id, b.run_id, b.bundle_no, b.lay_id, b.lay_key, COALESCE(l.name, '') AS lay_name,
+	b.section_key, b.colorway_id, COALESCE(p.color, '') AS colorway_name, b.size_id,
+	COALESCE(sz.name, '') AS size_name, b.stack_no, b.ply_from, b.ply_to, b.qty, b.created_by, b.created_at`
+
+const bundleJoins = `FROM production_run_bundle b
+	LEFT JOIN production_run_lay l ON l.id = b.lay_id
+	LEFT JOIN product p ON p.id = b.colorway_id
+	LEFT JOIN size sz ON sz.id = b.size_id`
+
+// ReplaceBundles swaps the run's bundles and operation snapshot for new ones in one transaction.
+// Bundle numbers and operation seqs are assigned by position, from 1.
+func (s *Store) ReplaceBundles(ctx context.Context, runID int, ops []entity.ProductionRunOperationInsert,
+	bundles []entity.ProductionRunBundleInsert, username string) error {
+
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		if _, err := lockRunForLay(ctx, db, runID); err != nil {
+			return err
+		}
+		scanned, err := storeutil.QueryCountNamed(ctx, db, `
+			SELECT COUNT(*)
+			FROM production_run_bundle_scan s
+			JOIN production_run_bundle b ON b.id = s.bundle_id
+			WHERE b.run_id = :run_id`, map[string]any{"run_id": runID})
+		if err != nil {
+			return fmt.Errorf("failed to count bundle scans of run %d: %w", runID, err)
+		}
+		if scanned > 0 {
+			return fmt.Errorf("%w: run %d has %d recorded operations", entity.ErrProductionRunBundlesScanned, runID, scanned)
+		}
+
+		args := map[string]any{"run_id": runID}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM production_run_bundle WHERE run_id = :run_id`, args); err != nil {
+			return fmt.Errorf("failed to delete bundles of run %d: %w", runID, err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM production_run_operation WHERE run_id = :run_id`, args); err != nil {
+			return fmt.Errorf("failed to delete operation snapshot of run %d: %w", runID, err)
+		}
+
+		opRows := make([][]any, 0, len(ops))
+		for i, op := range ops {
+			opRows = append(opRows, []any{runID, i + 1, op.OperationNumber, op.OperationType, op.Zone,
+				op.MachineType, op.Work, op.SMV, op.Note})
+		}
+		if err := storeutil.BulkInsertRows(ctx, db, "production_run_operation",
+			[]string{"run_id", "seq", "operation_number", "operation_type", "zone", "machine_type", "work", "smv", "note"},
+			opRows); err != nil {
+			return fmt.Errorf("failed to insert operation snapshot of run %d: %w", runID, err)
+		}
+
+		bundleRows := make([][]any, 0, len(bundles))
+		for i, b := range bundles {
+			bundleRows = append(bundleRows, []any{runID, i + 1, b.LayId, b.LayKey, b.SectionKey, b.ColorwayId,
+				b.SizeId, b.StackNo, b.PlyFrom, b.PlyTo, b.Qty(), username})
+		}
+		if err := storeutil.BulkInsertRows(ctx, db, "production_run_bundle",
+			[]string{"run_id", "bundle_no", "lay_id", "lay_key", "section_key", "colorway_id",
+				"size_id", "stack_no", "ply_from", "ply_to", "qty", "created_by"},
+			bundleRows); err != nil {
+			return fmt.Errorf("failed to insert bundles of run %d: %w", runID, err)
+		}
+		return nil
+	})
+}
+
+// ListBundles returns the run's operation snapshot, bundles and recorded operations. A run that was
+// never bundled answers three empty lists; a missing run is sql.ErrNoRows.
+func (s *Store) ListBundles(ctx context.Context, runID int) (*entity.ProductionRunBundleSet, error) {
+	exists, err := storeutil.QueryCountNamed(ctx, s.DB,
+		`SELECT COUNT(*) FROM production_run WHERE id = :id`, map[string]any{"id": runID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check production run %d for bundles: %w", runID, err)
+	}
+	if exists == 0 {
+		return nil, sql.ErrNoRows
+	}
+	args := map[string]any{"run_id": runID}
+	ops, err := loadRunOperations(ctx, s.DB, runID)
+	if err != nil {
+		return nil, fmt.Errorf("can't list operation snapshot of run %d: %w", runID, err)
+	}
+	bundles, err := storeutil.QueryListNamed[entity.ProductionRunBundle](ctx, s.DB, `
+		SELECT `+bundleColumns+` `+bundleJoins+`
+		WHERE b.run_id = :run_id
+		ORDER BY b.bundle_no`, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't list bundles of run %d: %w", runID, err)
+	}
+	scans, err := loadBundleScans(ctx, s.DB, "b.run_id = :run_id", args)
+	if err != nil {
+		return nil, fmt.Errorf("can't list bundle scans of run %d: %w", runID, err)
+	}
+	return &entity.ProductionRunBundleSet{Operations: ops, Bundles: bundles, Scans: scans}, nil
+}
+
+// GetBundle reads one bundle (the set's only bundle) with its run's operation snapshot and its own
+// recorded operations — what a scanned ticket shows. entity.ErrProductionRunBundleNotFound when it
+// does not exist.
+func (s *Store) GetBundle(ctx context.Context, bundleID int) (*entity.ProductionRunBundleSet, error) {
+	b, err := storeutil.QueryNamedOne[entity.ProductionRunBundle](ctx, s.DB, `
+		SELECT `+bundleColumns+` `+bundleJoins+`
+		WHERE b.id = :id`, map[string]any{"id": bundleID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrProductionRunBundleNotFound
+		}
+		return nil, fmt.Errorf("can't load bundle %d: %w", bundleID, err)
+	}
+	ops, err := loadRunOperations(ctx, s.DB, b.RunId)
+	if err != nil {
+		return nil, fmt.Errorf("can't load operation snapshot of run %d: %w", b.RunId, err)
+	}
+	scans, err := loadBundleScans(ctx, s.DB, "s.bundle_id = :bundle_id", map[string]any{"bundle_id": bundleID})
+	if err != nil {
+		return nil, fmt.Errorf("can't load scans of bundle %d: %w", bundleID, err)
+	}
+	return &entity.ProductionRunBundleSet{Operations: ops, Bundles: []entity.ProductionRunBundle{b}, Scans: scans}, nil
+}
+
+func loadRunOperations(ctx context.Context, db dependency.DB, runID int) ([]entity.ProductionRunOperation, error) {
+	return storeutil.QueryListNamed[entity.ProductionRunOperation](ctx, db, `
+		SELECT id, run_id, seq, operation_number, operation_type, zone, machine_type, work, smv, note
+		FROM production_run_operation
+		WHERE run_id = :run_id
+		ORDER BY seq`, map[string]any{"run_id": runID})
+}
+
+func loadBundleScans(ctx context.Context, db dependency.DB, where string, args map[string]any) ([]entity.ProductionRunBundleScan, error) {
+	return storeutil.QueryListNamed[entity.ProductionRunBundleScan](ctx, db, `
+		SELECT s.id, s.bundle_id, s.operation_id, o.seq AS operation_seq, s.employee_id,
+			COALESCE(e.full_name, '') AS employee_name, s.qty, s.source, s.recorded_by, s.scanned_at
+		FROM production_run_bundle_scan s
+		JOIN production_run_bundle b ON b.id = s.bundle_id
+		JOIN production_run_operation o ON o.id = s.operation_id
+		LEFT JOIN employee e ON e.id = s.employee_id
+		WHERE `+where+`
+		ORDER BY b.bundle_no, o.seq`, args)
+}
+
+// RecordBundleScan records one completed operation on one bundle, for the whole bundle quantity.
+//
+// The operation is addressed by its seq in the snapshot of the bundle's OWN run, so a coupon can
+// never complete a step of another run. The employee must be active: an archived employee does not
+// work here any more, and a scan in their name is somebody else's scan.
+func (s *Store) RecordBundleScan(ctx context.Context, ins entity.ProductionRunBundleScanInsert) (*entity.ProductionRunBundleScan, error) {
+	if ins.EmployeeId <= 0 {
+		return nil, entity.NewFieldViolation("employee_id", "required", "", "name who did the operation")
+	}
+	if ins.OperationSeq <= 0 {
+		return nil, entity.NewFieldViolation("operation_seq", "required", "", "name the operation from the coupon")
+	}
+
+	type target struct {
+		OperationId int `db:"operation_id"`
+		Qty         int `db:"qty"`
+	}
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		t, err := storeutil.QueryNamedOne[target](ctx, db, `
+			SELECT o.id AS operation_id, b.qty
+			FROM production_run_bundle b
+			JOIN production_run_operation o ON o.run_id = b.run_id AND o.seq = :seq
+			WHERE b.id = :bundle_id`,
+			map[string]any{"bundle_id": ins.BundleId, "seq": ins.OperationSeq})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				bundles, cerr := storeutil.QueryCountNamed(ctx, db,
+					`SELECT COUNT(*) FROM production_run_bundle WHERE id = :id`, map[string]any{"id": ins.BundleId})
+				if cerr != nil {
+					return fmt.Errorf("failed to check bundle %d: %w", ins.BundleId, cerr)
+				}
+				if bundles == 0 {
+					return entity.ErrProductionRunBundleNotFound
+				}
+				return fmt.Errorf("%w: seq %d", entity.ErrProductionRunOperationNotFound, ins.OperationSeq)
+			}
+			return fmt.Errorf("failed to resolve operation %d of bundle %d: %w", ins.OperationSeq, ins.BundleId, err)
+		}
+		active, err := storeutil.QueryCountNamed(ctx, db,
+			`SELECT COUNT(*) FROM employee WHERE id = :id AND archived = FALSE`, map[string]any{"id": ins.EmployeeId})
+		if err != nil {
+			return fmt.Errorf("failed to check employee %d: %w", ins.EmployeeId, err)
+		}
+		if active == 0 {
+			return entity.ErrEmployeeNotActive
+		}
+		if err := checkPayslipOpen(ctx, db, ins.EmployeeId, ins.ScannedAt); err != nil {
+			return err
+		}
+		id, err = storeutil.ExecNamedLastId(ctx, db, `
+			INSERT INTO production_run_bundle_scan
+				(bundle_id, operation_id, employee_id, qty, source, recorded_by, scanned_at)
+			VALUES (:bundle_id, :operation_id, :employee_id, :qty, :source, :recorded_by, :scanned_at)`,
+			map[string]any{
+				"bundle_id":    ins.BundleId,
+				"operation_id": t.OperationId,
+				"employee_id":  ins.EmployeeId,
+				"qty":          t.Qty,
+				"source":       string(ins.Source),
+				"recorded_by":  ins.RecordedBy,
+				"scanned_at":   ins.ScannedAt,
+			})
+		if err != nil {
+			var me *mysql.MySQLError
+			if errors.As(err, &me) && me.Number == 1062 {
+				return entity.ErrProductionRunBundleScanDuplicate
+			}
+			return fmt.Errorf("failed to record operation %d on bundle %d: %w", ins.OperationSeq, ins.BundleId, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	scans, err := loadBundleScans(ctx, s.DB, "s.id = :id", map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("can't reload bundle scan %d: %w", id, err)
+	}
+	if len(scans) == 0 {
+		return nil, entity.ErrProductionRunBundleScanNotFound
+	}
+	return &scans[0], nil
+}
+
+// DeleteBundleScan removes one recorded operation of a run — the correction of a wrong scan. A scan
+// paid by an approved payslip is refused (entity.ErrPayslipApproved): reopen the payslip first.
+func (s *Store) DeleteBundleScan(ctx context.Context, runID, scanID int) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		args := map[string]any{"id": scanID, "run_id": runID}
+		sc, err := storeutil.QueryNamedOne[struct {
+			EmployeeId int       `db:"employee_id"`
+			ScannedAt  time.Time `db:"scanned_at"`
+		}](ctx, db, `
+			SELECT s.employee_id, s.scanned_at
+			FROM production_run_bundle_scan s
+			JOIN production_run_bundle b ON b.id = s.bundle_id
+			WHERE s.id = :id AND b.run_id = :run_id
+			FOR UPDATE`, args)
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrProductionRunBundleScanNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle scan %d: %w", scanID, err)
+		}
+		if err := checkPayslipOpen(ctx, db, sc.EmployeeId, sc.ScannedAt); err != nil {
+			return err
+		}
+		if err := storeutil.ExecNamed(ctx, db,
+			`DELETE FROM production_run_bundle_scan WHERE id = :id`, args); err != nil {
+			return fmt.Errorf("failed to delete bundle scan %d: %w", scanID, err)
+		}
+		return nil
+	})
+}
+
+// checkPayslipOpen refuses changing the work of an employee-month whose payslip is approved
+// (migration 0341): the payslip froze what was paid, and a scan added or removed under it would be
+// work paid twice or never. The payslip row is share-locked so an approval cannot slip in between.
+func checkPayslipOpen(ctx context.Context, db dependency.DB, employeeID int, at time.Time) error {
+	u := at.UTC()
+	n, err := storeutil.QueryCountNamed(ctx, db, `
+		SELECT COUNT(*) FROM payroll_payslip
+		WHERE employee_id = :employee_id AND month = :month AND status = 'approved'
+		LOCK IN SHARE MODE`,
+		map[string]any{"employee_id": employeeID,
+			"month": time.Date(u.Year(), u.Month(), 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")})
+	if err != nil {
+		return fmt.Errorf("failed to check payslip of employee %d: %w", employeeID, err)
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %s of employee %d", entity.ErrPayslipApproved, u.Format("2006-01"), employeeID)
+	}
+	return nil
+}
@@ -198,10 +198,24 @@ func (s *Store) SaveLay(ctx context.Context, runID int, ins entity.ProductionRun
 		// 6b. The lot, if the payload speaks about one (Ф5б.1). Resolved to a code SNAPSHOT here and
 		// not on read: the code has to survive the lot's deletion, which is what SET NULL is paid
 		// with.
-		lot, err := resolveLayLot(ctx, db, ins.LotId, ins.ColorwayId, bomItemID)
+		lot, err := resolveLayLot(ctx, db, "lay.lot_id", ins.LotId, ins.ColorwayId, bomItemID)
 		if err != nil {
 			return err
 		}
+		// 6b'. The extra rolls (0348), by the same rule and with the same snapshot, one by one.
+		extras, err := resolveLayExtraLots(ctx, db, ins.ExtraLotIds, ins.ColorwayId, bomItemID)
+		if err != nil {
+			return err
+		}
+		// 6b''. ОТТЕНОЧНЫЕ ГРУППЫ (0348): рулоны двух разных групп в одном настиле не лежат — детали
+		// одного изделия выкроились бы из двух оттенков. Спрашивается только когда запрос говорит о
+		// рулонах: настил, чью группу перепроверили ПОСЛЕ сохранения, ловит чтение (lay_shade_band),
+		// а не отказ в правке его заметки.
+		if lot != nil || extras != nil {
+			if err := requireLayRolls(ctx, db, stored, lot, extras); err != nil {
+				return err
+			}
+		}
 		// 6c. The consumption fact, if the payload speaks about one (Ф5б.2). The who/when stamp is
 		// renewed ONLY when the measurement itself moved — see resolveLayActual.
 		actual := resolveLayActual(ins.Actual, stored)
@@ -252,6 +266,12 @@ func (s *Store) SaveLay(ctx context.Context, runID int, ins entity.ProductionRun
 			}
 		}
 
+		if extras != nil {
+			if err := replaceLayExtraLots(ctx, db, layID, extras); err != nil {
+				return err
+			}
+		}
+
 		// 8. The section diff.
 		sectionsChanged, err := upsertLaySections(ctx, db, layID, ins.Sections, sectionKeys)
 		if err != nil {
@@ -389,9 +409,12 @@ type layIdentity struct {
 // payload was silent and the stored binding must not be touched; a value with an invalid LotId is
 // the explicit UNBIND, which clears the snapshot with it — a lay that no longer claims a roll must
 // not keep naming one.
+//
+// ShadeBand is the lot's current band (0348), carried for the shade rule only and never written.
 type layLotBinding struct {
-	LotId sql.NullInt64
-	Code  string
+	LotId     sql.NullInt64  `db:"lot_id"`
+	Code      string         `db:"lot_code"`
+	ShadeBand sql.NullString `db:"shade_band"`
 }
 
 // layActualWrite is a RESOLVED fact instruction: the three stored values plus whether the who/when
@@ -482,7 +505,10 @@ func computeLayNettoStamp(ctx context.Context, rep dependency.Repository, runID,
 // positional index in SQL would put a second copy of the pin-resolution rules in the repository, so
 // the rule chosen is the tolerant one: a pin that cannot say WHICH slot it is on cannot be used to
 // disprove anything either.
-func resolveLayLot(ctx context.Context, db dependency.DB, lotID *int, colorwayID, bomItemID int) (*layLotBinding, error) {
+//
+// field is the payload path a refusal names: lay.lot_id for the lay's own roll, lay.extra_lot_ids[i]
+// for an extra one — the rule is the same, the roll the operator has to change is not.
+func resolveLayLot(ctx context.Context, db dependency.DB, field string, lotID *int, colorwayID, bomItemID int) (*layLotBinding, error) {
 	if lotID == nil {
 		return nil, nil
 	}
@@ -490,15 +516,16 @@ func resolveLayLot(ctx context.Context, db dependency.DB, lotID *int, colorwayID
 		return &layLotBinding{}, nil
 	}
 	lot, err := storeutil.QueryNamedOne[struct {
-		Id         int    `db:"id"`
-		LotCode    string `db:"lot_code"`
-		MaterialId int    `db:"material_id"`
+		Id         int            `db:"id"`
+		LotCode    string         `db:"lot_code"`
+		MaterialId int            `db:"material_id"`
+		ShadeBand  sql.NullString `db:"shade_band"`
 	}](ctx, db, `
-		SELECT id, lot_code, material_id
+		SELECT id, lot_code, material_id, shade_band
 		FROM material_lot WHERE id = :id`, map[string]any{"id": *lotID})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, entity.NewFieldViolation("lay.lot_id", "not_found", fmt.Sprintf("lot %d", *lotID),
+			return nil, entity.NewFieldViolation(field, "not_found", fmt.Sprintf("lot %d", *lotID),
 				"pick a lot that exists in the material warehouse, or leave the lay without one")
 		}
 		return nil, fmt.Errorf("resolve lot %d for lay: %w", *lotID, err)
@@ -527,7 +554,7 @@ func resolveLayLot(ctx context.Context, db dependency.DB, lotID *int, colorwayID
 			}
 		}
 		if !ok {
-			return nil, entity.NewFieldViolation("lay.lot_id", "lot_of_another_article",
+			return nil, entity.NewFieldViolation(field, "lot_of_another_article",
 				fmt.Sprintf("lot %q is article %d", lot.LotCode, lot.MaterialId),
 				"lay this slot off a lot of the cloth the slot actually holds")
 		}
@@ -536,7 +563,103 @@ func resolveLayLot(ctx context.Context, db dependency.DB, lotID *int, colorwayID
 	// production_run_lay.lot_code (0285) are both VARCHAR(64), so the snapshot cannot outgrow its
 	// column — and if one of them is ever widened without the other, MySQL must say so loudly rather
 	// than let a truncation quietly rename the roll the настил is pointing at.
-	return &layLotBinding{LotId: sql.NullInt64{Int64: int64(lot.Id), Valid: true}, Code: lot.LotCode}, nil
+	return &layLotBinding{LotId: sql.NullInt64{Int64: int64(lot.Id), Valid: true}, Code: lot.LotCode, ShadeBand: lot.ShadeBand}, nil
+}
+
+// resolveLayExtraLots resolves the extra rolls of a lay (0348) through resolveLayLot, so an extra
+// roll obeys exactly the rule the lay's own roll does. nil = the payload was silent; an empty,
+// non-nil slice clears the list.
+func resolveLayExtraLots(ctx context.Context, db dependency.DB, ids *[]int, colorwayID, bomItemID int) ([]layLotBinding, error) {
+	if ids == nil {
+		return nil, nil
+	}
+	out := make([]layLotBinding, 0, len(*ids))
+	for i, id := range *ids {
+		lot, err := resolveLayLot(ctx, db, fmt.Sprintf("lay.extra_lot_ids[%d]", i), &id, colorwayID, bomItemID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *lot)
+	}
+	return out, nil
+}
+
+// requireLayRolls refuses a lay whose rolls, AS THEY WILL STAND AFTER THIS SAVE, name one lot twice
+// or carry two or more known shade bands. The half of the lay the payload was silent about is read from the stored
+// row, because silence keeps it (see ProductionRunLayInsert.LotId) and it therefore lies in the lay
+// just the same. An untested roll is tolerated here — «not sorted yet» cannot prove a mix — and
+// reported on read as UNKNOWN instead.
+func requireLayRolls(ctx context.Context, db dependency.DB, stored *layIdentity, lot *layLotBinding, extras []layLotBinding) error {
+	var rolls []layLotBinding
+	switch {
+	case lot != nil:
+		if lot.LotId.Valid {
+			rolls = append(rolls, *lot)
+		}
+	case stored != nil:
+		own, err := storeutil.QueryListNamed[layLotBinding](ctx, db, `
+			SELECT l.lot_id, l.lot_code, ml.shade_band
+			FROM production_run_lay l
+			JOIN material_lot ml ON ml.id = l.lot_id
+			WHERE l.id = :id`, map[string]any{"id": stored.Id})
+		if err != nil {
+			return fmt.Errorf("load the lot of lay %d for the shade rule: %w", stored.Id, err)
+		}
+		rolls = append(rolls, own...)
+	}
+	switch {
+	case extras != nil:
+		rolls = append(rolls, extras...)
+	case stored != nil:
+		rest, err := storeutil.QueryListNamed[layLotBinding](ctx, db, `
+			SELECT x.lot_id, x.lot_code, ml.shade_band
+			FROM production_run_lay_lot x
+			JOIN material_lot ml ON ml.id = x.lot_id
+			WHERE x.lay_id = :id`, map[string]any{"id": stored.Id})
+		if err != nil {
+			return fmt.Errorf("load the extra lots of lay %d for the shade rule: %w", stored.Id, err)
+		}
+		rolls = append(rolls, rest...)
+	}
+
+	bands := make([]sql.NullString, 0, len(rolls))
+	seen := make(map[int64]bool, len(rolls))
+	for _, r := range rolls {
+		if r.LotId.Valid && seen[r.LotId.Int64] {
+			return entity.NewFieldViolation("lay.extra_lot_ids", "duplicate_lot", r.Code,
+				"a roll is laid into a lay once; name each lot a single time")
+		}
+		seen[r.LotId.Int64] = true
+		bands = append(bands, r.ShadeBand)
+	}
+	if !entity.ShadeBandMixOf(bands).Mixed() {
+		return nil
+	}
+	named := make([]string, 0, len(rolls))
+	for _, r := range rolls {
+		if b := entity.NormalizeShadeBand(r.ShadeBand); b.Valid {
+			named = append(named, fmt.Sprintf("%s (%s)", r.Code, b.String))
+		}
+	}
+	return entity.NewFieldViolation("lay.extra_lot_ids", "mixed_shade_bands", strings.Join(named, ", "),
+		"lay rolls of one shade band together; split the lay per band")
+}
+
+// replaceLayExtraLots rewrites the lay's extra rolls whole. The list is small and carries no
+// identity anything hangs off, so delete-and-insert is the honest diff here — unlike the sections.
+func replaceLayExtraLots(ctx context.Context, db dependency.DB, layID int, extras []layLotBinding) error {
+	if err := storeutil.ExecNamed(ctx, db, `DELETE FROM production_run_lay_lot WHERE lay_id = :id`,
+		map[string]any{"id": layID}); err != nil {
+		return fmt.Errorf("clear extra lots of lay %d: %w", layID, err)
+	}
+	rows := make([]map[string]any, 0, len(extras))
+	for i, x := range extras {
+		rows = append(rows, map[string]any{"lay_id": layID, "position": i + 1, "lot_id": x.LotId, "lot_code": x.Code})
+	}
+	if err := storeutil.BulkInsert(ctx, db, "production_run_lay_lot", rows); err != nil {
+		return fmt.Errorf("insert extra lots of lay %d: %w", layID, err)
+	}
+	return nil
 }
 
 // resolveLayActual turns the payload's fact instruction into the three values to store and decides
@@ -619,6 +742,25 @@ func validateLayInsert(ins *entity.ProductionRunLayInsert) error {
 			return err
 		}
 	}
+	if ins.ExtraLotIds != nil {
+		ids := *ins.ExtraLotIds
+		if len(ids) > entity.MaxProductionRunLayExtraLots {
+			return entity.NewFieldViolation("lay.extra_lot_ids", "too_many", fmt.Sprintf("%d", len(ids)),
+				fmt.Sprintf("a lay names at most %d extra rolls; split it", entity.MaxProductionRunLayExtraLots))
+		}
+		seen := make(map[int]bool, len(ids))
+		for i, id := range ids {
+			field := fmt.Sprintf("lay.extra_lot_ids[%d]", i)
+			if id <= 0 {
+				return entity.NewFieldViolation(field, "required", "", "name an existing lot, or drop the entry")
+			}
+			if seen[id] || (ins.LotId != nil && *ins.LotId == id) {
+				return entity.NewFieldViolation(field, "duplicate_lot", fmt.Sprintf("lot %d", id),
+					"a roll is laid into a lay once; name each lot a single time")
+			}
+			seen[id] = true
+		}
+	}
 	for i := range ins.Sections {
 		sec := &ins.Sections[i]
 		if sec.MarkerId <= 0 {
@@ -1154,6 +1296,8 @@ const layColumns = `
 	l.mode, l.end_loss_cm, l.name, l.note, l.display_order, l.lock_version,
 	l.lot_id, l.lot_code, ml.material_id AS lot_material_id,
 	ml.measured_width_cm AS lot_measured_width_cm, ml.shade_code AS lot_shade_code,
+	ml.shade_band AS lot_shade_band, ml.shrinkage_warp_pct AS lot_shrinkage_warp_pct,
+	ml.shrinkage_weft_pct AS lot_shrinkage_weft_pct, ml.measured_gsm AS lot_measured_gsm,
 	l.actual_qty, l.actual_uom, l.actual_method, l.actual_by, l.actual_at,
 	l.netto_qty, l.netto_basis_qty, l.netto_basis_uom,
 	l.qty_snapshot, l.created_by, l.updated_by, l.created_at, l.updated_at`
@@ -1209,6 +1353,13 @@ func loadLays(ctx context.Context, db dependency.DB, runID, layID int) ([]entity
 	for i := range lays {
 		lays[i].Sections = sections[lays[i].Id]
 	}
+	extras, err := loadLayExtraLots(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lays {
+		lays[i].ExtraLots = extras[lays[i].Id]
+	}
 
 	// Today's quantities, one query for every colourway the lays name. Attached here rather than
 	// left to the caller because "stale" is a comparison, and a reader that has to fetch the second
@@ -1266,6 +1417,30 @@ func loadLaySections(ctx context.Context, db dependency.DB, layIDs []int) (map[i
 	return out, nil
 }
 
+// loadLayExtraLots reads the extra rolls of a batch of lays (0348) in one round trip, with the lot
+// facts joined on the same terms as the lay's own roll: NULL exactly when the lot is gone, the code
+// snapshot surviving it.
+func loadLayExtraLots(ctx context.Context, db dependency.DB, layIDs []int) (map[int][]entity.ProductionRunLayLot, error) {
+	out := make(map[int][]entity.ProductionRunLayLot, len(layIDs))
+	if len(layIDs) == 0 {
+		return out, nil
+	}
+	rows, err := storeutil.QueryListNamed[entity.ProductionRunLayLot](ctx, db, `
+		SELECT x.lay_id, x.position, x.lot_id, x.lot_code, ml.material_id, ml.measured_width_cm,
+		       ml.shade_code, ml.shade_band, ml.shrinkage_warp_pct, ml.shrinkage_weft_pct, ml.measured_gsm
+		FROM production_run_lay_lot x
+		LEFT JOIN material_lot ml ON ml.id = x.lot_id
+		WHERE x.lay_id IN (:ids)
+		ORDER BY x.lay_id, x.position`, map[string]any{"ids": layIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lay extra lots: %w", err)
+	}
+	for i := range rows {
+		out[rows[i].LayId] = append(out[rows[i].LayId], rows[i])
+	}
+	return out, nil
+}
+
 // loadRunQuantitiesByColorway is the run's planned grid grouped by colourway and size — the "today"
 // half of the staleness comparison. Product-less lines are skipped: a line without a colourway
 // cannot be covered by any lay, and folding it into some colourway's total would be an invention.
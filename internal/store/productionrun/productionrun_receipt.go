@@ -221,6 +221,19 @@ func (s *Store) PostProductionRunReceipt(ctx context.Context, p entity.PostProdu
 				return entity.ErrProductionRunNothingReceived
 			}
 		}
+		// The QC gate (0343) judges the counts as submitted, before a single rollup moves: what it
+		// vouches for is this delivery, and a refusal must leave nothing behind.
+		inspectionID, err := claimQcInspection(ctx, db, p, totalGood, totalDefect)
+		if err != nil {
+			return err
+		}
+		// A subcontracted run (0344) closes its kit before the FINAL freezes its cost: the material
+		// still at the factory and the CMT charge land on the run only at reconciliation.
+		if p.Final {
+			if err := checkSubcontractSettled(ctx, db, p.RunID); err != nil {
+				return err
+			}
+		}
 
 		// Maintain the plan-grid rollups: received_qty/defect_qty are Σ over the run's receipts
 		// (Phase 5), so a counted line ACCUMULATES this delivery on top of what earlier receipts
@@ -397,6 +410,13 @@ func (s *Store) PostProductionRunReceipt(ctx context.Context, p entity.PostProdu
 		if err != nil {
 			return fmt.Errorf("failed to insert production run receipt: %w", err)
 		}
+		if inspectionID > 0 {
+			if err := storeutil.ExecNamed(ctx, db, `
+				UPDATE production_run_qc_inspection SET receipt_id = :receipt_id WHERE id = :id`,
+				map[string]any{"receipt_id": receiptID, "id": inspectionID}); err != nil {
+				return fmt.Errorf("failed to stamp qc inspection %d with receipt: %w", inspectionID, err)
+			}
+		}
 		for _, c := range counted {
 			if err := storeutil.ExecNamed(ctx, db, `
 				INSERT INTO production_run_receipt_line
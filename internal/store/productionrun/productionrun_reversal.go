@@ -311,6 +311,13 @@ func (s *Store) ReverseProductionRunReceipt(ctx context.Context, p entity.Revers
 			map[string]any{"rev": reversalID, "id": p.ReceiptID}); err != nil {
 			return fmt.Errorf("failed to link reversed receipt: %w", err)
 		}
+		// The QC inspection the receipt used (0343) is released with it: the lot was inspected, the
+		// count was wrong, and the corrected receipt is vouched for by the same sample.
+		if err := storeutil.ExecNamed(ctx, db, `
+			UPDATE production_run_qc_inspection SET receipt_id = NULL WHERE receipt_id = :id`,
+			map[string]any{"id": p.ReceiptID}); err != nil {
+			return fmt.Errorf("failed to release qc inspection of reversed receipt: %w", err)
+		}
 
 		// 6. cost_price rollback for products this receipt stocked, unless a live sibling still
 		// stocks them (the run's claim is then still earned by real goods on the shelf).
@@ -0,0 +1,547 @@
+package productionrun
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/qcsampling"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// КОНТРОЛЬ КАЧЕСТВА (migration 0343): каталог дефектов, инспекции партий и их находки. Гейт приёмки
+// живёт в productionrun_receipt.go, рядом с остальными гвардами приёмки; здесь — всё, что до неё.
+//
+// ДВА РЕШЕНИЯ ЖИВУТ В ЭТОМ ФАЙЛЕ.
+//
+//  1. ВЕРДИКТ ВЫНОСИТСЯ ЗДЕСЬ, ПОД БЛОКИРОВКОЙ ИНСПЕКЦИИ, по классам дефектов, прочитанным в той же
+//     транзакции. Класс снимается на находку — правка каталога после вердикта его не пересуживает,
+//     — и поэтому он обязан быть прочитан там же, где пишется: класс из чтения хендлера мог смениться
+//     до записи, и находка унесла бы не тот класс, по которому её судили.
+//
+//  2. ИСПОЛЬЗОВАННАЯ ИНСПЕКЦИЯ НЕИЗМЕНЯЕМА. Пока receipt_id пуст, находки перезаписываются целиком
+//     (контролёр досчитал выборку), и инспекцию можно удалить. После приёмки это документ о том,
+//     почему годное попало на склад, и любая правка — entity.ErrQcInspectionConsumed; сторно приёмки
+//     освобождает её обратно.
+
+const qcDefectTypeColumns = `id, code, name, severity, operation_type, work, description, retired_at,
+	created_by, created_at, updated_at`
+
+// ListQcDefectTypes returns the defect catalog by code; retired entries only when asked for.
+func (s *Store) ListQcDefectTypes(ctx context.Context, includeRetired bool) ([]entity.QcDefectType, error) {
+	where := "retired_at IS NULL"
+	if includeRetired {
+		where = "TRUE"
+	}
+	out, err := storeutil.QueryListNamed[entity.QcDefectType](ctx, s.DB, `
+		SELECT `+qcDefectTypeColumns+` FROM qc_defect_type
+		WHERE `+where+`
+		ORDER BY code`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't list qc defect types: %w", err)
+	}
+	return out, nil
+}
+
+// CreateQcDefectType adds a catalog entry. A taken code is a field violation, not a conflict to
+// retry: codes are printed on the inspector's sheet and two entries cannot share one.
+func (s *Store) CreateQcDefectType(ctx context.Context, ins entity.QcDefectTypeInsert, username string) (*entity.QcDefectType, error) {
+	id, err := storeutil.ExecNamedLastId(ctx, s.DB, `
+		INSERT INTO qc_defect_type (code, name, severity, operation_type, work, description, created_by)
+		VALUES (:code, :name, :severity, :operation_type, :work, :description, :created_by)`,
+		qcDefectTypeArgs(ins, map[string]any{"created_by": username}))
+	if err != nil {
+		return nil, qcDefectTypeWriteError(ins, err)
+	}
+	return s.getQcDefectType(ctx, id)
+}
+
+// UpdateQcDefectType rewrites a catalog entry. Findings already recorded keep the class they were
+// judged by (snapshot); only future findings see the change.
+func (s *Store) UpdateQcDefectType(ctx context.Context, id int, ins entity.QcDefectTypeInsert) (*entity.QcDefectType, error) {
+	n, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM qc_defect_type WHERE id = :id`,
+		map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check qc defect type %d: %w", id, err)
+	}
+	if n == 0 {
+		return nil, entity.ErrQcDefectTypeNotFound
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE qc_defect_type SET
+			code = :code, name = :name, severity = :severity, operation_type = :operation_type,
+			work = :work, description = :description
+		WHERE id = :id`, qcDefectTypeArgs(ins, map[string]any{"id": id})); err != nil {
+		return nil, qcDefectTypeWriteError(ins, err)
+	}
+	return s.getQcDefectType(ctx, id)
+}
+
+// SetQcDefectTypeRetired retires an entry (it leaves the inspector's sheet, its findings stay
+// readable) or brings it back.
+func (s *Store) SetQcDefectTypeRetired(ctx context.Context, id int, retired bool) (*entity.QcDefectType, error) {
+	n, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM qc_defect_type WHERE id = :id`,
+		map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check qc defect type %d: %w", id, err)
+	}
+	if n == 0 {
+		return nil, entity.ErrQcDefectTypeNotFound
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE qc_defect_type
+		SET retired_at = IF(:retired, COALESCE(retired_at, CURRENT_TIMESTAMP), NULL)
+		WHERE id = :id`, map[string]any{"id": id, "retired": retired}); err != nil {
+		return nil, fmt.Errorf("failed to retire qc defect type %d: %w", id, err)
+	}
+	return s.getQcDefectType(ctx, id)
+}
+
+func (s *Store) getQcDefectType(ctx context.Context, id int) (*entity.QcDefectType, error) {
+	t, err := storeutil.QueryNamedOne[entity.QcDefectType](ctx, s.DB, `
+		SELECT `+qcDefectTypeColumns+` FROM qc_defect_type WHERE id = :id`, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrQcDefectTypeNotFound
+		}
+		return nil, fmt.Errorf("can't load qc defect type %d: %w", id, err)
+	}
+	return &t, nil
+}
+
+func qcDefectTypeArgs(ins entity.QcDefectTypeInsert, extra map[string]any) map[string]any {
+	args := map[string]any{
+		"code":           ins.Code,
+		"name":           ins.Name,
+		"severity":       string(ins.Severity),
+		"operation_type": ins.OperationType,
+		"work":           ins.Work,
+		"description":    ins.Description,
+	}
+	for k, v := range extra {
+		args[k] = v
+	}
+	return args
+}
+
+// qcDefectTypeWriteError names the field behind the two constraint failures an operator can cause.
+func qcDefectTypeWriteError(ins entity.QcDefectTypeInsert, err error) error {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		switch me.Number {
+		case 1062:
+			return entity.NewFieldViolation("code", "taken", ins.Code, "another defect type already uses this code")
+		case 1452:
+			return entity.NewFieldViolation("work", "unknown_work", ins.Work.String, "pick a work from the operation work catalog")
+		}
+	}
+	return fmt.Errorf("failed to write qc defect type %q: %w", ins.Code, err)
+}
+
+const qcInspectionColumns = `id, run_id, receipt_id, lot_size, inspection_level, aql_major, aql_minor,
+	code_letter, sample_size, full_inspection, major_accept, major_reject, minor_accept, minor_reject,
+	result, inspector, note, created_by, created_at, decided_by, decided_at`
+
+// CreateQcInspection opens a pending inspection of a lot with the plan the caller computed. A missing
+// run is sql.ErrNoRows. There is no status guard: a lot is inspected while the run is still sewing,
+// and the receipt is where a closed run says no.
+func (s *Store) CreateQcInspection(ctx context.Context, ins entity.QcInspectionInsert) (*entity.QcInspection, error) {
+	exists, err := storeutil.QueryCountNamed(ctx, s.DB,
+		`SELECT COUNT(*) FROM production_run WHERE id = :id`, map[string]any{"id": ins.RunId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check production run %d for qc inspection: %w", ins.RunId, err)
+	}
+	if exists == 0 {
+		return nil, sql.ErrNoRows
+	}
+	id, err := storeutil.ExecNamedLastId(ctx, s.DB, `
+		INSERT INTO production_run_qc_inspection
+			(run_id, lot_size, inspection_level, aql_major, aql_minor, code_letter, sample_size,
+			 full_inspection, major_accept, major_reject, minor_accept, minor_reject, inspector, note, created_by)
+		VALUES (:run_id, :lot_size, :inspection_level, :aql_major, :aql_minor, :code_letter, :sample_size,
+			 :full_inspection, :major_accept, :major_reject, :minor_accept, :minor_reject, :inspector, :note, :created_by)`,
+		map[string]any{
+			"run_id":           ins.RunId,
+			"lot_size":         ins.LotSize,
+			"inspection_level": string(ins.InspectionLevel),
+			"aql_major":        string(ins.AqlMajor),
+			"aql_minor":        string(ins.AqlMinor),
+			"code_letter":      ins.Plan.CodeLetter,
+			"sample_size":      ins.Plan.SampleSize,
+			"full_inspection":  ins.Plan.FullInspection,
+			"major_accept":     ins.Plan.MajorAccept,
+			"major_reject":     ins.Plan.MajorReject,
+			"minor_accept":     ins.Plan.MinorAccept,
+			"minor_reject":     ins.Plan.MinorReject,
+			"inspector":        ins.Inspector,
+			"note":             ins.Note,
+			"created_by":       ins.CreatedBy,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert qc inspection of run %d: %w", ins.RunId, err)
+	}
+	return s.GetQcInspection(ctx, ins.RunId, id)
+}
+
+// ListQcInspections returns the run's inspections, newest first, with their findings. A missing run
+// is sql.ErrNoRows.
+func (s *Store) ListQcInspections(ctx context.Context, runID int) ([]entity.QcInspection, error) {
+	exists, err := storeutil.QueryCountNamed(ctx, s.DB,
+		`SELECT COUNT(*) FROM production_run WHERE id = :id`, map[string]any{"id": runID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check production run %d for qc inspections: %w", runID, err)
+	}
+	if exists == 0 {
+		return nil, sql.ErrNoRows
+	}
+	args := map[string]any{"run_id": runID}
+	out, err := storeutil.QueryListNamed[entity.QcInspection](ctx, s.DB, `
+		SELECT `+qcInspectionColumns+` FROM production_run_qc_inspection
+		WHERE run_id = :run_id
+		ORDER BY created_at DESC, id DESC`, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't list qc inspections of run %d: %w", runID, err)
+	}
+	defects, err := loadQcFindings(ctx, s.DB, "i.run_id = :run_id", args)
+	if err != nil {
+		return nil, fmt.Errorf("can't list qc findings of run %d: %w", runID, err)
+	}
+	byID := make(map[int]*entity.QcInspection, len(out))
+	for i := range out {
+		byID[out[i].Id] = &out[i]
+	}
+	for _, d := range defects {
+		if in, ok := byID[d.InspectionId]; ok {
+			in.Defects = append(in.Defects, d)
+		}
+	}
+	return out, nil
+}
+
+// GetQcInspection reads one inspection of a run with its findings.
+func (s *Store) GetQcInspection(ctx context.Context, runID, id int) (*entity.QcInspection, error) {
+	return getQcInspection(ctx, s.DB, runID, id, false)
+}
+
+func getQcInspection(ctx context.Context, db dependency.DB, runID, id int, forUpdate bool) (*entity.QcInspection, error) {
+	lock := ""
+	if forUpdate {
+		lock = " FOR UPDATE"
+	}
+	args := map[string]any{"id": id, "run_id": runID}
+	in, err := storeutil.QueryNamedOne[entity.QcInspection](ctx, db, `
+		SELECT `+qcInspectionColumns+` FROM production_run_qc_inspection
+		WHERE id = :id AND run_id = :run_id`+lock, args)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrQcInspectionNotFound
+		}
+		return nil, fmt.Errorf("can't load qc inspection %d: %w", id, err)
+	}
+	in.Defects, err = loadQcFindings(ctx, db, "f.inspection_id = :id", args)
+	if err != nil {
+		return nil, fmt.Errorf("can't load qc findings of inspection %d: %w", id, err)
+	}
+	return &in, nil
+}
+
+func loadQcFindings(ctx context.Context, db dependency.DB, where string, args map[string]any) ([]entity.QcInspectionDefect, error) {
+	return storeutil.QueryListNamed[entity.QcInspectionDefect](ctx, db, `
+		SELECT f.id, f.inspection_id, f.defect_type_id, t.code AS defect_code, t.name AS defect_name,
+			f.severity, f.run_operation_id, o.seq AS operation_seq, f.operation_type, f.work, f.qty
+		FROM production_run_qc_inspection_defect f
+		JOIN production_run_qc_inspection i ON i.id = f.inspection_id
+		JOIN qc_defect_type t ON t.id = f.defect_type_id
+		LEFT JOIN production_run_operation o ON o.id = f.run_operation_id
+		WHERE `+where+`
+		ORDER BY f.inspection_id, f.id`, args)
+}
+
+// RecordQcFindings replaces the findings of an unused inspection and decides it by its stored plan
+// (qcsampling.Verdict). An empty list is a clean sample and passes. The same defect type traced to
+// the same step twice is summed, so the sheet can be entered as counted.
+func (s *Store) RecordQcFindings(ctx context.Context, runID, inspectionID int, findings []entity.QcFindingInput, username string) (*entity.QcInspection, error) {
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		in, err := getQcInspection(ctx, db, runID, inspectionID, true)
+		if err != nil {
+			return err
+		}
+		if in.ReceiptId.Valid {
+			return entity.ErrQcInspectionConsumed
+		}
+
+		types := map[int]entity.QcDefectType{}
+		ops := map[int]entity.ProductionRunOperation{}
+		if len(findings) > 0 {
+			typeIDs := make([]int, 0, len(findings))
+			for _, f := range findings {
+				typeIDs = append(typeIDs, f.DefectTypeId)
+			}
+			list, err := storeutil.QueryListNamed[entity.QcDefectType](ctx, db, `
+				SELECT `+qcDefectTypeColumns+` FROM qc_defect_type WHERE id IN (:ids)`,
+				map[string]any{"ids": typeIDs})
+			if err != nil {
+				return fmt.Errorf("failed to load qc defect types: %w", err)
+			}
+			for _, t := range list {
+				types[t.Id] = t
+			}
+			runOps, err := loadRunOperations(ctx, db, runID)
+			if err != nil {
+				return fmt.Errorf("failed to load operation snapshot of run %d: %w", runID, err)
+			}
+			for _, o := range runOps {
+				ops[o.Seq] = o
+			}
+		}
+
+		type key struct{ typeID, seq int }
+		summed := make(map[key]int, len(findings))
+		order := make([]key, 0, len(findings))
+		defects := make([]entity.QcInspectionDefect, 0, len(findings))
+		for _, f := range findings {
+			t, ok := types[f.DefectTypeId]
+			if !ok {
+				return fmt.Errorf("%w: id %d", entity.ErrQcDefectTypeNotFound, f.DefectTypeId)
+			}
+			if t.RetiredAt.Valid {
+				return fmt.Errorf("%w: %s", entity.ErrQcDefectTypeRetired, t.Code)
+			}
+			if f.OperationSeq > 0 {
+				if _, ok := ops[f.OperationSeq]; !ok {
+					return fmt.Errorf("%w: seq %d", entity.ErrQcOperationNotFound, f.OperationSeq)
+				}
+			}
+			k := key{f.DefectTypeId, f.OperationSeq}
+			if _, seen := summed[k]; !seen {
+				order = append(order, k)
+			}
+			summed[k] += f.Qty
+		}
+		for _, k := range order {
+			d := entity.QcInspectionDefect{DefectTypeId: k.typeID, Severity: types[k.typeID].Severity, Qty: summed[k]}
+			if op, ok := ops[k.seq]; ok {
+				d.RunOperationId = sql.NullInt32{Int32: int32(op.Id), Valid: true}
+				d.OperationType = sql.NullString{String: op.OperationType, Valid: op.OperationType != ""}
+				d.Work = op.Work
+			}
+			defects = append(defects, d)
+		}
+		// The sample holds SampleSize garments, and a finding counts garments: more defective garments
+		// of one class than were drawn is a typo, not an inspection.
+		counts := qcsampling.Count(defects)
+		for _, c := range []struct {
+			class string
+			n     int
+		}{{"critical", counts.Critical}, {"major", counts.Major}, {"minor", counts.Minor}} {
+			if c.n > in.SampleSize {
+				return entity.NewFieldViolation("findings", "exceeds_sample", fmt.Sprint(c.n),
+					fmt.Sprintf("%s defects count garments of the sample, and the sample holds %d", c.class, in.SampleSize))
+			}
+		}
+		result, _ := qcsampling.Verdict(in.Plan(), counts)
+
+		args := map[string]any{"id": inspectionID}
+		if err := storeutil.ExecNamed(ctx, db,
+			`DELETE FROM production_run_qc_inspection_defect WHERE inspection_id = :id`, args); err != nil {
+			return fmt.Errorf("failed to clear findings of qc inspection %d: %w", inspectionID, err)
+		}
+		rows := make([][]any, 0, len(defects))
+		for _, d := range defects {
+			rows = append(rows, []any{inspectionID, d.DefectTypeId, string(d.Severity), d.RunOperationId,
+				d.OperationType, d.Work, d.Qty})
+		}
+		if err := storeutil.BulkInsertRows(ctx, db, "production_run_qc_inspection_defect",
+			[]string{"inspection_id", "defect_type_id", "severity", "run_operation_id", "operation_type", "work", "qty"},
+			rows); err != nil {
+			return fmt.Errorf("failed to insert findings of qc inspection %d: %w", inspectionID, err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			UPDATE production_run_qc_inspection
+			SET result = :result, decided_by = :decided_by, decided_at = :decided_at
+			WHERE id = :id`, map[string]any{
+			"id":         inspectionID,
+			"result":     string(result),
+			"decided_by": username,
+			"decided_at": s.Now(),
+		}); err != nil {
+			return fmt.Errorf("failed to decide qc inspection %d: %w", inspectionID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetQcInspection(ctx, runID, inspectionID)
+}
+
+// DeleteQcInspection removes an unused inspection — one opened by mistake or for the wrong lot.
+func (s *Store) DeleteQcInspection(ctx context.Context, runID, inspectionID int) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		in, err := getQcInspection(ctx, db, runID, inspectionID, true)
+		if err != nil {
+			return err
+		}
+		if in.ReceiptId.Valid {
+			return entity.ErrQcInspectionConsumed
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM production_run_qc_inspection WHERE id = :id`,
+			map[string]any{"id": inspectionID}); err != nil {
+			return fmt.Errorf("failed to delete qc inspection %d: %w", inspectionID, err)
+		}
+		return nil
+	})
+}
+
+// claimQcInspection is the receipt's QC gate, run inside the receipt transaction after the counts are
+// resolved (see PostProductionRunReceipt). It returns the inspection to stamp with the receipt, or 0
+// when the receipt goes ungated.
+func claimQcInspection(ctx context.Context, db dependency.DB, p entity.PostProductionRunReceiptParams, totalGood, totalDefect int) (int, error) {
+	if p.InspectionID <= 0 {
+		// The requirement covers what books good product stock. An all-defect receipt and a short
+		// close carry no good units to vouch for, and an auxiliary run's output is a material that no
+		// garment sampling plan describes.
+		if p.Aux || totalGood <= 0 {
+			return 0, nil
+		}
+		// Read inside the receipt transaction, not by the handler: a workshop that switches the gate
+		// on is obeyed by the next receipt to commit, not the next one to start. No row = not required.
+		required, err := storeutil.QueryScalarListNamed[sql.NullBool](ctx, db, `
+			SELECT qc_inspection_required FROM workshop_settings WHERE id = 1`, map[string]any{})
+		if err != nil {
+			return 0, fmt.Errorf("failed to read qc inspection requirement: %w", err)
+		}
+		if len(required) > 0 && required[0].Valid && required[0].Bool {
+			return 0, entity.ErrQcInspectionRequired
+		}
+		return 0, nil
+	}
+	in, err := storeutil.QueryNamedOne[struct {
+		ReceiptId sql.NullInt32 `db:"receipt_id"`
+		Result    string        `db:"result"`
+		LotSize   int           `db:"lot_size"`
+	}](ctx, db, `
+		SELECT receipt_id, result, lot_size FROM production_run_qc_inspection
+		WHERE id = :id AND run_id = :run_id
+		FOR UPDATE`, map[string]any{"id": p.InspectionID, "run_id": p.RunID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, entity.ErrQcInspectionNotFound
+		}
+		return 0, fmt.Errorf("failed to lock qc inspection %d: %w", p.InspectionID, err)
+	}
+	if in.ReceiptId.Valid {
+		return 0, entity.ErrQcInspectionConsumed
+	}
+	switch entity.QcInspectionResult(in.Result) {
+	case entity.QcInspectionPending:
+		return 0, entity.ErrQcInspectionPending
+	case entity.QcInspectionFailed:
+		if totalGood > 0 {
+			return 0, entity.ErrQcInspectionFailed
+		}
+	}
+	if totalGood+totalDefect > in.LotSize {
+		return 0, fmt.Errorf("%w: %d counted, %d inspected", entity.ErrQcInspectionLotExceeded, totalGood+totalDefect, in.LotSize)
+	}
+	return p.InspectionID, nil
+}
+
+// GetQcDefectAnalytics returns the defect rates of the inspections decided in the period, per style
+// (tech card), per operation and per workshop (the run's supplier; none = our own workshop).
+func (s *Store) GetQcDefectAnalytics(ctx context.Context, f entity.QcDefectAnalyticsFilter) (*entity.QcDefectAnalytics, error) {
+	args := map[string]any{"from": f.From, "to": f.To}
+	const decided = `i.result <> 'pending' AND i.decided_at >= :from AND i.decided_at < :to`
+	// Findings are summed per inspection first, so an inspection counts its sample once however many
+	// findings it has.
+	const perInspection = `
+		FROM production_run_qc_inspection i
+		JOIN production_run r ON r.id = i.run_id
+		LEFT JOIN (
+			SELECT inspection_id,
+			       SUM(IF(severity = 'critical', qty, 0)) AS critical,
+			       SUM(IF(severity = 'major', qty, 0)) AS major,
+			       SUM(IF(severity = 'minor', qty, 0)) AS minor
+			FROM production_run_qc_inspection_defect GROUP BY inspection_id
+		) d ON d.inspection_id = i.id`
+	const sums = `COUNT(*) AS inspections, COALESCE(SUM(i.result = 'failed'), 0) AS failed,
+		COALESCE(SUM(i.sample_size), 0) AS inspected, COALESCE(SUM(d.critical), 0) AS critical,
+		COALESCE(SUM(d.major), 0) AS major, COALESCE(SUM(d.minor), 0) AS minor`
+
+	out := &entity.QcDefectAnalytics{}
+	total, err := storeutil.QueryNamedOne[entity.QcDefectRateRow](ctx, s.DB, `
+		SELECT '' AS bucket_key, '' AS bucket_label, `+sums+perInspection+`
+		WHERE `+decided, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't total qc defects: %w", err)
+	}
+	out.Total = total
+
+	out.ByStyle, err = storeutil.QueryListNamed[entity.QcDefectRateRow](ctx, s.DB, `
+		SELECT CAST(r.tech_card_id AS CHAR) AS bucket_key,
+		       MAX(TRIM(CONCAT(COALESCE(tc.style_number, ''), ' ', COALESCE(tc.name, '')))) AS bucket_label, `+sums+perInspection+`
+		LEFT JOIN tech_card tc ON tc.id = r.tech_card_id
+		WHERE `+decided+`
+		GROUP BY r.tech_card_id
+		ORDER BY inspections DESC, r.tech_card_id`, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't group qc defects by style: %w", err)
+	}
+
+	out.ByWorkshop, err = storeutil.QueryListNamed[entity.QcDefectRateRow](ctx, s.DB, `
+		SELECT COALESCE(CAST(r.supplier_id AS CHAR), '') AS bucket_key,
+		       MAX(COALESCE(sp.name, '')) AS bucket_label, `+sums+perInspection+`
+		LEFT JOIN supplier sp ON sp.id = r.supplier_id
+		WHERE `+decided+`
+		GROUP BY r.supplier_id
+		ORDER BY inspections DESC, r.supplier_id`, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't group qc defects by workshop: %w", err)
+	}
+
+	// Per operation the finding is the row: the step it was traced to, else the step its catalog
+	// entry names — the work when there is one, the verb otherwise. Inspected is not per bucket (see
+	// entity.QcDefectAnalytics) and is filled from the total below.
+	out.ByOperation, err = storeutil.QueryListNamed[entity.QcDefectRateRow](ctx, s.DB, `
+		SELECT x.bucket_key, MAX(COALESCE(ow.label, '')) AS bucket_label,
+		       COUNT(DISTINCT x.inspection_id) AS inspections,
+		       COUNT(DISTINCT IF(x.result = 'failed', x.inspection_id, NULL)) AS failed,
+		       0 AS inspected,
+		       SUM(IF(x.severity = 'critical', x.qty, 0)) AS critical,
+		       SUM(IF(x.severity = 'major', x.qty, 0)) AS major,
+		       SUM(IF(x.severity = 'minor', x.qty, 0)) AS minor
+		FROM (
+			SELECT f.inspection_id, i.result, f.severity, f.qty,
+			       CASE
+			           WHEN f.work IS NOT NULL THEN CONCAT('work:', f.work)
+			           WHEN f.operation_type IS NOT NULL THEN CONCAT('type:', f.operation_type)
+			           WHEN t.work IS NOT NULL THEN CONCAT('work:', t.work)
+			           WHEN t.operation_type IS NOT NULL THEN CONCAT('type:', t.operation_type)
+			           ELSE ''
+			       END AS bucket_key
+			FROM production_run_qc_inspection_defect f
+			JOIN production_run_qc_inspection i ON i.id = f.inspection_id
+			JOIN qc_defect_type t ON t.id = f.defect_type_id
+			WHERE `+decided+`
+		) x
+		LEFT JOIN operation_work ow ON x.bucket_key = CONCAT('work:', ow.token)
+		GROUP BY x.bucket_key
+		ORDER BY SUM(x.qty) DESC, x.bucket_key`, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't group qc defects by operation: %w", err)
+	}
+	for i := range out.ByOperation {
+		out.ByOperation[i].Inspected = out.Total.Inspected
+		if out.ByOperation[i].Label == "" {
+			out.ByOperation[i].Label = strings.TrimPrefix(out.ByOperation[i].Key, "type:")
+		}
+	}
+	return out, nil
+}
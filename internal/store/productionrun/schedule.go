@@ -0,0 +1,114 @@
+package productionrun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// ПЛАН ЗАГРУЗКИ (0342). Здесь только факты об открытых прогонах — количество, сделанное, нормы; что
+// из них следует, решает internal/runschedule.
+//
+// НОРМА ПРОГОНА — ИЗ СНИМКА, КОГДА ОН ЕСТЬ. Прогон, которому напечатали купоны, шьётся по снимку
+// операций (0340), и правка карты после печати его нагрузку не меняет. Прогон без пачек читает
+// живые операции карты: другого источника у него нет, и это ровно то, что напечатается.
+
+// scheduleOpenStatuses are the runs that still ask the workshop for time.
+var scheduleOpenStatuses = []string{
+	string(entity.ProductionRunDraft),
+	string(entity.ProductionRunPlanned),
+	string(entity.ProductionRunInProgress),
+	string(entity.ProductionRunPartiallyReceived),
+}
+
+type smvRow struct {
+	Key        int                 `db:"k"`
+	SMV        decimal.NullDecimal `db:"smv"`
+	Operations int                 `db:"operations"`
+	Missing    int                 `db:"missing"`
+}
+
+// ListRunsForSchedule returns the open runs with what the scheduler loads them by.
+func (s *Store) ListRunsForSchedule(ctx context.Context) ([]entity.ProductionScheduleRun, error) {
+	runs, err := storeutil.QueryListNamed[entity.ProductionScheduleRun](ctx, s.DB, `
+		SELECT r.id AS run_id, r.tech_card_id,
+		       COALESCE(tc.name, '') AS tech_card_name, COALESCE(tc.style_number, '') AS style_number,
+		       r.status, r.planned_start_at, r.promised_at, r.supplier_id,
+		       COALESCE(l.planned_qty, 0) AS planned_qty, COALESCE(l.done_qty, 0) AS done_qty
+		FROM production_run r
+		JOIN tech_card tc ON tc.id = r.tech_card_id
+		LEFT JOIN (
+			SELECT run_id, SUM(planned_qty) AS planned_qty,
+			       SUM(COALESCE(received_qty, 0) + COALESCE(defect_qty, 0)) AS done_qty
+			FROM production_run_line GROUP BY run_id
+		) l ON l.run_id = r.id
+		WHERE r.status IN (:statuses)
+		ORDER BY r.id`, map[string]any{"statuses": scheduleOpenStatuses})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs for schedule: %w", err)
+	}
+	if len(runs) == 0 {
+		return runs, nil
+	}
+	runIDs := make([]int, 0, len(runs))
+	cardIDs := make([]int, 0, len(runs))
+	for _, r := range runs {
+		runIDs = append(runIDs, r.RunId)
+		cardIDs = append(cardIDs, r.TechCardId)
+	}
+
+	snapshot, err := storeutil.QueryListNamed[smvRow](ctx, s.DB, `
+		SELECT run_id AS k, SUM(smv) AS smv, COUNT(*) AS operations, SUM(smv IS NULL) AS missing
+		FROM production_run_operation WHERE run_id IN (:ids) GROUP BY run_id`,
+		map[string]any{"ids": runIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load run operation norms: %w", err)
+	}
+	live, err := storeutil.QueryListNamed[smvRow](ctx, s.DB, `
+		SELECT tech_card_id AS k, SUM(smv) AS smv, COUNT(*) AS operations, SUM(smv IS NULL) AS missing
+		FROM tech_card_operation WHERE tech_card_id IN (:ids) GROUP BY tech_card_id`,
+		map[string]any{"ids": cardIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tech card operation norms: %w", err)
+	}
+	scanned, err := storeutil.QueryListNamed[struct {
+		RunId   int             `db:"run_id"`
+		Minutes decimal.Decimal `db:"minutes"`
+	}](ctx, s.DB, `
+		SELECT b.run_id, SUM(sc.qty * o.smv) AS minutes
+		FROM production_run_bundle_scan sc
+		JOIN production_run_bundle b ON b.id = sc.bundle_id
+		JOIN production_run_operation o ON o.id = sc.operation_id
+		WHERE b.run_id IN (:ids) AND o.smv IS NOT NULL
+		GROUP BY b.run_id`, map[string]any{"ids": runIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scanned minutes: %w", err)
+	}
+
+	byRun := make(map[int]smvRow, len(snapshot))
+	for _, r := range snapshot {
+		byRun[r.Key] = r
+	}
+	byCard := make(map[int]smvRow, len(live))
+	for _, r := range live {
+		byCard[r.Key] = r
+	}
+	done := make(map[int]decimal.Decimal, len(scanned))
+	for _, r := range scanned {
+		done[r.RunId] = r.Minutes
+	}
+	for i := range runs {
+		norm, ok := byRun[runs[i].RunId]
+		if !ok {
+			norm = byCard[runs[i].TechCardId]
+		}
+		runs[i].SMVPerUnit = norm.SMV
+		runs[i].OperationCount = norm.Operations
+		runs[i].OperationsWithoutSMV = norm.Missing
+		runs[i].ScannedMinutes = done[runs[i].RunId]
+	}
+	return runs, nil
+}
@@ -0,0 +1,722 @@
+package productionrun
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/inventory"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// ПОДРЯД НА ПОШИВ (migration 0344): профили фабрик, соглашения о цене пошива, назначение прогона
+// фабрике, движение комплекта и сверка. Гейт финальной приёмки живёт в productionrun_receipt.go,
+// рядом с остальными гвардами приёмки.
+//
+// ТРИ РЕШЕНИЯ ЖИВУТ В ЭТОМ ФАЙЛЕ.
+//
+//  1. ЦЕНА — СНИМОК НА НАЗНАЧЕНИИ. Соглашение резолвится один раз, когда прогон отдают фабрике, и
+//     цена с валютой копируются на production_run_subcontract. Закрытие соглашения и новая цена
+//     завтра не переоценивают работу, которую фабрика взяла по вчерашней.
+//
+//  2. СМЕНА ФАБРИКИ — ТОЛЬКО ДО ПЕРВОГО ДВИЖЕНИЯ КОМПЛЕКТА. Отгруженный комплект лежит у конкретной
+//     фабрики (supplier_id на движении), и переназначение оставило бы его у старой без хозяина.
+//
+//  3. СВЕРКА ЗАКРЫВАЕТ КОМПЛЕКТ ОДНОЙ ТРАНЗАКЦИЕЙ под блокировкой прогона: остаток консигнации
+//     каждого материала списывается в прогон (inventory.SettleConsignmentInTx), строки сверки
+//     записываются, начисляется строка затрат kind='cmt' за годные изделия. Всё это должно случиться
+//     ДО финальной приёмки — она замораживает себестоимость, и материал с пошивом, пришедшие после,
+//     остались бы в WIP прогона, который больше нельзя принять.
+
+const subcontractorColumns = `sc.supplier_id, sup.name AS supplier_name, sc.contact_name, sc.contact_email,
+	sc.contact_phone, sc.address, sc.note, sc.archived_at, sc.created_by, sc.created_at, sc.updated_at`
+
+// ListSubcontractors returns the factory profiles by name; archived ones only when asked for.
+func (s *Store) ListSubcontractors(ctx context.Context, includeArchived bool) ([]entity.Subcontractor, error) {
+	where := "sc.archived_at IS NULL"
+	if includeArchived {
+		where = "TRUE"
+	}
+	out, err := storeutil.QueryListNamed[entity.Subcontractor](ctx, s.DB, `
+		SELECT `+subcontractorColumns+`
+		FROM subcontractor sc JOIN supplier sup ON sup.id = sc.supplier_id
+		WHERE `+where+`
+		ORDER BY sup.name, sc.supplier_id`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't list subcontractors: %w", err)
+	}
+	return out, nil
+}
+
+// UpsertSubcontractor creates or replaces the profile of a catalog supplier. An unknown supplier is
+// a field violation: the profile extends the catalog, it never invents a supplier.
+func (s *Store) UpsertSubcontractor(ctx context.Context, ins entity.SubcontractorInsert, username string) (*entity.Subcontractor, error) {
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		INSERT INTO subcontractor (supplier_id, contact_name, contact_email, contact_phone, address, note, created_by)
+		VALUES (:supplier_id, :contact_name, :contact_email, :contact_phone, :address, :note, :created_by)
+		ON DUPLICATE KEY UPDATE contact_name = VALUES(contact_name), contact_email = VALUES(contact_email),
+			contact_phone = VALUES(contact_phone), address = VALUES(address), note = VALUES(note)`,
+		map[string]any{
+			"supplier_id":   ins.SupplierId,
+			"contact_name":  ins.ContactName,
+			"contact_email": ins.ContactEmail,
+			"contact_phone": ins.ContactPhone,
+			"address":       ins.Address,
+			"note":          ins.Note,
+			"created_by":    username,
+		}); err != nil {
+		var me *mysql.MySQLError
+		if errors.As(err, &me) && me.Number == 1452 {
+			return nil, entity.NewFieldViolation("supplier_id", "unknown_supplier", fmt.Sprint(ins.SupplierId),
+				"pick a supplier from the supplier catalog")
+		}
+		return nil, fmt.Errorf("failed to upsert subcontractor %d: %w", ins.SupplierId, err)
+	}
+	return s.getSubcontractor(ctx, s.DB, ins.SupplierId, false)
+}
+
+// SetSubcontractorArchived takes a factory off (or back on) assignment. Runs already on it keep
+// going: archiving says «no new work», not «take the work back».
+func (s *Store) SetSubcontractorArchived(ctx context.Context, supplierID int, archived bool) (*entity.Subcontractor, error) {
+	n, err := storeutil.QueryCountNamed(ctx, s.DB,
+		`SELECT COUNT(*) FROM subcontractor WHERE supplier_id = :id`, map[string]any{"id": supplierID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subcontractor %d: %w", supplierID, err)
+	}
+	if n == 0 {
+		return nil, entity.ErrSubcontractorNotFound
+	}
+	set := "archived_at = NULL"
+	if archived {
+		set = "archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP)"
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB,
+		`UPDATE subcontractor SET `+set+` WHERE supplier_id = :id`, map[string]any{"id": supplierID}); err != nil {
+		return nil, fmt.Errorf("failed to archive subcontractor %d: %w", supplierID, err)
+	}
+	return s.getSubcontractor(ctx, s.DB, supplierID, false)
+}
+
+func (s *Store) getSubcontractor(ctx context.Context, db dependency.DB, supplierID int, forUpdate bool) (*entity.Subcontractor, error) {
+	lock := ""
+	if forUpdate {
+		lock = " FOR UPDATE"
+	}
+	sc, err := storeutil.QueryNamedOne[entity.Subcontractor](ctx, db, `
+		SELECT `+subcontractorColumns+`
+		FROM subcontractor sc JOIN supplier sup ON sup.id = sc.supplier_id
+		WHERE sc.supplier_id = :id`+lock, map[string]any{"id": supplierID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrSubcontractorNotFound
+		}
+		return nil, fmt.Errorf("can't load subcontractor %d: %w", supplierID, err)
+	}
+	return &sc, nil
+}
+
+const cmtAgreementColumns = `a.id, a.supplier_id, sup.name AS supplier_name, a.tech_card_id,
+	COALESCE(tc.style_number, '') AS style_number, COALESCE(tc.name, '') AS style_name,
+	a.unit_price, a.currency, a.valid_from, a.valid_to, a.note, a.created_by, a.created_at`
+
+const cmtAgreementFrom = `cmt_price_agreement a
+	JOIN supplier sup ON sup.id = a.supplier_id
+	JOIN tech_card tc ON tc.id = a.tech_card_id`
+
+// ListCmtPriceAgreements returns agreements matching the filter, newest period first. Closed ones
+// (valid_to before today) only when asked for.
+func (s *Store) ListCmtPriceAgreements(ctx context.Context, f entity.CmtPriceAgreementFilter) ([]entity.CmtPriceAgreement, error) {
+	where := []string{"TRUE"}
+	args := map[string]any{}
+	if f.SupplierId > 0 {
+		where = append(where, "a.supplier_id = :supplier_id")
+		args["supplier_id"] = f.SupplierId
+	}
+	if f.TechCardId > 0 {
+		where = append(where, "a.tech_card_id = :tech_card_id")
+		args["tech_card_id"] = f.TechCardId
+	}
+	if !f.IncludeClosed {
+		where = append(where, "(a.valid_to IS NULL OR a.valid_to >= :today)")
+		args["today"] = s.Now().UTC().Format(time.DateOnly)
+	}
+	out, err := storeutil.QueryListNamed[entity.CmtPriceAgreement](ctx, s.DB, `
+		SELECT `+cmtAgreementColumns+` FROM `+cmtAgreementFrom+`
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY sup.name, tc.style_number, a.valid_from DESC, a.id DESC`, args)
+	if err != nil {
+		return nil, fmt.Errorf("can't list cmt price agreements: %w", err)
+	}
+	return out, nil
+}
+
+// CreateCmtPriceAgreement opens an agreement. The factory must have a live profile, and the period
+// may not overlap another agreement of the same factory and style — the overlap check runs under
+// the profile's row lock, so two concurrent creates cannot both pass it.
+func (s *Store) CreateCmtPriceAgreement(ctx context.Context, ins entity.CmtPriceAgreementInsert, username string) (*entity.CmtPriceAgreement, error) {
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		sc, err := s.getSubcontractor(ctx, db, ins.SupplierId, true)
+		if err != nil {
+			return err
+		}
+		if sc.ArchivedAt.Valid {
+			return entity.ErrSubcontractorArchived
+		}
+		// Two periods [f1, t1] and [f2, t2] overlap unless one ends before the other starts; an open
+		// end is the far future.
+		overlaps, err := storeutil.QueryCountNamed(ctx, db, `
+			SELECT COUNT(*) FROM cmt_price_agreement
+			WHERE supplier_id = :supplier_id AND tech_card_id = :tech_card_id
+			  AND (valid_to IS NULL OR valid_to >= :valid_from)
+			  AND (:valid_to IS NULL OR valid_from <= :valid_to)`,
+			map[string]any{
+				"supplier_id":  ins.SupplierId,
+				"tech_card_id": ins.TechCardId,
+				"valid_from":   ins.ValidFrom.Format(time.DateOnly),
+				"valid_to":     nullDate(ins.ValidTo),
+			})
+		if err != nil {
+			return fmt.Errorf("failed to check cmt agreement overlap: %w", err)
+		}
+		if overlaps > 0 {
+			return entity.ErrCmtAgreementOverlap
+		}
+		id, err = storeutil.ExecNamedLastId(ctx, db, `
+			INSERT INTO cmt_price_agreement
+				(supplier_id, tech_card_id, unit_price, currency, valid_from, valid_to, note, created_by)
+			VALUES (:supplier_id, :tech_card_id, :unit_price, :currency, :valid_from, :valid_to, :note, :created_by)`,
+			map[string]any{
+				"supplier_id":  ins.SupplierId,
+				"tech_card_id": ins.TechCardId,
+				"unit_price":   ins.UnitPrice,
+				"currency":     strings.ToUpper(ins.Currency),
+				"valid_from":   ins.ValidFrom.Format(time.DateOnly),
+				"valid_to":     nullDate(ins.ValidTo),
+				"note":         ins.Note,
+				"created_by":   username,
+			})
+		if err != nil {
+			var me *mysql.MySQLError
+			if errors.As(err, &me) && me.Number == 1452 {
+				return entity.NewFieldViolation("tech_card_id", "unknown_tech_card", fmt.Sprint(ins.TechCardId),
+					"pick an existing tech card")
+			}
+			return fmt.Errorf("failed to insert cmt price agreement: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getCmtAgreement(ctx, s.DB, id)
+}
+
+// CloseCmtPriceAgreement ends an agreement on validTo (its last day in force). Closing only ever
+// shortens a period: an agreement already closed earlier stays closed at its own date, and a date
+// before the start is a field violation.
+func (s *Store) CloseCmtPriceAgreement(ctx context.Context, id int, validTo time.Time) (*entity.CmtPriceAgreement, error) {
+	a, err := s.getCmtAgreement(ctx, s.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	day := validTo.Format(time.DateOnly)
+	if day < a.ValidFrom.Format(time.DateOnly) {
+		return nil, entity.NewFieldViolation("valid_to", "before_valid_from", day,
+			"close the agreement on or after the day it came into force")
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE cmt_price_agreement SET valid_to = :valid_to
+		WHERE id = :id AND (valid_to IS NULL OR valid_to > :valid_to)`,
+		map[string]any{"id": id, "valid_to": day}); err != nil {
+		return nil, fmt.Errorf("failed to close cmt price agreement %d: %w", id, err)
+	}
+	return s.getCmtAgreement(ctx, s.DB, id)
+}
+
+func (s *Store) getCmtAgreement(ctx context.Context, db dependency.DB, id int) (*entity.CmtPriceAgreement, error) {
+	a, err := storeutil.QueryNamedOne[entity.CmtPriceAgreement](ctx, db, `
+		SELECT `+cmtAgreementColumns+` FROM `+cmtAgreementFrom+` WHERE a.id = :id`, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrCmtAgreementNotFound
+		}
+		return nil, fmt.Errorf("can't load cmt price agreement %d: %w", id, err)
+	}
+	return &a, nil
+}
+
+const runSubcontractColumns = `rs.run_id, rs.supplier_id, sup.name AS supplier_name, rs.agreement_id,
+	rs.unit_price, rs.currency, rs.portal_epoch, rs.portal_expires_at, rs.portal_revoked_at,
+	rs.confirmed_ship_date, rs.confirmed_qty, rs.factory_note, rs.confirmed_at,
+	rs.returned_good_qty, rs.returned_defect_qty, rs.reconcile_note, rs.reconciled_by, rs.reconciled_at,
+	rs.assigned_by, rs.created_at, rs.updated_at`
+
+// runSubcontractHeader is the status of the run read together with its subcontract row under the
+// run lock — every write of this file starts from it.
+type runSubcontractHeader struct {
+	Status     string        `db:"status"`
+	TechCardId int           `db:"tech_card_id"`
+	SupplierId sql.NullInt32 `db:"sc_supplier_id"`
+	Reconciled bool          `db:"reconciled"`
+}
+
+func lockRunSubcontract(ctx context.Context, db dependency.DB, runID int) (runSubcontractHeader, error) {
+	h, err := storeutil.QueryNamedOne[runSubcontractHeader](ctx, db, `
+		SELECT r.status, r.tech_card_id, rs.supplier_id AS sc_supplier_id,
+		       (rs.reconciled_at IS NOT NULL) AS reconciled
+		FROM production_run r
+		LEFT JOIN production_run_subcontract rs ON rs.run_id = r.id
+		WHERE r.id = :id FOR UPDATE`, map[string]any{"id": runID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return runSubcontractHeader{}, sql.ErrNoRows
+		}
+		return runSubcontractHeader{}, fmt.Errorf("failed to lock production run %d for subcontract: %w", runID, err)
+	}
+	return h, nil
+}
+
+// runOpenForSubcontract is the status window in which a run can be handed to a factory and its kit
+// can move: planned through partially received, the same window a material issue accepts.
+func runOpenForSubcontract(status string) bool {
+	switch entity.ProductionRunStatus(status) {
+	case entity.ProductionRunPlanned, entity.ProductionRunInProgress, entity.ProductionRunPartiallyReceived:
+		return true
+	}
+	return false
+}
+
+// AssignRunSubcontractor hands a run to a factory at the price of an agreement: the one named, or
+// the one of the factory for the run's style in force today. The run's supplier_id follows, so
+// per-vendor reporting keeps reading one column. Reassigning is allowed until the kit first moves.
+func (s *Store) AssignRunSubcontractor(ctx context.Context, runID, supplierID, agreementID int, username string) (*entity.ProductionRunSubcontract, error) {
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		h, err := lockRunSubcontract(ctx, db, runID)
+		if err != nil {
+			return err
+		}
+		if !runOpenForSubcontract(h.Status) {
+			return fmt.Errorf("%w: production run %d is %s (not open)", entity.ErrMaterialIssueTargetInvalid, runID, h.Status)
+		}
+		if h.Reconciled {
+			return entity.ErrSubcontractReconciled
+		}
+		if h.SupplierId.Valid && int(h.SupplierId.Int32) != supplierID {
+			moved, err := storeutil.QueryCountNamed(ctx, db, `
+				SELECT COUNT(*) FROM material_stock_movement
+				WHERE production_run_id = :id AND movement_type IN (:out, :ret)`,
+				map[string]any{
+					"id":  runID,
+					"out": string(entity.MaterialMovementConsignOut),
+					"ret": string(entity.MaterialMovementConsignReturn),
+				})
+			if err != nil {
+				return fmt.Errorf("failed to check kit movements of run %d: %w", runID, err)
+			}
+			if moved > 0 {
+				return entity.ErrSubcontractKitShipped
+			}
+		}
+		sc, err := s.getSubcontractor(ctx, db, supplierID, false)
+		if err != nil {
+			return err
+		}
+		if sc.ArchivedAt.Valid {
+			return entity.ErrSubcontractorArchived
+		}
+		agreement, err := s.resolveCmtAgreement(ctx, db, supplierID, h.TechCardId, agreementID)
+		if err != nil {
+			return err
+		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			INSERT INTO production_run_subcontract (run_id, supplier_id, agreement_id, unit_price, currency, assigned_by)
+			VALUES (:run_id, :supplier_id, :agreement_id, :unit_price, :currency, :assigned_by)
+			ON DUPLICATE KEY UPDATE supplier_id = VALUES(supplier_id), agreement_id = VALUES(agreement_id),
+				unit_price = VALUES(unit_price), currency = VALUES(currency), assigned_by = VALUES(assigned_by)`,
+			map[string]any{
+				"run_id":       runID,
+				"supplier_id":  supplierID,
+				"agreement_id": agreement.Id,
+				"unit_price":   agreement.UnitPrice,
+				"currency":     agreement.Currency,
+				"assigned_by":  username,
+			}); err != nil {
+			return fmt.Errorf("failed to assign production run %d to subcontractor %d: %w", runID, supplierID, err)
+		}
+		// The run header changes, so the version moves: a run form opened before the assignment would
+		// otherwise save the old factory straight back over it.
+		if err := storeutil.ExecNamed(ctx, db, `
+			UPDATE production_run SET supplier_id = :supplier_id, lock_version = lock_version + 1 WHERE id = :id`,
+			map[string]any{"id": runID, "supplier_id": supplierID}); err != nil {
+			return fmt.Errorf("failed to set factory of production run %d: %w", runID, err)
+		}
+		return recordRunEvent(ctx, db, runID, entity.ProductionRunEventSubcontractAssigned, username, "",
+			map[string]any{"supplier_id": supplierID, "agreement_id": agreement.Id})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRunSubcontract(ctx, runID)
+}
+
+// resolveCmtAgreement returns the agreement a run is priced at. A named agreement must belong to the
+// factory and the style and be in force today; without one, the single agreement in force today is
+// taken (periods of one factory and style never overlap, so there is at most one).
+func (s *Store) resolveCmtAgreement(ctx context.Context, db dependency.DB, supplierID, techCardID, agreementID int) (*entity.CmtPriceAgreement, error) {
+	today := s.Now().UTC()
+	if agreementID > 0 {
+		a, err := s.getCmtAgreement(ctx, db, agreementID)
+		if err != nil {
+			return nil, err
+		}
+		if a.SupplierId != supplierID || a.TechCardId != techCardID || !a.InForce(today) {
+			return nil, entity.ErrCmtAgreementNotFound
+		}
+		return a, nil
+	}
+	list, err := storeutil.QueryListNamed[entity.CmtPriceAgreement](ctx, db, `
+		SELECT `+cmtAgreementColumns+` FROM `+cmtAgreementFrom+`
+		WHERE a.supplier_id = :supplier_id AND a.tech_card_id = :tech_card_id
+		  AND a.valid_from <= :today AND (a.valid_to IS NULL OR a.valid_to >= :today)
+		ORDER BY a.valid_from DESC LIMIT 1`,
+		map[string]any{"supplier_id": supplierID, "tech_card_id": techCardID, "today": today.Format(time.DateOnly)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cmt price agreement: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, entity.ErrCmtAgreementNotFound
+	}
+	return &list[0], nil
+}
+
+// GetRunSubcontract returns the run's subcontract with its kit. entity.ErrSubcontractNotFound when
+// the run is not on subcontract (or does not exist).
+func (s *Store) GetRunSubcontract(ctx context.Context, runID int) (*entity.ProductionRunSubcontract, error) {
+	return getRunSubcontract(ctx, s.DB, runID)
+}
+
+func getRunSubcontract(ctx context.Context, db dependency.DB, runID int) (*entity.ProductionRunSubcontract, error) {
+	rs, err := storeutil.QueryNamedOne[entity.ProductionRunSubcontract](ctx, db, `
+		SELECT `+runSubcontractColumns+`
+		FROM production_run_subcontract rs JOIN supplier sup ON sup.id = rs.supplier_id
+		WHERE rs.run_id = :id`, map[string]any{"id": runID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrSubcontractNotFound
+		}
+		return nil, fmt.Errorf("can't load subcontract of production run %d: %w", runID, err)
+	}
+	if rs.Reconciled() {
+		rs.Kit, err = storeutil.QueryListNamed[entity.SubcontractKitLine](ctx, db, `
+			SELECT k.material_id, m.name AS material_name, COALESCE(m.unit, '') AS unit,
+			       k.shipped, k.returned, k.consumed, k.declared_consumed, k.unit_cost_base
+			FROM production_run_subcontract_kit k JOIN material m ON m.id = k.material_id
+			WHERE k.run_id = :id ORDER BY m.name, k.material_id`, map[string]any{"id": runID})
+	} else {
+		rs.Kit, err = openKitBalance(ctx, db, runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't load kit of production run %d: %w", runID, err)
+	}
+	return &rs, nil
+}
+
+// openKitBalance reads an open kit from the movements: what shipped, what came back, and the average
+// the remainder left the shelf at. Consumed is zero until reconciliation.
+func openKitBalance(ctx context.Context, db dependency.DB, runID int) ([]entity.SubcontractKitLine, error) {
+	return storeutil.QueryListNamed[entity.SubcontractKitLine](ctx, db, `
+		SELECT mv.material_id, m.name AS material_name, COALESCE(m.unit, '') AS unit,
+		       COALESCE(SUM(CASE mv.movement_type WHEN :out THEN mv.quantity ELSE 0 END), 0) AS shipped,
+		       COALESCE(SUM(CASE mv.movement_type WHEN :ret THEN mv.quantity ELSE 0 END), 0) AS returned,
+		       0 AS consumed,
+		       NULL AS declared_consumed,
+		       NULL AS unit_cost_base
+		FROM material_stock_movement mv JOIN material m ON m.id = mv.material_id
+		WHERE mv.production_run_id = :id AND mv.movement_type IN (:out, :ret)
+		GROUP BY mv.material_id, m.name, m.unit
+		ORDER BY m.name, mv.material_id`,
+		map[string]any{
+			"id":  runID,
+			"out": string(entity.MaterialMovementConsignOut),
+			"ret": string(entity.MaterialMovementConsignReturn),
+		})
+}
+
+// UpdateRunSubcontractPortal rotates, revokes or re-dates the factory's portal link.
+func (s *Store) UpdateRunSubcontractPortal(ctx context.Context, runID int, upd entity.SubcontractPortalUpdate) (*entity.ProductionRunSubcontract, error) {
+	set := []string{"portal_expires_at = :expires_at"}
+	if upd.Rotate {
+		set = append(set, "portal_epoch = portal_epoch + 1", "portal_revoked_at = NULL")
+	}
+	if upd.Revoke {
+		set = append(set, "portal_revoked_at = COALESCE(portal_revoked_at, CURRENT_TIMESTAMP)")
+	}
+	n, err := storeutil.QueryCountNamed(ctx, s.DB,
+		`SELECT COUNT(*) FROM production_run_subcontract WHERE run_id = :id`, map[string]any{"id": runID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check subcontract of production run %d: %w", runID, err)
+	}
+	if n == 0 {
+		return nil, entity.ErrSubcontractNotFound
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB,
+		`UPDATE production_run_subcontract SET `+strings.Join(set, ", ")+` WHERE run_id = :id`,
+		map[string]any{"id": runID, "expires_at": upd.ExpiresAt}); err != nil {
+		return nil, fmt.Errorf("failed to update subcontract portal of production run %d: %w", runID, err)
+	}
+	return s.GetRunSubcontract(ctx, runID)
+}
+
+// ShipSubcontractKit ships materials to the run's factory (consign_out per line, all or none).
+func (s *Store) ShipSubcontractKit(ctx context.Context, mv entity.SubcontractKitMove) ([]entity.MaterialMovement, error) {
+	return s.moveSubcontractKit(ctx, mv, false)
+}
+
+// ReturnSubcontractKit takes leftover materials back from the run's factory (consign_return).
+func (s *Store) ReturnSubcontractKit(ctx context.Context, mv entity.SubcontractKitMove) ([]entity.MaterialMovement, error) {
+	return s.moveSubcontractKit(ctx, mv, true)
+}
+
+func (s *Store) moveSubcontractKit(ctx context.Context, mv entity.SubcontractKitMove, isReturn bool) ([]entity.MaterialMovement, error) {
+	var out []entity.MaterialMovement
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		h, err := lockRunSubcontract(ctx, db, mv.RunId)
+		if err != nil {
+			return err
+		}
+		if !h.SupplierId.Valid {
+			return entity.ErrSubcontractNotFound
+		}
+		if h.Reconciled {
+			return entity.ErrSubcontractReconciled
+		}
+		supplierID := int(h.SupplierId.Int32)
+		out = make([]entity.MaterialMovement, 0, len(mv.Lines))
+		for _, in := range mv.Lines {
+			var m entity.MaterialMovement
+			if isReturn {
+				m, err = inventory.ConsignReturnInTx(ctx, db, mv.RunId, supplierID, in, mv)
+			} else {
+				m, err = inventory.ConsignOutInTx(ctx, db, mv.RunId, supplierID, in, mv)
+			}
+			if err != nil {
+				return err
+			}
+			out = append(out, m)
+		}
+		event := entity.ProductionRunEventSubcontractKitShipped
+		if isReturn {
+			event = entity.ProductionRunEventSubcontractKitReturn
+		}
+		ids := make([]int, len(out))
+		for i := range out {
+			ids[i] = out[i].Id
+		}
+		return recordRunEvent(ctx, db, mv.RunId, event, mv.Username, "",
+			map[string]any{"supplier_id": supplierID, "movement_ids": ids})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ConfirmRunSubcontract records what the factory confirmed through the portal. A confirmation
+// replaces the previous one — the factory moves its own date — until the kit is reconciled.
+func (s *Store) ConfirmRunSubcontract(ctx context.Context, runID int, c entity.SubcontractConfirmation) (*entity.ProductionRunSubcontract, error) {
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		h, err := lockRunSubcontract(ctx, db, runID)
+		if err != nil {
+			return err
+		}
+		if !h.SupplierId.Valid {
+			return entity.ErrSubcontractNotFound
+		}
+		if h.Reconciled {
+			return entity.ErrSubcontractReconciled
+		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			UPDATE production_run_subcontract
+			SET confirmed_ship_date = :ship_date, confirmed_qty = :qty, factory_note = :note,
+			    confirmed_at = CURRENT_TIMESTAMP
+			WHERE run_id = :id`,
+			map[string]any{"id": runID, "ship_date": c.ShipDate.Format(time.DateOnly), "qty": c.Qty, "note": c.Note}); err != nil {
+			return fmt.Errorf("failed to record subcontract confirmation of production run %d: %w", runID, err)
+		}
+		// Actor is the factory, not an admin: the event says who by its type and carries no username.
+		return recordRunEvent(ctx, db, runID, entity.ProductionRunEventSubcontractConfirmed, "", "",
+			map[string]any{"supplier_id": h.SupplierId.Int32, "ship_date": c.ShipDate.Format(time.DateOnly), "qty": c.Qty})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRunSubcontract(ctx, runID)
+}
+
+// ReconcileRunSubcontract closes the run's kit: everything still at the factory is booked into the
+// run at its consignment price, the per-material reconciliation rows are written, and the CMT charge
+// for the good garments that came back is accrued as a cost line of the run. Refused on a run that is
+// not open — a received run has frozen its cost, and a cancelled one takes its kit back instead.
+func (s *Store) ReconcileRunSubcontract(ctx context.Context, in entity.SubcontractReconcileInput) (*entity.ProductionRunSubcontract, error) {
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		h, err := lockRunSubcontract(ctx, db, in.RunId)
+		if err != nil {
+			return err
+		}
+		if !h.SupplierId.Valid {
+			return entity.ErrSubcontractNotFound
+		}
+		if h.Reconciled {
+			return entity.ErrSubcontractReconciled
+		}
+		if !runOpenForSubcontract(h.Status) {
+			return fmt.Errorf("%w: production run %d is %s (not open)", entity.ErrMaterialIssueTargetInvalid, in.RunId, h.Status)
+		}
+		rs, err := getRunSubcontract(ctx, db, in.RunId)
+		if err != nil {
+			return err
+		}
+		inKit := make(map[int]bool, len(rs.Kit))
+		for _, l := range rs.Kit {
+			inKit[l.MaterialId] = true
+		}
+		for id := range in.Declared {
+			if !inKit[id] {
+				return entity.NewFieldViolation("declared", "not_in_kit", fmt.Sprint(id),
+					"declare consumption only for materials shipped to the factory")
+			}
+		}
+		now := s.Now()
+		for _, l := range rs.Kit {
+			bal, err := inventory.ReadConsignmentBalance(ctx, db, in.RunId, l.MaterialId)
+			if err != nil {
+				return err
+			}
+			var cost decimal.NullDecimal
+			if bal.Qty.IsPositive() {
+				cost = bal.UnitCost(bal.Qty)
+				if _, err := inventory.SettleConsignmentInTx(ctx, db, in.RunId, int(h.SupplierId.Int32), l.MaterialId,
+					bal.Qty, cost, in.Username, now); err != nil {
+					return err
+				}
+			}
+			declared := decimal.NullDecimal{}
+			if d, ok := in.Declared[l.MaterialId]; ok {
+				declared = decimal.NullDecimal{Decimal: d, Valid: true}
+			}
+			if err := storeutil.ExecNamed(ctx, db, `
+				INSERT INTO production_run_subcontract_kit
+					(run_id, material_id, shipped, returned, consumed, declared_consumed, unit_cost_base)
+				VALUES (:run_id, :material_id, :shipped, :returned, :consumed, :declared, :cost)`,
+				map[string]any{
+					"run_id":      in.RunId,
+					"material_id": l.MaterialId,
+					"shipped":     l.Shipped,
+					"returned":    l.Returned,
+					"consumed":    bal.Qty,
+					"declared":    declared,
+					"cost":        cost,
+				}); err != nil {
+				return fmt.Errorf("failed to write kit reconciliation of material %d: %w", l.MaterialId, err)
+			}
+		}
+		if in.ReturnedGood > 0 {
+			if err := accrueCmtCost(ctx, rep, rs, in.ReturnedGood, now); err != nil {
+				return err
+			}
+		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			UPDATE production_run_subcontract
+			SET returned_good_qty = :good, returned_defect_qty = :defect, reconcile_note = :note,
+			    reconciled_by = :by, reconciled_at = :at
+			WHERE run_id = :id`,
+			map[string]any{
+				"id":     in.RunId,
+				"good":   in.ReturnedGood,
+				"defect": in.ReturnedDefect,
+				"note":   in.Note,
+				"by":     in.Username,
+				"at":     now,
+			}); err != nil {
+			return fmt.Errorf("failed to close subcontract of production run %d: %w", in.RunId, err)
+		}
+		// The costs of the run changed under an open form, the same reason a receipt moves the version.
+		if err := storeutil.ExecNamed(ctx, db,
+			`UPDATE production_run SET lock_version = lock_version + 1 WHERE id = :id`,
+			map[string]any{"id": in.RunId}); err != nil {
+			return fmt.Errorf("failed to bump production run %d version: %w", in.RunId, err)
+		}
+		return recordRunEvent(ctx, db, in.RunId, entity.ProductionRunEventSubcontractReconciled, in.Username, "",
+			map[string]any{"supplier_id": h.SupplierId.Int32, "returned_good": in.ReturnedGood, "returned_defect": in.ReturnedDefect})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRunSubcontract(ctx, in.RunId)
+}
+
+// accrueCmtCost adds the CMT charge — snapshot price × good garments back — as an accrued cost line
+// of the factory. The base amount is folded at today's costing rate; a currency without a rate
+// leaves it unset, exactly as a hand-entered line would (the actuals then say has_base=false).
+func accrueCmtCost(ctx context.Context, rep dependency.Repository, rs *entity.ProductionRunSubcontract, good int, now time.Time) error {
+	amount := rs.UnitPrice.Mul(decimal.NewFromInt(int64(good))).RoundBank(2)
+	c := entity.ProductionRunCost{
+		Kind:        entity.ProductionRunCostCMT,
+		Description: sql.NullString{String: fmt.Sprintf("CMT %s: %d × %s %s", rs.SupplierName, good, rs.UnitPrice.String(), rs.Currency), Valid: true},
+		Amount:      amount,
+		Currency:    rs.Currency,
+		IncurredAt:  sql.NullTime{Time: now, Valid: true},
+		SupplierId:  sql.NullInt64{Int64: int64(rs.SupplierId), Valid: true},
+		ApStatus:    sql.NullString{String: "accrued", Valid: true},
+	}
+	base := strings.ToUpper(cache.GetBaseCurrency())
+	if strings.EqualFold(rs.Currency, base) {
+		c.AmountBase = decimal.NullDecimal{Decimal: amount, Valid: true}
+	} else {
+		rates, err := rep.TechCards().GetCostingFxRatesToBase(ctx)
+		if err != nil {
+			return fmt.Errorf("load costing fx rates: %w", err)
+		}
+		if r, ok := rates[strings.ToUpper(rs.Currency)]; ok {
+			c.AmountBase = decimal.NullDecimal{Decimal: amount.Mul(r).RoundBank(2), Valid: true}
+		}
+	}
+	return insertRunCosts(ctx, rep.DB(), rs.RunId, []entity.ProductionRunCost{c})
+}
+
+// checkSubcontractSettled refuses the final receipt of a subcontracted run whose kit is still open.
+// The run row is already locked by the receipt, and every kit write takes the same lock first, so
+// the answer cannot change before the receipt commits.
+func checkSubcontractSettled(ctx context.Context, db dependency.DB, runID int) error {
+	open, err := storeutil.QueryCountNamed(ctx, db, `
+		SELECT COUNT(*) FROM production_run_subcontract WHERE run_id = :id AND reconciled_at IS NULL`,
+		map[string]any{"id": runID})
+	if err != nil {
+		return fmt.Errorf("failed to check subcontract of production run %d: %w", runID, err)
+	}
+	if open > 0 {
+		return entity.ErrSubcontractNotReconciled
+	}
+	return nil
+}
+
+func nullDate(t sql.NullTime) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.Format(time.DateOnly)
+}
@@ -1,88 +1,231 @@
-// Package promo implements promotional code management operations.
+// Package promo implements promotional code management operations and the rule-based evaluation
+// of entered codes against a cart (0335). A promo_code row carries the discount type and caps; its
+// per-currency amounts (promo_code_currency) and scopes (promo_code_scope) are loaded with it into
+// the in-memory promo cache. Generated single-use codes (promo_unique_code) are looked up in the DB.
+//
+// The order-flow methods (RecordRedemptions, ReleaseRedemptions) run on the caller's connection and
+// must be called inside the order store's transaction so the redemption moves with the order.
 package promo
 
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
 	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
 )
 
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
 // Store implements dependency.Promo.
 type Store struct {
 	storeutil.Base
+	txFunc TxFunc
 }
 
 // New creates a new promo store.
-func New(base storeutil.Base) *Store {
-	return &Store{Base: base}
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const promoColumnParams = `
+	free_shipping = :freeShipping, discount = :discount, expiration = :expiration, start = :start,
+	voucher = :voucher, allowed = :allowed, discount_type = :discountType, min_tier = :minTier,
+	max_uses = :maxUses, max_uses_per_customer = :maxUsesPerCustomer, buy_quantity = :buyQuantity,
+	get_quantity = :getQuantity, stacking = :stacking, exclude_sale_items = :excludeSaleItems,
+	unique_codes_only = :uniqueCodesOnly`
+
+func promoParams(promo *entity.PromoCodeInsert) map[string]any {
+	return map[string]any{
+		"code":               promo.Code,
+		"freeShipping":       promo.FreeShipping,
+		"discount":           promo.Discount,
+		"expiration":         startOfDay(promo.Expiration),
+		"start":              promo.Start,
+		"voucher":            promo.Voucher,
+		"allowed":            promo.Allowed,
+		"discountType":       promo.DiscountType,
+		"minTier":            promo.MinTier,
+		"maxUses":            promo.MaxUses,
+		"maxUsesPerCustomer": promo.MaxUsesPerCustomer,
+		"buyQuantity":        promo.BuyQuantity,
+		"getQuantity":        promo.GetQuantity,
+		"stacking":           promo.Stacking,
+		"excludeSaleItems":   promo.ExcludeSaleItems,
+		"uniqueCodesOnly":    promo.UniqueCodesOnly,
+	}
+}
+
+// replaceRules rewrites a promo's currency rules and scopes.
+func replaceRules(ctx context.Context, db dependency.DB, promoId int, promo *entity.PromoCodeInsert) error {
+	params := map[string]any{"promoId": promoId}
+	if err := storeutil.ExecNamed(ctx, db, `DELETE FROM promo_code_currency WHERE promo_id = :promoId`, params); err != nil {
+		return fmt.Errorf("can't clear promo currency rules: %w", err)
+	}
+	if err := storeutil.ExecNamed(ctx, db, `DELETE FROM promo_code_scope WHERE promo_id = :promoId`, params); err != nil {
+		return fmt.Errorf("can't clear promo scopes: %w", err)
+	}
+	for _, r := range promo.CurrencyRules {
+		err := storeutil.ExecNamed(ctx, db, `
+			INSERT INTO promo_code_currency (promo_id, currency, fixed_amount, min_spend)
+			VALUES (:promoId, :currency, :fixedAmount, :minSpend)`, map[string]any{
+			"promoId":     promoId,
+			"currency":    r.Currency,
+			"fixedAmount": r.FixedAmount,
+			"minSpend":    r.MinSpend,
+		})
+		if err != nil {
+			return fmt.Errorf("can't insert promo currency rule: %w", err)
+		}
+	}
+	for _, sc := range promo.Scopes {
+		err := storeutil.ExecNamed(ctx, db, `
+			INSERT INTO promo_code_scope (promo_id, scope_kind, scope_value)
+			VALUES (:promoId, :kind, :value)`, map[string]any{
+			"promoId": promoId,
+			"kind":    sc.Kind,
+			"value":   sc.Value,
+		})
+		if err != nil {
+			return fmt.Errorf("can't insert promo scope: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadRules attaches the currency rules and scopes to promos read from promo_code. The settings
+// store uses it when it fills the promo cache.
+func LoadRules(ctx context.Context, db dependency.DB, promos []entity.PromoCode) error {
+	if len(promos) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(promos))
+	for _, p := range promos {
+		ids = append(ids, p.Id)
+	}
+	rules, err := storeutil.QueryListNamed[entity.PromoCurrencyRule](ctx, db,
+		`SELECT * FROM promo_code_currency WHERE promo_id IN (:ids) ORDER BY promo_id, currency`, map[string]any{"ids": ids})
+	if err != nil {
+		return fmt.Errorf("can't get promo currency rules: %w", err)
+	}
+	scopes, err := storeutil.QueryListNamed[entity.PromoScope](ctx, db,
+		`SELECT * FROM promo_code_scope WHERE promo_id IN (:ids) ORDER BY promo_id, scope_kind, scope_value`, map[string]any{"ids": ids})
+	if err != nil {
+		return fmt.Errorf("can't get promo scopes: %w", err)
+	}
+	byId := make(map[int]*entity.PromoCode, len(promos))
+	for i := range promos {
+		promos[i].CurrencyRules = nil
+		promos[i].Scopes = nil
+		byId[promos[i].Id] = &promos[i]
+	}
+	for _, r := range rules {
+		if p, ok := byId[r.PromoId]; ok {
+			p.CurrencyRules = append(p.CurrencyRules, r)
+		}
+	}
+	for _, sc := range scopes {
+		if p, ok := byId[sc.PromoId]; ok {
+			p.Scopes = append(p.Scopes, sc)
+		}
+	}
+	return nil
+}
+
+// reloadPromo reads a promo and its rules back by code.
+func reloadPromo(ctx context.Context, db dependency.DB, code string) (entity.PromoCode, error) {
+	p, err := storeutil.QueryNamedOne[entity.PromoCode](ctx, db,
+		`SELECT * FROM promo_code WHERE code = :code`, map[string]any{"code": code})
+	if err != nil {
+		return entity.PromoCode{}, err
+	}
+	promos := []entity.PromoCode{p}
+	if err := LoadRules(ctx, db, promos); err != nil {
+		return entity.PromoCode{}, err
+	}
+	return promos[0], nil
 }
 
 func startOfDay(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.UTC().Location())
 }
 
-// AddPromo adds a new promo code.
+// AddPromo adds a new promo code with its currency rules and scopes.
 func (s *Store) AddPromo(ctx context.Context, promo *entity.PromoCodeInsert) error {
-	expiration := startOfDay(promo.Expiration)
-	id, err := storeutil.ExecNamedLastId(ctx, s.DB, `
-	INSERT INTO promo_code (code, free_shipping, discount, expiration, start, voucher, allowed) VALUES
-		(:code, :freeShipping, :discount, :expiration, :start, :voucher, :allowed)`, map[string]any{
-		"code":         promo.Code,
-		"freeShipping": promo.FreeShipping,
-		"discount":     promo.Discount,
-		"expiration":   expiration,
-		"start":        promo.Start,
-		"voucher":      promo.Voucher,
-		"allowed":      promo.Allowed,
+	if err := promo.Validate(); err != nil {
+		return err
+	}
+	var added entity.PromoCode
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		id, err := storeutil.ExecNamedLastId(ctx, rep.DB(), `
+		INSERT INTO promo_code (code, free_shipping, discount, expiration, start, voucher, allowed, discount_type,
+			min_tier, max_uses, max_uses_per_customer, buy_quantity, get_quantity, stacking, exclude_sale_items,
+			unique_codes_only)
+		VALUES (:code, :freeShipping, :discount, :expiration, :start, :voucher, :allowed, :discountType,
+			:minTier, :maxUses, :maxUsesPerCustomer, :buyQuantity, :getQuantity, :stacking, :excludeSaleItems,
+			:uniqueCodesOnly)`, promoParams(promo))
+		if err != nil {
+			return fmt.Errorf("failed to add promo code: %w", err)
+		}
+		if err := replaceRules(ctx, rep.DB(), id, promo); err != nil {
+			return err
+		}
+		added, err = reloadPromo(ctx, rep.DB(), promo.Code)
+		if err != nil {
+			return fmt.Errorf("failed to reload added promo code: %w", err)
+		}
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to add promo code: %w", err)
+		return err
 	}
-	cache.AddPromo(entity.PromoCode{
-		Id:              id,
-		PromoCodeInsert: *promo,
-	})
+	cache.AddPromo(added)
 
 	return nil
 }
 
 // UpdatePromoCode updates an existing promo code in place, identified by promo.Code. It replaces the
 // mutable fields (free_shipping / discount / expiration / start / voucher / allowed — including
-// re-enabling a disabled code via allowed=true) without touching the row's id, so no usage/creation
-// data is lost (the wave-1 delete-then-recreate workaround dropped it). A code that does not exist is
-// reported as sql.ErrNoRows (mapped to NOT_FOUND upstream).
+// re-enabling a disabled code via allowed=true — and the 0335 rules, currency rules and scopes)
+// without touching the row's id, so no usage/creation data is lost (the wave-1 delete-then-recreate
+// workaround dropped it). A code that does not exist is reported as sql.ErrNoRows (mapped to
+// NOT_FOUND upstream).
 func (s *Store) UpdatePromoCode(ctx context.Context, promo *entity.PromoCodeInsert) error {
-	expiration := startOfDay(promo.Expiration)
-	rows, err := storeutil.ExecNamedRows(ctx, s.DB, `
-	UPDATE promo_code
-	SET free_shipping = :freeShipping, discount = :discount, expiration = :expiration,
-	    start = :start, voucher = :voucher, allowed = :allowed
-	WHERE code = :code`, map[string]any{
-		"code":         promo.Code,
-		"freeShipping": promo.FreeShipping,
-		"discount":     promo.Discount,
-		"expiration":   expiration,
-		"start":        promo.Start,
-		"voucher":      promo.Voucher,
-		"allowed":      promo.Allowed,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to update promo code: %w", err)
-	}
-	if rows == 0 {
-		return sql.ErrNoRows
+	if err := promo.Validate(); err != nil {
+		return err
 	}
-	// Refresh the in-memory cache from DB truth so the row's id and the normalized expiration stay in
-	// sync (the promo cache is keyed by code, so this replaces the existing entry in place).
-	updated, err := storeutil.QueryNamedOne[entity.PromoCode](ctx, s.DB,
-		`SELECT * FROM promo_code WHERE code = :code`, map[string]any{"code": promo.Code})
+	var updated entity.PromoCode
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		existing, err := storeutil.QueryNamedOne[entity.PromoCode](ctx, rep.DB(),
+			`SELECT * FROM promo_code WHERE code = :code FOR UPDATE`, map[string]any{"code": promo.Code})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return sql.ErrNoRows
+			}
+			return fmt.Errorf("failed to lock promo code: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, rep.DB(), `UPDATE promo_code SET `+promoColumnParams+` WHERE code = :code`, promoParams(promo)); err != nil {
+			return fmt.Errorf("failed to update promo code: %w", err)
+		}
+		if err := replaceRules(ctx, rep.DB(), existing.Id, promo); err != nil {
+			return err
+		}
+		// Refresh from DB truth so the row's id and the normalized expiration stay in sync (the
+		// promo cache is keyed by code, so this replaces the existing entry in place).
+		updated, err = reloadPromo(ctx, rep.DB(), promo.Code)
+		if err != nil {
+			return fmt.Errorf("failed to reload updated promo code: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to reload updated promo code: %w", err)
+		return err
 	}
 	cache.AddPromo(updated)
 
@@ -103,6 +246,9 @@ func (s *Store) ListPromos(ctx context.Context, limit, offset int, orderFactor e
 	if err != nil {
 		return nil, fmt.Errorf("can't get PromoCode list: %w", err)
 	}
+	if err := LoadRules(ctx, s.DB, promos); err != nil {
+		return nil, err
+	}
 
 	return promos, nil
 }
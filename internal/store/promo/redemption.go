@@ -0,0 +1,310 @@
+package promo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// EvaluateCart resolves the entered codes (promo codes from the cache, generated codes from
+// promo_unique_code), counts their unreleased redemptions and prices them against the cart. Codes
+// that do not resolve are reported as rejected rather than failing the call, so the storefront can
+// show why a code did nothing.
+func (s *Store) EvaluateCart(ctx context.Context, codes []string, cart entity.PromoCart) (*entity.PromoBreakdown, error) {
+	codes = normalizeCodes(codes)
+	bd := &entity.PromoBreakdown{Discount: decimal.Zero}
+	if len(codes) == 0 {
+		return bd, nil
+	}
+	if cart.Now.IsZero() {
+		cart.Now = s.Now().UTC()
+	}
+
+	productIds := make([]int, 0, len(cart.Lines))
+	for _, l := range cart.Lines {
+		productIds = append(productIds, l.ProductId)
+	}
+	if len(productIds) > 0 {
+		giftCards, err := storeutil.QueryScalarListNamed[int](ctx, s.DB,
+			`SELECT product_id FROM gift_card_product WHERE product_id IN (:ids)`, map[string]any{"ids": productIds})
+		if err != nil {
+			return nil, fmt.Errorf("can't check gift card lines: %w", err)
+		}
+		// Promos would sell store credit below face value.
+		if len(giftCards) > 0 {
+			for _, c := range codes {
+				bd.Rejected = append(bd.Rejected, entity.PromoRejection{Code: c, Reason: entity.PromoRejectGiftCard})
+			}
+			return bd, nil
+		}
+	}
+
+	candidates, rejected, err := s.resolveCandidates(ctx, codes, cart.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillUsage(ctx, candidates, cart.BuyerEmail, cart.OrderId); err != nil {
+		return nil, err
+	}
+	if cart.Lines, err = s.scopeAttributes(ctx, cart.Lines, productIds); err != nil {
+		return nil, err
+	}
+
+	*bd = entity.EvaluatePromos(cart, candidates)
+	bd.Rejected = append(rejected, bd.Rejected...)
+	return bd, nil
+}
+
+func normalizeCodes(codes []string) []string {
+	out := make([]string, 0, len(codes))
+	for _, c := range codes {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// resolveCandidates looks each code up as a promo code, then as a generated unique code. A unique
+// code held by another order is already redeemed; orderId's own code stays usable when it is re-priced.
+func (s *Store) resolveCandidates(ctx context.Context, codes []string, orderId int) ([]entity.PromoCandidate, []entity.PromoRejection, error) {
+	var candidates []entity.PromoCandidate
+	var rejected []entity.PromoRejection
+	for _, code := range codes {
+		if p, ok := cache.GetPromoByCode(code); ok && !p.UniqueCodesOnly {
+			candidates = append(candidates, entity.PromoCandidate{Code: code, Promo: p})
+			continue
+		}
+		uc, err := storeutil.QueryNamedOne[entity.PromoUniqueCode](ctx, s.DB,
+			`SELECT id, promo_id, code, batch, order_id, redeemed_at, created_at FROM promo_unique_code WHERE code = :code`,
+			map[string]any{"code": strings.ToUpper(code)})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				rejected = append(rejected, entity.PromoRejection{Code: code, Reason: entity.PromoRejectNotFound})
+				continue
+			}
+			return nil, nil, fmt.Errorf("can't get unique promo code: %w", err)
+		}
+		if uc.OrderId.Valid && int(uc.OrderId.Int32) != orderId {
+			rejected = append(rejected, entity.PromoRejection{Code: code, Reason: entity.PromoRejectRedeemed})
+			continue
+		}
+		p, ok := cache.GetPromoById(uc.PromoId)
+		if !ok {
+			rejected = append(rejected, entity.PromoRejection{Code: code, Reason: entity.PromoRejectNotFound})
+			continue
+		}
+		candidates = append(candidates, entity.PromoCandidate{Code: uc.Code, Promo: p, UniqueCodeId: uc.Id})
+	}
+	return candidates, rejected, nil
+}
+
+type promoUsageRow struct {
+	PromoId  int `db:"promo_id"`
+	Total    int `db:"total"`
+	Customer int `db:"customer"`
+}
+
+// usage counts the unreleased redemptions of promoIds, overall and by email, leaving out orderId's.
+func usage(ctx context.Context, db dependency.DB, promoIds []int, email string, orderId int) (map[int]entity.PromoUsage, error) {
+	out := make(map[int]entity.PromoUsage, len(promoIds))
+	if len(promoIds) == 0 {
+		return out, nil
+	}
+	rows, err := storeutil.QueryListNamed[promoUsageRow](ctx, db, `
+		SELECT promo_id,
+		       COUNT(*) AS total,
+		       COALESCE(SUM(buyer_email <> '' AND buyer_email = :email), 0) AS customer
+		FROM customer_order_promo
+		WHERE promo_id IN (:ids) AND released = FALSE AND order_id <> :orderId
+		GROUP BY promo_id`, map[string]any{
+		"ids":     promoIds,
+		"email":   strings.ToLower(strings.TrimSpace(email)),
+		"orderId": orderId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't count promo redemptions: %w", err)
+	}
+	for _, r := range rows {
+		out[r.PromoId] = entity.PromoUsage{Total: r.Total, Customer: r.Customer}
+	}
+	return out, nil
+}
+
+func (s *Store) fillUsage(ctx context.Context, candidates []entity.PromoCandidate, email string, orderId int) error {
+	ids := make([]int, 0, len(candidates))
+	for _, c := range candidates {
+		if c.Promo.MaxUses.Valid || c.Promo.MaxUsesPerCustomer.Valid {
+			ids = append(ids, c.Promo.Id)
+		}
+	}
+	counts, err := usage(ctx, s.DB, ids, email, orderId)
+	if err != nil {
+		return err
+	}
+	for i := range candidates {
+		candidates[i].Usage = counts[candidates[i].Promo.Id]
+	}
+	return nil
+}
+
+type productScopeRow struct {
+	ProductId     int            `db:"product_id"`
+	TopCategoryId sql.NullInt32  `db:"top_category_id"`
+	Collection    string         `db:"collection"`
+	Tag           sql.NullString `db:"tag"`
+}
+
+// scopeAttributes fills each line's collection and tags (and its top category when the caller had
+// none) for scope matching.
+func (s *Store) scopeAttributes(ctx context.Context, lines []entity.PromoCartLine, productIds []int) ([]entity.PromoCartLine, error) {
+	if len(productIds) == 0 {
+		return lines, nil
+	}
+	rows, err := storeutil.QueryListNamed[productScopeRow](ctx, s.DB, `
+		SELECT p.id AS product_id, sty.top_category_id, COALESCE(sty.collection, '') AS collection, pt.tag
+		FROM product p
+		LEFT JOIN tech_card sty ON sty.id = p.style_id
+		LEFT JOIN product_tag pt ON pt.product_id = p.id
+		WHERE p.id IN (:ids)`, map[string]any{"ids": productIds})
+	if err != nil {
+		return nil, fmt.Errorf("can't get product scope attributes: %w", err)
+	}
+	type attrs struct {
+		topCategoryId int
+		collection    string
+		tags          []string
+	}
+	byProduct := make(map[int]*attrs, len(productIds))
+	for _, r := range rows {
+		a, ok := byProduct[r.ProductId]
+		if !ok {
+			a = &attrs{topCategoryId: int(r.TopCategoryId.Int32), collection: r.Collection}
+			byProduct[r.ProductId] = a
+		}
+		if r.Tag.Valid && r.Tag.String != "" {
+			a.tags = append(a.tags, r.Tag.String)
+		}
+	}
+	out := make([]entity.PromoCartLine, len(lines))
+	for i, l := range lines {
+		if a, ok := byProduct[l.ProductId]; ok {
+			l.Collection = a.collection
+			l.Tags = a.tags
+			if l.TopCategoryId == 0 {
+				l.TopCategoryId = a.topCategoryId
+			}
+		}
+		out[i] = l
+	}
+	return out, nil
+}
+
+// RecordRedemptions replaces orderId's active redemptions with the applied codes of bd: it drops the
+// previous unreleased rows, frees the unique codes the order held, then locks the applied promos,
+// re-checks their caps against everyone else's redemptions and claims the unique codes. A cap or a
+// unique code taken by a concurrent order since the cart was evaluated fails with a
+// *entity.ValidationError. Must run inside the order transaction.
+func (s *Store) RecordRedemptions(ctx context.Context, orderId int, buyerEmail string, bd *entity.PromoBreakdown) error {
+	params := map[string]any{"orderId": orderId}
+	if err := storeutil.ExecNamed(ctx, s.DB, `DELETE FROM customer_order_promo WHERE order_id = :orderId AND released = FALSE`, params); err != nil {
+		return fmt.Errorf("can't clear order promos: %w", err)
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `UPDATE promo_unique_code SET order_id = NULL, redeemed_at = NULL WHERE order_id = :orderId`, params); err != nil {
+		return fmt.Errorf("can't free order unique codes: %w", err)
+	}
+	if bd == nil || len(bd.Applied) == 0 {
+		return nil
+	}
+
+	email := strings.ToLower(strings.TrimSpace(buyerEmail))
+	ids := make([]int, 0, len(bd.Applied))
+	for _, a := range bd.Applied {
+		ids = append(ids, a.PromoId)
+	}
+	locked, err := storeutil.QueryListNamed[entity.PromoCode](ctx, s.DB,
+		`SELECT * FROM promo_code WHERE id IN (:ids) FOR UPDATE`, map[string]any{"ids": ids})
+	if err != nil {
+		return fmt.Errorf("can't lock promo codes: %w", err)
+	}
+	counts, err := usage(ctx, s.DB, ids, email, orderId)
+	if err != nil {
+		return err
+	}
+	for _, p := range locked {
+		u := counts[p.Id]
+		if p.MaxUses.Valid && u.Total >= int(p.MaxUses.Int32) {
+			return &entity.ValidationError{Message: fmt.Sprintf("promo code %s has reached its usage limit", p.Code), Field: "promo_code"}
+		}
+		if p.MaxUsesPerCustomer.Valid && email != "" && u.Customer >= int(p.MaxUsesPerCustomer.Int32) {
+			return &entity.ValidationError{Message: fmt.Sprintf("promo code %s was already used by this customer", p.Code), Field: "promo_code"}
+		}
+	}
+
+	now := s.Now().UTC()
+	for _, a := range bd.Applied {
+		uniqueId := sql.NullInt32{Int32: int32(a.UniqueCodeId), Valid: a.UniqueCodeId > 0}
+		if uniqueId.Valid {
+			n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+				UPDATE promo_unique_code SET order_id = :orderId, redeemed_at = :now
+				WHERE id = :id AND order_id IS NULL`, map[string]any{
+				"orderId": orderId,
+				"now":     now,
+				"id":      a.UniqueCodeId,
+			})
+			if err != nil {
+				return fmt.Errorf("can't redeem unique promo code: %w", err)
+			}
+			if n == 0 {
+				return &entity.ValidationError{Message: fmt.Sprintf("promo code %s was already redeemed", a.Code), Field: "promo_code"}
+			}
+		}
+		err := storeutil.ExecNamed(ctx, s.DB, `
+			INSERT INTO customer_order_promo (order_id, promo_id, unique_code_id, code, buyer_email, discount_amount, free_shipping)
+			VALUES (:orderId, :promoId, :uniqueCodeId, :code, :email, :discount, :freeShipping)`, map[string]any{
+			"orderId":      orderId,
+			"promoId":      a.PromoId,
+			"uniqueCodeId": uniqueId,
+			"code":         a.Code,
+			"email":        email,
+			"discount":     a.Discount,
+			"freeShipping": a.FreeShipping,
+		})
+		if err != nil {
+			return fmt.Errorf("can't record order promo: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReleaseRedemptions marks orderId's redemptions released, so they stop counting towards the caps,
+// and frees its unique codes for reuse. Idempotent; must run inside the order transaction.
+func (s *Store) ReleaseRedemptions(ctx context.Context, orderId int) error {
+	params := map[string]any{"orderId": orderId}
+	if err := storeutil.ExecNamed(ctx, s.DB, `UPDATE customer_order_promo SET released = TRUE WHERE order_id = :orderId`, params); err != nil {
+		return fmt.Errorf("can't release order promos: %w", err)
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB, `UPDATE promo_unique_code SET order_id = NULL, redeemed_at = NULL WHERE order_id = :orderId`, params); err != nil {
+		return fmt.Errorf("can't free order unique codes: %w", err)
+	}
+	return nil
+}
+
+// GetOrderPromoCodes returns the codes on orderId's active redemptions in the order they applied.
+func (s *Store) GetOrderPromoCodes(ctx context.Context, orderId int) ([]string, error) {
+	codes, err := storeutil.QueryScalarListNamed[string](ctx, s.DB,
+		`SELECT code FROM customer_order_promo WHERE order_id = :orderId AND released = FALSE ORDER BY id`,
+		map[string]any{"orderId": orderId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get order promo codes: %w", err)
+	}
+	return codes, nil
+}
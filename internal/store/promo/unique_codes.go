@@ -0,0 +1,138 @@
+package promo
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// maxUniqueCodeBatch bounds one generation run.
+const maxUniqueCodeBatch = 10000
+
+// uniqueCodeAlphabet leaves out 0/O and 1/I/L so a printed code cannot be mistyped.
+const uniqueCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// newUniqueCode returns PREFIX-XXXXXXXX (~39 bits); collisions are retried by the caller.
+func newUniqueCode(prefix string) (string, error) {
+	var b strings.Builder
+	if prefix != "" {
+		b.WriteString(prefix)
+		b.WriteByte('-')
+	}
+	max := big.NewInt(int64(len(uniqueCodeAlphabet)))
+	for i := 0; i < 8; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("can't generate promo code: %w", err)
+		}
+		b.WriteByte(uniqueCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// GenerateUniqueCodes creates count single-use codes for the promo code. Each redeems once with the
+// promo's rules; a promo with unique_codes_only set redeems through them alone.
+func (s *Store) GenerateUniqueCodes(ctx context.Context, code string, count int, prefix, batch string) ([]string, error) {
+	if count < 1 || count > maxUniqueCodeBatch {
+		return nil, &entity.ValidationError{Message: fmt.Sprintf("count must be between 1 and %d", maxUniqueCodeBatch), Field: "count"}
+	}
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	for _, r := range prefix {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return nil, &entity.ValidationError{Message: "prefix may only contain letters and digits", Field: "prefix"}
+		}
+	}
+	if len(prefix) > 20 {
+		return nil, &entity.ValidationError{Message: "prefix must be at most 20 characters", Field: "prefix"}
+	}
+	batch = strings.TrimSpace(batch)
+	if len(batch) > 64 {
+		return nil, &entity.ValidationError{Message: "batch must be at most 64 characters", Field: "batch"}
+	}
+
+	codes := make([]string, 0, count)
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		promo, err := storeutil.QueryNamedOne[entity.PromoCode](ctx, rep.DB(),
+			`SELECT * FROM promo_code WHERE code = :code`, map[string]any{"code": code})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return sql.ErrNoRows
+			}
+			return fmt.Errorf("can't get promo code: %w", err)
+		}
+		seen := make(map[string]bool, count)
+		for len(codes) < count {
+			c, err := newUniqueCode(prefix)
+			if err != nil {
+				return err
+			}
+			if seen[c] {
+				continue
+			}
+			n, err := storeutil.ExecNamedRows(ctx, rep.DB(), `
+				INSERT IGNORE INTO promo_unique_code (promo_id, code, batch)
+				VALUES (:promoId, :code, :batch)`, map[string]any{
+				"promoId": promo.Id,
+				"code":    c,
+				"batch":   batch,
+			})
+			if err != nil {
+				return fmt.Errorf("can't insert unique promo code: %w", err)
+			}
+			seen[c] = true
+			if n == 1 {
+				codes = append(codes, c)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ListUniqueCodes pages the generated codes of a promo, optionally one batch, with the total and how
+// many of them are redeemed.
+func (s *Store) ListUniqueCodes(ctx context.Context, code, batch string, limit, offset int) ([]entity.PromoUniqueCode, int, int, error) {
+	params := map[string]any{
+		"code":   code,
+		"batch":  strings.TrimSpace(batch),
+		"limit":  limit,
+		"offset": offset,
+	}
+	where := `WHERE pc.code = :code AND (:batch = '' OR uc.batch = :batch)`
+
+	type counts struct {
+		Total    int `db:"total"`
+		Redeemed int `db:"redeemed"`
+	}
+	c, err := storeutil.QueryNamedOne[counts](ctx, s.DB, `
+		SELECT COUNT(*) AS total, COALESCE(SUM(uc.order_id IS NOT NULL), 0) AS redeemed
+		FROM promo_unique_code uc
+		JOIN promo_code pc ON pc.id = uc.promo_id
+		`+where, params)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("can't count unique promo codes: %w", err)
+	}
+	codes, err := storeutil.QueryListNamed[entity.PromoUniqueCode](ctx, s.DB, `
+		SELECT uc.id, uc.promo_id, uc.code, uc.batch, uc.order_id, co.uuid AS order_uuid, uc.redeemed_at, uc.created_at
+		FROM promo_unique_code uc
+		JOIN promo_code pc ON pc.id = uc.promo_id
+		LEFT JOIN customer_order co ON co.id = uc.order_id
+		`+where+`
+		ORDER BY uc.id DESC
+		LIMIT :limit OFFSET :offset`, params)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("can't list unique promo codes: %w", err)
+	}
+	return codes, c.Total, c.Redeemed, nil
+}
@@ -0,0 +1,426 @@
+// Package purchaseorder implements material purchase orders (0336): the document that records what
+// was asked of a supplier, and the receiving path that books deliveries against it. A receipt against
+// a PO is booked by inventory.ReceiveInTx in the same transaction that locks the PO row and moves the
+// lines' received_qty, so the stock, the movement (and through it the M1 payable) and the PO can never
+// disagree about what arrived.
+package purchaseorder
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/inventory"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.PurchaseOrders.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new purchase order store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const selectOrder = `
+	SELECT po.id, po.supplier_id, s.name AS supplier_name, po.status, po.currency, po.ordered_at,
+	       po.expected_at, po.production_run_id, po.supplier_ref, po.notes, po.created_by,
+	       po.created_at, po.updated_at
+	FROM purchase_order po
+	JOIN supplier s ON s.id = po.supplier_id`
+
+const selectLine = `
+	SELECT l.id, l.purchase_order_id, l.material_id, m.name AS material_name, m.unit, l.ordered_qty,
+	       l.received_qty, l.unit_price, l.expected_at, l.note
+	FROM purchase_order_line l
+	JOIN material m ON m.id = l.material_id`
+
+// CreatePurchaseOrder stores a new draft and returns its id.
+func (s *Store) CreatePurchaseOrder(ctx context.Context, ins entity.PurchaseOrderInsert) (int, error) {
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		var err error
+		id, err = storeutil.ExecNamedLastId(ctx, rep.DB(), `
+			INSERT INTO purchase_order (supplier_id, status, currency, expected_at, production_run_id,
+				supplier_ref, notes, created_by)
+			VALUES (:supplierId, :status, :currency, :expectedAt, :runId, :supplierRef, :notes, :createdBy)`,
+			map[string]any{
+				"supplierId":  ins.SupplierId,
+				"status":      entity.PurchaseOrderDraft,
+				"currency":    ins.Currency,
+				"expectedAt":  ins.ExpectedAt(),
+				"runId":       ins.ProductionRunId,
+				"supplierRef": ins.SupplierRef,
+				"notes":       ins.Notes,
+				"createdBy":   ins.CreatedBy,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert purchase order: %w", err)
+		}
+		return insertLines(ctx, rep.DB(), id, ins.Lines)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdatePurchaseOrder replaces a draft's header and lines. Once sent, an order is the supplier's
+// document too and is no longer edited here: it is cancelled and raised again.
+func (s *Store) UpdatePurchaseOrder(ctx context.Context, id int, ins entity.PurchaseOrderInsert) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		po, err := lockOrder(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if po.Status != entity.PurchaseOrderDraft {
+			return fmt.Errorf("%w: only a draft can be edited, order is %s", entity.ErrPurchaseOrderState, po.Status)
+		}
+		err = storeutil.ExecNamed(ctx, db, `
+			UPDATE purchase_order SET supplier_id = :supplierId, currency = :currency,
+				expected_at = :expectedAt, production_run_id = :runId, supplier_ref = :supplierRef,
+				notes = :notes
+			WHERE id = :id`,
+			map[string]any{
+				"id":          id,
+				"supplierId":  ins.SupplierId,
+				"currency":    ins.Currency,
+				"expectedAt":  ins.ExpectedAt(),
+				"runId":       ins.ProductionRunId,
+				"supplierRef": ins.SupplierRef,
+				"notes":       ins.Notes,
+			})
+		if err != nil {
+			return fmt.Errorf("can't update purchase order: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM purchase_order_line WHERE purchase_order_id = :id`,
+			map[string]any{"id": id}); err != nil {
+			return fmt.Errorf("can't delete purchase order lines: %w", err)
+		}
+		return insertLines(ctx, db, id, ins.Lines)
+	})
+}
+
+func insertLines(ctx context.Context, db dependency.DB, orderID int, lines []entity.PurchaseOrderLineInsert) error {
+	for _, l := range lines {
+		err := storeutil.ExecNamed(ctx, db, `
+			INSERT INTO purchase_order_line (purchase_order_id, material_id, ordered_qty, unit_price,
+				expected_at, note)
+			VALUES (:orderId, :materialId, :qty, :price, :expectedAt, :note)`,
+			map[string]any{
+				"orderId":    orderID,
+				"materialId": l.MaterialId,
+				"qty":        l.OrderedQty,
+				"price":      l.UnitPrice,
+				"expectedAt": l.ExpectedAt,
+				"note":       l.Note,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert purchase order line: %w", err)
+		}
+	}
+	return nil
+}
+
+// lockOrder reads the header FOR UPDATE; every write to an order or its lines goes through it.
+func lockOrder(ctx context.Context, db dependency.DB, id int) (*entity.PurchaseOrder, error) {
+	po, err := storeutil.QueryNamedOne[entity.PurchaseOrder](ctx, db,
+		selectOrder+` WHERE po.id = :id FOR UPDATE`, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrPurchaseOrderNotFound
+		}
+		return nil, fmt.Errorf("can't lock purchase order: %w", err)
+	}
+	return &po, nil
+}
+
+func orderLines(ctx context.Context, db dependency.DB, ids []int) ([]entity.PurchaseOrderLine, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	lines, err := storeutil.QueryListNamed[entity.PurchaseOrderLine](ctx, db,
+		selectLine+` WHERE l.purchase_order_id IN (:ids) ORDER BY l.purchase_order_id, l.id`,
+		map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("can't get purchase order lines: %w", err)
+	}
+	return lines, nil
+}
+
+// GetPurchaseOrder returns an order with its lines.
+func (s *Store) GetPurchaseOrder(ctx context.Context, id int) (*entity.PurchaseOrderFull, error) {
+	po, err := storeutil.QueryNamedOne[entity.PurchaseOrder](ctx, s.DB, selectOrder+` WHERE po.id = :id`,
+		map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrPurchaseOrderNotFound
+		}
+		return nil, fmt.Errorf("can't get purchase order: %w", err)
+	}
+	lines, err := orderLines(ctx, s.DB, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	return &entity.PurchaseOrderFull{PurchaseOrder: po, Lines: lines}, nil
+}
+
+// ListPurchaseOrders lists orders, newest first, with their lines and the total count.
+func (s *Store) ListPurchaseOrders(ctx context.Context, limit, offset int, f entity.PurchaseOrderFilter) ([]entity.PurchaseOrderFull, int, error) {
+	where := []string{"1 = 1"}
+	params := map[string]any{"limit": limit, "offset": offset}
+	if f.Status != "" {
+		where = append(where, "po.status = :status")
+		params["status"] = f.Status
+	}
+	if f.OpenOnly {
+		where = append(where, "po.status IN (:open)")
+		params["open"] = []entity.PurchaseOrderStatus{entity.PurchaseOrderDraft, entity.PurchaseOrderOrdered,
+			entity.PurchaseOrderPartiallyReceived}
+	}
+	if f.SupplierId > 0 {
+		where = append(where, "po.supplier_id = :supplierId")
+		params["supplierId"] = f.SupplierId
+	}
+	if f.ProductionRunId > 0 {
+		where = append(where, "po.production_run_id = :runId")
+		params["runId"] = f.ProductionRunId
+	}
+	if f.MaterialId > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM purchase_order_line fl WHERE fl.purchase_order_id = po.id AND fl.material_id = :materialId)")
+		params["materialId"] = f.MaterialId
+	}
+	cond := " WHERE " + strings.Join(where, " AND ")
+
+	total, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM purchase_order po`+cond, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't count purchase orders: %w", err)
+	}
+	headers, err := storeutil.QueryListNamed[entity.PurchaseOrder](ctx, s.DB,
+		selectOrder+cond+` ORDER BY po.id DESC LIMIT :limit OFFSET :offset`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't get purchase orders: %w", err)
+	}
+	ids := make([]int, len(headers))
+	idx := make(map[int]int, len(headers))
+	out := make([]entity.PurchaseOrderFull, len(headers))
+	for i, h := range headers {
+		ids[i] = h.Id
+		idx[h.Id] = i
+		out[i].PurchaseOrder = h
+	}
+	lines, err := orderLines(ctx, s.DB, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, l := range lines {
+		i := idx[l.PurchaseOrderId]
+		out[i].Lines = append(out[i].Lines, l)
+	}
+	return out, total, nil
+}
+
+// SetPurchaseOrderStatus applies a hand-driven status change (entity.CheckPurchaseOrderTransition).
+// Sending a draft stamps ordered_at with today.
+func (s *Store) SetPurchaseOrderStatus(ctx context.Context, id int, to entity.PurchaseOrderStatus) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		po, err := lockOrder(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		lines, err := orderLines(ctx, db, []int{id})
+		if err != nil {
+			return err
+		}
+		if err := entity.CheckPurchaseOrderTransition(po.Status, to, lines); err != nil {
+			return err
+		}
+		q := `UPDATE purchase_order SET status = :status WHERE id = :id`
+		if to == entity.PurchaseOrderOrdered {
+			q = `UPDATE purchase_order SET status = :status, ordered_at = :today WHERE id = :id`
+		}
+		if err := storeutil.ExecNamed(ctx, db, q, map[string]any{
+			"id": id, "status": to, "today": s.Now().Format("2006-01-02"),
+		}); err != nil {
+			return fmt.Errorf("can't update purchase order status: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReceivePurchaseOrder books one delivery against an order. Every line is an ordinary purchase
+// receipt (inventory.ReceiveInTx) priced at the PO price in the PO currency and tagged with the PO's
+// supplier, so the accounting worker posts the received value as the payable. The delivery's input
+// VAT is shared across the lines pro rata to net value. The order row is locked first, the received
+// quantities and the derived status move in the same transaction, and the movements are returned in
+// receipt order.
+func (s *Store) ReceivePurchaseOrder(ctx context.Context, r entity.PurchaseOrderReceipt) ([]entity.MaterialMovement, error) {
+	var out []entity.MaterialMovement
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		po, err := lockOrder(ctx, db, r.PurchaseOrderId)
+		if err != nil {
+			return err
+		}
+		if !po.Status.IsReceivable() {
+			return fmt.Errorf("%w: order is %s", entity.ErrPurchaseOrderState, po.Status)
+		}
+		lines, err := orderLines(ctx, db, []int{po.Id})
+		if err != nil {
+			return err
+		}
+		byID := make(map[int]int, len(lines))
+		for i, l := range lines {
+			byID[l.Id] = i
+		}
+		seen := map[int]bool{}
+		nets := make([]decimal.Decimal, len(r.Lines))
+		for i, rl := range r.Lines {
+			li, ok := byID[rl.LineId]
+			if !ok {
+				return fmt.Errorf("%w: line %d is not on %s", entity.ErrPurchaseOrderNotFound, rl.LineId,
+					entity.PurchaseOrderNumber(po.Id))
+			}
+			if seen[rl.LineId] {
+				return fmt.Errorf("line %d appears twice in the receipt", rl.LineId)
+			}
+			seen[rl.LineId] = true
+			l := lines[li]
+			if rl.Quantity.GreaterThan(l.MaxReceivableQty()) {
+				return fmt.Errorf("%w: %s of %q, at most %s more can be received", entity.ErrPurchaseOrderOverReceipt,
+					rl.Quantity, l.MaterialName, l.MaxReceivableQty())
+			}
+			nets[i] = rl.Quantity.Mul(l.UnitPrice.Decimal)
+		}
+		var vat []decimal.Decimal
+		if r.InputVatAmount.Valid {
+			vat = entity.SplitInputVat(r.InputVatAmount.Decimal, nets)
+		}
+
+		comment := entity.PurchaseOrderNumber(po.Id)
+		if r.Comment.Valid && strings.TrimSpace(r.Comment.String) != "" {
+			comment += ": " + strings.TrimSpace(r.Comment.String)
+		}
+		for i, rl := range r.Lines {
+			l := &lines[byID[rl.LineId]]
+			expected := l.ExpectedAt
+			if !expected.Valid {
+				expected = po.ExpectedAt
+			}
+			ins := entity.MaterialReceiptInsert{
+				MaterialId:          l.MaterialId,
+				Quantity:            rl.Quantity,
+				UnitCost:            l.UnitPrice,
+				Currency:            po.Currency,
+				Lot:                 rl.Lot,
+				MeasuredWidthCm:     rl.MeasuredWidthCm,
+				ShadeCode:           rl.ShadeCode,
+				SupplierDoc:         r.SupplierDoc,
+				SupplierId:          sql.NullInt32{Int32: int32(po.SupplierId), Valid: true},
+				ExpectedAt:          expected,
+				PurchaseOrderLineId: sql.NullInt32{Int32: int32(l.Id), Valid: true},
+				OccurredAt:          r.OccurredAt,
+				Comment:             sql.NullString{String: comment, Valid: true},
+				AdminUsername:       r.AdminUsername,
+				InputVatRegime:      r.InputVatRegime,
+			}
+			if vat != nil {
+				ins.InputVatAmount = decimal.NullDecimal{Decimal: vat[i], Valid: true}
+			}
+			m, err := inventory.ReceiveInTx(ctx, rep, ins, s.Now())
+			if err != nil {
+				return err
+			}
+			out = append(out, m)
+			l.ReceivedQty = l.ReceivedQty.Add(rl.Quantity)
+			if err := storeutil.ExecNamed(ctx, db,
+				`UPDATE purchase_order_line SET received_qty = :qty WHERE id = :id`,
+				map[string]any{"id": l.Id, "qty": l.ReceivedQty}); err != nil {
+				return fmt.Errorf("can't update purchase order line: %w", err)
+			}
+		}
+		if status := entity.ReceivedStatus(lines); status != po.Status {
+			if err := storeutil.ExecNamed(ctx, db, `UPDATE purchase_order SET status = :status WHERE id = :id`,
+				map[string]any{"id": po.Id, "status": status}); err != nil {
+				return fmt.Errorf("can't update purchase order status: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OpenQuantities returns, per material, the quantity still to arrive on open orders (drafts
+// included: a draft is an intention to buy, and suggesting the same metres twice is the mistake
+// this exists to prevent). Materials with nothing open are absent.
+func (s *Store) OpenQuantities(ctx context.Context, materialIDs []int) (map[int]decimal.Decimal, error) {
+	out := map[int]decimal.Decimal{}
+	if len(materialIDs) == 0 {
+		return out, nil
+	}
+	rows, err := storeutil.QueryListNamed[struct {
+		MaterialId int             `db:"material_id"`
+		Qty        decimal.Decimal `db:"qty"`
+	}](ctx, s.DB, `
+		SELECT l.material_id, SUM(GREATEST(l.ordered_qty - l.received_qty, 0)) AS qty
+		FROM purchase_order_line l
+		JOIN purchase_order po ON po.id = l.purchase_order_id
+		WHERE l.material_id IN (:ids) AND po.status IN (:open)
+		GROUP BY l.material_id`,
+		map[string]any{
+			"ids": materialIDs,
+			"open": []entity.PurchaseOrderStatus{entity.PurchaseOrderDraft, entity.PurchaseOrderOrdered,
+				entity.PurchaseOrderPartiallyReceived},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't get open purchase quantities: %w", err)
+	}
+	for _, r := range rows {
+		if r.Qty.IsPositive() {
+			out[r.MaterialId] = r.Qty
+		}
+	}
+	return out, nil
+}
+
+// SupplierLeadTimes measures each supplier's actual order-to-door time over orders received in full:
+// ordered_at to the day of the order's last receipt. Suppliers without such an order are absent.
+func (s *Store) SupplierLeadTimes(ctx context.Context) ([]entity.SupplierLeadTime, error) {
+	rows, err := storeutil.QueryListNamed[entity.SupplierLeadTime](ctx, s.DB, `
+		SELECT t.supplier_id,
+		       COUNT(*) AS received_orders,
+		       AVG(t.days) AS avg_lead_time_days,
+		       MAX(t.days) AS max_lead_time_days,
+		       SUM(t.expected_at IS NOT NULL AND t.last_receipt > t.expected_at) AS late_orders
+		FROM (
+			SELECT po.id, po.supplier_id, po.expected_at,
+			       DATE(MAX(m.occurred_at)) AS last_receipt,
+			       DATEDIFF(MAX(m.occurred_at), po.ordered_at) AS days
+			FROM purchase_order po
+			JOIN purchase_order_line l ON l.purchase_order_id = po.id
+			JOIN material_stock_movement m ON m.purchase_order_line_id = l.id
+			WHERE po.status = 'received' AND po.ordered_at IS NOT NULL
+			GROUP BY po.id, po.supplier_id, po.expected_at, po.ordered_at
+		) t
+		GROUP BY t.supplier_id`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't get supplier lead times: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReturnRequestLifecycle walks the RMA store (0333) on one paid order of two units of size M,
+// with size L of the same colourway in stock for exchanges:
+//
+//	(1) returnable units — a second request cannot claim a unit another open return holds;
+//	(2) exchange — the request takes the replacement out of L (order_exchange) and approval leaves
+//	    it there; a failed inspection puts it back and frees the claimed unit;
+//	(3) refund — requested → approved → received → refunding → (RefundOrder) → refunded restocks M
+//	    and leaves no returnable units; the refund claim is re-entrant only with the same disposition.
+func TestReturnRequestLifecycle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	exec := func(q string, args ...any) int64 {
+		res, err := testDB.ExecContext(ctx, q, args...)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return id
+	}
+	count := func(q string, args ...any) int {
+		var n int
+		require.NoError(t, testDB.QueryRowContext(ctx, q, args...).Scan(&n))
+		return n
+	}
+
+	token := fmt.Sprintf("%d%04d", time.Now().UnixNano(), rand.Intn(10000))
+
+	carrierID := exec(`INSERT INTO shipment_carrier (carrier, tracking_url, allowed, description)
+		VALUES (CONCAT('RMA-', ?), 'http://x/%s', 1, 'rma')`, token)
+	exec(`INSERT INTO shipment_carrier_price (shipment_carrier_id, currency, price) VALUES (?, 'EUR', 5.00)`, carrierID)
+
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	di, err := s.Cache().GetDictionaryInfo(ctx)
+	require.NoError(t, err)
+	hf, err := s.Hero().GetHero(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cache.InitConsts(ctx, di, hf))
+	cache.UpdatePaymentMethodAllowance(entity.CARD, true)
+
+	mediaID, err := s.Media().AddMedia(ctx, &entity.MediaItem{
+		FullSizeMediaURL: "https://x/f.jpg", FullSizeWidth: 1, FullSizeHeight: 1,
+		ThumbnailMediaURL: "https://x/t.jpg", ThumbnailWidth: 1, ThumbnailHeight: 1,
+		CompressedMediaURL: "https://x/c.jpg", CompressedWidth: 1, CompressedHeight: 1,
+	})
+	require.NoError(t, err)
+
+	sizeM := exec(`INSERT INTO size (name, sku_ord, sku_system) VALUES (CONCAT('RM-', LEFT(MD5(RAND()),6)), 44, 'apparel')`)
+	sizeL := exec(`INSERT INTO size (name, sku_ord, sku_system) VALUES (CONCAT('RL-', LEFT(MD5(RAND()),6)), 45, 'apparel')`)
+	styleID := exec(`INSERT INTO tech_card (style_number, name, brand, collection, season_code, season_year, season, target_gender, top_category_id)
+		VALUES (CONCAT('RMA-', UUID_SHORT()), 'RMA', 'ACME', '', 'SS', 2026, 'SS26', 'unisex', 1)`)
+	baseSKU := "RMA-" + token
+	pid := exec(`INSERT INTO product (sku, color, color_code, color_hex, country_of_origin, thumbnail_id, style_id, lifecycle_status, cost_price)
+		VALUES (?, 'c', 'BLK', '#000000', 'US', ?, ?, 2, 30.00)`, baseSKU, mediaID, styleID)
+	exec(`INSERT INTO product_price (product_id, currency, price) VALUES (?, 'EUR', 100.00)`, pid)
+	mSKU := "VM" + baseSKU
+	mVarID := exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 10, ?)`, pid, sizeM, mSKU)
+	lVarID := exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, pid, sizeL, "VL"+baseSKU)
+
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_size WHERE product_id = ?", pid)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_price WHERE product_id = ?", pid)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product WHERE id = ?", pid)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM tech_card WHERE id = ?", styleID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM size WHERE id IN (?, ?)", sizeM, sizeL)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM shipment_carrier_price WHERE shipment_carrier_id = ?", carrierID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM shipment_carrier WHERE id = ?", carrierID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM media WHERE id = ?", mediaID)
+	})
+
+	o, _, err := s.Order().CreateOrder(ctx, &entity.OrderNew{
+		Items:             []entity.OrderItemInsert{{VariantSKU: mSKU, Quantity: decimal.NewFromInt(2)}},
+		ShippingAddress:   &entity.AddressInsert{Country: "US", City: "NYC", AddressLineOne: "1 St", PostalCode: "10001"},
+		BillingAddress:    &entity.AddressInsert{Country: "US", City: "NYC", AddressLineOne: "1 St", PostalCode: "10001"},
+		Buyer:             &entity.BuyerInsert{FirstName: "T", LastName: "T", Email: fmt.Sprintf("rma-%s@example.com", token), Phone: "1234567890"},
+		PaymentMethod:     entity.CARD,
+		ShipmentCarrierId: int(carrierID),
+		Currency:          "EUR",
+	}, false, time.Now().UTC().Add(time.Hour))
+	require.NoError(t, err)
+	pme, ok := cache.GetPaymentMethodByName(entity.CARD)
+	require.True(t, ok, "card payment method must be cached")
+	_, err = s.Order().InsertFiatInvoice(ctx, o.UUID, "rma-secret-"+token, pme.Method, time.Now().UTC().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = s.Order().OrderPaymentDone(ctx, o.UUID, &entity.Payment{
+		PaymentInsert: entity.PaymentInsert{
+			PaymentMethodID:                  pme.Method.Id,
+			TransactionAmount:                decimal.NewFromInt(205),
+			TransactionAmountPaymentCurrency: decimal.NewFromInt(205),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 8, count(`SELECT quantity FROM product_size WHERE id = ?`, mVarID))
+
+	var lineID int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM order_item WHERE order_id = ?`, o.Id).Scan(&lineID))
+
+	// --- (1)+(2) exchange one unit M → L ---
+	ex, err := s.Returns().CreateReturnRequest(ctx, o.Id, &entity.ReturnRequestInsert{
+		Resolution: entity.ReturnResolutionExchange,
+		ReasonCode: "wrong_size",
+		Items:      []entity.ReturnRequestItemInsert{{OrderItemId: lineID, Quantity: 1, ExchangeProductId: int(pid), ExchangeSizeId: int(sizeL)}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, entity.ReturnStatusRequested, ex.Status)
+	require.Regexp(t, `^RMA-\d{4}-\d{6}$`, ex.RMANumber)
+	require.Equal(t, o.UUID, ex.OrderUUID)
+	require.True(t, ex.StockAllocated)
+	require.Equal(t, 4, count(`SELECT quantity FROM product_size WHERE id = ?`, lVarID), "the request allocates the replacement")
+
+	_, err = s.Returns().CreateReturnRequest(ctx, o.Id, &entity.ReturnRequestInsert{
+		Resolution: entity.ReturnResolutionRefund,
+		ReasonCode: "changed_mind",
+		Items:      []entity.ReturnRequestItemInsert{{OrderItemId: lineID, Quantity: 2}},
+	})
+	var ve *entity.ValidationError
+	require.True(t, errors.As(err, &ve), "a unit held by an open return must not be claimed twice, got %v", err)
+
+	_, err = s.Returns().ReceiveReturnRequest(ctx, ex.Id, "")
+	require.ErrorIs(t, err, entity.ErrReturnRequestTransition, "a requested return cannot be received")
+
+	ex, err = s.Returns().ApproveReturnRequest(ctx, ex.Id, "admin", "ok")
+	require.NoError(t, err)
+	require.True(t, ex.StockAllocated)
+	require.Equal(t, 4, count(`SELECT quantity FROM product_size WHERE id = ?`, lVarID), "approval does not allocate twice")
+	require.Equal(t, 1, count(`SELECT COUNT(*) FROM product_stock_change_history WHERE order_id = ? AND source = ?`, o.Id, entity.StockChangeSourceOrderExchange))
+
+	ex, err = s.Returns().RejectReturnRequest(ctx, ex.Id, "admin", "worn")
+	require.NoError(t, err)
+	require.Equal(t, entity.ReturnStatusRejected, ex.Status)
+	require.False(t, ex.StockAllocated)
+	require.Equal(t, 5, count(`SELECT quantity FROM product_size WHERE id = ?`, lVarID), "rejection releases the replacement")
+
+	// --- (3) refund both units ---
+	rf, err := s.Returns().CreateReturnRequest(ctx, o.Id, &entity.ReturnRequestInsert{
+		Resolution: entity.ReturnResolutionRefund,
+		ReasonCode: "changed_mind",
+		Items:      []entity.ReturnRequestItemInsert{{OrderItemId: lineID, Quantity: 2}},
+	})
+	require.NoError(t, err, "a rejected return frees its units")
+	_, err = s.Returns().ApproveReturnRequest(ctx, rf.Id, "admin", "")
+	require.NoError(t, err)
+	_, err = s.Returns().ReceiveReturnRequest(ctx, rf.Id, "")
+	require.NoError(t, err)
+	_, err = s.Returns().CompleteReturnExchange(ctx, rf.Id, "", "")
+	require.ErrorIs(t, err, entity.ErrReturnRequestTransition, "a refund return is not exchanged")
+
+	rf, err = s.Returns().ClaimReturnRefund(ctx, rf.Id, entity.RefundDispositionRestock, "")
+	require.NoError(t, err)
+	require.Equal(t, entity.ReturnStatusRefunding, rf.Status)
+	_, err = s.Returns().ClaimReturnRefund(ctx, rf.Id, entity.RefundDispositionRestock, "")
+	require.NoError(t, err, "the claim is re-entrant for a retry")
+	_, err = s.Returns().ClaimReturnRefund(ctx, rf.Id, entity.RefundDispositionWriteoff, "")
+	require.ErrorIs(t, err, entity.ErrReturnRequestTransition, "a retry must keep the disposition")
+
+	require.NoError(t, s.Order().RefundOrder(ctx, o.UUID, []int32{int32(lineID), int32(lineID)}, "return "+rf.RMANumber, rf.ReasonCode, false, entity.RefundDispositionRestock, false))
+	rf, err = s.Returns().CompleteReturnRefund(ctx, rf.Id)
+	require.NoError(t, err)
+	require.Equal(t, entity.ReturnStatusRefunded, rf.Status)
+	require.True(t, rf.ClosedAt.Valid)
+	require.Equal(t, 10, count(`SELECT quantity FROM product_size WHERE id = ?`, mVarID), "the refund restocks M")
+
+	_, err = s.Returns().CreateReturnRequest(ctx, o.Id, &entity.ReturnRequestInsert{
+		Resolution: entity.ReturnResolutionRefund,
+		ReasonCode: "other",
+		Items:      []entity.ReturnRequestItemInsert{{OrderItemId: lineID, Quantity: 1}},
+	})
+	require.True(t, errors.As(err, &ve), "refunded units are no longer returnable, got %v", err)
+
+	list, err := s.Returns().GetReturnRequestsByOrderId(ctx, o.Id)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, rf.Id, list[0].Id, "newest first")
+
+	status := entity.ReturnStatusRefunded
+	paged, total, err := s.Returns().GetReturnRequestsPaged(ctx, 10, 0, entity.Descending, entity.ReturnRequestFilters{Status: &status, OrderUUID: o.UUID})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Len(t, paged, 1)
+	require.Equal(t, rf.UUID, paged[0].UUID)
+}
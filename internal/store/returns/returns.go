@@ -0,0 +1,557 @@
+// Package returns implements customer returns and exchanges (RMA, return_request 0333). The package
+// owns the request, its lines and the exchange replacement stock; the refund itself is the order
+// store's RefundOrder, which the admin handler calls between ClaimReturnRefund and
+// CompleteReturnRefund.
+package returns
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.Returns.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new returns store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const selectReturnRequest = `
+	SELECT rr.id, rr.uuid, COALESCE(rr.rma_number, '') AS rma_number, rr.order_id,
+	       co.uuid AS order_uuid, COALESCE(b.email, '') AS buyer_email,
+	       rr.status, rr.resolution, rr.reason_code, rr.customer_note, rr.admin_note,
+	       rr.disposition, rr.stock_allocated, rr.decided_by,
+	       rr.approved_at, rr.received_at, rr.closed_at, rr.created_at, rr.updated_at
+	FROM return_request rr
+	JOIN customer_order co ON co.id = rr.order_id
+	LEFT JOIN buyer b ON b.order_id = rr.order_id`
+
+// orderLine is the slice of order_item a return is checked against.
+type orderLine struct {
+	Id        int             `db:"id"`
+	ProductId int             `db:"product_id"`
+	SizeId    int             `db:"size_id"`
+	Quantity  decimal.Decimal `db:"quantity"`
+}
+
+// CreateReturnRequest opens a return for an order. Under the order's row lock it checks every line
+// against what is still returnable: bought, minus already refunded, minus units claimed by another
+// open return. An exchange must keep the colourway and change the size; its replacement units leave
+// sellable stock in the same transaction (order_exchange), so they stay put however long the
+// decision takes. A size that is gone fails with entity.ErrReturnExchangeUnavailable.
+func (s *Store) CreateReturnRequest(ctx context.Context, orderId int, in *entity.ReturnRequestInsert) (*entity.ReturnRequestFull, error) {
+	if err := entity.ValidateReturnRequestInsert(in); err != nil {
+		return nil, err
+	}
+	var out *entity.ReturnRequestFull
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		if _, err := storeutil.QueryScalarListNamed[int](ctx, db,
+			`SELECT id FROM customer_order WHERE id = :orderId FOR UPDATE`,
+			map[string]any{"orderId": orderId}); err != nil {
+			return fmt.Errorf("can't lock order: %w", err)
+		}
+
+		lines, err := storeutil.QueryListNamed[orderLine](ctx, db, `
+			SELECT id, product_id, size_id, quantity FROM order_item WHERE order_id = :orderId`,
+			map[string]any{"orderId": orderId})
+		if err != nil {
+			return fmt.Errorf("can't get order items: %w", err)
+		}
+		byId := make(map[int]orderLine, len(lines))
+		for _, l := range lines {
+			byId[l.Id] = l
+		}
+		claimed, err := claimedQuantities(ctx, db, orderId)
+		if err != nil {
+			return err
+		}
+
+		for _, it := range in.Items {
+			l, ok := byId[it.OrderItemId]
+			if !ok {
+				return &entity.ValidationError{Message: fmt.Sprintf("order item %d does not belong to this order", it.OrderItemId), Field: "items"}
+			}
+			left := int(l.Quantity.IntPart()) - claimed[l.Id]
+			if it.Quantity > left {
+				return &entity.ValidationError{Message: fmt.Sprintf("order item %d has %d returnable units, asked %d", it.OrderItemId, max(left, 0), it.Quantity), Field: "items"}
+			}
+			if in.Resolution == entity.ReturnResolutionExchange {
+				if it.ExchangeProductId != l.ProductId {
+					return &entity.ValidationError{Message: "an exchange must keep the same colourway", Field: "items"}
+				}
+				if it.ExchangeSizeId == l.SizeId {
+					return &entity.ValidationError{Message: "an exchange must change the size", Field: "items"}
+				}
+			}
+		}
+
+		id, err := storeutil.ExecNamedLastId(ctx, db, `
+			INSERT INTO return_request (uuid, order_id, status, resolution, reason_code, customer_note, stock_allocated, created_at, updated_at)
+			VALUES (:uuid, :orderId, :status, :resolution, :reasonCode, :customerNote, :allocated, :now, :now)`,
+			map[string]any{
+				"uuid":         uuid.NewString(),
+				"orderId":      orderId,
+				"status":       entity.ReturnStatusRequested,
+				"resolution":   in.Resolution,
+				"reasonCode":   in.ReasonCode,
+				"customerNote": sql.NullString{String: in.CustomerNote, Valid: in.CustomerNote != ""},
+				"allocated":    in.Resolution == entity.ReturnResolutionExchange,
+				"now":          rep.Now(),
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert return request: %w", err)
+		}
+		// Derived from the id inside the same tx, so two concurrent requests never race for a number.
+		if err := storeutil.ExecNamed(ctx, db, `
+			UPDATE return_request
+			SET rma_number = CONCAT('RMA-', YEAR(created_at), '-', LPAD(id, 6, '0'))
+			WHERE id = :id`, map[string]any{"id": id}); err != nil {
+			return fmt.Errorf("can't set rma number: %w", err)
+		}
+
+		rows := make([]map[string]any, 0, len(in.Items))
+		for _, it := range in.Items {
+			rows = append(rows, map[string]any{
+				"return_request_id":   id,
+				"order_item_id":       it.OrderItemId,
+				"quantity":            it.Quantity,
+				"exchange_product_id": sql.NullInt32{Int32: int32(it.ExchangeProductId), Valid: it.ExchangeSizeId != 0},
+				"exchange_size_id":    sql.NullInt32{Int32: int32(it.ExchangeSizeId), Valid: it.ExchangeSizeId != 0},
+			})
+		}
+		if err := storeutil.BulkInsert(ctx, db, "return_request_item", rows); err != nil {
+			return fmt.Errorf("can't insert return request items: %w", err)
+		}
+
+		out, err = getReturnRequestFull(ctx, db, "rr.id = :id", map[string]any{"id": id})
+		if err != nil {
+			return err
+		}
+		if in.Resolution == entity.ReturnResolutionExchange {
+			if err := allocateExchangeStock(ctx, rep, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// claimedQuantities returns, per order line, the units already refunded plus the units held by open
+// returns. A refunded return is left out of the second sum: its units are already in
+// refunded_order_item. An exchanged return keeps its lines — the replacement is not an order line.
+func claimedQuantities(ctx context.Context, db dependency.DB, orderId int) (map[int]int, error) {
+	type row struct {
+		OrderItemId int `db:"order_item_id"`
+		Quantity    int `db:"quantity"`
+	}
+	refunded, err := storeutil.QueryListNamed[row](ctx, db, `
+		SELECT order_item_id, CAST(SUM(quantity_refunded) AS SIGNED) AS quantity
+		FROM refunded_order_item WHERE order_id = :orderId
+		GROUP BY order_item_id`, map[string]any{"orderId": orderId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get refunded quantities: %w", err)
+	}
+	open, err := storeutil.QueryListNamed[row](ctx, db, `
+		SELECT rri.order_item_id, CAST(SUM(rri.quantity) AS SIGNED) AS quantity
+		FROM return_request_item rri
+		JOIN return_request rr ON rr.id = rri.return_request_id
+		WHERE rr.order_id = :orderId AND rr.status NOT IN (:closed)
+		GROUP BY rri.order_item_id`, map[string]any{
+		"orderId": orderId,
+		"closed":  []string{string(entity.ReturnStatusRejected), string(entity.ReturnStatusCancelled), string(entity.ReturnStatusRefunded)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't get open return quantities: %w", err)
+	}
+	out := make(map[int]int, len(refunded)+len(open))
+	for _, r := range refunded {
+		out[r.OrderItemId] += r.Quantity
+	}
+	for _, r := range open {
+		out[r.OrderItemId] += r.Quantity
+	}
+	return out, nil
+}
+
+// GetReturnRequestByUUID returns a return with its lines. sql.ErrNoRows when absent.
+func (s *Store) GetReturnRequestByUUID(ctx context.Context, returnUUID string) (*entity.ReturnRequestFull, error) {
+	return getReturnRequestFull(ctx, s.DB, "rr.uuid = :uuid", map[string]any{"uuid": returnUUID})
+}
+
+// GetReturnRequestById returns a return with its lines. sql.ErrNoRows when absent.
+func (s *Store) GetReturnRequestById(ctx context.Context, id int) (*entity.ReturnRequestFull, error) {
+	return getReturnRequestFull(ctx, s.DB, "rr.id = :id", map[string]any{"id": id})
+}
+
+// GetReturnRequestsByOrderId returns every return of an order, newest first, with their lines.
+func (s *Store) GetReturnRequestsByOrderId(ctx context.Context, orderId int) ([]entity.ReturnRequestFull, error) {
+	rrs, err := storeutil.QueryListNamed[entity.ReturnRequest](ctx, s.DB,
+		selectReturnRequest+` WHERE rr.order_id = :orderId ORDER BY rr.created_at DESC, rr.id DESC`,
+		map[string]any{"orderId": orderId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get order return requests: %w", err)
+	}
+	out := make([]entity.ReturnRequestFull, 0, len(rrs))
+	for _, rr := range rrs {
+		items, err := getReturnRequestItems(ctx, s.DB, rr.Id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entity.ReturnRequestFull{ReturnRequest: rr, Items: items})
+	}
+	return out, nil
+}
+
+// GetReturnRequestsPaged returns the admin return queue with optional filters.
+func (s *Store) GetReturnRequestsPaged(ctx context.Context, limit, offset int, orderFactor entity.OrderFactor, filters entity.ReturnRequestFilters) ([]entity.ReturnRequest, int, error) {
+	whereConditions := []string{}
+	args := map[string]any{
+		"limit":  limit,
+		"offset": offset,
+	}
+	if filters.Status != nil {
+		whereConditions = append(whereConditions, "rr.status = :status")
+		args["status"] = *filters.Status
+	}
+	if filters.Resolution != nil {
+		whereConditions = append(whereConditions, "rr.resolution = :resolution")
+		args["resolution"] = *filters.Resolution
+	}
+	if filters.OrderUUID != "" {
+		whereConditions = append(whereConditions, "co.uuid = :orderUuid")
+		args["orderUuid"] = filters.OrderUUID
+	}
+	if filters.Email != "" {
+		whereConditions = append(whereConditions, "b.email LIKE :email")
+		args["email"] = "%" + filters.Email + "%"
+	}
+
+	whereClause := ""
+	if len(whereConditions) > 0 {
+		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
+	}
+	orderByClause := "rr.created_at DESC, rr.id DESC"
+	if orderFactor == entity.Ascending {
+		orderByClause = "rr.created_at ASC, rr.id ASC"
+	}
+
+	rrs, err := storeutil.QueryListNamed[entity.ReturnRequest](ctx, s.DB, fmt.Sprintf(`%s
+		%s
+		ORDER BY %s
+		LIMIT :limit OFFSET :offset`, selectReturnRequest, whereClause, orderByClause), args)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't get return requests: %w", err)
+	}
+	total, err := storeutil.QueryCountNamed(ctx, s.DB, fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM return_request rr
+		JOIN customer_order co ON co.id = rr.order_id
+		LEFT JOIN buyer b ON b.order_id = rr.order_id
+		%s`, whereClause), args)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't count return requests: %w", err)
+	}
+	return rrs, total, nil
+}
+
+// ApproveReturnRequest accepts a requested return. An exchange's replacement units were taken out of
+// stock when it was requested; one requested before that (stock_allocated unset) is allocated now,
+// failing with entity.ErrReturnExchangeUnavailable — the return stays requested — if the size is gone.
+func (s *Store) ApproveReturnRequest(ctx context.Context, id int, decidedBy, note string) (*entity.ReturnRequestFull, error) {
+	return s.transition(ctx, id, entity.ReturnStatusApproved, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		if rr.Resolution == entity.ReturnResolutionExchange && !rr.StockAllocated {
+			if err := allocateExchangeStock(ctx, rep, rr); err != nil {
+				return err
+			}
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request
+			SET status = :status, stock_allocated = :allocated, decided_by = :decidedBy,
+			    admin_note = COALESCE(:note, admin_note), approved_at = :now
+			WHERE id = :id`, map[string]any{
+			"status":    entity.ReturnStatusApproved,
+			"allocated": rr.Resolution == entity.ReturnResolutionExchange,
+			"decidedBy": decidedBy,
+			"note":      nullIfEmpty(note),
+			"now":       rep.Now(),
+			"id":        rr.Id,
+		})
+	})
+}
+
+// RejectReturnRequest declines a return (before or after the parcel arrived, e.g. a failed
+// inspection). Replacement units already taken for an exchange go back to stock.
+func (s *Store) RejectReturnRequest(ctx context.Context, id int, decidedBy, note string) (*entity.ReturnRequestFull, error) {
+	return s.transition(ctx, id, entity.ReturnStatusRejected, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		if err := releaseExchangeStock(ctx, rep, rr); err != nil {
+			return err
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request
+			SET status = :status, stock_allocated = FALSE, decided_by = :decidedBy,
+			    admin_note = COALESCE(:note, admin_note), closed_at = :now
+			WHERE id = :id`, map[string]any{
+			"status":    entity.ReturnStatusRejected,
+			"decidedBy": decidedBy,
+			"note":      nullIfEmpty(note),
+			"now":       rep.Now(),
+			"id":        rr.Id,
+		})
+	})
+}
+
+// CancelReturnRequest withdraws a return on the customer's behalf; only before the parcel arrived.
+func (s *Store) CancelReturnRequest(ctx context.Context, returnUUID string) (*entity.ReturnRequestFull, error) {
+	rr, err := s.GetReturnRequestByUUID(ctx, returnUUID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, rr.Id, entity.ReturnStatusCancelled, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		if err := releaseExchangeStock(ctx, rep, rr); err != nil {
+			return err
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request SET status = :status, stock_allocated = FALSE, closed_at = :now
+			WHERE id = :id`, map[string]any{
+			"status": entity.ReturnStatusCancelled,
+			"now":    rep.Now(),
+			"id":     rr.Id,
+		})
+	})
+}
+
+// ReceiveReturnRequest records that the parcel of an approved return arrived at the warehouse.
+func (s *Store) ReceiveReturnRequest(ctx context.Context, id int, note string) (*entity.ReturnRequestFull, error) {
+	return s.transition(ctx, id, entity.ReturnStatusReceived, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request
+			SET status = :status, admin_note = COALESCE(:note, admin_note), received_at = :now
+			WHERE id = :id`, map[string]any{
+			"status": entity.ReturnStatusReceived,
+			"note":   nullIfEmpty(note),
+			"now":    rep.Now(),
+			"id":     rr.Id,
+		})
+	})
+}
+
+// ClaimReturnRefund moves an inspected refund return to refunding and records the disposition. The
+// caller then refunds the payment and calls RefundOrder; a claim already held (a retry after a failed
+// hand-off) is re-entered with the same disposition.
+func (s *Store) ClaimReturnRefund(ctx context.Context, id int, disposition, note string) (*entity.ReturnRequestFull, error) {
+	if !entity.ValidRefundDispositions[disposition] {
+		return nil, &entity.ValidationError{Message: fmt.Sprintf("unknown refund disposition %q", disposition), Field: "disposition"}
+	}
+	return s.transition(ctx, id, entity.ReturnStatusRefunding, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		if rr.Resolution != entity.ReturnResolutionRefund {
+			return fmt.Errorf("%w: an exchange return is not refunded", entity.ErrReturnRequestTransition)
+		}
+		if rr.Status == entity.ReturnStatusRefunding && rr.Disposition != disposition {
+			return fmt.Errorf("%w: refund already claimed with disposition %q", entity.ErrReturnRequestTransition, rr.Disposition)
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request
+			SET status = :status, disposition = :disposition, admin_note = COALESCE(:note, admin_note)
+			WHERE id = :id`, map[string]any{
+			"status":      entity.ReturnStatusRefunding,
+			"disposition": disposition,
+			"note":        nullIfEmpty(note),
+			"id":          rr.Id,
+		})
+	})
+}
+
+// CompleteReturnRefund closes a claimed refund once RefundOrder has committed.
+func (s *Store) CompleteReturnRefund(ctx context.Context, id int) (*entity.ReturnRequestFull, error) {
+	return s.transition(ctx, id, entity.ReturnStatusRefunded, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request SET status = :status, closed_at = :now WHERE id = :id`, map[string]any{
+			"status": entity.ReturnStatusRefunded,
+			"now":    rep.Now(),
+			"id":     rr.Id,
+		})
+	})
+}
+
+// CompleteReturnExchange closes an inspected exchange: the returned units go back per disposition
+// (restock / seconds / writeoff, journalled as order_returned like a refund) and the replacement,
+// allocated when the return was requested, stays out of stock for shipping. No money moves, so
+// nothing is posted.
+func (s *Store) CompleteReturnExchange(ctx context.Context, id int, disposition, note string) (*entity.ReturnRequestFull, error) {
+	if !entity.ValidRefundDispositions[disposition] {
+		return nil, &entity.ValidationError{Message: fmt.Sprintf("unknown refund disposition %q", disposition), Field: "disposition"}
+	}
+	return s.transition(ctx, id, entity.ReturnStatusExchanged, func(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+		if rr.Resolution != entity.ReturnResolutionExchange {
+			return fmt.Errorf("%w: a refund return is not exchanged", entity.ErrReturnRequestTransition)
+		}
+		returned := make([]entity.OrderItemInsert, 0, len(rr.Items))
+		for _, it := range rr.Items {
+			returned = append(returned, entity.OrderItemInsert{
+				ProductId: it.ProductId,
+				SizeId:    it.SizeId,
+				Grade:     it.Grade,
+				Quantity:  decimal.NewFromInt(int64(it.Quantity)),
+			})
+		}
+		history := &entity.StockHistoryParams{
+			Source:    entity.StockChangeSourceOrderReturned,
+			OrderId:   rr.OrderId,
+			OrderUUID: rr.OrderUUID,
+		}
+		switch disposition {
+		case entity.RefundDispositionWriteoff:
+			// Worn/damaged: nothing restocks, the same as a written-off refund.
+		case entity.RefundDispositionSeconds:
+			if err := rep.Products().RestoreStockForProductSizesSeconds(ctx, returned, history); err != nil {
+				return fmt.Errorf("restore seconds stock: %w", err)
+			}
+		default:
+			if err := rep.Products().RestoreStockForProductSizes(ctx, returned, history); err != nil {
+				return fmt.Errorf("restore stock: %w", err)
+			}
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE return_request
+			SET status = :status, disposition = :disposition, admin_note = COALESCE(:note, admin_note), closed_at = :now
+			WHERE id = :id`, map[string]any{
+			"status":      entity.ReturnStatusExchanged,
+			"disposition": disposition,
+			"note":        nullIfEmpty(note),
+			"now":         rep.Now(),
+			"id":          rr.Id,
+		})
+	})
+}
+
+// transition locks the return, checks that it may move to `to`, runs apply and returns the result.
+func (s *Store) transition(ctx context.Context, id int, to entity.ReturnRequestStatus, apply func(context.Context, dependency.Repository, *entity.ReturnRequestFull) error) (*entity.ReturnRequestFull, error) {
+	var out *entity.ReturnRequestFull
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		locked, err := storeutil.QueryScalarListNamed[int](ctx, rep.DB(),
+			`SELECT id FROM return_request WHERE id = :id FOR UPDATE`, map[string]any{"id": id})
+		if err != nil {
+			return fmt.Errorf("can't lock return request: %w", err)
+		}
+		if len(locked) == 0 {
+			return sql.ErrNoRows
+		}
+		rr, err := getReturnRequestFull(ctx, rep.DB(), "rr.id = :id", map[string]any{"id": id})
+		if err != nil {
+			return err
+		}
+		if !entity.CanTransitionReturnRequest(rr.Status, to) {
+			return fmt.Errorf("%w: %s → %s", entity.ErrReturnRequestTransition, rr.Status, to)
+		}
+		if err := apply(ctx, rep, rr); err != nil {
+			return err
+		}
+		out, err = getReturnRequestFull(ctx, rep.DB(), "rr.id = :id", map[string]any{"id": id})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// allocateExchangeStock takes the replacement units of an exchange out of stock (order_exchange,
+// negative); a size without enough units fails with entity.ErrReturnExchangeUnavailable.
+func allocateExchangeStock(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+	if err := rep.Products().ReduceStockForProductSizes(ctx, exchangeItems(rr), &entity.StockHistoryParams{
+		Source:    entity.StockChangeSourceOrderExchange,
+		OrderId:   rr.OrderId,
+		OrderUUID: rr.OrderUUID,
+	}); err != nil {
+		return fmt.Errorf("%w: %v", entity.ErrReturnExchangeUnavailable, err)
+	}
+	return nil
+}
+
+// releaseExchangeStock puts allocated replacement units back (order_exchange, positive).
+func releaseExchangeStock(ctx context.Context, rep dependency.Repository, rr *entity.ReturnRequestFull) error {
+	if !rr.StockAllocated {
+		return nil
+	}
+	if err := rep.Products().RestoreStockForProductSizes(ctx, exchangeItems(rr), &entity.StockHistoryParams{
+		Source:    entity.StockChangeSourceOrderExchange,
+		OrderId:   rr.OrderId,
+		OrderUUID: rr.OrderUUID,
+	}); err != nil {
+		return fmt.Errorf("release exchange stock: %w", err)
+	}
+	return nil
+}
+
+// exchangeItems lists the replacement units of an exchange as stock movements (always A grade).
+func exchangeItems(rr *entity.ReturnRequestFull) []entity.OrderItemInsert {
+	items := make([]entity.OrderItemInsert, 0, len(rr.Items))
+	for _, it := range rr.Items {
+		if !it.ExchangeSizeId.Valid {
+			continue
+		}
+		items = append(items, entity.OrderItemInsert{
+			ProductId: int(it.ExchangeProductId.Int32),
+			SizeId:    int(it.ExchangeSizeId.Int32),
+			Quantity:  decimal.NewFromInt(int64(it.Quantity)),
+		})
+	}
+	return items
+}
+
+func getReturnRequestFull(ctx context.Context, db dependency.DB, where string, args map[string]any) (*entity.ReturnRequestFull, error) {
+	rr, err := storeutil.QueryNamedOne[entity.ReturnRequest](ctx, db, selectReturnRequest+" WHERE "+where, args)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("can't get return request: %w", err)
+	}
+	items, err := getReturnRequestItems(ctx, db, rr.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.ReturnRequestFull{ReturnRequest: rr, Items: items}, nil
+}
+
+func getReturnRequestItems(ctx context.Context, db dependency.DB, returnRequestId int) ([]entity.ReturnRequestItem, error) {
+	items, err := storeutil.QueryListNamed[entity.ReturnRequestItem](ctx, db, `
+		SELECT rri.id, rri.return_request_id, rri.order_item_id, rri.quantity,
+		       oi.product_id, oi.size_id, oi.grade, COALESCE(oi.variant_sku_snapshot, '') AS variant_sku_snapshot,
+		       rri.exchange_product_id, rri.exchange_size_id, ps.sku AS exchange_variant_sku
+		FROM return_request_item rri
+		JOIN order_item oi ON oi.id = rri.order_item_id
+		LEFT JOIN product_size ps ON ps.product_id = rri.exchange_product_id
+		     AND ps.size_id = rri.exchange_size_id AND ps.grade = 'A'
+		WHERE rri.return_request_id = :id
+		ORDER BY rri.id`, map[string]any{"id": returnRequestId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get return request items: %w", err)
+	}
+	return items, nil
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,536 @@
+// Package salesinvoice issues sales invoices and correction invoices for orders (0337) and keeps their
+// gapless numbering. The number is taken from sales_invoice_counter in the same transaction as the
+// invoice insert, so it is only ever consumed by an invoice that commits. Invoices are never edited
+// or deleted; a refund is answered with a correction, and the only later write is the KSeF reference.
+package salesinvoice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/canonical"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.SalesInvoices.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new sales invoice store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const selectInvoice = `
+	SELECT si.id, si.number, si.kind, si.series, si.period, si.seq, si.order_id, co.uuid AS order_uuid,
+	       si.corrects_id, c.number AS corrects_number, c.issue_date AS corrects_issue_date,
+	       c.ksef_reference AS corrects_ksef_reference, si.issue_date, si.sale_date, si.currency,
+	       si.pln_rate, si.vat_regime, si.vat_rate_pct, si.buyer_name, si.buyer_vat_id, si.buyer_address,
+	       si.buyer_country, si.net, si.vat, si.gross, si.reason, si.ksef_reference, si.ksef_hash,
+	       si.ksef_submitted_at, si.created_by, si.created_at
+	FROM sales_invoice si
+	JOIN customer_order co ON co.id = si.order_id
+	LEFT JOIN sales_invoice c ON c.id = si.corrects_id`
+
+// invoiceableStatuses are the order statuses of a paid sale. An order that was never paid (placed,
+// awaiting payment, cancelled) has no supply to invoice.
+var invoiceableStatuses = map[entity.OrderStatusName]bool{
+	entity.Confirmed:         true,
+	entity.Shipped:           true,
+	entity.Delivered:         true,
+	entity.PendingReturn:     true,
+	entity.RefundInProgress:  true,
+	entity.PartiallyRefunded: true,
+	entity.Refunded:          true,
+}
+
+// IssueSalesInvoice issues the invoice for a paid order and returns its id. The lines come from the
+// order items and the shipping charged, the promo spread over the items; gift cards are not a supply
+// and stay off the invoice. The buyer is the billing address (company, else the buyer's name) and
+// the VAT regime the one the accounting worker resolved when it posted the sale — an order whose sale
+// is not posted yet can not be invoiced. issueDate may not precede the last invoice of its month.
+func (s *Store) IssueSalesInvoice(ctx context.Context, orderUUID string, issueDate time.Time, createdBy string) (int, error) {
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		orderID, err := lockCustomerOrder(ctx, db, orderUUID)
+		if err != nil {
+			return err
+		}
+		n, err := storeutil.QueryCountNamed(ctx, db, `
+			SELECT COUNT(*) FROM sales_invoice WHERE order_id = :orderId AND kind = 'invoice'`,
+			map[string]any{"orderId": orderID})
+		if err != nil {
+			return fmt.Errorf("can't check for an existing invoice: %w", err)
+		}
+		if n > 0 {
+			return entity.ErrSalesInvoiceExists
+		}
+
+		of, err := rep.Order().GetOrderFullByUUID(ctx, orderUUID)
+		if err != nil {
+			return fmt.Errorf("can't get order %s: %w", orderUUID, err)
+		}
+		st, ok := cache.GetOrderStatusById(of.Order.OrderStatusId)
+		if !ok || !invoiceableStatuses[st.Status.Name] {
+			return fmt.Errorf("%w: order %s is not paid (status %s)", entity.ErrSalesInvoiceState, orderUUID, st.Status.Name)
+		}
+		regime := entity.VatRegime(of.Order.VatRegime.String)
+		rate, err := entity.InvoiceVatRate(regime, of.Order.VatRatePct)
+		if err != nil {
+			return err
+		}
+
+		src, err := invoiceSource(ctx, db, of)
+		if err != nil {
+			return err
+		}
+		lines, err := entity.BuildSalesInvoiceLines(src, rate)
+		if err != nil {
+			return err
+		}
+
+		saleDate := saleDay(of)
+		ins := entity.SalesInvoiceInsert{
+			Kind:       entity.SalesInvoiceKindInvoice,
+			OrderId:    orderID,
+			IssueDate:  issueDate,
+			SaleDate:   saleDate,
+			Currency:   strings.ToUpper(of.Order.Currency),
+			VatRegime:  regime,
+			VatRatePct: rate,
+			BuyerVatId: of.Order.BuyerVatID,
+			Lines:      lines,
+			CreatedBy:  createdBy,
+		}
+		ins.BuyerName, ins.BuyerAddress, ins.BuyerCountry, err = billingParty(ctx, db, of)
+		if err != nil {
+			return err
+		}
+		if ins.Currency != "PLN" {
+			ins.PlnRate, err = plnRate(ctx, db, ins.Currency, saleDate)
+			if err != nil {
+				return err
+			}
+		}
+		id, err = insertInvoice(ctx, db, ins)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// IssueCorrectionInvoice issues the next correction of an invoice for the refunds its earlier
+// corrections do not cover yet, and returns its id. The buyer, regime, rate and PLN rate are the
+// corrected invoice's.
+func (s *Store) IssueCorrectionInvoice(ctx context.Context, invoiceID int, reason string, issueDate time.Time, createdBy string) (int, error) {
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		inv, err := storeutil.QueryNamedOne[entity.SalesInvoice](ctx, db,
+			selectInvoice+` WHERE si.id = :id FOR UPDATE`, map[string]any{"id": invoiceID})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return entity.ErrSalesInvoiceNotFound
+			}
+			return fmt.Errorf("can't lock sales invoice: %w", err)
+		}
+		if inv.Kind != entity.SalesInvoiceKindInvoice {
+			return fmt.Errorf("%w: %s is a correction; correct the invoice itself", entity.ErrSalesInvoiceState, inv.Number)
+		}
+		prior, err := storeutil.QueryListNamed[entity.SalesInvoice](ctx, db,
+			selectInvoice+` WHERE si.corrects_id = :id ORDER BY si.id`, map[string]any{"id": invoiceID})
+		if err != nil {
+			return fmt.Errorf("can't get earlier corrections: %w", err)
+		}
+		all, err := withLines(ctx, db, append([]entity.SalesInvoice{inv}, prior...))
+		if err != nil {
+			return err
+		}
+
+		refund, err := refundState(ctx, db, inv.OrderId)
+		if err != nil {
+			return err
+		}
+		lines, err := entity.BuildSalesCorrectionLines(all[0], all[1:], refund)
+		if err != nil {
+			return err
+		}
+		id, err = insertInvoice(ctx, db, entity.SalesInvoiceInsert{
+			Kind:         entity.SalesInvoiceKindCorrection,
+			OrderId:      inv.OrderId,
+			CorrectsId:   sql.NullInt32{Int32: int32(inv.Id), Valid: true},
+			IssueDate:    issueDate,
+			SaleDate:     inv.SaleDate,
+			Currency:     inv.Currency,
+			PlnRate:      inv.PlnRate,
+			VatRegime:    inv.VatRegime,
+			VatRatePct:   inv.VatRatePct,
+			BuyerName:    inv.BuyerName,
+			BuyerVatId:   inv.BuyerVatId,
+			BuyerAddress: inv.BuyerAddress,
+			BuyerCountry: inv.BuyerCountry,
+			Reason:       sql.NullString{String: reason, Valid: reason != ""},
+			Lines:        lines,
+			CreatedBy:    createdBy,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetSalesInvoice returns an invoice with its lines.
+func (s *Store) GetSalesInvoice(ctx context.Context, id int) (*entity.SalesInvoiceFull, error) {
+	inv, err := storeutil.QueryNamedOne[entity.SalesInvoice](ctx, s.DB, selectInvoice+` WHERE si.id = :id`,
+		map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entity.ErrSalesInvoiceNotFound
+		}
+		return nil, fmt.Errorf("can't get sales invoice: %w", err)
+	}
+	full, err := withLines(ctx, s.DB, []entity.SalesInvoice{inv})
+	if err != nil {
+		return nil, err
+	}
+	return &full[0], nil
+}
+
+// ListSalesInvoices lists invoices by number, newest period first, with the total count.
+func (s *Store) ListSalesInvoices(ctx context.Context, limit, offset int, f entity.SalesInvoiceFilter) ([]entity.SalesInvoice, int, error) {
+	where := []string{"1 = 1"}
+	params := map[string]any{"limit": limit, "offset": offset}
+	if f.Period != "" {
+		where = append(where, "si.period = :period")
+		params["period"] = f.Period
+	}
+	if f.OrderUUID != "" {
+		where = append(where, "co.uuid = :uuid")
+		params["uuid"] = f.OrderUUID
+	}
+	if f.Kind != "" {
+		where = append(where, "si.kind = :kind")
+		params["kind"] = f.Kind
+	}
+	cond := strings.Join(where, " AND ")
+	total, err := storeutil.QueryCountNamed(ctx, s.DB, `
+		SELECT COUNT(*) FROM sales_invoice si JOIN customer_order co ON co.id = si.order_id
+		WHERE `+cond, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't count sales invoices: %w", err)
+	}
+	list, err := storeutil.QueryListNamed[entity.SalesInvoice](ctx, s.DB,
+		selectInvoice+` WHERE `+cond+` ORDER BY si.period DESC, si.series DESC, si.seq DESC LIMIT :limit OFFSET :offset`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't list sales invoices: %w", err)
+	}
+	return list, total, nil
+}
+
+// SetSalesInvoiceKsef records the KSeF reference of a submitted invoice. An invoice is submitted
+// once; a second call fails with ErrSalesInvoiceState instead of overwriting the first reference.
+func (s *Store) SetSalesInvoiceKsef(ctx context.Context, id int, reference, hash string, submittedAt time.Time) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		UPDATE sales_invoice SET ksef_reference = :ref, ksef_hash = :hash, ksef_submitted_at = :at
+		WHERE id = :id AND ksef_reference IS NULL`,
+		map[string]any{"id": id, "ref": reference, "hash": hash, "at": submittedAt.UTC()})
+	if err != nil {
+		return fmt.Errorf("can't set ksef reference: %w", err)
+	}
+	if n == 0 {
+		if _, err := s.GetSalesInvoice(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: invoice %d is already in KSeF", entity.ErrSalesInvoiceState, id)
+	}
+	return nil
+}
+
+func lockCustomerOrder(ctx context.Context, db dependency.DB, orderUUID string) (int, error) {
+	row, err := storeutil.QueryNamedOne[struct {
+		Id int `db:"id"`
+	}](ctx, db, `SELECT id FROM customer_order WHERE uuid = :uuid FOR UPDATE`, map[string]any{"uuid": orderUUID})
+	if err != nil {
+		return 0, fmt.Errorf("can't lock order %s: %w", orderUUID, err)
+	}
+	return row.Id, nil
+}
+
+// invoiceSource collects the billable part of an order: every item but gift cards, the shipping the
+// buyer paid, and the total less the gift cards.
+func invoiceSource(ctx context.Context, db dependency.DB, of *entity.OrderFull) (entity.SalesInvoiceSource, error) {
+	productIDs := make([]int, 0, len(of.OrderItems))
+	for _, it := range of.OrderItems {
+		productIDs = append(productIDs, it.ProductId)
+	}
+	giftCards := map[int]bool{}
+	if len(productIDs) > 0 {
+		ids, err := storeutil.QueryScalarListNamed[int](ctx, db,
+			`SELECT product_id FROM gift_card_product WHERE product_id IN (:ids)`, map[string]any{"ids": productIDs})
+		if err != nil {
+			return entity.SalesInvoiceSource{}, fmt.Errorf("can't get gift card products: %w", err)
+		}
+		for _, id := range ids {
+			giftCards[id] = true
+		}
+	}
+	src := entity.SalesInvoiceSource{Gross: of.Order.TotalPrice}
+	for _, it := range of.OrderItems {
+		if giftCards[it.ProductId] {
+			src.Gross = src.Gross.Sub(it.ProductPriceWithSale.Mul(it.Quantity))
+			continue
+		}
+		name, ok := canonical.ProductName(it.Translations, cache.GetLanguages())
+		if !ok {
+			name = it.SKU
+		}
+		if it.Color != "" {
+			name += ", " + it.Color
+		}
+		src.Items = append(src.Items, entity.SalesInvoiceItemSource{
+			OrderItemId: it.Id,
+			Name:        name,
+			Quantity:    it.Quantity,
+			UnitGross:   it.ProductPriceWithSale,
+		})
+	}
+	if !of.Shipment.FreeShipping {
+		src.Shipping = of.Shipment.CostDecimal(of.Order.Currency)
+	}
+	return src, nil
+}
+
+// saleDay is the day the order was paid: its first move to confirmed, else the day it was placed.
+func saleDay(of *entity.OrderFull) time.Time {
+	day := of.Order.Placed
+	for _, h := range of.StatusHistory {
+		if h.OrderStatusId == cache.OrderStatusConfirmed.Status.Id {
+			day = h.ChangedAt
+			break
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// billingParty is the buyer as invoiced: the billing company or the buyer's name, the billing street
+// and town, and the billing country (country_code, falling back to country as on the VAT path).
+func billingParty(ctx context.Context, db dependency.DB, of *entity.OrderFull) (name, address, country string, err error) {
+	b := of.Billing
+	name = strings.TrimSpace(b.Company.String)
+	if name == "" {
+		name = strings.TrimSpace(of.Buyer.FirstName + " " + of.Buyer.LastName)
+	}
+	parts := []string{b.AddressLineOne}
+	if b.AddressLineTwo.Valid && b.AddressLineTwo.String != "" {
+		parts = append(parts, b.AddressLineTwo.String)
+	}
+	parts = append(parts, strings.TrimSpace(b.PostalCode+" "+b.City))
+	address = strings.Join(parts, ", ")
+
+	row, err := storeutil.QueryNamedOne[struct {
+		Country string `db:"country"`
+	}](ctx, db, `
+		SELECT UPPER(COALESCE(NULLIF(a.country_code, ''), a.country, '')) AS country
+		FROM address a WHERE a.id = :id`, map[string]any{"id": of.Buyer.BillingAddressID})
+	if err != nil {
+		return "", "", "", fmt.Errorf("can't get billing country: %w", err)
+	}
+	country = row.Country
+	if len(country) != 2 {
+		return "", "", "", fmt.Errorf("%w: billing country %q is not an ISO code", entity.ErrSalesInvoiceState, country)
+	}
+	return name, address, country, nil
+}
+
+// plnRate is the PLN value of one unit of currency on the last day with a reference rate before the
+// sale date (art. 31a — D-1). costing_fx_rate holds base-currency units per unit of each currency, so
+// the PLN rate is the currency's rate over the PLN one.
+func plnRate(ctx context.Context, db dependency.DB, currency string, saleDate time.Time) (decimal.NullDecimal, error) {
+	rateBefore := func(cur string) (decimal.Decimal, error) {
+		if strings.EqualFold(cur, cache.GetBaseCurrency()) {
+			return decimal.NewFromInt(1), nil
+		}
+		row, err := storeutil.QueryNamedOne[struct {
+			Rate decimal.Decimal `db:"rate_to_base"`
+		}](ctx, db, `
+			SELECT rate_to_base FROM costing_fx_rate
+			WHERE currency = :cur AND valid_from < :day
+			ORDER BY valid_from DESC LIMIT 1`,
+			map[string]any{"cur": strings.ToUpper(cur), "day": saleDate.Format("2006-01-02")})
+		if errors.Is(err, sql.ErrNoRows) || err == nil && !row.Rate.IsPositive() {
+			return decimal.Zero, fmt.Errorf("%w: %s before %s", entity.ErrSalesInvoiceFxMissing, cur, saleDate.Format("2006-01-02"))
+		}
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("can't get %s rate: %w", cur, err)
+		}
+		return row.Rate, nil
+	}
+	cur, err := rateBefore(currency)
+	if err != nil {
+		return decimal.NullDecimal{}, err
+	}
+	pln, err := rateBefore("PLN")
+	if err != nil {
+		return decimal.NullDecimal{}, err
+	}
+	return decimal.NullDecimal{Decimal: cur.Div(pln).Round(6), Valid: true}, nil
+}
+
+// refundState reads what has been refunded on an order so far.
+func refundState(ctx context.Context, db dependency.DB, orderID int) (entity.SalesCorrectionSource, error) {
+	head, err := storeutil.QueryNamedOne[struct {
+		RefundedAmount   decimal.Decimal `db:"refunded_amount"`
+		ShippingRefunded bool            `db:"shipping_refunded"`
+	}](ctx, db, `SELECT refunded_amount, shipping_refunded FROM customer_order WHERE id = :id`,
+		map[string]any{"id": orderID})
+	if err != nil {
+		return entity.SalesCorrectionSource{}, fmt.Errorf("can't get order refund state: %w", err)
+	}
+	rows, err := storeutil.QueryListNamed[struct {
+		OrderItemId int             `db:"order_item_id"`
+		Qty         decimal.Decimal `db:"qty"`
+	}](ctx, db, `
+		SELECT order_item_id, SUM(quantity_refunded) AS qty FROM refunded_order_item
+		WHERE order_id = :id GROUP BY order_item_id`, map[string]any{"id": orderID})
+	if err != nil {
+		return entity.SalesCorrectionSource{}, fmt.Errorf("can't get refunded items: %w", err)
+	}
+	src := entity.SalesCorrectionSource{
+		RefundedQty:      make(map[int]decimal.Decimal, len(rows)),
+		ShippingRefunded: head.ShippingRefunded,
+		RefundedGross:    head.RefundedAmount,
+	}
+	for _, r := range rows {
+		src.RefundedQty[r.OrderItemId] = r.Qty
+	}
+	return src, nil
+}
+
+func withLines(ctx context.Context, db dependency.DB, invoices []entity.SalesInvoice) ([]entity.SalesInvoiceFull, error) {
+	ids := make([]int, 0, len(invoices))
+	for _, inv := range invoices {
+		ids = append(ids, inv.Id)
+	}
+	lines, err := storeutil.QueryListNamed[entity.SalesInvoiceLine](ctx, db, `
+		SELECT id, invoice_id, position, kind, order_item_id, name, quantity, unit_gross, net, vat, gross
+		FROM sales_invoice_line WHERE invoice_id IN (:ids) ORDER BY invoice_id, position`,
+		map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("can't get sales invoice lines: %w", err)
+	}
+	byInvoice := make(map[int][]entity.SalesInvoiceLine, len(invoices))
+	for _, l := range lines {
+		byInvoice[l.InvoiceId] = append(byInvoice[l.InvoiceId], l)
+	}
+	out := make([]entity.SalesInvoiceFull, 0, len(invoices))
+	for _, inv := range invoices {
+		out = append(out, entity.SalesInvoiceFull{SalesInvoice: inv, Lines: byInvoice[inv.Id]})
+	}
+	return out, nil
+}
+
+// insertInvoice numbers and stores a built invoice. The counter row is bumped first — the statement
+// holds its lock until the transaction ends, so concurrent issues in one series and month queue
+// behind each other and a rollback gives the number back. An issue date before the latest one already
+// numbered in the month would break the chronological order of the sequence and is refused.
+func insertInvoice(ctx context.Context, db dependency.DB, ins entity.SalesInvoiceInsert) (int, error) {
+	series, period := ins.Kind.Series(), entity.SalesInvoicePeriod(ins.IssueDate)
+	if err := storeutil.ExecNamed(ctx, db, `
+		INSERT INTO sales_invoice_counter (series, period, last_seq) VALUES (:series, :period, 1)
+		ON DUPLICATE KEY UPDATE last_seq = last_seq + 1`,
+		map[string]any{"series": series, "period": period}); err != nil {
+		return 0, fmt.Errorf("can't allocate invoice number: %w", err)
+	}
+	state, err := storeutil.QueryNamedOne[struct {
+		Seq    int          `db:"last_seq"`
+		Latest sql.NullTime `db:"latest"`
+	}](ctx, db, `
+		SELECT c.last_seq,
+		       (SELECT MAX(issue_date) FROM sales_invoice WHERE series = c.series AND period = c.period) AS latest
+		FROM sales_invoice_counter c WHERE c.series = :series AND c.period = :period`,
+		map[string]any{"series": series, "period": period})
+	if err != nil {
+		return 0, fmt.Errorf("can't read invoice number: %w", err)
+	}
+	if state.Latest.Valid && ins.IssueDate.Before(state.Latest.Time) {
+		return 0, fmt.Errorf("%w: %s/%s is already numbered up to %s", entity.ErrSalesInvoiceState,
+			series, period, state.Latest.Time.Format("2006-01-02"))
+	}
+	seq := state.Seq
+
+	net, vat, gross := ins.Totals()
+	id, err := storeutil.ExecNamedLastId(ctx, db, `
+		INSERT INTO sales_invoice (number, kind, series, period, seq, order_id, corrects_id, issue_date,
+			sale_date, currency, pln_rate, vat_regime, vat_rate_pct, buyer_name, buyer_vat_id,
+			buyer_address, buyer_country, net, vat, gross, reason, created_by)
+		VALUES (:number, :kind, :series, :period, :seq, :orderId, :correctsId, :issueDate, :saleDate,
+			:currency, :plnRate, :regime, :rate, :buyerName, :buyerVatId, :buyerAddress, :buyerCountry,
+			:net, :vat, :gross, :reason, :createdBy)`,
+		map[string]any{
+			"number":       entity.SalesInvoiceNumber(series, ins.IssueDate, seq),
+			"kind":         ins.Kind,
+			"series":       series,
+			"period":       period,
+			"seq":          seq,
+			"orderId":      ins.OrderId,
+			"correctsId":   ins.CorrectsId,
+			"issueDate":    ins.IssueDate.Format("2006-01-02"),
+			"saleDate":     ins.SaleDate.Format("2006-01-02"),
+			"currency":     ins.Currency,
+			"plnRate":      ins.PlnRate,
+			"regime":       ins.VatRegime,
+			"rate":         ins.VatRatePct,
+			"buyerName":    ins.BuyerName,
+			"buyerVatId":   ins.BuyerVatId,
+			"buyerAddress": ins.BuyerAddress,
+			"buyerCountry": ins.BuyerCountry,
+			"net":          net,
+			"vat":          vat,
+			"gross":        gross,
+			"reason":       ins.Reason,
+			"createdBy":    ins.CreatedBy,
+		})
+	if err != nil {
+		return 0, fmt.Errorf("can't insert sales invoice: %w", err)
+	}
+	for _, l := range ins.Lines {
+		err := storeutil.ExecNamed(ctx, db, `
+			INSERT INTO sales_invoice_line (invoice_id, position, kind, order_item_id, name, quantity,
+				unit_gross, net, vat, gross)
+			VALUES (:invoiceId, :position, :kind, :orderItemId, :name, :qty, :unitGross, :net, :vat, :gross)`,
+			map[string]any{
+				"invoiceId":   id,
+				"position":    l.Position,
+				"kind":        l.Kind,
+				"orderItemId": l.OrderItemId,
+				"name":        l.Name,
+				"qty":         l.Quantity,
+				"unitGross":   l.UnitGross,
+				"net":         l.Net,
+				"vat":         l.Vat,
+				"gross":       l.Gross,
+			})
+		if err != nil {
+			return 0, fmt.Errorf("can't insert sales invoice line: %w", err)
+		}
+	}
+	return id, nil
+}
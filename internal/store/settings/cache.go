@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/promo"
 	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
 )
 
@@ -352,6 +353,9 @@ func (s *Store) getPromos(ctx context.Context) ([]entity.PromoCode, error) {
 	if err != nil {
 		return nil, fmt.Errorf("can't get PaymentMethod by id: %w", err)
 	}
+	if err := promo.LoadRules(ctx, s.DB, promos); err != nil {
+		return nil, err
+	}
 	return promos, nil
 }
 
@@ -0,0 +1,246 @@
+// Package sizing implements dependency.Sizing: storefront fit profiles (0351) and the per-style data
+// the size recommender reads — the size chart, approved fit-model fittings and wrong-size returns.
+package sizing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// TxFunc matches the store transaction callback used by MYSQLStore.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.Sizing.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a sizing store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+// GetFitProfile returns the account's fit profile; an account that never saved one gets an empty
+// regular-fit profile.
+func (s *Store) GetFitProfile(ctx context.Context, accountID int) (*entity.FitProfile, error) {
+	type profileRow struct {
+		Preference          string    `db:"fit_preference"`
+		ReferenceProductId  int       `db:"reference_product_id"`
+		ReferenceSizeId     int       `db:"reference_size_id"`
+		ReferenceVariantSKU string    `db:"reference_variant_sku"`
+		UpdatedAt           time.Time `db:"updated_at"`
+	}
+	p := &entity.FitProfile{AccountId: accountID, Preference: entity.FitPreferenceRegular}
+	row, err := storeutil.QueryNamedOne[profileRow](ctx, s.DB, `
+		SELECT fp.fit_preference,
+			COALESCE(fp.reference_product_id, 0) AS reference_product_id,
+			COALESCE(fp.reference_size_id, 0) AS reference_size_id,
+			COALESCE(ps.sku, '') AS reference_variant_sku,
+			fp.updated_at
+		FROM storefront_fit_profile fp
+		LEFT JOIN product_size ps ON ps.product_id = fp.reference_product_id
+			AND ps.size_id = fp.reference_size_id AND ps.grade = 'A'
+		WHERE fp.account_id = :accountId`, map[string]any{"accountId": accountID})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("can't get fit profile of account %d: %w", accountID, err)
+	}
+	if err == nil {
+		p.Preference = entity.FitPreference(row.Preference)
+		p.ReferenceProductId = row.ReferenceProductId
+		p.ReferenceSizeId = row.ReferenceSizeId
+		p.ReferenceVariantSKU = row.ReferenceVariantSKU
+		p.UpdatedAt = row.UpdatedAt
+	}
+
+	p.Measurements, err = storeutil.QueryListNamed[entity.ModelMeasurement](ctx, s.DB, `
+		SELECT measurement_name, measurement_value_mm
+		FROM storefront_fit_measurement
+		WHERE account_id = :accountId
+		ORDER BY measurement_name`, map[string]any{"accountId": accountID})
+	if err != nil {
+		return nil, fmt.Errorf("can't get fit measurements of account %d: %w", accountID, err)
+	}
+	return p, nil
+}
+
+// SaveFitProfile replaces the account's fit profile: the preference, the reference variant and the
+// full set of measurements (one left out is deleted).
+func (s *Store) SaveFitProfile(ctx context.Context, p *entity.FitProfile) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		var refProduct, refSize any
+		if p.ReferenceProductId > 0 && p.ReferenceSizeId > 0 {
+			refProduct, refSize = p.ReferenceProductId, p.ReferenceSizeId
+		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			INSERT INTO storefront_fit_profile (account_id, fit_preference, reference_product_id, reference_size_id)
+			VALUES (:accountId, :preference, :refProduct, :refSize)
+			ON DUPLICATE KEY UPDATE
+				fit_preference = VALUES(fit_preference),
+				reference_product_id = VALUES(reference_product_id),
+				reference_size_id = VALUES(reference_size_id),
+				updated_at = CURRENT_TIMESTAMP`, map[string]any{
+			"accountId":  p.AccountId,
+			"preference": string(p.Preference),
+			"refProduct": refProduct,
+			"refSize":    refSize,
+		}); err != nil {
+			return fmt.Errorf("save fit profile: %w", err)
+		}
+		if err := storeutil.ExecNamed(ctx, db, `DELETE FROM storefront_fit_measurement WHERE account_id = :accountId`,
+			map[string]any{"accountId": p.AccountId}); err != nil {
+			return fmt.Errorf("clear fit measurements: %w", err)
+		}
+		rows := make([]map[string]any, 0, len(p.Measurements))
+		for _, m := range p.Measurements {
+			rows = append(rows, map[string]any{
+				"account_id":           p.AccountId,
+				"measurement_name":     string(m.Name),
+				"measurement_value_mm": m.ValueMM,
+			})
+		}
+		if err := storeutil.BulkInsert(ctx, db, "storefront_fit_measurement", rows); err != nil {
+			return fmt.Errorf("save fit measurements: %w", err)
+		}
+		return nil
+	})
+}
+
+// ResolveFitVariant resolves a variant SKU of a storefront colourway (an ACTIVE colourway's grade-A
+// variant); sql.ErrNoRows when there is none.
+func (s *Store) ResolveFitVariant(ctx context.Context, variantSKU string) (*entity.FitVariant, error) {
+	v, err := storeutil.QueryNamedOne[entity.FitVariant](ctx, s.DB, `
+		SELECT ps.product_id, p.style_id, ps.size_id, ps.sku AS variant_sku,
+			p.min_tier, p.hidden_for_non_qualified
+		FROM product_size ps
+		JOIN product p ON p.id = ps.product_id
+		WHERE ps.sku = :sku AND ps.grade = 'A' AND p.lifecycle_status = 2 AND p.style_id IS NOT NULL`,
+		map[string]any{"sku": variantSKU})
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve fit variant %q: %w", variantSKU, err)
+	}
+	return &v, nil
+}
+
+// GetStyleFitData loads what the size recommender reads about a style. Only approved, done fittings
+// in a single size on a model with measurements calibrate the ease: a fitting that tried several
+// sizes does not say which one was approved. Wrong-size returns are the RMA lines (0333) plus the
+// legacy wrong_size refunds of orders that never had a return request, so a refunded RMA is not
+// counted twice.
+func (s *Store) GetStyleFitData(ctx context.Context, styleID int) (*entity.StyleFitData, error) {
+	params := map[string]any{"styleId": styleID}
+	unit, err := storeutil.QueryNamedOne[struct {
+		Unit string `db:"measurement_unit"`
+	}](ctx, s.DB, `SELECT measurement_unit FROM tech_card WHERE id = :styleId`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get style %d: %w", styleID, err)
+	}
+	d := &entity.StyleFitData{StyleId: styleID, Unit: entity.TechCardMeasurementUnit(unit.Unit)}
+
+	d.Sizes, err = storeutil.QueryListNamed[entity.FitSize](ctx, s.DB, `
+		SELECT DISTINCT sz.id AS size_id, sz.name, sz.sku_ord
+		FROM tech_card_size_measurement m
+		JOIN size sz ON sz.id = m.size_id
+		WHERE m.tech_card_id = :styleId
+		ORDER BY sz.sku_ord, sz.id`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get charted sizes of style %d: %w", styleID, err)
+	}
+	d.Chart, err = storeutil.QueryListNamed[entity.StyleFitCell](ctx, s.DB, `
+		SELECT m.size_id, mn.name AS measurement, m.measurement_value AS value
+		FROM tech_card_size_measurement m
+		JOIN measurement_name mn ON mn.id = m.measurement_name_id
+		WHERE m.tech_card_id = :styleId`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get size chart of style %d: %w", styleID, err)
+	}
+
+	type fittingRow struct {
+		FittingId int                        `db:"fitting_id"`
+		SizeId    int                        `db:"size_id"`
+		Name      entity.BodyMeasurementName `db:"measurement_name"`
+		ValueMM   int                        `db:"measurement_value_mm"`
+	}
+	frows, err := storeutil.QueryListNamed[fittingRow](ctx, s.DB, `
+		SELECT f.id AS fitting_id, fs.size_id, mm.measurement_name, mm.measurement_value_mm
+		FROM fitting f
+		LEFT JOIN product p ON p.id = f.product_id
+		JOIN fitting_size fs ON fs.fitting_id = f.id
+		JOIN model_measurement mm ON mm.model_id = f.model_id
+		WHERE (p.style_id = :styleId OR f.tech_card_id = :styleId)
+			AND f.status = 'done' AND f.verdict = 'approved'
+			AND (SELECT COUNT(*) FROM fitting_size x WHERE x.fitting_id = f.id) = 1
+		ORDER BY f.id`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get fittings of style %d: %w", styleID, err)
+	}
+	for _, r := range frows {
+		if n := len(d.Fittings); n == 0 || d.Fittings[n-1].FittingId != r.FittingId {
+			d.Fittings = append(d.Fittings, entity.FitModelFitting{FittingId: r.FittingId, SizeId: r.SizeId})
+		}
+		f := &d.Fittings[len(d.Fittings)-1]
+		f.Measurements = append(f.Measurements, entity.ModelMeasurement{Name: r.Name, ValueMM: r.ValueMM})
+	}
+
+	// Sold counts fully refunded orders too: they are where most wrong-size returns end up.
+	statusIDs := cache.OrderStatusIDsForRefund()
+	if len(statusIDs) == 0 {
+		return d, nil
+	}
+	params["statusIds"] = statusIDs
+	d.Returns, err = storeutil.QueryListNamed[entity.SizeReturnSignal](ctx, s.DB, `
+		WITH sold AS (
+			SELECT oi.size_id, SUM(oi.quantity) AS sold
+			FROM order_item oi
+			JOIN customer_order co ON co.id = oi.order_id
+			JOIN product p ON p.id = oi.product_id
+			WHERE p.style_id = :styleId AND co.order_status_id IN (:statusIds)
+			GROUP BY oi.size_id
+		),
+		rma AS (
+			SELECT oi.size_id,
+				SUM(rri.quantity) AS wrong_size,
+				SUM(CASE WHEN sx.sku_ord > sz.sku_ord THEN rri.quantity ELSE 0 END) AS exchanged_up,
+				SUM(CASE WHEN sx.sku_ord < sz.sku_ord THEN rri.quantity ELSE 0 END) AS exchanged_down
+			FROM return_request rr
+			JOIN return_request_item rri ON rri.return_request_id = rr.id
+			JOIN order_item oi ON oi.id = rri.order_item_id
+			JOIN product p ON p.id = oi.product_id
+			JOIN size sz ON sz.id = oi.size_id
+			LEFT JOIN size sx ON sx.id = rri.exchange_size_id
+			WHERE p.style_id = :styleId AND rr.reason_code = 'wrong_size'
+				AND rr.status NOT IN ('rejected', 'cancelled')
+			GROUP BY oi.size_id
+		),
+		legacy AS (
+			SELECT oi.size_id, SUM(roi.quantity_refunded) AS wrong_size
+			FROM refunded_order_item roi
+			JOIN customer_order co ON co.id = roi.order_id
+			JOIN order_item oi ON oi.id = roi.order_item_id
+			JOIN product p ON p.id = oi.product_id
+			WHERE p.style_id = :styleId AND co.refund_reason_code = 'wrong_size'
+				AND NOT EXISTS (SELECT 1 FROM return_request rr WHERE rr.order_id = co.id)
+			GROUP BY oi.size_id
+		)
+		SELECT s.size_id, s.sold,
+			COALESCE(r.wrong_size, 0) + COALESCE(l.wrong_size, 0) AS wrong_size,
+			COALESCE(r.exchanged_up, 0) AS exchanged_up,
+			COALESCE(r.exchanged_down, 0) AS exchanged_down
+		FROM sold s
+		LEFT JOIN rma r ON r.size_id = s.size_id
+		LEFT JOIN legacy l ON l.size_id = s.size_id
+		ORDER BY s.size_id`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get wrong-size returns of style %d: %w", styleID, err)
+	}
+	return d, nil
+}
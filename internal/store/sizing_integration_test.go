@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/sizerec"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSizing covers 0351: a fit profile round-trips (a save replaces the measurements), the style's
+// chart and its approved single-size fittings feed the recommender, a multi-size fitting does not,
+// and erasing the account deletes the profile.
+//
+// SAFE ONLY against a local container DSN — see the guard and mysql_test.go / project memory.
+func TestSizing(t *testing.T) {
+	if os.Getenv("CI") == "" &&
+		!strings.Contains(testCfg.DSN, "127.0.0.1") &&
+		!strings.Contains(testCfg.DSN, "localhost") {
+		t.Skip("skipping outside CI unless the DSN targets a local container (avoids the configured prod DB)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	exec := func(q string, args ...any) int {
+		res, err := testDB.ExecContext(ctx, q, args...)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return int(id)
+	}
+	token := fmt.Sprintf("%d%04d", time.Now().UnixNano(), rand.Intn(10000))
+	mediaID, err := s.Media().AddMedia(ctx, &entity.MediaItem{
+		FullSizeMediaURL: "https://x/f.jpg", FullSizeWidth: 1, FullSizeHeight: 1,
+		ThumbnailMediaURL: "https://x/t.jpg", ThumbnailWidth: 1, ThumbnailHeight: 1,
+		CompressedMediaURL: "https://x/c.jpg", CompressedWidth: 1, CompressedHeight: 1,
+		BlurHash: sql.NullString{String: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", Valid: true},
+	})
+	require.NoError(t, err)
+	var sizeA, sizeB, chestID int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 ORDER BY sku_ord, id LIMIT 1`).Scan(&sizeA))
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 AND sku_system = (SELECT sku_system FROM size WHERE id = ?) AND sku_ord > (SELECT sku_ord FROM size WHERE id = ?) ORDER BY sku_ord LIMIT 1`, sizeA, sizeA).Scan(&sizeB))
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM measurement_name WHERE name = 'chest'`).Scan(&chestID))
+
+	styleID := exec(`INSERT INTO tech_card (style_number, name, brand, collection, season_code, season_year, season, target_gender, top_category_id, measurement_unit)
+		VALUES (CONCAT('FIT-', UUID_SHORT()), 'FIT', 'ACME', '', 'SS', 2026, 'SS26', 'unisex', 1, 'cm')`)
+	product := exec(`INSERT INTO product (sku, color, color_code, color_hex, country_of_origin, thumbnail_id, style_id, lifecycle_status, sale_percentage)
+		VALUES (?, 'c', 'BLK', '#000000', 'US', ?, ?, 2, 0)`, "FIT-"+token, mediaID, styleID)
+	variantSKU := "FIT-" + token + "-1"
+	exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, product, sizeA, variantSKU)
+	exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, product, sizeB, "FIT-"+token+"-2")
+	exec(`INSERT INTO tech_card_size_measurement (tech_card_id, size_id, measurement_name_id, measurement_value) VALUES (?, ?, ?, 50), (?, ?, ?, 53)`,
+		styleID, sizeA, chestID, styleID, sizeB, chestID)
+
+	modelID := exec(`INSERT INTO model (name, thumbnail_id) VALUES (?, ?)`, "fit-"+token, mediaID)
+	exec(`INSERT INTO model_measurement (model_id, measurement_name, measurement_value_mm) VALUES (?, 'chest', 1000)`, modelID)
+	fitting := func(sizes ...int) int {
+		id := exec(`INSERT INTO fitting (product_id, tech_card_id, model_id, fitting_date, status, verdict) VALUES (?, ?, ?, CURDATE(), 'done', 'approved')`,
+			product, styleID, modelID)
+		for _, sz := range sizes {
+			exec(`INSERT INTO fitting_size (fitting_id, size_id) VALUES (?, ?)`, id, sz)
+		}
+		return id
+	}
+	approved := fitting(sizeB)
+	multi := fitting(sizeA, sizeB)
+
+	acc, err := s.StorefrontAccount().GetOrCreateAccountByEmail(ctx, "fit"+token+"@example.com")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM storefront_fit_measurement WHERE account_id = ?", acc.ID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM storefront_fit_profile WHERE account_id = ?", acc.ID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM storefront_account WHERE id = ?", acc.ID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM fitting WHERE id IN (?, ?)", approved, multi)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM model WHERE id = ?", modelID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_size WHERE product_id = ?", product)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product WHERE id = ?", product)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM tech_card WHERE id = ?", styleID)
+	})
+	S := s.Sizing()
+
+	// No profile yet: an empty regular fit.
+	p, err := S.GetFitProfile(ctx, acc.ID)
+	require.NoError(t, err)
+	require.Equal(t, entity.FitPreferenceRegular, p.Preference)
+	require.Empty(t, p.Measurements)
+	require.True(t, p.UpdatedAt.IsZero())
+
+	ref, err := S.ResolveFitVariant(ctx, variantSKU)
+	require.NoError(t, err)
+	require.Equal(t, styleID, ref.StyleId)
+	require.Equal(t, sizeA, ref.SizeId)
+	_, err = S.ResolveFitVariant(ctx, "NOPE-"+token)
+	require.True(t, errors.Is(err, sql.ErrNoRows), "got %v", err)
+
+	require.NoError(t, S.SaveFitProfile(ctx, &entity.FitProfile{
+		AccountId:          acc.ID,
+		Preference:         entity.FitPreferenceRelaxed,
+		Measurements:       []entity.ModelMeasurement{{Name: entity.BodyChest, ValueMM: 960}, {Name: entity.BodyWaist, ValueMM: 800}},
+		ReferenceProductId: ref.ProductId,
+		ReferenceSizeId:    ref.SizeId,
+	}))
+	require.NoError(t, S.SaveFitProfile(ctx, &entity.FitProfile{
+		AccountId:          acc.ID,
+		Preference:         entity.FitPreferenceClose,
+		Measurements:       []entity.ModelMeasurement{{Name: entity.BodyChest, ValueMM: 940}},
+		ReferenceProductId: ref.ProductId,
+		ReferenceSizeId:    ref.SizeId,
+	}))
+	p, err = S.GetFitProfile(ctx, acc.ID)
+	require.NoError(t, err)
+	require.Equal(t, entity.FitPreferenceClose, p.Preference)
+	require.Equal(t, []entity.ModelMeasurement{{Name: entity.BodyChest, ValueMM: 940}}, p.Measurements, "a save replaces the measurements")
+	require.Equal(t, variantSKU, p.ReferenceVariantSKU)
+
+	// The style: two charted sizes, the single-size fitting calibrates, the multi-size one does not.
+	d, err := S.GetStyleFitData(ctx, styleID)
+	require.NoError(t, err)
+	require.Equal(t, entity.TechCardUnitCm, d.Unit)
+	require.Len(t, d.Sizes, 2)
+	require.Equal(t, sizeA, d.Sizes[0].SizeId)
+	require.Len(t, d.Chart, 2)
+	require.Len(t, d.Fittings, 1)
+	require.Equal(t, approved, d.Fittings[0].FittingId)
+	require.Equal(t, sizeB, d.Fittings[0].SizeId)
+
+	rec, err := sizerec.Recommend(d, sizerec.BodyFromMeasurements(p.Measurements), entity.FitPreferenceRegular, sizerec.BasisMeasurements)
+	require.NoError(t, err)
+	require.True(t, rec.Calibrated)
+	require.Equal(t, sizeA, rec.SizeId, "940 mm gets exactly the 60 mm ease approved on the model in the larger size")
+
+	// Erasure removes the profile with the rest of the PII.
+	require.NoError(t, s.Membership().HardEraseAccount(ctx, acc.ID))
+	p, err = S.GetFitProfile(ctx, acc.ID)
+	require.NoError(t, err)
+	require.Empty(t, p.Measurements)
+	require.Empty(t, p.ReferenceVariantSKU)
+}
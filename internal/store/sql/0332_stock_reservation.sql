@@ -0,0 +1,43 @@
+-- +migrate Up
+
+-- Durable backend for storefront stock holds (stockreserve.MySQLManager). Until now every cart and
+-- order hold lived in the in-process maps of stockreserve.Manager, so a deploy or crash silently
+-- dropped all of them and a second replica would have kept its own, disjoint view of "who holds
+-- what" — two instances could each hand the last unit to a different cart.
+--
+-- ONE ROW PER (product, size, session), the same key the in-memory manager used. A re-reserve of
+-- the same variant by the same session is an upsert of that row, never a second hold.
+--
+-- The hold is still ADVISORY: the authoritative guard against oversell remains the conditional
+-- decrement of product_size.quantity at payment. What this table adds is that the advisory layer
+-- survives restarts and is shared by every instance. Reserves of one (product, size) are serialized
+-- by a row lock on its A-grade product_size row (B-grade seconds bypass the soft layer entirely, see
+-- order_pre_checkout.go), so the "available = stock − others' holds" read and the write that follows
+-- it can never interleave across instances.
+--
+-- expires_at is the only liveness signal. Readers ignore expired rows; the manager's sweeper deletes
+-- them in bounded batches, so a sweep that never runs costs disk, not correctness.
+--
+-- No FK to product / product_size: a hold is a 15-30 minute fact and must never block deleting or
+-- archiving a variant. A hold on a vanished variant simply expires.
+
+CREATE TABLE IF NOT EXISTS stock_reservation (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    size_id INT NOT NULL,
+    session_id VARCHAR(128) NOT NULL,
+    order_uuid VARCHAR(64) NULL DEFAULT NULL COMMENT 'Set by Commit: the hold now belongs to a submitted order',
+    quantity DECIMAL(10, 2) NOT NULL,
+    refresh_count INT NOT NULL DEFAULT 0 COMMENT 'Re-reserves of the same key; capped by stockreserve.Limits.MaxTTLRefreshes',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_stock_reservation_key (product_id, size_id, session_id),
+    INDEX idx_stock_reservation_product_size (product_id, size_id, expires_at),
+    INDEX idx_stock_reservation_session (session_id, expires_at),
+    INDEX idx_stock_reservation_order (order_uuid),
+    INDEX idx_stock_reservation_expires (expires_at),
+    CONSTRAINT chk_stock_reservation_qty_positive CHECK (quantity > 0)
+) COMMENT 'Temporary cart/order stock holds shared by every instance (stockreserve MySQL backend)';
+
+-- +migrate Down
+DROP TABLE IF EXISTS stock_reservation;
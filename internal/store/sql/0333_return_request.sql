@@ -0,0 +1,69 @@
+-- +migrate Up
+
+-- Customer self-service returns and exchanges (RMA). Until now a return existed only as an order
+-- status (pending_return) set by the customer's "cancel" on a shipped order or by an admin, and the
+-- admin then refunded whole lines blind. A return_request records WHAT the customer sends back
+-- (per order line and unit count), WHY (reason_code, the same vocabulary as the refund reason) and
+-- WHAT they want instead: the money back (resolution = refund) or another size of the same colourway
+-- (resolution = exchange).
+--
+-- Lifecycle (store/returns enforces the transitions, every one under a FOR UPDATE on the row):
+--   requested  → approved | rejected | cancelled
+--   approved   → received | rejected | cancelled
+--   received   → refunding → refunded   (refund: hand-off to the order refund path)
+--   received   → exchanged              (exchange: returned units restock, replacement ships)
+--   received   → rejected               (failed inspection: goods go back to the customer)
+-- refunding is the claim taken before the payment provider is called, so a double-click or a second
+-- admin cannot refund the same return twice; a failed hand-off leaves it there for a retry.
+--
+-- The money, the restock and the accounting entry are NOT duplicated here: the refund resolution
+-- calls the existing RefundOrder with the returned units, which restores stock per disposition,
+-- writes refunded_order_item and enqueues the order_refund accounting event.
+--
+-- Exchange replacement units are journalled under the order_exchange stock source: taken out of
+-- sellable stock when the return is approved (so the size cannot sell out while the parcel is in
+-- transit) and put back if the approved return is later rejected or cancelled.
+
+CREATE TABLE IF NOT EXISTS return_request (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    uuid CHAR(36) NOT NULL,
+    rma_number VARCHAR(20) NULL COMMENT 'RMA-YYYY-NNNNNN, set right after insert from the id',
+    order_id INT NOT NULL,
+    status ENUM('requested', 'approved', 'rejected', 'received', 'refunding', 'refunded', 'exchanged', 'cancelled') NOT NULL DEFAULT 'requested',
+    resolution ENUM('refund', 'exchange') NOT NULL,
+    reason_code VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'Refund reason key: wrong_size / not_as_described / defective / changed_mind / other',
+    customer_note TEXT NULL,
+    admin_note TEXT NULL,
+    disposition VARCHAR(16) NOT NULL DEFAULT '' COMMENT 'Set at inspection: restock / writeoff / seconds',
+    stock_allocated BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Exchange replacement units are out of stock (order_exchange journal)',
+    decided_by VARCHAR(255) NULL COMMENT 'Admin who approved or rejected',
+    approved_at TIMESTAMP NULL,
+    received_at TIMESTAMP NULL,
+    closed_at TIMESTAMP NULL COMMENT 'Refunded, exchanged, rejected or cancelled',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_return_request_uuid (uuid),
+    UNIQUE KEY uq_return_request_rma_number (rma_number),
+    INDEX idx_return_request_order (order_id),
+    INDEX idx_return_request_status (status, created_at),
+    CONSTRAINT fk_return_request_order FOREIGN KEY (order_id) REFERENCES customer_order(id)
+) COMMENT 'Customer return / exchange requests (RMA)';
+
+CREATE TABLE IF NOT EXISTS return_request_item (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    return_request_id INT NOT NULL,
+    order_item_id INT NOT NULL,
+    quantity INT NOT NULL,
+    exchange_product_id INT NULL COMMENT 'Exchange only: replacement colourway (always the returned one)',
+    exchange_size_id INT NULL COMMENT 'Exchange only: replacement size',
+    UNIQUE KEY uq_return_request_item_line (return_request_id, order_item_id),
+    INDEX idx_return_request_item_order_item (order_item_id),
+    CONSTRAINT fk_return_request_item_request FOREIGN KEY (return_request_id) REFERENCES return_request(id) ON DELETE CASCADE,
+    CONSTRAINT fk_return_request_item_order_item FOREIGN KEY (order_item_id) REFERENCES order_item(id),
+    CONSTRAINT chk_return_request_item_qty_positive CHECK (quantity > 0),
+    CONSTRAINT chk_return_request_item_exchange_pair CHECK ((exchange_product_id IS NULL) = (exchange_size_id IS NULL))
+) COMMENT 'Order lines (and unit counts) sent back under a return request';
+
+-- +migrate Down
+DROP TABLE IF EXISTS return_request_item;
+DROP TABLE IF EXISTS return_request;
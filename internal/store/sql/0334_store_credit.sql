@@ -0,0 +1,117 @@
+-- +migrate Up
+
+-- Gift cards and store credit. Both are a prepaid balance the shop owes the customer, so they share
+-- one model: a store_credit_wallet holds the balance and an append-only store_credit_ledger records
+-- every movement of it. Two kinds of wallet exist:
+--   gift_card — bearer balance behind a code, issued when an order containing a gift-card product is
+--               paid (one wallet per unit, face value = the paid unit price);
+--   account   — per-email, per-currency customer balance, credited by a refund to store credit or a
+--               manual admin adjustment.
+-- The wallet row carries the running balance and is the lock row: every movement takes it FOR UPDATE,
+-- writes the ledger row with the balance after the movement and updates the wallet in the same tx,
+-- so balance = Σ ledger.amount holds without re-summing the ledger on the hot path.
+--
+-- Redemption is a tender at checkout: CreateOrder debits the wallet (redeem) and records the amount
+-- on customer_order.store_credit_applied; the card is charged only the remainder. A cancelled or
+-- expired order gives the credit back (release). The liability lives on 2100 in the ledger: issued
+-- gift cards are credited there at sale, redemptions and refunds to credit move it.
+
+CREATE TABLE IF NOT EXISTS store_credit_wallet (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    kind ENUM('gift_card', 'account') NOT NULL,
+    code VARCHAR(32) NULL COMMENT 'Gift card code (GC-XXXX-XXXX-XXXX); NULL for account wallets',
+    account_email VARCHAR(255) NULL COMMENT 'Owner of an account wallet; NULL for gift cards',
+    currency VARCHAR(4) NOT NULL,
+    balance DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    initial_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT 'Face value of a gift card; 0 for account wallets',
+    status ENUM('active', 'disabled') NOT NULL DEFAULT 'active',
+    source_order_id INT NULL COMMENT 'Order that bought the gift card',
+    source_order_item_id INT NULL,
+    purchaser_email VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_store_credit_wallet_code (code),
+    UNIQUE KEY uq_store_credit_wallet_account (account_email, currency),
+    INDEX idx_store_credit_wallet_source_order (source_order_id),
+    CONSTRAINT fk_store_credit_wallet_source_order FOREIGN KEY (source_order_id) REFERENCES customer_order(id),
+    CONSTRAINT chk_store_credit_wallet_balance CHECK (balance >= 0),
+    CONSTRAINT chk_store_credit_wallet_kind CHECK (
+        (kind = 'gift_card' AND code IS NOT NULL AND account_email IS NULL)
+        OR (kind = 'account' AND account_email IS NOT NULL AND code IS NULL)
+    )
+) COMMENT 'Gift card and customer store-credit balances';
+
+CREATE TABLE IF NOT EXISTS store_credit_ledger (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    wallet_id INT NOT NULL,
+    entry_type ENUM('issue', 'redeem', 'release', 'refund_credit', 'adjustment') NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL COMMENT 'Signed: positive adds to the balance, negative spends it',
+    balance_after DECIMAL(10, 2) NOT NULL,
+    order_id INT NULL,
+    note VARCHAR(255) NULL,
+    created_by VARCHAR(255) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_store_credit_ledger_wallet (wallet_id, id),
+    INDEX idx_store_credit_ledger_order (order_id),
+    CONSTRAINT fk_store_credit_ledger_wallet FOREIGN KEY (wallet_id) REFERENCES store_credit_wallet(id),
+    CONSTRAINT fk_store_credit_ledger_order FOREIGN KEY (order_id) REFERENCES customer_order(id),
+    CONSTRAINT chk_store_credit_ledger_amount CHECK (amount <> 0)
+) COMMENT 'Append-only movements of store_credit_wallet balances';
+
+-- Products sold as digital gift cards. A gift card is an ordinary colourway (price per currency is
+-- its denomination) listed here; paying for it issues a code instead of a COGS-bearing sale.
+CREATE TABLE IF NOT EXISTS gift_card_product (
+    product_id INT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_gift_card_product_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE
+) COMMENT 'Colourways sold as gift cards';
+
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'customer_order' AND column_name = 'store_credit_applied') > 0,
+    'SELECT 1',
+    'ALTER TABLE customer_order ADD COLUMN store_credit_applied DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT ''Part of total_price paid with a gift card or store credit'''
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'customer_order' AND column_name = 'store_credit_wallet_id') > 0,
+    'SELECT 1',
+    'ALTER TABLE customer_order ADD COLUMN store_credit_wallet_id INT NULL COMMENT ''Wallet the applied credit was taken from'', ADD CONSTRAINT fk_customer_order_store_credit_wallet FOREIGN KEY (store_credit_wallet_id) REFERENCES store_credit_wallet(id)'
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- 2100: outstanding gift card and store-credit balances — credited when a gift card is sold or a
+-- refund goes to store credit, debited when credit is spent at checkout.
+INSERT INTO acct_account (code, name, section, statement, is_system)
+SELECT * FROM (SELECT
+    '2100' code, 'Gift Cards & Store Credit' name, 'liability' section, 'BS' statement, TRUE is_system
+) seed
+WHERE NOT EXISTS (SELECT 1 FROM acct_account a WHERE a.code = seed.code);
+
+-- +migrate Down
+-- The 2100 seed stays (journal lines may reference it), as with the 0190/0195 seeds.
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'customer_order' AND column_name = 'store_credit_wallet_id') > 0,
+    'ALTER TABLE customer_order DROP FOREIGN KEY fk_customer_order_store_credit_wallet, DROP COLUMN store_credit_wallet_id',
+    'SELECT 1'
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'customer_order' AND column_name = 'store_credit_applied') > 0,
+    'ALTER TABLE customer_order DROP COLUMN store_credit_applied',
+    'SELECT 1'
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+DROP TABLE IF EXISTS gift_card_product;
+DROP TABLE IF EXISTS store_credit_ledger;
+DROP TABLE IF EXISTS store_credit_wallet;
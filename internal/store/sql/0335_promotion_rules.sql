@@ -0,0 +1,133 @@
+-- +migrate Up
+
+-- Rule-based promotions. promo_code used to carry one percentage (discount) and a free-shipping flag;
+-- it now also says HOW the discount is computed and WHO may use it:
+--   discount_type         percentage   — discount % off the eligible lines (the pre-0335 behaviour);
+--                         fixed_amount — promo_code_currency.fixed_amount off the eligible lines, per
+--                                        currency (a code without an amount for the order currency
+--                                        does not apply);
+--                         buy_x_get_y  — in every group of buy_quantity + get_quantity eligible units,
+--                                        the get_quantity cheapest are discount % off (100 = free).
+--   min_tier              membership tier code the buyer must hold (entity.TierCanPurchase rule).
+--   max_uses              global cap across all orders; NULL = unlimited.
+--   max_uses_per_customer cap per buyer email; NULL = unlimited.
+--   stacking              exclusive — the only code on the order; stackable — combines with other
+--                         stackable codes, each applied to what the previous ones left.
+--   exclude_sale_items    keeps already-marked-down lines out of the eligible set.
+--   unique_codes_only     the row is a template: only its generated promo_unique_code rows redeem.
+-- Existing rows default to percentage / exclusive / unlimited, i.e. exactly what they did before.
+--
+-- promo_code_currency holds the per-currency fixed amount and minimum spend (on the eligible lines);
+-- promo_code_scope narrows the eligible lines to collections, tags or top categories (any match; no
+-- scope rows = whole cart). customer_order_promo is the redemption ledger: one row per code applied
+-- to an order with the discount it gave, released when the order is cancelled or expires. Usage caps
+-- count the unreleased rows.
+
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'promo_code' AND column_name = 'discount_type') > 0,
+    'SELECT 1',
+    'ALTER TABLE promo_code
+        ADD COLUMN discount_type ENUM(''percentage'', ''fixed_amount'', ''buy_x_get_y'') NOT NULL DEFAULT ''percentage'',
+        ADD COLUMN min_tier SMALLINT NOT NULL DEFAULT 0 COMMENT ''Membership tier code required to redeem; 0 = anyone'',
+        ADD COLUMN max_uses INT NULL COMMENT ''Global redemption cap; NULL = unlimited'',
+        ADD COLUMN max_uses_per_customer INT NULL COMMENT ''Per buyer email redemption cap; NULL = unlimited'',
+        ADD COLUMN buy_quantity INT NOT NULL DEFAULT 0 COMMENT ''buy_x_get_y: units paid in full per group'',
+        ADD COLUMN get_quantity INT NOT NULL DEFAULT 0 COMMENT ''buy_x_get_y: units discounted per group'',
+        ADD COLUMN stacking ENUM(''exclusive'', ''stackable'') NOT NULL DEFAULT ''exclusive'',
+        ADD COLUMN exclude_sale_items BOOLEAN NOT NULL DEFAULT FALSE,
+        ADD COLUMN unique_codes_only BOOLEAN NOT NULL DEFAULT FALSE COMMENT ''Only generated promo_unique_code rows redeem'''
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+CREATE TABLE IF NOT EXISTS promo_code_currency (
+    promo_id INT NOT NULL,
+    currency VARCHAR(4) NOT NULL,
+    fixed_amount DECIMAL(10, 2) NULL COMMENT 'fixed_amount promos: discount in this currency',
+    min_spend DECIMAL(10, 2) NULL COMMENT 'Eligible subtotal needed in this currency',
+    PRIMARY KEY (promo_id, currency),
+    CONSTRAINT fk_promo_code_currency_promo FOREIGN KEY (promo_id) REFERENCES promo_code(id) ON DELETE CASCADE,
+    CONSTRAINT chk_promo_code_currency_amounts CHECK (
+        (fixed_amount IS NULL OR fixed_amount > 0) AND (min_spend IS NULL OR min_spend >= 0)
+    )
+) COMMENT 'Per-currency fixed discount and minimum spend of a promo code';
+
+CREATE TABLE IF NOT EXISTS promo_code_scope (
+    promo_id INT NOT NULL,
+    scope_kind ENUM('collection', 'tag', 'top_category') NOT NULL,
+    scope_value VARCHAR(255) NOT NULL COMMENT 'Collection name, tag, or top category id',
+    PRIMARY KEY (promo_id, scope_kind, scope_value),
+    CONSTRAINT fk_promo_code_scope_promo FOREIGN KEY (promo_id) REFERENCES promo_code(id) ON DELETE CASCADE
+) COMMENT 'Lines a promo code applies to; none = whole cart';
+
+CREATE TABLE IF NOT EXISTS promo_unique_code (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    promo_id INT NOT NULL,
+    code VARCHAR(64) NOT NULL,
+    batch VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'Label of the generation run',
+    order_id INT NULL COMMENT 'Order holding the code; NULL = unredeemed',
+    redeemed_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_promo_unique_code_code (code),
+    INDEX idx_promo_unique_code_promo (promo_id, order_id),
+    CONSTRAINT fk_promo_unique_code_promo FOREIGN KEY (promo_id) REFERENCES promo_code(id) ON DELETE CASCADE,
+    CONSTRAINT fk_promo_unique_code_order FOREIGN KEY (order_id) REFERENCES customer_order(id)
+) COMMENT 'Single-use codes generated in bulk for a template promo_code';
+
+CREATE TABLE IF NOT EXISTS customer_order_promo (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    promo_id INT NULL COMMENT 'NULL once the promo_code row is deleted',
+    unique_code_id INT NULL,
+    code VARCHAR(255) NOT NULL COMMENT 'Code as entered (generated code for unique codes)',
+    buyer_email VARCHAR(255) NOT NULL DEFAULT '',
+    discount_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT 'Order currency',
+    free_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    released BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Order cancelled or expired; no longer counts towards caps',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_customer_order_promo_order (order_id),
+    INDEX idx_customer_order_promo_usage (promo_id, released, buyer_email),
+    CONSTRAINT fk_customer_order_promo_order FOREIGN KEY (order_id) REFERENCES customer_order(id),
+    CONSTRAINT fk_customer_order_promo_promo FOREIGN KEY (promo_id) REFERENCES promo_code(id) ON DELETE SET NULL,
+    CONSTRAINT fk_customer_order_promo_unique FOREIGN KEY (unique_code_id) REFERENCES promo_unique_code(id) ON DELETE SET NULL
+) COMMENT 'Promo codes applied to an order and the discount each gave';
+
+-- The discount amount joins the 0123 snapshot: promo_discount_pct is now the EFFECTIVE percentage of
+-- the subtotal (a fixed or buy-x-get-y discount has no configured one), so the metrics and the
+-- accounting 4030 reconstruction keep working unchanged.
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'customer_order' AND column_name = 'promo_discount_amount') > 0,
+    'SELECT 1',
+    'ALTER TABLE customer_order ADD COLUMN promo_discount_amount DECIMAL(10, 2) NULL COMMENT ''Total promo discount in the order currency, snapshotted at apply time'''
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- +migrate Down
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'customer_order' AND column_name = 'promo_discount_amount') > 0,
+    'ALTER TABLE customer_order DROP COLUMN promo_discount_amount',
+    'SELECT 1'
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+DROP TABLE IF EXISTS customer_order_promo;
+DROP TABLE IF EXISTS promo_unique_code;
+DROP TABLE IF EXISTS promo_code_scope;
+DROP TABLE IF EXISTS promo_code_currency;
+
+SET @sql = IF(
+    (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'promo_code' AND column_name = 'discount_type') > 0,
+    'ALTER TABLE promo_code
+        DROP COLUMN discount_type, DROP COLUMN min_tier, DROP COLUMN max_uses, DROP COLUMN max_uses_per_customer,
+        DROP COLUMN buy_quantity, DROP COLUMN get_quantity, DROP COLUMN stacking, DROP COLUMN exclude_sale_items,
+        DROP COLUMN unique_codes_only',
+    'SELECT 1'
+);
+PREPARE stmt FROM @sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
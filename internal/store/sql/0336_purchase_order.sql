@@ -0,0 +1,111 @@
+-- +migrate Up
+
+-- Material purchase orders. Until now a receipt was the only purchase document: quantity, price and a
+-- free expected_at stamped on the movement (Phase 9 — "lateness without a PO entity"). A purchase order
+-- records what was ASKED of a supplier before anything arrives, so "what is on its way" and "what did
+-- the supplier short us" become queries instead of memory.
+--
+-- A receipt against a PO is still an ordinary purchase receipt (material_stock_movement 'receipt'); it
+-- additionally names the PO line it delivers. The accounting worker posts it exactly as before
+-- (Dr 1110 / Cr 2010 tagged with the supplier), so the payable is the RECEIVED value at the PO price.
+-- purchase_order_line.received_qty is maintained under the PO row lock in the same transaction as the
+-- movement, and equals Σ quantity of the line's receipt movements.
+
+CREATE TABLE IF NOT EXISTS purchase_order (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    supplier_id INT NOT NULL,
+    status ENUM('draft', 'ordered', 'partially_received', 'received', 'closed', 'cancelled') NOT NULL DEFAULT 'draft',
+    currency VARCHAR(4) NOT NULL,
+    ordered_at DATE NULL COMMENT 'Day the order went to the supplier; NULL while a draft',
+    expected_at DATE NULL COMMENT 'Latest promised delivery day of the lines',
+    production_run_id INT NULL COMMENT 'Run whose material shortfall the order was suggested for',
+    supplier_ref VARCHAR(64) NULL COMMENT 'Supplier order confirmation number',
+    notes VARCHAR(512) NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_purchase_order_supplier (supplier_id, status),
+    INDEX idx_purchase_order_status (status, expected_at),
+    INDEX idx_purchase_order_run (production_run_id),
+    CONSTRAINT fk_purchase_order_supplier FOREIGN KEY (supplier_id) REFERENCES supplier(id),
+    CONSTRAINT fk_purchase_order_run FOREIGN KEY (production_run_id) REFERENCES production_run(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Material purchase orders';
+
+CREATE TABLE IF NOT EXISTS purchase_order_line (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    purchase_order_id INT NOT NULL,
+    material_id INT NOT NULL,
+    ordered_qty DECIMAL(12, 3) NOT NULL,
+    received_qty DECIMAL(12, 3) NOT NULL DEFAULT 0,
+    unit_price DECIMAL(12, 4) NULL COMMENT 'NET price per unit in the order currency; required to send the order',
+    expected_at DATE NULL,
+    note VARCHAR(255) NULL,
+    UNIQUE KEY uq_purchase_order_line_material (purchase_order_id, material_id),
+    INDEX idx_purchase_order_line_material (material_id),
+    CONSTRAINT fk_purchase_order_line_order FOREIGN KEY (purchase_order_id) REFERENCES purchase_order(id) ON DELETE CASCADE,
+    CONSTRAINT fk_purchase_order_line_material FOREIGN KEY (material_id) REFERENCES material(id),
+    CONSTRAINT chk_purchase_order_line_ordered CHECK (ordered_qty > 0),
+    CONSTRAINT chk_purchase_order_line_received CHECK (received_qty >= 0),
+    CONSTRAINT chk_purchase_order_line_price CHECK (unit_price IS NULL OR unit_price >= 0)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Materials ordered on a purchase order';
+
+-- --- material_stock_movement.purchase_order_line_id (nullable FK, guarded — mirrors 0201 supplier_id) ---
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_stock_movement' AND COLUMN_NAME = 'purchase_order_line_id');
+SET @sql := IF(@need_col,
+    'ALTER TABLE material_stock_movement ADD COLUMN purchase_order_line_id INT NULL', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_fk := (SELECT COUNT(*) = 0 FROM information_schema.TABLE_CONSTRAINTS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_stock_movement'
+      AND CONSTRAINT_NAME = 'fk_msm_purchase_order_line');
+SET @sql := IF(@need_fk,
+    'ALTER TABLE material_stock_movement
+        ADD CONSTRAINT fk_msm_purchase_order_line FOREIGN KEY (purchase_order_line_id) REFERENCES purchase_order_line(id)',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_idx := (SELECT COUNT(*) = 0 FROM information_schema.STATISTICS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_stock_movement' AND INDEX_NAME = 'idx_msm_purchase_order_line');
+SET @sql := IF(@need_idx,
+    'ALTER TABLE material_stock_movement ADD INDEX idx_msm_purchase_order_line (purchase_order_line_id)', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+-- --- supplier.lead_time_days: the supplier's promised order-to-door time, the default for materials
+-- that carry none of their own (material.lead_time_days wins). ---
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'supplier' AND COLUMN_NAME = 'lead_time_days');
+SET @sql := IF(@need_col,
+    'ALTER TABLE supplier ADD COLUMN lead_time_days INT NULL',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+-- +migrate Down
+SET @sql := IF(
+    (SELECT COUNT(*) FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'supplier' AND COLUMN_NAME = 'lead_time_days') > 0,
+    'ALTER TABLE supplier DROP COLUMN lead_time_days',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @sql := IF(
+    (SELECT COUNT(*) FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_stock_movement' AND COLUMN_NAME = 'purchase_order_line_id') > 0,
+    'ALTER TABLE material_stock_movement DROP FOREIGN KEY fk_msm_purchase_order_line, DROP INDEX idx_msm_purchase_order_line, DROP COLUMN purchase_order_line_id',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+DROP TABLE IF EXISTS purchase_order_line;
+DROP TABLE IF EXISTS purchase_order;
@@ -0,0 +1,78 @@
+-- +migrate Up
+
+-- Sales invoices. The JPK_V7M register so far had no document behind a row: a B2B sale was identified
+-- by its order uuid and a refund by "<uuid>-KOREKTA-yyyymmdd". A sales invoice is the document: a number
+-- from a gapless sequence per series and month, the buyer and the VAT regime snapshotted at issue, and
+-- lines computed from gross. A refund is never edited into the invoice; it is a correction invoice in the
+-- FK series holding the negative difference and pointing at the invoice it corrects.
+--
+-- Gaplessness: sales_invoice_counter holds the last number per (series, period). Issuing increments it
+-- with INSERT … ON DUPLICATE KEY UPDATE in the SAME transaction as the invoice insert, so the counter row
+-- stays locked until commit and a rolled-back issue gives its number back. Nothing deletes invoices.
+
+CREATE TABLE IF NOT EXISTS sales_invoice_counter (
+    series VARCHAR(8) NOT NULL COMMENT 'FV = invoice, FK = correction',
+    period CHAR(7) NOT NULL COMMENT 'YYYY-MM of the issue date',
+    last_seq INT NOT NULL,
+    PRIMARY KEY (series, period)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Last sales invoice number per series and month';
+
+CREATE TABLE IF NOT EXISTS sales_invoice (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    number VARCHAR(32) NOT NULL COMMENT 'series/yyyy/mm/sequence, e.g. FV/2026/07/00012',
+    kind ENUM('invoice', 'correction') NOT NULL,
+    series VARCHAR(8) NOT NULL,
+    period CHAR(7) NOT NULL,
+    seq INT NOT NULL,
+    order_id INT NOT NULL,
+    corrects_id INT NULL COMMENT 'Invoice a correction corrects; NULL on an invoice',
+    issue_date DATE NOT NULL,
+    sale_date DATE NOT NULL COMMENT 'Day the sale was paid (tax point)',
+    currency VARCHAR(4) NOT NULL,
+    pln_rate DECIMAL(12, 6) NULL COMMENT 'PLN per unit of currency, D-1 of sale_date; NULL on PLN invoices',
+    vat_regime VARCHAR(32) NOT NULL,
+    vat_rate_pct DECIMAL(5, 2) NOT NULL,
+    buyer_name VARCHAR(512) NOT NULL,
+    buyer_vat_id VARCHAR(32) NULL,
+    buyer_address VARCHAR(512) NOT NULL,
+    buyer_country CHAR(2) NOT NULL,
+    net DECIMAL(12, 2) NOT NULL,
+    vat DECIMAL(12, 2) NOT NULL,
+    gross DECIMAL(12, 2) NOT NULL,
+    reason VARCHAR(256) NULL COMMENT 'Correction reason (PrzyczynaKorekty)',
+    ksef_reference VARCHAR(64) NULL COMMENT 'KSeF number once submitted',
+    ksef_hash VARCHAR(64) NULL COMMENT 'SHA-256 (base64url) of the submitted document',
+    ksef_submitted_at DATETIME NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_sales_invoice_number (number),
+    UNIQUE KEY uq_sales_invoice_seq (series, period, seq),
+    INDEX idx_sales_invoice_order (order_id, kind),
+    INDEX idx_sales_invoice_corrects (corrects_id),
+    INDEX idx_sales_invoice_period (period, kind),
+    CONSTRAINT fk_sales_invoice_order FOREIGN KEY (order_id) REFERENCES customer_order(id),
+    CONSTRAINT fk_sales_invoice_corrects FOREIGN KEY (corrects_id) REFERENCES sales_invoice(id),
+    CONSTRAINT chk_sales_invoice_corrects CHECK ((kind = 'correction') = (corrects_id IS NOT NULL))
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Sales invoices and their corrections';
+
+CREATE TABLE IF NOT EXISTS sales_invoice_line (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    invoice_id INT NOT NULL,
+    position INT NOT NULL,
+    kind ENUM('item', 'shipping', 'adjustment') NOT NULL,
+    order_item_id INT NULL,
+    name VARCHAR(512) NOT NULL,
+    quantity DECIMAL(12, 3) NOT NULL COMMENT 'Negative on a correction',
+    unit_gross DECIMAL(12, 2) NOT NULL,
+    net DECIMAL(12, 2) NOT NULL,
+    vat DECIMAL(12, 2) NOT NULL,
+    gross DECIMAL(12, 2) NOT NULL,
+    UNIQUE KEY uq_sales_invoice_line_position (invoice_id, position),
+    INDEX idx_sales_invoice_line_item (order_item_id),
+    CONSTRAINT fk_sales_invoice_line_invoice FOREIGN KEY (invoice_id) REFERENCES sales_invoice(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Lines of a sales invoice';
+
+-- +migrate Down
+DROP TABLE IF EXISTS sales_invoice_line;
+DROP TABLE IF EXISTS sales_invoice;
+DROP TABLE IF EXISTS sales_invoice_counter;
@@ -0,0 +1,88 @@
+-- +migrate Up
+
+-- Bank statement formats beyond the Revolut CSV and import-time auto-matching.
+--
+-- acct_bank_txn.source now also takes 'camt053' (ISO 20022 XML), 'mt940' (SWIFT) and the name of an
+-- operator-registered CSV mapping (acct_bank_csv_mapping.source). An imported line that settles exactly
+-- one open item lands in state 'matched' with match_kind / match_ref naming it: a receivable (ref = the
+-- order uuid), a supplier payable (ref = the supplier id, also stored as supplier_id so the posted
+-- payment is tagged for GetPayables) or a Stripe payout (ref = the payout id when quoted). The line is
+-- still posted by the operator; match_ref is what GetReceivables nets a posted receivable payment by.
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_bank_txn' AND COLUMN_NAME = 'match_kind');
+SET @sql := IF(@need_col,
+    'ALTER TABLE acct_bank_txn
+        ADD COLUMN match_kind VARCHAR(16) NULL AFTER ignore_reason,
+        ADD COLUMN match_ref VARCHAR(64) NULL AFTER match_kind,
+        ADD COLUMN supplier_id INT NULL AFTER match_ref',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_fk := (SELECT COUNT(*) = 0 FROM information_schema.TABLE_CONSTRAINTS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_bank_txn'
+      AND CONSTRAINT_NAME = 'fk_acct_bank_txn_supplier');
+SET @sql := IF(@need_fk,
+    'ALTER TABLE acct_bank_txn
+        ADD CONSTRAINT fk_acct_bank_txn_supplier FOREIGN KEY (supplier_id) REFERENCES supplier(id) ON DELETE SET NULL',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_chk := (SELECT COUNT(*) = 0 FROM information_schema.TABLE_CONSTRAINTS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_bank_txn'
+      AND CONSTRAINT_NAME = 'chk_acct_bank_txn_match_kind');
+SET @sql := IF(@need_chk,
+    'ALTER TABLE acct_bank_txn
+        ADD CONSTRAINT chk_acct_bank_txn_match_kind
+        CHECK (match_kind IS NULL OR match_kind IN (''receivable'', ''payable'', ''stripe_payout''))',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_idx := (SELECT COUNT(*) = 0 FROM information_schema.STATISTICS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_bank_txn' AND INDEX_NAME = 'idx_acct_bank_txn_matched_entry');
+SET @sql := IF(@need_idx,
+    'ALTER TABLE acct_bank_txn ADD INDEX idx_acct_bank_txn_matched_entry (matched_entry_id, match_kind)', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+CREATE TABLE IF NOT EXISTS acct_bank_csv_mapping (
+    source              VARCHAR(16)  NOT NULL PRIMARY KEY COMMENT 'acct_bank_txn.source the mapped lines carry',
+    delimiter           VARCHAR(4)   NOT NULL DEFAULT ',',
+    skip_lines          INT          NOT NULL DEFAULT 0 COMMENT 'Preamble lines before the header row',
+    date_column         VARCHAR(128) NOT NULL,
+    date_layout         VARCHAR(64)  NOT NULL COMMENT 'Go time layout, e.g. 02.01.2006',
+    amount_column       VARCHAR(128) NULL COMMENT 'Signed amount; or the debit/credit pair',
+    debit_column        VARCHAR(128) NULL,
+    credit_column       VARCHAR(128) NULL,
+    decimal_comma       BOOLEAN      NOT NULL DEFAULT FALSE,
+    currency_column     VARCHAR(128) NULL COMMENT 'Or the fixed currency of a single-currency account',
+    currency            VARCHAR(4)   NULL,
+    description_columns VARCHAR(512) NOT NULL DEFAULT '' COMMENT 'Header names, newline-separated',
+    counterparty_column VARCHAR(128) NULL,
+    id_column           VARCHAR(128) NULL COMMENT 'Bank transaction id; NULL keys lines by content',
+    updated_at          TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT chk_acct_bank_csv_mapping_skip CHECK (skip_lines >= 0)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Column mappings of bank CSV exports';
+
+-- +migrate Down
+DROP TABLE IF EXISTS acct_bank_csv_mapping;
+
+SET @sql := IF(
+    (SELECT COUNT(*) FROM information_schema.COLUMNS
+        WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_bank_txn' AND COLUMN_NAME = 'match_kind') > 0,
+    'ALTER TABLE acct_bank_txn
+        DROP FOREIGN KEY fk_acct_bank_txn_supplier,
+        DROP CHECK chk_acct_bank_txn_match_kind,
+        DROP INDEX idx_acct_bank_txn_matched_entry,
+        DROP COLUMN supplier_id, DROP COLUMN match_ref, DROP COLUMN match_kind',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
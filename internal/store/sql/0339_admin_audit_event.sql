@@ -0,0 +1,30 @@
+-- +migrate Up
+
+-- Admin-wide audit trail. One row per call of a mutating admin.AdminService method (rbac write
+-- access, or an allowlisted write), written by the audit interceptor after the handler returned —
+-- failed and denied-by-handler calls included, so an attempt is as visible as a change. request and
+-- response are the call's protojson with secrets redacted and long values cut; response is only kept
+-- for successful calls. Rows older than audit.retention_days are purged by the retention worker.
+
+CREATE TABLE IF NOT EXISTS admin_audit_event (
+    id            BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    occurred_at   DATETIME(3)  NOT NULL COMMENT 'When the call started (UTC)',
+    actor         VARCHAR(255) NOT NULL COMMENT 'Admin username from the token; empty for a legacy token without one',
+    method        VARCHAR(128) NOT NULL COMMENT 'AdminService method name, without the service prefix',
+    section       VARCHAR(32)  NOT NULL COMMENT 'rbac section of the method; empty for allowlisted writes',
+    target_type   VARCHAR(64)  NOT NULL COMMENT 'Entity the method acts on, from its name (UpsertProduct → Product)',
+    target_id     VARCHAR(64)  NULL     COMMENT 'Id of the target taken from the request, or the response of a create',
+    status_code   VARCHAR(32)  NOT NULL COMMENT 'gRPC status code name, OK on success',
+    error_message VARCHAR(512) NULL,
+    duration_ms   INT          NOT NULL,
+    request       JSON         NOT NULL,
+    response      JSON         NULL,
+    INDEX idx_admin_audit_event_occurred (occurred_at),
+    INDEX idx_admin_audit_event_actor (actor, occurred_at),
+    INDEX idx_admin_audit_event_method (method, occurred_at),
+    INDEX idx_admin_audit_event_section (section, occurred_at),
+    INDEX idx_admin_audit_event_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT 'Audit trail of mutating admin RPCs';
+
+-- +migrate Down
+DROP TABLE IF EXISTS admin_audit_event;
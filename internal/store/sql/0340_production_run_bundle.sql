@@ -0,0 +1,113 @@
+-- +migrate Up
+
+-- ПАЧКИ И КУПОНЫ ЦЕХА: что происходит с кроем между настилом и приёмкой.
+--
+-- Три таблицы, и у каждой своя причина существовать отдельно.
+--
+-- production_run_operation — СНИМОК операций тех-карты на момент генерации пачек. Операции карты
+-- full-replace на каждом сохранении карты (id пересоздаются), поэтому ссылаться на
+-- tech_card_operation нельзя: первое же сохранение карты осиротило бы все сканирования. Снимок
+-- держит то, что было напечатано на купонах, и ровно это и сканируют. seq — порядковый номер
+-- операции в снимке (1..n), operation_number — номер, который технолог дал шагу (может
+-- отсутствовать).
+--
+-- production_run_bundle — пачка: одна стопка одного размера с одной укладки раскладки настила,
+-- слои ply_from..ply_to. Слои, а не просто количество, потому что пачка обязана держать детали с
+-- соседних слоёв вместе (оттенок рулона). Идентичность настила и секции — КЛЮЧИ (lay_key,
+-- section_key), снимком, как bom_line_key в 0281: настил можно удалить, а напечатанная пачка
+-- должна уметь его назвать. lay_id — SET NULL по той же причине.
+--
+-- production_run_bundle_scan — выполнение операции по пачке: кто (employee), когда, сколько.
+-- Одна операция по одной пачке выполняется один раз (uniq), исправление — удалить и отсканировать
+-- заново. FK на сотрудника RESTRICT: строка — запись о сделанной работе, и строка, потерявшая
+-- исполнителя, — это работа, которую некому приписать.
+--
+-- Перегенерация пачек прогона удаляет и операции, и пачки; сервер отказывает в ней, пока есть хоть
+-- одно сканирование (см. productionrun/bundles.go). CASCADE со сканирований на пачку поэтому
+-- срабатывает только вместе с удалением прогона.
+--
+-- Без CHARSET-клауза (прецедент 0252/0257/0281).
+
+CREATE TABLE IF NOT EXISTS production_run_operation (
+    id               INT PRIMARY KEY AUTO_INCREMENT,
+    run_id           INT NOT NULL,
+    seq              INT NOT NULL COMMENT 'порядок операции в снимке, 1..n',
+    operation_number INT NULL COMMENT 'номер шага в тех-карте; NULL = технолог не пронумеровал',
+    operation_type   VARCHAR(16) NOT NULL,
+    zone             VARCHAR(16) NOT NULL,
+    machine_type     VARCHAR(32) NULL,
+    smv              DECIMAL(10,4) NULL COMMENT 'стандартные минуты на одно изделие; NULL = норма не задана',
+    note             TEXT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_prop_seq UNIQUE (run_id, seq),
+    CONSTRAINT chk_prop_seq CHECK (seq >= 1),
+    CONSTRAINT chk_prop_smv CHECK (smv IS NULL OR smv >= 0),
+    CONSTRAINT fk_prop_run FOREIGN KEY (run_id) REFERENCES production_run (id) ON DELETE CASCADE
+) ENGINE=InnoDB COMMENT 'Снимок операций тех-карты, по которому напечатаны купоны пачек прогона';
+
+CREATE TABLE IF NOT EXISTS production_run_bundle (
+    id           INT PRIMARY KEY AUTO_INCREMENT,
+    run_id       INT NOT NULL,
+    bundle_no    INT NOT NULL COMMENT 'номер пачки внутри прогона, 1..n — то, что пишут мелом на пачке',
+    lay_id       INT NULL COMMENT 'настил; NULL = настил удалён после генерации, см. lay_key',
+    lay_key      CHAR(26) NOT NULL COMMENT 'СНИМОК ключа настила',
+    section_key  CHAR(26) NOT NULL COMMENT 'СНИМОК ключа секции настила',
+    colorway_id  INT NOT NULL,
+    size_id      INT NOT NULL,
+    stack_no     INT NOT NULL COMMENT 'номер укладки этого размера на раскладке, 1..n',
+    ply_from     INT NOT NULL,
+    ply_to       INT NOT NULL,
+    qty          INT NOT NULL COMMENT 'изделий в пачке = ply_to - ply_from + 1',
+    created_by   VARCHAR(255) NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_prb_no UNIQUE (run_id, bundle_no),
+    CONSTRAINT chk_prb_plies CHECK (ply_from >= 1 AND ply_to >= ply_from),
+    CONSTRAINT chk_prb_qty CHECK (qty = ply_to - ply_from + 1),
+    CONSTRAINT fk_prb_run FOREIGN KEY (run_id) REFERENCES production_run (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prb_lay FOREIGN KEY (lay_id) REFERENCES production_run_lay (id) ON DELETE SET NULL,
+    CONSTRAINT fk_prb_colorway FOREIGN KEY (colorway_id) REFERENCES product (id),
+    CONSTRAINT fk_prb_size FOREIGN KEY (size_id) REFERENCES size (id) ON DELETE RESTRICT,
+    INDEX idx_prb_lay (lay_id),
+    INDEX idx_prb_size (size_id)
+) ENGINE=InnoDB COMMENT 'Пачка кроя: стопка одного размера со слоёв ply_from..ply_to одной секции настила';
+
+CREATE TABLE IF NOT EXISTS production_run_bundle_scan (
+    id           INT PRIMARY KEY AUTO_INCREMENT,
+    bundle_id    INT NOT NULL,
+    operation_id INT NOT NULL,
+    employee_id  INT NOT NULL,
+    qty          INT NOT NULL COMMENT 'изделий выполнено; купон сканируется целиком, поэтому = qty пачки',
+    source       VARCHAR(16) NOT NULL COMMENT 'scan = купон из цеха, admin = записал мастер из панели',
+    recorded_by  VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'админ, если source = admin',
+    scanned_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_prbs_op UNIQUE (bundle_id, operation_id),
+    CONSTRAINT chk_prbs_qty CHECK (qty >= 1),
+    CONSTRAINT chk_prbs_source CHECK (source IN ('scan', 'admin')),
+    CONSTRAINT fk_prbs_bundle FOREIGN KEY (bundle_id) REFERENCES production_run_bundle (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prbs_operation FOREIGN KEY (operation_id) REFERENCES production_run_operation (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prbs_employee FOREIGN KEY (employee_id) REFERENCES employee (id) ON DELETE RESTRICT,
+    INDEX idx_prbs_operation (operation_id),
+    INDEX idx_prbs_employee (employee_id, scanned_at)
+) ENGINE=InnoDB COMMENT 'Выполнение операции по пачке: кто, когда, сколько';
+
+-- +migrate Down
+
+-- Гвард первым, до DROP (прецедент 0281/0287): сканирования — запись о сделанной работе, и
+-- сносить их откатом молча нельзя.
+SET @have := (SELECT COUNT(*) FROM information_schema.TABLES
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'production_run_bundle_scan');
+SET @sql := IF(@have = 0, 'SELECT 0 INTO @blocking',
+    'SELECT COUNT(*) INTO @blocking FROM production_run_bundle_scan');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @sql := IF(@blocking = 0, 'SELECT 1',
+    CONCAT('SELECT `0340 Down blocked: ', @blocking, ' bundle scans would be destroyed`'));
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+DROP TABLE IF EXISTS production_run_bundle_scan;
+DROP TABLE IF EXISTS production_run_bundle;
+DROP TABLE IF EXISTS production_run_operation;
@@ -0,0 +1,192 @@
+-- +migrate Up
+
+-- СДЕЛЬНАЯ ОПЛАТА: зарплата швеи из того, что она реально сшила.
+--
+-- До сих пор зарплата сотрудника — это плоский шаблон OPEX (opex_recurring, category=salaries,
+-- employee_id), одна сумма в месяц. Для цеха это неправда: швее платят за операции, и факт
+-- выполнения операции у нас уже есть — сканирование купона пачки (production_run_bundle_scan,
+-- 0340) с нормой времени (smv) из снимка операций прогона.
+--
+-- employee_pay_period — ПЕРИОД оплаты сотрудника: с какого месяца (valid_from, первое число) и по
+-- какой (valid_to, первое число последнего месяца; NULL = открыт) он работает по сдельной схеме,
+-- в какой валюте и по каким ставкам. Периоды одного сотрудника не пересекаются (проверяет сервер).
+--   piece_rate — каждая операция оплачивается отдельно: по ставке за штуку, если для её вида
+--                работы (operation_work) есть ставка периода, иначе минуты по норме × ставка за
+--                минуту;
+--   efficiency — оплата за нормо-минуты: выработанные минуты × ставка за минуту; отработанные
+--                минуты обязательны, из них считается выработка (%).
+-- В обеих схемах guaranteed_rate_per_minute — ГАРАНТИЯ: не меньше, чем отработанные минуты ×
+-- гарантированная ставка.
+--
+-- employee_pay_period_rate — ставки за штуку по виду работы. Ключ — токен каталога работ (0329),
+-- под FK: ставка на несуществующий вид работы не сохранится.
+--
+-- production_run_operation.work — СНИМОК вида работы шага карты, рядом с smv (0340 его не брал).
+-- Без FK, как и остальной снимок: снимок держит то, что было напечатано.
+--
+-- payroll_payslip / payroll_payslip_line — расчётный листок сотрудника за месяц. Черновик
+-- пересчитывается сколько угодно; утверждённый заморожен — строки держат СНИМОК схемы, ставок и
+-- количеств, и правка ставок или сканирований задним числом его не меняет (сервер отказывает в
+-- записи и удалении сканирований утверждённого месяца). Утверждённый листок проводится в журнал
+-- (source_type payroll, Dr 6330 / Cr 2030) и ЗАМЕНЯЕТ плоскую строку OPEX этого сотрудника за этот
+-- месяц: материализация шаблона зарплаты пропускает месяцы, покрытые периодом или листком.
+--
+-- Без CHARSET-клауза (прецедент 0252/0257/0281/0340).
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'production_run_operation' AND COLUMN_NAME = 'work');
+SET @sql := IF(@need_col,
+    'ALTER TABLE production_run_operation
+        ADD COLUMN work VARCHAR(32) COLLATE utf8mb4_bin NULL COMMENT ''СНИМОК токена operation_work; NULL = вид работы не указан'' AFTER machine_type',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+CREATE TABLE IF NOT EXISTS employee_pay_period (
+    id                         INT PRIMARY KEY AUTO_INCREMENT,
+    employee_id                INT NOT NULL,
+    scheme                     VARCHAR(16) NOT NULL,
+    valid_from                 DATE NOT NULL COMMENT 'первое число первого месяца периода',
+    valid_to                   DATE NULL COMMENT 'первое число последнего месяца; NULL = период открыт',
+    currency                   CHAR(3) NOT NULL,
+    rate_per_minute            DECIMAL(12,4) NULL COMMENT 'за нормо-минуту; обязательна для efficiency',
+    guaranteed_rate_per_minute DECIMAL(12,4) NULL COMMENT 'гарантия за отработанную минуту; NULL = без гарантии',
+    note                       VARCHAR(255) NULL,
+    created_by                 VARCHAR(255) NOT NULL DEFAULT '',
+    created_at                 TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at                 TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_epp_from UNIQUE (employee_id, valid_from),
+    CONSTRAINT chk_epp_scheme CHECK (scheme IN ('piece_rate', 'efficiency')),
+    CONSTRAINT chk_epp_from CHECK (DAYOFMONTH(valid_from) = 1),
+    CONSTRAINT chk_epp_to CHECK (valid_to IS NULL OR (DAYOFMONTH(valid_to) = 1 AND valid_to >= valid_from)),
+    CONSTRAINT chk_epp_rates CHECK ((rate_per_minute IS NULL OR rate_per_minute >= 0)
+        AND (guaranteed_rate_per_minute IS NULL OR guaranteed_rate_per_minute >= 0)),
+    CONSTRAINT chk_epp_efficiency CHECK (scheme <> 'efficiency' OR rate_per_minute IS NOT NULL),
+    CONSTRAINT fk_epp_employee FOREIGN KEY (employee_id) REFERENCES employee (id) ON DELETE RESTRICT
+) ENGINE=InnoDB COMMENT 'Период сдельной оплаты сотрудника: схема, валюта, ставки';
+
+CREATE TABLE IF NOT EXISTS employee_pay_period_rate (
+    period_id      INT NOT NULL,
+    work_token     VARCHAR(32) COLLATE utf8mb4_bin NOT NULL,
+    rate_per_piece DECIMAL(12,4) NOT NULL,
+    PRIMARY KEY (period_id, work_token),
+    CONSTRAINT chk_eppr_rate CHECK (rate_per_piece >= 0),
+    CONSTRAINT fk_eppr_period FOREIGN KEY (period_id) REFERENCES employee_pay_period (id) ON DELETE CASCADE,
+    CONSTRAINT fk_eppr_work FOREIGN KEY (work_token) REFERENCES operation_work (token)
+) ENGINE=InnoDB COMMENT 'Ставка за штуку по виду работы в периоде оплаты';
+
+CREATE TABLE IF NOT EXISTS payroll_payslip (
+    id                         INT PRIMARY KEY AUTO_INCREMENT,
+    employee_id                INT NOT NULL,
+    month                      DATE NOT NULL COMMENT 'первое число месяца',
+    period_id                  INT NULL COMMENT 'период, по которому считали; NULL = период удалён, снимок ниже',
+    scheme                     VARCHAR(16) NOT NULL COMMENT 'СНИМОК схемы периода',
+    currency                   CHAR(3) NOT NULL,
+    rate_per_minute            DECIMAL(12,4) NULL,
+    guaranteed_rate_per_minute DECIMAL(12,4) NULL,
+    status                     VARCHAR(16) NOT NULL DEFAULT 'draft',
+    attended_minutes           DECIMAL(10,2) NULL COMMENT 'отработанные минуты из табеля; NULL = не введены',
+    standard_minutes           DECIMAL(14,4) NOT NULL DEFAULT 0 COMMENT 'выработанные нормо-минуты (qty × smv)',
+    piece_earnings             DECIMAL(14,2) NOT NULL DEFAULT 0,
+    guaranteed_pay             DECIMAL(14,2) NULL,
+    adjustment                 DECIMAL(14,2) NOT NULL DEFAULT 0 COMMENT 'ручная корректировка, ±',
+    adjustment_note            VARCHAR(255) NULL,
+    gross                      DECIMAL(14,2) NOT NULL DEFAULT 0,
+    gross_base                 DECIMAL(14,2) NULL COMMENT 'gross в базовой валюте по курсу конца месяца; ставится при утверждении',
+    unpriced_qty               INT NOT NULL DEFAULT 0 COMMENT 'изделий без ставки и без нормы — не оплачены, показаны',
+    computed_by                VARCHAR(255) NOT NULL DEFAULT '',
+    computed_at                TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    approved_by                VARCHAR(255) NULL,
+    approved_at                TIMESTAMP NULL,
+    created_at                 TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at                 TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_pp_employee_month UNIQUE (employee_id, month),
+    CONSTRAINT chk_pp_month CHECK (DAYOFMONTH(month) = 1),
+    CONSTRAINT chk_pp_scheme CHECK (scheme IN ('piece_rate', 'efficiency')),
+    CONSTRAINT chk_pp_status CHECK (status IN ('draft', 'approved')),
+    CONSTRAINT chk_pp_gross CHECK (gross >= 0),
+    CONSTRAINT chk_pp_approved CHECK (status <> 'approved' OR (approved_at IS NOT NULL AND gross_base IS NOT NULL)),
+    CONSTRAINT fk_pp_employee FOREIGN KEY (employee_id) REFERENCES employee (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_pp_period FOREIGN KEY (period_id) REFERENCES employee_pay_period (id) ON DELETE SET NULL,
+    INDEX idx_pp_month_status (month, status)
+) ENGINE=InnoDB COMMENT 'Расчётный листок сдельщика за месяц';
+
+CREATE TABLE IF NOT EXISTS payroll_payslip_line (
+    id               INT PRIMARY KEY AUTO_INCREMENT,
+    payslip_id       INT NOT NULL,
+    run_id           INT NOT NULL COMMENT 'прогон; без FK — листок переживает прогон',
+    operation_seq    INT NOT NULL,
+    operation_type   VARCHAR(16) NOT NULL,
+    work             VARCHAR(32) COLLATE utf8mb4_bin NULL,
+    basis            VARCHAR(8) NOT NULL COMMENT 'piece = ставка за штуку, minute = норма × ставка за минуту, unpriced = не оплачено',
+    qty              INT NOT NULL,
+    bundles          INT NOT NULL,
+    smv              DECIMAL(10,4) NULL,
+    standard_minutes DECIMAL(14,4) NULL,
+    rate             DECIMAL(12,4) NULL,
+    amount           DECIMAL(14,2) NOT NULL DEFAULT 0,
+    CONSTRAINT uniq_ppl_op UNIQUE (payslip_id, run_id, operation_seq),
+    CONSTRAINT chk_ppl_basis CHECK (basis IN ('piece', 'minute', 'unpriced')),
+    CONSTRAINT chk_ppl_qty CHECK (qty >= 1 AND bundles >= 1),
+    CONSTRAINT fk_ppl_payslip FOREIGN KEY (payslip_id) REFERENCES payroll_payslip (id) ON DELETE CASCADE
+) ENGINE=InnoDB COMMENT 'Строка расчётного листка: одна операция одного прогона';
+
+-- Extend chk_acct_entry_source_type (+payroll). This migration sorts LAST, so its list MUST be the
+-- UNION of every source type ever added (0189/0195/0196/0197/0201/0248 — mirrors
+-- entity.ValidAcctSourceTypes).
+SET @sql := IF((SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_journal_entry'
+      AND CONSTRAINT_NAME = 'chk_acct_entry_source_type') > 0,
+    'ALTER TABLE acct_journal_entry DROP CONSTRAINT chk_acct_entry_source_type', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @sql := IF((SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'acct_journal_entry'
+      AND CONSTRAINT_NAME = 'chk_acct_entry_source_type') = 0,
+    'ALTER TABLE acct_journal_entry ADD CONSTRAINT chk_acct_entry_source_type CHECK (source_type IN (
+        ''order_sale'',''order_refund'',
+        ''order_prepayment'',''order_transit'',''order_delivered_sale'',
+        ''material_receipt'',''material_issue'',''material_return'',
+        ''material_writeoff'',''material_adjustment'',
+        ''production_receive'',''production_receive_reversal'',''opex_month'',
+        ''shipping_actual'',''dev_expense'',''payroll'',
+        ''depreciation'',''corp_tax'',
+        ''order_dispute'',
+        ''manual'',''reversal''))', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+-- +migrate Down
+
+-- Гвард первым (прецедент 0340): утверждённые листки — проведённая зарплата, и сносить их откатом
+-- молча нельзя. The CHECK narrowing is deliberately not reversed (posted payroll entries would
+-- violate it).
+SET @have := (SELECT COUNT(*) FROM information_schema.TABLES
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'payroll_payslip');
+SET @sql := IF(@have = 0, 'SELECT 0 INTO @blocking',
+    'SELECT COUNT(*) INTO @blocking FROM payroll_payslip WHERE status = ''approved''');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @sql := IF(@blocking = 0, 'SELECT 1',
+    CONCAT('SELECT `0341 Down blocked: ', @blocking, ' approved payslips would be destroyed`'));
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+DROP TABLE IF EXISTS payroll_payslip_line;
+DROP TABLE IF EXISTS payroll_payslip;
+DROP TABLE IF EXISTS employee_pay_period_rate;
+DROP TABLE IF EXISTS employee_pay_period;
+
+SET @has_col := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'production_run_operation' AND COLUMN_NAME = 'work');
+SET @sql := IF(@has_col, 'ALTER TABLE production_run_operation DROP COLUMN work', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
@@ -0,0 +1,154 @@
+-- +migrate Up
+
+-- КОНТРОЛЬ КАЧЕСТВА ПРИЁМКИ: выборочная инспекция партии по AQL перед тем, как годное уедет на склад.
+--
+-- До сих пор приёмка (0230/0246) записывала на строку два числа — годное и брак — и путь брака
+-- (scrap/seconds, 0264). Откуда брак, сколько изделий на самом деле смотрели и по какому правилу
+-- партию пропустили, не хранилось нигде. Здесь три таблицы и один жилец дома настроек цеха.
+--
+-- qc_defect_type — КАТАЛОГ ДЕФЕКТОВ. code — короткий код на бланке контролёра (SKIP_STITCH), он
+-- уникален и неизменяем по смыслу; name/description — представление. severity — класс:
+--   critical — ноль терпимости при любом плане (игла в шве, острый край);
+--   major    — изделие, которое покупатель вернёт;
+--   minor    — дефект, который продажу не останавливает.
+-- Связь с операцией — по ТОКЕНАМ, а не по id: operation_type (глагол шага, entity.OperationTypeTokens)
+-- и/или work (FK на operation_work.token, 0329). Операции тех-карты full-replace на каждом её
+-- сохранении (см. 0340), и ссылка на tech_card_operation.id осиротела бы первым же сохранением.
+-- Снятие пункта — RETIRE (retired_at), никогда DELETE: находки прошлых инспекций обязаны читаться.
+--
+-- production_run_qc_inspection — ИНСПЕКЦИЯ ПАРТИИ прогона. План выборки (кодовая буква, объём,
+-- Ac/Re для major и minor) считается при создании (internal/qcsampling, ISO 2859-1, нормальный
+-- контроль) и ХРАНИТСЯ СНИМКОМ: вердикт выносится по тому плану, по которому отбирали, и правка
+-- таблиц в коде не имеет права задним числом пересудить принятую партию. result — pending до записи
+-- находок, потом passed/failed; его НЕ выбирает человек, это вердикт плана.
+--
+-- receipt_id — приёмка, которая инспекцию ИСПОЛЬЗОВАЛА. Уникален: одна пройденная инспекция
+-- пропускает на склад ровно одну приёмку, иначе одна выборка пропустила бы сколько угодно партий.
+-- Ставится в транзакции приёмки, снимается её сторно (SET NULL на случай удаления строки приёмки,
+-- которого в норме не бывает).
+--
+-- production_run_qc_inspection_defect — НАХОДКИ: сколько изделий выборки несли дефект каталога.
+-- severity — СНИМОК класса на момент находки (вердикт вынесен по нему). run_operation_id — шаг
+-- снимка операций прогона (0340), к которому контролёр возвёл дефект; NULL = не возведён. Глагол и
+-- работа шага тоже СНИМКОМ: пока нет ни одного сканирования, пачки и снимок операций
+-- перегенерируются свободно (SET NULL на ссылке), а аналитика по операциям обязана пережить это.
+--
+-- workshop_settings.qc_inspection_required — ГЕЙТ. NULL/false = инспекция необязательна: приёмка
+-- без неё проводится как раньше, а переданная инспекция проверяется всегда. true = годное не
+-- проводится без пройденной инспекции. NULL значит «как было», по прецеденту run_readiness_blocking
+-- (0279): настройка, умолчание которой остановило бы цех в день выкатки, не создаётся.
+--
+-- Идемпотентность: каждый шаг под собственной проверкой в information_schema, по одному оператору на
+-- PREPARE (прод подключается без multiStatements). Без CHARSET-клауза (прецедент 0252/0257).
+
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'workshop_settings'
+      AND COLUMN_NAME = 'qc_inspection_required');
+SET @sql := IF(@need,
+    'ALTER TABLE workshop_settings
+        ADD COLUMN qc_inspection_required BOOLEAN NULL COMMENT ''годное проводится только с пройденной инспекцией; NULL = как false'' AFTER planning_efficiency_pct',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+CREATE TABLE IF NOT EXISTS qc_defect_type (
+    id             INT PRIMARY KEY AUTO_INCREMENT,
+    code           VARCHAR(32) COLLATE utf8mb4_bin NOT NULL COMMENT 'код на бланке контролёра',
+    name           VARCHAR(128) NOT NULL,
+    severity       VARCHAR(8) NOT NULL,
+    operation_type VARCHAR(16) COLLATE utf8mb4_bin NULL COMMENT 'глагол шага, который даёт дефект; NULL = не операционный',
+    work           VARCHAR(32) COLLATE utf8mb4_bin NULL COMMENT 'работа каталога 0329; NULL = любая работа глагола',
+    description    TEXT NULL,
+    retired_at     TIMESTAMP NULL COMMENT 'снят с бланка; находки прошлых инспекций читаются',
+    created_by     VARCHAR(255) NOT NULL DEFAULT '',
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_qcdt_code UNIQUE (code),
+    CONSTRAINT chk_qcdt_severity CHECK (severity IN ('critical', 'major', 'minor')),
+    CONSTRAINT fk_qcdt_work FOREIGN KEY (work) REFERENCES operation_work (token)
+) ENGINE=InnoDB COMMENT 'Каталог дефектов контроля качества';
+
+CREATE TABLE IF NOT EXISTS production_run_qc_inspection (
+    id               INT PRIMARY KEY AUTO_INCREMENT,
+    run_id           INT NOT NULL,
+    receipt_id       INT NULL COMMENT 'приёмка, использовавшая инспекцию; NULL = ещё не использована',
+    lot_size         INT NOT NULL COMMENT 'изделий в предъявленной партии',
+    inspection_level VARCHAR(3) NOT NULL COMMENT 'общий уровень контроля ISO 2859-1',
+    aql_major        VARCHAR(4) NOT NULL,
+    aql_minor        VARCHAR(4) NOT NULL,
+    code_letter      CHAR(1) NOT NULL,
+    sample_size      INT NOT NULL,
+    full_inspection  BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'план просил не меньше партии — смотрели всё',
+    major_accept     INT NOT NULL,
+    major_reject     INT NOT NULL,
+    minor_accept     INT NOT NULL,
+    minor_reject     INT NOT NULL,
+    result           VARCHAR(8) NOT NULL DEFAULT 'pending',
+    inspector        VARCHAR(255) NULL COMMENT 'кто смотрел, если не тот, кто записал',
+    note             TEXT NULL,
+    created_by       VARCHAR(255) NOT NULL DEFAULT '',
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_by       VARCHAR(255) NULL,
+    decided_at       TIMESTAMP NULL,
+    CONSTRAINT uniq_prqi_receipt UNIQUE (receipt_id),
+    CONSTRAINT chk_prqi_lot CHECK (lot_size >= 1 AND sample_size >= 1 AND sample_size <= lot_size),
+    CONSTRAINT chk_prqi_level CHECK (inspection_level IN ('I', 'II', 'III')),
+    CONSTRAINT chk_prqi_aql CHECK (aql_major IN ('2.5', '4.0') AND aql_minor IN ('2.5', '4.0')),
+    CONSTRAINT chk_prqi_numbers CHECK (major_reject = major_accept + 1 AND minor_reject = minor_accept + 1),
+    CONSTRAINT chk_prqi_result CHECK (result IN ('pending', 'passed', 'failed')),
+    CONSTRAINT chk_prqi_decided CHECK ((result = 'pending') = (decided_at IS NULL)),
+    CONSTRAINT fk_prqi_run FOREIGN KEY (run_id) REFERENCES production_run (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prqi_receipt FOREIGN KEY (receipt_id) REFERENCES production_run_receipt (id) ON DELETE SET NULL,
+    INDEX idx_prqi_run (run_id, created_at),
+    INDEX idx_prqi_decided (decided_at)
+) ENGINE=InnoDB COMMENT 'Выборочная инспекция партии прогона по AQL';
+
+CREATE TABLE IF NOT EXISTS production_run_qc_inspection_defect (
+    id               INT PRIMARY KEY AUTO_INCREMENT,
+    inspection_id    INT NOT NULL,
+    defect_type_id   INT NOT NULL,
+    severity         VARCHAR(8) NOT NULL COMMENT 'СНИМОК класса дефекта на момент находки',
+    run_operation_id INT NULL COMMENT 'шаг снимка операций прогона; NULL = не возведён к шагу или снимок перегенерирован',
+    operation_type   VARCHAR(16) COLLATE utf8mb4_bin NULL COMMENT 'СНИМОК глагола шага, к которому возведён дефект',
+    work             VARCHAR(32) COLLATE utf8mb4_bin NULL COMMENT 'СНИМОК работы шага, к которому возведён дефект',
+    qty              INT NOT NULL COMMENT 'изделий выборки с этим дефектом',
+    CONSTRAINT chk_prqid_qty CHECK (qty >= 1),
+    CONSTRAINT chk_prqid_severity CHECK (severity IN ('critical', 'major', 'minor')),
+    CONSTRAINT fk_prqid_inspection FOREIGN KEY (inspection_id) REFERENCES production_run_qc_inspection (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prqid_type FOREIGN KEY (defect_type_id) REFERENCES qc_defect_type (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_prqid_operation FOREIGN KEY (run_operation_id) REFERENCES production_run_operation (id) ON DELETE SET NULL,
+    INDEX idx_prqid_type (defect_type_id),
+    INDEX idx_prqid_operation (run_operation_id)
+) ENGINE=InnoDB COMMENT 'Находки инспекции: дефект каталога × количество изделий выборки';
+
+-- +migrate Down
+
+-- Гвард первым, до DROP (прецедент 0281/0340): решённые инспекции — запись о том, почему партию
+-- пропустили на склад, и сносить её откатом молча нельзя.
+SET @have := (SELECT COUNT(*) FROM information_schema.TABLES
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'production_run_qc_inspection');
+SET @sql := IF(@have = 0, 'SELECT 0 INTO @blocking',
+    'SELECT COUNT(*) INTO @blocking FROM production_run_qc_inspection WHERE result <> ''pending''');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+SET @sql := IF(@blocking = 0, 'SELECT 1',
+    CONCAT('SELECT `0343 Down blocked: ', @blocking, ' decided qc inspections would be destroyed`'));
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+DROP TABLE IF EXISTS production_run_qc_inspection_defect;
+DROP TABLE IF EXISTS production_run_qc_inspection;
+DROP TABLE IF EXISTS qc_defect_type;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'workshop_settings'
+      AND COLUMN_NAME = 'qc_inspection_required');
+SET @sql := IF(@have > 0,
+    'ALTER TABLE workshop_settings DROP COLUMN qc_inspection_required',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
@@ -0,0 +1,168 @@
+-- +migrate Up
+
+-- ПОДРЯД НА ПОШИВ (CMT — cut, make, trim): прогоны, которые шьёт внешняя фабрика.
+--
+-- До сих пор всё производство было своим: материал уходил в прогон движением issue_production, а
+-- всё, что платили на сторону, вписывалось руками строкой затрат. production_run.supplier_id (12.1)
+-- говорил «кто шьёт», но ни комплект, уехавший на фабрику, ни цена пошива, ни обещания фабрики не
+-- хранились нигде. Здесь четыре таблицы и два новых типа движения материала.
+--
+-- subcontractor — ПРОФИЛЬ ПОДРЯДЧИКА поверх каталога поставщиков (0201). Ключ — supplier_id: фабрика
+-- остаётся поставщиком (ей же идут счета, AP по поставщику), профиль добавляет только контакты и
+-- признак архива. Архив — retired, а не DELETE: соглашения и прогоны прошлого обязаны читаться.
+--
+-- cmt_price_agreement — ЦЕНА ПОШИВА за изделие модели (tech_card) у фабрики на период
+-- [valid_from, valid_to]. valid_to NULL = бессрочно. Соглашение не редактируется: новая цена — новая
+-- строка, старая закрывается датой. Прогон, назначенный фабрике, берёт цену СНИМКОМ (ниже).
+--
+-- production_run_subcontract — ПРОГОН НА ПОДРЯДЕ: фабрика, соглашение и снимок его цены/валюты на
+-- момент назначения (пересмотр соглашения не переоценивает уже отданную работу). portal_* — строка
+-- доступа фабричного портала (/api/cmt/{token}, скоуп 's'), та же механика epoch/revoke/expiry, что у
+-- наряда (0293). confirmed_* — что фабрика подтвердила через портал: дату отгрузки готового и
+-- количество. reconciled_* — СВЕРКА: сколько изделий вернулось, сколько комплекта израсходовано;
+-- после неё комплект закрыт, и финальная приёмка прогона становится возможной. Начисленный пошив —
+-- обычная строка production_run_cost kind='cmt' с supplier_id фабрики, а не ссылка отсюда: затраты
+-- прогона full-replace на каждом его сохранении, и id строки не пережил бы первой правки.
+--
+-- production_run_subcontract_kit — СТРОКИ СВЕРКИ КОМПЛЕКТА, по материалу: отгружено, возвращено,
+-- списано в прогон, сколько фабрика ЗАЯВИЛА израсходованным и расхождение. Пишутся один раз,
+-- сверкой; до неё баланс комплекта читается из движений.
+--
+-- consign_out / consign_return — движения КОНСИГНАЦИИ. Комплект, уехавший на фабрику, остаётся
+-- нашим материалом (счёт 1110 не трогается, проводки нет), но на нашей полке его больше нет: on_hand
+-- уменьшается, а стоимость уходит в «на консигнации» по цене движения. Остаток, вернувшийся с
+-- фабрики, приходит consign_return. Сверка переводит неиспользованный остаток консигнации в прогон
+-- обычным issue_production с on_hand_before = on_hand_after — только тогда материал становится WIP.
+--
+-- Идемпотентность: каждый шаг под собственной проверкой в information_schema, по одному оператору на
+-- PREPARE (прод подключается без multiStatements). Без CHARSET-клауза (прецедент 0252/0257).
+
+CREATE TABLE IF NOT EXISTS subcontractor (
+    supplier_id   INT PRIMARY KEY,
+    contact_name  VARCHAR(255) NULL,
+    contact_email VARCHAR(255) NULL,
+    contact_phone VARCHAR(64) NULL,
+    address       TEXT NULL,
+    note          TEXT NULL,
+    archived_at   TIMESTAMP NULL COMMENT 'снят с назначения; соглашения и прогоны прошлого читаются',
+    created_by    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_subcontractor_supplier FOREIGN KEY (supplier_id) REFERENCES supplier (id) ON DELETE RESTRICT
+) ENGINE=InnoDB COMMENT 'Профиль фабрики-подрядчика поверх каталога поставщиков';
+
+CREATE TABLE IF NOT EXISTS cmt_price_agreement (
+    id           INT PRIMARY KEY AUTO_INCREMENT,
+    supplier_id  INT NOT NULL,
+    tech_card_id INT NOT NULL,
+    unit_price   DECIMAL(12,4) NOT NULL COMMENT 'цена пошива одного изделия',
+    currency     CHAR(3) NOT NULL,
+    valid_from   DATE NOT NULL,
+    valid_to     DATE NULL COMMENT 'последний день действия; NULL = бессрочно',
+    note         TEXT NULL,
+    created_by   VARCHAR(255) NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_cmtpa_price CHECK (unit_price > 0),
+    CONSTRAINT chk_cmtpa_period CHECK (valid_to IS NULL OR valid_to >= valid_from),
+    CONSTRAINT fk_cmtpa_subcontractor FOREIGN KEY (supplier_id) REFERENCES subcontractor (supplier_id) ON DELETE RESTRICT,
+    CONSTRAINT fk_cmtpa_tech_card FOREIGN KEY (tech_card_id) REFERENCES tech_card (id) ON DELETE RESTRICT,
+    INDEX idx_cmtpa_lookup (supplier_id, tech_card_id, valid_from)
+) ENGINE=InnoDB COMMENT 'Цена пошива модели у фабрики на период';
+
+CREATE TABLE IF NOT EXISTS production_run_subcontract (
+    run_id              INT PRIMARY KEY,
+    supplier_id         INT NOT NULL,
+    agreement_id        INT NULL,
+    unit_price          DECIMAL(12,4) NOT NULL COMMENT 'СНИМОК цены соглашения на момент назначения',
+    currency            CHAR(3) NOT NULL,
+    portal_epoch        INT NOT NULL DEFAULT 1,
+    portal_expires_at   TIMESTAMP NULL,
+    portal_revoked_at   TIMESTAMP NULL,
+    confirmed_ship_date DATE NULL COMMENT 'дата отгрузки готового, подтверждённая фабрикой',
+    confirmed_qty       INT NULL COMMENT 'количество, подтверждённое фабрикой',
+    factory_note        TEXT NULL,
+    confirmed_at        TIMESTAMP NULL,
+    returned_good_qty   INT NULL COMMENT 'годных изделий вернулось, по сверке',
+    returned_defect_qty INT NULL COMMENT 'брака вернулось, по сверке',
+    reconcile_note      TEXT NULL,
+    reconciled_by       VARCHAR(255) NULL,
+    reconciled_at       TIMESTAMP NULL,
+    assigned_by         VARCHAR(255) NOT NULL DEFAULT '',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT chk_prsc_price CHECK (unit_price > 0),
+    CONSTRAINT chk_prsc_confirmed CHECK (confirmed_qty IS NULL OR confirmed_qty >= 0),
+    CONSTRAINT chk_prsc_returned CHECK ((reconciled_at IS NULL) = (returned_good_qty IS NULL)
+        AND (returned_good_qty IS NULL OR (returned_good_qty >= 0 AND returned_defect_qty >= 0))),
+    CONSTRAINT fk_prsc_run FOREIGN KEY (run_id) REFERENCES production_run (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prsc_subcontractor FOREIGN KEY (supplier_id) REFERENCES subcontractor (supplier_id) ON DELETE RESTRICT,
+    CONSTRAINT fk_prsc_agreement FOREIGN KEY (agreement_id) REFERENCES cmt_price_agreement (id) ON DELETE SET NULL,
+    INDEX idx_prsc_supplier (supplier_id)
+) ENGINE=InnoDB COMMENT 'Прогон, который шьёт фабрика-подрядчик';
+
+CREATE TABLE IF NOT EXISTS production_run_subcontract_kit (
+    run_id            INT NOT NULL,
+    material_id       INT NOT NULL,
+    shipped           DECIMAL(12,3) NOT NULL,
+    returned          DECIMAL(12,3) NOT NULL,
+    consumed          DECIMAL(12,3) NOT NULL COMMENT 'списано в прогон сверкой = shipped - returned',
+    declared_consumed DECIMAL(12,3) NULL COMMENT 'израсходовано по отчёту фабрики; NULL = не заявлено',
+    unit_cost_base    DECIMAL(12,4) NULL COMMENT 'цена консигнации, по которой списано',
+    CONSTRAINT pk_prsck PRIMARY KEY (run_id, material_id),
+    CONSTRAINT chk_prsck_qty CHECK (shipped >= 0 AND returned >= 0 AND consumed >= 0),
+    CONSTRAINT fk_prsck_run FOREIGN KEY (run_id) REFERENCES production_run_subcontract (run_id) ON DELETE CASCADE,
+    CONSTRAINT fk_prsck_material FOREIGN KEY (material_id) REFERENCES material (id)
+) ENGINE=InnoDB COMMENT 'Сверка комплекта прогона на подряде, по материалу';
+
+-- Расширение закрытого списка типов движения (0105). CHECK в MySQL не меняется на месте: снимаем и
+-- ставим заново, а повторный прогон узнаёт уже расширенный список по тексту клаузы.
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.CHECK_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'chk_msm_type'
+      AND CHECK_CLAUSE LIKE '%consign_out%');
+SET @sql := IF(@need,
+    'ALTER TABLE material_stock_movement DROP CHECK chk_msm_type',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+SET @sql := IF(@need,
+    'ALTER TABLE material_stock_movement ADD CONSTRAINT chk_msm_type CHECK (movement_type REGEXP
+        ''^(receipt|receipt_production|issue_production|issue_sample|return_production|return_sample|adjustment|writeoff|consign_out|consign_return)$'')',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+-- +migrate Down
+
+-- Гвард первым, до DROP (прецедент 0281/0340/0343): движения консигнации — записи о материале,
+-- который физически лежал на чужой фабрике, и откат, сузивший CHECK, не имеет права их осиротить.
+SET @blocking := (SELECT COUNT(*) FROM material_stock_movement
+    WHERE movement_type IN ('consign_out', 'consign_return'));
+SET @sql := IF(@blocking = 0, 'SELECT 1',
+    CONCAT('SELECT `0344 Down blocked: ', @blocking, ' consignment movements would violate the narrowed movement type check`'));
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.CHECK_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'chk_msm_type'
+      AND CHECK_CLAUSE LIKE '%consign_out%');
+SET @sql := IF(@have > 0,
+    'ALTER TABLE material_stock_movement DROP CHECK chk_msm_type',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+SET @sql := IF(@have > 0,
+    'ALTER TABLE material_stock_movement ADD CONSTRAINT chk_msm_type CHECK (movement_type REGEXP
+        ''^(receipt|receipt_production|issue_production|issue_sample|return_production|return_sample|adjustment|writeoff)$'')',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+DROP TABLE IF EXISTS production_run_subcontract_kit;
+DROP TABLE IF EXISTS production_run_subcontract;
+DROP TABLE IF EXISTS cmt_price_agreement;
+DROP TABLE IF EXISTS subcontractor;
@@ -0,0 +1,260 @@
+-- +migrate Up
+
+-- СКЛАДСКИЕ ЛОКАЦИИ: где физически лежит готовое изделие и материал.
+--
+-- До сих пор остаток был одним числом: product_size.quantity на вариант, material_stock.on_hand и
+-- material_lot.remaining_qty на материал и партию. Студия, склад, шоурум, поп-ап и фабрика видели
+-- один и тот же остаток, и ни перемещение между ними, ни то, откуда собирать заказ, не записывалось.
+--
+-- МОДЕЛЬ ОСТАТКА. Итоговые числа НЕ переезжают: продажа, возврат, приёмка прогона, выдача в
+-- производство по-прежнему пишут product_size.quantity / on_hand / remaining_qty и только их. Всё, что
+-- пишут эти пути, происходит в ЛОКАЦИИ ПО УМОЛЧАНИЮ (stock_location.is_default, ровно одна). Её
+-- количество НЕ хранится, а выводится:
+--
+--     по умолчанию = итог − Σ строк недефолтных локаций − Σ строк перемещений в пути
+--
+-- Хранятся только строки недефолтных локаций (product_size_location, material_location_stock,
+-- material_lot_location), и пишут их только перемещения и аллокация заказа. Так ни один из
+-- десятка старых путей записи остатка не нужно учить локациям, а сумма по локациям всегда равна
+-- итогу. Вывод может уйти в минус — когда продаж больше, чем лежит в локации по умолчанию; это не
+-- ошибка, а сигнал: заказ надо собирать из другой локации (аллокация ниже) или привезти товар.
+--
+-- stock_transfer / stock_transfer_line — ДОКУМЕНТ ПЕРЕМЕЩЕНИЯ: черновик → в пути → принят, или
+-- отменён. Отправка снимает количество с источника, приёмка кладёт в назначение; пока документ в
+-- пути, его строки не лежат ни в одной локации (и поэтому вычитаются из вывода по умолчанию).
+-- Строка — ЛИБО вариант (product_size_id), ЛИБО материал (material_id, с необязательной партией).
+--
+-- stock_order_allocation — из какой локации собирается оплаченный заказ. Аллокация в недефолтную
+-- локацию списывает её строки (продажа уже уменьшила итог при оплате, и без списания товар числился
+-- бы в обеих локациях); аллокация в локацию по умолчанию ничего не пишет. order_fulfillment.location_id
+-- — та же локация на карточке доски сборки.
+--
+-- product_stock_change_history.location_id — в какой локации произошло движение. NULL у всех
+-- старых строк и у всех путей, не знающих локаций = локация по умолчанию. Строки перемещения
+-- (source 'location_transfer') несут количества ДО/ПОСЛЕ этой локации, а не итога — как grade (0249)
+-- делает движения B-сорта отдельным потоком журнала.
+--
+-- Идемпотентность: каждый ALTER под собственной проверкой в information_schema, по одному оператору
+-- на PREPARE (прод подключается без multiStatements). Без CHARSET-клауза (прецедент 0252/0257).
+
+CREATE TABLE IF NOT EXISTS stock_location (
+    id          INT PRIMARY KEY AUTO_INCREMENT,
+    code        VARCHAR(32) NOT NULL COMMENT 'короткий код для документов и этикеток',
+    name        VARCHAR(255) NOT NULL,
+    kind        VARCHAR(16) NOT NULL,
+    is_default  BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'локация старых путей записи; её остаток выводится',
+    address     TEXT NULL,
+    note        TEXT NULL,
+    archived_at TIMESTAMP NULL COMMENT 'снята с использования; документы прошлого читаются',
+    created_by  VARCHAR(255) NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_stock_location_code UNIQUE (code),
+    CONSTRAINT chk_stock_location_kind CHECK (kind REGEXP '^(studio|warehouse|showroom|popup|factory)$'),
+    CONSTRAINT chk_stock_location_default CHECK (NOT (is_default AND archived_at IS NOT NULL))
+) ENGINE=InnoDB COMMENT 'Складская локация: студия, склад, шоурум, поп-ап, фабрика';
+
+-- Локация по умолчанию заводится миграцией и одна: всё, что было на остатке до 0345, лежит в ней.
+INSERT INTO stock_location (code, name, kind, is_default, created_by)
+SELECT 'MAIN', 'Main warehouse', 'warehouse', TRUE, 'migration'
+WHERE NOT EXISTS (SELECT 1 FROM stock_location WHERE is_default);
+
+CREATE TABLE IF NOT EXISTS product_size_location (
+    product_size_id INT NOT NULL,
+    location_id     INT NOT NULL,
+    quantity        INT NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT pk_psl PRIMARY KEY (product_size_id, location_id),
+    CONSTRAINT chk_psl_qty CHECK (quantity >= 0),
+    CONSTRAINT fk_psl_variant FOREIGN KEY (product_size_id) REFERENCES product_size (id) ON DELETE CASCADE,
+    CONSTRAINT fk_psl_location FOREIGN KEY (location_id) REFERENCES stock_location (id) ON DELETE RESTRICT,
+    INDEX idx_psl_location (location_id)
+) ENGINE=InnoDB COMMENT 'Остаток варианта в недефолтной локации';
+
+CREATE TABLE IF NOT EXISTS material_location_stock (
+    material_id INT NOT NULL,
+    location_id INT NOT NULL,
+    quantity    DECIMAL(12,3) NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT pk_mls PRIMARY KEY (material_id, location_id),
+    CONSTRAINT chk_mls_qty CHECK (quantity >= 0),
+    CONSTRAINT fk_mls_material FOREIGN KEY (material_id) REFERENCES material (id) ON DELETE CASCADE,
+    CONSTRAINT fk_mls_location FOREIGN KEY (location_id) REFERENCES stock_location (id) ON DELETE RESTRICT,
+    INDEX idx_mls_location (location_id)
+) ENGINE=InnoDB COMMENT 'Остаток материала в недефолтной локации';
+
+CREATE TABLE IF NOT EXISTS material_lot_location (
+    lot_id      INT NOT NULL,
+    location_id INT NOT NULL,
+    quantity    DECIMAL(12,3) NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT pk_mll PRIMARY KEY (lot_id, location_id),
+    CONSTRAINT chk_mll_qty CHECK (quantity >= 0),
+    CONSTRAINT fk_mll_lot FOREIGN KEY (lot_id) REFERENCES material_lot (id) ON DELETE CASCADE,
+    CONSTRAINT fk_mll_location FOREIGN KEY (location_id) REFERENCES stock_location (id) ON DELETE RESTRICT,
+    INDEX idx_mll_location (location_id)
+) ENGINE=InnoDB COMMENT 'Остаток партии материала в недефолтной локации (часть material_location_stock)';
+
+CREATE TABLE IF NOT EXISTS stock_transfer (
+    id               INT PRIMARY KEY AUTO_INCREMENT,
+    from_location_id INT NOT NULL,
+    to_location_id   INT NOT NULL,
+    status           VARCHAR(16) NOT NULL DEFAULT 'draft',
+    note             TEXT NULL,
+    created_by       VARCHAR(255) NOT NULL DEFAULT '',
+    dispatched_by    VARCHAR(255) NULL,
+    dispatched_at    TIMESTAMP NULL,
+    received_by      VARCHAR(255) NULL,
+    received_at      TIMESTAMP NULL,
+    cancelled_by     VARCHAR(255) NULL,
+    cancelled_at     TIMESTAMP NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT chk_stock_transfer_status CHECK (status REGEXP '^(draft|in_transit|received|cancelled)$'),
+    CONSTRAINT chk_stock_transfer_route CHECK (from_location_id <> to_location_id),
+    CONSTRAINT fk_stock_transfer_from FOREIGN KEY (from_location_id) REFERENCES stock_location (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_stock_transfer_to FOREIGN KEY (to_location_id) REFERENCES stock_location (id) ON DELETE RESTRICT,
+    INDEX idx_stock_transfer_status (status, created_at)
+) ENGINE=InnoDB COMMENT 'Документ перемещения остатка между локациями';
+
+CREATE TABLE IF NOT EXISTS stock_transfer_line (
+    id              INT PRIMARY KEY AUTO_INCREMENT,
+    transfer_id     INT NOT NULL,
+    product_size_id INT NULL,
+    material_id     INT NULL,
+    lot_id          INT NULL COMMENT 'партия материала; NULL = материал без партии',
+    quantity        DECIMAL(12,3) NOT NULL,
+    CONSTRAINT chk_stl_qty CHECK (quantity > 0),
+    CONSTRAINT chk_stl_subject CHECK ((product_size_id IS NULL) <> (material_id IS NULL)
+        AND (lot_id IS NULL OR material_id IS NOT NULL)),
+    CONSTRAINT fk_stl_transfer FOREIGN KEY (transfer_id) REFERENCES stock_transfer (id) ON DELETE CASCADE,
+    CONSTRAINT fk_stl_variant FOREIGN KEY (product_size_id) REFERENCES product_size (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_stl_material FOREIGN KEY (material_id) REFERENCES material (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_stl_lot FOREIGN KEY (lot_id) REFERENCES material_lot (id) ON DELETE RESTRICT,
+    INDEX idx_stl_transfer (transfer_id)
+) ENGINE=InnoDB COMMENT 'Строка перемещения: вариант либо материал (с партией)';
+
+CREATE TABLE IF NOT EXISTS stock_order_allocation (
+    order_id        INT NOT NULL,
+    product_size_id INT NOT NULL,
+    location_id     INT NOT NULL,
+    quantity        INT NOT NULL,
+    allocated_by    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT pk_soa PRIMARY KEY (order_id, product_size_id),
+    CONSTRAINT chk_soa_qty CHECK (quantity > 0),
+    CONSTRAINT fk_soa_order FOREIGN KEY (order_id) REFERENCES customer_order (id) ON DELETE CASCADE,
+    CONSTRAINT fk_soa_variant FOREIGN KEY (product_size_id) REFERENCES product_size (id) ON DELETE RESTRICT,
+    CONSTRAINT fk_soa_location FOREIGN KEY (location_id) REFERENCES stock_location (id) ON DELETE RESTRICT,
+    INDEX idx_soa_location (location_id)
+) ENGINE=InnoDB COMMENT 'Из какой локации собирается оплаченный заказ, по варианту';
+
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'product_stock_change_history' AND COLUMN_NAME = 'location_id');
+SET @sql := IF(@need,
+    'ALTER TABLE product_stock_change_history ADD COLUMN location_id INT NULL COMMENT ''локация движения; NULL = по умолчанию (0345)''',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.TABLE_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'product_stock_change_history'
+      AND CONSTRAINT_NAME = 'fk_psch_location');
+SET @sql := IF(@need,
+    'ALTER TABLE product_stock_change_history ADD CONSTRAINT fk_psch_location FOREIGN KEY (location_id) REFERENCES stock_location (id) ON DELETE RESTRICT',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.STATISTICS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'product_stock_change_history' AND INDEX_NAME = 'idx_psch_location');
+SET @sql := IF(@need,
+    'ALTER TABLE product_stock_change_history ADD INDEX idx_psch_location (location_id, created_at)',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'order_fulfillment' AND COLUMN_NAME = 'location_id');
+SET @sql := IF(@need,
+    'ALTER TABLE order_fulfillment ADD COLUMN location_id INT NULL COMMENT ''локация сборки заказа; NULL = не аллоцирован (0345)''',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need := (SELECT COUNT(*) = 0 FROM information_schema.TABLE_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'order_fulfillment'
+      AND CONSTRAINT_NAME = 'fk_order_fulfillment_location');
+SET @sql := IF(@need,
+    'ALTER TABLE order_fulfillment ADD CONSTRAINT fk_order_fulfillment_location FOREIGN KEY (location_id) REFERENCES stock_location (id) ON DELETE RESTRICT',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+-- +migrate Down
+
+-- Гвард первым, до DROP (прецедент 0281/0340/0343): пока хоть что-то лежит в недефолтной локации или
+-- едет, откат молча «вернул» бы это в локацию по умолчанию, и физический остаток шоурума или фабрики
+-- исчез бы из учёта без следа.
+SET @blocking := (SELECT (SELECT COUNT(*) FROM product_size_location WHERE quantity > 0)
+    + (SELECT COUNT(*) FROM material_location_stock WHERE quantity > 0)
+    + (SELECT COUNT(*) FROM stock_transfer WHERE status = 'in_transit'));
+SET @sql := IF(@blocking = 0, 'SELECT 1',
+    CONCAT('SELECT `0345 Down blocked: ', @blocking, ' location stock rows or transfers in transit would be folded into the default location`'));
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'order_fulfillment'
+      AND CONSTRAINT_NAME = 'fk_order_fulfillment_location');
+SET @sql := IF(@have > 0,
+    'ALTER TABLE order_fulfillment DROP FOREIGN KEY fk_order_fulfillment_location',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'order_fulfillment' AND COLUMN_NAME = 'location_id');
+SET @sql := IF(@have > 0, 'ALTER TABLE order_fulfillment DROP COLUMN location_id', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS
+    WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'product_stock_change_history'
+      AND CONSTRAINT_NAME = 'fk_psch_location');
+SET @sql := IF(@have > 0,
+    'ALTER TABLE product_stock_change_history DROP FOREIGN KEY fk_psch_location',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.STATISTICS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'product_stock_change_history' AND INDEX_NAME = 'idx_psch_location');
+SET @sql := IF(@have > 0, 'ALTER TABLE product_stock_change_history DROP INDEX idx_psch_location', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @have := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'product_stock_change_history' AND COLUMN_NAME = 'location_id');
+SET @sql := IF(@have > 0, 'ALTER TABLE product_stock_change_history DROP COLUMN location_id', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+DROP TABLE IF EXISTS stock_order_allocation;
+DROP TABLE IF EXISTS stock_transfer_line;
+DROP TABLE IF EXISTS stock_transfer;
+DROP TABLE IF EXISTS material_lot_location;
+DROP TABLE IF EXISTS material_location_stock;
+DROP TABLE IF EXISTS product_size_location;
+DROP TABLE IF EXISTS stock_location;
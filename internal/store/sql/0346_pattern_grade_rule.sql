@@ -0,0 +1,44 @@
+-- +migrate Up
+
+-- ПРАВИЛА ГРАДАЦИИ ЛЕКАЛ: размеры деталей, построенные сервером из базового размера, а не
+-- нарисованные градировщиком в CAD и залитые по файлу на размер.
+--
+-- До сих пор градация жила только в табеле мер: tech_card.grade_base_size_id и tech_card_grade_rule
+-- (шаг на позицию размера для каждой мерки). Сами лекала каждого размера рисовались где-то ещё, и
+-- без них у детали не было площади по размеру — ни нормы расхода, ни себестоимости.
+--
+-- ПРАВИЛО ДВИГАЕТ ОДНУ КАРДИНАЛЬНУЮ ТОЧКУ базового контура на (dx, dy) см НА ШАГ РАЗМЕРА. Шаг —
+-- позиция размера в размерном ряду карточки минус позиция базового, тот же «сдвиг позиции», что
+-- множит правило табеля мер. Базовый размер — tech_card.grade_base_size_id, второго не заводим.
+-- Точки между двумя точками с правилом сдвигаются интерполяцией по длине контура (dxfpattern.Grade).
+--
+-- ТОЧКА АДРЕСУЕТСЯ НОМЕРОМ в контуре базового блока, как его читает сервер. Перезаливка базового
+-- листа может перенумеровать точки — тогда правило с номером за пределами контура отказывает
+-- градацию явно (finding grade_point_out_of_range), а не двигает чужую точку молча.
+--
+-- КЛЮЧ НЕСЁТ СКОУП ТКАНИ, как площади (0297): подкладочная версия детали — свой контур со своей
+-- нумерацией точек. Деталь — по line_key, по той же причине, что и там.
+--
+-- БЕЗ КЛАУЗЫ CHARSET (прецедент 0252/0257/0272/0280/0297).
+
+CREATE TABLE IF NOT EXISTS tech_card_pattern_grade_rule (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    tech_card_id INT NOT NULL,
+    scope_key VARCHAR(64) NOT NULL,
+    piece_line_key CHAR(26) NOT NULL,
+    -- Номер точки в контуре базового блока (с нуля).
+    point_no INT NOT NULL,
+    -- Сдвиг точки на один шаг размера, см.
+    dx_cm DECIMAL(8,3) NOT NULL DEFAULT 0.000,
+    dy_cm DECIMAL(8,3) NOT NULL DEFAULT 0.000,
+    updated_by VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'admin username',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_tcpgr_scope_piece_point UNIQUE (tech_card_id, scope_key, piece_line_key, point_no),
+    CONSTRAINT chk_tcpgr_point CHECK (point_no >= 0),
+    CONSTRAINT fk_tcpgr_tech_card FOREIGN KEY (tech_card_id) REFERENCES tech_card (id) ON DELETE CASCADE,
+    INDEX idx_tcpgr_card_scope (tech_card_id, scope_key)
+) ENGINE=InnoDB;
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS tech_card_pattern_grade_rule;
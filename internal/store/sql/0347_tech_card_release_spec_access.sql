@@ -0,0 +1,34 @@
+-- +migrate Up
+
+-- ДОСТУП К PDF-СПЕЦИФИКАЦИИ РЕЛИЗА ПО ССЫЛКЕ (/api/ts/{token}) — релизный близнец
+-- production_run_pack_access (0293). Файл спецификации собирается из снапшота tech_card_release при
+-- каждом открытии и нигде не хранится; хранится только право его открыть. Ссылку отправляют фабрике
+-- в мессенджер, и эта строка — единственное, чем её можно отозвать: epoch = epoch + 1 убивает все
+-- разосланные ссылки разом, не трогая сам релиз.
+--
+-- КЛЮЧ — id РЕЛИЗА, а не карточки. Ссылка обещает «Rev.N», и следующий релиз той же карточки — это
+-- другой документ с другой ссылкой: фабрика, получившая Rev.3, не должна молча начать видеть Rev.4.
+--
+-- ОТДЕЛЬНАЯ ТАБЛИЦА И ОТДЕЛЬНЫЙ СКОУП ТОКЕНА ('t', internal/patterntoken) по тому же доводу, что в
+-- 0293: id релиза, прогона и карточки живут в одном числовом диапазоне, и токен, принятый чужим
+-- эндпоинтом, отдал бы чужой документ под настоящей подписью.
+--
+-- В отличие от наряда строка заводится НЕ лениво на чтении, а явным действием «поделиться»
+-- (ShareTechCardReleaseSpecSheet): выпуск наружу спецификации с составом и поставщиками — решение
+-- человека, а не побочный эффект открытия релиза в админке. created_by — кто это решение принял.
+--
+-- БЕЗ КЛАУЗЫ CHARSET (прецедент 0252/0257/0272/0280/0297).
+
+CREATE TABLE IF NOT EXISTS tech_card_release_spec_access (
+    release_id INT NOT NULL PRIMARY KEY,
+    epoch INT NOT NULL DEFAULT 1,
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    revoked_at TIMESTAMP NULL DEFAULT NULL,
+    created_by VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'admin username',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_tcrsa_release FOREIGN KEY (release_id) REFERENCES tech_card_release (id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS tech_card_release_spec_access;
@@ -0,0 +1,164 @@
+-- +migrate Up
+
+-- ЛАБОРАТОРИЯ ЛОТА: оттеночная группа, усадка и плотность рулона, и настил из нескольких рулонов.
+--
+-- material_lot (0118/0269) знает, какой ширины рулон пришёл и какой у него dye lot (shade_code), но
+-- не знает того, что про рулон говорит лаборатория после приёмки:
+--
+--   shade_band — ОТТЕНОЧНАЯ ГРУППА (A, B, C…), в которую рулон положили при сравнении под светом.
+--     Это НЕ shade_code: shade_code — то, что напечатал поставщик, а группа — то, что увидел
+--     контролёр. Рулоны двух партий поставщика законно попадают в одну группу, а рулоны одной
+--     партии — в разные. Смешивать в одном настиле можно только рулоны одной группы: детали одного
+--     изделия, выкроенные из разных групп, сшиваются в изделие двух оттенков.
+--   shrinkage_warp_pct / shrinkage_weft_pct — УСАДКА после стирки/ВТО по основе и по утку, в
+--     процентах. Отрицательная — это вытягивание, и оно тоже бывает. Номинал артикула
+--     (material_fabric_attr.shrinkage_pct) — одно число на каталог; здесь — замер ЭТОГО рулона, и из
+--     него считается припуск на лекала.
+--   measured_gsm — фактическая плотность, г/м².
+--
+-- material_lot_lab_test — ИСТОРИЯ испытаний: рулон перепроверяют (повторная стирка, спорная
+-- группа), и прошлый результат — это след, а не мусор. Колонки на material_lot — ТЕКУЩИЙ результат
+-- (последний по tested_at), чтобы настил и отчёт наряда читали его одним джойном, а не выбирали
+-- последнюю строку истории на каждом чтении. Пишутся в одной транзакции с историей.
+--
+-- production_run_lay_lot — ДОПОЛНИТЕЛЬНЫЕ рулоны настила. production_run_lay.lot_id (0285) остаётся
+-- рулоном, по которому сверяется ширина (lay_lot_width); эта таблица — остальные рулоны, которые
+-- легли в тот же настил. Тот же приём, что в 0285: lot_id на SET NULL, NOT NULL-снимок lot_code,
+-- чтобы настил, потерявший рулон, всё ещё мог его НАЗВАТЬ.
+--
+-- Идемпотентно: каждый DDL под своей проверкой в information_schema; таблицы — IF NOT EXISTS.
+-- БЕЗ КЛАУЗЫ CHARSET (прецедент 0252/0257/0272/0280/0297).
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'shade_band');
+SET @sql := IF(@need_col,
+    'ALTER TABLE material_lot ADD COLUMN shade_band VARCHAR(16) NULL AFTER shade_code',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'shrinkage_warp_pct');
+SET @sql := IF(@need_col,
+    'ALTER TABLE material_lot ADD COLUMN shrinkage_warp_pct DECIMAL(5,2) NULL AFTER shade_band',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'shrinkage_weft_pct');
+SET @sql := IF(@need_col,
+    'ALTER TABLE material_lot ADD COLUMN shrinkage_weft_pct DECIMAL(5,2) NULL AFTER shrinkage_warp_pct',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'measured_gsm');
+SET @sql := IF(@need_col,
+    'ALTER TABLE material_lot ADD COLUMN measured_gsm DECIMAL(7,2) NULL AFTER shrinkage_weft_pct',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_col := (SELECT COUNT(*) = 0 FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'lab_tested_at');
+SET @sql := IF(@need_col,
+    'ALTER TABLE material_lot ADD COLUMN lab_tested_at TIMESTAMP NULL AFTER measured_gsm',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @need_chk := (SELECT COUNT(*) = 0 FROM information_schema.TABLE_CONSTRAINTS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot'
+      AND CONSTRAINT_NAME = 'chk_material_lot_lab');
+SET @sql := IF(@need_chk,
+    'ALTER TABLE material_lot ADD CONSTRAINT chk_material_lot_lab CHECK ((shrinkage_warp_pct IS NULL OR shrinkage_warp_pct BETWEEN -20 AND 50) AND (shrinkage_weft_pct IS NULL OR shrinkage_weft_pct BETWEEN -20 AND 50) AND (measured_gsm IS NULL OR measured_gsm > 0))',
+    'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+CREATE TABLE IF NOT EXISTS material_lot_lab_test (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    lot_id INT NOT NULL,
+    shade_band VARCHAR(16) NULL,
+    shrinkage_warp_pct DECIMAL(5,2) NULL,
+    shrinkage_weft_pct DECIMAL(5,2) NULL,
+    measured_gsm DECIMAL(7,2) NULL,
+    note VARCHAR(1000) NULL,
+    tested_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    tested_by VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'admin username',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_mllt_lot (lot_id, tested_at),
+    CONSTRAINT fk_mllt_lot FOREIGN KEY (lot_id) REFERENCES material_lot (id) ON DELETE CASCADE,
+    CONSTRAINT chk_mllt_shrinkage CHECK ((shrinkage_warp_pct IS NULL OR shrinkage_warp_pct BETWEEN -20 AND 50)
+        AND (shrinkage_weft_pct IS NULL OR shrinkage_weft_pct BETWEEN -20 AND 50)),
+    CONSTRAINT chk_mllt_gsm CHECK (measured_gsm IS NULL OR measured_gsm > 0),
+    CONSTRAINT chk_mllt_any CHECK (shade_band IS NOT NULL OR shrinkage_warp_pct IS NOT NULL
+        OR shrinkage_weft_pct IS NOT NULL OR measured_gsm IS NOT NULL)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS production_run_lay_lot (
+    lay_id INT NOT NULL,
+    position INT NOT NULL,
+    lot_id INT NULL,
+    lot_code VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'снимок кода рулона на момент привязки — переживает удаление лота',
+    PRIMARY KEY (lay_id, position),
+    INDEX idx_prll_lot (lot_id),
+    CONSTRAINT fk_prll_lay FOREIGN KEY (lay_id) REFERENCES production_run_lay (id) ON DELETE CASCADE,
+    CONSTRAINT fk_prll_lot FOREIGN KEY (lot_id) REFERENCES material_lot (id) ON DELETE SET NULL
+) ENGINE=InnoDB;
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS production_run_lay_lot;
+DROP TABLE IF EXISTS material_lot_lab_test;
+
+SET @has_chk := (SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot'
+      AND CONSTRAINT_NAME = 'chk_material_lot_lab');
+SET @sql := IF(@has_chk, 'ALTER TABLE material_lot DROP CONSTRAINT chk_material_lot_lab', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @has_col := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'lab_tested_at');
+SET @sql := IF(@has_col, 'ALTER TABLE material_lot DROP COLUMN lab_tested_at', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @has_col := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'measured_gsm');
+SET @sql := IF(@has_col, 'ALTER TABLE material_lot DROP COLUMN measured_gsm', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @has_col := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'shrinkage_weft_pct');
+SET @sql := IF(@has_col, 'ALTER TABLE material_lot DROP COLUMN shrinkage_weft_pct', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @has_col := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'shrinkage_warp_pct');
+SET @sql := IF(@has_col, 'ALTER TABLE material_lot DROP COLUMN shrinkage_warp_pct', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
+
+SET @has_col := (SELECT COUNT(*) FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'material_lot' AND COLUMN_NAME = 'shade_band');
+SET @sql := IF(@has_col, 'ALTER TABLE material_lot DROP COLUMN shade_band', 'SELECT 1');
+PREPARE s FROM @sql;
+EXECUTE s;
+DEALLOCATE PREPARE s;
@@ -0,0 +1,95 @@
+-- +migrate Up
+
+-- ПРАЙС-ЛИСТЫ РЫНКОВ И ПЛАНОВЫЕ УЦЕНКИ.
+--
+-- До сих пор каждая цена колорвея (product_price) вводилась руками на каждую валюту, а распродажа
+-- была sale_percentage, который кто-то ставил и снимал на каждом колорвее сам. Новый рынок или
+-- распродажа означали обойти весь каталог.
+--
+-- price_list — ПРАВИЛО ВЫВОДА цен одной валюты из базовой: курс из costing_fx_rate (его держит fxsync
+-- по ECB), наценка рынка и правило округления (окончание на 0 / 5 / 9 / .99; нулевая точность JPY/KRW
+-- берётся из валюты, а не из правила). Один лист на валюту: product_price хранит цену на (колорвей,
+-- валюта), и второй лист той же валюты писал бы ту же ячейку. Публикация листа ПЕРЕПИСЫВАЕТ
+-- product_price этой валюты для всех неархивных колорвеев с базовой ценой; вне публикации лист ничего
+-- не трогает, а ручная правка цены после публикации живёт до следующей публикации.
+--
+-- price_list_pin — цена колорвея, которую лист НЕ выводит, а берёт как есть (витринная позиция,
+-- договорная цена рынка). Публикация пишет её вместо выведенной.
+--
+-- markdown_event / markdown_event_product — ПЛАНОВАЯ УЦЕНКА: процент, окно [starts_at, ends_at) и
+-- список колорвеев. Воркер в начале окна ставит product.sale_percentage (то, из чего витрина и промо
+-- читают OnSale), запомнив прежнее значение, а в конце окна возвращает его — но только там, где
+-- sale_percentage всё ещё равен проценту уценки: значение, которое человек поменял руками во время
+-- акции, откат не перетирает (revert_skipped). Колорвей не может стоять в двух пересекающихся уценках.
+--
+-- Без CHARSET-клауза (прецедент 0252/0257).
+
+CREATE TABLE IF NOT EXISTS price_list (
+    id                  INT PRIMARY KEY AUTO_INCREMENT,
+    name                VARCHAR(255) NOT NULL,
+    market              VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'метка рынка для людей: JP, UK, EU…',
+    currency            VARCHAR(4) NOT NULL,
+    markup_pct          DECIMAL(6,2) NOT NULL DEFAULT 0 COMMENT 'наценка рынка поверх пересчёта по курсу',
+    rounding            VARCHAR(8) NOT NULL DEFAULT 'none',
+    note                TEXT NULL,
+    last_published_at   TIMESTAMP NULL,
+    last_published_by   VARCHAR(255) NOT NULL DEFAULT '',
+    last_published_rate DECIMAL(18,8) NULL COMMENT 'курс к базе, по которому опубликовано',
+    created_by          VARCHAR(255) NOT NULL DEFAULT '',
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_price_list_currency UNIQUE (currency),
+    CONSTRAINT chk_price_list_rounding CHECK (rounding REGEXP '^(none|end_0|end_5|end_9|end_99)$'),
+    CONSTRAINT chk_price_list_markup CHECK (markup_pct > -100 AND markup_pct <= 500)
+) ENGINE=InnoDB COMMENT 'Прайс-лист рынка: вывод цен одной валюты из базовой';
+
+CREATE TABLE IF NOT EXISTS price_list_pin (
+    price_list_id INT NOT NULL,
+    product_id    INT NOT NULL,
+    price         DECIMAL(10,2) NOT NULL,
+    created_by    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT pk_price_list_pin PRIMARY KEY (price_list_id, product_id),
+    CONSTRAINT chk_price_list_pin_price CHECK (price > 0),
+    CONSTRAINT fk_plp_list FOREIGN KEY (price_list_id) REFERENCES price_list (id) ON DELETE CASCADE,
+    CONSTRAINT fk_plp_product FOREIGN KEY (product_id) REFERENCES product (id) ON DELETE CASCADE,
+    INDEX idx_plp_product (product_id)
+) ENGINE=InnoDB COMMENT 'Закреплённая цена колорвея в прайс-листе';
+
+CREATE TABLE IF NOT EXISTS markdown_event (
+    id              INT PRIMARY KEY AUTO_INCREMENT,
+    name            VARCHAR(255) NOT NULL,
+    sale_percentage DECIMAL(5,2) NOT NULL,
+    starts_at       TIMESTAMP NOT NULL,
+    ends_at         TIMESTAMP NOT NULL,
+    status          VARCHAR(16) NOT NULL DEFAULT 'scheduled',
+    applied_at      TIMESTAMP NULL,
+    reverted_at     TIMESTAMP NULL,
+    note            TEXT NULL,
+    created_by      VARCHAR(255) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT chk_markdown_status CHECK (status REGEXP '^(scheduled|active|ended|cancelled)$'),
+    CONSTRAINT chk_markdown_pct CHECK (sale_percentage > 0 AND sale_percentage < 100),
+    CONSTRAINT chk_markdown_window CHECK (ends_at > starts_at),
+    INDEX idx_markdown_due (status, starts_at),
+    INDEX idx_markdown_end (status, ends_at)
+) ENGINE=InnoDB COMMENT 'Плановая уценка: процент и окно действия';
+
+CREATE TABLE IF NOT EXISTS markdown_event_product (
+    event_id             INT NOT NULL,
+    product_id           INT NOT NULL,
+    prev_sale_percentage DECIMAL(5,2) NULL COMMENT 'что стояло до начала уценки; NULL = ещё не применена',
+    revert_skipped       BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'процент поменяли руками во время акции — откат не тронул',
+    CONSTRAINT pk_markdown_event_product PRIMARY KEY (event_id, product_id),
+    CONSTRAINT fk_mep_event FOREIGN KEY (event_id) REFERENCES markdown_event (id) ON DELETE CASCADE,
+    CONSTRAINT fk_mep_product FOREIGN KEY (product_id) REFERENCES product (id) ON DELETE CASCADE,
+    INDEX idx_mep_product (product_id)
+) ENGINE=InnoDB COMMENT 'Колорвей в плановой уценке';
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS markdown_event_product;
+DROP TABLE IF EXISTS markdown_event;
+DROP TABLE IF EXISTS price_list_pin;
+DROP TABLE IF EXISTS price_list;
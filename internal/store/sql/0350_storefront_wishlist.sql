@@ -0,0 +1,40 @@
+-- +migrate Up
+
+-- ИЗБРАННОЕ ПОКУПАТЕЛЯ.
+--
+-- У аккаунта витрины были профиль, адреса и история заказов, но сохранить вещь было некуда —
+-- единственным сигналом интереса оставался анонимный product_waitlist (NotifyMe, по email и размеру).
+--
+-- storefront_wishlist_item — колорвей в избранном аккаунта, по желанию с размером (size_id NULL —
+-- «весь колорвей»). Гостевой список живёт у витрины и сливается сюда при входе. Один и тот же
+-- (колорвей, размер) у аккаунта один раз: size_key — сгенерированный COALESCE(size_id, 0), потому что
+-- UNIQUE с NULL-колонкой дубликатов не ловит.
+--
+-- seen_sale_percentage / seen_quantity — что воркер уведомлений видел в прошлый раз: процент скидки
+-- колорвея и остаток грейда A (размера или всего колорвея). Письмо уходит на ПЕРЕХОД: скидка выросла
+-- (новая или глубже), остаток с нуля стал положительным (рестокинг), остаток опустился из выше
+-- порога в (0, порог] (заканчивается). NULL — ещё не видел: первое наблюдение только запоминается,
+-- так что добавление в избранное вещи, которая уже на скидке, письма не шлёт.
+--
+-- Без CHARSET-клауза (прецедент 0252/0257).
+
+CREATE TABLE IF NOT EXISTS storefront_wishlist_item (
+    id                   INT PRIMARY KEY AUTO_INCREMENT,
+    account_id           INT NOT NULL,
+    product_id           INT NOT NULL,
+    size_id              INT NULL COMMENT 'NULL — весь колорвей',
+    size_key             INT AS (COALESCE(size_id, 0)) STORED,
+    seen_sale_percentage DECIMAL(5,2) NULL COMMENT 'скидка при прошлом наблюдении воркера',
+    seen_quantity        DECIMAL(10,3) NULL COMMENT 'остаток грейда A при прошлом наблюдении воркера',
+    created_at           TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_storefront_wishlist_item UNIQUE (account_id, product_id, size_key),
+    INDEX idx_storefront_wishlist_item_product (product_id),
+    INDEX idx_storefront_wishlist_item_created (created_at),
+    CONSTRAINT fk_storefront_wishlist_item_account FOREIGN KEY (account_id) REFERENCES storefront_account(id) ON DELETE CASCADE,
+    CONSTRAINT fk_storefront_wishlist_item_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE,
+    CONSTRAINT fk_storefront_wishlist_item_size FOREIGN KEY (size_id) REFERENCES size(id) ON DELETE CASCADE
+) ENGINE=InnoDB COMMENT 'Избранное аккаунта витрины';
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS storefront_wishlist_item;
@@ -0,0 +1,46 @@
+-- +migrate Up
+
+-- ПРОФИЛЬ ПОСАДКИ ПОКУПАТЕЛЯ («подобрать размер»).
+--
+-- Таблица размеров стиля (tech_card_size_measurement) уже уходила на витрину, но сопоставлять её с
+-- телом покупатель должен был сам. Рекомендатор размеров (internal/sizerec) берёт мерки тела или
+-- размер, который покупатель уже носит в другом стиле, считает свободу облегания против таблицы,
+-- калибрует её одобренными примерками на фит-моделях и поправляет по возвратам «не подошёл размер».
+--
+-- storefront_fit_profile — профиль на аккаунте: предпочтение посадки (close / regular / relaxed) и
+-- опорный вариант «ношу этот размер» (колорвей + размер; SET NULL, если вариант удалён — профиль
+-- остаётся, опора пропадает).
+--
+-- storefront_fit_measurement — мерки тела, разреженно и в миллиметрах, как model_measurement у
+-- фит-моделей: тот же словарь entity.BodyMeasurementName, так что тело покупателя и тело модели
+-- сравниваются напрямую. Это персональные данные — HardEraseAccount удаляет обе таблицы.
+--
+-- Без CHARSET-клауза (прецедент 0252/0257).
+
+CREATE TABLE IF NOT EXISTS storefront_fit_profile (
+    account_id           INT PRIMARY KEY,
+    fit_preference       VARCHAR(16) NOT NULL DEFAULT 'regular' COMMENT 'close | regular | relaxed',
+    reference_product_id INT NULL COMMENT 'колорвей, размер которого покупатель носит',
+    reference_size_id    INT NULL COMMENT 'размер, который покупатель носит',
+    updated_at           TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT chk_storefront_fit_profile_preference CHECK (fit_preference REGEXP '^(close|regular|relaxed)$'),
+    CONSTRAINT chk_storefront_fit_profile_reference_pair CHECK ((reference_product_id IS NULL) = (reference_size_id IS NULL)),
+    CONSTRAINT fk_storefront_fit_profile_account FOREIGN KEY (account_id) REFERENCES storefront_account(id) ON DELETE CASCADE,
+    CONSTRAINT fk_storefront_fit_profile_product FOREIGN KEY (reference_product_id) REFERENCES product(id) ON DELETE SET NULL,
+    CONSTRAINT fk_storefront_fit_profile_size FOREIGN KEY (reference_size_id) REFERENCES size(id) ON DELETE SET NULL
+) ENGINE=InnoDB COMMENT 'Профиль посадки аккаунта витрины';
+
+CREATE TABLE IF NOT EXISTS storefront_fit_measurement (
+    id                   INT PRIMARY KEY AUTO_INCREMENT,
+    account_id           INT NOT NULL,
+    measurement_name     VARCHAR(40) NOT NULL COMMENT 'ключ entity.BodyMeasurementName, напр. chest',
+    measurement_value_mm INT NOT NULL COMMENT 'мм',
+    CONSTRAINT uniq_storefront_fit_measurement UNIQUE (account_id, measurement_name),
+    CONSTRAINT chk_storefront_fit_measurement_positive CHECK (measurement_value_mm > 0),
+    CONSTRAINT fk_storefront_fit_measurement_account FOREIGN KEY (account_id) REFERENCES storefront_account(id) ON DELETE CASCADE
+) ENGINE=InnoDB COMMENT 'Мерки тела аккаунта витрины (мм), разреженно';
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS storefront_fit_measurement;
+DROP TABLE IF EXISTS storefront_fit_profile;
@@ -0,0 +1,80 @@
+-- +migrate Up
+
+-- ВОЗВРАТ БРОШЕННОГО ЧЕКАУТА.
+--
+-- Неоплаченный заказ истекает (ordercleanup), пре-ордерный PaymentIntent чистится
+-- (CleanupOrphanedPreOrderPaymentIntents), а покупателю никто не пишет. Теперь витрина запоминает
+-- корзину покупателя, давшего согласие на письма, — на ValidateOrderItemsInsert (email и галочка на
+-- шаге чекаута или email из токена) и на SubmitOrder (receive_promo_emails заказа), — а воркер
+-- checkoutrecovery шлёт серию напоминаний со ссылкой «вернуть корзину» и, на выбранном шаге,
+-- одноразовым промокодом.
+--
+-- checkout_recovery — одна запись на брошенный чекаут. Открытая запись у email одна: open_email —
+-- сгенерированный email для status = 'open' и NULL для закрытых, UNIQUE на нём держит «одну открытую
+-- на адрес», а закрытые не мешают. Новая активность того же адреса обновляет открытую запись (корзина
+-- заменяется, last_activity_at сдвигается), серия не начинается заново: steps_sent — сколько писем
+-- уже ушло, следующее уходит через задержку своего шага после last_activity_at.
+--
+-- Закрытие:
+--   converted  — покупка (оплаченный заказ на этот email после начала записи, или заказ order_id)
+--                после хотя бы одного письма; attributed_step — последнее письмо перед покупкой;
+--   suppressed — покупка до первого письма: серия не нужна, в конверсию не идёт;
+--   opted_out  — отказ по ссылке из письма или отписка от маркетинга;
+--   expired    — серия закончилась (или корзина опустела) и окно атрибуции прошло без покупки.
+--
+-- token — секрет ссылки «вернуть корзину» (и отказа от серии); restored_step — шаг письма, по чьей
+-- ссылке корзину впервые вернули. incentive_code — сгенерированный promo_unique_code (incentive_step —
+-- письмо, в котором он ушёл); его погашение видно по promo_unique_code.order_id.
+--
+-- checkout_recovery_item — строки корзины по варианту (product_size.id, R2), количество — как в
+-- запросе; цены и остатки перечитываются при отправке письма.
+--
+-- Без CHARSET-клауза (прецедент 0252/0257).
+
+CREATE TABLE IF NOT EXISTS checkout_recovery (
+    id                 INT PRIMARY KEY AUTO_INCREMENT,
+    token              CHAR(32) NOT NULL COMMENT 'секрет ссылки восстановления, hex',
+    email              VARCHAR(100) NOT NULL,
+    account_id         INT NULL COMMENT 'аккаунт витрины, если покупатель вошёл',
+    order_id           INT NULL COMMENT 'неоплаченный заказ SubmitOrder',
+    currency           VARCHAR(3) NOT NULL,
+    source             VARCHAR(16) NOT NULL COMMENT 'validate | submit',
+    status             VARCHAR(16) NOT NULL DEFAULT 'open' COMMENT 'open | converted | suppressed | opted_out | expired',
+    open_email         VARCHAR(100) AS (IF(status = 'open', email, NULL)) STORED,
+    steps_sent         TINYINT NOT NULL DEFAULT 0,
+    last_activity_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_sent_at       TIMESTAMP NULL,
+    incentive_code     VARCHAR(64) NULL,
+    incentive_step     TINYINT NULL COMMENT 'шаг письма с промокодом',
+    restored_at        TIMESTAMP NULL,
+    restored_step      TINYINT NULL COMMENT 'шаг письма, по ссылке которого корзину вернули впервые',
+    converted_order_id INT NULL,
+    attributed_step    TINYINT NULL COMMENT 'последнее письмо перед покупкой',
+    closed_at          TIMESTAMP NULL,
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_checkout_recovery_token UNIQUE (token),
+    CONSTRAINT uniq_checkout_recovery_open_email UNIQUE (open_email),
+    CONSTRAINT chk_checkout_recovery_source CHECK (source REGEXP '^(validate|submit)$'),
+    CONSTRAINT chk_checkout_recovery_status CHECK (status REGEXP '^(open|converted|suppressed|opted_out|expired)$'),
+    INDEX idx_checkout_recovery_email (email),
+    INDEX idx_checkout_recovery_created (created_at),
+    CONSTRAINT fk_checkout_recovery_account FOREIGN KEY (account_id) REFERENCES storefront_account(id) ON DELETE SET NULL,
+    CONSTRAINT fk_checkout_recovery_order FOREIGN KEY (order_id) REFERENCES customer_order(id) ON DELETE SET NULL,
+    CONSTRAINT fk_checkout_recovery_converted_order FOREIGN KEY (converted_order_id) REFERENCES customer_order(id) ON DELETE SET NULL
+) ENGINE=InnoDB COMMENT 'Брошенный чекаут и серия писем о нём';
+
+CREATE TABLE IF NOT EXISTS checkout_recovery_item (
+    recovery_id INT NOT NULL,
+    variant_id  INT NOT NULL COMMENT 'product_size.id',
+    quantity    DECIMAL(10,3) NOT NULL,
+    PRIMARY KEY (recovery_id, variant_id),
+    CONSTRAINT chk_checkout_recovery_item_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_checkout_recovery_item_recovery FOREIGN KEY (recovery_id) REFERENCES checkout_recovery(id) ON DELETE CASCADE,
+    CONSTRAINT fk_checkout_recovery_item_variant FOREIGN KEY (variant_id) REFERENCES product_size(id) ON DELETE CASCADE
+) ENGINE=InnoDB COMMENT 'Корзина брошенного чекаута';
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS checkout_recovery_item;
+DROP TABLE IF EXISTS checkout_recovery;
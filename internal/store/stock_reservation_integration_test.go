@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/cache"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/stockreserve"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// seedReservableVariant inserts one product with a single A-grade size of the given stock and
+// returns (productID, sizeID). Seeded via SQL like the stock adjust test.
+func seedReservableVariant(t *testing.T, ctx context.Context, s *MYSQLStore, stock int) (int, int) {
+	t.Helper()
+	exec := func(q string, args ...any) int64 {
+		res, err := testDB.ExecContext(ctx, q, args...)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return id
+	}
+
+	var sizeID int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 ORDER BY id LIMIT 1`).Scan(&sizeID))
+
+	mediaID, err := s.Media().AddMedia(ctx, &entity.MediaItem{
+		FullSizeMediaURL: "https://x/f.jpg", FullSizeWidth: 1, FullSizeHeight: 1,
+		ThumbnailMediaURL: "https://x/t.jpg", ThumbnailWidth: 1, ThumbnailHeight: 1,
+		CompressedMediaURL: "https://x/c.jpg", CompressedWidth: 1, CompressedHeight: 1,
+	})
+	require.NoError(t, err)
+	styleID := exec(`INSERT INTO tech_card (style_number, name) VALUES (CONCAT('AUTO-', UUID_SHORT()), 'S25')`)
+	prodID := int(exec(`INSERT INTO product (sku, color, color_code, color_hex, country_of_origin, thumbnail_id, style_id)
+		VALUES (CONCAT('SS26-00098-', LEFT(MD5(RAND()),3)), 'c', 'BLK', '#000000', 'US', ?, ?)`, mediaID, styleID))
+	exec(`INSERT INTO product_size (product_id, size_id, quantity) VALUES (?, ?, ?)`, prodID, sizeID, stock)
+
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM stock_reservation WHERE product_id = ?", prodID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_size WHERE product_id = ?", prodID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product WHERE id = ?", prodID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM tech_card WHERE id = ?", styleID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM media WHERE id = ?", mediaID)
+	})
+	return prodID, sizeID
+}
+
+func newReservationTestStore(t *testing.T, ctx context.Context) *MYSQLStore {
+	t.Helper()
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	di, err := s.Cache().GetDictionaryInfo(ctx)
+	require.NoError(t, err)
+	hf, err := s.Hero().GetHero(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cache.InitConsts(ctx, di, hf))
+	return s
+}
+
+// TestMySQLReservationLastUnitAcrossInstances: two managers over one DB stand in for two replicas.
+// N sessions race for the last unit through both; exactly one may get it.
+func TestMySQLReservationLastUnitAcrossInstances(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	s := newReservationTestStore(t, ctx)
+	prodID, sizeID := seedReservableVariant(t, ctx, s, 1)
+
+	a := stockreserve.NewMySQLManager(s, 15*time.Minute, 30*time.Minute, time.Minute, stockreserve.DefaultLimits())
+	defer a.Stop()
+	b := stockreserve.NewMySQLManager(s, 15*time.Minute, 30*time.Minute, time.Minute, stockreserve.DefaultLimits())
+	defer b.Stop()
+	managers := []*stockreserve.MySQLManager{a, b}
+
+	const n = 10
+	var wg sync.WaitGroup
+	barrier := make(chan struct{})
+	avail := make([]decimal.Decimal, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-barrier
+			avail[i], errs[i] = managers[i%2].ReserveIfAvailable(ctx, decimal.NewFromInt(1), fmt.Sprintf("session-%d", i), prodID, sizeID, decimal.NewFromInt(1))
+		}(i)
+	}
+	close(barrier)
+	wg.Wait()
+
+	winners := 0
+	for i := range n {
+		require.NoError(t, errs[i], "reserve %d", i)
+		if avail[i].IsPositive() {
+			winners++
+		}
+	}
+	require.Equal(t, 1, winners, "exactly one session may hold the last unit")
+
+	var rows int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_reservation WHERE product_id = ?`, prodID).Scan(&rows))
+	require.Equal(t, 1, rows, "exactly one hold row")
+}
+
+// TestMySQLReservationLifecycle covers refresh, commit, release, session release and the sweep.
+func TestMySQLReservationLifecycle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	s := newReservationTestStore(t, ctx)
+	prodID, sizeID := seedReservableVariant(t, ctx, s, 3)
+	rm := stockreserve.NewMySQLManager(s, 15*time.Minute, 30*time.Minute, time.Minute, stockreserve.DefaultLimits())
+	defer rm.Stop()
+	total := decimal.NewFromInt(3)
+
+	// A holds 2; B asks for 5 and sees the 1 that is left.
+	_, err := rm.ReserveIfAvailable(ctx, total, "sess-a", prodID, sizeID, decimal.NewFromInt(2))
+	require.NoError(t, err)
+	availB, err := rm.ReserveIfAvailable(ctx, total, "sess-b", prodID, sizeID, decimal.NewFromInt(5))
+	require.NoError(t, err)
+	require.True(t, availB.Equal(decimal.NewFromInt(1)), "B available = %s", availB)
+
+	// A re-reserve is a refresh: same row, expiry kept, refresh_count bumped.
+	first, err := s.StockReservations().GetStockReservation(ctx, prodID, sizeID, "sess-a")
+	require.NoError(t, err)
+	_, err = rm.ReserveIfAvailable(ctx, total, "sess-a", prodID, sizeID, decimal.NewFromInt(1))
+	require.NoError(t, err)
+	refreshed, err := s.StockReservations().GetStockReservation(ctx, prodID, sizeID, "sess-a")
+	require.NoError(t, err)
+	require.Equal(t, first.Id, refreshed.Id)
+	require.True(t, first.ExpiresAt.Equal(refreshed.ExpiresAt), "refresh must not extend expiry")
+	require.Equal(t, 1, refreshed.RefreshCount)
+	require.True(t, refreshed.Quantity.Equal(decimal.NewFromInt(1)))
+
+	// Commit hands A's hold to the order; ReleaseSession then leaves it alone.
+	rm.Commit(ctx, "sess-a", "order-a")
+	rm.ReleaseSession(ctx, "sess-a")
+	committed, err := s.StockReservations().GetStockReservation(ctx, prodID, sizeID, "sess-a")
+	require.NoError(t, err)
+	require.Equal(t, "order-a", committed.OrderUUID.String)
+
+	// ReleaseSession drops B's cart hold; Release drops A's order hold.
+	rm.ReleaseSession(ctx, "sess-b")
+	_, err = s.StockReservations().GetStockReservation(ctx, prodID, sizeID, "sess-b")
+	require.Error(t, err)
+	rm.Release(ctx, "order-a")
+	_, err = s.StockReservations().GetStockReservation(ctx, prodID, sizeID, "sess-a")
+	require.Error(t, err)
+
+	// An expired hold is invisible to availability and is removed by the sweep.
+	_, err = testDB.ExecContext(ctx, `INSERT INTO stock_reservation (product_id, size_id, session_id, quantity, expires_at)
+		VALUES (?, ?, 'sess-old', 3, UTC_TIMESTAMP() - INTERVAL 1 MINUTE)`, prodID, sizeID)
+	require.NoError(t, err)
+	availC, err := rm.ReserveIfAvailable(ctx, total, "sess-c", prodID, sizeID, decimal.NewFromInt(1))
+	require.NoError(t, err)
+	require.True(t, availC.Equal(total), "expired hold must not count, got %s", availC)
+
+	_, err = s.StockReservations().DeleteExpiredStockReservations(ctx, 100)
+	require.NoError(t, err)
+	var stale int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_reservation WHERE session_id = 'sess-old'`).Scan(&stale))
+	require.Zero(t, stale)
+}
+
+// TestMySQLReservationLimits: a limit rejection writes nothing but still reports availability,
+// and the per-cart item cap counts durable rows.
+func TestMySQLReservationLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	s := newReservationTestStore(t, ctx)
+	prodID, sizeID := seedReservableVariant(t, ctx, s, 10)
+	limits := stockreserve.DefaultLimits()
+	limits.MaxItemsPerSession = 1
+	rm := stockreserve.NewMySQLManager(s, 15*time.Minute, 30*time.Minute, time.Minute, limits)
+	defer rm.Stop()
+	total := decimal.NewFromInt(10)
+
+	avail, err := rm.ReserveIfAvailable(ctx, total, "sess-x", prodID, sizeID, limits.MaxQtyPerItem.Add(decimal.NewFromInt(1)))
+	require.Error(t, err)
+	require.True(t, avail.Equal(total))
+	_, err = s.StockReservations().GetStockReservation(ctx, prodID, sizeID, "sess-x")
+	require.Error(t, err, "rejected hold must not be written")
+
+	// One item fills the cart; a second variant for the same session is refused.
+	_, err = rm.ReserveIfAvailable(ctx, total, "sess-x", prodID, sizeID, decimal.NewFromInt(1))
+	require.NoError(t, err)
+	_, err = rm.ReserveIfAvailable(ctx, total, "sess-x", prodID, sizeID+100000, decimal.NewFromInt(1))
+	require.ErrorContains(t, err, "items per cart")
+}
@@ -0,0 +1,245 @@
+package stocklocation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// An order is allocated once paid: the sale has already taken its units off the totals, i.e. off the
+// default location. Allocating it to another location moves those units back — the default location
+// did not give them — and takes them out of the location that does. Both sides are journalled
+// (source location_transfer, reason order_allocation) so each location's history adds up. Releasing
+// the allocation (refund, re-allocation) reverses exactly that.
+
+type allocOrder struct {
+	Id     int    `db:"id"`
+	UUID   string `db:"uuid"`
+	Status string `db:"status"`
+}
+
+// AllocateOrderInTx allocates a paid order to locationID, or, when it is 0, to the first active
+// location that holds the whole order (the default location first). Any previous allocation of the
+// order is released first. It returns the chosen location. It must run inside rep's transaction.
+func AllocateOrderInTx(ctx context.Context, rep dependency.Repository, orderID, locationID int, username string) (*entity.StockLocation, error) {
+	order, err := lockOrder(ctx, rep.DB(), orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != string(entity.Confirmed) {
+		return nil, fmt.Errorf("%w: order %s is %s", entity.ErrOrderNotAllocatable, order.UUID, order.Status)
+	}
+	if err := ReleaseOrderAllocationInTx(ctx, rep, orderID, username); err != nil {
+		return nil, err
+	}
+	needs, err := storeutil.QueryListNamed[struct {
+		ProductSizeId int `db:"product_size_id"`
+		Quantity      int `db:"quantity"`
+	}](ctx, rep.DB(), `
+		SELECT variant_id AS product_size_id, CAST(SUM(quantity) AS SIGNED) AS quantity
+		FROM order_item WHERE order_id = :id
+		GROUP BY variant_id ORDER BY variant_id`, map[string]any{"id": orderID})
+	if err != nil {
+		return nil, fmt.Errorf("can't read order items: %w", err)
+	}
+	want := make([]entity.StockAllocationNeed, 0, len(needs))
+	for _, n := range needs {
+		want = append(want, entity.StockAllocationNeed{ProductSizeId: n.ProductSizeId, Quantity: n.Quantity})
+	}
+
+	locs, err := storeutil.QueryListNamed[entity.StockLocation](ctx, rep.DB(), selectLocation+`
+		WHERE archived_at IS NULL AND (:loc = 0 OR id = :loc)
+		ORDER BY is_default DESC, id FOR UPDATE`, map[string]any{"loc": locationID})
+	if err != nil {
+		return nil, fmt.Errorf("can't list stock locations: %w", err)
+	}
+	if locationID > 0 && len(locs) == 0 {
+		if _, err := activeLocation(ctx, rep.DB(), locationID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %d", entity.ErrStockLocationNotFound, locationID)
+	}
+	candidates := make([]entity.LocationAvailability, 0, len(locs))
+	for i := range locs {
+		avail := entity.LocationAvailability{LocationId: locs[i].Id, Available: make(map[int]int, len(want))}
+		for _, n := range want {
+			q, err := variantSubject.qtyAt(ctx, rep.DB(), n.ProductSizeId, &locs[i])
+			if err != nil {
+				return nil, err
+			}
+			have := int(q.IntPart())
+			if locs[i].IsDefault {
+				have += n.Quantity // the sale took them from here
+			}
+			avail.Available[n.ProductSizeId] = have
+		}
+		candidates = append(candidates, avail)
+	}
+	picked, err := entity.PickFulfillmentLocation(want, candidates)
+	if err != nil {
+		if locationID > 0 {
+			return nil, fmt.Errorf("%w: %s does not hold the whole order", entity.ErrInsufficientLocationStock, locs[0].Code)
+		}
+		return nil, err
+	}
+	var loc *entity.StockLocation
+	for i := range locs {
+		if locs[i].Id == picked {
+			loc = &locs[i]
+		}
+	}
+
+	if !loc.IsDefault {
+		def, err := defaultLocation(ctx, rep.DB())
+		if err != nil {
+			return nil, err
+		}
+		if err := moveForOrder(ctx, rep, order, want, loc, def, username); err != nil {
+			return nil, err
+		}
+	}
+	rows := make([]map[string]any, 0, len(want))
+	for _, n := range want {
+		rows = append(rows, map[string]any{
+			"order_id":        orderID,
+			"product_size_id": n.ProductSizeId,
+			"location_id":     loc.Id,
+			"quantity":        n.Quantity,
+			"allocated_by":    username,
+		})
+	}
+	if err := storeutil.BulkInsert(ctx, rep.DB(), "stock_order_allocation", rows); err != nil {
+		return nil, fmt.Errorf("can't insert stock order allocation: %w", err)
+	}
+	err = storeutil.ExecNamed(ctx, rep.DB(), `
+		INSERT INTO order_fulfillment (order_uuid, created_by, location_id)
+		VALUES (:uuid, :username, :loc)
+		ON DUPLICATE KEY UPDATE location_id = VALUES(location_id)`,
+		map[string]any{"uuid": order.UUID, "username": username, "loc": loc.Id})
+	if err != nil {
+		return nil, fmt.Errorf("can't set fulfillment location: %w", err)
+	}
+	return loc, nil
+}
+
+// ReleaseOrderAllocationInTx undoes an order's allocation: units allocated to a non-default location
+// go back into it (and out of the default one). An order without an allocation is a no-op. It must
+// run inside rep's transaction.
+func ReleaseOrderAllocationInTx(ctx context.Context, rep dependency.Repository, orderID int, username string) error {
+	allocs, err := storeutil.QueryListNamed[entity.StockOrderAllocation](ctx, rep.DB(), `
+		SELECT order_id, product_size_id, location_id, quantity, allocated_by, created_at
+		FROM stock_order_allocation WHERE order_id = :id
+		ORDER BY product_size_id FOR UPDATE`, map[string]any{"id": orderID})
+	if err != nil {
+		return fmt.Errorf("can't read stock order allocation: %w", err)
+	}
+	if len(allocs) == 0 {
+		return nil
+	}
+	order, err := lockOrder(ctx, rep.DB(), orderID)
+	if err != nil {
+		return err
+	}
+	loc, err := getLocation(ctx, rep.DB(), allocs[0].LocationId, true)
+	if err != nil {
+		return err
+	}
+	if !loc.IsDefault {
+		def, err := defaultLocation(ctx, rep.DB())
+		if err != nil {
+			return err
+		}
+		back := make([]entity.StockAllocationNeed, 0, len(allocs))
+		for _, a := range allocs {
+			back = append(back, entity.StockAllocationNeed{ProductSizeId: a.ProductSizeId, Quantity: a.Quantity})
+		}
+		if err := moveForOrder(ctx, rep, order, back, def, loc, username); err != nil {
+			return err
+		}
+	}
+	err = storeutil.ExecNamed(ctx, rep.DB(), `DELETE FROM stock_order_allocation WHERE order_id = :id`,
+		map[string]any{"id": orderID})
+	if err != nil {
+		return fmt.Errorf("can't delete stock order allocation: %w", err)
+	}
+	err = storeutil.ExecNamed(ctx, rep.DB(), `UPDATE order_fulfillment SET location_id = NULL WHERE order_uuid = :uuid`,
+		map[string]any{"uuid": order.UUID})
+	if err != nil {
+		return fmt.Errorf("can't clear fulfillment location: %w", err)
+	}
+	return nil
+}
+
+// moveForOrder moves an order's units from one location into another and journals both sides.
+func moveForOrder(ctx context.Context, rep dependency.Repository, order *allocOrder, lines []entity.StockAllocationNeed, from, to *entity.StockLocation, username string) error {
+	journal := make([]entity.StockChangeInsert, 0, 2*len(lines))
+	for _, n := range lines {
+		q := decimal.NewFromInt(int64(n.Quantity))
+		v, err := variantKey(ctx, rep.DB(), n.ProductSizeId)
+		if err != nil {
+			return err
+		}
+		sides := []struct {
+			loc    *entity.StockLocation
+			delta  decimal.Decimal
+			before decimal.Decimal
+		}{{loc: from, delta: q.Neg()}, {loc: to, delta: q}}
+		// Both befores are read first: the default location's quantity is derived from the other's row.
+		for i := range sides {
+			if sides[i].before, err = variantSubject.qtyAt(ctx, rep.DB(), n.ProductSizeId, sides[i].loc); err != nil {
+				return err
+			}
+		}
+		for _, side := range sides {
+			if err := variantSubject.add(ctx, rep.DB(), n.ProductSizeId, side.loc, side.delta); err != nil {
+				return err
+			}
+			before := side.before
+			journal = append(journal, entity.StockChangeInsert{
+				ProductId:      sql.NullInt32{Int32: int32(v.ProductId), Valid: true},
+				SizeId:         sql.NullInt32{Int32: int32(v.SizeId), Valid: true},
+				Grade:          v.Grade,
+				QuantityDelta:  side.delta,
+				QuantityBefore: before,
+				QuantityAfter:  before.Add(side.delta),
+				Source:         string(entity.StockChangeSourceLocationTransfer),
+				Reason:         sql.NullString{String: string(entity.StockChangeReasonOrderAllocation), Valid: true},
+				OrderId:        sql.NullInt32{Int32: int32(order.Id), Valid: true},
+				OrderUUID:      sql.NullString{String: order.UUID, Valid: true},
+				Comment:        sql.NullString{String: from.Name + " → " + to.Name, Valid: true},
+				AdminUsername:  sql.NullString{String: username, Valid: username != ""},
+				LocationId:     sql.NullInt32{Int32: int32(side.loc.Id), Valid: true},
+			})
+		}
+	}
+	return rep.Products().RecordStockChange(ctx, journal)
+}
+
+func lockOrder(ctx context.Context, db dependency.DB, orderID int) (*allocOrder, error) {
+	o, err := storeutil.QueryNamedOne[allocOrder](ctx, db, `
+		SELECT co.id, co.uuid, os.name AS status
+		FROM customer_order co
+		JOIN order_status os ON os.id = co.order_status_id
+		WHERE co.id = :id FOR UPDATE`, map[string]any{"id": orderID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: order %d not found", entity.ErrOrderNotAllocatable, orderID)
+		}
+		return nil, fmt.Errorf("can't get order: %w", err)
+	}
+	return &o, nil
+}
+
+func defaultLocation(ctx context.Context, db dependency.DB) (*entity.StockLocation, error) {
+	loc, err := storeutil.QueryNamedOne[entity.StockLocation](ctx, db, selectLocation+` WHERE is_default`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get default stock location: %w", err)
+	}
+	return &loc, nil
+}
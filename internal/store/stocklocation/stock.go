@@ -0,0 +1,207 @@
+package stocklocation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// subject is one kind of thing a location holds: a variant, a material, or a lot of a material. Each
+// has a total written by the location-unaware paths, a per-location table for the other locations,
+// and a column on stock_transfer_line. The table and column names are constants, never input.
+type subject struct {
+	locTable string // per-location table
+	keyCol   string // key column, shared by the per-location table and stock_transfer_line
+	total    string // SELECT of the total as v, locking the row it is kept on
+}
+
+var (
+	variantSubject = subject{
+		locTable: "product_size_location",
+		keyCol:   "product_size_id",
+		total:    `SELECT COALESCE(quantity, 0) AS v FROM product_size WHERE id = :key FOR UPDATE`,
+	}
+	materialSubject = subject{
+		locTable: "material_location_stock",
+		keyCol:   "material_id",
+		total:    `SELECT on_hand AS v FROM material_stock WHERE material_id = :key FOR UPDATE`,
+	}
+	lotSubject = subject{
+		locTable: "material_lot_location",
+		keyCol:   "lot_id",
+		total:    `SELECT remaining_qty AS v FROM material_lot WHERE id = :key FOR UPDATE`,
+	}
+)
+
+// qtyAt returns how much of the subject is in loc, locking what it read: the stored row of a
+// non-default location, or the derived quantity of the default one.
+func (k subject) qtyAt(ctx context.Context, db dependency.DB, key int, loc *entity.StockLocation) (decimal.Decimal, error) {
+	params := map[string]any{"key": key, "loc": loc.Id}
+	if !loc.IsDefault {
+		return sumDecimal(ctx, db, `SELECT quantity AS v FROM `+k.locTable+`
+			WHERE `+k.keyCol+` = :key AND location_id = :loc FOR UPDATE`, params)
+	}
+	total, err := sumDecimal(ctx, db, k.total, params)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	elsewhere, err := sumDecimal(ctx, db, `SELECT COALESCE(SUM(quantity), 0) AS v FROM `+k.locTable+`
+		WHERE `+k.keyCol+` = :key`, params)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	inTransit, err := sumDecimal(ctx, db, `SELECT COALESCE(SUM(stl.quantity), 0) AS v
+		FROM stock_transfer_line stl
+		JOIN stock_transfer st ON st.id = stl.transfer_id
+		WHERE stl.`+k.keyCol+` = :key AND st.status = 'in_transit'`, params)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return entity.DefaultLocationQty(total, elsewhere, inTransit), nil
+}
+
+// add moves delta into (or, negative, out of) loc. The default location has no row: what is not in
+// another location or on the road is in it, so there is nothing to write. The caller has checked a
+// negative delta against qtyAt; chk_*_qty backs that up.
+func (k subject) add(ctx context.Context, db dependency.DB, key int, loc *entity.StockLocation, delta decimal.Decimal) error {
+	if loc.IsDefault || delta.IsZero() {
+		return nil
+	}
+	err := storeutil.ExecNamed(ctx, db, `
+		INSERT INTO `+k.locTable+` (`+k.keyCol+`, location_id, quantity) VALUES (:key, :loc, :delta)
+		ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity)`,
+		map[string]any{"key": key, "loc": loc.Id, "delta": delta})
+	if err != nil {
+		return fmt.Errorf("can't move %s %d in location %d: %w", k.keyCol, key, loc.Id, err)
+	}
+	return nil
+}
+
+// sumDecimal reads a single decimal column named v; no row reads as zero.
+func sumDecimal(ctx context.Context, db dependency.DB, query string, params map[string]any) (decimal.Decimal, error) {
+	row, err := storeutil.QueryNamedOne[struct {
+		V decimal.NullDecimal `db:"v"`
+	}](ctx, db, query, params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return decimal.Zero, nil
+		}
+		return decimal.Zero, fmt.Errorf("can't read location stock: %w", err)
+	}
+	return row.V.Decimal, nil
+}
+
+// ListLocationStock returns everything a location holds: its variants, and its materials with their
+// lots. For the default location the quantities are derived, and a NEGATIVE one is listed too — it
+// is the default location having sold more than it holds.
+func (s *Store) ListLocationStock(ctx context.Context, locationID int) (*entity.LocationStock, error) {
+	loc, err := getLocation(ctx, s.DB, locationID, false)
+	if err != nil {
+		return nil, err
+	}
+	variantsQ, materialsQ, lotsQ := locationVariantQuery, locationMaterialQuery, locationLotQuery
+	if loc.IsDefault {
+		variantsQ, materialsQ, lotsQ = defaultVariantQuery, defaultMaterialQuery, defaultLotQuery
+	}
+	params := map[string]any{"loc": loc.Id}
+	out := &entity.LocationStock{Location: *loc}
+	if out.Variants, err = storeutil.QueryListNamed[entity.LocationVariantStock](ctx, s.DB, variantsQ, params); err != nil {
+		return nil, fmt.Errorf("can't list location variants: %w", err)
+	}
+	if out.Materials, err = storeutil.QueryListNamed[entity.LocationMaterialStock](ctx, s.DB, materialsQ, params); err != nil {
+		return nil, fmt.Errorf("can't list location materials: %w", err)
+	}
+	lots, err := storeutil.QueryListNamed[entity.LocationLotStock](ctx, s.DB, lotsQ, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't list location lots: %w", err)
+	}
+	byMaterial := make(map[int]int, len(out.Materials))
+	for i, m := range out.Materials {
+		byMaterial[m.MaterialId] = i
+	}
+	for _, l := range lots {
+		if i, ok := byMaterial[l.MaterialId]; ok {
+			out.Materials[i].Lots = append(out.Materials[i].Lots, l)
+		}
+	}
+	return out, nil
+}
+
+const variantColumns = `
+	ps.id AS product_size_id, ps.product_id, ps.size_id, ps.grade, COALESCE(ps.sku, '') AS sku,
+	sz.name AS size_name, COALESCE(ps.quantity, 0) AS total`
+
+const locationVariantQuery = `
+	SELECT ` + variantColumns + `, psl.quantity
+	FROM product_size_location psl
+	JOIN product_size ps ON ps.id = psl.product_size_id
+	JOIN size sz ON sz.id = ps.size_id
+	WHERE psl.location_id = :loc AND psl.quantity <> 0
+	ORDER BY ps.product_id, ps.size_id, ps.grade`
+
+const defaultVariantQuery = `
+	SELECT ` + variantColumns + `,
+		CAST(COALESCE(ps.quantity, 0) - COALESCE(l.q, 0) - COALESCE(t.q, 0) AS SIGNED) AS quantity
+	FROM product_size ps
+	JOIN size sz ON sz.id = ps.size_id
+	LEFT JOIN (SELECT product_size_id, SUM(quantity) AS q FROM product_size_location
+	           GROUP BY product_size_id) l ON l.product_size_id = ps.id
+	LEFT JOIN (SELECT stl.product_size_id, SUM(stl.quantity) AS q
+	           FROM stock_transfer_line stl
+	           JOIN stock_transfer st ON st.id = stl.transfer_id
+	           WHERE st.status = 'in_transit' AND stl.product_size_id IS NOT NULL
+	           GROUP BY stl.product_size_id) t ON t.product_size_id = ps.id
+	HAVING quantity <> 0
+	ORDER BY ps.product_id, ps.size_id, ps.grade`
+
+const locationMaterialQuery = `
+	SELECT m.id AS material_id, m.name AS material_name, m.unit, mls.quantity,
+	       COALESCE(ms.on_hand, 0) AS total
+	FROM material_location_stock mls
+	JOIN material m ON m.id = mls.material_id
+	LEFT JOIN material_stock ms ON ms.material_id = m.id
+	WHERE mls.location_id = :loc AND mls.quantity <> 0
+	ORDER BY m.section, m.name`
+
+const defaultMaterialQuery = `
+	SELECT m.id AS material_id, m.name AS material_name, m.unit,
+	       COALESCE(ms.on_hand, 0) - COALESCE(l.q, 0) - COALESCE(t.q, 0) AS quantity,
+	       COALESCE(ms.on_hand, 0) AS total
+	FROM material m
+	LEFT JOIN material_stock ms ON ms.material_id = m.id
+	LEFT JOIN (SELECT material_id, SUM(quantity) AS q FROM material_location_stock
+	           GROUP BY material_id) l ON l.material_id = m.id
+	LEFT JOIN (SELECT stl.material_id, SUM(stl.quantity) AS q
+	           FROM stock_transfer_line stl
+	           JOIN stock_transfer st ON st.id = stl.transfer_id
+	           WHERE st.status = 'in_transit' AND stl.material_id IS NOT NULL
+	           GROUP BY stl.material_id) t ON t.material_id = m.id
+	HAVING quantity <> 0
+	ORDER BY m.section, m.name`
+
+const locationLotQuery = `
+	SELECT ml.material_id, mll.lot_id, ml.lot_code, mll.quantity
+	FROM material_lot_location mll
+	JOIN material_lot ml ON ml.id = mll.lot_id
+	WHERE mll.location_id = :loc AND mll.quantity <> 0
+	ORDER BY ml.material_id, ml.lot_code`
+
+const defaultLotQuery = `
+	SELECT ml.material_id, ml.id AS lot_id, ml.lot_code,
+	       ml.remaining_qty - COALESCE(l.q, 0) - COALESCE(t.q, 0) AS quantity
+	FROM material_lot ml
+	LEFT JOIN (SELECT lot_id, SUM(quantity) AS q FROM material_lot_location
+	           GROUP BY lot_id) l ON l.lot_id = ml.id
+	LEFT JOIN (SELECT stl.lot_id, SUM(stl.quantity) AS q
+	           FROM stock_transfer_line stl
+	           JOIN stock_transfer st ON st.id = stl.transfer_id
+	           WHERE st.status = 'in_transit' AND stl.lot_id IS NOT NULL
+	           GROUP BY stl.lot_id) t ON t.lot_id = ml.id
+	HAVING quantity <> 0
+	ORDER BY ml.material_id, ml.lot_code`
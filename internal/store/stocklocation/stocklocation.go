@@ -0,0 +1,212 @@
+// Package stocklocation implements stock locations (0345): the named places finished goods and
+// materials lie in, the per-location stock of the non-default locations, transfer documents between
+// them, and the allocation of paid orders to the location they are packed from.
+//
+// The totals (product_size.quantity, material_stock.on_hand, material_lot.remaining_qty) stay the
+// source of truth and every location-unaware writer keeps writing them — which is to say, writing
+// the default location, whose quantity is derived rather than stored (entity.DefaultLocationQty).
+// This package is the only writer of the per-location rows.
+package stocklocation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.StockLocations.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new stock location store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const selectLocation = `
+	SELECT id, code, name, kind, is_default, address, note, archived_at, created_by, created_at, updated_at
+	FROM stock_location`
+
+// ListStockLocations returns the locations, the default one first, then by name. Archived locations
+// are included only on request.
+func (s *Store) ListStockLocations(ctx context.Context, includeArchived bool) ([]entity.StockLocation, error) {
+	out, err := storeutil.QueryListNamed[entity.StockLocation](ctx, s.DB, selectLocation+`
+		WHERE :all OR archived_at IS NULL
+		ORDER BY is_default DESC, name, id`, map[string]any{"all": includeArchived})
+	if err != nil {
+		return nil, fmt.Errorf("can't list stock locations: %w", err)
+	}
+	return out, nil
+}
+
+// GetStockLocation returns one location.
+func (s *Store) GetStockLocation(ctx context.Context, id int) (*entity.StockLocation, error) {
+	return getLocation(ctx, s.DB, id, false)
+}
+
+// CreateStockLocation stores a new location and returns its id.
+func (s *Store) CreateStockLocation(ctx context.Context, ins entity.StockLocationInsert) (int, error) {
+	ins = normalizeLocation(ins)
+	if err := entity.ValidateStockLocationInsert(ins); err != nil {
+		return 0, err
+	}
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		if err := checkCodeFree(ctx, rep.DB(), ins.Code, 0); err != nil {
+			return err
+		}
+		var err error
+		id, err = storeutil.ExecNamedLastId(ctx, rep.DB(), `
+			INSERT INTO stock_location (code, name, kind, address, note, created_by)
+			VALUES (:code, :name, :kind, :address, :note, :createdBy)`,
+			map[string]any{
+				"code":      ins.Code,
+				"name":      ins.Name,
+				"kind":      ins.Kind,
+				"address":   ins.Address,
+				"note":      ins.Note,
+				"createdBy": ins.CreatedBy,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert stock location: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateStockLocation replaces a location's code, name, kind, address and note.
+func (s *Store) UpdateStockLocation(ctx context.Context, id int, ins entity.StockLocationInsert) error {
+	ins = normalizeLocation(ins)
+	if err := entity.ValidateStockLocationInsert(ins); err != nil {
+		return err
+	}
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		if _, err := getLocation(ctx, rep.DB(), id, true); err != nil {
+			return err
+		}
+		if err := checkCodeFree(ctx, rep.DB(), ins.Code, id); err != nil {
+			return err
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE stock_location
+			SET code = :code, name = :name, kind = :kind, address = :address, note = :note
+			WHERE id = :id`,
+			map[string]any{
+				"id":      id,
+				"code":    ins.Code,
+				"name":    ins.Name,
+				"kind":    ins.Kind,
+				"address": ins.Address,
+				"note":    ins.Note,
+			})
+	})
+}
+
+// SetStockLocationArchived archives or restores a location. The default location is never archived,
+// and neither is one that still holds stock, has a transfer not yet closed, or packs a paid order:
+// its quantities would be stranded where no transfer can reach them.
+func (s *Store) SetStockLocationArchived(ctx context.Context, id int, archived bool) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		loc, err := getLocation(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		if archived {
+			if loc.IsDefault {
+				return entity.ErrStockLocationDefault
+			}
+			busy, err := storeutil.QueryCountNamed(ctx, rep.DB(), `
+				SELECT (SELECT COUNT(*) FROM product_size_location WHERE location_id = :id AND quantity > 0)
+				     + (SELECT COUNT(*) FROM material_location_stock WHERE location_id = :id AND quantity > 0)
+				     + (SELECT COUNT(*) FROM stock_transfer
+				        WHERE (from_location_id = :id OR to_location_id = :id) AND status IN ('draft', 'in_transit'))
+				     + (SELECT COUNT(*) FROM stock_order_allocation a
+				        JOIN customer_order o ON o.id = a.order_id
+				        JOIN order_status os ON os.id = o.order_status_id
+				        WHERE a.location_id = :id AND os.name = :confirmed)`,
+				map[string]any{"id": id, "confirmed": string(entity.Confirmed)})
+			if err != nil {
+				return fmt.Errorf("can't check stock location contents: %w", err)
+			}
+			if busy > 0 {
+				return entity.ErrStockLocationNotEmpty
+			}
+		}
+		return storeutil.ExecNamed(ctx, rep.DB(), `
+			UPDATE stock_location
+			SET archived_at = CASE WHEN :archived THEN COALESCE(archived_at, CURRENT_TIMESTAMP) ELSE NULL END
+			WHERE id = :id`,
+			map[string]any{"id": id, "archived": archived})
+	})
+}
+
+// getLocation reads one location, optionally locking it against a concurrent archive.
+func getLocation(ctx context.Context, db dependency.DB, id int, forUpdate bool) (*entity.StockLocation, error) {
+	query := selectLocation + ` WHERE id = :id`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	loc, err := storeutil.QueryNamedOne[entity.StockLocation](ctx, db, query, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", entity.ErrStockLocationNotFound, id)
+		}
+		return nil, fmt.Errorf("can't get stock location: %w", err)
+	}
+	return &loc, nil
+}
+
+// activeLocation reads a location stock is about to move through and refuses an archived one.
+func activeLocation(ctx context.Context, db dependency.DB, id int) (*entity.StockLocation, error) {
+	loc, err := getLocation(ctx, db, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if loc.ArchivedAt.Valid {
+		return nil, fmt.Errorf("%w: %s", entity.ErrStockLocationArchived, loc.Code)
+	}
+	return loc, nil
+}
+
+func checkCodeFree(ctx context.Context, db dependency.DB, code string, exceptID int) error {
+	n, err := storeutil.QueryCountNamed(ctx, db,
+		`SELECT COUNT(*) FROM stock_location WHERE code = :code AND id <> :id`,
+		map[string]any{"code": code, "id": exceptID})
+	if err != nil {
+		return fmt.Errorf("can't check stock location code: %w", err)
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %s", entity.ErrStockLocationCodeTaken, code)
+	}
+	return nil
+}
+
+// normalizeLocation trims the payload; codes are upper-case, the way they are printed on documents.
+func normalizeLocation(ins entity.StockLocationInsert) entity.StockLocationInsert {
+	ins.Code = strings.ToUpper(strings.TrimSpace(ins.Code))
+	ins.Name = strings.TrimSpace(ins.Name)
+	ins.Kind = entity.StockLocationKind(strings.ToLower(strings.TrimSpace(string(ins.Kind))))
+	ins.Address = trimmedNull(ins.Address)
+	ins.Note = trimmedNull(ins.Note)
+	return ins
+}
+
+func trimmedNull(v sql.NullString) sql.NullString {
+	s := strings.TrimSpace(v.String)
+	return sql.NullString{String: s, Valid: v.Valid && s != ""}
+}
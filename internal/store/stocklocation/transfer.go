@@ -0,0 +1,359 @@
+package stocklocation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// Transfers move stock between two locations in two steps. Dispatch takes the lines out of the
+// source; from then until receipt they are on the road — in no location, and subtracted from the
+// default location's derived quantity. Receipt puts them into the destination; cancelling a transfer
+// on the road puts them back into the source. A draft has moved nothing and is cancelled freely.
+//
+// Variant lines are journalled (source location_transfer, one row per end) with location_id and that
+// location's before/after; material lines are not — the material movement ledger records value, and
+// a transfer moves none. The document itself is their trace.
+
+const selectTransfer = `
+	SELECT t.id, t.from_location_id, lf.name AS from_location, t.to_location_id, lt.name AS to_location,
+	       t.status, t.note, t.created_by, t.dispatched_by, t.dispatched_at, t.received_by, t.received_at,
+	       t.cancelled_by, t.cancelled_at, t.created_at, t.updated_at
+	FROM stock_transfer t
+	JOIN stock_location lf ON lf.id = t.from_location_id
+	JOIN stock_location lt ON lt.id = t.to_location_id`
+
+const selectTransferLine = `
+	SELECT l.id, l.transfer_id, l.product_size_id, l.material_id, l.lot_id, l.quantity,
+	       COALESCE(ps.sku, '') AS sku, COALESCE(sz.name, '') AS size_name,
+	       COALESCE(m.name, '') AS material_name, m.unit, COALESCE(ml.lot_code, '') AS lot_code
+	FROM stock_transfer_line l
+	LEFT JOIN product_size ps ON ps.id = l.product_size_id
+	LEFT JOIN size sz ON sz.id = ps.size_id
+	LEFT JOIN material m ON m.id = l.material_id
+	LEFT JOIN material_lot ml ON ml.id = l.lot_id`
+
+// CreateStockTransfer stores a new draft transfer and returns its id. Both locations must be active;
+// stock is not checked until dispatch, when it is taken.
+func (s *Store) CreateStockTransfer(ctx context.Context, ins entity.StockTransferInsert) (int, error) {
+	if err := entity.ValidateStockTransferInsert(ins); err != nil {
+		return 0, err
+	}
+	var id int
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		if _, err := activeLocation(ctx, rep.DB(), ins.FromLocationId); err != nil {
+			return err
+		}
+		if _, err := activeLocation(ctx, rep.DB(), ins.ToLocationId); err != nil {
+			return err
+		}
+		var err error
+		id, err = storeutil.ExecNamedLastId(ctx, rep.DB(), `
+			INSERT INTO stock_transfer (from_location_id, to_location_id, status, note, created_by)
+			VALUES (:from, :to, :status, :note, :createdBy)`,
+			map[string]any{
+				"from":      ins.FromLocationId,
+				"to":        ins.ToLocationId,
+				"status":    entity.StockTransferDraft,
+				"note":      trimmedNull(ins.Note),
+				"createdBy": ins.CreatedBy,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert stock transfer: %w", err)
+		}
+		rows := make([]map[string]any, 0, len(ins.Lines))
+		for i, l := range ins.Lines {
+			if l.LotId > 0 {
+				n, err := storeutil.QueryCountNamed(ctx, rep.DB(),
+					`SELECT COUNT(*) FROM material_lot WHERE id = :lot AND material_id = :material`,
+					map[string]any{"lot": l.LotId, "material": l.MaterialId})
+				if err != nil {
+					return fmt.Errorf("can't check material lot: %w", err)
+				}
+				if n == 0 {
+					return entity.NewFieldViolation(fmt.Sprintf("lines[%d].lot_id", i), "not_found", "",
+						"pick a lot of the line's material")
+				}
+			}
+			rows = append(rows, map[string]any{
+				"transfer_id":     id,
+				"product_size_id": nullID(l.ProductSizeId),
+				"material_id":     nullID(l.MaterialId),
+				"lot_id":          nullID(l.LotId),
+				"quantity":        l.Quantity,
+			})
+		}
+		if err := storeutil.BulkInsert(ctx, rep.DB(), "stock_transfer_line", rows); err != nil {
+			return fmt.Errorf("can't insert stock transfer lines: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetStockTransfer returns a transfer with its lines.
+func (s *Store) GetStockTransfer(ctx context.Context, id int) (*entity.StockTransferFull, error) {
+	return getTransfer(ctx, s.DB, id, false)
+}
+
+// ListStockTransfers returns transfer headers matching the filter, newest first, and the total.
+func (s *Store) ListStockTransfers(ctx context.Context, f entity.StockTransferFilter) ([]entity.StockTransfer, int, error) {
+	where := ` WHERE (:status = '' OR t.status = :status)
+		AND (:loc = 0 OR t.from_location_id = :loc OR t.to_location_id = :loc)`
+	params := map[string]any{"status": string(f.Status), "loc": f.LocationId}
+	total, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM stock_transfer t`+where, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't count stock transfers: %w", err)
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > maxTransferPage {
+		limit = maxTransferPage
+	}
+	params["limit"], params["offset"] = limit, max(f.Offset, 0)
+	out, err := storeutil.QueryListNamed[entity.StockTransfer](ctx, s.DB,
+		selectTransfer+where+` ORDER BY t.created_at DESC, t.id DESC LIMIT :limit OFFSET :offset`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't list stock transfers: %w", err)
+	}
+	return out, total, nil
+}
+
+const maxTransferPage = 200
+
+// DispatchStockTransfer sends a draft on the road: every line is taken out of the source, all or
+// nothing. A line the source does not hold in full fails the whole dispatch.
+func (s *Store) DispatchStockTransfer(ctx context.Context, id int, username string) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		tr, err := getTransfer(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(tr, entity.StockTransferInTransit); err != nil {
+			return err
+		}
+		from, err := activeLocation(ctx, rep.DB(), tr.FromLocationId)
+		if err != nil {
+			return err
+		}
+		if _, err := activeLocation(ctx, rep.DB(), tr.ToLocationId); err != nil {
+			return err
+		}
+		journal := make([]entity.StockChangeInsert, 0, len(tr.Lines))
+		for _, l := range tr.Lines {
+			for _, sk := range lineSubjects(l) {
+				have, err := sk.subject.qtyAt(ctx, rep.DB(), sk.key, from)
+				if err != nil {
+					return err
+				}
+				if have.LessThan(l.Quantity) {
+					return fmt.Errorf("%w: %s holds %s of %s, the transfer takes %s", entity.ErrInsufficientLocationStock,
+						from.Code, have.String(), lineLabel(l), l.Quantity.String())
+				}
+				if err := sk.subject.add(ctx, rep.DB(), sk.key, from, l.Quantity.Neg()); err != nil {
+					return err
+				}
+				if sk.subject == variantSubject {
+					e, err := transferJournal(ctx, rep.DB(), &tr.StockTransfer, l, from, have, l.Quantity.Neg(), entity.StockChangeReasonTransferOut, username)
+					if err != nil {
+						return err
+					}
+					journal = append(journal, e)
+				}
+			}
+		}
+		if err := setTransferStatus(ctx, rep.DB(), id, entity.StockTransferInTransit, "dispatched", username); err != nil {
+			return err
+		}
+		return rep.Products().RecordStockChange(ctx, journal)
+	})
+}
+
+// ReceiveStockTransfer books a transfer on the road into its destination.
+func (s *Store) ReceiveStockTransfer(ctx context.Context, id int, username string) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		tr, err := getTransfer(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(tr, entity.StockTransferReceived); err != nil {
+			return err
+		}
+		// An archived destination still takes what is already on the road to it: refusing would
+		// leave the goods in no location at all.
+		to, err := getLocation(ctx, rep.DB(), tr.ToLocationId, true)
+		if err != nil {
+			return err
+		}
+		if err := arrive(ctx, rep, tr, to, entity.StockChangeReasonTransferIn, username); err != nil {
+			return err
+		}
+		return setTransferStatus(ctx, rep.DB(), id, entity.StockTransferReceived, "received", username)
+	})
+}
+
+// CancelStockTransfer withdraws a transfer. A draft has moved nothing; a transfer on the road goes
+// back into its source.
+func (s *Store) CancelStockTransfer(ctx context.Context, id int, username string) error {
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		tr, err := getTransfer(ctx, rep.DB(), id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(tr, entity.StockTransferCancelled); err != nil {
+			return err
+		}
+		if tr.Status == entity.StockTransferInTransit {
+			from, err := getLocation(ctx, rep.DB(), tr.FromLocationId, true)
+			if err != nil {
+				return err
+			}
+			if err := arrive(ctx, rep, tr, from, entity.StockChangeReasonTransferIn, username); err != nil {
+				return err
+			}
+		}
+		return setTransferStatus(ctx, rep.DB(), id, entity.StockTransferCancelled, "cancelled", username)
+	})
+}
+
+// arrive puts every line of a transfer on the road into loc, journalling the variants. It runs
+// before the status leaves in_transit, so the default location's "before" still excludes the lines.
+func arrive(ctx context.Context, rep dependency.Repository, tr *entity.StockTransferFull, loc *entity.StockLocation, reason entity.StockChangeReason, username string) error {
+	journal := make([]entity.StockChangeInsert, 0, len(tr.Lines))
+	for _, l := range tr.Lines {
+		for _, sk := range lineSubjects(l) {
+			if sk.subject == variantSubject {
+				before, err := sk.subject.qtyAt(ctx, rep.DB(), sk.key, loc)
+				if err != nil {
+					return err
+				}
+				e, err := transferJournal(ctx, rep.DB(), &tr.StockTransfer, l, loc, before, l.Quantity, reason, username)
+				if err != nil {
+					return err
+				}
+				journal = append(journal, e)
+			}
+			if err := sk.subject.add(ctx, rep.DB(), sk.key, loc, l.Quantity); err != nil {
+				return err
+			}
+		}
+	}
+	return rep.Products().RecordStockChange(ctx, journal)
+}
+
+type lineSubject struct {
+	subject subject
+	key     int
+}
+
+// lineSubjects is what a line moves: its variant, or its material and (when named) its lot.
+func lineSubjects(l entity.StockTransferLine) []lineSubject {
+	if l.ProductSizeId.Valid {
+		return []lineSubject{{variantSubject, int(l.ProductSizeId.Int32)}}
+	}
+	out := []lineSubject{{materialSubject, int(l.MaterialId.Int32)}}
+	if l.LotId.Valid {
+		out = append(out, lineSubject{lotSubject, int(l.LotId.Int32)})
+	}
+	return out
+}
+
+func lineLabel(l entity.StockTransferLine) string {
+	switch {
+	case l.ProductSizeId.Valid:
+		return l.SKU
+	case l.LotId.Valid:
+		return l.MaterialName + " lot " + l.LotCode
+	}
+	return l.MaterialName
+}
+
+// transferJournal builds the journal row of a variant line at one end of a transfer.
+func transferJournal(ctx context.Context, db dependency.DB, tr *entity.StockTransfer, l entity.StockTransferLine, loc *entity.StockLocation, before, delta decimal.Decimal, reason entity.StockChangeReason, username string) (entity.StockChangeInsert, error) {
+	v, err := variantKey(ctx, db, int(l.ProductSizeId.Int32))
+	if err != nil {
+		return entity.StockChangeInsert{}, err
+	}
+	return entity.StockChangeInsert{
+		ProductId:      sql.NullInt32{Int32: int32(v.ProductId), Valid: true},
+		SizeId:         sql.NullInt32{Int32: int32(v.SizeId), Valid: true},
+		Grade:          v.Grade,
+		QuantityDelta:  delta,
+		QuantityBefore: before,
+		QuantityAfter:  before.Add(delta),
+		Source:         string(entity.StockChangeSourceLocationTransfer),
+		Reason:         sql.NullString{String: string(reason), Valid: true},
+		ReferenceId:    sql.NullString{String: "stock_transfer:" + fmt.Sprint(tr.Id), Valid: true},
+		Comment:        sql.NullString{String: entity.StockTransferNumber(tr.Id) + " " + tr.FromLocation + " → " + tr.ToLocation, Valid: true},
+		AdminUsername:  sql.NullString{String: username, Valid: username != ""},
+		LocationId:     sql.NullInt32{Int32: int32(loc.Id), Valid: true},
+	}, nil
+}
+
+type variantRow struct {
+	ProductId int    `db:"product_id"`
+	SizeId    int    `db:"size_id"`
+	Grade     string `db:"grade"`
+}
+
+func variantKey(ctx context.Context, db dependency.DB, productSizeID int) (variantRow, error) {
+	v, err := storeutil.QueryNamedOne[variantRow](ctx, db,
+		`SELECT product_id, size_id, grade FROM product_size WHERE id = :id`, map[string]any{"id": productSizeID})
+	if err != nil {
+		return variantRow{}, fmt.Errorf("can't read variant %d: %w", productSizeID, err)
+	}
+	return v, nil
+}
+
+func getTransfer(ctx context.Context, db dependency.DB, id int, forUpdate bool) (*entity.StockTransferFull, error) {
+	query := selectTransfer + ` WHERE t.id = :id`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	tr, err := storeutil.QueryNamedOne[entity.StockTransfer](ctx, db, query, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", entity.ErrStockTransferNotFound, id)
+		}
+		return nil, fmt.Errorf("can't get stock transfer: %w", err)
+	}
+	lines, err := storeutil.QueryListNamed[entity.StockTransferLine](ctx, db,
+		selectTransferLine+` WHERE l.transfer_id = :id ORDER BY l.id`, map[string]any{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("can't get stock transfer lines: %w", err)
+	}
+	return &entity.StockTransferFull{StockTransfer: tr, Lines: lines}, nil
+}
+
+func checkTransition(tr *entity.StockTransferFull, next entity.StockTransferStatus) error {
+	if !tr.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s is %s", entity.ErrStockTransferState, entity.StockTransferNumber(tr.Id), tr.Status)
+	}
+	return nil
+}
+
+// setTransferStatus moves the header and stamps who did it; step names the <step>_by/_at pair.
+func setTransferStatus(ctx context.Context, db dependency.DB, id int, status entity.StockTransferStatus, step, username string) error {
+	col := strings.ToLower(step) // dispatched | received | cancelled — constants of this file
+	err := storeutil.ExecNamed(ctx, db, `
+		UPDATE stock_transfer SET status = :status, `+col+`_by = :username, `+col+`_at = CURRENT_TIMESTAMP
+		WHERE id = :id`,
+		map[string]any{"id": id, "status": status, "username": username})
+	if err != nil {
+		return fmt.Errorf("can't set stock transfer status: %w", err)
+	}
+	return nil
+}
+
+func nullID(id int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(id), Valid: id > 0}
+}
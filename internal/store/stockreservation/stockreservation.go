@@ -0,0 +1,220 @@
+// Package stockreservation implements the durable stock hold ledger (stock_reservation, 0332)
+// behind the MySQL backend of stockreserve. Every method reads "live" as expires_at > Now(); the
+// abuse limits and TTL policy live in stockreserve, this package only stores and counts.
+package stockreservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// Store implements dependency.StockReservations.
+type Store struct {
+	storeutil.Base
+}
+
+// New creates a new stock reservation store.
+func New(base storeutil.Base) *Store {
+	return &Store{Base: base}
+}
+
+// LockProductSize takes the row lock on the A-grade product_size row of the pair. Every reserve of
+// that pair takes it first inside its transaction, so the availability read and the hold write of
+// two concurrent reserves (on any instance) cannot interleave. A missing variant is not an error:
+// there is nothing to lock and nothing to sell.
+func (s *Store) LockProductSize(ctx context.Context, productID, sizeID int) error {
+	_, err := storeutil.QueryScalarListNamed[int](ctx, s.DB, `
+		SELECT id FROM product_size
+		WHERE product_id = :productId AND size_id = :sizeId AND grade = :grade
+		FOR UPDATE`, map[string]any{
+		"productId": productID,
+		"sizeId":    sizeID,
+		"grade":     entity.VariantGradeA,
+	})
+	if err != nil {
+		return fmt.Errorf("can't lock product size: %w", err)
+	}
+	return nil
+}
+
+// GetStockReservation returns the hold of a session on a pair, live or expired-but-unswept, so the
+// caller can tell a refresh from a fresh reserve. sql.ErrNoRows when there is none.
+func (s *Store) GetStockReservation(ctx context.Context, productID, sizeID int, sessionID string) (*entity.StockReservation, error) {
+	r, err := storeutil.QueryNamedOne[entity.StockReservation](ctx, s.DB, `
+		SELECT id, product_id, size_id, session_id, order_uuid, quantity, refresh_count, expires_at, created_at
+		FROM stock_reservation
+		WHERE product_id = :productId AND size_id = :sizeId AND session_id = :sessionId`, map[string]any{
+		"productId": productID,
+		"sizeId":    sizeID,
+		"sessionId": sessionID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("can't get stock reservation: %w", err)
+	}
+	return &r, nil
+}
+
+// GetReservedQuantity sums the live holds on a pair, excluding excludeSessionID.
+func (s *Store) GetReservedQuantity(ctx context.Context, productID, sizeID int, excludeSessionID string) (decimal.Decimal, error) {
+	type sumRow struct {
+		Reserved decimal.Decimal `db:"reserved"`
+	}
+	row, err := storeutil.QueryNamedOne[sumRow](ctx, s.DB, `
+		SELECT COALESCE(SUM(quantity), 0) AS reserved
+		FROM stock_reservation
+		WHERE product_id = :productId AND size_id = :sizeId
+		  AND session_id <> :sessionId AND expires_at > :now`, map[string]any{
+		"productId": productID,
+		"sizeId":    sizeID,
+		"sessionId": excludeSessionID,
+		"now":       s.Now(),
+	})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("can't sum reserved quantity: %w", err)
+	}
+	return row.Reserved, nil
+}
+
+// CountSessionReservations counts the live holds of a session (the per-cart item cap).
+func (s *Store) CountSessionReservations(ctx context.Context, sessionID string) (int, error) {
+	n, err := storeutil.QueryCountNamed(ctx, s.DB, `
+		SELECT COUNT(*) FROM stock_reservation
+		WHERE session_id = :sessionId AND expires_at > :now`, map[string]any{
+		"sessionId": sessionID,
+		"now":       s.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't count session reservations: %w", err)
+	}
+	return n, nil
+}
+
+// CountReservations counts every live hold (the global capacity cap).
+func (s *Store) CountReservations(ctx context.Context) (int, error) {
+	n, err := storeutil.QueryCountNamed(ctx, s.DB, `
+		SELECT COUNT(*) FROM stock_reservation WHERE expires_at > :now`, map[string]any{
+		"now": s.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't count reservations: %w", err)
+	}
+	return n, nil
+}
+
+// UpsertStockReservation writes the full state of a hold on its (product, size, session) key. An
+// expired row under the same key is overwritten, which is how a returning session starts afresh.
+func (s *Store) UpsertStockReservation(ctx context.Context, r *entity.StockReservationInsert) error {
+	err := storeutil.ExecNamed(ctx, s.DB, `
+		INSERT INTO stock_reservation
+			(product_id, size_id, session_id, order_uuid, quantity, refresh_count, expires_at, created_at)
+		VALUES
+			(:productId, :sizeId, :sessionId, :orderUuid, :quantity, :refreshCount, :expiresAt, :createdAt)
+		ON DUPLICATE KEY UPDATE
+			order_uuid = VALUES(order_uuid),
+			quantity = VALUES(quantity),
+			refresh_count = VALUES(refresh_count),
+			expires_at = VALUES(expires_at),
+			created_at = VALUES(created_at)`, map[string]any{
+		"productId":    r.ProductId,
+		"sizeId":       r.SizeId,
+		"sessionId":    r.SessionId,
+		"orderUuid":    r.OrderUUID,
+		"quantity":     r.Quantity,
+		"refreshCount": r.RefreshCount,
+		"expiresAt":    r.ExpiresAt,
+		"createdAt":    r.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("can't upsert stock reservation: %w", err)
+	}
+	return nil
+}
+
+// CommitSessionReservations hands every live hold of a session to an order: the hold is tagged with
+// the order UUID, its TTL moves to the payment window and its refresh budget resets. Returns the
+// number of holds committed.
+func (s *Store) CommitSessionReservations(ctx context.Context, sessionID, orderUUID string, expiresAt time.Time) (int64, error) {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		UPDATE stock_reservation
+		SET order_uuid = :orderUuid, expires_at = :expiresAt, refresh_count = 0
+		WHERE session_id = :sessionId AND expires_at > :now`, map[string]any{
+		"orderUuid": orderUUID,
+		"expiresAt": expiresAt,
+		"sessionId": sessionID,
+		"now":       s.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't commit session reservations: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteOrderReservations frees every hold of an order (paid, cancelled or expired).
+func (s *Store) DeleteOrderReservations(ctx context.Context, orderUUID string) (int64, error) {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		DELETE FROM stock_reservation WHERE order_uuid = :orderUuid`, map[string]any{
+		"orderUuid": orderUUID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't delete order reservations: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteSessionCartReservations frees the cart holds of a session; holds already committed to an
+// order stay until the order releases them.
+func (s *Store) DeleteSessionCartReservations(ctx context.Context, sessionID string) (int64, error) {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		DELETE FROM stock_reservation WHERE session_id = :sessionId AND order_uuid IS NULL`, map[string]any{
+		"sessionId": sessionID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't delete session reservations: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteExpiredStockReservations removes up to limit expired holds and returns how many went. The
+// batch bound keeps one sweep from holding a long delete lock after an outage.
+func (s *Store) DeleteExpiredStockReservations(ctx context.Context, limit int) (int64, error) {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		DELETE FROM stock_reservation WHERE expires_at <= :now
+		ORDER BY expires_at
+		LIMIT :limit`, map[string]any{
+		"now":   s.Now(),
+		"limit": limit,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't delete expired stock reservations: %w", err)
+	}
+	return n, nil
+}
+
+// GetStockReservationStats summarizes the live holds.
+func (s *Store) GetStockReservationStats(ctx context.Context) (*entity.StockReservationStats, error) {
+	st, err := storeutil.QueryNamedOne[entity.StockReservationStats](ctx, s.DB, `
+		SELECT
+			COUNT(*) AS total,
+			COALESCE(SUM(order_uuid IS NULL), 0) AS cart,
+			COALESCE(SUM(order_uuid IS NOT NULL), 0) AS order_holds,
+			COUNT(DISTINCT session_id) AS active_sessions,
+			COUNT(DISTINCT order_uuid) AS active_orders
+		FROM stock_reservation
+		WHERE expires_at > :now`, map[string]any{
+		"now": s.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't get stock reservation stats: %w", err)
+	}
+	return &st, nil
+}
@@ -22,8 +22,10 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/store/account"
 	"github.com/jekabolt/grbpwr-manager/internal/store/accounting"
 	"github.com/jekabolt/grbpwr-manager/internal/store/admin"
+	"github.com/jekabolt/grbpwr-manager/internal/store/audit"
 	"github.com/jekabolt/grbpwr-manager/internal/store/bqcache"
 	"github.com/jekabolt/grbpwr-manager/internal/store/campaign"
+	"github.com/jekabolt/grbpwr-manager/internal/store/checkoutrecovery"
 	"github.com/jekabolt/grbpwr-manager/internal/store/communication"
 	"github.com/jekabolt/grbpwr-manager/internal/store/content"
 	"github.com/jekabolt/grbpwr-manager/internal/store/dictionary"
@@ -38,15 +40,25 @@ import (
 	"github.com/jekabolt/grbpwr-manager/internal/store/model"
 	"github.com/jekabolt/grbpwr-manager/internal/store/order"
 	"github.com/jekabolt/grbpwr-manager/internal/store/patternobject"
+	"github.com/jekabolt/grbpwr-manager/internal/store/payroll"
+	"github.com/jekabolt/grbpwr-manager/internal/store/pricing"
 	"github.com/jekabolt/grbpwr-manager/internal/store/product"
 	"github.com/jekabolt/grbpwr-manager/internal/store/productionrun"
 	"github.com/jekabolt/grbpwr-manager/internal/store/promo"
+	"github.com/jekabolt/grbpwr-manager/internal/store/purchaseorder"
+	"github.com/jekabolt/grbpwr-manager/internal/store/returns"
+	"github.com/jekabolt/grbpwr-manager/internal/store/salesinvoice"
 	"github.com/jekabolt/grbpwr-manager/internal/store/sample"
 	"github.com/jekabolt/grbpwr-manager/internal/store/settings"
+	"github.com/jekabolt/grbpwr-manager/internal/store/sizing"
+	"github.com/jekabolt/grbpwr-manager/internal/store/stocklocation"
+	"github.com/jekabolt/grbpwr-manager/internal/store/stockreservation"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storecredit"
 	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
 	"github.com/jekabolt/grbpwr-manager/internal/store/support"
 	"github.com/jekabolt/grbpwr-manager/internal/store/task"
 	"github.com/jekabolt/grbpwr-manager/internal/store/techcard"
+	"github.com/jekabolt/grbpwr-manager/internal/store/wishlist"
 	"github.com/jekabolt/grbpwr-manager/internal/store/workshop"
 	"github.com/jmoiron/sqlx"
 	migrate "github.com/rubenv/sql-migrate"
@@ -111,6 +123,8 @@ type MYSQLStore struct {
 	dictionaryStore    *dictionary.Store
 	comm               *communication.Store
 	supportStore       *support.Store
+	returnsStore       *returns.Store
+	storeCreditStore   *storecredit.Store
 	adminStore         *admin.Store
 	promoStore         *promo.Store
 	langStore          *language.Store
@@ -124,10 +138,20 @@ type MYSQLStore struct {
 	techCardStore      *techcard.Store
 	productionRunStore *productionrun.Store
 	materialStockStore *inventory.Store
+	purchaseOrderStore *purchaseorder.Store
+	salesInvoiceStore  *salesinvoice.Store
 	sampleStore        *sample.Store
 	accounting         *accounting.Store
 	patternObjectStore *patternobject.Store
+	stockResStore      *stockreservation.Store
 	workshopStore      *workshop.Store
+	auditStore         *audit.Store
+	payrollStore       *payroll.Store
+	stockLocationStore *stocklocation.Store
+	pricingStore       *pricing.Store
+	wishlistStore      *wishlist.Store
+	sizingStore        *sizing.Store
+	checkoutRecovery   *checkoutrecovery.Store
 }
 
 // resolveCertPath resolves @certs paths to the config/certs directory
@@ -409,9 +433,11 @@ func NewForTest(ctx context.Context, cfg Config) (*MYSQLStore, error) {
 func initSubStores(ms *MYSQLStore) {
 	base := storeutil.Base{DB: ms.db, Now: ms.Now}
 	ms.langStore = language.New(base)
-	ms.promoStore = promo.New(base)
+	ms.promoStore = promo.New(base, ms.Tx)
 	ms.comm = communication.New(base)
 	ms.supportStore = support.New(base)
+	ms.returnsStore = returns.New(base, ms.Tx)
+	ms.storeCreditStore = storecredit.New(base, ms.Tx)
 	ms.adminStore = admin.New(base, ms.Tx)
 	ms.settingsStore = settings.New(base, ms.Tx, func() dependency.Repository { return ms })
 	ms.dictionaryStore = dictionary.New(base, ms.Tx)
@@ -434,18 +460,30 @@ func initSubStores(ms *MYSQLStore) {
 	ms.techCardStore = techcard.New(base, ms.Tx, ms.readTx, func() dependency.Repository { return ms })
 	ms.productionRunStore = productionrun.New(base, ms.Tx)
 	ms.materialStockStore = inventory.New(base, ms.Tx)
+	ms.purchaseOrderStore = purchaseorder.New(base, ms.Tx)
+	ms.salesInvoiceStore = salesinvoice.New(base, ms.Tx)
 	ms.sampleStore = sample.New(base, ms.Tx)
 	ms.patternObjectStore = patternobject.New(base)
+	ms.stockResStore = stockreservation.New(base)
 	ms.workshopStore = workshop.New(base, ms.Tx)
+	ms.auditStore = audit.New(base)
+	ms.payrollStore = payroll.New(base, ms.Tx)
+	ms.stockLocationStore = stocklocation.New(base, ms.Tx)
+	ms.pricingStore = pricing.New(base, ms.Tx)
+	ms.wishlistStore = wishlist.New(base, ms.Tx)
+	ms.sizingStore = sizing.New(base, ms.Tx)
+	ms.checkoutRecovery = checkoutrecovery.New(base, ms.Tx)
 }
 
 // initSubStoresForTx initializes sub-stores for a transactional MYSQLStore.
 func initSubStoresForTx(txStore *MYSQLStore, outerTx func(context.Context, func(context.Context, dependency.Repository) error) error) {
 	base := storeutil.Base{DB: txStore.db, Now: txStore.Now}
 	txStore.langStore = language.New(base)
-	txStore.promoStore = promo.New(base)
+	txStore.promoStore = promo.New(base, outerTx)
 	txStore.comm = communication.New(base)
 	txStore.supportStore = support.New(base)
+	txStore.returnsStore = returns.New(base, outerTx)
+	txStore.storeCreditStore = storecredit.New(base, outerTx)
 	txStore.adminStore = admin.New(base, outerTx)
 	txStore.settingsStore = settings.New(base, outerTx, func() dependency.Repository { return txStore })
 	txStore.dictionaryStore = dictionary.New(base, outerTx)
@@ -468,9 +506,19 @@ func initSubStoresForTx(txStore *MYSQLStore, outerTx func(context.Context, func(
 	txStore.techCardStore = techcard.New(base, outerTx, outerTx, func() dependency.Repository { return txStore })
 	txStore.productionRunStore = productionrun.New(base, outerTx)
 	txStore.materialStockStore = inventory.New(base, outerTx)
+	txStore.purchaseOrderStore = purchaseorder.New(base, outerTx)
+	txStore.salesInvoiceStore = salesinvoice.New(base, outerTx)
 	txStore.sampleStore = sample.New(base, outerTx)
 	txStore.patternObjectStore = patternobject.New(base)
+	txStore.stockResStore = stockreservation.New(base)
 	txStore.workshopStore = workshop.New(base, outerTx)
+	txStore.auditStore = audit.New(base)
+	txStore.payrollStore = payroll.New(base, outerTx)
+	txStore.stockLocationStore = stocklocation.New(base, outerTx)
+	txStore.pricingStore = pricing.New(base, outerTx)
+	txStore.wishlistStore = wishlist.New(base, outerTx)
+	txStore.sizingStore = sizing.New(base, outerTx)
+	txStore.checkoutRecovery = checkoutrecovery.New(base, outerTx)
 }
 
 func (ms *MYSQLStore) Close() {
@@ -542,17 +590,45 @@ func (ms *MYSQLStore) Membership() dependency.Membership         { return ms.mem
 func (ms *MYSQLStore) Models() dependency.Models                 { return ms.modelStore }
 func (ms *MYSQLStore) Fittings() dependency.Fittings             { return ms.fittingStore }
 func (ms *MYSQLStore) PatternObjects() dependency.PatternObjects { return ms.patternObjectStore }
+func (ms *MYSQLStore) Audit() dependency.Audit                   { return ms.auditStore }
+func (ms *MYSQLStore) Payroll() dependency.Payroll               { return ms.payrollStore }
 func (ms *MYSQLStore) Tasks() dependency.Tasks                   { return ms.taskStore }
 func (ms *MYSQLStore) Files() dependency.Files                   { return ms.filesStore }
 func (ms *MYSQLStore) Fulfillment() dependency.Fulfillment       { return ms.fulfillmentStore }
 func (ms *MYSQLStore) TechCards() dependency.TechCards           { return ms.techCardStore }
 func (ms *MYSQLStore) ProductionRuns() dependency.ProductionRuns { return ms.productionRunStore }
 func (ms *MYSQLStore) MaterialStock() dependency.MaterialStock   { return ms.materialStockStore }
+func (ms *MYSQLStore) PurchaseOrders() dependency.PurchaseOrders { return ms.purchaseOrderStore }
+func (ms *MYSQLStore) SalesInvoices() dependency.SalesInvoices   { return ms.salesInvoiceStore }
 func (ms *MYSQLStore) Accounting() dependency.Accounting         { return ms.accounting }
 func (ms *MYSQLStore) Samples() dependency.Samples               { return ms.sampleStore }
 func (ms *MYSQLStore) StorefrontAccount() dependency.StorefrontAccount {
 	return ms.accountStore
 }
+func (ms *MYSQLStore) StockReservations() dependency.StockReservations {
+	return ms.stockResStore
+}
+func (ms *MYSQLStore) Returns() dependency.Returns {
+	return ms.returnsStore
+}
+func (ms *MYSQLStore) StoreCredit() dependency.StoreCredit {
+	return ms.storeCreditStore
+}
+func (ms *MYSQLStore) StockLocations() dependency.StockLocations {
+	return ms.stockLocationStore
+}
+func (ms *MYSQLStore) Pricing() dependency.Pricing {
+	return ms.pricingStore
+}
+func (ms *MYSQLStore) Wishlists() dependency.Wishlists {
+	return ms.wishlistStore
+}
+func (ms *MYSQLStore) Sizing() dependency.Sizing {
+	return ms.sizingStore
+}
+func (ms *MYSQLStore) CheckoutRecoveries() dependency.CheckoutRecoveries {
+	return ms.checkoutRecovery
+}
 
 // ErrOrderItemsUpdated is re-exported from the order sub-package for backward compatibility.
 var ErrOrderItemsUpdated = order.ErrOrderItemsUpdated
@@ -0,0 +1,557 @@
+// Package storecredit implements gift cards and customer store credit (store_credit_wallet and its
+// append-only store_credit_ledger, 0334). Every movement locks the wallet row, appends a ledger row
+// carrying the balance after it and updates the wallet in the same transaction.
+//
+// The order-flow methods (RedeemForOrder, ReleaseForOrder, ClampForOrder, IssueGiftCardsForOrder,
+// CreditAccount, CreditWallet) run on the caller's connection and must be called inside the order
+// store's transaction so the credit moves atomically with the order. The admin methods open their
+// own transaction.
+package storecredit
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/currency"
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+	"github.com/shopspring/decimal"
+)
+
+// TxFunc runs f within a transaction.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.StoreCredit.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a new store credit store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+const selectWallet = `
+	SELECT w.id, w.kind, w.code, w.account_email, w.currency, w.balance, w.initial_amount, w.status,
+	       w.source_order_id, w.source_order_item_id, so.uuid AS source_order_uuid, w.purchaser_email,
+	       w.created_at, w.updated_at
+	FROM store_credit_wallet w
+	LEFT JOIN customer_order so ON so.id = w.source_order_id`
+
+// giftCardCodeAlphabet leaves out 0/O and 1/I/L so a code read off a card cannot be mistyped.
+const giftCardCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// newGiftCardCode returns a random GC-XXXX-XXXX-XXXX code (~74 bits).
+func newGiftCardCode() (string, error) {
+	var b strings.Builder
+	b.WriteString("GC")
+	max := big.NewInt(int64(len(giftCardCodeAlphabet)))
+	for i := 0; i < 12; i++ {
+		if i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("can't generate gift card code: %w", err)
+		}
+		b.WriteByte(giftCardCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// lockWallet row-locks a wallet and returns it. sql.ErrNoRows when it does not exist.
+func (s *Store) lockWallet(ctx context.Context, walletId int) (*entity.StoreCreditWallet, error) {
+	w, err := storeutil.QueryNamedOne[entity.StoreCreditWallet](ctx, s.DB,
+		`SELECT * FROM store_credit_wallet WHERE id = :id FOR UPDATE`, map[string]any{"id": walletId})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("can't lock store credit wallet: %w", err)
+	}
+	return &w, nil
+}
+
+// move appends a ledger row for a locked wallet and updates its balance. A movement that would take
+// the balance below zero is refused.
+func (s *Store) move(ctx context.Context, w *entity.StoreCreditWallet, entryType entity.StoreCreditEntryType, amount decimal.Decimal, orderId int, note, createdBy string) error {
+	after := w.Balance.Add(amount)
+	if after.IsNegative() {
+		return &entity.ValidationError{
+			Message: fmt.Sprintf("insufficient store credit balance: %s %s available", w.Balance.StringFixed(2), w.Currency),
+			Field:   "amount",
+		}
+	}
+	if createdBy == "" {
+		createdBy = "system"
+	}
+	err := storeutil.ExecNamed(ctx, s.DB, `
+		INSERT INTO store_credit_ledger (wallet_id, entry_type, amount, balance_after, order_id, note, created_by)
+		VALUES (:walletId, :entryType, :amount, :balanceAfter, :orderId, :note, :createdBy)`, map[string]any{
+		"walletId":     w.Id,
+		"entryType":    entryType,
+		"amount":       amount,
+		"balanceAfter": after,
+		"orderId":      sql.NullInt32{Int32: int32(orderId), Valid: orderId > 0},
+		"note":         sql.NullString{String: note, Valid: note != ""},
+		"createdBy":    createdBy,
+	})
+	if err != nil {
+		return fmt.Errorf("can't insert store credit ledger entry: %w", err)
+	}
+	err = storeutil.ExecNamed(ctx, s.DB, `UPDATE store_credit_wallet SET balance = :balance WHERE id = :id`,
+		map[string]any{"balance": after, "id": w.Id})
+	if err != nil {
+		return fmt.Errorf("can't update store credit balance: %w", err)
+	}
+	w.Balance = after
+	return nil
+}
+
+// resolveRedemption finds and locks the wallet a checkout asked to spend.
+func (s *Store) resolveRedemption(ctx context.Context, r entity.StoreCreditRedemption, cur string) (*entity.StoreCreditWallet, error) {
+	var (
+		query  string
+		params map[string]any
+		field  string
+	)
+	if r.GiftCardCode != "" {
+		field = "gift_card_code"
+		query = `SELECT id FROM store_credit_wallet WHERE kind = :kind AND code = :code`
+		params = map[string]any{"kind": entity.StoreCreditWalletGiftCard, "code": entity.NormalizeGiftCardCode(r.GiftCardCode)}
+	} else {
+		field = "store_credit"
+		query = `SELECT id FROM store_credit_wallet WHERE kind = :kind AND account_email = :email AND currency = :currency`
+		params = map[string]any{
+			"kind":     entity.StoreCreditWalletAccount,
+			"email":    strings.ToLower(strings.TrimSpace(r.AccountEmail)),
+			"currency": strings.ToUpper(cur),
+		}
+	}
+	ids, err := storeutil.QueryScalarListNamed[int](ctx, s.DB, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't find store credit wallet: %w", err)
+	}
+	if len(ids) == 0 {
+		if field == "gift_card_code" {
+			return nil, &entity.ValidationError{Message: "gift card not found", Field: field}
+		}
+		return nil, &entity.ValidationError{Message: "no store credit available", Field: field}
+	}
+	w, err := s.lockWallet(ctx, ids[0])
+	if err != nil {
+		return nil, err
+	}
+	if w.Status != entity.StoreCreditWalletActive {
+		return nil, &entity.ValidationError{Message: "gift card is disabled", Field: field}
+	}
+	if !strings.EqualFold(w.Currency, cur) {
+		return nil, &entity.ValidationError{
+			Message: fmt.Sprintf("gift card is in %s and cannot pay a %s order", w.Currency, strings.ToUpper(cur)),
+			Field:   field,
+		}
+	}
+	if !w.Balance.IsPositive() {
+		return nil, &entity.ValidationError{Message: "gift card has no balance left", Field: field}
+	}
+	return w, nil
+}
+
+// RedeemForOrder spends credit as a tender on a freshly inserted order. It applies as much as the
+// wallet holds, but leaves at least the currency's card minimum for Stripe to charge, so every order
+// still goes through the one payment path; an order total at or below that minimum fails with a
+// ValidationError naming it. The amount applied is recorded on the order and returned.
+func (s *Store) RedeemForOrder(ctx context.Context, orderId int, r entity.StoreCreditRedemption, total decimal.Decimal, cur string) (decimal.Decimal, error) {
+	w, err := s.resolveRedemption(ctx, r, cur)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	room := total.Sub(currency.Minimum(cur))
+	applied := decimal.Min(w.Balance, room).Truncate(currency.DecimalPlaces(cur))
+	if !applied.IsPositive() {
+		return decimal.Zero, &entity.ValidationError{
+			Message: fmt.Sprintf("store credit cannot pay the whole order: at least %s %s must be paid by card",
+				currency.Minimum(cur).StringFixed(currency.DecimalPlaces(cur)), strings.ToUpper(cur)),
+			Field: "store_credit",
+		}
+	}
+	if err := s.move(ctx, w, entity.StoreCreditEntryRedeem, applied.Neg(), orderId, "", ""); err != nil {
+		return decimal.Zero, err
+	}
+	err = storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE customer_order SET store_credit_applied = :applied, store_credit_wallet_id = :walletId
+		WHERE id = :orderId`, map[string]any{
+		"applied":  applied,
+		"walletId": w.Id,
+		"orderId":  orderId,
+	})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("can't record store credit on order: %w", err)
+	}
+	return applied, nil
+}
+
+type orderCredit struct {
+	Applied  decimal.Decimal `db:"store_credit_applied"`
+	WalletId sql.NullInt32   `db:"store_credit_wallet_id"`
+}
+
+func (s *Store) getOrderCredit(ctx context.Context, orderId int) (orderCredit, error) {
+	oc, err := storeutil.QueryNamedOne[orderCredit](ctx, s.DB, `
+		SELECT store_credit_applied, store_credit_wallet_id FROM customer_order WHERE id = :orderId`,
+		map[string]any{"orderId": orderId})
+	if err != nil {
+		return oc, fmt.Errorf("can't get order store credit: %w", err)
+	}
+	return oc, nil
+}
+
+// ReleaseForOrder gives back all credit an unpaid order had taken (cancel, payment expiry). A no-op
+// for an order without credit, so it is safe on every cancel path.
+func (s *Store) ReleaseForOrder(ctx context.Context, orderId int, note string) error {
+	oc, err := s.getOrderCredit(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	if !oc.Applied.IsPositive() || !oc.WalletId.Valid {
+		return nil
+	}
+	w, err := s.lockWallet(ctx, int(oc.WalletId.Int32))
+	if err != nil {
+		return err
+	}
+	if err := s.move(ctx, w, entity.StoreCreditEntryRelease, oc.Applied, orderId, note, ""); err != nil {
+		return err
+	}
+	err = storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE customer_order SET store_credit_applied = 0, store_credit_wallet_id = NULL WHERE id = :orderId`,
+		map[string]any{"orderId": orderId})
+	if err != nil {
+		return fmt.Errorf("can't clear store credit on order: %w", err)
+	}
+	return nil
+}
+
+// ClampForOrder re-fits applied credit to an order total that changed before payment (items removed
+// or repriced): whatever no longer fits under the card minimum goes back to the wallet. Returns the
+// credit still applied.
+func (s *Store) ClampForOrder(ctx context.Context, orderId int, total decimal.Decimal, cur string) (decimal.Decimal, error) {
+	oc, err := s.getOrderCredit(ctx, orderId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !oc.Applied.IsPositive() || !oc.WalletId.Valid {
+		return decimal.Zero, nil
+	}
+	fit := decimal.Max(total.Sub(currency.Minimum(cur)), decimal.Zero).Truncate(currency.DecimalPlaces(cur))
+	if oc.Applied.LessThanOrEqual(fit) {
+		return oc.Applied, nil
+	}
+	w, err := s.lockWallet(ctx, int(oc.WalletId.Int32))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if err := s.move(ctx, w, entity.StoreCreditEntryRelease, oc.Applied.Sub(fit), orderId, "order total reduced", ""); err != nil {
+		return decimal.Zero, err
+	}
+	err = storeutil.ExecNamed(ctx, s.DB, `
+		UPDATE customer_order
+		SET store_credit_applied = :applied,
+		    store_credit_wallet_id = IF(:applied > 0, store_credit_wallet_id, NULL)
+		WHERE id = :orderId`, map[string]any{"applied": fit, "orderId": orderId})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("can't update store credit on order: %w", err)
+	}
+	return fit, nil
+}
+
+type giftCardLine struct {
+	Id         int             `db:"id"`
+	Quantity   decimal.Decimal `db:"quantity"`
+	UnitAmount decimal.Decimal `db:"product_price_with_sale"`
+	Currency   string          `db:"currency"`
+	BuyerEmail sql.NullString  `db:"buyer_email"`
+}
+
+// IssueGiftCardsForOrder issues one gift card per unit of every gift-card line of a paid order, with
+// the paid unit price as face value. Idempotent: an order whose cards already exist gets them back
+// unchanged, so a replayed payment webhook cannot issue twice.
+func (s *Store) IssueGiftCardsForOrder(ctx context.Context, orderId int) ([]entity.StoreCreditWallet, error) {
+	existing, err := s.GetWalletsBySourceOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return existing, nil
+	}
+	lines, err := storeutil.QueryListNamed[giftCardLine](ctx, s.DB, `
+		SELECT oi.id, oi.quantity, oi.product_price_with_sale, co.currency, b.email AS buyer_email
+		FROM order_item oi
+		JOIN gift_card_product gcp ON gcp.product_id = oi.product_id
+		JOIN customer_order co ON co.id = oi.order_id
+		LEFT JOIN buyer b ON b.order_id = oi.order_id
+		WHERE oi.order_id = :orderId
+		ORDER BY oi.id`, map[string]any{"orderId": orderId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get gift card lines: %w", err)
+	}
+	for _, l := range lines {
+		amount := currency.Round(l.UnitAmount, l.Currency)
+		if !amount.IsPositive() {
+			continue
+		}
+		for i := int64(0); i < l.Quantity.IntPart(); i++ {
+			code, err := newGiftCardCode()
+			if err != nil {
+				return nil, err
+			}
+			id, err := storeutil.ExecNamedLastId(ctx, s.DB, `
+				INSERT INTO store_credit_wallet
+					(kind, code, currency, balance, initial_amount, status, source_order_id, source_order_item_id, purchaser_email)
+				VALUES (:kind, :code, :currency, 0, :amount, :status, :orderId, :orderItemId, :purchaser)`, map[string]any{
+				"kind":        entity.StoreCreditWalletGiftCard,
+				"code":        code,
+				"currency":    strings.ToUpper(l.Currency),
+				"amount":      amount,
+				"status":      entity.StoreCreditWalletActive,
+				"orderId":     orderId,
+				"orderItemId": l.Id,
+				"purchaser":   l.BuyerEmail,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("can't insert gift card: %w", err)
+			}
+			w, err := s.lockWallet(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.move(ctx, w, entity.StoreCreditEntryIssue, amount, orderId, "", ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.GetWalletsBySourceOrder(ctx, orderId)
+}
+
+// CreditAccount adds credit to a customer's account wallet in a currency, creating the wallet on its
+// first credit.
+func (s *Store) CreditAccount(ctx context.Context, email, cur string, amount decimal.Decimal, orderId int, entryType entity.StoreCreditEntryType, note, createdBy string) (*entity.StoreCreditWallet, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	cur = strings.ToUpper(cur)
+	if email == "" {
+		return nil, &entity.ValidationError{Message: "store credit needs a customer email", Field: "email"}
+	}
+	// The no-op upsert makes a concurrent first credit for the same (email, currency) collide on the
+	// unique key instead of creating two wallets.
+	err := storeutil.ExecNamed(ctx, s.DB, `
+		INSERT INTO store_credit_wallet (kind, account_email, currency, status)
+		VALUES (:kind, :email, :currency, :status)
+		ON DUPLICATE KEY UPDATE id = id`, map[string]any{
+		"kind":     entity.StoreCreditWalletAccount,
+		"email":    email,
+		"currency": cur,
+		"status":   entity.StoreCreditWalletActive,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't create store credit wallet: %w", err)
+	}
+	ids, err := storeutil.QueryScalarListNamed[int](ctx, s.DB, `
+		SELECT id FROM store_credit_wallet WHERE kind = :kind AND account_email = :email AND currency = :currency`,
+		map[string]any{"kind": entity.StoreCreditWalletAccount, "email": email, "currency": cur})
+	if err != nil {
+		return nil, fmt.Errorf("can't find store credit wallet: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("store credit wallet for %s %s not found after insert", email, cur)
+	}
+	return s.CreditWallet(ctx, ids[0], amount, orderId, entryType, note, createdBy)
+}
+
+// CreditWallet moves a signed amount on an existing wallet.
+func (s *Store) CreditWallet(ctx context.Context, walletId int, amount decimal.Decimal, orderId int, entryType entity.StoreCreditEntryType, note, createdBy string) (*entity.StoreCreditWallet, error) {
+	if !entity.ValidStoreCreditEntryTypes[entryType] {
+		return nil, fmt.Errorf("unknown store credit entry type %q", entryType)
+	}
+	w, err := s.lockWallet(ctx, walletId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.move(ctx, w, entryType, amount, orderId, note, createdBy); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// AdjustWallet applies a manual admin correction in its own transaction.
+func (s *Store) AdjustWallet(ctx context.Context, walletId int, amount decimal.Decimal, note, adjustedBy string) (*entity.StoreCreditWalletFull, error) {
+	if err := entity.ValidateStoreCreditAdjustment(amount, note); err != nil {
+		return nil, err
+	}
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		_, err := rep.StoreCredit().CreditWallet(ctx, walletId, amount, 0, entity.StoreCreditEntryAdjustment, note, adjustedBy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWalletById(ctx, walletId)
+}
+
+// SetWalletStatus enables or disables spending from a wallet. A disabled gift card keeps its balance
+// (and its liability) until re-enabled or adjusted to zero.
+func (s *Store) SetWalletStatus(ctx context.Context, walletId int, status entity.StoreCreditWalletStatus) error {
+	if !entity.ValidStoreCreditWalletStatuses[status] {
+		return &entity.ValidationError{Message: fmt.Sprintf("unknown wallet status %q", status), Field: "status"}
+	}
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `UPDATE store_credit_wallet SET status = :status WHERE id = :id`,
+		map[string]any{"status": status, "id": walletId})
+	if err != nil {
+		return fmt.Errorf("can't update store credit wallet status: %w", err)
+	}
+	if n == 0 {
+		if _, err := s.GetWalletById(ctx, walletId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetWalletById returns a wallet with its ledger. sql.ErrNoRows when it does not exist.
+func (s *Store) GetWalletById(ctx context.Context, walletId int) (*entity.StoreCreditWalletFull, error) {
+	w, err := storeutil.QueryNamedOne[entity.StoreCreditWallet](ctx, s.DB, selectWallet+` WHERE w.id = :id`,
+		map[string]any{"id": walletId})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("can't get store credit wallet: %w", err)
+	}
+	ledger, err := storeutil.QueryListNamed[entity.StoreCreditLedgerEntry](ctx, s.DB, `
+		SELECT l.id, l.wallet_id, l.entry_type, l.amount, l.balance_after, l.order_id, co.uuid AS order_uuid,
+		       l.note, l.created_by, l.created_at
+		FROM store_credit_ledger l
+		LEFT JOIN customer_order co ON co.id = l.order_id
+		WHERE l.wallet_id = :id
+		ORDER BY l.id`, map[string]any{"id": walletId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get store credit ledger: %w", err)
+	}
+	return &entity.StoreCreditWalletFull{StoreCreditWallet: w, Ledger: ledger}, nil
+}
+
+// GetWalletByCode returns a gift card by its code. sql.ErrNoRows when it does not exist.
+func (s *Store) GetWalletByCode(ctx context.Context, code string) (*entity.StoreCreditWallet, error) {
+	w, err := storeutil.QueryNamedOne[entity.StoreCreditWallet](ctx, s.DB,
+		selectWallet+` WHERE w.kind = :kind AND w.code = :code`, map[string]any{
+			"kind": entity.StoreCreditWalletGiftCard,
+			"code": entity.NormalizeGiftCardCode(code),
+		})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("can't get gift card: %w", err)
+	}
+	return &w, nil
+}
+
+// GetAccountWallets returns a customer's store credit wallets, one per currency.
+func (s *Store) GetAccountWallets(ctx context.Context, email string) ([]entity.StoreCreditWallet, error) {
+	ws, err := storeutil.QueryListNamed[entity.StoreCreditWallet](ctx, s.DB,
+		selectWallet+` WHERE w.kind = :kind AND w.account_email = :email ORDER BY w.currency`, map[string]any{
+			"kind":  entity.StoreCreditWalletAccount,
+			"email": strings.ToLower(strings.TrimSpace(email)),
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't get account wallets: %w", err)
+	}
+	return ws, nil
+}
+
+// GetWalletsBySourceOrder returns the gift cards an order bought.
+func (s *Store) GetWalletsBySourceOrder(ctx context.Context, orderId int) ([]entity.StoreCreditWallet, error) {
+	ws, err := storeutil.QueryListNamed[entity.StoreCreditWallet](ctx, s.DB,
+		selectWallet+` WHERE w.source_order_id = :orderId ORDER BY w.id`, map[string]any{"orderId": orderId})
+	if err != nil {
+		return nil, fmt.Errorf("can't get order gift cards: %w", err)
+	}
+	return ws, nil
+}
+
+// GetWalletsPaged lists wallets for the admin, newest first, with the total count.
+func (s *Store) GetWalletsPaged(ctx context.Context, limit, offset int, f entity.StoreCreditWalletFilters) ([]entity.StoreCreditWallet, int, error) {
+	where := []string{"1 = 1"}
+	params := map[string]any{"limit": limit, "offset": offset}
+	if f.Kind != nil {
+		where = append(where, "w.kind = :kind")
+		params["kind"] = *f.Kind
+	}
+	if f.Status != nil {
+		where = append(where, "w.status = :status")
+		params["status"] = *f.Status
+	}
+	if f.Email != "" {
+		where = append(where, "(w.account_email = :email OR w.purchaser_email = :email)")
+		params["email"] = strings.ToLower(strings.TrimSpace(f.Email))
+	}
+	if f.Code != "" {
+		where = append(where, "w.code = :code")
+		params["code"] = entity.NormalizeGiftCardCode(f.Code)
+	}
+	cond := " WHERE " + strings.Join(where, " AND ")
+
+	total, err := storeutil.QueryCountNamed(ctx, s.DB, `SELECT COUNT(*) FROM store_credit_wallet w`+cond, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't count store credit wallets: %w", err)
+	}
+	ws, err := storeutil.QueryListNamed[entity.StoreCreditWallet](ctx, s.DB,
+		selectWallet+cond+` ORDER BY w.id DESC LIMIT :limit OFFSET :offset`, params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't get store credit wallets: %w", err)
+	}
+	return ws, total, nil
+}
+
+// SetGiftCardProduct marks or unmarks a colourway as a gift card. Only orders placed afterwards are
+// affected: the designation is read when the order is created and paid.
+func (s *Store) SetGiftCardProduct(ctx context.Context, productId int, giftCard bool) error {
+	var err error
+	if giftCard {
+		err = storeutil.ExecNamed(ctx, s.DB, `
+			INSERT INTO gift_card_product (product_id) VALUES (:productId)
+			ON DUPLICATE KEY UPDATE product_id = product_id`, map[string]any{"productId": productId})
+	} else {
+		err = storeutil.ExecNamed(ctx, s.DB, `DELETE FROM gift_card_product WHERE product_id = :productId`,
+			map[string]any{"productId": productId})
+	}
+	if err != nil {
+		return fmt.Errorf("can't set gift card product: %w", err)
+	}
+	return nil
+}
+
+// GetGiftCardProductIds returns which of the given products are gift cards; all of them when ids is empty.
+func (s *Store) GetGiftCardProductIds(ctx context.Context, productIds []int) (map[int]bool, error) {
+	query := `SELECT product_id FROM gift_card_product`
+	params := map[string]any{}
+	if len(productIds) > 0 {
+		query += ` WHERE product_id IN (:ids)`
+		params["ids"] = productIds
+	}
+	ids, err := storeutil.QueryScalarListNamed[int](ctx, s.DB, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't get gift card products: %w", err)
+	}
+	out := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, nil
+}
@@ -406,6 +406,12 @@ func upsertTechCardPieces(ctx context.Context, db dependency.DB, tcID int, piece
 			map[string]any{"card": tcID, "keys": doomedKeys}); err != nil {
 			return fmt.Errorf("drop measured areas of deleted cut-pieces: %w", err)
 		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			DELETE FROM tech_card_pattern_grade_rule
+			WHERE tech_card_id = :card AND piece_line_key IN (:keys)`,
+			map[string]any{"card": tcID, "keys": doomedKeys}); err != nil {
+			return fmt.Errorf("drop grade rules of deleted cut-pieces: %w", err)
+		}
 	}
 
 	// Delete pieces that vanished from the payload.
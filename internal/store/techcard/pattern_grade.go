@@ -0,0 +1,98 @@
+package techcard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// GetTechCardPatternGradeRules returns one fabric scope's point grade rules (0346), ordered by piece
+// and point. A scope nobody has graded comes back with no rules, not an error.
+func (s *Store) GetTechCardPatternGradeRules(ctx context.Context, techCardID int, scopeKey string) (*entity.PatternGradeRuleSet, error) {
+	scopeKey = strings.TrimSpace(scopeKey)
+	rows, err := storeutil.QueryListNamed[struct {
+		entity.PatternGradeRule
+		UpdatedBy string    `db:"updated_by"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}](ctx, s.DB, `
+		SELECT piece_line_key, point_no, dx_cm, dy_cm, updated_by, updated_at
+		FROM tech_card_pattern_grade_rule
+		WHERE tech_card_id = :id AND scope_key = :scope
+		ORDER BY piece_line_key, point_no`, map[string]any{"id": techCardID, "scope": scopeKey})
+	if err != nil {
+		return nil, fmt.Errorf("load grade rules of tech card %d: %w", techCardID, err)
+	}
+	out := &entity.PatternGradeRuleSet{TechCardId: techCardID, ScopeKey: scopeKey, Rules: make([]entity.PatternGradeRule, 0, len(rows))}
+	for _, r := range rows {
+		out.Rules = append(out.Rules, r.PatternGradeRule)
+		if r.UpdatedAt.After(out.UpdatedAt) {
+			out.UpdatedBy, out.UpdatedAt = r.UpdatedBy, r.UpdatedAt
+		}
+	}
+	return out, nil
+}
+
+// SaveTechCardPatternGradeRules replaces ONE fabric scope's point grade rules. Every rule must name a
+// cut piece of the card; whether its point exists is a question for the base contour, which lives
+// in the files and is answered by the grading itself. A released card is frozen like its areas.
+func (s *Store) SaveTechCardPatternGradeRules(ctx context.Context, in entity.PatternGradeRuleWrite) error {
+	in.ScopeKey = strings.TrimSpace(in.ScopeKey)
+	if err := entity.ValidatePatternGradeRules(in); err != nil {
+		return err
+	}
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		// The same order as SaveTechCardPieceAreas: the card row first, then the freeze check.
+		if err := lockTechCardRow(ctx, db, in.TechCardId); err != nil {
+			return err
+		}
+		if err := storeutil.RequireMutableTechCard(ctx, db, in.TechCardId); err != nil {
+			return err
+		}
+		pieces, err := storeutil.QueryListNamed[struct {
+			LineKey string `db:"line_key"`
+		}](ctx, db, `SELECT COALESCE(line_key, '') AS line_key FROM tech_card_piece WHERE tech_card_id = :id`,
+			map[string]any{"id": in.TechCardId})
+		if err != nil {
+			return fmt.Errorf("load pieces of tech card %d: %w", in.TechCardId, err)
+		}
+		known := make(map[string]bool, len(pieces))
+		for _, p := range pieces {
+			known[strings.ToUpper(strings.TrimSpace(p.LineKey))] = true
+		}
+		rows := make([]map[string]any, 0, len(in.Rules))
+		for i, r := range in.Rules {
+			key := strings.ToUpper(strings.TrimSpace(r.PieceLineKey))
+			if !known[key] {
+				return entity.NewFieldViolation(fmt.Sprintf("rules[%d].piece_line_key", i), "unknown_piece", key,
+					"the card has no cut piece with this line_key")
+			}
+			rows = append(rows, map[string]any{
+				"tech_card_id":   in.TechCardId,
+				"scope_key":      in.ScopeKey,
+				"piece_line_key": key,
+				"point_no":       r.PointNo,
+				"dx_cm":          r.DxCm,
+				"dy_cm":          r.DyCm,
+				"updated_by":     in.UpdatedBy,
+			})
+		}
+		if err := storeutil.ExecNamed(ctx, db, `
+			DELETE FROM tech_card_pattern_grade_rule WHERE tech_card_id = :id AND scope_key = :scope`,
+			map[string]any{"id": in.TechCardId, "scope": in.ScopeKey}); err != nil {
+			return fmt.Errorf("clear grade rules of tech card %d: %w", in.TechCardId, err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := storeutil.BulkInsert(ctx, db, "tech_card_pattern_grade_rule", rows); err != nil {
+			return fmt.Errorf("insert grade rules of tech card %d: %w", in.TechCardId, err)
+		}
+		return nil
+	})
+}
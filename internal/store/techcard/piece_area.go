@@ -925,3 +925,67 @@ func (s *Store) SaveTechCardPieceAreas(ctx context.Context, in entity.PieceAreaW
 	}
 	return out, nil
 }
+
+// GetTechCardPatternScopeSource loads what the server needs to measure one fabric scope from its own
+// files: the scope's sheets, its блок→деталь links, the card's pieces and size range.
+//
+// Sheets and links are bucketed by entity.FabricScopeIdentity over ONE read of the BOM lines — the
+// same resolution SaveTechCardPieceAreas runs inside its transaction, so a measurement built from
+// this answer addresses the set the write will check it against. It is a plain read and not a
+// snapshot of the write: a sheet replaced between the two moves the membership, and the write's own
+// sheet check refuses it, which is exactly the case it exists for.
+func (s *Store) GetTechCardPatternScopeSource(ctx context.Context, techCardID int, scopeKey string) (*entity.PatternScopeSource, error) {
+	scopeKey = strings.TrimSpace(scopeKey)
+	lines, err := loadRollGoodsLines(ctx, s.DB, techCardID)
+	if err != nil {
+		return nil, err
+	}
+	sheets, err := storeutil.QueryListNamed[struct {
+		entity.PatternScopeSheet
+		BomLineKey    string `db:"bom_line_key"`
+		FabricPurpose string `db:"fabric_purpose"`
+	}](ctx, s.DB, `
+		SELECT line_key, url, version, COALESCE(filename, '') AS filename,
+		       COALESCE(bom_line_key, '') AS bom_line_key,
+		       COALESCE(fabric_purpose, '') AS fabric_purpose
+		FROM tech_card_size_pattern
+		WHERE tech_card_id = :id
+		ORDER BY id`, map[string]any{"id": techCardID})
+	if err != nil {
+		return nil, fmt.Errorf("load pattern sheets of tech card %d: %w", techCardID, err)
+	}
+	blocks, err := scopeBlockRefs(ctx, s.DB, techCardID, lines)
+	if err != nil {
+		return nil, err
+	}
+	pieces, err := storeutil.QueryListNamed[entity.PatternScopePiece](ctx, s.DB, `
+		SELECT COALESCE(line_key, '') AS line_key, name, ungraded
+		FROM tech_card_piece WHERE tech_card_id = :id`, map[string]any{"id": techCardID})
+	if err != nil {
+		return nil, fmt.Errorf("load pieces of tech card %d: %w", techCardID, err)
+	}
+	sizes, err := storeutil.QueryListNamed[struct {
+		SizeId int `db:"size_id"`
+	}](ctx, s.DB, `SELECT size_id FROM tech_card_size WHERE tech_card_id = :id ORDER BY size_id`,
+		map[string]any{"id": techCardID})
+	if err != nil {
+		return nil, fmt.Errorf("load size range of tech card %d: %w", techCardID, err)
+	}
+
+	out := &entity.PatternScopeSource{
+		TechCardId: techCardID,
+		ScopeKey:   scopeKey,
+		Blocks:     blocks[scopeKey],
+		Pieces:     pieces,
+		SizeIds:    make([]int, 0, len(sizes)),
+	}
+	for _, sh := range sheets {
+		if entity.FabricScopeIdentity(sh.FabricPurpose, sh.BomLineKey, lines) == scopeKey {
+			out.Sheets = append(out.Sheets, sh.PatternScopeSheet)
+		}
+	}
+	for _, sz := range sizes {
+		out.SizeIds = append(out.SizeIds, sz.SizeId)
+	}
+	return out, nil
+}
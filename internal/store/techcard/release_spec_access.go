@@ -0,0 +1,62 @@
+package techcard
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// Строки доступа к ссылке на PDF-спецификацию релиза (tech_card_release_spec_access, 0347) —
+// релизный близнец production_run_pack_access. Ключ — id РЕЛИЗА; токен скоупа 't' резолвится только
+// против этой таблицы (обработчик /api/ts отказывает всем остальным скоупам, а чужие — ему).
+
+const releaseSpecAccessColumns = `release_id, epoch, expires_at, revoked_at, created_by, created_at`
+
+// GetReleaseSpecAccess читает строку доступа одного релиза. sql.ErrNoRows, когда ссылкой ещё не
+// делились.
+func (s *Store) GetReleaseSpecAccess(ctx context.Context, releaseID int) (*entity.TechCardReleaseSpecAccess, error) {
+	row, err := storeutil.QueryNamedOne[entity.TechCardReleaseSpecAccess](ctx, s.DB,
+		`SELECT `+releaseSpecAccessColumns+` FROM tech_card_release_spec_access WHERE release_id = :id`,
+		map[string]any{"id": releaseID})
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// UpdateReleaseSpecAccess заводит строку доступа при первом вызове (epoch 1, отзыва нет) и применяет
+// к ней upd. Первое «поделиться» — это и есть Rotate с нуля: выпуск новой ссылки, поэтому на
+// свежей строке Rotate эпоху НЕ поднимает. Релиза нет — sql.ErrNoRows (FK глотается INSERT IGNORE
+// и всплывает на повторном чтении).
+func (s *Store) UpdateReleaseSpecAccess(ctx context.Context, releaseID int, upd entity.ReleaseSpecAccessUpdate) (*entity.TechCardReleaseSpecAccess, error) {
+	_, err := s.GetReleaseSpecAccess(ctx, releaseID)
+	fresh := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !fresh {
+		return nil, fmt.Errorf("load spec sheet access of release %d: %w", releaseID, err)
+	}
+	if fresh {
+		if err := storeutil.ExecNamed(ctx, s.DB,
+			`INSERT IGNORE INTO tech_card_release_spec_access (release_id, created_by) VALUES (:id, :by)`,
+			map[string]any{"id": releaseID, "by": upd.Username}); err != nil {
+			return nil, fmt.Errorf("create spec sheet access of release %d: %w", releaseID, err)
+		}
+	}
+	set := []string{"expires_at = :expires_at"}
+	if upd.Rotate && !fresh {
+		set = append(set, "epoch = epoch + 1", "revoked_at = NULL")
+	}
+	if upd.Revoke {
+		set = append(set, "revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)")
+	}
+	if err := storeutil.ExecNamed(ctx, s.DB,
+		`UPDATE tech_card_release_spec_access SET `+strings.Join(set, ", ")+` WHERE release_id = :id`,
+		map[string]any{"id": releaseID, "expires_at": upd.ExpiresAt}); err != nil {
+		return nil, fmt.Errorf("update spec sheet access of release %d: %w", releaseID, err)
+	}
+	return s.GetReleaseSpecAccess(ctx, releaseID)
+}
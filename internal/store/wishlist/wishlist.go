@@ -0,0 +1,321 @@
+// Package wishlist implements dependency.Wishlists: storefront account wishlists (0350), the
+// most-wished report and the watch list the wishlist notifier polls.
+package wishlist
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/store/storeutil"
+)
+
+// TxFunc matches the store transaction callback used by MYSQLStore.
+type TxFunc func(ctx context.Context, f func(context.Context, dependency.Repository) error) error
+
+// Store implements dependency.Wishlists.
+type Store struct {
+	storeutil.Base
+	txFunc TxFunc
+}
+
+// New creates a wishlist store.
+func New(base storeutil.Base, txFunc TxFunc) *Store {
+	return &Store{Base: base, txFunc: txFunc}
+}
+
+// wishlistKey is (colourway, size) — what the UNIQUE on (account_id, product_id, size_key) sees.
+type wishlistKey struct {
+	productId int
+	sizeId    int
+}
+
+// ResolveWishlistRefs resolves storefront SKUs to the catalogue. A ref that does not name an ACTIVE
+// colourway (or an active grade-A variant of one), or whose base and variant SKUs disagree, is left
+// out; the result keeps the order of refs.
+func (s *Store) ResolveWishlistRefs(ctx context.Context, refs []entity.WishlistRef) ([]entity.WishlistTarget, error) {
+	var baseSKUs, variantSKUs []string
+	for _, r := range refs {
+		if r.VariantSKU != "" {
+			variantSKUs = append(variantSKUs, r.VariantSKU)
+		} else if r.BaseSKU != "" {
+			baseSKUs = append(baseSKUs, r.BaseSKU)
+		}
+	}
+
+	type resolvedRow struct {
+		BaseSKU               string `db:"base_sku"`
+		VariantSKU            string `db:"variant_sku"`
+		ProductId             int    `db:"product_id"`
+		SizeId                int    `db:"size_id"`
+		MinTier               int16  `db:"min_tier"`
+		HiddenForNonQualified bool   `db:"hidden_for_non_qualified"`
+	}
+	byBase := map[string]resolvedRow{}
+	byVariant := map[string]resolvedRow{}
+	if len(baseSKUs) > 0 {
+		rows, err := storeutil.QueryListNamed[resolvedRow](ctx, s.DB, `
+			SELECT p.sku AS base_sku, '' AS variant_sku, p.id AS product_id, 0 AS size_id,
+				p.min_tier, p.hidden_for_non_qualified
+			FROM product p
+			WHERE p.sku IN (:skus) AND p.lifecycle_status = 2`, map[string]any{"skus": baseSKUs})
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve wishlist colourways: %w", err)
+		}
+		for _, r := range rows {
+			byBase[r.BaseSKU] = r
+		}
+	}
+	if len(variantSKUs) > 0 {
+		rows, err := storeutil.QueryListNamed[resolvedRow](ctx, s.DB, `
+			SELECT p.sku AS base_sku, ps.sku AS variant_sku, p.id AS product_id, ps.size_id,
+				p.min_tier, p.hidden_for_non_qualified
+			FROM product_size ps
+			JOIN product p ON p.id = ps.product_id
+			WHERE ps.sku IN (:skus) AND ps.grade = 'A' AND ps.status = 1 AND p.lifecycle_status = 2`,
+			map[string]any{"skus": variantSKUs})
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve wishlist variants: %w", err)
+		}
+		for _, r := range rows {
+			byVariant[r.VariantSKU] = r
+		}
+	}
+
+	out := make([]entity.WishlistTarget, 0, len(refs))
+	for _, ref := range refs {
+		var (
+			r  resolvedRow
+			ok bool
+		)
+		if ref.VariantSKU != "" {
+			r, ok = byVariant[ref.VariantSKU]
+			if ok && ref.BaseSKU != "" && ref.BaseSKU != r.BaseSKU {
+				ok = false
+			}
+		} else {
+			r, ok = byBase[ref.BaseSKU]
+		}
+		if !ok {
+			continue
+		}
+		out = append(out, entity.WishlistTarget{
+			Ref:                   ref,
+			ProductId:             r.ProductId,
+			SizeId:                r.SizeId,
+			MinTier:               r.MinTier,
+			HiddenForNonQualified: r.HiddenForNonQualified,
+		})
+	}
+	return out, nil
+}
+
+// ListWishlistItems returns an account's wishlist, newest first. Items of colourways no longer on
+// the storefront stay in the list; the caller decides what to show.
+func (s *Store) ListWishlistItems(ctx context.Context, accountID int) ([]entity.WishlistItem, error) {
+	items, err := storeutil.QueryListNamed[entity.WishlistItem](ctx, s.DB, `
+		SELECT wi.id, wi.account_id, wi.product_id, wi.size_key AS size_id,
+			COALESCE(p.sku, '') AS base_sku, COALESCE(ps.sku, '') AS variant_sku, wi.created_at
+		FROM storefront_wishlist_item wi
+		JOIN product p ON p.id = wi.product_id
+		LEFT JOIN product_size ps ON ps.product_id = wi.product_id AND ps.size_id = wi.size_id AND ps.grade = 'A'
+		WHERE wi.account_id = :accountId
+		ORDER BY wi.created_at DESC, wi.id DESC`, map[string]any{"accountId": accountID})
+	if err != nil {
+		return nil, fmt.Errorf("can't list wishlist of account %d: %w", accountID, err)
+	}
+	return items, nil
+}
+
+// AddWishlistItems adds the targets the wishlist does not hold yet, in order, until it holds
+// entity.MaxWishlistItems. Adding an item already there is a no-op. It returns how many were added,
+// and entity.ErrWishlistFull when a new item did not fit — the ones before it stay added.
+func (s *Store) AddWishlistItems(ctx context.Context, accountID int, targets []entity.WishlistTarget) (int, error) {
+	var added int
+	full := false
+	err := s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		db := rep.DB()
+		// The account row serializes concurrent adds, so the cap holds.
+		if _, err := storeutil.QueryListNamed[struct {
+			Id int `db:"id"`
+		}](ctx, db, `SELECT id FROM storefront_account WHERE id = :accountId FOR UPDATE`,
+			map[string]any{"accountId": accountID}); err != nil {
+			return fmt.Errorf("lock account: %w", err)
+		}
+		type keyRow struct {
+			ProductId int `db:"product_id"`
+			SizeId    int `db:"size_key"`
+		}
+		existing, err := storeutil.QueryListNamed[keyRow](ctx, db, `
+			SELECT product_id, size_key FROM storefront_wishlist_item WHERE account_id = :accountId`,
+			map[string]any{"accountId": accountID})
+		if err != nil {
+			return fmt.Errorf("read wishlist: %w", err)
+		}
+		held := make(map[wishlistKey]bool, len(existing))
+		for _, k := range existing {
+			held[wishlistKey{k.ProductId, k.SizeId}] = true
+		}
+
+		for _, t := range targets {
+			k := wishlistKey{t.ProductId, t.SizeId}
+			if held[k] {
+				continue
+			}
+			if len(held) >= entity.MaxWishlistItems {
+				full = true
+				break
+			}
+			var sizeID any
+			if t.SizeId > 0 {
+				sizeID = t.SizeId
+			}
+			if err := storeutil.ExecNamed(ctx, db, `
+				INSERT INTO storefront_wishlist_item (account_id, product_id, size_id)
+				VALUES (:accountId, :productId, :sizeId)`, map[string]any{
+				"accountId": accountID,
+				"productId": t.ProductId,
+				"sizeId":    sizeID,
+			}); err != nil {
+				return fmt.Errorf("add wishlist item: %w", err)
+			}
+			held[k] = true
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if full {
+		return added, entity.ErrWishlistFull
+	}
+	return added, nil
+}
+
+// RemoveWishlistItem removes the item ref names: the size-pinned entry for a variant SKU, the
+// whole-colourway entry for a base SKU alone. It works on colourways no longer on the storefront too.
+// entity.ErrWishlistItemNotFound when the wishlist does not hold it.
+func (s *Store) RemoveWishlistItem(ctx context.Context, accountID int, ref entity.WishlistRef) error {
+	params := map[string]any{"accountId": accountID, "baseSku": ref.BaseSKU, "variantSku": ref.VariantSKU}
+	var match string
+	switch {
+	case ref.VariantSKU != "" && ref.BaseSKU != "":
+		match = `ps.sku = :variantSku AND p.sku = :baseSku`
+	case ref.VariantSKU != "":
+		match = `ps.sku = :variantSku`
+	default:
+		match = `wi.size_id IS NULL AND p.sku = :baseSku`
+	}
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `
+		DELETE wi FROM storefront_wishlist_item wi
+		JOIN product p ON p.id = wi.product_id
+		LEFT JOIN product_size ps ON ps.product_id = wi.product_id AND ps.size_id = wi.size_id AND ps.grade = 'A'
+		WHERE wi.account_id = :accountId AND `+match, params)
+	if err != nil {
+		return fmt.Errorf("can't remove wishlist item: %w", err)
+	}
+	if n == 0 {
+		return entity.ErrWishlistItemNotFound
+	}
+	return nil
+}
+
+// ListMostWishedStyles ranks styles by the accounts wishing for any of their colourways, with the
+// entries added in [from, to) and a per-colourway breakdown. Erased and deleted accounts are left out.
+func (s *Store) ListMostWishedStyles(ctx context.Context, from, to time.Time, limit int) ([]entity.MostWishedStyle, error) {
+	params := map[string]any{"from": from, "to": to, "limit": limit}
+	styles, err := storeutil.QueryListNamed[entity.MostWishedStyle](ctx, s.DB, `
+		SELECT sty.id AS style_id, sty.style_number, sty.name,
+			COUNT(DISTINCT wi.account_id) AS wishers,
+			COUNT(*) AS items,
+			CAST(COALESCE(SUM(wi.created_at >= :from AND wi.created_at < :to), 0) AS SIGNED) AS added_in_window
+		FROM storefront_wishlist_item wi
+		JOIN storefront_account sa ON sa.id = wi.account_id AND sa.status = 'active'
+		JOIN product p ON p.id = wi.product_id
+		JOIN tech_card sty ON sty.id = p.style_id
+		GROUP BY sty.id, sty.style_number, sty.name
+		ORDER BY wishers DESC, added_in_window DESC, sty.id
+		LIMIT :limit`, params)
+	if err != nil {
+		return nil, fmt.Errorf("can't list most wished styles: %w", err)
+	}
+	if len(styles) == 0 {
+		return styles, nil
+	}
+
+	ids := make([]int, 0, len(styles))
+	for _, st := range styles {
+		ids = append(ids, st.StyleId)
+	}
+	colorways, err := storeutil.QueryListNamed[entity.MostWishedColorway](ctx, s.DB, `
+		SELECT p.style_id, p.id AS product_id, COALESCE(p.sku, '') AS sku, COALESCE(p.color, '') AS color,
+			COUNT(DISTINCT wi.account_id) AS wishers
+		FROM storefront_wishlist_item wi
+		JOIN storefront_account sa ON sa.id = wi.account_id AND sa.status = 'active'
+		JOIN product p ON p.id = wi.product_id
+		WHERE p.style_id IN (:ids)
+		GROUP BY p.style_id, p.id, p.sku, p.color
+		ORDER BY wishers DESC, p.id`, map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("can't list most wished colourways: %w", err)
+	}
+	byStyle := make(map[int]*entity.MostWishedStyle, len(styles))
+	for i := range styles {
+		byStyle[styles[i].StyleId] = &styles[i]
+	}
+	for _, c := range colorways {
+		if st, ok := byStyle[c.StyleId]; ok {
+			st.Colorways = append(st.Colorways, c)
+		}
+	}
+	return styles, nil
+}
+
+// ListWishlistWatches returns every wishlist item of an active account whose colourway is on the
+// storefront, with its current sale and grade-A stock, the state last recorded for it and the
+// account's marketing opt-in. Opted-out accounts are listed too: their seen state is still kept.
+func (s *Store) ListWishlistWatches(ctx context.Context) ([]entity.WishlistWatch, error) {
+	watches, err := storeutil.QueryListNamed[entity.WishlistWatch](ctx, s.DB, `
+		SELECT wi.id, wi.account_id, sa.email, sa.first_name, sa.last_name, sa.account_tier,
+			sa.subscribe_newsletter, wi.product_id, wi.size_key AS size_id, p.min_tier, p.hidden_for_non_qualified,
+			p.sale_percentage,
+			COALESCE((SELECT SUM(ps.quantity) FROM product_size ps
+				WHERE ps.product_id = wi.product_id AND ps.grade = 'A' AND ps.status = 1
+				  AND (wi.size_id IS NULL OR ps.size_id = wi.size_id)), 0) AS quantity,
+			wi.seen_sale_percentage, wi.seen_quantity
+		FROM storefront_wishlist_item wi
+		JOIN storefront_account sa ON sa.id = wi.account_id
+		JOIN product p ON p.id = wi.product_id
+		WHERE sa.status = 'active' AND p.lifecycle_status = 2
+		ORDER BY wi.product_id, wi.id`, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("can't list wishlist watches: %w", err)
+	}
+	return watches, nil
+}
+
+// RecordWishlistSeen stores what the notifier saw for each item. An item removed since it was read is
+// simply not updated.
+func (s *Store) RecordWishlistSeen(ctx context.Context, seen []entity.WishlistSeen) error {
+	if len(seen) == 0 {
+		return nil
+	}
+	return s.txFunc(ctx, func(ctx context.Context, rep dependency.Repository) error {
+		for _, sn := range seen {
+			if err := storeutil.ExecNamed(ctx, rep.DB(), `
+				UPDATE storefront_wishlist_item
+				SET seen_sale_percentage = :sale, seen_quantity = :quantity
+				WHERE id = :id`, map[string]any{
+				"id":       sn.ItemId,
+				"sale":     sn.SalePercentage,
+				"quantity": sn.Quantity,
+			}); err != nil {
+				return fmt.Errorf("record wishlist item %d: %w", sn.ItemId, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWishlists covers 0350: refs resolve only to storefront colourways and active variants; adding
+// is idempotent per (colourway, size); removal works by base or variant SKU; the notifier reads the
+// pinned size's stock (or the whole colourway's) with the state it last recorded; the most-wished
+// report groups by style.
+//
+// SAFE ONLY against a local container DSN — see the guard and mysql_test.go / project memory.
+func TestWishlists(t *testing.T) {
+	if os.Getenv("CI") == "" &&
+		!strings.Contains(testCfg.DSN, "127.0.0.1") &&
+		!strings.Contains(testCfg.DSN, "localhost") {
+		t.Skip("skipping outside CI unless the DSN targets a local container (avoids the configured prod DB)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	cfg := *testCfg
+	cfg.Automigrate = true
+	s, err := NewForTest(ctx, cfg)
+	require.NoError(t, err)
+	defer s.Close()
+
+	exec := func(q string, args ...any) int {
+		res, err := testDB.ExecContext(ctx, q, args...)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return int(id)
+	}
+	token := fmt.Sprintf("%d%04d", time.Now().UnixNano(), rand.Intn(10000))
+	mediaID, err := s.Media().AddMedia(ctx, &entity.MediaItem{
+		FullSizeMediaURL: "https://x/f.jpg", FullSizeWidth: 1, FullSizeHeight: 1,
+		ThumbnailMediaURL: "https://x/t.jpg", ThumbnailWidth: 1, ThumbnailHeight: 1,
+		CompressedMediaURL: "https://x/c.jpg", CompressedWidth: 1, CompressedHeight: 1,
+		BlurHash: sql.NullString{String: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", Valid: true},
+	})
+	require.NoError(t, err)
+	var sizeA, sizeB int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 ORDER BY id LIMIT 1`).Scan(&sizeA))
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT id FROM size WHERE sku_ord != 0 AND id > ? ORDER BY id LIMIT 1`, sizeA).Scan(&sizeB))
+
+	styleID := exec(`INSERT INTO tech_card (style_number, name, brand, collection, season_code, season_year, season, target_gender, top_category_id)
+		VALUES (CONCAT('WL-', UUID_SHORT()), 'WL', 'ACME', '', 'SS', 2026, 'SS26', 'unisex', 1)`)
+	product := func(suffix string, lifecycle int) int {
+		return exec(`INSERT INTO product (sku, color, color_code, color_hex, country_of_origin, thumbnail_id, style_id, lifecycle_status, sale_percentage)
+			VALUES (?, 'c', 'BLK', '#000000', 'US', ?, ?, ?, 0)`, "WL"+suffix+"-"+token, mediaID, styleID, lifecycle)
+	}
+	live, draft := product("A", 2), product("B", 1)
+	baseSKU := "WLA-" + token
+	exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 5, ?)`, live, sizeA, baseSKU+"-1")
+	exec(`INSERT INTO product_size (product_id, size_id, quantity, sku) VALUES (?, ?, 0, ?)`, live, sizeB, baseSKU+"-2")
+
+	acc, err := s.StorefrontAccount().GetOrCreateAccountByEmail(ctx, "wl"+token+"@example.com")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cctx := context.Background()
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM storefront_wishlist_item WHERE account_id = ?", acc.ID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM storefront_account WHERE id = ?", acc.ID)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product_size WHERE product_id IN (?, ?)", live, draft)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM product WHERE id IN (?, ?)", live, draft)
+		_, _ = testDB.ExecContext(cctx, "DELETE FROM tech_card WHERE id = ?", styleID)
+	})
+	W := s.Wishlists()
+
+	// Resolve: the variant, the whole colourway; a mismatched pair, an unknown SKU and a draft drop.
+	targets, err := W.ResolveWishlistRefs(ctx, []entity.WishlistRef{
+		{VariantSKU: baseSKU + "-1"},
+		{BaseSKU: "WLB-" + token},
+		{BaseSKU: "WLB-" + token, VariantSKU: baseSKU + "-2"},
+		{BaseSKU: "NOPE-" + token},
+		{BaseSKU: baseSKU},
+	})
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	require.Equal(t, live, targets[0].ProductId)
+	require.Equal(t, sizeA, targets[0].SizeId)
+	require.Equal(t, 0, targets[1].SizeId)
+
+	added, err := W.AddWishlistItems(ctx, acc.ID, targets)
+	require.NoError(t, err)
+	require.Equal(t, 2, added)
+	added, err = W.AddWishlistItems(ctx, acc.ID, targets)
+	require.NoError(t, err)
+	require.Equal(t, 0, added, "adding an item already held is a no-op")
+
+	items, err := W.ListWishlistItems(ctx, acc.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	variants := map[string]bool{}
+	for _, it := range items {
+		require.Equal(t, baseSKU, it.BaseSKU)
+		variants[it.VariantSKU] = true
+	}
+	require.True(t, variants[baseSKU+"-1"] && variants[""])
+
+	// The notifier: the first look only records; then a sale and the pinned size running low alert.
+	watches := func() map[int]entity.WishlistWatch {
+		ws, err := W.ListWishlistWatches(ctx)
+		require.NoError(t, err)
+		out := map[int]entity.WishlistWatch{}
+		for _, w := range ws {
+			if w.AccountId == acc.ID {
+				out[w.SizeId] = w
+			}
+		}
+		return out
+	}
+	ws := watches()
+	require.Len(t, ws, 2)
+	exec(`UPDATE storefront_account SET subscribe_newsletter = TRUE WHERE id = ?`, acc.ID)
+	require.True(t, watches()[0].SubscribeNewsletter, "the marketing opt-in is read with the watch")
+	require.True(t, ws[sizeA].Quantity.Equal(decimal.NewFromInt(5)))
+	require.True(t, ws[0].Quantity.Equal(decimal.NewFromInt(5)), "the whole colourway sums its sizes")
+	var seen []entity.WishlistSeen
+	for _, w := range ws {
+		alerts, sn, changed := w.Observe(decimal.NewFromInt(3))
+		require.Empty(t, alerts)
+		require.True(t, changed)
+		seen = append(seen, sn)
+	}
+	require.NoError(t, W.RecordWishlistSeen(ctx, seen))
+
+	exec(`UPDATE product SET sale_percentage = 20 WHERE id = ?`, live)
+	exec(`UPDATE product_size SET quantity = 2 WHERE product_id = ? AND size_id = ?`, live, sizeA)
+	ws = watches()
+	w := ws[sizeA]
+	alerts, _, _ := w.Observe(decimal.NewFromInt(3))
+	require.Equal(t, []entity.WishlistAlertKind{entity.WishlistAlertOnSale, entity.WishlistAlertLowStock}, alerts)
+
+	// Most wished: the style with its one colourway and one wisher.
+	styles, err := W.ListMostWishedStyles(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 500)
+	require.NoError(t, err)
+	var st *entity.MostWishedStyle
+	for i := range styles {
+		if styles[i].StyleId == styleID {
+			st = &styles[i]
+		}
+	}
+	require.NotNil(t, st)
+	require.Equal(t, 1, st.Wishers)
+	require.Equal(t, 2, st.Items)
+	require.Equal(t, 2, st.AddedInWindow)
+	require.Len(t, st.Colorways, 1)
+	require.Equal(t, live, st.Colorways[0].ProductId)
+
+	// Remove by variant, then by base; a second removal finds nothing.
+	require.NoError(t, W.RemoveWishlistItem(ctx, acc.ID, entity.WishlistRef{VariantSKU: baseSKU + "-1"}))
+	require.NoError(t, W.RemoveWishlistItem(ctx, acc.ID, entity.WishlistRef{BaseSKU: baseSKU}))
+	err = W.RemoveWishlistItem(ctx, acc.ID, entity.WishlistRef{BaseSKU: baseSKU})
+	require.True(t, errors.Is(err, entity.ErrWishlistItemNotFound), "got %v", err)
+}
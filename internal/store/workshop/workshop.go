@@ -1,6 +1,7 @@
 // Package workshop implements the workshop_settings store (0272) — «дом настроек цеха», the
 // singleton row of shop-floor constants (cutting table length, припуск по умолчанию, режим гейта
-// готовности and предел высоты стопки today; минимальный зазор as it lands).
+// готовности, предел высоты стопки, the sewing capacity and the QC gate of the receipt today;
+// минимальный зазор as it lands) — and the workshop_holiday calendar beside it (0342).
 package workshop
 
 import (
@@ -8,6 +9,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jekabolt/grbpwr-manager/internal/dependency"
 	"github.com/jekabolt/grbpwr-manager/internal/entity"
@@ -34,7 +36,8 @@ func New(base storeutil.Base, txFunc TxFunc) *Store {
 }
 
 const selectSettings = `SELECT cutting_table_length_cm, default_seam_allowance_mm, run_readiness_blocking,
-	       max_stack_height_cm, updated_by, updated_at
+	       max_stack_height_cm, sewing_workstations, shift_minutes, shifts_per_day, working_weekdays,
+	       planning_efficiency_pct, qc_inspection_required, updated_by, updated_at
 	FROM workshop_settings WHERE id = :id`
 
 // GetSettings returns the workshop configuration. A MISSING singleton row is not an error: it reads
@@ -96,6 +99,28 @@ func (s *Store) UpdateSettings(ctx context.Context, patch entity.WorkshopSetting
 			return nil, err
 		}
 	}
+	// 0342 — the capacity tenants. Counts share one validator (whole, positive, under a unit-check
+	// ceiling); the weekday list arrives already canonical from entity.ParseWorkingWeekdays.
+	for _, c := range []struct {
+		field string
+		v     *sql.NullInt32
+		max   int32
+	}{
+		{"sewing_workstations", patch.SewingWorkstations, entity.MaxSewingWorkstations},
+		{"shift_minutes", patch.ShiftMinutes, entity.MaxShiftMinutes},
+		{"shifts_per_day", patch.ShiftsPerDay, entity.MaxShiftsPerDay},
+	} {
+		if c.v != nil {
+			if err := entity.ValidateCapacityCount(c.field, *c.v, c.max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if patch.PlanningEfficiencyPct != nil {
+		if err := entity.ValidatePlanningEfficiencyPct(*patch.PlanningEfficiencyPct); err != nil {
+			return nil, err
+		}
+	}
 
 	params := map[string]any{
 		"id":         singletonID,
@@ -117,6 +142,19 @@ func (s *Store) UpdateSettings(ctx context.Context, patch entity.WorkshopSetting
 		// would let it.
 		"max_stack_height_omitted": patch.MaxStackHeightCm == nil,
 		"max_stack_height_cm":      nullDecimalParam(patch.MaxStackHeightCm),
+		// 0342 — five more lines of the same mask, which is the argument above made once more.
+		"sewing_workstations_omitted":     patch.SewingWorkstations == nil,
+		"sewing_workstations":             nullInt32Param(patch.SewingWorkstations),
+		"shift_minutes_omitted":           patch.ShiftMinutes == nil,
+		"shift_minutes":                   nullInt32Param(patch.ShiftMinutes),
+		"shifts_per_day_omitted":          patch.ShiftsPerDay == nil,
+		"shifts_per_day":                  nullInt32Param(patch.ShiftsPerDay),
+		"working_weekdays_omitted":        patch.WorkingWeekdays == nil,
+		"working_weekdays":                nullStringParam(patch.WorkingWeekdays),
+		"planning_efficiency_pct_omitted": patch.PlanningEfficiencyPct == nil,
+		"planning_efficiency_pct":         nullDecimalParam(patch.PlanningEfficiencyPct),
+		"qc_inspection_required_omitted":  patch.QcInspectionRequired == nil,
+		"qc_inspection_required":          boolParam(patch.QcInspectionRequired),
 	}
 
 	var out *entity.WorkshopSettings
@@ -132,6 +170,12 @@ func (s *Store) UpdateSettings(ctx context.Context, patch entity.WorkshopSetting
 				default_seam_allowance_mm = IF(:seam_allowance_omitted, default_seam_allowance_mm, :default_seam_allowance_mm),
 				run_readiness_blocking = IF(:run_readiness_blocking_omitted, run_readiness_blocking, :run_readiness_blocking),
 				max_stack_height_cm = IF(:max_stack_height_omitted, max_stack_height_cm, :max_stack_height_cm),
+				sewing_workstations = IF(:sewing_workstations_omitted, sewing_workstations, :sewing_workstations),
+				shift_minutes = IF(:shift_minutes_omitted, shift_minutes, :shift_minutes),
+				shifts_per_day = IF(:shifts_per_day_omitted, shifts_per_day, :shifts_per_day),
+				working_weekdays = IF(:working_weekdays_omitted, working_weekdays, :working_weekdays),
+				planning_efficiency_pct = IF(:planning_efficiency_pct_omitted, planning_efficiency_pct, :planning_efficiency_pct),
+				qc_inspection_required = IF(:qc_inspection_required_omitted, qc_inspection_required, :qc_inspection_required),
 				updated_by = :updated_by
 			WHERE id = :id`, params); err != nil {
 			return fmt.Errorf("failed to update workshop settings: %w", err)
@@ -167,3 +211,63 @@ func boolParam(v *bool) any {
 	}
 	return *v
 }
+
+// nullInt32Param binds a tri-state integer patch field, the same way nullDecimalParam does.
+func nullInt32Param(v *sql.NullInt32) any {
+	if v == nil || !v.Valid {
+		return nil
+	}
+	return v.Int32
+}
+
+// nullStringParam binds a tri-state string patch field, the same way nullDecimalParam does.
+func nullStringParam(v *sql.NullString) any {
+	if v == nil || !v.Valid {
+		return nil
+	}
+	return v.String
+}
+
+// ListHolidays returns the closed days in [from, to], oldest first. A zero bound does not bound.
+func (s *Store) ListHolidays(ctx context.Context, from, to time.Time) ([]entity.WorkshopHoliday, error) {
+	where := "1=1"
+	params := map[string]any{}
+	if !from.IsZero() {
+		where += " AND day >= :from"
+		params["from"] = from.Format("2006-01-02")
+	}
+	if !to.IsZero() {
+		where += " AND day <= :to"
+		params["to"] = to.Format("2006-01-02")
+	}
+	list, err := storeutil.QueryListNamed[entity.WorkshopHoliday](ctx, s.DB,
+		`SELECT day, note, created_by, created_at FROM workshop_holiday WHERE `+where+` ORDER BY day`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workshop holidays: %w", err)
+	}
+	return list, nil
+}
+
+// UpsertHoliday closes a day, or re-notes one already closed.
+func (s *Store) UpsertHoliday(ctx context.Context, day time.Time, note sql.NullString, createdBy string) error {
+	if err := storeutil.ExecNamed(ctx, s.DB, `
+		INSERT INTO workshop_holiday (day, note, created_by) VALUES (:day, :note, :created_by)
+		ON DUPLICATE KEY UPDATE note = VALUES(note)`,
+		map[string]any{"day": day.Format("2006-01-02"), "note": note, "created_by": createdBy}); err != nil {
+		return fmt.Errorf("failed to save workshop holiday %s: %w", day.Format("2006-01-02"), err)
+	}
+	return nil
+}
+
+// DeleteHoliday reopens a closed day. sql.ErrNoRows when the day was not closed.
+func (s *Store) DeleteHoliday(ctx context.Context, day time.Time) error {
+	n, err := storeutil.ExecNamedRows(ctx, s.DB, `DELETE FROM workshop_holiday WHERE day = :day`,
+		map[string]any{"day": day.Format("2006-01-02")})
+	if err != nil {
+		return fmt.Errorf("failed to delete workshop holiday %s: %w", day.Format("2006-01-02"), err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+// Package subcontractportal — ПОРТАЛ ФАБРИКИ-ПОДРЯДЧИКА: минт токена на прогон, отданный на пошив,
+// и публичный эндпоинт /api/cmt/{token}.
+//
+// Посадка — копия ярлыка пачки (internal/bundleticket) и наряда (internal/runpackaccess): фабрика
+// не имеет аккаунта в админке, и токен аутентифицирует сам себя. Любой отказ по ТОКЕНУ (битая
+// подпись, чужой скоуп, устаревшая эпоха, отзыв, протухание, лимит, прогон больше не на подряде) —
+// один и тот же голый 404 с причиной только в сэмплированном логе.
+//
+// GET отдаёт прогон так, как его видит фабрика: стиль, грид, комплект (что отгружено и что
+// вернулось) и её же подтверждения. POST записывает подтверждение: {"ship_date": "YYYY-MM-DD",
+// "qty": n, "note": "..."}. Ответы POST после принятого токена читаемые, как у ярлыка: держатель
+// ссылки — фабрика, и «прогон уже сверен» — то, что ей нужно прочитать, а не угадать.
+//
+// ДЕНЕГ В ПОРТАЛЕ НЕТ, И ЭТО СВОЙСТВО ЧТЕНИЯ. Цена пошива — договорённость с фабрикой, и она её
+// знает, но ответ собирается руками из полей без денег (стоимость комплекта, цена соглашения,
+// затраты прогона в нём не объявлены), так что утечь им некуда.
+//
+// Эпоха живёт в production_run_subcontract.portal_epoch: ротация из админки убивает все выданные
+// ссылки, отзыв — до следующей ротации.
+package subcontractportal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/middleware"
+	"github.com/jekabolt/grbpwr-manager/internal/patterntoken"
+	"github.com/jekabolt/grbpwr-manager/internal/ratelimit"
+)
+
+// Runs — узкий срез dependency.ProductionRuns, который нужен порталу.
+type Runs interface {
+	GetRunSubcontract(ctx context.Context, runID int) (*entity.ProductionRunSubcontract, error)
+	GetRunPack(ctx context.Context, runID int) (*entity.RunPack, error)
+	ConfirmRunSubcontract(ctx context.Context, runID int, c entity.SubcontractConfirmation) (*entity.ProductionRunSubcontract, error)
+}
+
+const (
+	// Пер (ip|прогон). Портал открывают с офисного компьютера фабрики; бюджет покрывает
+	// перезагрузки и пару правок подтверждения подряд.
+	perTokenWindow = time.Minute
+	perTokenMax    = 30
+
+	// Свой бюджет на ip: за ним одна фабрика, а не цех, поэтому он скромнее, чем у ярлыка.
+	perIPWindow = time.Minute
+	perIPMax    = 300
+
+	// maxConfirmBodyBytes — тело POST: дата, число и заметка.
+	maxConfirmBodyBytes = 8 << 10
+	// maxNoteLen — заметка фабрики; больше — это уже письмо, а не пометка к дате.
+	maxNoteLen = 1000
+
+	// deniedLogSample — 1 из N отказов пишется на Info, остальные на Debug.
+	deniedLogSample = 10
+)
+
+// Service минтит ссылки порталов и обслуживает /api/cmt/{token}.
+type Service struct {
+	runs   Runs
+	minter *patterntoken.Minter
+	now    func() time.Time
+
+	tokenLimiter *ratelimit.Limiter
+	ipLimiter    *ratelimit.Limiter
+	stopOnce     sync.Once
+
+	deniedSeq atomic.Int64
+}
+
+// New собирает сервис. Пустой pepper — отказ на старте (patterntoken.NewMinter).
+func New(runs Runs, pepper string) (*Service, error) {
+	minter, err := patterntoken.NewMinter(pepper)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		runs:         runs,
+		minter:       minter,
+		now:          time.Now,
+		tokenLimiter: ratelimit.NewLimiter(perTokenWindow, perTokenMax),
+		ipLimiter:    ratelimit.NewLimiter(perIPWindow, perIPMax),
+	}, nil
+}
+
+// Stop останавливает лимитеры (идемпотентно).
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() {
+		s.tokenLimiter.Stop()
+		s.ipLimiter.Stop()
+	})
+}
+
+// MintPortalToken отдаёт токен скоупа 's' для подряда прогона на его текущей эпохе. Безопасен на
+// nil-получателе и на nil-подряде: ответ админки просто приезжает без ссылки.
+func (s *Service) MintPortalToken(rs *entity.ProductionRunSubcontract) string {
+	if s == nil || rs == nil || rs.RunId <= 0 {
+		return ""
+	}
+	return s.minter.Mint(patterntoken.ScopeSubcontract, int64(rs.RunId), rs.PortalEpoch)
+}
+
+// Handler обслуживает GET/HEAD (прогон) и POST (подтверждение) /api/cmt/{token}.
+func (s *Service) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			s.ServeConfirm(w, r)
+			return
+		}
+		s.ServeRun(w, r)
+	})
+}
+
+// Portal — прогон, как его видит фабрика.
+type Portal struct {
+	RunId       int    `json:"run_id"`
+	StyleNumber string `json:"style_number"`
+	StyleName   string `json:"style_name"`
+	FactoryName string `json:"factory_name"`
+	// PromisedAt — дата, к которой партия обещана нам (YYYY-MM-DD); пусто, когда не обещана.
+	PromisedAt string       `json:"promised_at,omitempty"`
+	Lines      []PortalLine `json:"lines"`
+	Kit        []PortalKit  `json:"kit"`
+	// Confirmation — последнее подтверждение фабрики; nil, пока его не было.
+	Confirmation *PortalConfirmation `json:"confirmation,omitempty"`
+	// Reconciled — комплект сверен: подтверждения больше не принимаются.
+	Reconciled bool `json:"reconciled"`
+}
+
+// PortalLine — одна строка планового грида.
+type PortalLine struct {
+	ColorwayName string `json:"colorway_name"`
+	SizeName     string `json:"size_name"`
+	PlannedQty   int    `json:"planned_qty"`
+}
+
+// PortalKit — один материал комплекта; количества строками-десятичными.
+type PortalKit struct {
+	MaterialName string `json:"material_name"`
+	Unit         string `json:"unit"`
+	Shipped      string `json:"shipped"`
+	Returned     string `json:"returned"`
+}
+
+// PortalConfirmation — то, что фабрика подтвердила.
+type PortalConfirmation struct {
+	ShipDate    string `json:"ship_date"`
+	Qty         int    `json:"qty"`
+	Note        string `json:"note,omitempty"`
+	ConfirmedAt string `json:"confirmed_at"`
+}
+
+// ConfirmRequest — тело POST.
+type ConfirmRequest struct {
+	ShipDate string `json:"ship_date"`
+	Qty      int    `json:"qty"`
+	Note     string `json:"note"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// resolve проверяет лимиты, токен и строку подряда; ok=false — ответ (голый 404) уже записан.
+func (s *Service) resolve(w http.ResponseWriter, r *http.Request) (*entity.ProductionRunSubcontract, bool) {
+	ctx := r.Context()
+	ip := middleware.ClientIPFromRequest(r)
+	if !s.ipLimiter.Allow(ip) {
+		s.notFound(w, r, ip, "ip rate limited")
+		return nil, false
+	}
+	scope, id, epoch, err := s.minter.Parse(chi.URLParam(r, "token"))
+	if err != nil {
+		s.notFound(w, r, ip, "bad token")
+		return nil, false
+	}
+	// СКОУП-ALLOWLIST: id здесь — номер ПРОГОНА, как и у наряда ('r'), но ссылка наряда с
+	// раскройного стола не должна давать права подтверждать даты за фабрику.
+	if scope != patterntoken.ScopeSubcontract {
+		s.notFound(w, r, ip, "wrong token scope")
+		return nil, false
+	}
+	if !s.tokenLimiter.Allow(ip + "|s|" + strconv.FormatInt(id, 10)) {
+		s.notFound(w, r, ip, "token rate limited")
+		return nil, false
+	}
+	rs, err := s.runs.GetRunSubcontract(ctx, int(id))
+	if err != nil {
+		if !errors.Is(err, entity.ErrSubcontractNotFound) {
+			slog.Default().ErrorContext(ctx, "subcontract portal lookup failed", slog.String("err", err.Error()))
+		}
+		s.notFound(w, r, ip, "no subcontract")
+		return nil, false
+	}
+	switch {
+	case rs.PortalEpoch != epoch:
+		s.notFound(w, r, ip, "stale epoch")
+		return nil, false
+	case rs.PortalRevokedAt.Valid:
+		s.notFound(w, r, ip, "revoked")
+		return nil, false
+	case rs.PortalExpiresAt.Valid && s.now().After(rs.PortalExpiresAt.Time):
+		s.notFound(w, r, ip, "expired")
+		return nil, false
+	}
+	return rs, true
+}
+
+// notFound — единственный ответ на отказ по токену. Причина только в сэмплированном логе.
+func (s *Service) notFound(w http.ResponseWriter, r *http.Request, ip, reason string) {
+	level := slog.LevelDebug
+	if s.deniedSeq.Add(1)%deniedLogSample == 0 {
+		level = slog.LevelInfo
+	}
+	slog.Default().Log(r.Context(), level, "subcontract portal denied",
+		slog.String("reason", reason), slog.String("ip", ip), slog.String("ua", r.UserAgent()))
+	http.NotFound(w, r)
+}
+
+// ServeRun обслуживает GET/HEAD /api/cmt/{token}.
+func (s *Service) ServeRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rs, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	pack, err := s.runs.GetRunPack(ctx, rs.RunId)
+	if err != nil {
+		// Строка подряда пережила прогон (гонка каскада FK с чтением) — тот же 404.
+		slog.Default().WarnContext(ctx, "subcontract portal run read failed",
+			slog.Int("run_id", rs.RunId), slog.String("err", err.Error()))
+		s.notFound(w, r, middleware.ClientIPFromRequest(r), "run gone")
+		return
+	}
+	slog.Default().InfoContext(ctx, "subcontract portal access",
+		slog.Int("run_id", rs.RunId), slog.String("ip", middleware.ClientIPFromRequest(r)))
+	writeJSON(w, http.StatusOK, buildPortal(rs, pack))
+}
+
+func buildPortal(rs *entity.ProductionRunSubcontract, pack *entity.RunPack) Portal {
+	p := Portal{
+		RunId:       rs.RunId,
+		StyleNumber: pack.StyleNumber,
+		StyleName:   pack.StyleName,
+		FactoryName: rs.SupplierName,
+		Lines:       make([]PortalLine, 0, len(pack.Lines)),
+		Kit:         make([]PortalKit, 0, len(rs.Kit)),
+		Reconciled:  rs.Reconciled(),
+	}
+	if pack.Run.PromisedAt.Valid {
+		p.PromisedAt = pack.Run.PromisedAt.Time.Format(time.DateOnly)
+	}
+	for _, l := range pack.Lines {
+		colorway := l.ColorwayName
+		if colorway == "" {
+			colorway = l.OutputVariantName
+		}
+		p.Lines = append(p.Lines, PortalLine{ColorwayName: colorway, SizeName: l.SizeName, PlannedQty: l.PlannedQty})
+	}
+	for _, k := range rs.Kit {
+		p.Kit = append(p.Kit, PortalKit{
+			MaterialName: k.MaterialName,
+			Unit:         k.Unit,
+			Shipped:      k.Shipped.String(),
+			Returned:     k.Returned.String(),
+		})
+	}
+	if rs.ConfirmedAt.Valid {
+		p.Confirmation = &PortalConfirmation{
+			ShipDate:    rs.ConfirmedShipDate.Time.Format(time.DateOnly),
+			Qty:         int(rs.ConfirmedQty.Int32),
+			Note:        rs.FactoryNote.String,
+			ConfirmedAt: rs.ConfirmedAt.Time.UTC().Format(time.RFC3339),
+		}
+	}
+	return p
+}
+
+// ServeConfirm обслуживает POST /api/cmt/{token}: подтверждение даты отгрузки и количества.
+func (s *Service) ServeConfirm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rs, ok := s.resolve(w, r)
+	if !ok {
+		return
+	}
+	var req ConfirmRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConfirmBodyBytes)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: `body must be {"ship_date": "YYYY-MM-DD", "qty": n, "note": "..."}`})
+		return
+	}
+	c, msg := parseConfirmation(req)
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: msg})
+		return
+	}
+	updated, err := s.runs.ConfirmRunSubcontract(ctx, rs.RunId, c)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrSubcontractNotFound):
+			s.notFound(w, r, middleware.ClientIPFromRequest(r), "no subcontract")
+		case errors.Is(err, entity.ErrSubcontractReconciled):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: "this run is already reconciled; contact us to change anything"})
+		default:
+			slog.Default().ErrorContext(ctx, "subcontract confirmation failed",
+				slog.Int("run_id", rs.RunId), slog.String("err", err.Error()))
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "can't save the confirmation; try again"})
+		}
+		return
+	}
+	slog.Default().InfoContext(ctx, "subcontract confirmed",
+		slog.Int("run_id", rs.RunId), slog.String("ship_date", req.ShipDate), slog.Int("qty", req.Qty))
+	pack, err := s.runs.GetRunPack(ctx, rs.RunId)
+	if err != nil {
+		// Подтверждение уже записано; ответ без грида лучше, чем «ошибка» на принятой записи.
+		slog.Default().WarnContext(ctx, "subcontract portal run read failed",
+			slog.Int("run_id", rs.RunId), slog.String("err", err.Error()))
+		pack = &entity.RunPack{}
+	}
+	writeJSON(w, http.StatusOK, buildPortal(updated, pack))
+}
+
+// parseConfirmation проверяет тело POST; msg не пусто — причина отказа для фабрики.
+func parseConfirmation(req ConfirmRequest) (entity.SubcontractConfirmation, string) {
+	day, err := time.Parse(time.DateOnly, strings.TrimSpace(req.ShipDate))
+	if err != nil {
+		return entity.SubcontractConfirmation{}, "ship_date must be a date as YYYY-MM-DD"
+	}
+	if req.Qty <= 0 {
+		return entity.SubcontractConfirmation{}, "qty must be a positive number of garments"
+	}
+	note := strings.TrimSpace(req.Note)
+	if len([]rune(note)) > maxNoteLen {
+		return entity.SubcontractConfirmation{}, "note is too long (max 1000 characters)"
+	}
+	c := entity.SubcontractConfirmation{ShipDate: day, Qty: req.Qty}
+	if note != "" {
+		c.Note.String, c.Note.Valid = note, true
+	}
+	return c, ""
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	// Портал живой — комплект и подтверждения меняются, общим кэшам его хранить нельзя.
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,245 @@
+package subcontractportal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/patterntoken"
+	"github.com/shopspring/decimal"
+)
+
+const testPepper = "test-pepper-for-cmt"
+
+// fakeRuns держит один прогон на подряде (id 5) и пишет подтверждения в память.
+type fakeRuns struct {
+	rs         *entity.ProductionRunSubcontract
+	confirmed  []entity.SubcontractConfirmation
+	confirmErr error
+}
+
+func newFakeRuns() *fakeRuns {
+	return &fakeRuns{rs: &entity.ProductionRunSubcontract{
+		RunId:        5,
+		SupplierId:   9,
+		SupplierName: "Atelier Nord",
+		UnitPrice:    decimal.RequireFromString("777.77"),
+		Currency:     "EUR",
+		PortalEpoch:  2,
+		Kit: []entity.SubcontractKitLine{{
+			MaterialId: 1, MaterialName: "Wool 320", Unit: "m",
+			Shipped: decimal.RequireFromString("42.5"), Returned: decimal.RequireFromString("1.5"),
+			UnitCostBase: decimal.NullDecimal{Decimal: decimal.RequireFromString("888.88"), Valid: true},
+		}},
+	}}
+}
+
+func (f *fakeRuns) GetRunSubcontract(_ context.Context, runID int) (*entity.ProductionRunSubcontract, error) {
+	if runID != f.rs.RunId {
+		return nil, entity.ErrSubcontractNotFound
+	}
+	return f.rs, nil
+}
+
+func (f *fakeRuns) GetRunPack(_ context.Context, runID int) (*entity.RunPack, error) {
+	return &entity.RunPack{
+		StyleNumber: "GR-01",
+		StyleName:   "Coat",
+		Lines: []entity.RunPackLine{
+			{ProductionRunLine: entity.ProductionRunLine{PlannedQty: 20}, ColorwayName: "black", SizeName: "M"},
+			{ProductionRunLine: entity.ProductionRunLine{PlannedQty: 15}, ColorwayName: "black", SizeName: "L"},
+		},
+	}, nil
+}
+
+func (f *fakeRuns) ConfirmRunSubcontract(_ context.Context, runID int, c entity.SubcontractConfirmation) (*entity.ProductionRunSubcontract, error) {
+	if f.confirmErr != nil {
+		return nil, f.confirmErr
+	}
+	f.confirmed = append(f.confirmed, c)
+	out := *f.rs
+	out.ConfirmedShipDate = sql.NullTime{Time: c.ShipDate, Valid: true}
+	out.ConfirmedQty = sql.NullInt32{Int32: int32(c.Qty), Valid: true}
+	out.FactoryNote = c.Note
+	out.ConfirmedAt = sql.NullTime{Time: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+	return &out, nil
+}
+
+func newTestService(t *testing.T, runs Runs) *Service {
+	t.Helper()
+	svc, err := New(runs, testPepper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Stop)
+	return svc
+}
+
+// servePortal routes через тот же mount, что и http.go.
+func servePortal(svc *Service, method, target, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost} {
+		r.Method(m, "/api/cmt/{token}", svc.Handler())
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+	return w
+}
+
+func TestPortalShapeCarriesNoMoney(t *testing.T) {
+	runs := newFakeRuns()
+	svc := newTestService(t, runs)
+	w := servePortal(svc, http.MethodGet, "/api/cmt/"+svc.MintPortalToken(runs.rs), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "private, no-store" {
+		t.Fatalf("cache-control = %q", w.Header().Get("Cache-Control"))
+	}
+	for _, money := range []string{"777.77", "888.88", "EUR"} {
+		if strings.Contains(w.Body.String(), money) {
+			t.Fatalf("portal leaks %s: %s", money, w.Body)
+		}
+	}
+	var got Portal
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RunId != 5 || got.StyleNumber != "GR-01" || got.FactoryName != "Atelier Nord" || len(got.Lines) != 2 {
+		t.Fatalf("portal = %+v", got)
+	}
+	if len(got.Kit) != 1 || got.Kit[0].Shipped != "42.5" || got.Kit[0].Returned != "1.5" {
+		t.Fatalf("kit = %+v", got.Kit)
+	}
+	if got.Confirmation != nil || got.Reconciled {
+		t.Fatalf("fresh run shows a confirmation: %+v", got)
+	}
+}
+
+func TestDeniedTokensAreNotFound(t *testing.T) {
+	runs := newFakeRuns()
+	svc := newTestService(t, runs)
+	m, _ := patterntoken.NewMinter(testPepper)
+	for _, tok := range []string{
+		m.Mint(patterntoken.ScopeRunPack, 5, 2),     // тот же прогон, ссылка наряда
+		m.Mint(patterntoken.ScopeSubcontract, 5, 1), // ротированная эпоха
+		m.Mint(patterntoken.ScopeSubcontract, 6, 1), // прогон не на подряде
+		"s5.2.AAAA",
+		"garbage",
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			w := servePortal(svc, method, "/api/cmt/"+tok, `{"ship_date":"2026-04-01","qty":35}`)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("%s %s = %d, want 404", method, tok, w.Code)
+			}
+		}
+	}
+	if len(runs.confirmed) != 0 {
+		t.Fatalf("a denied token confirmed %+v", runs.confirmed)
+	}
+}
+
+func TestRevokedAndExpiredAreNotFound(t *testing.T) {
+	for name, mutate := range map[string]func(*entity.ProductionRunSubcontract){
+		"revoked": func(rs *entity.ProductionRunSubcontract) {
+			rs.PortalRevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		},
+		"expired": func(rs *entity.ProductionRunSubcontract) {
+			rs.PortalExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+		},
+	} {
+		runs := newFakeRuns()
+		svc := newTestService(t, runs)
+		tok := svc.MintPortalToken(runs.rs)
+		mutate(runs.rs)
+		if w := servePortal(svc, http.MethodGet, "/api/cmt/"+tok, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: code = %d, want 404", name, w.Code)
+		}
+	}
+}
+
+func TestConfirmRecordsDateAndQty(t *testing.T) {
+	runs := newFakeRuns()
+	svc := newTestService(t, runs)
+	w := servePortal(svc, http.MethodPost, "/api/cmt/"+svc.MintPortalToken(runs.rs),
+		`{"ship_date":"2026-04-01","qty":35,"note":"  two days late on L  "}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d body=%s", w.Code, w.Body)
+	}
+	if len(runs.confirmed) != 1 {
+		t.Fatalf("confirmed = %d", len(runs.confirmed))
+	}
+	c := runs.confirmed[0]
+	if c.ShipDate.Format(time.DateOnly) != "2026-04-01" || c.Qty != 35 || c.Note.String != "two days late on L" {
+		t.Fatalf("confirmation = %+v", c)
+	}
+	var got Portal
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Confirmation == nil || got.Confirmation.ShipDate != "2026-04-01" || got.Confirmation.Qty != 35 {
+		t.Fatalf("confirmation echo = %+v", got.Confirmation)
+	}
+}
+
+func TestConfirmErrorsAreReadable(t *testing.T) {
+	cases := []struct {
+		err  error
+		body string
+		code int
+	}{
+		{nil, `not json`, http.StatusBadRequest},
+		{nil, `{"ship_date":"01.04.2026","qty":35}`, http.StatusBadRequest},
+		{nil, `{"ship_date":"2026-04-01","qty":0}`, http.StatusBadRequest},
+		{nil, `{"ship_date":"2026-04-01","qty":3,"note":"` + strings.Repeat("x", maxNoteLen+1) + `"}`, http.StatusBadRequest},
+		{entity.ErrSubcontractReconciled, `{"ship_date":"2026-04-01","qty":35}`, http.StatusConflict},
+		{errors.New("db down"), `{"ship_date":"2026-04-01","qty":35}`, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		runs := newFakeRuns()
+		runs.confirmErr = tc.err
+		svc := newTestService(t, runs)
+		w := servePortal(svc, http.MethodPost, "/api/cmt/"+svc.MintPortalToken(runs.rs), tc.body)
+		if w.Code != tc.code {
+			t.Fatalf("%v %.40s: code = %d, want %d", tc.err, tc.body, w.Code, tc.code)
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Fatalf("%v: body = %s", tc.err, w.Body)
+		}
+	}
+}
+
+func TestRateLimitedLooksLikeNotFound(t *testing.T) {
+	runs := newFakeRuns()
+	svc := newTestService(t, runs)
+	target := "/api/cmt/" + svc.MintPortalToken(runs.rs)
+	for i := 0; i < perTokenMax; i++ {
+		if w := servePortal(svc, http.MethodGet, target, ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i, w.Code)
+		}
+	}
+	if w := servePortal(svc, http.MethodGet, target, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("over budget = %d, want 404", w.Code)
+	}
+}
+
+func TestMintOnNilIsEmpty(t *testing.T) {
+	var svc *Service
+	if svc.MintPortalToken(&entity.ProductionRunSubcontract{RunId: 5, PortalEpoch: 1}) != "" {
+		t.Fatal("nil service must mint nothing")
+	}
+	live := newTestService(t, newFakeRuns())
+	if live.MintPortalToken(nil) != "" {
+		t.Fatal("no subcontract must mint nothing")
+	}
+}
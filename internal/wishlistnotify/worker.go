@@ -0,0 +1,236 @@
+// Package wishlistnotify runs a periodic job that mails storefront accounts about the items on
+// their wishlist (0350): when a colourway goes on sale or its markdown deepens, when its stock drops
+// to the last few pieces, and when it is restocked. The worker polls instead of hooking the writers —
+// sale_percentage moves through product edits, markdown events and price lists, stock through orders,
+// returns, adjustments and transfers — and compares with what it saw the previous tick (see
+// entity.WishlistWatch.Observe).
+package wishlistnotify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jekabolt/grbpwr-manager/internal/dependency"
+	"github.com/jekabolt/grbpwr-manager/internal/dto"
+	"github.com/jekabolt/grbpwr-manager/internal/entity"
+	"github.com/jekabolt/grbpwr-manager/internal/health"
+	"github.com/jekabolt/grbpwr-manager/internal/saferun"
+	"github.com/shopspring/decimal"
+)
+
+// tickTimeout bounds the work done in a single tick, so one stuck query can't
+// block the loop forever or stall graceful shutdown.
+const tickTimeout = 2 * time.Minute
+
+// Backoff bounds for consecutive-failure backoff (base * 2^(n-1), capped at
+// backoffMax); a successful tick resets it. Mirrors markdownsched.
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 5 * time.Minute
+)
+
+// Config configures the wishlist alert worker.
+type Config struct {
+	// WorkerInterval is how often wishlists are compared with the catalogue. A sale or a stock move
+	// that is undone within one interval is never seen.
+	WorkerInterval time.Duration `mapstructure:"worker_interval"`
+	// LowStockThreshold is the stock at or under which a wished item counts as almost gone.
+	LowStockThreshold int `mapstructure:"low_stock_threshold"`
+}
+
+// DefaultConfig returns sane defaults (run every five minutes, low stock at three pieces).
+func DefaultConfig() Config {
+	return Config{WorkerInterval: 5 * time.Minute, LowStockThreshold: 3}
+}
+
+// Worker periodically mails wishlist alerts.
+type Worker struct {
+	repo    dependency.Repository
+	mailer  dependency.Mailer
+	c       *Config
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	tracker health.Tracker
+}
+
+// Name implements health.Reporter.
+func (w *Worker) Name() string { return "wishlistnotify" }
+
+// LastSuccess implements health.Reporter (zero time until the first clean tick).
+func (w *Worker) LastSuccess() time.Time { return w.tracker.LastSuccess() }
+
+// New constructs a wishlist alert worker.
+func New(c *Config, repo dependency.Repository, mailer dependency.Mailer) *Worker {
+	if c == nil {
+		dc := DefaultConfig()
+		c = &dc
+	}
+	if c.WorkerInterval <= 0 {
+		c.WorkerInterval = DefaultConfig().WorkerInterval
+	}
+	if c.LowStockThreshold <= 0 {
+		c.LowStockThreshold = DefaultConfig().LowStockThreshold
+	}
+	return &Worker{repo: repo, mailer: mailer, c: c}
+}
+
+// Start launches the worker goroutine.
+func (w *Worker) Start(ctx context.Context) error {
+	if w.ctx != nil && w.stop != nil {
+		return fmt.Errorf("wishlist notify worker already started")
+	}
+	w.ctx, w.stop = context.WithCancel(ctx)
+	w.wg.Go(func() {
+		w.run(w.ctx)
+	})
+	return nil
+}
+
+// Stop signals the worker to exit and waits for its goroutine to return.
+func (w *Worker) Stop() error {
+	if w.stop == nil {
+		return fmt.Errorf("wishlist notify worker already stopped or not started")
+	}
+	w.stop()
+	w.stop = nil
+	w.wg.Wait()
+	return nil
+}
+
+func (w *Worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.c.WorkerInterval)
+	defer ticker.Stop()
+
+	var consecutiveFailures int
+	for {
+		select {
+		case <-ticker.C:
+			if w.runOnce(ctx) {
+				consecutiveFailures = 0
+				continue
+			}
+			consecutiveFailures++
+			delay := backoffDelay(consecutiveFailures)
+			slog.Default().WarnContext(ctx, "wishlistnotify: backing off after failed tick",
+				slog.Int("consecutive_failures", consecutiveFailures),
+				slog.Duration("delay", delay),
+			)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backoffDelay returns base * 2^(n-1), capped at backoffMax.
+func backoffDelay(consecutiveFailures int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < consecutiveFailures; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+// runOnce observes every wishlist item, queues the alerts due and records what it saw. An alert that
+// could not be queued keeps its part of the previous seen state, so it alone is tried again next
+// tick; the item's alerts that did go out are recorded and not sent twice.
+// An item of an account that opted out of marketing, or that the account may no longer see (a
+// tier-gated colourway after a downgrade), is recorded without mailing, so opting back in later does
+// not release a backlog of alerts.
+func (w *Worker) runOnce(ctx context.Context) bool {
+	defer saferun.Recover(ctx, "wishlistnotify")
+
+	tickCtx, cancel := context.WithTimeout(ctx, tickTimeout)
+	defer cancel()
+
+	watches, err := w.repo.Wishlists().ListWishlistWatches(tickCtx)
+	if err != nil {
+		w.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "wishlistnotify: can't list wishlist items", slog.String("err", err.Error()))
+		return false
+	}
+
+	lowStock := decimal.NewFromInt(int64(w.c.LowStockThreshold))
+	products := make(map[int]*entity.ColorwayFull)
+	seen := make([]entity.WishlistSeen, 0)
+	var queued, failed int
+	for i := range watches {
+		wt := &watches[i]
+		alerts, s, changed := wt.Observe(lowStock)
+		if !changed {
+			continue
+		}
+		if len(alerts) > 0 && wt.SubscribeNewsletter && wt.Visible() {
+			n, err := w.queueAlerts(tickCtx, products, wt, alerts)
+			queued += n
+			if err != nil {
+				failed++
+				slog.Default().ErrorContext(ctx, "wishlistnotify: can't queue wishlist alert",
+					slog.String("err", err.Error()),
+					slog.Int("item_id", wt.ItemId),
+					slog.Int("product_id", wt.ProductId),
+				)
+				s = wt.KeepUnsent(s, alerts[n:])
+			}
+		}
+		seen = append(seen, s)
+	}
+
+	if err := w.repo.Wishlists().RecordWishlistSeen(tickCtx, seen); err != nil {
+		w.tracker.MarkError(err)
+		slog.Default().ErrorContext(ctx, "wishlistnotify: can't record seen state", slog.String("err", err.Error()))
+		return false
+	}
+	if queued > 0 || failed > 0 {
+		slog.Default().InfoContext(ctx, "wishlistnotify: wishlist alerts queued",
+			slog.Int("queued", queued),
+			slog.Int("failed", failed),
+		)
+	}
+	if failed > 0 {
+		err := fmt.Errorf("%d wishlist items failed to queue alerts", failed)
+		w.tracker.MarkError(err)
+		return false
+	}
+	w.tracker.MarkSuccess()
+	return true
+}
+
+// queueAlerts queues one email per alert of the item and returns how many it queued. The colourway
+// is loaded once per tick however many wishlists hold it.
+func (w *Worker) queueAlerts(ctx context.Context, products map[int]*entity.ColorwayFull, wt *entity.WishlistWatch, alerts []entity.WishlistAlertKind) (int, error) {
+	prd, ok := products[wt.ProductId]
+	if !ok {
+		var err error
+		prd, err = w.repo.Products().GetProductByIdShowHidden(ctx, wt.ProductId, false)
+		if err != nil {
+			return 0, fmt.Errorf("can't get product: %w", err)
+		}
+		products[wt.ProductId] = prd
+	}
+
+	buyerName := strings.TrimSpace(wt.FirstName + " " + wt.LastName)
+	var n int
+	for _, kind := range alerts {
+		data := dto.ProductFullToWishlistAlert(prd, wt.SizeId, buyerName, wt.Email)
+		if err := w.mailer.QueueWishlistAlert(ctx, w.repo, wt.Email, kind, data); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}